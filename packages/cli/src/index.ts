@@ -1,12 +1,165 @@
 #!/usr/bin/env node
 
+import * as fs from 'fs';
+import * as readline from 'readline';
 import { Command } from 'commander';
+import { CheckOutput } from './utils/check-output.js';
 import { Logger } from './utils/logger.js';
 import { OutputFormatter } from './utils/output-formatter.js';
-import { CodebaseIndexer, RefactorableFile, TypeAbstraction } from '@refactogent/core';
+import { ReviewScreen } from './utils/review-screen.js';
+import {
+  analyzeGo,
+  analyzeGoTargets,
+  applyGoQuickFixes,
+  applyPlanWithJournal,
+  builtinGoRules,
+  CodebaseIndexer,
+  compareGoBaseline,
+  compareGoHealthSnapshots,
+  createGoAnalysisServer,
+  createGoBaseline,
+  createPlan,
+  defaultGoRuleRegistry,
+  diffGoApi,
+  evaluateGoGate,
+  formatGoApi,
+  formatGoDocCoverage,
+  formatGoExampleCoverage,
+  formatGoGateSummary,
+  formatGoProfileSummary,
+  formatGoRefactorPriorities,
+  formatGoRegressionReport,
+  GoCheckpoint,
+  GoConfigResolver,
+  goConfigRuleOptions,
+  GoFinding,
+  GoGateError,
+  goApiSurface,
+  goCheckstyleReport,
+  goDiffScope,
+  goDocCoverage,
+  goExampleCoverage,
+  goHealthSnapshot,
+  goLspDiagnostics,
+  goPipelineFromConfig,
+  goPprofProfile,
+  GoProfiler,
+  GoReviewPreview,
+  GoReviewSession,
+  goRefactorPriorities,
+  goSarifLog,
+  goTemplateData,
+  GoSuppressedFinding,
+  loadGoFiles,
+  parseGoMaintainabilityConstants,
+  parseGoPlatforms,
+  parseGoPriorityWeights,
+  parseGoSeverity,
+  parseGoSeverityOverrides,
+  parsePlan,
+  planGoPipeline,
+  PlannedChange,
+  pruneGoBaseline,
+  readCoverProfile,
+  readGitDiff,
+  readGoBaseline,
+  readGoHealthSnapshot,
+  readGoReportTemplate,
+  RefactorableFile,
+  RefactorPlan,
+  renderPlan,
+  rollback,
+  serializePlan,
+  streamGoFindings,
+  TypeAbstraction,
+  writeGoBaseline,
+  writeGoHealthSnapshot,
+} from '@refactogent/core';
 
 const program = new Command();
 
+// Prints where the time went, and writes the pprof profile when given a file
+function reportProfile(profiler: GoProfiler | undefined, output: string | boolean, root: string) {
+  if (!profiler) return;
+  const summary = profiler.summary();
+  process.stderr.write(formatGoProfileSummary(summary, { root }));
+  if (typeof output === 'string') fs.writeFileSync(output, goPprofProfile(summary));
+}
+
+// Runs the review until the user writes the approved suggestions (true) or quits (false)
+function reviewInTerminal(session: GoReviewSession, root: string): Promise<boolean> {
+  const count = session.items().length;
+  let selected = 0;
+  let message: string | undefined;
+  let previews = new Map<number, GoReviewPreview>();
+  // Approving changes the files every other preview is computed against
+  const refresh = () => {
+    previews = new Map(session.items().map(item => [item.id, session.preview(item.id)]));
+  };
+  const draw = () =>
+    process.stdout.write(
+      '\x1b[2J\x1b[H' +
+        ReviewScreen.render({
+          root,
+          groups: session.groups(),
+          previews,
+          selected,
+          rows: process.stdout.rows ?? 24,
+          columns: process.stdout.columns ?? 80,
+          message,
+        })
+    );
+  // The next item still open, or the current one when none is
+  const advance = () => {
+    const items = session.items();
+    const next = items.find(
+      item => item.id > selected && (item.status === 'pending' || item.status === 'skipped')
+    );
+    if (next) selected = next.id;
+  };
+
+  return new Promise(resolve => {
+    const done = (write: boolean) => {
+      process.stdin.off('keypress', onKey);
+      process.stdout.off('resize', draw);
+      process.stdin.setRawMode(false);
+      process.stdin.pause();
+      process.stdout.write('\x1b[?1049l');
+      resolve(write);
+    };
+    const onKey = (_: string, key: readline.Key) => {
+      message = undefined;
+      if (key.name === 'q' || (key.ctrl && key.name === 'c')) return done(false);
+      if (key.name === 'w') return done(true);
+      try {
+        if (key.name === 'down' || key.name === 'j') {
+          selected = Math.min(selected + 1, count - 1);
+        } else if (key.name === 'up' || key.name === 'k') {
+          selected = Math.max(selected - 1, 0);
+        } else if (key.name === 'a' || key.name === 'return') {
+          session.approve(selected);
+          refresh();
+          advance();
+        } else if (key.name === 's') {
+          session.skip(selected);
+          advance();
+        }
+      } catch (error) {
+        message = error instanceof Error ? error.message : String(error);
+      }
+      draw();
+    };
+
+    refresh();
+    readline.emitKeypressEvents(process.stdin);
+    process.stdin.setRawMode(true);
+    process.stdin.on('keypress', onKey);
+    process.stdout.on('resize', draw);
+    process.stdout.write('\x1b[?1049h');
+    draw();
+  });
+}
+
 // Global options
 program
   .name('refactogent')
@@ -24,7 +177,8 @@ program
   .option('-f, --format <format>', 'Output format (json|table|detailed)', 'detailed')
   .option('--include-tests', 'Include test creation in workflow')
   .option('--include-critique', 'Include validation critique in workflow')
-  .option('--dry-run', 'Analyze without making changes')
+  .option('--dry-run', 'Print the planned changes as unified diffs without writing files')
+  .option('--plan <file>', 'Write the planned changes to a JSON file for a later apply step')
   .option('--debug', 'Enable detailed debugging output showing LLM interactions')
   .option('--skip-type-abstraction', 'Skip type abstraction step')
   .option('--types-path <path>', 'Custom path for centralized types', 'src/types')
@@ -132,11 +286,17 @@ program
               });
             }
 
+            const plan = await typeAbstraction.planAbstractions(abstractionResults);
+            if (options.plan) {
+              fs.writeFileSync(options.plan, serializePlan(plan), 'utf-8');
+              logger.log(OutputFormatter.info(`Wrote refactor plan to ${options.plan}`));
+            }
+
             // Apply abstractions if not in dry-run mode
             if (!options.dryRun) {
               logger.log(OutputFormatter.info('Applying type abstractions...'));
               try {
-                await typeAbstraction.applyAbstractions(abstractionResults);
+                await typeAbstraction.applyAbstractions(plan);
                 logger.log(
                   OutputFormatter.success(
                     `Successfully applied ${abstractionResults.centralized.length + abstractionResults.local.length} type abstractions`
@@ -156,6 +316,7 @@ program
                 });
               }
             } else {
+              process.stdout.write(renderPlan(plan));
               logger.log(OutputFormatter.info('Dry run mode: Type abstractions not applied'));
             }
           } else {
@@ -193,6 +354,710 @@ program
     }
   });
 
+program
+  .command('apply')
+  .description('Apply a refactor plan written with --plan, or the quick fixes of chosen rules')
+  .argument('[plan]', 'Plan file to apply; with --rule, the path to fix')
+  .argument('[path]', 'Path the plan was made for', '.')
+  .option(
+    '--rule <ids>',
+    'Apply the fixes of these rules instead of a plan (comma-separated, repeatable)',
+    (value: string, previous: string[]) => [...previous, value],
+    []
+  )
+  .option('--dry-run', 'Print the changes as unified diffs without writing files')
+  .option('--allow-breaking', 'Apply plans that change exported API')
+  .option('--no-config', 'Ignore .refactogent.yaml files when running --rule')
+  .action(async (planFile, path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      let plan: RefactorPlan;
+      let summary = 'Applied refactor plan to';
+      if (options.rule.length > 0) {
+        // The only argument names the path to fix
+        const root = planFile ?? '.';
+        const rules = options.rule
+          .flatMap((value: string) => value.split(','))
+          .map((rule: string) => rule.trim())
+          .filter(Boolean);
+        const config = options.config ? new GoConfigResolver(root) : undefined;
+        const changes: PlannedChange[] = [];
+        let fixed = 0;
+        for await (const batch of streamGoFindings(await loadGoFiles(root), { config })) {
+          const result = applyGoQuickFixes(batch.files, batch.findings, {
+            rules,
+            ruleOptions: goConfigRuleOptions(batch.config?.config ?? {}),
+          });
+          changes.push(...result.changes);
+          fixed += result.applied.length;
+          for (const { finding, reason } of result.skipped) {
+            logger.log(
+              OutputFormatter.info(
+                `Skipped ${finding.rule} at ${finding.filePath}:${finding.line}: ${reason}`
+              )
+            );
+          }
+        }
+        plan = createPlan(root, changes);
+        path = root;
+        summary = `Applied ${fixed} fixes of ${rules.join(', ')} to`;
+      } else if (planFile) {
+        plan = parsePlan(fs.readFileSync(planFile, 'utf-8'));
+      } else {
+        throw new Error('apply needs a plan file, or --rule');
+      }
+
+      if (options.dryRun) {
+        process.stdout.write(renderPlan(plan));
+        return;
+      }
+
+      const { written, journal } = await applyPlanWithJournal(plan, path, {
+        allowBreaking: options.allowBreaking,
+      });
+      logger.log(OutputFormatter.success(`${summary} ${written.length} files`));
+      logger.log(OutputFormatter.info(`Undo with: refactogent rollback ${journal.id}`));
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to apply refactor plan'));
+      logger.error('Apply failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(1);
+    }
+  });
+
+program
+  .command('rollback')
+  .description('Restore the files an apply changed, unless they were edited since')
+  .argument('<journal>', 'Journal ID printed by apply')
+  .action(async (journal, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const restored = await rollback(journal);
+      logger.log(OutputFormatter.success(`Restored ${restored.length} files`));
+    } catch (error) {
+      logger.log(OutputFormatter.error('Rollback failed'));
+      logger.error('Rollback failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(1);
+    }
+  });
+
+program
+  .command('review')
+  .description('Step through Go quick fixes, previewing each diff, and write the approved ones')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option('--rule <ids>', 'Review only the fixes of these rules (comma-separated)')
+  .option('--coverage <profile>', 'Score confidence with a go test -coverprofile file')
+  .option('--allow-breaking', 'Write approved suggestions that change exported API')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      if (!process.stdin.isTTY || !process.stdout.isTTY) {
+        throw new Error('review needs an interactive terminal; use apply --rule --dry-run instead');
+      }
+      const session = new GoReviewSession(path, await loadGoFiles(path), {
+        rules: options.rule
+          ? options.rule.split(',').map((rule: string) => rule.trim())
+          : undefined,
+        coverage: options.coverage ? await readCoverProfile(options.coverage) : undefined,
+      });
+      if (session.items().length === 0) {
+        logger.log(OutputFormatter.info('No suggestions to review'));
+        return;
+      }
+      if (!(await reviewInTerminal(session, path))) {
+        logger.log(OutputFormatter.info('Quit without writing any files'));
+        return;
+      }
+
+      const approved = session.items().filter(item => item.status === 'approved').length;
+      const plan = session.plan();
+      if (plan.files.length === 0) {
+        logger.log(OutputFormatter.info('No suggestions approved; nothing written'));
+        return;
+      }
+      const { written, journal } = await applyPlanWithJournal(plan, path, {
+        allowBreaking: options.allowBreaking,
+      });
+      logger.log(
+        OutputFormatter.success(`Applied ${approved} suggestions to ${written.length} files`)
+      );
+      logger.log(OutputFormatter.info(`Undo with: refactogent rollback ${journal.id}`));
+    } catch (error) {
+      logger.log(OutputFormatter.error('Review failed'));
+      logger.error('Review failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(1);
+    }
+  });
+
+program
+  .command('pipeline')
+  .description('Run a config of Go transforms in order and write the result once')
+  .argument('<config>', 'JSON pipeline config: {"stages": [{"transform": ..., "options": ...}]}')
+  .argument('[path]', 'Path to transform', '.')
+  .option('--dry-run', 'Print the combined changes as unified diffs without writing files')
+  .option('--plan <file>', 'Write the combined changes to a JSON file for a later apply step')
+  .option('--allow-breaking', 'Apply changes to exported API')
+  .action(async (configFile, path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const pipeline = goPipelineFromConfig(JSON.parse(fs.readFileSync(configFile, 'utf-8')));
+      const plan = planGoPipeline(path, pipeline, await loadGoFiles(path));
+
+      if (options.plan) {
+        fs.writeFileSync(options.plan, serializePlan(plan), 'utf-8');
+        logger.log(OutputFormatter.info(`Wrote refactor plan to ${options.plan}`));
+      }
+      if (options.dryRun) {
+        process.stdout.write(renderPlan(plan));
+        return;
+      }
+
+      const { written, journal } = await applyPlanWithJournal(plan, path, {
+        allowBreaking: options.allowBreaking,
+      });
+      logger.log(
+        OutputFormatter.success(
+          `Ran ${pipeline.stages.length} stages and wrote ${written.length} files`
+        )
+      );
+      logger.log(OutputFormatter.info(`Undo with: refactogent rollback ${journal.id}`));
+    } catch (error) {
+      logger.log(OutputFormatter.error('Pipeline failed; no files were written'));
+      logger.error('Pipeline failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(1);
+    }
+  });
+
+program
+  .command('check')
+  .description('Run the Go rules and exit non-zero when findings exceed the CI thresholds')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option(
+    '--fail-on <severity>',
+    'Lowest severity that fails (high|medium|low; default: failOn of the config, or high)'
+  )
+  .option(
+    '--severity <rule=severity>',
+    'Override the severity of a rule, or switch it off (repeatable)',
+    (value: string, previous: string[]) => [...previous, value],
+    []
+  )
+  .option('--max-warnings <n>', 'Fail when more findings than this are below --fail-on')
+  .option('--baseline <file>', 'Only report findings the baseline file does not record')
+  .option('--format <format>', 'Output format (text|jsonl|lsp|sarif|checkstyle)', 'text')
+  .option('--template <file>', 'Render the findings with a Go text/template file instead')
+  .option('--base <ref>', 'Only report findings in code changed since this Git revision')
+  .option('--head <ref>', 'Revision compared with --base (default: the working tree)')
+  .option(
+    '--platform <targets>',
+    'Check the files built for each goos/goarch target, e.g. linux/amd64,windows/amd64'
+  )
+  .option('--profile [file]', 'Print time spent per phase and file; write a pprof profile to file')
+  .option(
+    '--checkpoint [dir]',
+    'Save the results of each package as it is checked, and resume unchanged packages from them'
+  )
+  .option('--fresh', 'Discard the saved checkpoint and check every package again')
+  .option('--no-config', 'Ignore .refactogent.yaml files')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      if (options.fresh && !options.checkpoint) {
+        throw new GoGateError('--fresh needs --checkpoint');
+      }
+      const checkpoint = options.checkpoint
+        ? new GoCheckpoint(
+            options.checkpoint === true ? '.refactogent/checkpoint' : options.checkpoint,
+            { version: program.version() }
+          )
+        : undefined;
+      if (options.fresh) await checkpoint.clear();
+      const profiler = options.profile ? new GoProfiler() : undefined;
+      const config = options.config ? new GoConfigResolver(path) : undefined;
+      const rootConfig = config?.rootConfig() ?? {};
+      // Options given on the command line win over the root config
+      const failOn = options.failOn
+        ? parseGoSeverity(options.failOn)
+        : rootConfig.failOn ?? 'high';
+      if (failOn === 'off') {
+        throw new GoGateError('--fail-on must be high, medium or low');
+      }
+      const maxWarnings =
+        options.maxWarnings === undefined ? rootConfig.maxWarnings : Number(options.maxWarnings);
+      if (!['text', 'jsonl', 'lsp', 'sarif', 'checkstyle'].includes(options.format)) {
+        throw new GoGateError(
+          `Unknown format ${options.format}; expected text, jsonl, lsp, sarif or checkstyle`
+        );
+      }
+
+      if (options.template && options.format !== 'text') {
+        throw new GoGateError('--template replaces --format');
+      }
+      // Checked before any analysis, so a broken template fails fast
+      const template = options.template ? await readGoReportTemplate(options.template) : undefined;
+
+      if (options.head && !options.base) {
+        throw new GoGateError('--head needs --base');
+      }
+      const platforms = options.platform ? parseGoPlatforms(options.platform) : undefined;
+      const output = new CheckOutput({
+        format: options.format,
+        root: path,
+        severities: parseGoSeverityOverrides(options.severity),
+        platforms,
+      });
+
+      let files = await loadGoFiles(path, { profiler });
+      const scope = options.base
+        ? goDiffScope(files, await readGitDiff(path, options.base, options.head), { root: path })
+        : undefined;
+      // Only packages the diff touches need checking
+      if (scope) files = scope.packageFiles(files);
+      const baseline = options.baseline ? await readGoBaseline(options.baseline) : undefined;
+      const findings: GoFinding[] = [];
+      const suppressed: GoFinding[] = [];
+      const ignored: GoSuppressedFinding[] = [];
+      // Findings are written per package as they are found, one write per line
+      const stream = streamGoFindings(files, { platforms, profiler, config, checkpoint });
+      for await (const batch of stream) {
+        if (batch.config) {
+          logger.debug('Configuration applied', {
+            directory: batch.config.directory,
+            sources: batch.config.sources,
+            config: batch.config.config,
+          });
+        }
+        let reported = scope ? scope.filter(batch.findings) : batch.findings;
+        ignored.push(...batch.suppressed);
+        if (baseline) {
+          const comparison = compareGoBaseline(reported, batch.files, baseline, { root: path });
+          reported = comparison.findings;
+          suppressed.push(...comparison.suppressed);
+        }
+        // Reports show the severities the gate counts, without rules switched off
+        reported = output.findings(reported);
+        // LSP diagnostics, SARIF logs, CheckStyle reports and templates are
+        // documents written once at the end
+        const documents = template || ['lsp', 'sarif', 'checkstyle'].includes(options.format);
+        for (const finding of documents ? [] : reported) {
+          process.stdout.write(output.line(finding));
+        }
+        findings.push(...reported);
+      }
+      if (checkpoint) {
+        // Entries of packages no longer in the tree are dropped once every
+        // package has been checked
+        const pruned = scope ? 0 : await checkpoint.prune();
+        logger.debug('Checkpoint applied', { ...checkpoint.getStats(), pruned });
+      }
+      if (options.format === 'lsp') {
+        process.stdout.write(JSON.stringify(goLspDiagnostics(findings, files), null, 2) + '\n');
+      }
+      if (options.format === 'sarif') {
+        const log = goSarifLog(findings, files, {
+          root: path,
+          toolVersion: program.version(),
+          suppressed: ignored,
+        });
+        process.stdout.write(JSON.stringify(log, null, 2) + '\n');
+      }
+      if (options.format === 'checkstyle') {
+        process.stdout.write(goCheckstyleReport(findings, files, { root: path }));
+      }
+      if (template) {
+        const data = goTemplateData(findings, files, {
+          root: path,
+          version: program.version(),
+          rules: builtinGoRules(),
+        });
+        process.stdout.write(template.render(data));
+      }
+      logger.debug('Inline suppressions applied', {
+        suppressed: ignored.length,
+        reasons: ignored.map(
+          ({ finding, suppression }) =>
+            `${finding.filePath}:${finding.line} ${finding.rule}: ${suppression.reason}`
+        ),
+      });
+      if (baseline) {
+        const all = [...findings, ...suppressed];
+        logger.debug('Baseline applied', {
+          suppressed: suppressed.length,
+          stale: compareGoBaseline(all, files, baseline, { root: path }).stale.length,
+        });
+      }
+      const result = evaluateGoGate(findings, {
+        failOn,
+        ...(maxWarnings !== undefined && { maxWarnings }),
+      });
+      process.stderr.write(formatGoGateSummary(result) + '\n');
+      reportProfile(profiler, options.profile, path);
+      process.exitCode = result.exitCode;
+    } catch (error) {
+      logger.log(OutputFormatter.error('Check failed'));
+      logger.error('Check failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('baseline')
+  .description('Record the current Go findings so check only reports new ones')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option('-f, --file <file>', 'Baseline file', '.refactogent/baseline.json')
+  .option('--update', 'Only drop entries for fixed findings, recording no new ones')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const files = await loadGoFiles(path);
+      const findings = defaultGoRuleRegistry().run(files);
+      const baseline = options.update
+        ? pruneGoBaseline(await readGoBaseline(options.file), findings, files, { root: path })
+        : createGoBaseline(findings, files, { root: path });
+      await writeGoBaseline(options.file, baseline);
+      logger.log(
+        OutputFormatter.success(
+          `Wrote ${baseline.entries.length} baseline entries to ${options.file}`
+        )
+      );
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to write baseline'));
+      logger.error('Baseline failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(1);
+    }
+  });
+
+program
+  .command('api')
+  .description('List the exported Go API, one sorted line per symbol, for diffing between versions')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option('--json', 'Print the entries as JSON instead of the listing')
+  .option('--check <file>', 'Exit non-zero when the API differs from a saved listing')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const entries = goApiSurface(await loadGoFiles(path), { root: path });
+      if (!options.check) {
+        process.stdout.write(
+          options.json ? JSON.stringify(entries, null, 2) + '\n' : formatGoApi(entries)
+        );
+        return;
+      }
+
+      const { added, removed } = diffGoApi(
+        fs.readFileSync(options.check, 'utf-8'),
+        formatGoApi(entries)
+      );
+      for (const line of removed) process.stdout.write(`- ${line}\n`);
+      for (const line of added) process.stdout.write(`+ ${line}\n`);
+      if (added.length + removed.length === 0) {
+        logger.log(OutputFormatter.success(`API matches ${options.check}`));
+        return;
+      }
+      logger.log(
+        OutputFormatter.error(
+          `API differs from ${options.check}: ${removed.length} removed, ${added.length} added`
+        )
+      );
+      process.exitCode = 1;
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to list the API'));
+      logger.error('API listing failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('snapshot')
+  .description('Save the Go findings, complexity, coverage and API, to compare releases with')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option('-f, --file <file>', 'Snapshot file', '.refactogent/snapshot.json')
+  .option('--coverage <profile>', 'Record test coverage from a go test -coverprofile file')
+  .option('--label <label>', 'What the snapshot is of, e.g. a release tag')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const files = await loadGoFiles(path);
+      const snapshot = goHealthSnapshot(files, defaultGoRuleRegistry().run(files), {
+        root: path,
+        label: options.label,
+        coverage: options.coverage ? await readCoverProfile(options.coverage) : undefined,
+      });
+      await writeGoHealthSnapshot(options.file, snapshot);
+      logger.log(
+        OutputFormatter.success(
+          `Wrote a snapshot of ${snapshot.functions.length} functions and ${snapshot.findings.length} findings to ${options.file}`
+        )
+      );
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to write snapshot'));
+      logger.error('Snapshot failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('compare')
+  .description('Report the regressions between two Go snapshots, for a release checklist')
+  .argument('<before>', 'Snapshot of the older version')
+  .argument('<after>', 'Snapshot of the newer version')
+  .option('--json', 'Print the comparison as JSON instead of the report')
+  .action(async (before, after, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const comparison = compareGoHealthSnapshots(
+        await readGoHealthSnapshot(before),
+        await readGoHealthSnapshot(after)
+      );
+      process.stdout.write(
+        options.json
+          ? JSON.stringify(comparison, null, 2) + '\n'
+          : formatGoRegressionReport(comparison)
+      );
+      if (comparison.regressed) process.exitCode = 1;
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to compare snapshots'));
+      logger.error('Snapshot comparison failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('doc-coverage')
+  .description('Measure the share of the exported Go API with doc comments')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option('--json', 'Print the coverage and every symbol as JSON')
+  .option('--min <percent>', 'Exit non-zero when coverage is below this percentage')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const coverage = goDocCoverage(await loadGoFiles(path), { root: path });
+      process.stdout.write(
+        options.json
+          ? JSON.stringify(coverage, null, 2) + '\n'
+          : formatGoDocCoverage(coverage, { root: path })
+      );
+      if (options.min !== undefined && coverage.percent < Number(options.min)) {
+        logger.log(
+          OutputFormatter.error(
+            `Documentation coverage ${coverage.percent.toFixed(1)}% is below ${options.min}%`
+          )
+        );
+        process.exitCode = 1;
+      }
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to measure documentation coverage'));
+      logger.error('Documentation coverage failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('examples')
+  .description('List Go example functions and the exported API they leave uncovered')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option('--json', 'Print the coverage, every symbol and every example as JSON')
+  .option('--min <percent>', 'Exit non-zero when coverage is below this percentage')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const coverage = goExampleCoverage(await loadGoFiles(path), { root: path });
+      process.stdout.write(
+        options.json
+          ? JSON.stringify(coverage, null, 2) + '\n'
+          : formatGoExampleCoverage(coverage, { root: path })
+      );
+      const rejected = coverage.examples.filter((example) => example.problems.length > 0);
+      if (rejected.length > 0) {
+        logger.log(OutputFormatter.error(`${rejected.length} example(s) rejected by go vet`));
+        process.exitCode = 1;
+      }
+      if (options.min !== undefined && coverage.percent < Number(options.min)) {
+        logger.log(
+          OutputFormatter.error(
+            `Example coverage ${coverage.percent.toFixed(1)}% is below ${options.min}%`
+          )
+        );
+        process.exitCode = 1;
+      }
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to list examples'));
+      logger.error('Example listing failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('priorities')
+  .description('Rank Go functions by refactor priority, with the factors behind each score')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option('--coverage <profile>', 'Rate test coverage from a go test -coverprofile file')
+  .option('--weights <weights>', 'Factor weights, e.g. complexity=3,coverage=2,fanIn=1,effort=0.5')
+  .option('--limit <count>', 'Show only the highest priority functions')
+  .option('--json', 'Print the ranked items as JSON')
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const items = goRefactorPriorities(await loadGoFiles(path), {
+        weights: options.weights ? parseGoPriorityWeights(options.weights) : undefined,
+        coverage: options.coverage ? await readCoverProfile(options.coverage) : undefined,
+        limit: options.limit !== undefined ? Number(options.limit) : undefined,
+      });
+      process.stdout.write(
+        options.json
+          ? JSON.stringify(items, null, 2) + '\n'
+          : formatGoRefactorPriorities(items, { root: path })
+      );
+    } catch (error) {
+      logger.log(OutputFormatter.error('Failed to rank refactor priorities'));
+      logger.error('Refactor priorities failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('analyze-go')
+  .description('Print the symbols, findings and call graph of Go code as one JSON document')
+  .argument('[path]', 'Root directory of the Go code', '.')
+  .option(
+    '--platform <targets>',
+    'Check the files built for each goos/goarch target, e.g. linux/amd64,windows/amd64'
+  )
+  .option('--profile [file]', 'Print time spent per phase and file; write a pprof profile to file')
+  .option(
+    '--symbols <ids>',
+    'Analyze only these symbols and their packages, e.g. main.DataProcessor.ProcessData,store.Save'
+  )
+  .option('--recover', 'Analyze files with syntax errors as far as they parse instead of failing')
+  .option(
+    '--maintainability <constants>',
+    'Maintainability index constants, e.g. base=171,complexity=0.23,lines=16.2,comments=50,scale=171'
+  )
+  .action(async (path, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    try {
+      const maintainability = options.maintainability
+        ? parseGoMaintainabilityConstants(options.maintainability)
+        : undefined;
+      if (options.symbols) {
+        if (options.platform || options.profile || options.recover) {
+          throw new Error('--symbols cannot be combined with --platform, --profile or --recover');
+        }
+        const symbols = options.symbols.split(',').map((id: string) => id.trim());
+        const result = await analyzeGoTargets(path, symbols, { maintainability });
+        process.stdout.write(JSON.stringify(result, null, 2) + '\n');
+        return;
+      }
+      const platforms = options.platform ? parseGoPlatforms(options.platform) : undefined;
+      const profiler = options.profile ? new GoProfiler() : undefined;
+      const result = await analyzeGo(path, {
+        platforms,
+        profiler,
+        recover: options.recover,
+        maintainability,
+      });
+      process.stdout.write(JSON.stringify(result, null, 2) + '\n');
+      reportProfile(profiler, options.profile, path);
+    } catch (error) {
+      logger.log(OutputFormatter.error('Go analysis failed'));
+      logger.error('Go analysis failed', {
+        error: error instanceof Error ? error.message : String(error),
+      });
+
+      process.exit(2);
+    }
+  });
+
+program
+  .command('serve')
+  .description('Serve Go symbols and findings over HTTP (POST /analyze, GET /symbols)')
+  .argument('[root]', 'Directory requests may analyze', '.')
+  .option('--port <port>', 'Port to listen on', '8080')
+  .option('--host <host>', 'Address to listen on', '127.0.0.1')
+  .option('--concurrency <n>', 'Analyses run at the same time', '2')
+  .option('--max-queued <n>', 'Requests waiting before new ones are refused', '16')
+  .action((root, options, command) => {
+    const globalOpts = command.parent.opts();
+    const logger = new Logger(globalOpts.verbose);
+
+    const server = createGoAnalysisServer({
+      root,
+      concurrency: Number(options.concurrency),
+      maxQueued: Number(options.maxQueued),
+    });
+    server.on('error', error => {
+      logger.log(OutputFormatter.error('Server failed'));
+      logger.error('Server failed', { error: error.message });
+      process.exit(1);
+    });
+    server.listen(Number(options.port), options.host, () => {
+      logger.log(
+        OutputFormatter.success(`Serving ${root} on http://${options.host}:${options.port}`)
+      );
+    });
+  });
+
 // Configure help
 program.configureHelp({
   sortSubcommands: true,
@@ -0,0 +1,45 @@
+import {
+  applyGoSeverityOverrides,
+  formatGoFindingJsonLine,
+  GoFinding,
+  GoGateSeverity,
+  GoPlatform,
+  GoPlatformFinding,
+} from '@refactogent/core';
+
+export interface CheckOutputOptions {
+  format: string;
+  root: string;
+  /** Severity per rule ID from `--severity`; `off` leaves the rule out */
+  severities: Record<string, GoGateSeverity>;
+  /** The platform matrix, when checking more than the host platform */
+  platforms?: GoPlatform[];
+}
+
+export class CheckOutput {
+  constructor(private readonly options: CheckOutputOptions) {}
+
+  /** Findings with the overridden severities, without rules switched off */
+  findings<T extends GoFinding>(findings: T[]): T[] {
+    return applyGoSeverityOverrides(findings, this.options.severities);
+  }
+
+  /** The line written for a finding in the text and jsonl formats */
+  line(finding: GoFinding | GoPlatformFinding): string {
+    if (this.options.format === 'jsonl') {
+      return formatGoFindingJsonLine(finding, { root: this.options.root });
+    }
+    return (
+      `${finding.filePath}:${finding.line}:${finding.column}: ` +
+      `${finding.severity} ${finding.rule}: ${finding.message}` +
+      `${this.onlyOn(finding)}\n`
+    );
+  }
+
+  // The targets a finding is limited to, when the matrix has others
+  private onlyOn(finding: GoFinding | GoPlatformFinding): string {
+    const { platforms } = this.options;
+    if (!platforms || !('platforms' in finding)) return '';
+    return finding.platforms.length < platforms.length ? ` [${finding.platforms.join(', ')}]` : '';
+  }
+}
@@ -66,6 +66,7 @@ export class OutputFormatter {
 Usage: refactogent [options] [command]
 
 Commands:
+  apply [options] <plan> [path]  Apply a refactor plan written with --plan, exactly as reviewed
   refactor [options] [path]  Complete refactoring workflow: analyze + suggest + apply AI-powered changes
   help [command]             display help for command
 
@@ -80,6 +81,8 @@ Examples:
   refactogent refactor                    # Analyze current directory
   refactogent refactor ./src --verbose    # Analyze src directory with verbose output
   refactogent refactor --include-tests    # Include test files in analysis
+  refactogent refactor --dry-run --plan plan.json  # Review diffs, save the plan
+  refactogent apply plan.json             # Apply the reviewed plan
 `;
   }
 }
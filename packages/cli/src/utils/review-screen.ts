@@ -0,0 +1,119 @@
+import * as path from 'path';
+import chalk from 'chalk';
+import { GoReviewGroup, GoReviewPreview, GoReviewStatus } from '@refactogent/core';
+
+const MARKS: Record<GoReviewStatus, string> = {
+  pending: ' ',
+  approved: chalk.green('✓'),
+  skipped: chalk.yellow('-'),
+  resolved: chalk.dim('='),
+};
+
+export const REVIEW_KEYS =
+  '↑/k ↓/j move · a approve · s skip · w write approved and quit · q quit without writing';
+
+export interface ReviewScreenState {
+  root: string;
+  groups: GoReviewGroup[];
+  /** Previews by item id */
+  previews: Map<number, GoReviewPreview>;
+  selected: number;
+  rows: number;
+  columns: number;
+  /** Shown above the key help, e.g. why an approve failed */
+  message?: string;
+}
+
+export class ReviewScreen {
+  /** A one-line summary of an item's confidence and impact */
+  static estimate(preview: GoReviewPreview): string {
+    const confidence = preview.confidence
+      ? `confidence ${preview.confidence.score.toFixed(2)}`
+      : 'confidence -';
+    if (!preview.file) return `${confidence} · ${preview.reason}`;
+    const { impact } = preview.file;
+    const references = `${impact.references} ${impact.references === 1 ? 'edit' : 'edits'}`;
+    const breaking = impact.breaking ? ` · breaks ${impact.exportedChanges.join(', ')}` : '';
+    return `${confidence} · ${references}${breaking}`;
+  }
+
+  static diff(diff: string): string[] {
+    return diff
+      .replace(/\n$/, '')
+      .split('\n')
+      .map(line => {
+        if (line.startsWith('+++') || line.startsWith('---')) return chalk.bold(line);
+        if (line.startsWith('+')) return chalk.green(line);
+        if (line.startsWith('-')) return chalk.red(line);
+        if (line.startsWith('@@')) return chalk.cyan(line);
+        return line;
+      });
+  }
+
+  /** The whole screen, fitted to the terminal's rows */
+  static render(state: ReviewScreenState): string {
+    const items = state.groups.flatMap(group => group.items);
+    const counts = (status: GoReviewStatus) => items.filter(item => item.status === status).length;
+    const header = chalk.bold(
+      `Review ${items.length} suggestions: ${counts('pending')} pending, ${counts('approved')} approved, ${counts('skipped')} skipped, ${counts('resolved')} resolved`
+    );
+
+    // The list gets up to half the screen, scrolled to keep the selection in view
+    const list: string[] = [];
+    let selectedRow = 0;
+    for (const group of state.groups) {
+      list.push(chalk.bold.underline(path.relative(state.root, group.filePath)));
+      for (const item of group.items) {
+        if (item.id === state.selected) selectedRow = list.length;
+        const pointer = item.id === state.selected ? chalk.cyan('›') : ' ';
+        const preview = state.previews.get(item.id);
+        const line = `${pointer} ${MARKS[item.status]} ${String(item.finding.line).padStart(5)}  ${item.finding.rule.padEnd(26)} ${preview ? ReviewScreen.estimate(preview) : ''}`;
+        list.push(item.status === 'resolved' ? chalk.dim(line) : line);
+      }
+    }
+    const listRows = Math.max(3, Math.floor((state.rows - 4) / 2));
+    const first = Math.min(
+      Math.max(0, selectedRow - Math.floor(listRows / 2)),
+      Math.max(0, list.length - listRows)
+    );
+    const shown = list.slice(first, first + listRows);
+
+    const selected = state.previews.get(state.selected);
+    const detail: string[] = [];
+    if (selected) {
+      detail.push(chalk.bold(selected.item.finding.message));
+      if (selected.confidence) {
+        const doubts = selected.confidence.factors.filter(factor => factor.score < 1);
+        detail.push(
+          `confidence ${selected.confidence.score.toFixed(2)}` +
+            (doubts.length > 0
+              ? ` (${doubts.map(factor => `${factor.name}: ${factor.reason}`).join('; ')})`
+              : '')
+        );
+      }
+      if (selected.file) {
+        detail.push(...ReviewScreen.diff(selected.file.diff));
+      } else {
+        detail.push(chalk.yellow(`No preview: ${selected.reason}`));
+      }
+    }
+    const rule = chalk.dim('─'.repeat(Math.max(0, state.columns)));
+    const detailRows = Math.max(1, state.rows - shown.length - 5);
+    const clipped =
+      detail.length > detailRows
+        ? [
+            ...detail.slice(0, detailRows - 1),
+            chalk.dim(`… ${detail.length - detailRows + 1} more lines`),
+          ]
+        : detail;
+
+    return [
+      header,
+      ...shown,
+      rule,
+      ...clipped,
+      rule,
+      state.message ? chalk.yellow(state.message) : chalk.dim(REVIEW_KEYS),
+    ].join('\n');
+  }
+}
@@ -0,0 +1,65 @@
+import { describe, it, expect } from '@jest/globals';
+import { GoFinding, parseGoSeverityOverrides } from '@refactogent/core';
+import { CheckOutput } from '../src/utils/check-output';
+
+const finding = (rule: string, severity: GoFinding['severity'], line: number): GoFinding => ({
+  rule,
+  severity,
+  filePath: '/repo/main.go',
+  line,
+  column: 2,
+  message: `${rule} on line ${line}`,
+});
+
+const findings = [
+  finding('ignored-error', 'high', 4),
+  finding('shadowed-variable', 'medium', 9),
+  finding('too-many-parameters', 'low', 12),
+];
+
+const output = (format: string) =>
+  new CheckOutput({
+    format,
+    root: '/repo',
+    severities: parseGoSeverityOverrides(['shadowed-variable=error', 'too-many-parameters=off']),
+  });
+
+describe('CheckOutput', () => {
+  it('should print the overridden severities and leave out rules switched off', () => {
+    const text = output('text');
+    const lines = text.findings(findings).map(f => text.line(f));
+
+    expect(lines).toEqual([
+      '/repo/main.go:4:2: high ignored-error: ignored-error on line 4\n',
+      '/repo/main.go:9:2: high shadowed-variable: shadowed-variable on line 9\n',
+    ]);
+  });
+
+  it('should write the overridden severities to JSON lines', () => {
+    const jsonl = output('jsonl');
+    const lines = jsonl.findings(findings).map(f => JSON.parse(jsonl.line(f)));
+
+    expect(lines.map(line => [line.rule, line.severity])).toEqual([
+      ['ignored-error', 'high'],
+      ['shadowed-variable', 'high'],
+    ]);
+    expect(lines.filter(line => line.severity === 'medium')).toEqual([]);
+  });
+
+  it('should mark findings limited to some of the platforms checked', () => {
+    const text = new CheckOutput({
+      format: 'text',
+      root: '/repo',
+      severities: {},
+      platforms: [
+        { goos: 'linux', goarch: 'amd64' },
+        { goos: 'windows', goarch: 'amd64' },
+      ],
+    });
+
+    expect(text.line({ ...findings[0], platforms: ['linux/amd64'] })).toBe(
+      '/repo/main.go:4:2: high ignored-error: ignored-error on line 4 [linux/amd64]\n'
+    );
+    expect(text.line(findings[0])).toBe('/repo/main.go:4:2: high ignored-error: ignored-error on line 4\n');
+  });
+});
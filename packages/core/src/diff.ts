@@ -0,0 +1,191 @@
+/**
+ * Text Edits and Unified Diffs
+ * ============================
+ * Refactors describe their changes as offset-based text edits against the
+ * original source, then render the result as a unified diff for review.
+ */
+
+/**
+ * Replace the text between two UTF-16 offsets
+ */
+export interface TextEdit {
+  start: number;
+  end: number;
+  newText: string;
+}
+
+/**
+ * Options for rendering a unified diff
+ */
+export interface UnifiedDiffOptions {
+  oldPath?: string;
+  newPath?: string;
+  /** Unchanged lines shown around each change; defaults to 3 */
+  context?: number;
+}
+
+/**
+ * Apply non-overlapping edits to a source string
+ */
+export function applyEdits(source: string, edits: TextEdit[]): string {
+  const sorted = [...edits].sort((a, b) => a.start - b.start || a.end - b.end);
+  let result = "";
+  let cursor = 0;
+  for (const edit of sorted) {
+    if (edit.start < cursor) {
+      throw new Error(
+        `Overlapping edits at offset ${edit.start} (previous edit ends at ${cursor})`,
+      );
+    }
+    result += source.slice(cursor, edit.start) + edit.newText;
+    cursor = edit.end;
+  }
+  return result + source.slice(cursor);
+}
+
+type DiffOp = { kind: "equal" | "delete" | "insert"; line: string };
+
+/**
+ * Myers' O(ND) line diff
+ */
+function diffLines(a: string[], b: string[]): DiffOp[] {
+  const max = a.length + b.length;
+  const offset = max + 1;
+  const v = new Array<number>(2 * max + 3).fill(0);
+  const trace: number[][] = [];
+
+  outer: for (let d = 0; d <= max; d++) {
+    trace.push(v.slice());
+    for (let k = -d; k <= d; k += 2) {
+      let x =
+        k === -d || (k !== d && v[offset + k - 1] < v[offset + k + 1])
+          ? v[offset + k + 1]
+          : v[offset + k - 1] + 1;
+      let y = x - k;
+      while (x < a.length && y < b.length && a[x] === b[y]) {
+        x++;
+        y++;
+      }
+      v[offset + k] = x;
+      if (x >= a.length && y >= b.length) {
+        trace.push(v.slice());
+        break outer;
+      }
+    }
+  }
+
+  // Walk the trace backwards to recover the edit script
+  const ops: DiffOp[] = [];
+  let x = a.length;
+  let y = b.length;
+  for (let d = trace.length - 2; d >= 0 && (x > 0 || y > 0); d--) {
+    const previous = trace[d];
+    const k = x - y;
+    const prevK =
+      k === -d ||
+      (k !== d && previous[offset + k - 1] < previous[offset + k + 1])
+        ? k + 1
+        : k - 1;
+    const prevX = previous[offset + prevK];
+    const prevY = prevX - prevK;
+    while (x > prevX && y > prevY) {
+      ops.push({ kind: "equal", line: a[--x] });
+      y--;
+    }
+    if (d > 0) {
+      if (x === prevX) {
+        ops.push({ kind: "insert", line: b[--y] });
+      } else {
+        ops.push({ kind: "delete", line: a[--x] });
+      }
+    }
+  }
+  return ops.reverse();
+}
+
+function splitLines(text: string): string[] {
+  if (text === "") {
+    return [];
+  }
+  const lines = text.split("\n");
+  if (lines[lines.length - 1] === "") {
+    lines.pop();
+  } else {
+    lines[lines.length - 1] += "\n\\ No newline at end of file";
+  }
+  return lines;
+}
+
+function hunkRange(start: number, count: number): string {
+  // Empty ranges point at the line before the change, as diff(1) does
+  const first = count === 0 ? start : start + 1;
+  return count === 1 ? `${first}` : `${first},${count}`;
+}
+
+/**
+ * Render the difference between two texts as a unified diff. Returns an empty
+ * string when the texts are identical.
+ */
+export function unifiedDiff(
+  oldText: string,
+  newText: string,
+  options: UnifiedDiffOptions = {},
+): string {
+  if (oldText === newText) {
+    return "";
+  }
+  const context = options.context ?? 3;
+  const ops = diffLines(splitLines(oldText), splitLines(newText));
+
+  // Group changes whose context windows touch into hunks
+  const changes = ops
+    .map((op, index) => (op.kind === "equal" ? -1 : index))
+    .filter((index) => index >= 0);
+  const hunks: { start: number; end: number }[] = [];
+  for (const index of changes) {
+    const last = hunks[hunks.length - 1];
+    if (last && index - last.end <= context * 2 + 1) {
+      last.end = index;
+    } else {
+      hunks.push({ start: index, end: index });
+    }
+  }
+
+  const output = [
+    `--- ${options.oldPath ?? "a"}`,
+    `+++ ${options.newPath ?? options.oldPath ?? "b"}`,
+  ];
+  for (const hunk of hunks) {
+    const from = Math.max(0, hunk.start - context);
+    const to = Math.min(ops.length - 1, hunk.end + context);
+    // Line numbers consumed before the hunk
+    let oldLine = 0;
+    let newLine = 0;
+    for (let i = 0; i < from; i++) {
+      if (ops[i].kind !== "insert") oldLine++;
+      if (ops[i].kind !== "delete") newLine++;
+    }
+    const body: string[] = [];
+    let oldCount = 0;
+    let newCount = 0;
+    for (let i = from; i <= to; i++) {
+      const op = ops[i];
+      if (op.kind === "equal") {
+        body.push(` ${op.line}`);
+        oldCount++;
+        newCount++;
+      } else if (op.kind === "delete") {
+        body.push(`-${op.line}`);
+        oldCount++;
+      } else {
+        body.push(`+${op.line}`);
+        newCount++;
+      }
+    }
+    output.push(
+      `@@ -${hunkRange(oldLine, oldCount)} +${hunkRange(newLine, newCount)} @@`,
+      ...body,
+    );
+  }
+  return output.join("\n") + "\n";
+}
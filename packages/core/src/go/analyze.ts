@@ -0,0 +1,238 @@
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GO_ANALYZER_VERSION } from "./cache.js";
+import { buildGoCallGraph, findRecursionCycles } from "./callgraph.js";
+import { discoverGoFiles, GoDiscoverOptions } from "./discover.js";
+import { compareCodePoints } from "./findings.js";
+import { goFindingJson, GoFindingJson, streamGoFindings } from "./jsonl.js";
+import {
+  annotateMaintainability,
+  GoMaintainabilityConstants,
+} from "./maintainability.js";
+import { GoOverlay, GoOverlayEntries, goOverlay } from "./overlay.js";
+import { parseGoFile } from "./parser.js";
+import { GoPlatform } from "./platforms.js";
+import { GoProfiler } from "./profile.js";
+import { GoRuleRegistry } from "./rules.js";
+import {
+  GO_SYMBOLS_SCHEMA_VERSION,
+  JsonFile,
+  toGoSymbolsDocument,
+} from "./serialize.js";
+import { extractGoFileSymbols } from "./symbols.js";
+
+/**
+ * Library Entry Point
+ * ===================
+ * Everything the CLI reports about a tree of Go code, for tools embedding
+ * the analyzer instead of running it: `analyzeGo` discovers and parses the
+ * files under a root, runs the rules package by package and builds the call
+ * graph, without any flag parsing or output formatting of its own.
+ *
+ * The result is plain data in the JSON model of the symbols document, with
+ * paths relative to the root and no maps, syntax trees or other structures
+ * the analyzer keeps working with. It is a deeply frozen copy, so nothing a
+ * caller does to it reaches the analyzer, and `JSON.stringify` writes all
+ * of it. Files that do not parse reject the promise with a `GoSyntaxError`;
+ * an aborted signal rejects it with the signal's reason.
+ *
+ * With `recover`, as an editor wants for buffers that are mid-edit, a file
+ * with syntax errors is analyzed as far as it parses instead: its errors are
+ * listed on its entry in `files`, symbols holding one are marked partial
+ * and its findings are kept only in the declarations that parsed cleanly.
+ * The rules still see the rest of its package, though code lost to an error
+ * may make package-wide findings there, such as dead code, less accurate.
+ */
+
+export interface GoAnalyzeOptions {
+  /** Which files under the root to analyze */
+  discover?: Omit<GoDiscoverOptions, "overlay">;
+  /** Contents read in place of files on disk, and files added to them */
+  overlay?: GoOverlay | GoOverlayEntries;
+  /** Rules to run (default: the built-in rules) */
+  registry?: GoRuleRegistry;
+  /** Check each target's files on their own (default: every file at once) */
+  platforms?: GoPlatform[];
+  /** Stops the analysis between files and packages when aborted */
+  signal?: AbortSignal;
+  /** Recover from syntax errors instead of rejecting (default: false) */
+  recover?: boolean;
+  /** Maintainability index constants replacing the defaults, one by one */
+  maintainability?: Partial<GoMaintainabilityConstants>;
+  /**
+   * Records the time of discovery, and of parsing, symbol extraction, the
+   * rules and the call graph
+   */
+  profiler?: GoProfiler;
+}
+
+export interface JsonCallGraphNode {
+  /** `pkg.Func` or `pkg.Type.Method` */
+  id: string;
+  package: string;
+  file: string;
+  line: number;
+}
+
+export interface JsonCallGraph {
+  /** Declared functions and methods, sorted by id */
+  nodes: JsonCallGraphNode[];
+  /** Calls between declared functions, sorted by caller then callee */
+  edges: { caller: string; callee: string }[];
+  /** Calls resolving to no declared function, as in the call graph */
+  external_calls: { caller: string; callee: string }[];
+  /** Recursions, direct and mutual, with their ids sorted */
+  cycles: { ids: string[]; direct: boolean }[];
+}
+
+export interface GoAnalysisResult {
+  schema_version: number;
+  analyzer_version: string;
+  files: JsonFile[];
+  /** Sorted within each package, packages in discovery order */
+  findings: GoFindingJson[];
+  call_graph: JsonCallGraph;
+}
+
+function deepFreeze<T>(value: T): T {
+  if (typeof value === "object" && value !== null) {
+    Object.values(value).forEach(deepFreeze);
+    Object.freeze(value);
+  }
+  return value;
+}
+
+// A copy sharing nothing with the analyzer's own objects, frozen
+function detached<T>(value: T): T {
+  return deepFreeze(JSON.parse(JSON.stringify(value)));
+}
+
+// Whether a line of a file is in a declaration, other than its imports,
+// that parsed without errors
+function parsedCleanly(file: GoFile, line: number): boolean {
+  const { sourceMap } = file;
+  const decl = file.decls.find(
+    (candidate) =>
+      line >=
+        sourceMap.line(
+          (candidate.kind !== "BadDecl" && candidate.doc?.pos) ||
+            candidate.pos,
+        ) && line <= sourceMap.line(candidate.end),
+  );
+  return (
+    decl !== undefined &&
+    !(decl.kind === "GenDecl" && decl.tok === "import") &&
+    !file.syntaxErrors.some(
+      (error) => error.pos >= decl.pos && error.pos <= decl.end,
+    )
+  );
+}
+
+/**
+ * Discover and parse the Go files under a root, in discovery order
+ */
+export async function loadGoFiles(
+  root: string,
+  options: Omit<GoAnalyzeOptions, "registry" | "platforms"> = {},
+): Promise<GoFile[]> {
+  const { profiler } = options;
+  const overlay = goOverlay(options.overlay);
+  const discover = () =>
+    discoverGoFiles(root, { ...options.discover, overlay });
+  const filePaths = profiler
+    ? await profiler.measureAsync("discover", discover)
+    : await discover();
+  const files: GoFile[] = [];
+  for (const filePath of filePaths) {
+    options.signal?.throwIfAborted();
+    const source = await overlay.readFile(filePath);
+    const parse = () =>
+      parseGoFile(source, filePath, { recover: options.recover });
+    files.push(profiler ? profiler.measure("parse", parse, filePath) : parse());
+  }
+  return files;
+}
+
+/**
+ * The call graph of files in the JSON model, with paths relative to `root`
+ */
+export function goCallGraphJson(files: GoFile[], root: string): JsonCallGraph {
+  const graph = buildGoCallGraph(files);
+  const relative = (filePath: string) =>
+    path.relative(root, filePath).split(path.sep).join("/");
+  const pairs = (adjacency: Map<string, Set<string>>) =>
+    [...adjacency]
+      .flatMap(([caller, callees]) =>
+        [...callees].map((callee) => ({ caller, callee })),
+      )
+      .sort(
+        (a, b) =>
+          compareCodePoints(a.caller, b.caller) ||
+          compareCodePoints(a.callee, b.callee),
+      );
+  return {
+    nodes: [...graph.nodes.values()]
+      .map((node) => ({
+        id: node.id,
+        package: node.packageName,
+        file: relative(node.filePath),
+        line: node.symbol.startLine,
+      }))
+      .sort((a, b) => compareCodePoints(a.id, b.id)),
+    edges: pairs(graph.callees),
+    external_calls: pairs(graph.externalCalls),
+    cycles: findRecursionCycles(graph).map((cycle) => ({
+      ids: [...cycle.ids],
+      direct: cycle.direct,
+    })),
+  };
+}
+
+/**
+ * Analyze the Go code under a root: its symbols, the findings of the rules
+ * and its call graph
+ */
+export async function analyzeGo(
+  root: string,
+  options: GoAnalyzeOptions = {},
+): Promise<GoAnalysisResult> {
+  const { signal, profiler } = options;
+  const measure = <T>(phase: string, run: () => T, filePath?: string) =>
+    profiler ? profiler.measure(phase, run, filePath) : run();
+  const files = await loadGoFiles(root, options);
+  const broken = new Map(
+    files
+      .filter((file) => file.syntaxErrors?.length > 0)
+      .map((file) => [file.filePath, file]),
+  );
+  const findings: GoFindingJson[] = [];
+  const stream = streamGoFindings(files, {
+    registry: options.registry,
+    platforms: options.platforms,
+    profiler,
+  });
+  for await (const batch of stream) {
+    signal?.throwIfAborted();
+    findings.push(
+      ...batch.findings
+        .filter(
+          (finding) =>
+            !broken.has(finding.filePath) ||
+            parsedCleanly(broken.get(finding.filePath), finding.line),
+        )
+        .map((finding) => goFindingJson(finding, { root })),
+    );
+  }
+  signal?.throwIfAborted();
+  const symbols = files.map((file) =>
+    measure("symbols", () => extractGoFileSymbols(file), file.filePath),
+  );
+  annotateMaintainability(symbols, options.maintainability);
+  return detached({
+    schema_version: GO_SYMBOLS_SCHEMA_VERSION,
+    analyzer_version: GO_ANALYZER_VERSION,
+    files: toGoSymbolsDocument(symbols, root).files,
+    findings,
+    call_graph: measure("call graph", () => goCallGraphJson(files, root)),
+  });
+}
@@ -0,0 +1,321 @@
+import {
+  Expr,
+  FuncDecl,
+  FuncLit,
+  GoFile,
+  Node,
+  ReturnStmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference } from "./infer.js";
+import { groupGoPackages } from "./package.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Interface Returns
+ * =================
+ * A function declared to return `interface{}` or `any` that always returns
+ * values of one concrete type makes every caller assert a type the function
+ * already knew. This pass infers the type of each value returned in such a
+ * position and, when every return yields the same concrete type, suggests
+ * declaring it instead. `nil` agrees with pointer, slice, map, channel and
+ * function types.
+ *
+ * Functions are left alone when any returned value has an unknown or
+ * interface type, when a return is bare, when the name of a method is
+ * declared by an interface in the analyzed files or by a standard library
+ * interface whose method returns `any`, such as `Sys` of `fs.FileInfo` (it
+ * may be implementing it), when the method is exported and values of its
+ * type are returned, assigned or passed on where another type is expected,
+ * as they are when stored in an interface, when the function is used as a
+ * value (a function type may require the signature), and when a caller
+ * asserts the type of the result, which does not compile on a concrete
+ * type.
+ */
+
+export interface GoAnyReturnFinding extends GoFinding {
+  rule: "unnecessary-any-return";
+  /** `Type.Method` for methods, the bare name for functions */
+  function: string;
+  /** Position of the result in the result list, from 0 */
+  result: number;
+  /** The concrete type every return yields */
+  type: string;
+}
+
+function isEmptyInterface(text: string): boolean {
+  return text === "any" || /^interface\s*\{\s*\}$/.test(text);
+}
+
+// Types nil is a value of
+function isNilable(type: string): boolean {
+  return /^(\*|\[\]|map\[|chan\b|<-chan\b|func\b)/.test(type);
+}
+
+// Methods of standard library interfaces that return any: Sys of
+// fs.FileInfo, Pop of heap.Interface, Get of flag.Getter and Value of
+// context.Context
+const STDLIB_ANY_METHODS = new Set(["Get", "Pop", "Sys", "Value"]);
+
+// The declared type a value is constructed as: `T{}`, `&T{}` or `T(x)`
+function constructedType(node: Node, types: Set<string>): string | undefined {
+  const value = node.kind === "UnaryExpr" && node.op === "&" ? node.x : node;
+  const type =
+    value.kind === "CompositeLit"
+      ? value.type
+      : value.kind === "CallExpr"
+        ? value.fun
+        : undefined;
+  return type?.kind === "Ident" && types.has(type.name) ? type.name : undefined;
+}
+
+function callee(node: Node): string | undefined {
+  if (node.kind !== "CallExpr") return undefined;
+  const fun = node.fun;
+  if (fun.kind === "Ident") return fun.name;
+  if (fun.kind === "SelectorExpr") return fun.sel.name;
+  return undefined;
+}
+
+/**
+ * Names the package uses in ways a narrower result would break: functions
+ * and methods used as values, called with their result type asserted, or
+ * declared by an interface, and types whose values may be interface values
+ */
+class PackageUses {
+  readonly values = new Set<string>();
+  readonly asserted = new Set<string>();
+  readonly interfaceMethods = new Set<string>();
+  readonly interfaceValues = new Set<string>();
+
+  constructor(group: GoFile[], all: GoFile[]) {
+    for (const file of all) {
+      inspect(file, (node) => {
+        if (node.kind !== "InterfaceType") return;
+        for (const field of node.methods.list) {
+          field.names.forEach((name) => this.interfaceMethods.add(name.name));
+        }
+      });
+    }
+    for (const file of group) {
+      inspect(file, (node, parents) => {
+        const parent = parents[parents.length - 1];
+        if (node.kind === "TypeAssertExpr") {
+          const name = callee(node.x);
+          if (name) this.asserted.add(name);
+        }
+        const called = parent?.kind === "CallExpr" && parent.fun === node;
+        if (node.kind === "Ident" && !called) {
+          // Declared names and selected names are not uses of a function
+          const declared =
+            (parent?.kind === "FuncDecl" && parent.name === node) ||
+            (parent?.kind === "SelectorExpr" && parent.sel === node);
+          if (!declared) this.values.add(node.name);
+        }
+        if (node.kind === "SelectorExpr" && !called) {
+          this.values.add(node.sel.name);
+        }
+      });
+    }
+    this.findInterfaceValues(group);
+  }
+
+  // Types constructed where something else may be expected: returned as
+  // another result type, assigned, passed, sent or stored in a literal
+  private findInterfaceValues(group: GoFile[]): void {
+    const types = new Set<string>();
+    for (const file of group) {
+      inspect(file, (node) => {
+        if (node.kind === "TypeSpec") types.add(node.name.name);
+      });
+    }
+    for (const file of group) {
+      const text = (node: Node) => file.source.slice(node.pos, node.end);
+      inspect(file, (node, parents) => {
+        const type = constructedType(node, types);
+        const parent = parents[parents.length - 1];
+        if (!type || !parent) return;
+        switch (parent.kind) {
+          case "ReturnStmt": {
+            const fn = parents.findLast(
+              (candidate) =>
+                candidate.kind === "FuncDecl" || candidate.kind === "FuncLit",
+            ) as FuncDecl | FuncLit | undefined;
+            const results = (fn?.type.results?.list ?? []).flatMap((field) =>
+              Array.from({ length: Math.max(1, field.names.length) }, () =>
+                text(field.type).replace(/^\*/, ""),
+              ),
+            );
+            const index = parent.results.indexOf(node as Expr);
+            if (results[index] !== type) this.interfaceValues.add(type);
+            return;
+          }
+          case "CallExpr":
+            if (parent.fun !== node && callee(parent) !== "append") {
+              this.interfaceValues.add(type);
+            }
+            return;
+          case "ValueSpec":
+            if (parent.type && text(parent.type).replace(/^\*/, "") !== type) {
+              this.interfaceValues.add(type);
+            }
+            return;
+          case "AssignStmt":
+            if (parent.tok === "=") this.interfaceValues.add(type);
+            return;
+          case "KeyValueExpr":
+          case "CompositeLit":
+          case "SendStmt":
+            this.interfaceValues.add(type);
+            return;
+        }
+      });
+    }
+  }
+}
+
+class AnyReturnAnalyzer {
+  private readonly file: GoFile;
+  private readonly uses: PackageUses;
+
+  constructor(file: GoFile, uses: PackageUses) {
+    this.file = file;
+    this.uses = uses;
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private functionName(decl: FuncDecl): string {
+    const field = decl.recv?.list[0];
+    return field
+      ? `${baseTypeName(field.type).name}.${decl.name.name}`
+      : decl.name.name;
+  }
+
+  // The return statements of the function itself, not of nested literals
+  private returns(decl: FuncDecl): ReturnStmt[] {
+    const found: ReturnStmt[] = [];
+    inspect(decl.body, (node) => {
+      if (node.kind === "FuncLit") return false;
+      if (node.kind === "ReturnStmt") found.push(node);
+    });
+    return found;
+  }
+
+  // Whether a method may implement an interface
+  private implements(decl: FuncDecl): boolean {
+    const name = decl.name.name;
+    if (this.uses.interfaceMethods.has(name) || STDLIB_ANY_METHODS.has(name)) {
+      return true;
+    }
+    const { name: type } = baseTypeName(decl.recv.list[0].type);
+    return isExportedName(name) && this.uses.interfaceValues.has(type);
+  }
+
+  private check(decl: FuncDecl): GoAnyReturnFinding[] {
+    const name = decl.name.name;
+    if (
+      this.uses.values.has(name) ||
+      this.uses.asserted.has(name) ||
+      (decl.recv && this.implements(decl))
+    ) {
+      return [];
+    }
+    const results = decl.type.results.list.flatMap((field) =>
+      Array.from({ length: Math.max(1, field.names.length) }, () => field),
+    );
+    const candidates = results
+      .map((field, index) => ({ field, index }))
+      .filter(({ field }) => isEmptyInterface(this.text(field.type)));
+    if (candidates.length === 0) return [];
+    const returns = this.returns(decl);
+    if (returns.length === 0 || returns.some((ret) => !ret.results.length)) {
+      return [];
+    }
+
+    const types = new GoTypeInference(this.file, resolveFunctionScopes(decl));
+    const findings: GoAnyReturnFinding[] = [];
+    for (const { field, index } of candidates) {
+      let concrete: string | undefined;
+      let nils = false;
+      let known = true;
+      for (const ret of returns) {
+        // `return f()` passes on every result of a call
+        const value =
+          ret.results.length === results.length
+            ? ret.results[index]
+            : undefined;
+        if (value?.kind === "Ident" && value.name === "nil") {
+          nils = true;
+          continue;
+        }
+        const type = value
+          ? types.typeOf(value)
+          : types.callResults(ret.results[0])?.[index];
+        const underlying = type && (types.underlying(type) ?? type);
+        if (
+          !type ||
+          isEmptyInterface(type) ||
+          type === "error" ||
+          underlying.startsWith("interface") ||
+          (concrete !== undefined && concrete !== type)
+        ) {
+          known = false;
+          break;
+        }
+        concrete = type;
+      }
+      if (!known || !concrete) continue;
+      if (nils && !isNilable(types.underlying(concrete) ?? concrete)) continue;
+
+      const header = this.file.source.slice(decl.pos, decl.body.pos).trimEnd();
+      const typeStart = field.type.pos - decl.pos;
+      const fix =
+        header.slice(0, typeStart) +
+        concrete +
+        header.slice(field.type.end - decl.pos);
+      const fn = this.functionName(decl);
+      findings.push({
+        rule: "unnecessary-any-return",
+        severity: "low",
+        filePath: this.file.filePath,
+        ...this.file.sourceMap.position(field.type.pos),
+        message: `${fn} always returns ${concrete} as ${this.text(field.type)}; declare the result as ${concrete}`,
+        fix,
+        function: fn,
+        result: index,
+        type: concrete,
+      });
+    }
+    return findings;
+  }
+
+  analyze(): GoAnyReturnFinding[] {
+    return this.file.decls.flatMap((decl) =>
+      decl.kind === "FuncDecl" && decl.body && decl.type.results
+        ? this.check(decl)
+        : [],
+    );
+  }
+}
+
+/**
+ * Find results declared as `interface{}` or `any` that always hold one
+ * concrete type
+ */
+export function findUnnecessaryAnyReturns(
+  files: GoFile[],
+): GoAnyReturnFinding[] {
+  const findings: GoAnyReturnFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const uses = new PackageUses(group, files);
+    for (const file of group) {
+      findings.push(...new AnyReturnAnalyzer(file, uses).analyze());
+    }
+  }
+  return sortFindings(findings);
+}
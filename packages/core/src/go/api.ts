@@ -0,0 +1,289 @@
+import * as path from "path";
+import {
+  CommentGroup,
+  Field,
+  FuncDecl,
+  GenDecl,
+  GoFile,
+  TypeSpec,
+  ValueSpec,
+} from "./ast.js";
+import { extractGoConstants } from "./constants.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  goSignature,
+  GoTypeParam,
+  goTypeParams,
+  typeString,
+} from "./signature.js";
+import { baseTypeName, goFunctionSymbol, isExportedName } from "./symbols.js";
+
+/**
+ * Exported API Surface
+ * ====================
+ * Lists the exported symbols of each package the way Go's own `api/*.txt`
+ * files do: one line per constant, variable, type, exported field,
+ * interface method, function and method on an exported type, with the full
+ * signature and without parameter names, which callers cannot depend on.
+ * Lines are sorted, so two listings of different versions diff cleanly and
+ * a CI check can fail on an accidental change.
+ *
+ * Lines of symbols without a doc comment end in `// undocumented`. Members
+ * of a grouped declaration are documented by the group's comment, as
+ * `go doc` shows it. {@link diffGoApi} ignores the marker, so documenting a
+ * symbol is not an API change. Test files are left out.
+ */
+
+export type GoApiKind = "const" | "var" | "type" | "field" | "func" | "method";
+
+export interface GoApiEntry {
+  /** Package directory relative to the root, `.` for the root itself */
+  package: string;
+  kind: GoApiKind;
+  /** `Type.Member` for fields, interface methods and methods */
+  name: string;
+  /** The declaration, e.g. `func NewServer(string, int) *Server` */
+  declaration: string;
+  documented: boolean;
+  filePath: string;
+  line: number;
+}
+
+export interface GoApiOptions {
+  /** Directory package paths are relative to (default: the cwd) */
+  root?: string;
+}
+
+export interface GoApiDiff {
+  /** Declarations only the new listing has */
+  added: string[];
+  /** Declarations only the old listing has; removals break callers */
+  removed: string[];
+}
+
+const UNDOCUMENTED = " // undocumented";
+
+function documented(...docs: (CommentGroup | undefined)[]): boolean {
+  return docs.some((doc) => Boolean(doc?.text.trim()));
+}
+
+function typeParamList(params: GoTypeParam[]): string {
+  const list = params.map((param) => `${param.name} ${param.constraint}`);
+  return list.length === 0 ? "" : `[${list.join(", ")}]`;
+}
+
+class ApiCollector {
+  private readonly file: GoFile;
+  private readonly pkg: string;
+  readonly entries: GoApiEntry[] = [];
+
+  constructor(file: GoFile, pkg: string) {
+    this.file = file;
+    this.pkg = pkg;
+  }
+
+  private add(
+    kind: GoApiKind,
+    name: string,
+    declaration: string,
+    isDocumented: boolean,
+    pos: number,
+  ): void {
+    this.entries.push({
+      package: this.pkg,
+      kind,
+      name,
+      declaration,
+      documented: isDocumented,
+      filePath: this.file.filePath,
+      line: this.file.sourceMap.line(pos),
+    });
+  }
+
+  private func(decl: FuncDecl): void {
+    const symbol = goFunctionSymbol(this.file, decl);
+    if (!symbol.isExported) return;
+    const { name, signature } = symbol;
+    const isDocumented = documented(decl.doc);
+    if (!symbol.receiver) {
+      const typeParams = typeParamList(symbol.typeParams ?? []);
+      const declaration = `func ${name}${typeParams}${signature.text}`;
+      this.add("func", name, declaration, isDocumented, decl.pos);
+      return;
+    }
+    const { typeName, isPointer, typeParams: receiverParams } = symbol.receiver;
+    if (!isExportedName(typeName)) return;
+    const args = receiverParams ? `[${receiverParams.join(", ")}]` : "";
+    const receiver = `${isPointer ? "*" : ""}${typeName}${args}`;
+    const declaration = `method (${receiver}) ${name}${signature.text}`;
+    const qualified = symbol.qualifiedName;
+    this.add("method", qualified, declaration, isDocumented, decl.pos);
+  }
+
+  private members(spec: TypeSpec, prefix: string, fields: Field[]): void {
+    const owner = spec.name.name;
+    const isInterface = spec.type.kind === "InterfaceType";
+    const kind = isInterface ? "method" : "field";
+    for (const field of fields) {
+      const isDocumented = documented(field.doc, field.comment);
+      if (field.names.length === 0) {
+        // Every embedded interface and type set element is part of an
+        // interface; a struct only exports embedded exported types
+        const { name } = baseTypeName(field.type);
+        if (!isInterface && !isExportedName(name)) continue;
+        const type = typeString(this.file, field.type);
+        const declaration = `${prefix}, embedded ${type}`;
+        const member = `${owner}.${name || type}`;
+        this.add(kind, member, declaration, isDocumented, field.pos);
+        continue;
+      }
+      for (const ident of field.names) {
+        if (!isExportedName(ident.name)) continue;
+        const member = `${owner}.${ident.name}`;
+        const type =
+          isInterface && field.type.kind === "FuncType"
+            ? goSignature(this.file, field.type).text
+            : ` ${typeString(this.file, field.type)}`;
+        const declaration = `${prefix}, ${ident.name}${type}`;
+        this.add(kind, member, declaration, isDocumented, ident.pos);
+      }
+    }
+  }
+
+  private type(decl: GenDecl, spec: TypeSpec): void {
+    const name = spec.name.name;
+    if (!isExportedName(name)) return;
+    const params = typeParamList(goTypeParams(this.file, spec.typeParams));
+    const head = `type ${name}${params}`;
+    const isDocumented = documented(spec.doc, decl.doc);
+    if (spec.isAlias) {
+      const declaration = `${head} = ${typeString(this.file, spec.type)}`;
+      this.add("type", name, declaration, isDocumented, spec.pos);
+    } else if (spec.type.kind === "StructType") {
+      this.add("type", name, `${head} struct`, isDocumented, spec.pos);
+      this.members(spec, `${head} struct`, spec.type.fields.list);
+    } else if (spec.type.kind === "InterfaceType") {
+      this.add("type", name, `${head} interface`, isDocumented, spec.pos);
+      this.members(spec, `${head} interface`, spec.type.methods.list);
+    } else {
+      const declaration = `${head} ${typeString(this.file, spec.type)}`;
+      this.add("type", name, declaration, isDocumented, spec.pos);
+    }
+  }
+
+  private vars(decl: GenDecl, spec: ValueSpec, types: GoTypeInference): void {
+    spec.names.forEach((ident, index) => {
+      if (!isExportedName(ident.name)) return;
+      const value = spec.values[index];
+      // Without a declared or inferred type, the initializer stands in
+      const type = spec.type
+        ? typeString(this.file, spec.type)
+        : value && types.typeOf(value);
+      const declaration = type
+        ? `var ${ident.name} ${type}`
+        : `var ${ident.name} = ${this.file.source.slice(value.pos, value.end)}`;
+      const isDocumented = documented(spec.doc, decl.doc);
+      this.add("var", ident.name, declaration, isDocumented, ident.pos);
+    });
+  }
+
+  private constants(documentedNames: Set<string>): void {
+    for (const constant of extractGoConstants(this.file)) {
+      if (!constant.isExported) continue;
+      const type = constant.isTyped ? ` ${constant.declaredType}` : "";
+      const value = constant.value ?? constant.expression;
+      const initializer = value === undefined ? "" : ` = ${value}`;
+      const declaration = `const ${constant.name}${type}${initializer}`;
+      this.entries.push({
+        package: this.pkg,
+        kind: "const",
+        name: constant.name,
+        declaration,
+        documented: documentedNames.has(constant.name),
+        filePath: this.file.filePath,
+        line: constant.startLine,
+      });
+    }
+  }
+
+  collect(): GoApiEntry[] {
+    const types = new GoTypeInference(this.file);
+    // Constants documented by their own or their group's comment
+    const documentedConstants = new Set<string>();
+    for (const decl of this.file.decls) {
+      if (decl.kind === "FuncDecl") {
+        this.func(decl);
+        continue;
+      }
+      for (const spec of decl.specs) {
+        if (spec.kind === "TypeSpec") {
+          this.type(decl, spec);
+        } else if (spec.kind === "ValueSpec" && decl.tok === "var") {
+          this.vars(decl, spec, types);
+        } else if (spec.kind === "ValueSpec") {
+          if (!documented(spec.doc, decl.doc)) continue;
+          spec.names.forEach((ident) => documentedConstants.add(ident.name));
+        }
+      }
+    }
+    this.constants(documentedConstants);
+    return this.entries;
+  }
+}
+
+function apiLine(entry: GoApiEntry): string {
+  const line = `pkg ${entry.package}, ${entry.declaration}`;
+  return entry.documented ? line : line + UNDOCUMENTED;
+}
+
+/**
+ * The exported API of the packages among `files`, sorted by package and then
+ * by declaration
+ */
+export function goApiSurface(
+  files: GoFile[],
+  options: GoApiOptions = {},
+): GoApiEntry[] {
+  const root = options.root ?? process.cwd();
+  return files
+    .filter((file) => !file.filePath.endsWith("_test.go"))
+    .flatMap((file) => {
+      const directory = path.relative(root, path.dirname(file.filePath));
+      const pkg = directory.split(path.sep).join("/") || ".";
+      return new ApiCollector(file, pkg).collect();
+    })
+    .sort((a, b) => {
+      const [x, y] = [apiLine(a), apiLine(b)];
+      return x < y ? -1 : x > y ? 1 : 0;
+    });
+}
+
+/**
+ * An API listing, one line per entry, as {@link goApiSurface} sorts them
+ */
+export function formatGoApi(entries: GoApiEntry[]): string {
+  return entries.map((entry) => apiLine(entry) + "\n").join("");
+}
+
+/**
+ * Compare two listings from {@link formatGoApi}. Documentation markers are
+ * ignored, so only changes to declarations are reported.
+ */
+export function diffGoApi(before: string, after: string): GoApiDiff {
+  const declarations = (listing: string) =>
+    new Set(
+      listing
+        .split("\n")
+        .map((line) =>
+          line.endsWith(UNDOCUMENTED)
+            ? line.slice(0, -UNDOCUMENTED.length)
+            : line.trim(),
+        )
+        .filter((line) => line.length > 0),
+    );
+  const [old, current] = [declarations(before), declarations(after)];
+  return {
+    added: [...current].filter((line) => !old.has(line)),
+    removed: [...old].filter((line) => !current.has(line)),
+  };
+}
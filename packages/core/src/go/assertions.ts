@@ -0,0 +1,333 @@
+import { TextEdit } from "../diff.js";
+import {
+  AssignStmt,
+  FuncDecl,
+  FuncLit,
+  GoFile,
+  Ident,
+  Node,
+  Stmt,
+  TypeAssertExpr,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+
+/**
+ * Unchecked Type Assertions
+ * =========================
+ * Flags single-result type assertions, `x.(T)`, which panic when `x` does
+ * not hold a `T`. Values read back from a `map[string]interface{}` cache are
+ * the usual case: nothing but convention says what each key holds. The
+ * two-result form, `v, ok := x.(T)`, reports the mismatch instead.
+ *
+ * Assertions are left alone in functions that defer a call of `recover`,
+ * which turns the panic into something the function handles, and inside a
+ * case of a `switch x.(type)` when they assert the switch's own operand,
+ * since the case has already checked its type.
+ *
+ * The fix applies to an assertion assigned to a new variable on its own,
+ * `v := x.(T)`: it becomes `if v, ok := x.(T); ok {`, wrapping the
+ * statements after it up to the last that uses `v`. When that would leave a
+ * function with results without a terminating statement, or guards of the
+ * same statements overlap, the assertion is reported without a fix; running
+ * the fix again guards the next of a sequence of assertions.
+ */
+
+export interface GoTypeAssertionFinding extends GoFinding {
+  rule: "unchecked-type-assertion";
+  /** The asserted operand, as written */
+  expression: string;
+  /** The asserted type, as written */
+  type: string;
+}
+
+export interface GuardTypeAssertionsOptions {
+  /**
+   * Line of an assertion reported by {@link findUncheckedTypeAssertions}
+   * (default: every one in the file)
+   */
+  line?: number;
+}
+
+export interface GuardTypeAssertionsResult extends GoRefactorResult {
+  /** Positions of the assertions now guarded by an `if ok` block */
+  guarded: { line: number; column: number }[];
+}
+
+interface Assertion {
+  expr: TypeAssertExpr;
+  /** The statement declaring the asserted value's variable, when fixable */
+  assign?: AssignStmt;
+  /** Statements the `if ok` block wraps */
+  wrapped?: Stmt[];
+  /** Why the assertion cannot be guarded automatically */
+  reason?: string;
+  variable: string;
+}
+
+type GoFunction = FuncDecl | FuncLit;
+
+class TypeAssertionAnalyzer {
+  private readonly file: GoFile;
+  private readonly parents = new Map<Node, Node>();
+
+  constructor(file: GoFile) {
+    this.file = file;
+    inspect(file, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+    });
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private enclosingFunction(node: Node): GoFunction | undefined {
+    for (let at = this.parents.get(node); at; at = this.parents.get(at)) {
+      if (at.kind === "FuncDecl" || at.kind === "FuncLit") return at;
+    }
+    return undefined;
+  }
+
+  // Whether a function defers a call that recovers from panics
+  private recovers(fn: GoFunction): boolean {
+    let found = false;
+    inspect(fn.body, (node) => {
+      if (found || node.kind !== "DeferStmt") return;
+      if (this.enclosingFunction(node) !== fn) return;
+      inspect(node.call, (inner) => {
+        if (
+          inner.kind === "CallExpr" &&
+          inner.fun.kind === "Ident" &&
+          inner.fun.name === "recover"
+        ) {
+          found = true;
+        }
+      });
+    });
+    return found;
+  }
+
+  // Whether the assertion is in a case of a type switch on the same operand
+  private checkedBySwitch(expr: TypeAssertExpr): boolean {
+    const operand = this.text(expr.x);
+    for (let at = this.parents.get(expr); at; at = this.parents.get(at)) {
+      if (at.kind === "FuncLit") return false;
+      if (at.kind !== "TypeSwitchStmt") continue;
+      let guard: Node | undefined;
+      inspect(at.assign, (node) => {
+        if (node.kind === "TypeAssertExpr" && !node.type) guard ??= node.x;
+      });
+      if (guard && this.text(guard) === operand) return true;
+    }
+    return false;
+  }
+
+  private commaOk(expr: TypeAssertExpr): boolean {
+    let node = this.parents.get(expr);
+    while (node?.kind === "ParenExpr") node = this.parents.get(node);
+    return (
+      (node?.kind === "AssignStmt" && node.lhs.length === 2) ||
+      (node?.kind === "ValueSpec" && node.names.length === 2)
+    );
+  }
+
+  // The `if ok` block an assertion declaring a variable can be wrapped in
+  private guard(
+    expr: TypeAssertExpr,
+    fn: GoFunction,
+    scopes: GoFunctionScopes,
+  ): Pick<Assertion, "assign" | "wrapped" | "reason"> {
+    const assign = this.parents.get(expr);
+    if (
+      assign?.kind !== "AssignStmt" ||
+      assign.tok !== ":=" ||
+      assign.lhs.length !== 1 ||
+      assign.lhs[0].kind !== "Ident" ||
+      assign.lhs[0].name === "_"
+    ) {
+      return { reason: "the assertion does not declare a variable of its own" };
+    }
+    const container = this.parents.get(assign);
+    const list =
+      container?.kind === "BlockStmt"
+        ? container.list
+        : container?.kind === "CaseClause" || container?.kind === "CommClause"
+          ? container.body
+          : undefined;
+    if (!list) return { reason: "the assertion is not a statement of a block" };
+
+    const variable = scopes.resolved.get(assign.lhs[0] as Ident);
+    const index = list.indexOf(assign);
+    let last = -1;
+    for (let i = index + 1; i < list.length; i++) {
+      const uses = scopes.references.some(
+        (reference) =>
+          reference.variable === variable &&
+          reference.ident.pos >= list[i].pos &&
+          reference.ident.end <= list[i].end,
+      );
+      if (uses) last = i;
+    }
+    if (last < 0) return { reason: "nothing uses the asserted value" };
+    const wrapped = list.slice(index + 1, last + 1);
+    const end = wrapped.at(-1).end;
+    const needed = scopes.references.find(
+      (reference) =>
+        reference.ident.pos >= end &&
+        reference.variable.ident.pos > assign.end &&
+        reference.variable.ident.pos < end,
+    );
+    if (needed) {
+      return {
+        reason: `${needed.variable.name} would be declared inside the if block but is used after it`,
+      };
+    }
+    const text = this.file.source.slice(assign.end, end);
+    if (text.includes("`")) {
+      return { reason: "a raw string would be reindented" };
+    }
+    const results = fn.type.results?.list.length ?? 0;
+    if (results > 0 && container === fn.body && last === list.length - 1) {
+      return {
+        reason:
+          "the function would end without a return when the assertion fails",
+      };
+    }
+    return { assign, wrapped };
+  }
+
+  analyze(): Assertion[] {
+    const assertions: Assertion[] = [];
+    const recovering = new Map<GoFunction, boolean>();
+    const scopes = new Map<FuncDecl, GoFunctionScopes>();
+    inspect(this.file, (node) => {
+      if (node.kind !== "TypeAssertExpr" || !node.type) return;
+      if (this.commaOk(node) || this.checkedBySwitch(node)) return;
+      const fn = this.enclosingFunction(node);
+      if (!fn) return;
+      if (!recovering.has(fn)) recovering.set(fn, this.recovers(fn));
+      if (recovering.get(fn)) return;
+
+      let decl: Node = fn;
+      while (decl.kind !== "FuncDecl") decl = this.enclosingFunction(decl);
+      if (!scopes.has(decl)) scopes.set(decl, resolveFunctionScopes(decl));
+      const guard = this.guard(node, fn, scopes.get(decl));
+      const parent = this.parents.get(node);
+      const variable =
+        parent.kind === "AssignStmt" &&
+        parent.lhs.length === 1 &&
+        parent.lhs[0].kind === "Ident" &&
+        parent.lhs[0].name !== "_"
+          ? parent.lhs[0].name
+          : "v";
+      assertions.push({ expr: node, variable, ...guard });
+    });
+    return assertions;
+  }
+
+  findings(): GoTypeAssertionFinding[] {
+    return this.analyze().map(({ expr, variable }) => {
+      const assertion = this.text(expr);
+      const expression = this.text(expr.x);
+      const type = this.text(expr.type);
+      return {
+        rule: "unchecked-type-assertion",
+        severity: "medium",
+        filePath: this.file.filePath,
+        ...this.file.sourceMap.position(expr.pos),
+        message: `${assertion} panics when ${expression} does not hold a ${type}; use ${variable}, ok := ${assertion} and check ok`,
+        fix: `${variable}, ok := ${assertion}`,
+        expression,
+        type,
+      };
+    });
+  }
+
+  // A name for the flag that nothing in the wrapped statements uses
+  private okName(nodes: Node[]): string {
+    const names = new Set<string>();
+    for (const node of nodes) {
+      inspect(node, (inner) => {
+        if (inner.kind === "Ident") names.add(inner.name);
+      });
+    }
+    let name = "ok";
+    for (let i = 2; names.has(name); i++) name = `ok${i}`;
+    return name;
+  }
+
+  rewrite(options: GuardTypeAssertionsOptions): GuardTypeAssertionsResult {
+    const { source, sourceMap } = this.file;
+    const matches = this.analyze().filter(
+      (match) =>
+        options.line === undefined ||
+        sourceMap.line(match.expr.pos) === options.line,
+    );
+    if (options.line !== undefined) {
+      if (matches.length === 0) {
+        throw new GoRefactorError(
+          `Line ${options.line} has no unchecked type assertion`,
+        );
+      }
+      const [first] = matches;
+      if (matches.every((match) => !match.assign)) {
+        throw new GoRefactorError(
+          `Cannot guard ${this.text(first.expr)}: ${first.reason}`,
+        );
+      }
+    }
+
+    const edits: TextEdit[] = [];
+    const guarded: { line: number; column: number }[] = [];
+    for (const { expr, assign, wrapped, variable } of matches) {
+      if (!assign) continue;
+      const start = assign.pos;
+      const end = wrapped.at(-1).end;
+      if (edits.some((edit) => edit.start < end && start < edit.end)) continue;
+      const lineStart = source.lastIndexOf("\n", start - 1) + 1;
+      const indent = /^[ \t]*/.exec(source.slice(lineStart))[0];
+      const body = source
+        .slice(assign.end, end)
+        .replace(/\n(?=[^\n])/g, "\n\t");
+      const ok = this.okName([expr, ...wrapped]);
+      edits.push({
+        start,
+        end,
+        newText: `if ${variable}, ${ok} := ${this.text(expr)}; ${ok} {${body}\n${indent}}`,
+      });
+      guarded.push(sourceMap.position(expr.pos));
+    }
+    return { ...refactorResult(this.file, edits), guarded };
+  }
+}
+
+/**
+ * Find single-result type assertions that panic on a mismatch
+ */
+export function findUncheckedTypeAssertions(
+  files: GoFile[],
+): GoTypeAssertionFinding[] {
+  const findings: GoTypeAssertionFinding[] = [];
+  for (const file of files) {
+    findings.push(...new TypeAssertionAnalyzer(file).findings());
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Turn `v := x.(T)` into the comma-ok form, wrapping the statements that use
+ * `v` in an `if ok` block
+ */
+export function guardTypeAssertions(
+  file: GoFile,
+  options: GuardTypeAssertionsOptions = {},
+): GuardTypeAssertionsResult {
+  return new TypeAssertionAnalyzer(file).rewrite(options);
+}
@@ -0,0 +1,569 @@
+import { GoComment, GoSourceMap, GoSyntaxError } from "./lexer.js";
+
+/**
+ * Go AST
+ * ======
+ * Node shapes mirror the standard library's go/ast package so analysis passes
+ * can be described in the same vocabulary Go developers already use. Every
+ * node carries `pos`/`end` UTF-16 offsets into the original source.
+ */
+
+interface NodeBase {
+  pos: number;
+  end: number;
+}
+
+/**
+ * A run of adjacent comments with no blank line between them
+ */
+export interface CommentGroup extends NodeBase {
+  kind: "CommentGroup";
+  list: GoComment[];
+  /** Comment text with markers stripped, as go/ast's CommentGroup.Text() */
+  text: string;
+}
+
+// ---------------------------------------------------------------------------
+// Expressions and types
+// ---------------------------------------------------------------------------
+
+export interface Ident extends NodeBase {
+  kind: "Ident";
+  name: string;
+}
+
+export interface BadExpr extends NodeBase {
+  kind: "BadExpr";
+}
+
+export interface BasicLit extends NodeBase {
+  kind: "BasicLit";
+  litKind: "int" | "float" | "imag" | "char" | "string";
+  value: string;
+}
+
+export interface Ellipsis extends NodeBase {
+  kind: "Ellipsis";
+  elt?: Expr;
+}
+
+export interface FuncLit extends NodeBase {
+  kind: "FuncLit";
+  type: FuncType;
+  body: BlockStmt;
+}
+
+export interface CompositeLit extends NodeBase {
+  kind: "CompositeLit";
+  type?: Expr;
+  elts: Expr[];
+  lbrace: number;
+}
+
+export interface ParenExpr extends NodeBase {
+  kind: "ParenExpr";
+  x: Expr;
+}
+
+export interface SelectorExpr extends NodeBase {
+  kind: "SelectorExpr";
+  x: Expr;
+  sel: Ident;
+}
+
+export interface IndexExpr extends NodeBase {
+  kind: "IndexExpr";
+  x: Expr;
+  index: Expr;
+}
+
+export interface IndexListExpr extends NodeBase {
+  kind: "IndexListExpr";
+  x: Expr;
+  indices: Expr[];
+}
+
+export interface SliceExpr extends NodeBase {
+  kind: "SliceExpr";
+  x: Expr;
+  low?: Expr;
+  high?: Expr;
+  max?: Expr;
+  slice3: boolean;
+}
+
+export interface TypeAssertExpr extends NodeBase {
+  kind: "TypeAssertExpr";
+  x: Expr;
+  /** Undefined for the `x.(type)` form used in type switches */
+  type?: Expr;
+}
+
+export interface CallExpr extends NodeBase {
+  kind: "CallExpr";
+  fun: Expr;
+  args: Expr[];
+  /** Offset of a trailing `...` on the final argument, or -1 */
+  ellipsis: number;
+  lparen: number;
+  rparen: number;
+}
+
+export interface StarExpr extends NodeBase {
+  kind: "StarExpr";
+  x: Expr;
+}
+
+export interface UnaryExpr extends NodeBase {
+  kind: "UnaryExpr";
+  op: string;
+  x: Expr;
+}
+
+export interface BinaryExpr extends NodeBase {
+  kind: "BinaryExpr";
+  op: string;
+  opPos: number;
+  x: Expr;
+  y: Expr;
+}
+
+export interface KeyValueExpr extends NodeBase {
+  kind: "KeyValueExpr";
+  key: Expr;
+  value: Expr;
+}
+
+export interface ArrayType extends NodeBase {
+  kind: "ArrayType";
+  /** Undefined for slices; an Ellipsis for `[...]T` */
+  len?: Expr;
+  elt: Expr;
+}
+
+export interface StructType extends NodeBase {
+  kind: "StructType";
+  fields: FieldList;
+}
+
+export interface FuncType extends NodeBase {
+  kind: "FuncType";
+  typeParams?: FieldList;
+  params: FieldList;
+  results?: FieldList;
+}
+
+export interface InterfaceType extends NodeBase {
+  kind: "InterfaceType";
+  methods: FieldList;
+}
+
+export interface MapType extends NodeBase {
+  kind: "MapType";
+  key: Expr;
+  value: Expr;
+}
+
+export interface ChanType extends NodeBase {
+  kind: "ChanType";
+  dir: "both" | "send" | "recv";
+  value: Expr;
+}
+
+export type Expr =
+  | Ident
+  | BadExpr
+  | BasicLit
+  | Ellipsis
+  | FuncLit
+  | CompositeLit
+  | ParenExpr
+  | SelectorExpr
+  | IndexExpr
+  | IndexListExpr
+  | SliceExpr
+  | TypeAssertExpr
+  | CallExpr
+  | StarExpr
+  | UnaryExpr
+  | BinaryExpr
+  | KeyValueExpr
+  | ArrayType
+  | StructType
+  | FuncType
+  | InterfaceType
+  | MapType
+  | ChanType;
+
+/**
+ * A field in a struct, a parameter/result, a method or embedded element in an
+ * interface, or a type parameter
+ */
+export interface Field extends NodeBase {
+  kind: "Field";
+  doc?: CommentGroup;
+  /** Empty for embedded fields and unnamed parameters */
+  names: Ident[];
+  type: Expr;
+  tag?: BasicLit;
+  comment?: CommentGroup;
+}
+
+export interface FieldList extends NodeBase {
+  kind: "FieldList";
+  /** Offset of the opening delimiter, or -1 when absent (single result) */
+  opening: number;
+  list: Field[];
+}
+
+// ---------------------------------------------------------------------------
+// Statements
+// ---------------------------------------------------------------------------
+
+export interface BadStmt extends NodeBase {
+  kind: "BadStmt";
+}
+
+export interface DeclStmt extends NodeBase {
+  kind: "DeclStmt";
+  decl: GenDecl;
+}
+
+export interface EmptyStmt extends NodeBase {
+  kind: "EmptyStmt";
+  implicit: boolean;
+}
+
+export interface LabeledStmt extends NodeBase {
+  kind: "LabeledStmt";
+  label: Ident;
+  stmt: Stmt;
+}
+
+export interface ExprStmt extends NodeBase {
+  kind: "ExprStmt";
+  x: Expr;
+}
+
+export interface SendStmt extends NodeBase {
+  kind: "SendStmt";
+  chan: Expr;
+  value: Expr;
+}
+
+export interface IncDecStmt extends NodeBase {
+  kind: "IncDecStmt";
+  x: Expr;
+  tok: "++" | "--";
+}
+
+export interface AssignStmt extends NodeBase {
+  kind: "AssignStmt";
+  lhs: Expr[];
+  /** "=", ":=" or an compound operator such as "+=" */
+  tok: string;
+  tokPos: number;
+  rhs: Expr[];
+}
+
+export interface GoStmt extends NodeBase {
+  kind: "GoStmt";
+  call: CallExpr;
+}
+
+export interface DeferStmt extends NodeBase {
+  kind: "DeferStmt";
+  call: CallExpr;
+}
+
+export interface ReturnStmt extends NodeBase {
+  kind: "ReturnStmt";
+  results: Expr[];
+}
+
+export interface BranchStmt extends NodeBase {
+  kind: "BranchStmt";
+  tok: "break" | "continue" | "goto" | "fallthrough";
+  label?: Ident;
+}
+
+export interface BlockStmt extends NodeBase {
+  kind: "BlockStmt";
+  list: Stmt[];
+  lbrace: number;
+  rbrace: number;
+}
+
+export interface IfStmt extends NodeBase {
+  kind: "IfStmt";
+  init?: Stmt;
+  cond: Expr;
+  body: BlockStmt;
+  else?: IfStmt | BlockStmt;
+}
+
+export interface CaseClause extends NodeBase {
+  kind: "CaseClause";
+  /** Empty for the default clause */
+  list: Expr[];
+  isDefault: boolean;
+  colon: number;
+  body: Stmt[];
+}
+
+export interface SwitchStmt extends NodeBase {
+  kind: "SwitchStmt";
+  init?: Stmt;
+  tag?: Expr;
+  body: BlockStmt;
+}
+
+export interface TypeSwitchStmt extends NodeBase {
+  kind: "TypeSwitchStmt";
+  init?: Stmt;
+  /** `x := y.(type)` or `y.(type)` */
+  assign: Stmt;
+  body: BlockStmt;
+}
+
+export interface CommClause extends NodeBase {
+  kind: "CommClause";
+  /** Undefined for the default clause */
+  comm?: Stmt;
+  colon: number;
+  body: Stmt[];
+}
+
+export interface SelectStmt extends NodeBase {
+  kind: "SelectStmt";
+  body: BlockStmt;
+}
+
+export interface ForStmt extends NodeBase {
+  kind: "ForStmt";
+  init?: Stmt;
+  cond?: Expr;
+  post?: Stmt;
+  body: BlockStmt;
+}
+
+export interface RangeStmt extends NodeBase {
+  kind: "RangeStmt";
+  key?: Expr;
+  value?: Expr;
+  /** ":=", "=" or undefined for `for range x` */
+  tok?: string;
+  x: Expr;
+  body: BlockStmt;
+}
+
+export type Stmt =
+  | BadStmt
+  | DeclStmt
+  | EmptyStmt
+  | LabeledStmt
+  | ExprStmt
+  | SendStmt
+  | IncDecStmt
+  | AssignStmt
+  | GoStmt
+  | DeferStmt
+  | ReturnStmt
+  | BranchStmt
+  | BlockStmt
+  | IfStmt
+  | CaseClause
+  | SwitchStmt
+  | TypeSwitchStmt
+  | CommClause
+  | SelectStmt
+  | ForStmt
+  | RangeStmt;
+
+// ---------------------------------------------------------------------------
+// Declarations
+// ---------------------------------------------------------------------------
+
+export interface ImportSpec extends NodeBase {
+  kind: "ImportSpec";
+  doc?: CommentGroup;
+  /** Local name: an identifier, "." or "_" */
+  name?: Ident;
+  path: BasicLit;
+  comment?: CommentGroup;
+}
+
+export interface ValueSpec extends NodeBase {
+  kind: "ValueSpec";
+  doc?: CommentGroup;
+  names: Ident[];
+  type?: Expr;
+  values: Expr[];
+  comment?: CommentGroup;
+}
+
+export interface TypeSpec extends NodeBase {
+  kind: "TypeSpec";
+  doc?: CommentGroup;
+  name: Ident;
+  typeParams?: FieldList;
+  /** True for alias declarations (`type A = B`) */
+  isAlias: boolean;
+  type: Expr;
+  comment?: CommentGroup;
+}
+
+export type Spec = ImportSpec | ValueSpec | TypeSpec;
+
+export interface GenDecl extends NodeBase {
+  kind: "GenDecl";
+  doc?: CommentGroup;
+  tok: "import" | "const" | "type" | "var";
+  /** Offset of "(" for grouped declarations, or -1 */
+  lparen: number;
+  rparen: number;
+  specs: Spec[];
+}
+
+export interface FuncDecl extends NodeBase {
+  kind: "FuncDecl";
+  doc?: CommentGroup;
+  recv?: FieldList;
+  name: Ident;
+  type: FuncType;
+  body?: BlockStmt;
+}
+
+export interface BadDecl extends NodeBase {
+  kind: "BadDecl";
+}
+
+export type Decl = GenDecl | FuncDecl | BadDecl;
+
+/**
+ * A parsed Go source file
+ */
+export interface GoFile extends NodeBase {
+  kind: "File";
+  filePath: string;
+  source: string;
+  sourceMap: GoSourceMap;
+  doc?: CommentGroup;
+  packageName: Ident;
+  imports: ImportSpec[];
+  decls: Decl[];
+  comments: CommentGroup[];
+  /** Errors recovered from, in source order, when parsed with `recover` */
+  syntaxErrors?: GoSyntaxError[];
+  /**
+   * Declarations that did not parse, when parsed with `recover`; they are
+   * kept out of `decls`, which passes expect to hold well-formed ones
+   */
+  badDecls?: BadDecl[];
+}
+
+export type Node =
+  | Expr
+  | Stmt
+  | Spec
+  | Decl
+  | Field
+  | FieldList
+  | CommentGroup
+  | GoFile;
+
+// ---------------------------------------------------------------------------
+// Traversal
+// ---------------------------------------------------------------------------
+
+// Child-bearing properties per node kind, in source order
+const CHILD_KEYS: Record<string, string[]> = {
+  Ellipsis: ["elt"],
+  FuncLit: ["type", "body"],
+  CompositeLit: ["type", "elts"],
+  ParenExpr: ["x"],
+  SelectorExpr: ["x", "sel"],
+  IndexExpr: ["x", "index"],
+  IndexListExpr: ["x", "indices"],
+  SliceExpr: ["x", "low", "high", "max"],
+  TypeAssertExpr: ["x", "type"],
+  CallExpr: ["fun", "args"],
+  StarExpr: ["x"],
+  UnaryExpr: ["x"],
+  BinaryExpr: ["x", "y"],
+  KeyValueExpr: ["key", "value"],
+  ArrayType: ["len", "elt"],
+  StructType: ["fields"],
+  FuncType: ["typeParams", "params", "results"],
+  InterfaceType: ["methods"],
+  MapType: ["key", "value"],
+  ChanType: ["value"],
+  Field: ["names", "type", "tag"],
+  FieldList: ["list"],
+  DeclStmt: ["decl"],
+  LabeledStmt: ["label", "stmt"],
+  ExprStmt: ["x"],
+  SendStmt: ["chan", "value"],
+  IncDecStmt: ["x"],
+  AssignStmt: ["lhs", "rhs"],
+  GoStmt: ["call"],
+  DeferStmt: ["call"],
+  ReturnStmt: ["results"],
+  BranchStmt: ["label"],
+  BlockStmt: ["list"],
+  IfStmt: ["init", "cond", "body", "else"],
+  CaseClause: ["list", "body"],
+  SwitchStmt: ["init", "tag", "body"],
+  TypeSwitchStmt: ["init", "assign", "body"],
+  CommClause: ["comm", "body"],
+  SelectStmt: ["body"],
+  ForStmt: ["init", "cond", "post", "body"],
+  RangeStmt: ["key", "value", "x", "body"],
+  ImportSpec: ["name", "path"],
+  ValueSpec: ["names", "type", "values"],
+  TypeSpec: ["name", "typeParams", "type"],
+  GenDecl: ["specs"],
+  FuncDecl: ["recv", "name", "type", "body"],
+  File: ["packageName", "decls"],
+};
+
+/**
+ * Invoke a callback for each direct child of a node, in source order
+ */
+export function forEachChild(node: Node, fn: (child: Node) => void): void {
+  const keys = CHILD_KEYS[node.kind];
+  if (!keys) {
+    return;
+  }
+  for (const key of keys) {
+    const value = (node as any)[key];
+    if (!value) {
+      continue;
+    }
+    if (Array.isArray(value)) {
+      value.forEach((child: Node) => child && fn(child));
+    } else {
+      fn(value as Node);
+    }
+  }
+}
+
+/**
+ * Pre-order traversal. Returning false from the visitor skips the node's
+ * children, matching go/ast.Inspect.
+ */
+export function inspect(
+  node: Node,
+  visit: (node: Node, parents: Node[]) => boolean | void,
+): void {
+  const parents: Node[] = [];
+  const walk = (current: Node) => {
+    if (visit(current, parents) === false) {
+      return;
+    }
+    parents.push(current);
+    forEachChild(current, walk);
+    parents.pop();
+  };
+  walk(node);
+}
@@ -0,0 +1,223 @@
+import * as fs from "fs";
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoFingerprinter, goFindingSymbol } from "./fingerprint.js";
+
+/**
+ * Go Findings Baseline
+ * ====================
+ * Records the findings a codebase already has so later runs report only new
+ * ones, which lets a legacy repository adopt the checks without fixing
+ * everything first. Findings are matched by their fingerprints (see
+ * fingerprint.ts), so edits elsewhere in the file do not resurface them, and
+ * neither does moving a declaration to another file of its package.
+ */
+
+/**
+ * Version of the baseline file format. Version 1 fingerprints included the
+ * file path.
+ */
+export const GO_BASELINE_VERSION = 2;
+
+export interface GoBaselineOptions {
+  /** Directory file paths are recorded relative to */
+  root?: string;
+}
+
+export interface GoBaselineEntry {
+  fingerprint: string;
+  rule: string;
+  /** File path, relative to the root, with `/` separators */
+  filePath: string;
+  /** Declaration holding the finding; empty at file level */
+  symbol: string;
+  /** Message when recorded, for people reading the file */
+  message: string;
+}
+
+export interface GoBaseline {
+  version: number;
+  entries: GoBaselineEntry[];
+}
+
+export interface GoFingerprintedFinding<T extends GoFinding = GoFinding> {
+  finding: T;
+  fingerprint: string;
+  filePath: string;
+  symbol: string;
+}
+
+export interface GoBaselineComparison<T extends GoFinding = GoFinding> {
+  /** Findings the baseline does not record */
+  findings: T[];
+  /** Findings the baseline records */
+  suppressed: T[];
+  /** Entries no current finding matches, most likely fixed */
+  stale: GoBaselineEntry[];
+}
+
+/**
+ * Error raised for baseline files that cannot be read
+ */
+export class GoBaselineError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoBaselineError";
+  }
+}
+
+function displayPath(filePath: string, options: GoBaselineOptions): string {
+  return (options.root ? path.relative(options.root, filePath) : filePath)
+    .split(path.sep)
+    .join("/");
+}
+
+/**
+ * Fingerprint findings, which stay the same while the code around a finding
+ * moves
+ */
+export function fingerprintGoFindings<T extends GoFinding>(
+  findings: T[],
+  files: GoFile[],
+  options: GoBaselineOptions = {},
+): GoFingerprintedFinding<T>[] {
+  const byPath = new Map(files.map((file) => [file.filePath, file]));
+  const fingerprinter = new GoFingerprinter(files);
+  return sortFindings([...findings]).map((finding) => {
+    const file = byPath.get(finding.filePath);
+    return {
+      finding,
+      fingerprint: fingerprinter.fingerprint(finding),
+      filePath: displayPath(finding.filePath, options),
+      symbol: file ? goFindingSymbol(file, finding.line) : "",
+    };
+  });
+}
+
+/**
+ * A baseline recording every finding
+ */
+export function createGoBaseline(
+  findings: GoFinding[],
+  files: GoFile[],
+  options: GoBaselineOptions = {},
+): GoBaseline {
+  const entries = fingerprintGoFindings(findings, files, options).map(
+    ({ finding, fingerprint, filePath, symbol }) => ({
+      fingerprint,
+      rule: finding.rule,
+      filePath,
+      symbol,
+      message: finding.message,
+    }),
+  );
+  return { version: GO_BASELINE_VERSION, entries };
+}
+
+/**
+ * Split findings into those the baseline records and new ones
+ */
+export function compareGoBaseline<T extends GoFinding>(
+  findings: T[],
+  files: GoFile[],
+  baseline: GoBaseline,
+  options: GoBaselineOptions = {},
+): GoBaselineComparison<T> {
+  const known = new Set(baseline.entries.map((entry) => entry.fingerprint));
+  const seen = new Set<string>();
+  const result: GoBaselineComparison<T> = {
+    findings: [],
+    suppressed: [],
+    stale: [],
+  };
+  for (const { finding, fingerprint } of fingerprintGoFindings(
+    findings,
+    files,
+    options,
+  )) {
+    seen.add(fingerprint);
+    (known.has(fingerprint) ? result.suppressed : result.findings).push(
+      finding,
+    );
+  }
+  result.stale = baseline.entries.filter(
+    (entry) => !seen.has(entry.fingerprint),
+  );
+  return result;
+}
+
+/**
+ * Drop the entries of fixed findings from a baseline without recording new
+ * ones, so the baseline only ever shrinks
+ */
+export function pruneGoBaseline(
+  baseline: GoBaseline,
+  findings: GoFinding[],
+  files: GoFile[],
+  options: GoBaselineOptions = {},
+): GoBaseline {
+  const { stale } = compareGoBaseline(findings, files, baseline, options);
+  const fixed = new Set(stale);
+  return {
+    version: GO_BASELINE_VERSION,
+    entries: baseline.entries.filter((entry) => !fixed.has(entry)),
+  };
+}
+
+/**
+ * Render a baseline as JSON, entries ordered by file and fingerprint
+ */
+export function serializeGoBaseline(baseline: GoBaseline): string {
+  const entries = [...baseline.entries].sort(
+    (a, b) =>
+      (a.filePath < b.filePath ? -1 : a.filePath > b.filePath ? 1 : 0) ||
+      (a.fingerprint < b.fingerprint ? -1 : 1),
+  );
+  return JSON.stringify({ version: baseline.version, entries }, null, 2) + "\n";
+}
+
+/**
+ * Parse a baseline file written by {@link serializeGoBaseline}
+ */
+export function parseGoBaseline(text: string): GoBaseline {
+  let json: unknown;
+  try {
+    json = JSON.parse(text);
+  } catch (error) {
+    throw new GoBaselineError(
+      `Baseline is not valid JSON: ${error instanceof Error ? error.message : String(error)}`,
+    );
+  }
+  const baseline = json as Partial<GoBaseline> | null;
+  if (baseline?.version !== GO_BASELINE_VERSION) {
+    throw new GoBaselineError(
+      `Unsupported baseline version ${JSON.stringify(baseline?.version)}; expected ${GO_BASELINE_VERSION}`,
+    );
+  }
+  if (
+    !Array.isArray(baseline.entries) ||
+    baseline.entries.some((entry) => typeof entry?.fingerprint !== "string")
+  ) {
+    throw new GoBaselineError("Baseline entries must each have a fingerprint");
+  }
+  return { version: baseline.version, entries: baseline.entries };
+}
+
+/**
+ * Read a baseline file
+ */
+export async function readGoBaseline(filePath: string): Promise<GoBaseline> {
+  return parseGoBaseline(await fs.promises.readFile(filePath, "utf-8"));
+}
+
+/**
+ * Write a baseline file, creating its directory when needed
+ */
+export async function writeGoBaseline(
+  filePath: string,
+  baseline: GoBaseline,
+): Promise<void> {
+  await fs.promises.mkdir(path.dirname(filePath), { recursive: true });
+  await fs.promises.writeFile(filePath, serializeGoBaseline(baseline), "utf-8");
+}
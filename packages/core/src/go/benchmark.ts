@@ -0,0 +1,278 @@
+import * as fs from "fs";
+import { Expr, FuncDecl, GoFile } from "./ast.js";
+import { parseGoFile } from "./parser.js";
+
+/**
+ * Benchmarks
+ * ==========
+ * Scaffolds a `Benchmark` function per Go function, calling it `b.N` times
+ * with allocations reported, so a refactor can be compared against a
+ * baseline with `go test -bench`. Inputs are fixed rather than sampled: one
+ * representative value per basic type and 100-element slices filled from
+ * the index, built before the timer starts. Results go to package variables,
+ * which keeps the compiler from optimizing the calls away. Functions whose
+ * parameters are not basic types or slices of them are skipped, since their
+ * inputs cannot be synthesized without knowing what a realistic value looks
+ * like.
+ */
+
+export interface BenchmarkOptions {
+  /** Functions to benchmark; defaults to every function in the file */
+  functions?: string[];
+  /** Log the reason each skipped function was not benchmarked */
+  verbose?: boolean;
+}
+
+export interface BenchmarkSkip {
+  name: string;
+  reason: string;
+}
+
+export interface BenchmarkResult {
+  /** Path of the generated benchmark file, beside the source file */
+  testPath: string;
+  /** Generated Go source; empty when no function could be benchmarked */
+  content: string;
+  functions: string[];
+  skipped: BenchmarkSkip[];
+}
+
+/** Length of the slices passed to benchmarked functions */
+export const BENCHMARK_SLICE_LENGTH = 100;
+
+const INTEGER_TYPES = [
+  "int8",
+  "int16",
+  "int32",
+  "int64",
+  "uint",
+  "uint16",
+  "uint32",
+  "uint64",
+];
+
+// Representative value, and slice element written in terms of the index `i`,
+// per basic type
+const INPUTS: Record<string, { value: string; element: string }> = {
+  int: { value: "20", element: "i" },
+  ...Object.fromEntries(
+    INTEGER_TYPES.map((type) => [
+      type,
+      { value: `${type}(20)`, element: `${type}(i)` },
+    ]),
+  ),
+  uint8: { value: "uint8('a')", element: "uint8('a' + i%26)" },
+  byte: { value: "byte('a')", element: "byte('a' + i%26)" },
+  rune: { value: "'a'", element: "rune('a' + i%26)" },
+  float32: { value: "float32(1.5)", element: "float32(i) * 0.5" },
+  float64: { value: "1.5", element: "float64(i) * 0.5" },
+  string: {
+    value: '"The quick brown fox jumps over the lazy dog"',
+    element: '"item" + strconv.Itoa(i)',
+  },
+  bool: { value: "true", element: "i%2 == 0" },
+};
+
+// Names the benchmark itself declares
+const RESERVED = new Set(["b", "i"]);
+
+function basicType(expr: Expr): string | undefined {
+  return expr.kind === "Ident" && INPUTS[expr.name] ? expr.name : undefined;
+}
+
+// Element type of a parameter taking a slice of a basic type
+function sliceElement(expr: Expr): string | undefined {
+  if (expr.kind === "Ellipsis") return expr.elt && basicType(expr.elt);
+  if (expr.kind === "ArrayType" && !expr.len) return basicType(expr.elt);
+  return undefined;
+}
+
+/**
+ * Why a function cannot be benchmarked with synthesized inputs, or undefined
+ * when it can
+ */
+export function benchmarkSkipReason(
+  file: GoFile,
+  decl: FuncDecl,
+): string | undefined {
+  if (decl.recv) return "method; needs a receiver value";
+  if (decl.type.typeParams) return "generic; type arguments cannot be chosen";
+  if (!decl.body) return "declared without a body";
+  const name = decl.name.name;
+  if (name === "init" || name === "main") return `${name} cannot be called`;
+  for (const field of decl.type.params.list) {
+    if (!basicType(field.type) && !sliceElement(field.type)) {
+      const type = file.source.slice(field.type.pos, field.type.end);
+      return `takes a ${type} parameter, which cannot be synthesized cheaply`;
+    }
+  }
+  return undefined;
+}
+
+/**
+ * Path of the benchmark file generated for a Go source file
+ */
+export function benchmarkTestPath(filePath: string): string {
+  return filePath.replace(/\.go$/, "") + "_bench_test.go";
+}
+
+function benchmarkFunction(
+  file: GoFile,
+  decl: FuncDecl,
+  imports: Set<string>,
+): string {
+  const name = decl.name.name;
+  const setup: string[] = [];
+  const args: string[] = [];
+  let index = 0;
+  let built = false;
+  for (const field of decl.type.params.list) {
+    const names = field.names.length > 0 ? field.names : [undefined];
+    for (const ident of names) {
+      const variable =
+        ident && ident.name !== "_" && !RESERVED.has(ident.name)
+          ? ident.name
+          : `arg${index}`;
+      index++;
+
+      const basic = basicType(field.type);
+      if (basic) {
+        setup.push(`\t${variable} := ${INPUTS[basic].value}`);
+        args.push(variable);
+        continue;
+      }
+      const element = sliceElement(field.type);
+      if (element === "string") imports.add("strconv");
+      built = true;
+      setup.push(
+        `\t${variable} := make([]${element}, ${BENCHMARK_SLICE_LENGTH})`,
+        `\tfor i := range ${variable} {`,
+        `\t\t${variable}[i] = ${INPUTS[element].element}`,
+        "\t}",
+      );
+      args.push(field.type.kind === "Ellipsis" ? `${variable}...` : variable);
+    }
+  }
+
+  // Results are stored in package variables so the compiler cannot drop
+  // the call
+  const results = (decl.type.results?.list ?? []).flatMap((field) =>
+    Array(Math.max(field.names.length, 1)).fill(
+      file.source.slice(field.type.pos, field.type.end),
+    ),
+  );
+  const sink = `benchmark${name.charAt(0).toUpperCase()}${name.slice(1)}Result`;
+  const sinks = results.map((_, i) =>
+    results.length === 1 ? sink : `${sink}${i}`,
+  );
+  const declarations =
+    results.length === 1
+      ? [`var ${sink} ${results[0]}`, ""]
+      : results.length > 1
+        ? [
+            "var (",
+            ...sinks.map((variable, i) => `\t${variable} ${results[i]}`),
+            ")",
+            "",
+          ]
+        : [];
+  const call = `${name}(${args.join(", ")})`;
+
+  // go test skips names where a lowercase letter follows "Benchmark"
+  const benchmarkName = /^[a-z]/.test(name) ? `_${name}` : name;
+  return [
+    ...declarations,
+    `func Benchmark${benchmarkName}(b *testing.B) {`,
+    ...setup,
+    "\tb.ReportAllocs()",
+    ...(built ? ["\tb.ResetTimer()"] : []),
+    "\tfor i := 0; i < b.N; i++ {",
+    sinks.length > 0 ? `\t\t${sinks.join(", ")} = ${call}` : `\t\t${call}`,
+    "\t}",
+    "}",
+  ].join("\n");
+}
+
+/**
+ * Generate benchmarks for the functions of a file whose inputs can be
+ * synthesized
+ */
+export function generateBenchmarks(
+  file: GoFile,
+  options: BenchmarkOptions = {},
+): BenchmarkResult {
+  const wanted = options.functions && new Set(options.functions);
+  const functions: string[] = [];
+  const skipped: BenchmarkSkip[] = [];
+  const benchmarks: string[] = [];
+  const imports = new Set(["testing"]);
+
+  const skip = (name: string, reason: string) => {
+    skipped.push({ name, reason });
+    if (options.verbose) {
+      console.log(`⏭️  Skipping ${name}: ${reason}`);
+    }
+  };
+
+  for (const decl of file.decls) {
+    if (decl.kind !== "FuncDecl") continue;
+    const name = decl.recv
+      ? `${file.source.slice(decl.recv.pos, decl.recv.end)} ${decl.name.name}`
+      : decl.name.name;
+    if (wanted && !wanted.has(name) && !wanted.has(decl.name.name)) continue;
+    wanted?.delete(name);
+    wanted?.delete(decl.name.name);
+    const reason = benchmarkSkipReason(file, decl);
+    if (reason) {
+      skip(name, reason);
+      continue;
+    }
+    functions.push(name);
+    benchmarks.push(benchmarkFunction(file, decl, imports));
+  }
+  wanted?.forEach((name) => skip(name, "not declared in this file"));
+
+  const importLines =
+    imports.size === 1
+      ? [`import "testing"`]
+      : ["import (", ...[...imports].sort().map((path) => `\t"${path}"`), ")"];
+  const content =
+    benchmarks.length === 0
+      ? ""
+      : [
+          "// Code generated by refactogent. DO NOT EDIT.",
+          "",
+          "// Benchmarks record a performance baseline before refactoring; compare",
+          "// runs with go test -bench . -benchmem.",
+          "",
+          `package ${file.packageName.name}`,
+          "",
+          ...importLines,
+          "",
+          benchmarks.join("\n\n"),
+          "",
+        ].join("\n");
+
+  return {
+    testPath: benchmarkTestPath(file.filePath),
+    content,
+    functions,
+    skipped,
+  };
+}
+
+/**
+ * Parse a Go file and write its benchmarks beside it. Nothing is written when
+ * no function in the file can be benchmarked.
+ */
+export async function writeBenchmarks(
+  filePath: string,
+  options: BenchmarkOptions = {},
+): Promise<BenchmarkResult> {
+  const source = await fs.promises.readFile(filePath, "utf-8");
+  const result = generateBenchmarks(parseGoFile(source, filePath), options);
+  if (result.content) {
+    await fs.promises.writeFile(result.testPath, result.content, "utf-8");
+  }
+  return result;
+}
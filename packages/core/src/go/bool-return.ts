@@ -0,0 +1,274 @@
+import { TextEdit } from "../diff.js";
+import {
+  Expr,
+  FuncDecl,
+  FuncType,
+  GoFile,
+  IfStmt,
+  Node,
+  Stmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+
+/**
+ * Boolean Returns
+ * ===============
+ * Flags `if` statements in functions returning a `bool` whose branches do
+ * nothing but return `true` and `false`, and rewrites them to return the
+ * condition directly:
+ *
+ *     if cond { return true } else { return false }  =>  return cond
+ *     if cond { return false }; return true           =>  return !cond
+ *
+ * The condition is evaluated exactly as before, so `&&` and `||` still
+ * short-circuit and calls in it still run once. A condition is only ever
+ * negated as a whole: `==` and `!=` are flipped and a leading `!` dropped,
+ * but `<` is not turned into `>=`, which differs for NaN. Branches containing
+ * anything besides the return, an `if` with an init statement (whose
+ * variables would leak into the enclosing block), comments that the rewrite
+ * would drop and conditions of a named boolean type are left alone.
+ */
+
+export interface GoBoolReturnFinding extends GoFinding {
+  rule: "redundant-bool-return";
+  /** Source of the condition */
+  condition: string;
+  /** Whether the condition is negated by the rewrite */
+  negated: boolean;
+}
+
+export interface SimplifyBoolReturnOptions {
+  /**
+   * Line of an `if` reported by {@link findRedundantBoolReturns} (default:
+   * every one in the file)
+   */
+  line?: number;
+}
+
+export interface SimplifyBoolReturnResult extends GoRefactorResult {
+  /** Positions of the rewritten `if` statements */
+  simplified: { line: number; column: number }[];
+}
+
+interface BoolReturn {
+  stmt: IfStmt;
+  /** End of the replaced statements: the `if`, or the `return` after it */
+  end: number;
+  negated: boolean;
+  replacement: string;
+}
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+// Whether a function's only result is declared as bool
+function returnsBool(type: FuncType): boolean {
+  const results = type.results?.list ?? [];
+  return (
+    results.length === 1 &&
+    results[0].names.length <= 1 &&
+    results[0].type.kind === "Ident" &&
+    results[0].type.name === "bool"
+  );
+}
+
+// The innermost function around a node decides what its returns return
+function enclosingFunction(parents: Node[]): FuncType | undefined {
+  for (let i = parents.length - 1; i >= 0; i--) {
+    const parent = parents[i];
+    if (parent.kind === "FuncLit" || parent.kind === "FuncDecl") {
+      return parent.type;
+    }
+  }
+  return undefined;
+}
+
+class BoolReturnAnalyzer {
+  private readonly file: GoFile;
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  // The constant a statement list returns, when that is all it does
+  private returned(list: Stmt[]): boolean | undefined {
+    if (list.length !== 1) return undefined;
+    const [stmt] = list;
+    if (stmt.kind !== "ReturnStmt" || stmt.results.length !== 1) {
+      return undefined;
+    }
+    const result = unparen(stmt.results[0]);
+    // A local named true or false is not the constant
+    if (result.kind !== "Ident" || this.scopes.resolved.has(result)) {
+      return undefined;
+    }
+    if (result.name === "true") return true;
+    if (result.name === "false") return false;
+    return undefined;
+  }
+
+  private negate(cond: Expr): string {
+    const inner = unparen(cond);
+    if (inner.kind === "UnaryExpr" && inner.op === "!") {
+      return this.text(inner.x);
+    }
+    if (
+      inner.kind === "BinaryExpr" &&
+      (inner.op === "==" || inner.op === "!=")
+    ) {
+      const op = inner.op === "==" ? "!=" : "==";
+      return `${this.text(inner.x)} ${op} ${this.text(inner.y)}`;
+    }
+    return inner.kind === "BinaryExpr"
+      ? `!(${this.text(inner)})`
+      : `!${this.text(inner)}`;
+  }
+
+  // Comments in the replaced range, outside the condition, would be lost
+  private hasComments(stmt: IfStmt, end: number): boolean {
+    return this.file.comments.some(
+      (group) =>
+        group.pos >= stmt.pos &&
+        group.end <= end &&
+        !(group.pos >= stmt.cond.pos && group.end <= stmt.cond.end),
+    );
+  }
+
+  private match(stmt: IfStmt, next?: Stmt): BoolReturn | undefined {
+    if (stmt.init) return undefined;
+    const then = this.returned(stmt.body.list);
+    if (then === undefined) return undefined;
+    let otherwise: boolean | undefined;
+    let end = stmt.end;
+    if (stmt.else?.kind === "BlockStmt") {
+      otherwise = this.returned(stmt.else.list);
+    } else if (!stmt.else && next) {
+      otherwise = this.returned([next]);
+      end = next.end;
+    }
+    if (otherwise === undefined || otherwise === then) return undefined;
+    const type = this.types.typeOf(stmt.cond);
+    if (type !== undefined && type !== "bool") return undefined;
+    if (this.hasComments(stmt, end)) return undefined;
+    const negated = !then;
+    const condition = negated
+      ? this.negate(stmt.cond)
+      : this.text(unparen(stmt.cond));
+    return { stmt, end, negated, replacement: `return ${condition}` };
+  }
+
+  private statementLists(node: Node): Stmt[][] {
+    switch (node.kind) {
+      case "BlockStmt":
+        return [node.list];
+      case "CaseClause":
+      case "CommClause":
+        return [node.body];
+      default:
+        return [];
+    }
+  }
+
+  private check(decl: FuncDecl, found: BoolReturn[]): void {
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+    inspect(decl, (node, parents) => {
+      const type = enclosingFunction(parents);
+      if (!type || !returnsBool(type)) return;
+      for (const list of this.statementLists(node)) {
+        list.forEach((stmt, i) => {
+          if (stmt.kind !== "IfStmt") return;
+          const match = this.match(stmt, list[i + 1]);
+          if (match) found.push(match);
+        });
+      }
+    });
+  }
+
+  analyze(): BoolReturn[] {
+    const found: BoolReturn[] = [];
+    for (const decl of this.file.decls) {
+      if (decl.kind === "FuncDecl" && decl.body) this.check(decl, found);
+    }
+    return found.sort((a, b) => a.stmt.pos - b.stmt.pos);
+  }
+
+  finding(match: BoolReturn): GoBoolReturnFinding {
+    const condition = this.text(unparen(match.stmt.cond));
+    return {
+      rule: "redundant-bool-return",
+      severity: "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(match.stmt.pos),
+      message: `if ${condition} returns ${match.negated ? "false and true" : "true and false"}; use ${match.replacement}`,
+      fix: match.replacement,
+      condition,
+      negated: match.negated,
+    };
+  }
+
+  simplify(options: SimplifyBoolReturnOptions): SimplifyBoolReturnResult {
+    const { sourceMap } = this.file;
+    const matches = this.analyze().filter(
+      (match) =>
+        options.line === undefined ||
+        sourceMap.line(match.stmt.pos) === options.line,
+    );
+    if (options.line !== undefined && matches.length === 0) {
+      throw new GoRefactorError(
+        `Line ${options.line} has no if statement returning true and false`,
+      );
+    }
+    const edits: TextEdit[] = matches.map((match) => ({
+      start: match.stmt.pos,
+      end: match.end,
+      newText: match.replacement,
+    }));
+    return {
+      ...refactorResult(this.file, edits),
+      simplified: matches.map((match) => sourceMap.position(match.stmt.pos)),
+    };
+  }
+}
+
+/**
+ * Find if statements that return true and false where returning the
+ * condition would do
+ */
+export function findRedundantBoolReturns(
+  files: GoFile[],
+): GoBoolReturnFinding[] {
+  const findings: GoBoolReturnFinding[] = [];
+  for (const file of files) {
+    const analyzer = new BoolReturnAnalyzer(file);
+    findings.push(
+      ...analyzer.analyze().map((match) => analyzer.finding(match)),
+    );
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Rewrite if statements returning true and false to return their condition
+ */
+export function simplifyBoolReturns(
+  file: GoFile,
+  options: SimplifyBoolReturnOptions = {},
+): SimplifyBoolReturnResult {
+  return new BoolReturnAnalyzer(file).simplify(options);
+}
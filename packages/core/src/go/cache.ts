@@ -0,0 +1,247 @@
+import { createHash } from "crypto";
+import * as fs from "fs";
+import * as path from "path";
+import { GoOverlay, GoOverlayEntries, goOverlay } from "./overlay.js";
+import { parseGoFile } from "./parser.js";
+import {
+  extractGoFileSymbols,
+  GoFileSymbols,
+  groupMethods,
+} from "./symbols.js";
+
+/**
+ * Version of the symbol tables produced by the Go analyzer. Bump it whenever
+ * the shape or meaning of {@link GoFileSymbols} changes so cached entries
+ * written by an older analyzer are not reused.
+ */
+export const GO_ANALYZER_VERSION = "11";
+
+/**
+ * Storage for per-file symbol tables, keyed by path and content hash. Methods
+ * may be synchronous or return promises, so callers can back the cache with
+ * memory, disk or a remote store.
+ */
+export interface Cache {
+  get(
+    filePath: string,
+    hash: string,
+  ): GoFileSymbols | undefined | Promise<GoFileSymbols | undefined>;
+  put(
+    filePath: string,
+    hash: string,
+    symbols: GoFileSymbols,
+  ): void | Promise<void>;
+}
+
+/**
+ * SHA-256 of a file's contents. The analyzer version is folded into the hash,
+ * so upgrading the analyzer changes every key and invalidates old entries.
+ */
+export function contentHash(
+  content: string,
+  analyzerVersion: string = GO_ANALYZER_VERSION,
+): string {
+  return createHash("sha256")
+    .update(analyzerVersion)
+    .update("\0")
+    .update(content)
+    .digest("hex");
+}
+
+/**
+ * Cache that lives for the lifetime of the process
+ */
+export class MemoryCache implements Cache {
+  private entries = new Map<string, { hash: string; symbols: GoFileSymbols }>();
+
+  get(filePath: string, hash: string): GoFileSymbols | undefined {
+    const entry = this.entries.get(filePath);
+    return entry?.hash === hash ? entry.symbols : undefined;
+  }
+
+  put(filePath: string, hash: string, symbols: GoFileSymbols): void {
+    this.entries.set(filePath, { hash, symbols });
+  }
+}
+
+// Distinguishes the temporary files of concurrent writes
+let writes = 0;
+
+/**
+ * Cache persisted as one JSON file per source file under a directory. Entries
+ * are written to a temporary file and renamed into place, so concurrent
+ * readers and writers, in this process or another, only ever see whole
+ * entries.
+ */
+export class DiskCache implements Cache {
+  private readonly directory: string;
+
+  constructor(directory: string) {
+    this.directory = directory;
+  }
+
+  private entryPath(filePath: string): string {
+    const name = createHash("sha256").update(filePath).digest("hex");
+    return path.join(this.directory, `${name}.json`);
+  }
+
+  async get(
+    filePath: string,
+    hash: string,
+  ): Promise<GoFileSymbols | undefined> {
+    let entry: { path: string; hash: string; symbols: GoFileSymbols };
+    try {
+      entry = JSON.parse(
+        await fs.promises.readFile(this.entryPath(filePath), "utf-8"),
+      );
+    } catch {
+      // Missing or corrupt entries are treated as misses
+      return undefined;
+    }
+    if (entry.path !== filePath || entry.hash !== hash) {
+      return undefined;
+    }
+    // JSON duplicates methods shared between types and the method list
+    const { symbols } = entry;
+    symbols.types.forEach((type) => (type.methods = []));
+    groupMethods(symbols.types, symbols.methods);
+    return symbols;
+  }
+
+  async put(
+    filePath: string,
+    hash: string,
+    symbols: GoFileSymbols,
+  ): Promise<void> {
+    await fs.promises.mkdir(this.directory, { recursive: true });
+    const entryPath = this.entryPath(filePath);
+    const temporary = `${entryPath}.${process.pid}-${writes++}.tmp`;
+    try {
+      await fs.promises.writeFile(
+        temporary,
+        JSON.stringify({ path: filePath, hash, symbols }),
+        "utf-8",
+      );
+      await fs.promises.rename(temporary, entryPath);
+    } catch (error) {
+      await fs.promises.rm(temporary, { force: true });
+      throw error;
+    }
+  }
+}
+
+/**
+ * Result of analyzing one file incrementally
+ */
+export interface IncrementalResult {
+  symbols: GoFileSymbols;
+  hash: string;
+  /** True when the symbols came from the cache without re-parsing */
+  cached: boolean;
+}
+
+export interface AnalyzeFilesOptions {
+  /** Files analyzed at the same time (default: 1) */
+  concurrency?: number;
+}
+
+export interface IncrementalGoAnalyzerOptions {
+  /** Contents read in place of the files on disk */
+  overlay?: GoOverlay | GoOverlayEntries;
+}
+
+/**
+ * Re-analyzes Go files only when their contents changed since the symbols
+ * were cached. Analyses may run concurrently: a file requested again while
+ * it is being analyzed waits for that analysis instead of parsing it twice,
+ * and batches come back in input order however they were scheduled.
+ */
+export class IncrementalGoAnalyzer {
+  private readonly cache: Cache;
+  private readonly overlay: GoOverlay;
+  // Analyses in progress, by path and content hash
+  private readonly pending = new Map<string, Promise<IncrementalResult>>();
+  private hits = 0;
+  private misses = 0;
+
+  constructor(
+    cache: Cache = new MemoryCache(),
+    options: IncrementalGoAnalyzerOptions = {},
+  ) {
+    this.cache = cache;
+    this.overlay = goOverlay(options.overlay);
+  }
+
+  /**
+   * Analyze a file, reading it from the overlay or disk unless its content
+   * is given
+   */
+  async analyzeFile(
+    filePath: string,
+    content?: string,
+  ): Promise<IncrementalResult> {
+    const source = content ?? (await this.overlay.readFile(filePath));
+    const hash = contentHash(source);
+    const key = `${filePath}\0${hash}`;
+
+    const pending = this.pending.get(key);
+    if (pending) {
+      const result = await pending;
+      this.hits++;
+      return { ...result, cached: true };
+    }
+    const analysis = this.analyze(filePath, source, hash);
+    this.pending.set(key, analysis);
+    try {
+      return await analysis;
+    } finally {
+      this.pending.delete(key);
+    }
+  }
+
+  private async analyze(
+    filePath: string,
+    source: string,
+    hash: string,
+  ): Promise<IncrementalResult> {
+    const cached = await this.cache.get(filePath, hash);
+    if (cached) {
+      this.hits++;
+      return { symbols: cached, hash, cached: true };
+    }
+
+    this.misses++;
+    const symbols = extractGoFileSymbols(parseGoFile(source, filePath));
+    await this.cache.put(filePath, hash, symbols);
+    return { symbols, hash, cached: false };
+  }
+
+  /**
+   * Analyze files, returning their results in the order of `filePaths`
+   */
+  async analyzeFiles(
+    filePaths: string[],
+    options: AnalyzeFilesOptions = {},
+  ): Promise<IncrementalResult[]> {
+    const concurrency = Math.max(1, Math.floor(options.concurrency ?? 1));
+    const results = new Array<IncrementalResult>(filePaths.length);
+    let next = 0;
+    const worker = async () => {
+      while (next < filePaths.length) {
+        const index = next++;
+        results[index] = await this.analyzeFile(filePaths[index]);
+      }
+    };
+    await Promise.all(
+      Array.from({ length: Math.min(concurrency, filePaths.length) }, worker),
+    );
+    return results;
+  }
+
+  /**
+   * Cache hits and misses since the analyzer was created
+   */
+  getStats(): { hits: number; misses: number } {
+    return { hits: this.hits, misses: this.misses };
+  }
+}
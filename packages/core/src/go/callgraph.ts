@@ -0,0 +1,627 @@
+import { FuncDecl, GoFile, Node, forEachChild } from "./ast.js";
+import {
+  GoFileSymbols,
+  GoFunctionSymbol,
+  goFunctionSymbol,
+  isExportedName,
+} from "./symbols.js";
+
+/**
+ * Go Call Graph
+ * =============
+ * A syntactic call graph over one or more packages. Without type information,
+ * method calls are resolved by name: `x.m()` links to every method named `m`
+ * that could be reached, which over-approximates dynamic dispatch but never
+ * misses a real edge. Function values (`sort.Slice(xs, less)`) count as edges
+ * too, since the callee may invoke them.
+ */
+
+/**
+ * A function or method declared in the analyzed packages
+ */
+export interface GoCallGraphNode {
+  /** `pkg.Func` or `pkg.Type.Method` */
+  id: string;
+  packageName: string;
+  filePath: string;
+  symbol: GoFunctionSymbol;
+}
+
+/**
+ * Caller/callee adjacency between declared functions
+ */
+export interface GoCallGraph {
+  nodes: Map<string, GoCallGraphNode>;
+  /** Callee ids per caller id */
+  callees: Map<string, Set<string>>;
+  /** Caller ids per callee id */
+  callers: Map<string, Set<string>>;
+  /** Names of methods declared by any interface in the analyzed files */
+  interfaceMethods: Set<string>;
+  /**
+   * Calls that resolve to no declared function, per caller id: qualified
+   * calls as `import/path.Func`, method calls as `.Method`
+   */
+  externalCalls: Map<string, Set<string>>;
+}
+
+/**
+ * Id of a declared function within the call graph
+ */
+export function callGraphId(
+  packageName: string,
+  symbol: Pick<GoFunctionSymbol, "qualifiedName">,
+): string {
+  return `${packageName}.${symbol.qualifiedName}`;
+}
+
+// Every name a function declares locally, regardless of scope. Shadowing a
+// package function anywhere in the body hides it everywhere, which errs
+// toward fewer edges only for code that reuses a function's name.
+function localNames(decl: FuncDecl): Set<string> {
+  const names = new Set<string>();
+  const addFields = (node: Node) => {
+    if (node.kind === "FuncType") {
+      for (const list of [node.params, node.results]) {
+        list?.list.forEach((field) =>
+          field.names.forEach((name) => names.add(name.name)),
+        );
+      }
+    }
+  };
+  if (decl.recv) {
+    decl.recv.list.forEach((field) =>
+      field.names.forEach((name) => names.add(name.name)),
+    );
+  }
+  addFields(decl.type);
+
+  const visit = (node: Node) => {
+    switch (node.kind) {
+      case "FuncLit":
+        addFields(node.type);
+        break;
+      case "AssignStmt":
+        if (node.tok === ":=") {
+          node.lhs.forEach(
+            (expr) => expr.kind === "Ident" && names.add(expr.name),
+          );
+        }
+        break;
+      case "RangeStmt":
+        if (node.tok === ":=") {
+          [node.key, node.value].forEach(
+            (expr) => expr?.kind === "Ident" && names.add(expr.name),
+          );
+        }
+        break;
+      case "ValueSpec":
+        node.names.forEach((name) => names.add(name.name));
+        break;
+      case "TypeSpec":
+        names.add(node.name.name);
+        break;
+    }
+    forEachChild(node, visit);
+  };
+  if (decl.body) visit(decl.body);
+  return names;
+}
+
+/**
+ * A call as the walk of a file's bodies records it, before it is resolved
+ * against the declared functions of every file
+ */
+export type GoCallReference =
+  /** A package-level name, linked when a function declares it */
+  | { kind: "ident"; caller: string; callee: string }
+  /** `pkg.Name` through an import, external when nothing declares it */
+  | { kind: "qualified"; caller: string; callee: string; external?: string }
+  /** `x.Name`, linked to every method of that name the caller can reach */
+  | {
+      kind: "method";
+      caller: string;
+      name: string;
+      packageName: string;
+      called: boolean;
+    };
+
+// A resolved call, external when it names no declared function
+interface GoCallEdge {
+  caller: string;
+  callee: string;
+  external: boolean;
+}
+
+/**
+ * What one file contributes to the graph: its functions, the methods its
+ * interfaces declare and its calls. It is plain data, so it can be stored
+ * and given to a graph in place of the parsed file.
+ */
+export interface GoCallGraphFile {
+  filePath: string;
+  nodes: GoCallGraphNode[];
+  interfaceMethods: string[];
+  references: GoCallReference[];
+}
+
+interface GoFileCalls extends GoCallGraphFile {
+  /** Keys whose resolution the file's edges depend on, see `referenceKey` */
+  keys: Set<string>;
+  edges: GoCallEdge[];
+}
+
+// Node ids for calls of functions, `.Name` for calls of methods
+function referenceKey(reference: GoCallReference): string {
+  return reference.kind === "method" ? `.${reference.name}` : reference.callee;
+}
+
+/**
+ * The declarations and calls of one file, without resolving the calls
+ */
+export function goCallGraphFile(file: GoFile): GoCallGraphFile {
+  const packageName = file.packageName.name;
+  const nodes: GoCallGraphNode[] = [];
+  const interfaceMethods: string[] = [];
+  const references: GoCallReference[] = [];
+  const declIds = new Map<FuncDecl, string>();
+
+  for (const decl of file.decls) {
+    if (decl.kind !== "FuncDecl" || decl.name.name === "_") continue;
+    const symbol = goFunctionSymbol(file, decl);
+    const id = callGraphId(packageName, symbol);
+    nodes.push({ id, packageName, filePath: file.filePath, symbol });
+    declIds.set(decl, id);
+  }
+  forEachChild(file, function collect(node: Node) {
+    if (node.kind === "InterfaceType") {
+      node.methods.list.forEach((field) =>
+        field.names.forEach((name) => interfaceMethods.push(name.name)),
+      );
+    }
+    forEachChild(node, collect);
+  });
+
+  const imports = new Map<string, string>();
+  for (const spec of file.imports) {
+    const importPath = spec.path.value.slice(1, -1);
+    imports.set(spec.name?.name ?? importPath.split("/").pop(), importPath);
+  }
+
+  const walk = (caller: string, root: Node, locals: Set<string>) => {
+    const visit = (node: Node, called = false) => {
+      if (node.kind === "Ident") {
+        if (!locals.has(node.name)) {
+          references.push({
+            kind: "ident",
+            caller,
+            callee: `${packageName}.${node.name}`,
+          });
+        }
+        return;
+      }
+      if (node.kind === "CallExpr") {
+        visit(node.fun, true);
+        node.args.forEach((arg) => visit(arg));
+        return;
+      }
+      if (node.kind === "SelectorExpr") {
+        const sel = node.sel.name;
+        if (node.x.kind === "Ident" && !locals.has(node.x.name)) {
+          const importPath = imports.get(node.x.name);
+          if (importPath !== undefined) {
+            references.push({
+              kind: "qualified",
+              caller,
+              callee: `${importPath.split("/").pop()}.${sel}`,
+              external: called ? `${importPath}.${sel}` : undefined,
+            });
+            return;
+          }
+        }
+        references.push({
+          kind: "method",
+          caller,
+          name: sel,
+          packageName,
+          called,
+        });
+        visit(node.x);
+        return;
+      }
+      if (node.kind === "KeyValueExpr" && node.key.kind === "Ident") {
+        // Struct literal keys are field names
+        visit(node.value);
+        return;
+      }
+      forEachChild(node, visit);
+    };
+    visit(root);
+  };
+
+  for (const decl of file.decls) {
+    if (decl.kind === "FuncDecl") {
+      if (declIds.has(decl) && decl.body) {
+        walk(declIds.get(decl), decl.body, localNames(decl));
+      }
+    } else if (decl.kind === "GenDecl" && decl.tok !== "import") {
+      // Package-level initializers run as part of package initialization
+      walk(`${packageName}.init`, decl, new Set());
+    }
+  }
+
+  return { filePath: file.filePath, nodes, interfaceMethods, references };
+}
+
+/**
+ * Which files the last update of a {@link GoCallGraphBuilder} walked again
+ * and which it only resolved again
+ */
+export interface GoCallGraphUpdate {
+  /** Changed files, whose declarations and calls were collected anew */
+  rebuilt: string[];
+  /** Unchanged files calling a name the changed files declare or declared */
+  revalidated: string[];
+}
+
+/**
+ * A call graph patched as files change. Walking function bodies and
+ * measuring their symbols is the costly part of building a graph, so the
+ * builder keeps what each file declares and calls, and an update walks only
+ * the changed files. Calls from unchanged files are resolved again only when
+ * they name a function or method a changed file declares or used to
+ * declare; the edges of every other file are reused as they are.
+ */
+export class GoCallGraphBuilder {
+  private readonly files = new Map<string, GoFileCalls>();
+  private current: GoCallGraph;
+  private last: GoCallGraphUpdate = { rebuilt: [], revalidated: [] };
+
+  constructor(files: (GoFile | GoCallGraphFile)[] = []) {
+    this.update(files);
+  }
+
+  /** The graph as of the last update */
+  get graph(): GoCallGraph {
+    return this.current;
+  }
+
+  /** What the last update recomputed */
+  get lastUpdate(): GoCallGraphUpdate {
+    return this.last;
+  }
+
+  /**
+   * Patch the graph with new contents of changed files, files added since
+   * the last update and the paths of files that were deleted. Files may be
+   * given parsed or as collected by {@link goCallGraphFile}.
+   */
+  update(
+    changed: (GoFile | GoCallGraphFile)[],
+    removed: string[] = [],
+  ): GoCallGraph {
+    // Names whose resolution may differ: whatever the changed files
+    // declared before the update and declare after it
+    const affected = new Set<string>();
+    const declared = (calls: GoFileCalls | undefined) => {
+      for (const node of calls?.nodes ?? []) {
+        affected.add(node.id);
+        if (node.symbol.receiver) affected.add(`.${node.symbol.name}`);
+      }
+    };
+    for (const filePath of removed) {
+      declared(this.files.get(filePath));
+      this.files.delete(filePath);
+    }
+    const rebuilt = new Set<string>();
+    for (const file of changed) {
+      declared(this.files.get(file.filePath));
+      const collected = "kind" in file ? goCallGraphFile(file) : file;
+      const calls: GoFileCalls = {
+        ...collected,
+        keys: new Set(collected.references.map(referenceKey)),
+        edges: [],
+      };
+      declared(calls);
+      this.files.set(file.filePath, calls);
+      rebuilt.add(file.filePath);
+    }
+
+    const nodes = new Map<string, GoCallGraphNode>();
+    const interfaceMethods = new Set<string>();
+    // Method name -> ids, for name-based dispatch
+    const methodsByName = new Map<string, string[]>();
+    for (const calls of this.files.values()) {
+      for (const node of calls.nodes) {
+        nodes.set(node.id, node);
+        if (node.symbol.receiver) {
+          const ids = methodsByName.get(node.symbol.name) ?? [];
+          ids.push(node.id);
+          methodsByName.set(node.symbol.name, ids);
+        }
+      }
+      calls.interfaceMethods.forEach((name) => interfaceMethods.add(name));
+    }
+
+    const resolve = (reference: GoCallReference): GoCallEdge[] => {
+      switch (reference.kind) {
+        case "ident":
+          return nodes.has(reference.callee)
+            ? [{ ...reference, external: false }]
+            : [];
+        case "qualified": {
+          const { caller, callee, external } = reference;
+          if (nodes.has(callee)) return [{ caller, callee, external: false }];
+          return external === undefined
+            ? []
+            : [{ caller, callee: external, external: true }];
+        }
+        case "method": {
+          const { caller, name, packageName, called } = reference;
+          // Unexported methods are only reachable from their own package
+          const exported = isExportedName(name);
+          const targets = (methodsByName.get(name) ?? []).filter(
+            (id) => exported || nodes.get(id).packageName === packageName,
+          );
+          if (called && targets.length === 0) {
+            return [{ caller, callee: `.${name}`, external: true }];
+          }
+          return targets.map((id) => ({ caller, callee: id, external: false }));
+        }
+      }
+    };
+
+    const revalidated: string[] = [];
+    for (const [filePath, calls] of this.files) {
+      if (!rebuilt.has(filePath)) {
+        if (![...affected].some((key) => calls.keys.has(key))) continue;
+        revalidated.push(filePath);
+      }
+      calls.edges = calls.references.flatMap(resolve);
+    }
+
+    const callees = new Map<string, Set<string>>();
+    const callers = new Map<string, Set<string>>();
+    const externalCalls = new Map<string, Set<string>>();
+    for (const calls of this.files.values()) {
+      for (const { caller, callee, external } of calls.edges) {
+        if (external) {
+          if (!externalCalls.has(caller)) externalCalls.set(caller, new Set());
+          externalCalls.get(caller).add(callee);
+          continue;
+        }
+        if (!callees.has(caller)) callees.set(caller, new Set());
+        if (!callers.has(callee)) callers.set(callee, new Set());
+        callees.get(caller).add(callee);
+        callers.get(callee).add(caller);
+      }
+    }
+
+    this.last = { rebuilt: [...rebuilt], revalidated };
+    this.current = { nodes, callees, callers, interfaceMethods, externalCalls };
+    return this.current;
+  }
+}
+
+/**
+ * Build the call graph for a set of parsed files. Files may span several
+ * packages; they are grouped by package name.
+ */
+export function buildGoCallGraph(
+  files: (GoFile | GoCallGraphFile)[],
+): GoCallGraph {
+  return new GoCallGraphBuilder(files).graph;
+}
+
+/**
+ * Coupling of a declared function within the call graph
+ */
+export interface GoCallMetrics {
+  /** Distinct callers, counting package initialization as one */
+  fanIn: number;
+  /** Distinct callees, declared and external */
+  fanOut: number;
+}
+
+/**
+ * Fan-in and fan-out of every declared function, keyed by call graph id.
+ * High fan-in marks functions that are risky to change; high fan-out marks
+ * functions that coordinate too much.
+ */
+export function callGraphMetrics(
+  graph: GoCallGraph,
+): Map<string, GoCallMetrics> {
+  const metrics = new Map<string, GoCallMetrics>();
+  for (const id of graph.nodes.keys()) {
+    metrics.set(id, {
+      fanIn: graph.callers.get(id)?.size ?? 0,
+      fanOut:
+        (graph.callees.get(id)?.size ?? 0) +
+        (graph.externalCalls.get(id)?.size ?? 0),
+    });
+  }
+  return metrics;
+}
+
+/**
+ * Record fan-in and fan-out on the function and method symbols of the given
+ * files. Symbols without a node in the graph are left unchanged.
+ */
+export function annotateCallMetrics(
+  files: GoFileSymbols[],
+  graph: GoCallGraph,
+): void {
+  const metrics = callGraphMetrics(graph);
+  for (const file of files) {
+    for (const symbol of [...file.functions, ...file.methods]) {
+      const entry = metrics.get(callGraphId(file.packageName, symbol));
+      if (entry) {
+        symbol.fanIn = entry.fanIn;
+        symbol.fanOut = entry.fanOut;
+      }
+    }
+  }
+}
+
+/**
+ * Functions that reach themselves through calls: a strongly connected
+ * component of the call graph with several functions, or a single function
+ * calling itself
+ */
+export interface GoRecursionCycle {
+  /** Call graph ids of the functions, sorted */
+  ids: string[];
+  /** Whether this is one function calling itself */
+  direct: boolean;
+}
+
+/**
+ * Find every recursion in the call graph, direct and mutual, with Tarjan's
+ * strongly connected components algorithm. Edges resolved by method name
+ * can join functions that never call each other at run time, so a cycle
+ * through methods may be spurious; one that is real is never missed.
+ */
+export function findRecursionCycles(graph: GoCallGraph): GoRecursionCycle[] {
+  const index = new Map<string, number>();
+  const lowlink = new Map<string, number>();
+  const stack: string[] = [];
+  const onStack = new Set<string>();
+  const cycles: GoRecursionCycle[] = [];
+  const callees = (id: string) =>
+    [...(graph.callees.get(id) ?? [])].filter((callee) =>
+      graph.nodes.has(callee),
+    );
+
+  for (const root of [...graph.nodes.keys()].sort()) {
+    if (index.has(root)) continue;
+    // An explicit stack of nodes and their unvisited callees, so deep call
+    // chains cannot overflow the JavaScript stack
+    const frames: { id: string; pending: string[] }[] = [];
+    const enter = (id: string) => {
+      index.set(id, index.size);
+      lowlink.set(id, index.get(id));
+      stack.push(id);
+      onStack.add(id);
+      frames.push({ id, pending: callees(id) });
+    };
+    enter(root);
+    while (frames.length > 0) {
+      const frame = frames[frames.length - 1];
+      const callee = frame.pending.shift();
+      if (callee !== undefined) {
+        if (!index.has(callee)) {
+          enter(callee);
+        } else if (onStack.has(callee)) {
+          lowlink.set(
+            frame.id,
+            Math.min(lowlink.get(frame.id), index.get(callee)),
+          );
+        }
+        continue;
+      }
+      frames.pop();
+      const parent = frames[frames.length - 1];
+      if (parent) {
+        lowlink.set(
+          parent.id,
+          Math.min(lowlink.get(parent.id), lowlink.get(frame.id)),
+        );
+      }
+      if (lowlink.get(frame.id) !== index.get(frame.id)) continue;
+      const component: string[] = [];
+      for (let id = stack.pop(); ; id = stack.pop()) {
+        onStack.delete(id);
+        component.push(id);
+        if (id === frame.id) break;
+      }
+      const direct =
+        component.length === 1 &&
+        (graph.callees.get(frame.id)?.has(frame.id) ?? false);
+      if (component.length > 1 || direct) {
+        cycles.push({ ids: component.sort(), direct });
+      }
+    }
+  }
+  return cycles.sort((a, b) => (a.ids[0] < b.ids[0] ? -1 : 1));
+}
+
+/**
+ * Options for rendering a call graph as Graphviz DOT
+ */
+export interface GoCallGraphDotOptions {
+  /** Graph name; defaults to `callgraph` */
+  name?: string;
+  /** Omit the external node and the edges into it */
+  hideExternal?: boolean;
+}
+
+/** Id of the node that stands in for every unresolved callee */
+export const EXTERNAL_NODE_ID = "external";
+
+function dotId(value: string): string {
+  return `"${value.replace(/(["\\])/g, "\\$1")}"`;
+}
+
+/**
+ * Render a call graph as Graphviz DOT. Methods are boxes and free functions
+ * ellipses; exported symbols are filled blue and unexported ones grey. Calls
+ * outside the analyzed code share a single dashed `external` node.
+ */
+export function callGraphToDot(
+  graph: GoCallGraph,
+  options: GoCallGraphDotOptions = {},
+): string {
+  const packages = new Set(
+    [...graph.nodes.values()].map((node) => node.packageName),
+  );
+  const lines = [
+    `digraph ${dotId(options.name ?? "callgraph")} {`,
+    "  rankdir=LR;",
+    '  node [fontname="Helvetica", style=filled];',
+  ];
+
+  const ids = new Set(graph.nodes.keys());
+  for (const [caller, targets] of graph.callees) {
+    ids.add(caller);
+    targets.forEach((target) => ids.add(target));
+  }
+  for (const id of [...ids].sort()) {
+    const node = graph.nodes.get(id);
+    if (!node) {
+      // Pseudo-callers such as package initialization
+      lines.push(`  ${dotId(id)} [shape=diamond, fillcolor=white];`);
+      continue;
+    }
+    const { symbol } = node;
+    const label = packages.size > 1 ? id : symbol.qualifiedName;
+    const shape = symbol.receiver ? "box" : "ellipse";
+    const fill = symbol.isExported ? "lightblue" : "lightgrey";
+    lines.push(
+      `  ${dotId(id)} [label=${dotId(label)}, shape=${shape}, fillcolor=${fill}];`,
+    );
+  }
+
+  const external = !options.hideExternal && graph.externalCalls.size > 0;
+  if (external) {
+    lines.push(
+      `  ${dotId(EXTERNAL_NODE_ID)} [label="external", shape=box, style=dashed, fillcolor=white];`,
+    );
+  }
+
+  for (const caller of [...graph.callees.keys()].sort()) {
+    for (const callee of [...graph.callees.get(caller)].sort()) {
+      lines.push(`  ${dotId(caller)} -> ${dotId(callee)};`);
+    }
+  }
+  if (external) {
+    for (const caller of [...graph.externalCalls.keys()].sort()) {
+      const calls = [...graph.externalCalls.get(caller)].sort();
+      lines.push(
+        `  ${dotId(caller)} -> ${dotId(EXTERNAL_NODE_ID)} [style=dashed, tooltip=${dotId(calls.join(", "))}];`,
+      );
+    }
+  }
+
+  lines.push("}");
+  return lines.join("\n") + "\n";
+}
@@ -0,0 +1,566 @@
+import * as fs from "fs";
+import * as path from "path";
+import { Expr, FuncDecl, GoFile, Ident, Node, forEachChild } from "./ast.js";
+import { parseGoFile } from "./parser.js";
+
+/**
+ * Characterization Tests
+ * ======================
+ * Generates golden-style Go tests that record what deterministic functions
+ * currently return across a sampled input range. The first run captures a
+ * baseline under testdata/characterization; later runs fail on any drift, so
+ * the tests act as a safety net while a function is being refactored.
+ */
+
+/**
+ * Options for characterization test generation
+ */
+export interface CharacterizationOptions {
+  /** Upper bound on calls generated per function */
+  maxCases?: number;
+  /** Log the reason each skipped function was not characterized */
+  verbose?: boolean;
+}
+
+/**
+ * A function left out of the generated test, and why
+ */
+export interface CharacterizationSkip {
+  name: string;
+  reason: string;
+}
+
+/**
+ * Result of generating characterization tests for one Go file
+ */
+export interface CharacterizationResult {
+  /** Path of the generated test, beside the source file */
+  testPath: string;
+  /** Generated Go source; empty when no function was safe to call */
+  content: string;
+  functions: string[];
+  skipped: CharacterizationSkip[];
+}
+
+const DEFAULT_MAX_CASES = 64;
+
+// Sample inputs per basic type, ordered so truncation keeps the edge cases
+const SAMPLES: Record<string, string[]> = {
+  int: ["0", "1", "-1", "2", "3", "5", "10"],
+  uint: ["0", "1", "2", "3", "5", "10"],
+  float: ["0", "1", "-1", "0.5", "2.25", "-3.75"],
+  string: ['""', '"a"', '"hello"', '"Hello, World"', '"  spaced  "'],
+  bool: ["false", "true"],
+  byte: ["0", "'a'", "'Z'", "' '"],
+  rune: ["'a'", "'Z'", "'0'", "'é'"],
+};
+
+const BASIC_TYPES: Record<string, string> = {
+  int: "int",
+  int8: "int",
+  int16: "int",
+  int32: "int",
+  int64: "int",
+  uint: "uint",
+  uint16: "uint",
+  uint32: "uint",
+  uint64: "uint",
+  float32: "float",
+  float64: "float",
+  string: "string",
+  bool: "bool",
+  byte: "byte",
+  uint8: "byte",
+  rune: "rune",
+};
+
+const BUILTINS = new Set([
+  "append",
+  "cap",
+  "clear",
+  "complex",
+  "copy",
+  "delete",
+  "imag",
+  "len",
+  "make",
+  "max",
+  "min",
+  "new",
+  "panic",
+  "real",
+  "recover",
+]);
+
+// Standard library packages that touch the outside world or global state
+const IMPURE_PACKAGES = [
+  "bufio",
+  "crypto/rand",
+  "database/sql",
+  "io",
+  "log",
+  "math/rand",
+  "net",
+  "os",
+  "runtime",
+  "sync",
+  "syscall",
+  "time",
+  "unsafe",
+];
+
+// fmt is only pure when formatting into a string or error
+const PURE_FMT_FUNCTIONS = new Set(["Errorf", "Sprint", "Sprintf", "Sprintln"]);
+
+function isImpurePackage(importPath: string): boolean {
+  if (importPath.split("/")[0].includes(".")) {
+    // Third-party code cannot be vetted from a single file
+    return true;
+  }
+  return IMPURE_PACKAGES.some(
+    (pkg) => importPath === pkg || importPath.startsWith(`${pkg}/`),
+  );
+}
+
+function importNames(file: GoFile): Map<string, string> {
+  const names = new Map<string, string>();
+  for (const spec of file.imports) {
+    const importPath = spec.path.value.slice(1, -1);
+    const name = spec.name?.name ?? importPath.split("/").pop();
+    names.set(name, importPath);
+  }
+  return names;
+}
+
+function packageVariables(file: GoFile): Set<string> {
+  const names = new Set<string>();
+  for (const decl of file.decls) {
+    if (decl.kind !== "GenDecl" || decl.tok !== "var") continue;
+    for (const spec of decl.specs) {
+      if (spec.kind === "ValueSpec") {
+        spec.names.forEach((name) => names.add(name.name));
+      }
+    }
+  }
+  return names;
+}
+
+/**
+ * Classify a parameter or result type; returns the sample key for basic types
+ * and slices of them, or undefined when the type is not supported
+ */
+function sampleKind(expr: Expr): { kind: string; slice: boolean } | undefined {
+  if (expr.kind === "Ident" && BASIC_TYPES[expr.name]) {
+    return { kind: BASIC_TYPES[expr.name], slice: false };
+  }
+  if (
+    expr.kind === "ArrayType" &&
+    !expr.len &&
+    expr.elt.kind === "Ident" &&
+    BASIC_TYPES[expr.elt.name]
+  ) {
+    return { kind: BASIC_TYPES[expr.elt.name], slice: true };
+  }
+  return undefined;
+}
+
+function signatureSkipReason(decl: FuncDecl): string | undefined {
+  if (decl.recv) {
+    return "method; needs a receiver value";
+  }
+  if (decl.type.typeParams) {
+    return "generic; type arguments cannot be sampled";
+  }
+  if (!decl.body) {
+    return "declared without a body";
+  }
+  for (const field of decl.type.params.list) {
+    if (field.type.kind === "StarExpr") {
+      return "takes a pointer argument";
+    }
+    if (!sampleKind(field.type)) {
+      return field.type.kind === "Ellipsis"
+        ? "takes a variadic parameter"
+        : "takes an unsupported parameter";
+    }
+  }
+  const results = decl.type.results?.list ?? [];
+  if (results.length === 0) {
+    return "returns nothing to record";
+  }
+  for (const field of results) {
+    const isError = field.type.kind === "Ident" && field.type.name === "error";
+    if (!isError && !sampleKind(field.type)) {
+      return "returns an unsupported type";
+    }
+  }
+  return undefined;
+}
+
+/**
+ * Decide whether calling a function is free of I/O, concurrency, pointer
+ * arguments and package state. Returns the reason it is not, or undefined when
+ * the function is safe to characterize.
+ */
+export function characterizationSkipReason(
+  file: GoFile,
+  decl: FuncDecl,
+): string | undefined {
+  const functions = new Map<string, FuncDecl>();
+  for (const candidate of file.decls) {
+    if (candidate.kind === "FuncDecl" && !candidate.recv) {
+      functions.set(candidate.name.name, candidate);
+    }
+  }
+  const imports = importNames(file);
+  const globals = packageVariables(file);
+  const types = new Set<string>();
+  for (const candidate of file.decls) {
+    if (candidate.kind !== "GenDecl" || candidate.tok !== "type") continue;
+    for (const spec of candidate.specs) {
+      if (spec.kind === "TypeSpec") types.add(spec.name.name);
+    }
+  }
+
+  const verdicts = new Map<FuncDecl, string | undefined>();
+
+  const bodyReason = (fn: FuncDecl): string | undefined => {
+    if (verdicts.has(fn)) {
+      // Recursion is assumed pure until proven otherwise
+      return verdicts.get(fn);
+    }
+    verdicts.set(fn, undefined);
+
+    const locals = new Set<string>();
+    fn.type.params.list.forEach((field) =>
+      field.names.forEach((name) => locals.add(name.name)),
+    );
+    let reason: string | undefined;
+
+    const visit = (node: Node) => {
+      if (reason) return;
+      switch (node.kind) {
+        case "GoStmt":
+          reason = "starts a goroutine";
+          return;
+        case "SendStmt":
+        case "SelectStmt":
+          reason = "uses channels";
+          return;
+        case "UnaryExpr":
+          if (node.op === "<-") {
+            reason = "uses channels";
+            return;
+          }
+          break;
+        case "AssignStmt":
+          if (node.tok === ":=") {
+            node.lhs.forEach(
+              (expr) => expr.kind === "Ident" && locals.add(expr.name),
+            );
+          }
+          break;
+        case "RangeStmt":
+          if (node.tok === ":=") {
+            [node.key, node.value].forEach(
+              (expr) => expr?.kind === "Ident" && locals.add(expr.name),
+            );
+          }
+          break;
+        case "ValueSpec":
+          node.names.forEach((name) => locals.add(name.name));
+          break;
+        case "FuncLit":
+          node.type.params.list.forEach((field) =>
+            field.names.forEach((name) => locals.add(name.name)),
+          );
+          break;
+        case "KeyValueExpr":
+          // Keys of struct literals are field names, not variables
+          visit(node.value);
+          if (node.key.kind !== "Ident") visit(node.key);
+          return;
+        case "SelectorExpr":
+          if (node.x.kind === "Ident" && !locals.has(node.x.name)) {
+            const importPath = imports.get(node.x.name);
+            if (importPath !== undefined) {
+              if (
+                importPath === "fmt"
+                  ? !PURE_FMT_FUNCTIONS.has(node.sel.name)
+                  : isImpurePackage(importPath)
+              ) {
+                reason = `uses ${importPath}.${node.sel.name}`;
+              }
+              return;
+            }
+          }
+          visit(node.x);
+          return;
+        case "CallExpr":
+          if (node.fun.kind === "Ident" && !locals.has(node.fun.name)) {
+            const name = node.fun.name;
+            const callee = functions.get(name);
+            if (callee) {
+              const calleeReason = bodyReason(callee);
+              if (calleeReason) {
+                reason = `calls ${name}, which ${calleeReason}`;
+                return;
+              }
+            } else if (name === "print" || name === "println") {
+              reason = `uses ${name}`;
+              return;
+            } else if (
+              !BUILTINS.has(name) &&
+              !BASIC_TYPES[name] &&
+              !types.has(name) &&
+              name !== "error" &&
+              name !== "any"
+            ) {
+              reason = `calls ${name}, which is declared outside this file`;
+              return;
+            }
+          }
+          break;
+        case "Ident":
+          if (globals.has(node.name) && !locals.has(node.name)) {
+            reason = `reads package state (${node.name})`;
+          }
+          return;
+      }
+      forEachChild(node, visit);
+    };
+    if (fn.body) visit(fn.body);
+
+    verdicts.set(fn, reason);
+    return reason;
+  };
+
+  return signatureSkipReason(decl) ?? bodyReason(decl);
+}
+
+/**
+ * Path of the characterization test generated for a Go source file
+ */
+export function characterizationTestPath(filePath: string): string {
+  return filePath.replace(/\.go$/, "") + "_characterization_test.go";
+}
+
+// Helpers are suffixed per source file so several generated tests can share a
+// package without redeclaring them
+function helperSuffix(filePath: string): string {
+  const base = path.basename(filePath, ".go");
+  return base
+    .split(/[^A-Za-z0-9]+/)
+    .filter(Boolean)
+    .map((part) => part.charAt(0).toUpperCase() + part.slice(1))
+    .join("");
+}
+
+// Only called for types accepted by sampleKind
+function typeText(expr: Expr): string {
+  return expr.kind === "ArrayType"
+    ? `[]${(expr.elt as Ident).name}`
+    : (expr as Ident).name;
+}
+
+function sampleInputs(expr: Expr, count: number): string[] {
+  const { kind, slice } = sampleKind(expr);
+  const values = SAMPLES[kind];
+  if (!slice) {
+    return values.slice(0, count);
+  }
+  const text = typeText(expr);
+  return [
+    "nil",
+    `${text}{}`,
+    `${text}{${values[1]}}`,
+    `${text}{${values.slice(0, 3).join(", ")}}`,
+  ].slice(0, count);
+}
+
+function testFunction(
+  decl: FuncDecl,
+  suffix: string,
+  maxCases: number,
+): string {
+  const name = decl.name.name;
+  const params: Expr[] = [];
+  for (const field of decl.type.params.list) {
+    const count = Math.max(field.names.length, 1);
+    for (let i = 0; i < count; i++) params.push(field.type);
+  }
+  const resultCount = (decl.type.results?.list ?? []).reduce(
+    (sum, field) => sum + Math.max(field.names.length, 1),
+    0,
+  );
+
+  // Shrink each parameter's samples so the cartesian product stays bounded
+  const perParam =
+    params.length > 0
+      ? Math.max(2, Math.floor(Math.pow(maxCases, 1 / params.length)))
+      : 0;
+
+  const args = params.map((_, i) => `arg${i}`);
+  const results = Array.from({ length: resultCount }, (_, i) => `r${i}`);
+  // go test skips names where a lowercase letter follows "Test"
+  const testName = /^[a-z]/.test(name) ? `_${name}` : name;
+  const lines: string[] = [
+    `func TestCharacterize${testName}(t *testing.T) {`,
+    "\tvar got []string",
+  ];
+
+  let indent = "\t";
+  params.forEach((param, i) => {
+    const samples = sampleInputs(param, perParam).join(", ");
+    lines.push(
+      `${indent}for _, ${args[i]} := range []${typeText(param)}{${samples}} {`,
+    );
+    indent += "\t";
+  });
+
+  const format = params.map(() => "%#v").join(", ");
+  const callLabel =
+    params.length > 0
+      ? `fmt.Sprintf("${name}(${format})", ${args.join(", ")})`
+      : `"${name}()"`;
+  lines.push(
+    `${indent}got = append(got, characterize${suffix}Call(${callLabel}, func() []interface{} {`,
+    `${indent}\t${results.join(", ")} := ${name}(${args.join(", ")})`,
+    `${indent}\treturn []interface{}{${results.join(", ")}}`,
+    `${indent}}))`,
+  );
+
+  params.forEach(() => {
+    indent = indent.slice(1);
+    lines.push(`${indent}}`);
+  });
+  lines.push(`\tcheck${suffix}Characterization(t, "${name}", got)`, "}");
+  return lines.join("\n");
+}
+
+function helpers(suffix: string): string {
+  return `// characterize${suffix}Call formats one call and its results, recording
+// panics as output rather than failing the test.
+func characterize${suffix}Call(call string, fn func() []interface{}) (line string) {
+	defer func() {
+		if r := recover(); r != nil {
+			line = fmt.Sprintf("%s panics: %v", call, r)
+		}
+	}()
+	var formatted []string
+	for _, result := range fn() {
+		if err, ok := result.(error); ok {
+			formatted = append(formatted, fmt.Sprintf("error(%q)", err.Error()))
+		} else {
+			formatted = append(formatted, fmt.Sprintf("%#v", result))
+		}
+	}
+	return call + " = " + strings.Join(formatted, ", ")
+}
+
+// check${suffix}Characterization compares outputs with the recorded baseline,
+// capturing it on the first run or when UPDATE_CHARACTERIZATION is set.
+func check${suffix}Characterization(t *testing.T, name string, got []string) {
+	t.Helper()
+	golden := filepath.Join("testdata", "characterization", name+".golden")
+	actual := strings.Join(got, "\\n") + "\\n"
+	want, err := os.ReadFile(golden)
+	if os.Getenv("UPDATE_CHARACTERIZATION") != "" || os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, []byte(actual), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("recorded characterization baseline %s", golden)
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(want) != actual {
+		t.Errorf("%s drifted from %s\\n--- want\\n%s--- got\\n%s", name, golden, want, actual)
+	}
+}`;
+}
+
+/**
+ * Generate a characterization test for every safe-to-call function in a file
+ */
+export function generateCharacterizationTests(
+  file: GoFile,
+  options: CharacterizationOptions = {},
+): CharacterizationResult {
+  const maxCases = options.maxCases ?? DEFAULT_MAX_CASES;
+  const suffix = helperSuffix(file.filePath);
+  const functions: string[] = [];
+  const skipped: CharacterizationSkip[] = [];
+  const tests: string[] = [];
+
+  for (const decl of file.decls) {
+    if (decl.kind !== "FuncDecl") continue;
+    const name = decl.recv
+      ? `${file.source.slice(decl.recv.pos, decl.recv.end)} ${decl.name.name}`
+      : decl.name.name;
+    const reason = characterizationSkipReason(file, decl);
+    if (reason) {
+      skipped.push({ name, reason });
+      if (options.verbose) {
+        console.log(`⏭️  Skipping ${name}: ${reason}`);
+      }
+      continue;
+    }
+    functions.push(name);
+    tests.push(testFunction(decl, suffix, maxCases));
+  }
+
+  const content =
+    tests.length === 0
+      ? ""
+      : [
+          "// Code generated by refactogent. DO NOT EDIT.",
+          "",
+          "// Characterization tests record current behavior as a baseline before",
+          "// refactoring. Set UPDATE_CHARACTERIZATION=1 to re-record the baselines.",
+          "",
+          `package ${file.packageName.name}`,
+          "",
+          "import (",
+          '\t"fmt"',
+          '\t"os"',
+          '\t"path/filepath"',
+          '\t"strings"',
+          '\t"testing"',
+          ")",
+          "",
+          ...tests.map((test) => `${test}\n`),
+          helpers(suffix),
+          "",
+        ].join("\n");
+
+  return {
+    testPath: characterizationTestPath(file.filePath),
+    content,
+    functions,
+    skipped,
+  };
+}
+
+/**
+ * Parse a Go file and write its characterization test beside it. Nothing is
+ * written when no function in the file is safe to call.
+ */
+export async function writeCharacterizationTests(
+  filePath: string,
+  options: CharacterizationOptions = {},
+): Promise<CharacterizationResult> {
+  const source = await fs.promises.readFile(filePath, "utf-8");
+  const result = generateCharacterizationTests(
+    parseGoFile(source, filePath),
+    options,
+  );
+  if (result.content) {
+    await fs.promises.writeFile(result.testPath, result.content, "utf-8");
+  }
+  return result;
+}
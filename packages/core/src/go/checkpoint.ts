@@ -0,0 +1,163 @@
+import { createHash } from "crypto";
+import * as fs from "fs";
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { contentHash } from "./cache.js";
+import { GoFinding } from "./findings.js";
+import { GoSuppressedFinding } from "./suppress.js";
+
+/**
+ * Resumable Checks
+ * ================
+ * Checking a large repository takes long enough that an interrupted run
+ * should not start over. A checkpoint persists the results of each package
+ * as soon as it has been checked, so the next run skips the packages that
+ * are unchanged since and only checks the rest. Rules see every file of a
+ * package at once, so the package is the unit that is resumed: its entry is
+ * keyed by the path and content hash of each of its files, together with a
+ * fingerprint of the run, the rules and config it was checked with, so an
+ * entry is only reused for the exact same input.
+ *
+ * Entries are written like {@link DiskCache} entries, to a temporary file
+ * renamed into place, so a run killed mid-write leaves the entries it
+ * completed whole and at worst a stray temporary file. An entry is never
+ * rewritten, since changed input has a new key, so a run that checked every
+ * package calls {@link GoCheckpoint.prune} to drop the entries of input that
+ * no longer exists.
+ */
+
+/**
+ * The saved results of one package
+ */
+export interface GoCheckpointEntry {
+  findings: GoFinding[];
+  suppressed: GoSuppressedFinding[];
+}
+
+export interface GoCheckpointOptions {
+  /** Version of the tool; entries other versions wrote are not resumed */
+  version?: string;
+}
+
+// Distinguishes the temporary files of concurrent writes
+let writes = 0;
+
+/**
+ * Per-package results persisted under a directory as a run completes them
+ */
+export class GoCheckpoint {
+  private readonly directory: string;
+  private readonly version: string;
+  // Keys read or written by this run, which pruning keeps
+  private readonly used = new Set<string>();
+  private resumed = 0;
+  private saved = 0;
+
+  constructor(directory: string, options: GoCheckpointOptions = {}) {
+    this.directory = directory;
+    this.version = options.version ?? "";
+  }
+
+  /**
+   * The key of a package's results: its files' paths and content, and the
+   * fingerprint of the run checking them
+   */
+  key(files: GoFile[], run: string): string {
+    const hash = createHash("sha256")
+      .update(this.version)
+      .update("\0")
+      .update(run);
+    for (const file of [...files].sort((a, b) =>
+      a.filePath < b.filePath ? -1 : a.filePath > b.filePath ? 1 : 0,
+    )) {
+      hash
+        .update("\0")
+        .update(file.filePath)
+        .update("\0")
+        .update(contentHash(file.source));
+    }
+    return hash.digest("hex");
+  }
+
+  private entryPath(key: string): string {
+    return path.join(this.directory, `${key}.json`);
+  }
+
+  /**
+   * The results saved under a key, if a run saved them
+   */
+  async get(key: string): Promise<GoCheckpointEntry | undefined> {
+    let entry: GoCheckpointEntry & { key: string };
+    try {
+      entry = JSON.parse(
+        await fs.promises.readFile(this.entryPath(key), "utf-8"),
+      );
+    } catch {
+      // Missing or corrupt entries are checked again
+      return undefined;
+    }
+    if (entry.key !== key) return undefined;
+    this.used.add(key);
+    this.resumed++;
+    return { findings: entry.findings, suppressed: entry.suppressed };
+  }
+
+  /**
+   * Save the results of a package
+   */
+  async put(key: string, entry: GoCheckpointEntry): Promise<void> {
+    await fs.promises.mkdir(this.directory, { recursive: true });
+    const entryPath = this.entryPath(key);
+    const temporary = `${entryPath}.${process.pid}-${writes++}.tmp`;
+    try {
+      await fs.promises.writeFile(
+        temporary,
+        JSON.stringify({ key, ...entry }),
+        "utf-8",
+      );
+      await fs.promises.rename(temporary, entryPath);
+    } catch (error) {
+      await fs.promises.rm(temporary, { force: true });
+      throw error;
+    }
+    this.used.add(key);
+    this.saved++;
+  }
+
+  /**
+   * Remove the entries this run neither resumed nor saved; returns how many
+   * were removed
+   */
+  async prune(): Promise<number> {
+    let names: string[];
+    try {
+      names = await fs.promises.readdir(this.directory);
+    } catch {
+      return 0;
+    }
+    const stale = names.filter(
+      (name) => name.endsWith(".json") && !this.used.has(name.slice(0, -5)),
+    );
+    await Promise.all(
+      stale.map((name) =>
+        fs.promises.rm(path.join(this.directory, name), { force: true }),
+      ),
+    );
+    return stale.length;
+  }
+
+  /**
+   * Remove every entry, so the next run checks everything
+   */
+  async clear(): Promise<void> {
+    await fs.promises.rm(this.directory, { recursive: true, force: true });
+    this.used.clear();
+  }
+
+  /**
+   * Packages resumed from and saved to the checkpoint by this run
+   */
+  getStats(): { resumed: number; saved: number } {
+    return { resumed: this.resumed, saved: this.saved };
+  }
+}
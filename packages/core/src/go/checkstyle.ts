@@ -0,0 +1,135 @@
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GoFinding, GoSeverity, sortFindings } from "./findings.js";
+import { goLspPosition } from "./lsp.js";
+
+/**
+ * Go Findings as CheckStyle XML
+ * =============================
+ * Writes findings in the XML format CheckStyle reports in, which Jenkins'
+ * Warnings plugin and many older CI servers read: one `<file>` element per
+ * analyzed file, in path order, holding an `<error>` per finding with its
+ * line, column, severity, message and rule ID as `source`. Files without
+ * findings are listed too, so a report that grows clean shows every file as
+ * checked. Severities map high to `error`, medium to `warning` and low to
+ * `info`.
+ *
+ * Paths are relative to the root with `/` separators, which CI servers
+ * resolve against the workspace. Columns count UTF-16 code units, as the
+ * Java tools reading the format do, rather than the bytes findings carry.
+ * Text is escaped for attribute values, newlines in messages included, and
+ * characters XML 1.0 cannot represent at all, such as NUL, are replaced with
+ * U+FFFD so the document stays well-formed.
+ */
+
+/** CheckStyle version the report claims, which readers only display */
+export const GO_CHECKSTYLE_VERSION = "8.0";
+
+export type GoCheckstyleSeverity = "error" | "warning" | "info";
+
+export interface GoCheckstyleOptions {
+  /** Directory file names are relative to (default: the cwd) */
+  root?: string;
+}
+
+const SEVERITIES: Record<GoSeverity, GoCheckstyleSeverity> = {
+  high: "error",
+  medium: "warning",
+  low: "info",
+};
+
+// Characters outside XML 1.0's Char production: controls other than tab,
+// newline and carriage return, lone surrogates, U+FFFE and U+FFFF
+const INVALID = new RegExp(
+  [
+    "[\\u0000-\\u0008\\u000B\\u000C\\u000E-\\u001F\\uFFFE\\uFFFF]",
+    "[\\uD800-\\uDBFF](?![\\uDC00-\\uDFFF])",
+    "(?<![\\uD800-\\uDBFF])[\\uDC00-\\uDFFF]",
+  ].join("|"),
+  "g",
+);
+
+const ESCAPES: Record<string, string> = {
+  "&": "&amp;",
+  "<": "&lt;",
+  ">": "&gt;",
+  '"': "&quot;",
+  "'": "&apos;",
+  // Attribute values normalize raw whitespace to spaces
+  "\t": "&#9;",
+  "\n": "&#10;",
+  "\r": "&#13;",
+};
+
+/**
+ * Text escaped for a double-quoted XML attribute value
+ */
+export function escapeXmlAttribute(text: string): string {
+  return text
+    .replace(INVALID, "\uFFFD")
+    .replace(/[&<>"'\t\n\r]/g, (char) => ESCAPES[char]);
+}
+
+function attributes(values: Record<string, string | number>): string {
+  return Object.entries(values)
+    .map(([name, value]) => ` ${name}="${escapeXmlAttribute(String(value))}"`)
+    .join("");
+}
+
+/**
+ * Findings as a CheckStyle XML document. `files` are listed as checked and
+ * provide the lines columns are converted on.
+ */
+export function goCheckstyleReport(
+  findings: GoFinding[],
+  files: GoFile[],
+  options: GoCheckstyleOptions = {},
+): string {
+  const root = options.root ?? process.cwd();
+  const byPath = new Map(files.map((file) => [file.filePath, file]));
+  const byFile = new Map<string, GoFinding[]>(
+    files.map((file) => [file.filePath, []]),
+  );
+  for (const finding of sortFindings(findings)) {
+    if (!byFile.has(finding.filePath)) byFile.set(finding.filePath, []);
+    byFile.get(finding.filePath).push(finding);
+  }
+  const name = (filePath: string) =>
+    path.relative(root, filePath).split(path.sep).join("/");
+  const paths = [...byFile.keys()].sort((a, b) => {
+    const [x, y] = [name(a), name(b)];
+    return x < y ? -1 : x > y ? 1 : 0;
+  });
+
+  const lines = [
+    '<?xml version="1.0" encoding="UTF-8"?>',
+    `<checkstyle${attributes({ version: GO_CHECKSTYLE_VERSION })}>`,
+  ];
+  for (const filePath of paths) {
+    const reported = byFile.get(filePath);
+    const open = `  <file${attributes({ name: name(filePath) })}`;
+    if (reported.length === 0) {
+      lines.push(`${open}/>`);
+      continue;
+    }
+    lines.push(`${open}>`);
+    const file = byPath.get(filePath);
+    for (const finding of reported) {
+      // Without the source, byte columns of ASCII lines are the best guess
+      const column = file
+        ? goLspPosition(file, finding.line, finding.column).character + 1
+        : finding.column;
+      const error = attributes({
+        line: finding.line,
+        column,
+        severity: SEVERITIES[finding.severity],
+        message: finding.message,
+        source: finding.rule,
+      });
+      lines.push(`    <error${error}/>`);
+    }
+    lines.push("  </file>");
+  }
+  lines.push("</checkstyle>");
+  return lines.join("\n") + "\n";
+}
@@ -0,0 +1,483 @@
+import * as path from "path";
+import {
+  CallExpr,
+  Expr,
+  FuncDecl,
+  GoFile,
+  IfStmt,
+  Node,
+  Stmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importName, importPath } from "./imports.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Unreleased Resources
+ * ====================
+ * Flags resources acquired in a function and not released on every path out
+ * of it: files, connections and listeners left open, tickers and timers
+ * never stopped, mutexes left locked. A resource is the result of a call in
+ * {@link DEFAULT_RESOURCE_ACQUISITIONS}, of a package function returning a
+ * type of the package with a `Close` or `Stop` method, or a mutex after
+ * `Lock`/`RLock`. It is released by a `defer` of its release method, in the
+ * function that acquired it, or by a plain call that no `return`, `break`
+ * or `continue` after the acquisition skips. Returns in the error check
+ * right after the acquisition do not count, since nothing was acquired.
+ *
+ * Loops need care: a deferred release inside a loop runs only when the
+ * function returns, so every iteration's resource stays open until then,
+ * and a mutex locked twice deadlocks. Such defers are reported too, and
+ * resources acquired in a loop are never told to `defer`, since the fix is
+ * to release them each iteration or move the loop body into a function.
+ *
+ * A resource that is returned, stored in a struct, map, slice or another
+ * variable, sent on a channel or released in a closure is handed over to
+ * whoever receives it and not reported.
+ */
+
+/**
+ * Calls returning a resource, as `path.Func`, with the method releasing it;
+ * `Body.Close` releases a field of the result
+ */
+export const DEFAULT_RESOURCE_ACQUISITIONS: Record<string, string> = {
+  "compress/gzip.NewReader": "Close",
+  "database/sql.Open": "Close",
+  "net.Dial": "Close",
+  "net.DialTimeout": "Close",
+  "net.Listen": "Close",
+  "net/http.Get": "Body.Close",
+  "net/http.Head": "Body.Close",
+  "net/http.Post": "Body.Close",
+  "net/http.PostForm": "Body.Close",
+  "os.Create": "Close",
+  "os.CreateTemp": "Close",
+  "os.Open": "Close",
+  "os.OpenFile": "Close",
+  "time.NewTicker": "Stop",
+  "time.NewTimer": "Stop",
+};
+
+/** Methods that release a value of a type declared in the package */
+const RELEASE_METHODS = ["Close", "Stop"];
+
+const LOCKS = new Map([
+  ["Lock", "Unlock"],
+  ["RLock", "RUnlock"],
+]);
+
+export type GoUnreleasedReason =
+  /** No release on any path */
+  | "never-released"
+  /** A release that a return, break or continue can skip */
+  | "early-exit"
+  /** A deferred release in the loop acquiring the resource */
+  | "deferred-in-loop"
+  /** The resource is assigned to `_` or not assigned at all */
+  | "discarded";
+
+export interface GoUnreleasedResourceFinding extends GoFinding {
+  rule: "unreleased-resource";
+  /** The resource, e.g. `f`, `resp.Body` or `mu` */
+  resource: string;
+  /** The release call, e.g. `f.Close()` */
+  release: string;
+  reason: GoUnreleasedReason;
+  /** Line of the statement skipping the release, for early exits */
+  exitLine?: number;
+}
+
+interface Acquisition {
+  stmt: Stmt;
+  call: CallExpr;
+  /** What acquired it, e.g. `os.Open` or `mu.Lock()` */
+  source: string;
+  /** The variable holding the resource; undefined for locks and discards */
+  variable?: GoVariable;
+  resource: string;
+  /** Release method, relative to the resource */
+  method: string;
+}
+
+const isLoop = (node: Node) =>
+  node.kind === "ForStmt" || node.kind === "RangeStmt";
+
+const isFunction = (node: Node) =>
+  node.kind === "FuncDecl" || node.kind === "FuncLit";
+
+/**
+ * Package functions returning a type of the package with a release method,
+ * by name, with the method
+ */
+function packageAcquisitions(files: GoFile[]): Map<string, string> {
+  const releases = new Map<string, string>();
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl" || !decl.recv) continue;
+      if (!RELEASE_METHODS.includes(decl.name.name)) continue;
+      if (decl.type.params.list.length > 0) continue;
+      releases.set(baseTypeName(decl.recv.list[0].type).name, decl.name.name);
+    }
+  }
+  const acquisitions = new Map<string, string>();
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl" || decl.recv) continue;
+      const result = decl.type.results?.list[0];
+      const method = result && releases.get(baseTypeName(result.type).name);
+      if (method) acquisitions.set(decl.name.name, method);
+    }
+  }
+  return acquisitions;
+}
+
+class CleanupAnalyzer {
+  private readonly file: GoFile;
+  private readonly acquisitions: Map<string, string>;
+  private readonly imports = new Map<string, string>();
+  private readonly parents = new Map<Node, Node>();
+  private scopes: GoFunctionScopes;
+  readonly findings: GoUnreleasedResourceFinding[] = [];
+
+  constructor(file: GoFile, acquisitions: Map<string, string>) {
+    this.file = file;
+    this.acquisitions = acquisitions;
+    for (const spec of file.imports) {
+      this.imports.set(importName(spec), importPath(spec));
+    }
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private line(node: Node): number {
+    return this.file.sourceMap.line(node.pos);
+  }
+
+  private ancestors(node: Node): Node[] {
+    const list: Node[] = [];
+    for (let at = this.parents.get(node); at; at = this.parents.get(at)) {
+      list.push(at);
+    }
+    return list;
+  }
+
+  // The innermost enclosing function, and the loops between it and the node
+  private context(node: Node): { fn: Node; loops: Node[] } {
+    const loops: Node[] = [];
+    for (const ancestor of this.ancestors(node)) {
+      if (isFunction(ancestor)) return { fn: ancestor, loops };
+      if (isLoop(ancestor)) loops.push(ancestor);
+    }
+    return { fn: undefined, loops };
+  }
+
+  // The release method of a call's result, when it acquires a resource
+  private releaseOf(call: CallExpr): string | undefined {
+    const { fun } = call;
+    if (fun.kind === "Ident" && !this.scopes.resolved.has(fun)) {
+      return this.acquisitions.get(fun.name);
+    }
+    if (
+      fun.kind === "SelectorExpr" &&
+      fun.x.kind === "Ident" &&
+      !this.scopes.resolved.has(fun.x) &&
+      this.imports.has(fun.x.name)
+    ) {
+      const callee = `${this.imports.get(fun.x.name)}.${fun.sel.name}`;
+      return DEFAULT_RESOURCE_ACQUISITIONS[callee];
+    }
+    return undefined;
+  }
+
+  private acquisition(stmt: Stmt): Acquisition | undefined {
+    if (stmt.kind === "ExprStmt" && stmt.x.kind === "CallExpr") {
+      const call = stmt.x;
+      const { fun } = call;
+      if (fun.kind === "SelectorExpr" && LOCKS.has(fun.sel.name)) {
+        if (call.args.length > 0) return undefined;
+        const resource = this.text(fun.x);
+        const source = `${this.text(fun)}()`;
+        const method = LOCKS.get(fun.sel.name);
+        return { stmt, call, source, resource, method };
+      }
+      const method = this.releaseOf(call);
+      if (!method) return undefined;
+      return { stmt, call, source: this.text(fun), resource: "", method };
+    }
+    if (stmt.kind !== "AssignStmt" || stmt.rhs.length !== 1) return undefined;
+    const [call, target] = [stmt.rhs[0], stmt.lhs[0]];
+    if (call.kind !== "CallExpr" || target.kind !== "Ident") return undefined;
+    const method = this.releaseOf(call);
+    if (!method || (stmt.tok !== ":=" && stmt.tok !== "=")) return undefined;
+    const source = this.text(call.fun);
+    if (target.name === "_") {
+      return { stmt, call, source, resource: "", method };
+    }
+    const variable = this.scopes.resolved.get(target);
+    if (!variable) return undefined;
+    // For `resp.Body.Close`, the resource is `resp.Body`
+    const parts = method.split(".");
+    const release = parts.pop();
+    const resource = [target.name, ...parts].join(".");
+    return { stmt, call, source, variable, resource, method: release };
+  }
+
+  // Whether an identifier hands the resource over to someone else
+  private escapes(ident: Node): boolean {
+    let child = ident;
+    for (const parent of this.ancestors(ident)) {
+      switch (parent.kind) {
+        case "ReturnStmt":
+        case "CompositeLit":
+        case "KeyValueExpr":
+          return true;
+        case "SendStmt":
+          return parent.value === child;
+        case "AssignStmt":
+          // Wrapping it, as in `bufio.NewReader(f)`, keeps it in place
+          return (
+            child === ident &&
+            parent.rhs.includes(ident as Expr) &&
+            parent.lhs.some((lhs) => this.text(lhs) !== "_")
+          );
+        case "SelectorExpr":
+          // A method call or field read uses the resource in place
+          if (parent.x === child) return false;
+          break;
+        case "CallExpr":
+          if (this.text(parent.fun) === "append") return true;
+          break;
+        case "FuncLit":
+          return false;
+        default:
+          if (parent.kind.endsWith("Stmt")) return false;
+      }
+      child = parent;
+    }
+    return false;
+  }
+
+  private releases(body: Node, acquired: Acquisition): CallExpr[] {
+    const calls: CallExpr[] = [];
+    inspect(body, (node) => {
+      if (node.kind !== "CallExpr" || node === acquired.call) return;
+      const { fun } = node;
+      if (fun.kind !== "SelectorExpr" || fun.sel.name !== acquired.method) {
+        return;
+      }
+      if (this.text(fun.x) !== acquired.resource) return;
+      let root = fun.x;
+      while (root.kind === "SelectorExpr") root = root.x;
+      if (
+        acquired.variable &&
+        (root.kind !== "Ident" ||
+          this.scopes.resolved.get(root) !== acquired.variable)
+      ) {
+        return;
+      }
+      if (node.pos > acquired.stmt.pos) calls.push(node);
+    });
+    return calls;
+  }
+
+  // Whether a release is deferred, directly or in a deferred closure
+  private isDeferred(call: CallExpr): boolean {
+    if (this.parents.get(call)?.kind === "DeferStmt") return true;
+    const fn = this.context(call).fn;
+    const parent = fn && this.parents.get(fn);
+    return (
+      fn?.kind === "FuncLit" &&
+      parent?.kind === "CallExpr" &&
+      parent.fun === fn &&
+      this.parents.get(parent)?.kind === "DeferStmt"
+    );
+  }
+
+  // The `if` checking the acquisition's error, whose exits do not leak
+  private errorCheck(acquired: Acquisition): IfStmt | undefined {
+    const parent = this.parents.get(acquired.stmt);
+    if (parent?.kind === "IfStmt" && parent.init === acquired.stmt) {
+      return parent;
+    }
+    const list =
+      parent?.kind === "BlockStmt"
+        ? parent.list
+        : parent?.kind === "CaseClause" || parent?.kind === "CommClause"
+          ? parent.body
+          : [];
+    const next = list[list.indexOf(acquired.stmt) + 1];
+    return next?.kind === "IfStmt" ? next : undefined;
+  }
+
+  // Statements leaving the function or the acquiring loop iteration between
+  // the acquisition and a release
+  private exits(acquired: Acquisition, release: CallExpr): Node[] {
+    const { fn, loops } = this.context(acquired.stmt);
+    const check = this.errorCheck(acquired);
+    const exits: Node[] = [];
+    inspect(fn, (node) => {
+      if (node.pos >= release.pos || node.end <= acquired.stmt.end) {
+        return false;
+      }
+      if (check && node === check.body) return false;
+      if (node !== fn && isFunction(node)) return false;
+      if (node.kind === "ReturnStmt") exits.push(node);
+      if (node.kind === "BranchStmt" && loops.length > 0 && !node.label) {
+        const target = this.ancestors(node).find(
+          (ancestor) =>
+            isLoop(ancestor) ||
+            (node.tok === "break" &&
+              /^(Switch|TypeSwitch|Select)Stmt$/.test(ancestor.kind)),
+        );
+        if (target === loops[0]) exits.push(node);
+      }
+    });
+    return exits;
+  }
+
+  private report(
+    acquired: Acquisition,
+    reason: GoUnreleasedReason,
+    message: string,
+    exitLine?: number,
+  ): void {
+    const release = `${acquired.resource}.${acquired.method}()`;
+    const { loops } = this.context(acquired.stmt);
+    const fixable = reason !== "deferred-in-loop" && loops.length === 0;
+    this.findings.push({
+      rule: "unreleased-resource",
+      severity: "medium",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(acquired.call.pos),
+      message,
+      ...(fixable && reason !== "discarded" && { fix: `defer ${release}` }),
+      resource: acquired.resource,
+      release,
+      reason,
+      ...(exitLine !== undefined && { exitLine }),
+    });
+  }
+
+  private check(acquired: Acquisition): void {
+    const { fn, loops } = this.context(acquired.stmt);
+    const what = acquired.variable
+      ? `${acquired.resource} from ${acquired.source}`
+      : acquired.resource;
+    const release = `${acquired.resource}.${acquired.method}()`;
+    if (!acquired.resource) {
+      this.report(
+        acquired,
+        "discarded",
+        `The result of ${acquired.source} is discarded, so it can never be released with ${acquired.method}()`,
+      );
+      return;
+    }
+    if (acquired.variable) {
+      const handedOver = this.scopes.references.some(
+        (reference) =>
+          reference.variable === acquired.variable &&
+          reference.ident !== acquired.variable.ident &&
+          this.escapes(reference.ident),
+      );
+      if (handedOver) return;
+    }
+    const releases = this.releases(fn, acquired);
+    const deferred = releases.find((call) => this.isDeferred(call));
+    if (deferred) {
+      const inLoop = loops.some((loop) =>
+        this.ancestors(deferred).includes(loop),
+      );
+      if (inLoop) {
+        this.report(
+          acquired,
+          "deferred-in-loop",
+          `defer ${release} runs when the function returns, not at the end of the loop iteration acquiring ${what}; release it in the loop or move the loop body into a function`,
+        );
+      }
+      return;
+    }
+    // A closure releasing it, as in `t.Cleanup(func() { f.Close() })`,
+    // runs at a time the function does not decide
+    if (releases.some((call) => this.context(call).fn !== fn)) return;
+    const [plain] = releases;
+    const inLoop = loops.length > 0;
+    if (!plain) {
+      this.report(
+        acquired,
+        "never-released",
+        inLoop
+          ? `${what} is never released with ${release}; release it before the next loop iteration`
+          : `${what} is never released; defer ${release} after acquiring it`,
+      );
+      return;
+    }
+    const [exit] = this.exits(acquired, plain);
+    if (!exit) return;
+    const statement = exit.kind === "BranchStmt" ? exit.tok : "return";
+    this.report(
+      acquired,
+      "early-exit",
+      inLoop
+        ? `The ${statement} on line ${this.line(exit)} skips ${release} on line ${this.line(plain)}, leaking ${what}; release it before leaving the iteration`
+        : `The ${statement} on line ${this.line(exit)} skips ${release} on line ${this.line(plain)}, leaking ${what}; defer ${release} instead`,
+      this.line(exit),
+    );
+  }
+
+  analyzeFunction(decl: FuncDecl): void {
+    this.scopes = resolveFunctionScopes(decl);
+    this.parents.clear();
+    inspect(decl, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+    });
+    inspect(decl.body, (node) => {
+      const list =
+        node.kind === "BlockStmt"
+          ? node.list
+          : node.kind === "CaseClause" || node.kind === "CommClause"
+            ? node.body
+            : undefined;
+      if (list) {
+        for (const stmt of list) {
+          const acquired = this.acquisition(stmt);
+          if (acquired) this.check(acquired);
+        }
+      }
+      if (node.kind === "IfStmt" && node.init) {
+        const acquired = this.acquisition(node.init);
+        if (acquired) this.check(acquired);
+      }
+    });
+  }
+}
+
+/**
+ * Find resources acquired in a function and not released on every path
+ */
+export function findUnreleasedResources(
+  files: GoFile[],
+): GoUnreleasedResourceFinding[] {
+  const findings: GoUnreleasedResourceFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const acquisitions = packageAcquisitions(group);
+    for (const file of group) {
+      const analyzer = new CleanupAnalyzer(file, acquisitions);
+      for (const decl of file.decls) {
+        if (decl.kind === "FuncDecl" && decl.body) {
+          analyzer.analyzeFunction(decl);
+        }
+      }
+      findings.push(...analyzer.findings);
+    }
+  }
+  return sortFindings(findings);
+}
@@ -0,0 +1,591 @@
+import { TextEdit } from "../diff.js";
+import {
+  CallExpr,
+  Expr,
+  FuncDecl,
+  GenDecl,
+  GoFile,
+  ImportSpec,
+  Node,
+  SelectorExpr,
+  StructType,
+  TypeSpec,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importEdits, importName, importPath } from "./imports.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+import { isGoTestFile } from "./test-links.js";
+
+/**
+ * Direct Clock Reads
+ * ==================
+ * Code that calls `time.Now` itself gets whatever time it is when the test
+ * runs, so timeouts, expiries and timestamps cannot be tested without
+ * sleeping or loose assertions. This pass flags the functions and methods
+ * outside test files that call `time.Now`, `time.Since` or `time.Until`,
+ * once per function at its first call, counting calls in the function
+ * literals it contains. It suggests injecting the clock instead: a
+ * `now func() time.Time` field on the receiver's struct for methods, or a
+ * parameter for functions, which tests set to a fixed time. Functions that
+ * only adapt the clock, returning a `time.Time` with `return time.Now()` as
+ * their last statement, are the injection point already and are not flagged.
+ *
+ * {@link injectClock} makes the change for a struct: it adds the field, a
+ * method returning the field's time or `time.Now()` when the field is unset,
+ * so the zero value and existing constructors keep working, and routes the
+ * clock calls of every method of the type through that method.
+ */
+
+export interface GoDirectClockFinding extends GoFinding {
+  rule: "direct-clock";
+  /** Name of the function, `Type.Method` for methods */
+  function: string;
+  /** The receiver's type, for methods */
+  type?: string;
+  /** Clock calls in the function */
+  calls: number;
+}
+
+export interface InjectClockOptions {
+  /** The struct type whose methods should read an injected clock */
+  type: string;
+  /** Name of the clock field (default: `now`) */
+  field?: string;
+  /** Name of the method reading the clock (default: `clock`) */
+  method?: string;
+}
+
+export interface InjectClockResult {
+  type: string;
+  field: string;
+  method: string;
+  /** Changed files */
+  files: GoRefactorResult[];
+  /** Methods whose clock calls were rewritten */
+  methods: string[];
+}
+
+const CLOCK_FUNCTIONS = new Set(["Now", "Since", "Until"]);
+
+// Operands `.Sub` can follow without parentheses
+const PRIMARY = new Set([
+  "Ident",
+  "SelectorExpr",
+  "CallExpr",
+  "IndexExpr",
+  "ParenExpr",
+  "SliceExpr",
+  "TypeAssertExpr",
+]);
+
+function functionName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+function list(names: string[]): string {
+  return names.length === 1
+    ? names[0]
+    : `${names.slice(0, -1).join(", ")} and ${names.at(-1)}`;
+}
+
+// The import of the time package, when it binds a name
+function timeImport(file: GoFile): ImportSpec | undefined {
+  const spec = file.imports.find(
+    (candidate) => importPath(candidate) === "time",
+  );
+  const name = spec && importName(spec);
+  return name === "." || name === "_" ? undefined : spec;
+}
+
+// Calls of the clock functions in a function, in source order
+function clockCalls(decl: FuncDecl, name: string): CallExpr[] {
+  if (!decl.body) return [];
+  const { resolved } = resolveFunctionScopes(decl);
+  const calls: CallExpr[] = [];
+  inspect(decl.body, (node) => {
+    if (
+      node.kind === "CallExpr" &&
+      node.fun.kind === "SelectorExpr" &&
+      node.fun.x.kind === "Ident" &&
+      node.fun.x.name === name &&
+      !resolved.has(node.fun.x) &&
+      CLOCK_FUNCTIONS.has(node.fun.sel.name) &&
+      node.args.length === (node.fun.sel.name === "Now" ? 0 : 1)
+    ) {
+      calls.push(node);
+    }
+  });
+  return calls;
+}
+
+function isTimeType(type: Expr, name: string): boolean {
+  return (
+    type.kind === "SelectorExpr" &&
+    type.x.kind === "Ident" &&
+    type.x.name === name &&
+    type.sel.name === "Time"
+  );
+}
+
+// A function returning only a time.Time, ending in `return time.Now()`
+function isClockAdapter(decl: FuncDecl, name: string): boolean {
+  const results = decl.type.results?.list ?? [];
+  const last = decl.body?.list.at(-1);
+  if (
+    results.length !== 1 ||
+    results[0].names.length > 1 ||
+    !isTimeType(results[0].type, name) ||
+    last?.kind !== "ReturnStmt" ||
+    last.results.length !== 1
+  ) {
+    return false;
+  }
+  const [result] = last.results;
+  return (
+    result.kind === "CallExpr" &&
+    result.args.length === 0 &&
+    result.fun.kind === "SelectorExpr" &&
+    result.fun.x.kind === "Ident" &&
+    result.fun.x.name === name &&
+    result.fun.sel.name === "Now"
+  );
+}
+
+/**
+ * Find functions and methods that read the wall clock directly
+ */
+export function findDirectClockCalls(
+  files: GoFile[],
+): GoDirectClockFinding[] {
+  const findings: GoDirectClockFinding[] = [];
+  for (const file of files) {
+    if (isGoTestFile(file.filePath)) continue;
+    const spec = timeImport(file);
+    if (!spec) continue;
+    const name = importName(spec);
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl" || isClockAdapter(decl, name)) continue;
+      const calls = clockCalls(decl, name);
+      if (calls.length === 0) continue;
+      const used = [
+        ...new Set(calls.map((call) => (call.fun as SelectorExpr).sel.name)),
+      ].map((called) => `${name}.${called}`);
+      const recv = decl.recv?.list[0];
+      const type = recv && baseTypeName(recv.type).name;
+      const fn = functionName(decl);
+      const count = calls.length === 1 ? "" : ` (${calls.length} calls)`;
+      findings.push({
+        rule: "direct-clock",
+        severity: "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(calls[0].pos),
+        message: type
+          ? `${fn} reads the clock with ${list(used)}${count}, which tests cannot control; give ${type} a now func() ${name}.Time field to call instead`
+          : `${fn} reads the clock with ${list(used)}${count}, which tests cannot control; take a now func() ${name}.Time parameter to call instead`,
+        function: fn,
+        ...(type && { type }),
+        calls: calls.length,
+      });
+    }
+  }
+  return sortFindings(findings);
+}
+
+interface Method {
+  file: GoFile;
+  decl: FuncDecl;
+}
+
+// The struct type declared under a name, and where
+function findStruct(
+  group: GoFile[],
+  type: string,
+): { file: GoFile; decl: GenDecl; spec: TypeSpec } | undefined {
+  for (const file of group) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+      for (const spec of decl.specs) {
+        if (
+          spec.kind === "TypeSpec" &&
+          spec.name.name === type &&
+          spec.type.kind === "StructType"
+        ) {
+          return { file, decl, spec };
+        }
+      }
+    }
+  }
+  return undefined;
+}
+
+// The receiver name most methods use, the earliest on a tie
+function mostCommon(methods: Method[]): string | undefined {
+  const counts = new Map<string, number>();
+  for (const { decl } of methods) {
+    const name = decl.recv.list[0].names[0]?.name;
+    if (name && name !== "_") counts.set(name, (counts.get(name) ?? 0) + 1);
+  }
+  let best: string | undefined;
+  for (const [name, count] of counts) {
+    if (best === undefined || count > counts.get(best)) best = name;
+  }
+  return best;
+}
+
+function indentOf(file: GoFile, offset: number): string {
+  const { source } = file;
+  const lineStart = source.lastIndexOf("\n", offset - 1) + 1;
+  return /^[ \t]*/.exec(source.slice(lineStart))[0];
+}
+
+// Edits adding a field before the closing brace of a struct, aligned with
+// the fields gofmt aligns it with
+function fieldEdits(
+  file: GoFile,
+  struct: StructType,
+  type: string,
+  field: string,
+  fieldType: string,
+): TextEdit[] {
+  const { source, sourceMap } = file;
+  const { fields } = struct;
+  const close = fields.end - 1;
+  const line = (offset: number) => sourceMap.line(offset);
+  if (line(fields.opening) === line(close)) {
+    if (fields.list.length > 0) {
+      throw new GoRefactorError(
+        `${type} is declared on one line; put its fields on lines of their own first`,
+      );
+    }
+    const indent = indentOf(file, struct.pos);
+    return [
+      {
+        start: struct.pos,
+        end: fields.end,
+        newText: `struct {\n${indent}\t${field} ${fieldType}\n${indent}}`,
+      },
+    ];
+  }
+
+  // The fields of the last section: consecutive lines, each a single-line
+  // field with names, broken by blank lines and comment lines
+  const run: { start: number; end: number; type: number }[] = [];
+  let next = line(close);
+  for (const candidate of [...fields.list].reverse()) {
+    if (
+      candidate.names.length === 0 ||
+      line(candidate.pos) !== line(candidate.type.end) ||
+      line(candidate.pos) !== next - 1
+    ) {
+      break;
+    }
+    run.unshift({
+      start: candidate.names[0].pos,
+      end: candidate.names.at(-1).end,
+      type: candidate.type.pos,
+    });
+    if (candidate.doc) break;
+    next = line(candidate.pos);
+  }
+  const width = Math.max(
+    field.length,
+    ...run.map((cell) => cell.end - cell.start),
+  );
+  const edits: TextEdit[] = run
+    .filter((cell) => cell.type - cell.start !== width + 1)
+    .map((cell) => ({
+      start: cell.end,
+      end: cell.type,
+      newText: " ".repeat(width + 1 - (cell.end - cell.start)),
+    }));
+  const at = sourceMap.lineStart(line(close));
+  const indent = `${indentOf(file, close)}\t`;
+  const padding = " ".repeat(run.length > 0 ? width + 1 - field.length : 1);
+  edits.push({
+    start: at,
+    end: at,
+    newText: `${indent}${field}${padding}${fieldType}\n`,
+  });
+  // The brace may follow the last field on its line
+  if (source.slice(at, close).trim() !== "") {
+    edits[edits.length - 1] = {
+      start: close,
+      end: close,
+      newText: `\n${indent}${field} ${fieldType}\n${indentOf(file, close)}`,
+    };
+  }
+  return edits;
+}
+
+// Edits removing an import spec, or its declaration when it is the only one
+function removeImport(file: GoFile, spec: ImportSpec): TextEdit[] {
+  const { source, sourceMap } = file;
+  const decl = file.decls.find(
+    (candidate): candidate is GenDecl =>
+      candidate.kind === "GenDecl" && candidate.specs.includes(spec),
+  );
+  const lone = decl.specs.length === 1;
+  const [from, to] = lone
+    ? [decl.doc?.pos ?? decl.pos, decl.end]
+    : [spec.doc?.pos ?? spec.pos, spec.comment?.end ?? spec.end];
+  const start = sourceMap.lineStart(sourceMap.line(from));
+  let end = sourceMap.lineStart(sourceMap.line(to) + 1);
+  // A lone declaration takes the blank line after it along
+  if (lone && source[end] === "\n") end++;
+  return [{ start, end, newText: "" }];
+}
+
+// Uses of an import outside the given selectors
+function otherUses(file: GoFile, name: string, except: Set<Node>): number {
+  let uses = 0;
+  for (const decl of file.decls) {
+    if (decl.kind === "GenDecl" && decl.tok === "import") continue;
+    const resolved =
+      decl.kind === "FuncDecl"
+        ? resolveFunctionScopes(decl).resolved
+        : undefined;
+    inspect(decl, (node) => {
+      if (
+        node.kind === "SelectorExpr" &&
+        node.x.kind === "Ident" &&
+        node.x.name === name &&
+        !resolved?.has(node.x) &&
+        !except.has(node)
+      ) {
+        uses++;
+      }
+    });
+  }
+  return uses;
+}
+
+// Why the receiver cannot reach the clock from one of a method's calls
+function receiverConflict(
+  decl: FuncDecl,
+  receiver: string,
+  calls: CallExpr[],
+): string | undefined {
+  const { variables } = resolveFunctionScopes(decl);
+  for (const variable of variables) {
+    if (variable.kind === "receiver" || variable.name !== receiver) continue;
+    const { node } = variable.scope;
+    const hidden = calls.some(
+      (call) =>
+        node.pos <= call.pos &&
+        call.end <= node.end &&
+        variable.ident.pos < call.pos,
+    );
+    if (hidden) {
+      return `${functionName(decl)} declares ${receiver}, hiding the receiver where it reads the clock`;
+    }
+  }
+  return undefined;
+}
+
+// Edits routing clock calls through `clock`; calls nested in the arguments
+// of another are rewritten inside its replacement
+function callEdits(file: GoFile, calls: CallExpr[], clock: string): TextEdit[] {
+  const inside = (inner: Node, outer: Node) =>
+    inner !== outer && outer.pos <= inner.pos && inner.end <= outer.end;
+  const render = (start: number, end: number): string => {
+    const outermost = calls.filter(
+      (call) =>
+        start <= call.pos &&
+        call.end <= end &&
+        !calls.some(
+          (other) =>
+            inside(call, other) && start <= other.pos && other.end <= end,
+        ),
+    );
+    let text = "";
+    let at = start;
+    for (const call of outermost) {
+      text += file.source.slice(at, call.pos) + replace(call);
+      at = call.end;
+    }
+    return text + file.source.slice(at, end);
+  };
+  const replace = (call: CallExpr): string => {
+    const [arg] = call.args;
+    switch ((call.fun as SelectorExpr).sel.name) {
+      case "Since":
+        return `${clock}.Sub(${render(arg.pos, arg.end)})`;
+      case "Until": {
+        const operand = render(arg.pos, arg.end);
+        return PRIMARY.has(arg.kind)
+          ? `${operand}.Sub(${clock})`
+          : `(${operand}).Sub(${clock})`;
+      }
+      default:
+        return clock;
+    }
+  };
+  return calls
+    .filter((call) => !calls.some((other) => inside(call, other)))
+    .map((call) => ({
+      start: call.pos,
+      end: call.end,
+      newText: replace(call),
+    }));
+}
+
+/**
+ * Give a struct an injectable clock field and make its methods read the
+ * clock through it
+ */
+export function injectClock(
+  files: GoFile[],
+  options: InjectClockOptions,
+): InjectClockResult {
+  const field = options.field ?? "now";
+  const method = options.method ?? "clock";
+  for (const name of [field, method]) {
+    if (!/^[\p{L}_][\p{L}\p{Nd}_]*$/u.test(name) || name === "_") {
+      throw new GoRefactorError(`${name} is not a valid identifier`);
+    }
+  }
+  if (field === method) {
+    throw new GoRefactorError(
+      `The field and the method cannot both be named ${field}`,
+    );
+  }
+
+  let found: ReturnType<typeof findStruct>;
+  let group: GoFile[] = [];
+  for (const candidate of groupGoPackages(files)) {
+    const struct = findStruct(candidate, options.type);
+    if (struct) {
+      found = struct;
+      group = candidate;
+    }
+  }
+  if (!found) {
+    throw new GoRefactorError(
+      `${options.type} is not a struct type in the analyzed files`,
+    );
+  }
+  const struct = found.spec.type as StructType;
+  const methods: Method[] = [];
+  for (const file of group) {
+    for (const decl of file.decls) {
+      const recv = decl.kind === "FuncDecl" ? decl.recv?.list[0] : undefined;
+      if (recv && baseTypeName(recv.type).name === options.type) {
+        methods.push({ file, decl: decl as FuncDecl });
+      }
+    }
+  }
+  const members = [
+    ...struct.fields.list.flatMap((candidate) =>
+      candidate.names.map((ident) => ident.name),
+    ),
+    ...methods.map(({ decl }) => decl.name.name),
+  ];
+  for (const name of [field, method]) {
+    if (members.includes(name)) {
+      throw new GoRefactorError(
+        `${options.type} already has a member named ${name}`,
+      );
+    }
+  }
+
+  const receiver =
+    mostCommon(methods) ?? options.type.charAt(0).toLowerCase();
+  const edits = new Map<GoFile, TextEdit[]>();
+  const add = (file: GoFile, ...added: TextEdit[]) =>
+    edits.set(file, [...(edits.get(file) ?? []), ...added]);
+  const rewritten = new Map<GoFile, CallExpr[]>();
+  const changed: string[] = [];
+  for (const { file, decl } of methods) {
+    const spec = timeImport(file);
+    const calls = spec ? clockCalls(decl, importName(spec)) : [];
+    if (calls.length === 0) continue;
+    const recv = decl.recv.list[0];
+    const named = recv.names[0] && recv.names[0].name !== "_";
+    const name = named ? recv.names[0].name : receiver;
+    const conflict = receiverConflict(decl, name, calls);
+    if (conflict) {
+      throw new GoRefactorError(`Cannot inject the clock: ${conflict}`);
+    }
+    if (!named) {
+      const [blank] = recv.names;
+      add(
+        file,
+        blank
+          ? { start: blank.pos, end: blank.end, newText: name }
+          : { start: recv.type.pos, end: recv.type.pos, newText: `${name} ` },
+      );
+    }
+    add(file, ...callEdits(file, calls, `${name}.${method}()`));
+    rewritten.set(file, [...(rewritten.get(file) ?? []), ...calls]);
+    changed.push(decl.name.name);
+  }
+  if (changed.length === 0) {
+    throw new GoRefactorError(
+      `No method of ${options.type} calls time.Now, time.Since or time.Until`,
+    );
+  }
+
+  // The struct's file gains the field and the method, and needs time
+  const home = found.file;
+  const homeImport = timeImport(home);
+  const time = homeImport ? importName(homeImport) : "time";
+  if (!homeImport) add(home, ...importEdits(home, [{ path: "time" }]));
+  add(
+    home,
+    ...fieldEdits(home, struct, options.type, field, `func() ${time}.Time`),
+  );
+  // A pointer receiver if any method has one, written as the methods do
+  const typed =
+    methods.find(({ decl }) => decl.recv.list[0].type.kind === "StarExpr") ??
+    methods[0];
+  const { type: recvType } = typed.decl.recv.list[0];
+  const recvText = typed.file.source.slice(recvType.pos, recvType.end);
+  const after =
+    methods.filter(({ file }) => file === home).at(-1)?.decl ?? found.decl;
+  add(home, {
+    start: after.end,
+    end: after.end,
+    newText: [
+      "",
+      "",
+      `// ${method} calls ${field} when it is set, and ${time}.Now otherwise`,
+      `func (${receiver} ${recvText}) ${method}() ${time}.Time {`,
+      `\tif ${receiver}.${field} != nil {`,
+      `\t\treturn ${receiver}.${field}()`,
+      "\t}",
+      `\treturn ${time}.Now()`,
+      "}",
+    ].join("\n"),
+  });
+
+  // Other files drop the time import once nothing else uses it
+  for (const [file, calls] of rewritten) {
+    if (file === home) continue;
+    const spec = timeImport(file);
+    const funs = new Set<Node>(calls.map((call) => call.fun));
+    if (otherUses(file, importName(spec), funs) === 0) {
+      add(file, ...removeImport(file, spec));
+    }
+  }
+
+  return {
+    type: options.type,
+    field,
+    method,
+    files: group
+      .filter((file) => edits.has(file))
+      .map((file) => refactorResult(file, edits.get(file))),
+    methods: changed,
+  };
+}
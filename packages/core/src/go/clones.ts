@@ -0,0 +1,290 @@
+import { FuncDecl, GoFile, Node, Stmt, forEachChild } from "./ast.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Go Clone Detection
+ * ==================
+ * Finds structurally duplicated statements and statement runs, within and
+ * across functions. Every subtree is hash-consed twice: once exactly (type-1
+ * clones, which differ only in layout and comments) and once with identifier
+ * names and literal values erased (type-2 clones, which also differ in
+ * naming and constants). Only the largest duplicates are reported, not every
+ * smaller block nested inside them.
+ */
+
+export type GoCloneKind = "exact" | "renamed";
+
+export interface GoCloneOptions {
+  /** Smallest duplicate to report, in AST nodes (default: 20) */
+  minSize?: number;
+  /**
+   * `exact` reports only type-1 clones; `renamed` (the default) also reports
+   * type-2 clones whose identifiers and literals differ
+   */
+  sensitivity?: GoCloneKind;
+}
+
+export interface GoCloneLocation {
+  filePath: string;
+  /** Qualified name of the enclosing function, e.g. `DataProcessor.Load` */
+  function?: string;
+  startLine: number;
+  endLine: number;
+}
+
+export interface GoClone {
+  kind: GoCloneKind;
+  /** Size of each copy, in AST nodes */
+  size: number;
+  locations: GoCloneLocation[];
+  /** Identifiers and literals that differ between copies */
+  differences: number;
+  suggestion: string;
+}
+
+interface Candidate {
+  file: GoFile;
+  decl?: FuncDecl;
+  pos: number;
+  end: number;
+  size: number;
+  exact: string;
+  renamed: string;
+  leaves: string[];
+}
+
+interface Hashed {
+  exact: number;
+  renamed: number;
+  size: number;
+  leaves: string[];
+}
+
+/**
+ * Interns structural signatures as small integers, so a subtree's key is a
+ * short list of its children's ids rather than the whole subtree's text
+ */
+class Interner {
+  private readonly ids = new Map<string, number>();
+
+  id(signature: string): number {
+    let id = this.ids.get(signature);
+    if (id === undefined) {
+      id = this.ids.size;
+      this.ids.set(signature, id);
+    }
+    return id;
+  }
+}
+
+function isStatement(node: Node): node is Stmt {
+  return node.kind.endsWith("Stmt") && node.kind !== "BlockStmt";
+}
+
+function statementLists(node: Node): Stmt[][] {
+  switch (node.kind) {
+    case "BlockStmt":
+      return [node.list];
+    case "CaseClause":
+    case "CommClause":
+      return [node.body];
+    default:
+      return [];
+  }
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+class CloneDetector {
+  private readonly exact = new Interner();
+  private readonly renamed = new Interner();
+  private readonly hashes = new Map<Node, Hashed>();
+  readonly candidates: Candidate[] = [];
+
+  constructor(private readonly minSize: number) {}
+
+  private hash(node: Node): Hashed {
+    const cached = this.hashes.get(node);
+    if (cached) return cached;
+
+    const props: string[] = [];
+    for (const [key, value] of Object.entries(node)) {
+      if (key === "kind" || key === "name" || key === "value") continue;
+      if (typeof value === "string" || typeof value === "boolean") {
+        props.push(`${key}=${value}`);
+      }
+    }
+    const head = `${node.kind}(${props.join(",")})`;
+    const exact: number[] = [];
+    const renamed: number[] = [];
+    const leaves: string[] = [];
+    let size = 1;
+    const leaf =
+      node.kind === "Ident"
+        ? node.name
+        : node.kind === "BasicLit"
+          ? node.value
+          : undefined;
+    if (leaf !== undefined) leaves.push(leaf);
+    forEachChild(node, (child) => {
+      const hashed = this.hash(child);
+      exact.push(hashed.exact);
+      renamed.push(hashed.renamed);
+      leaves.push(...hashed.leaves);
+      size += hashed.size;
+    });
+    const hashed = {
+      exact: this.exact.id(
+        `${head}${leaf === undefined ? "" : JSON.stringify(leaf)}[${exact.join(",")}]`,
+      ),
+      renamed: this.renamed.id(`${head}[${renamed.join(",")}]`),
+      size,
+      leaves,
+    };
+    this.hashes.set(node, hashed);
+    return hashed;
+  }
+
+  private add(file: GoFile, decl: FuncDecl | undefined, nodes: Stmt[]) {
+    const hashed = nodes.map((node) => this.hash(node));
+    const size = hashed.reduce((total, entry) => total + entry.size, 0);
+    if (size < this.minSize) return;
+    this.candidates.push({
+      file,
+      decl,
+      pos: nodes[0].pos,
+      end: nodes.at(-1).end,
+      size,
+      exact: hashed.map((entry) => entry.exact).join(","),
+      renamed: hashed.map((entry) => entry.renamed).join(","),
+      leaves: hashed.flatMap((entry) => entry.leaves),
+    });
+  }
+
+  visit(file: GoFile, decl: FuncDecl | undefined, node: Node): void {
+    if (isStatement(node)) {
+      this.add(file, decl, [node]);
+    }
+    // Runs of two or more adjacent statements
+    for (const list of statementLists(node)) {
+      for (let start = 0; start < list.length; start++) {
+        for (let end = start + 2; end <= list.length; end++) {
+          this.add(file, decl, list.slice(start, end));
+        }
+      }
+    }
+    forEachChild(node, (child) => this.visit(file, decl, child));
+  }
+}
+
+function contains(outer: Candidate, inner: Candidate): boolean {
+  return (
+    outer.file === inner.file &&
+    outer.pos <= inner.pos &&
+    inner.end <= outer.end
+  );
+}
+
+function overlaps(a: Candidate, b: Candidate): boolean {
+  return a.file === b.file && a.pos < b.end && b.pos < a.end;
+}
+
+function location(candidate: Candidate): GoCloneLocation {
+  const { file, decl } = candidate;
+  return {
+    filePath: file.filePath,
+    function: decl ? qualifiedName(decl) : undefined,
+    startLine: file.sourceMap.line(candidate.pos),
+    endLine: file.sourceMap.line(candidate.end),
+  };
+}
+
+function suggestion(locations: GoCloneLocation[], differences: number) {
+  const names = [
+    ...new Set(locations.map((loc) => loc.function ?? loc.filePath)),
+  ];
+  const where =
+    names.length === 1
+      ? names[0]
+      : `${names.slice(0, -1).join(", ")} and ${names.at(-1)}`;
+  const params =
+    differences === 0
+      ? ""
+      : `, passing the ${differences} differing names and values as parameters`;
+  return `Extract a shared helper for the ${locations.length} copies in ${where}${params}`;
+}
+
+/**
+ * Find duplicated code across Go files
+ */
+export function findGoClones(
+  files: GoFile[],
+  options: GoCloneOptions = {},
+): GoClone[] {
+  const sensitivity = options.sensitivity ?? "renamed";
+  const detector = new CloneDetector(options.minSize ?? 20);
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl" && decl.body) {
+        detector.visit(file, decl, decl.body);
+      }
+    }
+  }
+
+  const groups = new Map<string, Candidate[]>();
+  for (const candidate of detector.candidates) {
+    const key = sensitivity === "exact" ? candidate.exact : candidate.renamed;
+    const group = groups.get(key);
+    if (group) {
+      group.push(candidate);
+    } else {
+      groups.set(key, [candidate]);
+    }
+  }
+
+  // Largest duplicates first, so their nested blocks can be skipped
+  const ordered = [...groups.values()]
+    .filter((group) => group.length > 1)
+    .sort((a, b) => b[0].size - a[0].size);
+
+  const reported: Candidate[] = [];
+  const clones: GoClone[] = [];
+  for (const group of ordered) {
+    // Copies of a repeated run may overlap each other; keep disjoint ones
+    const members: Candidate[] = [];
+    for (const candidate of group) {
+      if (!members.some((member) => overlaps(member, candidate))) {
+        members.push(candidate);
+      }
+    }
+    if (members.length < 2) continue;
+    if (
+      members.every((member) =>
+        reported.some((outer) => contains(outer, member)),
+      )
+    ) {
+      continue;
+    }
+    reported.push(...members);
+
+    const differences = members[0].leaves.filter((leaf, index) =>
+      members.some((member) => member.leaves[index] !== leaf),
+    ).length;
+    const locations = members.map(location);
+    clones.push({
+      kind: members.every((member) => member.exact === members[0].exact)
+        ? "exact"
+        : "renamed",
+      size: members[0].size,
+      locations,
+      differences,
+      suggestion: suggestion(locations, differences),
+    });
+  }
+  return clones;
+}
@@ -0,0 +1,292 @@
+import {
+  BlockStmt,
+  Expr,
+  FuncLit,
+  GoFile,
+  IfStmt,
+  Node,
+  Stmt,
+  forEachChild,
+} from "./ast.js";
+import { compareCodePoints } from "./findings.js";
+
+/**
+ * Default cyclomatic complexity above which a function is a refactor candidate
+ */
+export const DEFAULT_COMPLEXITY_THRESHOLD = 10;
+
+/**
+ * Default cognitive complexity above which a function is a refactor candidate
+ */
+export const DEFAULT_COGNITIVE_THRESHOLD = 15;
+
+/**
+ * How {@link cognitiveComplexity} counts, for reports to explain the metric
+ */
+export const COGNITIVE_COMPLEXITY_RULES =
+  "Cognitive complexity adds 1 for each `if`, `else if`, `else`, `switch`, " +
+  "`select`, loop, `goto`, labeled `break` or `continue` and sequence of " +
+  "like boolean operators, plus 1 per level of nesting for `if`, `switch`, " +
+  "`select` and loops inside other ones or inside function literals.";
+
+/**
+ * Complexity of a function literal, counted independently of its enclosing
+ * function
+ */
+export interface GoClosureComplexity {
+  startLine: number;
+  endLine: number;
+  complexity: number;
+  /** How the literal is used: deferred, launched as a goroutine, or a value */
+  usage: "defer" | "go" | "value";
+}
+
+/**
+ * A function whose cyclomatic or cognitive complexity exceeds its threshold
+ */
+export interface GoComplexityCandidate {
+  qualifiedName: string;
+  startLine: number;
+  complexity: number;
+  threshold: number;
+  cognitiveComplexity?: number;
+  cognitiveThreshold: number;
+  fanIn?: number;
+  fanOut?: number;
+}
+
+/**
+ * Ordering and call graph filters for refactor candidates
+ */
+export interface GoCandidateOptions {
+  /** Sort key, highest first (default: `complexity`) */
+  sortBy?: "complexity" | "cognitiveComplexity" | "fanIn" | "fanOut";
+  /**
+   * Cognitive complexity above which a function is a candidate whatever its
+   * cyclomatic complexity (default: {@link DEFAULT_COGNITIVE_THRESHOLD})
+   */
+  cognitiveThreshold?: number;
+  /** Keep only functions with at least this many distinct callers */
+  minFanIn?: number;
+  /** Keep only functions with at least this many distinct callees */
+  minFanOut?: number;
+}
+
+/**
+ * Count decision points in a function body. The count starts at 1 and adds one
+ * for each `if`, `for`/`range`, non-default `case`, `&&`, `||`, and each
+ * `return` nested inside a branch (an early exit). Function literals are not
+ * descended into; they are measured separately.
+ */
+export function cyclomaticComplexity(body: BlockStmt | undefined): number {
+  if (!body) {
+    return 1;
+  }
+  let complexity = 1;
+
+  const visit = (node: Node, inBranch: boolean) => {
+    switch (node.kind) {
+      case "FuncLit":
+        return;
+      case "IfStmt":
+        complexity++;
+        if (node.init) visit(node.init, inBranch);
+        visit(node.cond, inBranch);
+        visit(node.body, true);
+        if (node.else) visit(node.else, true);
+        return;
+      case "ForStmt":
+      case "RangeStmt":
+        complexity++;
+        forEachChild(node, (child) => visit(child, inBranch));
+        return;
+      case "CaseClause":
+        if (!node.isDefault) complexity++;
+        node.list.forEach((expr) => visit(expr, inBranch));
+        node.body.forEach((stmt) => visit(stmt, true));
+        return;
+      case "CommClause":
+        if (node.comm) complexity++;
+        if (node.comm) visit(node.comm, inBranch);
+        node.body.forEach((stmt) => visit(stmt, true));
+        return;
+      case "BinaryExpr":
+        if (node.op === "&&" || node.op === "||") complexity++;
+        break;
+      case "ReturnStmt":
+        if (inBranch) complexity++;
+        break;
+    }
+    forEachChild(node, (child) => visit(child, inBranch));
+  };
+
+  body.list.forEach((stmt: Stmt) => visit(stmt, false));
+  return complexity;
+}
+
+// The operators of a chain of `&&` and `||`, left to right, and the operands
+function logicalChain(expr: Expr, ops: string[], operands: Expr[]): void {
+  const node = expr.kind === "ParenExpr" ? expr.x : expr;
+  if (node.kind === "BinaryExpr" && (node.op === "&&" || node.op === "||")) {
+    logicalChain(node.x, ops, operands);
+    ops.push(node.op);
+    logicalChain(node.y, ops, operands);
+  } else {
+    operands.push(node);
+  }
+}
+
+/**
+ * Score how hard a body is to follow. Unlike {@link cyclomaticComplexity},
+ * structures nested in others cost more the deeper they are, so an `if` in a
+ * loop in a `switch` outweighs three sibling conditions; `else` branches and
+ * `switch` statements count once however many cases they have, and a run of
+ * `&&` or `||` counts once. Function literals are part of the body, their
+ * contents nested one level deeper. See {@link COGNITIVE_COMPLEXITY_RULES}.
+ */
+export function cognitiveComplexity(body: BlockStmt | undefined): number {
+  if (!body) {
+    return 0;
+  }
+  let complexity = 0;
+
+  const visitIf = (node: IfStmt, nesting: number) => {
+    if (node.init) visit(node.init, nesting);
+    visit(node.cond, nesting);
+    visit(node.body, nesting + 1);
+    if (node.else) {
+      // `else` and `else if` continue the statement: no nesting increment
+      complexity++;
+      if (node.else.kind === "IfStmt") visitIf(node.else, nesting);
+      else visit(node.else, nesting + 1);
+    }
+  };
+
+  const visit = (node: Node, nesting: number) => {
+    switch (node.kind) {
+      case "FuncLit":
+        visit(node.body, nesting + 1);
+        return;
+      case "IfStmt":
+        complexity += 1 + nesting;
+        visitIf(node, nesting);
+        return;
+      case "ForStmt":
+      case "RangeStmt":
+      case "SwitchStmt":
+      case "TypeSwitchStmt":
+      case "SelectStmt":
+        complexity += 1 + nesting;
+        forEachChild(node, (child) =>
+          visit(child, child === node.body ? nesting + 1 : nesting),
+        );
+        return;
+      case "BranchStmt":
+        if (node.tok === "goto" || node.label) complexity++;
+        return;
+      case "BinaryExpr":
+      case "ParenExpr": {
+        const ops: string[] = [];
+        const operands: Expr[] = [];
+        logicalChain(node, ops, operands);
+        if (ops.length === 0) break;
+        complexity += ops.filter((op, i) => op !== ops[i - 1]).length;
+        operands.forEach((operand) => visit(operand, nesting));
+        return;
+      }
+    }
+    forEachChild(node, (child) => visit(child, nesting));
+  };
+
+  body.list.forEach((stmt: Stmt) => visit(stmt, 0));
+  return complexity;
+}
+
+/**
+ * Measure every function literal inside a body, including nested ones
+ */
+export function closureComplexities(
+  file: GoFile,
+  body: BlockStmt | undefined,
+): GoClosureComplexity[] {
+  const closures: GoClosureComplexity[] = [];
+  if (!body) {
+    return closures;
+  }
+
+  // Pre-order traversal sees `defer`/`go` statements before their literal
+  const usages = new Map<FuncLit, GoClosureComplexity["usage"]>();
+  const visit = (node: Node) => {
+    if (
+      (node.kind === "DeferStmt" || node.kind === "GoStmt") &&
+      node.call.fun.kind === "FuncLit"
+    ) {
+      usages.set(node.call.fun, node.kind === "DeferStmt" ? "defer" : "go");
+    }
+    if (node.kind === "FuncLit") {
+      closures.push({
+        startLine: file.sourceMap.line(node.pos),
+        endLine: file.sourceMap.line(node.end),
+        complexity: cyclomaticComplexity(node.body),
+        usage: usages.get(node) ?? "value",
+      });
+    }
+    forEachChild(node, visit);
+  };
+  visit(body);
+
+  return closures;
+}
+
+/**
+ * Functions whose cyclomatic complexity exceeds the threshold, or whose
+ * cognitive complexity exceeds the cognitive threshold, most complex first
+ * unless another sort key is given. Fan-in and fan-out filters only match
+ * symbols annotated from a call graph.
+ */
+export function complexityCandidates(
+  symbols: {
+    qualifiedName: string;
+    startLine: number;
+    complexity?: number;
+    cognitiveComplexity?: number;
+    fanIn?: number;
+    fanOut?: number;
+  }[],
+  threshold: number = DEFAULT_COMPLEXITY_THRESHOLD,
+  options: GoCandidateOptions = {},
+): GoComplexityCandidate[] {
+  const sortBy = options.sortBy ?? "complexity";
+  const cognitiveThreshold =
+    options.cognitiveThreshold ?? DEFAULT_COGNITIVE_THRESHOLD;
+  return symbols
+    .filter(
+      (symbol) =>
+        (symbol.complexity ?? 0) > threshold ||
+        (symbol.cognitiveComplexity ?? 0) > cognitiveThreshold,
+    )
+    .filter(
+      (symbol) =>
+        options.minFanIn === undefined || symbol.fanIn >= options.minFanIn,
+    )
+    .filter(
+      (symbol) =>
+        options.minFanOut === undefined || symbol.fanOut >= options.minFanOut,
+    )
+    .map((symbol) => ({
+      qualifiedName: symbol.qualifiedName,
+      startLine: symbol.startLine,
+      complexity: symbol.complexity ?? 0,
+      threshold,
+      cognitiveComplexity: symbol.cognitiveComplexity,
+      cognitiveThreshold,
+      fanIn: symbol.fanIn,
+      fanOut: symbol.fanOut,
+    }))
+    .sort(
+      (a, b) =>
+        (b[sortBy] ?? 0) - (a[sortBy] ?? 0) ||
+        b.complexity - a.complexity ||
+        compareCodePoints(a.qualifiedName, b.qualifiedName),
+    );
+}
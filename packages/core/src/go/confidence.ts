@@ -0,0 +1,260 @@
+import { GoFile } from "./ast.js";
+import { buildGoCallGraph, GoCallGraph } from "./callgraph.js";
+import { GoCoverProfile, symbolCoverage } from "./coverage.js";
+import { GoRefactorError } from "./refactor.js";
+import { Visibility } from "./symbols.js";
+import { GoTestIndex } from "./test-links.js";
+
+/**
+ * Refactor Confidence
+ * ===================
+ * Scores how safe a proposed refactor is, from 0 (review every line) to 1
+ * (safe to apply unattended). The score is the product of independent
+ * factors, each between 0 and 1, so any single risk pulls it down and the
+ * factors show where the doubt comes from:
+ *
+ * - visibility: exported symbols may be used by packages that were not
+ *   analyzed
+ * - tests: a test that reaches the function would catch a broken refactor;
+ *   with a coverage profile, the share of its lines the tests run
+ * - complexity: branching bodies are harder to transform correctly
+ * - references: every caller of the symbol, or every method the body calls,
+ *   resolves within the analyzed files
+ */
+
+export const DEFAULT_AUTO_APPLY_CONFIDENCE = 0.8;
+
+export type GoRefactorKind =
+  | "rename"
+  | "signature"
+  | "move"
+  | "delete"
+  | "extract"
+  | "inline"
+  | "rewrite";
+
+// Refactors that change how callers refer to the function
+const CALLER_FACING = new Set<GoRefactorKind>([
+  "rename",
+  "signature",
+  "move",
+  "delete",
+]);
+
+// Refactors that edit the function's body
+const BODY_EDITING = new Set<GoRefactorKind>([
+  "signature",
+  "extract",
+  "inline",
+  "rewrite",
+]);
+
+/**
+ * A refactor proposed for one function or method
+ */
+export interface GoRefactorSuggestion {
+  kind: GoRefactorKind;
+  /** Call graph id of the function the refactor changes, e.g. `pkg.Parse` */
+  target: string;
+}
+
+export type GoConfidenceFactorName =
+  | "visibility"
+  | "tests"
+  | "complexity"
+  | "references";
+
+export interface GoConfidenceFactor {
+  name: GoConfidenceFactorName;
+  /** Multiplier this factor contributes, between 0 and 1 */
+  score: number;
+  reason: string;
+}
+
+export interface GoConfidence {
+  /** Product of the factor scores, rounded to two decimals */
+  score: number;
+  factors: GoConfidenceFactor[];
+}
+
+export interface GoConfidenceOptions {
+  /** Coverage profile used in place of call graph reachability from tests */
+  coverage?: GoCoverProfile;
+}
+
+export type GoScoredSuggestion<T extends GoRefactorSuggestion> = T & {
+  confidence: GoConfidence;
+};
+
+// Built once per graph, since suggestions are scored against the same one
+const testIndexes = new WeakMap<GoCallGraph, GoTestIndex>();
+
+function testIndex(graph: GoCallGraph): GoTestIndex {
+  if (!testIndexes.has(graph)) testIndexes.set(graph, new GoTestIndex(graph));
+  return testIndexes.get(graph);
+}
+
+function round(value: number): number {
+  return Math.round(value * 100) / 100;
+}
+
+/**
+ * Score one suggestion against a call graph of the analyzed files
+ */
+export function refactorConfidence(
+  graph: GoCallGraph,
+  suggestion: GoRefactorSuggestion,
+  options: GoConfidenceOptions = {},
+): GoConfidence {
+  const node = graph.nodes.get(suggestion.target);
+  if (!node) {
+    throw new GoRefactorError(
+      `No function ${suggestion.target} in the analyzed files`,
+    );
+  }
+  const { symbol, packageName } = node;
+  const callerFacing = CALLER_FACING.has(suggestion.kind);
+  // Package main cannot be imported, so its exported names are local too
+  const external =
+    symbol.visibility === Visibility.Exported && packageName !== "main";
+  const factors: GoConfidenceFactor[] = [];
+
+  if (!callerFacing) {
+    factors.push({
+      name: "visibility",
+      score: 1,
+      reason: "callers are unaffected",
+    });
+  } else if (external) {
+    factors.push({
+      name: "visibility",
+      score: 0.6,
+      reason: `exported from package ${packageName}`,
+    });
+  } else {
+    factors.push({
+      name: "visibility",
+      score: 1,
+      reason:
+        symbol.visibility === Visibility.Exported
+          ? "declared in package main"
+          : "unexported",
+    });
+  }
+
+  const coverage =
+    options.coverage && symbolCoverage(options.coverage, node.filePath, symbol);
+  const test = coverage ? undefined : testIndex(graph).nearest(node.id);
+  if (coverage) {
+    factors.push({
+      name: "tests",
+      score: round(0.6 + (0.4 * coverage.coveragePct) / 100),
+      reason: `${coverage.coveragePct}% of lines covered`,
+    });
+  } else if (!test) {
+    factors.push({ name: "tests", score: 0.6, reason: "no test reaches it" });
+  } else if (test.depth === 1) {
+    factors.push({
+      name: "tests",
+      score: 1,
+      reason: `called by ${test.test}`,
+    });
+  } else {
+    factors.push({
+      name: "tests",
+      score: 0.9,
+      reason: `reached from ${test.test} through ${test.depth - 1} call(s)`,
+    });
+  }
+
+  if (!BODY_EDITING.has(suggestion.kind)) {
+    factors.push({
+      name: "complexity",
+      score: 1,
+      reason: "the body is not edited",
+    });
+  } else {
+    const complexity = symbol.complexity;
+    factors.push({
+      name: "complexity",
+      score:
+        complexity <= 5 ? 1 : round(Math.max(0.5, 1 - (complexity - 5) / 30)),
+      reason: `cyclomatic complexity ${complexity}`,
+    });
+  }
+
+  if (callerFacing) {
+    const callers = [...(graph.callers.get(node.id) ?? [])].filter(
+      (caller) => caller !== node.id,
+    ).length;
+    if (external) {
+      factors.push({
+        name: "references",
+        score: 0.5,
+        reason: `${callers} caller(s) found; callers in other packages may exist`,
+      });
+    } else if (symbol.receiver && graph.interfaceMethods.has(symbol.name)) {
+      factors.push({
+        name: "references",
+        score: 0.5,
+        reason: `${symbol.name} is declared by an interface; other implementations must change too`,
+      });
+    } else {
+      factors.push({
+        name: "references",
+        score: 1,
+        reason: `all ${callers} caller(s) are in the analyzed files`,
+      });
+    }
+  } else {
+    // Method calls on values of unknown type cannot be followed
+    const unresolved = [...(graph.externalCalls.get(node.id) ?? [])]
+      .filter((call) => call.startsWith("."))
+      .sort();
+    factors.push({
+      name: "references",
+      score:
+        unresolved.length === 0
+          ? 1
+          : round(Math.max(0.6, 1 - 0.1 * unresolved.length)),
+      reason:
+        unresolved.length === 0
+          ? "every call in the body resolves"
+          : `calls undeclared method(s) ${unresolved.join(", ")}`,
+    });
+  }
+
+  return {
+    score: round(
+      factors.reduce((product, factor) => product * factor.score, 1),
+    ),
+    factors,
+  };
+}
+
+/**
+ * Attach a confidence score to each suggestion
+ */
+export function scoreSuggestions<T extends GoRefactorSuggestion>(
+  files: GoFile[],
+  suggestions: T[],
+  options: GoConfidenceOptions = {},
+  graph: GoCallGraph = buildGoCallGraph(files),
+): GoScoredSuggestion<T>[] {
+  return suggestions.map((suggestion) => ({
+    ...suggestion,
+    confidence: refactorConfidence(graph, suggestion, options),
+  }));
+}
+
+/**
+ * Keep the suggestions confident enough to apply without review
+ */
+export function autoApplicable<T extends { confidence: GoConfidence }>(
+  suggestions: T[],
+  threshold = DEFAULT_AUTO_APPLY_CONFIDENCE,
+): T[] {
+  return suggestions.filter(
+    (suggestion) => suggestion.confidence.score >= threshold,
+  );
+}
@@ -0,0 +1,423 @@
+import * as fs from "fs";
+import * as yaml from "js-yaml";
+import * as path from "path";
+import { GoFinding, GoSeverity } from "./findings.js";
+import { GoGateError, GoGateSeverity, parseGoSeverity } from "./gate.js";
+import {
+  DEFAULT_GO_MAINTAINABILITY_CONSTANTS,
+  GoMaintainabilityConstants,
+} from "./maintainability.js";
+import {
+  builtinGoRules,
+  defaultGoRuleRegistry,
+  GoBuiltinRuleOptions,
+  GoRuleRegistry,
+} from "./rules.js";
+
+/**
+ * Go Configuration Files
+ * ======================
+ * Settings read from `.refactogent.yaml` at the root of the analyzed tree,
+ * and from the same file in any directory below it, to adjust the rules for
+ * a subtree: stricter in `internal/`, lenient in `testdata/`. A file's
+ * settings are those of the root config deep-merged with the config nearest
+ * to the file, so a subtree config only lists what it changes. Mappings are
+ * merged key by key; a value of any other kind, lists included, replaces the
+ * root's.
+ *
+ * ```yaml
+ * failOn: medium
+ * maxWarnings: 20
+ * rules:
+ *   todo-comment: off
+ *   ignored-error: high
+ *   too-many-parameters:
+ *     severity: medium
+ *     maxParameters: 7
+ * ```
+ *
+ * A rule takes a severity, `off` to switch it off, or a mapping of its
+ * severity and the options of its check, such as thresholds. `failOn` and
+ * `maxWarnings` set the CI gate for the whole run, so only the root config
+ * may give them. Unknown keys and rule IDs are errors naming the file, so a
+ * typo does not silently leave a setting unapplied.
+ */
+
+export const GO_CONFIG_FILE_NAMES = [".refactogent.yaml", ".refactogent.yml"];
+
+export interface GoRuleConfig {
+  /** Severity findings of the rule are reported with, or `off` */
+  severity?: GoGateSeverity;
+  /** Options of the rule's check (see {@link GoBuiltinRuleOptions}) */
+  options?: Record<
+    string,
+    number | string[] | Partial<GoMaintainabilityConstants>
+  >;
+}
+
+export interface GoConfig {
+  /** Lowest severity that fails the build */
+  failOn?: GoSeverity;
+  /** Warnings allowed before the build fails */
+  maxWarnings?: number;
+  /** Settings per rule ID */
+  rules?: Record<string, GoRuleConfig>;
+}
+
+export interface GoResolvedConfig {
+  /** Directory the config applies to */
+  directory: string;
+  /** Config files merged into it, the root's first */
+  sources: string[];
+  config: GoConfig;
+}
+
+export interface GoConfigParseOptions {
+  /** File name errors are reported with */
+  source?: string;
+  /** Whether settings of the whole run are allowed (default: true) */
+  root?: boolean;
+  /** Rule IDs besides the built-in ones, for project-specific rules */
+  rules?: string[];
+}
+
+export interface GoConfigResolverOptions {
+  /** Rule IDs besides the built-in ones, for project-specific rules */
+  rules?: string[];
+}
+
+/**
+ * Error raised for config files that cannot be read or contain unknown keys
+ */
+export class GoConfigError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoConfigError";
+  }
+}
+
+type OptionKind = "count" | "index" | "strings" | "constants";
+
+// The options of each built-in check a config may set
+const RULE_OPTIONS: Record<
+  keyof GoBuiltinRuleOptions,
+  Record<string, OptionKind>
+> = {
+  "error-string-style": { capitalizedWords: "strings" },
+  "unsynchronized-field": { ignoreTypes: "strings" },
+  "split-struct": { minFields: "count", minClusterFields: "count" },
+  "too-many-parameters": { maxParameters: "count" },
+  "function-could-be-method": { minClusterSize: "count" },
+  "naked-return": { maxLines: "count" },
+  "missing-context": { blockingCalls: "strings" },
+  "todo-comment": { tags: "strings" },
+  "hidden-global-state": { minGlobals: "count" },
+  "low-maintainability": { minIndex: "index", constants: "constants" },
+};
+
+const ROOT_KEYS = ["failOn", "maxWarnings"];
+
+function isMapping(value: unknown): value is Record<string, unknown> {
+  return typeof value === "object" && value !== null && !Array.isArray(value);
+}
+
+function expected(keys: string[]): string {
+  return keys.length === 1
+    ? keys[0]
+    : `${keys.slice(0, -1).join(", ")} or ${keys.at(-1)}`;
+}
+
+class ConfigParser {
+  private readonly source: string;
+  private readonly root: boolean;
+  private readonly rules: Set<string>;
+
+  constructor(options: GoConfigParseOptions) {
+    this.source = options.source ?? GO_CONFIG_FILE_NAMES[0];
+    this.root = options.root ?? true;
+    this.rules = new Set([
+      ...builtinGoRules().map((rule) => rule.id),
+      ...(options.rules ?? []),
+    ]);
+  }
+
+  private fail(message: string): never {
+    throw new GoConfigError(`${this.source}: ${message}`);
+  }
+
+  private severity(value: unknown, key: string): GoGateSeverity {
+    if (typeof value !== "string") {
+      this.fail(`${key} must be a severity`);
+    }
+    try {
+      return parseGoSeverity(value);
+    } catch (error) {
+      if (error instanceof GoGateError) this.fail(`${key}: ${error.message}`);
+      throw error;
+    }
+  }
+
+  private option(value: unknown, kind: OptionKind, key: string) {
+    if (kind === "count") {
+      if (!Number.isInteger(value) || (value as number) < 0) {
+        this.fail(`${key} must be a non-negative integer`);
+      }
+      return value as number;
+    }
+    if (kind === "index") {
+      if (typeof value !== "number" || !(value >= 0 && value <= 100)) {
+        this.fail(`${key} must be a number from 0 to 100`);
+      }
+      return value as number;
+    }
+    if (kind === "constants") return this.constants(value, key);
+    if (
+      !Array.isArray(value) ||
+      !value.every((item) => typeof item === "string")
+    ) {
+      this.fail(`${key} must be a list of strings`);
+    }
+    return value as string[];
+  }
+
+  private constants(
+    value: unknown,
+    key: string,
+  ): Partial<GoMaintainabilityConstants> {
+    const names = Object.keys(DEFAULT_GO_MAINTAINABILITY_CONSTANTS);
+    if (!isMapping(value)) this.fail(`${key} must be a mapping`);
+    for (const [name, constant] of Object.entries(value)) {
+      if (!names.includes(name)) {
+        this.fail(`unknown key ${key}.${name}; expected ${expected(names)}`);
+      }
+      if (!Number.isFinite(constant)) {
+        this.fail(`${key}.${name} must be a number`);
+      }
+    }
+    if (value.scale === 0) this.fail(`${key}.scale cannot be 0`);
+    return value as Partial<GoMaintainabilityConstants>;
+  }
+
+  private rule(id: string, value: unknown): GoRuleConfig {
+    const key = `rules.${id}`;
+    if (!this.rules.has(id)) this.fail(`unknown rule ${id}`);
+    if (!isMapping(value)) return { severity: this.severity(value, key) };
+
+    const kinds: Record<string, OptionKind> =
+      RULE_OPTIONS[id as keyof GoBuiltinRuleOptions] ?? {};
+    const rule: GoRuleConfig = {};
+    const options: GoRuleConfig["options"] = {};
+    for (const [name, setting] of Object.entries(value)) {
+      if (name === "severity") {
+        rule.severity = this.severity(setting, `${key}.severity`);
+      } else if (name in kinds) {
+        options[name] = this.option(setting, kinds[name], `${key}.${name}`);
+      } else {
+        const keys = ["severity", ...Object.keys(kinds)];
+        this.fail(`unknown key ${key}.${name}; expected ${expected(keys)}`);
+      }
+    }
+    if (Object.keys(options).length > 0) rule.options = options;
+    return rule;
+  }
+
+  parse(content: string): GoConfig {
+    let document: unknown;
+    try {
+      document = yaml.load(content);
+    } catch (error) {
+      this.fail(error.message);
+    }
+    if (document === undefined || document === null) return {};
+    if (!isMapping(document)) this.fail("the config must be a mapping");
+
+    const config: GoConfig = {};
+    for (const [key, value] of Object.entries(document)) {
+      if (ROOT_KEYS.includes(key) && !this.root) {
+        this.fail(`${key} applies to the whole run; set it in the root config`);
+      }
+      switch (key) {
+        case "failOn": {
+          const severity = this.severity(value, key);
+          if (severity === "off") this.fail("failOn cannot be off");
+          config.failOn = severity;
+          break;
+        }
+        case "maxWarnings":
+          config.maxWarnings = this.option(value, "count", key) as number;
+          break;
+        case "rules":
+          if (!isMapping(value)) this.fail("rules must be a mapping");
+          config.rules = Object.fromEntries(
+            Object.entries(value).map(([id, rule]) => [
+              id,
+              this.rule(id, rule),
+            ]),
+          );
+          break;
+        default:
+          this.fail(
+            `unknown key ${key}; expected ${expected([...ROOT_KEYS, "rules"])}`,
+          );
+      }
+    }
+    return config;
+  }
+}
+
+/**
+ * Parse and validate the content of a config file
+ */
+export function parseGoConfig(
+  content: string,
+  options: GoConfigParseOptions = {},
+): GoConfig {
+  return new ConfigParser(options).parse(content);
+}
+
+function deepMerge<T>(base: T, override: T): T {
+  if (!isMapping(base) || !isMapping(override)) return override;
+  const merged: Record<string, unknown> = { ...base };
+  for (const [key, value] of Object.entries(override)) {
+    merged[key] = key in base ? deepMerge(base[key], value) : value;
+  }
+  return merged as T;
+}
+
+/**
+ * A config with another's settings laid over it
+ */
+export function mergeGoConfigs(base: GoConfig, override: GoConfig): GoConfig {
+  return deepMerge(base, override);
+}
+
+/**
+ * The options a config gives the built-in checks, by rule ID
+ */
+export function goConfigRuleOptions(config: GoConfig): GoBuiltinRuleOptions {
+  return Object.fromEntries(
+    Object.entries(config.rules ?? {})
+      .filter(([, rule]) => rule.options)
+      .map(([id, rule]) => [id, rule.options]),
+  );
+}
+
+/**
+ * The registry of built-in rules a config selects and tunes
+ */
+export function goConfigRegistry(config: GoConfig): GoRuleRegistry {
+  const registry = defaultGoRuleRegistry(goConfigRuleOptions(config));
+  for (const [id, rule] of Object.entries(config.rules ?? {})) {
+    if (rule.severity === "off" && registry.get(id)) registry.disable(id);
+  }
+  return registry;
+}
+
+/**
+ * Findings with the severities a config gives their rules, leaving out the
+ * rules it switches off
+ */
+export function applyGoConfigSeverities<T extends GoFinding>(
+  findings: T[],
+  config: GoConfig,
+): T[] {
+  return findings.flatMap((finding) => {
+    const severity = config.rules?.[finding.rule]?.severity;
+    if (severity === "off") return [];
+    return severity ? [{ ...finding, severity }] : [finding];
+  });
+}
+
+/**
+ * Finds the config files of a tree and resolves the config of each file in
+ * it. Files are read once and their settings kept for the resolver's life.
+ */
+export class GoConfigResolver {
+  private readonly root: string;
+  private readonly options: GoConfigResolverOptions;
+  private readonly files = new Map<string, GoConfig | undefined>();
+  private readonly nearest = new Map<string, string | undefined>();
+  private readonly registries = new Map<string, GoRuleRegistry>();
+
+  constructor(root: string, options: GoConfigResolverOptions = {}) {
+    this.root = path.resolve(root);
+    this.options = options;
+  }
+
+  // The config file of a directory, when it has one
+  private fileIn(directory: string): string | undefined {
+    const found = GO_CONFIG_FILE_NAMES.map((name) =>
+      path.join(directory, name),
+    ).filter((file) => fs.existsSync(file));
+    if (found.length > 1) {
+      throw new GoConfigError(
+        `${path.relative(this.root, directory) || "."} has both ${found
+          .map((file) => path.basename(file))
+          .join(" and ")}; keep one`,
+      );
+    }
+    return found[0];
+  }
+
+  private read(file: string): GoConfig {
+    if (!this.files.has(file)) {
+      const source = path.relative(this.root, file).split(path.sep).join("/");
+      this.files.set(
+        file,
+        parseGoConfig(fs.readFileSync(file, "utf-8"), {
+          source,
+          root: path.dirname(file) === this.root,
+          rules: this.options.rules,
+        }),
+      );
+    }
+    return this.files.get(file);
+  }
+
+  // The config file nearest to a directory below the root, the root's aside
+  private nearestFile(directory: string): string | undefined {
+    const below = directory.startsWith(this.root + path.sep);
+    if (!below) return undefined;
+    if (!this.nearest.has(directory)) {
+      this.nearest.set(
+        directory,
+        this.fileIn(directory) ?? this.nearestFile(path.dirname(directory)),
+      );
+    }
+    return this.nearest.get(directory);
+  }
+
+  /**
+   * The config of the root directory, holding the settings of the whole run
+   */
+  rootConfig(): GoConfig {
+    const file = this.fileIn(this.root);
+    return file ? this.read(file) : {};
+  }
+
+  /**
+   * The config applying to a file: the root's merged with the nearest one
+   */
+  resolve(filePath: string): GoResolvedConfig {
+    const directory = path.dirname(path.resolve(filePath));
+    const sources = [
+      this.fileIn(this.root),
+      this.nearestFile(directory),
+    ].filter((file) => file !== undefined);
+    const config = sources
+      .map((file) => this.read(file))
+      .reduce(mergeGoConfigs, {});
+    return { directory, sources, config };
+  }
+
+  /**
+   * The registry for a file's config, shared by files with the same sources
+   */
+  registry(resolved: GoResolvedConfig): GoRuleRegistry {
+    const key = resolved.sources.join("\0");
+    if (!this.registries.has(key)) {
+      this.registries.set(key, goConfigRegistry(resolved.config));
+    }
+    return this.registries.get(key);
+  }
+}
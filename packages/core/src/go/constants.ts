@@ -0,0 +1,481 @@
+import { SymbolInfo } from "../indexing.js";
+import { BasicLit, Expr, GenDecl, GoFile, ValueSpec } from "./ast.js";
+import { goVisibility, Visibility } from "./symbols.js";
+
+/**
+ * A package-level constant with its resolved value
+ */
+export interface GoConstantSymbol extends SymbolInfo {
+  type: "constant";
+  visibility: Visibility;
+  /** Declared type, or the default type of an untyped constant */
+  declaredType: string;
+  isTyped: boolean;
+  /**
+   * Source of the value expression. Members that repeat the previous
+   * expression implicitly (`B` in `A = iota; B`) carry the repeated text.
+   */
+  expression?: string;
+  /** Resolved value as Go source; undefined when it cannot be evaluated */
+  value?: string;
+  /** Value of iota for the spec declaring this constant */
+  iota: number;
+  /** Whether the constant is declared in a `const ( ... )` block */
+  grouped: boolean;
+}
+
+type ConstValue =
+  | { kind: "int"; value: bigint }
+  | { kind: "float"; value: number }
+  | { kind: "string"; value: string }
+  | { kind: "bool"; value: boolean };
+
+// A value with its type: a declared or named type, or undefined when untyped
+interface Typed {
+  value: ConstValue;
+  typeName?: string;
+  /** Default type of an untyped constant, from the literal it came from */
+  defaultType: string;
+}
+
+const INTEGER_TYPES = new Set([
+  "int",
+  "int8",
+  "int16",
+  "int32",
+  "int64",
+  "uint",
+  "uint8",
+  "uint16",
+  "uint32",
+  "uint64",
+  "uintptr",
+  "byte",
+  "rune",
+]);
+
+const FLOAT_TYPES = new Set(["float32", "float64"]);
+
+const ESCAPES: Record<string, string> = {
+  a: "\x07",
+  b: "\b",
+  f: "\f",
+  n: "\n",
+  r: "\r",
+  t: "\t",
+  v: "\v",
+  "\\": "\\",
+  "'": "'",
+  '"': '"',
+};
+
+/**
+ * Decode the contents of an interpreted string or rune literal
+ */
+function unquote(literal: string): string {
+  if (literal.startsWith("`")) {
+    return literal.slice(1, -1).replace(/\r/g, "");
+  }
+  const body = literal.slice(1, -1);
+  return body.replace(
+    /\\(x[0-9a-fA-F]{2}|u[0-9a-fA-F]{4}|U[0-9a-fA-F]{8}|[0-7]{3}|.)/g,
+    (_, escape: string) => {
+      switch (escape[0]) {
+        case "x":
+        case "u":
+        case "U":
+          return String.fromCodePoint(parseInt(escape.slice(1), 16));
+        default:
+          if (/^[0-7]{3}$/.test(escape)) {
+            return String.fromCodePoint(parseInt(escape, 8));
+          }
+          return ESCAPES[escape] ?? escape;
+      }
+    },
+  );
+}
+
+function literalValue(lit: BasicLit): Typed | undefined {
+  const text = lit.value.replace(/_/g, "");
+  switch (lit.litKind) {
+    case "int": {
+      // Legacy octal literals such as 0755
+      const normalized = /^0[0-7]+$/.test(text) ? `0o${text.slice(1)}` : text;
+      return {
+        value: { kind: "int", value: BigInt(normalized) },
+        defaultType: "int",
+      };
+    }
+    case "float":
+      if (/^0[xX]/.test(text)) return undefined;
+      return {
+        value: { kind: "float", value: parseFloat(text) },
+        defaultType: "float64",
+      };
+    case "char": {
+      const rune = unquote(lit.value).codePointAt(0);
+      return {
+        value: { kind: "int", value: BigInt(rune) },
+        defaultType: "rune",
+      };
+    }
+    case "string":
+      return {
+        value: { kind: "string", value: unquote(lit.value) },
+        defaultType: "string",
+      };
+    default:
+      return undefined;
+  }
+}
+
+function toFloat(value: ConstValue): number {
+  return value.kind === "int" ? Number(value.value) : (value.value as number);
+}
+
+// A conversion or typed operand fixes the type; otherwise promote the default
+// type the way Go does for mixed untyped operands (int < rune < float64)
+function resultType(
+  x: Typed,
+  y: Typed,
+): Pick<Typed, "typeName" | "defaultType"> {
+  const rank = ["int", "rune", "float64"];
+  const defaultType =
+    rank.indexOf(y.defaultType) > rank.indexOf(x.defaultType)
+      ? y.defaultType
+      : x.defaultType;
+  return { typeName: x.typeName ?? y.typeName, defaultType };
+}
+
+const INT_OPS: Record<string, (l: bigint, r: bigint) => bigint | undefined> = {
+  "+": (l, r) => l + r,
+  "-": (l, r) => l - r,
+  "*": (l, r) => l * r,
+  "/": (l, r) => (r === 0n ? undefined : l / r),
+  "%": (l, r) => (r === 0n ? undefined : l % r),
+  "&": (l, r) => l & r,
+  "|": (l, r) => l | r,
+  "^": (l, r) => l ^ r,
+  "&^": (l, r) => l & ~r,
+  "<<": (l, r) => l << r,
+  ">>": (l, r) => l >> r,
+};
+
+const FLOAT_OPS: Record<string, (l: number, r: number) => number | undefined> =
+  {
+    "+": (l, r) => l + r,
+    "-": (l, r) => l - r,
+    "*": (l, r) => l * r,
+    "/": (l, r) => (r === 0 ? undefined : l / r),
+  };
+
+const COMPARISONS: Record<string, (order: number) => boolean> = {
+  "==": (order) => order === 0,
+  "!=": (order) => order !== 0,
+  "<": (order) => order < 0,
+  "<=": (order) => order <= 0,
+  ">": (order) => order > 0,
+  ">=": (order) => order >= 0,
+};
+
+function compare<T>(op: string, l: T, r: T): Typed | undefined {
+  const test = COMPARISONS[op];
+  if (!test) return undefined;
+  return {
+    value: { kind: "bool", value: test(l < r ? -1 : l > r ? 1 : 0) },
+    defaultType: "bool",
+  };
+}
+
+function binary(op: string, x: Typed, y: Typed): Typed | undefined {
+  const a = x.value;
+  const b = y.value;
+  const type = resultType(x, y);
+
+  if (a.kind === "bool" && b.kind === "bool") {
+    if (op === "&&" || op === "||") {
+      const value = op === "&&" ? a.value && b.value : a.value || b.value;
+      return { value: { kind: "bool", value }, ...type };
+    }
+    return op === "==" || op === "!="
+      ? compare(op, a.value, b.value)
+      : undefined;
+  }
+
+  if (a.kind === "string" && b.kind === "string") {
+    if (op === "+") {
+      return { value: { kind: "string", value: a.value + b.value }, ...type };
+    }
+    return compare(op, a.value, b.value);
+  }
+
+  if (a.kind === "int" && b.kind === "int") {
+    const apply = INT_OPS[op];
+    if (!apply) return compare(op, a.value, b.value);
+    const value = apply(a.value, b.value);
+    if (value === undefined) return undefined;
+    // Shifts take the type of the left operand
+    const shifted = op === "<<" || op === ">>";
+    return {
+      value: { kind: "int", value },
+      ...(shifted
+        ? { typeName: x.typeName, defaultType: x.defaultType }
+        : type),
+    };
+  }
+
+  if (
+    (a.kind === "int" || a.kind === "float") &&
+    (b.kind === "int" || b.kind === "float")
+  ) {
+    const apply = FLOAT_OPS[op];
+    if (!apply) return compare(op, toFloat(a), toFloat(b));
+    const value = apply(toFloat(a), toFloat(b));
+    if (value === undefined) return undefined;
+    return {
+      value: { kind: "float", value },
+      typeName: type.typeName,
+      defaultType: "float64",
+    };
+  }
+
+  return undefined;
+}
+
+/**
+ * Format a constant value as Go source
+ */
+function formatValue(value: ConstValue): string {
+  switch (value.kind) {
+    case "int":
+      return value.value.toString();
+    case "float": {
+      const text = String(value.value);
+      return /^-?\d+$/.test(text) ? `${text}.0` : text;
+    }
+    case "string":
+      return JSON.stringify(value.value);
+    case "bool":
+      return String(value.value);
+  }
+}
+
+class ConstEvaluator {
+  private readonly file: GoFile;
+  private readonly constants = new Map<string, Typed>();
+  // Underlying basic type of each named type declared in the file
+  private readonly underlying = new Map<string, string>();
+
+  constructor(file: GoFile) {
+    this.file = file;
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+      for (const spec of decl.specs) {
+        if (spec.kind === "TypeSpec" && spec.type.kind === "Ident") {
+          this.underlying.set(spec.name.name, spec.type.name);
+        }
+      }
+    }
+  }
+
+  define(name: string, value: Typed): void {
+    if (name !== "_") {
+      this.constants.set(name, value);
+    }
+  }
+
+  text(expr: Expr): string {
+    return this.file.source.slice(expr.pos, expr.end);
+  }
+
+  private basicType(typeName: string): string {
+    let current = typeName;
+    for (let depth = 0; depth < 8 && this.underlying.has(current); depth++) {
+      current = this.underlying.get(current);
+    }
+    return current;
+  }
+
+  convert(value: Typed, typeName: string): Typed | undefined {
+    const basic = this.basicType(typeName);
+    const v = value.value;
+    if (INTEGER_TYPES.has(basic)) {
+      if (v.kind === "float") {
+        if (!Number.isInteger(v.value)) return undefined;
+        const value = BigInt(v.value);
+        return { value: { kind: "int", value }, typeName, defaultType: basic };
+      }
+      if (v.kind !== "int") return undefined;
+    } else if (FLOAT_TYPES.has(basic)) {
+      if (v.kind !== "int" && v.kind !== "float") return undefined;
+      const value = toFloat(v);
+      return { value: { kind: "float", value }, typeName, defaultType: basic };
+    } else if (basic === "string") {
+      if (v.kind === "int") {
+        const value = String.fromCodePoint(Number(v.value));
+        return {
+          value: { kind: "string", value },
+          typeName,
+          defaultType: basic,
+        };
+      }
+      if (v.kind !== "string") return undefined;
+    } else if (basic === "bool") {
+      if (v.kind !== "bool") return undefined;
+    } else {
+      return undefined;
+    }
+    return { value: v, typeName, defaultType: basic };
+  }
+
+  evaluate(expr: Expr, iota: number): Typed | undefined {
+    switch (expr.kind) {
+      case "BasicLit":
+        return literalValue(expr);
+      case "ParenExpr":
+        return this.evaluate(expr.x, iota);
+      case "Ident":
+        switch (expr.name) {
+          case "iota":
+            return {
+              value: { kind: "int", value: BigInt(iota) },
+              defaultType: "int",
+            };
+          case "true":
+          case "false":
+            return {
+              value: { kind: "bool", value: expr.name === "true" },
+              defaultType: "bool",
+            };
+          default:
+            return this.constants.get(expr.name);
+        }
+      case "UnaryExpr": {
+        const operand = this.evaluate(expr.x, iota);
+        if (!operand) return undefined;
+        const v = operand.value;
+        switch (expr.op) {
+          case "+":
+            return v.kind === "int" || v.kind === "float" ? operand : undefined;
+          case "-":
+            if (v.kind === "int") {
+              return { ...operand, value: { kind: "int", value: -v.value } };
+            }
+            if (v.kind === "float") {
+              return { ...operand, value: { kind: "float", value: -v.value } };
+            }
+            return undefined;
+          case "^":
+            return v.kind === "int"
+              ? { ...operand, value: { kind: "int", value: ~v.value } }
+              : undefined;
+          case "!":
+            return v.kind === "bool"
+              ? { ...operand, value: { kind: "bool", value: !v.value } }
+              : undefined;
+          default:
+            return undefined;
+        }
+      }
+      case "BinaryExpr": {
+        const x = this.evaluate(expr.x, iota);
+        const y = this.evaluate(expr.y, iota);
+        return x && y ? binary(expr.op, x, y) : undefined;
+      }
+      case "CallExpr": {
+        if (expr.args.length !== 1) return undefined;
+        const arg = this.evaluate(expr.args[0], iota);
+        if (!arg) return undefined;
+        if (expr.fun.kind === "Ident" && expr.fun.name === "len") {
+          if (arg.value.kind !== "string") return undefined;
+          // len of a constant string counts bytes
+          const length = Buffer.byteLength(arg.value.value, "utf-8");
+          return {
+            value: { kind: "int", value: BigInt(length) },
+            defaultType: "int",
+          };
+        }
+        if (expr.fun.kind === "Ident") {
+          return this.convert(arg, expr.fun.name);
+        }
+        return undefined;
+      }
+      default:
+        return undefined;
+    }
+  }
+}
+
+/**
+ * Extract package-level constants, resolving iota and implicit repetition of
+ * the previous spec's type and expressions within a `const` block
+ */
+export function extractGoConstants(file: GoFile): GoConstantSymbol[] {
+  const evaluator = new ConstEvaluator(file);
+  const constants: GoConstantSymbol[] = [];
+
+  for (const decl of file.decls) {
+    if (decl.kind !== "GenDecl" || decl.tok !== "const") continue;
+    constants.push(...blockConstants(file, evaluator, decl));
+  }
+
+  return constants;
+}
+
+function blockConstants(
+  file: GoFile,
+  evaluator: ConstEvaluator,
+  decl: GenDecl,
+): GoConstantSymbol[] {
+  const grouped = decl.lparen >= 0;
+  const constants: GoConstantSymbol[] = [];
+  // The most recent spec with values, repeated by specs that omit them
+  let template: ValueSpec | undefined;
+
+  decl.specs.forEach((spec, iota) => {
+    if (spec.kind !== "ValueSpec") return;
+    if (spec.values.length > 0) {
+      template = spec;
+    }
+    const source = spec.values.length > 0 ? spec : template;
+    const typeExpr = source?.type;
+    const declared = typeExpr ? evaluator.text(typeExpr) : undefined;
+    // Only ungrouped specs take the declaration's doc comment
+    const doc = (spec.doc ?? (!grouped ? decl.doc : undefined))?.text.trim();
+
+    spec.names.forEach((name, index) => {
+      const expr = source?.values[index];
+      let resolved = expr ? evaluator.evaluate(expr, iota) : undefined;
+      if (resolved && declared) {
+        resolved = evaluator.convert(resolved, declared);
+      }
+      if (resolved) {
+        evaluator.define(name.name, resolved);
+      }
+      const start = file.sourceMap.position(name.pos);
+      const end = file.sourceMap.position(spec.end);
+      const visibility = goVisibility(name.name);
+      constants.push({
+        name: name.name,
+        type: "constant",
+        startLine: start.line,
+        endLine: end.line,
+        startColumn: start.column,
+        endColumn: end.column,
+        isExported: visibility === Visibility.Exported,
+        isPrivate: visibility !== Visibility.Exported,
+        documentation: doc || undefined,
+        visibility,
+        declaredType:
+          declared ?? resolved?.typeName ?? resolved?.defaultType ?? "",
+        isTyped: Boolean(declared ?? resolved?.typeName),
+        expression: expr ? evaluator.text(expr) : undefined,
+        value: resolved ? formatValue(resolved.value) : undefined,
+        iota,
+        grouped,
+      });
+    });
+  });
+
+  return constants;
+}
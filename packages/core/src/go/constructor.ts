@@ -0,0 +1,272 @@
+import { TextEdit } from "../diff.js";
+import { Expr, FuncDecl, GenDecl, GoFile, TypeSpec } from "./ast.js";
+import { importName } from "./imports.js";
+import { GO_KEYWORDS } from "./lexer.js";
+import { parseGoFile } from "./parser.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Generate Constructor
+ * ====================
+ * Declares `NewT` (or `newT` for an unexported type) for a struct that has
+ * no constructor: it takes the chosen fields as parameters, in declaration
+ * order, and returns a `*T` with them set. Fields that are not parameters
+ * keep their zero value, except maps and channels, which are made, and
+ * slices, which start empty, so the struct is usable without nil checks.
+ * Types declared in the file as maps, slices or channels count as such.
+ *
+ * A struct already has a constructor when a function whose name starts with
+ * `New` or `new` returns `T` or `*T`, as `NewDataProcessor` does for
+ * `DataProcessor`. The refactor refuses such a struct unless asked to update
+ * the constructor, which replaces its signature and body and keeps its doc
+ * comment.
+ *
+ * Parameters are the field names with a lowercase first letter; a name that
+ * is a keyword, an import of the file or another parameter gets a `Value`
+ * suffix. By default every named field is a parameter; embedded fields only
+ * when chosen.
+ */
+
+export interface GenerateConstructorOptions {
+  /** Struct to construct */
+  type: string;
+  /**
+   * Fields taken as parameters, embedded fields by their type name
+   * (default: every named field)
+   */
+  params?: string[];
+  /** Rewrite the existing constructor instead of refusing */
+  update?: boolean;
+}
+
+export interface GenerateConstructorResult extends GoRefactorResult {
+  constructorName: string;
+  /** Fields set from parameters, in declaration order */
+  params: string[];
+  /** Maps, slices and channels initialized to empty values */
+  initialized: string[];
+  /** Whether an existing constructor was rewritten */
+  updated: boolean;
+}
+
+interface StructField {
+  name: string;
+  type: Expr;
+  embedded: boolean;
+}
+
+class ConstructorGenerator {
+  private readonly file: GoFile;
+  private readonly options: GenerateConstructorOptions;
+  private decl: GenDecl;
+  private spec: TypeSpec;
+  private readonly localTypes = new Map<string, TypeSpec>();
+
+  constructor(file: GoFile, options: GenerateConstructorOptions) {
+    this.file = file;
+    this.options = options;
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+      for (const spec of decl.specs) {
+        if (spec.kind !== "TypeSpec") continue;
+        this.localTypes.set(spec.name.name, spec);
+        if (spec.name.name === options.type) {
+          [this.decl, this.spec] = [decl, spec];
+        }
+      }
+    }
+  }
+
+  private get type(): string {
+    return this.options.type;
+  }
+
+  private text(node: Expr): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private fields(): StructField[] {
+    const { type } = this.spec;
+    if (type.kind !== "StructType") return [];
+    return type.fields.list.flatMap((field) =>
+      field.names.length === 0
+        ? [
+            {
+              name: baseTypeName(field.type).name,
+              type: field.type,
+              embedded: true,
+            },
+          ]
+        : field.names
+            .filter((ident) => ident.name !== "_")
+            .map((ident) => ({
+              name: ident.name,
+              type: field.type,
+              embedded: false,
+            })),
+    );
+  }
+
+  // Functions constructing the struct, by the `New` prefix and the result
+  private existing(): FuncDecl[] {
+    return this.file.decls.filter((decl): decl is FuncDecl => {
+      if (decl.kind !== "FuncDecl" || decl.recv) return false;
+      if (!/^[Nn]ew/.test(decl.name.name)) return false;
+      const result = decl.type.results?.list[0]?.type;
+      if (!result) return false;
+      const base = result.kind === "StarExpr" ? result.x : result;
+      return base.kind === "Ident" && base.name === this.type;
+    });
+  }
+
+  // The empty value a field gets when it is not a parameter
+  private emptyValue(type: Expr): string | undefined {
+    let underlying = type;
+    if (type.kind === "Ident") {
+      const spec = this.localTypes.get(type.name);
+      if (spec && !spec.typeParams) underlying = spec.type;
+    }
+    switch (underlying.kind) {
+      case "MapType":
+      case "ChanType":
+        return `make(${this.text(type)})`;
+      case "ArrayType":
+        return underlying.len ? undefined : `${this.text(type)}{}`;
+      default:
+        return undefined;
+    }
+  }
+
+  private parameterName(field: string, taken: Set<string>): string {
+    const name = field[0].toLowerCase() + field.slice(1);
+    return GO_KEYWORDS.has(name) || taken.has(name) ? `${name}Value` : name;
+  }
+
+  generate(): GenerateConstructorResult {
+    if (!this.spec) {
+      throw new GoRefactorError(`${this.type} is not declared in the file`);
+    }
+    if (this.spec.type.kind !== "StructType" || this.spec.isAlias) {
+      throw new GoRefactorError(`${this.type} is not a struct`);
+    }
+    if (this.spec.typeParams) {
+      throw new GoRefactorError(
+        `Generating a constructor for generic type ${this.type} is not supported`,
+      );
+    }
+    const fields = this.fields();
+    const chosen = this.options.params;
+    for (const name of chosen ?? []) {
+      if (!fields.some((field) => field.name === name)) {
+        throw new GoRefactorError(`${this.type} has no field ${name}`);
+      }
+    }
+    const isParam = (field: StructField) =>
+      chosen ? chosen.includes(field.name) : !field.embedded;
+
+    const name = isExportedName(this.type)
+      ? `New${this.type}`
+      : `new${this.type[0].toUpperCase()}${this.type.slice(1)}`;
+    const constructors = this.existing();
+    const current =
+      constructors.find((decl) => decl.name.name === name) ?? constructors[0];
+    if (current && !this.options.update) {
+      throw new GoRefactorError(
+        `${this.type} already has a constructor, ${current.name.name}; update it instead`,
+      );
+    }
+    const constructorName = current?.name.name ?? name;
+    const clash = this.file.decls.some((decl) =>
+      decl.kind === "FuncDecl"
+        ? !decl.recv && decl.name.name === constructorName && decl !== current
+        : decl.specs.some((spec) =>
+            spec.kind === "TypeSpec"
+              ? spec.name.name === constructorName
+              : spec.kind === "ValueSpec" &&
+                spec.names.some((ident) => ident.name === constructorName),
+          ),
+    );
+    if (clash) {
+      throw new GoRefactorError(
+        `${constructorName} is already declared in the file`,
+      );
+    }
+
+    // Imports, and the parameters named so far
+    const taken = new Set(this.file.imports.map(importName));
+    const params: string[] = [];
+    const initialized: string[] = [];
+    // Consecutive parameters of one type share it, as gofmt leaves them
+    const groups: { names: string[]; type: string }[] = [];
+    const values: [string, string][] = [];
+    for (const field of fields) {
+      const type = this.text(field.type);
+      if (isParam(field)) {
+        const param = this.parameterName(field.name, taken);
+        taken.add(param);
+        params.push(field.name);
+        values.push([field.name, param]);
+        if (groups.at(-1)?.type === type) groups.at(-1).names.push(param);
+        else groups.push({ names: [param], type });
+        continue;
+      }
+      const empty = this.emptyValue(field.type);
+      if (empty) {
+        initialized.push(field.name);
+        values.push([field.name, empty]);
+      }
+    }
+
+    // Keys padded so the values line up, as gofmt aligns them
+    const width = Math.max(0, ...values.map(([key]) => key.length + 1));
+    const elements = values.map(
+      ([key, value]) => `\t\t${`${key}:`.padEnd(width)} ${value},`,
+    );
+    const literal =
+      elements.length === 0
+        ? `&${this.type}{}`
+        : [`&${this.type}{`, ...elements, "\t}"].join("\n");
+    const signature = groups
+      .map((group) => `${group.names.join(", ")} ${group.type}`)
+      .join(", ");
+    const func = [
+      `func ${constructorName}(${signature}) *${this.type} {`,
+      `\treturn ${literal}`,
+      "}",
+    ].join("\n");
+
+    const edits: TextEdit[] = current
+      ? [{ start: current.pos, end: current.end, newText: func }]
+      : [
+          {
+            start: this.decl.end,
+            end: this.decl.end,
+            newText: `\n\n// ${constructorName} creates a new ${this.type}\n${func}`,
+          },
+        ];
+    const result = refactorResult(this.file, edits);
+    parseGoFile(result.source, this.file.filePath);
+    return {
+      ...result,
+      constructorName,
+      params,
+      initialized,
+      updated: current !== undefined,
+    };
+  }
+}
+
+/**
+ * Declare a constructor for a struct, or rewrite its existing one
+ */
+export function generateConstructor(
+  file: GoFile,
+  options: GenerateConstructorOptions,
+): GenerateConstructorResult {
+  return new ConstructorGenerator(file, options).generate();
+}
@@ -0,0 +1,178 @@
+import { Expr, FuncDecl, GoFile, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importName, importPath } from "./imports.js";
+import { groupGoPackages } from "./package.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Stored Contexts
+ * ===============
+ * A `context.Context` belongs to one call: stored in a struct, it outlives
+ * the call that made it, so the methods using it get a context they cannot
+ * tell was canceled long ago, and callers lose the means to give each call
+ * its own deadline. Go guidance is to pass the context to each method that
+ * needs it instead, as {@link addContextParameter} does for functions. This
+ * pass flags struct fields of type `context.Context`, named or embedded,
+ * and lists the methods that would need a context parameter if the field
+ * were removed: those reading the field through their receiver, and those
+ * calling such a method on their receiver.
+ *
+ * Some designs store a context on purpose, such as a worker owning the
+ * context of its lifetime, so the findings are informational: they are low
+ * severity and come without a fix.
+ */
+
+export interface GoStoredContextFinding extends GoFinding {
+  rule: "context-in-struct";
+  /** The struct's name */
+  type: string;
+  /** The field's name, `Context` when embedded */
+  field: string;
+  /**
+   * Methods that would need a context parameter without the field, in
+   * source order
+   */
+  methods: string[];
+}
+
+// Methods of an embedded context, which the struct's methods reach directly
+const CONTEXT_METHODS = new Set(["Deadline", "Done", "Err", "Value"]);
+
+interface Method {
+  decl: FuncDecl;
+  /** Fields and methods the method selects on its receiver */
+  selected: Set<string>;
+}
+
+function isContextType(file: GoFile, type: Expr): boolean {
+  const spec = file.imports.find(
+    (candidate) =>
+      importPath(candidate) === "context" &&
+      candidate.name?.name !== "_" &&
+      candidate.name?.name !== ".",
+  );
+  return (
+    spec !== undefined &&
+    type.kind === "SelectorExpr" &&
+    type.x.kind === "Ident" &&
+    type.x.name === importName(spec) &&
+    type.sel.name === "Context"
+  );
+}
+
+// Names selected on the receiver of a method, as in `c.ctx` or `c.load()`
+function receiverSelections(decl: FuncDecl): Set<string> {
+  const selected = new Set<string>();
+  if (!decl.body) return selected;
+  const scopes = resolveFunctionScopes(decl);
+  const receiver = scopes.variables.find((v) => v.kind === "receiver");
+  if (!receiver) return selected;
+  inspect(decl.body, (node) => {
+    if (
+      node.kind === "SelectorExpr" &&
+      node.x.kind === "Ident" &&
+      scopes.resolved.get(node.x) === receiver
+    ) {
+      selected.add(node.sel.name);
+    }
+  });
+  return selected;
+}
+
+// Methods of each receiver type, in source order
+function methodsByType(files: GoFile[]): Map<string, Method[]> {
+  const types = new Map<string, Method[]>();
+  for (const file of files) {
+    for (const decl of file.decls) {
+      const field = decl.kind === "FuncDecl" ? decl.recv?.list[0] : undefined;
+      if (!field) continue;
+      const type = baseTypeName(field.type).name;
+      if (!types.has(type)) types.set(type, []);
+      types.get(type).push({
+        decl: decl as FuncDecl,
+        selected: receiverSelections(decl as FuncDecl),
+      });
+    }
+  }
+  return types;
+}
+
+// Methods using a field, directly or through other methods of the type
+function methodsUsing(
+  methods: Method[],
+  field: string,
+  embedded: boolean,
+): string[] {
+  const using = new Set(
+    methods.filter(({ selected }) =>
+      [...selected].some(
+        (name) => name === field || (embedded && CONTEXT_METHODS.has(name)),
+      ),
+    ),
+  );
+  for (let grew = true; grew; ) {
+    grew = false;
+    const names = new Set([...using].map(({ decl }) => decl.name.name));
+    for (const method of methods) {
+      if (using.has(method)) continue;
+      if ([...method.selected].some((name) => names.has(name))) {
+        using.add(method);
+        grew = true;
+      }
+    }
+  }
+  return methods
+    .filter((method) => using.has(method))
+    .map(({ decl }) => decl.name.name);
+}
+
+/**
+ * Find struct fields holding a `context.Context`, and the methods that would
+ * need a context parameter instead
+ */
+export function findStoredContexts(files: GoFile[]): GoStoredContextFinding[] {
+  const findings: GoStoredContextFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const methods = methodsByType(group);
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+        for (const spec of decl.specs) {
+          if (spec.kind !== "TypeSpec" || spec.type.kind !== "StructType") {
+            continue;
+          }
+          const type = spec.name.name;
+          for (const field of spec.type.fields.list) {
+            if (!isContextType(file, field.type)) continue;
+            const embedded = field.names.length === 0;
+            const sites: Expr[] = embedded ? [field.type] : field.names;
+            for (const site of sites) {
+              const name = site.kind === "Ident" ? site.name : "Context";
+              const using = methodsUsing(
+                methods.get(type) ?? [],
+                name,
+                embedded,
+              );
+              const uses =
+                using.length > 0
+                  ? `pass a context to the methods using it instead: ${using.map((method) => `${type}.${method}`).join(", ")}`
+                  : `no method of ${type} reads it`;
+              findings.push({
+                rule: "context-in-struct",
+                severity: "low",
+                filePath: file.filePath,
+                ...file.sourceMap.position(site.pos),
+                message: `${type} stores a context.Context in field ${name}, which outlives the call it belongs to; ${uses}`,
+                type,
+                field: name,
+                methods: using,
+              });
+            }
+          }
+        }
+      }
+    }
+  }
+  return sortFindings(findings);
+}
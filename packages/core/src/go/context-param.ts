@@ -0,0 +1,599 @@
+import * as path from "path";
+import { TextEdit } from "../diff.js";
+import {
+  CallExpr,
+  Expr,
+  Field,
+  FuncDecl,
+  GoFile,
+  Node,
+  inspect,
+} from "./ast.js";
+import { STDLIB_INTERFACE_METHODS } from "./deadcode.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference } from "./infer.js";
+import { importEdits, importName, importPath } from "./imports.js";
+import { indexGoPackageNames } from "./naming.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Missing Context Parameters
+ * ==========================
+ * Flags functions that do I/O without taking a `context.Context` as their
+ * first parameter, which leaves callers no way to cancel them or set a
+ * deadline. Whether a function needs a context is decided by a predicate:
+ * the default one looks for calls of known blocking and network APIs, for
+ * contexts made up on the spot with `context.Background()` or
+ * `context.TODO()`, and optionally for names matching a pattern. Teams with
+ * other conventions pass their own predicate. Whatever the predicate,
+ * callers in the package of a function that needs or takes a context need
+ * one too.
+ *
+ * {@link addContextParameter} adds the parameter, passes it on to the calls
+ * in the body that can take it, and updates the calls in the package: a
+ * caller with a context or an HTTP request passes its context along, any
+ * other caller passes `context.TODO()` until it gets a context of its own.
+ */
+
+/**
+ * Functions and methods that block on I/O, as `path.Func` and
+ * `path.Type.Method`
+ */
+export const DEFAULT_BLOCKING_CALLS = [
+  "database/sql.Conn.Exec",
+  "database/sql.Conn.Query",
+  "database/sql.Conn.QueryRow",
+  "database/sql.DB.Begin",
+  "database/sql.DB.Exec",
+  "database/sql.DB.Ping",
+  "database/sql.DB.Prepare",
+  "database/sql.DB.Query",
+  "database/sql.DB.QueryRow",
+  "database/sql.Stmt.Exec",
+  "database/sql.Stmt.Query",
+  "database/sql.Stmt.QueryRow",
+  "database/sql.Tx.Exec",
+  "database/sql.Tx.Prepare",
+  "database/sql.Tx.Query",
+  "database/sql.Tx.QueryRow",
+  "net.Dial",
+  "net.DialTimeout",
+  "net.Listen",
+  "net/http.Client.Do",
+  "net/http.Client.Get",
+  "net/http.Client.Head",
+  "net/http.Client.Post",
+  "net/http.Client.PostForm",
+  "net/http.Get",
+  "net/http.Head",
+  "net/http.NewRequest",
+  "net/http.Post",
+  "net/http.PostForm",
+  "os/exec.Command",
+  "time.Sleep",
+];
+
+// Calls with a variant taking a context first, and the variant's name
+const CONTEXT_VARIANTS: Record<string, string> = {
+  "database/sql.Conn.Exec": "ExecContext",
+  "database/sql.Conn.Query": "QueryContext",
+  "database/sql.Conn.QueryRow": "QueryRowContext",
+  "database/sql.DB.Exec": "ExecContext",
+  "database/sql.DB.Ping": "PingContext",
+  "database/sql.DB.Prepare": "PrepareContext",
+  "database/sql.DB.Query": "QueryContext",
+  "database/sql.DB.QueryRow": "QueryRowContext",
+  "database/sql.Stmt.Exec": "ExecContext",
+  "database/sql.Stmt.Query": "QueryContext",
+  "database/sql.Stmt.QueryRow": "QueryRowContext",
+  "database/sql.Tx.Exec": "ExecContext",
+  "database/sql.Tx.Prepare": "PrepareContext",
+  "database/sql.Tx.Query": "QueryContext",
+  "database/sql.Tx.QueryRow": "QueryRowContext",
+  "net/http.NewRequest": "NewRequestWithContext",
+  "os/exec.Command": "CommandContext",
+};
+
+/**
+ * A call in a function body
+ */
+export interface GoContextCall {
+  /**
+   * What is called: `path.Func` or `path.Type.Method` for imported code, the
+   * name or `Type.Method` for code in the package, undefined when unknown
+   */
+  callee?: string;
+  /** The called expression as written, e.g. `http.Get` */
+  text: string;
+  node: CallExpr;
+}
+
+/**
+ * A function the predicate decides about
+ */
+export interface GoContextCandidate {
+  file: GoFile;
+  decl: FuncDecl;
+  /** `Type.Method` for methods, the bare name for functions */
+  function: string;
+  calls: GoContextCall[];
+}
+
+/**
+ * Why a function needs a context, or undefined when it does not
+ */
+export type GoContextPredicate = (
+  candidate: GoContextCandidate,
+) => string | undefined;
+
+export interface GoContextOptions {
+  /** Calls that need a context (default: {@link DEFAULT_BLOCKING_CALLS}) */
+  blockingCalls?: string[];
+  /** Function names that need a context, such as `/^(Fetch|Load)/` */
+  namePattern?: RegExp;
+  /** Replaces the default predicate built from the options above */
+  needsContext?: GoContextPredicate;
+}
+
+export interface GoMissingContextFinding extends GoFinding {
+  rule: "missing-context";
+  function: string;
+  /** Why the function needs a context */
+  reason: string;
+}
+
+export interface AddContextOptions {
+  /** The function: its name, or `Type.Method` for a method */
+  function: string;
+  /** Name of the new parameter (default: `ctx`) */
+  name?: string;
+}
+
+export interface GoContextCallSite {
+  filePath: string;
+  line: number;
+  column: number;
+  /** What the call now passes: the caller's context or `context.TODO()` */
+  argument: string;
+}
+
+export interface AddContextResult {
+  function: string;
+  /** Changed files */
+  files: GoRefactorResult[];
+  /** Calls of the function, now passing a context */
+  callSites: GoContextCallSite[];
+  /** Calls in the function's body the new parameter is passed to */
+  threaded: GoContextCallSite[];
+}
+
+interface Declared {
+  file: GoFile;
+  decl: FuncDecl;
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+/**
+ * The default predicate: calls of blocking APIs, contexts made up with
+ * `context.Background()` or `context.TODO()`, and names matching the
+ * pattern
+ */
+export function defaultContextPredicate(
+  options: GoContextOptions = {},
+): GoContextPredicate {
+  const blocking = new Set(options.blockingCalls ?? DEFAULT_BLOCKING_CALLS);
+  return (candidate) => {
+    for (const call of candidate.calls) {
+      if (call.callee && blocking.has(call.callee)) {
+        return `calls ${call.text}`;
+      }
+      if (
+        call.callee === "context.Background" ||
+        call.callee === "context.TODO"
+      ) {
+        return `makes its own context with ${call.text}()`;
+      }
+    }
+    if (options.namePattern?.test(candidate.decl.name.name)) {
+      return `its name matches ${options.namePattern}`;
+    }
+    return undefined;
+  };
+}
+
+// Name the file imports the context package under
+function contextName(file: GoFile): string | undefined {
+  const spec = file.imports.find(
+    (candidate) =>
+      importPath(candidate) === "context" &&
+      candidate.name?.name !== "_" &&
+      candidate.name?.name !== ".",
+  );
+  return spec && importName(spec);
+}
+
+function isContextType(file: GoFile, type: Expr): boolean {
+  const name = contextName(file);
+  return (
+    name !== undefined &&
+    type.kind === "SelectorExpr" &&
+    type.x.kind === "Ident" &&
+    type.x.name === name &&
+    type.sel.name === "Context"
+  );
+}
+
+// Name of the context parameter a function takes first, "" when unnamed
+function contextParam(file: GoFile, decl: FuncDecl): string | undefined {
+  const first: Field | undefined = decl.type.params.list[0];
+  if (!first || !isContextType(file, first.type)) return undefined;
+  return first.names[0]?.name ?? "";
+}
+
+// The context a function can pass on: its context parameter, or the
+// context of a request it handles
+function callerContextOf(file: GoFile, decl: FuncDecl): string | undefined {
+  const param = contextParam(file, decl);
+  if (param && param !== "_") return param;
+  for (const field of decl.type.params.list) {
+    const request = field.names.find((ident) => ident.name !== "_");
+    if (request && isRequestType(file, field.type)) {
+      return `${request.name}.Context()`;
+    }
+  }
+  return undefined;
+}
+
+function isRequestType(file: GoFile, type: Expr): boolean {
+  return file.source.slice(type.pos, type.end) === "*http.Request";
+}
+
+// Whether a function cannot take a context first, or already has one
+function isExempt(file: GoFile, decl: FuncDecl): boolean {
+  if (!decl.body || file.filePath.endsWith("_test.go")) return true;
+  if (!decl.recv && ["init", "main"].includes(decl.name.name)) return true;
+  // A request carries its own context
+  return decl.type.params.list.some(
+    (field) =>
+      isContextType(file, field.type) || isRequestType(file, field.type),
+  );
+}
+
+class PackageContexts {
+  private readonly files: GoFile[];
+  private readonly parents = new Map<Node, Node>();
+  readonly interfaceMethods = new Set<string>();
+  private readonly functions = new Map<string, Declared>();
+
+  constructor(files: GoFile[]) {
+    this.files = files;
+    const declared = new Set<string>();
+    for (const file of files) {
+      inspect(file, (node, parents) => {
+        if (parents.length > 0) this.parents.set(node, parents.at(-1));
+        if (node.kind === "InterfaceType") {
+          for (const field of node.methods.list) {
+            field.names.forEach((ident) =>
+              this.interfaceMethods.add(ident.name),
+            );
+          }
+        }
+      });
+      for (const decl of file.decls) {
+        if (decl.kind !== "FuncDecl") continue;
+        const name = qualifiedName(decl);
+        if (declared.has(name)) this.functions.delete(name);
+        else this.functions.set(name, { file, decl });
+        declared.add(name);
+      }
+    }
+  }
+
+  get parentMap(): Map<Node, Node> {
+    return this.parents;
+  }
+
+  find(name: string): Declared | undefined {
+    return this.functions.get(name);
+  }
+
+  /**
+   * The calls of a function body with their callees resolved where the
+   * imports, the package and local types tell
+   */
+  calls(
+    file: GoFile,
+    decl: FuncDecl,
+    scopes: GoFunctionScopes,
+  ): GoContextCall[] {
+    if (!decl.body) return [];
+    const imports = new Map<string, string>();
+    for (const spec of file.imports) {
+      imports.set(importName(spec), importPath(spec));
+    }
+    const types = new GoTypeInference(file, scopes);
+    const calls: GoContextCall[] = [];
+    inspect(decl.body, (node) => {
+      if (node.kind !== "CallExpr") return;
+      const { fun } = node;
+      const text = file.source.slice(fun.pos, fun.end);
+      let callee: string | undefined;
+      if (fun.kind === "Ident" && !scopes.resolved.has(fun)) {
+        callee = fun.name;
+      } else if (fun.kind === "SelectorExpr") {
+        const { x, sel } = fun;
+        if (
+          x.kind === "Ident" &&
+          !scopes.resolved.has(x) &&
+          imports.has(x.name)
+        ) {
+          callee = `${imports.get(x.name)}.${sel.name}`;
+        } else {
+          const type = types.typeOf(x)?.replace(/^\*/, "");
+          const [qualifier, name] = type?.includes(".")
+            ? type.split(".", 2)
+            : [undefined, type];
+          if (qualifier !== undefined && imports.has(qualifier)) {
+            callee = `${imports.get(qualifier)}.${name}.${sel.name}`;
+          } else if (name && /^\w+$/.test(name)) {
+            callee = `${name}.${sel.name}`;
+          }
+        }
+      }
+      calls.push({ callee, text, node });
+    });
+    return calls;
+  }
+
+  findings(options: GoContextOptions): GoMissingContextFinding[] {
+    const predicate = options.needsContext ?? defaultContextPredicate(options);
+    const candidates = new Map<string, GoContextCandidate>();
+    const reasons = new Map<string, string>();
+    const exempt = new Set<string>();
+    for (const [name, { file, decl }] of this.functions) {
+      const scopes = resolveFunctionScopes(decl);
+      const candidate = {
+        file,
+        decl,
+        function: name,
+        calls: this.calls(file, decl, scopes),
+      };
+      candidates.set(name, candidate);
+      if (isExempt(file, decl)) {
+        exempt.add(name);
+        continue;
+      }
+      const reason = predicate(candidate);
+      if (reason) reasons.set(name, reason);
+    }
+
+    // Calling a function that needs or takes a context needs one too
+    const needs = (name: string) => {
+      const callee = this.functions.get(name);
+      if (reasons.has(name)) return "needs a context";
+      if (callee && contextParam(callee.file, callee.decl) !== undefined) {
+        return "takes a context";
+      }
+      return undefined;
+    };
+    for (let changed = true; changed; ) {
+      changed = false;
+      for (const [name, candidate] of candidates) {
+        if (exempt.has(name) || reasons.has(name)) continue;
+        for (const call of candidate.calls) {
+          const why = call.callee && needs(call.callee);
+          if (why && call.callee !== name) {
+            reasons.set(name, `calls ${call.text}, which ${why}`);
+            changed = true;
+            break;
+          }
+        }
+      }
+    }
+
+    const findings: GoMissingContextFinding[] = [];
+    for (const [name, why] of reasons) {
+      const { file, decl } = this.functions.get(name);
+      findings.push({
+        rule: "missing-context",
+        severity: "medium",
+        filePath: file.filePath,
+        ...file.sourceMap.position(decl.name.pos),
+        message: `${name} ${why} but takes no context.Context; add ctx context.Context as its first parameter`,
+        fix: "ctx context.Context",
+        function: name,
+        reason: why,
+      });
+    }
+    return findings;
+  }
+}
+
+/**
+ * Find functions that need a context but do not take one first. Callers are
+ * followed within each package, so a function calling one that needs a
+ * context is reported too.
+ */
+export function findMissingContextParams(
+  files: GoFile[],
+  options: GoContextOptions = {},
+): GoMissingContextFinding[] {
+  return sortFindings(
+    groupGoPackages(files).flatMap((group) =>
+      new PackageContexts(group).findings(options),
+    ),
+  );
+}
+
+// The innermost function declaration holding a node
+function enclosingDecl(
+  parents: Map<Node, Node>,
+  node: Node,
+): FuncDecl | undefined {
+  for (let at = parents.get(node); at; at = parents.get(at)) {
+    if (at.kind === "FuncDecl") return at;
+  }
+  return undefined;
+}
+
+function isMadeUpContext(file: GoFile, expr: Expr): boolean {
+  const name = contextName(file);
+  return (
+    name !== undefined &&
+    expr.kind === "CallExpr" &&
+    expr.args.length === 0 &&
+    expr.fun.kind === "SelectorExpr" &&
+    expr.fun.x.kind === "Ident" &&
+    expr.fun.x.name === name &&
+    (expr.fun.sel.name === "Background" || expr.fun.sel.name === "TODO")
+  );
+}
+
+// Insert a first argument or parameter after an opening parenthesis
+function insertFirst(
+  lparen: number,
+  hasOthers: boolean,
+  text: string,
+): TextEdit {
+  return {
+    start: lparen + 1,
+    end: lparen + 1,
+    newText: hasOthers ? `${text}, ` : text,
+  };
+}
+
+/**
+ * Add a leading `ctx context.Context` parameter to a function, pass it to
+ * the calls in its body that can take it, and pass a context at every call
+ * in the package. Code outside the analyzed files that calls an exported
+ * function has to be updated by hand.
+ */
+export function addContextParameter(
+  files: GoFile[],
+  options: AddContextOptions,
+): AddContextResult {
+  const name = options.name ?? "ctx";
+  let found:
+    | (Declared & { group: GoFile[]; analyzer: PackageContexts })
+    | undefined;
+  for (const group of groupGoPackages(files)) {
+    const analyzer = new PackageContexts(group);
+    const match = analyzer.find(options.function);
+    if (match) found = { ...match, group, analyzer };
+  }
+  if (!found) {
+    throw new GoRefactorError(
+      `${options.function} is not declared in the analyzed files, or is declared more than once`,
+    );
+  }
+  const { file, decl, group, analyzer } = found;
+  if (contextParam(file, decl) !== undefined) {
+    throw new GoRefactorError(`${options.function} already takes a context`);
+  }
+  if (!decl.body) {
+    throw new GoRefactorError(`${options.function} has no body`);
+  }
+  if (
+    decl.recv &&
+    (analyzer.interfaceMethods.has(decl.name.name) ||
+      STDLIB_INTERFACE_METHODS.has(decl.name.name))
+  ) {
+    throw new GoRefactorError(
+      `${options.function} may implement an interface, which fixes its signature`,
+    );
+  }
+  if (!decl.recv && ["init", "main"].includes(decl.name.name)) {
+    throw new GoRefactorError(`${decl.name.name} is called by the runtime`);
+  }
+  const scopes = resolveFunctionScopes(decl);
+  if (scopes.variables.some((variable) => variable.name === name)) {
+    throw new GoRefactorError(
+      `${options.function} already declares ${name}; choose another name`,
+    );
+  }
+  const calls = indexGoPackageNames(group).calls(decl, analyzer.parentMap);
+  if (typeof calls === "string") {
+    throw new GoRefactorError(`Cannot add a context: ${calls}`);
+  }
+
+  const edits = new Map<GoFile, TextEdit[]>();
+  const editsFor = (target: GoFile) => {
+    if (!edits.has(target)) edits.set(target, []);
+    return edits.get(target);
+  };
+  const contextIn = (target: GoFile) => contextName(target) ?? "context";
+  const site = (target: GoFile, node: Node, argument: string) => ({
+    filePath: target.filePath,
+    ...target.sourceMap.position(node.pos),
+    argument,
+  });
+
+  editsFor(file).push(
+    insertFirst(
+      decl.type.params.opening,
+      decl.type.params.list.length > 0,
+      `${name} ${contextIn(file)}.Context`,
+    ),
+  );
+
+  const threaded: GoContextCallSite[] = [];
+  for (const call of analyzer.calls(file, decl, scopes)) {
+    const { node } = call;
+    const variant = call.callee && CONTEXT_VARIANTS[call.callee];
+    if (variant && node.fun.kind === "SelectorExpr") {
+      const { sel } = node.fun;
+      editsFor(file).push(
+        { start: sel.pos, end: sel.end, newText: variant },
+        insertFirst(node.lparen, node.args.length > 0, name),
+      );
+      threaded.push(site(file, node, name));
+      continue;
+    }
+    for (const arg of node.args) {
+      if (isMadeUpContext(file, arg)) {
+        editsFor(file).push({ start: arg.pos, end: arg.end, newText: name });
+        threaded.push(site(file, node, name));
+      }
+    }
+  }
+
+  const callSites: GoContextCallSite[] = [];
+  for (const { file: callFile, call } of calls) {
+    const caller = enclosingDecl(analyzer.parentMap, call);
+    const callerContext =
+      caller === decl ? name : caller && callerContextOf(callFile, caller);
+    const argument = callerContext ?? `${contextIn(callFile)}.TODO()`;
+    editsFor(callFile).push(
+      insertFirst(call.lparen, call.args.length > 0, argument),
+    );
+    callSites.push(site(callFile, call, argument));
+  }
+
+  for (const [target, targetEdits] of edits) {
+    if (contextName(target) === undefined) {
+      targetEdits.push(...importEdits(target, [{ path: "context" }]));
+    }
+  }
+
+  return {
+    function: options.function,
+    files: group
+      .filter((candidate) => edits.has(candidate))
+      .map((candidate) => refactorResult(candidate, edits.get(candidate))),
+    callSites,
+    threaded,
+  };
+}
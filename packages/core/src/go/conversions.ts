@@ -0,0 +1,344 @@
+import { TextEdit } from "../diff.js";
+import { CallExpr, Expr, GoFile, Ident, Node, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importName } from "./imports.js";
+import { GoTypeInference } from "./infer.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+
+/**
+ * Redundant Conversions
+ * =====================
+ * Flags conversions whose operand already has the target type, such as
+ * `string(s)` for a string `s` or `Celsius(c)` for a `Celsius` value, which
+ * refactors leave behind when a variable's type changes. The operand's type
+ * comes from type inference over the package, and a conversion is only
+ * reported when that type is written exactly like the target: converting
+ * between distinct named types, or between a defined type and its
+ * underlying type, changes the type even when the representation is the
+ * same, so `Celsius(f)` for a `Fahrenheit` `f` and `int(n)` for a `Count` are
+ * left alone. So are conversions of untyped constants, where `int64(1)` or
+ * `float64(limit)` give the constant its type, and conversions whose
+ * operand's type is not known.
+ *
+ * The fix replaces the conversion with its operand, parenthesized where the
+ * operand would otherwise bind differently.
+ */
+
+export interface GoRedundantConversionFinding extends GoFinding {
+  rule: "redundant-conversion";
+  /** The target type, as written */
+  type: string;
+}
+
+export interface RemoveConversionsOptions {
+  /**
+   * Line of a conversion reported by {@link findRedundantConversions}
+   * (default: every one in the file)
+   */
+  line?: number;
+}
+
+export interface RemoveConversionsResult extends GoRefactorResult {
+  /** Positions of the removed conversions */
+  removed: { line: number; column: number }[];
+}
+
+const BASIC_TYPES = new Set([
+  "any",
+  "bool",
+  "byte",
+  "complex64",
+  "complex128",
+  "error",
+  "float32",
+  "float64",
+  "int",
+  "int8",
+  "int16",
+  "int32",
+  "int64",
+  "rune",
+  "string",
+  "uint",
+  "uint8",
+  "uint16",
+  "uint32",
+  "uint64",
+  "uintptr",
+]);
+
+// Parents in which an operand that is not a primary expression needs parens
+const TIGHT_PARENTS = new Set([
+  "BinaryExpr",
+  "UnaryExpr",
+  "StarExpr",
+  "SelectorExpr",
+  "IndexExpr",
+  "SliceExpr",
+  "TypeAssertExpr",
+]);
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+// Names a package declares at the top level
+interface PackageNames {
+  types: Set<string>;
+  /** Constants declared without a type */
+  untyped: Set<string>;
+  /** Functions, variables and typed constants */
+  others: Set<string>;
+}
+
+function packageNames(group: GoFile[]): PackageNames {
+  const names: PackageNames = {
+    types: new Set<string>(),
+    untyped: new Set<string>(),
+    others: new Set<string>(),
+  };
+  for (const file of group) {
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl") {
+        if (!decl.recv) names.others.add(decl.name.name);
+        continue;
+      }
+      for (const spec of decl.specs) {
+        if (spec.kind === "TypeSpec") {
+          names.types.add(spec.name.name);
+        } else if (spec.kind === "ValueSpec") {
+          const untyped = decl.tok === "const" && !spec.type;
+          for (const ident of spec.names) {
+            (untyped ? names.untyped : names.others).add(ident.name);
+          }
+        }
+      }
+    }
+  }
+  return names;
+}
+
+interface Conversion {
+  call: CallExpr;
+  type: string;
+  /** The operand, past any redundant conversions nested in it */
+  operand: Expr;
+  replacement: string;
+}
+
+class ConversionAnalyzer {
+  private readonly file: GoFile;
+  private readonly names: PackageNames;
+  private readonly imports = new Set<string>();
+  private readonly parents = new Map<Node, Node>();
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+  // Declaring identifiers of the function's untyped local constants
+  private untypedLocals = new Set<Ident>();
+
+  constructor(file: GoFile, names: PackageNames) {
+    this.file = file;
+    this.names = names;
+    for (const spec of file.imports) this.imports.add(importName(spec));
+    inspect(file, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+    });
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private isLocal(ident: Ident): boolean {
+    return this.scopes.resolved.has(ident);
+  }
+
+  // The type a call converts to, when it is a conversion
+  private targetType(call: CallExpr): string | undefined {
+    const fun = unparen(call.fun);
+    switch (fun.kind) {
+      case "Ident": {
+        if (this.isLocal(fun)) return undefined;
+        const { name } = fun;
+        if (this.names.types.has(name)) return name;
+        const shadowed = this.names.others.has(name);
+        return BASIC_TYPES.has(name) && !shadowed ? name : undefined;
+      }
+      case "SelectorExpr": {
+        // A type of an imported package, not a method of a value
+        const { x } = fun;
+        if (x.kind !== "Ident" || !this.imports.has(x.name)) return undefined;
+        if (this.isLocal(x) || !/^[A-Z]/.test(fun.sel.name)) return undefined;
+        return this.text(fun);
+      }
+      case "ArrayType":
+      case "MapType":
+      case "ChanType":
+      case "StarExpr":
+      case "FuncType":
+      case "InterfaceType":
+        return this.text(fun);
+      default:
+        return undefined;
+    }
+  }
+
+  // Whether an expression is an untyped constant the conversion gives a type
+  private isUntypedConstant(expr: Expr): boolean {
+    switch (expr.kind) {
+      case "BasicLit":
+        return true;
+      case "Ident": {
+        const variable = this.scopes.resolved.get(expr);
+        if (variable) return this.untypedLocals.has(variable.ident);
+        return (
+          ["true", "false", "iota", "nil"].includes(expr.name) ||
+          this.names.untyped.has(expr.name)
+        );
+      }
+      case "ParenExpr":
+        return this.isUntypedConstant(expr.x);
+      case "UnaryExpr":
+        return (
+          expr.op !== "&" && expr.op !== "<-" && this.isUntypedConstant(expr.x)
+        );
+      case "BinaryExpr":
+        return this.isUntypedConstant(expr.x) && this.isUntypedConstant(expr.y);
+      default:
+        return false;
+    }
+  }
+
+  private conversion(call: CallExpr): Conversion | undefined {
+    if (call.args.length !== 1 || call.ellipsis >= 0) return undefined;
+    const type = this.targetType(call);
+    if (type === undefined) return undefined;
+    const [operand] = call.args;
+    if (this.isUntypedConstant(operand)) return undefined;
+    const operandType = this.types.typeOf(operand);
+    const normalize = (text: string) => text.replace(/\s+/g, "");
+    if (operandType === undefined) return undefined;
+    if (normalize(operandType) !== normalize(type)) return undefined;
+
+    let inner = unparen(operand);
+    const nested = inner.kind === "CallExpr" && this.conversion(inner);
+    if (nested) inner = nested.operand;
+    let replacement = this.text(inner);
+    const parent = this.parents.get(call);
+    const primary = !["BinaryExpr", "UnaryExpr", "StarExpr"].includes(
+      inner.kind,
+    );
+    const tight =
+      (parent && TIGHT_PARENTS.has(parent.kind)) ||
+      (parent?.kind === "CallExpr" && parent.fun === call);
+    if (!primary && tight) replacement = `(${replacement})`;
+    return { call, type, operand: inner, replacement };
+  }
+
+  analyze(): Conversion[] {
+    const conversions: Conversion[] = [];
+    for (const decl of this.file.decls) {
+      if (decl.kind !== "FuncDecl" || !decl.body) continue;
+      this.scopes = resolveFunctionScopes(decl);
+      this.types = new GoTypeInference(this.file, this.scopes);
+      this.untypedLocals = new Set();
+      inspect(decl.body, (node) => {
+        if (node.kind !== "DeclStmt" || node.decl.tok !== "const") return;
+        for (const spec of node.decl.specs) {
+          if (spec.kind === "ValueSpec" && !spec.type) {
+            spec.names.forEach((ident) => this.untypedLocals.add(ident));
+          }
+        }
+      });
+      inspect(decl.body, (node) => {
+        if (node.kind !== "CallExpr") return;
+        const conversion = this.conversion(node);
+        if (conversion) conversions.push(conversion);
+      });
+    }
+    return conversions;
+  }
+
+  findings(): GoRedundantConversionFinding[] {
+    return this.analyze().map((conversion) => ({
+      rule: "redundant-conversion",
+      severity: "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(conversion.call.pos),
+      message: `${this.text(conversion.call)} converts a value that is already of type ${conversion.type}`,
+      fix: conversion.replacement,
+      type: conversion.type,
+    }));
+  }
+
+  rewrite(options: RemoveConversionsOptions): RemoveConversionsResult {
+    const { sourceMap } = this.file;
+    const matches = this.analyze().filter(
+      (match) =>
+        options.line === undefined ||
+        sourceMap.line(match.call.pos) === options.line,
+    );
+    if (options.line !== undefined && matches.length === 0) {
+      throw new GoRefactorError(
+        `Line ${options.line} has no conversion to the operand's own type`,
+      );
+    }
+    // A conversion nested in another goes with the outer one
+    const outermost = matches.filter(
+      (match) =>
+        !matches.some(
+          (other) =>
+            other !== match &&
+            other.call.pos <= match.call.pos &&
+            match.call.end <= other.call.end,
+        ),
+    );
+    const edits: TextEdit[] = outermost.map((match) => ({
+      start: match.call.pos,
+      end: match.call.end,
+      newText: match.replacement,
+    }));
+    return {
+      ...refactorResult(this.file, edits),
+      removed: outermost.map((match) => sourceMap.position(match.call.pos)),
+    };
+  }
+}
+
+/**
+ * Find conversions to the type their operand already has
+ */
+export function findRedundantConversions(
+  files: GoFile[],
+): GoRedundantConversionFinding[] {
+  const findings: GoRedundantConversionFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const names = packageNames(group);
+    for (const file of group) {
+      findings.push(...new ConversionAnalyzer(file, names).findings());
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Remove conversions to the type their operand already has. Types declared
+ * in other files of the package are found through `files`.
+ */
+export function removeRedundantConversions(
+  file: GoFile,
+  options: RemoveConversionsOptions = {},
+  files: GoFile[] = [file],
+): RemoveConversionsResult {
+  const group = groupGoPackages([
+    file,
+    ...files.filter((other) => other !== file),
+  ]);
+  return new ConversionAnalyzer(file, packageNames(group[0])).rewrite(options);
+}
@@ -0,0 +1,272 @@
+import * as fs from "fs";
+import { compareCodePoints } from "./findings.js";
+import { GoFileSymbols } from "./symbols.js";
+
+/**
+ * Go Test Coverage
+ * ================
+ * Reads the profiles written by `go test -coverprofile` and maps their blocks
+ * onto function symbols. A profile names files by import path
+ * (`example.com/mod/pkg/file.go`), so each analyzed file is matched to the
+ * profile file sharing the longest run of trailing path segments.
+ *
+ * Coverage is reported as the ratio of covered lines to lines inside any
+ * block of the function, so a partially tested function shows how much of it
+ * a refactor could break unnoticed.
+ */
+
+/** Functions at or above this percentage are considered safe to refactor */
+export const SAFE_COVERAGE_PCT = 80;
+
+export type GoCoverMode = "set" | "count" | "atomic";
+
+/**
+ * One basic block of a profile, with 1-based lines and columns
+ */
+export interface GoCoverBlock {
+  fileName: string;
+  startLine: number;
+  startColumn: number;
+  endLine: number;
+  endColumn: number;
+  statements: number;
+  /** Times the block ran; 0 or 1 in `set` mode */
+  count: number;
+}
+
+export interface GoCoverProfile {
+  mode: GoCoverMode;
+  /** Blocks per file name as written in the profile */
+  files: Map<string, GoCoverBlock[]>;
+}
+
+export type GoCoverageRisk = "safe" | "partial" | "high-risk";
+
+export interface GoSymbolCoverage {
+  coveredLines: number;
+  totalLines: number;
+  /** Covered share of the lines, as a percentage with one decimal */
+  coveragePct: number;
+  risk: GoCoverageRisk;
+}
+
+/**
+ * Coverage of one function or method
+ */
+export interface GoFunctionCoverage extends GoSymbolCoverage {
+  filePath: string;
+  line: number;
+  /** `Type.Method` for methods, the bare name for functions */
+  name: string;
+}
+
+/**
+ * Error raised for a malformed coverage profile
+ */
+export class GoCoverProfileError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoCoverProfileError";
+  }
+}
+
+const BLOCK_LINE = /^(.+):(\d+)\.(\d+),(\d+)\.(\d+) (\d+) (\d+)$/;
+
+/**
+ * Parse a coverage profile. Profiles concatenated from several runs are
+ * accepted as long as they share a mode; blocks reported more than once are
+ * merged the way `go tool cover` merges them.
+ */
+export function parseCoverProfile(content: string): GoCoverProfile {
+  let mode: GoCoverMode | undefined;
+  const blocks = new Map<string, GoCoverBlock>();
+
+  content.split(/\r?\n/).forEach((line, index) => {
+    if (line.trim() === "") return;
+    if (line.startsWith("mode: ")) {
+      const value = line.slice("mode: ".length).trim();
+      if (value !== "set" && value !== "count" && value !== "atomic") {
+        throw new GoCoverProfileError(
+          `line ${index + 1}: unknown mode ${value}`,
+        );
+      }
+      if (mode !== undefined && mode !== value) {
+        throw new GoCoverProfileError(
+          `line ${index + 1}: mode ${value} does not match mode ${mode}`,
+        );
+      }
+      mode = value;
+      return;
+    }
+    if (mode === undefined) {
+      throw new GoCoverProfileError(
+        `line ${index + 1}: expected a mode line first`,
+      );
+    }
+    const match = BLOCK_LINE.exec(line);
+    if (!match) {
+      throw new GoCoverProfileError(
+        `line ${index + 1}: malformed block ${JSON.stringify(line)}`,
+      );
+    }
+    const [, fileName, ...numbers] = match;
+    const [startLine, startColumn, endLine, endColumn, statements, count] =
+      numbers.map(Number);
+    const key = `${fileName}:${startLine}.${startColumn},${endLine}.${endColumn}`;
+    const existing = blocks.get(key);
+    if (existing) {
+      existing.count =
+        mode === "set"
+          ? Math.max(existing.count, count)
+          : existing.count + count;
+      return;
+    }
+    blocks.set(key, {
+      fileName,
+      startLine,
+      startColumn,
+      endLine,
+      endColumn,
+      statements,
+      count,
+    });
+  });
+
+  if (mode === undefined) {
+    throw new GoCoverProfileError("missing mode line");
+  }
+  const files = new Map<string, GoCoverBlock[]>();
+  for (const block of blocks.values()) {
+    if (!files.has(block.fileName)) files.set(block.fileName, []);
+    files.get(block.fileName).push(block);
+  }
+  return { mode, files };
+}
+
+/**
+ * Read and parse a coverage profile from disk
+ */
+export async function readCoverProfile(
+  profilePath: string,
+): Promise<GoCoverProfile> {
+  return parseCoverProfile(await fs.promises.readFile(profilePath, "utf-8"));
+}
+
+function segments(filePath: string): string[] {
+  return filePath.split(/[\\/]+/).filter((segment) => segment !== "");
+}
+
+// The profile's blocks for an analyzed file, matched by trailing segments
+function blocksFor(
+  profile: GoCoverProfile,
+  filePath: string,
+): GoCoverBlock[] | undefined {
+  const target = segments(filePath);
+  let best: GoCoverBlock[] | undefined;
+  let bestLength = 0;
+  for (const [fileName, blocks] of profile.files) {
+    const candidate = segments(fileName);
+    let length = 0;
+    while (
+      length < target.length &&
+      length < candidate.length &&
+      target[target.length - 1 - length] ===
+        candidate[candidate.length - 1 - length]
+    ) {
+      length++;
+    }
+    if (length > bestLength) {
+      best = blocks;
+      bestLength = length;
+    }
+  }
+  return best;
+}
+
+function risk(coveragePct: number): GoCoverageRisk {
+  if (coveragePct >= SAFE_COVERAGE_PCT) return "safe";
+  return coveragePct === 0 ? "high-risk" : "partial";
+}
+
+/**
+ * Coverage of the lines between a symbol's start and end. Returns undefined
+ * when the profile has no blocks for the file or none inside the symbol.
+ */
+export function symbolCoverage(
+  profile: GoCoverProfile,
+  filePath: string,
+  symbol: { startLine: number; endLine: number },
+): GoSymbolCoverage | undefined {
+  const blocks = blocksFor(profile, filePath);
+  if (!blocks) return undefined;
+
+  const covered = new Set<number>();
+  const lines = new Set<number>();
+  for (const block of blocks) {
+    if (block.startLine < symbol.startLine) continue;
+    if (block.startLine > symbol.endLine) continue;
+    // A block ending at column 1 stops before that line's text
+    const lastLine =
+      block.endColumn <= 1 && block.endLine > block.startLine
+        ? block.endLine - 1
+        : block.endLine;
+    for (let line = block.startLine; line <= lastLine; line++) {
+      lines.add(line);
+      if (block.count > 0) covered.add(line);
+    }
+  }
+  if (lines.size === 0) return undefined;
+
+  const coveragePct = Math.round((covered.size / lines.size) * 1000) / 10;
+  return {
+    coveredLines: covered.size,
+    totalLines: lines.size,
+    coveragePct,
+    risk: risk(coveragePct),
+  };
+}
+
+/**
+ * Coverage of every function and method the profile covers, least covered
+ * first so the riskiest refactor targets lead
+ */
+export function coverageReport(
+  files: GoFileSymbols[],
+  profile: GoCoverProfile,
+): GoFunctionCoverage[] {
+  const report: GoFunctionCoverage[] = [];
+  for (const file of files) {
+    for (const symbol of [...file.functions, ...file.methods]) {
+      const coverage = symbolCoverage(profile, file.filePath, symbol);
+      if (!coverage) continue;
+      report.push({
+        filePath: file.filePath,
+        line: symbol.startLine,
+        name: symbol.qualifiedName,
+        ...coverage,
+      });
+    }
+  }
+  return report.sort(
+    (a, b) =>
+      a.coveragePct - b.coveragePct ||
+      compareCodePoints(a.filePath, b.filePath) ||
+      a.line - b.line,
+  );
+}
+
+/**
+ * Record the coverage percentage on the function and method symbols of the
+ * given files. Symbols the profile does not cover are left unchanged.
+ */
+export function annotateCoverage(
+  files: GoFileSymbols[],
+  profile: GoCoverProfile,
+): void {
+  for (const file of files) {
+    for (const symbol of [...file.functions, ...file.methods]) {
+      const coverage = symbolCoverage(profile, file.filePath, symbol);
+      if (coverage) symbol.coveragePct = coverage.coveragePct;
+    }
+  }
+}
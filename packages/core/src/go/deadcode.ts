@@ -0,0 +1,185 @@
+import { GoFile } from "./ast.js";
+import {
+  buildGoCallGraph,
+  GoCallGraph,
+  GoCallGraphFile,
+  GoCallGraphNode,
+} from "./callgraph.js";
+import { compareCodePoints } from "./findings.js";
+import { Visibility } from "./symbols.js";
+
+/**
+ * A function or method with no callers, reported as a refactor candidate
+ */
+export interface GoDeadFunction {
+  filePath: string;
+  line: number;
+  packageName: string;
+  name: string;
+  qualifiedName: string;
+  /** Set when only tests use it, which keeps it compiled but not needed */
+  testOnly?: boolean;
+}
+
+/**
+ * Options for dead code detection
+ */
+export interface GoDeadCodeOptions {
+  /**
+   * Treat the analyzed files as the whole program, so exported symbols with
+   * no callers are reported too. Off by default, since exported symbols may
+   * be used by packages that were not analyzed.
+   */
+  wholeProgram?: boolean;
+  /**
+   * Packages the analyzed files import, as {@link loadGoDependencies} loads
+   * them. Their calls and interfaces keep the functions they reach alive;
+   * their own functions are never reported.
+   */
+  dependencies?: GoCallGraphFile[];
+}
+
+// Methods that satisfy common standard library interfaces, which are invoked
+// by fmt, sort, encoding/json and friends rather than by the program itself
+export const STDLIB_INTERFACE_METHODS = new Set([
+  "As",
+  "Close",
+  "Error",
+  "Format",
+  "GoString",
+  "Is",
+  "Len",
+  "Less",
+  "MarshalJSON",
+  "MarshalText",
+  "Read",
+  "ServeHTTP",
+  "String",
+  "Swap",
+  "UnmarshalJSON",
+  "UnmarshalText",
+  "Unwrap",
+  "Write",
+]);
+
+const TEST_ENTRY_POINT = /^(Test|Benchmark|Example|Fuzz)([^a-z]|$)/;
+
+/**
+ * Whether a function is run by `go test`: a test, benchmark, example or fuzz
+ * target, or TestMain, declared in a `_test.go` file
+ */
+export function isTestFunction(node: GoCallGraphNode): boolean {
+  const { name, receiver } = node.symbol;
+  return (
+    !receiver &&
+    node.filePath.endsWith("_test.go") &&
+    (name === "TestMain" || TEST_ENTRY_POINT.test(name))
+  );
+}
+
+function isEntryPoint(node: GoCallGraphNode): boolean {
+  const { name, receiver } = node.symbol;
+  if (receiver) {
+    return false;
+  }
+  if (name === "init" || (name === "main" && node.packageName === "main")) {
+    return true;
+  }
+  return isTestFunction(node);
+}
+
+// Functions used from outside what the call graph sees
+function assumedUsed(
+  node: GoCallGraphNode,
+  graph: GoCallGraph,
+  options: GoDeadCodeOptions,
+): boolean {
+  const { symbol } = node;
+  if (symbol.visibility === Visibility.Exported && !options.wholeProgram) {
+    return true;
+  }
+  if (isEntryPoint(node)) {
+    return true;
+  }
+  return (
+    symbol.receiver !== undefined &&
+    (graph.interfaceMethods.has(symbol.name) ||
+      STDLIB_INTERFACE_METHODS.has(symbol.name))
+  );
+}
+
+// Recursion alone does not keep a function alive
+function callersOf(graph: GoCallGraph, id: string): string[] {
+  return [...(graph.callers.get(id) ?? [])].filter((caller) => caller !== id);
+}
+
+// Functions outside test files whose callers are all tests, test helpers or
+// other such functions. A cycle only reachable from itself is not included.
+function testOnlyFunctions(
+  graph: GoCallGraph,
+  options: GoDeadCodeOptions,
+): Set<string> {
+  const testOnly = new Set<string>();
+  const usedByTests = (id: string) =>
+    testOnly.has(id) || graph.nodes.get(id)?.filePath.endsWith("_test.go");
+  for (let changed = true; changed; ) {
+    changed = false;
+    for (const node of graph.nodes.values()) {
+      if (
+        testOnly.has(node.id) ||
+        node.filePath.endsWith("_test.go") ||
+        assumedUsed(node, graph, options)
+      ) {
+        continue;
+      }
+      const callers = callersOf(graph, node.id);
+      if (callers.length > 0 && callers.every(usedByTests)) {
+        testOnly.add(node.id);
+        changed = true;
+      }
+    }
+  }
+  return testOnly;
+}
+
+/**
+ * Find functions and methods that nothing calls or references. Unexported
+ * symbols are reported when no function in their package uses them; exported
+ * ones only in whole-program mode. Methods whose name is declared by an
+ * interface are assumed reachable through interface satisfaction. Functions
+ * only tests use are reported too, marked `testOnly`.
+ */
+export function findDeadFunctions(
+  files: GoFile[],
+  options: GoDeadCodeOptions = {},
+  graph: GoCallGraph = buildGoCallGraph([
+    ...files,
+    ...(options.dependencies ?? []),
+  ]),
+): GoDeadFunction[] {
+  const dead: GoDeadFunction[] = [];
+  const testOnly = testOnlyFunctions(graph, options);
+  const analyzed = new Set(files.map((file) => file.filePath));
+
+  for (const node of graph.nodes.values()) {
+    const { symbol } = node;
+    if (!analyzed.has(node.filePath) || assumedUsed(node, graph, options)) {
+      continue;
+    }
+    if (callersOf(graph, node.id).length > 0 && !testOnly.has(node.id)) {
+      continue;
+    }
+    dead.push({
+      filePath: node.filePath,
+      line: symbol.startLine,
+      packageName: node.packageName,
+      name: symbol.name,
+      qualifiedName: symbol.qualifiedName,
+      ...(testOnly.has(node.id) && { testOnly: true }),
+    });
+  }
+
+  return dead.sort(
+    (a, b) => compareCodePoints(a.filePath, b.filePath) || a.line - b.line,
+  );
+}
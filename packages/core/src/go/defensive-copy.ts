@@ -0,0 +1,215 @@
+import {
+  Expr,
+  FuncDecl,
+  GoFile,
+  Node,
+  ReturnStmt,
+  TypeSpec,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GO_KEYWORDS } from "./lexer.js";
+import { groupGoPackages } from "./package.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Defensive Copies
+ * ================
+ * A method handing out a map or slice field of its receiver as it is gives
+ * callers the receiver's own storage: whatever they write to the result, the
+ * receiver sees, and a receiver guarded by a lock loses that protection the
+ * moment the result escapes it. This pass flags exported methods returning
+ * a map or slice field, or a slice of one, straight from the receiver, with
+ * a fix returning a copy instead:
+ *
+ * - a slice as `append([]T(nil), s.items...)`, which stays nil for a nil
+ *   field and copies the elements otherwise
+ * - a map copied key by key into a map made with the field's length
+ *
+ * Copies are shallow: elements that are pointers, maps or slices are still
+ * shared. Fields of a named map or slice type declared in the package count
+ * too. Methods documented as returning something callers must not modify,
+ * or as read-only, are left alone, and so are freshly built results, like
+ * the slice `ProcessData` builds, and results derived from a field, like the
+ * length `GetCacheSize` returns.
+ */
+
+export interface GoExposedFieldFinding extends GoFinding {
+  rule: "exposed-mutable-field";
+  type: string;
+  method: string;
+  field: string;
+  kind: "map" | "slice";
+}
+
+const READ_ONLY = new RegExp(
+  [
+    "\\b(must|should) not (be )?(modif|mutat|chang)",
+    "\\bdo not (modify|mutate|change)",
+    "\\bread-only\\b",
+  ].join("|"),
+  "i",
+);
+
+// `(x)` reaches `x`
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+class DefensiveCopyAnalyzer {
+  private readonly group: GoFile[];
+  /** Types declared in the package, by name */
+  private readonly types = new Map<string, TypeSpec>();
+
+  constructor(group: GoFile[]) {
+    this.group = group;
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+        for (const spec of decl.specs) {
+          if (spec.kind === "TypeSpec") this.types.set(spec.name.name, spec);
+        }
+      }
+    }
+  }
+
+  // Whether values of a type share their storage when copied
+  private kind(type: Expr): "map" | "slice" | undefined {
+    let underlying = type;
+    if (type.kind === "Ident") {
+      const spec = this.types.get(type.name);
+      if (spec && !spec.typeParams) underlying = spec.type;
+    }
+    if (underlying.kind === "MapType") return "map";
+    if (underlying.kind === "ArrayType" && !underlying.len) return "slice";
+    return undefined;
+  }
+
+  // Map and slice fields of a struct declared in the package, by name
+  private fields(typeName: string): Map<string, Expr> {
+    const fields = new Map<string, Expr>();
+    const spec = this.types.get(typeName);
+    if (spec?.type.kind !== "StructType") return fields;
+    for (const field of spec.type.fields.list) {
+      if (!this.kind(field.type)) continue;
+      for (const name of field.names) fields.set(name.name, field.type);
+    }
+    return fields;
+  }
+
+  private findingsIn(file: GoFile, decl: FuncDecl): GoExposedFieldFinding[] {
+    const recv = decl.recv?.list[0];
+    const receiver = recv?.names[0]?.name;
+    if (!receiver || receiver === "_" || !decl.body) return [];
+    if (!isExportedName(decl.name.name)) return [];
+    if (READ_ONLY.test(decl.doc?.text ?? "")) return [];
+    const type = baseTypeName(recv.type).name;
+    const fields = this.fields(type);
+    if (fields.size === 0) return [];
+
+    const scopes = resolveFunctionScopes(decl);
+    const findings: GoExposedFieldFinding[] = [];
+    inspect(decl.body, (node) => {
+      // Returns of function literals belong to them
+      if (node.kind === "FuncLit") return false;
+      if (node.kind !== "ReturnStmt") return;
+      node.results.forEach((result, index) => {
+        let expr = unparen(result);
+        if (expr.kind === "SliceExpr") expr = unparen(expr.x);
+        if (expr.kind !== "SelectorExpr" || expr.x.kind !== "Ident") return;
+        if (scopes.resolved.get(expr.x)?.kind !== "receiver") return;
+        const fieldType = fields.get(expr.sel.name);
+        if (!fieldType) return;
+        const kind = this.kind(fieldType);
+        const text = file.source.slice(result.pos, result.end);
+        findings.push({
+          rule: "exposed-mutable-field",
+          severity: "low",
+          filePath: file.filePath,
+          ...file.sourceMap.position(result.pos),
+          message: `${type}.${decl.name.name} returns ${text} without copying it, so callers can change the ${kind} ${type} holds; return a copy`,
+          fix: this.copy(file, node, index, fieldType, scopes.variables),
+          type,
+          method: decl.name.name,
+          field: expr.sel.name,
+          kind,
+        });
+      });
+    });
+    return findings;
+  }
+
+  // The return statement rewritten to return a copy of one result
+  private copy(
+    file: GoFile,
+    stmt: ReturnStmt,
+    index: number,
+    fieldType: Expr,
+    variables: { name: string }[],
+  ): string {
+    const text = (node: Expr) => file.source.slice(node.pos, node.end);
+    const result = stmt.results[index];
+    const typeText = text(fieldType);
+    const results = (replacement: string) =>
+      `return ${stmt.results
+        .map((other, at) => (at === index ? replacement : text(other)))
+        .join(", ")}`;
+    if (this.kind(fieldType) === "slice") {
+      return results(`append(${typeText}(nil), ${text(result)}...)`);
+    }
+
+    const line = file.sourceMap.line(stmt.pos);
+    const start = file.sourceMap.lineStart(line);
+    const indent = /^[ \t]*/.exec(file.source.slice(start, stmt.pos))[0];
+    // A name nothing in the function, the return statement or the loop
+    // uses; field names after a dot are not in scope
+    const taken = new Set(["k", "v", ...variables.map(({ name }) => name)]);
+    const collect = (node: Node) => {
+      if (node.kind === "SelectorExpr") {
+        inspect(node.x, collect);
+        return false;
+      }
+      if (node.kind === "Ident") taken.add(node.name);
+    };
+    inspect(stmt, collect);
+    const field = unparen(result);
+    const base = field.kind === "SelectorExpr" ? field.sel.name : "copied";
+    let name = base;
+    for (let n = 1; taken.has(name) || GO_KEYWORDS.has(name); n++) {
+      name = n === 1 ? `${base}Copy` : `${base}Copy${n}`;
+    }
+    const source = text(result);
+    return [
+      `${name} := make(${typeText}, len(${source}))`,
+      `${indent}for k, v := range ${source} {`,
+      `${indent}\t${name}[k] = v`,
+      `${indent}}`,
+      `${indent}${results(name)}`,
+    ].join("\n");
+  }
+
+  analyze(): GoExposedFieldFinding[] {
+    return this.group.flatMap((file) =>
+      file.decls.flatMap((decl) =>
+        decl.kind === "FuncDecl" && decl.recv
+          ? this.findingsIn(file, decl)
+          : [],
+      ),
+    );
+  }
+}
+
+/**
+ * Exported methods returning a map or slice field of their receiver without
+ * copying it
+ */
+export function findExposedMutableFields(
+  files: GoFile[],
+): GoExposedFieldFinding[] {
+  return sortFindings(
+    groupGoPackages(files).flatMap((group) =>
+      new DefensiveCopyAnalyzer(group).analyze(),
+    ),
+  );
+}
@@ -0,0 +1,361 @@
+import { createHash } from "crypto";
+import * as fs from "fs";
+import * as os from "os";
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GO_ANALYZER_VERSION } from "./cache.js";
+import { GoCallGraphFile, goCallGraphFile } from "./callgraph.js";
+import { GoBuildContext, GoPackageError, loadGoPackage } from "./package.js";
+
+/**
+ * Dependencies
+ * ============
+ * Loads the packages the analyzed files import from other modules, enough
+ * to resolve calls into them: their functions, the methods their interfaces
+ * declare and their calls, without anything else of their files. Given to
+ * the call graph, calls into a dependency stop being external, and a method
+ * a dependency calls through an interface counts as used, so exported
+ * functions can be reported dead with confidence.
+ *
+ * Packages are found the way the go command finds them. The module a
+ * package belongs to is the longest `require` of `go.mod` its import path
+ * starts with, subject to `replace` directives. When `vendor/modules.txt`
+ * exists the package is read from `vendor/`; a replacement by a directory
+ * is read from that directory; anything else from the module cache, at
+ * `$GOMODCACHE`, `$GOPATH/pkg/mod` or `~/go/pkg/mod` with upper case letters
+ * escaped as `!` and the lower case letter. Standard library packages and
+ * packages of the main module are not loaded, nor are the packages the
+ * dependencies import in turn.
+ *
+ * Loading is opt-in: parsing dependencies costs more than the analysis of
+ * most packages. With a cache directory, what a package contributes to the
+ * call graph is stored per package and reused while the names, sizes and
+ * modification times of its `.go` files stay the same.
+ */
+
+export interface GoModRequirement {
+  path: string;
+  version: string;
+}
+
+export interface GoModReplacement {
+  path: string;
+  /** Version the replacement is limited to, when given */
+  version?: string;
+  /** Module path, or a directory when it starts with `./`, `../` or `/` */
+  newPath: string;
+  newVersion?: string;
+}
+
+export interface GoModFile {
+  module: string;
+  require: GoModRequirement[];
+  replace: GoModReplacement[];
+}
+
+export type GoDependencySource = "vendor" | "replace" | "module-cache";
+
+export interface GoDependencyPackage {
+  importPath: string;
+  module: string;
+  version?: string;
+  directory: string;
+  source: GoDependencySource;
+}
+
+export interface GoDependencyOptions {
+  /** Module cache to read (default: from the environment) */
+  moduleCache?: string;
+  /** Directory the loaded metadata is stored in between runs */
+  cacheDirectory?: string;
+  /** Build context the dependencies' files are selected for */
+  context?: Partial<GoBuildContext>;
+}
+
+export interface GoDependencies {
+  packages: GoDependencyPackage[];
+  /** What each file of the packages contributes to the call graph */
+  files: GoCallGraphFile[];
+  /** Imports of modules `go.mod` requires whose directory was not found */
+  missing: string[];
+  /** Packages whose metadata came from the cache */
+  cached: string[];
+}
+
+export class GoModError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoModError";
+  }
+}
+
+function unquote(token: string): string {
+  return /^["`]/.test(token) ? token.slice(1, -1) : token;
+}
+
+/**
+ * Parse the `module`, `require` and `replace` directives of a `go.mod` file
+ */
+export function parseGoMod(content: string): GoModFile {
+  const mod: GoModFile = { module: "", require: [], replace: [] };
+  let block: string | undefined;
+  const directive = (verb: string, args: string[], line: number) => {
+    const fail = () => {
+      throw new GoModError(`go.mod:${line}: malformed ${verb} directive`);
+    };
+    switch (verb) {
+      case "module":
+        if (args.length !== 1) fail();
+        mod.module = unquote(args[0]);
+        break;
+      case "require":
+        if (args.length !== 2) fail();
+        mod.require.push({ path: unquote(args[0]), version: args[1] });
+        break;
+      case "replace": {
+        const arrow = args.indexOf("=>");
+        if (arrow < 1 || arrow > 2 || args.length - arrow - 1 < 1) fail();
+        const [newPath, newVersion] = args.slice(arrow + 1);
+        mod.replace.push({
+          path: unquote(args[0]),
+          ...(arrow === 2 && { version: args[1] }),
+          newPath: unquote(newPath),
+          ...(newVersion !== undefined && { newVersion }),
+        });
+        break;
+      }
+    }
+  };
+
+  content.split("\n").forEach((text, index) => {
+    const tokens = text.replace(/\/\/.*$/, "").trim().split(/\s+/);
+    if (tokens[0] === "") return;
+    if (block !== undefined) {
+      if (tokens[0] === ")") block = undefined;
+      else directive(block, tokens, index + 1);
+    } else if (tokens[1] === "(") {
+      block = tokens[0];
+    } else {
+      directive(tokens[0], tokens.slice(1), index + 1);
+    }
+  });
+  if (mod.module === "") throw new GoModError("go.mod has no module directive");
+  return mod;
+}
+
+/**
+ * The module cache directory the go command uses
+ */
+export function goModuleCache(env: NodeJS.ProcessEnv = process.env): string {
+  if (env.GOMODCACHE) return env.GOMODCACHE;
+  const gopath = env.GOPATH?.split(path.delimiter)[0];
+  return path.join(gopath || path.join(os.homedir(), "go"), "pkg", "mod");
+}
+
+/**
+ * A module path or version as the module cache spells it, with each upper
+ * case letter written as `!` and its lower case
+ */
+export function escapeModulePath(modulePath: string): string {
+  return modulePath.replace(/[A-Z]/g, (letter) => `!${letter.toLowerCase()}`);
+}
+
+function isLocalPath(modulePath: string): boolean {
+  return /^(\.\.?|)\//.test(modulePath) || path.isAbsolute(modulePath);
+}
+
+// Whether an import path names a package of the module
+function within(importPath: string, modulePath: string): boolean {
+  return importPath === modulePath || importPath.startsWith(`${modulePath}/`);
+}
+
+function isStandardLibrary(importPath: string): boolean {
+  return !importPath.split("/")[0].includes(".");
+}
+
+/**
+ * Where the packages the files import from other modules are, by the
+ * `go.mod` at the root
+ */
+export async function resolveGoDependencies(
+  root: string,
+  files: GoFile[],
+  options: Pick<GoDependencyOptions, "moduleCache"> = {},
+): Promise<{ packages: GoDependencyPackage[]; missing: string[] }> {
+  let content: string;
+  try {
+    content = await fs.promises.readFile(path.join(root, "go.mod"), "utf-8");
+  } catch {
+    throw new GoModError(`No go.mod in ${root}`);
+  }
+  const mod = parseGoMod(content);
+  const vendored = fs.existsSync(path.join(root, "vendor", "modules.txt"));
+  const moduleCache = options.moduleCache ?? goModuleCache();
+
+  const imports = new Set<string>();
+  for (const file of files) {
+    for (const spec of file.imports) imports.add(spec.path.value.slice(1, -1));
+  }
+  const packages: GoDependencyPackage[] = [];
+  const missing: string[] = [];
+  for (const importPath of [...imports].sort()) {
+    if (isStandardLibrary(importPath) || within(importPath, mod.module)) {
+      continue;
+    }
+    const requirement = mod.require
+      .filter((candidate) => within(importPath, candidate.path))
+      .sort((a, b) => b.path.length - a.path.length)[0];
+    if (!requirement) continue;
+    const { version } = requirement;
+    const subpath = importPath.slice(requirement.path.length);
+    const replacement = mod.replace.find(
+      (candidate) =>
+        candidate.path === requirement.path &&
+        (candidate.version === undefined || candidate.version === version),
+    );
+
+    let found: GoDependencyPackage;
+    if (vendored) {
+      found = {
+        importPath,
+        module: requirement.path,
+        version,
+        directory: path.join(root, "vendor", importPath),
+        source: "vendor",
+      };
+    } else if (replacement && isLocalPath(replacement.newPath)) {
+      found = {
+        importPath,
+        module: requirement.path,
+        directory: path.join(path.resolve(root, replacement.newPath), subpath),
+        source: "replace",
+      };
+    } else {
+      const module = replacement?.newPath ?? requirement.path;
+      const moduleVersion = replacement?.newVersion ?? version;
+      found = {
+        importPath,
+        module: requirement.path,
+        version: moduleVersion,
+        directory: path.join(
+          moduleCache,
+          `${escapeModulePath(module)}@${escapeModulePath(moduleVersion)}`,
+          subpath,
+        ),
+        source: "module-cache",
+      };
+    }
+    if (fs.existsSync(found.directory)) packages.push(found);
+    else missing.push(importPath);
+  }
+  return { packages, missing };
+}
+
+interface CacheEntry {
+  analyzerVersion: string;
+  directory: string;
+  stamp: string;
+  files: GoCallGraphFile[];
+}
+
+// Names, sizes and modification times of a directory's Go files
+async function directoryStamp(directory: string): Promise<string> {
+  const entries = await fs.promises.readdir(directory, { withFileTypes: true });
+  const stamps: string[] = [];
+  for (const entry of entries) {
+    if (!entry.isFile() || !entry.name.endsWith(".go")) continue;
+    const stat = await fs.promises.stat(path.join(directory, entry.name));
+    stamps.push(`${entry.name}:${stat.size}:${stat.mtimeMs}`);
+  }
+  return stamps.sort().join("\n");
+}
+
+function entryPath(cacheDirectory: string, directory: string): string {
+  const name = createHash("sha256").update(directory).digest("hex");
+  return path.join(cacheDirectory, `${name}.json`);
+}
+
+async function readEntry(
+  cacheDirectory: string,
+  directory: string,
+  stamp: string,
+): Promise<GoCallGraphFile[] | undefined> {
+  let entry: CacheEntry;
+  try {
+    entry = JSON.parse(
+      await fs.promises.readFile(entryPath(cacheDirectory, directory), "utf-8"),
+    );
+  } catch {
+    // Missing or corrupt entries are treated as misses
+    return undefined;
+  }
+  const current =
+    entry.analyzerVersion === GO_ANALYZER_VERSION &&
+    entry.directory === directory &&
+    entry.stamp === stamp;
+  return current ? entry.files : undefined;
+}
+
+async function writeEntry(
+  cacheDirectory: string,
+  entry: Omit<CacheEntry, "analyzerVersion">,
+): Promise<void> {
+  await fs.promises.mkdir(cacheDirectory, { recursive: true });
+  const target = entryPath(cacheDirectory, entry.directory);
+  const temporary = `${target}.${process.pid}.tmp`;
+  const content: CacheEntry = {
+    analyzerVersion: GO_ANALYZER_VERSION,
+    ...entry,
+  };
+  await fs.promises.writeFile(temporary, JSON.stringify(content), "utf-8");
+  await fs.promises.rename(temporary, target);
+}
+
+/**
+ * Load the packages the files import from other modules. Throws a
+ * {@link GoModError} when the root has no readable `go.mod`.
+ */
+export async function loadGoDependencies(
+  root: string,
+  files: GoFile[],
+  options: GoDependencyOptions = {},
+): Promise<GoDependencies> {
+  const { packages, missing } = await resolveGoDependencies(
+    root,
+    files,
+    options,
+  );
+  const { cacheDirectory } = options;
+  const loaded: GoCallGraphFile[] = [];
+  const cached: string[] = [];
+  for (const pkg of packages) {
+    const stamp = cacheDirectory && (await directoryStamp(pkg.directory));
+    const hit =
+      cacheDirectory && (await readEntry(cacheDirectory, pkg.directory, stamp));
+    if (hit) {
+      loaded.push(...hit);
+      cached.push(pkg.importPath);
+      continue;
+    }
+    let contributed: GoCallGraphFile[] = [];
+    try {
+      const { files: parsed } = await loadGoPackage(pkg.directory, {
+        context: options.context,
+        includeTests: false,
+      });
+      contributed = parsed.map(goCallGraphFile);
+    } catch (error) {
+      // A directory without buildable files contributes nothing
+      if (!(error instanceof GoPackageError)) throw error;
+    }
+    loaded.push(...contributed);
+    if (cacheDirectory) {
+      await writeEntry(cacheDirectory, {
+        directory: pkg.directory,
+        stamp,
+        files: contributed,
+      });
+    }
+  }
+  return { packages, files: loaded, missing, cached };
+}
@@ -0,0 +1,298 @@
+import * as fs from "fs";
+import * as path from "path";
+import { GoOverlay, GoOverlayEntries, goOverlay } from "./overlay.js";
+
+/**
+ * Go File Discovery
+ * =================
+ * Finds the Go files under a repository root the way Git and the Go tools
+ * see them: paths ignored by `.gitignore` files (at any depth) and
+ * `.git/info/exclude` are skipped, as are `vendor/` and `testdata/`
+ * directories and files or directories whose names start with `.` or `_`.
+ * Include and exclude globs narrow the result further. Symbolic links are
+ * followed only while they stay inside the root, and each directory is
+ * visited once, so link loops terminate. Files of an overlay are listed as
+ * if they were on disk.
+ */
+
+export interface GoDiscoverOptions {
+  /** Globs relative to the root selecting files (default: `**\/*.go`) */
+  include?: string[];
+  /** Globs relative to the root for files and directories to skip */
+  exclude?: string[];
+  /** Honor `.gitignore` files and `.git/info/exclude` (default: true) */
+  respectGitignore?: boolean;
+  /** Descend into `vendor/` directories (default: false) */
+  includeVendor?: boolean;
+  /** Descend into `testdata/` directories (default: false) */
+  includeTestdata?: boolean;
+  /** Keep `_test.go` files (default: true) */
+  includeTests?: boolean;
+  /**
+   * Files to report alongside those on disk, for example unsaved editor
+   * buffers; only files in directories the walk visits are added
+   */
+  overlay?: GoOverlay | GoOverlayEntries;
+}
+
+interface IgnoreRule {
+  /** Directory of the ignore file, relative to the root */
+  base: string;
+  pattern: RegExp;
+  negated: boolean;
+  directoryOnly: boolean;
+}
+
+/**
+ * Translate a glob to a regular expression over `/`-separated paths. `*` and
+ * `?` never match `/`; `**` matches any number of directories.
+ */
+function globSource(glob: string, braces: boolean): string {
+  let source = "";
+  for (let i = 0; i < glob.length; i++) {
+    const ch = glob[i];
+    if (ch === "*" && glob[i + 1] === "*") {
+      const atStart = i === 0 || glob[i - 1] === "/";
+      if (atStart && glob[i + 2] === "/") {
+        source += "(?:.*/)?";
+        i += 2;
+      } else if (atStart && i + 2 === glob.length) {
+        source += ".*";
+        i += 1;
+      } else {
+        source += "[^/]*";
+        i += 1;
+      }
+    } else if (ch === "*") {
+      source += "[^/]*";
+    } else if (ch === "?") {
+      source += "[^/]";
+    } else if (ch === "[") {
+      const close = glob.indexOf("]", i + 2);
+      if (close < 0) {
+        source += "\\[";
+        continue;
+      }
+      let body = glob.slice(i + 1, close);
+      if (body.startsWith("!")) body = "^" + body.slice(1);
+      source += `[${body.replace(/\\/g, "\\\\")}]`;
+      i = close;
+    } else if (braces && ch === "{") {
+      const close = glob.indexOf("}", i);
+      if (close < 0) {
+        source += "\\{";
+        continue;
+      }
+      const options = glob
+        .slice(i + 1, close)
+        .split(",")
+        .map((option) => globSource(option, false));
+      source += `(?:${options.join("|")})`;
+      i = close;
+    } else if (ch === "\\" && i + 1 < glob.length) {
+      source += glob[++i].replace(/[.*+?^${}()|[\]\\/]/g, "\\$&");
+    } else {
+      source += ch.replace(/[.*+?^${}()|[\]\\/]/g, "\\$&");
+    }
+  }
+  return source;
+}
+
+function globPattern(glob: string): RegExp {
+  return new RegExp(`^${globSource(glob.replace(/^\.\//, ""), true)}$`);
+}
+
+/**
+ * Parse the rules of one ignore file, in file order
+ */
+function parseIgnoreFile(content: string, base: string): IgnoreRule[] {
+  const rules: IgnoreRule[] = [];
+  for (const raw of content.split(/\r?\n/)) {
+    // Trailing spaces are ignored unless escaped
+    let line = raw.replace(/(?<!\\) +$/, "");
+    if (line === "" || line.startsWith("#")) continue;
+    const negated = line.startsWith("!");
+    if (negated) line = line.slice(1);
+    if (line.startsWith("\\#") || line.startsWith("\\!")) line = line.slice(1);
+    const directoryOnly = line.endsWith("/");
+    if (directoryOnly) line = line.slice(0, -1);
+    if (line === "") continue;
+
+    // A slash other than a trailing one anchors the pattern to its file
+    const anchored = line.includes("/");
+    const glob = line.replace(/^\//, "");
+    const prefix = anchored ? "^" : "^(?:.*/)?";
+    rules.push({
+      base,
+      pattern: new RegExp(`${prefix}${globSource(glob, false)}$`),
+      negated,
+      directoryOnly,
+    });
+  }
+  return rules;
+}
+
+/**
+ * Whether the last rule matching a path ignores it
+ */
+function isIgnored(rules: IgnoreRule[], relPath: string, isDir: boolean) {
+  let ignored = false;
+  for (const rule of rules) {
+    if (rule.directoryOnly && !isDir) continue;
+    if (rule.base !== "" && !relPath.startsWith(`${rule.base}/`)) continue;
+    const local =
+      rule.base === "" ? relPath : relPath.slice(rule.base.length + 1);
+    if (rule.pattern.test(local)) ignored = !rule.negated;
+  }
+  return ignored;
+}
+
+type DirectoryEntry = Pick<
+  fs.Dirent,
+  "name" | "isFile" | "isDirectory" | "isSymbolicLink"
+>;
+
+// An overlaid file missing from its directory on disk
+function overlayEntry(name: string): DirectoryEntry {
+  return {
+    name,
+    isFile: () => true,
+    isDirectory: () => false,
+    isSymbolicLink: () => false,
+  };
+}
+
+async function readIfExists(filePath: string): Promise<string | undefined> {
+  try {
+    return await fs.promises.readFile(filePath, "utf-8");
+  } catch {
+    return undefined;
+  }
+}
+
+function isInside(root: string, target: string): boolean {
+  return target === root || target.startsWith(root + path.sep);
+}
+
+/**
+ * List the Go files to analyze under a root directory, as absolute paths
+ * ordered by their path relative to the root
+ */
+export async function discoverGoFiles(
+  root: string,
+  options: GoDiscoverOptions = {},
+): Promise<string[]> {
+  const rootPath = path.resolve(root);
+  const rootReal = await fs.promises.realpath(rootPath);
+  const include = (options.include ?? ["**/*.go"]).map(globPattern);
+  const exclude = (options.exclude ?? []).map(globPattern);
+  const gitignore = options.respectGitignore ?? true;
+  const overlay = goOverlay(options.overlay);
+
+  const visited = new Set<string>([rootReal]);
+  const found: { rel: string; real: string; link: boolean }[] = [];
+
+  const skipDirectory = (name: string) =>
+    name.startsWith(".") ||
+    name.startsWith("_") ||
+    (name === "vendor" && !options.includeVendor) ||
+    (name === "testdata" && !options.includeTestdata);
+
+  const walk = async (dir: string, rel: string, inherited: IgnoreRule[]) => {
+    let rules = inherited;
+    if (gitignore) {
+      const content = await readIfExists(path.join(dir, ".gitignore"));
+      if (content !== undefined) {
+        rules = [...rules, ...parseIgnoreFile(content, rel)];
+      }
+    }
+
+    const entries: DirectoryEntry[] = await fs.promises.readdir(dir, {
+      withFileTypes: true,
+    });
+    const names = new Set(entries.map((entry) => entry.name));
+    const overlaid = new Set<string>();
+    for (const name of overlay.fileNames(dir)) {
+      if (!names.has(name)) {
+        entries.push(overlayEntry(name));
+        overlaid.add(name);
+      }
+    }
+    entries.sort((a, b) => (a.name < b.name ? -1 : a.name > b.name ? 1 : 0));
+    for (const entry of entries) {
+      const childPath = path.join(dir, entry.name);
+      const childRel = rel === "" ? entry.name : `${rel}/${entry.name}`;
+      let isDir = entry.isDirectory();
+      let isFile = entry.isFile();
+      let real = childPath;
+
+      if (entry.isSymbolicLink()) {
+        try {
+          real = await fs.promises.realpath(childPath);
+          const stat = await fs.promises.stat(real);
+          isDir = stat.isDirectory();
+          isFile = stat.isFile();
+        } catch {
+          // Dangling link
+          continue;
+        }
+        if (!isInside(rootReal, real)) continue;
+      }
+
+      if (isDir) {
+        if (skipDirectory(entry.name)) continue;
+        if (isIgnored(rules, childRel, true)) continue;
+        if (exclude.some((pattern) => pattern.test(childRel))) continue;
+        if (!entry.isSymbolicLink()) {
+          real = await fs.promises.realpath(childPath);
+        }
+        // A directory reached twice is a link loop or an alias
+        if (visited.has(real)) continue;
+        visited.add(real);
+        await walk(childPath, childRel, rules);
+        continue;
+      }
+
+      if (!isFile || !entry.name.endsWith(".go")) continue;
+      if (entry.name.startsWith(".") || entry.name.startsWith("_")) continue;
+      if (options.includeTests === false && entry.name.endsWith("_test.go")) {
+        continue;
+      }
+      if (isIgnored(rules, childRel, false)) continue;
+      if (exclude.some((pattern) => pattern.test(childRel))) continue;
+      if (!include.some((pattern) => pattern.test(childRel))) continue;
+      if (overlaid.has(entry.name)) {
+        // Not on disk, so there is no link to resolve
+        real = path.join(await fs.promises.realpath(dir), entry.name);
+      } else if (!entry.isSymbolicLink()) {
+        real = await fs.promises.realpath(childPath);
+      }
+      found.push({ rel: childRel, real, link: entry.isSymbolicLink() });
+    }
+  };
+
+  let rootRules: IgnoreRule[] = [];
+  if (gitignore) {
+    const infoExclude = await readIfExists(
+      path.join(rootPath, ".git", "info", "exclude"),
+    );
+    if (infoExclude !== undefined) {
+      rootRules = parseIgnoreFile(infoExclude, "");
+    }
+  }
+  await walk(rootPath, "", rootRules);
+
+  // A file reachable through links as well is reported once, preferably
+  // under its own path
+  const byReal = new Map<string, { rel: string; link: boolean }>();
+  for (const file of found) {
+    const existing = byReal.get(file.real);
+    if (!existing || (existing.link && !file.link)) {
+      byReal.set(file.real, file);
+    }
+  }
+  return [...byReal.values()]
+    .map((file) => file.rel)
+    .sort((a, b) => (a < b ? -1 : a > b ? 1 : 0))
+    .map((rel) => path.join(rootPath, ...rel.split("/")));
+}
@@ -0,0 +1,190 @@
+import * as path from "path";
+import { CommentGroup, GoFile } from "./ast.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Documentation Coverage
+ * ======================
+ * The share of the exported API with a doc comment: every exported function,
+ * type and constant, and every exported method of an exported type. A symbol
+ * counts as documented when its doc comment begins with its name, as Go
+ * convention and `go doc` expect (`// Open opens the named file`); a type's
+ * comment may start with an article (`// A Reader reads ...`). A comment that
+ * starts differently is reported as misnamed and does not count.
+ *
+ * Members of a grouped declaration without a comment of their own are
+ * documented by the group's comment, which `go doc` shows above the whole
+ * group, whatever its wording: in a `const ( ... )` block of related values
+ * the group comment is the documentation. A member's own comment must still
+ * begin with its name. Test files are left out.
+ */
+
+export type GoDocKind = "func" | "method" | "type" | "const";
+
+export type GoDocStatus =
+  /** Its own doc comment begins with its name */
+  | "documented"
+  /** Documented by the comment of its declaration group */
+  | "group"
+  /** A doc comment that does not begin with its name */
+  | "misnamed"
+  | "missing";
+
+export interface GoDocSymbol {
+  /** Package directory relative to the root, `.` for the root itself */
+  package: string;
+  kind: GoDocKind;
+  /** `Type.Method` for methods */
+  name: string;
+  status: GoDocStatus;
+  filePath: string;
+  line: number;
+}
+
+export interface GoDocCoverage {
+  /** Exported symbols */
+  total: number;
+  /** Symbols documented by their own or their group's comment */
+  documented: number;
+  /** `documented` as a percentage of `total`; 100 without symbols */
+  percent: number;
+  /** Symbols that are missing documentation or have a misnamed comment */
+  undocumented: GoDocSymbol[];
+  /** Every symbol, sorted by package, file and line */
+  symbols: GoDocSymbol[];
+}
+
+export interface GoDocCoverageOptions {
+  /** Directory package paths are relative to (default: the cwd) */
+  root?: string;
+}
+
+function escapeRegExp(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+}
+
+function ownStatus(
+  name: string,
+  kind: GoDocKind,
+  doc: CommentGroup | undefined,
+): GoDocStatus {
+  const text = doc?.text.trim() ?? "";
+  if (text === "") return "missing";
+  const article = kind === "type" ? "(?:(?:A|An|The)\\s+)?" : "";
+  const pattern = new RegExp(`^${article}${escapeRegExp(name)}\\b`);
+  return pattern.test(text) ? "documented" : "misnamed";
+}
+
+function fileSymbols(file: GoFile, pkg: string): GoDocSymbol[] {
+  const symbols: GoDocSymbol[] = [];
+  const add = (
+    kind: GoDocKind,
+    name: string,
+    status: GoDocStatus,
+    pos: number,
+  ) =>
+    symbols.push({
+      package: pkg,
+      kind,
+      name,
+      status,
+      filePath: file.filePath,
+      line: file.sourceMap.line(pos),
+    });
+
+  for (const decl of file.decls) {
+    if (decl.kind === "FuncDecl") {
+      const { name } = decl.name;
+      if (!isExportedName(name)) continue;
+      const status = ownStatus(name, "func", decl.doc);
+      if (!decl.recv) {
+        add("func", name, status, decl.pos);
+        continue;
+      }
+      const owner = baseTypeName(decl.recv.list[0].type).name;
+      if (isExportedName(owner)) {
+        add("method", `${owner}.${name}`, status, decl.pos);
+      }
+      continue;
+    }
+    if (decl.tok !== "type" && decl.tok !== "const") continue;
+    const grouped = decl.lparen >= 0 && Boolean(decl.doc?.text.trim());
+    const kind = decl.tok;
+    for (const spec of decl.specs) {
+      if (spec.kind === "ImportSpec") continue;
+      const names = spec.kind === "TypeSpec" ? [spec.name] : spec.names;
+      // An ungrouped declaration's comment is the spec's own
+      const own = decl.lparen >= 0 ? spec.doc : (spec.doc ?? decl.doc);
+      for (const ident of names) {
+        if (!isExportedName(ident.name)) continue;
+        let status = ownStatus(ident.name, kind, own);
+        if (status === "missing" && grouped) status = "group";
+        add(kind, ident.name, status, ident.pos);
+      }
+    }
+  }
+  return symbols;
+}
+
+/**
+ * Measure how much of the exported API of the packages among `files` is
+ * documented
+ */
+export function goDocCoverage(
+  files: GoFile[],
+  options: GoDocCoverageOptions = {},
+): GoDocCoverage {
+  const root = options.root ?? process.cwd();
+  const symbols = files
+    .filter((file) => !file.filePath.endsWith("_test.go"))
+    .flatMap((file) => {
+      const directory = path.relative(root, path.dirname(file.filePath));
+      const pkg = directory.split(path.sep).join("/") || ".";
+      return fileSymbols(file, pkg);
+    })
+    .sort(
+      (a, b) =>
+        (a.package < b.package ? -1 : a.package > b.package ? 1 : 0) ||
+        (a.filePath < b.filePath ? -1 : a.filePath > b.filePath ? 1 : 0) ||
+        a.line - b.line,
+    );
+  const undocumented = symbols.filter(
+    (symbol) => symbol.status === "missing" || symbol.status === "misnamed",
+  );
+  const documented = symbols.length - undocumented.length;
+  return {
+    total: symbols.length,
+    documented,
+    percent: symbols.length === 0 ? 100 : (100 * documented) / symbols.length,
+    undocumented,
+    symbols,
+  };
+}
+
+/**
+ * A summary line with the percentage, followed by one line per undocumented
+ * symbol
+ */
+export function formatGoDocCoverage(
+  coverage: GoDocCoverage,
+  options: GoDocCoverageOptions = {},
+): string {
+  const root = options.root ?? process.cwd();
+  const lines = [
+    `Documentation coverage: ${coverage.percent.toFixed(1)}% (${coverage.documented} of ${coverage.total} exported symbols)`,
+  ];
+  for (const symbol of coverage.undocumented) {
+    const file = path
+      .relative(root, symbol.filePath)
+      .split(path.sep)
+      .join("/");
+    const problem =
+      symbol.status === "misnamed"
+        ? `doc comment does not begin with ${symbol.name.split(".").pop()}`
+        : "no doc comment";
+    lines.push(
+      `${file}:${symbol.line}: ${symbol.kind} ${symbol.name}: ${problem}`,
+    );
+  }
+  return lines.join("\n") + "\n";
+}
@@ -0,0 +1,338 @@
+import { FuncDecl, GoFile, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importName } from "./imports.js";
+import { GoComment } from "./lexer.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Doc Comment Drift
+ * =================
+ * Doc comments are written against one signature and are rarely updated when
+ * a refactor renames the function or drops a parameter. This pass checks the
+ * doc comment of every exported function, and of every exported method of an
+ * exported type, outside test files:
+ *
+ * - stale-doc-name: the comment does not begin with the function's name, as
+ *   Go convention and `go doc` expect (`// Open opens the named file`)
+ * - stale-doc-parameter: the comment mentions a name that nothing in the
+ *   function or at the top level of its package declares
+ *
+ * A leading word looks like a former name, and is fixed by
+ * {@link fixDocNames}, when it is written like an identifier, as in
+ * `ProcessData` or `newParser`, or when it is capitalized and followed by a
+ * verb in the third person, as in `Parse parses`. Other openings, such as
+ * `Returns the count`, need rewording and are reported without a fix. A
+ * comment opening with a label, as in `TODO:` or `Deprecated:`, is a note
+ * rather than documentation and is not checked.
+ *
+ * Parameter names are usually plain words, which prose uses too, so only
+ * mentions that look like code are checked: words in backquotes and
+ * camel-case words starting in lower case, such as `maxRetries`. Indented
+ * code blocks and URLs are skipped. Functions without a doc comment are left
+ * to the documentation coverage report.
+ */
+
+export type GoDocDriftRule = "stale-doc-name" | "stale-doc-parameter";
+
+export interface GoDocDriftFinding extends GoFinding {
+  rule: GoDocDriftRule;
+  /** The documented function, `Type.Method` for methods */
+  function: string;
+  /** The stale word in the comment */
+  mention: string;
+}
+
+export interface FixDocNamesOptions {
+  /** Line of a doc comment reported as `stale-doc-name` (default: all) */
+  line?: number;
+}
+
+export interface FixDocNamesResult extends GoRefactorResult {
+  /** Positions of the corrected names, with the name before and after */
+  renamed: { line: number; column: number; from: string; to: string }[];
+}
+
+// A name, or a name qualified by its type as in `Reader.Read`
+const NAME = /^[\p{L}_][\p{L}\p{Nd}_]*(?:\.[\p{L}_][\p{L}\p{Nd}_]*)?/u;
+// A backquoted name, or a camel-case word with a lower-case run after each
+// capital, so `maxRetries` counts while words like gRPC and macOS do not
+const MENTION =
+  /`([\p{L}_][\p{L}\p{Nd}_]*)`|(?<![\p{L}\p{Nd}_.])(\p{Ll}[\p{Ll}\p{Nd}]*(?:\p{Lu}+[\p{Ll}\p{Nd}]+)+\p{Lu}*)(?![\p{L}\p{Nd}_])/gu;
+const ARTICLES = new Set(["A", "An", "The"]);
+const PREDECLARED = new Set([
+  "any",
+  "append",
+  "bool",
+  "byte",
+  "cap",
+  "clear",
+  "close",
+  "comparable",
+  "complex",
+  "complex128",
+  "complex64",
+  "copy",
+  "delete",
+  "error",
+  "false",
+  "float32",
+  "float64",
+  "imag",
+  "int",
+  "int16",
+  "int32",
+  "int64",
+  "int8",
+  "iota",
+  "len",
+  "make",
+  "max",
+  "min",
+  "new",
+  "nil",
+  "panic",
+  "print",
+  "println",
+  "real",
+  "recover",
+  "rune",
+  "string",
+  "true",
+  "uint",
+  "uint16",
+  "uint32",
+  "uint64",
+  "uint8",
+  "uintptr",
+]);
+
+interface Line {
+  text: string;
+  pos: number;
+}
+
+interface LeadingName {
+  word: string;
+  pos: number;
+  fixable: boolean;
+}
+
+function functionName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+// The text of each line of a doc comment, without markers or directives
+function commentLines(comment: GoComment): Line[] {
+  if (!comment.isBlock) {
+    const body = comment.text.slice(2);
+    if (/^[a-z0-9]+:[a-z0-9]/.test(body) || body.startsWith("line ")) {
+      return [];
+    }
+    return [{ text: body, pos: comment.pos + 2 }];
+  }
+  const lines: Line[] = [];
+  let pos = comment.pos + 2;
+  for (const line of comment.text.slice(2, -2).split("\n")) {
+    lines.push({ text: line, pos });
+    pos += line.length + 1;
+  }
+  return lines;
+}
+
+// Exported functions, and exported methods of exported types
+function documentedFunctions(file: GoFile): FuncDecl[] {
+  return file.decls.filter(
+    (decl): decl is FuncDecl =>
+      decl.kind === "FuncDecl" &&
+      decl.doc !== undefined &&
+      isExportedName(decl.name.name) &&
+      (!decl.recv || isExportedName(baseTypeName(decl.recv.list[0].type).name)),
+  );
+}
+
+// The first word of the comment, and whether replacing it fixes the comment
+function leadingName(decl: FuncDecl): LeadingName | undefined {
+  const lines = decl.doc.list.flatMap(commentLines);
+  const first = lines.find((line) => line.text.trim() !== "");
+  if (!first) return undefined;
+  const indent = /^\s*/.exec(first.text)[0].length;
+  const rest = first.text.slice(indent);
+  const word = NAME.exec(rest)?.[0];
+  // Labels such as `TODO:` or `Deprecated:` start a note, not a sentence
+  if (!word || ARTICLES.has(word) || rest[word.length] === ":") {
+    return undefined;
+  }
+  if (word.split(".").at(-1) === decl.name.name) return undefined;
+  // Camel case, digits, underscores or a qualifier, unlike TODO or JSON
+  const identifier = /\p{Ll}.*\p{Lu}|[\p{Nd}_.]/u.test(word);
+  const verb = /^\s+[a-z]+s\b/.test(rest.slice(word.length));
+  return {
+    word,
+    pos: first.pos + indent,
+    fixable: identifier || (/^\p{Lu}/u.test(word) && verb),
+  };
+}
+
+// Names the package declares at its top level, fields and methods included
+function packageNames(files: GoFile[]): Set<string> {
+  const names = new Set<string>();
+  for (const file of files) {
+    file.imports.forEach((spec) => names.add(importName(spec)));
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl") {
+        names.add(decl.name.name);
+        continue;
+      }
+      inspect(decl, (node) => {
+        if (node.kind === "Ident") names.add(node.name);
+      });
+    }
+  }
+  return names;
+}
+
+// Words of the comment that look like code: in backquotes, or camel case
+function codeMentions(decl: FuncDecl): { word: string; pos: number }[] {
+  const mentions: { word: string; pos: number }[] = [];
+  for (const line of decl.doc.list.flatMap(commentLines)) {
+    // Lines indented past the space after the marker are code blocks
+    if (/^[ \t]/.test(line.text.replace(/^ /, ""))) continue;
+    const text = line.text.replace(/\b\w+:\/\/\S+/g, (url) =>
+      " ".repeat(url.length),
+    );
+    for (const match of text.matchAll(MENTION)) {
+      const word = match[1] ?? match[2];
+      const offset = match[1] !== undefined ? 1 : 0;
+      mentions.push({ word, pos: line.pos + match.index + offset });
+    }
+  }
+  return mentions;
+}
+
+function driftFindings(
+  file: GoFile,
+  declared: Set<string>,
+): GoDocDriftFinding[] {
+  const findings: GoDocDriftFinding[] = [];
+  for (const decl of documentedFunctions(file)) {
+    const fn = functionName(decl);
+    const name = decl.name.name;
+    const leading = leadingName(decl);
+    if (leading) {
+      const line = file.sourceMap.line(leading.pos);
+      const start = file.sourceMap.lineStart(line);
+      const end = file.source.indexOf("\n", start);
+      const text = file.source.slice(start, end < 0 ? undefined : end);
+      const at = leading.pos - start;
+      findings.push({
+        rule: "stale-doc-name",
+        severity: "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(leading.pos),
+        message: `Doc comment of ${fn} begins with ${leading.word} instead of its name ${name}`,
+        ...(leading.fixable && {
+          fix: `${text.slice(0, at)}${name}${text.slice(at + leading.word.length)}`.trim(),
+        }),
+        function: fn,
+        mention: leading.word,
+      });
+    }
+
+    const local = new Set<string>();
+    inspect(decl, (node) => {
+      if (node.kind === "Ident") local.add(node.name);
+    });
+    const reported = new Set<string>();
+    for (const { word, pos } of codeMentions(decl)) {
+      if (reported.has(word) || (leading && word === leading.word)) continue;
+      if (local.has(word) || declared.has(word) || PREDECLARED.has(word)) {
+        continue;
+      }
+      reported.add(word);
+      const params = decl.type.params.list.flatMap((field) =>
+        field.names.map((ident) => ident.name),
+      );
+      const signature =
+        params.length > 0
+          ? `its parameters are ${params.join(", ")}`
+          : "it takes no parameters";
+      findings.push({
+        rule: "stale-doc-parameter",
+        severity: "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(pos),
+        message: `Doc comment of ${fn} mentions ${word}, which nothing in the function or its package declares; ${signature}`,
+        function: fn,
+        mention: word,
+      });
+    }
+  }
+  return findings;
+}
+
+/**
+ * Find doc comments of exported functions that no longer match the
+ * function's name or parameters
+ */
+export function findDocDrift(files: GoFile[]): GoDocDriftFinding[] {
+  const findings: GoDocDriftFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const declared = packageNames(group);
+    for (const file of group) {
+      if (file.filePath.endsWith("_test.go")) continue;
+      findings.push(...driftFindings(file, declared));
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Replace the stale leading name of doc comments reported as
+ * `stale-doc-name` with the function's name
+ */
+export function fixDocNames(
+  file: GoFile,
+  options: FixDocNamesOptions = {},
+): FixDocNamesResult {
+  const { sourceMap } = file;
+  const fixes = documentedFunctions(file).flatMap((decl) => {
+    const leading = leadingName(decl);
+    if (!leading?.fixable) return [];
+    if (
+      options.line !== undefined &&
+      sourceMap.line(leading.pos) !== options.line
+    ) {
+      return [];
+    }
+    return [{ leading, name: decl.name.name }];
+  });
+  if (options.line !== undefined && fixes.length === 0) {
+    throw new GoRefactorError(
+      `Line ${options.line} has no doc comment beginning with a former name`,
+    );
+  }
+  return {
+    ...refactorResult(
+      file,
+      fixes.map(({ leading, name }) => ({
+        start: leading.pos,
+        end: leading.pos + leading.word.length,
+        newText: name,
+      })),
+    ),
+    renamed: fixes.map(({ leading, name }) => ({
+      ...sourceMap.position(leading.pos),
+      from: leading.word,
+      to: name,
+    })),
+  };
+}
@@ -0,0 +1,315 @@
+import { TextEdit } from "../diff.js";
+import {
+  BinaryExpr,
+  Expr,
+  FuncDecl,
+  GoFile,
+  Node,
+  TypeAssertExpr,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importEdits, importName, importPath } from "./imports.js";
+import { GoTypeInference } from "./infer.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+
+/**
+ * Error Comparisons
+ * =================
+ * An error wrapped with `%w` no longer equals the sentinel it wraps, and no
+ * longer asserts to the type it wraps, so `err == ErrNotFound` and
+ * `err.(*PathError)` quietly stop matching once a caller adds context. This
+ * pass flags both:
+ *
+ * - `error-comparison`: `==` or `!=` against a sentinel error, or between two
+ *   error values, rewritten to `errors.Is` with the `errors` import added
+ * - `error-type-assertion`: a type assertion on an error, which should use
+ *   `errors.As`; assertions bind a new variable, so there is no fix
+ *
+ * Sentinels are package-level variables of type `error` or initialized with
+ * `errors.New` or `fmt.Errorf`, imported `ErrFoo` variables and the standard
+ * library's `io.EOF`, `context.Canceled` and `context.DeadlineExceeded`.
+ * The other operand of a comparison with a sentinel must be known to be an
+ * error as well. Comparisons with `nil` are idiomatic and never flagged, nor
+ * is anything in an `Is`, `As` or `Unwrap` method or in the `errors` package
+ * itself, which is where matching is implemented.
+ */
+
+export type GoErrorCompareRule = "error-comparison" | "error-type-assertion";
+
+export interface GoErrorCompareFinding extends GoFinding {
+  rule: GoErrorCompareRule;
+}
+
+export interface ErrorsIsOptions {
+  /**
+   * Line of a comparison reported by {@link findErrorComparisons} (default:
+   * every one in the file)
+   */
+  line?: number;
+}
+
+export interface ErrorsIsResult extends GoRefactorResult {
+  /** Positions of the rewritten comparisons */
+  rewritten: { line: number; column: number }[];
+}
+
+const STDLIB_SENTINELS = new Set([
+  "io.EOF",
+  "context.Canceled",
+  "context.DeadlineExceeded",
+]);
+
+const MATCHING_METHODS = new Set(["Is", "As", "Unwrap"]);
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+function isNil(expr: Expr): boolean {
+  const node = unparen(expr);
+  return node.kind === "Ident" && node.name === "nil";
+}
+
+// Package-level variables holding sentinel errors
+function packageSentinels(group: GoFile[]): Set<string> {
+  const sentinels = new Set<string>();
+  for (const file of group) {
+    const text = (node: Node) => file.source.slice(node.pos, node.end);
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "var") continue;
+      for (const spec of decl.specs) {
+        if (spec.kind !== "ValueSpec") continue;
+        spec.names.forEach((name, index) => {
+          const value = spec.values[index];
+          const constructed =
+            value?.kind === "CallExpr" &&
+            ["errors.New", "fmt.Errorf"].includes(text(value.fun));
+          if ((spec.type && text(spec.type) === "error") || constructed) {
+            sentinels.add(name.name);
+          }
+        });
+      }
+    }
+  }
+  return sentinels;
+}
+
+interface Comparison {
+  expr: BinaryExpr;
+  /** The `errors.Is` call replacing it */
+  replacement?: string;
+  /** Why it cannot be rewritten */
+  unfixable?: string;
+}
+
+class ErrorCompareAnalyzer {
+  private readonly file: GoFile;
+  private readonly sentinels: Set<string>;
+  private readonly imports = new Map<string, string>();
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(file: GoFile, sentinels: Set<string>) {
+    this.file = file;
+    this.sentinels = sentinels;
+    for (const spec of file.imports) {
+      this.imports.set(importName(spec), importPath(spec));
+    }
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private isSentinel(expr: Expr): boolean {
+    const node = unparen(expr);
+    if (node.kind === "Ident") {
+      return this.sentinels.has(node.name) && !this.scopes.resolved.has(node);
+    }
+    if (node.kind !== "SelectorExpr" || node.x.kind !== "Ident") return false;
+    const imported = this.imports.get(node.x.name);
+    if (!imported || this.scopes.resolved.has(node.x)) return false;
+    return (
+      /^Err[A-Z0-9]/.test(node.sel.name) ||
+      STDLIB_SENTINELS.has(`${imported}.${node.sel.name}`)
+    );
+  }
+
+  private isError(expr: Expr): boolean {
+    const type = this.types.typeOf(expr);
+    return (
+      type !== undefined && (this.types.underlying(type) ?? type) === "error"
+    );
+  }
+
+  // How errors.Is is named here, or why it cannot be
+  private errorsPackage(): { name?: string; unfixable?: string } {
+    const spec = this.file.imports.find(
+      (candidate) => importPath(candidate) === "errors",
+    );
+    const name = spec ? importName(spec) : "errors";
+    if (name === "_" || name === ".") {
+      return { unfixable: `errors is imported as ${name}` };
+    }
+    if (this.scopes.variables.some((variable) => variable.name === name)) {
+      return { unfixable: `a local variable hides the ${name} package` };
+    }
+    return { name };
+  }
+
+  private comparison(expr: BinaryExpr): Comparison | undefined {
+    if (expr.op !== "==" && expr.op !== "!=") return undefined;
+    if (isNil(expr.x) || isNil(expr.y)) return undefined;
+    const [x, y] = [this.isSentinel(expr.x), this.isSentinel(expr.y)];
+    // The other operand must be an error too: comparing a sentinel with the
+    // interface{} from recover() is not an error comparison
+    const errors = (x || this.isError(expr.x)) && (y || this.isError(expr.y));
+    if (!errors) return undefined;
+    // errors.Is takes the error first and the target second
+    const [err, target] = x && !y ? [expr.y, expr.x] : [expr.x, expr.y];
+    const { name, unfixable } = this.errorsPackage();
+    if (unfixable) return { expr, unfixable };
+    const call = `${name}.Is(${this.text(unparen(err))}, ${this.text(unparen(target))})`;
+    return { expr, replacement: expr.op === "!=" ? `!${call}` : call };
+  }
+
+  private check(
+    decl: FuncDecl,
+    comparisons: Comparison[],
+    assertions: TypeAssertExpr[],
+  ): void {
+    if (decl.recv && MATCHING_METHODS.has(decl.name.name)) return;
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+    inspect(decl.body, (node) => {
+      if (node.kind === "BinaryExpr") {
+        const comparison = this.comparison(node);
+        if (comparison) comparisons.push(comparison);
+      } else if (node.kind === "TypeAssertExpr" && node.type) {
+        if (this.isError(node.x)) assertions.push(node);
+      }
+    });
+  }
+
+  analyze(): { comparisons: Comparison[]; assertions: TypeAssertExpr[] } {
+    const comparisons: Comparison[] = [];
+    const assertions: TypeAssertExpr[] = [];
+    // The errors package implements errors.Is with ==
+    if (this.file.packageName.name === "errors") {
+      return { comparisons, assertions };
+    }
+    for (const decl of this.file.decls) {
+      if (decl.kind === "FuncDecl" && decl.body) {
+        this.check(decl, comparisons, assertions);
+      }
+    }
+    return { comparisons, assertions };
+  }
+
+  findings(): GoErrorCompareFinding[] {
+    const { comparisons, assertions } = this.analyze();
+    const position = (node: Node) => ({
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(node.pos),
+    });
+    return [
+      ...comparisons.map(
+        (comparison): GoErrorCompareFinding => ({
+          rule: "error-comparison",
+          severity: "medium",
+          ...position(comparison.expr),
+          message: `${this.text(comparison.expr)} does not match wrapped errors; use ${comparison.replacement ?? "errors.Is"}`,
+          ...(comparison.replacement && { fix: comparison.replacement }),
+        }),
+      ),
+      ...assertions.map(
+        (assertion): GoErrorCompareFinding => ({
+          rule: "error-type-assertion",
+          severity: "medium",
+          ...position(assertion),
+          message: `${this.text(assertion)} does not match wrapped errors; use errors.As(${this.text(assertion.x)}, &target) with a ${this.text(assertion.type)} target`,
+        }),
+      ),
+    ];
+  }
+
+  rewrite(options: ErrorsIsOptions): ErrorsIsResult {
+    const { sourceMap } = this.file;
+    const matches = this.analyze().comparisons.filter(
+      (match) =>
+        options.line === undefined ||
+        sourceMap.line(match.expr.pos) === options.line,
+    );
+    if (options.line !== undefined) {
+      if (matches.length === 0) {
+        throw new GoRefactorError(
+          `Line ${options.line} has no comparison of errors with == or !=`,
+        );
+      }
+      const [unfixable] = matches.filter((match) => !match.replacement);
+      if (unfixable) {
+        throw new GoRefactorError(
+          `Cannot use errors.Is: ${unfixable.unfixable}`,
+        );
+      }
+    }
+    const rewritten = matches.filter((match) => match.replacement);
+    const edits: TextEdit[] = rewritten.map((match) => ({
+      start: match.expr.pos,
+      end: match.expr.end,
+      newText: match.replacement,
+    }));
+    const imported = this.file.imports.some(
+      (spec) => importPath(spec) === "errors",
+    );
+    if (rewritten.length > 0 && !imported) {
+      edits.push(...importEdits(this.file, [{ path: "errors" }]));
+    }
+    return {
+      ...refactorResult(this.file, edits),
+      rewritten: rewritten.map((match) => sourceMap.position(match.expr.pos)),
+    };
+  }
+}
+
+/**
+ * Find errors compared with `==` or `!=` or type asserted, where `errors.Is`
+ * and `errors.As` would also match wrapped errors
+ */
+export function findErrorComparisons(
+  files: GoFile[],
+): GoErrorCompareFinding[] {
+  const findings: GoErrorCompareFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const sentinels = packageSentinels(group);
+    for (const file of group) {
+      findings.push(...new ErrorCompareAnalyzer(file, sentinels).findings());
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Rewrite error comparisons to `errors.Is`, adding the `errors` import when
+ * the file lacks it. Sentinels declared in other files of the package are
+ * found through `files`.
+ */
+export function useErrorsIs(
+  file: GoFile,
+  options: ErrorsIsOptions = {},
+  files: GoFile[] = [file],
+): ErrorsIsResult {
+  const group = groupGoPackages([
+    file,
+    ...files.filter((other) => other !== file),
+  ]);
+  const sentinels = packageSentinels(group[0]);
+  return new ErrorCompareAnalyzer(file, sentinels).rewrite(options);
+}
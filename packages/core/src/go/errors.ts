@@ -0,0 +1,535 @@
+import {
+  AssignStmt,
+  BasicLit,
+  CallExpr,
+  Expr,
+  FuncDecl,
+  FuncLit,
+  GenDecl,
+  GoFile,
+  Node,
+  ReturnStmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference, isZeroLiteral, zeroValue } from "./infer.js";
+import { DEFAULT_GO_INITIALISMS } from "./naming.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import { functionSignatures, GoSignature, goSignature } from "./signature.js";
+
+/**
+ * Go Error Handling
+ * =================
+ * Flags common mistakes with Go errors:
+ *
+ * - `ignored-error`: an error result dropped by calling a function as a
+ *   statement, or discarded with `_`
+ * - `unwrapped-error`: `fmt.Errorf` formatting an error with a verb other
+ *   than `%w`, which hides it from `errors.Is` and `errors.As`
+ * - `nil-error-zero-value`: an early branch returning zero values with a nil
+ *   error, which callers cannot tell apart from success
+ * - `error-string-style`: an `errors.New` or `fmt.Errorf` message starting
+ *   with a capital letter or ending in punctuation, which reads badly once
+ *   wrapped into another message; words in capitals throughout, words with
+ *   inner capitals such as identifiers, and the configured initialisms may
+ *   still start a message
+ *
+ * Result types come from signatures declared in the package, the standard
+ * library table in `infer.ts` and local inference; calls whose results are
+ * unknown are not reported. Error strings can be restyled in place with
+ * {@link restyleErrorStrings}.
+ */
+
+export type GoErrorRule =
+  | "ignored-error"
+  | "unwrapped-error"
+  | "nil-error-zero-value"
+  | "error-string-style";
+
+export interface GoErrorFinding extends GoFinding {
+  rule: GoErrorRule;
+}
+
+export interface GoErrorCheckOptions {
+  /**
+   * Report `fmt.Errorf` calls that format an error without `%w` (default:
+   * true). Turn off for projects that deliberately keep errors opaque.
+   */
+  requireWrapping?: boolean;
+  /**
+   * Words error strings may start with capitalized, such as proper nouns
+   * (default: {@link DEFAULT_GO_INITIALISMS})
+   */
+  capitalizedWords?: string[];
+}
+
+export interface RestyleErrorStringsOptions
+  extends Pick<GoErrorCheckOptions, "capitalizedWords"> {
+  /**
+   * Line of a message reported as `error-string-style` (default: every one
+   * in the file)
+   */
+  line?: number;
+}
+
+export interface RestyleErrorStringsResult extends GoRefactorResult {
+  /** Positions of the restyled messages */
+  restyled: { line: number; column: number }[];
+}
+
+// `%[flags][[index]][width][.precision]verb`
+const VERB = /%([-+# 0]*)(?:\[(\d+)\])?(\d+|\*)?(?:\.(\d+|\*)?)?([a-zA-Z%])/g;
+
+interface FormatVerb {
+  verb: string;
+  /** Explicit argument index, as in `%[2]v` */
+  index?: string;
+  /** Offsets within the literal's source text */
+  start: number;
+  end: number;
+  /** Index into the arguments after the format string */
+  arg: number;
+}
+
+function formatVerbs(literal: BasicLit): FormatVerb[] {
+  const verbs: FormatVerb[] = [];
+  let arg = 0;
+  for (const match of literal.value.matchAll(VERB)) {
+    const [text, , index, width, precision, verb] = match;
+    if (verb === "%") continue;
+    if (index) arg = Number(index) - 1;
+    if (width === "*") arg++;
+    if (precision === "*") arg++;
+    verbs.push({
+      verb,
+      index,
+      start: match.index,
+      end: match.index + text.length,
+      arg: arg++,
+    });
+  }
+  return verbs;
+}
+
+const ERROR_NAME = /^err([A-Z0-9_]|$)|Err$/;
+
+// Trailing punctuation, including an escaped newline, of a string's content
+const TRAILING_PUNCTUATION = /(?:[.:!?]|\\n)+$/;
+
+/**
+ * The content of an error string restyled: the first word lowercased unless
+ * it may stay capitalized, and trailing punctuation removed
+ */
+function restyleErrorString(content: string, capitalized: Set<string>): string {
+  let styled = content.replace(TRAILING_PUNCTUATION, "");
+  // An ellipsis is deliberate
+  if (content.endsWith("...")) styled = content;
+  const word = /^\p{L}[\p{L}\p{Nd}]*/u.exec(styled)?.[0];
+  if (
+    word &&
+    /^\p{Lu}/u.test(word) &&
+    !/\p{Lu}/u.test(word.slice(1)) &&
+    !capitalized.has(word)
+  ) {
+    styled = word[0].toLowerCase() + styled.slice(1);
+  }
+  return styled;
+}
+
+class ErrorChecker {
+  private readonly file: GoFile;
+  private readonly options: GoErrorCheckOptions;
+  private readonly signatures: Map<string, GoSignature>;
+  private readonly imports = new Map<string, string>();
+  private readonly findings: GoErrorFinding[] = [];
+  /** Error string literals and their restyled source text */
+  private readonly restyles: { message: BasicLit; text: string }[] = [];
+  /** Scopes of the function being checked; unset at package level */
+  private scopes?: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(
+    file: GoFile,
+    signatures: Map<string, GoSignature>,
+    options: GoErrorCheckOptions,
+  ) {
+    this.file = file;
+    this.signatures = signatures;
+    this.options = options;
+    for (const spec of file.imports) {
+      const importPath = spec.path.value.slice(1, -1);
+      const name = spec.name?.name ?? importPath.split("/").pop();
+      this.imports.set(name, importPath);
+    }
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private report(
+    rule: GoErrorRule,
+    severity: GoErrorFinding["severity"],
+    node: Node,
+    message: string,
+    fix?: string,
+  ): void {
+    this.findings.push({
+      rule,
+      severity,
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(node.pos),
+      message,
+      fix,
+    });
+  }
+
+  private isPackage(expr: Expr): boolean {
+    return (
+      expr.kind === "Ident" &&
+      this.imports.has(expr.name) &&
+      !this.scopes?.resolved.has(expr)
+    );
+  }
+
+  /**
+   * Result types of a call, or undefined when they cannot be determined
+   */
+  private resultsOf(call: CallExpr): string[] | undefined {
+    const fun = call.fun.kind === "ParenExpr" ? call.fun.x : call.fun;
+    let name: string | undefined;
+    if (fun.kind === "Ident" && !this.scopes.resolved.has(fun)) {
+      name = fun.name;
+    } else if (fun.kind === "SelectorExpr" && !this.isPackage(fun.x)) {
+      name = fun.sel.name;
+    }
+    const signature =
+      name === undefined ? undefined : this.signatures.get(name);
+    if (signature) {
+      return signature.results.map((result) => result.type);
+    }
+    return this.types.callResults(call);
+  }
+
+  private isError(expr: Expr): boolean {
+    const type =
+      expr.kind === "CallExpr"
+        ? this.resultsOf(expr)?.join(", ")
+        : this.types.typeOf(expr);
+    if (type !== undefined) return type === "error";
+    return expr.kind === "Ident" && ERROR_NAME.test(expr.name);
+  }
+
+  /**
+   * The statement that hands an error back to the enclosing function's
+   * caller, or a placeholder when the function has no error result
+   */
+  private propagate(fn: FuncDecl | FuncLit): string {
+    const results = goSignature(this.file, fn.type).results;
+    if (results.at(-1)?.type !== "error") {
+      return "// handle err";
+    }
+    const zeros = results
+      .slice(0, -1)
+      .map((result) =>
+        zeroValue(result.type, (name) => this.types.underlying(name)),
+      );
+    return `return ${[...zeros, "err"].join(", ")}`;
+  }
+
+  check(decl: FuncDecl): void {
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+
+    inspect(decl.body, (node, parents) => {
+      const fn = ([...parents].reverse().find(
+        (parent) => parent.kind === "FuncLit",
+      ) ?? decl) as FuncDecl | FuncLit;
+      switch (node.kind) {
+        case "ExprStmt":
+          if (node.x.kind === "CallExpr") {
+            this.checkDropped(node.x, fn);
+          }
+          break;
+        case "AssignStmt":
+          this.checkDiscarded(node, fn);
+          break;
+        case "CallExpr":
+          this.checkWrapping(node);
+          this.checkErrorString(node);
+          break;
+        case "ReturnStmt":
+          this.checkNilReturn(node, fn, parents);
+          break;
+      }
+      return true;
+    });
+  }
+
+  /**
+   * Check the error strings of package-level variables, where sentinel
+   * errors are declared
+   */
+  checkValues(decl: GenDecl): void {
+    this.scopes = undefined;
+    inspect(decl, (node) => {
+      if (node.kind === "CallExpr") this.checkErrorString(node);
+      return true;
+    });
+  }
+
+  private checkDropped(call: CallExpr, fn: FuncDecl | FuncLit): void {
+    const results = this.resultsOf(call);
+    const errorIndex = results?.lastIndexOf("error") ?? -1;
+    if (errorIndex < 0) return;
+
+    const names = results.map((_, index) =>
+      index === errorIndex ? "err" : "_",
+    );
+    this.report(
+      "ignored-error",
+      "high",
+      call,
+      `error returned by ${this.text(call.fun)} is not checked`,
+      `if ${names.join(", ")} := ${this.text(call)}; err != nil {\n\t${this.propagate(fn)}\n}`,
+    );
+  }
+
+  private checkDiscarded(stmt: AssignStmt, fn: FuncDecl | FuncLit): void {
+    const isBlank = (expr: Expr) => expr.kind === "Ident" && expr.name === "_";
+
+    // `a, _ := f()` spreads one call over the left-hand side
+    if (stmt.rhs.length === 1 && stmt.lhs.length > 1) {
+      const [call] = stmt.rhs;
+      if (call.kind !== "CallExpr") return;
+      const results = this.resultsOf(call);
+      if (results?.length !== stmt.lhs.length) return;
+      const index = stmt.lhs.findIndex(
+        (expr, i) => isBlank(expr) && results[i] === "error",
+      );
+      if (index >= 0) this.reportDiscarded(stmt, call, index, fn);
+      return;
+    }
+
+    stmt.rhs.forEach((value, index) => {
+      if (
+        value.kind === "CallExpr" &&
+        isBlank(stmt.lhs[index]) &&
+        this.resultsOf(value)?.join(", ") === "error"
+      ) {
+        this.reportDiscarded(stmt, value, index, fn);
+      }
+    });
+  }
+
+  private reportDiscarded(
+    stmt: AssignStmt,
+    call: CallExpr,
+    index: number,
+    fn: FuncDecl | FuncLit,
+  ): void {
+    const check = `err != nil {\n\t${this.propagate(fn)}\n}`;
+    let fix = `if err := ${this.text(call)}; ${check}`;
+    if (stmt.rhs.length === 1) {
+      const names = stmt.lhs.map((expr, i) =>
+        i === index ? "err" : this.text(expr),
+      );
+      fix = names.every((name) => name === "_" || name === "err")
+        ? `if ${names.join(", ")} := ${this.text(call)}; ${check}`
+        : `${names.join(", ")} ${stmt.tok} ${this.text(call)}\nif ${check}`;
+    }
+    this.report(
+      "ignored-error",
+      "medium",
+      stmt,
+      `error returned by ${this.text(call.fun)} is discarded with _`,
+      fix,
+    );
+  }
+
+  private checkWrapping(call: CallExpr): void {
+    if (this.options.requireWrapping === false) return;
+    const { fun } = call;
+    if (
+      fun.kind !== "SelectorExpr" ||
+      fun.sel.name !== "Errorf" ||
+      !this.isPackage(fun.x) ||
+      this.imports.get((fun.x as Expr & { name: string }).name) !== "fmt"
+    ) {
+      return;
+    }
+    const [format, ...args] = call.args;
+    if (format?.kind !== "BasicLit" || format.litKind !== "string") return;
+
+    for (const verb of formatVerbs(format)) {
+      const arg = args[verb.arg];
+      if (!arg || verb.verb === "w" || !this.isError(arg)) continue;
+
+      const offset = format.pos - call.pos;
+      const wrap = verb.index ? `%[${verb.index}]w` : "%w";
+      const text = this.text(call);
+      const fix = `${text.slice(0, offset + verb.start)}${wrap}${text.slice(offset + verb.end)}`;
+      this.report(
+        "unwrapped-error",
+        "medium",
+        call,
+        `fmt.Errorf formats ${this.text(arg)} with %${verb.verb}; use %w so callers can match it with errors.Is and errors.As`,
+        fix,
+      );
+    }
+  }
+
+  private checkErrorString(call: CallExpr): void {
+    const { fun } = call;
+    if (fun.kind !== "SelectorExpr" || !this.isPackage(fun.x)) return;
+    const imported = this.imports.get((fun.x as Expr & { name: string }).name);
+    const name = `${imported}.${fun.sel.name}`;
+    if (name !== "errors.New" && name !== "fmt.Errorf") return;
+    const [message] = call.args;
+    if (message?.kind !== "BasicLit" || message.litKind !== "string") return;
+
+    const content = message.value.slice(1, -1);
+    const styled = restyleErrorString(
+      content,
+      new Set(this.options.capitalizedWords ?? DEFAULT_GO_INITIALISMS),
+    );
+    if (styled === content) return;
+    const problems = [
+      styled.length > 0 && styled[0] !== content[0] && "be capitalized",
+      styled.length < content.length && "end with punctuation",
+    ].filter(Boolean);
+    const quote = message.value[0];
+    this.restyles.push({ message, text: `${quote}${styled}${quote}` });
+    const offset = message.pos - call.pos;
+    const text = this.text(call);
+    this.report(
+      "error-string-style",
+      "low",
+      message,
+      `error strings should not ${problems.join(" or ")}: ${message.value}`,
+      `${text.slice(0, offset)}${quote}${styled}${quote}${text.slice(offset + message.value.length)}`,
+    );
+  }
+
+  private checkNilReturn(
+    stmt: ReturnStmt,
+    fn: FuncDecl | FuncLit,
+    parents: Node[],
+  ): void {
+    const results = goSignature(this.file, fn.type).results;
+    if (
+      results.length < 2 ||
+      results.at(-1).type !== "error" ||
+      stmt.results.length !== results.length
+    ) {
+      return;
+    }
+    const error = stmt.results.at(-1);
+    const values = stmt.results.slice(0, -1);
+    if (
+      error.kind !== "Ident" ||
+      error.name !== "nil" ||
+      !values.every(isZeroLiteral)
+    ) {
+      return;
+    }
+    // Only early branches count; a final `return nil, nil` is the result
+    if (parents.at(-1) === fn.body) return;
+
+    const zeros = values.map((value) => this.text(value));
+    this.report(
+      "nil-error-zero-value",
+      "low",
+      stmt,
+      `early return of ${[...zeros, "nil"].join(", ")} reports success without a value; return an error if this branch is a failure`,
+      `return ${[...zeros, 'errors.New("<describe the failure>")'].join(", ")}`,
+    );
+  }
+
+  checkFile(): void {
+    for (const decl of this.file.decls) {
+      if (decl.kind === "FuncDecl" && decl.body) {
+        this.check(decl);
+      } else if (decl.kind === "GenDecl" && decl.tok === "var") {
+        this.checkValues(decl);
+      }
+    }
+  }
+
+  results(): GoErrorFinding[] {
+    return this.findings;
+  }
+
+  restyled(): { message: BasicLit; text: string }[] {
+    return this.restyles;
+  }
+}
+
+/**
+ * Find error-handling mistakes in Go files. Files are grouped by package so
+ * calls resolve to functions declared in sibling files.
+ */
+export function findErrorHandlingIssues(
+  files: GoFile[],
+  options: GoErrorCheckOptions = {},
+): GoErrorFinding[] {
+  const packages = new Map<string, GoFile[]>();
+  for (const file of files) {
+    const key = file.packageName.name;
+    packages.set(key, [...(packages.get(key) ?? []), file]);
+  }
+
+  const findings: GoErrorFinding[] = [];
+  for (const packageFiles of packages.values()) {
+    const signatures = functionSignatures(packageFiles);
+    for (const file of packageFiles) {
+      const checker = new ErrorChecker(file, signatures, options);
+      checker.checkFile();
+      findings.push(...checker.results());
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Rewrite error strings reported as `error-string-style`: lowercase their
+ * first word and drop trailing punctuation
+ */
+export function restyleErrorStrings(
+  file: GoFile,
+  options: RestyleErrorStringsOptions = {},
+): RestyleErrorStringsResult {
+  const checker = new ErrorChecker(file, functionSignatures([file]), {
+    capitalizedWords: options.capitalizedWords,
+  });
+  checker.checkFile();
+  const { sourceMap } = file;
+  const restyles = checker
+    .restyled()
+    .filter(
+      ({ message }) =>
+        options.line === undefined ||
+        sourceMap.line(message.pos) === options.line,
+    );
+  if (options.line !== undefined && restyles.length === 0) {
+    throw new GoRefactorError(
+      `Line ${options.line} has no error string to restyle`,
+    );
+  }
+  return {
+    ...refactorResult(
+      file,
+      restyles.map(({ message, text }) => ({
+        start: message.pos,
+        end: message.end,
+        newText: text,
+      })),
+    ),
+    restyled: restyles.map(({ message }) => sourceMap.position(message.pos)),
+  };
+}
@@ -0,0 +1,401 @@
+import * as path from "path";
+import { FuncDecl, GoFile } from "./ast.js";
+import { compareCodePoints, GoFinding, sortFindings } from "./findings.js";
+import { groupGoPackages } from "./package.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+import { isGoTestFile } from "./test-links.js";
+
+/**
+ * Go Examples
+ * ===========
+ * Example functions in `_test.go` files are documentation that `go test`
+ * keeps honest: `godoc` shows `Example` under the package, `ExampleF` under
+ * the function `F`, `ExampleT` under the type `T` and `ExampleT_M` under its
+ * method or field `M`, and any of them may end in a lower-case suffix naming
+ * a variant (`ExampleT_M_second`). An example with an `// Output:` or
+ * `// Unordered output:` comment as its last comment is run and its output
+ * compared; one without is only compiled.
+ *
+ * This module lists the examples of each package with what they document,
+ * reports the exported functions, types and methods that have none, and
+ * flags examples `go vet` rejects: those naming a function, type, field or
+ * method the package no longer declares, usually left behind by a rename,
+ * those with a malformed suffix, and those with parameters, results or type
+ * parameters, which `go test` cannot call. An example in the external test
+ * package `p_test` documents the symbols of `p`. Whether the body of an
+ * example compiles is left to `go vet` and `go test`.
+ */
+
+export interface GoExample {
+  /** Package directory relative to the root, `.` for the root itself */
+  package: string;
+  name: string;
+  /** `F`, `T` or `T.M`; absent for package examples */
+  subject?: string;
+  /** The variant after the subject, `second` in `ExampleT_M_second` */
+  suffix?: string;
+  filePath: string;
+  line: number;
+  /** Whether an output comment makes `go test` run it */
+  runnable: boolean;
+  /** Whether the output may come in any order */
+  unordered: boolean;
+  /** Expected output, with surrounding space removed */
+  output?: string;
+  /** Why `go vet` rejects it, if it does */
+  problems: string[];
+}
+
+export type GoExampleSymbolKind = "func" | "method" | "type";
+
+export interface GoExampleSymbol {
+  package: string;
+  kind: GoExampleSymbolKind;
+  /** `Type.Method` for methods */
+  name: string;
+  filePath: string;
+  line: number;
+  /** Names of the examples documenting it */
+  examples: string[];
+}
+
+export interface GoExampleCoverage {
+  /** Exported functions, types and methods of exported types */
+  total: number;
+  /** Symbols with at least one example */
+  covered: number;
+  /** `covered` as a percentage of `total`; 100 without symbols */
+  percent: number;
+  /** Symbols without examples */
+  missing: GoExampleSymbol[];
+  /** Every symbol, sorted by package, file and line */
+  symbols: GoExampleSymbol[];
+  /** Every example, sorted by package, file and line */
+  examples: GoExample[];
+}
+
+export interface GoExampleOptions {
+  /** Directory package paths are relative to (default: the cwd) */
+  root?: string;
+}
+
+export interface GoExampleFinding extends GoFinding {
+  rule: "stale-example" | "malformed-example";
+  example: string;
+}
+
+// Matches the output comments go/doc recognizes
+const OUTPUT = /^\s*(unordered )?output:/i;
+
+function isExampleSuffix(text: string): boolean {
+  return /^\p{Ll}/u.test(text);
+}
+
+function isExample(file: GoFile, decl: FuncDecl): boolean {
+  return (
+    isGoTestFile(file.filePath) &&
+    !decl.recv &&
+    /^Example(?!\p{Ll})/u.test(decl.name.name)
+  );
+}
+
+class ExampleAnalyzer {
+  private readonly group: GoFile[];
+  private readonly pkg: string;
+  /** Package-level names, with the fields and methods of types */
+  private readonly members = new Map<string, Set<string>>();
+
+  constructor(group: GoFile[], root: string) {
+    this.group = group;
+    const directory = path.relative(root, path.dirname(group[0].filePath));
+    this.pkg = directory.split(path.sep).join("/") || ".";
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind === "FuncDecl") {
+          if (!decl.recv) {
+            this.declare(decl.name.name);
+            continue;
+          }
+          const owner = baseTypeName(decl.recv.list[0].type).name;
+          this.declare(owner).add(decl.name.name);
+          continue;
+        }
+        for (const spec of decl.specs) {
+          if (spec.kind === "ValueSpec") {
+            spec.names.forEach((ident) => this.declare(ident.name));
+          } else if (spec.kind === "TypeSpec") {
+            const members = this.declare(spec.name.name);
+            const { type } = spec;
+            const fields =
+              type.kind === "StructType"
+                ? type.fields.list
+                : type.kind === "InterfaceType"
+                  ? type.methods.list
+                  : [];
+            for (const field of fields) {
+              // Embedded fields are named after their type
+              const names = field.names.length
+                ? field.names.map((ident) => ident.name)
+                : [baseTypeName(field.type).name];
+              names.forEach((name) => members.add(name));
+            }
+          }
+        }
+      }
+    }
+  }
+
+  private declare(name: string): Set<string> {
+    if (!this.members.has(name)) this.members.set(name, new Set());
+    return this.members.get(name);
+  }
+
+  // The checks of go vet's tests analyzer
+  private problems(decl: FuncDecl): string[] {
+    const name = decl.name.name;
+    const problems: string[] = [];
+    if (decl.type.params.list.length > 0) {
+      problems.push(`${name} should be niladic`);
+    }
+    if (decl.type.results?.list.length) {
+      problems.push(`${name} should return nothing`);
+    }
+    if (decl.type.typeParams?.list.length) {
+      problems.push(`${name} should not have type params`);
+    }
+    if (name === "Example") return problems;
+
+    const rest = name.slice("Example".length);
+    const [ident, member, suffix] = this.elements(rest);
+    if (ident !== "" && !this.members.has(ident)) {
+      problems.push(`${name} refers to unknown identifier: ${ident}`);
+      return problems;
+    }
+    if (member === undefined) return problems;
+    if (ident === "") {
+      const residual = rest.slice(1);
+      if (!isExampleSuffix(residual)) {
+        problems.push(`${name} has malformed example suffix: ${residual}`);
+      }
+      return problems;
+    }
+    if (!isExampleSuffix(member) && !this.members.get(ident).has(member)) {
+      problems.push(
+        `${name} refers to unknown field or method: ${ident}.${member}`,
+      );
+    }
+    if (suffix !== undefined && !isExampleSuffix(suffix)) {
+      problems.push(`${name} has malformed example suffix: ${suffix}`);
+    }
+    return problems;
+  }
+
+  private example(file: GoFile, decl: FuncDecl): GoExample {
+    const rest = decl.name.name.slice("Example".length);
+    const [ident, member, suffix] = this.elements(rest);
+    const example: GoExample = {
+      package: this.pkg,
+      name: decl.name.name,
+      filePath: file.filePath,
+      line: file.sourceMap.line(decl.pos),
+      runnable: false,
+      unordered: false,
+      problems: this.problems(decl),
+    };
+    if (ident !== "") {
+      const isMember = member !== undefined && !isExampleSuffix(member);
+      example.subject = isMember ? `${ident}.${member}` : ident;
+      const variant = isMember ? [suffix] : [member, suffix];
+      const text = variant.filter((part) => part !== undefined).join("_");
+      if (text) example.suffix = text;
+    } else if (member !== undefined) {
+      example.suffix = rest.slice(1);
+    }
+
+    const last = file.comments
+      .filter(
+        (group) => group.pos > decl.body.pos && group.end < decl.body.end,
+      )
+      .at(-1);
+    const match = last && OUTPUT.exec(last.text);
+    if (match) {
+      example.runnable = true;
+      example.unordered = Boolean(match[1]);
+      example.output = last.text.slice(match[0].length).trim();
+    }
+    return example;
+  }
+
+  // `T`, `M` and the suffix of `ExampleT_M_suffix`, as go vet splits them
+  private elements(rest: string): [string, string?, string?] {
+    const [ident, member, ...suffix] = rest.split("_");
+    if (member === undefined) return [ident];
+    if (suffix.length === 0) return [ident, member];
+    return [ident, member, suffix.join("_")];
+  }
+
+  examples(): GoExample[] {
+    return this.group.flatMap((file) =>
+      file.decls.flatMap((decl) =>
+        decl.kind === "FuncDecl" && decl.body && isExample(file, decl)
+          ? [this.example(file, decl)]
+          : [],
+      ),
+    );
+  }
+
+  symbols(examples: GoExample[]): GoExampleSymbol[] {
+    const symbols: GoExampleSymbol[] = [];
+    const add = (
+      file: GoFile,
+      kind: GoExampleSymbolKind,
+      name: string,
+      pos: number,
+    ) =>
+      symbols.push({
+        package: this.pkg,
+        kind,
+        name,
+        filePath: file.filePath,
+        line: file.sourceMap.line(pos),
+        examples: examples
+          .filter((example) => example.subject === name)
+          .map((example) => example.name),
+      });
+
+    for (const file of this.group) {
+      if (isGoTestFile(file.filePath)) continue;
+      for (const decl of file.decls) {
+        if (decl.kind === "FuncDecl") {
+          const { name } = decl.name;
+          if (!isExportedName(name)) continue;
+          if (!decl.recv) {
+            add(file, "func", name, decl.pos);
+            continue;
+          }
+          const owner = baseTypeName(decl.recv.list[0].type).name;
+          if (isExportedName(owner)) {
+            add(file, "method", `${owner}.${name}`, decl.pos);
+          }
+          continue;
+        }
+        if (decl.tok !== "type") continue;
+        for (const spec of decl.specs) {
+          if (spec.kind !== "TypeSpec") continue;
+          if (!isExportedName(spec.name.name)) continue;
+          add(file, "type", spec.name.name, spec.name.pos);
+        }
+      }
+    }
+    return symbols;
+  }
+}
+
+function sortByLocation<T extends GoExample | GoExampleSymbol>(items: T[]) {
+  return items.sort(
+    (a, b) =>
+      compareCodePoints(a.package, b.package) ||
+      compareCodePoints(a.filePath, b.filePath) ||
+      a.line - b.line,
+  );
+}
+
+/**
+ * The example functions of the packages among `files`
+ */
+export function goExamples(
+  files: GoFile[],
+  options: GoExampleOptions = {},
+): GoExample[] {
+  const root = options.root ?? process.cwd();
+  return sortByLocation(
+    groupGoPackages(files, { externalTests: true }).flatMap((group) =>
+      new ExampleAnalyzer(group, root).examples(),
+    ),
+  );
+}
+
+/**
+ * Measure how much of the exported API of the packages among `files` has
+ * examples
+ */
+export function goExampleCoverage(
+  files: GoFile[],
+  options: GoExampleOptions = {},
+): GoExampleCoverage {
+  const root = options.root ?? process.cwd();
+  const examples: GoExample[] = [];
+  const symbols: GoExampleSymbol[] = [];
+  for (const group of groupGoPackages(files, { externalTests: true })) {
+    const analyzer = new ExampleAnalyzer(group, root);
+    const found = analyzer.examples();
+    examples.push(...found);
+    symbols.push(...analyzer.symbols(found));
+  }
+  sortByLocation(examples);
+  sortByLocation(symbols);
+  const missing = symbols.filter((symbol) => symbol.examples.length === 0);
+  const covered = symbols.length - missing.length;
+  return {
+    total: symbols.length,
+    covered,
+    percent: symbols.length === 0 ? 100 : (100 * covered) / symbols.length,
+    missing,
+    symbols,
+    examples,
+  };
+}
+
+/**
+ * Examples that name a symbol the package no longer declares, or that
+ * `go test` cannot run as written
+ */
+export function findExampleProblems(files: GoFile[]): GoExampleFinding[] {
+  return sortFindings(
+    goExamples(files).flatMap((example) =>
+      example.problems.map((problem): GoExampleFinding => {
+        const stale = / refers to unknown /.test(problem);
+        return {
+          rule: stale ? "stale-example" : "malformed-example",
+          severity: "medium",
+          filePath: example.filePath,
+          line: example.line,
+          column: 1,
+          message: stale
+            ? `${problem}; rename the example after the symbol it documents`
+            : problem,
+          example: example.name,
+        };
+      }),
+    ),
+  );
+}
+
+/**
+ * A summary line with the percentage and the runnable examples, followed by
+ * one line per symbol without examples and per example go vet rejects
+ */
+export function formatGoExampleCoverage(
+  coverage: GoExampleCoverage,
+  options: GoExampleOptions = {},
+): string {
+  const root = options.root ?? process.cwd();
+  const relative = (filePath: string) =>
+    path.relative(root, filePath).split(path.sep).join("/");
+  const runnable = coverage.examples.filter((example) => example.runnable);
+  const lines = [
+    `Example coverage: ${coverage.percent.toFixed(1)}% (${coverage.covered} of ${coverage.total} exported symbols)`,
+    `Examples: ${coverage.examples.length}, ${runnable.length} runnable`,
+  ];
+  for (const symbol of coverage.missing) {
+    lines.push(
+      `${relative(symbol.filePath)}:${symbol.line}: ${symbol.kind} ${symbol.name}: no example`,
+    );
+  }
+  for (const example of coverage.examples) {
+    for (const problem of example.problems) {
+      lines.push(`${relative(example.filePath)}:${example.line}: ${problem}`);
+    }
+  }
+  return lines.join("\n") + "\n";
+}
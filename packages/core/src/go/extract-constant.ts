@@ -0,0 +1,371 @@
+import { TextEdit } from "../diff.js";
+import {
+  BasicLit,
+  Decl,
+  Expr,
+  GoFile,
+  Node,
+  UnaryExpr,
+  inspect,
+} from "./ast.js";
+import { importName } from "./imports.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { resolveFunctionScopes } from "./scope.js";
+
+/**
+ * Extract Constant
+ * ================
+ * Replaces a magic number with a package-level constant declared just above
+ * the declaration using it. The constant is untyped, so every replaced
+ * literal keeps the type its context gave it and the program behaves exactly
+ * as before. By default only numbers used as thresholds and limits are
+ * extracted: operands of comparisons and the sizes passed to `make`. Other
+ * positions, such as arithmetic, can be targeted explicitly. `0` and `1`
+ * are left alone in threshold positions, since they rarely mean more than
+ * "empty" and "one".
+ *
+ * The name is inferred from what the number is compared with (`len(item) >
+ * 5` gives `itemLengthLimit`) unless one is given, and gets a numeric suffix
+ * when it would collide with a name in the package, an import or a local
+ * variable of a function the constant replaces literals in.
+ */
+
+export interface ExtractConstantOptions {
+  /** Line of the literal, 1-based */
+  line: number;
+  /**
+   * Byte column of the literal; only needed when the line has several
+   * numbers that could be extracted
+   */
+  column?: number;
+  /** Name of the constant; inferred from the literal's context by default */
+  name?: string;
+  /** Also replace identical literals elsewhere in the same declaration */
+  all?: boolean;
+  /** Extract a number in any position, not only thresholds and limits */
+  anyPosition?: boolean;
+  /** Other files of the package, whose names the constant must not take */
+  packageFiles?: GoFile[];
+}
+
+export interface ExtractConstantResult extends GoRefactorResult {
+  name: string;
+  /** The literal as written, sign included */
+  value: string;
+  /** Positions of the replaced literals */
+  replaced: { line: number; column: number }[];
+}
+
+// A number with its sign, and the node it is in
+interface Literal {
+  expr: Expr;
+  lit: BasicLit;
+  parent?: Node;
+  grandparent?: Node;
+}
+
+const COMPARISONS = new Set(["==", "!=", "<", "<=", ">", ">="]);
+
+const NUMBER_KINDS = new Set(["int", "float", "imag"]);
+
+const PREDECLARED = new Set([
+  "any",
+  "append",
+  "bool",
+  "byte",
+  "cap",
+  "clear",
+  "close",
+  "complex",
+  "copy",
+  "delete",
+  "error",
+  "false",
+  "float32",
+  "float64",
+  "imag",
+  "int",
+  "iota",
+  "len",
+  "make",
+  "max",
+  "min",
+  "new",
+  "nil",
+  "panic",
+  "print",
+  "println",
+  "real",
+  "recover",
+  "rune",
+  "string",
+  "true",
+  "uint",
+]);
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+function capitalize(word: string): string {
+  return word.charAt(0).toUpperCase() + word.slice(1);
+}
+
+// Words describing an operand, as in `len(item)` → ["item", "Length"]
+function describe(expr: Expr): string[] {
+  const inner = unparen(expr);
+  switch (inner.kind) {
+    case "Ident":
+      return [inner.name];
+    case "SelectorExpr":
+      return [inner.sel.name];
+    case "IndexExpr":
+      return describe(inner.x);
+    case "CallExpr": {
+      const fun = unparen(inner.fun);
+      if (fun.kind === "Ident" && ["len", "cap"].includes(fun.name)) {
+        const [arg] = inner.args;
+        const words = arg ? describe(arg) : [];
+        return [...words, fun.name === "len" ? "Length" : "Capacity"];
+      }
+      return describe(fun);
+    }
+    default:
+      return [];
+  }
+}
+
+function camelCase(words: string[]): string {
+  const name = words
+    .filter((word) => word.length > 0)
+    .map((word, index) => (index === 0 ? word : capitalize(word)))
+    .join("");
+  return name.charAt(0).toLowerCase() + name.slice(1);
+}
+
+class ConstantExtractor {
+  private readonly file: GoFile;
+  private readonly options: ExtractConstantOptions;
+  private readonly literals: Literal[] = [];
+  private readonly owners = new Map<Literal, Decl>();
+
+  constructor(file: GoFile, options: ExtractConstantOptions) {
+    this.file = file;
+    this.options = options;
+    for (const decl of file.decls) {
+      inspect(decl, (node, parents) => {
+        if (node.kind !== "BasicLit" || !NUMBER_KINDS.has(node.litKind)) {
+          return;
+        }
+        // `-5` is one number
+        const unary = parents.at(-1);
+        const signed =
+          unary?.kind === "UnaryExpr" &&
+          ["-", "+"].includes((unary as UnaryExpr).op);
+        const chain = signed ? parents.slice(0, -1) : parents;
+        const literal: Literal = {
+          expr: signed ? (unary as UnaryExpr) : node,
+          lit: node,
+          parent: chain.at(-1),
+          grandparent: chain.at(-2),
+        };
+        // Parentheses around the number do not change its position
+        for (let i = chain.length - 1; i > 0; i--) {
+          if (chain[i].kind !== "ParenExpr") break;
+          literal.parent = chain[i - 1];
+          literal.grandparent = chain[i - 2];
+        }
+        this.literals.push(literal);
+        this.owners.set(literal, decl);
+      });
+    }
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private isThreshold(literal: Literal): boolean {
+    const { parent } = literal;
+    if (parent?.kind === "BinaryExpr") return COMPARISONS.has(parent.op);
+    if (parent?.kind === "CallExpr") {
+      const fun = unparen(parent.fun);
+      return (
+        fun.kind === "Ident" &&
+        fun.name === "make" &&
+        parent.args.indexOf(literal.expr) > 0
+      );
+    }
+    return false;
+  }
+
+  private inConstDecl(literal: Literal): boolean {
+    const decl = this.owners.get(literal);
+    return decl.kind === "GenDecl" && decl.tok === "const";
+  }
+
+  private eligible(literal: Literal): boolean {
+    if (this.inConstDecl(literal)) return false;
+    if (this.options.anyPosition) return true;
+    return this.isThreshold(literal) && !["0", "1"].includes(literal.lit.value);
+  }
+
+  private target(): Literal {
+    const { line, column } = this.options;
+    const onLine = this.literals.filter(
+      (literal) => this.file.sourceMap.line(literal.expr.pos) === line,
+    );
+    const eligible = onLine.filter((literal) => this.eligible(literal));
+    const matches =
+      column === undefined
+        ? eligible.length > 0
+          ? eligible
+          : onLine
+        : onLine.filter((literal) =>
+            [literal.expr.pos, literal.lit.pos].some(
+              (offset) =>
+                this.file.sourceMap.position(offset).column === column,
+            ),
+          );
+    if (matches.length === 0) {
+      throw new GoRefactorError(
+        `There is no number at line ${line}${column === undefined ? "" : `, column ${column}`}`,
+      );
+    }
+    if (matches.length > 1) {
+      throw new GoRefactorError(
+        `Line ${line} has ${matches.length} numbers; give the column of one`,
+      );
+    }
+    const [literal] = matches;
+    if (this.inConstDecl(literal)) {
+      throw new GoRefactorError(
+        `${this.text(literal.expr)} is already part of a constant declaration`,
+      );
+    }
+    if (!this.options.anyPosition && !this.eligible(literal)) {
+      throw new GoRefactorError(
+        `${this.text(literal.expr)} is not used as a threshold or limit; pass anyPosition to extract it anyway`,
+      );
+    }
+    return literal;
+  }
+
+  private inferName(literal: Literal): string {
+    const { parent } = literal;
+    let words: string[] = [];
+    if (parent?.kind === "BinaryExpr" && COMPARISONS.has(parent.op)) {
+      const other = unparen(parent.x) === literal.expr ? parent.y : parent.x;
+      words = [...describe(other), "Limit"];
+    } else if (parent?.kind === "CallExpr") {
+      const index = parent.args.indexOf(literal.expr);
+      words = ["initial", index === 1 ? "Length" : "Capacity"];
+      const assigned = literal.grandparent;
+      if (assigned?.kind === "AssignStmt" || assigned?.kind === "ValueSpec") {
+        const names =
+          assigned.kind === "AssignStmt" ? assigned.lhs : assigned.names;
+        words = [...describe(names[0]), words[1]];
+      }
+    }
+    return words.length > 1 ? camelCase(words) : "magicNumber";
+  }
+
+  // Names the constant could clash with or shadow
+  private takenNames(decls: Decl[]): Set<string> {
+    const taken = new Set(PREDECLARED);
+    const files = [this.file, ...(this.options.packageFiles ?? [])];
+    for (const file of files) {
+      for (const decl of file.decls) {
+        if (decl.kind === "FuncDecl") {
+          if (!decl.recv) taken.add(decl.name.name);
+          continue;
+        }
+        for (const spec of decl.specs) {
+          if (spec.kind === "TypeSpec") taken.add(spec.name.name);
+          if (spec.kind === "ValueSpec") {
+            spec.names.forEach((ident) => taken.add(ident.name));
+          }
+        }
+      }
+    }
+    this.file.imports.forEach((spec) => taken.add(importName(spec)));
+    for (const decl of decls) {
+      if (decl.kind !== "FuncDecl") continue;
+      resolveFunctionScopes(decl).variables.forEach((variable) =>
+        taken.add(variable.name),
+      );
+    }
+    return taken;
+  }
+
+  extract(): ExtractConstantResult {
+    const target = this.target();
+    const value = this.text(target.expr);
+    const owner = this.owners.get(target);
+    const replaced = this.options.all
+      ? this.literals.filter(
+          (literal) =>
+            this.owners.get(literal) === owner &&
+            this.text(literal.expr) === value &&
+            (literal === target || this.eligible(literal)),
+        )
+      : [target];
+
+    const taken = this.takenNames([owner]);
+    let name = this.options.name;
+    if (name !== undefined) {
+      if (!/^[\p{L}_][\p{L}\p{Nd}_]*$/u.test(name) || name === "_") {
+        throw new GoRefactorError(`${name} is not a valid constant name`);
+      }
+      if (taken.has(name)) {
+        throw new GoRefactorError(
+          `${name} is already declared in the package or the function`,
+        );
+      }
+    } else {
+      const base = this.inferName(target);
+      name = base;
+      for (let suffix = 2; taken.has(name); suffix++) name = `${base}${suffix}`;
+    }
+
+    const { sourceMap } = this.file;
+    const declStart = sourceMap.lineStart(
+      sourceMap.line((owner.kind !== "BadDecl" && owner.doc?.pos) || owner.pos),
+    );
+    const edits: TextEdit[] = [
+      {
+        start: declStart,
+        end: declStart,
+        newText: `const ${name} = ${value}\n\n`,
+      },
+      ...replaced.map((literal) => ({
+        start: literal.expr.pos,
+        end: literal.expr.end,
+        newText: name,
+      })),
+    ];
+    return {
+      ...refactorResult(this.file, edits),
+      name,
+      value,
+      replaced: replaced.map((literal) =>
+        sourceMap.position(literal.expr.pos),
+      ),
+    };
+  }
+}
+
+/**
+ * Replace a number with a package-level constant, and optionally every
+ * identical number in the same declaration
+ */
+export function extractConstant(
+  file: GoFile,
+  options: ExtractConstantOptions,
+): ExtractConstantResult {
+  return new ConstantExtractor(file, options).extract();
+}
@@ -0,0 +1,641 @@
+import { TextEdit } from "../diff.js";
+import {
+  BlockStmt,
+  CaseClause,
+  CommClause,
+  FuncDecl,
+  FuncLit,
+  FuncType,
+  GoFile,
+  Node,
+  ReturnStmt,
+  Stmt,
+  forEachChild,
+  inspect,
+} from "./ast.js";
+import { GoTypeInference, isZeroLiteral, zeroValue } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+
+/**
+ * Extract Function
+ * ================
+ * Moves a run of whole statements out of a function into a new function
+ * declared right after it, and replaces them with a call. Variables declared
+ * before the range and used inside it become parameters; variables assigned
+ * inside the range and used after it become results. Early `return`s are
+ * preserved either by returning the call's results directly (when the range
+ * ends the enclosing function) or by threading the error through a trailing
+ * `error` result.
+ */
+
+/**
+ * Lines to extract, 1-based and inclusive
+ */
+export interface ExtractFunctionOptions {
+  startLine: number;
+  endLine: number;
+  /** Name of the new function; defaults to `<function>Extracted` */
+  name?: string;
+}
+
+export interface ExtractedVariable {
+  name: string;
+  type: string;
+}
+
+export interface ExtractFunctionResult extends GoRefactorResult {
+  name: string;
+  /** Receiver of the new method, when the range uses the receiver */
+  receiver?: string;
+  parameters: ExtractedVariable[];
+  /** Values returned to the caller, not counting a trailing error */
+  results: ExtractedVariable[];
+  /** True when early returns are threaded through an error result */
+  returnsError: boolean;
+}
+
+type StatementList = {
+  container: BlockStmt | CaseClause | CommClause;
+  list: Stmt[];
+};
+
+type EnclosingFunction = FuncDecl | FuncLit;
+
+function statementLists(root: Node): StatementList[] {
+  const lists: StatementList[] = [];
+  inspect(root, (node) => {
+    if (node.kind === "BlockStmt") {
+      lists.push({ container: node, list: node.list });
+    } else if (node.kind === "CaseClause" || node.kind === "CommClause") {
+      lists.push({ container: node, list: node.body });
+    }
+  });
+  return lists;
+}
+
+function lowerFirst(name: string): string {
+  return name.charAt(0).toLowerCase() + name.slice(1);
+}
+
+class FunctionExtractor {
+  private readonly file: GoFile;
+  private readonly options: ExtractFunctionOptions;
+  private decl: FuncDecl;
+  private fn: EnclosingFunction;
+  private selection: StatementList;
+  private statements: Stmt[];
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+  private returns: ReturnStmt[] = [];
+
+  constructor(file: GoFile, options: ExtractFunctionOptions) {
+    this.file = file;
+    this.options = options;
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private get start(): number {
+    return this.statements[0].pos;
+  }
+
+  private get end(): number {
+    return this.statements[this.statements.length - 1].end;
+  }
+
+  private inRange(node: Node): boolean {
+    return node.pos >= this.start && node.end <= this.end;
+  }
+
+  private locate(): void {
+    const { startLine, endLine } = this.options;
+    if (startLine > endLine) {
+      throw new GoRefactorError(
+        `Start line ${startLine} is after end line ${endLine}`,
+      );
+    }
+    this.decl = this.file.decls.find(
+      (decl): decl is FuncDecl =>
+        decl.kind === "FuncDecl" &&
+        decl.body !== undefined &&
+        this.line(decl.body.lbrace) < startLine &&
+        this.line(decl.body.rbrace) > endLine,
+    );
+    if (!this.decl) {
+      throw new GoRefactorError(
+        `Lines ${startLine}-${endLine} are not inside a function body`,
+      );
+    }
+    const recv = this.decl.recv?.list[0]?.type;
+    const generic =
+      this.decl.type.typeParams ||
+      (recv?.kind === "StarExpr" ? recv.x : recv)?.kind === "IndexExpr" ||
+      (recv?.kind === "StarExpr" ? recv.x : recv)?.kind === "IndexListExpr";
+    if (generic) {
+      throw new GoRefactorError(
+        `Extracting from generic function ${this.decl.name.name} is not supported`,
+      );
+    }
+    const overlaps = (stmt: Stmt) =>
+      this.line(stmt.pos) <= endLine && this.line(stmt.end) >= startLine;
+    const contained = (stmt: Stmt) =>
+      this.line(stmt.pos) >= startLine && this.line(stmt.end) <= endLine;
+    const encloses = ({ container }: StatementList) =>
+      this.line(container.pos) <= startLine &&
+      this.line(container.end) >= endLine;
+
+    // The outermost list whose statements the range covers exactly
+    for (const candidate of statementLists(this.decl.body)) {
+      const selected = candidate.list.filter(overlaps);
+      if (
+        selected.length > 0 &&
+        selected.every(contained) &&
+        encloses(candidate)
+      ) {
+        this.selection = candidate;
+        this.statements = selected;
+        break;
+      }
+    }
+    if (!this.selection) {
+      throw new GoRefactorError(
+        `Lines ${startLine}-${endLine} do not cover complete statements`,
+      );
+    }
+
+    // The innermost function literal around the range owns its returns
+    this.fn = this.decl;
+    inspect(this.decl.body, (node) => {
+      const encloses = node.pos < this.start && node.end >= this.end;
+      if (encloses && node.kind === "FuncLit") this.fn = node;
+      return encloses;
+    });
+  }
+
+  private validate(): void {
+    const labels = new Set<string>();
+    for (const stmt of this.statements) {
+      inspect(stmt, (node) => {
+        if (node.kind === "LabeledStmt") labels.add(node.label.name);
+      });
+    }
+
+    const visit = (
+      node: Node,
+      depth: { loops: number; breakable: number; switches: number },
+    ) => {
+      switch (node.kind) {
+        case "FuncLit":
+          // Statements in a literal's body belong to the literal
+          return;
+        case "ReturnStmt":
+          this.returns.push(node);
+          break;
+        case "DeferStmt":
+          throw new GoRefactorError(
+            `Cannot extract a defer statement at line ${this.line(node.pos)}; it would run when the new function returns`,
+          );
+        case "BranchStmt": {
+          const where = `line ${this.line(node.pos)}`;
+          if (node.tok === "goto") {
+            throw new GoRefactorError(`Cannot extract goto at ${where}`);
+          }
+          if (node.label) {
+            if (!labels.has(node.label.name)) {
+              throw new GoRefactorError(
+                `${node.tok} at ${where} targets label ${node.label.name} outside the range`,
+              );
+            }
+          } else if (
+            (node.tok === "continue" && depth.loops === 0) ||
+            (node.tok === "break" && depth.breakable === 0) ||
+            (node.tok === "fallthrough" && depth.switches === 0)
+          ) {
+            throw new GoRefactorError(
+              `${node.tok} at ${where} targets a statement outside the range`,
+            );
+          }
+          break;
+        }
+        case "ForStmt":
+        case "RangeStmt":
+          depth = {
+            ...depth,
+            loops: depth.loops + 1,
+            breakable: depth.breakable + 1,
+          };
+          break;
+        case "SwitchStmt":
+        case "TypeSwitchStmt":
+          depth = {
+            ...depth,
+            breakable: depth.breakable + 1,
+            switches: depth.switches + 1,
+          };
+          break;
+        case "SelectStmt":
+          depth = { ...depth, breakable: depth.breakable + 1 };
+          break;
+      }
+      forEachChild(node, (child) => visit(child, depth));
+    };
+    this.statements.forEach((stmt) =>
+      visit(stmt, { loops: 0, breakable: 0, switches: 0 }),
+    );
+  }
+
+  /**
+   * Whether a variable is read or written outside the range in a way that
+   * observes a value assigned inside it
+   */
+  private usedAfter(variable: GoVariable): boolean {
+    // Loops around the range run it again, so any read in the loop, the
+    // range's own included, may see the new value
+    const loops: Node[] = [];
+    inspect(this.fn.body, (node) => {
+      if (
+        (node.kind === "ForStmt" || node.kind === "RangeStmt") &&
+        node.pos < this.start &&
+        node.end >= this.end &&
+        node.pos > variable.ident.pos
+      ) {
+        loops.push(node);
+      }
+    });
+    return this.scopes.references.some(
+      ({ ident, variable: target, read }) =>
+        target === variable &&
+        ((!this.inRange(ident) && ident.pos >= this.end) ||
+          ((read || !this.inRange(ident)) &&
+            loops.some(
+              (loop) => ident.pos >= loop.pos && ident.end <= loop.end,
+            ))),
+    );
+  }
+
+  private typeOf(variable: GoVariable): string {
+    const type = this.types.typeOfVariable(variable);
+    if (type === undefined) {
+      throw new GoRefactorError(
+        `Cannot infer the type of ${variable.name}; declare it with an explicit type first`,
+      );
+    }
+    return type;
+  }
+
+  private resultTypes(type: FuncType): string[] {
+    const types: string[] = [];
+    for (const field of type.results?.list ?? []) {
+      const count = Math.max(field.names.length, 1);
+      for (let i = 0; i < count; i++) types.push(this.text(field.type));
+    }
+    return types;
+  }
+
+  private visibleAtCall(name: string): GoVariable | undefined {
+    let best: GoVariable | undefined;
+    for (const variable of this.scopes.variables) {
+      const scope = variable.scope.node;
+      if (
+        variable.name === name &&
+        variable.ident.pos < this.start &&
+        scope.pos <= this.start &&
+        scope.end >= this.end &&
+        (!best || scope.pos >= best.scope.node.pos)
+      ) {
+        best = variable;
+      }
+    }
+    return best;
+  }
+
+  extract(): ExtractFunctionResult {
+    this.locate();
+    this.validate();
+    this.scopes = resolveFunctionScopes(this.decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+
+    const funcName = this.decl.name.name;
+    const name = this.options.name ?? `${lowerFirst(funcName)}Extracted`;
+    const taken = this.file.decls.some(
+      (decl) =>
+        (decl.kind === "FuncDecl" && !decl.recv && decl.name.name === name) ||
+        (decl.kind === "GenDecl" &&
+          decl.specs.some((spec) =>
+            spec.kind === "ValueSpec"
+              ? spec.names.some((ident) => ident.name === name)
+              : spec.kind === "TypeSpec" && spec.name.name === name,
+          )),
+    );
+    if (taken) {
+      throw new GoRefactorError(`${name} is already declared in the file`);
+    }
+
+    // Parameters: variables declared before the range and used in it
+    let receiver: GoVariable | undefined;
+    const parameters: GoVariable[] = [];
+    const written = new Set<GoVariable>();
+    for (const reference of this.scopes.references) {
+      const { variable } = reference;
+      if (!this.inRange(reference.ident) || this.inRange(variable.ident)) {
+        continue;
+      }
+      if (reference.write) written.add(variable);
+      if (variable.kind === "receiver") {
+        receiver = variable;
+      } else if (!parameters.includes(variable)) {
+        parameters.push(variable);
+      }
+    }
+    parameters.sort((a, b) => a.ident.pos - b.ident.pos);
+
+    // Results: assigned pre-existing variables and top-level declarations
+    // that are still needed afterwards
+    const topLevel = this.scopes.variables.filter(
+      (variable) =>
+        this.inRange(variable.ident) &&
+        this.statements.some(
+          (stmt) =>
+            (stmt.kind === "AssignStmt" || stmt.kind === "DeclStmt") &&
+            variable.ident.pos >= stmt.pos &&
+            variable.ident.end <= stmt.end &&
+            !this.insideFuncLit(stmt, variable.ident),
+        ),
+    );
+    const outputs = [
+      ...parameters.filter((variable) => written.has(variable)),
+      ...topLevel,
+    ].filter((variable) => this.usedAfter(variable));
+
+    const parameterList = parameters.map((variable) => ({
+      name: variable.name,
+      type: this.typeOf(variable),
+    }));
+    const results = outputs.map((variable) => ({
+      name: variable.name,
+      type: this.typeOf(variable),
+    }));
+
+    return this.render(name, receiver, parameterList, results, outputs);
+  }
+
+  private insideFuncLit(stmt: Stmt, node: Node): boolean {
+    let inside = false;
+    inspect(stmt, (child) => {
+      if (
+        child.kind === "FuncLit" &&
+        child.pos <= node.pos &&
+        child.end >= node.end
+      ) {
+        inside = true;
+      }
+      return !inside;
+    });
+    return inside;
+  }
+
+  private render(
+    name: string,
+    receiver: GoVariable | undefined,
+    parameters: ExtractedVariable[],
+    results: ExtractedVariable[],
+    outputs: GoVariable[],
+  ): ExtractFunctionResult {
+    const { source, sourceMap } = this.file;
+    const enclosingResults = this.resultTypes(this.fn.type);
+    const namedResults = this.fn.type.results?.list.some(
+      (field) => field.names.length > 0,
+    );
+    const last = this.statements[this.statements.length - 1];
+    const endsFunction =
+      this.selection.container === this.fn.body &&
+      last === this.fn.body.list[this.fn.body.list.length - 1];
+    const tail =
+      this.returns.length > 0 &&
+      (last.kind === "ReturnStmt" || endsFunction);
+    const returnsError = this.returns.length > 0 && !tail;
+
+    if (namedResults && this.returns.some((ret) => ret.results.length === 0)) {
+      throw new GoRefactorError(
+        "Cannot extract a bare return from a function with named results",
+      );
+    }
+    if (tail && results.length > 0) {
+      const names = results.map((result) => result.name).join(", ");
+      throw new GoRefactorError(
+        `The range returns from ${this.decl.name.name} but also assigns ${names}, which is used afterwards`,
+      );
+    }
+
+    // Rewrite the range's returns so errors reach the caller
+    const bodyEdits: TextEdit[] = [];
+    let callerZeros: string[] = [];
+    if (returnsError) {
+      if (
+        enclosingResults.length === 0 ||
+        enclosingResults[enclosingResults.length - 1] !== "error"
+      ) {
+        throw new GoRefactorError(
+          `The range returns early, but ${this.decl.name.name} does not end with an error result to propagate it`,
+        );
+      }
+      for (const ret of this.returns) {
+        const values = ret.results;
+        const errExpr = values[values.length - 1];
+        if (
+          values.length !== enclosingResults.length ||
+          !values.slice(0, -1).every(isZeroLiteral) ||
+          (errExpr.kind === "Ident" && errExpr.name === "nil")
+        ) {
+          throw new GoRefactorError(
+            `The return at line ${this.line(ret.pos)} does not return an error with zero values, so it cannot be threaded through the new function`,
+          );
+        }
+        const zeros = results.map((result) =>
+          zeroValue(result.type, (type) => this.types.underlying(type)),
+        );
+        bodyEdits.push({
+          start: ret.pos,
+          end: ret.end,
+          newText: `return ${[...zeros, this.text(errExpr)].join(", ")}`,
+        });
+      }
+      callerZeros = this.returns[0].results
+        .slice(0, -1)
+        .map((expr) => this.text(expr));
+    }
+
+    // Body: the selected lines re-indented one level inside the new function
+    const lineStart = sourceMap.lineStart(this.line(this.start));
+    const indent = source.slice(lineStart, this.start);
+    const shifted = bodyEdits.map((edit) => ({
+      ...edit,
+      start: edit.start - lineStart,
+      end: edit.end - lineStart,
+    }));
+    let bodyText = source.slice(lineStart, this.end);
+    for (const edit of [...shifted].sort((a, b) => b.start - a.start)) {
+      bodyText =
+        bodyText.slice(0, edit.start) + edit.newText + bodyText.slice(edit.end);
+    }
+    const bodyLines = bodyText.split("\n").map((line) => {
+      if (line.trim() === "") return "";
+      const relative = line.startsWith(indent)
+        ? line.slice(indent.length)
+        : line;
+      return "\t" + relative;
+    });
+
+    const resultTypes = tail
+      ? enclosingResults
+      : [
+          ...results.map((result) => result.type),
+          ...(returnsError ? ["error"] : []),
+        ];
+    if (!tail && resultTypes.length > 0) {
+      const values = [
+        ...results.map((result) => result.name),
+        ...(returnsError ? ["nil"] : []),
+      ];
+      bodyLines.push(`\treturn ${values.join(", ")}`);
+    }
+    const signature =
+      resultTypes.length === 0
+        ? ""
+        : resultTypes.length === 1
+          ? ` ${resultTypes[0]}`
+          : ` (${resultTypes.join(", ")})`;
+    const receiverText = receiver
+      ? `(${this.text(this.decl.recv.list[0])}) `
+      : "";
+    const params = parameters
+      .map((param) => `${param.name} ${param.type}`)
+      .join(", ");
+    const declaration = [
+      `func ${receiverText}${name}(${params})${signature} {`,
+      ...bodyLines,
+      "}",
+    ].join("\n");
+
+    // Call site
+    const callee = receiver ? `${receiver.name}.${name}` : name;
+    const args = parameters.map((param) => param.name).join(", ");
+    const call = `${callee}(${args})`;
+    const lines: string[] = [];
+    if (tail) {
+      if (enclosingResults.length > 0) {
+        lines.push(`return ${call}`);
+      } else {
+        lines.push(call);
+        if (!endsFunction) lines.push("return");
+      }
+    } else {
+      const names = results.map((result) => result.name);
+      if (returnsError) {
+        const propagate = [`${[...callerZeros, "err"].join(", ")}`];
+        if (names.length === 0) {
+          lines.push(
+            `if err := ${call}; err != nil {`,
+            `\treturn ${propagate}`,
+            "}",
+          );
+        } else {
+          lines.push(
+            ...this.assignment([...names, "err"], outputs, call, results),
+            "if err != nil {",
+            `\treturn ${propagate}`,
+            "}",
+          );
+        }
+      } else if (names.length > 0) {
+        lines.push(...this.assignment(names, outputs, call, results));
+      } else {
+        lines.push(call);
+      }
+    }
+    const callText = lines
+      .map((line, index) => (index === 0 ? line : indent + line))
+      .join("\n");
+
+    const edits: TextEdit[] = [
+      { start: this.start, end: this.end, newText: callText },
+      {
+        start: this.decl.end,
+        end: this.decl.end,
+        newText: `\n\n${declaration}`,
+      },
+    ];
+    return {
+      ...refactorResult(this.file, edits),
+      name,
+      receiver: receiver ? this.text(this.decl.recv.list[0]) : undefined,
+      parameters,
+      results,
+      returnsError,
+    };
+  }
+
+  /**
+   * Assign the call's results at the call site without shadowing variables
+   * declared in an enclosing scope
+   */
+  private assignment(
+    names: string[],
+    outputs: GoVariable[],
+    call: string,
+    results: ExtractedVariable[],
+  ): string[] {
+    const local = this.selection.container;
+    const fresh: { name: string; type: string }[] = [];
+    let shadows = false;
+    names.forEach((name, index) => {
+      const output = outputs[index];
+      if (output && this.inRange(output.ident)) {
+        fresh.push(results[index]);
+        return;
+      }
+      const existing = output ?? this.visibleAtCall(name);
+      if (!existing) {
+        fresh.push({ name, type: "error" });
+      } else if (existing.scope.node !== local) {
+        shadows = true;
+      }
+    });
+    const lhs = names.join(", ");
+    if (fresh.length === 0) {
+      return [`${lhs} = ${call}`];
+    }
+    if (!shadows) {
+      return [`${lhs} := ${call}`];
+    }
+    return [
+      ...fresh.map((variable) => `var ${variable.name} ${variable.type}`),
+      `${lhs} = ${call}`,
+    ];
+  }
+}
+
+/**
+ * Extract the statements on the given lines into a new function
+ */
+export function extractFunction(
+  file: GoFile,
+  options: ExtractFunctionOptions,
+): ExtractFunctionResult {
+  return new FunctionExtractor(file, options).extract();
+}
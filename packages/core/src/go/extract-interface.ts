@@ -0,0 +1,286 @@
+import { TextEdit } from "../diff.js";
+import { FuncDecl, GenDecl, GoFile, Node, TypeSpec, inspect } from "./ast.js";
+import { parseGoFile } from "./parser.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+import { goSignature } from "./signature.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Extract Interface
+ * =================
+ * Declares an interface holding the exported method set of a struct, so
+ * code can depend on the behaviour instead of the concrete type and tests
+ * can pass a fake. The interface goes right after the struct, with each
+ * method's doc comment and its signature as `go/types` prints it, followed
+ * by `var _ I = (*T)(nil)` so the compiler keeps checking that the struct
+ * implements it. Methods with pointer receivers are only in the method set
+ * of `*T`, so the assertion and the consumers use `*T` when any has one.
+ *
+ * Consumers, functions or methods named in the options, then take the
+ * interface wherever a parameter was `T` or `*T`. A consumer that uses such
+ * a parameter for anything but calling the interface's methods, such as
+ * reading a field or passing it on, is refused, since the interface could
+ * not stand in for it. Only methods declared in the file are seen.
+ */
+
+export interface ExtractInterfaceOptions {
+  /** Struct whose exported methods the interface declares */
+  type: string;
+  /** Name of the interface (default: the type name + `er`) */
+  interfaceName?: string;
+  /**
+   * Functions, or `Type.Method` for methods, whose parameters of the struct
+   * type should take the interface instead (default: none)
+   */
+  consumers?: string[];
+}
+
+export interface ExtractInterfaceResult extends GoRefactorResult {
+  interfaceName: string;
+  /** Methods of the interface, in declaration order */
+  methods: string[];
+  /** The type implementing the interface: `T`, or `*T` */
+  implementation: string;
+  /** Parameters now of the interface type, as `Consumer.param` */
+  rewritten: string[];
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+class InterfaceExtractor {
+  private readonly file: GoFile;
+  private readonly options: ExtractInterfaceOptions;
+  private readonly parents = new Map<Node, Node>();
+  private decl: GenDecl;
+  private spec: TypeSpec;
+  private methods: FuncDecl[] = [];
+
+  constructor(file: GoFile, options: ExtractInterfaceOptions) {
+    this.file = file;
+    this.options = options;
+    inspect(file, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+    });
+  }
+
+  private get type(): string {
+    return this.options.type;
+  }
+
+  private locate(): void {
+    for (const decl of this.file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+      const spec = decl.specs.find(
+        (candidate): candidate is TypeSpec =>
+          candidate.kind === "TypeSpec" && candidate.name.name === this.type,
+      );
+      if (spec) [this.decl, this.spec] = [decl, spec];
+    }
+    if (!this.spec) {
+      throw new GoRefactorError(`${this.type} is not declared in the file`);
+    }
+    if (this.spec.type.kind !== "StructType" || this.spec.isAlias) {
+      throw new GoRefactorError(`${this.type} is not a struct`);
+    }
+    if (this.spec.typeParams) {
+      throw new GoRefactorError(
+        `Extracting an interface from generic type ${this.type} is not supported`,
+      );
+    }
+    this.methods = this.file.decls.filter(
+      (decl): decl is FuncDecl =>
+        decl.kind === "FuncDecl" &&
+        decl.recv !== undefined &&
+        isExportedName(decl.name.name) &&
+        baseTypeName(decl.recv.list[0].type).name === this.type,
+    );
+    if (this.methods.length === 0) {
+      throw new GoRefactorError(`${this.type} has no exported methods`);
+    }
+  }
+
+  private interfaceName(): string {
+    const name = this.options.interfaceName ?? `${this.type}er`;
+    const declared = this.file.decls.some((decl) => {
+      if (decl.kind === "FuncDecl") {
+        return !decl.recv && decl.name.name === name;
+      }
+      return decl.specs.some((spec) =>
+        spec.kind === "TypeSpec"
+          ? spec.name.name === name
+          : spec.kind === "ValueSpec" &&
+            spec.names.some((ident) => ident.name === name),
+      );
+    });
+    if (declared) {
+      throw new GoRefactorError(`${name} is already declared in the file`);
+    }
+    return name;
+  }
+
+  private declaration(name: string, implementation: string): string {
+    const members = this.methods.map((method) => {
+      const doc = (method.doc?.list ?? []).map(
+        (comment) => `\t${comment.text}`,
+      );
+      const { text } = goSignature(this.file, method.type);
+      return [...doc, `\t${method.name.name}${text}`].join("\n");
+    });
+    const value = implementation.startsWith("*")
+      ? `(${implementation})(nil)`
+      : `${implementation}{}`;
+    return [
+      `// ${name} holds the exported methods of ${this.type}`,
+      `type ${name} interface {`,
+      ...members,
+      "}",
+      "",
+      `var _ ${name} = ${value}`,
+    ].join("\n");
+  }
+
+  // Parameters of a consumer to retype, refusing uses the interface lacks
+  private consumerEdits(
+    consumer: string,
+    name: string,
+    implementation: string,
+  ): { params: string[]; edits: TextEdit[] } {
+    const decl = this.file.decls.find(
+      (candidate): candidate is FuncDecl =>
+        candidate.kind === "FuncDecl" && qualifiedName(candidate) === consumer,
+    );
+    if (!decl) {
+      throw new GoRefactorError(`${consumer} is not declared in the file`);
+    }
+    const methods = new Set(this.methods.map((method) => method.name.name));
+    const scopes = resolveFunctionScopes(decl);
+    const params: string[] = [];
+    const edits: TextEdit[] = [];
+    for (const field of decl.type.params.list) {
+      const type = field.type;
+      const isPointer = type.kind === "StarExpr";
+      const base = isPointer ? type.x : type;
+      if (base.kind !== "Ident" || base.name !== this.type) continue;
+      if (isPointer !== implementation.startsWith("*")) {
+        throw new GoRefactorError(
+          `${consumer} takes ${isPointer ? "*" : ""}${this.type}, which does not implement ${name}`,
+        );
+      }
+      for (const ident of field.names) {
+        this.checkUses(consumer, scopes.resolved.get(ident), scopes, methods);
+        params.push(`${consumer}.${ident.name}`);
+      }
+      edits.push({ start: type.pos, end: type.end, newText: name });
+    }
+    if (edits.length === 0) {
+      throw new GoRefactorError(
+        `${consumer} has no parameter of type ${implementation}`,
+      );
+    }
+    return { params, edits };
+  }
+
+  private checkUses(
+    consumer: string,
+    param: GoVariable,
+    scopes: GoFunctionScopes,
+    methods: Set<string>,
+  ): void {
+    for (const { ident, variable } of scopes.references) {
+      if (variable !== param || ident === param.ident) continue;
+      const parent = this.parents.get(ident);
+      if (
+        parent?.kind === "SelectorExpr" &&
+        parent.x === ident &&
+        methods.has(parent.sel.name)
+      ) {
+        continue;
+      }
+      const use =
+        parent?.kind === "SelectorExpr" && parent.x === ident
+          ? `${param.name}.${parent.sel.name}`
+          : param.name;
+      throw new GoRefactorError(
+        `${consumer} uses ${use} on line ${this.file.sourceMap.line(ident.pos)}, which the interface cannot stand in for`,
+      );
+    }
+  }
+
+  // The rewritten source must still parse and the struct still declare
+  // every method of the interface with the same signature
+  private verify(source: string, name: string): void {
+    const file = parseGoFile(source, this.file.filePath);
+    const declared = new Map<string, string>();
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl" || !decl.recv) continue;
+      if (baseTypeName(decl.recv.list[0].type).name !== this.type) continue;
+      declared.set(decl.name.name, goSignature(file, decl.type).text);
+    }
+    for (const method of this.methods) {
+      const expected = goSignature(this.file, method.type).text;
+      if (declared.get(method.name.name) !== expected) {
+        throw new GoRefactorError(
+          `${this.type} no longer implements ${name}: ${method.name.name}${expected} is missing`,
+        );
+      }
+    }
+  }
+
+  extract(): ExtractInterfaceResult {
+    this.locate();
+    const name = this.interfaceName();
+    const pointer = this.methods.some(
+      (method) => method.recv.list[0].type.kind === "StarExpr",
+    );
+    const implementation = `${pointer ? "*" : ""}${this.type}`;
+
+    const rewritten: string[] = [];
+    const edits: TextEdit[] = [
+      {
+        start: this.decl.end,
+        end: this.decl.end,
+        newText: `\n\n${this.declaration(name, implementation)}`,
+      },
+    ];
+    for (const consumer of this.options.consumers ?? []) {
+      const found = this.consumerEdits(consumer, name, implementation);
+      rewritten.push(...found.params);
+      edits.push(...found.edits);
+    }
+
+    const result = refactorResult(this.file, edits);
+    this.verify(result.source, name);
+    return {
+      ...result,
+      interfaceName: name,
+      methods: this.methods.map((method) => method.name.name),
+      implementation,
+      rewritten,
+    };
+  }
+}
+
+/**
+ * Declare an interface with the exported methods of a struct and optionally
+ * make consumers depend on it
+ */
+export function extractInterface(
+  file: GoFile,
+  options: ExtractInterfaceOptions,
+): ExtractInterfaceResult {
+  return new InterfaceExtractor(file, options).extract();
+}
@@ -0,0 +1,43 @@
+/**
+ * Go Findings
+ * ===========
+ * The common shape of issues reported by the Go lint passes, so reports,
+ * baselines and output formats can treat every pass the same way.
+ */
+
+export type GoSeverity = "high" | "medium" | "low";
+
+export interface GoFinding {
+  /** Identifier of the check, e.g. `ignored-error` */
+  rule: string;
+  severity: GoSeverity;
+  filePath: string;
+  line: number;
+  column: number;
+  message: string;
+  /** Replacement code showing how to address the finding */
+  fix?: string;
+}
+
+/**
+ * Compare strings by code point, so the order does not depend on the locale
+ */
+export function compareCodePoints(a: string, b: string): number {
+  return a < b ? -1 : a > b ? 1 : 0;
+}
+
+/**
+ * Order findings by file, then position, then rule and message. The order is
+ * total, so findings collected in any order, for example by passes running
+ * concurrently, always come out the same.
+ */
+export function sortFindings<T extends GoFinding>(findings: T[]): T[] {
+  return findings.sort(
+    (a, b) =>
+      compareCodePoints(a.filePath, b.filePath) ||
+      a.line - b.line ||
+      a.column - b.column ||
+      compareCodePoints(a.rule, b.rule) ||
+      compareCodePoints(a.message, b.message),
+  );
+}
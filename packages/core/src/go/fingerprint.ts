@@ -0,0 +1,109 @@
+import { createHash } from "crypto";
+import * as path from "path";
+import { FuncDecl, GoFile } from "./ast.js";
+import { GoFinding } from "./findings.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Go Finding Fingerprints
+ * =======================
+ * A short identity for a finding that survives edits elsewhere in the code,
+ * for tools tracking findings across runs. The fingerprint hashes the rule,
+ * the qualified name of the declaration holding the finding and the text of
+ * the line it points at with whitespace collapsed. Line numbers, columns and
+ * messages (which mention line numbers) are left out, so adding code above a
+ * finding or reindenting its line keeps the fingerprint, while editing the
+ * offending line itself changes it. Findings outside any declaration, such
+ * as unused imports, count the file name as their declaration.
+ *
+ * Two findings of one rule on identical lines of one declaration share a
+ * fingerprint; {@link GoFingerprinter} numbers them in source order.
+ */
+
+/**
+ * The top-level declaration a line falls in, by name: `Type.Method` for
+ * methods, `var a,b` for a declaration of values, and empty outside any
+ */
+export function goFindingSymbol(file: GoFile, line: number): string {
+  for (const decl of file.decls) {
+    const start = file.sourceMap.line(decl.doc?.pos ?? decl.pos);
+    if (line < start || line > file.sourceMap.line(decl.end)) continue;
+    if (decl.kind === "FuncDecl") return functionName(decl);
+    if (decl.kind !== "GenDecl") return "";
+    const names = decl.specs.flatMap((spec) =>
+      spec.kind === "TypeSpec"
+        ? [spec.name.name]
+        : spec.kind === "ValueSpec"
+          ? spec.names.map((ident) => ident.name)
+          : [],
+    );
+    return names.length > 0 ? `${decl.tok} ${names.join(",")}` : decl.tok;
+  }
+  return "";
+}
+
+function functionName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+/**
+ * The line a finding points at with whitespace collapsed, so reindenting
+ * keeps it
+ */
+export function goFindingSnippet(file: GoFile, line: number): string {
+  const { source, sourceMap } = file;
+  const start = sourceMap.lineStart(line);
+  const end = source.indexOf("\n", start);
+  return source
+    .slice(start, end < 0 ? source.length : end)
+    .trim()
+    .replace(/\s+/g, " ");
+}
+
+function hash(text: string): string {
+  return createHash("sha256").update(text).digest("hex").slice(0, 16);
+}
+
+/**
+ * The fingerprint of a finding in a parsed file. Without the file only the
+ * rule and file name are known, and the fingerprint says no more than that.
+ */
+export function goFindingFingerprint(
+  finding: GoFinding,
+  file: GoFile | undefined,
+): string {
+  const symbol = file && goFindingSymbol(file, finding.line);
+  const qualified = symbol
+    ? `${file.packageName.name}.${symbol}`
+    : path.basename(finding.filePath);
+  const snippet = file ? goFindingSnippet(file, finding.line) : "";
+  return hash([finding.rule, qualified, snippet].join("\0"));
+}
+
+/**
+ * Fingerprints findings of a set of files, numbering findings that would
+ * otherwise share one so every fingerprint of a run is unique. The first of
+ * them keeps the plain fingerprint. Give it findings in source order, as
+ * {@link sortFindings} leaves them, for the numbers to be stable.
+ */
+export class GoFingerprinter {
+  private readonly files: Map<string, GoFile>;
+  private readonly occurrences = new Map<string, number>();
+
+  constructor(files: GoFile[]) {
+    this.files = new Map(files.map((file) => [file.filePath, file]));
+  }
+
+  fingerprint(finding: GoFinding): string {
+    const plain = goFindingFingerprint(
+      finding,
+      this.files.get(finding.filePath),
+    );
+    const occurrence = this.occurrences.get(plain) ?? 0;
+    this.occurrences.set(plain, occurrence + 1);
+    return occurrence === 0 ? plain : hash(`${plain}\0${occurrence}`);
+  }
+}
@@ -0,0 +1,227 @@
+import {
+  CallExpr,
+  Expr,
+  FuncDecl,
+  GoFile,
+  Ident,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importName } from "./imports.js";
+import { groupGoPackages } from "./package.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Flag Arguments
+ * ==============
+ * A bool parameter that picks one of two things a function does makes its
+ * calls unreadable: `Export(records, true)` does not say what `true` means.
+ * Two functions named for what they do say it at every call. This pass flags
+ * bool parameters whose only uses are the conditions of `if` statements at
+ * the top of the function body, plain or negated, where the `if` splits the
+ * function into two behaviors: it has an `else`, or it returns early with
+ * more code after it. It also reports each call in the package passing the
+ * parameter a literal `true` or `false`, the call sites a split would make
+ * readable.
+ *
+ * A bool that is stored, returned, passed on or combined with another
+ * condition is data rather than a switch, and one that only guards an extra
+ * step, such as logging, selects no alternative behavior; neither is
+ * flagged. Calls are resolved by name within the package: a method is only
+ * matched when no other method of the package shares its name.
+ */
+
+export interface GoFlagArgumentFinding extends GoFinding {
+  rule: "flag-argument";
+  /** Name of the function, `Type.Method` for methods */
+  function: string;
+  parameter: string;
+  /** The parameter's declaration, or a call passing it a literal */
+  site: "declaration" | "call";
+  /** The literal passed, at call sites */
+  value?: boolean;
+}
+
+interface Flag {
+  file: GoFile;
+  decl: FuncDecl;
+  param: Ident;
+  /** Position among the function's parameters */
+  index: number;
+  /** Number of parameters calls pass */
+  arity: number;
+}
+
+function functionName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+// The flag an `if` condition tests, plain or negated
+function testedIdent(cond: Expr): Ident | undefined {
+  let expr = unparen(cond);
+  if (expr.kind === "UnaryExpr" && expr.op === "!") expr = unparen(expr.x);
+  return expr.kind === "Ident" ? expr : undefined;
+}
+
+// Bool parameters of a function that only choose between two behaviors
+function flagParams(file: GoFile, decl: FuncDecl): Flag[] {
+  if (!decl.body || decl.type.params.list.some((f) => f.names.length === 0)) {
+    return [];
+  }
+  const params = decl.type.params.list.flatMap((field) =>
+    field.names.map((ident) => ({ ident, field })),
+  );
+  const scopes = resolveFunctionScopes(decl);
+  const statements = decl.body.list;
+  // Parameters tested by the top-level `if` statements splitting the body
+  const tested = new Set<Ident>();
+  statements.forEach((stmt, index) => {
+    if (stmt.kind !== "IfStmt" || stmt.init || stmt.body.list.length === 0) {
+      return;
+    }
+    const ident = testedIdent(stmt.cond);
+    const variable = ident && scopes.resolved.get(ident);
+    if (variable?.kind !== "param") return;
+    const returns = stmt.body.list.at(-1).kind === "ReturnStmt";
+    if (!stmt.else && !(returns && index < statements.length - 1)) return;
+    tested.add(ident);
+  });
+
+  const flags: Flag[] = [];
+  params.forEach(({ ident, field }, index) => {
+    if (
+      ident.name === "_" ||
+      field.type.kind !== "Ident" ||
+      field.type.name !== "bool"
+    ) {
+      return;
+    }
+    const variable = scopes.resolved.get(ident);
+    const uses = scopes.references.filter(
+      (reference) => reference.variable === variable,
+    );
+    const tests = [...tested].filter(
+      (test) => scopes.resolved.get(test) === variable,
+    );
+    if (tests.length === 0 || uses.length !== tests.length) return;
+    flags.push({ file, decl, param: ident, index, arity: params.length });
+  });
+  return flags;
+}
+
+// The literal `true` or `false` an argument is, if it is one
+function literalBool(arg: Expr, shadowed: (ident: Ident) => boolean) {
+  const expr = unparen(arg);
+  return expr.kind === "Ident" &&
+    (expr.name === "true" || expr.name === "false") &&
+    !shadowed(expr)
+    ? expr.name === "true"
+    : undefined;
+}
+
+/**
+ * Find bool parameters that switch a function between two behaviors, and the
+ * calls passing them literals
+ */
+export function findFlagArguments(files: GoFile[]): GoFlagArgumentFinding[] {
+  const findings: GoFlagArgumentFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const decls = group.flatMap((file) =>
+      file.decls.filter((decl): decl is FuncDecl => decl.kind === "FuncDecl"),
+    );
+    const methodNames = decls
+      .filter((decl) => decl.recv)
+      .map((decl) => decl.name.name);
+    // Flags by the name calls use: the function's, or a unique method name
+    const byCallee = new Map<string, Flag[]>();
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "FuncDecl") continue;
+        const flags = flagParams(file, decl);
+        const fn = functionName(decl);
+        for (const flag of flags) {
+          findings.push({
+            rule: "flag-argument",
+            severity: "low",
+            filePath: file.filePath,
+            ...file.sourceMap.position(flag.param.pos),
+            message: `${fn} does one of two things depending on its bool parameter ${flag.param.name}; split it into two functions named for what each does`,
+            function: fn,
+            parameter: flag.param.name,
+            site: "declaration",
+          });
+        }
+        const unique =
+          !decl.recv ||
+          methodNames.filter((name) => name === decl.name.name).length === 1;
+        if (flags.length > 0 && unique) {
+          const key = `${decl.recv ? "." : ""}${decl.name.name}`;
+          byCallee.set(key, flags);
+        }
+      }
+    }
+    if (byCallee.size === 0) continue;
+
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "FuncDecl" || !decl.body) continue;
+        const { resolved } = resolveFunctionScopes(decl);
+        const shadowed = (ident: Ident) => resolved.has(ident);
+        const imports = new Set(file.imports.map(importName));
+        inspect(decl.body, (node) => {
+          if (node.kind !== "CallExpr") return;
+          const flags = calleeFlags(node, byCallee, shadowed, imports);
+          for (const flag of flags) {
+            const value = literalBool(node.args[flag.index], shadowed);
+            if (value === undefined) continue;
+            const fn = functionName(flag.decl);
+            findings.push({
+              rule: "flag-argument",
+              severity: "low",
+              filePath: file.filePath,
+              ...file.sourceMap.position(node.args[flag.index].pos),
+              message: `Literal ${value} passed as ${flag.param.name} to ${fn} does not say what it chooses; call a function named for that behavior instead`,
+              function: fn,
+              parameter: flag.param.name,
+              site: "call",
+              value,
+            });
+          }
+        });
+      }
+    }
+  }
+  return sortFindings(findings);
+}
+
+// The flags of the function a call calls, when it is one with flags
+function calleeFlags(
+  call: CallExpr,
+  byCallee: Map<string, Flag[]>,
+  shadowed: (ident: Ident) => boolean,
+  imports: Set<string>,
+): Flag[] {
+  const fun = unparen(call.fun);
+  let key: string | undefined;
+  if (fun.kind === "Ident" && !shadowed(fun)) {
+    key = fun.name;
+  } else if (
+    fun.kind === "SelectorExpr" &&
+    // Functions of imported packages are not this package's methods
+    !(fun.x.kind === "Ident" && imports.has(fun.x.name) && !shadowed(fun.x))
+  ) {
+    key = `.${fun.sel.name}`;
+  }
+  const flags = (key && byCallee.get(key)) ?? [];
+  return flags.filter(
+    (flag) => call.ellipsis < 0 && call.args.length === flag.arity,
+  );
+}
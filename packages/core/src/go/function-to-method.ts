@@ -0,0 +1,312 @@
+import * as path from "path";
+import { TextEdit } from "../diff.js";
+import { CallExpr, Expr, FuncDecl, GoFile, Node, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { indexGoPackageNames } from "./naming.js";
+import { goPackageSymbols, groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { baseTypeName, GoTypeSymbol } from "./symbols.js";
+
+/**
+ * Functions That Could Be Methods
+ * ===============================
+ * Several functions taking the same type as their first parameter usually
+ * describe behaviour of that type and read better as its methods. This pass
+ * groups the functions of each package by the type of their first
+ * parameter, `T` or `*T`, and suggests methods once a group reaches the
+ * minimum size. Only named types declared in the package qualify, since
+ * methods cannot be declared on other packages' types, on predeclared types
+ * or on interfaces; aliases, generic types and generic functions are
+ * skipped too, as are functions whose name the type already uses for a field
+ * or method.
+ *
+ * {@link convertToMethod} applies a suggestion: the first parameter becomes
+ * the receiver and calls in the package change from `F(x, ...)` to
+ * `x.F(...)`. Code outside the analyzed files that calls an exported
+ * function has to be updated by hand.
+ */
+
+/** Functions sharing a first parameter type before methods are suggested */
+export const DEFAULT_METHOD_CLUSTER_SIZE = 3;
+
+export interface GoMethodCandidateFinding extends GoFinding {
+  rule: "function-could-be-method";
+  function: string;
+  /** The type it could be a method of, without `*` */
+  type: string;
+  /** Functions of the package taking the same type first, in source order */
+  cluster: string[];
+}
+
+export interface FindMethodCandidateOptions {
+  /**
+   * Functions that must share a first parameter type (default:
+   * {@link DEFAULT_METHOD_CLUSTER_SIZE})
+   */
+  minClusterSize?: number;
+}
+
+export interface ConvertToMethodOptions {
+  /** Name of the function to turn into a method */
+  function: string;
+}
+
+export interface ConvertToMethodResult {
+  function: string;
+  type: string;
+  /** Changed files */
+  files: GoRefactorResult[];
+  /** Calls rewritten to method calls */
+  callSites: { filePath: string; line: number; column: number }[];
+}
+
+interface Candidate {
+  file: GoFile;
+  decl: FuncDecl;
+  type: GoTypeSymbol;
+  /** First parameter written as a receiver, e.g. `s *Store` */
+  receiver: string;
+  /** Its type as written, e.g. `*Store` */
+  typeText: string;
+}
+
+// Operands that can take a selector as written
+function isOperand(expr: Expr): boolean {
+  return [
+    "Ident",
+    "SelectorExpr",
+    "CallExpr",
+    "IndexExpr",
+    "ParenExpr",
+    "CompositeLit",
+  ].includes(expr.kind);
+}
+
+class MethodCandidates {
+  private readonly files: GoFile[];
+  readonly candidates: Candidate[] = [];
+  /** Why functions that take a local type first cannot become methods */
+  readonly rejected = new Map<string, string>();
+
+  constructor(files: GoFile[]) {
+    this.files = files;
+    const symbols = goPackageSymbols(files);
+    const types = new Map(symbols.types.map((type) => [type.name, type]));
+    for (const file of files) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "FuncDecl" || decl.recv || !decl.body) continue;
+        const first = decl.type.params.list[0];
+        if (!first || first.names.length === 0) continue;
+        const { name } = baseTypeName(first.type);
+        const type = types.get(name);
+        const direct =
+          first.type.kind === "Ident" ||
+          (first.type.kind === "StarExpr" && first.type.x.kind === "Ident");
+        if (!type || !direct) continue;
+        const reason = this.rejection(decl, type);
+        if (reason) {
+          this.rejected.set(decl.name.name, reason);
+          continue;
+        }
+        const typeText = file.source.slice(first.type.pos, first.type.end);
+        this.candidates.push({
+          file,
+          decl,
+          type,
+          receiver: `${first.names[0].name} ${typeText}`,
+          typeText,
+        });
+      }
+    }
+  }
+
+  private rejection(decl: FuncDecl, type: GoTypeSymbol): string | undefined {
+    const name = decl.name.name;
+    if (type.typeKind === "interface" || type.typeKind === "alias") {
+      return `${type.name} is an ${type.typeKind}`;
+    }
+    if (type.typeParams) return `${type.name} is generic`;
+    if (decl.type.typeParams) return `${name} is generic`;
+    if (type.methods.some((method) => method.name === name)) {
+      return `${type.name} already has a method ${name}`;
+    }
+    if (type.fields.some((field) => field.name === name)) {
+      return `${type.name} already has a field ${name}`;
+    }
+    return undefined;
+  }
+
+  clusters(): Map<string, Candidate[]> {
+    const clusters = new Map<string, Candidate[]>();
+    for (const candidate of this.candidates) {
+      const { name } = candidate.type;
+      if (!clusters.has(name)) clusters.set(name, []);
+      clusters.get(name).push(candidate);
+    }
+    return clusters;
+  }
+
+  convert(name: string): ConvertToMethodResult {
+    const candidate = this.candidates.find(
+      (other) => other.decl.name.name === name,
+    );
+    if (!candidate) {
+      throw new GoRefactorError(
+        `${name} does not take a type of its package as its first parameter`,
+      );
+    }
+    const { file, decl, type, receiver } = candidate;
+    const parents = new Map<Node, Node>();
+    for (const target of this.files) {
+      inspect(target, (node, stack) => {
+        if (stack.length > 0) parents.set(node, stack.at(-1));
+      });
+    }
+    const calls = indexGoPackageNames(this.files).calls(decl, parents);
+    if (typeof calls === "string") {
+      throw new GoRefactorError(`Cannot make ${name} a method: ${calls}`);
+    }
+
+    const edits = new Map<GoFile, TextEdit[]>();
+    const editsFor = (target: GoFile) => {
+      if (!edits.has(target)) edits.set(target, []);
+      return edits.get(target);
+    };
+    editsFor(file).push(
+      { start: decl.name.pos, end: decl.name.pos, newText: `(${receiver}) ` },
+      this.dropFirstParam(decl),
+    );
+    const callSites: ConvertToMethodResult["callSites"] = [];
+    for (const { file: callFile, call } of calls) {
+      editsFor(callFile).push(this.methodCall(callFile, call, decl));
+      callSites.push({
+        filePath: callFile.filePath,
+        ...callFile.sourceMap.position(call.pos),
+      });
+    }
+
+    return {
+      function: name,
+      type: type.name,
+      files: this.files
+        .filter((target) => edits.has(target))
+        .map((target) => refactorResult(target, edits.get(target))),
+      callSites,
+    };
+  }
+
+  // Remove the first parameter, or its name from a shared `a, b T` field
+  private dropFirstParam(decl: FuncDecl): TextEdit {
+    const { params } = decl.type;
+    const [first, second] = params.list;
+    if (first.names.length > 1) {
+      const [name, next] = first.names;
+      return { start: name.pos, end: next.pos, newText: "" };
+    }
+    return {
+      start: first.pos,
+      end: second ? second.pos : params.end - 1,
+      newText: "",
+    };
+  }
+
+  // `F(x, rest)` becomes `x.F(rest)`
+  private methodCall(file: GoFile, call: CallExpr, decl: FuncDecl): TextEdit {
+    const name = decl.name.name;
+    const params = decl.type.params.list.reduce(
+      (count, field) => count + Math.max(field.names.length, 1),
+      0,
+    );
+    const [first, second] = call.args;
+    if (call.args.length === 1 && params > 1) {
+      throw new GoRefactorError(
+        `${name} is called with a multi-value expression at ${this.where(file, call)}`,
+      );
+    }
+    // Taking the address is implicit for pointer receivers
+    const operand =
+      first.kind === "UnaryExpr" && first.op === "&" && isOperand(first.x)
+        ? first.x
+        : first;
+    const text = file.source.slice(operand.pos, operand.end);
+    const receiver = isOperand(operand) ? text : `(${text})`;
+    return {
+      start: call.fun.pos,
+      end: second ? second.pos : first.end,
+      newText: `${receiver}.${name}(`,
+    };
+  }
+
+  private where(file: GoFile, node: Node): string {
+    return `${path.basename(file.filePath)}:${file.sourceMap.line(node.pos)}`;
+  }
+}
+
+/**
+ * Find groups of functions taking the same package type first, which could
+ * be methods of that type
+ */
+export function findMethodCandidates(
+  files: GoFile[],
+  options: FindMethodCandidateOptions = {},
+): GoMethodCandidateFinding[] {
+  const minimum = options.minClusterSize ?? DEFAULT_METHOD_CLUSTER_SIZE;
+  const findings: GoMethodCandidateFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    for (const [type, cluster] of new MethodCandidates(group).clusters()) {
+      if (cluster.length < minimum) continue;
+      const names = cluster.map((candidate) => candidate.decl.name.name);
+      for (const { file, decl, receiver, typeText } of cluster) {
+        const others = cluster.length - 1;
+        findings.push({
+          rule: "function-could-be-method",
+          severity: "low",
+          filePath: file.filePath,
+          ...file.sourceMap.position(decl.name.pos),
+          message: `${decl.name.name} takes ${typeText} first like ${others} other function(s); make it a method of ${type}`,
+          fix: `func (${receiver}) ${decl.name.name}`,
+          function: decl.name.name,
+          type,
+          cluster: names,
+        });
+      }
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Turn a function into a method of the type of its first parameter and
+ * rewrite its calls in the package
+ */
+export function convertToMethod(
+  files: GoFile[],
+  options: ConvertToMethodOptions,
+): ConvertToMethodResult {
+  for (const group of groupGoPackages(files)) {
+    const declared = group.some((file) =>
+      file.decls.some(
+        (decl) =>
+          decl.kind === "FuncDecl" &&
+          !decl.recv &&
+          decl.name.name === options.function,
+      ),
+    );
+    if (!declared) continue;
+    const analyzer = new MethodCandidates(group);
+    const reason = analyzer.rejected.get(options.function);
+    if (reason) {
+      throw new GoRefactorError(
+        `Cannot make ${options.function} a method: ${reason}`,
+      );
+    }
+    return analyzer.convert(options.function);
+  }
+  throw new GoRefactorError(
+    `${options.function} is not declared in the analyzed files`,
+  );
+}
@@ -0,0 +1,189 @@
+import { GoFinding, GoSeverity } from "./findings.js";
+
+/**
+ * Go CI Gate
+ * ==========
+ * Decides whether a set of findings should fail a build. Findings at or
+ * above the `failOn` severity fail it outright; those below it are warnings,
+ * which only fail the build once there are more than `maxWarnings` of them.
+ * Rules can be given a different severity, or switched off with `off`, so a
+ * project can fail on ignored errors while letting low-value suggestions
+ * through. The outcome is summarized as JSON for CI to parse.
+ */
+
+export type GoGateSeverity = GoSeverity | "off";
+
+export interface GoGateOptions {
+  /** Lowest severity that fails the build (default: high) */
+  failOn?: GoSeverity;
+  /** Severity per rule ID, overriding the severity each finding reports */
+  severities?: Record<string, GoGateSeverity>;
+  /** Warnings allowed before the build fails; unlimited when unset */
+  maxWarnings?: number;
+}
+
+export interface GoGateResult {
+  passed: boolean;
+  /** Process exit code for the outcome: 0 when passed, otherwise 1 */
+  exitCode: number;
+  failOn: GoSeverity;
+  maxWarnings?: number;
+  /** Findings counted, after overrides, by severity */
+  counts: Record<GoSeverity, number>;
+  /** Findings counted, after overrides, by rule */
+  rules: Record<string, number>;
+  /** Findings at or above `failOn`, with their overridden severity */
+  failures: GoFinding[];
+  warnings: number;
+  /** Why the build failed, when it did */
+  reasons: string[];
+}
+
+/**
+ * Error raised for severities and gate options that cannot be understood
+ */
+export class GoGateError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoGateError";
+  }
+}
+
+const RANK: Record<GoSeverity, number> = { high: 3, medium: 2, low: 1 };
+
+// Names CI tools commonly use for the same levels
+const ALIASES: Record<string, GoGateSeverity> = {
+  error: "high",
+  warning: "medium",
+  warn: "medium",
+  info: "low",
+  none: "off",
+};
+
+/**
+ * Parse a severity, accepting `error`, `warning` and `info` for high, medium
+ * and low
+ */
+export function parseGoSeverity(value: string): GoGateSeverity {
+  const name = value.trim().toLowerCase();
+  if (name === "off" || name in RANK) return name as GoGateSeverity;
+  const alias = ALIASES[name];
+  if (!alias) {
+    throw new GoGateError(
+      `Unknown severity ${JSON.stringify(value)}; expected high, medium, low or off`,
+    );
+  }
+  return alias;
+}
+
+/**
+ * Parse `rule=severity` overrides, as given on the command line
+ */
+export function parseGoSeverityOverrides(
+  values: string[],
+): Record<string, GoGateSeverity> {
+  const severities: Record<string, GoGateSeverity> = {};
+  for (const value of values) {
+    const separator = value.indexOf("=");
+    const rule = value.slice(0, separator).trim();
+    if (separator < 0 || rule === "") {
+      throw new GoGateError(
+        `Severity override ${JSON.stringify(value)} must look like rule=severity`,
+      );
+    }
+    severities[rule] = parseGoSeverity(value.slice(separator + 1));
+  }
+  return severities;
+}
+
+/**
+ * Findings with the severities `severities` gives their rules, leaving out
+ * the rules it switches off, so reports show what the gate counts
+ */
+export function applyGoSeverityOverrides<T extends GoFinding>(
+  findings: T[],
+  severities: Record<string, GoGateSeverity>,
+): T[] {
+  return findings.flatMap((finding) => {
+    const severity = severities[finding.rule];
+    if (severity === "off") return [];
+    return severity ? [{ ...finding, severity }] : [finding];
+  });
+}
+
+/**
+ * Decide whether findings pass the gate
+ */
+export function evaluateGoGate(
+  findings: GoFinding[],
+  options: GoGateOptions = {},
+): GoGateResult {
+  const failOn = options.failOn ?? "high";
+  const { maxWarnings } = options;
+  if (
+    maxWarnings !== undefined &&
+    (!Number.isInteger(maxWarnings) || maxWarnings < 0)
+  ) {
+    throw new GoGateError(
+      `maxWarnings must be a non-negative integer, not ${maxWarnings}`,
+    );
+  }
+
+  const counts: Record<GoSeverity, number> = { high: 0, medium: 0, low: 0 };
+  const rules: Record<string, number> = {};
+  const failures: GoFinding[] = [];
+  let warnings = 0;
+  for (const finding of findings) {
+    const severity = options.severities?.[finding.rule] ?? finding.severity;
+    if (severity === "off") continue;
+    counts[severity]++;
+    rules[finding.rule] = (rules[finding.rule] ?? 0) + 1;
+    if (RANK[severity] >= RANK[failOn]) {
+      failures.push({ ...finding, severity });
+    } else {
+      warnings++;
+    }
+  }
+
+  const reasons: string[] = [];
+  if (failures.length > 0) {
+    reasons.push(
+      `${failures.length} ${failures.length === 1 ? "finding" : "findings"} at or above ${failOn} severity`,
+    );
+  }
+  if (maxWarnings !== undefined && warnings > maxWarnings) {
+    reasons.push(`${warnings} warnings exceed the budget of ${maxWarnings}`);
+  }
+  const passed = reasons.length === 0;
+  return {
+    passed,
+    exitCode: passed ? 0 : 1,
+    failOn,
+    ...(maxWarnings !== undefined && { maxWarnings }),
+    counts,
+    rules: Object.fromEntries(
+      Object.entries(rules).sort(([a], [b]) => (a < b ? -1 : a > b ? 1 : 0)),
+    ),
+    failures,
+    warnings,
+    reasons,
+  };
+}
+
+/**
+ * One line of JSON summarizing a gate result, for CI to parse from stderr
+ */
+export function formatGoGateSummary(result: GoGateResult): string {
+  return JSON.stringify({
+    passed: result.passed,
+    failOn: result.failOn,
+    ...(result.maxWarnings !== undefined && {
+      maxWarnings: result.maxWarnings,
+    }),
+    failures: result.failures.length,
+    warnings: result.warnings,
+    counts: result.counts,
+    rules: result.rules,
+    reasons: result.reasons,
+  });
+}
@@ -0,0 +1,233 @@
+import { execFile } from "child_process";
+import * as path from "path";
+import { promisify } from "util";
+import { GoFile } from "./ast.js";
+import { GoFinding } from "./findings.js";
+
+/**
+ * Go Diff Scope
+ * =============
+ * Restricts findings to what a change touched, so CI on a pull request
+ * reports only the code the pull request changed. `git diff` between two
+ * revisions gives the changed line ranges of every file, renames included;
+ * a declaration overlapping a changed range is then checked as a whole, so a
+ * one-line edit to a function surfaces every finding in that function, not
+ * just those on the edited line. Findings outside any declaration count when
+ * their own line changed, and new files count entirely. Deleted lines mark
+ * the lines on either side of them, so removing code from a function still
+ * touches it.
+ */
+
+/**
+ * Lines of the new version of a file, 1-based and inclusive
+ */
+export interface GoLineRange {
+  start: number;
+  end: number;
+}
+
+export interface GoChangedFile {
+  /** Path in the new revision, as git prints it */
+  filePath: string;
+  /** Path in the old revision, for renames */
+  previousPath?: string;
+  status: "added" | "modified" | "renamed";
+  /** Changed lines of the new version */
+  ranges: GoLineRange[];
+}
+
+export interface GoDiffScopeOptions {
+  /** Directory the changed paths are relative to */
+  root?: string;
+}
+
+/**
+ * Error raised when git cannot produce the diff
+ */
+export class GoGitDiffError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoGitDiffError";
+  }
+}
+
+const HUNK = /^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@/;
+
+// The path after an a/ or b/ prefix, unquoting paths git quoted
+function diffPath(text: string): string {
+  const unquoted = text.startsWith('"') ? JSON.parse(text) : text;
+  return unquoted.replace(/^[ab]\//, "");
+}
+
+/**
+ * Parse the output of `git diff --unified=0`. Deleted files are left out:
+ * nothing in them can have a finding.
+ */
+export function parseGitDiff(diff: string): GoChangedFile[] {
+  const changed: GoChangedFile[] = [];
+  let current: GoChangedFile | undefined;
+  let deleted = false;
+  const finish = () => {
+    if (current && !deleted) changed.push(current);
+    current = undefined;
+    deleted = false;
+  };
+
+  for (const line of diff.split("\n")) {
+    if (line.startsWith("diff --git ")) {
+      finish();
+      current = { filePath: "", status: "modified", ranges: [] };
+      continue;
+    }
+    if (!current) continue;
+    if (line.startsWith("new file mode")) {
+      current.status = "added";
+    } else if (line.startsWith("deleted file mode")) {
+      deleted = true;
+    } else if (line.startsWith("rename from ")) {
+      current.status = "renamed";
+      current.previousPath = diffPath(line.slice("rename from ".length));
+    } else if (line.startsWith("rename to ")) {
+      current.filePath = diffPath(line.slice("rename to ".length));
+    } else if (line.startsWith("+++ ") && line !== "+++ /dev/null") {
+      current.filePath = diffPath(line.slice(4));
+    } else {
+      const hunk = HUNK.exec(line);
+      if (!hunk) continue;
+      const start = Number(hunk[1]);
+      const count = hunk[2] === undefined ? 1 : Number(hunk[2]);
+      current.ranges.push(
+        count > 0
+          ? { start, end: start + count - 1 }
+          : { start: Math.max(start, 1), end: start + 1 },
+      );
+    }
+  }
+  finish();
+  return changed.filter((file) => file.filePath !== "");
+}
+
+/**
+ * The files changed between two revisions of the repository containing
+ * `root`, with paths relative to `root`. Without `head` the base is compared
+ * with the working tree.
+ */
+export async function readGitDiff(
+  root: string,
+  base: string,
+  head?: string,
+): Promise<GoChangedFile[]> {
+  const args = [
+    "-C",
+    root,
+    "diff",
+    "--relative",
+    "--no-color",
+    "--no-ext-diff",
+    "--unified=0",
+    "--find-renames",
+    base,
+    ...(head ? [head] : []),
+    "--",
+  ];
+  try {
+    const { stdout } = await promisify(execFile)("git", args, {
+      maxBuffer: 256 * 1024 * 1024,
+    });
+    return parseGitDiff(stdout);
+  } catch (error) {
+    const stderr = (error as { stderr?: string }).stderr?.trim();
+    throw new GoGitDiffError(
+      `git diff ${base}${head ? ` ${head}` : ""} failed: ${stderr || (error as Error).message}`,
+    );
+  }
+}
+
+interface ScopedFile {
+  added: boolean;
+  ranges: GoLineRange[];
+  /** Declarations overlapping a changed range, by line span */
+  touched: GoLineRange[];
+}
+
+function overlaps(a: GoLineRange, b: GoLineRange): boolean {
+  return a.start <= b.end && b.start <= a.end;
+}
+
+/**
+ * The part of a set of files a diff changed
+ */
+export class GoDiffScope {
+  private readonly scoped = new Map<string, ScopedFile>();
+  private readonly directories = new Set<string>();
+
+  constructor(
+    files: GoFile[],
+    changes: GoChangedFile[],
+    options: GoDiffScopeOptions = {},
+  ) {
+    const byPath = new Map(
+      changes.map((change) => [
+        path.resolve(options.root ?? "", change.filePath),
+        change,
+      ]),
+    );
+    for (const file of files) {
+      const change = byPath.get(path.resolve(file.filePath));
+      if (!change) continue;
+      this.directories.add(path.dirname(file.filePath));
+      const { sourceMap } = file;
+      const touched = file.decls
+        .map((decl) => ({
+          start: sourceMap.line(decl.doc?.pos ?? decl.pos),
+          end: sourceMap.line(decl.end),
+        }))
+        .filter((span) => change.ranges.some((range) => overlaps(span, range)));
+      this.scoped.set(file.filePath, {
+        added: change.status === "added",
+        ranges: change.ranges,
+        touched,
+      });
+    }
+  }
+
+  /**
+   * Files in the packages the diff changed, which package-wide checks still
+   * need to see
+   */
+  packageFiles(files: GoFile[]): GoFile[] {
+    return files.filter((file) =>
+      this.directories.has(path.dirname(file.filePath)),
+    );
+  }
+
+  /**
+   * Whether a finding lies in a declaration the diff touched, on a changed
+   * line, or in a new file
+   */
+  includes(finding: GoFinding): boolean {
+    const file = this.scoped.get(finding.filePath);
+    if (!file) return false;
+    if (file.added) return true;
+    const line = { start: finding.line, end: finding.line };
+    return (
+      file.touched.some((span) => overlaps(span, line)) ||
+      file.ranges.some((range) => overlaps(range, line))
+    );
+  }
+
+  filter<T extends GoFinding>(findings: T[]): T[] {
+    return findings.filter((finding) => this.includes(finding));
+  }
+}
+
+/**
+ * Scope files to the changes of a diff
+ */
+export function goDiffScope(
+  files: GoFile[],
+  changes: GoChangedFile[],
+  options: GoDiffScopeOptions = {},
+): GoDiffScope {
+  return new GoDiffScope(files, changes, options);
+}
@@ -0,0 +1,238 @@
+import { Expr, FuncDecl, GoFile, Ident, Node, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { groupGoPackages } from "./package.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+import { isGoTestFile } from "./test-links.js";
+
+/**
+ * Hidden Global State
+ * ===================
+ * A function without parameters that reads or writes package-level
+ * variables gets its inputs from somewhere a test cannot see: to exercise
+ * it, the test has to set the globals up and put them back afterwards, and
+ * tests doing so cannot run in parallel. This pass flags functions and
+ * methods taking no parameters that use package-level state, listing the
+ * variables and whether each is read or written, so they can be passed in as
+ * parameters or moved to fields of a receiver.
+ *
+ * Only mutable state counts: a variable is mutable when some function of the
+ * package assigns it, increments it, stores into it through an index or a
+ * field, or takes its address. Constants, and variables only ever set by
+ * their declaration, such as sentinel errors, compiled regular expressions
+ * and lookup tables, read the same in every test and are not reported, which
+ * leaves a function like `privateHelper`, printing a constant message, alone.
+ * `init` and `main`, which set up a program's globals by design, and test
+ * files are skipped.
+ */
+
+/** Mutable globals a parameter-less function may use before it is reported */
+export const DEFAULT_GLOBAL_STATE_MIN_GLOBALS = 1;
+
+export interface GoGlobalAccess {
+  name: string;
+  read: boolean;
+  write: boolean;
+}
+
+export interface GoGlobalStateFinding extends GoFinding {
+  rule: "hidden-global-state";
+  /** Name of the function, `Type.Method` for methods */
+  function: string;
+  /** Mutable package-level variables it uses, by name */
+  globals: GoGlobalAccess[];
+}
+
+export interface GlobalStateOptions {
+  /**
+   * Mutable globals a function must use to be reported (default:
+   * {@link DEFAULT_GLOBAL_STATE_MIN_GLOBALS})
+   */
+  minGlobals?: number;
+}
+
+function functionName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+// The variable an assignment target stores into: `x` in `x.f[i]` or `*x`
+function rootIdent(expr: Expr): Ident | undefined {
+  switch (expr.kind) {
+    case "Ident":
+      return expr;
+    case "ParenExpr":
+    case "SelectorExpr":
+    case "IndexExpr":
+    case "StarExpr":
+      return rootIdent(expr.x);
+    default:
+      return undefined;
+  }
+}
+
+function list(names: string[]): string {
+  return names.length === 1
+    ? names[0]
+    : `${names.slice(0, -1).join(", ")} and ${names.at(-1)}`;
+}
+
+interface Uses {
+  reads: Ident[];
+  writes: Ident[];
+}
+
+class GlobalStateAnalyzer {
+  private readonly group: GoFile[];
+  private readonly minGlobals: number;
+  /** Package-level variables, by name */
+  private readonly globals = new Set<string>();
+
+  constructor(group: GoFile[], options: GlobalStateOptions) {
+    this.group = group;
+    this.minGlobals = options.minGlobals ?? DEFAULT_GLOBAL_STATE_MIN_GLOBALS;
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "GenDecl" || decl.tok !== "var") continue;
+        for (const spec of decl.specs) {
+          if (spec.kind !== "ValueSpec") continue;
+          for (const ident of spec.names) {
+            if (ident.name !== "_") this.globals.add(ident.name);
+          }
+        }
+      }
+    }
+  }
+
+  // Package-level variables a function reads and writes, its literals'
+  // uses included; names resolving to locals are not globals
+  private usesIn(decl: FuncDecl, scopes: GoFunctionScopes): Uses {
+    const writes = new Set<Ident>();
+    // Targets of plain assignments, which store without reading
+    const stores = new Set<Ident>();
+    const isGlobal = (ident: Ident | undefined) =>
+      ident !== undefined &&
+      this.globals.has(ident.name) &&
+      !scopes.resolved.has(ident);
+    const written = (expr: Expr) => {
+      const ident = rootIdent(expr);
+      if (isGlobal(ident)) writes.add(ident);
+    };
+    const reads: Ident[] = [];
+    const visit = (node: Node): boolean | void => {
+      if (node.kind === "AssignStmt") {
+        node.lhs.forEach(written);
+        for (const target of node.lhs) {
+          if (node.tok === "=" && target.kind === "Ident") stores.add(target);
+        }
+      } else if (node.kind === "IncDecStmt") {
+        written(node.x);
+      } else if (node.kind === "UnaryExpr" && node.op === "&") {
+        written(node.x);
+      }
+      if (node.kind === "SelectorExpr") {
+        // A field or method name is not a variable
+        inspect(node.x, visit);
+        return false;
+      }
+      if (node.kind === "CompositeLit" && node.type?.kind !== "MapType") {
+        // Keys of struct literals name fields
+        for (const elt of node.elts) {
+          inspect(elt.kind === "KeyValueExpr" ? elt.value : elt, visit);
+        }
+        return false;
+      }
+      if (node.kind === "Ident" && isGlobal(node) && !stores.has(node)) {
+        reads.push(node);
+      }
+    };
+    inspect(decl.body, visit);
+    return { reads, writes: [...writes] };
+  }
+
+  private isSkipped(file: GoFile, decl: FuncDecl): boolean {
+    if (isGoTestFile(file.filePath) || !decl.body) return true;
+    if (decl.recv) return false;
+    return (
+      decl.name.name === "init" ||
+      (decl.name.name === "main" && file.packageName.name === "main")
+    );
+  }
+
+  analyze(): GoGlobalStateFinding[] {
+    if (this.globals.size === 0) return [];
+    const functions: [GoFile, FuncDecl, Uses][] = [];
+    for (const file of this.group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "FuncDecl" || !decl.body) continue;
+        const uses = this.usesIn(decl, resolveFunctionScopes(decl));
+        functions.push([file, decl, uses]);
+      }
+    }
+    // Written anywhere in the package, init and tests included
+    const mutable = new Set(
+      functions.flatMap(([, , uses]) => uses.writes.map(({ name }) => name)),
+    );
+
+    const findings: GoGlobalStateFinding[] = [];
+    for (const [file, decl, { reads, writes }] of functions) {
+      if (this.isSkipped(file, decl)) continue;
+      if (decl.type.params.list.length > 0) continue;
+      const byName = new Map<string, GoGlobalAccess>();
+      for (const [idents, write] of [
+        [reads, false],
+        [writes, true],
+      ] as const) {
+        for (const { name } of idents) {
+          if (!mutable.has(name)) continue;
+          const access = byName.get(name) ?? {
+            name,
+            read: false,
+            write: false,
+          };
+          if (write) access.write = true;
+          else access.read = true;
+          byName.set(name, access);
+        }
+      }
+      const globals = [...byName.values()].sort((a, b) =>
+        a.name < b.name ? -1 : a.name > b.name ? 1 : 0,
+      );
+      if (globals.length === 0 || globals.length < this.minGlobals) continue;
+
+      const name = functionName(decl);
+      const described = globals.map((access) => {
+        const how = [access.read && "read", access.write && "written"];
+        return `${access.name} (${how.filter(Boolean).join(" and ")})`;
+      });
+      const them = globals.length === 1 ? "it" : "them";
+      findings.push({
+        rule: "hidden-global-state",
+        severity: "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(decl.name.pos),
+        message: `${name} takes no parameters but depends on the package-level state ${list(described)}; pass ${them} in as parameters, or fields of a receiver, so tests can supply their own`,
+        function: name,
+        globals,
+      });
+    }
+    return findings;
+  }
+}
+
+/**
+ * Functions and methods without parameters that use mutable package-level
+ * variables
+ */
+export function findHiddenGlobalState(
+  files: GoFile[],
+  options: GlobalStateOptions = {},
+): GoGlobalStateFinding[] {
+  return sortFindings(
+    groupGoPackages(files).flatMap((group) =>
+      new GlobalStateAnalyzer(group, options).analyze(),
+    ),
+  );
+}
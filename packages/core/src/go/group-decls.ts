@@ -0,0 +1,446 @@
+import { TextEdit } from "../diff.js";
+import { CommentGroup, GenDecl, GoFile, inspect, ValueSpec } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { parseGoFile } from "./parser.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+
+/**
+ * Group Declarations
+ * ==================
+ * Normalizes how package-level `const` and `var` declarations are grouped.
+ * Adjacent declarations of the same kind merge into one parenthesized block
+ * when at least one of them stands alone, so `const A = 1` next to
+ * `const B = 2` becomes `const ( A = 1; B = 2 )`, and a lone declaration
+ * next to a block joins it. Two blocks side by side are left apart, since
+ * separate blocks usually are separate on purpose. Blank lines between the
+ * declarations stay as blank lines in the block, doc comments move in above
+ * their entries and trailing comments stay on their lines. Entries line up
+ * the way gofmt aligns them: names, types, values and trailing comments in
+ * columns, over runs of single-line entries.
+ *
+ * Constants using `iota` are never merged, as their values depend on their
+ * place in the block, nor are declarations with a multi-line raw string,
+ * which cannot be indented without changing it. With `ungroupSingle`, a
+ * block holding a single entry loses its parentheses, its doc comment
+ * moving above the declaration.
+ */
+
+export type GoDeclGroupingPolicy = "always-group" | "leave-as-is";
+
+export interface GoUngroupedDeclFinding extends GoFinding {
+  rule: "ungrouped-declarations";
+  tok: "const" | "var";
+  /** Declarations the merged block would replace */
+  count: number;
+}
+
+export interface NormalizeDeclGroupsOptions {
+  /**
+   * Whether adjacent declarations merge into one block (default:
+   * always-group)
+   */
+  policy?: GoDeclGroupingPolicy;
+  /** Write blocks holding a single entry without parentheses */
+  ungroupSingle?: boolean;
+}
+
+export interface NormalizeDeclGroupsResult extends GoRefactorResult {
+  /** Blocks written from adjacent declarations, by their first line */
+  merged: { tok: "const" | "var"; line: number; count: number }[];
+  /** Single-entry blocks written without parentheses, by their first line */
+  ungrouped: { tok: "const" | "var"; line: number }[];
+}
+
+type ValueDecl = GenDecl & { tok: "const" | "var" };
+
+// A line of a block as gofmt's tabwriter sees it: cells ended by tabs, then
+// text that is not aligned
+interface Row {
+  cells: string[];
+  text: string;
+}
+
+interface Item {
+  pos: number;
+  end: number;
+  /** Indentation continuation lines gain in the block */
+  shift: string;
+  spec?: ValueSpec;
+  comment?: CommentGroup;
+}
+
+/**
+ * Lay out rows the way text/tabwriter does for gofmt: each column of
+ * consecutive rows having a cell there is as wide as its widest cell plus a
+ * space, and columns holding only empty cells are dropped
+ */
+function alignRows(rows: Row[]): string[] {
+  const widths: number[][] = rows.map(() => []);
+  const format = (start: number, end: number, column: number) => {
+    for (let i = start; i < end; i++) {
+      if (column >= rows[i].cells.length) continue;
+      const first = i;
+      let width = 0;
+      let empty = true;
+      for (; i < end && column < rows[i].cells.length; i++) {
+        const cell = rows[i].cells[column];
+        width = Math.max(width, cell.length + 1);
+        if (cell !== "") empty = false;
+      }
+      for (let j = first; j < i; j++) widths[j][column] = empty ? 0 : width;
+      format(first, i, column + 1);
+    }
+  };
+  format(0, rows.length, 0);
+  return rows.map((row, index) =>
+    (
+      row.cells
+        .map((cell, column) => cell.padEnd(widths[index][column]))
+        .join("") + row.text
+    ).trimEnd(),
+  );
+}
+
+/**
+ * Whether the specs of a block keep a column for types, as gofmt decides:
+ * a run of entries with values keeps it when any of them has a type
+ */
+function keepTypeColumn(specs: ValueSpec[]): boolean[] {
+  const keep = specs.map(() => false);
+  let start = -1;
+  let keepType = false;
+  specs.forEach((spec, index) => {
+    if (spec.values.length > 0) {
+      if (start < 0) {
+        start = index;
+        keepType = false;
+      }
+    } else if (start >= 0) {
+      keep.fill(keepType, start, index);
+      start = -1;
+    }
+    if (spec.type) keepType = true;
+  });
+  if (start >= 0) keep.fill(keepType, start);
+  return keep;
+}
+
+class DeclGrouper {
+  private readonly file: GoFile;
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private text(node: { pos: number; end: number }): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private usesIota(decl: ValueDecl): boolean {
+    let found = false;
+    for (const spec of decl.specs) {
+      inspect(spec, (node) => {
+        if (node.kind === "Ident" && node.name === "iota") found = true;
+        return !found;
+      });
+    }
+    return found;
+  }
+
+  private hasRawLines(decl: ValueDecl): boolean {
+    let found = false;
+    inspect(decl, (node) => {
+      if (node.kind === "BasicLit" && /^`[^]*\n/.test(node.value)) {
+        found = true;
+      }
+      return !found;
+    });
+    return found;
+  }
+
+  // Declarations that may take part in a merge
+  private isMergeable(decl: GenDecl): decl is ValueDecl {
+    if (decl.tok !== "const" && decl.tok !== "var") return false;
+    if (decl.tok === "const" && this.usesIota(decl as ValueDecl)) return false;
+    return decl.lparen >= 0 || !this.hasRawLines(decl as ValueDecl);
+  }
+
+  /**
+   * Runs of adjacent declarations to merge, each joining a lone declaration
+   * to its neighbour
+   */
+  runs(): ValueDecl[][] {
+    const runs: ValueDecl[][] = [];
+    let run: ValueDecl[] = [];
+    const close = () => {
+      if (run.length > 1) runs.push(run);
+      run = [];
+    };
+    for (const decl of this.file.decls) {
+      if (decl.kind !== "GenDecl" || !this.isMergeable(decl)) {
+        close();
+        continue;
+      }
+      const last = run.at(-1);
+      const joins =
+        last &&
+        last.tok === decl.tok &&
+        (last.lparen < 0 || decl.lparen < 0);
+      if (!joins) close();
+      run.push(decl);
+    }
+    close();
+    return runs;
+  }
+
+  /**
+   * Blocks holding a single entry that can lose their parentheses
+   */
+  lonely(): ValueDecl[] {
+    return this.file.decls.filter((decl): decl is ValueDecl => {
+      if (decl.kind !== "GenDecl" || decl.lparen < 0) return false;
+      if (decl.tok !== "const" && decl.tok !== "var") return false;
+      if (decl.specs.length !== 1) return false;
+      const spec = decl.specs[0] as ValueSpec;
+      if (decl.doc && spec.doc) return false;
+      // Comments of their own inside the parentheses have nowhere to go
+      return this.file.comments.every(
+        (group) =>
+          group.pos < decl.lparen ||
+          group.pos > decl.rparen ||
+          group === spec.doc ||
+          group === spec.comment,
+      );
+    });
+  }
+
+  // Where a declaration's replaced source starts and ends: a lone
+  // declaration takes its doc comment and trailing comment along
+  private extent(decl: ValueDecl): { pos: number; end: number } {
+    if (decl.lparen >= 0) return { pos: decl.pos, end: decl.end };
+    const spec = decl.specs[0] as ValueSpec;
+    return {
+      pos: decl.doc?.pos ?? decl.pos,
+      end: spec.comment?.end ?? decl.end,
+    };
+  }
+
+  // Source with each line after the first indented by shift, leaving
+  // empty lines alone
+  private shifted(text: string, shift: string): string[] {
+    const [first, ...rest] = text.split("\n");
+    return [first, ...rest.map((line) => (line === "" ? line : shift + line))];
+  }
+
+  // Rows of an entry: the first line in cells, as gofmt prints a value
+  // spec, and any further lines of a multi-line type or value as text
+  private specRows(spec: ValueSpec, keepType: boolean, shift: string): Row[] {
+    const names = spec.names.map((ident) => ident.name).join(", ");
+    const type = spec.type ? this.text(spec.type) : "";
+    const values =
+      spec.values.length > 0
+        ? this.text({ pos: spec.values[0].pos, end: spec.values.at(-1).end })
+        : undefined;
+    const comment = spec.comment ? this.text(spec.comment) : undefined;
+    if (type.includes("\n")) {
+      const rest = [` ${type}`, values && ` = ${values}`].join("");
+      const lines = this.shifted(`\t${names}${rest}`, shift);
+      if (comment) lines[lines.length - 1] += ` ${comment}`;
+      return lines.map((text) => ({ cells: [], text }));
+    }
+
+    const cells: string[] = [];
+    let text = `\t${names}`;
+    // gofmt ends each cell with a tab and puts trailing comments in the
+    // fourth column, padding with empty cells
+    let extraTabs = 3;
+    const tab = () => {
+      cells.push(text);
+      text = "";
+    };
+    if (spec.type || keepType) {
+      tab();
+      text = type;
+      extraTabs--;
+    }
+    if (values !== undefined) {
+      tab();
+      text = `= ${values}`;
+      extraTabs--;
+    }
+    // Further lines of a value are text; only its first line is aligned
+    const [first, ...rest] = this.shifted(text, shift);
+    text = first;
+    if (comment && rest.length > 0) {
+      rest[rest.length - 1] += ` ${comment}`;
+    } else if (comment) {
+      for (; extraTabs > 0; extraTabs--) tab();
+      text = comment;
+    }
+    return [
+      { cells, text },
+      ...rest.map((line) => ({ cells: [], text: line })),
+    ];
+  }
+
+  /**
+   * The block replacing a run of declarations
+   */
+  mergedBlock(run: ValueDecl[]): TextEdit {
+    const pos = this.extent(run[0]).pos;
+    const end = this.extent(run.at(-1)).end;
+    const inBlock = (offset: number) =>
+      run.some(
+        (decl) =>
+          decl.lparen >= 0 && offset > decl.lparen && offset < decl.rparen,
+      );
+    const specs = run.flatMap((decl) => decl.specs as ValueSpec[]);
+    const trailing = new Set(specs.map((spec) => spec.comment));
+    const items: Item[] = [
+      ...specs.map((spec) => ({
+        pos: spec.pos,
+        end: spec.comment?.end ?? spec.end,
+        shift: inBlock(spec.pos) ? "" : "\t",
+        spec,
+      })),
+      ...this.file.comments
+        .filter(
+          (group) =>
+            group.pos >= pos && group.end <= end && !trailing.has(group),
+        )
+        .map((group) => ({
+          pos: group.pos,
+          end: group.end,
+          shift: inBlock(group.pos) ? "" : "\t",
+          comment: group,
+        })),
+    ].sort((a, b) => a.pos - b.pos);
+
+    const keep = keepTypeColumn(specs);
+    const rows: Row[] = [];
+    items.forEach((item, index) => {
+      const previous = items[index - 1];
+      const between =
+        previous && this.text({ pos: previous.end, end: item.pos });
+      if (between && /\n[ \t]*\n/.test(between)) {
+        rows.push({ cells: [], text: "" });
+      }
+      if (item.comment) {
+        const lines = this.shifted(`\t${this.text(item.comment)}`, item.shift);
+        rows.push(...lines.map((text) => ({ cells: [], text })));
+      } else {
+        const keepType = keep[specs.indexOf(item.spec)];
+        rows.push(...this.specRows(item.spec, keepType, item.shift));
+      }
+    });
+    const tok = run[0].tok;
+    return {
+      start: pos,
+      end,
+      newText: `${tok} (\n${alignRows(rows).join("\n")}\n)`,
+    };
+  }
+
+  /**
+   * The declaration replacing a single-entry block
+   */
+  ungroupedDecl(decl: ValueDecl): TextEdit {
+    const spec = decl.specs[0] as ValueSpec;
+    const dedent = (text: string) => text.replace(/\n\t/g, "\n");
+    const body = this.text({
+      pos: spec.pos,
+      end: spec.comment?.end ?? spec.end,
+    });
+    const doc = spec.doc ? `${dedent(this.text(spec.doc))}\n` : "";
+    return {
+      start: decl.pos,
+      end: decl.end,
+      newText: `${doc}${decl.tok} ${dedent(body)}`,
+    };
+  }
+}
+
+/**
+ * Adjacent package-level `const` and `var` declarations that could share one
+ * block
+ */
+export function findUngroupedDeclarations(
+  files: GoFile[],
+): GoUngroupedDeclFinding[] {
+  const findings: GoUngroupedDeclFinding[] = [];
+  for (const file of files) {
+    for (const run of new DeclGrouper(file).runs()) {
+      const { tok } = run[0];
+      const { line, column } = file.sourceMap.position(run[0].pos);
+      findings.push({
+        rule: "ungrouped-declarations",
+        severity: "low",
+        filePath: file.filePath,
+        line,
+        column,
+        message: `${run.length} adjacent ${tok} declarations could share one ${tok} (...) block`,
+        tok,
+        count: run.length,
+      });
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Merge adjacent `const` and `var` declarations into blocks and, when asked,
+ * unwrap blocks holding a single entry
+ */
+export function normalizeDeclGroups(
+  file: GoFile,
+  options: NormalizeDeclGroupsOptions = {},
+): NormalizeDeclGroupsResult {
+  const policy = options.policy ?? "always-group";
+  if (policy !== "always-group" && policy !== "leave-as-is") {
+    throw new GoRefactorError(
+      `Unknown grouping policy ${JSON.stringify(policy)}; expected always-group or leave-as-is`,
+    );
+  }
+  const grouper = new DeclGrouper(file);
+  const runs = policy === "always-group" ? grouper.runs() : [];
+  const merging = new Set(runs.flat());
+  const lonely = options.ungroupSingle
+    ? grouper.lonely().filter((decl) => !merging.has(decl))
+    : [];
+
+  const mergedBlocks = runs.map((run) => grouper.mergedBlock(run));
+  const ungroupedDecls = lonely.map((decl) => grouper.ungroupedDecl(decl));
+  const edits = [...mergedBlocks, ...ungroupedDecls].sort(
+    (a, b) => a.start - b.start,
+  );
+  const result = refactorResult(file, edits);
+  parseGoFile(result.source, file.filePath);
+
+  // The line an edit's new text starts on, after the edits before it
+  const newLine = (edit: TextEdit) =>
+    edits
+      .filter((other) => other.end <= edit.start)
+      .reduce(
+        (line, other) =>
+          line +
+          other.newText.split("\n").length -
+          file.source.slice(other.start, other.end).split("\n").length,
+        file.sourceMap.line(edit.start),
+      );
+  return {
+    ...result,
+    merged: runs.map((run, index) => ({
+      tok: run[0].tok,
+      line: newLine(mergedBlocks[index]),
+      count: run.length,
+    })),
+    ungrouped: lonely.map((decl, index) => ({
+      tok: decl.tok,
+      line: newLine(ungroupedDecls[index]),
+    })),
+  };
+}
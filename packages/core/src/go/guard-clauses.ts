@@ -0,0 +1,538 @@
+import { TextEdit } from "../diff.js";
+import {
+  BlockStmt,
+  Expr,
+  FuncDecl,
+  FuncLit,
+  GoFile,
+  IfStmt,
+  Node,
+  Stmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+
+/**
+ * Nested Conditionals
+ * ===================
+ * Flags `if` statements nesting further `if` statements that guard clauses
+ * would flatten: `if a != nil { if b != nil { use(a, b) } }` reads better as
+ * `if a == nil { return }`, `if b == nil { return }`, `use(a, b)`. Each level
+ * of the chain inverts its condition into a guard, and the guard takes the
+ * path the code took when the condition failed:
+ *
+ * - the `else` branch, when it ends in a `return`, `panic`, `break`,
+ *   `continue` or `goto`
+ * - the `return` right after the `if`, when the `if` ends in one itself
+ * - the end of the function or loop iteration, when the `if` is its last
+ *   statement: a `return` in a function without results, a `continue` in a
+ *   loop, run after the `else` branch when there is one
+ *
+ * The inverted condition evaluates the same operands in the same order:
+ * `==` and `!=` swap, `!x` becomes `x`, a chain of `&&` or `||` over such
+ * operands uses De Morgan's laws, which keep short-circuiting the same, and
+ * anything else is negated with `!(...)`, so ordered comparisons of floats,
+ * where `<` is not the inverse of `>=`, stay correct. A chain of two levels
+ * or more is reported; the fix flattens the chain at a line even when it has
+ * one level.
+ *
+ * The statements of a branch move to the enclosing block, so a level is not
+ * flattened when it declares a name the block already declares or uses after
+ * the `if`, a parameter when the block is the function body, when the `if`
+ * has an init statement, whose variables would change scope, when comments
+ * would be lost or a multi-line raw string reindented, or when the function
+ * uses `goto`, which may not jump over the moved declarations.
+ */
+
+export interface GoNestedConditionalFinding extends GoFinding {
+  rule: "nested-conditional";
+  /** Levels of nesting the guard clauses remove */
+  depth: number;
+}
+
+export interface FlattenNestedConditionalsOptions {
+  /**
+   * Line of the outermost `if` to flatten, which may have a single level
+   * (default: every chain {@link findNestedConditionals} reports)
+   */
+  line?: number;
+}
+
+export interface FlattenNestedConditionalsResult extends GoRefactorResult {
+  /** Positions of the flattened `if` statements, with the levels removed */
+  flattened: { line: number; column: number; depth: number }[];
+}
+
+type GoFunction = FuncDecl | FuncLit;
+
+// What ends the block when control reaches its end
+type Exit = "return" | "continue";
+
+interface Level {
+  stmt: IfStmt;
+  /** The statements the guard runs, indented for its block */
+  guard: string;
+  /** End of the replaced source: the `if`, or the `return` after it */
+  end: number;
+  /** The branch whose statements move to the enclosing block */
+  hoisted: BlockStmt;
+}
+
+interface Chain {
+  levels: Level[];
+  /** Why the outermost level cannot be flattened, when it cannot */
+  reason?: string;
+}
+
+interface Context {
+  fn: GoFunction;
+  list: Stmt[];
+  exit?: Exit;
+  /** Whether the list is the body of the function */
+  functionBody: boolean;
+}
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+// `a && b && c` as [a, b, c] for op `&&`
+function operands(expr: Expr, op: string): Expr[] {
+  const inner = unparen(expr);
+  if (inner.kind === "BinaryExpr" && inner.op === op) {
+    return [...operands(inner.x, op), ...operands(inner.y, op)];
+  }
+  return [inner];
+}
+
+function isTerminating(list: Stmt[]): boolean {
+  const last = list.at(-1);
+  switch (last?.kind) {
+    case "ReturnStmt":
+      return true;
+    case "BranchStmt":
+      return last.tok !== "fallthrough";
+    case "BlockStmt":
+      return isTerminating(last.list);
+    case "IfStmt":
+      return (
+        last.else !== undefined &&
+        isTerminating(last.body.list) &&
+        (last.else.kind === "IfStmt"
+          ? isTerminating([last.else])
+          : isTerminating(last.else.list))
+      );
+    case "ExprStmt": {
+      const x = unparen(last.x);
+      return (
+        x.kind === "CallExpr" &&
+        x.fun.kind === "Ident" &&
+        x.fun.name === "panic"
+      );
+    }
+    default:
+      return false;
+  }
+}
+
+// Names a statement list declares in its own block
+function declaredNames(list: Stmt[]): string[] {
+  const names: string[] = [];
+  for (const stmt of list) {
+    if (stmt.kind === "AssignStmt" && stmt.tok === ":=") {
+      for (const lhs of stmt.lhs) {
+        if (lhs.kind === "Ident") names.push(lhs.name);
+      }
+    } else if (stmt.kind === "DeclStmt") {
+      for (const spec of stmt.decl.specs) {
+        if (spec.kind === "ValueSpec") {
+          names.push(...spec.names.map((ident) => ident.name));
+        } else if (spec.kind === "TypeSpec") {
+          names.push(spec.name.name);
+        }
+      }
+    }
+  }
+  return names.filter((name) => name !== "_");
+}
+
+function identifiers(nodes: Node[]): string[] {
+  const names: string[] = [];
+  for (const node of nodes) {
+    inspect(node, (inner) => {
+      if (inner.kind === "Ident") names.push(inner.name);
+    });
+  }
+  return names;
+}
+
+function parameterNames(fn: GoFunction): string[] {
+  const lists = [fn.type.params, fn.type.results];
+  if (fn.kind === "FuncDecl") lists.push(fn.recv);
+  return lists.flatMap((list) =>
+    (list?.list ?? []).flatMap((field) =>
+      field.names.map((ident) => ident.name),
+    ),
+  );
+}
+
+class NestedConditionalAnalyzer {
+  private readonly file: GoFile;
+  private readonly contexts: { stmt: IfStmt; context: Context }[] = [];
+
+  constructor(file: GoFile) {
+    this.file = file;
+    inspect(file, (node, parents) => {
+      const parent = parents.at(-1);
+      let list: Stmt[] | undefined;
+      if (node.kind === "BlockStmt") list = node.list;
+      else if (node.kind === "CaseClause" || node.kind === "CommClause") {
+        list = node.body;
+      }
+      if (!list) return;
+      const fn = [...parents]
+        .reverse()
+        .find((at): at is GoFunction =>
+          at.kind === "FuncDecl" || at.kind === "FuncLit",
+        );
+      if (!fn) return;
+      const functionBody = parent === fn;
+      let exit: Exit | undefined;
+      if (functionBody && (fn.type.results?.list.length ?? 0) === 0) {
+        exit = "return";
+      } else if (parent.kind === "ForStmt" || parent.kind === "RangeStmt") {
+        exit = "continue";
+      }
+      for (const stmt of list) {
+        if (stmt.kind !== "IfStmt") continue;
+        this.contexts.push({ stmt, context: { fn, list, exit, functionBody } });
+      }
+    });
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private indentOf(offset: number): string {
+    const { source } = this.file;
+    const lineStart = source.lastIndexOf("\n", offset - 1) + 1;
+    return /^[ \t]*/.exec(source.slice(lineStart))[0];
+  }
+
+  // The inverse of a condition, evaluating the same operands in order
+  private negate(cond: Expr): string {
+    const inner = unparen(cond);
+    if (inner.kind === "UnaryExpr" && inner.op === "!") {
+      return this.text(inner.x);
+    }
+    if (
+      inner.kind === "BinaryExpr" &&
+      (inner.op === "==" || inner.op === "!=")
+    ) {
+      const op = inner.op === "==" ? "!=" : "==";
+      return `${this.text(inner.x)} ${op} ${this.text(inner.y)}`;
+    }
+    if (inner.kind === "BinaryExpr" && ["&&", "||"].includes(inner.op)) {
+      const parts = operands(inner, inner.op);
+      const simple = parts.every(
+        (part) =>
+          (part.kind === "BinaryExpr" && ["==", "!="].includes(part.op)) ||
+          (part.kind === "UnaryExpr" && part.op === "!") ||
+          ["Ident", "SelectorExpr", "CallExpr", "IndexExpr"].includes(
+            part.kind,
+          ),
+      );
+      if (simple) {
+        const op = inner.op === "&&" ? " || " : " && ";
+        return parts.map((part) => this.negate(part)).join(op);
+      }
+    }
+    return inner.kind === "BinaryExpr"
+      ? `!(${this.text(inner)})`
+      : `!${this.text(inner)}`;
+  }
+
+  // The statements of a block on lines of their own, indented one level
+  // deeper than `indent`, with `replace` substituted into its source
+  private blockLines(
+    block: BlockStmt,
+    indent: string,
+    replace?: { start: number; end: number; text: string },
+  ): string {
+    const { source } = this.file;
+    let inner = replace
+      ? source.slice(block.lbrace + 1, replace.start) +
+        replace.text +
+        source.slice(replace.end, block.rbrace)
+      : source.slice(block.lbrace + 1, block.rbrace);
+    if (!inner.includes("\n")) return `${indent}\t${inner.trim()}`;
+    // A comment after the brace goes on a line of its own
+    const newline = inner.indexOf("\n");
+    const first = inner.slice(0, newline).trim();
+    inner = inner.slice(newline + 1).replace(/\s+$/, "");
+    return first === "" ? inner : `${indent}\t${first}\n${inner}`;
+  }
+
+  private hasRawString(nodes: Node[]): boolean {
+    let found = false;
+    for (const node of nodes) {
+      inspect(node, (inner) => {
+        if (
+          inner.kind === "BasicLit" &&
+          inner.value.startsWith("`") &&
+          inner.value.includes("\n")
+        ) {
+          found = true;
+        }
+        return !found;
+      });
+    }
+    return found;
+  }
+
+  // One level of the chain: the if at `index` of the list, as a guard
+  private level(
+    list: Stmt[],
+    index: number,
+    exit: Exit | undefined,
+  ): Level | string {
+    const stmt = list[index] as IfStmt;
+    if (stmt.init) {
+      return "its init statement declares variables for both branches";
+    }
+    if (stmt.else?.kind === "IfStmt") return "it has an else if";
+    if (stmt.body.list.length === 0) return "its body is empty";
+    const last = index === list.length - 1;
+    const next = list[index + 1];
+    const indent = this.indentOf(stmt.pos);
+    let guard: string;
+    let end = stmt.end;
+    if (stmt.else && isTerminating(stmt.else.list)) {
+      guard = this.blockLines(stmt.else, indent);
+    } else if (last && exit) {
+      guard = stmt.else
+        ? `${this.blockLines(stmt.else, indent)}\n${indent}\t${exit}`
+        : `${indent}\t${exit}`;
+    } else if (
+      !stmt.else &&
+      next?.kind === "ReturnStmt" &&
+      index === list.length - 2 &&
+      isTerminating(stmt.body.list)
+    ) {
+      guard = `${indent}\t${this.text(next).replace(/\n/g, "\n\t")}`;
+      end = next.end;
+    } else {
+      return "the code after it would run when its condition fails";
+    }
+
+    const moved: Node[] = [stmt, ...(end === stmt.end ? [] : [next])];
+    if (this.hasRawString(moved)) return "a raw string would be reindented";
+    const blocks = [stmt.body, stmt.else].filter(Boolean);
+    const lost = this.file.comments.some(
+      (group) =>
+        group.pos >= stmt.pos &&
+        group.end <= end &&
+        !blocks.some(
+          (block) => group.pos > block.lbrace && group.end <= block.rbrace,
+        ),
+    );
+    if (lost) return "a comment outside its branches would be lost";
+    return { stmt, guard, end, hoisted: stmt.body };
+  }
+
+  // The levels of the chain starting at the if, up to the first that cannot
+  // be flattened
+  private chain(stmt: IfStmt, context: Context): Chain {
+    const { fn, list } = context;
+    let goto = false;
+    inspect(fn.body, (node) => {
+      goto ||= node.kind === "BranchStmt" && node.tok === "goto";
+    });
+    if (goto) return { levels: [], reason: "the function uses goto" };
+
+    const index = list.indexOf(stmt);
+    const first = this.level(list, index, context.exit);
+    if (typeof first === "string") return { levels: [], reason: first };
+    const others = list.filter(
+      (other) => other.pos < stmt.pos || other.pos >= first.end,
+    );
+    const taken = new Set([
+      ...declaredNames(others),
+      ...identifiers(others.filter((other) => other.pos >= first.end)),
+      ...(context.functionBody ? parameterNames(fn) : []),
+    ]);
+
+    const levels: Level[] = [];
+    let level = first;
+    let atEnd = index === list.length - 1;
+    let exit = context.exit;
+    for (;;) {
+      const hoisted = level.hoisted.list;
+      const last = hoisted.at(-1);
+      let innerIndex = -1;
+      if (last?.kind === "IfStmt") {
+        innerIndex = hoisted.length - 1;
+      } else if (
+        last?.kind === "ReturnStmt" &&
+        hoisted.at(-2)?.kind === "IfStmt"
+      ) {
+        innerIndex = hoisted.length - 2;
+      }
+      const own = declaredNames(
+        innerIndex < 0 ? hoisted : hoisted.slice(0, innerIndex),
+      );
+      const clash = own.find((name) => taken.has(name));
+      if (clash !== undefined) {
+        if (levels.length > 0) break;
+        return {
+          levels,
+          reason: `it declares ${clash}, which the enclosing block already declares or uses`,
+        };
+      }
+      own.forEach((name) => taken.add(name));
+      levels.push(level);
+      if (innerIndex < 0) break;
+
+      // The branch ends where the function or iteration does only when the
+      // if was the last statement of a block that did
+      if (!atEnd || level.end !== level.stmt.end) exit = undefined;
+      const inner = this.level(hoisted, innerIndex, exit);
+      if (typeof inner === "string") break;
+      const trailing = hoisted.filter((other) => other.pos >= inner.end);
+      identifiers(trailing).forEach((name) => taken.add(name));
+      atEnd = trailing.length === 0;
+      level = inner;
+    }
+    return { levels };
+  }
+
+  // The source replacing the levels from `at` on, for an if at `indent`
+  private render(levels: Level[], at: number, indent: string): string {
+    const { stmt, guard, hoisted } = levels[at];
+    const inner = levels[at + 1];
+    const body = this.blockLines(
+      hoisted,
+      indent,
+      inner && {
+        start: inner.stmt.pos,
+        end: inner.end,
+        text: this.render(levels, at + 1, `${indent}\t`),
+      },
+    );
+    const dedented = body.replace(/^\t/gm, "");
+    return [
+      `if ${this.negate(stmt.cond)} {`,
+      guard,
+      `${indent}}`,
+      dedented,
+    ].join("\n");
+  }
+
+  private chains(): { stmt: IfStmt; chain: Chain }[] {
+    const nested = new Set<IfStmt>();
+    const chains: { stmt: IfStmt; chain: Chain }[] = [];
+    for (const { stmt, context } of this.contexts) {
+      if (nested.has(stmt)) continue;
+      const chain = this.chain(stmt, context);
+      chain.levels.slice(1).forEach((level) => nested.add(level.stmt));
+      chains.push({ stmt, chain });
+    }
+    return chains;
+  }
+
+  findings(): GoNestedConditionalFinding[] {
+    return this.chains()
+      .filter(({ chain }) => chain.levels.length >= 2)
+      .map(({ stmt, chain }) => {
+        const depth = chain.levels.length;
+        const [{ guard }] = chain.levels;
+        const body = guard.includes("\n") ? "..." : guard.trim();
+        return {
+          rule: "nested-conditional",
+          severity: "low",
+          filePath: this.file.filePath,
+          ...this.file.sourceMap.position(stmt.pos),
+          message: `${depth} nested if statements can be flattened into guard clauses that exit early`,
+          fix: `if ${this.negate(stmt.cond)} { ${body} }`,
+          depth,
+        };
+      });
+  }
+
+  rewrite(
+    options: FlattenNestedConditionalsOptions,
+  ): FlattenNestedConditionalsResult {
+    const { sourceMap } = this.file;
+    const chains = this.chains().filter(({ stmt, chain }) =>
+      options.line === undefined
+        ? chain.levels.length >= 2
+        : sourceMap.line(stmt.pos) === options.line,
+    );
+    if (options.line !== undefined) {
+      if (chains.length === 0) {
+        throw new GoRefactorError(
+          `No if statement starts on line ${options.line}`,
+        );
+      }
+      const [{ chain }] = chains;
+      if (chain.levels.length === 0) {
+        throw new GoRefactorError(
+          `Cannot flatten the if statement on line ${options.line}: ${chain.reason}`,
+        );
+      }
+    }
+
+    const edits: TextEdit[] = [];
+    const flattened: FlattenNestedConditionalsResult["flattened"] = [];
+    const selected = options.line === undefined ? chains : chains.slice(0, 1);
+    for (const { stmt, chain } of selected) {
+      const { levels } = chain;
+      const [first] = levels;
+      if (!first) continue;
+      const { end } = first;
+      if (edits.some((edit) => edit.start < end && stmt.pos < edit.end)) {
+        continue;
+      }
+      edits.push({
+        start: stmt.pos,
+        end,
+        newText: this.render(levels, 0, this.indentOf(stmt.pos)),
+      });
+      flattened.push({
+        ...sourceMap.position(stmt.pos),
+        depth: levels.length,
+      });
+    }
+    return { ...refactorResult(this.file, edits), flattened };
+  }
+}
+
+/**
+ * Find if statements nesting conditionals that guard clauses would flatten
+ */
+export function findNestedConditionals(
+  files: GoFile[],
+): GoNestedConditionalFinding[] {
+  const findings: GoNestedConditionalFinding[] = [];
+  for (const file of files) {
+    findings.push(...new NestedConditionalAnalyzer(file).findings());
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Flatten nested if statements into guard clauses that return, or continue,
+ * early
+ */
+export function flattenNestedConditionals(
+  file: GoFile,
+  options: FlattenNestedConditionalsOptions = {},
+): FlattenNestedConditionalsResult {
+  return new NestedConditionalAnalyzer(file).rewrite(options);
+}
@@ -0,0 +1,510 @@
+import { createHash } from "crypto";
+import * as fs from "fs";
+import * as path from "path";
+import { diffGoApi, formatGoApi, GoApiDiff, goApiSurface } from "./api.js";
+import { FuncDecl, GoFile } from "./ast.js";
+import { fingerprintGoFindings, GoBaselineEntry } from "./baseline.js";
+import { callGraphId } from "./callgraph.js";
+import { GoCoverProfile, symbolCoverage } from "./coverage.js";
+import { compareCodePoints, GoFinding, GoSeverity } from "./findings.js";
+import { goFunctionSymbol } from "./symbols.js";
+
+/**
+ * Codebase Health Snapshots
+ * =========================
+ * Saves what an analysis found about a codebase, so two points in its
+ * history, say the last release and the current branch, can be compared and
+ * the changes summarized as a regression report for a release checklist. A
+ * snapshot records the findings with their fingerprints, the complexity,
+ * size and coverage of every function, the total coverage and the exported
+ * API listing. File paths are relative to the analyzed root, so snapshots
+ * taken in different checkouts compare.
+ *
+ * Functions are matched by package directory and qualified name. A function
+ * that only one snapshot has under its name is matched by its fingerprint,
+ * a hash of its signature and body without its name and receiver, so it is
+ * reported as renamed rather than as removed and added. Findings are matched
+ * by fingerprint, and a finding in a renamed function, whose fingerprint
+ * changes with the name, by its rule in the function's new name.
+ */
+
+/**
+ * Version of the snapshot file format
+ */
+export const GO_HEALTH_SNAPSHOT_VERSION = 1;
+
+export interface GoHealthFunction {
+  /** Package directory relative to the root, `.` for the root itself */
+  package: string;
+  /** `pkg.Func` or `pkg.Type.Method`, as in the call graph */
+  id: string;
+  filePath: string;
+  line: number;
+  complexity: number;
+  cognitiveComplexity: number;
+  /** Lines holding code */
+  codeLines: number;
+  /** Share of lines covered, when the snapshot had a profile covering it */
+  coveragePct?: number;
+  /** Hash of the signature and body, without the name and receiver */
+  fingerprint: string;
+}
+
+export interface GoHealthFinding extends GoBaselineEntry {
+  severity: GoSeverity;
+  line: number;
+}
+
+export interface GoHealthSnapshot {
+  version: number;
+  /** What the snapshot was taken of, e.g. a release tag */
+  label?: string;
+  /** Share of statements covered, when taken with a profile */
+  coveragePct?: number;
+  /** Sorted by package and id */
+  functions: GoHealthFunction[];
+  findings: GoHealthFinding[];
+  /** Lines of the API listing, as `refactogent api` prints them */
+  api: string[];
+}
+
+export interface GoHealthSnapshotOptions {
+  /** Directory file paths are recorded relative to */
+  root?: string;
+  label?: string;
+  /** Records coverage, per function and in total */
+  coverage?: GoCoverProfile;
+}
+
+/**
+ * A function in both snapshots whose complexity or coverage changed
+ */
+export interface GoFunctionChange {
+  before: GoHealthFunction;
+  after: GoHealthFunction;
+}
+
+export interface GoHealthComparison {
+  before?: string;
+  after?: string;
+  /** Findings only the newer snapshot has */
+  newFindings: GoHealthFinding[];
+  /** Findings only the older snapshot has */
+  resolvedFindings: GoHealthFinding[];
+  /** Functions more complex or less covered than before */
+  worse: GoFunctionChange[];
+  /** Functions simpler or better covered than before */
+  better: GoFunctionChange[];
+  /** Functions matched under another name */
+  renamed: GoFunctionChange[];
+  added: GoHealthFunction[];
+  removed: GoHealthFunction[];
+  coverage?: { before: number; after: number };
+  api: GoApiDiff;
+  /** Whether anything got worse: findings, complexity, coverage or API */
+  regressed: boolean;
+}
+
+/**
+ * Error raised for snapshot files that cannot be read
+ */
+export class GoHealthSnapshotError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoHealthSnapshotError";
+  }
+}
+
+function hash(text: string): string {
+  return createHash("sha256").update(text).digest("hex").slice(0, 16);
+}
+
+function displayPath(filePath: string, root: string | undefined): string {
+  return (root ? path.relative(root, filePath) : filePath)
+    .split(path.sep)
+    .join("/");
+}
+
+function packageDirectory(file: GoFile, root: string | undefined): string {
+  return displayPath(path.dirname(file.filePath), root) || ".";
+}
+
+// The signature and body of a function with whitespace collapsed
+function functionFingerprint(file: GoFile, decl: FuncDecl): string {
+  const text = file.source
+    .slice(decl.type.params.pos, decl.end)
+    .replace(/\s+/g, " ");
+  return hash(text);
+}
+
+// Statement-weighted coverage of a whole profile, as `go tool cover` totals
+function totalCoverage(profile: GoCoverProfile): number | undefined {
+  let covered = 0;
+  let total = 0;
+  for (const blocks of profile.files.values()) {
+    for (const block of blocks) {
+      total += block.statements;
+      if (block.count > 0) covered += block.statements;
+    }
+  }
+  return total === 0 ? undefined : Math.round((covered / total) * 1000) / 10;
+}
+
+/**
+ * Take a snapshot of the health of a set of files and their findings
+ */
+export function goHealthSnapshot(
+  files: GoFile[],
+  findings: GoFinding[],
+  options: GoHealthSnapshotOptions = {},
+): GoHealthSnapshot {
+  const { root, coverage } = options;
+  const functions: GoHealthFunction[] = [];
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl") continue;
+      const symbol = goFunctionSymbol(file, decl);
+      const covered = coverage
+        ? symbolCoverage(coverage, file.filePath, symbol)
+        : undefined;
+      functions.push({
+        package: packageDirectory(file, root),
+        id: callGraphId(file.packageName.name, symbol),
+        filePath: displayPath(file.filePath, root),
+        line: symbol.startLine,
+        complexity: symbol.complexity,
+        cognitiveComplexity: symbol.cognitiveComplexity,
+        codeLines: symbol.size.codeLines,
+        ...(covered && { coveragePct: covered.coveragePct }),
+        fingerprint: functionFingerprint(file, decl),
+      });
+    }
+  }
+  functions.sort(
+    (a, b) =>
+      compareCodePoints(a.package, b.package) ||
+      compareCodePoints(a.id, b.id) ||
+      a.line - b.line,
+  );
+
+  const total = coverage && totalCoverage(coverage);
+  return {
+    version: GO_HEALTH_SNAPSHOT_VERSION,
+    ...(options.label !== undefined && { label: options.label }),
+    ...(total !== undefined && { coveragePct: total }),
+    functions,
+    findings: fingerprintGoFindings(findings, files, { root }).map(
+      ({ finding, fingerprint, filePath, symbol }) => ({
+        fingerprint,
+        rule: finding.rule,
+        filePath,
+        symbol,
+        message: finding.message,
+        severity: finding.severity,
+        line: finding.line,
+      }),
+    ),
+    api: formatGoApi(goApiSurface(files, { root }))
+      .split("\n")
+      .filter((line) => line.length > 0),
+  };
+}
+
+// Functions by package and id, numbering ids declared more than once
+function functionKeys(
+  functions: GoHealthFunction[],
+): Map<string, GoHealthFunction> {
+  const keys = new Map<string, GoHealthFunction>();
+  for (const fn of functions) {
+    const key = `${fn.package}\0${fn.id}`;
+    let unique = key;
+    for (let n = 2; keys.has(unique); n++) unique = `${key}\0${n}`;
+    keys.set(unique, fn);
+  }
+  return keys;
+}
+
+// Pairs of unmatched functions sharing a fingerprint no other one has
+function renames(
+  removed: GoHealthFunction[],
+  added: GoHealthFunction[],
+): GoFunctionChange[] {
+  const count = (functions: GoHealthFunction[]) => {
+    const counts = new Map<string, number>();
+    for (const fn of functions) {
+      counts.set(fn.fingerprint, (counts.get(fn.fingerprint) ?? 0) + 1);
+    }
+    return counts;
+  };
+  const [old, current] = [count(removed), count(added)];
+  return removed
+    .filter(
+      (fn) =>
+        old.get(fn.fingerprint) === 1 && current.get(fn.fingerprint) === 1,
+    )
+    .map((before) => ({
+      before,
+      after: added.find((fn) => fn.fingerprint === before.fingerprint),
+    }));
+}
+
+// The declaration name a finding's symbol gets from a function's id
+function symbolOf(fn: GoHealthFunction): string {
+  return fn.id.slice(fn.id.indexOf(".") + 1);
+}
+
+/**
+ * Compare an older snapshot with a newer one
+ */
+export function compareGoHealthSnapshots(
+  before: GoHealthSnapshot,
+  after: GoHealthSnapshot,
+): GoHealthComparison {
+  const [old, current] = [
+    functionKeys(before.functions),
+    functionKeys(after.functions),
+  ];
+  const pairs: GoFunctionChange[] = [];
+  for (const [key, fn] of old) {
+    if (current.has(key)) pairs.push({ before: fn, after: current.get(key) });
+  }
+  const renamed = renames(
+    [...old].filter(([key]) => !current.has(key)).map(([, fn]) => fn),
+    [...current].filter(([key]) => !old.has(key)).map(([, fn]) => fn),
+  );
+  const matched = new Set(
+    [...pairs, ...renamed].flatMap(({ before, after }) => [before, after]),
+  );
+
+  const worse: GoFunctionChange[] = [];
+  const better: GoFunctionChange[] = [];
+  for (const pair of [...pairs, ...renamed]) {
+    const { before: was, after: now } = pair;
+    const coverage =
+      was.coveragePct !== undefined && now.coveragePct !== undefined
+        ? now.coveragePct - was.coveragePct
+        : 0;
+    const complexity =
+      now.complexity - was.complexity ||
+      now.cognitiveComplexity - was.cognitiveComplexity;
+    if (complexity > 0 || coverage < 0) {
+      worse.push(pair);
+    } else if (complexity < 0 || coverage > 0) {
+      better.push(pair);
+    }
+  }
+
+  // Findings of renamed functions are matched by rule under the new name
+  const known = new Set(after.findings.map((finding) => finding.fingerprint));
+  const resolved = before.findings.filter(
+    (finding) => !known.has(finding.fingerprint),
+  );
+  const seen = new Set(before.findings.map((finding) => finding.fingerprint));
+  const added = after.findings.filter(
+    (finding) => !seen.has(finding.fingerprint),
+  );
+  const newNames = new Map(
+    renamed.map(({ before: was, after: now }) => [
+      `${was.filePath}\0${symbolOf(was)}`,
+      symbolOf(now),
+    ]),
+  );
+  const carried = new Set<GoHealthFinding>();
+  for (const finding of resolved) {
+    const name = newNames.get(`${finding.filePath}\0${finding.symbol}`);
+    const match =
+      name &&
+      added.find(
+        (candidate) =>
+          !carried.has(candidate) &&
+          candidate.rule === finding.rule &&
+          candidate.symbol === name,
+      );
+    if (match) {
+      carried.add(match);
+      carried.add(finding);
+    }
+  }
+
+  const coverage =
+    before.coveragePct !== undefined && after.coveragePct !== undefined
+      ? { before: before.coveragePct, after: after.coveragePct }
+      : undefined;
+  const api = diffGoApi(before.api.join("\n"), after.api.join("\n"));
+  const newFindings = added.filter((finding) => !carried.has(finding));
+  return {
+    ...(before.label !== undefined && { before: before.label }),
+    ...(after.label !== undefined && { after: after.label }),
+    newFindings,
+    resolvedFindings: resolved.filter((finding) => !carried.has(finding)),
+    worse,
+    better,
+    renamed,
+    added: after.functions.filter((fn) => !matched.has(fn)),
+    removed: before.functions.filter((fn) => !matched.has(fn)),
+    ...(coverage && { coverage }),
+    api,
+    regressed:
+      newFindings.length > 0 ||
+      worse.length > 0 ||
+      api.removed.length > 0 ||
+      (coverage !== undefined && coverage.after < coverage.before),
+  };
+}
+
+function signed(value: number): string {
+  const rounded = Math.round(value * 10) / 10;
+  return rounded > 0 ? `+${rounded}` : `${rounded}`;
+}
+
+function functionLine(change: GoFunctionChange): string {
+  const { before: was, after: now } = change;
+  const parts = [
+    `complexity ${was.complexity} → ${now.complexity}`,
+    `cognitive ${was.cognitiveComplexity} → ${now.cognitiveComplexity}`,
+  ];
+  if (was.coveragePct !== undefined && now.coveragePct !== undefined) {
+    parts.push(`coverage ${was.coveragePct}% → ${now.coveragePct}%`);
+  }
+  return `\`${now.id}\` (${now.filePath}): ${parts.join(", ")}`;
+}
+
+function findingLine(finding: GoHealthFinding): string {
+  const where = finding.symbol ? ` in \`${finding.symbol}\`` : "";
+  return `${finding.severity} ${finding.rule} at ${finding.filePath}:${finding.line}${where}: ${finding.message}`;
+}
+
+/**
+ * Render a comparison as a Markdown regression report: regressions as an
+ * unchecked checklist to work through, improvements as plain lists
+ */
+export function formatGoRegressionReport(
+  comparison: GoHealthComparison,
+): string {
+  const { newFindings, resolvedFindings, worse, better, api, coverage } =
+    comparison;
+  const span = `${comparison.before ?? "before"} → ${comparison.after ?? "after"}`;
+  const lines = [
+    `# Regression report: ${span}`,
+    "",
+    comparison.regressed
+      ? "Regressed: review the items below before releasing."
+      : "No regressions.",
+    "",
+    "| | Before | After | Change |",
+    "| --- | ---: | ---: | ---: |",
+    `| Findings | ${resolvedFindings.length} resolved | ${newFindings.length} new | ${signed(newFindings.length - resolvedFindings.length)} |`,
+    `| Functions worse / better | | | ${worse.length} / ${better.length} |`,
+    `| Functions added / removed / renamed | | | ${comparison.added.length} / ${comparison.removed.length} / ${comparison.renamed.length} |`,
+    `| API added / removed | | | ${api.added.length} / ${api.removed.length} |`,
+  ];
+  if (coverage) {
+    lines.push(
+      `| Coverage | ${coverage.before}% | ${coverage.after}% | ${signed(coverage.after - coverage.before)} |`,
+    );
+  }
+
+  const section = (title: string, items: string[], checklist: boolean) => {
+    if (items.length === 0) return;
+    lines.push("", `## ${title}`, "");
+    lines.push(...items.map((item) => `- ${checklist ? "[ ] " : ""}${item}`));
+  };
+  const regressions: string[] = [
+    ...newFindings.map((finding) => `New finding: ${findingLine(finding)}`),
+    ...worse.map((change) => `Worse: ${functionLine(change)}`),
+    ...api.removed.map((line) => `Removed API: \`${line}\``),
+  ];
+  if (coverage && coverage.after < coverage.before) {
+    regressions.push(
+      `Coverage fell from ${coverage.before}% to ${coverage.after}%`,
+    );
+  }
+  section("Regressions", regressions, true);
+  section(
+    "Improvements",
+    [
+      ...resolvedFindings.map(
+        (finding) => `Resolved: ${findingLine(finding)}`,
+      ),
+      ...better.map((change) => `Better: ${functionLine(change)}`),
+    ],
+    false,
+  );
+  section(
+    "Changes",
+    [
+      ...comparison.renamed.map(
+        ({ before: was, after: now }) =>
+          `Renamed: \`${was.id}\` → \`${now.id}\``,
+      ),
+      ...comparison.added.map((fn) => `Added: \`${fn.id}\` (${fn.filePath})`),
+      ...comparison.removed.map(
+        (fn) => `Removed: \`${fn.id}\` (${fn.filePath})`,
+      ),
+      ...api.added.map((line) => `Added API: \`${line}\``),
+    ],
+    false,
+  );
+  return lines.join("\n") + "\n";
+}
+
+/**
+ * Render a snapshot as JSON
+ */
+export function serializeGoHealthSnapshot(snapshot: GoHealthSnapshot): string {
+  return JSON.stringify(snapshot, null, 2) + "\n";
+}
+
+/**
+ * Parse a snapshot file written by {@link serializeGoHealthSnapshot}
+ */
+export function parseGoHealthSnapshot(text: string): GoHealthSnapshot {
+  let json: unknown;
+  try {
+    json = JSON.parse(text);
+  } catch (error) {
+    throw new GoHealthSnapshotError(
+      `Snapshot is not valid JSON: ${error instanceof Error ? error.message : String(error)}`,
+    );
+  }
+  const snapshot = json as Partial<GoHealthSnapshot> | null;
+  if (snapshot?.version !== GO_HEALTH_SNAPSHOT_VERSION) {
+    throw new GoHealthSnapshotError(
+      `Unsupported snapshot version ${JSON.stringify(snapshot?.version)}; expected ${GO_HEALTH_SNAPSHOT_VERSION}`,
+    );
+  }
+  if (
+    !Array.isArray(snapshot.functions) ||
+    !Array.isArray(snapshot.findings) ||
+    !Array.isArray(snapshot.api)
+  ) {
+    throw new GoHealthSnapshotError(
+      "Snapshot must list functions, findings and api",
+    );
+  }
+  return snapshot as GoHealthSnapshot;
+}
+
+/**
+ * Read a snapshot file
+ */
+export async function readGoHealthSnapshot(
+  filePath: string,
+): Promise<GoHealthSnapshot> {
+  return parseGoHealthSnapshot(await fs.promises.readFile(filePath, "utf-8"));
+}
+
+/**
+ * Write a snapshot file, creating its directory when needed
+ */
+export async function writeGoHealthSnapshot(
+  filePath: string,
+  snapshot: GoHealthSnapshot,
+): Promise<void> {
+  await fs.promises.mkdir(path.dirname(filePath), { recursive: true });
+  await fs.promises.writeFile(
+    filePath,
+    serializeGoHealthSnapshot(snapshot),
+    "utf-8",
+  );
+}
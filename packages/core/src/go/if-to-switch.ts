@@ -0,0 +1,274 @@
+import {
+  BlockStmt,
+  Expr,
+  GoFile,
+  IfStmt,
+  Node,
+  inspect,
+} from "./ast.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+
+/**
+ * If-Else Chain to Switch
+ * =======================
+ * Rewrites an `if` / `else if` chain as a `switch`. When every condition
+ * compares the same side-effect free subject for equality (`x == a`, or
+ * `x == a || x == b`), the result is a tagged switch over the subject with the
+ * compared values as cases; otherwise it is a tagless switch with one case
+ * per condition. A trailing `else` becomes the `default` case and an `init`
+ * statement on the first `if` moves to the switch.
+ *
+ * Both forms evaluate the conditions in the same order and stop at the same
+ * arm the chain would, so calls in conditions run exactly as before. Chains
+ * whose conditions depend on each other, through variables declared by an
+ * `else if` or through a repeated condition that leaves an arm unreachable,
+ * are refused, as are arms whose `break` would end up leaving the switch.
+ */
+
+export interface IfToSwitchOptions {
+  /** Line of the first `if` of the chain */
+  line: number;
+}
+
+export interface IfToSwitchResult extends GoRefactorResult {
+  /** Source of the switch tag, undefined for a tagless switch */
+  tag?: string;
+  /** Cases converted from conditions, not counting `default` */
+  cases: number;
+  hasDefault: boolean;
+}
+
+interface Arm {
+  cond: Expr;
+  body: BlockStmt;
+}
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+// `a || b || c` as [a, b, c]
+function disjuncts(expr: Expr): Expr[] {
+  const inner = unparen(expr);
+  if (inner.kind === "BinaryExpr" && inner.op === "||") {
+    return [...disjuncts(inner.x), ...disjuncts(inner.y)];
+  }
+  return [inner];
+}
+
+// Calls other than len and cap, and channel receives, may behave differently
+// when evaluated once instead of once per condition
+function hasSideEffects(expr: Expr): boolean {
+  let effects = false;
+  inspect(expr, (node) => {
+    if (node.kind === "CallExpr") {
+      const { fun } = node;
+      effects ||= fun.kind !== "Ident" || !["len", "cap"].includes(fun.name);
+    } else if (node.kind === "UnaryExpr" && node.op === "<-") {
+      effects = true;
+    }
+    return !effects;
+  });
+  return effects;
+}
+
+class IfToSwitchConverter {
+  private readonly file: GoFile;
+  private readonly options: IfToSwitchOptions;
+
+  constructor(file: GoFile, options: IfToSwitchOptions) {
+    this.file = file;
+    this.options = options;
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  // The first `if` on the line that is not itself the `else` of another
+  private findChain(): IfStmt {
+    const elses = new Set<Node>();
+    let found: IfStmt | undefined;
+    for (const decl of this.file.decls) {
+      if (decl.kind !== "FuncDecl" || !decl.body) continue;
+      inspect(decl, (node) => {
+        if (found) return false;
+        if (node.kind !== "IfStmt") return;
+        if (node.else) elses.add(node.else);
+        if (!elses.has(node) && this.line(node.pos) === this.options.line) {
+          found = node;
+        }
+      });
+    }
+    if (!found) {
+      throw new GoRefactorError(
+        `No if statement starts on line ${this.options.line}`,
+      );
+    }
+    return found;
+  }
+
+  private arms(chain: IfStmt): { arms: Arm[]; otherwise?: BlockStmt } {
+    const arms: Arm[] = [];
+    let current: IfStmt | BlockStmt | undefined = chain;
+    while (current?.kind === "IfStmt") {
+      if (current !== chain && current.init) {
+        throw new GoRefactorError(
+          `The else if on line ${this.line(current.pos)} declares variables for the conditions after it, which a switch cannot express`,
+        );
+      }
+      arms.push({ cond: current.cond, body: current.body });
+      current = current.else;
+    }
+    if (arms.length < 2) {
+      throw new GoRefactorError(
+        `The if statement on line ${this.options.line} has no else if to convert`,
+      );
+    }
+    return { arms, otherwise: current };
+  }
+
+  // An arm repeating an earlier side-effect free condition can never run
+  private checkIndependent(arms: Arm[]): void {
+    const seen = new Map<string, number>();
+    for (const arm of arms) {
+      for (const cond of disjuncts(arm.cond)) {
+        if (hasSideEffects(cond)) continue;
+        const key = this.text(cond).replace(/\s+/g, "");
+        const earlier = seen.get(key);
+        if (earlier !== undefined) {
+          throw new GoRefactorError(
+            `The condition on line ${this.line(cond.pos)} repeats the one on line ${earlier}, so the conditions are not independent`,
+          );
+        }
+        seen.set(key, this.line(cond.pos));
+      }
+    }
+  }
+
+  // An unlabeled `break` in an arm ends the loop or switch around the chain,
+  // but would end the new switch instead
+  private checkBreaks(blocks: BlockStmt[]): void {
+    for (const block of blocks) {
+      inspect(block, (node) => {
+        switch (node.kind) {
+          case "ForStmt":
+          case "RangeStmt":
+          case "SwitchStmt":
+          case "TypeSwitchStmt":
+          case "SelectStmt":
+          case "FuncLit":
+            return false;
+          case "BranchStmt":
+            if (node.tok === "break" && !node.label) {
+              throw new GoRefactorError(
+                `The break on line ${this.line(node.pos)} would leave the switch instead of the enclosing statement`,
+              );
+            }
+        }
+      });
+    }
+  }
+
+  // The subject every condition compares for equality, with the values it
+  // is compared to per arm
+  private tagged(arms: Arm[]): { tag: string; values: Expr[][] } | undefined {
+    const first = disjuncts(arms[0].cond)[0];
+    if (first.kind !== "BinaryExpr" || first.op !== "==") return undefined;
+
+    for (const candidate of [first.x, first.y]) {
+      if (hasSideEffects(candidate)) continue;
+      const tag = this.text(candidate);
+      const values = arms.map((arm) =>
+        disjuncts(arm.cond).map((cond) => {
+          if (cond.kind !== "BinaryExpr" || cond.op !== "==") return undefined;
+          if (this.text(cond.x) === tag) return cond.y;
+          if (this.text(cond.y) === tag) return cond.x;
+          return undefined;
+        }),
+      );
+      if (values.every((list) => list.every((value) => value !== undefined))) {
+        return { tag, values };
+      }
+    }
+    return undefined;
+  }
+
+  // The statements of a block, indented for a case of a switch at `indent`
+  private caseBody(block: BlockStmt, indent: string): string {
+    const inner = this.file.source.slice(block.lbrace + 1, block.rbrace);
+    if (inner.trim() === "") return "";
+    if (inner.startsWith("\n")) return inner.replace(/\n[ \t]*$/, "");
+    return `\n${indent}\t${inner.trim()}`;
+  }
+
+  // Comments between the arms have no place in the switch
+  private checkComments(chain: IfStmt, arms: Arm[], blocks: BlockStmt[]) {
+    const conditions = arms.map((arm) => arm.cond);
+    for (const group of this.file.comments) {
+      if (group.pos < chain.pos || group.end > chain.end) continue;
+      const inside = [...blocks, ...conditions, chain.init].some(
+        (node) => node && group.pos >= node.pos && group.end <= node.end,
+      );
+      if (!inside) {
+        throw new GoRefactorError(
+          `The comment on line ${this.line(group.pos)} sits between the arms of the chain`,
+        );
+      }
+    }
+  }
+
+  convert(): IfToSwitchResult {
+    const chain = this.findChain();
+    const { arms, otherwise } = this.arms(chain);
+    this.checkIndependent(arms);
+    const blocks = [...arms.map((arm) => arm.body), otherwise].filter(Boolean);
+    this.checkBreaks(blocks);
+    this.checkComments(chain, arms, blocks);
+
+    const { source, sourceMap } = this.file;
+    const lineStart = sourceMap.lineStart(this.line(chain.pos));
+    const indent = /^[ \t]*/.exec(source.slice(lineStart, chain.pos))[0];
+    const tagged = this.tagged(arms);
+
+    const init = chain.init ? `${this.text(chain.init)}; ` : "";
+    const lines = [tagged ? `switch ${init}${tagged.tag} {` : `switch ${init}{`];
+    arms.forEach((arm, index) => {
+      const cases = tagged
+        ? tagged.values[index].map((value) => this.text(value)).join(", ")
+        : this.text(arm.cond);
+      lines.push(`${indent}case ${cases}:${this.caseBody(arm.body, indent)}`);
+    });
+    if (otherwise) {
+      lines.push(`${indent}default:${this.caseBody(otherwise, indent)}`);
+    }
+    lines.push(`${indent}}`);
+
+    return {
+      ...refactorResult(this.file, [
+        { start: chain.pos, end: chain.end, newText: lines.join("\n") },
+      ]),
+      tag: tagged?.tag,
+      cases: arms.length,
+      hasDefault: otherwise !== undefined,
+    };
+  }
+}
+
+/**
+ * Rewrite the if / else if chain starting on a line as a switch statement
+ */
+export function convertIfElseToSwitch(
+  file: GoFile,
+  options: IfToSwitchOptions,
+): IfToSwitchResult {
+  return new IfToSwitchConverter(file, options).convert();
+}
@@ -0,0 +1,145 @@
+import * as path from "path";
+import { PlannedChange } from "../plan.js";
+import { GoFile } from "./ast.js";
+import { parseGoFile } from "./parser.js";
+import { GoRefactorResult } from "./refactor.js";
+import { GoTypeParam } from "./signature.js";
+import { extractGoFileSymbols, isExportedName, Visibility } from "./symbols.js";
+
+/**
+ * Go Refactor Impact
+ * ==================
+ * Turns Go refactor results into plan entries carrying an impact estimate.
+ * Every edit of a result counts as one updated reference site. Exported API
+ * is compared before and after across all changed files of a package, so a
+ * declaration moved from one file to another is not a change, while removing
+ * or renaming an exported function, method, type, field, constant or
+ * variable, or changing its signature, is. Additions never break callers and
+ * are not reported. Each change is recorded on the file that declared the
+ * symbol before the refactor.
+ */
+
+export interface GoPlanOptions {
+  /** Symbols the refactor affects, recorded on every entry */
+  symbols?: string[];
+}
+
+/**
+ * An exported name and its source file
+ */
+interface ExportedSymbol {
+  filePath: string;
+  /** Signature or type the name is declared with */
+  shape: string;
+}
+
+function typeParamsText(params: GoTypeParam[] | undefined): string {
+  if (!params) return "";
+  return `[${params.map((param) => `${param.name} ${param.constraint}`).join(", ")}]`;
+}
+
+// The exported API of a file, by name
+function exportedApi(file: GoFile): Map<string, ExportedSymbol> {
+  const api = new Map<string, ExportedSymbol>();
+  const add = (name: string, shape: string) =>
+    api.set(name, { filePath: file.filePath, shape });
+  const symbols = extractGoFileSymbols(file);
+
+  for (const fn of symbols.functions) {
+    if (!fn.isExported) continue;
+    add(fn.name, `func${typeParamsText(fn.typeParams)}${fn.signature.text}`);
+  }
+  for (const method of symbols.methods) {
+    const receiver = method.receiver;
+    if (!method.isExported || !isExportedName(receiver.typeName)) continue;
+    const pointer = receiver.isPointer ? "*" : "";
+    add(
+      method.qualifiedName,
+      `func (${pointer}${receiver.typeName}) ${method.signature.text}`,
+    );
+  }
+  for (const type of symbols.types) {
+    if (!type.isExported) continue;
+    add(type.name, `${type.typeKind}${typeParamsText(type.typeParams)}`);
+    for (const field of type.fields) {
+      if (field.visibility !== Visibility.Exported) continue;
+      add(`${type.name}.${field.name}`, field.type);
+    }
+  }
+  for (const constant of symbols.constants) {
+    if (constant.isExported) {
+      add(constant.name, `const ${constant.declaredType}`);
+    }
+  }
+  for (const decl of file.decls) {
+    if (decl.kind !== "GenDecl" || decl.tok !== "var") continue;
+    for (const spec of decl.specs) {
+      if (spec.kind !== "ValueSpec") continue;
+      const type = spec.type
+        ? file.source.slice(spec.type.pos, spec.type.end)
+        : "";
+      for (const ident of spec.names) {
+        if (isExportedName(ident.name)) add(ident.name, `var ${type}`);
+      }
+    }
+  }
+  return api;
+}
+
+// Exported symbols removed or changed between two versions of a package's
+// changed files, by the file declaring them before
+function exportedChanges(
+  before: GoFile[],
+  after: GoFile[],
+): Map<string, string[]> {
+  const merge = (files: GoFile[]) =>
+    new Map(files.flatMap((file) => [...exportedApi(file)]));
+  const old = merge(before);
+  const current = merge(after);
+  const changes = new Map<string, string[]>();
+  for (const [name, symbol] of old) {
+    if (current.get(name)?.shape === symbol.shape) continue;
+    if (!changes.has(symbol.filePath)) changes.set(symbol.filePath, []);
+    changes.get(symbol.filePath).push(name);
+  }
+  return changes;
+}
+
+/**
+ * Plan entries for the files Go refactor results change, each with the
+ * number of sites it updates and the exported API it changes. `files` are
+ * the parsed files the refactor ran on; results for other paths create new
+ * files.
+ */
+export function goPlannedChanges(
+  files: GoFile[],
+  results: GoRefactorResult[],
+  options: GoPlanOptions = {},
+): PlannedChange[] {
+  const originals = new Map(files.map((file) => [file.filePath, file]));
+  const packages = new Map<string, { before: GoFile[]; after: GoFile[] }>();
+  for (const result of results) {
+    const original = originals.get(result.filePath);
+    const updated = parseGoFile(result.source, result.filePath);
+    const key = `${path.dirname(result.filePath)}\0${updated.packageName.name}`;
+    if (!packages.has(key)) packages.set(key, { before: [], after: [] });
+    const group = packages.get(key);
+    if (original) group.before.push(original);
+    group.after.push(updated);
+  }
+  const changed = new Map<string, string[]>();
+  for (const { before, after } of packages.values()) {
+    for (const [filePath, names] of exportedChanges(before, after)) {
+      changed.set(filePath, names);
+    }
+  }
+
+  return results.map((result) => ({
+    filePath: result.filePath,
+    original: originals.get(result.filePath)?.source ?? null,
+    content: result.source,
+    symbols: options.symbols,
+    references: result.edits.length,
+    exportedChanges: changed.get(result.filePath) ?? [],
+  }));
+}
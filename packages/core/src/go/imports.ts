@@ -0,0 +1,246 @@
+import { TextEdit } from "../diff.js";
+import { Decl, GenDecl, GoFile, ImportSpec, inspect } from "./ast.js";
+import { compareCodePoints, GoFinding, sortFindings } from "./findings.js";
+import { resolveFunctionScopes } from "./scope.js";
+
+/**
+ * Go Import Dependencies
+ * ======================
+ * Records the imports of a file with the names they bind and works out which
+ * of them each declaration selects from, so transforms that move code know
+ * the minimal imports it needs. Dot imports merge a package into the file's
+ * scope and blank imports only run a package's initialization; neither binds
+ * a name, so they are kept apart from named imports and never attributed to a
+ * declaration.
+ */
+
+/**
+ * How an import binds its package: under the package's own name, under an
+ * explicit alias, merged into the file scope (`.`) or not at all (`_`)
+ */
+export type GoImportKind = "package" | "alias" | "dot" | "blank";
+
+export interface GoImport {
+  path: string;
+  /** Name code uses to refer to the package; undefined for dot and blank */
+  name?: string;
+  kind: GoImportKind;
+  line: number;
+  column: number;
+}
+
+export interface GoUnusedImportFinding extends GoFinding {
+  rule: "unused-import";
+  path: string;
+}
+
+/**
+ * The import path of a spec, without quotes
+ */
+export function importPath(spec: ImportSpec): string {
+  return spec.path.value.slice(1, -1);
+}
+
+/**
+ * The name a package is referred to by when imported without an alias: its
+ * last path element, skipping a major version suffix (`example.com/mod/v2`)
+ * and dropping gopkg.in's `.vN` (`gopkg.in/yaml.v3`)
+ */
+export function defaultImportName(path: string): string {
+  const elements = path.split("/");
+  let last = elements.pop();
+  if (/^v\d+$/.test(last) && elements.length > 1) last = elements.pop();
+  return last.replace(/\.v\d+$/, "");
+}
+
+/**
+ * The name a spec binds, "." for dot imports and "_" for blank ones
+ */
+export function importName(spec: ImportSpec): string {
+  return spec.name?.name ?? defaultImportName(importPath(spec));
+}
+
+function importKind(spec: ImportSpec): GoImportKind {
+  switch (spec.name?.name) {
+    case undefined:
+      return "package";
+    case ".":
+      return "dot";
+    case "_":
+      return "blank";
+    default:
+      return "alias";
+  }
+}
+
+/**
+ * The imports of a file in source order
+ */
+export function fileImports(file: GoFile): GoImport[] {
+  return file.imports.map((spec) => {
+    const kind = importKind(spec);
+    return {
+      path: importPath(spec),
+      name: kind === "dot" || kind === "blank" ? undefined : importName(spec),
+      kind,
+      ...file.sourceMap.position(spec.pos),
+    };
+  });
+}
+
+/**
+ * Import names a declaration selects from, out of `names`, ignoring local
+ * variables that shadow them
+ */
+export function usedImportNames(decl: Decl, names: Set<string>): Set<string> {
+  const used = new Set<string>();
+  const resolved =
+    decl.kind === "FuncDecl" ? resolveFunctionScopes(decl).resolved : undefined;
+  inspect(decl, (node) => {
+    if (
+      node.kind === "SelectorExpr" &&
+      node.x.kind === "Ident" &&
+      names.has(node.x.name) &&
+      !resolved?.has(node.x)
+    ) {
+      used.add(node.x.name);
+    }
+  });
+  return used;
+}
+
+/**
+ * Paths of the named imports a declaration uses, sorted
+ */
+export function declarationImports(file: GoFile, decl: Decl): string[] {
+  const byName = new Map<string, string>();
+  for (const spec of file.imports) {
+    const kind = importKind(spec);
+    if (kind === "package" || kind === "alias") {
+      byName.set(importName(spec), importPath(spec));
+    }
+  }
+  const used = usedImportNames(decl, new Set(byName.keys()));
+  return [...used].map((name) => byName.get(name)).sort();
+}
+
+/**
+ * An import to add to a file; `name` is an alias, `.` or `_`
+ */
+export interface GoImportEntry {
+  path: string;
+  name?: string;
+}
+
+function importLine(entry: GoImportEntry): string {
+  const quoted = JSON.stringify(entry.path);
+  return entry.name ? `${entry.name} ${quoted}` : quoted;
+}
+
+/**
+ * An import declaration for the entries, grouped when there are several
+ */
+export function importDecl(entries: GoImportEntry[]): string {
+  if (entries.length === 1) {
+    return `import ${importLine(entries[0])}`;
+  }
+  const lines = entries.map((entry) => `\t${importLine(entry)}`);
+  return `import (\n${lines.join("\n")}\n)`;
+}
+
+/**
+ * Edits adding imports to a file: into its import group in path order, by
+ * turning a lone import into a group, or after the package clause. Entries
+ * the file already imports are not checked for.
+ */
+export function importEdits(
+  file: GoFile,
+  entries: GoImportEntry[],
+): TextEdit[] {
+  if (entries.length === 0) return [];
+  const { sourceMap } = file;
+  const line = (offset: number) => sourceMap.line(offset);
+  const importDecls = file.decls.filter(
+    (decl): decl is GenDecl => decl.kind === "GenDecl" && decl.tok === "import",
+  );
+  const group = importDecls.find((decl) => decl.lparen >= 0);
+  if (group) {
+    const specs = group.specs as ImportSpec[];
+    return entries.map((entry) => {
+      const next = specs.find(
+        (candidate) => importPath(candidate) > entry.path,
+      );
+      const at = next
+        ? sourceMap.lineStart(line(next.doc?.pos ?? next.pos))
+        : sourceMap.lineStart(line(specs.at(-1)?.end ?? group.lparen) + 1);
+      return { start: at, end: at, newText: `\t${importLine(entry)}\n` };
+    });
+  }
+
+  const last = importDecls.at(-1);
+  if (last) {
+    const existing = (last.specs as ImportSpec[]).map((spec) => ({
+      path: importPath(spec),
+      name: spec.name?.name,
+    }));
+    const sorted = [...existing, ...entries].sort((a, b) =>
+      compareCodePoints(a.path, b.path),
+    );
+    return [
+      {
+        start: last.pos,
+        end: last.end,
+        newText: `import (\n${sorted.map((entry) => `\t${importLine(entry)}`).join("\n")}\n)`,
+      },
+    ];
+  }
+
+  return [firstImportEdit(file, importDecl(entries))];
+}
+
+/**
+ * An edit placing the first import declaration of a file after its package
+ * clause line, before any trailing newline
+ */
+export function firstImportEdit(file: GoFile, decl: string): TextEdit {
+  const { source, sourceMap } = file;
+  const lineEnd = sourceMap.lineStart(sourceMap.line(file.packageName.end) + 1);
+  const at = source[lineEnd - 1] === "\n" ? lineEnd - 1 : lineEnd;
+  return { start: at, end: at, newText: `\n\n${decl}` };
+}
+
+/**
+ * Named imports no declaration of their file uses. Dot and blank imports are
+ * not reported, nor is cgo's `import "C"`.
+ */
+export function findUnusedImports(files: GoFile[]): GoUnusedImportFinding[] {
+  const findings: GoUnusedImportFinding[] = [];
+  for (const file of files) {
+    const specs = file.imports.filter((spec) => {
+      const kind = importKind(spec);
+      return (
+        (kind === "package" || kind === "alias") && importPath(spec) !== "C"
+      );
+    });
+    const names = new Set(specs.map(importName));
+    const used = new Set<string>();
+    for (const decl of file.decls) {
+      usedImportNames(decl, names).forEach((name) => used.add(name));
+    }
+    for (const spec of specs) {
+      if (used.has(importName(spec))) continue;
+      const path = importPath(spec);
+      findings.push({
+        rule: "unused-import",
+        severity: "medium",
+        filePath: file.filePath,
+        ...file.sourceMap.position(spec.pos),
+        message: spec.name
+          ? `${path} is imported as ${spec.name.name} but not used`
+          : `${path} is imported but not used`,
+        path,
+      });
+    }
+  }
+  return sortFindings(findings);
+}
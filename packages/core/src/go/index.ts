@@ -0,0 +1,109 @@
+export * from "./analyze.js";
+export * from "./any-returns.js";
+export * from "./api.js";
+export * from "./assertions.js";
+export * from "./ast.js";
+export * from "./baseline.js";
+export * from "./benchmark.js";
+export * from "./bool-return.js";
+export * from "./cache.js";
+export * from "./callgraph.js";
+export * from "./characterize.js";
+export * from "./checkpoint.js";
+export * from "./checkstyle.js";
+export * from "./cleanup.js";
+export * from "./clock.js";
+export * from "./clones.js";
+export * from "./complexity.js";
+export * from "./confidence.js";
+export * from "./config.js";
+export * from "./constants.js";
+export * from "./constructor.js";
+export * from "./context-field.js";
+export * from "./context-param.js";
+export * from "./conversions.js";
+export * from "./coverage.js";
+export * from "./deadcode.js";
+export * from "./defensive-copy.js";
+export * from "./dependencies.js";
+export * from "./discover.js";
+export * from "./doc-coverage.js";
+export * from "./doc-sync.js";
+export * from "./error-compare.js";
+export * from "./errors.js";
+export * from "./examples.js";
+export * from "./extract-constant.js";
+export * from "./extract-function.js";
+export * from "./extract-interface.js";
+export * from "./findings.js";
+export * from "./fingerprint.js";
+export * from "./flag-args.js";
+export * from "./function-to-method.js";
+export * from "./gate.js";
+export * from "./git-diff.js";
+export * from "./globals.js";
+export * from "./group-decls.js";
+export * from "./guard-clauses.js";
+export * from "./health.js";
+export * from "./if-to-switch.js";
+export * from "./impact.js";
+export * from "./imports.js";
+export * from "./infer.js";
+export * from "./inline-function.js";
+export * from "./jsonl.js";
+export * from "./keyed-literals.js";
+export * from "./lexer.js";
+export * from "./lsp.js";
+export * from "./maintainability.js";
+export * from "./map-access.js";
+export * from "./map-struct.js";
+export * from "./move-function.js";
+export * from "./naked-returns.js";
+export * from "./naming.js";
+export * from "./numeric.js";
+export * from "./overlay.js";
+export * from "./package.js";
+export * from "./panics.js";
+export * from "./parameter-object.js";
+export * from "./parser.js";
+export * from "./pipeline.js";
+export * from "./platforms.js";
+export * from "./prealloc.js";
+export * from "./priority.js";
+export * from "./profile.js";
+export * from "./quick-fix.js";
+export * from "./range-loops.js";
+export * from "./receivers.js";
+export * from "./refactor.js";
+export * from "./references.js";
+export * from "./rename.js";
+export * from "./report.js";
+export * from "./review.js";
+export * from "./rules.js";
+export * from "./sarif.js";
+export * from "./scope.js";
+export * from "./serialize.js";
+export * from "./server.js";
+export * from "./shadow.js";
+export * from "./shared-fields.js";
+export * from "./signature.js";
+export * from "./size.js";
+export * from "./snapshot.js";
+export * from "./sort-imports.js";
+export * from "./split-function.js";
+export * from "./split-struct.js";
+export * from "./sprintf.js";
+export * from "./stream.js";
+export * from "./string-builder.js";
+export * from "./suppress.js";
+export * from "./symbol-at.js";
+export * from "./symbols.js";
+export * from "./table-test.js";
+export * from "./targets.js";
+export * from "./template.js";
+export * from "./test-links.js";
+export * from "./todos.js";
+export * from "./typed-errors.js";
+export * from "./unused-params.js";
+export * from "./watch.js";
+export * from "./wrap-errors.js";
@@ -0,0 +1,542 @@
+import { CallExpr, Expr, FuncType, GoFile, Node } from "./ast.js";
+import { GoFunctionScopes, GoVariable } from "./scope.js";
+
+/**
+ * Go Type Inference
+ * =================
+ * Best-effort static types for expressions, without a type checker. Types are
+ * rendered as Go source text (`[]string`, `map[string]int`, `*Config`) so they
+ * can be written straight into generated code. Inference covers literals,
+ * declarations, builtins, calls to functions and methods declared in the same
+ * file, and a table of common standard library functions; anything else is
+ * reported as unknown.
+ */
+
+// Result types of common standard library functions, keyed by import path
+const STDLIB_RESULTS: Record<string, string[]> = {
+  "errors.New": ["error"],
+  "fmt.Errorf": ["error"],
+  "fmt.Sprint": ["string"],
+  "fmt.Sprintf": ["string"],
+  "fmt.Sprintln": ["string"],
+  "os.Chdir": ["error"],
+  "os.Getenv": ["string"],
+  "os.Mkdir": ["error"],
+  "os.MkdirAll": ["error"],
+  "os.Open": ["*os.File", "error"],
+  "os.ReadFile": ["[]byte", "error"],
+  "os.Remove": ["error"],
+  "os.RemoveAll": ["error"],
+  "os.Rename": ["error"],
+  "os.Setenv": ["error"],
+  "os.WriteFile": ["error"],
+  "strconv.Atoi": ["int", "error"],
+  "strconv.FormatBool": ["string"],
+  "strconv.FormatFloat": ["string"],
+  "strconv.FormatInt": ["string"],
+  "strconv.Itoa": ["string"],
+  "strconv.ParseBool": ["bool", "error"],
+  "strconv.ParseFloat": ["float64", "error"],
+  "strconv.ParseInt": ["int64", "error"],
+  "strconv.Quote": ["string"],
+  "strings.Compare": ["int"],
+  "strings.Contains": ["bool"],
+  "strings.ContainsAny": ["bool"],
+  "strings.ContainsRune": ["bool"],
+  "strings.Count": ["int"],
+  "strings.Cut": ["string", "string", "bool"],
+  "strings.EqualFold": ["bool"],
+  "strings.Fields": ["[]string"],
+  "strings.HasPrefix": ["bool"],
+  "strings.HasSuffix": ["bool"],
+  "strings.Index": ["int"],
+  "strings.Join": ["string"],
+  "strings.LastIndex": ["int"],
+  "strings.Repeat": ["string"],
+  "strings.Replace": ["string"],
+  "strings.ReplaceAll": ["string"],
+  "strings.Split": ["[]string"],
+  "strings.SplitN": ["[]string"],
+  "strings.ToLower": ["string"],
+  "strings.ToUpper": ["string"],
+  "strings.Trim": ["string"],
+  "strings.TrimPrefix": ["string"],
+  "strings.TrimSpace": ["string"],
+  "strings.TrimSuffix": ["string"],
+  "time.Now": ["time.Time"],
+  "time.Since": ["time.Duration"],
+};
+
+const BASIC_TYPES = new Set([
+  "bool",
+  "byte",
+  "complex64",
+  "complex128",
+  "error",
+  "float32",
+  "float64",
+  "int",
+  "int8",
+  "int16",
+  "int32",
+  "int64",
+  "rune",
+  "string",
+  "uint",
+  "uint8",
+  "uint16",
+  "uint32",
+  "uint64",
+  "uintptr",
+  "any",
+]);
+
+const NUMERIC_TYPES = new Set([
+  "byte",
+  "complex64",
+  "complex128",
+  "float32",
+  "float64",
+  "int",
+  "int8",
+  "int16",
+  "int32",
+  "int64",
+  "rune",
+  "uint",
+  "uint8",
+  "uint16",
+  "uint32",
+  "uint64",
+  "uintptr",
+]);
+
+const COMPARISON_OPS = new Set(["==", "!=", "<", "<=", ">", ">=", "&&", "||"]);
+
+// Index just past the bracket matching the one at `open`
+function matchBracket(text: string, open: number): number {
+  let depth = 0;
+  for (let i = open; i < text.length; i++) {
+    if (text[i] === "[" || text[i] === "(" || text[i] === "{") depth++;
+    if (text[i] === "]" || text[i] === ")" || text[i] === "}") depth--;
+    if (depth === 0) return i + 1;
+  }
+  return text.length;
+}
+
+/**
+ * Element type of a slice, array, pointer-to-array or channel type
+ */
+export function elementType(type: string): string | undefined {
+  if (type.startsWith("[")) {
+    return type.slice(matchBracket(type, 0));
+  }
+  if (type.startsWith("*[")) {
+    return elementType(type.slice(1));
+  }
+  const chan = type.match(/^(?:<-\s*)?chan(?:<-)?\s+(.*)$/);
+  return chan ? chan[1] : undefined;
+}
+
+/**
+ * Key and value types of a map type
+ */
+export function mapTypes(
+  type: string,
+): { key: string; value: string } | undefined {
+  if (!type.startsWith("map[")) {
+    return undefined;
+  }
+  const close = matchBracket(type, 3);
+  return { key: type.slice(4, close - 1), value: type.slice(close) };
+}
+
+/**
+ * The zero value of a type, written as Go source
+ */
+export function zeroValue(
+  type: string,
+  underlying: (name: string) => string | undefined = () => undefined,
+): string {
+  const resolved = underlying(type) ?? type;
+  if (NUMERIC_TYPES.has(resolved)) return "0";
+  if (resolved === "string") return '""';
+  if (resolved === "bool") return "false";
+  if (
+    /^(\*|\[\]|map\[|chan\b|<-|func\b|interface\b)/.test(resolved) ||
+    resolved === "error" ||
+    resolved === "any"
+  ) {
+    return "nil";
+  }
+  if (resolved.startsWith("struct") || /^\[/.test(resolved)) {
+    return `${type}{}`;
+  }
+  // Any type at all can be zeroed this way
+  return `*new(${type})`;
+}
+
+/**
+ * Whether an expression is written as the zero value of its type
+ */
+export function isZeroLiteral(expr: Expr): boolean {
+  switch (expr.kind) {
+    case "Ident":
+      return expr.name === "nil" || expr.name === "false";
+    case "BasicLit":
+      return /^(0+(\.0*)?|""|``)$/.test(expr.value);
+    case "CompositeLit":
+      return expr.elts.length === 0;
+    default:
+      return false;
+  }
+}
+
+/**
+ * Infers types of expressions and local variables within one file
+ */
+export class GoTypeInference {
+  private readonly file: GoFile;
+  private readonly scopes?: GoFunctionScopes;
+  private readonly imports = new Map<string, string>();
+  private readonly functions = new Map<string, FuncType>();
+  // Keyed by `Type.method`
+  private readonly methods = new Map<string, FuncType>();
+  private readonly typeDecls = new Map<string, Expr>();
+  private readonly packageVars = new Map<
+    string,
+    { type?: Expr; value?: Expr; constant: boolean }
+  >();
+  private readonly visiting = new Set<GoVariable>();
+
+  constructor(file: GoFile, scopes?: GoFunctionScopes) {
+    this.file = file;
+    this.scopes = scopes;
+    for (const spec of file.imports) {
+      const importPath = spec.path.value.slice(1, -1);
+      const name = spec.name?.name ?? importPath.split("/").pop();
+      this.imports.set(name, importPath);
+    }
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl") {
+        const recv = decl.recv?.list[0]?.type;
+        if (recv) {
+          const base = this.text(recv).replace(/^\*/, "").replace(/\[.*$/, "");
+          this.methods.set(`${base}.${decl.name.name}`, decl.type);
+        } else {
+          this.functions.set(decl.name.name, decl.type);
+        }
+      } else if (decl.kind === "GenDecl") {
+        for (const spec of decl.specs) {
+          if (spec.kind === "TypeSpec") {
+            this.typeDecls.set(spec.name.name, spec.type);
+          } else if (spec.kind === "ValueSpec") {
+            spec.names.forEach((name, index) =>
+              this.packageVars.set(name.name, {
+                type: spec.type,
+                value:
+                  spec.values.length === spec.names.length
+                    ? spec.values[index]
+                    : undefined,
+                constant: decl.tok === "const",
+              }),
+            );
+          }
+        }
+      }
+    }
+  }
+
+  text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  /**
+   * Underlying type text of a named type declared in the file
+   */
+  underlying(type: string): string | undefined {
+    let current = type;
+    for (let depth = 0; depth < 8; depth++) {
+      const decl = this.typeDecls.get(current);
+      if (!decl) break;
+      current = this.text(decl);
+    }
+    return current === type ? undefined : current;
+  }
+
+  private resolve(type: string | undefined): string | undefined {
+    return type === undefined ? undefined : (this.underlying(type) ?? type);
+  }
+
+  private resultTypes(fn: FuncType): string[] {
+    const types: string[] = [];
+    for (const field of fn.results?.list ?? []) {
+      const count = Math.max(field.names.length, 1);
+      for (let i = 0; i < count; i++) types.push(this.text(field.type));
+    }
+    return types;
+  }
+
+  /**
+   * Types produced by a call, for single- and multi-value contexts
+   */
+  callResults(expr: Expr): string[] | undefined {
+    if (expr.kind !== "CallExpr") return undefined;
+    const results = this.callResultsOf(expr);
+    if (results) return results;
+    const single = this.typeOf(expr);
+    return single === undefined ? undefined : [single];
+  }
+
+  /**
+   * Type of the value at `index` of an expression that yields `count` values
+   */
+  typeOfValue(expr: Expr, index: number, count: number): string | undefined {
+    if (count === 1) return this.typeOf(expr);
+    switch (expr.kind) {
+      case "CallExpr":
+        return this.callResults(expr)?.[index];
+      case "IndexExpr":
+      case "TypeAssertExpr":
+        // Comma-ok forms
+        return index === 1 ? "bool" : this.typeOf(expr);
+      case "UnaryExpr":
+        return expr.op === "<-" && index === 1 ? "bool" : this.typeOf(expr);
+      default:
+        return undefined;
+    }
+  }
+
+  typeOfVariable(variable: GoVariable): string | undefined {
+    const { typeExpr } = variable;
+    if (typeExpr?.kind === "Ellipsis") {
+      // Variadic parameters are slices inside the function
+      return `[]${this.text(typeExpr.elt)}`;
+    }
+    if (typeExpr) return this.text(typeExpr);
+    if (this.visiting.has(variable)) return undefined;
+    this.visiting.add(variable);
+    try {
+      if (variable.init) {
+        const { expr, index, count } = variable.init;
+        return this.typeOfValue(expr, index, count);
+      }
+      if (variable.rangeOf) {
+        const { expr, slot } = variable.rangeOf;
+        const ranged = this.resolve(this.typeOf(expr));
+        if (!ranged) return undefined;
+        if (ranged === "string") return slot === "key" ? "int" : "rune";
+        if (NUMERIC_TYPES.has(ranged)) return ranged;
+        const map = mapTypes(ranged);
+        if (map) return slot === "key" ? map.key : map.value;
+        if (ranged.includes("chan")) return elementType(ranged);
+        return slot === "key" ? "int" : elementType(ranged);
+      }
+      return undefined;
+    } finally {
+      this.visiting.delete(variable);
+    }
+  }
+
+  /**
+   * Whether an expression is an untyped constant, whose type depends on
+   * where it is used
+   */
+  isUntypedConstant(expr: Expr): boolean {
+    switch (expr.kind) {
+      case "BasicLit":
+        return true;
+      case "Ident": {
+        const variable = this.scopes?.resolved.get(expr);
+        const constant = variable
+          ? {
+              constant: !!variable.constant,
+              type: variable.typeExpr,
+              value: variable.init?.expr,
+            }
+          : this.packageVars.get(expr.name);
+        if (!constant) return ["true", "false", "iota"].includes(expr.name);
+        // `const n = int64(1)` is typed too
+        return (
+          constant.constant &&
+          !constant.type &&
+          (!constant.value || this.isUntypedConstant(constant.value))
+        );
+      }
+      case "ParenExpr":
+        return this.isUntypedConstant(expr.x);
+      case "UnaryExpr":
+        return (
+          expr.op !== "&" && expr.op !== "<-" && this.isUntypedConstant(expr.x)
+        );
+      case "BinaryExpr":
+        return this.isUntypedConstant(expr.x) && this.isUntypedConstant(expr.y);
+      default:
+        return false;
+    }
+  }
+
+  typeOf(expr: Expr): string | undefined {
+    switch (expr.kind) {
+      case "BasicLit":
+        return {
+          int: "int",
+          float: "float64",
+          imag: "complex128",
+          char: "rune",
+          string: "string",
+        }[expr.litKind];
+      case "Ident": {
+        const variable = this.scopes?.resolved.get(expr);
+        if (variable) return this.typeOfVariable(variable);
+        if (expr.name === "true" || expr.name === "false") return "bool";
+        const global = this.packageVars.get(expr.name);
+        if (global?.type) return this.text(global.type);
+        if (global?.value) return this.typeOf(global.value);
+        return undefined;
+      }
+      case "ParenExpr":
+        return this.typeOf(expr.x);
+      case "CompositeLit":
+        return expr.type ? this.text(expr.type) : undefined;
+      case "FuncLit":
+        return this.text(expr.type);
+      case "TypeAssertExpr":
+        return expr.type ? this.text(expr.type) : undefined;
+      case "StarExpr": {
+        const pointer = this.typeOf(expr.x);
+        return pointer?.startsWith("*") ? pointer.slice(1) : undefined;
+      }
+      case "UnaryExpr": {
+        if (expr.op === "!") return "bool";
+        const operand = this.typeOf(expr.x);
+        if (operand === undefined) return undefined;
+        if (expr.op === "&") return `*${operand}`;
+        if (expr.op === "<-") return elementType(this.resolve(operand));
+        return operand;
+      }
+      case "BinaryExpr": {
+        if (COMPARISON_OPS.has(expr.op)) return "bool";
+        const untypedX = this.isUntypedConstant(expr.x);
+        if (expr.op === "<<" || expr.op === ">>") {
+          // A constant shifted by a variable takes its type from the context
+          return untypedX ? undefined : this.typeOf(expr.x);
+        }
+        // An untyped constant takes the type of the other operand
+        const untypedY = this.isUntypedConstant(expr.y);
+        if (untypedX !== untypedY) {
+          return this.typeOf(untypedX ? expr.y : expr.x);
+        }
+        const x = this.typeOf(expr.x);
+        const y = this.typeOf(expr.y);
+        // Operands whose types differ give nothing to go on
+        if (x !== undefined && y !== undefined && x !== y) return undefined;
+        return x ?? y;
+      }
+      case "IndexExpr": {
+        const base = this.resolve(this.typeOf(expr.x));
+        if (!base) return undefined;
+        if (base === "string") return "byte";
+        return mapTypes(base)?.value ?? elementType(base);
+      }
+      case "SliceExpr": {
+        const base = this.typeOf(expr.x);
+        const resolved = this.resolve(base);
+        if (resolved && /^\[[^\]]/.test(resolved)) {
+          return `[]${elementType(resolved)}`;
+        }
+        return base;
+      }
+      case "SelectorExpr": {
+        const owner = this.typeOf(expr.x)?.replace(/^\*/, "");
+        const struct = owner && this.typeDecls.get(owner);
+        if (struct?.kind !== "StructType") return undefined;
+        const field = struct.fields.list.find((candidate) =>
+          candidate.names.some((name) => name.name === expr.sel.name),
+        );
+        return field ? this.text(field.type) : undefined;
+      }
+      case "CallExpr":
+        return this.typeOfCall(expr);
+      default:
+        return undefined;
+    }
+  }
+
+  private typeOfCall(expr: CallExpr): string | undefined {
+    const fun = expr.fun.kind === "ParenExpr" ? expr.fun.x : expr.fun;
+    const [first] = expr.args;
+    if (fun.kind === "Ident" && !this.scopes?.resolved.has(fun)) {
+      switch (fun.name) {
+        case "len":
+        case "cap":
+        case "copy":
+          return "int";
+        case "make":
+          return first ? this.text(first) : undefined;
+        case "new":
+          return first ? `*${this.text(first)}` : undefined;
+        case "append":
+        case "min":
+        case "max":
+          return first ? this.typeOf(first) : undefined;
+        case "real":
+        case "imag": {
+          // Untyped for a constant argument
+          if (!first || this.isUntypedConstant(first)) return undefined;
+          const type = this.resolve(this.typeOf(first));
+          if (type === "complex64") return "float32";
+          return type === "complex128" ? "float64" : undefined;
+        }
+        case "complex": {
+          const typed = expr.args.find((arg) => !this.isUntypedConstant(arg));
+          const type = typed && this.resolve(this.typeOf(typed));
+          if (type === "float32") return "complex64";
+          return type === "float64" ? "complex128" : undefined;
+        }
+      }
+      // A conversion to a basic or declared type
+      if (BASIC_TYPES.has(fun.name) || this.typeDecls.has(fun.name)) {
+        return fun.name;
+      }
+    }
+    if (
+      fun.kind === "ArrayType" ||
+      fun.kind === "MapType" ||
+      fun.kind === "ChanType" ||
+      fun.kind === "FuncType" ||
+      fun.kind === "StarExpr" ||
+      fun.kind === "InterfaceType"
+    ) {
+      return this.text(fun);
+    }
+    const results = this.callResultsOf(expr);
+    return results?.length === 1 ? results[0] : undefined;
+  }
+
+  private callResultsOf(expr: CallExpr): string[] | undefined {
+    const fun = expr.fun;
+    if (fun.kind === "Ident" && !this.scopes?.resolved.has(fun)) {
+      const fn = this.functions.get(fun.name);
+      if (fn) return this.resultTypes(fn);
+    }
+    if (fun.kind === "SelectorExpr") {
+      if (fun.x.kind === "Ident" && !this.scopes?.resolved.has(fun.x)) {
+        const importPath = this.imports.get(fun.x.name);
+        if (importPath !== undefined) {
+          return STDLIB_RESULTS[`${importPath}.${fun.sel.name}`];
+        }
+      }
+      const receiver = this.typeOf(fun.x)?.replace(/^\*/, "");
+      const method =
+        receiver && this.methods.get(`${receiver}.${fun.sel.name}`);
+      return method ? this.resultTypes(method) : undefined;
+    }
+    const value = this.resolve(this.typeOf(fun));
+    const results = value?.match(/^func\(.*?\)\s*(.*)$/);
+    if (results && results[1]) {
+      return [results[1].replace(/^\((.*)\)$/, "$1")];
+    }
+    return undefined;
+  }
+}
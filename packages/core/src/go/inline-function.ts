@@ -0,0 +1,911 @@
+import { TextEdit } from "../diff.js";
+import {
+  CallExpr,
+  Expr,
+  FuncDecl,
+  GoFile,
+  Ident,
+  Node,
+  inspect,
+} from "./ast.js";
+import {
+  buildGoCallGraph,
+  callGraphId,
+  findRecursionCycles,
+} from "./callgraph.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Inline Function
+ * ===============
+ * The inverse of extract function: replaces calls to a small function with
+ * its body. Two shapes can be inlined. A function whose body is a single
+ * `return <expr>` is substituted as an expression wherever it is called; a
+ * function without results or `return`s is substituted as statements where it
+ * is called as a statement. Arguments without side effects are substituted
+ * for their parameters; others are bound to new variables when the body runs
+ * as statements, and must be used exactly once, in argument order, when it is
+ * an expression. Names the body declares are renamed when they would clash
+ * with names at the call site. Only calls within the given file are rewritten.
+ */
+
+export interface InlineFunctionOptions {
+  /** Function to inline: its name, or `Type.Method` for a method */
+  name: string;
+  /** Delete the declaration once nothing references it (default: false) */
+  deleteDefinition?: boolean;
+}
+
+/**
+ * A call left in place, with the reason it could not be inlined
+ */
+export interface SkippedCallSite {
+  line: number;
+  reason: string;
+}
+
+export interface InlineFunctionResult extends GoRefactorResult {
+  name: string;
+  /** Lines of the calls that were replaced */
+  inlined: number[];
+  skipped: SkippedCallSite[];
+  /** References to the function left in the file after inlining */
+  remainingReferences: number;
+  /**
+   * True when the function is unexported and nothing in the file references
+   * it any more, so its declaration can be deleted
+   */
+  removable: boolean;
+  removed: boolean;
+}
+
+/**
+ * A parameter, or the receiver, that call arguments are bound to
+ */
+interface Slot {
+  variable?: GoVariable;
+  type: string;
+  uses: Ident[];
+  written: boolean;
+  addressed: boolean;
+}
+
+interface CallSite {
+  call: CallExpr;
+  caller: FuncDecl;
+  /** Argument for each slot, the receiver expression first for methods */
+  args: Expr[];
+}
+
+/**
+ * How safely an argument can be moved: `simple` arguments can be copied to
+ * every use, `pure` ones have no side effects but build new values, and
+ * `effect` ones call functions or receive from channels
+ */
+type ArgumentKind = "simple" | "pure" | "effect";
+
+const PURE_BUILTINS = new Set(["len", "cap", "min", "max", "real", "imag"]);
+
+const BASIC_TYPES = new Set([
+  "bool",
+  "byte",
+  "complex64",
+  "complex128",
+  "float32",
+  "float64",
+  "int",
+  "int8",
+  "int16",
+  "int32",
+  "int64",
+  "rune",
+  "string",
+  "uint",
+  "uint8",
+  "uint16",
+  "uint32",
+  "uint64",
+  "uintptr",
+]);
+
+const CONVERSION_TYPES = new Set([
+  "ArrayType",
+  "ChanType",
+  "FuncType",
+  "InterfaceType",
+  "MapType",
+]);
+
+// Calls that neither have side effects nor allocate: builtins and
+// conversions to types written inline
+function isPureCall(call: CallExpr): boolean {
+  const { fun } = call;
+  if (fun.kind === "Ident") {
+    return PURE_BUILTINS.has(fun.name) || BASIC_TYPES.has(fun.name);
+  }
+  const inner = fun.kind === "ParenExpr" ? fun.x : fun;
+  return CONVERSION_TYPES.has(inner.kind);
+}
+
+function argumentKind(expr: Expr): ArgumentKind {
+  let kind: ArgumentKind = "simple";
+  inspect(expr, (node) => {
+    switch (node.kind) {
+      case "CallExpr":
+        if (!isPureCall(node)) kind = "effect";
+        break;
+      case "UnaryExpr":
+        if (node.op === "<-") kind = "effect";
+        else if (node.op === "&" && kind === "simple") kind = "pure";
+        break;
+      case "CompositeLit":
+      case "FuncLit":
+        if (kind === "simple") kind = "pure";
+        break;
+    }
+    return kind !== "effect";
+  });
+  return kind;
+}
+
+function isUntyped(expr: Expr): boolean {
+  return (
+    expr.kind === "BasicLit" ||
+    (expr.kind === "Ident" &&
+      (expr.name === "nil" || expr.name === "true" || expr.name === "false"))
+  );
+}
+
+const PRECEDENCE: Record<string, number> = {
+  "||": 1,
+  "&&": 2,
+  "==": 3,
+  "!=": 3,
+  "<": 3,
+  "<=": 3,
+  ">": 3,
+  ">=": 3,
+  "+": 4,
+  "-": 4,
+  "|": 4,
+  "^": 4,
+  "*": 5,
+  "/": 5,
+  "%": 5,
+  "<<": 5,
+  ">>": 5,
+  "&": 5,
+  "&^": 5,
+};
+
+/**
+ * Whether an expression needs parentheses to keep its meaning when it
+ * replaces `child` as an operand of `parent`
+ */
+function needsParens(expr: Expr, parent: Node | undefined, child: Node) {
+  if (!parent) return false;
+  const operand =
+    ((parent.kind === "SelectorExpr" ||
+      parent.kind === "IndexExpr" ||
+      parent.kind === "IndexListExpr" ||
+      parent.kind === "SliceExpr" ||
+      parent.kind === "TypeAssertExpr") &&
+      parent.x === child) ||
+    (parent.kind === "CallExpr" && parent.fun === child);
+  switch (expr.kind) {
+    case "UnaryExpr":
+    case "StarExpr":
+      return operand;
+    case "BinaryExpr":
+      if (parent.kind === "BinaryExpr") {
+        const inner = PRECEDENCE[expr.op];
+        const outer = PRECEDENCE[parent.op];
+        return inner < outer || (inner === outer && parent.y === child);
+      }
+      return (
+        operand || parent.kind === "UnaryExpr" || parent.kind === "StarExpr"
+      );
+    default:
+      return false;
+  }
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+class FunctionInliner {
+  private readonly file: GoFile;
+  private readonly options: InlineFunctionOptions;
+  private readonly parents = new Map<Node, Node>();
+  private readonly callerScopes = new Map<FuncDecl, GoFunctionScopes>();
+  /** Names declared in each caller by bodies inlined so far */
+  private readonly introduced = new Map<FuncDecl, Set<string>>();
+  private decl: FuncDecl;
+  private scopes: GoFunctionScopes;
+  private slots: Slot[] = [];
+  /** The returned expression, for single-expression functions */
+  private expr?: Expr;
+  /** Names the body uses without declaring them */
+  private freeNames = new Set<string>();
+
+  constructor(file: GoFile, options: InlineFunctionOptions) {
+    this.file = file;
+    this.options = options;
+    inspect(file, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+    });
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private get name(): string {
+    return this.options.name;
+  }
+
+  private locate(): void {
+    this.decl = this.file.decls.find(
+      (decl): decl is FuncDecl =>
+        decl.kind === "FuncDecl" && qualifiedName(decl) === this.name,
+    );
+    if (!this.decl) {
+      throw new GoRefactorError(`${this.name} is not declared in the file`);
+    }
+    const { decl } = this;
+    if (!decl.body) {
+      throw new GoRefactorError(`${this.name} has no body to inline`);
+    }
+    const recv = decl.recv?.list[0]?.type;
+    const base = recv?.kind === "StarExpr" ? recv.x : recv;
+    if (
+      decl.type.typeParams ||
+      base?.kind === "IndexExpr" ||
+      base?.kind === "IndexListExpr"
+    ) {
+      throw new GoRefactorError(
+        `Inlining generic function ${this.name} is not supported`,
+      );
+    }
+    const params = decl.type.params.list;
+    if (params.some((field) => field.type.kind === "Ellipsis")) {
+      throw new GoRefactorError(
+        `Inlining variadic function ${this.name} is not supported`,
+      );
+    }
+  }
+
+  /**
+   * Decide which shape the body has and reject bodies that cannot be
+   * expressed at a call site
+   */
+  private validate(): void {
+    const { body, type } = this.decl;
+    const results = (type.results?.list ?? []).reduce(
+      (count, field) => count + Math.max(field.names.length, 1),
+      0,
+    );
+    if (results > 1) {
+      throw new GoRefactorError(
+        `${this.name} returns several values, which cannot be inlined as an expression`,
+      );
+    }
+    if (results === 1) {
+      const [stmt] = body.list;
+      if (
+        body.list.length !== 1 ||
+        stmt.kind !== "ReturnStmt" ||
+        stmt.results.length !== 1
+      ) {
+        throw new GoRefactorError(
+          `${this.name} does more than return a single expression; only one-line functions that return a value can be inlined`,
+        );
+      }
+      this.expr = stmt.results[0];
+    }
+
+    const visit = (node: Node) => {
+      switch (node.kind) {
+        case "FuncLit":
+          // Returns and defers in a literal belong to the literal
+          return false;
+        case "ReturnStmt":
+          if (!this.expr) {
+            throw new GoRefactorError(
+              `${this.name} returns early at line ${this.line(node.pos)}, which cannot be expressed inline`,
+            );
+          }
+          break;
+        case "DeferStmt":
+          throw new GoRefactorError(
+            `${this.name} defers a call at line ${this.line(node.pos)}; it would run when the caller returns`,
+          );
+        case "LabeledStmt":
+          throw new GoRefactorError(
+            `${this.name} declares label ${node.label.name}, which could clash at the call site`,
+          );
+      }
+    };
+    inspect(body, visit);
+  }
+
+  private analyzeBody(): void {
+    const { decl } = this;
+    this.scopes = resolveFunctionScopes(decl);
+    const declared = new Map<Ident, GoVariable>();
+    for (const variable of this.scopes.variables) {
+      declared.set(variable.ident, variable);
+    }
+
+    const slot = (name: Ident | undefined, type: Expr): Slot => {
+      const variable = name && declared.get(name);
+      const uses = this.scopes.references.filter(
+        (reference) => variable && reference.variable === variable,
+      );
+      return {
+        variable,
+        type: this.text(type),
+        uses: uses.map((reference) => reference.ident),
+        written: uses.some((reference) => reference.write),
+        addressed: uses.some((reference) => {
+          const parent = this.parents.get(reference.ident);
+          return parent?.kind === "UnaryExpr" && parent.op === "&";
+        }),
+      };
+    };
+    const recv = decl.recv?.list[0];
+    if (recv) {
+      this.slots.push(slot(recv.names[0], recv.type));
+    }
+    for (const field of decl.type.params.list) {
+      if (field.names.length === 0) {
+        this.slots.push(slot(undefined, field.type));
+      }
+      field.names.forEach((name) => this.slots.push(slot(name, field.type)));
+    }
+
+    const receiver = recv ? this.slots[0] : undefined;
+    if (
+      recv?.type.kind === "StarExpr" &&
+      receiver.uses.some((ident) => {
+        const parent = this.parents.get(ident);
+        return parent?.kind !== "SelectorExpr" || parent.x !== ident;
+      })
+    ) {
+      throw new GoRefactorError(
+        `${this.name} uses its pointer receiver as a value, which may not be addressable at the call site`,
+      );
+    }
+    if (this.expr) {
+      const bound = this.slots.find(
+        (entry) => entry.written || entry.addressed,
+      );
+      if (bound) {
+        throw new GoRefactorError(
+          `${this.name} assigns to or takes the address of ${bound.variable.name}, which requires a variable an expression cannot declare`,
+        );
+      }
+      const result = this.scopes.references.find(
+        (reference) => reference.variable.kind === "result",
+      );
+      if (result) {
+        throw new GoRefactorError(
+          `${this.name} reads its named result ${result.variable.name}`,
+        );
+      }
+    }
+
+    inspect(decl.body, (node) => {
+      if (node.kind === "SelectorExpr") {
+        inspect(node.x, (inner) => {
+          if (inner.kind === "Ident") this.freeName(inner);
+        });
+        return false;
+      }
+      if (node.kind === "KeyValueExpr" && node.key.kind === "Ident") {
+        // Likely a struct field name; map keys are checked at the call site
+        inspect(node.value, (inner) => {
+          if (inner.kind === "Ident") this.freeName(inner);
+        });
+        return false;
+      }
+      if (node.kind === "BranchStmt") return false;
+      if (node.kind === "Ident") this.freeName(node);
+    });
+    if (this.freeNames.has(decl.name.name) && !decl.recv) {
+      throw new GoRefactorError(`${this.name} is recursive`);
+    }
+    if (decl.recv) {
+      inspect(decl.body, (node) => {
+        if (node.kind === "SelectorExpr" && node.sel.name === decl.name.name) {
+          throw new GoRefactorError(`${this.name} may be recursive`);
+        }
+      });
+    }
+    // Inlining one function of a cycle only moves the call to the next
+    const graph = buildGoCallGraph([this.file]);
+    const id = callGraphId(this.file.packageName.name, {
+      qualifiedName: this.name,
+    });
+    const cycle = findRecursionCycles(graph).find(
+      (candidate) => !candidate.direct && candidate.ids.includes(id),
+    );
+    if (cycle) {
+      const others = cycle.ids
+        .filter((other) => other !== id)
+        .map((other) => graph.nodes.get(other).symbol.qualifiedName);
+      throw new GoRefactorError(
+        `${this.name} is mutually recursive with ${others.join(", ")}`,
+      );
+    }
+  }
+
+  private freeName(ident: Ident): void {
+    if (ident.name !== "_" && !this.scopes.resolved.has(ident)) {
+      this.freeNames.add(ident.name);
+    }
+  }
+
+  private scopesOf(caller: FuncDecl): GoFunctionScopes {
+    let scopes = this.callerScopes.get(caller);
+    if (!scopes) {
+      scopes = resolveFunctionScopes(caller);
+      this.callerScopes.set(caller, scopes);
+    }
+    return scopes;
+  }
+
+  /**
+   * Every expression in the file that may refer to the function: identifiers
+   * for functions, selectors for methods. Method selectors whose receiver type
+   * cannot be inferred are counted, since they may call the method.
+   */
+  private references(): { ref: Expr; caller?: FuncDecl }[] {
+    const refs: { ref: Expr; caller?: FuncDecl }[] = [];
+    const { decl } = this;
+    const name = decl.name.name;
+    const recvType = decl.recv && baseTypeName(decl.recv.list[0].type).name;
+
+    for (const top of this.file.decls) {
+      if (top === decl) continue;
+      const caller = top.kind === "FuncDecl" ? top : undefined;
+      const scopes = caller && this.scopesOf(caller);
+      const types = caller && new GoTypeInference(this.file, scopes);
+      const fieldNames = new Set<Ident>();
+      inspect(top, (node) => {
+        if (node.kind === "Field") {
+          node.names.forEach((ident) => fieldNames.add(ident));
+        }
+        if (fieldNames.has(node as Ident)) return;
+        if (recvType) {
+          if (node.kind !== "SelectorExpr" || node.sel.name !== name) return;
+          const type = types?.typeOf(node.x);
+          const base = type?.replace(/^\*/, "").replace(/\[.*$/, "");
+          if (base === undefined || base === recvType) {
+            refs.push({ ref: node, caller });
+          }
+          return;
+        }
+        if (node.kind === "SelectorExpr") {
+          // `x.name` never refers to a package-level function of this file
+          inspect(node.x, (inner) => {
+            if (
+              inner.kind === "Ident" &&
+              inner.name === name &&
+              !scopes?.resolved.has(inner)
+            ) {
+              refs.push({ ref: inner, caller });
+            }
+          });
+          return false;
+        }
+        if (
+          node.kind === "Ident" &&
+          node.name === name &&
+          !scopes?.resolved.has(node) &&
+          node !== caller?.name
+        ) {
+          refs.push({ ref: node, caller });
+        }
+      });
+    }
+    return refs;
+  }
+
+  private variablesInScope(site: CallSite, name: string): boolean {
+    const { call, caller } = site;
+    return this.scopesOf(caller).variables.some(
+      (variable) =>
+        variable.name === name &&
+        variable.ident.pos < call.pos &&
+        variable.scope.node.pos <= call.pos &&
+        call.end <= variable.scope.node.end,
+    );
+  }
+
+  private callerNames(caller: FuncDecl): Set<string> {
+    const names = new Set([
+      ...this.freeNames,
+      ...(this.introduced.get(caller) ?? []),
+    ]);
+    inspect(caller, (node) => {
+      if (node.kind === "Ident") names.add(node.name);
+    });
+    return names;
+  }
+
+  /**
+   * Rename the body's locals that clash with names at the call site
+   */
+  private renames(taken: Set<string>): Map<GoVariable, string> {
+    const renames = new Map<GoVariable, string>();
+    const clashes = new Set(taken);
+    this.scopes.variables.forEach((variable) => taken.add(variable.name));
+    for (const variable of this.scopes.variables) {
+      if (variable.kind !== "local" || !clashes.has(variable.name)) continue;
+      renames.set(variable, this.fresh(variable.name, taken));
+    }
+    return renames;
+  }
+
+  private fresh(name: string, taken: Set<string>): string {
+    let suffix = 2;
+    while (taken.has(`${name}${suffix}`)) suffix++;
+    const fresh = `${name}${suffix}`;
+    taken.add(fresh);
+    return fresh;
+  }
+
+  /**
+   * Source text of a range of the body with identifiers replaced
+   */
+  private substitute(
+    start: number,
+    end: number,
+    replacements: Map<Ident, string>,
+  ): string {
+    const edits = [...replacements]
+      .filter(([ident]) => ident.pos >= start && ident.end <= end)
+      .sort(([a], [b]) => b.pos - a.pos);
+    let text = this.file.source.slice(start, end);
+    for (const [ident, newText] of edits) {
+      text =
+        text.slice(0, ident.pos - start) +
+        newText +
+        text.slice(ident.end - start);
+    }
+    return text;
+  }
+
+  private argumentText(arg: Expr, use: Ident): string {
+    const text = this.text(arg);
+    return needsParens(arg, this.parents.get(use), use) ? `(${text})` : text;
+  }
+
+  private localReplacements(renames: Map<GoVariable, string>) {
+    const replacements = new Map<Ident, string>();
+    for (const [ident, variable] of this.scopes.resolved) {
+      const name = renames.get(variable);
+      if (name !== undefined) replacements.set(ident, name);
+    }
+    return replacements;
+  }
+
+  /**
+   * Check that evaluating the expression body runs each argument with side
+   * effects exactly once, unconditionally and in argument order, before any
+   * call of its own
+   */
+  private checkOrder(kinds: ArgumentKind[]): string | undefined {
+    const events: { pos: number; slot?: number }[] = [];
+    for (const [index, slot] of this.slots.entries()) {
+      if (kinds[index] === "simple") continue;
+      if (slot.uses.length === 0) {
+        if (kinds[index] === "effect") {
+          return `argument ${index + 1} has side effects but is never used`;
+        }
+        continue;
+      }
+      if (slot.uses.length > 1) {
+        return `argument ${index + 1} would be evaluated ${slot.uses.length} times`;
+      }
+      const [use] = slot.uses;
+      for (
+        let child: Node = use, parent = this.parents.get(use);
+        parent && child !== this.expr;
+        child = parent, parent = this.parents.get(parent)
+      ) {
+        if (
+          parent.kind === "FuncLit" ||
+          (parent.kind === "BinaryExpr" &&
+            (parent.op === "&&" || parent.op === "||") &&
+            parent.y === child)
+        ) {
+          return `argument ${index + 1} would only be evaluated conditionally`;
+        }
+      }
+      if (kinds[index] === "effect") {
+        events.push({ pos: use.pos, slot: index });
+      }
+    }
+    inspect(this.expr, (node) => {
+      if (node.kind === "FuncLit") return false;
+      if (
+        (node.kind === "CallExpr" && !isPureCall(node)) ||
+        (node.kind === "UnaryExpr" && node.op === "<-")
+      ) {
+        // A call runs after its operands are evaluated
+        events.push({ pos: node.end });
+      }
+    });
+    events.sort((a, b) => a.pos - b.pos);
+    let last = -1;
+    let called = false;
+    for (const event of events) {
+      if (event.slot === undefined) {
+        called = true;
+      } else if (called || event.slot < last) {
+        return `argument ${event.slot + 1} would be evaluated out of order`;
+      } else {
+        last = event.slot;
+      }
+    }
+    return undefined;
+  }
+
+  private inlineExpression(site: CallSite): TextEdit | string {
+    const { call } = site;
+    const parent = this.parents.get(call);
+    const expr = this.expr;
+    if (
+      parent?.kind === "ExprStmt" &&
+      expr.kind !== "CallExpr" &&
+      !(expr.kind === "UnaryExpr" && expr.op === "<-")
+    ) {
+      return "its result is discarded, so the body would not be a statement";
+    }
+    const kinds = site.args.map(argumentKind);
+    const problem = this.checkOrder(kinds);
+    if (problem) return problem;
+
+    const replacements = this.localReplacements(
+      this.renames(this.callerNames(site.caller)),
+    );
+    site.args.forEach((arg, index) =>
+      this.slots[index].uses.forEach((use) =>
+        replacements.set(use, this.argumentText(arg, use)),
+      ),
+    );
+    const text = this.substitute(expr.pos, expr.end, replacements);
+    return {
+      start: call.pos,
+      end: call.end,
+      newText: needsParens(expr, parent, call) ? `(${text})` : text,
+    };
+  }
+
+  private inlineStatements(site: CallSite): TextEdit | string {
+    const { call } = site;
+    const stmt = this.parents.get(call);
+    if (stmt?.kind !== "ExprStmt") {
+      return "it is not called as a statement";
+    }
+    const { source, sourceMap } = this.file;
+    const callerNames = this.callerNames(site.caller);
+    const taken = new Set(callerNames);
+    const renames = this.renames(taken);
+    const replacements = this.localReplacements(renames);
+    const declared = this.scopes.variables
+      .filter((variable) => variable.kind === "local")
+      .map((variable) => renames.get(variable) ?? variable.name);
+
+    const bindings: string[] = [];
+    site.args.forEach((arg, index) => {
+      const slot = this.slots[index];
+      const kind = argumentKind(arg);
+      if (slot.uses.length === 0) {
+        if (kind === "effect") bindings.push(`_ = ${this.text(arg)}`);
+        return;
+      }
+      if (kind === "simple" && !slot.written && !slot.addressed) {
+        slot.uses.forEach((use) =>
+          replacements.set(use, this.argumentText(arg, use)),
+        );
+        return;
+      }
+      const { name } = slot.variable;
+      const bound = callerNames.has(name) ? this.fresh(name, taken) : name;
+      declared.push(bound);
+      bindings.push(
+        isUntyped(arg)
+          ? `var ${bound} ${slot.type} = ${this.text(arg)}`
+          : `${bound} := ${this.text(arg)}`,
+      );
+      slot.uses.forEach((use) => replacements.set(use, bound));
+    });
+
+    const { list } = this.decl.body;
+    const lines = [...bindings];
+    if (list.length > 0) {
+      const lineStart = sourceMap.lineStart(this.line(list[0].pos));
+      const indent = source.slice(lineStart, list[0].pos);
+      const body = this.substitute(lineStart, list.at(-1).end, replacements);
+      for (const line of body.split("\n")) {
+        lines.push(line.startsWith(indent) ? line.slice(indent.length) : line);
+      }
+    }
+    if (lines.length === 0) {
+      // Nothing left to run: drop the whole line
+      return {
+        start: sourceMap.lineStart(this.line(stmt.pos)),
+        end: sourceMap.lineStart(this.line(stmt.end) + 1),
+        newText: "",
+      };
+    }
+    // Later calls in the same function must not declare these names again
+    this.introduced.set(
+      site.caller,
+      new Set([...(this.introduced.get(site.caller) ?? []), ...declared]),
+    );
+    const siteStart = sourceMap.lineStart(this.line(stmt.pos));
+    const siteIndent = source.slice(siteStart, stmt.pos);
+    return {
+      start: stmt.pos,
+      end: stmt.end,
+      newText: lines
+        .map((line, index) =>
+          line.trim() === "" ? "" : index === 0 ? line : siteIndent + line,
+        )
+        .join("\n"),
+    };
+  }
+
+  private deletion(): TextEdit {
+    const { source, sourceMap } = this.file;
+    const start = sourceMap.lineStart(
+      this.line(this.decl.doc?.pos ?? this.decl.pos),
+    );
+    let end = sourceMap.lineStart(this.line(this.decl.end) + 1);
+    if (source[end] === "\n") {
+      end++;
+    } else if (end >= source.length && source[start - 2] === "\n") {
+      return { start: start - 1, end, newText: "" };
+    }
+    return { start, end, newText: "" };
+  }
+
+  inline(): InlineFunctionResult {
+    this.locate();
+    this.validate();
+    this.analyzeBody();
+
+    const refs = this.references();
+    const sites: CallSite[] = [];
+    const skipped: SkippedCallSite[] = [];
+    const skip = (node: Node, reason: string) =>
+      skipped.push({ line: this.line(node.pos), reason });
+
+    for (const { ref, caller } of refs) {
+      const call = this.parents.get(ref);
+      if (call?.kind !== "CallExpr" || call.fun !== ref) continue;
+      const parent = this.parents.get(call);
+      if (!caller) {
+        skip(call, "it is outside a function");
+      } else if (parent?.kind === "DeferStmt") {
+        skip(call, "the call is deferred");
+      } else if (parent?.kind === "GoStmt") {
+        skip(call, "the call starts a goroutine");
+      } else if (call.ellipsis > 0) {
+        skip(call, "its arguments are spread from a slice");
+      } else if (
+        call.args.length !== this.slots.length - (this.decl.recv ? 1 : 0)
+      ) {
+        skip(call, "its arguments come from a multi-value call");
+      } else {
+        const receiver = ref.kind === "SelectorExpr" ? [ref.x] : [];
+        sites.push({ call, caller, args: [...receiver, ...call.args] });
+      }
+    }
+
+    const edits: TextEdit[] = [];
+    const inlined: number[] = [];
+    for (const site of sites) {
+      const { call } = site;
+      const outer = sites.find(
+        (other) =>
+          other !== site &&
+          other.call.pos <= call.pos &&
+          call.end <= other.call.end,
+      );
+      const shadowed = [...this.freeNames].find((name) =>
+        this.variablesInScope(site, name),
+      );
+      if (outer) {
+        skip(call, "it is nested in another call being inlined");
+        continue;
+      }
+      if (shadowed) {
+        skip(call, `${shadowed} refers to a local variable at the call site`);
+        continue;
+      }
+      const edit = this.expr
+        ? this.inlineExpression(site)
+        : this.inlineStatements(site);
+      if (typeof edit === "string") {
+        skip(call, edit);
+        continue;
+      }
+      edits.push(edit);
+      inlined.push(this.line(call.pos));
+    }
+
+    if (inlined.length === 0) {
+      throw new GoRefactorError(
+        skipped.length === 0
+          ? `${this.name} is not called in the file`
+          : `No call to ${this.name} can be inlined: line ${skipped[0].line}: ${skipped[0].reason}`,
+      );
+    }
+
+    const remainingReferences = refs.length - inlined.length;
+    const interfaceMethod =
+      this.decl.recv !== undefined &&
+      this.file.decls.some((decl) => {
+        let found = false;
+        inspect(decl, (node) => {
+          if (node.kind === "InterfaceType") {
+            found ||= node.methods.list.some((field) =>
+              field.names.some((ident) => ident.name === this.decl.name.name),
+            );
+          }
+          return !found;
+        });
+        return found;
+      });
+    const removable =
+      remainingReferences === 0 &&
+      !isExportedName(this.decl.name.name) &&
+      !interfaceMethod;
+    const removed = removable && this.options.deleteDefinition === true;
+    if (removed) edits.push(this.deletion());
+
+    return {
+      ...refactorResult(this.file, edits),
+      name: this.name,
+      inlined,
+      skipped,
+      remainingReferences,
+      removable,
+      removed,
+    };
+  }
+}
+
+/**
+ * Replace calls to a function or method with its body
+ */
+export function inlineFunction(
+  file: GoFile,
+  options: InlineFunctionOptions,
+): InlineFunctionResult {
+  return new FunctionInliner(file, options).inline();
+}
@@ -0,0 +1,180 @@
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GoCheckpoint } from "./checkpoint.js";
+import {
+  applyGoConfigSeverities,
+  GoConfigResolver,
+  GoResolvedConfig,
+} from "./config.js";
+import { GoFinding, GoSeverity } from "./findings.js";
+import { groupGoPackages } from "./package.js";
+import {
+  analyzeGoPlatforms,
+  GoPlatform,
+  GoPlatformFinding,
+} from "./platforms.js";
+import { GoProfiler } from "./profile.js";
+import { defaultGoRuleRegistry, GoRuleRegistry } from "./rules.js";
+import { GoSuppressedFinding } from "./suppress.js";
+
+/**
+ * Go Findings as JSON Lines
+ * =========================
+ * One JSON object per finding, each on its own line, for log processors and
+ * other tools reading findings as they arrive. Rules run one package at a
+ * time and the findings of a package are yielded as soon as it is checked,
+ * so output starts before the whole codebase has been analyzed. Every line
+ * is complete in itself: writing each with a single call means a run that
+ * dies halfway leaves nothing but whole lines behind.
+ */
+
+export interface GoFindingJson {
+  rule: string;
+  severity: GoSeverity;
+  file: string;
+  line: number;
+  column: number;
+  message: string;
+  /** Suggested replacement code, or null when the rule has none */
+  fix: string | null;
+  /** Targets the finding was reported for, when run for a platform matrix */
+  platforms?: string[];
+}
+
+export interface GoJsonLinesOptions {
+  /** Directory file paths are written relative to */
+  root?: string;
+}
+
+export interface GoFindingStreamOptions {
+  /** Rules to run (default: the built-in rules) */
+  registry?: GoRuleRegistry;
+  /**
+   * Check each package once per target, with the files compiled for it,
+   * merging findings identical across targets (default: every file at once)
+   */
+  platforms?: GoPlatform[];
+  /** Records the time of each rule */
+  profiler?: GoProfiler;
+  /**
+   * Config files selecting and tuning the built-in rules per directory,
+   * replacing `registry`
+   */
+  config?: GoConfigResolver;
+  /**
+   * Resume packages unchanged since a run saved them, and save the others
+   * as they are checked
+   */
+  checkpoint?: GoCheckpoint;
+}
+
+/**
+ * The JSON object written for a finding
+ */
+export function goFindingJson(
+  finding: GoFinding | GoPlatformFinding,
+  options: GoJsonLinesOptions = {},
+): GoFindingJson {
+  return {
+    rule: finding.rule,
+    severity: finding.severity,
+    file: (options.root
+      ? path.relative(options.root, finding.filePath)
+      : finding.filePath
+    )
+      .split(path.sep)
+      .join("/"),
+    line: finding.line,
+    column: finding.column,
+    message: finding.message,
+    fix: finding.fix ?? null,
+    ...("platforms" in finding && { platforms: finding.platforms }),
+  };
+}
+
+/**
+ * A finding as one line of JSON, newline included
+ */
+export function formatGoFindingJsonLine(
+  finding: GoFinding | GoPlatformFinding,
+  options: GoJsonLinesOptions = {},
+): string {
+  return JSON.stringify(goFindingJson(finding, options)) + "\n";
+}
+
+/**
+ * The findings of one package
+ */
+export interface GoPackageFindings {
+  files: GoFile[];
+  /** Sorted, as the registry returns them */
+  findings: GoFinding[];
+  /** Findings `//refactogent:ignore` directives silence */
+  suppressed: GoSuppressedFinding[];
+  /** Config the package was checked with, when run with config files */
+  config?: GoResolvedConfig;
+  /** Set when the findings were resumed from a checkpoint */
+  resumed?: boolean;
+}
+
+// What besides its files decides a package's findings
+function runFingerprint(
+  rules: GoRuleRegistry,
+  config: GoResolvedConfig | undefined,
+  platforms: GoPlatform[] | undefined,
+): string {
+  return JSON.stringify({
+    rules: rules
+      .list()
+      .filter((rule) => rules.isEnabled(rule.id))
+      .map((rule) => [rule.id, rule.severity]),
+    config: config?.config ?? null,
+    platforms: platforms ?? null,
+  });
+}
+
+/**
+ * Run the rules package by package, yielding each package's findings as
+ * soon as they are found. Between packages the event loop gets a turn, so
+ * what has been written so far reaches its destination.
+ */
+export async function* streamGoFindings(
+  files: GoFile[],
+  options: GoFindingStreamOptions = {},
+): AsyncGenerator<GoPackageFindings, void, undefined> {
+  const { profiler, checkpoint } = options;
+  const registry = options.registry ?? defaultGoRuleRegistry();
+  for (const group of groupGoPackages(files)) {
+    // A package is one directory, so its files share their config
+    const config = options.config?.resolve(group[0].filePath);
+    const rules = config ? options.config.registry(config) : registry;
+    const key = checkpoint?.key(
+      group,
+      runFingerprint(rules, config, options.platforms),
+    );
+    const saved = key && (await checkpoint.get(key));
+    if (saved) {
+      profiler?.count("checkpoint hits");
+      yield {
+        files: group,
+        ...saved,
+        ...(config && { config }),
+        resumed: true,
+      };
+      continue;
+    }
+    const result = options.platforms
+      ? analyzeGoPlatforms(group, options.platforms, {
+          registry: rules,
+          profiler,
+        })
+      : rules.runWithSuppressions(group, { profiler });
+    const findings = config
+      ? applyGoConfigSeverities(result.findings, config.config)
+      : result.findings;
+    const { suppressed } = result;
+    if (key) await checkpoint.put(key, { findings, suppressed });
+    yield { files: group, findings, suppressed, ...(config && { config }) };
+    await new Promise<void>((resolve) => setImmediate(resolve));
+  }
+}
@@ -0,0 +1,245 @@
+import { applyEdits, TextEdit } from "../diff.js";
+import {
+  CompositeLit,
+  Expr,
+  GoFile,
+  Node,
+  StructType,
+  TypeSpec,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Keyed Struct Literals
+ * =====================
+ * A positional struct literal, `Point{1, 2}`, assigns its values to the
+ * struct's fields in declaration order, so reordering or inserting fields of
+ * the same types silently assigns them to the wrong ones. This pass flags
+ * positional literals of structs declared in the package and rewrites them
+ * to keyed literals, `Point{X: 1, Y: 2}`, naming each value's field from the
+ * struct's declaration. Literals whose type is elided inside a slice, array
+ * or map literal, as in `[]Point{{1, 2}}`, are resolved through the
+ * element type.
+ *
+ * Only literals whose struct declaration is certain are touched: types of
+ * other packages, whose fields cannot be read here, types declared inside a
+ * function, which may shadow the package's, and structs with blank fields,
+ * which a keyed literal cannot set, are left alone, as are literals whose
+ * value count does not match the fields, which do not compile.
+ */
+
+export interface GoUnkeyedLiteralFinding extends GoFinding {
+  rule: "unkeyed-literal";
+  /** The struct's name */
+  type: string;
+  /** The fields the values are assigned to, in order */
+  fields: string[];
+}
+
+export interface KeyStructLiteralsOptions {
+  /**
+   * Line of a literal reported by {@link findUnkeyedLiterals} (default:
+   * every one in the file)
+   */
+  line?: number;
+}
+
+export interface KeyStructLiteralsResult extends GoRefactorResult {
+  /** Positions of the rewritten literals */
+  rewritten: { line: number; column: number }[];
+}
+
+interface Literal {
+  lit: CompositeLit;
+  type: string;
+  fields: string[];
+}
+
+// Package-level type declarations, aliases excluded
+function packageTypes(files: GoFile[]): Map<string, TypeSpec> {
+  const types = new Map<string, TypeSpec>();
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+      for (const spec of decl.specs) {
+        if (spec.kind === "TypeSpec" && !spec.isAlias) {
+          types.set(spec.name.name, spec);
+        }
+      }
+    }
+  }
+  return types;
+}
+
+// Field names in declaration order; undefined when a field is blank
+function fieldNames(struct: StructType): string[] | undefined {
+  const names = struct.fields.list.flatMap((field) =>
+    field.names.length > 0
+      ? field.names.map((ident) => ident.name)
+      : [baseTypeName(field.type).name],
+  );
+  return names.includes("_") || names.includes("") ? undefined : names;
+}
+
+class LiteralCollector {
+  // Type names declared inside the function being walked
+  private local = new Set<string>();
+
+  constructor(
+    private readonly file: GoFile,
+    private readonly types: Map<string, TypeSpec>,
+  ) {}
+
+  collect(): Literal[] {
+    const literals: Literal[] = [];
+    for (const decl of this.file.decls) {
+      this.local = new Set();
+      if (decl.kind === "FuncDecl" && decl.body) {
+        inspect(decl.body, (node) => {
+          if (node.kind !== "DeclStmt" || node.decl.tok !== "type") return;
+          for (const spec of node.decl.specs) {
+            if (spec.kind === "TypeSpec") this.local.add(spec.name.name);
+          }
+        });
+      }
+      inspect(decl, (node, parents) => {
+        if (node.kind !== "CompositeLit" || node.elts.length === 0) return;
+        if (node.elts.some((elt) => elt.kind === "KeyValueExpr")) return;
+        const spec = this.named(this.literalType(node, parents));
+        if (spec?.type.kind !== "StructType") return;
+        const fields = fieldNames(spec.type);
+        if (!fields || fields.length !== node.elts.length) return;
+        literals.push({ lit: node, type: spec.name.name, fields });
+      });
+    }
+    return literals;
+  }
+
+  // The package type a type expression names, when it certainly names one
+  private named(type: Expr | undefined): TypeSpec | undefined {
+    let current = type;
+    while (
+      current?.kind === "ParenExpr" ||
+      current?.kind === "IndexExpr" ||
+      current?.kind === "IndexListExpr"
+    ) {
+      current = current.x;
+    }
+    if (current?.kind !== "Ident" || this.local.has(current.name)) {
+      return undefined;
+    }
+    return this.types.get(current.name);
+  }
+
+  // The type of a literal, looking through the literal enclosing an elided one
+  private literalType(lit: CompositeLit, parents: Node[]): Expr | undefined {
+    if (lit.type) return lit.type;
+    let index = parents.length - 1;
+    let key = false;
+    const parent = parents[index];
+    if (parent?.kind === "KeyValueExpr") {
+      key = parent.key === lit;
+      index--;
+    }
+    const outer = parents[index];
+    if (outer?.kind !== "CompositeLit") return undefined;
+    const element = this.elementType(
+      this.literalType(outer, parents.slice(0, index)),
+      key,
+    );
+    // `[]*Point{{1, 2}}` elides `&Point`
+    return element?.kind === "StarExpr" ? element.x : element;
+  }
+
+  // The element or key type of a slice, array or map type
+  private elementType(type: Expr | undefined, key: boolean, depth = 0) {
+    if (type?.kind === "ParenExpr") return this.elementType(type.x, key);
+    if (type?.kind === "ArrayType") return key ? undefined : type.elt;
+    if (type?.kind === "MapType") return key ? type.key : type.value;
+    // Named slice and map types, as in `type Points []Point`
+    const spec = depth < 8 ? this.named(type) : undefined;
+    return spec && this.elementType(spec.type, key, depth + 1);
+  }
+}
+
+function fileLiterals(file: GoFile, files: GoFile[]): Literal[] {
+  const group = groupGoPackages([
+    file,
+    ...files.filter((other) => other !== file),
+  ]);
+  return new LiteralCollector(file, packageTypes(group[0])).collect();
+}
+
+function keyEdits(literal: Literal): TextEdit[] {
+  return literal.lit.elts.map((elt, index) => ({
+    start: elt.pos,
+    end: elt.pos,
+    newText: `${literal.fields[index]}: `,
+  }));
+}
+
+/**
+ * Find positional literals of structs declared in the package
+ */
+export function findUnkeyedLiterals(
+  files: GoFile[],
+): GoUnkeyedLiteralFinding[] {
+  const findings: GoUnkeyedLiteralFinding[] = [];
+  for (const file of files) {
+    for (const literal of fileLiterals(file, files)) {
+      const { lit, type, fields } = literal;
+      const keyed = applyEdits(
+        file.source.slice(lit.pos, lit.end),
+        keyEdits(literal).map((edit) => ({
+          ...edit,
+          start: edit.start - lit.pos,
+          end: edit.end - lit.pos,
+        })),
+      );
+      findings.push({
+        rule: "unkeyed-literal",
+        severity: "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(lit.pos),
+        message: `Literal of ${type} sets its fields by position, which silently assigns them to the wrong fields once the struct's fields are reordered; name the field of each value`,
+        fix: keyed,
+        type,
+        fields,
+      });
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Rewrite positional struct literals to keyed ones. Structs declared in
+ * other files of the package are found through `files`.
+ */
+export function keyStructLiterals(
+  file: GoFile,
+  options: KeyStructLiteralsOptions = {},
+  files: GoFile[] = [file],
+): KeyStructLiteralsResult {
+  const { sourceMap } = file;
+  const literals = fileLiterals(file, files).filter(
+    ({ lit }) =>
+      options.line === undefined || sourceMap.line(lit.pos) === options.line,
+  );
+  if (options.line !== undefined && literals.length === 0) {
+    throw new GoRefactorError(
+      `Line ${options.line} has no positional literal of a struct declared in the package`,
+    );
+  }
+  return {
+    ...refactorResult(file, literals.flatMap(keyEdits)),
+    rewritten: literals.map(({ lit }) => sourceMap.position(lit.pos)),
+  };
+}
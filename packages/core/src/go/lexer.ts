@@ -0,0 +1,444 @@
+/**
+ * Go Lexer
+ * ========
+ * Tokenizes Go source following the lexical rules of the Go specification,
+ * including automatic semicolon insertion. Offsets are UTF-16 indices into the
+ * source string so tokens can be sliced directly; line/column conversion is
+ * handled by {@link GoSourceMap}.
+ */
+
+/**
+ * Kinds of tokens produced by the lexer
+ */
+export type GoTokenKind =
+  | "ident"
+  | "keyword"
+  | "int"
+  | "float"
+  | "imag"
+  | "char"
+  | "string"
+  | "op"
+  | ";"
+  | "eof";
+
+/**
+ * A single lexical token
+ */
+export interface GoToken {
+  kind: GoTokenKind;
+  value: string;
+  pos: number;
+  end: number;
+  /** True when a ";" token was inserted automatically at a newline or EOF */
+  implicit?: boolean;
+}
+
+/**
+ * A comment with its source range
+ */
+export interface GoComment {
+  text: string;
+  pos: number;
+  end: number;
+  isBlock: boolean;
+}
+
+export const GO_KEYWORDS = new Set([
+  "break",
+  "case",
+  "chan",
+  "const",
+  "continue",
+  "default",
+  "defer",
+  "else",
+  "fallthrough",
+  "for",
+  "func",
+  "go",
+  "goto",
+  "if",
+  "import",
+  "interface",
+  "map",
+  "package",
+  "range",
+  "return",
+  "select",
+  "struct",
+  "switch",
+  "type",
+  "var",
+]);
+
+// Operators sorted longest first so the scanner can match greedily
+const GO_OPERATORS = [
+  "&^=",
+  "<<=",
+  ">>=",
+  "...",
+  "&&",
+  "||",
+  "<-",
+  "++",
+  "--",
+  "==",
+  "!=",
+  "<=",
+  ">=",
+  ":=",
+  "+=",
+  "-=",
+  "*=",
+  "/=",
+  "%=",
+  "&=",
+  "|=",
+  "^=",
+  "<<",
+  ">>",
+  "&^",
+  "~",
+  "+",
+  "-",
+  "*",
+  "/",
+  "%",
+  "&",
+  "|",
+  "^",
+  "<",
+  ">",
+  "=",
+  "!",
+  "(",
+  ")",
+  "[",
+  "]",
+  "{",
+  "}",
+  ",",
+  ";",
+  ".",
+  ":",
+];
+
+/**
+ * Error raised for malformed Go source
+ */
+export class GoSyntaxError extends Error {
+  /** The message without the position it is prefixed with */
+  readonly reason: string;
+  readonly pos: number;
+  readonly line: number;
+  readonly column: number;
+
+  constructor(message: string, pos: number, line: number, column: number) {
+    super(`${line}:${column}: ${message}`);
+    this.name = "GoSyntaxError";
+    this.reason = message;
+    this.pos = pos;
+    this.line = line;
+    this.column = column;
+  }
+}
+
+/**
+ * Maps UTF-16 offsets to Go-style 1-based line and byte columns
+ */
+export class GoSourceMap {
+  private readonly source: string;
+  private lineStarts: number[] = [0];
+
+  constructor(source: string) {
+    this.source = source;
+    for (let i = 0; i < source.length; i++) {
+      if (source.charCodeAt(i) === 10) {
+        this.lineStarts.push(i + 1);
+      }
+    }
+  }
+
+  get lineCount(): number {
+    return this.lineStarts.length;
+  }
+
+  /**
+   * Offset of the first character of a 1-based line
+   */
+  lineStart(line: number): number {
+    return this.lineStarts[line - 1] ?? this.source.length;
+  }
+
+  /**
+   * Convert an offset into a 1-based line number
+   */
+  line(offset: number): number {
+    let lo = 0;
+    let hi = this.lineStarts.length - 1;
+    while (lo < hi) {
+      const mid = (lo + hi + 1) >> 1;
+      if (this.lineStarts[mid] <= offset) {
+        lo = mid;
+      } else {
+        hi = mid - 1;
+      }
+    }
+    return lo + 1;
+  }
+
+  /**
+   * Convert an offset into a 1-based line and byte column, as go/token does
+   */
+  position(offset: number): { line: number; column: number } {
+    const line = this.line(offset);
+    const start = this.lineStarts[line - 1];
+    const prefix = this.source.slice(start, offset);
+    // eslint-disable-next-line no-control-regex
+    const column = /^[\x00-\x7f]*$/.test(prefix)
+      ? prefix.length + 1
+      : Buffer.byteLength(prefix, "utf-8") + 1;
+    return { line, column };
+  }
+
+  /**
+   * Convert a 1-based line and byte column back into an offset. Columns past
+   * the end of the line stop at the line break.
+   */
+  offset(line: number, column: number): number {
+    const start = this.lineStart(line);
+    const end =
+      line < this.lineStarts.length
+        ? this.lineStarts[line] - 1
+        : this.source.length;
+    let offset = start;
+    let bytes = 1;
+    while (offset < end && bytes < column) {
+      const code = this.source.codePointAt(offset);
+      bytes += code < 0x80 ? 1 : code < 0x800 ? 2 : code < 0x10000 ? 3 : 4;
+      offset += code < 0x10000 ? 1 : 2;
+    }
+    return offset;
+  }
+}
+
+function isLetter(ch: string): boolean {
+  return /[\p{L}_]/u.test(ch);
+}
+
+function isDigit(ch: string): boolean {
+  return /[\p{Nd}]/u.test(ch);
+}
+
+/**
+ * Tokenize Go source. Comments are returned separately from the token stream.
+ */
+export function tokenizeGo(source: string): {
+  tokens: GoToken[];
+  comments: GoComment[];
+} {
+  const map = new GoSourceMap(source);
+  const tokens: GoToken[] = [];
+  const comments: GoComment[] = [];
+  let i = 0;
+
+  const fail = (message: string, pos: number): never => {
+    const { line, column } = map.position(pos);
+    throw new GoSyntaxError(message, pos, line, column);
+  };
+
+  const needsSemicolon = (): boolean => {
+    const last = tokens[tokens.length - 1];
+    if (!last) {
+      return false;
+    }
+    switch (last.kind) {
+      case "ident":
+      case "int":
+      case "float":
+      case "imag":
+      case "char":
+      case "string":
+        return true;
+      case "keyword":
+        return ["break", "continue", "fallthrough", "return"].includes(
+          last.value,
+        );
+      case "op":
+        return ["++", "--", ")", "]", "}"].includes(last.value);
+      default:
+        return false;
+    }
+  };
+
+  const insertSemicolon = (pos: number) => {
+    if (needsSemicolon()) {
+      tokens.push({ kind: ";", value: "\n", pos, end: pos, implicit: true });
+    }
+  };
+
+  while (i < source.length) {
+    const ch = source[i];
+
+    if (ch === "\n") {
+      insertSemicolon(i);
+      i++;
+      continue;
+    }
+
+    if (ch === " " || ch === "\t" || ch === "\r" || ch === "\uFEFF") {
+      i++;
+      continue;
+    }
+
+    // Comments
+    if (ch === "/" && source[i + 1] === "/") {
+      const start = i;
+      while (i < source.length && source[i] !== "\n") {
+        i++;
+      }
+      comments.push({
+        text: source.slice(start, i),
+        pos: start,
+        end: i,
+        isBlock: false,
+      });
+      continue;
+    }
+    if (ch === "/" && source[i + 1] === "*") {
+      const start = i;
+      const close = source.indexOf("*/", i + 2);
+      if (close === -1) {
+        fail("comment not terminated", start);
+      }
+      i = close + 2;
+      const text = source.slice(start, i);
+      comments.push({ text, pos: start, end: i, isBlock: true });
+      // A block comment spanning lines acts like a newline
+      if (text.includes("\n")) {
+        insertSemicolon(start);
+      }
+      continue;
+    }
+
+    // Identifiers and keywords
+    if (isLetter(ch)) {
+      const start = i;
+      while (i < source.length && (isLetter(source[i]) || isDigit(source[i]))) {
+        i++;
+      }
+      const value = source.slice(start, i);
+      tokens.push({
+        kind: GO_KEYWORDS.has(value) ? "keyword" : "ident",
+        value,
+        pos: start,
+        end: i,
+      });
+      continue;
+    }
+
+    // Numbers
+    if (/[0-9]/.test(ch) || (ch === "." && /[0-9]/.test(source[i + 1] ?? ""))) {
+      const start = i;
+      let kind: GoTokenKind = "int";
+      if (ch === "0" && /[xX]/.test(source[i + 1] ?? "")) {
+        i += 2;
+        while (/[0-9a-fA-F_]/.test(source[i] ?? "")) i++;
+        if (source[i] === ".") {
+          kind = "float";
+          i++;
+          while (/[0-9a-fA-F_]/.test(source[i] ?? "")) i++;
+        }
+        if (/[pP]/.test(source[i] ?? "")) {
+          kind = "float";
+          i++;
+          if (/[+-]/.test(source[i] ?? "")) i++;
+          while (/[0-9_]/.test(source[i] ?? "")) i++;
+        }
+      } else if (ch === "0" && /[bBoO]/.test(source[i + 1] ?? "")) {
+        i += 2;
+        while (/[0-9_]/.test(source[i] ?? "")) i++;
+      } else {
+        while (/[0-9_]/.test(source[i] ?? "")) i++;
+        if (source[i] === ".") {
+          kind = "float";
+          i++;
+          while (/[0-9_]/.test(source[i] ?? "")) i++;
+        }
+        if (/[eE]/.test(source[i] ?? "")) {
+          kind = "float";
+          i++;
+          if (/[+-]/.test(source[i] ?? "")) i++;
+          while (/[0-9_]/.test(source[i] ?? "")) i++;
+        }
+      }
+      if (source[i] === "i") {
+        kind = "imag";
+        i++;
+      }
+      tokens.push({ kind, value: source.slice(start, i), pos: start, end: i });
+      continue;
+    }
+
+    // Strings and runes
+    if (ch === '"' || ch === "'") {
+      const start = i;
+      i++;
+      while (i < source.length && source[i] !== ch) {
+        if (source[i] === "\\") {
+          i++;
+        } else if (source[i] === "\n") {
+          fail("string literal not terminated", start);
+        }
+        i++;
+      }
+      if (i >= source.length) {
+        fail("string literal not terminated", start);
+      }
+      i++;
+      tokens.push({
+        kind: ch === '"' ? "string" : "char",
+        value: source.slice(start, i),
+        pos: start,
+        end: i,
+      });
+      continue;
+    }
+    if (ch === "`") {
+      const start = i;
+      const close = source.indexOf("`", i + 1);
+      if (close === -1) {
+        fail("raw string literal not terminated", start);
+      }
+      i = close + 1;
+      tokens.push({
+        kind: "string",
+        value: source.slice(start, i),
+        pos: start,
+        end: i,
+      });
+      continue;
+    }
+
+    // Operators and punctuation
+    const op = GO_OPERATORS.find((candidate) =>
+      source.startsWith(candidate, i),
+    );
+    if (!op) {
+      fail(`invalid character ${JSON.stringify(ch)}`, i);
+    }
+    tokens.push({
+      kind: op === ";" ? ";" : "op",
+      value: op,
+      pos: i,
+      end: i + op.length,
+    });
+    i += op.length;
+  }
+
+  insertSemicolon(source.length);
+  tokens.push({ kind: "eof", value: "", pos: source.length, end: source.length });
+
+  return { tokens, comments };
+}
@@ -0,0 +1,135 @@
+import * as path from "path";
+import { pathToFileURL } from "url";
+import { GoFile } from "./ast.js";
+import { GoFinding, GoSeverity, sortFindings } from "./findings.js";
+
+/**
+ * Go Findings as LSP Diagnostics
+ * ==============================
+ * Shapes findings as Language Server Protocol `Diagnostic` objects grouped
+ * per file, as in `textDocument/publishDiagnostics`, so editors such as
+ * VS Code show them inline. LSP positions are zero-based and count columns
+ * in UTF-16 code units, while findings carry Go's 1-based byte columns, so
+ * a column after "é" (two bytes, one code unit) or "😀" (four bytes, two
+ * code units) moves left; the conversion reads the line from the parsed
+ * file. Findings only mark a position, so a diagnostic's range covers the
+ * identifier, selector or number starting there, or is empty when there is
+ * none.
+ */
+
+/** `DiagnosticSeverity` of the protocol */
+export const LSP_DIAGNOSTIC_SEVERITY = {
+  error: 1,
+  warning: 2,
+  information: 3,
+  hint: 4,
+} as const;
+
+export type GoLspSeverity =
+  (typeof LSP_DIAGNOSTIC_SEVERITY)[keyof typeof LSP_DIAGNOSTIC_SEVERITY];
+
+export interface GoLspPosition {
+  /** Zero-based line */
+  line: number;
+  /** Zero-based UTF-16 code unit offset in the line */
+  character: number;
+}
+
+export interface GoLspDiagnostic {
+  range: { start: GoLspPosition; end: GoLspPosition };
+  severity: GoLspSeverity;
+  source: string;
+  /** The rule ID */
+  code: string;
+  message: string;
+}
+
+export interface GoLspFileDiagnostics {
+  /** `file://` URI of the file */
+  uri: string;
+  diagnostics: GoLspDiagnostic[];
+}
+
+export interface GoLspOptions {
+  /** Overrides the severity a finding severity maps to */
+  severities?: Partial<Record<GoSeverity, GoLspSeverity>>;
+  /** Name shown with each diagnostic (default: `refactogent`) */
+  source?: string;
+  /** Directory relative file paths resolve against (default: the cwd) */
+  root?: string;
+}
+
+const DEFAULT_SEVERITIES: Record<GoSeverity, GoLspSeverity> = {
+  high: LSP_DIAGNOSTIC_SEVERITY.error,
+  medium: LSP_DIAGNOSTIC_SEVERITY.warning,
+  low: LSP_DIAGNOSTIC_SEVERITY.information,
+};
+
+const WORD = /[\p{L}\p{Nd}_.]*/uy;
+
+/**
+ * The LSP position of a 1-based line and byte column of a file
+ */
+export function goLspPosition(
+  file: GoFile,
+  line: number,
+  column: number,
+): GoLspPosition {
+  const offset = file.sourceMap.offset(line, column);
+  return {
+    line: line - 1,
+    character: offset - file.sourceMap.lineStart(line),
+  };
+}
+
+function diagnostic(
+  finding: GoFinding,
+  file: GoFile | undefined,
+  options: GoLspOptions,
+): GoLspDiagnostic {
+  const severity =
+    options.severities?.[finding.severity] ??
+    DEFAULT_SEVERITIES[finding.severity];
+  let start: GoLspPosition;
+  let end: GoLspPosition;
+  if (file) {
+    start = goLspPosition(file, finding.line, finding.column);
+    WORD.lastIndex = file.sourceMap.offset(finding.line, finding.column);
+    const length = WORD.exec(file.source)?.[0].length ?? 0;
+    end = { line: start.line, character: start.character + length };
+  } else {
+    // Without the source, byte columns of ASCII lines are the best guess
+    start = { line: finding.line - 1, character: finding.column - 1 };
+    end = start;
+  }
+  return {
+    range: { start, end },
+    severity,
+    source: options.source ?? "refactogent",
+    code: finding.rule,
+    message: finding.message,
+  };
+}
+
+/**
+ * Findings as LSP diagnostics, one entry per file with findings, sorted by
+ * path. `files` provide the lines columns are converted on.
+ */
+export function goLspDiagnostics(
+  findings: GoFinding[],
+  files: GoFile[],
+  options: GoLspOptions = {},
+): GoLspFileDiagnostics[] {
+  const byPath = new Map(files.map((file) => [file.filePath, file]));
+  const grouped = new Map<string, GoLspDiagnostic[]>();
+  for (const finding of sortFindings([...findings])) {
+    if (!grouped.has(finding.filePath)) grouped.set(finding.filePath, []);
+    grouped
+      .get(finding.filePath)
+      .push(diagnostic(finding, byPath.get(finding.filePath), options));
+  }
+  return [...grouped].map(([filePath, diagnostics]) => ({
+    uri: pathToFileURL(path.resolve(options.root ?? "", filePath)).href,
+    diagnostics,
+  }));
+}
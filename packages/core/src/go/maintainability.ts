@@ -0,0 +1,196 @@
+import { GoFile } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoFunctionSize } from "./size.js";
+import { GoFileSymbols, goFunctionSymbol } from "./symbols.js";
+
+/**
+ * Maintainability Index
+ * =====================
+ * One score between 0 and 100 per function, for teams that gate on a single
+ * number rather than on complexity and size separately. It follows the
+ * classic maintainability index:
+ *
+ *     MI = base - complexity * G - lines * ln(LOC)
+ *              + comments * sin(sqrt(2.4 * CM))
+ *
+ * where G is the cyclomatic complexity, LOC the lines holding code and CM
+ * the share of comment lines, the doc comment included, between 0 and 1.
+ * The score is `MI * 100 / scale`, clamped to 0–100 and rounded to one
+ * decimal, as Visual Studio reports it. The classic formula also subtracts
+ * `5.2 * ln(V)` for the Halstead volume V, which is left out, so scores run
+ * higher than tools measuring it report; lowering `base` compensates.
+ *
+ * A file scores the mean of its functions and methods weighted by their
+ * code lines, so one large, tangled function drags it down more than a few
+ * small ones lift it. Files without functions have no score.
+ *
+ * The `low-maintainability` rule reports functions scoring below a minimum,
+ * 20 by default, the point below which Visual Studio rates code as hard to
+ * maintain, so a CI gate can fail on them like on any other finding. With
+ * the volume left out, a higher minimum gets closer to what Visual Studio
+ * flags. The rule takes the same constants as the report, so a check gates
+ * on the scores `analyze` shows when both are given them.
+ */
+
+export type GoMaintainabilityConstant =
+  | "base"
+  | "complexity"
+  | "lines"
+  | "comments"
+  | "scale";
+
+export type GoMaintainabilityConstants = Record<
+  GoMaintainabilityConstant,
+  number
+>;
+
+export const DEFAULT_GO_MAINTAINABILITY_CONSTANTS: Readonly<GoMaintainabilityConstants> =
+  Object.freeze({
+    base: 171,
+    complexity: 0.23,
+    lines: 16.2,
+    comments: 50,
+    scale: 171,
+  });
+
+export const DEFAULT_GO_MIN_MAINTAINABILITY = 20;
+
+export interface GoMaintainabilityOptions {
+  /**
+   * Lowest index a function may score without being reported (default:
+   * {@link DEFAULT_GO_MIN_MAINTAINABILITY})
+   */
+  minIndex?: number;
+  /** Constants of the formula, as given to the report */
+  constants?: Partial<GoMaintainabilityConstants>;
+}
+
+export interface GoLowMaintainabilityFinding extends GoFinding {
+  rule: "low-maintainability";
+  /** `Type.Method` for methods, the bare name for functions */
+  name: string;
+  maintainabilityIndex: number;
+}
+
+const CONSTANT_NAMES = Object.keys(
+  DEFAULT_GO_MAINTAINABILITY_CONSTANTS,
+) as GoMaintainabilityConstant[];
+
+export class GoMaintainabilityError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoMaintainabilityError";
+  }
+}
+
+/**
+ * Parse constants written as `name=value` pairs separated by commas, e.g.
+ * `base=140,comments=0`
+ */
+export function parseGoMaintainabilityConstants(
+  text: string,
+): Partial<GoMaintainabilityConstants> {
+  const constants: Partial<GoMaintainabilityConstants> = {};
+  for (const pair of text.split(",")) {
+    const separator = pair.indexOf("=");
+    const name = pair.slice(0, separator).trim() as GoMaintainabilityConstant;
+    const value = pair.slice(separator + 1).trim();
+    if (separator < 0 || !CONSTANT_NAMES.includes(name)) {
+      throw new GoMaintainabilityError(
+        `Constant ${JSON.stringify(pair)} must look like name=value, with name one of ${CONSTANT_NAMES.join(", ")}`,
+      );
+    }
+    if (value === "" || !Number.isFinite(Number(value))) {
+      throw new GoMaintainabilityError(
+        `Constant ${name} must be a number, not ${JSON.stringify(value)}`,
+      );
+    }
+    constants[name] = Number(value);
+  }
+  if (constants.scale === 0) {
+    throw new GoMaintainabilityError("Constant scale cannot be 0");
+  }
+  return constants;
+}
+
+/**
+ * The maintainability index of a function from its cyclomatic complexity
+ * and size
+ */
+export function goMaintainabilityIndex(
+  complexity: number,
+  size: Pick<GoFunctionSize, "codeLines" | "commentLines" | "docLines">,
+  constants: Partial<GoMaintainabilityConstants> = {},
+): number {
+  const formula = { ...DEFAULT_GO_MAINTAINABILITY_CONSTANTS, ...constants };
+  const commented = size.commentLines + size.docLines;
+  const ratio = commented / Math.max(1, size.codeLines + commented);
+  const index =
+    formula.base -
+    formula.complexity * complexity -
+    formula.lines * Math.log(Math.max(1, size.codeLines)) +
+    formula.comments * Math.sin(Math.sqrt(2.4 * ratio));
+  const score = Math.min(100, Math.max(0, (index * 100) / formula.scale));
+  return Math.round(score * 10) / 10;
+}
+
+/**
+ * Record the maintainability index on the function and method symbols of
+ * the given files, and on the files themselves
+ */
+export function annotateMaintainability(
+  files: GoFileSymbols[],
+  constants: Partial<GoMaintainabilityConstants> = {},
+): void {
+  for (const file of files) {
+    let weighted = 0;
+    let codeLines = 0;
+    for (const symbol of [...file.functions, ...file.methods]) {
+      symbol.maintainabilityIndex = goMaintainabilityIndex(
+        symbol.complexity,
+        symbol.size,
+        constants,
+      );
+      const weight = Math.max(1, symbol.size.codeLines);
+      weighted += symbol.maintainabilityIndex * weight;
+      codeLines += weight;
+    }
+    if (codeLines > 0) {
+      file.maintainabilityIndex = Math.round((weighted / codeLines) * 10) / 10;
+    }
+  }
+}
+
+/**
+ * Find functions and methods whose maintainability index is below the
+ * minimum
+ */
+export function findLowMaintainability(
+  files: GoFile[],
+  options: GoMaintainabilityOptions = {},
+): GoLowMaintainabilityFinding[] {
+  const minimum = options.minIndex ?? DEFAULT_GO_MIN_MAINTAINABILITY;
+  const findings: GoLowMaintainabilityFinding[] = [];
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl" || !decl.body) continue;
+      const symbol = goFunctionSymbol(file, decl);
+      const index = goMaintainabilityIndex(
+        symbol.complexity,
+        symbol.size,
+        options.constants,
+      );
+      if (index >= minimum) continue;
+      findings.push({
+        rule: "low-maintainability",
+        severity: "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(decl.name.pos),
+        message: `${symbol.qualifiedName} has a maintainability index of ${index}, below ${minimum}; split it or simplify its control flow`,
+        name: symbol.qualifiedName,
+        maintainabilityIndex: index,
+      });
+    }
+  }
+  return sortFindings(findings);
+}
@@ -0,0 +1,241 @@
+import {
+  Expr,
+  FuncDecl,
+  GoFile,
+  Ident,
+  IndexExpr,
+  Node,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference, mapTypes } from "./infer.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+
+/**
+ * Go Map Presence Checks
+ * ======================
+ * Flags single-value map reads whose result is compared with nil or
+ * dereferenced, when the map holds pointers or interfaces. For those maps the
+ * nil a missing key reads as cannot be told apart from a stored nil, so the
+ * check reads as "is the key present?" without answering it, and a
+ * dereference panics on a missing key. The comma-ok form answers the question
+ * directly. A read is followed into the variable it initializes or is
+ * assigned to, up to the variable's next assignment; reads passed on inside a
+ * larger expression, where a nil value is as good as an absent one, are not
+ * reported.
+ */
+
+export type GoMapReadUse = "nil-check" | "dereference";
+
+export interface GoMapAccessFinding extends GoFinding {
+  rule: "map-read-without-ok";
+  /** The map index expression, as written */
+  expression: string;
+  /** Value type of the map */
+  valueType: string;
+  use: GoMapReadUse;
+}
+
+class MapAccessChecker {
+  private readonly file: GoFile;
+  private readonly findings: GoMapAccessFinding[] = [];
+  private parents = new Map<Node, Node>();
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  // The node an expression's value flows into, looking through parentheses
+  private consumer(expr: Node): { node: Node; child: Node } | undefined {
+    let child = expr;
+    let node = this.parents.get(child);
+    while (node?.kind === "ParenExpr") {
+      child = node;
+      node = this.parents.get(child);
+    }
+    return node ? { node, child } : undefined;
+  }
+
+  // How the value of an expression is used, when that is a nil check or a
+  // dereference
+  private useOf(expr: Node): GoMapReadUse | undefined {
+    const consumer = this.consumer(expr);
+    if (!consumer) return undefined;
+    const { node, child } = consumer;
+    switch (node.kind) {
+      case "BinaryExpr": {
+        if (node.op !== "==" && node.op !== "!=") return undefined;
+        const other = node.x === child ? node.y : node.x;
+        return other.kind === "Ident" && other.name === "nil"
+          ? "nil-check"
+          : undefined;
+      }
+      case "SelectorExpr":
+      case "StarExpr":
+        return node.x === child ? "dereference" : undefined;
+      case "TypeAssertExpr": {
+        if (node.x !== child) return undefined;
+        // Only the single-value assertion panics
+        const assigned = this.consumer(node)?.node;
+        const commaOk =
+          (assigned?.kind === "AssignStmt" && assigned.lhs.length === 2) ||
+          (assigned?.kind === "ValueSpec" && assigned.names.length === 2);
+        return commaOk ? undefined : "dereference";
+      }
+      default:
+        return undefined;
+    }
+  }
+
+  // Value type of a map read when a missing key and a stored nil look alike
+  private ambiguousValueType(expr: IndexExpr): string | undefined {
+    const mapType = this.types.typeOf(expr.x);
+    const map = mapType && mapTypes(this.types.underlying(mapType) ?? mapType);
+    if (!map) return undefined;
+    const value = this.types.underlying(map.value) ?? map.value;
+    const nilable =
+      value.startsWith("*") ||
+      /^interface\s*\{/.test(value) ||
+      value === "any" ||
+      value === "error";
+    return nilable ? map.value : undefined;
+  }
+
+  // The variable a read initializes or is assigned to on its own
+  private assignedVariable(expr: IndexExpr): GoVariable | undefined {
+    const parent = this.parents.get(expr);
+    let target: Expr | undefined;
+    if (
+      parent?.kind === "AssignStmt" &&
+      (parent.tok === "=" || parent.tok === ":=") &&
+      parent.lhs.length === 1 &&
+      parent.rhs[0] === expr
+    ) {
+      target = parent.lhs[0];
+    } else if (
+      parent?.kind === "ValueSpec" &&
+      parent.names.length === 1 &&
+      parent.values[0] === expr
+    ) {
+      target = parent.names[0];
+    }
+    return target?.kind === "Ident"
+      ? this.scopes.resolved.get(target)
+      : undefined;
+  }
+
+  // The first nil check or dereference of a variable after it is assigned,
+  // before anything assigns it again
+  private variableUse(
+    variable: GoVariable,
+    after: number,
+  ): { ident: Ident; use: GoMapReadUse } | undefined {
+    const references = this.scopes.references
+      .filter(
+        (reference) =>
+          reference.variable === variable && reference.ident.pos >= after,
+      )
+      .sort((a, b) => a.ident.pos - b.ident.pos);
+    for (const reference of references) {
+      if (reference.write) return undefined;
+      const use = this.useOf(reference.ident);
+      if (use) return { ident: reference.ident, use };
+    }
+    return undefined;
+  }
+
+  private report(
+    expr: IndexExpr,
+    valueType: string,
+    use: GoMapReadUse,
+    variable?: GoVariable,
+  ): void {
+    const expression = this.text(expr);
+    const name = variable?.name ?? "v";
+    const what =
+      use === "nil-check"
+        ? "compared with nil, which cannot tell a missing key from a stored nil"
+        : "dereferenced, which panics when the key is missing";
+    const subject = variable
+      ? `${expression} is read into ${name}, which is ${what}`
+      : `${expression} is ${what}`;
+    this.findings.push({
+      rule: "map-read-without-ok",
+      severity: use === "dereference" ? "medium" : "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(expr.pos),
+      message: `${subject}; use ${name}, ok := ${expression}`,
+      fix: `${name}, ok := ${expression}`,
+      expression,
+      valueType,
+      use,
+    });
+  }
+
+  check(decl: FuncDecl): void {
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+    this.parents = new Map();
+    const reads: IndexExpr[] = [];
+    inspect(decl, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+      if (node.kind === "IndexExpr") reads.push(node);
+    });
+
+    for (const expr of reads) {
+      const parent = this.parents.get(expr);
+      // Writes and the comma-ok form itself
+      if (
+        (parent?.kind === "AssignStmt" &&
+          (parent.lhs.includes(expr) || parent.lhs.length === 2)) ||
+        (parent?.kind === "ValueSpec" && parent.names.length === 2) ||
+        parent?.kind === "IncDecStmt"
+      ) {
+        continue;
+      }
+      const valueType = this.ambiguousValueType(expr);
+      if (!valueType) continue;
+
+      const direct = this.useOf(expr);
+      if (direct) {
+        this.report(expr, valueType, direct);
+        continue;
+      }
+      const variable = this.assignedVariable(expr);
+      const found = variable && this.variableUse(variable, parent.end);
+      if (found) this.report(expr, valueType, found.use, variable);
+    }
+  }
+
+  results(): GoMapAccessFinding[] {
+    return this.findings;
+  }
+}
+
+/**
+ * Find map reads of pointers and interfaces that are nil checked or
+ * dereferenced without the comma-ok form
+ */
+export function findMapReadsWithoutOk(files: GoFile[]): GoMapAccessFinding[] {
+  const findings: GoMapAccessFinding[] = [];
+  for (const file of files) {
+    const checker = new MapAccessChecker(file);
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl" && decl.body) {
+        checker.check(decl);
+      }
+    }
+    findings.push(...checker.results());
+  }
+  return sortFindings(findings);
+}
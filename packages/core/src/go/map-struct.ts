@@ -0,0 +1,394 @@
+import {
+  CompositeLit,
+  Expr,
+  GoFile,
+  Ident,
+  MapType,
+  Node,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference } from "./infer.js";
+import { suggestGoName } from "./naming.js";
+import { groupGoPackages } from "./package.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Maps as Structs
+ * ===============
+ * A `map[string]T` only ever indexed with a fixed set of literal keys is a
+ * struct in disguise: typos in keys compile, every value has the same type
+ * and nothing documents which keys exist. This pass collects the keys used
+ * with each map-typed struct field and package-level variable across the
+ * package (indexing, `delete`, and map literals assigned to it) and
+ * suggests a struct with a field per key. Values of `interface{}` and `any`
+ * maps get the type assigned to each key when every assignment agrees.
+ *
+ * Keys that are not string literals or string constants, and uses of the
+ * map as a whole (ranging over it, passing it on, assigning it a map from
+ * elsewhere) make the key set unknowable. They are listed with the finding,
+ * which then carries no suggested struct.
+ */
+
+export interface GoMapKeyUse {
+  /** Source of the key, or of the use of the whole map */
+  text: string;
+  line: number;
+  column: number;
+}
+
+export interface GoMapStructFinding extends GoFinding {
+  rule: "map-as-struct";
+  /** `Type.field` for fields, the name for package-level variables */
+  map: string;
+  /** Literal keys, in order of first use */
+  keys: string[];
+  /** Name of the suggested struct */
+  struct: string;
+  /** Keys and uses that could not be resolved; no struct is suggested */
+  unresolved: GoMapKeyUse[];
+}
+
+interface MapCandidate {
+  name: string;
+  /** Owning struct type; undefined for package-level variables */
+  owner?: string;
+  file: GoFile;
+  ident: Ident;
+  valueType: string;
+  keys: string[];
+  /** Types assigned to each key */
+  assigned: Map<string, Set<string>>;
+  unresolved: GoMapKeyUse[];
+}
+
+function isStringMap(file: GoFile, type: Expr | undefined): type is MapType {
+  return (
+    type?.kind === "MapType" &&
+    file.source.slice(type.key.pos, type.key.end) === "string"
+  );
+}
+
+function isEmptyInterface(text: string): boolean {
+  return text === "any" || /^interface\s*\{\s*\}$/.test(text);
+}
+
+// The field a key becomes, when it can be spelled as an exported identifier
+function fieldName(key: string): string | undefined {
+  const joined = key.replace(/[^\p{L}\p{Nd}]+/gu, "_").replace(/^_+|_+$/g, "");
+  if (!/^\p{L}/u.test(joined)) return undefined;
+  const name = suggestGoName(joined.charAt(0).toUpperCase() + joined.slice(1));
+  return /^\p{Lu}[\p{L}\p{Nd}_]*$/u.test(name) ? name : undefined;
+}
+
+class MapStructAnalyzer {
+  private readonly group: GoFile[];
+  private readonly fields = new Map<string, MapCandidate>();
+  private readonly vars = new Map<string, MapCandidate>();
+  private readonly constants = new Map<string, string>();
+  /** Package-level names a suggested struct must not collide with */
+  private readonly names = new Set<string>();
+  private file: GoFile;
+  private scopes: GoFunctionScopes | undefined;
+  private types: GoTypeInference;
+
+  constructor(group: GoFile[]) {
+    this.group = group;
+    for (const file of group) this.declarations(file);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private declarations(file: GoFile): void {
+    const text = (node: Node) => file.source.slice(node.pos, node.end);
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl" && !decl.recv) {
+        this.names.add(decl.name.name);
+      }
+      if (decl.kind !== "GenDecl") continue;
+      for (const spec of decl.specs) {
+        if (spec.kind === "TypeSpec") {
+          this.names.add(spec.name.name);
+          if (spec.type.kind !== "StructType") continue;
+          for (const field of spec.type.fields.list) {
+            if (!isStringMap(file, field.type)) continue;
+            for (const ident of field.names) {
+              this.fields.set(`${spec.name.name}.${ident.name}`, {
+                name: ident.name,
+                owner: spec.name.name,
+                file,
+                ident,
+                valueType: text(field.type.value),
+                keys: [],
+                assigned: new Map(),
+                unresolved: [],
+              });
+            }
+          }
+        } else if (spec.kind === "ValueSpec") {
+          spec.names.forEach((ident, index) => {
+            this.names.add(ident.name);
+            const value = spec.values[index];
+            if (decl.tok === "const") {
+              if (value?.kind === "BasicLit" && value.litKind === "string") {
+                const key = this.literal(value.value);
+                if (key !== undefined) this.constants.set(ident.name, key);
+              }
+              return;
+            }
+            const type =
+              spec.type ??
+              (value?.kind === "CompositeLit" ? value.type : undefined) ??
+              (value?.kind === "CallExpr" &&
+              text(value.fun) === "make" &&
+              value.args.length > 0
+                ? value.args[0]
+                : undefined);
+            if (!isStringMap(file, type)) return;
+            this.vars.set(ident.name, {
+              name: ident.name,
+              file,
+              ident,
+              valueType: text(type.value),
+              keys: [],
+              assigned: new Map(),
+              unresolved: [],
+            });
+          });
+        }
+      }
+    }
+  }
+
+  private literal(value: string): string | undefined {
+    if (value.startsWith("`")) return value.slice(1, -1);
+    try {
+      return JSON.parse(value);
+    } catch {
+      return undefined;
+    }
+  }
+
+  private use(node: Node): GoMapKeyUse {
+    return {
+      text: this.text(node),
+      ...this.file.sourceMap.position(node.pos),
+    };
+  }
+
+  // The candidate an expression refers to
+  private candidate(expr: Expr): MapCandidate | undefined {
+    if (expr.kind === "Ident") {
+      const local = this.scopes?.resolved.has(expr);
+      return local ? undefined : this.vars.get(expr.name);
+    }
+    if (expr.kind !== "SelectorExpr") return undefined;
+    const owner = this.types.typeOf(expr.x)?.replace(/^\*/, "");
+    return owner ? this.fields.get(`${owner}.${expr.sel.name}`) : undefined;
+  }
+
+  private key(candidate: MapCandidate, expr: Expr, value?: Expr): void {
+    let key: string | undefined;
+    if (expr.kind === "BasicLit" && expr.litKind === "string") {
+      key = this.literal(expr.value);
+    } else if (expr.kind === "Ident" && !this.scopes?.resolved.has(expr)) {
+      key = this.constants.get(expr.name);
+    }
+    if (key === undefined) {
+      candidate.unresolved.push(this.use(expr));
+      return;
+    }
+    if (!candidate.keys.includes(key)) candidate.keys.push(key);
+    if (!value) return;
+    if (!candidate.assigned.has(key)) candidate.assigned.set(key, new Set());
+    candidate.assigned.get(key).add(this.types.typeOf(value) ?? "");
+  }
+
+  // A whole map stored into a candidate: a literal's keys count, make and
+  // nil add none, anything else brings unknown keys
+  private stored(candidate: MapCandidate, value: Expr): void {
+    if (value.kind === "CompositeLit" && isStringMap(this.file, value.type)) {
+      this.literalKeys(candidate, value);
+    } else if (
+      !(value.kind === "Ident" && value.name === "nil") &&
+      !(value.kind === "CallExpr" && this.text(value.fun) === "make")
+    ) {
+      candidate.unresolved.push(this.use(value));
+    }
+  }
+
+  private literalKeys(candidate: MapCandidate, literal: CompositeLit): void {
+    for (const elt of literal.elts) {
+      if (elt.kind === "KeyValueExpr") this.key(candidate, elt.key, elt.value);
+    }
+  }
+
+  private visit(root: Node): void {
+    inspect(root, (node, parents) => {
+      const parent = parents[parents.length - 1];
+      if (node.kind === "CompositeLit" && node.type) {
+        const owner = baseTypeName(node.type).name;
+        for (const elt of node.elts) {
+          if (elt.kind !== "KeyValueExpr" || elt.key.kind !== "Ident") continue;
+          const candidate = this.fields.get(`${owner}.${elt.key.name}`);
+          if (candidate) this.stored(candidate, elt.value);
+        }
+        return;
+      }
+      if (node.kind === "ValueSpec") {
+        node.names.forEach((ident, index) => {
+          const candidate = this.vars.get(ident.name);
+          const value = node.values[index];
+          if (candidate?.ident === ident && value) {
+            this.stored(candidate, value);
+          }
+        });
+        return;
+      }
+      if (node.kind !== "Ident" && node.kind !== "SelectorExpr") return;
+      // Declared names, field names in struct literals and selected names
+      // are not uses
+      if (
+        parent?.kind === "Field" ||
+        (parent?.kind === "KeyValueExpr" && parent.key === node) ||
+        (parent?.kind === "SelectorExpr" && parent.sel === node)
+      ) {
+        return;
+      }
+      const candidate = this.candidate(node);
+      if (!candidate || node === candidate.ident) return;
+      if (parent?.kind === "IndexExpr" && parent.x === node) {
+        const grand = parents[parents.length - 2];
+        const assigned =
+          grand?.kind === "AssignStmt" &&
+          grand.tok === "=" &&
+          grand.lhs.length === grand.rhs.length
+            ? grand.rhs[grand.lhs.indexOf(parent)]
+            : undefined;
+        this.key(candidate, parent.index, assigned);
+        return false;
+      }
+      if (parent?.kind === "CallExpr" && parent.args[0] === node) {
+        const builtin = this.text(parent.fun);
+        if (builtin === "len" || builtin === "clear") return false;
+        if (builtin === "delete" && parent.args.length === 2) {
+          this.key(candidate, parent.args[1]);
+          return false;
+        }
+      }
+      if (parent?.kind === "AssignStmt" && parent.lhs.includes(node)) {
+        const index = parent.lhs.indexOf(node);
+        if (parent.lhs.length === parent.rhs.length) {
+          this.stored(candidate, parent.rhs[index]);
+          return false;
+        }
+      }
+      candidate.unresolved.push(
+        parent?.kind === "RangeStmt" && parent.x === node
+          ? { ...this.use(node), text: `range ${this.text(node)}` }
+          : this.use(node),
+      );
+      return false;
+    });
+  }
+
+  private structName(candidate: MapCandidate): string {
+    const base = suggestGoName(
+      candidate.name.charAt(0).toUpperCase() + candidate.name.slice(1),
+    );
+    const names = [base, `${candidate.owner ?? ""}${base}`, `${base}Fields`];
+    return names.find((name) => !this.names.has(name)) ?? names[2];
+  }
+
+  private finding(candidate: MapCandidate): GoMapStructFinding | undefined {
+    if (candidate.keys.length === 0) return undefined;
+    const unresolved = [...candidate.unresolved];
+    const fields: [string, string][] = [];
+    const names = new Set<string>();
+    for (const key of candidate.keys) {
+      const name = fieldName(key);
+      if (!name || names.has(name)) {
+        unresolved.push({
+          text: JSON.stringify(key),
+          ...candidate.file.sourceMap.position(candidate.ident.pos),
+        });
+        continue;
+      }
+      names.add(name);
+      const assigned = [...(candidate.assigned.get(key) ?? [])];
+      const type =
+        isEmptyInterface(candidate.valueType) &&
+        assigned.length === 1 &&
+        assigned[0]
+          ? assigned[0]
+          : candidate.valueType;
+      fields.push([name, type]);
+    }
+
+    const map = candidate.owner
+      ? `${candidate.owner}.${candidate.name}`
+      : candidate.name;
+    const struct = this.structName(candidate);
+    const width = Math.max(...fields.map(([name]) => name.length));
+    const keys = candidate.keys.map((key) => JSON.stringify(key)).join(", ");
+    const message =
+      unresolved.length === 0
+        ? `${map} is only used with the keys ${keys}; a ${struct} struct would name and type them`
+        : `${map} is used with the keys ${keys}, but ${unresolved.length} key(s) or use(s) could not be resolved: ${unresolved.map((use) => `${use.text} (line ${use.line})`).join(", ")}`;
+    return {
+      rule: "map-as-struct",
+      severity: "low",
+      filePath: candidate.file.filePath,
+      ...candidate.file.sourceMap.position(candidate.ident.pos),
+      message,
+      ...(unresolved.length === 0 && {
+        fix: [
+          `type ${struct} struct {`,
+          ...fields.map(([name, type]) => `\t${name.padEnd(width)} ${type}`),
+          "}",
+        ].join("\n"),
+      }),
+      map,
+      keys: candidate.keys,
+      struct,
+      unresolved,
+    };
+  }
+
+  analyze(): GoMapStructFinding[] {
+    if (this.fields.size === 0 && this.vars.size === 0) return [];
+    for (const file of this.group) {
+      this.file = file;
+      for (const decl of file.decls) {
+        if (decl.kind === "FuncDecl") {
+          if (!decl.body) continue;
+          this.scopes = resolveFunctionScopes(decl);
+          this.types = new GoTypeInference(file, this.scopes);
+          this.visit(decl.body);
+        } else {
+          this.scopes = undefined;
+          this.types = new GoTypeInference(file);
+          this.visit(decl);
+        }
+      }
+    }
+    return [...this.fields.values(), ...this.vars.values()]
+      .map((candidate) => this.finding(candidate))
+      .filter((finding) => finding !== undefined);
+  }
+}
+
+/**
+ * Find string-keyed maps used with a fixed set of literal keys, which could
+ * be structs
+ */
+export function findMapsAsStructs(files: GoFile[]): GoMapStructFinding[] {
+  return sortFindings(
+    groupGoPackages(files).flatMap((group) =>
+      new MapStructAnalyzer(group).analyze(),
+    ),
+  );
+}
@@ -0,0 +1,405 @@
+import * as path from "path";
+import { TextEdit, unifiedDiff } from "../diff.js";
+import { FuncDecl, GenDecl, GoFile, ImportSpec } from "./ast.js";
+import { compareCodePoints } from "./findings.js";
+import {
+  GoImportEntry,
+  importDecl,
+  importEdits,
+  importName,
+  importPath,
+  usedImportNames,
+} from "./imports.js";
+import {
+  KNOWN_GOARCH,
+  KNOWN_GOOS,
+  parseBuildConstraint,
+} from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { sortedImportDecl, sortedImportEdits } from "./sort-imports.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Move Function
+ * =============
+ * Relocates functions and methods, with their doc comments, to another file
+ * of the same package. References need no update, but imports do: the
+ * destination gains the imports the moved code uses and the source loses the
+ * ones nothing else uses. Moves that would change when the code is compiled
+ * (different build constraints, file name suffixes or test status) are
+ * refused.
+ */
+
+export interface MoveFunctionOptions {
+  /** Also group and sort the destination's imports */
+  sortImports?: boolean;
+}
+
+export interface MoveFunctionResult {
+  /** The source file without the moved declarations */
+  source: GoRefactorResult;
+  /** The destination file with the declarations appended */
+  destination: GoRefactorResult;
+  /** True when the destination file did not exist and is created */
+  created: boolean;
+  moved: string[];
+  /** Import paths added to the destination */
+  addedImports: string[];
+  /** Import paths removed from the source */
+  removedImports: string[];
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+function importEntry(spec: ImportSpec): GoImportEntry {
+  return { path: importPath(spec), name: spec.name?.name };
+}
+
+// The `_GOOS`, `_GOARCH` or `_GOOS_GOARCH` suffix of a file name, if any
+function fileNameTags(filePath: string): string {
+  const parts = path
+    .basename(filePath, ".go")
+    .replace(/_test$/, "")
+    .split("_")
+    .slice(1);
+  const n = parts.length;
+  if (
+    n >= 2 &&
+    KNOWN_GOOS.has(parts[n - 2]) &&
+    KNOWN_GOARCH.has(parts[n - 1])
+  ) {
+    return `${parts[n - 2]}_${parts[n - 1]}`;
+  }
+  if (
+    n >= 1 &&
+    (KNOWN_GOOS.has(parts[n - 1]) || KNOWN_GOARCH.has(parts[n - 1]))
+  ) {
+    return parts[n - 1];
+  }
+  return "";
+}
+
+// The `//go:build` line of a file header, for copying into a new file
+function buildLine(source: string): string | undefined {
+  const header = source.slice(0, source.search(/^package\s/m));
+  return header.match(/^\/\/go:build .*$/m)?.[0];
+}
+
+// Join touching deletions, and drop the blank line before a deletion that
+// reaches the end of the file so no blank line is left trailing
+function mergeDeletions(source: string, edits: TextEdit[]): TextEdit[] {
+  const merged: TextEdit[] = [];
+  for (const edit of [...edits].sort((a, b) => a.start - b.start)) {
+    const last = merged.at(-1);
+    if (last && edit.start <= last.end) {
+      last.end = Math.max(last.end, edit.end);
+    } else {
+      merged.push({ ...edit });
+    }
+  }
+  const last = merged.at(-1);
+  if (last && last.end >= source.length && source[last.start - 2] === "\n") {
+    last.start--;
+  }
+  return merged;
+}
+
+class FunctionMover {
+  private readonly file: GoFile;
+  private readonly destination: GoFile | undefined;
+  private readonly destinationPath: string;
+  private readonly names: string[];
+  private readonly options: MoveFunctionOptions;
+
+  constructor(
+    file: GoFile,
+    destination: GoFile | string,
+    names: string[],
+    options: MoveFunctionOptions,
+  ) {
+    this.file = file;
+    this.destination =
+      typeof destination === "string" ? undefined : destination;
+    this.destinationPath =
+      typeof destination === "string" ? destination : destination.filePath;
+    this.names = names;
+    this.options = options;
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  private locate(): FuncDecl[] {
+    if (this.names.length === 0) {
+      throw new GoRefactorError("No functions to move");
+    }
+    const decls = this.names.map((name) => {
+      const decl = this.file.decls.find(
+        (candidate): candidate is FuncDecl =>
+          candidate.kind === "FuncDecl" && qualifiedName(candidate) === name,
+      );
+      if (!decl) {
+        throw new GoRefactorError(`${name} is not declared in the file`);
+      }
+      return decl;
+    });
+    return [...new Set(decls)].sort((a, b) => a.pos - b.pos);
+  }
+
+  private validate(decls: FuncDecl[]): void {
+    const { file, destination, destinationPath } = this;
+    const sourceName = path.basename(file.filePath);
+    const destinationName = path.basename(destinationPath);
+    if (path.resolve(file.filePath) === path.resolve(destinationPath)) {
+      throw new GoRefactorError("The destination is the source file");
+    }
+    const directory = (filePath: string) =>
+      path.dirname(path.resolve(filePath));
+    if (directory(file.filePath) !== directory(destinationPath)) {
+      throw new GoRefactorError(
+        `${destinationName} is not in the same directory as ${sourceName}`,
+      );
+    }
+    if (!destinationName.endsWith(".go")) {
+      throw new GoRefactorError(`${destinationName} is not a Go file`);
+    }
+    const isTest = (name: string) => name.endsWith("_test.go");
+    if (isTest(sourceName) !== isTest(destinationName)) {
+      throw new GoRefactorError(
+        "Cannot move code between test and non-test files",
+      );
+    }
+    if (fileNameTags(sourceName) !== fileNameTags(destinationName)) {
+      throw new GoRefactorError(
+        `${sourceName} and ${destinationName} have different file name build tags`,
+      );
+    }
+    if (file.imports.some((spec) => spec.name?.name === ".")) {
+      throw new GoRefactorError(
+        `${sourceName} has dot imports, so the imports moved code needs are unknown`,
+      );
+    }
+    if (!destination) return;
+
+    if (destination.packageName.name !== file.packageName.name) {
+      throw new GoRefactorError(
+        `${destinationName} is in package ${destination.packageName.name}, not ${file.packageName.name}`,
+      );
+    }
+    const constraint = (source: string) =>
+      JSON.stringify(parseBuildConstraint(source) ?? null);
+    if (constraint(destination.source) !== constraint(file.source)) {
+      throw new GoRefactorError(
+        `${sourceName} and ${destinationName} have different build constraints`,
+      );
+    }
+    const declared = new Set(
+      destination.decls
+        .filter((decl): decl is FuncDecl => decl.kind === "FuncDecl")
+        .map(qualifiedName),
+    );
+    for (const decl of decls) {
+      const name = qualifiedName(decl);
+      if (declared.has(name) && name !== "init" && name !== "_") {
+        throw new GoRefactorError(
+          `${destinationName} already declares ${name}`,
+        );
+      }
+    }
+  }
+
+  // Whole lines from a declaration's doc comment through its last line, plus
+  // one following blank line
+  private removal(
+    startOffset: number,
+    endOffset: number,
+  ): TextEdit & { text: string } {
+    const { source, sourceMap } = this.file;
+    const start = sourceMap.lineStart(this.line(startOffset));
+    let end = sourceMap.lineStart(this.line(endOffset) + 1);
+    const text = source.slice(start, end).replace(/\n$/, "");
+    if (source[end] === "\n") end++;
+    return { start, end, newText: "", text };
+  }
+
+  // Edits removing imports nothing left in the source uses
+  private sourceImportEdits(
+    moved: Set<FuncDecl>,
+    removed: string[],
+  ): TextEdit[] {
+    const { file } = this;
+    const names = new Set(
+      file.imports
+        .filter((spec) => spec.name?.name !== "_")
+        .map((spec) => importName(spec)),
+    );
+    const stillUsed = new Set<string>();
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl" && moved.has(decl)) continue;
+      usedImportNames(decl, names).forEach((name) => stillUsed.add(name));
+    }
+    const movedUses = new Set<string>();
+    for (const decl of moved) {
+      usedImportNames(decl, names).forEach((name) => movedUses.add(name));
+    }
+
+    const edits: TextEdit[] = [];
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "import") continue;
+      const unused = decl.specs.filter(
+        (spec): spec is ImportSpec =>
+          spec.kind === "ImportSpec" &&
+          movedUses.has(importName(spec)) &&
+          !stillUsed.has(importName(spec)),
+      );
+      if (unused.length === 0) continue;
+      removed.push(...unused.map(importPath));
+      if (unused.length === decl.specs.length) {
+        edits.push(this.removal(decl.doc?.pos ?? decl.pos, decl.end));
+        continue;
+      }
+      for (const spec of unused) {
+        const { sourceMap } = file;
+        edits.push({
+          start: sourceMap.lineStart(this.line(spec.doc?.pos ?? spec.pos)),
+          end: sourceMap.lineStart(this.line(spec.end) + 1),
+          newText: "",
+        });
+      }
+    }
+    return edits;
+  }
+
+  // Imports of the source that the moved declarations use
+  private neededImports(moved: Set<FuncDecl>): ImportSpec[] {
+    const names = new Set(
+      this.file.imports
+        .filter((spec) => spec.name?.name !== "_")
+        .map((spec) => importName(spec)),
+    );
+    const used = new Set<string>();
+    for (const decl of moved) {
+      usedImportNames(decl, names).forEach((name) => used.add(name));
+    }
+    return this.file.imports
+      .filter((spec) => spec.name?.name !== "_" && used.has(importName(spec)))
+      .sort((a, b) => compareCodePoints(importPath(a), importPath(b)));
+  }
+
+  private destinationImportEdits(
+    destination: GoFile,
+    needed: ImportSpec[],
+    added: string[],
+  ): TextEdit[] {
+    const destinationName = path.basename(destination.filePath);
+    const missing = needed.filter((spec) => {
+      const existing = destination.imports.find(
+        (candidate) => importName(candidate) === importName(spec),
+      );
+      if (existing && importPath(existing) !== importPath(spec)) {
+        throw new GoRefactorError(
+          `${destinationName} imports ${importPath(existing)} as ${importName(spec)}, which the moved code uses for ${importPath(spec)}`,
+        );
+      }
+      return !existing;
+    });
+    added.push(...missing.map(importPath));
+    const entries = missing.map(importEntry);
+    return this.options.sortImports
+      ? sortedImportEdits(destination, entries)
+      : importEdits(destination, entries);
+  }
+
+  move(): MoveFunctionResult {
+    const decls = this.locate();
+    this.validate(decls);
+    const moved = new Set(decls);
+
+    const removals = decls.map((decl) =>
+      this.removal(decl.doc?.pos ?? decl.pos, decl.end),
+    );
+    const removedImports: string[] = [];
+    const sourceEdits = mergeDeletions(this.file.source, [
+      ...removals.map(({ start, end, newText }) => ({ start, end, newText })),
+      ...this.sourceImportEdits(moved, removedImports),
+    ]);
+    const text = removals.map((removal) => removal.text).join("\n\n");
+
+    const needed = this.neededImports(moved);
+    const addedImports: string[] = [];
+    let destination: GoRefactorResult;
+    if (this.destination) {
+      const { source } = this.destination;
+      const trailing = source.length - source.trimEnd().length;
+      const edits = [
+        ...this.destinationImportEdits(this.destination, needed, addedImports),
+        {
+          start: source.length - trailing,
+          end: source.length,
+          newText: `\n\n${text}\n`,
+        },
+      ];
+      destination = refactorResult(this.destination, edits);
+    } else {
+      addedImports.push(...needed.map(importPath));
+      const header = buildLine(this.file.source);
+      const content = [
+        ...(header ? [header, ""] : []),
+        `package ${this.file.packageName.name}`,
+        "",
+        ...(needed.length > 0
+          ? [
+              (this.options.sortImports ? sortedImportDecl : importDecl)(
+                needed.map(importEntry),
+              ),
+              "",
+            ]
+          : []),
+        text,
+        "",
+      ].join("\n");
+      destination = {
+        filePath: this.destinationPath,
+        edits: [{ start: 0, end: 0, newText: content }],
+        source: content,
+        diff: unifiedDiff("", content, {
+          oldPath: "/dev/null",
+          newPath: this.destinationPath,
+        }),
+      };
+    }
+
+    return {
+      source: refactorResult(this.file, sourceEdits),
+      destination,
+      created: !this.destination,
+      moved: decls.map(qualifiedName),
+      addedImports,
+      removedImports,
+    };
+  }
+}
+
+/**
+ * Move functions or methods (`Func` or `Type.Method`) to another file of the
+ * same package. The destination is a parsed file, or the path of a file to
+ * create.
+ */
+export function moveFunctions(
+  file: GoFile,
+  destination: GoFile | string,
+  names: string[],
+  options: MoveFunctionOptions = {},
+): MoveFunctionResult {
+  return new FunctionMover(file, destination, names, options).move();
+}
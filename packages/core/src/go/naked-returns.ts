@@ -0,0 +1,234 @@
+import { TextEdit } from "../diff.js";
+import { FuncDecl, FuncLit, GoFile, Node, ReturnStmt, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference, zeroValue } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import { typeString } from "./signature.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Naked Returns
+ * =============
+ * A bare `return` in a function with named results returns whatever the
+ * results hold at that point, which is easy to follow in a few lines and
+ * hard to follow in many. This pass flags bare returns in functions longer
+ * than a line limit, counted from `func` to the closing brace, and fixes them
+ * by listing the results: since a bare return returns exactly the named
+ * result variables, `return a, b` naming them returns exactly the same
+ * values. Blank results, which cannot be named, return their zero value as
+ * they always did. A return where a local hides a result name is reported
+ * without a fix, since the explicit names would refer to the local.
+ */
+
+/** Lines a function may have before its naked returns are reported */
+export const DEFAULT_NAKED_RETURN_MAX_LINES = 5;
+
+export interface GoNakedReturnFinding extends GoFinding {
+  rule: "naked-return";
+  /** Name of the function, `Type.Method` for methods */
+  function: string;
+  /** Lines of the innermost function around the return */
+  lines: number;
+}
+
+export interface NakedReturnOptions {
+  /**
+   * Longest function allowed naked returns (default:
+   * {@link DEFAULT_NAKED_RETURN_MAX_LINES})
+   */
+  maxLines?: number;
+}
+
+export interface ExplicitReturnOptions extends NakedReturnOptions {
+  /** Line of a return reported by {@link findNakedReturns} (default: all) */
+  line?: number;
+}
+
+export interface ExplicitReturnResult extends GoRefactorResult {
+  /** Positions of the rewritten returns */
+  rewritten: { line: number; column: number }[];
+}
+
+interface NakedReturn {
+  stmt: ReturnStmt;
+  function: string;
+  lines: number;
+  /** The explicit return; undefined when a result name is shadowed */
+  replacement?: string;
+  /** Why there is no replacement */
+  shadowed?: string;
+}
+
+function functionName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+function contains(node: Node, pos: number): boolean {
+  return node.pos <= pos && pos < node.end;
+}
+
+class NakedReturnAnalyzer {
+  private readonly file: GoFile;
+  private readonly maxLines: number;
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(file: GoFile, options: NakedReturnOptions) {
+    this.file = file;
+    this.maxLines = options.maxLines ?? DEFAULT_NAKED_RETURN_MAX_LINES;
+  }
+
+  private lines(fn: FuncDecl | FuncLit): number {
+    const { sourceMap } = this.file;
+    return sourceMap.line(fn.end) - sourceMap.line(fn.pos) + 1;
+  }
+
+  // The explicit form of a bare return from a function, or the result name
+  // a local hides there
+  private explicit(
+    fn: FuncDecl | FuncLit,
+    stmt: ReturnStmt,
+  ): { replacement?: string; shadowed?: string } {
+    const values: string[] = [];
+    for (const field of fn.type.results.list) {
+      for (const ident of field.names) {
+        if (ident.name === "_") {
+          const type = typeString(this.file, field.type);
+          values.push(zeroValue(type, (name) => this.types.underlying(name)));
+          continue;
+        }
+        const result = this.scopes.resolved.get(ident);
+        const hidden = this.scopes.variables.some(
+          (variable) =>
+            variable !== result &&
+            variable.name === ident.name &&
+            variable.ident.pos < stmt.pos &&
+            contains(variable.scope.node, stmt.pos) &&
+            !contains(variable.scope.node, fn.pos),
+        );
+        if (hidden) return { shadowed: ident.name };
+        values.push(ident.name);
+      }
+    }
+    return { replacement: `return ${values.join(", ")}` };
+  }
+
+  private check(decl: FuncDecl, found: NakedReturn[]): void {
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+    inspect(decl, (node, parents) => {
+      if (node.kind !== "ReturnStmt" || node.results.length > 0) return;
+      const fn = ([...parents].reverse().find(
+        (parent) => parent.kind === "FuncLit",
+      ) ?? decl) as FuncDecl | FuncLit;
+      const named = fn.type.results?.list.some(
+        (field) => field.names.length > 0,
+      );
+      const lines = this.lines(fn);
+      if (!named || lines <= this.maxLines) return;
+      found.push({
+        stmt: node,
+        function:
+          fn === decl
+            ? functionName(decl)
+            : `function literal in ${functionName(decl)}`,
+        lines,
+        ...this.explicit(fn, node),
+      });
+    });
+  }
+
+  analyze(): NakedReturn[] {
+    const found: NakedReturn[] = [];
+    for (const decl of this.file.decls) {
+      if (decl.kind === "FuncDecl" && decl.body) this.check(decl, found);
+    }
+    return found.sort((a, b) => a.stmt.pos - b.stmt.pos);
+  }
+
+  finding(match: NakedReturn): GoNakedReturnFinding {
+    const advice = match.replacement
+      ? `use ${match.replacement}`
+      : `a local hides the result ${match.shadowed}`;
+    return {
+      rule: "naked-return",
+      severity: "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(match.stmt.pos),
+      message: `naked return in ${match.function}, which has ${match.lines} lines; ${advice}`,
+      ...(match.replacement && { fix: match.replacement }),
+      function: match.function,
+      lines: match.lines,
+    };
+  }
+
+  rewrite(options: ExplicitReturnOptions): ExplicitReturnResult {
+    const { sourceMap } = this.file;
+    const matches = this.analyze().filter(
+      (match) =>
+        options.line === undefined ||
+        sourceMap.line(match.stmt.pos) === options.line,
+    );
+    if (options.line !== undefined) {
+      if (matches.length === 0) {
+        throw new GoRefactorError(
+          `Line ${options.line} has no naked return in a long function`,
+        );
+      }
+      const [shadowed] = matches.filter((match) => !match.replacement);
+      if (shadowed) {
+        throw new GoRefactorError(
+          `Cannot make the return explicit: a local hides the result ${shadowed.shadowed}`,
+        );
+      }
+    }
+    const rewritten = matches.filter((match) => match.replacement);
+    const edits: TextEdit[] = rewritten.map((match) => ({
+      start: match.stmt.pos,
+      end: match.stmt.end,
+      newText: match.replacement,
+    }));
+    return {
+      ...refactorResult(this.file, edits),
+      rewritten: rewritten.map((match) => sourceMap.position(match.stmt.pos)),
+    };
+  }
+}
+
+/**
+ * Find bare returns in functions with named results that are longer than
+ * the line limit
+ */
+export function findNakedReturns(
+  files: GoFile[],
+  options: NakedReturnOptions = {},
+): GoNakedReturnFinding[] {
+  const findings: GoNakedReturnFinding[] = [];
+  for (const file of files) {
+    const analyzer = new NakedReturnAnalyzer(file, options);
+    findings.push(
+      ...analyzer.analyze().map((match) => analyzer.finding(match)),
+    );
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Rewrite naked returns in long functions to list the named results. Returns
+ * where a local hides a result are left alone, or refused when targeted by
+ * line.
+ */
+export function makeReturnsExplicit(
+  file: GoFile,
+  options: ExplicitReturnOptions = {},
+): ExplicitReturnResult {
+  return new NakedReturnAnalyzer(file, options).rewrite(options);
+}
@@ -0,0 +1,570 @@
+import * as path from "path";
+import {
+  CallExpr,
+  FieldList,
+  FuncDecl,
+  GoFile,
+  Ident,
+  Node,
+  forEachChild,
+} from "./ast.js";
+import { compareCodePoints } from "./findings.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import { baseTypeName, receiverTypeParams } from "./symbols.js";
+
+/**
+ * Go Naming Conventions
+ * =====================
+ * Flags identifiers that do not follow Effective Go's MixedCaps convention:
+ * snake_case names, ALL_CAPS constants, and initialisms written like ordinary
+ * words (`Api`, `userId`). Each finding carries the suggested name and every
+ * place in the package that would change with it.
+ */
+
+/**
+ * Initialisms Go keeps in a consistent case, as listed by golint
+ */
+export const DEFAULT_GO_INITIALISMS = [
+  "ACL",
+  "API",
+  "ASCII",
+  "CPU",
+  "CSS",
+  "DNS",
+  "EOF",
+  "GUID",
+  "HTML",
+  "HTTP",
+  "HTTPS",
+  "ID",
+  "IP",
+  "JSON",
+  "LHS",
+  "QPS",
+  "RAM",
+  "RHS",
+  "RPC",
+  "SLA",
+  "SMTP",
+  "SQL",
+  "SSH",
+  "TCP",
+  "TLS",
+  "TTL",
+  "UDP",
+  "UI",
+  "UID",
+  "UUID",
+  "URI",
+  "URL",
+  "UTF8",
+  "VM",
+  "XML",
+  "XMPP",
+  "XSRF",
+  "XSS",
+];
+
+export interface GoNamingOptions {
+  /** Replaces {@link DEFAULT_GO_INITIALISMS} when given */
+  initialisms?: string[];
+}
+
+export type GoNamingRule = "snake-case" | "all-caps" | "initialism";
+
+export type GoNamedKind =
+  | "function"
+  | "method"
+  | "type"
+  | "constant"
+  | "variable"
+  | "field"
+  | "parameter"
+  | "type-parameter";
+
+/**
+ * A place an identifier appears, declaration included
+ */
+export interface GoNameReference {
+  filePath: string;
+  line: number;
+  column: number;
+}
+
+export interface GoNamingViolation {
+  filePath: string;
+  line: number;
+  column: number;
+  name: string;
+  kind: GoNamedKind;
+  rule: GoNamingRule;
+  message: string;
+  suggestion: string;
+  /** Every site the rename touches, in file and source order */
+  references: GoNameReference[];
+}
+
+// Test, benchmark and example names may use underscores, as in
+// `ExampleClient_Get` or `TestParse_empty`
+const TEST_FUNCTION = /^(Test|Benchmark|Example|Fuzz)(_|[A-Z]|$)/;
+
+// An upper-case run before a capitalized word, an upper-case run, a
+// capitalized or lower-case word, or digits
+const WORD = /[A-Z]+(?=[A-Z][a-z])|[A-Z]+\d*(?![a-z])|[A-Z]?[a-z]+\d*|\d+/g;
+
+function words(name: string): string[] {
+  return name.match(WORD) ?? [];
+}
+
+function capitalize(word: string): string {
+  return word.charAt(0).toUpperCase() + word.slice(1).toLowerCase();
+}
+
+/**
+ * The MixedCaps spelling of a name, keeping its visibility. Returns the name
+ * unchanged when it already follows the convention.
+ */
+export function suggestGoName(
+  name: string,
+  options: GoNamingOptions = {},
+): string {
+  if (name === "_" || name.startsWith("_")) {
+    return name;
+  }
+  const initialisms = new Set(
+    (options.initialisms ?? DEFAULT_GO_INITIALISMS).map((word) =>
+      word.toUpperCase(),
+    ),
+  );
+  const exported = /^\p{Lu}/u.test(name);
+  const parts = words(name);
+  if (parts.join("") !== name.replace(/_/g, "")) {
+    // Non-ASCII or otherwise unusual names are left alone
+    return name;
+  }
+  // Snake and ALL_CAPS names are re-cased word by word; MixedCaps names only
+  // have their initialisms fixed
+  const recase = name.includes("_") || isAllCaps(name, initialisms);
+
+  return parts
+    .map((word, index) => {
+      const upper = word.toUpperCase();
+      if (index === 0 && !exported) {
+        return recase || initialisms.has(upper) ? word.toLowerCase() : word;
+      }
+      if (initialisms.has(upper)) {
+        return upper;
+      }
+      return recase ? capitalize(word) : word;
+    })
+    .join("");
+}
+
+function isAllCaps(name: string, initialisms: Set<string>): boolean {
+  const letters = name.replace(/[^A-Za-z]/g, "");
+  return (
+    letters.length > 1 &&
+    letters === letters.toUpperCase() &&
+    !words(name).every((word) => initialisms.has(word))
+  );
+}
+
+function classify(name: string, initialisms: Set<string>): GoNamingRule {
+  if (isAllCaps(name, initialisms)) return "all-caps";
+  if (name.includes("_")) return "snake-case";
+  return "initialism";
+}
+
+const RULE_MESSAGES: Record<GoNamingRule, string> = {
+  "snake-case": "uses underscores",
+  "all-caps": "is written in ALL_CAPS",
+  initialism: "does not keep initialisms in a consistent case",
+};
+
+/**
+ * Which names a name lives among: package-level declarations, fields and
+ * methods (matched by name alone), or the locals of one function
+ */
+export type GoNamespace = "package" | "member" | "local";
+
+/**
+ * An identifier in a file
+ */
+export interface GoNameSite {
+  file: GoFile;
+  node: Node;
+}
+
+/**
+ * A declared name, with its site
+ */
+export interface GoNameDeclaration extends GoNameSite {
+  name: string;
+  kind: GoNamedKind;
+  namespace: GoNamespace;
+  /** Identifies locals, which are only referenced inside their function */
+  key?: unknown;
+}
+
+/**
+ * A call of a function or method declared in the package
+ */
+export interface GoCallSite {
+  file: GoFile;
+  call: CallExpr;
+}
+
+/**
+ * Index of declarations and references across one package
+ */
+export class GoPackageNames {
+  readonly declarations: GoNameDeclaration[] = [];
+  // Package-level and member sites by name; local sites by variable
+  private readonly named = new Map<string, GoNameSite[]>();
+  private readonly locals = new Map<unknown, GoNameSite[]>();
+
+  declare(declaration: GoNameDeclaration): void {
+    if (declaration.name === "_") return;
+    this.declarations.push(declaration);
+    this.reference(
+      declaration.namespace,
+      declaration.name,
+      declaration,
+      declaration.key,
+    );
+  }
+
+  reference(
+    namespace: GoNamespace,
+    name: string,
+    site: GoNameSite,
+    key?: unknown,
+  ): void {
+    const sites = this.sitesFor(namespace, name, key);
+    if (!sites.some((existing) => existing.node === site.node)) {
+      sites.push({ file: site.file, node: site.node });
+    }
+  }
+
+  sites(declaration: GoNameDeclaration): GoNameSite[] {
+    const { namespace, name, key } = declaration;
+    return this.sitesFor(namespace, name, key);
+  }
+
+  /**
+   * The calls of a function or method, or why they cannot all be known: its
+   * name is declared more than once, or it is used other than by calling it,
+   * which also fixes its signature. `parents` maps the nodes of the indexed
+   * files to their parents.
+   */
+  calls(decl: FuncDecl, parents: Map<Node, Node>): GoCallSite[] | string {
+    const name = decl.name.name;
+    const recv = decl.recv?.list[0];
+    const qualified = recv ? `${baseTypeName(recv.type).name}.${name}` : name;
+    const where = ({ file, node }: GoNameSite) =>
+      `${path.basename(file.filePath)}:${file.sourceMap.line(node.pos)}`;
+
+    const namespace = recv ? "member" : "package";
+    const declarations = this.declarations.filter(
+      (candidate) =>
+        candidate.namespace === namespace && candidate.name === name,
+    );
+    if (declarations.length > 1) {
+      return `${name} is declared more than once in the package, so its calls cannot be told apart`;
+    }
+    const calls: GoCallSite[] = [];
+    for (const site of declarations[0] ? this.sites(declarations[0]) : []) {
+      if (site.node === decl.name) continue;
+      let callee: Node = site.node;
+      const selector = parents.get(callee);
+      if (recv && selector?.kind === "SelectorExpr") {
+        // Method expressions take the receiver as their first argument
+        const receiver =
+          selector.x.kind === "ParenExpr" ? selector.x.x : selector.x;
+        if (
+          receiver.kind === "StarExpr" ||
+          (receiver.kind === "Ident" &&
+            receiver.name === baseTypeName(recv.type).name)
+        ) {
+          return `${qualified} is used as a method expression on ${where(site)}, which fixes its signature`;
+        }
+        callee = selector;
+      }
+      // Explicit instantiations, as in `Map[int, string](...)`
+      const instance = parents.get(callee);
+      if (
+        (instance?.kind === "IndexExpr" ||
+          instance?.kind === "IndexListExpr") &&
+        instance.x === callee
+      ) {
+        callee = instance;
+      }
+      const call = parents.get(callee);
+      if (call?.kind !== "CallExpr" || call.fun !== callee) {
+        return `${qualified} is used as a value on ${where(site)}, which fixes its signature`;
+      }
+      calls.push({ file: site.file, call });
+    }
+    return calls;
+  }
+
+  private sitesFor(namespace: GoNamespace, name: string, key?: unknown) {
+    const map = namespace === "local" ? this.locals : this.named;
+    const mapKey = namespace === "local" ? key : `${namespace}:${name}`;
+    if (!map.has(mapKey)) map.set(mapKey, []);
+    return map.get(mapKey);
+  }
+}
+
+// Field names are needed before composite literal keys can be classified
+function collectFieldNames(node: Node, fieldNames: Set<string>): void {
+  if (node.kind === "StructType") {
+    node.fields.list.forEach((field) =>
+      field.names.forEach((ident) => fieldNames.add(ident.name)),
+    );
+  }
+  forEachChild(node, (child) => collectFieldNames(child, fieldNames));
+}
+
+function indexFile(
+  names: GoPackageNames,
+  file: GoFile,
+  fieldNames: Set<string>,
+): void {
+  const imports = new Set(
+    file.imports.map(
+      (spec) =>
+        spec.name?.name ?? spec.path.value.slice(1, -1).split("/").pop(),
+    ),
+  );
+
+  // Type parameters of the declaration being indexed, which are scoped to
+  // it like locals
+  let typeParams = new Map<string, object>();
+  const declareTypeParams = (idents: Ident[]) => {
+    typeParams = new Map();
+    for (const ident of idents) {
+      const key = {};
+      typeParams.set(ident.name, key);
+      names.declare({
+        file,
+        node: ident,
+        name: ident.name,
+        kind: "type-parameter",
+        namespace: "local",
+        key,
+      });
+    }
+  };
+  const paramNames = (list: FieldList | undefined) =>
+    (list?.list ?? []).flatMap((field) => field.names);
+
+  const visit = (node: Node, scopes?: GoFunctionScopes): void => {
+    switch (node.kind) {
+      case "Ident": {
+        const variable = scopes?.resolved.get(node);
+        if (variable) {
+          names.reference("local", node.name, { file, node }, variable);
+        } else if (typeParams.has(node.name)) {
+          const key = typeParams.get(node.name);
+          names.reference("local", node.name, { file, node }, key);
+        } else {
+          names.reference("package", node.name, { file, node });
+        }
+        return;
+      }
+      case "SelectorExpr":
+        visit(node.x, scopes);
+        // Qualified identifiers name another package's members
+        if (
+          node.x.kind === "Ident" &&
+          imports.has(node.x.name) &&
+          !scopes?.resolved.has(node.x)
+        ) {
+          return;
+        }
+        names.reference("member", node.sel.name, { file, node: node.sel });
+        return;
+      case "KeyValueExpr":
+        if (
+          node.key.kind === "Ident" &&
+          !scopes?.resolved.has(node.key) &&
+          fieldNames.has(node.key.name)
+        ) {
+          names.reference("member", node.key.name, { file, node: node.key });
+        } else {
+          visit(node.key, scopes);
+        }
+        visit(node.value, scopes);
+        return;
+      case "StructType":
+      case "InterfaceType":
+        for (const field of node.kind === "StructType"
+          ? node.fields.list
+          : node.methods.list) {
+          for (const ident of field.names) {
+            names.declare({
+              file,
+              node: ident,
+              name: ident.name,
+              kind: node.kind === "StructType" ? "field" : "method",
+              namespace: "member",
+            });
+          }
+          visit(field.type, scopes);
+        }
+        return;
+      case "LabeledStmt":
+        visit(node.stmt, scopes);
+        return;
+      case "BranchStmt":
+      case "ImportSpec":
+        return;
+      default:
+        forEachChild(node, (child) => visit(child, scopes));
+    }
+  };
+
+  for (const decl of file.decls) {
+    typeParams = new Map();
+    if (decl.kind === "FuncDecl") {
+      const recv = decl.recv?.list[0];
+      declareTypeParams([
+        ...(recv ? receiverTypeParams(recv.type) : []),
+        ...paramNames(decl.type.typeParams),
+      ]);
+      const scopes = resolveFunctionScopes(decl);
+      names.declare({
+        file,
+        node: decl.name,
+        name: decl.name.name,
+        kind: decl.recv ? "method" : "function",
+        namespace: decl.recv ? "member" : "package",
+      });
+      for (const variable of scopes.variables) {
+        names.declare({
+          file,
+          node: variable.ident,
+          name: variable.name,
+          kind: variable.kind === "local" ? "variable" : "parameter",
+          namespace: "local",
+          key: variable,
+        });
+      }
+      if (decl.recv) visit(decl.recv, scopes);
+      visit(decl.type, scopes);
+      if (decl.body) visit(decl.body, scopes);
+    } else if (decl.kind === "GenDecl") {
+      for (const spec of decl.specs) {
+        if (spec.kind === "TypeSpec") {
+          names.declare({
+            file,
+            node: spec.name,
+            name: spec.name.name,
+            kind: "type",
+            namespace: "package",
+          });
+          declareTypeParams(paramNames(spec.typeParams));
+          if (spec.typeParams) visit(spec.typeParams);
+          visit(spec.type);
+          typeParams = new Map();
+        } else if (spec.kind === "ValueSpec") {
+          for (const ident of spec.names) {
+            names.declare({
+              file,
+              node: ident,
+              name: ident.name,
+              kind: decl.tok === "const" ? "constant" : "variable",
+              namespace: "package",
+            });
+          }
+          if (spec.type) visit(spec.type);
+          spec.values.forEach((value) => visit(value));
+        }
+      }
+    }
+  }
+}
+
+/**
+ * Index the declarations and references of one package's files
+ */
+export function indexGoPackageNames(files: GoFile[]): GoPackageNames {
+  const names = new GoPackageNames();
+  const fieldNames = new Set<string>();
+  files.forEach((file) => collectFieldNames(file, fieldNames));
+  files.forEach((file) => indexFile(names, file, fieldNames));
+  return names;
+}
+
+/**
+ * Find identifiers that break Go naming conventions. Files are grouped by
+ * package so renames of package-level names include references from other
+ * files in the same package. Fields and methods are matched by name, without
+ * resolving the operand's type.
+ */
+export function findNamingViolations(
+  files: GoFile[],
+  options: GoNamingOptions = {},
+): GoNamingViolation[] {
+  const initialisms = new Set(
+    (options.initialisms ?? DEFAULT_GO_INITIALISMS).map((word) =>
+      word.toUpperCase(),
+    ),
+  );
+  const packages = new Map<string, GoFile[]>();
+  for (const file of files) {
+    const key = file.packageName.name;
+    packages.set(key, [...(packages.get(key) ?? []), file]);
+  }
+
+  const violations: GoNamingViolation[] = [];
+  for (const packageFiles of packages.values()) {
+    const names = indexGoPackageNames(packageFiles);
+
+    for (const declaration of names.declarations) {
+      const { name, file } = declaration;
+      if (
+        declaration.kind === "function" &&
+        file.filePath.endsWith("_test.go") &&
+        TEST_FUNCTION.test(name)
+      ) {
+        continue;
+      }
+      const suggestion = suggestGoName(name, options);
+      if (suggestion === name) continue;
+
+      const rule = classify(name, initialisms);
+      const position = file.sourceMap.position(declaration.node.pos);
+      const references = names
+        .sites(declaration)
+        .map((site) => ({
+          filePath: site.file.filePath,
+          ...site.file.sourceMap.position(site.node.pos),
+        }))
+        .sort(
+          (a, b) =>
+            compareCodePoints(a.filePath, b.filePath) ||
+            a.line - b.line ||
+            a.column - b.column,
+        );
+      violations.push({
+        filePath: file.filePath,
+        ...position,
+        name,
+        kind: declaration.kind,
+        rule,
+        message: `${declaration.kind.replace("-", " ")} ${name} ${RULE_MESSAGES[rule]}; rename it to ${suggestion}`,
+        suggestion,
+        references,
+      });
+    }
+  }
+
+  return violations.sort(
+    (a, b) =>
+      compareCodePoints(a.filePath, b.filePath) ||
+      a.line - b.line ||
+      a.column - b.column,
+  );
+}
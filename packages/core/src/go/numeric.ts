@@ -0,0 +1,394 @@
+import {
+  AssignStmt,
+  BinaryExpr,
+  Expr,
+  ForStmt,
+  FuncDecl,
+  GoFile,
+  Ident,
+  Node,
+  RangeStmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Numeric Risks
+ * =============
+ * Fixed-size integers wrap around silently, and integer division drops the
+ * remainder without a word. This pass flags two informational patterns:
+ *
+ * - `integer-overflow`: a function returning a fixed-size integer whose
+ *   result grows on every iteration of a loop bounded by its input, such as
+ *   the running sum `total += v` over a parameter, the product
+ *   `result *= base` or the recurrence `a, b = b, a+b` of
+ *   `CalculateFibonacci`, suggesting a wider type, `math/big` or an overflow
+ *   check
+ * - `integer-division`: integer division whose result is used as a ratio,
+ *   either converted to a float, as in `float64(sum / n)`, or written with
+ *   constant operands where a float is expected, as in
+ *   `var half float64 = 1 / 2`, where it truncates to a whole number
+ *
+ * Growth is only reported where the input bounds the loop: counters stepping
+ * by a constant, loops with constant bounds and accumulators the function
+ * does not return are left alone, as is division whose result stays an
+ * integer, like the midpoint `lo + (hi-lo)/2`. Types come from inference, so
+ * operands of unknown type are never flagged.
+ */
+
+export type GoNumericRule = "integer-overflow" | "integer-division";
+
+export interface GoNumericFinding extends GoFinding {
+  rule: GoNumericRule;
+  /** Name of the function, `Type.Method` for methods */
+  function?: string;
+  /** The growing variable, for `integer-overflow` */
+  variable?: string;
+  /** Its integer type */
+  type?: string;
+}
+
+const INTEGER_TYPES = new Set([
+  "byte",
+  "int",
+  "int8",
+  "int16",
+  "int32",
+  "int64",
+  "rune",
+  "uint",
+  "uint8",
+  "uint16",
+  "uint32",
+  "uint64",
+  "uintptr",
+]);
+
+// No wider fixed-size integer exists on 64-bit platforms
+const WIDEST_TYPES = new Set(["int", "int64", "uint", "uint64", "uintptr"]);
+
+const FLOAT_TYPES = new Set(["float32", "float64"]);
+
+// Operators a recurrence grows its variables with
+const GROWING_OPS = new Set(["+", "*", "<<"]);
+
+const ARITHMETIC_OPS = new Set(["+", "-", "*", "/"]);
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+function isIntLiteral(expr: Expr): boolean {
+  const inner = unparen(expr);
+  return inner.kind === "BasicLit" && inner.litKind === "int";
+}
+
+function functionName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+function article(type: string): string {
+  return /^[aeiou]/.test(type) ? "an" : "a";
+}
+
+function list(names: string[]): string {
+  return names.length === 1
+    ? names[0]
+    : `${names.slice(0, -1).join(", ")} and ${names.at(-1)}`;
+}
+
+// Where a variable first grows, and its type
+interface Growth {
+  site: Node;
+  type: string;
+  loop: ForStmt | RangeStmt;
+}
+
+class NumericAnalyzer {
+  private readonly file: GoFile;
+  private scopes?: GoFunctionScopes;
+  private types: GoTypeInference;
+  private readonly findings: GoNumericFinding[] = [];
+
+  constructor(file: GoFile) {
+    this.file = file;
+    this.types = new GoTypeInference(file);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private resolved(type: string | undefined): string | undefined {
+    return type && (this.types.underlying(type) ?? type);
+  }
+
+  private isInteger(expr: Expr): boolean {
+    return INTEGER_TYPES.has(this.resolved(this.types.typeOf(expr)) ?? "");
+  }
+
+  private isFloat(type: string | undefined): boolean {
+    return FLOAT_TYPES.has(this.resolved(type) ?? "");
+  }
+
+  private variable(expr: Expr): GoVariable | undefined {
+    const ident = unparen(expr);
+    return ident.kind === "Ident"
+      ? this.scopes?.resolved.get(ident)
+      : undefined;
+  }
+
+  // Parameters and receivers the header of a loop mentions
+  private bounds(loop: ForStmt | RangeStmt): string[] {
+    const names = new Set<string>();
+    const header = loop.kind === "ForStmt" ? loop.cond : loop.x;
+    if (!header) return [];
+    inspect(header, (node) => {
+      if (node.kind !== "Ident") return;
+      const variable = this.scopes.resolved.get(node);
+      if (variable?.kind === "param" || variable?.kind === "receiver") {
+        names.add(variable.name);
+      }
+    });
+    return [...names];
+  }
+
+  // Variables declared before a loop that an assignment in it grows
+  private growing(assign: AssignStmt, loop: Node): GoVariable[] {
+    const outside = (variable: GoVariable | undefined) =>
+      variable !== undefined &&
+      (variable.ident.pos < loop.pos || variable.ident.pos >= loop.end);
+    if (assign.tok === "+=" || assign.tok === "*=" || assign.tok === "<<=") {
+      const variable = this.variable(assign.lhs[0]);
+      // A counter stepping by a constant grows no faster than the loop runs
+      if (assign.tok === "+=" && isIntLiteral(assign.rhs[0])) return [];
+      return outside(variable) ? [variable] : [];
+    }
+    if (assign.tok !== "=" || assign.lhs.length !== assign.rhs.length) {
+      return [];
+    }
+    const targets = assign.lhs.map((target) => this.variable(target));
+    const grown = new Set<GoVariable>();
+    assign.rhs.forEach((value, index) => {
+      const expr = unparen(value);
+      if (expr.kind !== "BinaryExpr" || !GROWING_OPS.has(expr.op)) return;
+      if (expr.op === "+" && (isIntLiteral(expr.x) || isIntLiteral(expr.y))) {
+        return;
+      }
+      let recurs = false;
+      inspect(expr, (node) => {
+        if (node.kind !== "Ident") return;
+        if (targets.includes(this.scopes.resolved.get(node))) recurs = true;
+      });
+      if (recurs && outside(targets[index])) grown.add(targets[index]);
+    });
+    // In `a, b = b, a+b`, a takes on the grown value too
+    assign.rhs.forEach((value, index) => {
+      const source = this.variable(value);
+      if (grown.has(source) && outside(targets[index])) {
+        grown.add(targets[index]);
+      }
+    });
+    return [...grown];
+  }
+
+  // Variables a function returns in its integer results
+  private returned(decl: FuncDecl): Set<GoVariable> {
+    const results: { type: Expr; name?: Ident }[] = [];
+    for (const field of decl.type.results?.list ?? []) {
+      if (field.names.length === 0) results.push({ type: field.type });
+      for (const name of field.names) results.push({ type: field.type, name });
+    }
+    const integer = results.map(({ type }) =>
+      INTEGER_TYPES.has(this.resolved(this.text(type)) ?? ""),
+    );
+    const returned = new Set<GoVariable>();
+    if (!integer.includes(true)) return returned;
+    inspect(decl.body, (node) => {
+      if (node.kind === "FuncLit") return false;
+      if (node.kind !== "ReturnStmt") return;
+      if (node.results.length === 0) {
+        results.forEach(({ name }, index) => {
+          const variable = name && this.scopes.resolved.get(name);
+          if (variable && integer[index]) returned.add(variable);
+        });
+        return;
+      }
+      if (node.results.length !== results.length) return;
+      node.results.forEach((result, index) => {
+        if (!integer[index]) return;
+        inspect(result, (inner) => {
+          if (inner.kind !== "Ident") return;
+          const variable = this.scopes.resolved.get(inner);
+          if (variable) returned.add(variable);
+        });
+      });
+    });
+    return returned;
+  }
+
+  private checkGrowth(decl: FuncDecl): void {
+    const returned = this.returned(decl);
+    if (returned.size === 0) return;
+    const growth = new Map<GoVariable, Growth>();
+    inspect(decl.body, (node) => {
+      if (node.kind === "FuncLit") return false;
+      if (node.kind !== "ForStmt" && node.kind !== "RangeStmt") return;
+      if (this.bounds(node).length === 0) return;
+      inspect(node.body, (inner) => {
+        if (inner.kind === "FuncLit") return false;
+        if (inner.kind !== "AssignStmt") return;
+        for (const variable of this.growing(inner, node)) {
+          if (!returned.has(variable) || growth.has(variable)) continue;
+          const type = this.types.typeOfVariable(variable);
+          if (!INTEGER_TYPES.has(this.resolved(type) ?? "")) continue;
+          growth.set(variable, { site: inner, type, loop: node });
+        }
+      });
+    });
+
+    const name = functionName(decl);
+    for (const [variable, { site, type, loop }] of growth) {
+      const bounds = list(this.bounds(loop));
+      const bounded =
+        loop.kind === "RangeStmt" ? `over ${bounds}` : `bounded by ${bounds}`;
+      const signed = !this.resolved(type).startsWith("u");
+      const advice = WIDEST_TYPES.has(this.resolved(type))
+        ? "use math/big, or check for overflow before the arithmetic"
+        : `use ${signed ? "int64" : "uint64"}, or check for overflow before the arithmetic`;
+      this.findings.push({
+        rule: "integer-overflow",
+        severity: "low",
+        filePath: this.file.filePath,
+        ...this.file.sourceMap.position(site.pos),
+        message: `${name} returns ${variable.name}, ${article(type)} ${type} that grows on every iteration of a loop ${bounded}, and can overflow for large inputs; ${advice}`,
+        function: name,
+        variable: variable.name,
+        type,
+      });
+    }
+  }
+
+  // The float type constant operands of a division are used as, if any
+  private floatContext(
+    division: BinaryExpr,
+    parents: Node[],
+    decl?: FuncDecl,
+  ): string | undefined {
+    let node: Node = division;
+    let depth = parents.length - 1;
+    while (parents[depth]?.kind === "ParenExpr") node = parents[depth--];
+    const parent = parents[depth];
+    switch (parent?.kind) {
+      case "ValueSpec": {
+        const type = parent.type && this.text(parent.type);
+        return parent.values.includes(node as Expr) && this.isFloat(type)
+          ? type
+          : undefined;
+      }
+      case "BinaryExpr": {
+        if (!ARITHMETIC_OPS.has(parent.op)) return undefined;
+        const other = parent.x === node ? parent.y : parent.x;
+        const type = this.types.typeOf(other);
+        return this.isFloat(type) ? type : undefined;
+      }
+      case "AssignStmt": {
+        const index = parent.rhs.indexOf(node as Expr);
+        if (index < 0 || parent.tok === ":=") return undefined;
+        if (parent.lhs.length !== parent.rhs.length) return undefined;
+        const type = this.types.typeOf(parent.lhs[index]);
+        return this.isFloat(type) ? type : undefined;
+      }
+      case "ReturnStmt": {
+        const results = (decl?.type.results?.list ?? []).flatMap((field) =>
+          Array(Math.max(field.names.length, 1)).fill(this.text(field.type)),
+        );
+        if (results.length !== parent.results.length) return undefined;
+        const type = results[parent.results.indexOf(node as Expr)];
+        return this.isFloat(type) ? type : undefined;
+      }
+      default:
+        return undefined;
+    }
+  }
+
+  private checkDivisions(root: Node, decl?: FuncDecl): void {
+    inspect(root, (node, parents) => {
+      if (node.kind === "CallExpr") {
+        const fun = unparen(node.fun);
+        const [arg] = node.args;
+        if (fun.kind !== "Ident" || !FLOAT_TYPES.has(fun.name)) return;
+        if (this.scopes?.resolved.has(fun) || node.args.length !== 1) return;
+        const division = unparen(arg);
+        if (division.kind !== "BinaryExpr" || division.op !== "/") return;
+        if (!this.isInteger(division.x) || !this.isInteger(division.y)) return;
+        const [x, y] = [this.text(division.x), this.text(division.y)];
+        this.findings.push({
+          rule: "integer-division",
+          severity: "low",
+          filePath: this.file.filePath,
+          ...this.file.sourceMap.position(node.pos),
+          message: `${this.text(division)} is integer division, so the remainder is lost before the conversion to ${fun.name}; convert the operands instead`,
+          fix: `${fun.name}(${x}) / ${fun.name}(${y})`,
+          ...(decl && { function: functionName(decl) }),
+        });
+        return false;
+      }
+      if (node.kind !== "BinaryExpr" || node.op !== "/") return;
+      if (!isIntLiteral(node.x) || !isIntLiteral(node.y)) return;
+      const type = this.floatContext(node, parents, decl);
+      if (!type) return;
+      const x = this.text(unparen(node.x));
+      const numerator = /^(0|[1-9][0-9_]*)$/.test(x)
+        ? `${x}.0`
+        : `${type}(${x})`;
+      const fix = `${numerator} / ${this.text(node.y)}`;
+      this.findings.push({
+        rule: "integer-division",
+        severity: "low",
+        filePath: this.file.filePath,
+        ...this.file.sourceMap.position(node.pos),
+        message: `${this.text(node)} divides integer constants, which truncates to a whole number where a ${type} is expected; write ${fix}`,
+        fix,
+        ...(decl && { function: functionName(decl) }),
+      });
+    });
+  }
+
+  analyze(): GoNumericFinding[] {
+    for (const decl of this.file.decls) {
+      if (decl.kind !== "FuncDecl") {
+        this.scopes = undefined;
+        this.types = new GoTypeInference(this.file);
+        this.checkDivisions(decl);
+        continue;
+      }
+      if (!decl.body) continue;
+      this.scopes = resolveFunctionScopes(decl);
+      this.types = new GoTypeInference(this.file, this.scopes);
+      this.checkGrowth(decl);
+      this.checkDivisions(decl.body, decl);
+    }
+    return this.findings;
+  }
+}
+
+/**
+ * Find integer results that may overflow as their input grows, and integer
+ * division whose result is used as a ratio
+ */
+export function findNumericRisks(files: GoFile[]): GoNumericFinding[] {
+  return sortFindings(
+    files.flatMap((file) => new NumericAnalyzer(file).analyze()),
+  );
+}
@@ -0,0 +1,75 @@
+import * as fs from "fs";
+import * as path from "path";
+
+/**
+ * Source Overlays
+ * ===============
+ * Contents to use in place of files on disk, keyed by path, in the manner of
+ * `go/packages` overlays. An editor passes its unsaved buffers so analyses
+ * see what the user is typing, and tests can analyze code that was never
+ * written out. An overlaid path wins over the file on disk; a path with no
+ * file on disk is added to its directory. Paths are compared once resolved
+ * against the working directory, so relative and absolute spellings of the
+ * same file match.
+ */
+
+export type GoOverlayEntries =
+  | Map<string, string>
+  | Record<string, string>
+  | Iterable<[string, string]>;
+
+export class GoOverlay {
+  private readonly files = new Map<string, string>();
+
+  constructor(entries: GoOverlayEntries = []) {
+    const pairs: Iterable<[string, string]> =
+      Symbol.iterator in entries ? entries : Object.entries(entries);
+    for (const [filePath, content] of pairs) {
+      this.set(filePath, content);
+    }
+  }
+
+  set(filePath: string, content: string): this {
+    this.files.set(path.resolve(filePath), content);
+    return this;
+  }
+
+  delete(filePath: string): boolean {
+    return this.files.delete(path.resolve(filePath));
+  }
+
+  has(filePath: string): boolean {
+    return this.files.has(path.resolve(filePath));
+  }
+
+  get(filePath: string): string | undefined {
+    return this.files.get(path.resolve(filePath));
+  }
+
+  /**
+   * Names of the overlaid files directly inside a directory
+   */
+  fileNames(directory: string): string[] {
+    const resolved = path.resolve(directory);
+    return [...this.files.keys()]
+      .filter((filePath) => path.dirname(filePath) === resolved)
+      .map((filePath) => path.basename(filePath))
+      .sort();
+  }
+
+  /**
+   * The overlaid contents of a file, or the file read from disk
+   */
+  async readFile(filePath: string): Promise<string> {
+    return this.get(filePath) ?? fs.promises.readFile(filePath, "utf-8");
+  }
+}
+
+/**
+ * An overlay from entries, or the overlay itself when one is given
+ */
+export function goOverlay(
+  entries: GoOverlay | GoOverlayEntries | undefined,
+): GoOverlay {
+  return entries instanceof GoOverlay ? entries : new GoOverlay(entries);
+}
@@ -0,0 +1,525 @@
+import * as fs from "fs";
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GoConstantSymbol } from "./constants.js";
+import { GoOverlay, GoOverlayEntries, goOverlay } from "./overlay.js";
+import { parseGoFile } from "./parser.js";
+import {
+  extractGoFileSymbols,
+  GoFileSymbols,
+  GoFunctionSymbol,
+  GoTypeSymbol,
+  groupMethods,
+} from "./symbols.js";
+
+/**
+ * Go Packages
+ * ===========
+ * Loads the `.go` files of a directory as one package, the way `go build`
+ * selects them: files excluded by `//go:build` lines (or legacy `// +build`
+ * lines) and by `_GOOS`/`_GOARCH` file name suffixes are skipped, `_test.go`
+ * files are kept apart, and symbols are resolved across the remaining files
+ * so a method in `b.go` is attached to the type declared in `a.go`.
+ */
+
+/**
+ * The target a package is loaded for
+ */
+export interface GoBuildContext {
+  goos: string;
+  goarch: string;
+  /** Extra tags satisfied, as passed to `go build -tags` */
+  tags: string[];
+  cgo: boolean;
+  /**
+   * Minor version of the Go release, satisfying `go1.N` tags up to it. When
+   * unset every release tag is satisfied.
+   */
+  goMinorVersion?: number;
+}
+
+export const KNOWN_GOOS = new Set([
+  "aix",
+  "android",
+  "darwin",
+  "dragonfly",
+  "freebsd",
+  "hurd",
+  "illumos",
+  "ios",
+  "js",
+  "linux",
+  "nacl",
+  "netbsd",
+  "openbsd",
+  "plan9",
+  "solaris",
+  "wasip1",
+  "windows",
+  "zos",
+]);
+
+export const KNOWN_GOARCH = new Set([
+  "386",
+  "amd64",
+  "amd64p32",
+  "arm",
+  "arm64",
+  "arm64be",
+  "armbe",
+  "loong64",
+  "mips",
+  "mips64",
+  "mips64le",
+  "mips64p32",
+  "mips64p32le",
+  "mipsle",
+  "ppc",
+  "ppc64",
+  "ppc64le",
+  "riscv",
+  "riscv64",
+  "s390",
+  "s390x",
+  "sparc",
+  "sparc64",
+  "wasm",
+]);
+
+// Operating systems satisfying the `unix` tag, as listed in go/build
+const UNIX_GOOS = new Set([
+  "aix",
+  "android",
+  "darwin",
+  "dragonfly",
+  "freebsd",
+  "hurd",
+  "illumos",
+  "ios",
+  "linux",
+  "netbsd",
+  "openbsd",
+  "solaris",
+]);
+
+// Operating systems that also build files meant for another one
+const GOOS_IMPLIES: Record<string, string> = {
+  android: "linux",
+  illumos: "solaris",
+  ios: "darwin",
+};
+
+const NODE_GOOS: Record<string, string> = {
+  win32: "windows",
+  sunos: "solaris",
+};
+
+const NODE_GOARCH: Record<string, string> = {
+  x64: "amd64",
+  ia32: "386",
+  x32: "386",
+};
+
+/**
+ * Build context for the machine running the analyzer, with cgo enabled as
+ * `go build` does by default for native builds
+ */
+export function defaultGoBuildContext(): GoBuildContext {
+  return {
+    goos: NODE_GOOS[process.platform] ?? process.platform,
+    goarch: NODE_GOARCH[process.arch] ?? process.arch,
+    tags: [],
+    cgo: true,
+  };
+}
+
+/**
+ * A parsed build constraint expression
+ */
+export type GoBuildExpr =
+  | { kind: "tag"; name: string }
+  | { kind: "not"; x: GoBuildExpr }
+  | { kind: "and" | "or"; x: GoBuildExpr; y: GoBuildExpr };
+
+export class GoBuildConstraintError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoBuildConstraintError";
+  }
+}
+
+/**
+ * Parse the expression of a `//go:build` line
+ */
+export function parseBuildExpr(text: string): GoBuildExpr {
+  const tokens = text.match(/&&|\|\||[!()]|[^\s&|!()]+|\S/g) ?? [];
+  let index = 0;
+
+  const fail = (message: string): never => {
+    throw new GoBuildConstraintError(`${message} in "${text.trim()}"`);
+  };
+
+  const parseOr = (): GoBuildExpr => {
+    let x = parseAnd();
+    while (tokens[index] === "||") {
+      index++;
+      x = { kind: "or", x, y: parseAnd() };
+    }
+    return x;
+  };
+
+  const parseAnd = (): GoBuildExpr => {
+    let x = parseNot();
+    while (tokens[index] === "&&") {
+      index++;
+      x = { kind: "and", x, y: parseNot() };
+    }
+    return x;
+  };
+
+  const parseNot = (): GoBuildExpr => {
+    const token = tokens[index++];
+    if (token === "!") {
+      return { kind: "not", x: parseNot() };
+    }
+    if (token === "(") {
+      const x = parseOr();
+      if (tokens[index++] !== ")") fail("missing )");
+      return x;
+    }
+    if (token === undefined) return fail("unexpected end of expression");
+    if (!/^[\w.]+$/.test(token)) return fail(`unexpected ${token}`);
+    return { kind: "tag", name: token };
+  };
+
+  const expr = parseOr();
+  if (index < tokens.length) fail(`unexpected ${tokens[index]}`);
+  return expr;
+}
+
+/**
+ * Convert the lines of legacy `// +build` constraints to an expression: terms
+ * on a line are ORed, comma-separated terms ANDed, and the lines ANDed.
+ */
+function plusBuildExpr(lines: string[]): GoBuildExpr {
+  const and = (x: GoBuildExpr, y: GoBuildExpr): GoBuildExpr => ({
+    kind: "and",
+    x,
+    y,
+  });
+  const or = (x: GoBuildExpr, y: GoBuildExpr): GoBuildExpr => ({
+    kind: "or",
+    x,
+    y,
+  });
+  const term = (text: string): GoBuildExpr =>
+    text.startsWith("!")
+      ? { kind: "not", x: { kind: "tag", name: text.slice(1) } }
+      : { kind: "tag", name: text };
+
+  return lines
+    .map((line) =>
+      line
+        .split(/\s+/)
+        .filter(Boolean)
+        .map((option) => option.split(",").map(term).reduce(and))
+        .reduce(or),
+    )
+    .reduce(and);
+}
+
+/**
+ * Find the build constraint in a file's header, the comments before the
+ * package clause. A `//go:build` line takes precedence over `// +build`
+ * lines, as in Go 1.17 and later. Returns undefined when there is none.
+ */
+export function parseBuildConstraint(source: string): GoBuildExpr | undefined {
+  const plusBuild: string[] = [];
+  let inBlock = false;
+
+  for (const rawLine of source.split("\n")) {
+    const line = rawLine.trim();
+    if (inBlock) {
+      if (line.includes("*/")) inBlock = false;
+      continue;
+    }
+    if (line === "") continue;
+    if (line.startsWith("/*")) {
+      inBlock = !line.includes("*/", 2);
+      continue;
+    }
+    if (!line.startsWith("//")) break;
+
+    const goBuild = /^\/\/go:build\s(.*)$/.exec(line);
+    if (goBuild) {
+      return parseBuildExpr(goBuild[1]);
+    }
+    const legacy = /^\/\/\s*\+build\s(.*)$/.exec(line);
+    if (legacy) {
+      plusBuild.push(legacy[1]);
+    }
+  }
+
+  return plusBuild.length > 0 ? plusBuildExpr(plusBuild) : undefined;
+}
+
+/**
+ * Whether a single tag is satisfied by the build context
+ */
+export function matchBuildTag(name: string, context: GoBuildContext): boolean {
+  if (name === context.goos || name === context.goarch) return true;
+  if (GOOS_IMPLIES[context.goos] === name) return true;
+  if (name === "unix") return UNIX_GOOS.has(context.goos);
+  if (name === "cgo") return context.cgo;
+  if (name === "gc") return true;
+  const release = /^go1\.(\d+)$/.exec(name);
+  if (release) {
+    return (
+      context.goMinorVersion === undefined ||
+      Number(release[1]) <= context.goMinorVersion
+    );
+  }
+  return context.tags.includes(name);
+}
+
+export function evaluateBuildExpr(
+  expr: GoBuildExpr,
+  context: GoBuildContext,
+): boolean {
+  switch (expr.kind) {
+    case "tag":
+      return matchBuildTag(expr.name, context);
+    case "not":
+      return !evaluateBuildExpr(expr.x, context);
+    case "and":
+      return (
+        evaluateBuildExpr(expr.x, context) && evaluateBuildExpr(expr.y, context)
+      );
+    default:
+      return (
+        evaluateBuildExpr(expr.x, context) || evaluateBuildExpr(expr.y, context)
+      );
+  }
+}
+
+/**
+ * Whether a file name's `_GOOS`, `_GOARCH` or `_GOOS_GOARCH` suffix, if any,
+ * matches the build context. Unknown suffixes never exclude a file.
+ */
+export function matchFileName(
+  fileName: string,
+  context: GoBuildContext,
+): boolean {
+  const stem = path.basename(fileName, ".go").replace(/_test$/, "");
+  const parts = stem.split("_").slice(1);
+  const n = parts.length;
+  if (
+    n >= 2 &&
+    KNOWN_GOOS.has(parts[n - 2]) &&
+    KNOWN_GOARCH.has(parts[n - 1])
+  ) {
+    return (
+      matchBuildTag(parts[n - 2], context) && parts[n - 1] === context.goarch
+    );
+  }
+  if (n >= 1 && KNOWN_GOOS.has(parts[n - 1])) {
+    return matchBuildTag(parts[n - 1], context);
+  }
+  if (n >= 1 && KNOWN_GOARCH.has(parts[n - 1])) {
+    return parts[n - 1] === context.goarch;
+  }
+  return true;
+}
+
+/**
+ * A file `go build` would not compile for the build context
+ */
+export interface GoIgnoredFile {
+  filePath: string;
+  reason: string;
+}
+
+/**
+ * Symbols of a package, with methods attached to their types across files
+ */
+export interface GoPackageSymbols {
+  packageName: string;
+  files: GoFileSymbols[];
+  functions: GoFunctionSymbol[];
+  methods: GoFunctionSymbol[];
+  types: GoTypeSymbol[];
+  constants: GoConstantSymbol[];
+}
+
+export interface GoPackage {
+  directory: string;
+  name: string;
+  /** Files compiled into the package, sorted by name */
+  files: GoFile[];
+  /** `_test.go` files declaring the same package */
+  testFiles: GoFile[];
+  /** `_test.go` files of the external `<name>_test` package */
+  externalTestFiles: GoFile[];
+  ignored: GoIgnoredFile[];
+  /** Symbols of {@link files}; test files are not included */
+  symbols: GoPackageSymbols;
+}
+
+export interface GoPackageLoadOptions {
+  /** Overrides fields of {@link defaultGoBuildContext} */
+  context?: Partial<GoBuildContext>;
+  /** Whether to read `_test.go` files (default: true) */
+  includeTests?: boolean;
+  /** Contents read in place of the files on disk, and files added to it */
+  overlay?: GoOverlay | GoOverlayEntries;
+}
+
+export class GoPackageError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoPackageError";
+  }
+}
+
+export interface GoPackageGroupOptions {
+  /**
+   * Group an external test package (`foo_test`) with the package it tests
+   * (default: false)
+   */
+  externalTests?: boolean;
+}
+
+/**
+ * Files grouped by package: same directory, same package clause
+ */
+export function groupGoPackages(
+  files: GoFile[],
+  options: GoPackageGroupOptions = {},
+): GoFile[][] {
+  const groups = new Map<string, GoFile[]>();
+  for (const file of files) {
+    let name = file.packageName.name;
+    if (options.externalTests) name = name.replace(/_test$/, "");
+    const key = `${path.dirname(file.filePath)}\0${name}`;
+    if (!groups.has(key)) groups.set(key, []);
+    groups.get(key).push(file);
+  }
+  return [...groups.values()];
+}
+
+/**
+ * Extract the symbols of files belonging to one package. Methods declared in
+ * one file are attached to types declared in any of the others.
+ */
+export function goPackageSymbols(files: GoFile[]): GoPackageSymbols {
+  const perFile = files.map((file) => extractGoFileSymbols(file));
+  const types = perFile.flatMap((symbols) => symbols.types);
+  const methods = perFile.flatMap((symbols) => symbols.methods);
+  groupMethods(types, methods);
+
+  return {
+    packageName: files[0]?.packageName.name ?? "",
+    files: perFile,
+    functions: perFile.flatMap((symbols) => symbols.functions),
+    methods,
+    types,
+    constants: perFile.flatMap((symbols) => symbols.constants),
+  };
+}
+
+/**
+ * Load the Go package in a directory for a build context. Throws a
+ * {@link GoPackageError} when the directory has no buildable Go files or the
+ * files declare more than one package.
+ */
+export async function loadGoPackage(
+  directory: string,
+  options: GoPackageLoadOptions = {},
+): Promise<GoPackage> {
+  const context = { ...defaultGoBuildContext(), ...options.context };
+  const includeTests = options.includeTests ?? true;
+  const overlay = goOverlay(options.overlay);
+
+  const overlaid = overlay.fileNames(directory);
+  let entries: fs.Dirent[] = [];
+  try {
+    entries = await fs.promises.readdir(directory, { withFileTypes: true });
+  } catch (error) {
+    // A directory can exist only in the overlay
+    if (error.code !== "ENOENT" || overlaid.length === 0) throw error;
+  }
+  const onDisk = entries
+    .filter((entry) => entry.isFile())
+    .map((entry) => entry.name);
+  const names = [...new Set([...onDisk, ...overlaid])]
+    .filter((name) => name.endsWith(".go"))
+    // Go tools skip files starting with `_` or `.`
+    .filter((name) => !name.startsWith("_") && !name.startsWith("."))
+    .sort();
+
+  const files: GoFile[] = [];
+  const testFiles: GoFile[] = [];
+  const ignored: GoIgnoredFile[] = [];
+
+  for (const name of names) {
+    const filePath = path.join(directory, name);
+    const isTest = name.endsWith("_test.go");
+    if (isTest && !includeTests) continue;
+
+    if (!matchFileName(name, context)) {
+      ignored.push({ filePath, reason: "file name suffix excludes it" });
+      continue;
+    }
+    const source = await overlay.readFile(filePath);
+    const constraint = parseBuildConstraint(source);
+    if (constraint && !evaluateBuildExpr(constraint, context)) {
+      ignored.push({ filePath, reason: "build constraints exclude it" });
+      continue;
+    }
+    (isTest ? testFiles : files).push(parseGoFile(source, filePath));
+  }
+
+  const packageName =
+    files[0]?.packageName.name ??
+    testFiles[0]?.packageName.name.replace(/_test$/, "");
+  if (packageName === undefined) {
+    throw new GoPackageError(`no buildable Go source files in ${directory}`);
+  }
+
+  const mismatched = files.find(
+    (file) => file.packageName.name !== packageName,
+  );
+  if (mismatched) {
+    throw new GoPackageError(
+      `found packages ${packageName} (${path.basename(files[0].filePath)}) ` +
+        `and ${mismatched.packageName.name} ` +
+        `(${path.basename(mismatched.filePath)}) in ${directory}`,
+    );
+  }
+
+  const internalTests: GoFile[] = [];
+  const externalTests: GoFile[] = [];
+  for (const file of testFiles) {
+    const name = file.packageName.name;
+    if (name === packageName) {
+      internalTests.push(file);
+    } else if (name === `${packageName}_test`) {
+      externalTests.push(file);
+    } else {
+      throw new GoPackageError(
+        `found packages ${packageName} and ${name} ` +
+          `(${path.basename(file.filePath)}) in ${directory}`,
+      );
+    }
+  }
+
+  return {
+    directory,
+    name: packageName,
+    files,
+    testFiles: internalTests,
+    externalTestFiles: externalTests,
+    ignored,
+    symbols: { ...goPackageSymbols(files), packageName },
+  };
+}
@@ -0,0 +1,403 @@
+import {
+  CallExpr,
+  Expr,
+  FuncDecl,
+  GoFile,
+  Node,
+  inspect,
+} from "./ast.js";
+import { buildGoCallGraph, callGraphId, GoCallGraph } from "./callgraph.js";
+import { compareCodePoints, GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference, zeroValue } from "./infer.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import { goSignature } from "./signature.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Go Panics
+ * =========
+ * Flags `panic` calls that report conditions a caller could handle, such as
+ * invalid input, and proposes returning an `error` instead. A panic is
+ * treated as recoverable when its value is a constructed error or a string
+ * message and the panicking function can be reached from an exported
+ * function. Panics in `init`, in `Must*` helpers (which panic by convention),
+ * with messages naming a programming error ("unreachable", "invariant") or
+ * with values of other types are classified but not reported. Panics inside
+ * function literals, such as a deferred re-panic, are not considered.
+ */
+
+/**
+ * A `panic` call and how it was classified
+ */
+export interface GoPanicSite {
+  filePath: string;
+  line: number;
+  column: number;
+  /** Qualified name of the enclosing function */
+  function: string;
+  recoverable: boolean;
+  /** Why the panic is or is not considered recoverable */
+  reason: string;
+}
+
+/**
+ * A call site that must check the error once the callee returns one
+ */
+export interface GoPanicCallerFix {
+  filePath: string;
+  line: number;
+  column: number;
+  /** Qualified name of the calling function */
+  function: string;
+  fix: string;
+}
+
+export interface GoPanicFinding extends GoFinding {
+  rule: "panic-instead-of-error";
+  /** Qualified name of the panicking function */
+  function: string;
+  /** Declaration line with an error result added */
+  signature: string;
+  callers: GoPanicCallerFix[];
+}
+
+// Messages that describe a bug in the program rather than bad input
+const INVARIANT_MESSAGE =
+  /unreachable|impossible|invariant|not implemented|should never|can(?:no|')t happen|programming error|\bbug\b/i;
+
+const ERROR_NAME = /^err([A-Z0-9_]|$)|Err$/;
+
+interface PanicCall {
+  file: GoFile;
+  decl: FuncDecl;
+  id: string;
+  call: CallExpr;
+  /** What the panic value is, when it is an error or a message */
+  value?: "error" | "string";
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+function isBuiltinPanic(call: CallExpr, scopes: GoFunctionScopes): boolean {
+  return (
+    call.fun.kind === "Ident" &&
+    call.fun.name === "panic" &&
+    !scopes.resolved.has(call.fun) &&
+    call.args.length === 1
+  );
+}
+
+class PanicAnalyzer {
+  private readonly graph: GoCallGraph;
+  private readonly panics: PanicCall[] = [];
+  private readonly decls = new Map<string, { file: GoFile; decl: FuncDecl }>();
+  /** Exported entry point each reachable function is first reached from */
+  private readonly roots = new Map<string, string>();
+
+  constructor(files: GoFile[]) {
+    this.graph = buildGoCallGraph(files);
+    for (const file of files) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "FuncDecl" || !decl.body) continue;
+        const id = callGraphId(file.packageName.name, {
+          qualifiedName: qualifiedName(decl),
+        });
+        this.decls.set(id, { file, decl });
+        this.collect(file, decl, id);
+      }
+    }
+    this.reach();
+  }
+
+  private text(file: GoFile, node: Node): string {
+    return file.source.slice(node.pos, node.end);
+  }
+
+  private collect(file: GoFile, decl: FuncDecl, id: string): void {
+    const scopes = resolveFunctionScopes(decl);
+    const types = new GoTypeInference(file, scopes);
+    inspect(decl.body, (node) => {
+      // Panics in function literals run wherever the literal is called
+      if (node.kind === "FuncLit") return false;
+      if (node.kind === "CallExpr" && isBuiltinPanic(node, scopes)) {
+        const value = this.valueKind(file, node.args[0], types);
+        this.panics.push({ file, decl, id, call: node, value });
+      }
+    });
+  }
+
+  private valueKind(
+    file: GoFile,
+    expr: Expr,
+    types: GoTypeInference,
+  ): PanicCall["value"] {
+    const inner = expr.kind === "ParenExpr" ? expr.x : expr;
+    if (inner.kind === "BasicLit") {
+      return inner.litKind === "string" ? "string" : undefined;
+    }
+    if (inner.kind === "BinaryExpr" && inner.op === "+") {
+      const operands = [inner.x, inner.y];
+      if (operands.some((operand) => this.valueKind(file, operand, types))) {
+        return "string";
+      }
+    }
+    if (inner.kind === "CallExpr") {
+      const callee = this.text(file, inner.fun);
+      if (callee === "fmt.Sprintf") return "string";
+      if (callee === "errors.New" || callee === "fmt.Errorf") return "error";
+    }
+    const literal =
+      inner.kind === "UnaryExpr" && inner.op === "&" ? inner.x : inner;
+    if (literal.kind === "CompositeLit" && literal.type) {
+      if (/Err(or)?$/.test(baseTypeName(literal.type).name)) return "error";
+    }
+    const type = types.typeOf(inner);
+    if (type === "string" || type === "error") return type;
+    if (type === undefined && inner.kind === "Ident") {
+      return ERROR_NAME.test(inner.name) ? "error" : undefined;
+    }
+    return undefined;
+  }
+
+  /**
+   * Walk the call graph from every exported function outside tests
+   */
+  private reach(): void {
+    const queue: string[] = [];
+    for (const node of this.graph.nodes.values()) {
+      const name = node.symbol.name;
+      if (
+        node.symbol.isExported &&
+        !node.filePath.endsWith("_test.go") &&
+        name !== "init"
+      ) {
+        this.roots.set(node.id, node.id);
+        queue.push(node.id);
+      }
+    }
+    while (queue.length > 0) {
+      const id = queue.shift();
+      for (const callee of this.graph.callees.get(id) ?? []) {
+        if (this.roots.has(callee)) continue;
+        if (this.graph.nodes.get(callee)?.symbol.name === "init") continue;
+        this.roots.set(callee, this.roots.get(id));
+        queue.push(callee);
+      }
+    }
+  }
+
+  /**
+   * Reason a panic is left alone, or undefined when it is recoverable
+   */
+  private excluded(panic: PanicCall): string | undefined {
+    const { decl, call, file } = panic;
+    const name = decl.name.name;
+    if (name === "init" && !decl.recv) {
+      return "panics during package initialization";
+    }
+    if (/^must[A-Z]|^Must/.test(name)) {
+      return "Must functions panic by convention";
+    }
+    if (!panic.value) {
+      return "the panic value is not an error or a message";
+    }
+    if (INVARIANT_MESSAGE.test(this.text(file, call.args[0]))) {
+      return "the message describes a programming error";
+    }
+    if (!this.roots.has(panic.id)) {
+      return "not reachable from an exported function";
+    }
+    return undefined;
+  }
+
+  sites(): GoPanicSite[] {
+    return this.panics.map((panic) => {
+      const reason = this.excluded(panic);
+      return {
+        filePath: panic.file.filePath,
+        ...panic.file.sourceMap.position(panic.call.pos),
+        function: qualifiedName(panic.decl),
+        recoverable: reason === undefined,
+        reason:
+          reason ??
+          `panics with ${panic.value === "error" ? "an error" : "a message"} a caller could handle`,
+      };
+    });
+  }
+
+  /**
+   * The value to return in place of the panic
+   */
+  private errorValue(panic: PanicCall): string {
+    const arg = panic.call.args[0];
+    const text = this.text(panic.file, arg);
+    if (panic.value === "error") return text;
+    if (
+      arg.kind === "CallExpr" &&
+      this.text(panic.file, arg.fun) === "fmt.Sprintf"
+    ) {
+      const args = arg.args.map((expr) => this.text(panic.file, expr));
+      return `fmt.Errorf(${args.join(", ")})`;
+    }
+    return `errors.New(${text})`;
+  }
+
+  private returnsError(file: GoFile, decl: FuncDecl): boolean {
+    return goSignature(file, decl.type).results.at(-1)?.type === "error";
+  }
+
+  private zeros(file: GoFile, decl: FuncDecl, types: GoTypeInference) {
+    const results = goSignature(file, decl.type).results;
+    const values = this.returnsError(file, decl)
+      ? results.slice(0, -1)
+      : results;
+    return values.map((result) =>
+      zeroValue(result.type, (name) => types.underlying(name)),
+    );
+  }
+
+  private proposedSignature(file: GoFile, decl: FuncDecl): string {
+    const { params, results } = decl.type;
+    if (this.returnsError(file, decl)) {
+      return file.source.slice(decl.pos, results.end);
+    }
+    const prefix = file.source.slice(decl.pos, params.end);
+    const fields = results?.list ?? [];
+    if (fields.length === 0) return `${prefix} error`;
+    if (fields.some((field) => field.names.length > 0)) {
+      const named = fields.map((field) => this.text(file, field));
+      return `${prefix} (${named.join(", ")}, err error)`;
+    }
+    const types = goSignature(file, decl.type).results.map(
+      (result) => result.type,
+    );
+    return `${prefix} (${[...types, "error"].join(", ")})`;
+  }
+
+  /**
+   * How a caller hands the error on: returned when the caller can return
+   * an error, otherwise left for the author to handle
+   */
+  private propagate(file: GoFile, caller: FuncDecl): string {
+    if (!this.returnsError(file, caller)) return "// handle err";
+    const types = new GoTypeInference(file);
+    return `return ${[...this.zeros(file, caller, types), "err"].join(", ")}`;
+  }
+
+  private callerFixes(panic: PanicCall): GoPanicCallerFix[] {
+    const fixes: GoPanicCallerFix[] = [];
+    const name = panic.decl.name.name;
+    const method = panic.decl.recv !== undefined;
+    const resultCount = goSignature(panic.file, panic.decl.type).results
+      .length;
+    const alreadyErrors = this.returnsError(panic.file, panic.decl);
+
+    for (const callerId of this.graph.callers.get(panic.id) ?? []) {
+      const entry = this.decls.get(callerId);
+      if (!entry) continue;
+      const { file, decl } = entry;
+      const scopes = resolveFunctionScopes(decl);
+      inspect(decl.body, (node, parents) => {
+        if (node.kind !== "CallExpr") return;
+        const { fun } = node;
+        const matches = method
+          ? fun.kind === "SelectorExpr" && fun.sel.name === name
+          : (fun.kind === "Ident" &&
+              fun.name === name &&
+              !scopes.resolved.has(fun)) ||
+            (fun.kind === "SelectorExpr" &&
+              fun.sel.name === name &&
+              fun.x.kind === "Ident" &&
+              !scopes.resolved.has(fun.x));
+        if (!matches) return;
+
+        const call = this.text(file, node);
+        const handle = `err != nil {\n\t${this.propagate(file, decl)}\n}`;
+        const parent = parents.at(-1);
+        const blanks = Array.from(
+          { length: alreadyErrors ? resultCount - 1 : resultCount },
+          () => "_",
+        );
+        let fix: string;
+        if (parent?.kind === "ExprStmt") {
+          fix = `if ${[...blanks, "err"].join(", ")} := ${call}; ${handle}`;
+        } else if (
+          parent?.kind === "AssignStmt" &&
+          parent.rhs.length === 1 &&
+          parent.rhs[0] === node
+        ) {
+          const lhs = parent.lhs.map((expr) => this.text(file, expr));
+          const names = alreadyErrors ? lhs.slice(0, -1) : lhs;
+          const assign = `${[...names, "err"].join(", ")} ${parent.tok} ${call}`;
+          const declare = parent.tok === "=" ? "var err error\n" : "";
+          fix = `${declare}${assign}\nif ${handle}`;
+        } else {
+          const values = blanks.map((_, index) =>
+            blanks.length === 1 ? "v" : `v${index + 1}`,
+          );
+          fix = `${[...values, "err"].join(", ")} := ${call}\nif ${handle}\n// use ${values.join(", ")} in place of the call`;
+        }
+        fixes.push({
+          filePath: file.filePath,
+          ...file.sourceMap.position(node.pos),
+          function: qualifiedName(decl),
+          fix,
+        });
+      });
+    }
+    return fixes.sort(
+      (a, b) =>
+        compareCodePoints(a.filePath, b.filePath) ||
+        a.line - b.line ||
+        a.column - b.column,
+    );
+  }
+
+  findings(): GoPanicFinding[] {
+    const findings: GoPanicFinding[] = [];
+    for (const panic of this.panics) {
+      if (this.excluded(panic) !== undefined) continue;
+      const { file, decl } = panic;
+      const fn = qualifiedName(decl);
+      const root = this.roots.get(panic.id);
+      const via =
+        root === panic.id
+          ? ""
+          : ` and is reachable from exported ${this.graph.nodes.get(root).symbol.qualifiedName}`;
+      const types = new GoTypeInference(file, resolveFunctionScopes(decl));
+      const values = [...this.zeros(file, decl, types), this.errorValue(panic)];
+      findings.push({
+        rule: "panic-instead-of-error",
+        severity: isExportedName(decl.name.name) ? "medium" : "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(panic.call.pos),
+        message: `${fn} panics on a condition its callers could handle${via}; return an error instead`,
+        fix: `return ${values.join(", ")}`,
+        function: fn,
+        signature: this.proposedSignature(file, decl),
+        callers: this.callerFixes(panic),
+      });
+    }
+    return sortFindings(findings);
+  }
+}
+
+/**
+ * Classify every `panic` call in the files as recoverable or not
+ */
+export function classifyPanics(files: GoFile[]): GoPanicSite[] {
+  return new PanicAnalyzer(files).sites();
+}
+
+/**
+ * Find panics that should be error returns, with the signature change and
+ * the checks each caller needs
+ */
+export function findPanicsInsteadOfErrors(files: GoFile[]): GoPanicFinding[] {
+  return new PanicAnalyzer(files).findings();
+}
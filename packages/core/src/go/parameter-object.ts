@@ -0,0 +1,423 @@
+import { applyEdits, TextEdit } from "../diff.js";
+import { CallExpr, Field, FuncDecl, GoFile, Node, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { SkippedCallSite } from "./inline-function.js";
+import { suggestGoName } from "./naming.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { goFunctionSize } from "./size.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Introduce Parameter Object
+ * ==========================
+ * Flags functions taking more positional parameters than a limit, and
+ * replaces those parameters with a single struct. The struct is declared
+ * right before the function with one field per parameter, in the original
+ * order; a trailing variadic parameter stays positional. The body reads the
+ * fields instead of the parameters and calls in the file pass a keyed struct
+ * literal. Exported functions get an exported struct with exported fields, so
+ * callers in other packages can build it.
+ */
+
+/** Functions with more parameters than this are reported */
+export const DEFAULT_MAX_PARAMETERS = 5;
+
+export interface GoParameterCountOptions {
+  /** Highest parameter count not reported (default: 5) */
+  maxParameters?: number;
+}
+
+export interface GoWideSignatureFinding extends GoFinding {
+  rule: "too-many-parameters";
+  /** `Type.Method` for methods, the bare name for functions */
+  name: string;
+  parameters: number;
+  /** Lines of code in the function, to weigh the finding during triage */
+  codeLines: number;
+}
+
+export interface ParameterObjectOptions {
+  /** Function to rewrite: its name, or `Type.Method` for a method */
+  name: string;
+  /** Name of the new struct (default: the function name + `Params`) */
+  structName?: string;
+  /** Name of the new parameter (default: `params`) */
+  paramName?: string;
+}
+
+export interface ParameterObjectField {
+  /** The parameter the field replaces */
+  param: string;
+  field: string;
+  type: string;
+}
+
+export interface ParameterObjectResult extends GoRefactorResult {
+  structName: string;
+  paramName: string;
+  fields: ParameterObjectField[];
+  /** Lines of the calls that now pass the struct */
+  updated: number[];
+  /** References to the function that still need updating by hand */
+  skipped: SkippedCallSite[];
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+function parameterCount(decl: FuncDecl): number {
+  return decl.type.params.list.reduce(
+    (count, field) => count + Math.max(field.names.length, 1),
+    0,
+  );
+}
+
+function defaultStructName(decl: FuncDecl): string {
+  return `${decl.name.name}Params`;
+}
+
+function exportedField(name: string): string {
+  return suggestGoName(name.charAt(0).toUpperCase() + name.slice(1));
+}
+
+/**
+ * The struct declaration holding the grouped parameters, with field types
+ * aligned the way gofmt aligns them
+ */
+function structDeclaration(
+  name: string,
+  owner: string,
+  fields: ParameterObjectField[],
+): string {
+  const width = Math.max(...fields.map((field) => field.field.length));
+  return [
+    `// ${name} holds the parameters of ${owner}`,
+    `type ${name} struct {`,
+    ...fields.map(
+      (field) => `\t${field.field.padEnd(width)} ${field.type}`.trimEnd(),
+    ),
+    "}",
+  ].join("\n");
+}
+
+/**
+ * Find functions and methods with more parameters than the limit
+ */
+export function findWideSignatures(
+  files: GoFile[],
+  options: GoParameterCountOptions = {},
+): GoWideSignatureFinding[] {
+  const limit = options.maxParameters ?? DEFAULT_MAX_PARAMETERS;
+  const findings: GoWideSignatureFinding[] = [];
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl") continue;
+      const parameters = parameterCount(decl);
+      if (parameters <= limit) continue;
+      const name = qualifiedName(decl);
+      findings.push({
+        rule: "too-many-parameters",
+        severity: "low",
+        filePath: file.filePath,
+        ...file.sourceMap.position(decl.name.pos),
+        message: `${name} takes ${parameters} parameters, more than ${limit}; group them in a ${defaultStructName(decl)} struct`,
+        name,
+        parameters,
+        codeLines: goFunctionSize(file, decl).codeLines,
+      });
+    }
+  }
+  return sortFindings(findings);
+}
+
+class ParameterObjectIntroducer {
+  private readonly file: GoFile;
+  private readonly options: ParameterObjectOptions;
+  private readonly parents = new Map<Node, Node>();
+  private decl: FuncDecl;
+  private grouped: Field[] = [];
+  private variadic?: Field;
+
+  constructor(file: GoFile, options: ParameterObjectOptions) {
+    this.file = file;
+    this.options = options;
+    inspect(file, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+    });
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private get name(): string {
+    return this.options.name;
+  }
+
+  private locate(): void {
+    this.decl = this.file.decls.find(
+      (decl): decl is FuncDecl =>
+        decl.kind === "FuncDecl" && qualifiedName(decl) === this.name,
+    );
+    const { decl } = this;
+    if (!decl) {
+      throw new GoRefactorError(`${this.name} is not declared in the file`);
+    }
+    if (!decl.body) {
+      throw new GoRefactorError(`${this.name} has no body to rewrite`);
+    }
+    const recv = decl.recv?.list[0]?.type;
+    const base = recv?.kind === "StarExpr" ? recv.x : recv;
+    if (
+      decl.type.typeParams ||
+      base?.kind === "IndexExpr" ||
+      base?.kind === "IndexListExpr"
+    ) {
+      throw new GoRefactorError(
+        `Introducing a parameter object for generic function ${this.name} is not supported`,
+      );
+    }
+
+    const fields = decl.type.params.list;
+    if (fields.at(-1)?.type.kind === "Ellipsis") {
+      this.variadic = fields.at(-1);
+    }
+    this.grouped = fields.filter((field) => field !== this.variadic);
+    for (const field of this.grouped) {
+      if (field.names.length === 0) {
+        throw new GoRefactorError(
+          `${this.name} has an unnamed parameter, which cannot become a field`,
+        );
+      }
+      if (field.names.some((ident) => ident.name === "_")) {
+        throw new GoRefactorError(
+          `${this.name} has a blank parameter, which cannot become a field`,
+        );
+      }
+    }
+    if (this.grouped.flatMap((field) => field.names).length < 2) {
+      throw new GoRefactorError(
+        `${this.name} has fewer than two parameters to group`,
+      );
+    }
+  }
+
+  private fields(): ParameterObjectField[] {
+    const exported = isExportedName(this.decl.name.name);
+    const fields = this.grouped.flatMap((field) =>
+      field.names.map((ident) => ({
+        param: ident.name,
+        field: exported ? exportedField(ident.name) : ident.name,
+        type: this.text(field.type).replace(/\s+/g, " "),
+      })),
+    );
+    const seen = new Set<string>();
+    for (const { field } of fields) {
+      if (seen.has(field)) {
+        throw new GoRefactorError(
+          `Two parameters of ${this.name} map to the field name ${field}`,
+        );
+      }
+      seen.add(field);
+    }
+    return fields;
+  }
+
+  private structName(): string {
+    const name = this.options.structName ?? defaultStructName(this.decl);
+    const declared = this.file.decls.some((decl) => {
+      if (decl.kind === "FuncDecl") {
+        return !decl.recv && decl.name.name === name;
+      }
+      return decl.specs.some((spec) =>
+        spec.kind === "TypeSpec"
+          ? spec.name.name === name
+          : spec.kind === "ValueSpec" &&
+            spec.names.some((ident) => ident.name === name),
+      );
+    });
+    if (declared) {
+      throw new GoRefactorError(`${name} is already declared in the file`);
+    }
+    return name;
+  }
+
+  private paramName(): string {
+    const used = new Set<string>();
+    inspect(this.decl, (node) => {
+      if (node.kind === "Ident") used.add(node.name);
+    });
+    const { paramName } = this.options;
+    if (paramName !== undefined) {
+      if (used.has(paramName)) {
+        throw new GoRefactorError(
+          `${paramName} is already used in ${this.name}`,
+        );
+      }
+      return paramName;
+    }
+    let name = "params";
+    for (let i = 2; used.has(name); i++) name = `params${i}`;
+    return name;
+  }
+
+  // Whether a call or reference names the function being rewritten
+  private refersToFunction(node: Node): boolean {
+    if (this.decl.recv) {
+      return (
+        node.kind === "SelectorExpr" && node.sel.name === this.decl.name.name
+      );
+    }
+    return (
+      node.kind === "Ident" &&
+      node.name === this.decl.name.name &&
+      node !== this.decl.name
+    );
+  }
+
+  // Methods are matched by name, so another type's method of the same name
+  // would have its calls rewritten too
+  private checkMethodName(): void {
+    if (!this.decl.recv) return;
+    const clash = this.file.decls.some(
+      (decl) =>
+        decl !== this.decl &&
+        decl.kind === "FuncDecl" &&
+        decl.recv &&
+        decl.name.name === this.decl.name.name,
+    );
+    if (clash) {
+      throw new GoRefactorError(
+        `Another type in the file declares a method named ${this.decl.name.name}, so its calls cannot be told apart`,
+      );
+    }
+  }
+
+  introduce(): ParameterObjectResult {
+    this.locate();
+    this.checkMethodName();
+    const fields = this.fields();
+    const structName = this.structName();
+    const paramName = this.paramName();
+    const fieldOf = new Map(fields.map((field) => [field.param, field.field]));
+
+    // Reads and writes of the parameters become field accesses
+    const scopes = resolveFunctionScopes(this.decl);
+    const inner: TextEdit[] = [];
+    for (const { ident, variable } of scopes.references) {
+      if (variable.kind !== "param" || ident === variable.ident) continue;
+      const field = fieldOf.get(variable.name);
+      if (field === undefined) continue;
+      inner.push({
+        start: ident.pos,
+        end: ident.end,
+        newText: `${paramName}.${field}`,
+      });
+    }
+
+    const calls: CallExpr[] = [];
+    const skipped: SkippedCallSite[] = [];
+    const skip = (node: Node, reason: string) =>
+      skipped.push({ line: this.line(node.pos), reason });
+    const count = fields.length;
+    inspect(this.file, (node) => {
+      if (!this.refersToFunction(node)) return;
+      const call = this.parents.get(node);
+      if (call?.kind !== "CallExpr" || call.fun !== node) {
+        skip(node, "the function is used as a value");
+      } else if (call.args.length < count) {
+        skip(call, "its arguments come from a multi-value call");
+      } else if (call.ellipsis >= 0 && call.args.length === count) {
+        skip(call, "its arguments are spread from a slice");
+      } else {
+        calls.push(call);
+      }
+    });
+
+    // Innermost calls first, so a call rewritten inside another call's
+    // arguments is folded into the outer replacement
+    calls.sort((a, b) => a.end - a.pos - (b.end - b.pos));
+    for (const call of calls) {
+      const start = call.args[0].pos;
+      const end = call.args[count - 1].end;
+      const contained = inner.filter(
+        (edit) => edit.start >= start && edit.end <= end,
+      );
+      const argText = (index: number) => {
+        const arg = call.args[index];
+        const edits = contained
+          .filter((edit) => edit.start >= arg.pos && edit.end <= arg.end)
+          .map((edit) => ({
+            ...edit,
+            start: edit.start - arg.pos,
+            end: edit.end - arg.pos,
+          }));
+        return applyEdits(this.text(arg), edits);
+      };
+      const literal = `${structName}{${fields
+        .map((field, index) => `${field.field}: ${argText(index)}`)
+        .join(", ")}}`;
+      inner.splice(
+        0,
+        inner.length,
+        ...inner.filter((edit) => !contained.includes(edit)),
+        { start, end, newText: literal },
+      );
+    }
+
+    const { params } = this.decl.type;
+    const { source, sourceMap } = this.file;
+    if (source[params.end - 1] !== ")") {
+      throw new GoRefactorError(`Cannot locate the parameters of ${this.name}`);
+    }
+    const variadic = this.variadic ? `, ${this.text(this.variadic)}` : "";
+    const declStart = sourceMap.lineStart(
+      this.line(this.decl.doc?.pos ?? this.decl.pos),
+    );
+    const edits: TextEdit[] = [
+      {
+        start: declStart,
+        end: declStart,
+        newText: `${structDeclaration(structName, this.decl.name.name, fields)}\n\n`,
+      },
+      {
+        start: params.opening + 1,
+        end: params.end - 1,
+        newText: `${paramName} ${structName}${variadic}`,
+      },
+      ...inner,
+    ];
+
+    return {
+      ...refactorResult(this.file, edits),
+      structName,
+      paramName,
+      fields,
+      updated: calls.map((call) => this.line(call.pos)).sort((a, b) => a - b),
+      skipped: skipped.sort((a, b) => a.line - b.line),
+    };
+  }
+}
+
+/**
+ * Replace the parameters of a function with a struct holding them
+ */
+export function introduceParameterObject(
+  file: GoFile,
+  options: ParameterObjectOptions,
+): ParameterObjectResult {
+  return new ParameterObjectIntroducer(file, options).introduce();
+}
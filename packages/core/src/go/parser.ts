@@ -0,0 +1,1972 @@
+import {
+  ArrayType,
+  AssignStmt,
+  BadDecl,
+  BlockStmt,
+  CallExpr,
+  CaseClause,
+  CommClause,
+  CommentGroup,
+  Decl,
+  Expr,
+  Field,
+  FieldList,
+  FuncDecl,
+  FuncType,
+  GenDecl,
+  GoFile,
+  Ident,
+  IfStmt,
+  ImportSpec,
+  InterfaceType,
+  Spec,
+  Stmt,
+  StructType,
+  TypeSpec,
+  ValueSpec,
+} from "./ast.js";
+import {
+  GoComment,
+  GoSourceMap,
+  GoSyntaxError,
+  GoToken,
+  tokenizeGo,
+} from "./lexer.js";
+
+const BINARY_PRECEDENCE: Record<string, number> = {
+  "||": 1,
+  "&&": 2,
+  "==": 3,
+  "!=": 3,
+  "<": 3,
+  "<=": 3,
+  ">": 3,
+  ">=": 3,
+  "+": 4,
+  "-": 4,
+  "|": 4,
+  "^": 4,
+  "*": 5,
+  "/": 5,
+  "%": 5,
+  "<<": 5,
+  ">>": 5,
+  "&": 5,
+  "&^": 5,
+};
+
+const ASSIGN_OPS = new Set([
+  "=",
+  ":=",
+  "+=",
+  "-=",
+  "*=",
+  "/=",
+  "%=",
+  "&=",
+  "|=",
+  "^=",
+  "<<=",
+  ">>=",
+  "&^=",
+]);
+
+/**
+ * Strip comment markers the way go/ast's CommentGroup.Text does, dropping
+ * directive comments such as `//go:build`
+ */
+export function commentText(list: GoComment[]): string {
+  const lines: string[] = [];
+  for (const comment of list) {
+    if (!comment.isBlock) {
+      const body = comment.text.slice(2);
+      if (/^[a-z0-9]+:[a-z0-9]/.test(body) || body.startsWith("line ")) {
+        continue;
+      }
+      lines.push(body.startsWith(" ") ? body.slice(1) : body);
+    } else {
+      lines.push(...comment.text.slice(2, -2).split("\n"));
+    }
+  }
+  const trimmed = lines.map((line) => line.replace(/\s+$/, ""));
+  while (trimmed.length > 0 && trimmed[0] === "") trimmed.shift();
+  while (trimmed.length > 0 && trimmed[trimmed.length - 1] === "") {
+    trimmed.pop();
+  }
+  return trimmed.length > 0 ? trimmed.join("\n") + "\n" : "";
+}
+
+export interface GoParseOptions {
+  /**
+   * Keep parsing past syntax errors, recording them on the file instead of
+   * throwing. Only a missing or malformed package clause still throws.
+   */
+  recover?: boolean;
+}
+
+const DECL_KEYWORDS = new Set(["const", "func", "import", "type", "var"]);
+
+// Deepest nesting of statements, expressions and types parsed; go/parser
+// allows 100000, more than the call stack of the recursive descent holds
+const MAX_NESTING = 1000;
+
+// Blank out the source the lexer stopped at, so it can go on: an unclosed
+// literal becomes an empty one ending the line, an unclosed comment takes
+// the rest of the file and a stray character becomes spaces. Lengths and
+// line breaks are kept, so offsets still match the original source.
+function patchLexError(source: string, pos: number): string {
+  const blank = (text: string) => text.replace(/[^\n]/g, " ");
+  const ch = source[pos];
+  if (ch === "/") {
+    return source.slice(0, pos) + blank(source.slice(pos));
+  }
+  if (ch === '"' || ch === "'" || ch === "`") {
+    const newline = source.indexOf("\n", pos);
+    const end = newline < 0 ? source.length : newline;
+    const literal = end - pos >= 2 ? ch + ch : "";
+    return (
+      source.slice(0, pos) +
+      literal.padEnd(end - pos, " ") +
+      source.slice(end)
+    );
+  }
+  const width = source.codePointAt(pos) > 0xffff ? 2 : 1;
+  return source.slice(0, pos) + " ".repeat(width) + source.slice(pos + width);
+}
+
+/**
+ * Recursive-descent parser producing a go/ast-shaped tree
+ */
+class GoParser {
+  private readonly source: string;
+  private readonly filePath: string;
+  private readonly map: GoSourceMap;
+  private readonly tokens: GoToken[];
+  private readonly groups: CommentGroup[];
+  // Errors recovered from, or undefined when the first error throws
+  private readonly errors: GoSyntaxError[] | undefined;
+  private index = 0;
+  // Expression nesting level; negative inside control clause headers where
+  // composite literals of bare type names are not permitted
+  private exprLev = 0;
+  // Depth of the statements, expressions and types being parsed
+  private nesting = 0;
+  // Set once the nesting limit is hit, which ends the declaration
+  private tooDeep?: GoSyntaxError;
+  // Index of the token read as EOF, set before the next declaration while
+  // re-parsing a broken one
+  private limit: number;
+  private bound: GoToken;
+
+  constructor(source: string, filePath: string, options: GoParseOptions) {
+    this.source = source;
+    this.filePath = filePath;
+    this.map = new GoSourceMap(source);
+    this.errors = options.recover ? [] : undefined;
+    let text = source;
+    for (;;) {
+      try {
+        const { tokens, comments } = tokenizeGo(text);
+        this.tokens = tokens;
+        this.groups = this.groupComments(comments);
+        break;
+      } catch (error) {
+        if (!this.errors || !(error instanceof GoSyntaxError)) throw error;
+        this.record(error);
+        text = patchLexError(text, error.pos);
+      }
+    }
+    this.setLimit(this.tokens.length - 1);
+  }
+
+  // -------------------------------------------------------------------------
+  // Token helpers
+  // -------------------------------------------------------------------------
+
+  private get tok(): GoToken {
+    return this.index < this.limit ? this.tokens[this.index] : this.bound;
+  }
+
+  private peek(offset = 1): GoToken {
+    return this.index + offset < this.limit
+      ? this.tokens[this.index + offset]
+      : this.bound;
+  }
+
+  private next(): GoToken {
+    const token = this.tok;
+    if (token.kind !== "eof") {
+      this.index++;
+    }
+    return token;
+  }
+
+  private setLimit(limit: number): void {
+    const token = this.tokens[limit];
+    this.limit = limit;
+    this.bound =
+      token.kind === "eof"
+        ? token
+        : { kind: "eof", value: "", pos: token.pos, end: token.pos };
+  }
+
+  private is(value: string, token: GoToken = this.tok): boolean {
+    if (value === ";") {
+      return token.kind === ";";
+    }
+    return (
+      (token.kind === "op" || token.kind === "keyword") && token.value === value
+    );
+  }
+
+  private got(value: string): boolean {
+    if (this.is(value)) {
+      this.next();
+      return true;
+    }
+    return false;
+  }
+
+  private syntaxError(message: string, pos = this.tok.pos): GoSyntaxError {
+    const { line, column } = this.map.position(pos);
+    return new GoSyntaxError(message, pos, line, column);
+  }
+
+  private error(message: string, pos: number = this.tok.pos): never {
+    throw this.syntaxError(message, pos);
+  }
+
+  private descend(): void {
+    if (++this.nesting > MAX_NESTING) {
+      this.tooDeep = this.syntaxError("exceeded max nesting depth");
+      throw this.tooDeep;
+    }
+  }
+
+  // Keep one error per line, as go/parser does; the rest tend to follow
+  // from the first
+  private record(error: GoSyntaxError): void {
+    if (!this.errors.some((other) => other.line === error.line)) {
+      this.errors.push(error);
+    }
+  }
+
+  private describe(token: GoToken): string {
+    // The start of the next declaration, read as EOF while re-parsing
+    if (token === this.bound && token !== this.tokens[this.limit]) {
+      return this.describe(this.tokens[this.limit]);
+    }
+    if (token.kind === "eof") return "EOF";
+    if (token.kind === ";") return token.implicit ? "newline" : "';'";
+    return `'${token.value}'`;
+  }
+
+  private expect(value: string): GoToken {
+    if (!this.is(value)) {
+      this.error(`expected '${value}', found ${this.describe(this.tok)}`);
+    }
+    return this.next();
+  }
+
+  /**
+   * Statements and declarations end in ";", which may be elided before a
+   * closing ")" or "}"
+   */
+  private expectSemi(): void {
+    if (this.tok.kind === ";") {
+      this.next();
+      return;
+    }
+    if (this.is(")") || this.is("}") || this.tok.kind === "eof") {
+      return;
+    }
+    this.error(`expected ';', found ${this.describe(this.tok)}`);
+  }
+
+  private prevEnd(): number {
+    for (let i = this.index - 1; i >= 0; i--) {
+      const token = this.tokens[i];
+      if (!token.implicit) {
+        return token.end;
+      }
+    }
+    return 0;
+  }
+
+  // -------------------------------------------------------------------------
+  // Comments
+  // -------------------------------------------------------------------------
+
+  private groupComments(comments: GoComment[]): CommentGroup[] {
+    const groups: CommentGroup[] = [];
+    let current: GoComment[] = [];
+    const flush = () => {
+      if (current.length > 0) {
+        groups.push({
+          kind: "CommentGroup",
+          list: current,
+          text: commentText(current),
+          pos: current[0].pos,
+          end: current[current.length - 1].end,
+        });
+      }
+      current = [];
+    };
+    for (const comment of comments) {
+      const previous = current[current.length - 1];
+      if (previous) {
+        const gap = this.source.slice(previous.end, comment.pos);
+        const newlines = gap.split("\n").length - 1;
+        // Comments stay grouped across at most one newline and no code
+        if (newlines > 1 || gap.trim() !== "" || !this.startsLine(comment)) {
+          flush();
+        }
+      }
+      current.push(comment);
+    }
+    flush();
+    return groups;
+  }
+
+  private startsLine(comment: GoComment): boolean {
+    const lineStart = this.map.lineStart(this.map.line(comment.pos));
+    return this.source.slice(lineStart, comment.pos).trim() === "";
+  }
+
+  /**
+   * The comment group ending on the line directly above `pos`
+   */
+  private docFor(pos: number): CommentGroup | undefined {
+    const line = this.map.line(pos);
+    const lineStart = this.map.lineStart(line);
+    if (this.source.slice(lineStart, pos).trim() !== "") {
+      return undefined;
+    }
+    return this.groups.find(
+      (group) =>
+        group.end <= pos &&
+        this.map.line(group.end) === line - 1 &&
+        this.startsLine(group.list[0]),
+    );
+  }
+
+  /**
+   * A trailing comment on the same line as `end`
+   */
+  private lineCommentFor(end: number): CommentGroup | undefined {
+    const line = this.map.line(end);
+    return this.groups.find(
+      (group) =>
+        group.pos >= end &&
+        this.map.line(group.pos) === line &&
+        this.source.slice(end, group.pos).trim().replace(/[;,]/g, "") === "",
+    );
+  }
+
+  // -------------------------------------------------------------------------
+  // File and declarations
+  // -------------------------------------------------------------------------
+
+  parseFile(): GoFile {
+    while (this.tok.kind === ";") this.next();
+    const packagePos = this.tok.pos;
+    const doc = this.docFor(packagePos);
+    this.expect("package");
+    const packageName = this.parseIdent();
+    this.expectSemi();
+
+    const decls: Decl[] = [];
+    const badDecls: BadDecl[] = [];
+    const imports: ImportSpec[] = [];
+    while (this.tok.kind !== "eof") {
+      if (this.tok.kind === ";") {
+        this.next();
+        continue;
+      }
+      const decl = this.errors ? this.recoverDecl() : this.parseDecl();
+      if (decl.kind === "GenDecl" && decl.tok === "import") {
+        imports.push(...(decl.specs as ImportSpec[]));
+      }
+      (decl.kind === "BadDecl" ? badDecls : decls).push(decl);
+      if (!this.errors) this.expectSemi();
+    }
+
+    return {
+      kind: "File",
+      filePath: this.filePath,
+      source: this.source,
+      sourceMap: this.map,
+      doc,
+      packageName,
+      imports,
+      decls,
+      comments: this.groups,
+      pos: packagePos,
+      end: this.source.length,
+      ...(this.errors && {
+        syntaxErrors: this.errors.sort((a, b) => a.pos - b.pos),
+        badDecls,
+      }),
+    };
+  }
+
+  /**
+   * A declaration and its ";", recovering from errors in it. A declaration
+   * that does not parse is parsed again up to the next declaration keyword
+   * starting a line, so an unclosed body does not swallow the declarations
+   * after it; what still fails becomes a BadDecl.
+   */
+  private recoverDecl(): Decl {
+    const start = this.index;
+    const recorded = this.errors.length;
+    let sync = start + 1;
+    while (
+      this.tokens[sync].kind !== "eof" &&
+      !(
+        this.tokens[sync].kind === "keyword" &&
+        DECL_KEYWORDS.has(this.tokens[sync].value) &&
+        this.map.position(this.tokens[sync].pos).column === 1
+      )
+    ) {
+      sync++;
+    }
+    try {
+      const decl = this.parseDecl();
+      this.expectSemi();
+      if (this.errors.length === recorded || this.index <= sync) return decl;
+    } catch (error) {
+      if (!(error instanceof GoSyntaxError)) throw error;
+    }
+
+    this.errors.length = recorded;
+    this.index = start;
+    this.exprLev = 0;
+    const limit = this.limit;
+    this.setLimit(sync);
+    let decl: Decl;
+    try {
+      decl = this.parseDecl();
+      this.expectSemi();
+    } catch (error) {
+      if (!(error instanceof GoSyntaxError)) throw error;
+      this.record(error);
+      this.index = sync;
+      decl = {
+        kind: "BadDecl",
+        pos: this.tokens[start].pos,
+        end: this.prevEnd(),
+      };
+    }
+    this.setLimit(limit);
+    this.index = sync;
+    return decl;
+  }
+
+  private parseDecl(): Decl {
+    if (this.is("func")) {
+      return this.parseFuncDecl();
+    }
+    if (
+      this.is("import") ||
+      this.is("const") ||
+      this.is("var") ||
+      this.is("type")
+    ) {
+      return this.parseGenDecl();
+    }
+    return this.error(
+      `non-declaration statement outside function body: ${this.describe(this.tok)}`,
+    );
+  }
+
+  private parseGenDecl(): GenDecl {
+    const keyword = this.next();
+    const tok = keyword.value as GenDecl["tok"];
+    const doc = this.docFor(keyword.pos);
+    const specs: Spec[] = [];
+    let lparen = -1;
+    let rparen = -1;
+
+    if (this.is("(")) {
+      lparen = this.next().pos;
+      while (!this.is(")") && this.tok.kind !== "eof") {
+        if (this.tok.kind === ";") {
+          this.next();
+          continue;
+        }
+        specs.push(this.parseSpec(tok));
+        this.expectSemi();
+      }
+      rparen = this.expect(")").pos;
+    } else {
+      specs.push(this.parseSpec(tok));
+    }
+
+    return {
+      kind: "GenDecl",
+      doc,
+      tok,
+      lparen,
+      rparen,
+      specs,
+      pos: keyword.pos,
+      end: rparen >= 0 ? rparen + 1 : this.prevEnd(),
+    };
+  }
+
+  private parseSpec(tok: GenDecl["tok"]): Spec {
+    const pos = this.tok.pos;
+    const doc = this.docFor(pos);
+    switch (tok) {
+      case "import":
+        return this.parseImportSpec(pos, doc);
+      case "type":
+        return this.parseTypeSpec(pos, doc);
+      default:
+        return this.parseValueSpec(pos, doc, tok === "const");
+    }
+  }
+
+  private parseImportSpec(pos: number, doc?: CommentGroup): ImportSpec {
+    let name: Ident | undefined;
+    if (this.tok.kind === "ident") {
+      name = this.parseIdent();
+    } else if (this.is(".")) {
+      const dot = this.next();
+      name = { kind: "Ident", name: ".", pos: dot.pos, end: dot.end };
+    }
+    if (this.tok.kind !== "string") {
+      this.error("missing import path");
+    }
+    const pathToken = this.next();
+    const end = pathToken.end;
+    return {
+      kind: "ImportSpec",
+      doc,
+      name,
+      path: {
+        kind: "BasicLit",
+        litKind: "string",
+        value: pathToken.value,
+        pos: pathToken.pos,
+        end: pathToken.end,
+      },
+      comment: this.lineCommentFor(end),
+      pos,
+      end,
+    };
+  }
+
+  private parseValueSpec(
+    pos: number,
+    doc: CommentGroup | undefined,
+    isConst: boolean,
+  ): ValueSpec {
+    const names = this.parseIdentList();
+    let type: Expr | undefined;
+    let values: Expr[] = [];
+    if (!this.is("=") && this.tok.kind !== ";" && !this.is(")")) {
+      type = this.parseType();
+    }
+    if (this.got("=")) {
+      values = this.parseExprList();
+    } else if (!isConst && !type) {
+      this.error("missing variable type or initialization");
+    }
+    const end = this.prevEnd();
+    return {
+      kind: "ValueSpec",
+      doc,
+      names,
+      type,
+      values,
+      comment: this.lineCommentFor(end),
+      pos,
+      end,
+    };
+  }
+
+  private parseTypeSpec(pos: number, doc?: CommentGroup): TypeSpec {
+    const name = this.parseIdent();
+    let typeParams: FieldList | undefined;
+    if (this.is("[") && this.looksLikeTypeParams()) {
+      typeParams = this.parseParameters("[", "]", true);
+    }
+    const isAlias = this.got("=");
+    const type = this.parseType();
+    const end = this.prevEnd();
+    return {
+      kind: "TypeSpec",
+      doc,
+      name,
+      typeParams,
+      isAlias,
+      type,
+      comment: this.lineCommentFor(end),
+      pos,
+      end,
+    };
+  }
+
+  /**
+   * Distinguish `type T[P any] ...` from the array type `type T [N]int`
+   */
+  private looksLikeTypeParams(): boolean {
+    const first = this.peek(1);
+    const second = this.peek(2);
+    if (first.kind !== "ident") {
+      return false;
+    }
+    if (second.kind === "ident" || second.kind === "keyword") {
+      return true;
+    }
+    return this.is(",", second) || this.is("~", second) || this.is("[", second);
+  }
+
+  private parseFuncDecl(): FuncDecl {
+    const funcToken = this.expect("func");
+    const doc = this.docFor(funcToken.pos);
+    let recv: FieldList | undefined;
+    if (this.is("(")) {
+      recv = this.parseParameters("(", ")", false);
+    }
+    const name = this.parseIdent();
+    let typeParams: FieldList | undefined;
+    if (this.is("[")) {
+      typeParams = this.parseParameters("[", "]", true);
+    }
+    const params = this.parseParameters("(", ")", false);
+    const results = this.parseResults();
+    const type: FuncType = {
+      kind: "FuncType",
+      typeParams,
+      params,
+      results,
+      pos: funcToken.pos,
+      end: this.prevEnd(),
+    };
+    let body: BlockStmt | undefined;
+    if (this.is("{")) {
+      const saved = this.exprLev;
+      this.exprLev = 0;
+      body = this.parseBlock();
+      this.exprLev = saved;
+    }
+    return {
+      kind: "FuncDecl",
+      doc,
+      recv,
+      name,
+      type,
+      body,
+      pos: funcToken.pos,
+      end: body ? body.end : type.end,
+    };
+  }
+
+  // -------------------------------------------------------------------------
+  // Parameters and fields
+  // -------------------------------------------------------------------------
+
+  private parseParameters(
+    open: string,
+    close: string,
+    typeParams: boolean,
+  ): FieldList {
+    const opening = this.expect(open).pos;
+    const entries: { name?: Ident; type?: Expr; pos: number }[] = [];
+    const saved = this.exprLev;
+    this.exprLev = Math.max(this.exprLev, 0) + 1;
+
+    while (!this.is(close) && this.tok.kind !== "eof") {
+      while (this.tok.kind === ";") this.next();
+      if (this.is(close)) break;
+      const pos = this.tok.pos;
+      if (this.is("...")) {
+        entries.push({ type: this.parseVariadic(), pos });
+      } else if (this.tok.kind === "ident") {
+        const following = this.peek(1);
+        if (
+          this.is(",", following) ||
+          this.is(close, following) ||
+          following.kind === ";"
+        ) {
+          entries.push({ name: this.parseIdent(), pos });
+        } else if (
+          this.is(".", following) ||
+          (!typeParams &&
+            this.is("[", following) &&
+            this.isInstance([",", close]))
+        ) {
+          entries.push({ type: this.parseType(), pos });
+        } else {
+          const name = this.parseIdent();
+          const type = this.is("...")
+            ? this.parseVariadic()
+            : typeParams
+              ? this.parseConstraint()
+              : this.parseType();
+          entries.push({ name, type, pos });
+        }
+      } else {
+        entries.push({
+          type: typeParams ? this.parseConstraint() : this.parseType(),
+          pos,
+        });
+      }
+      if (!this.got(",")) {
+        break;
+      }
+    }
+    while (this.tok.kind === ";") this.next();
+    const closing = this.expect(close);
+    this.exprLev = saved;
+
+    const named = entries.some((entry) => entry.name && entry.type);
+    const list: Field[] = [];
+    if (named) {
+      let pending: Ident[] = [];
+      let pendingPos = -1;
+      for (const entry of entries) {
+        if (entry.name && !entry.type) {
+          if (pending.length === 0) pendingPos = entry.pos;
+          pending.push(entry.name);
+          continue;
+        }
+        if (!entry.name) {
+          this.error("mixed named and unnamed parameters", entry.pos);
+        }
+        const names = [...pending, entry.name];
+        list.push({
+          kind: "Field",
+          names,
+          type: entry.type,
+          pos: pending.length > 0 ? pendingPos : entry.pos,
+          end: entry.type.end,
+        });
+        pending = [];
+      }
+      if (pending.length > 0) {
+        this.error("mixed named and unnamed parameters", pendingPos);
+      }
+    } else {
+      for (const entry of entries) {
+        const type = entry.type ?? entry.name;
+        list.push({
+          kind: "Field",
+          names: [],
+          type,
+          pos: entry.pos,
+          end: type.end,
+        });
+      }
+    }
+
+    return {
+      kind: "FieldList",
+      opening,
+      list,
+      pos: opening,
+      end: closing.end,
+    };
+  }
+
+  // Whether the identifier before a `[` names a generic type being
+  // instantiated rather than a field or parameter of array type: the brackets
+  // hold several types or are followed by one of `ends`
+  private isInstance(ends: string[]): boolean {
+    let depth = 0;
+    for (let offset = 1; ; offset++) {
+      const token = this.peek(offset);
+      if (token.kind === "eof") return false;
+      if (this.is("[", token) || this.is("(", token) || this.is("{", token)) {
+        depth++;
+      } else if (
+        this.is("]", token) ||
+        this.is(")", token) ||
+        this.is("}", token)
+      ) {
+        depth--;
+        if (depth > 0) continue;
+        // `s []T` is a slice
+        if (offset === 2) return false;
+        const after = this.peek(offset + 1);
+        return ends.some((end) =>
+          end === "string" ? after.kind === "string" : this.is(end, after),
+        );
+      } else if (depth === 1 && this.is(",", token)) {
+        return true;
+      }
+    }
+  }
+
+  private parseVariadic(): Expr {
+    const dots = this.expect("...");
+    const elt = this.parseType();
+    return { kind: "Ellipsis", elt, pos: dots.pos, end: elt.end };
+  }
+
+  private parseResults(): FieldList | undefined {
+    if (this.is("(")) {
+      return this.parseParameters("(", ")", false);
+    }
+    if (this.startsType()) {
+      const type = this.parseType();
+      return {
+        kind: "FieldList",
+        opening: -1,
+        list: [
+          { kind: "Field", names: [], type, pos: type.pos, end: type.end },
+        ],
+        pos: type.pos,
+        end: type.end,
+      };
+    }
+    return undefined;
+  }
+
+  private startsType(): boolean {
+    if (this.tok.kind === "ident") return true;
+    return ["[", "struct", "map", "chan", "func", "interface", "*", "<-", "("].some(
+      (value) => this.is(value),
+    );
+  }
+
+  /**
+   * Type parameter constraints allow unions (`~int | ~string`)
+   */
+  private parseConstraint(): Expr {
+    let x = this.parseConstraintTerm();
+    while (this.is("|")) {
+      const op = this.next();
+      const y = this.parseConstraintTerm();
+      x = { kind: "BinaryExpr", op: "|", opPos: op.pos, x, y, pos: x.pos, end: y.end };
+    }
+    return x;
+  }
+
+  private parseConstraintTerm(): Expr {
+    if (this.is("~")) {
+      const tilde = this.next();
+      const x = this.parseType();
+      return { kind: "UnaryExpr", op: "~", x, pos: tilde.pos, end: x.end };
+    }
+    return this.parseType();
+  }
+
+  // -------------------------------------------------------------------------
+  // Types
+  // -------------------------------------------------------------------------
+
+  parseType(): Expr {
+    this.descend();
+    try {
+      const token = this.tok;
+      if (token.kind === "ident") {
+        return this.parseTypeName();
+      }
+      if (this.is("[")) {
+        return this.parseArrayType();
+      }
+      if (this.is("struct")) return this.parseStructType();
+      if (this.is("interface")) return this.parseInterfaceType();
+      if (this.is("map")) return this.parseMapType();
+      if (this.is("chan") || this.is("<-")) return this.parseChanType();
+      if (this.is("func")) {
+        const funcToken = this.next();
+        return this.parseFuncTypeRest(funcToken.pos);
+      }
+      if (this.is("*")) {
+        const star = this.next();
+        const x = this.parseType();
+        return { kind: "StarExpr", x, pos: star.pos, end: x.end };
+      }
+      if (this.is("(")) {
+        const lparen = this.next();
+        const x = this.parseType();
+        const rparen = this.expect(")");
+        return { kind: "ParenExpr", x, pos: lparen.pos, end: rparen.end };
+      }
+      return this.error(`expected type, found ${this.describe(token)}`);
+    } finally {
+      this.nesting--;
+    }
+  }
+
+  private parseTypeName(): Expr {
+    let x: Expr = this.parseIdent();
+    if (this.is(".")) {
+      this.next();
+      const sel = this.parseIdent();
+      x = { kind: "SelectorExpr", x, sel, pos: x.pos, end: sel.end };
+    }
+    if (this.is("[")) {
+      this.next();
+      const saved = this.exprLev;
+      this.exprLev++;
+      const indices: Expr[] = [this.parseType()];
+      while (this.got(",")) {
+        if (this.is("]")) break;
+        indices.push(this.parseType());
+      }
+      this.exprLev = saved;
+      const rbrack = this.expect("]");
+      x =
+        indices.length === 1
+          ? { kind: "IndexExpr", x, index: indices[0], pos: x.pos, end: rbrack.end }
+          : { kind: "IndexListExpr", x, indices, pos: x.pos, end: rbrack.end };
+    }
+    return x;
+  }
+
+  private parseArrayType(): ArrayType {
+    const lbrack = this.expect("[");
+    let len: Expr | undefined;
+    if (this.is("...")) {
+      const dots = this.next();
+      len = { kind: "Ellipsis", pos: dots.pos, end: dots.end };
+    } else if (!this.is("]")) {
+      const saved = this.exprLev;
+      this.exprLev++;
+      len = this.parseExpr();
+      this.exprLev = saved;
+    }
+    this.expect("]");
+    const elt = this.parseType();
+    return { kind: "ArrayType", len, elt, pos: lbrack.pos, end: elt.end };
+  }
+
+  private parseStructType(): StructType {
+    const keyword = this.expect("struct");
+    const lbrace = this.expect("{");
+    const fields: Field[] = [];
+    while (!this.is("}") && this.tok.kind !== "eof") {
+      if (this.tok.kind === ";") {
+        this.next();
+        continue;
+      }
+      fields.push(this.parseFieldDecl());
+      this.expectSemi();
+    }
+    const rbrace = this.expect("}");
+    return {
+      kind: "StructType",
+      fields: {
+        kind: "FieldList",
+        opening: lbrace.pos,
+        list: fields,
+        pos: lbrace.pos,
+        end: rbrace.end,
+      },
+      pos: keyword.pos,
+      end: rbrace.end,
+    };
+  }
+
+  private parseFieldDecl(): Field {
+    const pos = this.tok.pos;
+    const doc = this.docFor(pos);
+    let names: Ident[] = [];
+    let type: Expr;
+
+    const following = this.peek(1);
+    const embedded =
+      this.is("*") ||
+      (this.tok.kind === "ident" &&
+        (following.kind === ";" ||
+          following.kind === "string" ||
+          this.is(".", following) ||
+          this.is("}", following) ||
+          (this.is("[", following) && this.isInstance([";", "}", "string"]))));
+
+    if (embedded) {
+      if (this.is("*")) {
+        const star = this.next();
+        const x = this.parseTypeName();
+        type = { kind: "StarExpr", x, pos: star.pos, end: x.end };
+      } else {
+        type = this.parseTypeName();
+      }
+    } else {
+      names = this.parseIdentList();
+      type = this.parseType();
+    }
+
+    let tag: Field["tag"];
+    if (this.tok.kind === "string") {
+      const tagToken = this.next();
+      tag = {
+        kind: "BasicLit",
+        litKind: "string",
+        value: tagToken.value,
+        pos: tagToken.pos,
+        end: tagToken.end,
+      };
+    }
+    const end = this.prevEnd();
+    return {
+      kind: "Field",
+      doc,
+      names,
+      type,
+      tag,
+      comment: this.lineCommentFor(end),
+      pos,
+      end,
+    };
+  }
+
+  private parseInterfaceType(): InterfaceType {
+    const keyword = this.expect("interface");
+    const lbrace = this.expect("{");
+    const methods: Field[] = [];
+    while (!this.is("}") && this.tok.kind !== "eof") {
+      if (this.tok.kind === ";") {
+        this.next();
+        continue;
+      }
+      const pos = this.tok.pos;
+      const doc = this.docFor(pos);
+      if (this.tok.kind === "ident" && this.is("(", this.peek(1))) {
+        const name = this.parseIdent();
+        const type = this.parseFuncTypeRest(name.end);
+        type.pos = name.pos;
+        const end = this.prevEnd();
+        methods.push({
+          kind: "Field",
+          doc,
+          names: [name],
+          type,
+          comment: this.lineCommentFor(end),
+          pos,
+          end,
+        });
+      } else {
+        const type = this.parseConstraint();
+        const end = this.prevEnd();
+        methods.push({
+          kind: "Field",
+          doc,
+          names: [],
+          type,
+          comment: this.lineCommentFor(end),
+          pos,
+          end,
+        });
+      }
+      this.expectSemi();
+    }
+    const rbrace = this.expect("}");
+    return {
+      kind: "InterfaceType",
+      methods: {
+        kind: "FieldList",
+        opening: lbrace.pos,
+        list: methods,
+        pos: lbrace.pos,
+        end: rbrace.end,
+      },
+      pos: keyword.pos,
+      end: rbrace.end,
+    };
+  }
+
+  private parseMapType(): Expr {
+    const keyword = this.expect("map");
+    this.expect("[");
+    const key = this.parseType();
+    this.expect("]");
+    const value = this.parseType();
+    return { kind: "MapType", key, value, pos: keyword.pos, end: value.end };
+  }
+
+  private parseChanType(): Expr {
+    const pos = this.tok.pos;
+    let dir: "both" | "send" | "recv" = "both";
+    if (this.got("<-")) {
+      this.expect("chan");
+      dir = "recv";
+    } else {
+      this.expect("chan");
+      if (this.got("<-")) {
+        dir = "send";
+      }
+    }
+    const value = this.parseType();
+    return { kind: "ChanType", dir, value, pos, end: value.end };
+  }
+
+  private parseFuncTypeRest(pos: number): FuncType {
+    const params = this.parseParameters("(", ")", false);
+    const results = this.parseResults();
+    return {
+      kind: "FuncType",
+      params,
+      results,
+      pos,
+      end: this.prevEnd(),
+    };
+  }
+
+  // -------------------------------------------------------------------------
+  // Expressions
+  // -------------------------------------------------------------------------
+
+  private parseIdent(): Ident {
+    const token = this.tok;
+    if (token.kind !== "ident") {
+      this.error(`expected identifier, found ${this.describe(token)}`);
+    }
+    this.next();
+    return { kind: "Ident", name: token.value, pos: token.pos, end: token.end };
+  }
+
+  private parseIdentList(): Ident[] {
+    const list = [this.parseIdent()];
+    while (this.got(",")) {
+      list.push(this.parseIdent());
+    }
+    return list;
+  }
+
+  parseExprList(): Expr[] {
+    const list = [this.parseExpr()];
+    while (this.got(",")) {
+      list.push(this.parseExpr());
+    }
+    return list;
+  }
+
+  parseExpr(): Expr {
+    return this.parseBinaryExpr(1);
+  }
+
+  private parseBinaryExpr(minPrec: number): Expr {
+    let x = this.parseUnaryExpr();
+    for (;;) {
+      const token = this.tok;
+      const prec = token.kind === "op" ? BINARY_PRECEDENCE[token.value] : 0;
+      if (!prec || prec < minPrec) {
+        return x;
+      }
+      this.next();
+      const y = this.parseBinaryExpr(prec + 1);
+      x = {
+        kind: "BinaryExpr",
+        op: token.value,
+        opPos: token.pos,
+        x,
+        y,
+        pos: x.pos,
+        end: y.end,
+      };
+    }
+  }
+
+  private parseUnaryExpr(): Expr {
+    this.descend();
+    try {
+      const token = this.tok;
+      if (token.kind === "op") {
+        switch (token.value) {
+          case "+":
+          case "-":
+          case "!":
+          case "^":
+          case "&":
+          case "~": {
+            this.next();
+            const x = this.parseUnaryExpr();
+            return { kind: "UnaryExpr", op: token.value, x, pos: token.pos, end: x.end };
+          }
+          case "<-": {
+            if (this.is("chan", this.peek(1))) {
+              return this.parsePrimaryExpr(this.parseChanType());
+            }
+            this.next();
+            const x = this.parseUnaryExpr();
+            return { kind: "UnaryExpr", op: "<-", x, pos: token.pos, end: x.end };
+          }
+          case "*": {
+            this.next();
+            const x = this.parseUnaryExpr();
+            return { kind: "StarExpr", x, pos: token.pos, end: x.end };
+          }
+        }
+      }
+      return this.parsePrimaryExpr(this.parseOperand());
+    } finally {
+      this.nesting--;
+    }
+  }
+
+  private parseOperand(): Expr {
+    const token = this.tok;
+    switch (token.kind) {
+      case "ident":
+        return this.parseIdent();
+      case "int":
+      case "float":
+      case "imag":
+      case "char":
+      case "string":
+        this.next();
+        return {
+          kind: "BasicLit",
+          litKind: token.kind,
+          value: token.value,
+          pos: token.pos,
+          end: token.end,
+        };
+    }
+    if (this.is("(")) {
+      const lparen = this.next();
+      const saved = this.exprLev;
+      this.exprLev = Math.max(this.exprLev, 0) + 1;
+      const x = this.parseExprOrType();
+      this.exprLev = saved;
+      const rparen = this.expect(")");
+      return { kind: "ParenExpr", x, pos: lparen.pos, end: rparen.end };
+    }
+    if (this.is("func")) {
+      const funcToken = this.next();
+      const type = this.parseFuncTypeRest(funcToken.pos);
+      if (this.is("{")) {
+        const saved = this.exprLev;
+        this.exprLev = 0;
+        const body = this.parseBlock();
+        this.exprLev = saved;
+        return { kind: "FuncLit", type, body, pos: funcToken.pos, end: body.end };
+      }
+      return type;
+    }
+    if (
+      this.is("[") ||
+      this.is("struct") ||
+      this.is("map") ||
+      this.is("chan") ||
+      this.is("interface")
+    ) {
+      if (this.is("[")) return this.parseArrayType();
+      if (this.is("struct")) return this.parseStructType();
+      if (this.is("map")) return this.parseMapType();
+      if (this.is("chan")) return this.parseChanType();
+      return this.parseInterfaceType();
+    }
+    return this.error(`expected operand, found ${this.describe(token)}`);
+  }
+
+  private parseExprOrType(): Expr {
+    return this.parseExpr();
+  }
+
+  private isLiteralType(x: Expr): boolean {
+    switch (x.kind) {
+      case "Ident":
+      case "ArrayType":
+      case "StructType":
+      case "MapType":
+        return true;
+      case "SelectorExpr":
+        return x.x.kind === "Ident";
+      case "IndexExpr":
+      case "IndexListExpr":
+        return x.x.kind === "Ident" || x.x.kind === "SelectorExpr";
+      default:
+        return false;
+    }
+  }
+
+  private isTypeName(x: Expr): boolean {
+    return (
+      x.kind === "Ident" ||
+      (x.kind === "SelectorExpr" && x.x.kind === "Ident") ||
+      x.kind === "IndexExpr" ||
+      x.kind === "IndexListExpr"
+    );
+  }
+
+  private parsePrimaryExpr(operand: Expr): Expr {
+    let x = operand;
+    for (;;) {
+      if (this.is(".")) {
+        this.next();
+        if (this.tok.kind === "ident") {
+          const sel = this.parseIdent();
+          x = { kind: "SelectorExpr", x, sel, pos: x.pos, end: sel.end };
+        } else if (this.is("(")) {
+          this.next();
+          let type: Expr | undefined;
+          if (!this.got("type")) {
+            type = this.parseType();
+          }
+          const rparen = this.expect(")");
+          x = { kind: "TypeAssertExpr", x, type, pos: x.pos, end: rparen.end };
+        } else {
+          this.error(`expected selector or type assertion, found ${this.describe(this.tok)}`);
+        }
+      } else if (this.is("[")) {
+        x = this.parseIndexOrSlice(x);
+      } else if (this.is("(")) {
+        x = this.parseCall(x);
+      } else if (
+        this.is("{") &&
+        this.isLiteralType(x) &&
+        (this.exprLev >= 0 || !this.isTypeName(x))
+      ) {
+        x = this.parseCompositeLit(x);
+      } else {
+        return x;
+      }
+    }
+  }
+
+  private parseIndexOrSlice(x: Expr): Expr {
+    this.expect("[");
+    const saved = this.exprLev;
+    this.exprLev = Math.max(this.exprLev, 0) + 1;
+    const indices: (Expr | undefined)[] = [];
+    let colons = 0;
+    if (!this.is(":")) {
+      indices.push(this.parseExprOrType());
+    } else {
+      indices.push(undefined);
+    }
+    if (this.is(",")) {
+      const list = [indices[0] as Expr];
+      while (this.got(",")) {
+        if (this.is("]")) break;
+        list.push(this.parseExprOrType());
+      }
+      this.exprLev = saved;
+      const rbrack = this.expect("]");
+      return { kind: "IndexListExpr", x, indices: list, pos: x.pos, end: rbrack.end };
+    }
+    while (this.is(":") && colons < 2) {
+      this.next();
+      colons++;
+      if (!this.is(":") && !this.is("]")) {
+        indices.push(this.parseExpr());
+      } else {
+        indices.push(undefined);
+      }
+    }
+    this.exprLev = saved;
+    const rbrack = this.expect("]");
+    if (colons === 0) {
+      return { kind: "IndexExpr", x, index: indices[0] as Expr, pos: x.pos, end: rbrack.end };
+    }
+    return {
+      kind: "SliceExpr",
+      x,
+      low: indices[0],
+      high: indices[1],
+      max: indices[2],
+      slice3: colons === 2,
+      pos: x.pos,
+      end: rbrack.end,
+    };
+  }
+
+  private parseCall(fun: Expr): CallExpr {
+    const lparen = this.expect("(");
+    const saved = this.exprLev;
+    this.exprLev = Math.max(this.exprLev, 0) + 1;
+    const args: Expr[] = [];
+    let ellipsis = -1;
+    while (!this.is(")") && this.tok.kind !== "eof") {
+      while (this.tok.kind === ";") this.next();
+      if (this.is(")")) break;
+      args.push(this.parseExprOrType());
+      if (this.is("...")) {
+        ellipsis = this.next().pos;
+      }
+      if (!this.got(",")) {
+        break;
+      }
+    }
+    while (this.tok.kind === ";") this.next();
+    this.exprLev = saved;
+    const rparen = this.expect(")");
+    return {
+      kind: "CallExpr",
+      fun,
+      args,
+      ellipsis,
+      lparen: lparen.pos,
+      rparen: rparen.pos,
+      pos: fun.pos,
+      end: rparen.end,
+    };
+  }
+
+  private parseCompositeLit(type: Expr | undefined): Expr {
+    const lbrace = this.expect("{");
+    const saved = this.exprLev;
+    this.exprLev = Math.max(this.exprLev, 0) + 1;
+    const elts: Expr[] = [];
+    while (!this.is("}") && this.tok.kind !== "eof") {
+      while (this.tok.kind === ";") this.next();
+      if (this.is("}")) break;
+      elts.push(this.parseElement());
+      if (!this.got(",")) {
+        break;
+      }
+    }
+    while (this.tok.kind === ";") this.next();
+    this.exprLev = saved;
+    const rbrace = this.expect("}");
+    return {
+      kind: "CompositeLit",
+      type,
+      elts,
+      lbrace: lbrace.pos,
+      pos: type ? type.pos : lbrace.pos,
+      end: rbrace.end,
+    };
+  }
+
+  private parseElement(): Expr {
+    const x = this.parseElementValue();
+    if (this.got(":")) {
+      const value = this.parseElementValue();
+      return { kind: "KeyValueExpr", key: x, value, pos: x.pos, end: value.end };
+    }
+    return x;
+  }
+
+  private parseElementValue(): Expr {
+    this.descend();
+    try {
+      if (this.is("{")) {
+        return this.parseCompositeLit(undefined);
+      }
+      return this.parseExpr();
+    } finally {
+      this.nesting--;
+    }
+  }
+
+  // -------------------------------------------------------------------------
+  // Statements
+  // -------------------------------------------------------------------------
+
+  private parseBlock(): BlockStmt {
+    const lbrace = this.expect("{");
+    const list = this.parseStmtList();
+    if (this.errors && this.tok.kind === "eof") {
+      // Closed after its last statement, where the "}" is missing
+      const end = this.prevEnd();
+      const line = this.map.line(lbrace.pos);
+      this.record(
+        this.syntaxError(`missing '}' for the '{' on line ${line}`, end),
+      );
+      return {
+        kind: "BlockStmt",
+        list,
+        lbrace: lbrace.pos,
+        rbrace: end,
+        pos: lbrace.pos,
+        end,
+      };
+    }
+    const rbrace = this.expect("}");
+    return {
+      kind: "BlockStmt",
+      list,
+      lbrace: lbrace.pos,
+      rbrace: rbrace.pos,
+      pos: lbrace.pos,
+      end: rbrace.end,
+    };
+  }
+
+  private parseStmtList(): Stmt[] {
+    const list: Stmt[] = [];
+    while (
+      !this.is("}") &&
+      !this.is("case") &&
+      !this.is("default") &&
+      this.tok.kind !== "eof"
+    ) {
+      if (this.tok.kind === ";") {
+        const semi = this.next();
+        if (!semi.implicit) {
+          list.push({ kind: "EmptyStmt", implicit: false, pos: semi.pos, end: semi.end });
+        }
+        continue;
+      }
+      if (this.errors) {
+        list.push(this.recoverStmt());
+        continue;
+      }
+      list.push(this.parseStmt());
+      if (!this.is("}") && !this.is("case") && !this.is("default")) {
+        this.expectSemi();
+      }
+    }
+    return list;
+  }
+
+  /**
+   * A statement and its ";", or a BadStmt skipping to the end of the
+   * statement when it does not parse
+   */
+  private recoverStmt(): Stmt {
+    const start = this.tok.pos;
+    const exprLev = this.exprLev;
+    try {
+      const stmt = this.parseStmt();
+      if (!this.is("}") && !this.is("case") && !this.is("default")) {
+        this.expectSemi();
+      }
+      return stmt;
+    } catch (error) {
+      // Going on at the depth of the limit would only hit it again
+      if (!(error instanceof GoSyntaxError) || error === this.tooDeep) {
+        throw error;
+      }
+      this.record(error);
+    }
+    this.exprLev = exprLev;
+    // Stop after a ";" or before the "}", "case" or "default" closing the
+    // list, skipping brackets opened on the way
+    let depth = 0;
+    while (this.tok.kind !== "eof") {
+      if (depth === 0 && this.tok.kind === ";") {
+        this.next();
+        break;
+      }
+      if (
+        depth === 0 &&
+        (this.is("}") || this.is("case") || this.is("default"))
+      ) {
+        break;
+      }
+      if (this.is("(") || this.is("[") || this.is("{")) depth++;
+      if (depth > 0 && (this.is(")") || this.is("]") || this.is("}"))) {
+        depth--;
+      }
+      this.next();
+    }
+    return {
+      kind: "BadStmt",
+      pos: start,
+      end: Math.max(start, this.prevEnd()),
+    };
+  }
+
+  private parseStmt(): Stmt {
+    this.descend();
+    try {
+      const token = this.tok;
+      if (token.kind === "keyword") {
+        switch (token.value) {
+          case "var":
+          case "const":
+          case "type": {
+            const decl = this.parseGenDecl();
+            return { kind: "DeclStmt", decl, pos: decl.pos, end: decl.end };
+          }
+          case "go":
+          case "defer": {
+            this.next();
+            const call = this.parseExpr();
+            if (call.kind !== "CallExpr") {
+              this.error(`expression in ${token.value} must be function call`, call.pos);
+            }
+            return {
+              kind: token.value === "go" ? "GoStmt" : "DeferStmt",
+              call,
+              pos: token.pos,
+              end: call.end,
+            };
+          }
+          case "return": {
+            this.next();
+            let results: Expr[] = [];
+            if (this.tok.kind !== ";" && !this.is("}")) {
+              results = this.parseExprList();
+            }
+            return {
+              kind: "ReturnStmt",
+              results,
+              pos: token.pos,
+              end: results.length > 0 ? results[results.length - 1].end : token.end,
+            };
+          }
+          case "break":
+          case "continue":
+          case "goto":
+          case "fallthrough": {
+            this.next();
+            let label: Ident | undefined;
+            if (token.value !== "fallthrough" && this.tok.kind === "ident") {
+              label = this.parseIdent();
+            }
+            return {
+              kind: "BranchStmt",
+              tok: token.value,
+              label,
+              pos: token.pos,
+              end: label ? label.end : token.end,
+            };
+          }
+          case "if":
+            return this.parseIfStmt();
+          case "switch":
+            return this.parseSwitchStmt();
+          case "select":
+            return this.parseSelectStmt();
+          case "for":
+            return this.parseForStmt();
+          case "func":
+            // Function literal used as an expression statement
+            break;
+          case "struct":
+          case "map":
+          case "chan":
+          case "interface":
+            break;
+          default:
+            this.error(`unexpected ${token.value}`);
+        }
+      }
+      if (this.is("{")) {
+        return this.parseBlock();
+      }
+      return this.parseSimpleStmt(true, false);
+    } finally {
+      this.nesting--;
+    }
+  }
+
+  private parseSimpleStmt(labelOk: boolean, rangeOk: boolean): Stmt {
+    const pos = this.tok.pos;
+
+    if (rangeOk && this.is("range")) {
+      // `for range x`
+      this.next();
+      const x = this.parseExpr();
+      return this.rangeMarker(undefined, undefined, undefined, x, pos);
+    }
+
+    const lhs = this.parseExprList();
+    const token = this.tok;
+
+    if (token.kind === "op" && ASSIGN_OPS.has(token.value)) {
+      this.next();
+      if (rangeOk && this.is("range") && (token.value === "=" || token.value === ":=")) {
+        this.next();
+        const x = this.parseExpr();
+        return this.rangeMarker(lhs[0], lhs[1], token.value, x, pos);
+      }
+      const rhs = this.parseExprList();
+      const stmt: AssignStmt = {
+        kind: "AssignStmt",
+        lhs,
+        tok: token.value,
+        tokPos: token.pos,
+        rhs,
+        pos,
+        end: rhs[rhs.length - 1].end,
+      };
+      return stmt;
+    }
+
+    if (lhs.length > 1) {
+      this.error(`expected 1 expression, found ${lhs.length}`, pos);
+    }
+    const x = lhs[0];
+
+    if (this.is(":") && labelOk && x.kind === "Ident") {
+      this.next();
+      if (this.is("}")) {
+        const empty: Stmt = { kind: "EmptyStmt", implicit: true, pos: this.tok.pos, end: this.tok.pos };
+        return { kind: "LabeledStmt", label: x, stmt: empty, pos, end: x.end + 1 };
+      }
+      while (this.tok.kind === ";" && this.tok.implicit) this.next();
+      if (this.tok.kind === ";") {
+        // A labeled empty statement, the semicolon ending both
+        const empty: Stmt = { kind: "EmptyStmt", implicit: false, pos: this.tok.pos, end: this.tok.end };
+        return { kind: "LabeledStmt", label: x, stmt: empty, pos, end: empty.end };
+      }
+      const stmt = this.parseStmt();
+      return { kind: "LabeledStmt", label: x, stmt, pos, end: stmt.end };
+    }
+
+    if (this.is("<-")) {
+      this.next();
+      const value = this.parseExpr();
+      return { kind: "SendStmt", chan: x, value, pos, end: value.end };
+    }
+
+    if (this.is("++") || this.is("--")) {
+      const op = this.next();
+      return { kind: "IncDecStmt", x, tok: op.value as "++" | "--", pos, end: op.end };
+    }
+
+    return { kind: "ExprStmt", x, pos: x.pos, end: x.end };
+  }
+
+  // A RangeStmt without its body; completed by parseForStmt
+  private rangeMarker(
+    key: Expr | undefined,
+    value: Expr | undefined,
+    tok: string | undefined,
+    x: Expr,
+    pos: number,
+  ): Stmt {
+    return {
+      kind: "RangeStmt",
+      key,
+      value,
+      tok,
+      x,
+      body: undefined as unknown as BlockStmt,
+      pos,
+      end: x.end,
+    };
+  }
+
+  private parseIfStmt(): IfStmt {
+    const keyword = this.expect("if");
+    const saved = this.exprLev;
+    this.exprLev = -1;
+    let init: Stmt | undefined;
+    let cond: Expr | undefined;
+    if (this.is("{")) {
+      this.error("missing condition in if statement");
+    }
+    if (this.tok.kind !== ";") {
+      const stmt = this.parseSimpleStmt(false, false);
+      if (this.tok.kind === ";") {
+        this.next();
+        init = stmt;
+      } else if (stmt.kind === "ExprStmt") {
+        cond = stmt.x;
+      } else {
+        this.error("cannot use assignment as value", stmt.pos);
+      }
+    } else {
+      this.next();
+    }
+    if (!cond) {
+      cond = this.parseExpr();
+    }
+    this.exprLev = saved;
+    const body = this.parseBlock();
+    let elseBranch: IfStmt | BlockStmt | undefined;
+    if (this.got("else")) {
+      if (this.is("if")) {
+        elseBranch = this.parseIfStmt();
+      } else if (this.is("{")) {
+        elseBranch = this.parseBlock();
+      } else {
+        this.error("else must be followed by if or statement block");
+      }
+    }
+    return {
+      kind: "IfStmt",
+      init,
+      cond,
+      body,
+      else: elseBranch,
+      pos: keyword.pos,
+      end: elseBranch ? elseBranch.end : body.end,
+    };
+  }
+
+  private parseSwitchStmt(): Stmt {
+    const keyword = this.expect("switch");
+    const saved = this.exprLev;
+    this.exprLev = -1;
+    let init: Stmt | undefined;
+    let tagStmt: Stmt | undefined;
+    if (!this.is("{")) {
+      if (this.tok.kind !== ";") {
+        tagStmt = this.parseSimpleStmt(false, false);
+      }
+      if (this.tok.kind === ";") {
+        this.next();
+        init = tagStmt;
+        tagStmt = undefined;
+        if (!this.is("{")) {
+          tagStmt = this.parseSimpleStmt(false, false);
+        }
+      }
+    }
+    this.exprLev = saved;
+
+    const isTypeSwitch =
+      tagStmt !== undefined &&
+      ((tagStmt.kind === "AssignStmt" &&
+        tagStmt.tok === ":=" &&
+        tagStmt.rhs.length === 1 &&
+        tagStmt.rhs[0].kind === "TypeAssertExpr" &&
+        !tagStmt.rhs[0].type) ||
+        (tagStmt.kind === "ExprStmt" &&
+          tagStmt.x.kind === "TypeAssertExpr" &&
+          !tagStmt.x.type));
+
+    const lbrace = this.expect("{");
+    const clauses: Stmt[] = [];
+    while (this.is("case") || this.is("default")) {
+      clauses.push(this.parseCaseClause());
+    }
+    const rbrace = this.expect("}");
+    const body: BlockStmt = {
+      kind: "BlockStmt",
+      list: clauses,
+      lbrace: lbrace.pos,
+      rbrace: rbrace.pos,
+      pos: lbrace.pos,
+      end: rbrace.end,
+    };
+
+    if (isTypeSwitch) {
+      return {
+        kind: "TypeSwitchStmt",
+        init,
+        assign: tagStmt,
+        body,
+        pos: keyword.pos,
+        end: body.end,
+      };
+    }
+    if (tagStmt && tagStmt.kind !== "ExprStmt") {
+      this.error("switch expression must be an expression", tagStmt.pos);
+    }
+    return {
+      kind: "SwitchStmt",
+      init,
+      tag: tagStmt ? (tagStmt as { x: Expr }).x : undefined,
+      body,
+      pos: keyword.pos,
+      end: body.end,
+    };
+  }
+
+  private parseCaseClause(): CaseClause {
+    const keyword = this.next();
+    let list: Expr[] = [];
+    const isDefault = keyword.value === "default";
+    if (!isDefault) {
+      list = this.parseExprList();
+    }
+    const colon = this.expect(":");
+    const body = this.parseStmtList();
+    return {
+      kind: "CaseClause",
+      list,
+      isDefault,
+      colon: colon.pos,
+      body,
+      pos: keyword.pos,
+      end: body.length > 0 ? body[body.length - 1].end : colon.end,
+    };
+  }
+
+  private parseSelectStmt(): Stmt {
+    const keyword = this.expect("select");
+    const lbrace = this.expect("{");
+    const clauses: CommClause[] = [];
+    while (this.is("case") || this.is("default")) {
+      const clauseToken = this.next();
+      let comm: Stmt | undefined;
+      if (clauseToken.value === "case") {
+        comm = this.parseSimpleStmt(false, false);
+      }
+      const colon = this.expect(":");
+      const body = this.parseStmtList();
+      clauses.push({
+        kind: "CommClause",
+        comm,
+        colon: colon.pos,
+        body,
+        pos: clauseToken.pos,
+        end: body.length > 0 ? body[body.length - 1].end : colon.end,
+      });
+    }
+    const rbrace = this.expect("}");
+    return {
+      kind: "SelectStmt",
+      body: {
+        kind: "BlockStmt",
+        list: clauses,
+        lbrace: lbrace.pos,
+        rbrace: rbrace.pos,
+        pos: lbrace.pos,
+        end: rbrace.end,
+      },
+      pos: keyword.pos,
+      end: rbrace.end,
+    };
+  }
+
+  private parseForStmt(): Stmt {
+    const keyword = this.expect("for");
+    const saved = this.exprLev;
+    this.exprLev = -1;
+    let init: Stmt | undefined;
+    let cond: Expr | undefined;
+    let post: Stmt | undefined;
+    let range: Stmt | undefined;
+
+    if (!this.is("{")) {
+      let first: Stmt | undefined;
+      if (this.tok.kind !== ";") {
+        first = this.parseSimpleStmt(false, true);
+      }
+      if (first && first.kind === "RangeStmt") {
+        range = first;
+      } else if (this.tok.kind === ";") {
+        this.next();
+        init = first;
+        if (this.tok.kind !== ";") {
+          cond = this.parseExpr();
+        }
+        this.expect(";");
+        if (!this.is("{")) {
+          post = this.parseSimpleStmt(false, false);
+        }
+      } else if (first) {
+        if (first.kind !== "ExprStmt") {
+          this.error("expected for loop condition", first.pos);
+        }
+        cond = first.x;
+      }
+    }
+    this.exprLev = saved;
+    const body = this.parseBlock();
+
+    if (range && range.kind === "RangeStmt") {
+      return { ...range, body, pos: keyword.pos, end: body.end };
+    }
+    return {
+      kind: "ForStmt",
+      init,
+      cond,
+      post,
+      body,
+      pos: keyword.pos,
+      end: body.end,
+    };
+  }
+}
+
+/**
+ * Parse a Go source file into a go/ast-shaped tree. With `recover`, the
+ * statements that do not parse become BadStmt nodes, the declarations that
+ * do not parse are listed in the file's `badDecls` instead of its `decls`,
+ * and the errors in its `syntaxErrors`.
+ *
+ * @throws GoSyntaxError when the source is not valid Go
+ */
+export function parseGoFile(
+  source: string,
+  filePath = "source.go",
+  options: GoParseOptions = {},
+): GoFile {
+  return new GoParser(source, filePath, options).parseFile();
+}
@@ -0,0 +1,327 @@
+import { createPlan, PlannedChange, RefactorPlan } from "../plan.js";
+import { GoFile } from "./ast.js";
+import { simplifyBoolReturns } from "./bool-return.js";
+import { generateCharacterizationTests } from "./characterize.js";
+import { GoDeclGroupingPolicy, normalizeDeclGroups } from "./group-decls.js";
+import { makeReturnsExplicit } from "./naked-returns.js";
+import { parseGoFile } from "./parser.js";
+import { renameReceivers } from "./receivers.js";
+import { renameGoSymbol } from "./rename.js";
+import { sortGoImports } from "./sort-imports.js";
+import { isGoTestFile } from "./test-links.js";
+import { convertSentinelError } from "./typed-errors.js";
+import { wrapErrors } from "./wrap-errors.js";
+
+/**
+ * Transform Pipelines
+ * ===================
+ * A pipeline runs transforms one after another over the same in-memory
+ * files: each stage sees the sources the stages before it produced, reparsed,
+ * and nothing is read from or written to disk in between. The result is the
+ * set of files that changed, ready for a single {@link createPlan} and apply
+ * once every stage has succeeded.
+ *
+ * Stages declare how they may be ordered. A stage `requires` stages that must
+ * run before it, runs `after` stages when they are in the pipeline, and
+ * `conflicts` with stages that may not share a pipeline with it. An ordering
+ * breaking any of these is rejected before anything runs.
+ */
+
+/**
+ * A file a stage writes: an analyzed file it changed or a new one
+ */
+export interface GoPipelineOutput {
+  filePath: string;
+  /** Full source of the file after the stage */
+  source: string;
+}
+
+export interface GoPipelineStage {
+  /** Unique within a pipeline */
+  name: string;
+  /** The built-in transform the stage runs, which constraints also match */
+  transform?: string;
+  /** Stages that must run earlier in the pipeline */
+  requires?: string[];
+  /** Stages that must run earlier when they are in the pipeline at all */
+  after?: string[];
+  /** Stages that cannot be in the same pipeline */
+  conflicts?: string[];
+  /** Transform the current files; unchanged files may be left out */
+  run(files: GoFile[]): GoPipelineOutput[];
+}
+
+export interface GoPipelineStageResult {
+  name: string;
+  /** Files the stage changed or created */
+  files: string[];
+}
+
+export interface GoPipelineResult {
+  stages: GoPipelineStageResult[];
+  /** Every changed file, from its content before the first stage */
+  changes: PlannedChange[];
+}
+
+/**
+ * Error raised when stages are ordered invalidly or a stage fails
+ */
+export class GoPipelineError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoPipelineError";
+  }
+}
+
+/**
+ * An ordered, validated sequence of stages
+ */
+export class GoPipeline {
+  readonly stages: GoPipelineStage[];
+
+  constructor(stages: GoPipelineStage[]) {
+    this.stages = stages;
+    this.validate();
+  }
+
+  private validate(): void {
+    // Constraints name stages, or the built-in transforms stages run
+    const positions = new Map<string, number[]>();
+    const names = new Set<string>();
+    this.stages.forEach((stage, index) => {
+      if (names.has(stage.name)) {
+        throw new GoPipelineError(`Stage ${stage.name} appears twice`);
+      }
+      names.add(stage.name);
+      for (const key of new Set([stage.name, stage.transform ?? stage.name])) {
+        positions.set(key, [...(positions.get(key) ?? []), index]);
+      }
+    });
+    this.stages.forEach((stage, index) => {
+      for (const name of stage.requires ?? []) {
+        const found = positions.get(name);
+        if (!found) {
+          throw new GoPipelineError(`Stage ${stage.name} requires ${name}`);
+        }
+        if (found[0] > index) {
+          throw new GoPipelineError(
+            `Stage ${stage.name} requires ${name} to run before it`,
+          );
+        }
+      }
+      for (const name of stage.after ?? []) {
+        if ((positions.get(name) ?? []).some((position) => position > index)) {
+          throw new GoPipelineError(
+            `Stage ${stage.name} must run after ${name}`,
+          );
+        }
+      }
+      for (const name of stage.conflicts ?? []) {
+        if (positions.has(name)) {
+          throw new GoPipelineError(
+            `Stage ${stage.name} conflicts with ${name}`,
+          );
+        }
+      }
+    });
+  }
+
+  /**
+   * Run every stage in order. Nothing is written; a failing stage fails the
+   * whole run, naming the stage.
+   */
+  run(files: GoFile[]): GoPipelineResult {
+    const current = new Map(files.map((file) => [file.filePath, file]));
+    const originals = new Map<string, string | null>(
+      files.map((file) => [file.filePath, file.source]),
+    );
+    const stages: GoPipelineStageResult[] = [];
+
+    for (const stage of this.stages) {
+      let outputs: GoPipelineOutput[];
+      try {
+        outputs = stage.run([...current.values()]);
+      } catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        throw new GoPipelineError(`Stage ${stage.name} failed: ${message}`);
+      }
+      const changed: string[] = [];
+      for (const output of outputs) {
+        const before = current.get(output.filePath);
+        if (before?.source === output.source) continue;
+        if (!originals.has(output.filePath)) {
+          originals.set(output.filePath, null);
+        }
+        try {
+          current.set(
+            output.filePath,
+            parseGoFile(output.source, output.filePath),
+          );
+        } catch (error) {
+          const message =
+            error instanceof Error ? error.message : String(error);
+          throw new GoPipelineError(
+            `Stage ${stage.name} produced invalid Go in ${output.filePath}: ${message}`,
+          );
+        }
+        changed.push(output.filePath);
+      }
+      stages.push({ name: stage.name, files: changed });
+    }
+
+    const changes: PlannedChange[] = [];
+    for (const [filePath, original] of originals) {
+      const content = current.get(filePath).source;
+      if (content === original) continue;
+      changes.push({
+        filePath,
+        original,
+        content,
+        symbols: stages
+          .filter((stage) => stage.files.includes(filePath))
+          .map((stage) => stage.name),
+      });
+    }
+    return { stages, changes };
+  }
+}
+
+/**
+ * A stage in a pipeline config: a built-in transform and its options
+ */
+export interface GoPipelineStageConfig {
+  transform: string;
+  /** Stage name (default: the transform) */
+  name?: string;
+  options?: Record<string, unknown>;
+}
+
+export interface GoPipelineConfig {
+  stages: GoPipelineStageConfig[];
+}
+
+type StageFactory = (
+  name: string,
+  options: Record<string, unknown>,
+) => GoPipelineStage;
+
+// Source files, which per-file transforms apply to
+const sources = (files: GoFile[]) =>
+  files.filter((file) => !isGoTestFile(file.filePath));
+
+/**
+ * Transforms a pipeline config can name
+ */
+export const GO_PIPELINE_TRANSFORMS: Record<string, StageFactory> = {
+  rename: (name, options) => ({
+    name,
+    run: (files) =>
+      renameGoSymbol(files, {
+        name: String(options.name),
+        newName: String(options.newName),
+      }).files,
+  }),
+  "rename-receivers": (name, options) => ({
+    name,
+    run: (files) =>
+      renameReceivers(files, {
+        type: String(options.type),
+        ...(options.name !== undefined && { name: String(options.name) }),
+      }).files,
+  }),
+  "explicit-returns": (name, options) => ({
+    name,
+    run: (files) =>
+      sources(files).map((file) =>
+        makeReturnsExplicit(file, {
+          ...(options.maxLines !== undefined && {
+            maxLines: Number(options.maxLines),
+          }),
+        }),
+      ),
+  }),
+  "simplify-bool-returns": (name) => ({
+    name,
+    run: (files) => sources(files).map((file) => simplifyBoolReturns(file)),
+  }),
+  "sort-imports": (name) => ({
+    name,
+    run: (files) => files.map((file) => sortGoImports(file)),
+  }),
+  "group-declarations": (name, options) => ({
+    name,
+    run: (files) =>
+      files.map((file) =>
+        normalizeDeclGroups(file, {
+          ...(options.policy !== undefined && {
+            policy: String(options.policy) as GoDeclGroupingPolicy,
+          }),
+          ungroupSingle: options.ungroupSingle === true,
+        }),
+      ),
+  }),
+  "wrap-errors": (name) => ({
+    name,
+    run: (files) => sources(files).map((file) => wrapErrors(file)),
+  }),
+  "typed-error": (name, options) => ({
+    name,
+    run: (files) =>
+      convertSentinelError(files, {
+        sentinel: String(options.sentinel),
+        ...(options.typeName !== undefined && {
+          typeName: String(options.typeName),
+        }),
+        is: options.is === true,
+      }).files,
+  }),
+  // Tests record the behavior of the final code, and renaming the functions
+  // they call would leave them behind
+  "characterization-tests": (name, options) => ({
+    name,
+    after: ["rename", "rename-receivers"],
+    run: (files) =>
+      sources(files).flatMap((file) => {
+        const result = generateCharacterizationTests(file, {
+          ...(options.maxCases !== undefined && {
+            maxCases: Number(options.maxCases),
+          }),
+        });
+        return result.content
+          ? [{ filePath: result.testPath, source: result.content }]
+          : [];
+      }),
+  }),
+};
+
+/**
+ * Build a pipeline from a config naming built-in transforms
+ */
+export function goPipelineFromConfig(config: GoPipelineConfig): GoPipeline {
+  if (!Array.isArray(config?.stages)) {
+    throw new GoPipelineError("Pipeline config needs a stages array");
+  }
+  return new GoPipeline(
+    config.stages.map((stage) => {
+      const factory = GO_PIPELINE_TRANSFORMS[stage.transform];
+      if (!factory) {
+        throw new GoPipelineError(`Unknown transform ${stage.transform}`);
+      }
+      return {
+        ...factory(stage.name ?? stage.transform, stage.options ?? {}),
+        transform: stage.transform,
+      };
+    }),
+  );
+}
+
+/**
+ * Run a pipeline and plan its changes, to be written once with `applyPlan`
+ */
+export function planGoPipeline(
+  rootPath: string,
+  pipeline: GoPipeline,
+  files: GoFile[],
+): RefactorPlan {
+  return createPlan(rootPath, pipeline.run(files).changes);
+}
@@ -0,0 +1,208 @@
+import { GoFile } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import {
+  defaultGoBuildContext,
+  evaluateBuildExpr,
+  GoBuildContext,
+  KNOWN_GOARCH,
+  KNOWN_GOOS,
+  matchFileName,
+  parseBuildConstraint,
+} from "./package.js";
+import { GoProfiler } from "./profile.js";
+import { defaultGoRuleRegistry, GoRuleRegistry } from "./rules.js";
+import { GoSuppressedFinding } from "./suppress.js";
+
+/**
+ * Platform Matrix
+ * ===============
+ * Cross-platform code keeps its system-specific parts behind build
+ * constraints, so checking only the files the host would compile misses the
+ * rest. Given a matrix of targets such as `linux/amd64,windows/amd64`, the
+ * files `go build` would compile for each target are selected, by their
+ * `//go:build` lines and `_GOOS`/`_GOARCH` name suffixes, and the rules run
+ * over each selection on its own. A function behind `//go:build windows` is
+ * then checked when windows is targeted, and never mixed with its linux
+ * counterpart of the same name.
+ *
+ * In the aggregate, a finding reported for several targets at the same place
+ * with the same message is listed once, with the targets it was found for.
+ * As `go build` does when cross-compiling, cgo is only enabled for the
+ * host's own platform.
+ */
+
+export interface GoPlatform {
+  goos: string;
+  goarch: string;
+}
+
+export interface GoPlatformFinding extends GoFinding {
+  /** Targets the finding was reported for, as `goos/goarch`, in matrix order */
+  platforms: string[];
+}
+
+export interface GoPlatformResult {
+  /** The target, as `goos/goarch` */
+  platform: string;
+  /** Files compiled for the target */
+  files: GoFile[];
+  findings: GoFinding[];
+  suppressed: GoSuppressedFinding[];
+}
+
+export interface GoPlatformAnalysis {
+  platforms: GoPlatformResult[];
+  /** Findings of every target, each distinct finding once, sorted */
+  findings: GoPlatformFinding[];
+  /** Suppressed findings of every target, each distinct finding once */
+  suppressed: GoSuppressedFinding<GoPlatformFinding>[];
+}
+
+export interface GoPlatformOptions {
+  /** Tags, cgo and release version shared by every target */
+  context?: Partial<Omit<GoBuildContext, "goos" | "goarch">>;
+  /** Rules to run (default: the built-in rules) */
+  registry?: GoRuleRegistry;
+  /** Records the time of each rule, summed over the targets */
+  profiler?: GoProfiler;
+}
+
+export class GoPlatformError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoPlatformError";
+  }
+}
+
+export function formatGoPlatform(platform: GoPlatform): string {
+  return `${platform.goos}/${platform.goarch}`;
+}
+
+/**
+ * Parse a target written as `goos/goarch`, as `go tool dist list` prints
+ * them
+ */
+export function parseGoPlatform(text: string): GoPlatform {
+  const match = /^(\w+)\/(\w+)$/.exec(text.trim());
+  if (!match) {
+    throw new GoPlatformError(`${text} is not of the form goos/goarch`);
+  }
+  const [, goos, goarch] = match;
+  if (!KNOWN_GOOS.has(goos)) {
+    throw new GoPlatformError(`unknown operating system ${goos} in ${text}`);
+  }
+  if (!KNOWN_GOARCH.has(goarch)) {
+    throw new GoPlatformError(`unknown architecture ${goarch} in ${text}`);
+  }
+  return { goos, goarch };
+}
+
+/**
+ * Parse a comma-separated list of targets, dropping repeated ones
+ */
+export function parseGoPlatforms(text: string): GoPlatform[] {
+  const platforms = text
+    .split(",")
+    .filter((item) => item.trim() !== "")
+    .map(parseGoPlatform);
+  if (platforms.length === 0) {
+    throw new GoPlatformError("no target platform given");
+  }
+  const seen = new Set<string>();
+  return platforms.filter((platform) => {
+    const key = formatGoPlatform(platform);
+    if (seen.has(key)) return false;
+    seen.add(key);
+    return true;
+  });
+}
+
+/**
+ * The build context of a target, cgo enabled only for the host platform
+ */
+export function goPlatformContext(
+  platform: GoPlatform,
+  options: GoPlatformOptions = {},
+): GoBuildContext {
+  const host = defaultGoBuildContext();
+  const native = platform.goos === host.goos && platform.goarch === host.goarch;
+  return {
+    ...host,
+    cgo: native,
+    ...options.context,
+    goos: platform.goos,
+    goarch: platform.goarch,
+  };
+}
+
+/**
+ * The files `go build` would compile for a build context
+ */
+export function goPlatformFiles(
+  files: GoFile[],
+  context: GoBuildContext,
+): GoFile[] {
+  return files.filter((file) => {
+    if (!matchFileName(file.filePath, context)) return false;
+    const constraint = parseBuildConstraint(file.source);
+    return !constraint || evaluateBuildExpr(constraint, context);
+  });
+}
+
+function findingKey(finding: GoFinding): string {
+  const { rule, filePath, line, column, message } = finding;
+  return [rule, filePath, line, column, message].join("\0");
+}
+
+// Each distinct finding once, with its first instance and its targets
+function distinct<T>(
+  results: GoPlatformResult[],
+  items: (result: GoPlatformResult) => T[],
+  finding: (item: T) => GoFinding,
+): { item: T; finding: GoPlatformFinding }[] {
+  const merged = new Map<string, { item: T; platforms: string[] }>();
+  for (const result of results) {
+    for (const item of items(result)) {
+      const key = findingKey(finding(item));
+      if (!merged.has(key)) merged.set(key, { item, platforms: [] });
+      merged.get(key).platforms.push(result.platform);
+    }
+  }
+  return [...merged.values()].map(({ item, platforms }) => ({
+    item,
+    finding: { ...finding(item), platforms },
+  }));
+}
+
+/**
+ * Run the rules for every target of a matrix and merge the findings
+ */
+export function analyzeGoPlatforms(
+  files: GoFile[],
+  platforms: GoPlatform[],
+  options: GoPlatformOptions = {},
+): GoPlatformAnalysis {
+  const registry = options.registry ?? defaultGoRuleRegistry();
+  const results = platforms.map((platform): GoPlatformResult => {
+    const selected = goPlatformFiles(
+      files,
+      goPlatformContext(platform, options),
+    );
+    return {
+      platform: formatGoPlatform(platform),
+      files: selected,
+      ...registry.runWithSuppressions(selected, { profiler: options.profiler }),
+    };
+  });
+  const findings = distinct(
+    results,
+    (result) => result.findings,
+    (item) => item,
+  ).map((entry) => entry.finding);
+  const suppressed = distinct(
+    results,
+    (result) => result.suppressed,
+    (item) => item.finding,
+  ).map(({ item, finding }) => ({ ...item, finding }));
+  return { platforms: results, findings: sortFindings(findings), suppressed };
+}
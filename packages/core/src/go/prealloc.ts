@@ -0,0 +1,318 @@
+import {
+  Expr,
+  FuncDecl,
+  GoFile,
+  Ident,
+  Node,
+  RangeStmt,
+  Stmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+
+/**
+ * Slice Preallocation
+ * ===================
+ * A slice declared without capacity (`var s []T`, `s := []T{}` or
+ * `s := make([]T, 0)`) and then appended to once per iteration of a `range`
+ * loop grows by reallocation, copying its elements each time it outgrows
+ * its backing array, although the final length is known up front: the
+ * length of the ranged slice, array or map, or the ranged integer. This
+ * pass flags such slices with the `make` call that preallocates them, e.g.
+ * `make([]string, 0, len(items))`, and rewrites the declaration to it.
+ *
+ * Only the simplest shape is flagged, where the length is certain: the loop
+ * is the first statement after the declaration to use the slice, appends
+ * exactly one element per iteration outside any condition, never leaves
+ * early, and the ranged expression is a variable or field already in scope
+ * at the declaration. A `var s []T` slice that was nil when the loop ran
+ * zero times is an empty, non-nil slice after the rewrite.
+ */
+
+export interface GoPreallocFinding extends GoFinding {
+  rule: "slice-prealloc";
+  variable: string;
+  /** Line of the loop appending to the slice */
+  loopLine: number;
+  /** The preallocating call, e.g. `make([]string, 0, len(items))` */
+  make: string;
+}
+
+export interface PreallocateOptions {
+  /**
+   * Line of a declaration reported by {@link findMissingPreallocations}
+   * (default: every one in the file)
+   */
+  line?: number;
+}
+
+export interface PreallocateResult extends GoRefactorResult {
+  /** Slices now preallocated */
+  variables: string[];
+}
+
+interface Candidate {
+  variable: GoVariable;
+  /** The statement declaring the slice */
+  declaration: Stmt;
+  /** Element type of the slice */
+  elt: Expr;
+  loop: RangeStmt;
+  /** Capacity the loop needs */
+  capacity: string;
+}
+
+const INTEGER_TYPES =
+  /^(u?int(8|16|32|64)?|uintptr|byte|rune|untyped (int|rune))$/;
+
+function statementLists(node: Node): Stmt[] | undefined {
+  switch (node.kind) {
+    case "BlockStmt":
+      return node.list;
+    case "CaseClause":
+    case "CommClause":
+      return node.body;
+    default:
+      return undefined;
+  }
+}
+
+class PreallocAnalyzer {
+  private readonly file: GoFile;
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private uses(node: Node, variables: Set<GoVariable>): Ident[] {
+    const found: Ident[] = [];
+    inspect(node, (child) => {
+      if (child.kind !== "Ident") return;
+      const variable = this.scopes.resolved.get(child);
+      if (variable && variables.has(variable)) found.push(child);
+    });
+    return found;
+  }
+
+  // The slice a statement declares without capacity, and its element type
+  private declared(stmt: Stmt): { ident: Ident; elt: Expr } | undefined {
+    let ident: Expr;
+    let type: Expr | undefined;
+    let init: Expr | undefined;
+    if (stmt.kind === "DeclStmt" && stmt.decl.tok === "var") {
+      const [spec] = stmt.decl.specs;
+      if (stmt.decl.specs.length !== 1 || spec.kind !== "ValueSpec") {
+        return undefined;
+      }
+      if (spec.names.length !== 1 || spec.values.length > 1) return undefined;
+      [ident, type, init] = [spec.names[0], spec.type, spec.values[0]];
+    } else if (stmt.kind === "AssignStmt" && stmt.tok === ":=") {
+      if (stmt.lhs.length !== 1 || stmt.rhs.length !== 1) return undefined;
+      [ident, init] = [stmt.lhs[0], stmt.rhs[0]];
+    } else {
+      return undefined;
+    }
+    if (ident.kind !== "Ident") return undefined;
+    if (init?.kind === "CompositeLit" && init.elts.length === 0) {
+      type = init.type;
+    } else if (
+      init?.kind === "CallExpr" &&
+      this.text(init.fun) === "make" &&
+      init.args.length === 2 &&
+      this.text(init.args[1]) === "0"
+    ) {
+      type = init.args[0];
+    } else if (init) {
+      return undefined;
+    }
+    if (type?.kind !== "ArrayType" || type.len) return undefined;
+    return { ident, elt: type.elt };
+  }
+
+  // The capacity ranging over an expression needs, when it is certain
+  private capacity(x: Expr): string | undefined {
+    let current = x;
+    while (current.kind === "SelectorExpr") current = current.x;
+    if (current.kind === "BasicLit" && current.litKind === "int") {
+      return this.text(x);
+    }
+    if (current.kind !== "Ident") return undefined;
+    const type = this.types.typeOf(x);
+    if (type === undefined) return undefined;
+    const resolved = this.types.underlying(type) ?? type;
+    if (INTEGER_TYPES.test(resolved)) return this.text(x);
+    if (/^(\[\d*\]|map\[)/.test(resolved)) return `len(${this.text(x)})`;
+    return undefined;
+  }
+
+  // Whether a statement is `s = append(s, x)`
+  private isAppend(stmt: Stmt, variable: GoVariable): boolean {
+    if (stmt.kind !== "AssignStmt" || stmt.tok !== "=") return false;
+    if (stmt.lhs.length !== 1 || stmt.rhs.length !== 1) return false;
+    const [target, call] = [stmt.lhs[0], stmt.rhs[0]];
+    const refersTo = (expr: Expr) =>
+      expr.kind === "Ident" && this.scopes.resolved.get(expr) === variable;
+    return (
+      refersTo(target) &&
+      call.kind === "CallExpr" &&
+      this.text(call.fun) === "append" &&
+      call.args.length === 2 &&
+      call.ellipsis < 0 &&
+      refersTo(call.args[0])
+    );
+  }
+
+  // Whether each iteration of the loop appends exactly one element to s
+  private appendsOnce(loop: RangeStmt, variable: GoVariable): boolean {
+    const uses = this.uses(loop.body, new Set([variable]));
+    const appends = loop.body.list.filter((stmt) =>
+      this.isAppend(stmt, variable),
+    );
+    if (appends.length !== 1 || uses.length !== 2) return false;
+    let leaves = false;
+    inspect(loop.body, (node) => {
+      if (node.kind === "FuncLit") return false;
+      if (node.kind === "BranchStmt" || node.kind === "ReturnStmt") {
+        leaves = true;
+      }
+    });
+    return !leaves;
+  }
+
+  private candidate(list: Stmt[], index: number): Candidate | undefined {
+    const declaration = list[index];
+    const declared = this.declared(declaration);
+    if (!declared) return undefined;
+    const variable = this.scopes.resolved.get(declared.ident);
+    if (!variable) return undefined;
+    const self = new Set([variable]);
+    const next = list
+      .slice(index + 1)
+      .findIndex((stmt) => this.uses(stmt, self).length > 0);
+    const loop = list[index + 1 + next];
+    if (next < 0 || loop.kind !== "RangeStmt") return undefined;
+    if (this.uses(loop.x, self).length > 0) return undefined;
+    const capacity = this.capacity(loop.x);
+    if (!capacity || !this.appendsOnce(loop, variable)) return undefined;
+    // What the capacity names must exist, unchanged, at the declaration
+    const ranged = new Set<GoVariable>();
+    inspect(loop.x, (node) => {
+      const used = node.kind === "Ident" && this.scopes.resolved.get(node);
+      if (used) ranged.add(used);
+    });
+    if ([...ranged].some((used) => used.ident.pos > declaration.pos)) {
+      return undefined;
+    }
+    const between = list.slice(index + 1, index + 1 + next);
+    if (between.some((stmt) => this.uses(stmt, ranged).length > 0)) {
+      return undefined;
+    }
+    return { variable, declaration, elt: declared.elt, loop, capacity };
+  }
+
+  private analyzeFunction(decl: FuncDecl): Candidate[] {
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+    const candidates: Candidate[] = [];
+    inspect(decl.body, (node) => {
+      const list = statementLists(node);
+      list?.forEach((_, index) => {
+        const candidate = this.candidate(list, index);
+        if (candidate) candidates.push(candidate);
+      });
+    });
+    return candidates;
+  }
+
+  analyze(): Candidate[] {
+    return this.file.decls.flatMap((decl) =>
+      decl.kind === "FuncDecl" && decl.body ? this.analyzeFunction(decl) : [],
+    );
+  }
+
+  makeCall(candidate: Candidate): string {
+    return `make([]${this.text(candidate.elt)}, 0, ${candidate.capacity})`;
+  }
+
+  finding(candidate: Candidate): GoPreallocFinding {
+    const { name } = candidate.variable;
+    const loopLine = this.file.sourceMap.line(candidate.loop.pos);
+    const make = this.makeCall(candidate);
+    return {
+      rule: "slice-prealloc",
+      severity: "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(candidate.declaration.pos),
+      message: `${name} grows by one element per iteration of the loop on line ${loopLine}; preallocate it with ${make}`,
+      fix: make,
+      variable: name,
+      loopLine,
+      make,
+    };
+  }
+
+  rewrite(options: PreallocateOptions): PreallocateResult {
+    const candidates = this.analyze().filter(
+      (candidate) =>
+        options.line === undefined ||
+        this.file.sourceMap.line(candidate.declaration.pos) === options.line,
+    );
+    if (options.line !== undefined && candidates.length === 0) {
+      throw new GoRefactorError(
+        `Line ${options.line} declares no slice appended to once per loop iteration`,
+      );
+    }
+    const edits = candidates.map((candidate) => ({
+      start: candidate.declaration.pos,
+      end: candidate.declaration.end,
+      newText: `${candidate.variable.name} := ${this.makeCall(candidate)}`,
+    }));
+    return {
+      ...refactorResult(this.file, edits),
+      variables: candidates.map((candidate) => candidate.variable.name),
+    };
+  }
+}
+
+/**
+ * Find slices appended to once per iteration of a range loop that could be
+ * created with the capacity the loop needs
+ */
+export function findMissingPreallocations(
+  files: GoFile[],
+): GoPreallocFinding[] {
+  return sortFindings(
+    files.flatMap((file) => {
+      const analyzer = new PreallocAnalyzer(file);
+      return analyzer.analyze().map((candidate) => analyzer.finding(candidate));
+    }),
+  );
+}
+
+/**
+ * Declare slices reported by {@link findMissingPreallocations} with
+ * `make([]T, 0, n)`
+ */
+export function preallocateSlices(
+  file: GoFile,
+  options: PreallocateOptions = {},
+): PreallocateResult {
+  return new PreallocAnalyzer(file).rewrite(options);
+}
@@ -0,0 +1,264 @@
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { buildGoCallGraph, GoCallGraph } from "./callgraph.js";
+import { GoCoverProfile, symbolCoverage } from "./coverage.js";
+import { compareCodePoints } from "./findings.js";
+import { GoTestIndex, isGoTestFile } from "./test-links.js";
+
+/**
+ * Refactor Priorities
+ * ===================
+ * A worklist of the functions and methods most worth refactoring, highest
+ * priority first. Each one is rated on four factors between 0 and 1:
+ *
+ * - complexity: cyclomatic complexity above 1, reaching 1 at 15, or
+ *   cognitive complexity reaching 1 at 20, whichever is higher
+ * - coverage: the share of its lines no test runs, with a coverage profile;
+ *   without one, 1 when no test reaches it, 0.5 when a test reaches it
+ *   through other calls and 0.25 when a test calls it directly
+ * - fanIn: how many other functions call it, reaching 1 at 10 callers, since
+ *   a function many depend on repays a cleanup many times
+ * - effort: lines of code, reaching 1 at 60, as an estimate of the work
+ *
+ * The first three measure the value of the refactor: their weighted mean is
+ * the item's value. Effort is a cost, and its weight (between 0 and 1) is
+ * the share of the value the largest functions lose to it, so the priority
+ * is `value * (1 - effortWeight * effort)`. Equal priorities are ordered by
+ * symbol name, then file and line.
+ */
+
+export type GoPriorityFactorName =
+  | "complexity"
+  | "coverage"
+  | "fanIn"
+  | "effort";
+
+export type GoPriorityWeights = Record<GoPriorityFactorName, number>;
+
+export const DEFAULT_GO_PRIORITY_WEIGHTS: Readonly<GoPriorityWeights> =
+  Object.freeze({
+    complexity: 3,
+    coverage: 2,
+    fanIn: 1,
+    effort: 0.5,
+  });
+
+export interface GoPriorityFactor {
+  name: GoPriorityFactorName;
+  /** Rating between 0 and 1, rounded to two decimals */
+  value: number;
+  weight: number;
+  reason: string;
+}
+
+export interface GoPriorityItem {
+  /** Call graph id, `pkg.Func` or `pkg.Type.Method` */
+  name: string;
+  filePath: string;
+  line: number;
+  /** Priority between 0 and 1, rounded to three decimals */
+  score: number;
+  factors: GoPriorityFactor[];
+}
+
+export interface GoPriorityOptions {
+  /** Weights replacing the defaults, factor by factor */
+  weights?: Partial<GoPriorityWeights>;
+  /** Coverage profile used in place of call graph reachability from tests */
+  coverage?: GoCoverProfile;
+  /** Keep only the highest priority items */
+  limit?: number;
+  /** Directory the formatted paths are relative to (default: the cwd) */
+  root?: string;
+}
+
+export class GoPriorityError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoPriorityError";
+  }
+}
+
+const FACTOR_NAMES = Object.keys(
+  DEFAULT_GO_PRIORITY_WEIGHTS,
+) as GoPriorityFactorName[];
+
+function round(value: number, digits = 2): number {
+  const scale = 10 ** digits;
+  return Math.round(value * scale) / scale;
+}
+
+function weightsOf(options: GoPriorityOptions): GoPriorityWeights {
+  const weights = { ...DEFAULT_GO_PRIORITY_WEIGHTS, ...options.weights };
+  for (const name of FACTOR_NAMES) {
+    const weight = weights[name];
+    if (!Number.isFinite(weight) || weight < 0) {
+      throw new GoPriorityError(
+        `Weight of ${name} must be a non-negative number, not ${weight}`,
+      );
+    }
+  }
+  if (weights.effort > 1) {
+    throw new GoPriorityError(
+      `Weight of effort must be at most 1, not ${weights.effort}`,
+    );
+  }
+  return weights;
+}
+
+/**
+ * Parse weights written as `name=weight` pairs separated by commas, e.g.
+ * `complexity=2,effort=0`
+ */
+export function parseGoPriorityWeights(
+  text: string,
+): Partial<GoPriorityWeights> {
+  const weights: Partial<GoPriorityWeights> = {};
+  for (const pair of text.split(",")) {
+    const separator = pair.indexOf("=");
+    const name = pair.slice(0, separator).trim() as GoPriorityFactorName;
+    const value = pair.slice(separator + 1).trim();
+    if (separator < 0 || !FACTOR_NAMES.includes(name)) {
+      throw new GoPriorityError(
+        `Weight ${JSON.stringify(pair)} must look like factor=weight, with factor one of ${FACTOR_NAMES.join(", ")}`,
+      );
+    }
+    if (value === "" || !Number.isFinite(Number(value))) {
+      throw new GoPriorityError(
+        `Weight of ${name} must be a number, not ${JSON.stringify(value)}`,
+      );
+    }
+    weights[name] = Number(value);
+  }
+  return weights;
+}
+
+function coverageFactor(
+  graph: GoCallGraph,
+  tests: GoTestIndex,
+  id: string,
+  options: GoPriorityOptions,
+): Omit<GoPriorityFactor, "weight"> {
+  const node = graph.nodes.get(id);
+  const coverage =
+    options.coverage &&
+    symbolCoverage(options.coverage, node.filePath, node.symbol);
+  if (coverage) {
+    return {
+      name: "coverage",
+      value: round(1 - coverage.coveragePct / 100),
+      reason: `${coverage.coveragePct}% of lines covered`,
+    };
+  }
+  const test = tests.nearest(id);
+  if (!test) {
+    return { name: "coverage", value: 1, reason: "no test reaches it" };
+  }
+  if (test.depth === 1) {
+    return { name: "coverage", value: 0.25, reason: `called by ${test.test}` };
+  }
+  return {
+    name: "coverage",
+    value: 0.5,
+    reason: `reached from ${test.test} through ${test.depth - 1} call(s)`,
+  };
+}
+
+/**
+ * Rank the non-test functions and methods of the files by refactor priority
+ */
+export function goRefactorPriorities(
+  files: GoFile[],
+  options: GoPriorityOptions = {},
+  graph: GoCallGraph = buildGoCallGraph(files),
+): GoPriorityItem[] {
+  const weights = weightsOf(options);
+  const tests = new GoTestIndex(graph);
+  const items: GoPriorityItem[] = [];
+  for (const node of graph.nodes.values()) {
+    if (isGoTestFile(node.filePath)) continue;
+    const { symbol } = node;
+    const { complexity, cognitiveComplexity } = symbol;
+    const callers = [...(graph.callers.get(node.id) ?? [])].filter(
+      (caller) => caller !== node.id,
+    ).length;
+    const codeLines = symbol.size.codeLines;
+    const ratings: Omit<GoPriorityFactor, "weight">[] = [
+      {
+        name: "complexity",
+        value: round(
+          Math.min(
+            1,
+            Math.max((complexity - 1) / 14, cognitiveComplexity / 20),
+          ),
+        ),
+        reason: `cyclomatic complexity ${complexity}, cognitive complexity ${cognitiveComplexity}`,
+      },
+      coverageFactor(graph, tests, node.id, options),
+      {
+        name: "fanIn",
+        value: round(Math.min(1, callers / 10)),
+        reason: `${callers} caller(s)`,
+      },
+      {
+        name: "effort",
+        value: round(Math.min(1, codeLines / 60)),
+        reason: `${codeLines} line(s) of code`,
+      },
+    ];
+    const factors = ratings.map((factor) => ({
+      ...factor,
+      weight: weights[factor.name],
+    }));
+
+    const benefits = factors.filter((factor) => factor.name !== "effort");
+    const total = benefits.reduce((sum, factor) => sum + factor.weight, 0);
+    const value =
+      total === 0
+        ? 0
+        : benefits.reduce(
+            (sum, factor) => sum + factor.weight * factor.value,
+            0,
+          ) / total;
+    const effort = factors.find((factor) => factor.name === "effort");
+    items.push({
+      name: node.id,
+      filePath: node.filePath,
+      line: symbol.startLine,
+      score: round(value * (1 - effort.weight * effort.value), 3),
+      factors,
+    });
+  }
+  items.sort(
+    (a, b) =>
+      b.score - a.score ||
+      compareCodePoints(a.name, b.name) ||
+      compareCodePoints(a.filePath, b.filePath) ||
+      a.line - b.line,
+  );
+  return options.limit === undefined ? items : items.slice(0, options.limit);
+}
+
+/**
+ * Render the worklist as text, one numbered item per function followed by
+ * the ratings of its factors
+ */
+export function formatGoRefactorPriorities(
+  items: GoPriorityItem[],
+  options: Pick<GoPriorityOptions, "root"> = {},
+): string {
+  const root = options.root ?? process.cwd();
+  const lines: string[] = [];
+  items.forEach((item, index) => {
+    const file = path.relative(root, item.filePath).split(path.sep).join("/");
+    lines.push(
+      `${index + 1}. ${item.name} (${file}:${item.line}): priority ${item.score.toFixed(3)}`,
+    );
+    for (const factor of item.factors) {
+      lines.push(
+        `   ${factor.name} ${factor.value.toFixed(2)} x${factor.weight}: ${factor.reason}`,
+      );
+    }
+  });
+  return lines.length === 0 ? "" : lines.join("\n") + "\n";
+}
@@ -0,0 +1,302 @@
+import * as path from "path";
+import { performance } from "perf_hooks";
+import { gzipSync } from "zlib";
+import { compareCodePoints } from "./findings.js";
+
+/**
+ * Profiling
+ * =========
+ * Records where the time of a run goes: the wall time of each phase, such
+ * as discovery, parsing, symbol extraction, the call graph and every rule,
+ * summed over its runs, and the share of it spent on each file. Phases the
+ * analyzer runs once for a whole package, like most rules, have no per-file
+ * breakdown; a pass reporting several rules is timed under the first of them
+ * to run. Phases overlap when files are analyzed concurrently, so their sum
+ * can exceed the wall time of the run.
+ *
+ * Counters record events that are not timed, such as cache hits and misses.
+ * The summary renders as text, and as a gzipped pprof protobuf that `go tool
+ * pprof` reads, with the phases as frames under one root and the files as
+ * frames under their phase.
+ */
+
+export interface GoProfilerOptions {
+  /** Milliseconds since some fixed point (default: `performance.now`) */
+  clock?: () => number;
+}
+
+export interface GoProfilePhase {
+  name: string;
+  /** Wall time summed over the phase's runs, in milliseconds */
+  totalMs: number;
+  count: number;
+}
+
+export interface GoProfileFile {
+  filePath: string;
+  totalMs: number;
+  /** Time per phase, slowest first */
+  phases: { name: string; totalMs: number }[];
+}
+
+export interface GoProfileSummary {
+  /** Wall time since the profiler was created, in milliseconds */
+  wallMs: number;
+  /** Slowest first */
+  phases: GoProfilePhase[];
+  /** Slowest first */
+  files: GoProfileFile[];
+  counters: Record<string, number>;
+}
+
+export interface GoProfileFormatOptions {
+  /** Directory file paths are written relative to (default: the cwd) */
+  root?: string;
+  /** Files listed (default: 10) */
+  top?: number;
+}
+
+function round(ms: number): number {
+  return Math.round(ms * 1000) / 1000;
+}
+
+/**
+ * Collects the wall time of phases and files over a run
+ */
+export class GoProfiler {
+  private readonly clock: () => number;
+  private readonly started: number;
+  private readonly phases = new Map<string, GoProfilePhase>();
+  private readonly files = new Map<string, Map<string, number>>();
+  private readonly counters = new Map<string, number>();
+
+  constructor(options: GoProfilerOptions = {}) {
+    this.clock = options.clock ?? (() => performance.now());
+    this.started = this.clock();
+  }
+
+  /**
+   * Add the time of one run of a phase, for a file when given
+   */
+  record(phase: string, ms: number, filePath?: string): void {
+    const entry = this.phases.get(phase) ?? {
+      name: phase,
+      totalMs: 0,
+      count: 0,
+    };
+    entry.totalMs += ms;
+    entry.count++;
+    this.phases.set(phase, entry);
+    if (filePath === undefined) return;
+    const file = this.files.get(filePath) ?? new Map<string, number>();
+    file.set(phase, (file.get(phase) ?? 0) + ms);
+    this.files.set(filePath, file);
+  }
+
+  /**
+   * Run a function as one run of a phase
+   */
+  measure<T>(phase: string, run: () => T, filePath?: string): T {
+    const start = this.clock();
+    try {
+      return run();
+    } finally {
+      this.record(phase, this.clock() - start, filePath);
+    }
+  }
+
+  /**
+   * Await a function as one run of a phase
+   */
+  async measureAsync<T>(
+    phase: string,
+    run: () => Promise<T>,
+    filePath?: string,
+  ): Promise<T> {
+    const start = this.clock();
+    try {
+      return await run();
+    } finally {
+      this.record(phase, this.clock() - start, filePath);
+    }
+  }
+
+  count(name: string, by = 1): void {
+    this.counters.set(name, (this.counters.get(name) ?? 0) + by);
+  }
+
+  summary(): GoProfileSummary {
+    const phases = [...this.phases.values()]
+      .map((phase) => ({ ...phase, totalMs: round(phase.totalMs) }))
+      .sort(
+        (a, b) => b.totalMs - a.totalMs || compareCodePoints(a.name, b.name),
+      );
+    const files = [...this.files].map(([filePath, times]) => {
+      const perPhase = [...times]
+        .map(([name, totalMs]) => ({ name, totalMs: round(totalMs) }))
+        .sort(
+          (a, b) => b.totalMs - a.totalMs || compareCodePoints(a.name, b.name),
+        );
+      const total = [...times.values()].reduce((sum, ms) => sum + ms, 0);
+      return { filePath, totalMs: round(total), phases: perPhase };
+    });
+    files.sort(
+      (a, b) =>
+        b.totalMs - a.totalMs || compareCodePoints(a.filePath, b.filePath),
+    );
+    const counters = Object.fromEntries(
+      [...this.counters].sort(([a], [b]) => compareCodePoints(a, b)),
+    );
+    return {
+      wallMs: round(this.clock() - this.started),
+      phases,
+      files,
+      counters,
+    };
+  }
+}
+
+/**
+ * Render a summary as text: the phases, the slowest files and the counters
+ */
+export function formatGoProfileSummary(
+  summary: GoProfileSummary,
+  options: GoProfileFormatOptions = {},
+): string {
+  const root = options.root ?? process.cwd();
+  const ms = (value: number) => `${value.toFixed(1)} ms`;
+  const lines = [`Wall time: ${ms(summary.wallMs)}`];
+  if (summary.phases.length > 0) {
+    lines.push("Phases:");
+    const width = Math.max(
+      ...summary.phases.map((phase) => phase.name.length),
+    );
+    for (const phase of summary.phases) {
+      const share =
+        summary.wallMs > 0 ? (phase.totalMs / summary.wallMs) * 100 : 0;
+      lines.push(
+        `  ${phase.name.padEnd(width)}  ${ms(phase.totalMs).padStart(10)}  ${share.toFixed(1).padStart(5)}%  ${phase.count} run(s)`,
+      );
+    }
+  }
+  const files = summary.files.slice(0, options.top ?? 10);
+  if (files.length > 0) {
+    lines.push("Slowest files:");
+    for (const file of files) {
+      const name = path
+        .relative(root, file.filePath)
+        .split(path.sep)
+        .join("/");
+      const breakdown = file.phases
+        .map((phase) => `${phase.name} ${phase.totalMs.toFixed(1)}`)
+        .join(", ");
+      lines.push(`  ${name}  ${ms(file.totalMs)} (${breakdown})`);
+    }
+  }
+  const counters = Object.entries(summary.counters);
+  if (counters.length > 0) {
+    lines.push("Counters:");
+    for (const [name, value] of counters) lines.push(`  ${name}: ${value}`);
+  }
+  return lines.join("\n") + "\n";
+}
+
+// Protocol buffer encoding of the few field types profile.proto uses
+class ProtoWriter {
+  readonly bytes: number[] = [];
+
+  private varint(value: number): void {
+    let rest = value;
+    while (rest >= 0x80) {
+      this.bytes.push((rest % 0x80) | 0x80);
+      rest = Math.floor(rest / 0x80);
+    }
+    this.bytes.push(rest);
+  }
+
+  uint(field: number, value: number): this {
+    if (value === 0) return this;
+    this.varint(field * 8);
+    this.varint(value);
+    return this;
+  }
+
+  message(field: number, bytes: number[] | Uint8Array): this {
+    this.varint(field * 8 + 2);
+    this.varint(bytes.length);
+    for (const byte of bytes) this.bytes.push(byte);
+    return this;
+  }
+
+  string(field: number, value: string): this {
+    return this.message(field, Buffer.from(value, "utf-8"));
+  }
+
+  packed(field: number, values: number[]): this {
+    const inner = new ProtoWriter();
+    values.forEach((value) => inner.varint(value));
+    return this.message(field, inner.bytes);
+  }
+}
+
+/**
+ * Encode a summary as a gzipped pprof profile of wall time, in nanoseconds
+ */
+export function goPprofProfile(summary: GoProfileSummary): Buffer {
+  const strings = [""];
+  const index = (value: string) => {
+    let at = strings.indexOf(value);
+    if (at < 0) at = strings.push(value) - 1;
+    return at;
+  };
+  const profile = new ProtoWriter();
+  const valueType = () =>
+    new ProtoWriter().uint(1, index("wall")).uint(2, index("nanoseconds"));
+
+  // One function and location per frame, ids starting at 1
+  const frames = new Map<string, number>();
+  const frame = (name: string, fileName: string) => {
+    const key = `${name}\0${fileName}`;
+    if (!frames.has(key)) {
+      const id = frames.size + 1;
+      frames.set(key, id);
+      const fn = new ProtoWriter()
+        .uint(1, id)
+        .uint(2, index(name))
+        .uint(3, index(name))
+        .uint(4, index(fileName));
+      profile.message(5, fn.bytes);
+      const line = new ProtoWriter().uint(1, id);
+      const location = new ProtoWriter().uint(1, id).message(4, line.bytes);
+      profile.message(4, location.bytes);
+    }
+    return frames.get(key);
+  };
+  const nanos = (ms: number) => Math.max(0, Math.round(ms * 1e6));
+  const sample = (stack: number[], ms: number) => {
+    if (nanos(ms) === 0) return;
+    const entry = new ProtoWriter().packed(1, stack).packed(2, [nanos(ms)]);
+    profile.message(2, entry.bytes);
+  };
+
+  profile.message(1, valueType().bytes);
+  const root = frame("refactogent", "");
+  for (const phase of summary.phases) {
+    const phaseFrame = frame(phase.name, "");
+    let attributed = 0;
+    for (const file of summary.files) {
+      const time = file.phases.find((entry) => entry.name === phase.name);
+      if (!time) continue;
+      attributed += time.totalMs;
+      const fileFrame = frame(file.filePath, file.filePath);
+      sample([fileFrame, phaseFrame, root], time.totalMs);
+    }
+    sample([phaseFrame, root], phase.totalMs - attributed);
+  }
+  profile.uint(10, nanos(summary.wallMs));
+  profile.message(11, valueType().bytes);
+  profile.uint(12, 1);
+  // The string table comes last so it holds every string used above
+  strings.forEach((value) => profile.string(6, value));
+  return gzipSync(Buffer.from(profile.bytes));
+}
@@ -0,0 +1,240 @@
+import { TextEdit } from "../diff.js";
+import { PlannedChange } from "../plan.js";
+import { guardTypeAssertions } from "./assertions.js";
+import { GoFile } from "./ast.js";
+import { simplifyBoolReturns } from "./bool-return.js";
+import { removeRedundantConversions } from "./conversions.js";
+import { fixDocNames } from "./doc-sync.js";
+import { useErrorsIs } from "./error-compare.js";
+import { restyleErrorStrings } from "./errors.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { flattenNestedConditionals } from "./guard-clauses.js";
+import { keyStructLiterals } from "./keyed-literals.js";
+import { makeReturnsExplicit } from "./naked-returns.js";
+import { preallocateSlices } from "./prealloc.js";
+import { GoRefactorError, GoRefactorResult } from "./refactor.js";
+import { GoBuiltinRuleOptions } from "./rules.js";
+
+/**
+ * Quick Fixes by Rule
+ * ===================
+ * Applies the fixes of chosen rules across many files at once, leaving
+ * every other finding alone, so a rule whose fix has earned trust can be
+ * applied to a whole repository as one reviewable change. Each finding is
+ * fixed by the transform behind its rule, targeted at the finding's line,
+ * which computes the fix's edits against the file as analyzed.
+ *
+ * The fixes of a file are then taken from the bottom of the file up: a fix
+ * is kept unless one of its edits overlaps an edit of a fix already kept,
+ * in which case it is skipped and reported with the fix it conflicts with.
+ * Identical edits, such as two fixes adding the same import, are made once.
+ * The kept edits are applied bottom-up too, so no edit moves the text the
+ * remaining ones point at. A fix whose transform refuses the finding, for
+ * example because the code changed since it was reported, is skipped with
+ * the transform's reason.
+ *
+ * Only rules whose transform can target one finding have quick fixes;
+ * `unnecessary-sprintf` is left out, since whether its fix may drop the `fmt`
+ * import depends on which of the file's other calls are fixed with it.
+ */
+
+/**
+ * Computes the fix of the findings of a rule on one line of a file
+ */
+export type GoQuickFix = (
+  file: GoFile,
+  line: number,
+  files: GoFile[],
+  options: GoBuiltinRuleOptions,
+) => GoRefactorResult;
+
+/**
+ * Quick fixes of the built-in rules, by rule ID
+ */
+export const GO_QUICK_FIXES: Record<string, GoQuickFix> = {
+  "error-comparison": (file, line, files) =>
+    useErrorsIs(file, { line }, files),
+  "error-string-style": (file, line, _, options) =>
+    restyleErrorStrings(file, { ...options["error-string-style"], line }),
+  "naked-return": (file, line, _, options) =>
+    makeReturnsExplicit(file, { ...options["naked-return"], line }),
+  "nested-conditional": (file, line) =>
+    flattenNestedConditionals(file, { line }),
+  "redundant-bool-return": (file, line) => simplifyBoolReturns(file, { line }),
+  "redundant-conversion": (file, line, files) =>
+    removeRedundantConversions(file, { line }, files),
+  "slice-prealloc": (file, line) => preallocateSlices(file, { line }),
+  "stale-doc-name": (file, line) => fixDocNames(file, { line }),
+  "unchecked-type-assertion": (file, line) =>
+    guardTypeAssertions(file, { line }),
+  "unkeyed-literal": (file, line, files) =>
+    keyStructLiterals(file, { line }, files),
+};
+
+export interface GoQuickFixOptions {
+  /** IDs of the rules whose findings to fix */
+  rules: string[];
+  /** Options the rules were run with, which their fixes follow */
+  ruleOptions?: GoBuiltinRuleOptions;
+}
+
+export interface GoSkippedFix {
+  finding: GoFinding;
+  reason: string;
+}
+
+export interface GoQuickFixResult {
+  /** Every changed file, with all of its kept fixes applied */
+  changes: PlannedChange[];
+  /** Findings whose fixes were applied */
+  applied: GoFinding[];
+  /** Findings whose fixes were not, and why */
+  skipped: GoSkippedFix[];
+}
+
+interface Fix {
+  /** Findings of the rule on the fix's line, which it fixes together */
+  findings: GoFinding[];
+  edits: TextEdit[];
+}
+
+function overlaps(a: TextEdit, b: TextEdit): boolean {
+  // Insertions at one offset would land in an arbitrary order
+  if (a.start === a.end && b.start === b.end) return a.start === b.start;
+  return a.start < b.end && b.start < a.end;
+}
+
+function same(a: TextEdit, b: TextEdit): boolean {
+  return a.start === b.start && a.end === b.end && a.newText === b.newText;
+}
+
+// Where the lowest edit of a fix starts, which orders fixes bottom-up
+function bottom(fix: Fix): number {
+  return Math.max(...fix.edits.map((edit) => edit.start));
+}
+
+/**
+ * The rule IDs with quick fixes, sorted
+ */
+export function goQuickFixRules(): string[] {
+  return Object.keys(GO_QUICK_FIXES).sort();
+}
+
+/**
+ * Apply the fixes of the findings of the given rules. `files` are the files
+ * the findings were reported on, and `findings` may hold other rules' too,
+ * which are ignored. Nothing is written.
+ */
+export function applyGoQuickFixes(
+  files: GoFile[],
+  findings: GoFinding[],
+  options: GoQuickFixOptions,
+): GoQuickFixResult {
+  for (const rule of options.rules) {
+    if (!GO_QUICK_FIXES[rule]) {
+      throw new GoRefactorError(
+        `${rule} has no quick fix; rules with one are ${goQuickFixRules().join(", ")}`,
+      );
+    }
+  }
+  const rules = new Set(options.rules);
+  const byPath = new Map(files.map((file) => [file.filePath, file]));
+  const applied: GoFinding[] = [];
+  const skipped: GoSkippedFix[] = [];
+  const skip = (fixed: GoFinding[], reason: string) =>
+    skipped.push(...fixed.map((finding) => ({ finding, reason })));
+
+  const byFile = new Map<string, GoFinding[]>();
+  for (const finding of findings) {
+    if (!rules.has(finding.rule)) continue;
+    if (!byPath.has(finding.filePath)) {
+      skip([finding], "the file was not analyzed");
+      continue;
+    }
+    byFile.set(finding.filePath, [
+      ...(byFile.get(finding.filePath) ?? []),
+      finding,
+    ]);
+  }
+
+  const changes: PlannedChange[] = [];
+  for (const [filePath, reported] of byFile) {
+    const file = byPath.get(filePath);
+    const fixes = new Map<string, Fix>();
+    for (const finding of reported) {
+      const key = `${finding.rule}:${finding.line}`;
+      const fix = fixes.get(key);
+      if (fix) {
+        fix.findings.push(finding);
+        continue;
+      }
+      const fixAt = GO_QUICK_FIXES[finding.rule];
+      try {
+        const { edits } = fixAt(
+          file,
+          finding.line,
+          files,
+          options.ruleOptions ?? {},
+        );
+        fixes.set(key, { findings: [finding], edits });
+      } catch (error) {
+        if (!(error instanceof GoRefactorError)) throw error;
+        skip([finding], error.message);
+      }
+    }
+
+    const kept: Fix[] = [];
+    const edits: TextEdit[] = [];
+    const ordered = [...fixes.values()]
+      .filter((fix) => {
+        if (fix.edits.length > 0) return true;
+        skip(fix.findings, "the fix changes nothing");
+        return false;
+      })
+      .sort((a, b) => bottom(b) - bottom(a));
+    for (const fix of ordered) {
+      const added = fix.edits.filter(
+        (edit) => !edits.some((other) => same(edit, other)),
+      );
+      const conflict = kept.find((other) =>
+        other.edits.some((edit) =>
+          added.some((candidate) => overlaps(candidate, edit)),
+        ),
+      );
+      if (conflict) {
+        const [{ rule, line }] = conflict.findings;
+        const reason = `conflicts with the fix for ${rule} on line ${line}`;
+        skip(fix.findings, reason);
+        continue;
+      }
+      kept.push(fix);
+      edits.push(...added);
+      applied.push(...fix.findings);
+    }
+    if (edits.length === 0) continue;
+
+    // Bottom-up, an edit leaves the offsets of the edits above it valid; an
+    // insertion goes before a replacement starting where it does
+    let content = file.source;
+    for (const edit of [...edits].sort(
+      (a, b) => b.start - a.start || b.end - a.end,
+    )) {
+      content =
+        content.slice(0, edit.start) + edit.newText + content.slice(edit.end);
+    }
+    changes.push({
+      filePath,
+      original: file.source,
+      content,
+      symbols: [...new Set(kept.map((fix) => fix.findings[0].rule))].sort(),
+    });
+  }
+  const order = sortFindings(skipped.map(({ finding }) => finding));
+  return {
+    changes,
+    applied: sortFindings(applied),
+    skipped: skipped.sort(
+      (a, b) => order.indexOf(a.finding) - order.indexOf(b.finding),
+    ),
+  };
+}
@@ -0,0 +1,291 @@
+import {
+  Expr,
+  ForStmt,
+  FuncDecl,
+  GoFile,
+  Ident,
+  IndexExpr,
+  Node,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+
+/**
+ * Index Loops
+ * ===========
+ * A C-style loop `for i := 0; i < len(s); i++` whose index is only ever used
+ * to subscript `s` says with bookkeeping what `range` says directly. This
+ * pass flags such loops and suggests the range form: `for _, v := range s`
+ * with each `s[i]` read as `v` when the elements are only read, and
+ * `for i := range s` when some are assigned, incremented, have their address
+ * taken or have methods called on them, which the copy in `v` would not see.
+ *
+ * Only loops the rewrite keeps equivalent are reported: the index starts at
+ * zero, runs to the full length and steps by one; the body uses it for
+ * nothing but `s[i]` and never assigns it, and never reassigns `s` or a
+ * variable or field it is reached through, since the range form evaluates
+ * `s` once. A loop starting elsewhere, such as the one in
+ * `CalculateFibonacci`, stopping short of the length or skipping ahead on
+ * its own is left alone. Strings, whose range yields runes rather than
+ * bytes, channels and maps, whose keys need not run from zero to the
+ * length, are skipped when their type is known.
+ */
+
+export interface GoIndexLoopFinding extends GoFinding {
+  rule: "index-loop";
+  /** Name of the index variable */
+  index: string;
+  /** Source text of the collection, e.g. `p.items` */
+  collection: string;
+  /** Name of the value variable the range form introduces, if any */
+  value?: string;
+}
+
+interface IndexLoop {
+  loop: ForStmt;
+  index: GoVariable;
+  collection: Expr;
+  /** Every `s[i]` in the body */
+  elements: IndexExpr[];
+  /** Elements the body changes or calls methods on */
+  mutated: Set<IndexExpr>;
+}
+
+// Strings range over runes, channels receive and maps yield their keys
+const NOT_INDEXED = /^(string|map\[|chan\b|<-chan\b)/;
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+// Whether an expression is a variable or a chain of field selections
+function isPath(expr: Expr): boolean {
+  if (expr.kind === "Ident") return true;
+  return expr.kind === "SelectorExpr" && isPath(expr.x);
+}
+
+// Plural collection names suggest their element: `items` holds an `item`
+function valueBase(collection: Expr): string {
+  const name =
+    collection.kind === "SelectorExpr"
+      ? collection.sel.name
+      : collection.kind === "Ident"
+        ? collection.name
+        : "";
+  return /^[a-z]\w*[^s]s$/.test(name) ? name.slice(0, -1) : "v";
+}
+
+class IndexLoopAnalyzer {
+  private readonly file: GoFile;
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private isIndex(expr: Expr, index: GoVariable): boolean {
+    const ident = unparen(expr);
+    return ident.kind === "Ident" && this.scopes.resolved.get(ident) === index;
+  }
+
+  // The index `i := 0`, when the header counts it from zero to `len(s)`
+  private header(
+    loop: ForStmt,
+  ): { index: GoVariable; collection: Expr } | undefined {
+    const { init, cond, post } = loop;
+    if (init?.kind !== "AssignStmt" || init.tok !== ":=") return undefined;
+    if (init.lhs.length !== 1 || init.rhs.length !== 1) return undefined;
+    const [ident, start] = [init.lhs[0], init.rhs[0]];
+    if (ident.kind !== "Ident" || this.text(start) !== "0") return undefined;
+    const index = this.scopes.resolved.get(ident);
+    if (!index) return undefined;
+
+    if (cond?.kind !== "BinaryExpr" || cond.op !== "<") return undefined;
+    const length = unparen(cond.y);
+    if (!this.isIndex(cond.x, index) || length.kind !== "CallExpr") {
+      return undefined;
+    }
+    if (this.text(length.fun) !== "len" || length.args.length !== 1) {
+      return undefined;
+    }
+    const collection = unparen(length.args[0]);
+    if (!isPath(collection)) return undefined;
+    if (post?.kind !== "IncDecStmt" || post.tok !== "++") return undefined;
+    if (!this.isIndex(post.x, index)) return undefined;
+
+    const type = this.types.typeOf(collection);
+    const resolved = type && (this.types.underlying(type) ?? type);
+    if (resolved && NOT_INDEXED.test(resolved)) return undefined;
+    return { index, collection };
+  }
+
+  // Whether an assignment target replaces the collection or what holds it
+  private replaces(target: Expr, collection: string): boolean {
+    const text = this.text(unparen(target));
+    return collection === text || collection.startsWith(`${text}.`);
+  }
+
+  private indexLoop(loop: ForStmt): IndexLoop | undefined {
+    const header = this.header(loop);
+    if (!header) return undefined;
+    const { index, collection } = header;
+    const name = this.text(collection);
+    const elements: IndexExpr[] = [];
+    const subscripts = new Set<Ident>();
+    let replaced = false;
+    inspect(loop.body, (node) => {
+      if (
+        node.kind === "IndexExpr" &&
+        this.isIndex(node.index, index) &&
+        this.text(unparen(node.x)) === name
+      ) {
+        elements.push(node);
+        subscripts.add(unparen(node.index) as Ident);
+      } else if (node.kind === "AssignStmt") {
+        if (node.lhs.some((target) => this.replaces(target, name))) {
+          replaced = true;
+        }
+      } else if (node.kind === "UnaryExpr" && node.op === "&") {
+        // A pointer could replace it behind the loop's back
+        if (this.replaces(node.x, name)) replaced = true;
+      }
+    });
+    if (replaced || elements.length === 0) return undefined;
+    // The index must serve as nothing but the subscript
+    let other = false;
+    inspect(loop.body, (node) => {
+      if (
+        node.kind === "Ident" &&
+        this.scopes.resolved.get(node) === index &&
+        !subscripts.has(node)
+      ) {
+        other = true;
+      }
+    });
+    if (other) return undefined;
+    return {
+      loop,
+      index,
+      collection,
+      elements,
+      mutated: this.mutated(loop, elements),
+    };
+  }
+
+  // Elements written through, addressed or used as method receivers
+  private mutated(loop: ForStmt, elements: IndexExpr[]): Set<IndexExpr> {
+    const candidates = new Set(elements);
+    const mutated = new Set<IndexExpr>();
+    const through = (expr: Expr) => {
+      let current = expr;
+      for (;;) {
+        if (candidates.has(current as IndexExpr)) {
+          mutated.add(current as IndexExpr);
+          return;
+        }
+        if (
+          current.kind === "ParenExpr" ||
+          current.kind === "SelectorExpr" ||
+          current.kind === "IndexExpr" ||
+          current.kind === "StarExpr"
+        ) {
+          current = current.x;
+        } else {
+          return;
+        }
+      }
+    };
+    inspect(loop.body, (node) => {
+      if (node.kind === "AssignStmt" && node.tok !== ":=") {
+        node.lhs.forEach(through);
+      } else if (node.kind === "IncDecStmt") {
+        through(node.x);
+      } else if (node.kind === "UnaryExpr" && node.op === "&") {
+        through(node.x);
+      } else if (node.kind === "CallExpr") {
+        const fun = unparen(node.fun);
+        if (fun.kind === "SelectorExpr") through(fun.x);
+      }
+    });
+    return mutated;
+  }
+
+  private valueName(decl: FuncDecl, candidate: IndexLoop): string {
+    const taken = new Set<string>();
+    inspect(decl, (node) => {
+      if (node.kind === "Ident") taken.add(node.name);
+    });
+    const base = valueBase(candidate.collection);
+    let name = base;
+    for (let n = 2; taken.has(name); n++) name = `${base}${n}`;
+    return name;
+  }
+
+  private finding(decl: FuncDecl, candidate: IndexLoop): GoIndexLoopFinding {
+    const { loop, index, collection, elements, mutated } = candidate;
+    const coll = this.text(collection);
+    const value = mutated.size === 0 ? this.valueName(decl, candidate) : "";
+    let body = this.text(loop.body);
+    if (value) {
+      for (const element of [...elements].reverse()) {
+        const start = element.pos - loop.body.pos;
+        const end = element.end - loop.body.pos;
+        body = body.slice(0, start) + value + body.slice(end);
+      }
+    }
+    const suggestion = value
+      ? `for _, ${value} := range ${coll}`
+      : `for ${index.name} := range ${coll}`;
+    const uses = value ? `read ${coll}[${index.name}]` : "subscript it";
+    return {
+      rule: "index-loop",
+      severity: "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(loop.pos),
+      message: `Loop uses ${index.name} only to ${uses}; write it as ${suggestion}`,
+      fix: `${suggestion} ${body}`,
+      index: index.name,
+      collection: coll,
+      ...(value && { value }),
+    };
+  }
+
+  private analyzeFunction(decl: FuncDecl): GoIndexLoopFinding[] {
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(this.file, this.scopes);
+    const findings: GoIndexLoopFinding[] = [];
+    inspect(decl.body, (node) => {
+      if (node.kind !== "ForStmt") return;
+      const candidate = this.indexLoop(node);
+      if (candidate) findings.push(this.finding(decl, candidate));
+    });
+    return findings;
+  }
+
+  analyze(): GoIndexLoopFinding[] {
+    return this.file.decls.flatMap((decl) =>
+      decl.kind === "FuncDecl" && decl.body ? this.analyzeFunction(decl) : [],
+    );
+  }
+}
+
+/**
+ * Find index loops over the full length of a slice or array that use the
+ * index only to subscript it
+ */
+export function findIndexLoops(files: GoFile[]): GoIndexLoopFinding[] {
+  return sortFindings(
+    files.flatMap((file) => new IndexLoopAnalyzer(file).analyze()),
+  );
+}
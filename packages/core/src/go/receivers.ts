@@ -0,0 +1,437 @@
+import { TextEdit } from "../diff.js";
+import { FuncDecl, GoFile, Ident, Node, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Go Receivers
+ * ============
+ * Go style gives all methods of a type the same receiver name. This pass
+ * groups methods by receiver type, package by package, and flags the
+ * methods whose receiver is named differently from the rest, suggesting the
+ * name most methods use; on a tie, the name of the first method in the
+ * source wins. Unnamed and blank receivers are never referenced, so they
+ * are neither counted nor flagged. A type with one named receiver is
+ * consistent by definition.
+ *
+ * {@link renameReceivers} applies the suggestion, renaming the receiver of
+ * every method of the type along with its uses in the method bodies.
+ *
+ * Go style also gives all methods of a type the same kind of receiver,
+ * pointer or value, so the method set of `T` and `*T` is easy to predict.
+ * {@link findMixedReceivers} flags the methods of a type whose receiver kind
+ * differs from the one it recommends: pointer receivers when any method
+ * modifies the receiver, assigning to or locking one of its fields, since a
+ * value receiver only sees a copy; otherwise the kind most methods use,
+ * pointers on a tie. A value method implementing an interface declared in
+ * the package, or a well-known one such as `fmt.Stringer`, may have to stay
+ * one so values of the type, not only pointers, satisfy the interface; such
+ * methods are named in the explanation but never flagged.
+ */
+
+export interface GoReceiverNameFinding extends GoFinding {
+  rule: "inconsistent-receiver";
+  /** The receiver's type, without `*` or type parameters */
+  type: string;
+  method: string;
+  receiver: string;
+  suggested: string;
+}
+
+export interface GoMixedReceiverFinding extends GoFinding {
+  rule: "mixed-receiver-kind";
+  /** The receiver's type, without `*` or type parameters */
+  type: string;
+  method: string;
+  /** The method's receiver kind */
+  receiver: "pointer" | "value";
+  suggested: "pointer" | "value";
+  /** Value methods left alone because an interface may need them */
+  kept: string[];
+}
+
+export interface RenameReceiversOptions {
+  /** The receiver type whose methods to update */
+  type: string;
+  /** New receiver name (default: the most common one) */
+  name?: string;
+}
+
+export interface RenameReceiversResult {
+  type: string;
+  name: string;
+  /** Changed files */
+  files: GoRefactorResult[];
+  /** Methods whose receiver was renamed */
+  methods: string[];
+}
+
+interface Method {
+  file: GoFile;
+  decl: FuncDecl;
+  /** Receiver name; undefined for unnamed and blank receivers */
+  name?: Ident;
+}
+
+// Methods of each receiver type, in source order
+function methodsByType(files: GoFile[]): Map<string, Method[]> {
+  const types = new Map<string, Method[]>();
+  for (const file of files) {
+    for (const decl of file.decls) {
+      const field = decl.kind === "FuncDecl" ? decl.recv?.list[0] : undefined;
+      if (!field) continue;
+      const type = baseTypeName(field.type).name;
+      const ident = field.names[0];
+      if (!types.has(type)) types.set(type, []);
+      types.get(type).push({
+        file,
+        decl: decl as FuncDecl,
+        name: ident && ident.name !== "_" ? ident : undefined,
+      });
+    }
+  }
+  return types;
+}
+
+// The receiver name most methods use, the earliest on a tie
+function mostCommon(methods: Method[]): string | undefined {
+  const counts = new Map<string, number>();
+  for (const { name } of methods) {
+    if (name) counts.set(name.name, (counts.get(name.name) ?? 0) + 1);
+  }
+  let best: string | undefined;
+  for (const [name, count] of counts) {
+    if (best === undefined || count > counts.get(best)) best = name;
+  }
+  return best;
+}
+
+/**
+ * Find methods whose receiver name differs from the one the other methods of
+ * the type use
+ */
+export function findInconsistentReceivers(
+  files: GoFile[],
+): GoReceiverNameFinding[] {
+  const findings: GoReceiverNameFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    for (const [type, methods] of methodsByType(group)) {
+      const suggested = mostCommon(methods);
+      for (const { file, decl, name } of methods) {
+        if (!name || name.name === suggested) continue;
+        findings.push({
+          rule: "inconsistent-receiver",
+          severity: "low",
+          filePath: file.filePath,
+          ...file.sourceMap.position(name.pos),
+          message: `Receiver ${name.name} of ${type}.${decl.name.name} differs from ${suggested}, the name the other methods of ${type} use`,
+          fix: suggested,
+          type,
+          method: decl.name.name,
+          receiver: name.name,
+          suggested,
+        });
+      }
+    }
+  }
+  return sortFindings(findings);
+}
+
+// Standard interfaces values commonly implement, by method name
+const VALUE_INTERFACES = new Map([
+  ["Error", "error"],
+  ["Format", "fmt.Formatter"],
+  ["GoString", "fmt.GoStringer"],
+  ["MarshalBinary", "encoding.BinaryMarshaler"],
+  ["MarshalJSON", "json.Marshaler"],
+  ["MarshalText", "encoding.TextMarshaler"],
+  ["MarshalYAML", "yaml.Marshaler"],
+  ["String", "fmt.Stringer"],
+  ["Value", "driver.Valuer"],
+]);
+
+// Locking a field's mutex needs the original, not a copy
+const LOCKS = new Set(["Lock", "Unlock", "RLock", "RUnlock"]);
+
+function list(names: string[]): string {
+  return names.length === 1
+    ? names[0]
+    : `${names.slice(0, -1).join(", ")} and ${names.at(-1)}`;
+}
+
+function isPointerReceiver(decl: FuncDecl): boolean {
+  return baseTypeName(decl.recv.list[0].type).isPointer;
+}
+
+// The identifier a chain of field selections starts from, if it has one
+function selectedFrom(expr: Node): Ident | undefined {
+  let current = expr;
+  let selected = false;
+  for (;;) {
+    if (current.kind === "SelectorExpr") {
+      selected = true;
+      current = current.x;
+    } else if (current.kind === "ParenExpr" || current.kind === "StarExpr") {
+      selected = true;
+      current = current.x;
+    } else {
+      break;
+    }
+  }
+  return selected && current.kind === "Ident" ? current : undefined;
+}
+
+// Whether a method assigns to, takes the address of or locks a receiver field
+function modifiesReceiver(decl: FuncDecl): boolean {
+  if (!decl.body) return false;
+  const scopes = resolveFunctionScopes(decl);
+  const receiver = scopes.variables.find((v) => v.kind === "receiver");
+  if (!receiver) return false;
+  const isReceiver = (expr: Node) => {
+    const ident = selectedFrom(expr);
+    return ident !== undefined && scopes.resolved.get(ident) === receiver;
+  };
+  let modifies = false;
+  inspect(decl.body, (node) => {
+    if (node.kind === "AssignStmt" && node.tok !== ":=") {
+      modifies = node.lhs.some(isReceiver);
+    } else if (node.kind === "IncDecStmt") {
+      modifies = isReceiver(node.x);
+    } else if (node.kind === "UnaryExpr" && node.op === "&") {
+      modifies = isReceiver(node.x);
+    } else if (
+      node.kind === "CallExpr" &&
+      node.fun.kind === "SelectorExpr" &&
+      LOCKS.has(node.fun.sel.name)
+    ) {
+      modifies = isReceiver(node.fun.x);
+    }
+    return !modifies;
+  });
+  return modifies;
+}
+
+function arity(list: { list: { names: Ident[] }[] } | undefined): number {
+  return (list?.list ?? []).reduce(
+    (count, field) => count + Math.max(field.names.length, 1),
+    0,
+  );
+}
+
+// Interfaces declared in a package, by name, with their own methods' arities
+function packageInterfaces(files: GoFile[]): Map<string, Map<string, number>> {
+  const interfaces = new Map<string, Map<string, number>>();
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+      for (const spec of decl.specs) {
+        if (spec.kind !== "TypeSpec" || spec.type.kind !== "InterfaceType") {
+          continue;
+        }
+        const methods = new Map<string, number>();
+        for (const field of spec.type.methods.list) {
+          if (field.type.kind !== "FuncType") continue;
+          for (const name of field.names) {
+            methods.set(name.name, arity(field.type.params));
+          }
+        }
+        if (methods.size > 0) interfaces.set(spec.name.name, methods);
+      }
+    }
+  }
+  return interfaces;
+}
+
+// Value methods an interface may need, with the interface for each
+function interfaceMethods(
+  values: Method[],
+  interfaces: Map<string, Map<string, number>>,
+): Map<Method, string> {
+  const kept = new Map<Method, string>();
+  const byName = new Map(
+    values.map((method) => [method.decl.name.name, method]),
+  );
+  for (const [name, methods] of interfaces) {
+    const implementing = [...methods].map(([method, count]) => {
+      const value = byName.get(method);
+      return value && arity(value.decl.type.params) === count
+        ? value
+        : undefined;
+    });
+    if (implementing.every((method) => method !== undefined)) {
+      for (const method of implementing) {
+        if (!kept.has(method)) kept.set(method, name);
+      }
+    }
+  }
+  for (const method of values) {
+    const name = VALUE_INTERFACES.get(method.decl.name.name);
+    if (name && !kept.has(method)) kept.set(method, name);
+  }
+  return kept;
+}
+
+/**
+ * Find methods whose receiver is a pointer where the type's other methods
+ * take values, or the other way around
+ */
+export function findMixedReceivers(files: GoFile[]): GoMixedReceiverFinding[] {
+  const findings: GoMixedReceiverFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    const interfaces = packageInterfaces(group);
+    for (const [type, methods] of methodsByType(group)) {
+      const pointers = methods.filter(({ decl }) => isPointerReceiver(decl));
+      const values = methods.filter(({ decl }) => !isPointerReceiver(decl));
+      const kept = interfaceMethods(values, interfaces);
+      const unkept = values.filter((method) => !kept.has(method));
+      if (pointers.length === 0 || unkept.length === 0) continue;
+
+      const modifying = methods.find(({ decl }) => modifiesReceiver(decl));
+      const suggested =
+        modifying || pointers.length >= unkept.length ? "pointer" : "value";
+      const keptNames = [...kept.keys()].map(({ decl }) => decl.name.name);
+      const satisfied = [...new Set(kept.values())];
+      const note =
+        keptNames.length === 0
+          ? ""
+          : keptNames.length === 1
+            ? `; ${keptNames[0]} keeps its value receiver so values of ${type} still satisfy ${list(satisfied)}`
+            : `; ${list(keptNames)} keep their value receivers so values of ${type} still satisfy ${list(satisfied)}`;
+      const others = suggested === "pointer" ? pointers : unkept;
+      const flagged = suggested === "pointer" ? unkept : pointers;
+      for (const { file, decl } of flagged) {
+        const method = decl.name.name;
+        const reason =
+          suggested === "value"
+            ? `no method modifies the receiver, and ${others.length} of ${type}'s methods take values`
+            : modifiesReceiver(decl)
+              ? `${method} modifies the receiver, which a value receiver only does to a copy`
+              : modifying
+                ? `${modifying.decl.name.name} modifies the receiver`
+                : `${others.length} of ${type}'s methods take pointers`;
+        findings.push({
+          rule: "mixed-receiver-kind",
+          severity: "low",
+          filePath: file.filePath,
+          ...file.sourceMap.position(decl.recv.list[0].type.pos),
+          message: `${type}.${method} has a ${suggested === "pointer" ? "value" : "pointer"} receiver unlike the other methods of ${type}; give every method a ${suggested} receiver, since ${reason}${note}`,
+          type,
+          method,
+          receiver: suggested === "pointer" ? "value" : "pointer",
+          suggested,
+          kept: keptNames,
+        });
+      }
+    }
+  }
+  return sortFindings(findings);
+}
+
+// Why a receiver cannot be renamed in a method, if it cannot
+function renameConflict(method: Method, newName: string): string | undefined {
+  const { decl } = method;
+  const scopes = resolveFunctionScopes(decl);
+  const receiver = scopes.variables.find((v) => v.kind === "receiver");
+  if (
+    scopes.variables.some(
+      (variable) => variable !== receiver && variable.name === newName,
+    )
+  ) {
+    return `${decl.name.name} already declares ${newName}`;
+  }
+  // The new name would hide a package-level name or import the body uses
+  let hidden = false;
+  if (decl.body) {
+    inspect(decl.body, (node, parents) => {
+      const parent: Node | undefined = parents.at(-1);
+      if (
+        node.kind === "Ident" &&
+        node.name === newName &&
+        !scopes.resolved.has(node) &&
+        !(parent?.kind === "SelectorExpr" && parent.sel === node) &&
+        !(parent?.kind === "KeyValueExpr" && parent.key === node)
+      ) {
+        hidden = true;
+      }
+      return !hidden;
+    });
+  }
+  return hidden
+    ? `${decl.name.name} uses ${newName} from outside the method`
+    : undefined;
+}
+
+/**
+ * Rename the receivers of every method of a type, and their uses
+ */
+export function renameReceivers(
+  files: GoFile[],
+  options: RenameReceiversOptions,
+): RenameReceiversResult {
+  let methods: Method[] | undefined;
+  let group: GoFile[] = [];
+  for (const candidate of groupGoPackages(files)) {
+    const found = methodsByType(candidate).get(options.type);
+    if (found) {
+      methods = found;
+      group = candidate;
+    }
+  }
+  if (!methods) {
+    throw new GoRefactorError(
+      `${options.type} has no methods in the analyzed files`,
+    );
+  }
+  const name = options.name ?? mostCommon(methods);
+  if (name === undefined) {
+    throw new GoRefactorError(
+      `No method of ${options.type} names its receiver; give the name to use`,
+    );
+  }
+  if (!/^[\p{L}_][\p{L}\p{Nd}_]*$/u.test(name) || name === "_") {
+    throw new GoRefactorError(`${name} is not a valid receiver name`);
+  }
+
+  const edits = new Map<GoFile, TextEdit[]>();
+  const renamed: string[] = [];
+  for (const method of methods) {
+    if (!method.name || method.name.name === name) continue;
+    const conflict = renameConflict(method, name);
+    if (conflict) {
+      throw new GoRefactorError(`Cannot rename the receiver: ${conflict}`);
+    }
+    const scopes = resolveFunctionScopes(method.decl);
+    const receiver = scopes.resolved.get(method.name);
+    const sites = new Set([
+      method.name,
+      ...scopes.references
+        .filter((reference) => reference.variable === receiver)
+        .map((reference) => reference.ident),
+    ]);
+    if (!edits.has(method.file)) edits.set(method.file, []);
+    edits.get(method.file).push(
+      ...[...sites].map((ident) => ({
+        start: ident.pos,
+        end: ident.end,
+        newText: name,
+      })),
+    );
+    renamed.push(method.decl.name.name);
+  }
+
+  return {
+    type: options.type,
+    name,
+    files: group
+      .filter((file) => edits.has(file))
+      .map((file) => refactorResult(file, edits.get(file))),
+    methods: renamed,
+  };
+}
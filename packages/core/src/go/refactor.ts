@@ -0,0 +1,41 @@
+import { applyEdits, TextEdit, unifiedDiff } from "../diff.js";
+import { GoFile } from "./ast.js";
+
+/**
+ * Error raised when a refactor cannot be applied safely
+ */
+export class GoRefactorError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoRefactorError";
+  }
+}
+
+/**
+ * Outcome of a source-to-source refactor. Nothing is written to disk; callers
+ * review the diff and decide whether to apply the new source.
+ */
+export interface GoRefactorResult {
+  filePath: string;
+  edits: TextEdit[];
+  /** Full source of the file after the edits */
+  source: string;
+  /** Unified diff from the original source to the new one */
+  diff: string;
+}
+
+/**
+ * Apply edits to a parsed file and render the change
+ */
+export function refactorResult(
+  file: GoFile,
+  edits: TextEdit[],
+): GoRefactorResult {
+  const source = applyEdits(file.source, edits);
+  return {
+    filePath: file.filePath,
+    edits,
+    source,
+    diff: unifiedDiff(file.source, source, { oldPath: file.filePath }),
+  };
+}
@@ -0,0 +1,247 @@
+import { FuncDecl, GoFile, Node, inspect } from "./ast.js";
+import { callGraphId } from "./callgraph.js";
+import { compareCodePoints } from "./findings.js";
+import { GoNameDeclaration, indexGoPackageNames } from "./naming.js";
+import { groupGoPackages } from "./package.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Go Reference Index
+ * ==================
+ * A reverse index answering "where is this symbol referenced?" for editors
+ * and impact estimates. Symbols are the package-level functions, types,
+ * variables and constants of the analyzed packages, and the fields and
+ * methods of their named types, identified like call graph nodes:
+ * `pkg.Name`, or `pkg.Type.Member` for members. Each reference is either the
+ * symbol's definition or a use, and says where a use sits, so a type's
+ * method receivers, parameters, results, variable declarations and
+ * composite literals can be told apart from its other mentions.
+ *
+ * The index is built once, from the naming index of each package, and a
+ * query is a map lookup. Like the rename it is built on, it resolves names
+ * without type information: a use of a field or method named like a member
+ * of another type in the package is listed for each and marked ambiguous.
+ * References from other packages, which reach a symbol through an import,
+ * are not resolved.
+ */
+
+/**
+ * Where a use sits: the type of a receiver, parameter, result, variable or
+ * field, the type of a composite literal, the callee of a call or
+ * conversion, or anything else. Definitions sit at their `declaration`.
+ */
+export type GoReferenceContext =
+  | "declaration"
+  | "receiver"
+  | "parameter"
+  | "result"
+  | "variable"
+  | "field"
+  | "literal"
+  | "call"
+  | "other";
+
+export interface GoSymbolReference {
+  filePath: string;
+  line: number;
+  column: number;
+  role: "definition" | "use";
+  context: GoReferenceContext;
+  /** Call graph id of the function or method the reference is in */
+  enclosing?: string;
+  /** Set on uses that may refer to a same-named member of another type */
+  ambiguous?: boolean;
+}
+
+function functionId(pkg: string, decl: FuncDecl): string {
+  const recv = decl.recv?.list[0];
+  const qualifiedName = recv
+    ? `${baseTypeName(recv.type).name}.${decl.name.name}`
+    : decl.name.name;
+  return callGraphId(pkg, { qualifiedName });
+}
+
+// Nodes a type is written inside of without changing what it names
+const TYPE_WRAPPERS = new Set([
+  "ParenExpr",
+  "StarExpr",
+  "ArrayType",
+  "MapType",
+  "ChanType",
+  "Ellipsis",
+  "IndexExpr",
+  "IndexListExpr",
+]);
+
+// Where an identifier sits, from its ancestors, innermost last
+function contextOf(ident: Node, parents: Node[]): GoReferenceContext {
+  let node = ident;
+  let depth = parents.length - 1;
+  const parent = parents[depth];
+  if (parent?.kind === "SelectorExpr" && parent.sel === ident) {
+    node = parent;
+    depth--;
+  }
+  while (depth >= 0 && TYPE_WRAPPERS.has(parents[depth].kind)) {
+    const wrapper = parents[depth];
+    // An index names an element, not the type being instantiated
+    if (wrapper.kind === "IndexExpr" && wrapper.x !== node) return "other";
+    node = wrapper;
+    depth--;
+  }
+  const [owner, list, holder] = [
+    parents[depth],
+    parents[depth - 1],
+    parents[depth - 2],
+  ];
+  switch (owner?.kind) {
+    case "Field":
+      if (owner.type !== node) return "other";
+      if (holder?.kind === "FuncDecl" && holder.recv === list) {
+        return "receiver";
+      }
+      if (holder?.kind === "FuncType") {
+        return holder.results === list ? "result" : "parameter";
+      }
+      return holder?.kind === "StructType" ? "field" : "other";
+    case "ValueSpec":
+      return owner.type === node ? "variable" : "other";
+    case "CompositeLit":
+      return owner.type === node ? "literal" : "other";
+    case "CallExpr":
+      return owner.fun === node ? "call" : "other";
+    default:
+      return "other";
+  }
+}
+
+/**
+ * Index of the references to the symbols of Go packages
+ */
+export class GoReferenceIndex {
+  private readonly bySymbol = new Map<string, GoSymbolReference[]>();
+
+  constructor(files: GoFile[]) {
+    for (const group of groupGoPackages(files)) {
+      this.indexPackage(group);
+    }
+    for (const references of this.bySymbol.values()) {
+      references.sort(
+        (a, b) =>
+          compareCodePoints(a.filePath, b.filePath) ||
+          a.line - b.line ||
+          a.column - b.column,
+      );
+    }
+  }
+
+  /**
+   * Ids of the indexed symbols, sorted
+   */
+  symbols(): string[] {
+    return [...this.bySymbol.keys()].sort(compareCodePoints);
+  }
+
+  /**
+   * Definitions and uses of a symbol, in file and source order; none for
+   * ids the index does not know
+   */
+  references(id: string): GoSymbolReference[] {
+    return this.bySymbol.get(id) ?? [];
+  }
+
+  private indexPackage(group: GoFile[]): void {
+    const pkg = group[0].packageName.name;
+    // Members of named types, by the identifier declaring them
+    const owners = new Map<Node, string>();
+    // What each identifier sits in, recorded on one walk of the files
+    const sites = new Map<Node, Omit<GoSymbolReference, "role">>();
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind === "FuncDecl") {
+          const recv = decl.recv?.list[0];
+          if (recv) owners.set(decl.name, baseTypeName(recv.type).name);
+        } else if (decl.tok === "type") {
+          for (const spec of decl.specs) {
+            if (spec.kind !== "TypeSpec") continue;
+            const fields =
+              spec.type.kind === "StructType"
+                ? spec.type.fields.list
+                : spec.type.kind === "InterfaceType"
+                  ? spec.type.methods.list
+                  : [];
+            for (const field of fields) {
+              field.names.forEach((ident) =>
+                owners.set(ident, spec.name.name),
+              );
+            }
+          }
+        }
+      }
+      inspect(file, (node, parents) => {
+        if (node.kind !== "Ident") return;
+        const decl = parents[1];
+        const enclosing =
+          decl?.kind === "FuncDecl" ? functionId(pkg, decl) : undefined;
+        sites.set(node, {
+          filePath: file.filePath,
+          ...file.sourceMap.position(node.pos),
+          context: contextOf(node, parents),
+          ...(enclosing && { enclosing }),
+        });
+      });
+    }
+
+    const names = indexGoPackageNames(group);
+    const declared = new Set(
+      names.declarations.map((declaration) => declaration.node),
+    );
+    const members = new Map<string, GoNameDeclaration[]>();
+    for (const declaration of names.declarations) {
+      if (declaration.namespace !== "member") continue;
+      const same = members.get(declaration.name) ?? [];
+      members.set(declaration.name, [...same, declaration]);
+    }
+
+    const withUses = new Set<string>();
+    for (const declaration of names.declarations) {
+      let id: string;
+      if (declaration.namespace === "package") {
+        id = `${pkg}.${declaration.name}`;
+      } else if (owners.has(declaration.node)) {
+        id = `${pkg}.${owners.get(declaration.node)}.${declaration.name}`;
+      } else {
+        // Locals, and fields of anonymous structs, have no id
+        continue;
+      }
+      const ambiguous =
+        declaration.namespace === "member" &&
+        members.get(declaration.name).length > 1;
+      const references = this.bySymbol.get(id) ?? [];
+      this.bySymbol.set(id, references);
+      references.push({
+        ...sites.get(declaration.node),
+        role: "definition",
+        context: "declaration",
+      });
+      // Several definitions with one id, such as init functions, share uses
+      if (withUses.has(id)) continue;
+      withUses.add(id);
+      for (const use of names.sites(declaration)) {
+        if (declared.has(use.node)) continue;
+        references.push({
+          ...sites.get(use.node),
+          role: "use",
+          ...(ambiguous && { ambiguous }),
+        });
+      }
+    }
+  }
+}
+
+/**
+ * Index the references to the symbols declared in Go files
+ */
+export function indexGoReferences(files: GoFile[]): GoReferenceIndex {
+  return new GoReferenceIndex(files);
+}
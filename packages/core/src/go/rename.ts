@@ -0,0 +1,353 @@
+import * as path from "path";
+import { TextEdit } from "../diff.js";
+import { CommentGroup, Decl, GoFile, Node, inspect } from "./ast.js";
+import { compareCodePoints } from "./findings.js";
+import { importName } from "./imports.js";
+import { GO_KEYWORDS } from "./lexer.js";
+import {
+  GoNameDeclaration,
+  GoNamespace,
+  GoNameSite,
+  GoPackageNames,
+  indexGoPackageNames,
+} from "./naming.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Rename Symbol
+ * =============
+ * Renames a package-level type, function, variable or constant, or a method
+ * or field of a named type, together with every reference to it in the files
+ * of its package: uses, method receivers, composite literals and the doc
+ * comment that leads with the old name. The rename is all or nothing; it
+ * fails without touching anything when the new name would collide with, or
+ * be shadowed by, a name in scope at any reference, or when a reference
+ * cannot be told apart from another symbol of the same name.
+ *
+ * Fields and methods are matched by name, so a member can only be renamed
+ * when no other member of the package shares its name. References from other
+ * packages are outside the analyzed files and are not updated.
+ */
+
+export interface RenameOptions {
+  /** Symbol to rename: its name, or `Type.Member` for a method or field */
+  name: string;
+  newName: string;
+}
+
+export interface RenamedReference {
+  filePath: string;
+  line: number;
+  column: number;
+}
+
+export interface RenameResult {
+  oldName: string;
+  newName: string;
+  /** Changed files; files without references are left out */
+  files: GoRefactorResult[];
+  /** Every renamed identifier, the declaration included */
+  references: RenamedReference[];
+  count: number;
+  /** True when a doc comment led with the old name and was updated */
+  docCommentUpdated: boolean;
+}
+
+const IDENTIFIER = /^[\p{L}_][\p{L}\p{Nd}_]*$/u;
+
+function escapeRegExp(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+}
+
+class SymbolRenamer {
+  private readonly files: GoFile[];
+  private readonly options: RenameOptions;
+  private names: GoPackageNames;
+  private packageFiles: GoFile[];
+  private declaration: GoNameDeclaration;
+
+  constructor(files: GoFile[], options: RenameOptions) {
+    this.files = files;
+    this.options = options;
+  }
+
+  private get oldName(): string {
+    return this.declaration.name;
+  }
+
+  private get newName(): string {
+    return this.options.newName;
+  }
+
+  private position(site: GoNameSite): RenamedReference {
+    return {
+      filePath: site.file.filePath,
+      ...site.file.sourceMap.position(site.node.pos),
+    };
+  }
+
+  private where(site: GoNameSite): string {
+    const { filePath, line } = this.position(site);
+    return `${path.basename(filePath)}:${line}`;
+  }
+
+  private sitesOf(namespace: GoNamespace, name: string): GoNameSite[] {
+    // Sites are looked up by namespace and name only
+    return this.names.sites({ namespace, name } as GoNameDeclaration);
+  }
+
+  private validateName(): void {
+    const { newName } = this;
+    if (!IDENTIFIER.test(newName) || GO_KEYWORDS.has(newName)) {
+      throw new GoRefactorError(`${newName} is not a valid Go identifier`);
+    }
+    if (newName === "_") {
+      throw new GoRefactorError("Cannot rename to the blank identifier");
+    }
+  }
+
+  // The declaration to rename and the files of its package
+  private locate(): void {
+    const [typeName, member] = this.options.name.includes(".")
+      ? this.options.name.split(".", 2)
+      : [undefined, this.options.name];
+    if (typeName === undefined && (member === "init" || member === "main")) {
+      throw new GoRefactorError(`Cannot rename the special function ${member}`);
+    }
+
+    const declaring = this.files.find((file) =>
+      file.decls.some((decl) =>
+        typeName === undefined
+          ? this.declares(decl, member)
+          : this.declaresMember(decl, typeName, member),
+      ),
+    );
+    if (!declaring) {
+      throw new GoRefactorError(
+        `${this.options.name} is not declared in the analyzed files`,
+      );
+    }
+    const directory = path.dirname(declaring.filePath);
+    this.packageFiles = this.files.filter(
+      (file) =>
+        path.dirname(file.filePath) === directory &&
+        file.packageName.name === declaring.packageName.name,
+    );
+    this.names = indexGoPackageNames(this.packageFiles);
+
+    const namespace = typeName === undefined ? "package" : "member";
+    const declarations = this.names.declarations.filter(
+      (candidate) =>
+        candidate.namespace === namespace && candidate.name === member,
+    );
+    if (declarations.length > 1) {
+      const others = declarations.map((other) => this.where(other)).join(", ");
+      throw new GoRefactorError(
+        `${member} is declared more than once in the package (${others}), so its references cannot be told apart`,
+      );
+    }
+    this.declaration = declarations[0];
+  }
+
+  private declares(decl: Decl, name: string): boolean {
+    if (decl.kind === "FuncDecl") return !decl.recv && decl.name.name === name;
+    return decl.specs.some((spec) =>
+      spec.kind === "TypeSpec"
+        ? spec.name.name === name
+        : spec.kind === "ValueSpec" &&
+          spec.names.some((ident) => ident.name === name),
+    );
+  }
+
+  private declaresMember(
+    decl: Decl,
+    typeName: string,
+    member: string,
+  ): boolean {
+    if (decl.kind === "FuncDecl") {
+      const recv = decl.recv?.list[0];
+      return (
+        recv !== undefined &&
+        baseTypeName(recv.type).name === typeName &&
+        decl.name.name === member
+      );
+    }
+    return decl.specs.some(
+      (spec) =>
+        spec.kind === "TypeSpec" &&
+        spec.name.name === typeName &&
+        spec.type.kind === "StructType" &&
+        spec.type.fields.list.some((field) =>
+          field.names.some((ident) => ident.name === member),
+        ),
+    );
+  }
+
+  // The function or type declaration a local or type parameter belongs to
+  private enclosingScope(file: GoFile, node: Node): Node | undefined {
+    let scope: Node | undefined;
+    inspect(file, (child) => {
+      if (child.pos > node.pos || node.end > child.end) return false;
+      if (child.kind === "FuncDecl" || child.kind === "TypeSpec") {
+        scope = child;
+        return false;
+      }
+    });
+    return scope;
+  }
+
+  private checkCollisions(sites: GoNameSite[]): void {
+    const { newName, oldName } = this;
+    const namespace = this.declaration.namespace;
+
+    const existing = this.sitesOf(namespace, newName);
+    if (existing.length > 0) {
+      throw new GoRefactorError(
+        namespace === "package"
+          ? `${newName} already names something in the package (${this.where(existing[0])})`
+          : `${newName} is already used as a field or method name in the package (${this.where(existing[0])})`,
+      );
+    }
+    if (namespace === "member") return;
+
+    // A local or import named like the new name would capture references
+    for (const local of this.names.declarations) {
+      if (local.namespace !== "local" || local.name !== newName) continue;
+      const scope = this.enclosingScope(local.file, local.node);
+      const captured = sites.find(
+        (site) =>
+          site.file === local.file &&
+          scope &&
+          scope.pos <= site.node.pos &&
+          site.node.end <= scope.end,
+      );
+      if (captured) {
+        throw new GoRefactorError(
+          `The reference at ${this.where(captured)} would refer to the local ${newName} declared at ${this.where(local)}`,
+        );
+      }
+    }
+    for (const file of new Set(sites.map((site) => site.file))) {
+      if (file.imports.some((spec) => importName(spec) === newName)) {
+        throw new GoRefactorError(
+          `${path.basename(file.filePath)} imports a package named ${newName}`,
+        );
+      }
+    }
+
+    // Composite literal keys named like a field are indexed as members, and
+    // so are selections of an embedded field named like a renamed type
+    const keys = new Set<Node>();
+    for (const file of this.packageFiles) {
+      inspect(file, (node) => {
+        if (node.kind === "KeyValueExpr") keys.add(node.key);
+      });
+    }
+    const isType = this.declaration.kind === "type";
+    const ambiguous = this.sitesOf("member", oldName).find(
+      (site) => isType || keys.has(site.node),
+    );
+    if (ambiguous) {
+      throw new GoRefactorError(
+        `Cannot tell whether ${oldName} at ${this.where(ambiguous)} refers to the renamed symbol or to a field`,
+      );
+    }
+  }
+
+  // The doc comment of the declaration, when it leads with the old name
+  private docEdit(): { file: GoFile; edit: TextEdit } | undefined {
+    const { file, node } = this.declaration;
+    let doc: CommentGroup | undefined;
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl") {
+        if (decl.name === node) doc = decl.doc;
+        continue;
+      }
+      inspect(decl, (child) => {
+        if (child.kind === "TypeSpec" && child.name === node) {
+          doc = child.doc ?? (decl.lparen < 0 ? decl.doc : undefined);
+        } else if (child.kind === "ValueSpec" && child.names.includes(node)) {
+          doc = child.doc ?? (decl.lparen < 0 ? decl.doc : undefined);
+        } else if (child.kind === "Field" && child.names.includes(node)) {
+          doc = child.doc;
+        }
+      });
+    }
+    const comment = doc?.list[0];
+    if (!comment) return undefined;
+    const leading = new RegExp(
+      `^(//|/\\*)(\\s*)${escapeRegExp(this.oldName)}(?![\\p{L}\\p{Nd}_])`,
+      "u",
+    ).exec(comment.text);
+    if (!leading) return undefined;
+    const start = comment.pos + leading[1].length + leading[2].length;
+    return {
+      file,
+      edit: { start, end: start + this.oldName.length, newText: this.newName },
+    };
+  }
+
+  rename(): RenameResult {
+    this.validateName();
+    this.locate();
+    if (this.newName === this.oldName) {
+      throw new GoRefactorError(`${this.oldName} already has that name`);
+    }
+    const sites = this.names.sites(this.declaration);
+    for (const site of sites) {
+      if (site.node.kind !== "Ident" || site.node.name !== this.oldName) {
+        throw new GoRefactorError(
+          `Cannot resolve the reference at ${this.where(site)}`,
+        );
+      }
+    }
+    this.checkCollisions(sites);
+
+    const edits = new Map<GoFile, TextEdit[]>();
+    const add = (file: GoFile, edit: TextEdit) =>
+      edits.set(file, [...(edits.get(file) ?? []), edit]);
+    for (const site of sites) {
+      add(site.file, {
+        start: site.node.pos,
+        end: site.node.end,
+        newText: this.newName,
+      });
+    }
+    const doc = this.docEdit();
+    if (doc) add(doc.file, doc.edit);
+
+    const references = sites
+      .map((site) => this.position(site))
+      .sort(
+        (a, b) =>
+          compareCodePoints(a.filePath, b.filePath) ||
+          a.line - b.line ||
+          a.column - b.column,
+      );
+    return {
+      oldName: this.oldName,
+      newName: this.newName,
+      files: this.packageFiles
+        .filter((file) => edits.has(file))
+        .map((file) => refactorResult(file, edits.get(file))),
+      references,
+      count: references.length,
+      docCommentUpdated: doc !== undefined,
+    };
+  }
+}
+
+/**
+ * Rename a symbol and its references across the files of its package
+ */
+export function renameGoSymbol(
+  files: GoFile[],
+  options: RenameOptions,
+): RenameResult {
+  return new SymbolRenamer(files, options).rename();
+}
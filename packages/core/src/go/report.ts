@@ -0,0 +1,421 @@
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import {
+  annotateCallMetrics,
+  buildGoCallGraph,
+  callGraphToDot,
+  findRecursionCycles,
+} from "./callgraph.js";
+import {
+  COGNITIVE_COMPLEXITY_RULES,
+  DEFAULT_COGNITIVE_THRESHOLD,
+  DEFAULT_COMPLEXITY_THRESHOLD,
+} from "./complexity.js";
+import { annotateCoverage, GoCoverProfile } from "./coverage.js";
+import { findDeadFunctions } from "./deadcode.js";
+import { compareCodePoints } from "./findings.js";
+import {
+  annotateMaintainability,
+  GoMaintainabilityConstants,
+} from "./maintainability.js";
+import {
+  extractGoFileSymbols,
+  GoFunctionSymbol,
+  Visibility,
+} from "./symbols.js";
+import { DEFAULT_TODO_TAGS, findTodoComments } from "./todos.js";
+
+/**
+ * Go Markdown Report
+ * ==================
+ * Summarizes an analysis for humans: every function with its complexity,
+ * maintainability index, coverage and coupling, the refactor candidates in
+ * priority order, the largest functions, the files by maintainability, the
+ * dead functions, the recursive ones and the TODO-style comments by the
+ * declaration they are in. Rows are sorted by
+ * code point and the report holds no timestamps, so the same sources always
+ * render the same report and it can be committed and diffed.
+ */
+
+export interface GoReportOptions {
+  /** Heading of the report; defaults to the analyzed package names */
+  title?: string;
+  /** Directory file paths are shown relative to */
+  root?: string;
+  /** Adds a coverage column and weighs candidates by coverage */
+  coverage?: GoCoverProfile;
+  /** Complexity above which a function is a candidate (default: 10) */
+  complexityThreshold?: number;
+  /** Cognitive complexity making a function a candidate (default: 15) */
+  cognitiveThreshold?: number;
+  /** How many functions the largest functions section lists (default: 10) */
+  largestFunctions?: number;
+  /** Embed the call graph as a fenced DOT block */
+  callGraph?: boolean;
+  /** Maintainability index constants replacing the defaults, one by one */
+  maintainability?: Partial<GoMaintainabilityConstants>;
+}
+
+interface ReportRow {
+  file: string;
+  symbol: GoFunctionSymbol;
+}
+
+function table(header: string[], align: string[], rows: string[][]): string[] {
+  return [
+    `| ${header.join(" | ")} |`,
+    `| ${align.join(" | ")} |`,
+    ...rows.map((row) => `| ${row.join(" | ")} |`),
+  ];
+}
+
+function percent(value: number | undefined): string {
+  return value === undefined ? "—" : `${value}%`;
+}
+
+/**
+ * Render a Markdown report for a set of parsed files
+ */
+export function goMarkdownReport(
+  files: GoFile[],
+  options: GoReportOptions = {},
+): string {
+  const threshold = options.complexityThreshold ?? DEFAULT_COMPLEXITY_THRESHOLD;
+  const cognitiveThreshold =
+    options.cognitiveThreshold ?? DEFAULT_COGNITIVE_THRESHOLD;
+  const display = (filePath: string) =>
+    (options.root ? path.relative(options.root, filePath) : filePath)
+      .split(path.sep)
+      .join("/");
+
+  const graph = buildGoCallGraph(files);
+  const symbols = files.map(extractGoFileSymbols);
+  annotateCallMetrics(symbols, graph);
+  annotateMaintainability(symbols, options.maintainability);
+  if (options.coverage) annotateCoverage(symbols, options.coverage);
+
+  const rows: ReportRow[] = symbols
+    .flatMap((file) =>
+      [...file.functions, ...file.methods].map((symbol) => ({
+        file: display(file.filePath),
+        symbol,
+      })),
+    )
+    .sort(
+      (a, b) =>
+        compareCodePoints(a.file, b.file) ||
+        a.symbol.startLine - b.symbol.startLine ||
+        compareCodePoints(a.symbol.qualifiedName, b.symbol.qualifiedName),
+    );
+  const dead = findDeadFunctions(files, {}, graph)
+    .map((fn) => ({ ...fn, file: display(fn.filePath) }))
+    .sort((a, b) => compareCodePoints(a.file, b.file) || a.line - b.line);
+  const unused = dead.filter((fn) => !fn.testOnly).length;
+
+  const packages = [...new Set(files.map((file) => file.packageName.name))];
+  const lines = [
+    `# ${options.title ?? `Go analysis: ${packages.sort(compareCodePoints).join(", ")}`}`,
+    "",
+    `${files.length} file(s), ${rows.length} function(s), ${unused} dead function(s).`,
+    "",
+    "## Functions",
+    "",
+  ];
+
+  const coverage = options.coverage !== undefined;
+  if (rows.length === 0) {
+    lines.push("_None._");
+  } else {
+    lines.push(
+      ...table(
+        [
+          "Function",
+          "File",
+          "Line",
+          "Visibility",
+          "Complexity",
+          "Cognitive",
+          "Maintainability",
+          ...(coverage ? ["Coverage"] : []),
+          "Fan-in",
+          "Fan-out",
+        ],
+        [
+          "---",
+          "---",
+          "---:",
+          "---",
+          "---:",
+          "---:",
+          "---:",
+          ...(coverage ? ["---:"] : []),
+          "---:",
+          "---:",
+        ],
+        rows.map(({ file, symbol }) => [
+          `\`${symbol.qualifiedName}\``,
+          file,
+          `${symbol.startLine}`,
+          symbol.visibility === Visibility.Exported ? "exported" : "unexported",
+          `${symbol.complexity}`,
+          `${symbol.cognitiveComplexity}`,
+          `${symbol.maintainabilityIndex}`,
+          ...(coverage ? [percent(symbol.coveragePct)] : []),
+          `${symbol.fanIn ?? 0}`,
+          `${symbol.fanOut ?? 0}`,
+        ]),
+      ),
+    );
+  }
+
+  // Complex functions first; among equals, the least covered and then the
+  // most called are the riskiest to leave alone
+  const candidates = rows
+    .filter(
+      (row) =>
+        row.symbol.complexity > threshold ||
+        row.symbol.cognitiveComplexity > cognitiveThreshold,
+    )
+    .sort(
+      (a, b) =>
+        b.symbol.complexity - a.symbol.complexity ||
+        b.symbol.cognitiveComplexity - a.symbol.cognitiveComplexity ||
+        (a.symbol.coveragePct ?? 0) - (b.symbol.coveragePct ?? 0) ||
+        (b.symbol.fanIn ?? 0) - (a.symbol.fanIn ?? 0) ||
+        compareCodePoints(a.file, b.file) ||
+        a.symbol.startLine - b.symbol.startLine,
+    );
+  lines.push(
+    "",
+    "## Refactor candidates",
+    "",
+    `Functions with cyclomatic complexity above ${threshold} or cognitive complexity above ${cognitiveThreshold}, by priority. ${COGNITIVE_COMPLEXITY_RULES}`,
+    "",
+  );
+  if (candidates.length === 0) {
+    lines.push("_None._");
+  } else {
+    lines.push(
+      ...table(
+        [
+          "Priority",
+          "Function",
+          "File",
+          "Complexity",
+          "Cognitive",
+          ...(coverage ? ["Coverage"] : []),
+          "Fan-in",
+        ],
+        [
+          "---:",
+          "---",
+          "---",
+          "---:",
+          "---:",
+          ...(coverage ? ["---:"] : []),
+          "---:",
+        ],
+        candidates.map(({ file, symbol }, index) => [
+          `${index + 1}`,
+          `\`${symbol.qualifiedName}\``,
+          `${file}:${symbol.startLine}`,
+          `${symbol.complexity}`,
+          `${symbol.cognitiveComplexity}`,
+          ...(coverage ? [percent(symbol.coveragePct)] : []),
+          `${symbol.fanIn ?? 0}`,
+        ]),
+      ),
+    );
+  }
+
+  // Code lines, so neither comments nor the doc comment count against a
+  // function
+  const largest = [...rows]
+    .sort(
+      (a, b) =>
+        b.symbol.size.codeLines - a.symbol.size.codeLines ||
+        b.symbol.size.statements - a.symbol.size.statements ||
+        compareCodePoints(a.file, b.file) ||
+        a.symbol.startLine - b.symbol.startLine,
+    )
+    .slice(0, options.largestFunctions ?? 10);
+  lines.push(
+    "",
+    "## Largest functions",
+    "",
+    "Functions by lines of code, excluding blank lines and comments.",
+    "",
+  );
+  if (largest.length === 0) {
+    lines.push("_None._");
+  } else {
+    lines.push(
+      ...table(
+        ["Function", "File", "Code", "Statements", "Comments", "Doc", "Lines"],
+        ["---", "---", "---:", "---:", "---:", "---:", "---:"],
+        largest.map(({ file, symbol }) => [
+          `\`${symbol.qualifiedName}\``,
+          `${file}:${symbol.startLine}`,
+          `${symbol.size.codeLines}`,
+          `${symbol.size.statements}`,
+          `${symbol.size.commentLines}`,
+          `${symbol.size.docLines}`,
+          `${symbol.size.lines}`,
+        ]),
+      ),
+    );
+  }
+
+  // The least maintainable files first, each with its worst function
+  const maintainability = symbols
+    .filter((file) => file.maintainabilityIndex !== undefined)
+    .map((file) => {
+      const shown = display(file.filePath);
+      const functions = rows.filter((row) => row.file === shown);
+      const [lowest] = [...functions].sort(
+        (a, b) =>
+          a.symbol.maintainabilityIndex - b.symbol.maintainabilityIndex ||
+          a.symbol.startLine - b.symbol.startLine,
+      );
+      return {
+        file: shown,
+        index: file.maintainabilityIndex,
+        functions: functions.length,
+        lowest: lowest.symbol,
+      };
+    })
+    .sort((a, b) => a.index - b.index || compareCodePoints(a.file, b.file));
+  lines.push(
+    "",
+    "## Maintainability",
+    "",
+    "Files by maintainability index, lowest first. The index scores a function from 0 to 100 on its cyclomatic complexity, code lines and share of comments; a file scores the mean of its functions weighted by their code lines.",
+    "",
+  );
+  if (maintainability.length === 0) {
+    lines.push("_None._");
+  } else {
+    lines.push(
+      ...table(
+        ["File", "Index", "Functions", "Lowest"],
+        ["---", "---:", "---:", "---"],
+        maintainability.map((entry) => [
+          entry.file,
+          `${entry.index}`,
+          `${entry.functions}`,
+          `\`${entry.lowest.qualifiedName}\` (${entry.lowest.maintainabilityIndex})`,
+        ]),
+      ),
+    );
+  }
+
+  lines.push(
+    "",
+    "## Dead code",
+    "",
+    "Unexported functions and methods nothing in their package uses, or only its tests.",
+    "",
+  );
+  if (dead.length === 0) {
+    lines.push("_None._");
+  } else {
+    lines.push(
+      ...dead.map(
+        (fn) =>
+          `- \`${fn.qualifiedName}\` (${fn.file}:${fn.line})` +
+          (fn.testOnly ? " — only called from tests" : ""),
+      ),
+    );
+  }
+
+  lines.push(
+    "",
+    "## Recursion",
+    "",
+    "Functions that call themselves, directly or through each other; inlining across them is unsafe.",
+    "",
+  );
+  const cycles = findRecursionCycles(graph);
+  if (cycles.length === 0) {
+    lines.push("_None._");
+  } else {
+    lines.push(
+      ...cycles.map((cycle) => {
+        const members = cycle.ids.map((id) => {
+          const { filePath, symbol } = graph.nodes.get(id);
+          const location = `${display(filePath)}:${symbol.startLine}`;
+          return `\`${symbol.qualifiedName}\` (${location})`;
+        });
+        return `- ${members.join(", ")}${cycle.direct ? " — calls itself" : ""}`;
+      }),
+    );
+  }
+
+  // Debt in complex functions is the most expensive to leave
+  const complexity = new Map(
+    rows.map(({ file, symbol }) => [
+      `${file}\0${symbol.qualifiedName}`,
+      symbol.complexity,
+    ]),
+  );
+  const debt = new Map<
+    string,
+    { file: string; symbol?: string; line: number; tags: string[] }
+  >();
+  for (const todo of findTodoComments(files)) {
+    const file = display(todo.filePath);
+    const key = `${file}\0${todo.symbol ?? ""}`;
+    if (!debt.has(key)) {
+      debt.set(key, {
+        file,
+        symbol: todo.symbol,
+        line: todo.line,
+        tags: [],
+      });
+    }
+    debt.get(key).tags.push(todo.tag);
+  }
+  const debtRows = [...debt]
+    .map(([key, entry]) => ({ ...entry, complexity: complexity.get(key) }))
+    .sort(
+      (a, b) =>
+        b.tags.length - a.tags.length ||
+        (b.complexity ?? 0) - (a.complexity ?? 0) ||
+        compareCodePoints(a.file, b.file) ||
+        a.line - b.line,
+    );
+  lines.push(
+    "",
+    "## Tech debt",
+    "",
+    `${DEFAULT_TODO_TAGS.join(", ")} comments by declaration, the most first.`,
+    "",
+  );
+  if (debtRows.length === 0) {
+    lines.push("_None._");
+  } else {
+    lines.push(
+      ...table(
+        ["Declaration", "File", "Markers", "Complexity"],
+        ["---", "---", "---", "---:"],
+        debtRows.map((row) => [
+          row.symbol ? `\`${row.symbol}\`` : "—",
+          `${row.file}:${row.line}`,
+          DEFAULT_TODO_TAGS.filter((tag) => row.tags.includes(tag))
+            .map((tag) => {
+              const count = row.tags.filter((other) => other === tag).length;
+              return `${count} ${tag}`;
+            })
+            .join(", "),
+          row.complexity === undefined ? "—" : `${row.complexity}`,
+        ]),
+      ),
+    );
+  }
+
+  if (options.callGraph) {
+    lines.push("", "## Call graph", "", "```dot");
+    lines.push(callGraphToDot(graph).trimEnd(), "```");
+  }
+  return lines.join("\n") + "\n";
+}
@@ -0,0 +1,344 @@
+import {
+  createPlan,
+  PlannedChange,
+  PlannedFile,
+  RefactorPlan,
+} from "../plan.js";
+import { GoFile } from "./ast.js";
+import { buildGoCallGraph, callGraphId, GoCallGraph } from "./callgraph.js";
+import { GoConfidence, refactorConfidence } from "./confidence.js";
+import { GoCoverProfile } from "./coverage.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { goFindingSymbol, GoFingerprinter } from "./fingerprint.js";
+import { goPlannedChanges } from "./impact.js";
+import { groupGoPackages } from "./package.js";
+import { parseGoFile } from "./parser.js";
+import { GO_QUICK_FIXES, goQuickFixRules } from "./quick-fix.js";
+import { GoRefactorError } from "./refactor.js";
+import {
+  defaultGoRuleRegistry,
+  GoBuiltinRuleOptions,
+  GoRuleRegistry,
+} from "./rules.js";
+import { goFunctionSymbol } from "./symbols.js";
+
+/**
+ * Suggestion Review
+ * =================
+ * The model behind reviewing quick fixes one at a time, as an interactive
+ * front-end does: every finding with a quick fix becomes an item, grouped
+ * by file, which can be previewed, approved or skipped in any order. A
+ * preview is the fix's diff against the files as approved so far, with the
+ * confidence of rewriting the function holding the finding and the impact
+ * {@link createPlan} estimates for the change.
+ *
+ * Approving an item applies its fix to the session's files, not to disk,
+ * and runs the rules again on the file's package. Items still open there
+ * are matched to the new findings by fingerprint, which survives the lines
+ * moving, or when the fix edited their line, by rule and declaration, so
+ * their previews follow the edit; items whose finding is gone,
+ * because an approved fix also fixed them, are marked resolved. Findings
+ * that only appear after an edit are not added. {@link GoReviewSession.plan}
+ * turns the approved fixes into a plan to write with `applyPlan`.
+ */
+
+export type GoReviewStatus = "pending" | "approved" | "skipped" | "resolved";
+
+export interface GoReviewItem {
+  /** Position of the item in the session, which never changes */
+  id: number;
+  /** The finding as reported on the session's current files */
+  finding: GoFinding;
+  status: GoReviewStatus;
+}
+
+export interface GoReviewGroup {
+  filePath: string;
+  /** In source order */
+  items: GoReviewItem[];
+}
+
+export interface GoReviewPreview {
+  item: GoReviewItem;
+  /**
+   * The planned file, with its diff and impact; undefined when the fix
+   * cannot be applied
+   */
+  file?: PlannedFile;
+  /** Why the fix cannot be applied */
+  reason?: string;
+  /**
+   * Confidence of rewriting the function holding the finding; null when
+   * the finding is outside any function
+   */
+  confidence: GoConfidence | null;
+}
+
+export interface GoReviewOptions {
+  /** Rules whose findings to review (default: every rule with a fix) */
+  rules?: string[];
+  /** Options the rules run with, which their fixes follow */
+  ruleOptions?: GoBuiltinRuleOptions;
+  /** Rules to run (default: the built-in rules, with `ruleOptions`) */
+  registry?: GoRuleRegistry;
+  /** Coverage profile the confidence scores use */
+  coverage?: GoCoverProfile;
+}
+
+interface Entry extends GoReviewItem {
+  fingerprint: string;
+  /** The declaration holding the finding, as in its fingerprint */
+  symbol: string;
+}
+
+// Call graph id of the function or method declared around a line
+function enclosingFunction(file: GoFile, line: number): string | undefined {
+  for (const decl of file.decls) {
+    if (decl.kind !== "FuncDecl") continue;
+    const start = file.sourceMap.line(decl.pos);
+    if (line < start || line > file.sourceMap.line(decl.end)) continue;
+    return callGraphId(file.packageName.name, goFunctionSymbol(file, decl));
+  }
+  return undefined;
+}
+
+/**
+ * A review of the quick fixes for the findings on a set of files
+ */
+export class GoReviewSession {
+  private readonly root: string;
+  private readonly options: GoReviewOptions;
+  private readonly registry: GoRuleRegistry;
+  private readonly rules: Set<string>;
+  private readonly entries: Entry[] = [];
+  private readonly current = new Map<string, GoFile>();
+  private readonly approved: PlannedChange[] = [];
+  private graph: GoCallGraph | undefined;
+
+  /**
+   * `root` is the directory plan paths are relative to, and `files` the
+   * parsed files to review, whole packages for the rules to see
+   */
+  constructor(root: string, files: GoFile[], options: GoReviewOptions = {}) {
+    this.root = root;
+    this.options = options;
+    this.registry =
+      options.registry ?? defaultGoRuleRegistry(options.ruleOptions);
+    const rules = options.rules ?? goQuickFixRules();
+    for (const rule of rules) {
+      if (!GO_QUICK_FIXES[rule]) {
+        throw new GoRefactorError(
+          `${rule} has no quick fix; rules with one are ${goQuickFixRules().join(", ")}`,
+        );
+      }
+    }
+    this.rules = new Set(rules);
+    files.forEach((file) => this.current.set(file.filePath, file));
+
+    const found = new Map(
+      groupGoPackages(files).flatMap((group) =>
+        this.findingsOf(group).map(({ finding, fingerprint }) => [
+          finding,
+          fingerprint,
+        ]),
+      ),
+    );
+    sortFindings([...found.keys()]).forEach((finding, id) =>
+      this.entries.push({
+        id,
+        finding,
+        fingerprint: found.get(finding),
+        symbol: this.symbolOf(finding),
+        status: "pending",
+      }),
+    );
+  }
+
+  /**
+   * The files as approved so far
+   */
+  get files(): GoFile[] {
+    return [...this.current.values()];
+  }
+
+  /**
+   * Every item, by file in code-point order and in source order within one
+   */
+  items(): GoReviewItem[] {
+    return this.entries.map(({ id, finding, status }) => ({
+      id,
+      finding,
+      status,
+    }));
+  }
+
+  /**
+   * The items grouped by file, in the order of {@link items}
+   */
+  groups(): GoReviewGroup[] {
+    const groups: GoReviewGroup[] = [];
+    for (const item of this.items()) {
+      const last = groups.at(-1);
+      if (last?.filePath === item.finding.filePath) {
+        last.items.push(item);
+      } else {
+        groups.push({ filePath: item.finding.filePath, items: [item] });
+      }
+    }
+    return groups;
+  }
+
+  /**
+   * The fix of an item against the files as approved so far
+   */
+  preview(id: number): GoReviewPreview {
+    const entry = this.entry(id);
+    const item = this.items()[id];
+    const { finding } = entry;
+    const file = this.current.get(finding.filePath);
+    const target = enclosingFunction(file, finding.line);
+    let confidence: GoConfidence | null = null;
+    if (target) {
+      this.graph ??= buildGoCallGraph(this.files);
+      confidence = refactorConfidence(
+        this.graph,
+        { kind: "rewrite", target },
+        { coverage: this.options.coverage },
+      );
+    }
+    if (entry.status === "approved" || entry.status === "resolved") {
+      return { item, reason: `the finding is ${entry.status}`, confidence };
+    }
+    let change: PlannedChange;
+    try {
+      const result = GO_QUICK_FIXES[finding.rule](
+        file,
+        finding.line,
+        this.files,
+        this.options.ruleOptions ?? {},
+      );
+      if (result.edits.length === 0) {
+        return { item, reason: "the fix changes nothing", confidence };
+      }
+      [change] = goPlannedChanges(this.files, [result], {
+        symbols: target ? [target] : [],
+      });
+    } catch (error) {
+      if (!(error instanceof GoRefactorError)) throw error;
+      return { item, reason: error.message, confidence };
+    }
+    const [planned] = createPlan(this.root, [change]).files;
+    return { item, file: planned, confidence };
+  }
+
+  /**
+   * Apply an item's fix to the session's files, updating the items left in
+   * its package. Throws a {@link GoRefactorError} when the fix cannot be
+   * applied.
+   */
+  approve(id: number): GoReviewPreview {
+    const entry = this.entry(id);
+    if (entry.status === "approved" || entry.status === "resolved") {
+      throw new GoRefactorError(`Item ${id} is already ${entry.status}`);
+    }
+    const preview = this.preview(id);
+    if (!preview.file) {
+      throw new GoRefactorError(`Cannot apply item ${id}: ${preview.reason}`);
+    }
+    const { filePath } = entry.finding;
+    this.approved.push({
+      filePath,
+      original: this.current.get(filePath).source,
+      content: preview.file.content,
+      symbols: preview.file.symbols,
+      references: preview.file.impact.references,
+      exportedChanges: preview.file.impact.exportedChanges,
+    });
+    this.current.set(filePath, parseGoFile(preview.file.content, filePath));
+    this.graph = undefined;
+    entry.status = "approved";
+    this.follow(filePath);
+    return { ...preview, item: this.items()[id] };
+  }
+
+  /**
+   * Leave an item's fix out; a skipped item can still be approved later
+   */
+  skip(id: number): GoReviewItem {
+    const entry = this.entry(id);
+    if (entry.status === "pending") entry.status = "skipped";
+    return this.items()[id];
+  }
+
+  /**
+   * The approved fixes as a plan, one entry per changed file
+   */
+  plan(): RefactorPlan {
+    return createPlan(this.root, this.approved);
+  }
+
+  // Match the open items of a file's package to the findings after an edit:
+  // by fingerprint, or when the edit changed the line, by rule and
+  // declaration
+  private follow(filePath: string): void {
+    const updated = groupGoPackages(this.files).find((group) =>
+      group.some((file) => file.filePath === filePath),
+    );
+    const inPackage = new Set(updated.map((file) => file.filePath));
+    const open = this.entries.filter(
+      (entry) =>
+        (entry.status === "pending" || entry.status === "skipped") &&
+        inPackage.has(entry.finding.filePath),
+    );
+    const unclaimed = this.findingsOf(updated);
+    const claim = (match: (found: (typeof unclaimed)[number]) => boolean) => {
+      const index = unclaimed.findIndex(match);
+      return index < 0 ? undefined : unclaimed.splice(index, 1)[0];
+    };
+    const unmatched = open.filter((entry) => {
+      const found = claim(
+        ({ fingerprint }) => fingerprint === entry.fingerprint,
+      );
+      if (found) entry.finding = found.finding;
+      return !found;
+    });
+    for (const entry of unmatched) {
+      const found = claim(
+        ({ finding }) =>
+          finding.rule === entry.finding.rule &&
+          finding.filePath === entry.finding.filePath &&
+          this.symbolOf(finding) === entry.symbol,
+      );
+      if (found) {
+        entry.finding = found.finding;
+        entry.fingerprint = found.fingerprint;
+      } else {
+        entry.status = "resolved";
+      }
+    }
+  }
+
+  private symbolOf(finding: GoFinding): string {
+    return goFindingSymbol(this.current.get(finding.filePath), finding.line);
+  }
+
+  private entry(id: number): Entry {
+    const entry = this.entries[id];
+    if (!entry) throw new GoRefactorError(`No review item ${id}`);
+    return entry;
+  }
+
+  // The fixable findings of a package, with their fingerprints
+  private findingsOf(
+    files: GoFile[],
+  ): { finding: GoFinding; fingerprint: string }[] {
+    const fingerprinter = new GoFingerprinter(files);
+    return this.registry
+      .run(files)
+      .filter((finding) => this.rules.has(finding.rule))
+      .map((finding) => ({
+        finding,
+        fingerprint: fingerprinter.fingerprint(finding),
+      }));
+  }
+}
@@ -0,0 +1,674 @@
+import { findUnnecessaryAnyReturns } from "./any-returns.js";
+import { findUncheckedTypeAssertions } from "./assertions.js";
+import { GoFile } from "./ast.js";
+import { findRedundantBoolReturns } from "./bool-return.js";
+import { findUnreleasedResources } from "./cleanup.js";
+import { findDirectClockCalls } from "./clock.js";
+import { GoConstantSymbol } from "./constants.js";
+import { findStoredContexts } from "./context-field.js";
+import {
+  findMissingContextParams,
+  GoContextOptions,
+} from "./context-param.js";
+import { findRedundantConversions } from "./conversions.js";
+import { findExposedMutableFields } from "./defensive-copy.js";
+import { findDocDrift } from "./doc-sync.js";
+import { findErrorComparisons } from "./error-compare.js";
+import { findErrorHandlingIssues, GoErrorCheckOptions } from "./errors.js";
+import { findExampleProblems } from "./examples.js";
+import { GoFinding, GoSeverity, sortFindings } from "./findings.js";
+import { findFlagArguments } from "./flag-args.js";
+import {
+  FindMethodCandidateOptions,
+  findMethodCandidates,
+} from "./function-to-method.js";
+import { findHiddenGlobalState, GlobalStateOptions } from "./globals.js";
+import { findUngroupedDeclarations } from "./group-decls.js";
+import { findNestedConditionals } from "./guard-clauses.js";
+import { findUnusedImports } from "./imports.js";
+import { findUnkeyedLiterals } from "./keyed-literals.js";
+import { findMapReadsWithoutOk } from "./map-access.js";
+import {
+  findLowMaintainability,
+  GoMaintainabilityOptions,
+} from "./maintainability.js";
+import { findMapsAsStructs } from "./map-struct.js";
+import { findNakedReturns, NakedReturnOptions } from "./naked-returns.js";
+import { findNumericRisks } from "./numeric.js";
+import { findPanicsInsteadOfErrors } from "./panics.js";
+import {
+  findWideSignatures,
+  GoParameterCountOptions,
+} from "./parameter-object.js";
+import { findMissingPreallocations } from "./prealloc.js";
+import { GoProfiler } from "./profile.js";
+import { findIndexLoops } from "./range-loops.js";
+import {
+  findInconsistentReceivers,
+  findMixedReceivers,
+} from "./receivers.js";
+import { findShadowedVariables } from "./shadow.js";
+import {
+  findUnsynchronizedFields,
+  SharedFieldOptions,
+} from "./shared-fields.js";
+import { findFunctionSplits } from "./split-function.js";
+import { findStructSplits, SplitStructOptions } from "./split-struct.js";
+import { findUnnecessarySprintf } from "./sprintf.js";
+import { findStringConcatInLoops } from "./string-builder.js";
+import {
+  extractGoFileSymbols,
+  GoFileSymbols,
+  GoFunctionSymbol,
+  GoTypeSymbol,
+} from "./symbols.js";
+import {
+  applyGoSuppressions,
+  GoSuppressedFinding,
+  unusedSuppressionFinding,
+} from "./suppress.js";
+import { FindTodoOptions, findTodoComments } from "./todos.js";
+import { findSentinelErrors } from "./typed-errors.js";
+import { findUnusedParameters } from "./unused-params.js";
+
+/**
+ * Go Analysis Rules
+ * =================
+ * A rule is a check with a stable ID and a default severity. It either looks
+ * at one symbol at a time, receiving the file it is declared in, or at all
+ * files at once. The built-in passes are registered as rules too, so project
+ * specific rules run alongside them and every rule can be switched off by ID.
+ */
+
+export type GoRuleSymbol = GoFunctionSymbol | GoTypeSymbol | GoConstantSymbol;
+
+/**
+ * Where a symbol being checked is declared
+ */
+export interface GoRuleContext {
+  file: GoFile;
+  symbols: GoFileSymbols;
+  /** Every file being checked, for rules that look across the package */
+  files: GoFile[];
+}
+
+/**
+ * An issue reported by a symbol rule. The rule ID and file are filled in by
+ * the registry, and the position defaults to the symbol's.
+ */
+export interface GoRuleIssue {
+  message: string;
+  line?: number;
+  column?: number;
+  /** Overrides the rule's default severity */
+  severity?: GoSeverity;
+  fix?: string;
+}
+
+export interface GoRule {
+  /** Stable kebab-case identifier, used to enable, disable and suppress */
+  id: string;
+  description: string;
+  severity: GoSeverity;
+  /** Check one function, method, type or constant */
+  check?(symbol: GoRuleSymbol, context: GoRuleContext): GoRuleIssue[];
+  /** Check all files at once; findings carry their own positions */
+  checkFiles?(files: GoFile[]): GoFinding[];
+}
+
+/**
+ * Error raised for invalid rules and unknown rule IDs
+ */
+export class GoRuleError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoRuleError";
+  }
+}
+
+export interface GoRuleRunOptions {
+  /** Records the time of each rule and of symbol extraction per file */
+  profiler?: GoProfiler;
+}
+
+const RULE_ID = /^[a-z][a-z0-9]*(-[a-z0-9]+)*$/;
+
+/**
+ * The rules to run, each enabled or disabled
+ */
+export class GoRuleRegistry {
+  private readonly rules = new Map<string, GoRule>();
+  private readonly disabled = new Set<string>();
+
+  /**
+   * Add a rule. IDs must be unique and kebab-case.
+   */
+  register(rule: GoRule, options: { enabled?: boolean } = {}): this {
+    if (!RULE_ID.test(rule.id)) {
+      throw new GoRuleError(
+        `Rule ID ${JSON.stringify(rule.id)} must be kebab-case`,
+      );
+    }
+    if (this.rules.has(rule.id)) {
+      throw new GoRuleError(`Rule ${rule.id} is already registered`);
+    }
+    if (!rule.check && !rule.checkFiles) {
+      throw new GoRuleError(`Rule ${rule.id} has no check`);
+    }
+    this.rules.set(rule.id, rule);
+    if (options.enabled === false) this.disabled.add(rule.id);
+    return this;
+  }
+
+  get(id: string): GoRule | undefined {
+    return this.rules.get(id);
+  }
+
+  /**
+   * Registered rules, sorted by ID
+   */
+  list(): GoRule[] {
+    return [...this.rules.values()].sort((a, b) =>
+      a.id < b.id ? -1 : a.id > b.id ? 1 : 0,
+    );
+  }
+
+  private known(id: string): void {
+    if (!this.rules.has(id)) {
+      throw new GoRuleError(`Unknown rule ${id}`);
+    }
+  }
+
+  enable(...ids: string[]): this {
+    ids.forEach((id) => this.known(id));
+    ids.forEach((id) => this.disabled.delete(id));
+    return this;
+  }
+
+  disable(...ids: string[]): this {
+    ids.forEach((id) => this.known(id));
+    ids.forEach((id) => this.disabled.add(id));
+    return this;
+  }
+
+  isEnabled(id: string): boolean {
+    return this.rules.has(id) && !this.disabled.has(id);
+  }
+
+  /**
+   * Run every enabled rule over the files, leaving out findings silenced by
+   * `//refactogent:ignore` directives and reporting the directives that
+   * silence nothing
+   */
+  run(files: GoFile[], options: GoRuleRunOptions = {}): GoFinding[] {
+    return this.runWithSuppressions(files, options).findings;
+  }
+
+  /**
+   * Like {@link run}, but also returns the suppressed findings with the
+   * directives silencing them
+   */
+  runWithSuppressions(
+    files: GoFile[],
+    options: GoRuleRunOptions = {},
+  ): {
+    findings: GoFinding[];
+    suppressed: GoSuppressedFinding[];
+  } {
+    const { profiler } = options;
+    const checked = this.check(files, profiler);
+    const measure = <T>(run: () => T) =>
+      profiler ? profiler.measure("suppressions", run) : run();
+    const { findings, suppressed, unused } = measure(() =>
+      applyGoSuppressions(checked, files),
+    );
+    // A disabled rule reports nothing, so its suppressions cannot be judged
+    const stale = unused
+      .filter((suppression) => !this.disabled.has(suppression.rule))
+      .map((suppression) =>
+        unusedSuppressionFinding(suppression, this.rules.has(suppression.rule)),
+      );
+    return { findings: sortFindings([...findings, ...stale]), suppressed };
+  }
+
+  private check(files: GoFile[], profiler?: GoProfiler): GoFinding[] {
+    const enabled = this.list().filter((rule) => this.isEnabled(rule.id));
+    const findings: GoFinding[] = [];
+    const measure = <T>(phase: string, run: () => T, filePath?: string) =>
+      profiler ? profiler.measure(phase, run, filePath) : run();
+
+    for (const rule of enabled.filter((rule) => rule.checkFiles)) {
+      const found = measure(`rule ${rule.id}`, () => rule.checkFiles(files));
+      for (const finding of found) {
+        findings.push({ ...finding, rule: rule.id });
+      }
+    }
+
+    const symbolRules = enabled.filter((rule) => rule.check);
+    if (symbolRules.length > 0) {
+      for (const file of files) {
+        const symbols = measure(
+          "symbols",
+          () => extractGoFileSymbols(file),
+          file.filePath,
+        );
+        const context: GoRuleContext = { file, symbols, files };
+        const all: GoRuleSymbol[] = [
+          ...symbols.functions,
+          ...symbols.methods,
+          ...symbols.types,
+          ...symbols.constants,
+        ];
+        for (const rule of symbolRules) {
+          const issues = measure(
+            `rule ${rule.id}`,
+            () =>
+              all.map(
+                (symbol) => [symbol, rule.check(symbol, context)] as const,
+              ),
+            file.filePath,
+          );
+          for (const [symbol, found] of issues) {
+            for (const issue of found) {
+              findings.push({
+                rule: rule.id,
+                severity: issue.severity ?? rule.severity,
+                filePath: file.filePath,
+                line: issue.line ?? symbol.startLine,
+                column: issue.column ?? symbol.startColumn,
+                message: issue.message,
+                ...(issue.fix !== undefined && { fix: issue.fix }),
+              });
+            }
+          }
+        }
+      }
+    }
+    return sortFindings(findings);
+  }
+}
+
+type PassRule = Pick<GoRule, "id" | "description" | "severity">;
+
+// One rule per ID a pass reports; the pass runs once per set of files, with
+// the options given
+function passRules<O>(
+  pass: (files: GoFile[], options?: O) => GoFinding[],
+  options: O | undefined,
+  rules: PassRule[],
+): GoRule[];
+function passRules(
+  pass: (files: GoFile[]) => GoFinding[],
+  rules: PassRule[],
+): GoRule[];
+function passRules<O>(
+  pass: (files: GoFile[], options?: O) => GoFinding[],
+  ...args: [O | undefined, PassRule[]] | [PassRule[]]
+): GoRule[] {
+  const [options, rules] = args.length === 2 ? args : [undefined, args[0]];
+  let lastFiles: GoFile[] | undefined;
+  let lastFindings: GoFinding[] = [];
+  const run = (files: GoFile[]) => {
+    if (files !== lastFiles) {
+      lastFindings = pass(files, options);
+      lastFiles = files;
+    }
+    return lastFindings;
+  };
+  return rules.map((rule) => ({
+    ...rule,
+    checkFiles: (files) =>
+      run(files).filter((finding) => finding.rule === rule.id),
+  }));
+}
+
+/**
+ * Options of the built-in checks, by the ID of the rule they tune. A check
+ * reporting several rules takes its options under the one they affect.
+ */
+export interface GoBuiltinRuleOptions {
+  "error-string-style"?: Pick<GoErrorCheckOptions, "capitalizedWords">;
+  "unsynchronized-field"?: SharedFieldOptions;
+  "split-struct"?: SplitStructOptions;
+  "too-many-parameters"?: GoParameterCountOptions;
+  "function-could-be-method"?: FindMethodCandidateOptions;
+  "naked-return"?: NakedReturnOptions;
+  "missing-context"?: Pick<GoContextOptions, "blockingCalls">;
+  "todo-comment"?: FindTodoOptions;
+  "hidden-global-state"?: GlobalStateOptions;
+  "low-maintainability"?: GoMaintainabilityOptions;
+}
+
+/**
+ * The checks built into the analyzer, as rules
+ */
+export function builtinGoRules(options: GoBuiltinRuleOptions = {}): GoRule[] {
+  return [
+    ...passRules(findErrorHandlingIssues, options["error-string-style"], [
+      {
+        id: "ignored-error",
+        description: "Error results dropped or discarded with _",
+        severity: "high",
+      },
+      {
+        id: "unwrapped-error",
+        description: "fmt.Errorf formatting an error without %w",
+        severity: "medium",
+      },
+      {
+        id: "nil-error-zero-value",
+        description: "Early returns of zero values with a nil error",
+        severity: "medium",
+      },
+      {
+        id: "error-string-style",
+        description: "Error strings capitalized or ending in punctuation",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findErrorComparisons, [
+      {
+        id: "error-comparison",
+        description: "Errors compared with == or != instead of errors.Is",
+        severity: "medium",
+      },
+      {
+        id: "error-type-assertion",
+        description: "Type assertions on errors instead of errors.As",
+        severity: "medium",
+      },
+    ]),
+    ...passRules(findSentinelErrors, [
+      {
+        id: "sentinel-error",
+        description: "Sentinel errors a typed error could replace",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findShadowedVariables, [
+      {
+        id: "shadowed-variable",
+        description: "Inner declarations hiding a variable read afterwards",
+        severity: "medium",
+      },
+    ]),
+    ...passRules(findUnsynchronizedFields, options["unsynchronized-field"], [
+      {
+        id: "unsynchronized-field",
+        description: "Map and slice fields methods write and read unlocked",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findMapReadsWithoutOk, [
+      {
+        id: "map-read-without-ok",
+        description: "Pointer and interface map reads nil checked without ok",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findUncheckedTypeAssertions, [
+      {
+        id: "unchecked-type-assertion",
+        description: "Single-result type assertions that panic on a mismatch",
+        severity: "medium",
+      },
+    ]),
+    ...passRules(findPanicsInsteadOfErrors, [
+      {
+        id: "panic-instead-of-error",
+        description: "Panics in functions that could return an error",
+        severity: "medium",
+      },
+    ]),
+    ...passRules(findUnreleasedResources, [
+      {
+        id: "unreleased-resource",
+        description: "Resources not released on every path out of a function",
+        severity: "medium",
+      },
+    ]),
+    ...passRules(findUnnecessaryAnyReturns, [
+      {
+        id: "unnecessary-any-return",
+        description: "Results declared any that always hold one concrete type",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findFunctionSplits, [
+      {
+        id: "split-function",
+        description: "Functions that validate, loop and more in one body",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findStructSplits, options["split-struct"], [
+      {
+        id: "split-struct",
+        description: "Structs whose methods use disjoint sets of fields",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findMapsAsStructs, [
+      {
+        id: "map-as-struct",
+        description: "String-keyed maps only ever used with literal keys",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findWideSignatures, options["too-many-parameters"], [
+      {
+        id: "too-many-parameters",
+        description: "Functions with more parameters than the limit",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findLowMaintainability, options["low-maintainability"], [
+      {
+        id: "low-maintainability",
+        description: "Functions below the minimum maintainability index",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findMethodCandidates, options["function-could-be-method"], [
+      {
+        id: "function-could-be-method",
+        description: "Functions that could be methods of their first parameter",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findStringConcatInLoops, [
+      {
+        id: "string-concat-in-loop",
+        description: "Strings built by concatenation inside loops",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findRedundantConversions, [
+      {
+        id: "redundant-conversion",
+        description: "Conversions to the type the operand already has",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findUnnecessarySprintf, [
+      {
+        id: "unnecessary-sprintf",
+        description: "fmt.Sprintf calls formatting one value strconv can",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findMissingPreallocations, [
+      {
+        id: "slice-prealloc",
+        description: "Slices grown in loops of known length without capacity",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findRedundantBoolReturns, [
+      {
+        id: "redundant-bool-return",
+        description: "Ifs returning true and false instead of the condition",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findNestedConditionals, [
+      {
+        id: "nested-conditional",
+        description: "Nested ifs that guard clauses returning early flatten",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findNakedReturns, options["naked-return"], [
+      {
+        id: "naked-return",
+        description: "Bare returns in functions longer than a few lines",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findMissingContextParams, options["missing-context"], [
+      {
+        id: "missing-context",
+        description: "I/O functions without a leading context.Context",
+        severity: "medium",
+      },
+    ]),
+    ...passRules(findStoredContexts, [
+      {
+        id: "context-in-struct",
+        description: "Struct fields storing a context.Context",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findUnusedParameters, [
+      {
+        id: "unused-parameter",
+        description: "Named parameters the function body never uses",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findInconsistentReceivers, [
+      {
+        id: "inconsistent-receiver",
+        description: "Methods naming their receiver unlike the type's others",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findMixedReceivers, [
+      {
+        id: "mixed-receiver-kind",
+        description: "Types mixing pointer and value receivers",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findUngroupedDeclarations, [
+      {
+        id: "ungrouped-declarations",
+        description: "Adjacent package-level consts or vars outside a block",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findExposedMutableFields, [
+      {
+        id: "exposed-mutable-field",
+        description: "Exported methods returning map or slice fields uncopied",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findExampleProblems, [
+      {
+        id: "stale-example",
+        description: "Example functions naming symbols that no longer exist",
+        severity: "medium",
+      },
+      {
+        id: "malformed-example",
+        description: "Example functions go test cannot run as written",
+        severity: "medium",
+      },
+    ]),
+    ...passRules(findDocDrift, [
+      {
+        id: "stale-doc-name",
+        description: "Doc comments not beginning with the function's name",
+        severity: "low",
+      },
+      {
+        id: "stale-doc-parameter",
+        description: "Doc comments naming parameters the signature lacks",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findHiddenGlobalState, options["hidden-global-state"], [
+      {
+        id: "hidden-global-state",
+        description: "Parameter-less functions using mutable package state",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findIndexLoops, [
+      {
+        id: "index-loop",
+        description: "Index loops that only subscript what they could range",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findNumericRisks, [
+      {
+        id: "integer-overflow",
+        description: "Integer results growing with the input in a loop",
+        severity: "low",
+      },
+      {
+        id: "integer-division",
+        description: "Integer division truncated before use as a ratio",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findDirectClockCalls, [
+      {
+        id: "direct-clock",
+        description: "Functions reading the wall clock directly",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findFlagArguments, [
+      {
+        id: "flag-argument",
+        description: "Bool parameters switching a function between behaviors",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findUnkeyedLiterals, [
+      {
+        id: "unkeyed-literal",
+        description: "Struct literals setting fields by position",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findTodoComments, options["todo-comment"], [
+      {
+        id: "todo-comment",
+        description: "TODO, FIXME, HACK and XXX comments",
+        severity: "low",
+      },
+    ]),
+    ...passRules(findUnusedImports, [
+      {
+        id: "unused-import",
+        description: "Named imports nothing in the file uses",
+        severity: "medium",
+      },
+    ]),
+  ];
+}
+
+/**
+ * A registry holding the built-in rules, all enabled
+ */
+export function defaultGoRuleRegistry(
+  options: GoBuiltinRuleOptions = {},
+): GoRuleRegistry {
+  const registry = new GoRuleRegistry();
+  builtinGoRules(options).forEach((rule) => registry.register(rule));
+  return registry;
+}
@@ -0,0 +1,205 @@
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GoFinding, GoSeverity, sortFindings } from "./findings.js";
+import { GoFingerprinter } from "./fingerprint.js";
+import { goLspPosition } from "./lsp.js";
+import { defaultGoRuleRegistry, GoRuleRegistry } from "./rules.js";
+import { GoSuppressedFinding } from "./suppress.js";
+
+/**
+ * Go Findings as SARIF
+ * ====================
+ * Writes findings as a SARIF 2.1.0 log, the format GitHub code scanning
+ * uploads, so they show up in a repository's Security tab and on pull
+ * requests. The log has one run whose tool lists every registered rule with
+ * its description and default level; each result refers to its rule by ID
+ * and index.
+ *
+ * Artifact URIs are relative to the repository root, which code scanning
+ * resolves them against. Columns count UTF-16 code units, SARIF's default,
+ * rather than the bytes findings carry. Each result has a partial
+ * fingerprint from {@link GoFingerprinter}, the same identity baselines use,
+ * so code scanning keeps tracking a finding as code around it moves.
+ * Findings silenced by `//refactogent:ignore` can be included as results
+ * with an in-source suppression and its justification, which code scanning
+ * shows as dismissed.
+ */
+
+export const GO_SARIF_VERSION = "2.1.0";
+
+export const GO_SARIF_SCHEMA = "https://json.schemastore.org/sarif-2.1.0.json";
+
+/** Key of the partial fingerprint every result carries */
+export const GO_SARIF_FINGERPRINT = "refactogentFinding/v1";
+
+export type GoSarifLevel = "error" | "warning" | "note";
+
+export interface GoSarifRule {
+  id: string;
+  /** The ID in PascalCase, as code scanning displays it */
+  name: string;
+  shortDescription: { text: string };
+  fullDescription: { text: string };
+  defaultConfiguration: { level: GoSarifLevel };
+}
+
+export interface GoSarifResult {
+  ruleId: string;
+  /** Index of the rule in the driver's rules, when it is registered */
+  ruleIndex?: number;
+  level: GoSarifLevel;
+  message: { text: string };
+  locations: {
+    physicalLocation: {
+      artifactLocation: { uri: string; uriBaseId: string };
+      region: { startLine: number; startColumn: number };
+    };
+  }[];
+  partialFingerprints: Record<string, string>;
+  suppressions?: { kind: "inSource"; justification?: string }[];
+}
+
+export interface GoSarifLog {
+  $schema: string;
+  version: string;
+  runs: {
+    tool: {
+      driver: {
+        name: string;
+        version?: string;
+        informationUri: string;
+        rules: GoSarifRule[];
+      };
+    };
+    columnKind: "utf16CodeUnits";
+    results: GoSarifResult[];
+  }[];
+}
+
+export interface GoSarifOptions {
+  /** Repository root artifact URIs are relative to (default: the cwd) */
+  root?: string;
+  /** Rules listed in the log (default: the built-in rules) */
+  registry?: GoRuleRegistry;
+  /** Version of refactogent recorded as the tool's */
+  toolVersion?: string;
+  /** Suppressed findings, written as suppressed results */
+  suppressed?: GoSuppressedFinding[];
+}
+
+const LEVELS: Record<GoSeverity, GoSarifLevel> = {
+  high: "error",
+  medium: "warning",
+  low: "note",
+};
+
+// Base ID code scanning resolves relative artifact URIs against
+const SOURCE_ROOT = "%SRCROOT%";
+
+function pascalCase(id: string): string {
+  return id
+    .split("-")
+    .map((word) => word.charAt(0).toUpperCase() + word.slice(1))
+    .join("");
+}
+
+// A relative path as a URI reference, each segment escaped
+function artifactUri(root: string, filePath: string): string {
+  return path
+    .relative(root, filePath)
+    .split(path.sep)
+    .map((segment) => encodeURIComponent(segment))
+    .join("/");
+}
+
+/**
+ * Findings as a SARIF log. `files` provide the lines columns are converted
+ * on and the code fingerprints are computed from.
+ */
+export function goSarifLog(
+  findings: GoFinding[],
+  files: GoFile[],
+  options: GoSarifOptions = {},
+): GoSarifLog {
+  const registry = options.registry ?? defaultGoRuleRegistry();
+  const root = options.root ?? process.cwd();
+  const rules: GoSarifRule[] = registry.list().map((rule) => ({
+    id: rule.id,
+    name: pascalCase(rule.id),
+    shortDescription: { text: rule.description },
+    fullDescription: {
+      text: `${rule.description}. Reported at ${rule.severity} severity unless configured otherwise.`,
+    },
+    defaultConfiguration: { level: LEVELS[rule.severity] },
+  }));
+  const indexes = new Map(rules.map((rule, index) => [rule.id, index]));
+
+  const byPath = new Map(files.map((file) => [file.filePath, file]));
+  const fingerprinter = new GoFingerprinter(files);
+  const justifications = new Map<GoFinding, string>(
+    (options.suppressed ?? []).map(({ finding, suppression }) => [
+      finding,
+      suppression.reason,
+    ]),
+  );
+  const all = [...findings, ...justifications.keys()];
+  const results = sortFindings(all).map(
+    (finding): GoSarifResult => {
+      const file = byPath.get(finding.filePath);
+      // Without the source, byte columns of ASCII lines are the best guess
+      const column = file
+        ? goLspPosition(file, finding.line, finding.column).character + 1
+        : finding.column;
+      const index = indexes.get(finding.rule);
+      return {
+        ruleId: finding.rule,
+        ...(index !== undefined && { ruleIndex: index }),
+        level: LEVELS[finding.severity],
+        message: { text: finding.message },
+        locations: [
+          {
+            physicalLocation: {
+              artifactLocation: {
+                uri: artifactUri(root, finding.filePath),
+                uriBaseId: SOURCE_ROOT,
+              },
+              region: { startLine: finding.line, startColumn: column },
+            },
+          },
+        ],
+        partialFingerprints: {
+          [GO_SARIF_FINGERPRINT]: fingerprinter.fingerprint(finding),
+        },
+        ...(justifications.has(finding) && {
+          suppressions: [
+            {
+              kind: "inSource",
+              ...(justifications.get(finding) && {
+                justification: justifications.get(finding),
+              }),
+            },
+          ],
+        }),
+      };
+    },
+  );
+
+  return {
+    $schema: GO_SARIF_SCHEMA,
+    version: GO_SARIF_VERSION,
+    runs: [
+      {
+        tool: {
+          driver: {
+            name: "refactogent",
+            ...(options.toolVersion && { version: options.toolVersion }),
+            informationUri: "https://github.com/khaliqgant/refactogent",
+            rules,
+          },
+        },
+        columnKind: "utf16CodeUnits",
+        results,
+      },
+    ],
+  };
+}
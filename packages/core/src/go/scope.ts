@@ -0,0 +1,393 @@
+import {
+  Expr,
+  FieldList,
+  FuncDecl,
+  FuncLit,
+  Ident,
+  Node,
+  Stmt,
+  forEachChild,
+} from "./ast.js";
+
+/**
+ * Go Scopes
+ * =========
+ * Resolves identifiers inside a function body to the local variables they
+ * refer to, following Go's block scoping: each block, `if`/`for`/`switch`
+ * header and case clause opens a scope, and `:=` only declares the names not
+ * already declared in the current scope. Names that do not resolve to a local
+ * (package members, imports, builtins) are left unresolved.
+ */
+
+/**
+ * A variable declared inside a function, including its parameters
+ */
+export interface GoVariable {
+  name: string;
+  /** Identifier at the declaration site */
+  ident: Ident;
+  kind: "receiver" | "param" | "result" | "local";
+  /** Explicit type from the declaration, when given */
+  typeExpr?: Expr;
+  /**
+   * Initializer the type can be inferred from; `index` selects the value when
+   * one expression produces several (`a, b := f()`)
+   */
+  init?: { expr: Expr; index: number; count: number };
+  /** Set for `range` variables: the ranged expression and which slot */
+  rangeOf?: { expr: Expr; slot: "key" | "value" };
+  /** Set for local constants */
+  constant?: boolean;
+  scope: GoScope;
+}
+
+/**
+ * A use of a local variable
+ */
+export interface GoReference {
+  ident: Ident;
+  variable: GoVariable;
+  read: boolean;
+  write: boolean;
+}
+
+/**
+ * A lexical scope
+ */
+export interface GoScope {
+  node: Node;
+  parent?: GoScope;
+  variables: Map<string, GoVariable>;
+}
+
+/**
+ * Variables and references resolved within one function
+ */
+export interface GoFunctionScopes {
+  root: GoScope;
+  variables: GoVariable[];
+  references: GoReference[];
+  /** The variable each resolved identifier refers to, declarations included */
+  resolved: Map<Ident, GoVariable>;
+}
+
+/**
+ * Resolve the local variables of a function declaration or literal. Nested
+ * function literals are resolved as part of their enclosing function, so
+ * captured variables resolve to the outer declaration.
+ */
+export function resolveFunctionScopes(
+  fn: FuncDecl | FuncLit,
+): GoFunctionScopes {
+  const variables: GoVariable[] = [];
+  const references: GoReference[] = [];
+  const resolved = new Map<Ident, GoVariable>();
+  const root: GoScope = { node: fn, variables: new Map() };
+
+  const declare = (
+    scope: GoScope,
+    ident: Ident,
+    kind: GoVariable["kind"],
+    extra: Partial<GoVariable> = {},
+  ) => {
+    if (ident.name === "_") return;
+    const variable: GoVariable = {
+      name: ident.name,
+      ident,
+      kind,
+      scope,
+      ...extra,
+    };
+    scope.variables.set(ident.name, variable);
+    variables.push(variable);
+    resolved.set(ident, variable);
+  };
+
+  const lookup = (scope: GoScope, name: string): GoVariable | undefined => {
+    for (let current = scope; current; current = current.parent) {
+      const variable = current.variables.get(name);
+      if (variable) return variable;
+    }
+    return undefined;
+  };
+
+  const open = (scope: GoScope, node: Node): GoScope => ({
+    node,
+    parent: scope,
+    variables: new Map(),
+  });
+
+  const declareFields = (
+    scope: GoScope,
+    list: FieldList | undefined,
+    kind: GoVariable["kind"],
+  ) => {
+    for (const field of list?.list ?? []) {
+      field.names.forEach((name) =>
+        declare(scope, name, kind, { typeExpr: field.type }),
+      );
+    }
+  };
+
+  const reference = (
+    scope: GoScope,
+    ident: Ident,
+    access: { read: boolean; write: boolean },
+  ) => {
+    const variable = lookup(scope, ident.name);
+    if (variable) {
+      references.push({ ident, variable, ...access });
+      resolved.set(ident, variable);
+    }
+  };
+
+  // Assignment targets: `x = v` writes x; `x.f = v` and `x[i] = v` update x
+  // in place; `*p = v` only reads p
+  const assignTarget = (scope: GoScope, expr: Expr, compound: boolean) => {
+    if (expr.kind === "Ident") {
+      reference(scope, expr, { read: compound, write: true });
+      return;
+    }
+    let root: Expr = expr;
+    while (
+      root.kind === "SelectorExpr" ||
+      root.kind === "IndexExpr" ||
+      root.kind === "ParenExpr"
+    ) {
+      if (root.kind === "IndexExpr") visitExpr(scope, root.index);
+      root = root.x;
+    }
+    if (root.kind === "Ident") {
+      reference(scope, root, { read: true, write: true });
+    } else {
+      visitExpr(scope, root);
+    }
+  };
+
+  const visitExpr = (scope: GoScope, expr: Node | undefined) => {
+    if (!expr) return;
+    switch (expr.kind) {
+      case "Ident":
+        reference(scope, expr, { read: true, write: false });
+        return;
+      case "SelectorExpr":
+        // The selected name is a field or method, never a local
+        visitExpr(scope, expr.x);
+        return;
+      case "UnaryExpr":
+        if (expr.op === "&" && expr.x.kind === "Ident") {
+          // Taking the address lets the variable be modified through it
+          reference(scope, expr.x, { read: true, write: true });
+          return;
+        }
+        visitExpr(scope, expr.x);
+        return;
+      case "FuncLit": {
+        const inner = open(scope, expr);
+        declareFields(inner, expr.type.params, "param");
+        declareFields(inner, expr.type.results, "result");
+        visitBlock(inner, expr.body.list, expr.body);
+        return;
+      }
+      case "KeyValueExpr":
+        // Keys of struct literals are field names; keys that name a local
+        // are map keys and resolve like any other expression
+        visitExpr(scope, expr.key);
+        visitExpr(scope, expr.value);
+        return;
+      default:
+        forEachChild(expr, (child) => visitExpr(scope, child));
+    }
+  };
+
+  const visitBlock = (scope: GoScope, list: Stmt[], node: Node) => {
+    const inner = open(scope, node);
+    list.forEach((stmt) => visitStmt(inner, stmt));
+  };
+
+  const visitStmt = (scope: GoScope, stmt: Stmt | undefined) => {
+    if (!stmt) return;
+    switch (stmt.kind) {
+      case "AssignStmt": {
+        stmt.rhs.forEach((expr) => visitExpr(scope, expr));
+        if (stmt.tok === ":=") {
+          stmt.lhs.forEach((expr, index) => {
+            if (expr.kind !== "Ident") return;
+            if (scope.variables.has(expr.name)) {
+              // Redeclaration in the same scope assigns the existing variable
+              reference(scope, expr, { read: false, write: true });
+              return;
+            }
+            const init =
+              stmt.rhs.length === stmt.lhs.length
+                ? { expr: stmt.rhs[index], index: 0, count: 1 }
+                : { expr: stmt.rhs[0], index, count: stmt.lhs.length };
+            declare(scope, expr, "local", { init });
+          });
+        } else {
+          const compound = stmt.tok !== "=";
+          stmt.lhs.forEach((expr) => assignTarget(scope, expr, compound));
+        }
+        return;
+      }
+      case "IncDecStmt":
+        assignTarget(scope, stmt.x, true);
+        return;
+      case "DeclStmt":
+        for (const spec of stmt.decl.specs) {
+          if (spec.kind !== "ValueSpec") continue;
+          spec.values.forEach((expr) => visitExpr(scope, expr));
+          spec.names.forEach((name, index) => {
+            const init =
+              spec.values.length === spec.names.length
+                ? { expr: spec.values[index], index: 0, count: 1 }
+                : spec.values.length === 1
+                  ? { expr: spec.values[0], index, count: spec.names.length }
+                  : undefined;
+            declare(scope, name, "local", {
+              typeExpr: spec.type,
+              init,
+              ...(stmt.decl.tok === "const" && { constant: true }),
+            });
+          });
+        }
+        return;
+      case "BlockStmt":
+        visitBlock(scope, stmt.list, stmt);
+        return;
+      case "IfStmt": {
+        const inner = open(scope, stmt);
+        visitStmt(inner, stmt.init);
+        visitExpr(inner, stmt.cond);
+        visitStmt(inner, stmt.body);
+        visitStmt(inner, stmt.else);
+        return;
+      }
+      case "ForStmt": {
+        const inner = open(scope, stmt);
+        visitStmt(inner, stmt.init);
+        visitExpr(inner, stmt.cond);
+        visitStmt(inner, stmt.post);
+        visitStmt(inner, stmt.body);
+        return;
+      }
+      case "RangeStmt": {
+        visitExpr(scope, stmt.x);
+        const inner = open(scope, stmt);
+        if (stmt.tok === ":=") {
+          if (stmt.key?.kind === "Ident") {
+            declare(inner, stmt.key, "local", {
+              rangeOf: { expr: stmt.x, slot: "key" },
+            });
+          }
+          if (stmt.value?.kind === "Ident") {
+            declare(inner, stmt.value, "local", {
+              rangeOf: { expr: stmt.x, slot: "value" },
+            });
+          }
+        } else {
+          if (stmt.key) assignTarget(scope, stmt.key, false);
+          if (stmt.value) assignTarget(scope, stmt.value, false);
+        }
+        visitStmt(inner, stmt.body);
+        return;
+      }
+      case "SwitchStmt": {
+        const inner = open(scope, stmt);
+        visitStmt(inner, stmt.init);
+        visitExpr(inner, stmt.tag);
+        visitStmt(inner, stmt.body);
+        return;
+      }
+      case "TypeSwitchStmt": {
+        const inner = open(scope, stmt);
+        visitStmt(inner, stmt.init);
+        // `switch v := x.(type)` declares v separately in every clause
+        let bound: Ident | undefined;
+        if (stmt.assign.kind === "AssignStmt") {
+          stmt.assign.rhs.forEach((expr) => visitExpr(inner, expr));
+          const lhs = stmt.assign.lhs[0];
+          if (lhs?.kind === "Ident") bound = lhs;
+        } else {
+          visitStmt(inner, stmt.assign);
+        }
+        for (const clause of stmt.body.list) {
+          if (clause.kind !== "CaseClause") continue;
+          const clauseScope = open(inner, clause);
+          clause.list.forEach((expr) => visitExpr(clauseScope, expr));
+          if (bound) {
+            // The variable has the case's type only when the case names one
+            const typeExpr =
+              clause.list.length === 1 ? clause.list[0] : undefined;
+            declare(clauseScope, bound, "local", { typeExpr });
+          }
+          clause.body.forEach((child) => visitStmt(clauseScope, child));
+        }
+        return;
+      }
+      case "CaseClause": {
+        const inner = open(scope, stmt);
+        stmt.list.forEach((expr) => visitExpr(inner, expr));
+        stmt.body.forEach((child) => visitStmt(inner, child));
+        return;
+      }
+      case "CommClause": {
+        const inner = open(scope, stmt);
+        visitStmt(inner, stmt.comm);
+        stmt.body.forEach((child) => visitStmt(inner, child));
+        return;
+      }
+      case "LabeledStmt":
+        visitStmt(scope, stmt.stmt);
+        return;
+      case "BranchStmt":
+        return;
+      default:
+        forEachChild(stmt, (child) => {
+          if (isStmt(child)) {
+            visitStmt(scope, child);
+          } else {
+            visitExpr(scope, child);
+          }
+        });
+    }
+  };
+
+  if (fn.kind === "FuncDecl") {
+    declareFields(root, fn.recv, "receiver");
+  }
+  declareFields(root, fn.type.params, "param");
+  declareFields(root, fn.type.results, "result");
+  if (fn.body) {
+    visitBlock(root, fn.body.list, fn.body);
+  }
+
+  return { root, variables, references, resolved };
+}
+
+const STMT_KINDS = new Set([
+  "AssignStmt",
+  "BlockStmt",
+  "BranchStmt",
+  "CaseClause",
+  "CommClause",
+  "DeclStmt",
+  "DeferStmt",
+  "EmptyStmt",
+  "ExprStmt",
+  "ForStmt",
+  "GoStmt",
+  "IfStmt",
+  "IncDecStmt",
+  "LabeledStmt",
+  "RangeStmt",
+  "ReturnStmt",
+  "SelectStmt",
+  "SendStmt",
+  "SwitchStmt",
+  "TypeSwitchStmt",
+]);
+
+function isStmt(node: Node): node is Stmt {
+  return STMT_KINDS.has(node.kind);
+}
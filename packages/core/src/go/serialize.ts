@@ -0,0 +1,505 @@
+import * as path from "path";
+import { GO_ANALYZER_VERSION } from "./cache.js";
+import { GoClosureComplexity } from "./complexity.js";
+import { GoConstantSymbol } from "./constants.js";
+import { GoImport, GoImportKind } from "./imports.js";
+import { GoParameter, GoSignature, GoTypeParam } from "./signature.js";
+import {
+  GoFieldSymbol,
+  GoFileSymbols,
+  GoFunctionSymbol,
+  GoTypeSymbol,
+  groupMethods,
+  Visibility,
+} from "./symbols.js";
+
+/**
+ * Version of the JSON schema below. Field names are part of the contract:
+ * additive changes keep the version, renames or removals bump it.
+ */
+export const GO_SYMBOLS_SCHEMA_VERSION = 1;
+
+export interface JsonPosition {
+  start_line: number;
+  start_column: number;
+  end_line: number;
+  end_column: number;
+}
+
+export interface JsonReceiver {
+  name: string | null;
+  type_name: string;
+  is_pointer: boolean;
+  /** Null unless the receiver type is generic */
+  type_params: string[] | null;
+}
+
+export interface JsonTypeParam {
+  name: string;
+  constraint: string;
+}
+
+export interface JsonParameter {
+  name: string | null;
+  type: string;
+  variadic: boolean;
+}
+
+export interface JsonSignature {
+  params: JsonParameter[];
+  results: JsonParameter[];
+  text: string;
+}
+
+export interface JsonClosure {
+  start_line: number;
+  end_line: number;
+  complexity: number;
+  usage: GoClosureComplexity["usage"];
+}
+
+export interface JsonSize {
+  lines: number;
+  code_lines: number;
+  comment_lines: number;
+  doc_lines: number;
+  statements: number;
+}
+
+export interface JsonFunction {
+  name: string;
+  qualified_name: string;
+  visibility: Visibility;
+  receiver: JsonReceiver | null;
+  /** Null for functions that are not generic */
+  type_params: JsonTypeParam[] | null;
+  signature: JsonSignature;
+  complexity: number;
+  cognitive_complexity: number;
+  closures: JsonClosure[];
+  size: JsonSize;
+  /** Paths of the named imports the function uses */
+  imports: string[];
+  /** Null until the symbols are annotated from a call graph */
+  fan_in: number | null;
+  fan_out: number | null;
+  coverage_pct: number | null;
+  maintainability_index: number | null;
+  documentation: string | null;
+  position: JsonPosition;
+  /** Only present, as true, when parsing recovered from an error in it */
+  partial?: boolean;
+}
+
+export interface JsonField {
+  name: string;
+  type: string;
+  visibility: Visibility;
+  embedded: boolean;
+  tag: string | null;
+  tags: Record<string, string>;
+  /** Fields of an anonymous struct type, null for other types */
+  fields: JsonField[] | null;
+  line: number;
+  column: number;
+  documentation: string | null;
+}
+
+export interface JsonType {
+  name: string;
+  kind: GoTypeSymbol["typeKind"];
+  /** Null for types that are not generic */
+  type_params: JsonTypeParam[] | null;
+  visibility: Visibility;
+  embedded: { type_name: string; is_pointer: boolean }[];
+  fields: JsonField[];
+  /** Qualified names of the methods declared on the type */
+  methods: string[];
+  documentation: string | null;
+  position: JsonPosition;
+  /** Only present, as true, when parsing recovered from an error in it */
+  partial?: boolean;
+}
+
+export interface JsonConstant {
+  name: string;
+  visibility: Visibility;
+  declared_type: string;
+  is_typed: boolean;
+  expression: string | null;
+  value: string | null;
+  iota: number;
+  grouped: boolean;
+  documentation: string | null;
+  position: JsonPosition;
+}
+
+export interface JsonImport {
+  path: string;
+  /** Null for dot and blank imports */
+  name: string | null;
+  kind: GoImportKind;
+  line: number;
+  column: number;
+}
+
+export interface JsonSyntaxError {
+  message: string;
+  line: number;
+  column: number;
+}
+
+export interface JsonFile {
+  /** Path relative to the repository root, with forward slashes */
+  path: string;
+  package: string;
+  imports: JsonImport[];
+  functions: JsonFunction[];
+  methods: JsonFunction[];
+  types: JsonType[];
+  constants: JsonConstant[];
+  /** Null until the symbols are annotated, or when the file has no functions */
+  maintainability_index: number | null;
+  /** Only present when parsing recovered from errors in the file */
+  syntax_errors?: JsonSyntaxError[];
+}
+
+/**
+ * Top-level JSON document for a Go symbol table
+ */
+export interface GoSymbolsDocument {
+  schema_version: number;
+  analyzer_version: string;
+  files: JsonFile[];
+}
+
+function toPosition(
+  symbol: GoFunctionSymbol | GoTypeSymbol | GoConstantSymbol,
+): JsonPosition {
+  return {
+    start_line: symbol.startLine,
+    start_column: symbol.startColumn,
+    end_line: symbol.endLine,
+    end_column: symbol.endColumn,
+  };
+}
+
+function fromPosition(position: JsonPosition, visibility: Visibility) {
+  const isExported = visibility === Visibility.Exported;
+  return {
+    startLine: position.start_line,
+    endLine: position.end_line,
+    startColumn: position.start_column,
+    endColumn: position.end_column,
+    isExported,
+    isPrivate: !isExported,
+  };
+}
+
+function toSignature(signature: GoSignature): JsonSignature {
+  const toParameter = (param: GoParameter): JsonParameter => ({
+    name: param.name ?? null,
+    type: param.type,
+    variadic: param.variadic,
+  });
+  return {
+    params: signature.params.map(toParameter),
+    results: signature.results.map(toParameter),
+    text: signature.text,
+  };
+}
+
+function fromSignature(json: JsonSignature): GoSignature {
+  const fromParameter = (param: JsonParameter): GoParameter => ({
+    name: param.name ?? undefined,
+    type: param.type,
+    variadic: param.variadic,
+  });
+  return {
+    params: json.params.map(fromParameter),
+    results: json.results.map(fromParameter),
+    text: json.text,
+  };
+}
+
+function toTypeParams(params: GoTypeParam[] | undefined) {
+  return params?.map(({ name, constraint }) => ({ name, constraint })) ?? null;
+}
+
+function fromTypeParams(json: JsonTypeParam[] | null) {
+  return (
+    json?.map(({ name, constraint }) => ({ name, constraint })) ?? undefined
+  );
+}
+
+function toFunction(symbol: GoFunctionSymbol): JsonFunction {
+  const { receiver } = symbol;
+  return {
+    name: symbol.name,
+    qualified_name: symbol.qualifiedName,
+    visibility: symbol.visibility,
+    receiver: receiver
+      ? {
+          name: receiver.name ?? null,
+          type_name: receiver.typeName,
+          is_pointer: receiver.isPointer,
+          type_params: receiver.typeParams ?? null,
+        }
+      : null,
+    type_params: toTypeParams(symbol.typeParams),
+    signature: toSignature(symbol.signature),
+    complexity: symbol.complexity,
+    cognitive_complexity: symbol.cognitiveComplexity,
+    closures: symbol.closures.map((closure) => ({
+      start_line: closure.startLine,
+      end_line: closure.endLine,
+      complexity: closure.complexity,
+      usage: closure.usage,
+    })),
+    size: {
+      lines: symbol.size.lines,
+      code_lines: symbol.size.codeLines,
+      comment_lines: symbol.size.commentLines,
+      doc_lines: symbol.size.docLines,
+      statements: symbol.size.statements,
+    },
+    imports: symbol.imports,
+    fan_in: symbol.fanIn ?? null,
+    fan_out: symbol.fanOut ?? null,
+    coverage_pct: symbol.coveragePct ?? null,
+    maintainability_index: symbol.maintainabilityIndex ?? null,
+    documentation: symbol.documentation ?? null,
+    position: toPosition(symbol),
+    ...(symbol.partial && { partial: true }),
+  };
+}
+
+function fromFunction(json: JsonFunction): GoFunctionSymbol {
+  return {
+    name: json.name,
+    type: "function",
+    qualifiedName: json.qualified_name,
+    receiver: json.receiver
+      ? {
+          name: json.receiver.name ?? undefined,
+          typeName: json.receiver.type_name,
+          isPointer: json.receiver.is_pointer,
+          typeParams: json.receiver.type_params ?? undefined,
+        }
+      : undefined,
+    typeParams: fromTypeParams(json.type_params),
+    signature: fromSignature(json.signature),
+    ...fromPosition(json.position, json.visibility),
+    visibility: json.visibility,
+    documentation: json.documentation ?? undefined,
+    complexity: json.complexity,
+    cognitiveComplexity: json.cognitive_complexity,
+    closures: json.closures.map((closure) => ({
+      startLine: closure.start_line,
+      endLine: closure.end_line,
+      complexity: closure.complexity,
+      usage: closure.usage,
+    })),
+    size: {
+      lines: json.size.lines,
+      codeLines: json.size.code_lines,
+      commentLines: json.size.comment_lines,
+      docLines: json.size.doc_lines,
+      statements: json.size.statements,
+    },
+    imports: json.imports,
+    fanIn: json.fan_in ?? undefined,
+    fanOut: json.fan_out ?? undefined,
+    coveragePct: json.coverage_pct ?? undefined,
+    maintainabilityIndex: json.maintainability_index ?? undefined,
+    ...(json.partial && { partial: true }),
+  };
+}
+
+function toField(field: GoFieldSymbol): JsonField {
+  return {
+    name: field.name,
+    type: field.type,
+    visibility: field.visibility,
+    embedded: field.embedded,
+    tag: field.tag ?? null,
+    tags: field.tags,
+    fields: field.fields?.map(toField) ?? null,
+    line: field.line,
+    column: field.column,
+    documentation: field.documentation ?? null,
+  };
+}
+
+function fromField(json: JsonField): GoFieldSymbol {
+  return {
+    name: json.name,
+    type: json.type,
+    visibility: json.visibility,
+    embedded: json.embedded,
+    tag: json.tag ?? undefined,
+    tags: json.tags,
+    fields: json.fields?.map(fromField) ?? undefined,
+    line: json.line,
+    column: json.column,
+    documentation: json.documentation ?? undefined,
+  };
+}
+
+function toType(symbol: GoTypeSymbol): JsonType {
+  return {
+    name: symbol.name,
+    kind: symbol.typeKind,
+    type_params: toTypeParams(symbol.typeParams),
+    visibility: symbol.visibility,
+    embedded: symbol.embedded.map((embedded) => ({
+      type_name: embedded.typeName,
+      is_pointer: embedded.isPointer,
+    })),
+    fields: symbol.fields.map(toField),
+    methods: symbol.methods.map((method) => method.qualifiedName),
+    documentation: symbol.documentation ?? null,
+    position: toPosition(symbol),
+    ...(symbol.partial && { partial: true }),
+  };
+}
+
+function fromType(json: JsonType): GoTypeSymbol {
+  return {
+    name: json.name,
+    type: json.kind === "interface" ? "interface" : "type",
+    typeKind: json.kind,
+    typeParams: fromTypeParams(json.type_params),
+    visibility: json.visibility,
+    embedded: json.embedded.map((embedded) => ({
+      typeName: embedded.type_name,
+      isPointer: embedded.is_pointer,
+    })),
+    fields: json.fields.map(fromField),
+    // Re-attached from the file's methods once all symbols are read
+    methods: [],
+    ...fromPosition(json.position, json.visibility),
+    documentation: json.documentation ?? undefined,
+    ...(json.partial && { partial: true }),
+  };
+}
+
+function toConstant(symbol: GoConstantSymbol): JsonConstant {
+  return {
+    name: symbol.name,
+    visibility: symbol.visibility,
+    declared_type: symbol.declaredType,
+    is_typed: symbol.isTyped,
+    expression: symbol.expression ?? null,
+    value: symbol.value ?? null,
+    iota: symbol.iota,
+    grouped: symbol.grouped,
+    documentation: symbol.documentation ?? null,
+    position: toPosition(symbol),
+  };
+}
+
+function fromConstant(json: JsonConstant): GoConstantSymbol {
+  return {
+    name: json.name,
+    type: "constant",
+    ...fromPosition(json.position, json.visibility),
+    documentation: json.documentation ?? undefined,
+    visibility: json.visibility,
+    declaredType: json.declared_type,
+    isTyped: json.is_typed,
+    expression: json.expression ?? undefined,
+    value: json.value ?? undefined,
+    iota: json.iota,
+    grouped: json.grouped,
+  };
+}
+
+/**
+ * Convert symbol tables to the JSON document model, with file paths made
+ * relative to the repository root
+ */
+export function toGoSymbolsDocument(
+  files: GoFileSymbols[],
+  rootPath: string,
+): GoSymbolsDocument {
+  return {
+    schema_version: GO_SYMBOLS_SCHEMA_VERSION,
+    analyzer_version: GO_ANALYZER_VERSION,
+    files: files.map((file) => ({
+      path: path
+        .relative(rootPath, path.resolve(rootPath, file.filePath))
+        .split(path.sep)
+        .join("/"),
+      package: file.packageName,
+      imports: file.imports.map((spec) => ({
+        path: spec.path,
+        name: spec.name ?? null,
+        kind: spec.kind,
+        line: spec.line,
+        column: spec.column,
+      })),
+      functions: file.functions.map(toFunction),
+      methods: file.methods.map(toFunction),
+      types: file.types.map(toType),
+      constants: file.constants.map(toConstant),
+      maintainability_index: file.maintainabilityIndex ?? null,
+      ...(file.syntaxErrors && {
+        syntax_errors: file.syntaxErrors.map((error) => ({ ...error })),
+      }),
+    })),
+  };
+}
+
+/**
+ * Serialize symbol tables to JSON
+ */
+export function serializeGoSymbols(
+  files: GoFileSymbols[],
+  rootPath: string,
+): string {
+  return JSON.stringify(toGoSymbolsDocument(files, rootPath), null, 2);
+}
+
+/**
+ * Read a JSON document back into symbol tables. Paths are resolved against
+ * the repository root.
+ */
+export function parseGoSymbolsJson(
+  json: string,
+  rootPath: string,
+): GoFileSymbols[] {
+  const document: GoSymbolsDocument = JSON.parse(json);
+  if (document.schema_version !== GO_SYMBOLS_SCHEMA_VERSION) {
+    throw new Error(
+      `Unsupported Go symbols schema version ${document.schema_version}; expected ${GO_SYMBOLS_SCHEMA_VERSION}`,
+    );
+  }
+
+  return document.files.map((file) => {
+    const methods = file.methods.map(fromFunction);
+    const types = file.types.map(fromType);
+    groupMethods(types, methods);
+    return {
+      filePath: path.resolve(rootPath, ...file.path.split("/")),
+      packageName: file.package,
+      imports: file.imports.map(
+        (spec): GoImport => ({
+          path: spec.path,
+          name: spec.name ?? undefined,
+          kind: spec.kind,
+          line: spec.line,
+          column: spec.column,
+        }),
+      ),
+      functions: file.functions.map(fromFunction),
+      methods,
+      types,
+      constants: file.constants.map(fromConstant),
+      maintainabilityIndex: file.maintainability_index ?? undefined,
+      ...(file.syntax_errors && {
+        syntaxErrors: file.syntax_errors.map((error) => ({ ...error })),
+      }),
+    };
+  });
+}
@@ -0,0 +1,464 @@
+import * as fs from "fs";
+import * as http from "http";
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { GO_ANALYZER_VERSION } from "./cache.js";
+import { discoverGoFiles } from "./discover.js";
+import { goFindingJson, GoFindingJson, streamGoFindings } from "./jsonl.js";
+import { GoSyntaxError } from "./lexer.js";
+import { GoOverlay } from "./overlay.js";
+import { parseGoFile } from "./parser.js";
+import { GoRuleRegistry } from "./rules.js";
+import {
+  GO_SYMBOLS_SCHEMA_VERSION,
+  JsonFile,
+  toGoSymbolsDocument,
+} from "./serialize.js";
+import { extractGoFileSymbols, GoFileSymbols } from "./symbols.js";
+
+/**
+ * Go Analysis Server
+ * ==================
+ * Serves the JSON symbol model and findings over HTTP, for dashboards and
+ * other services that cannot run the CLI:
+ *
+ * - `POST /analyze` takes `{"path": "pkg/store"}`, a directory under the
+ *   server root, and/or `{"files": {"store.go": "package store..."}}`, an
+ *   overlay of contents relative to that directory (or analyzed on their
+ *   own when no path is given). It returns the symbols of every file and
+ *   the findings of the rules.
+ * - `GET /symbols?path=pkg/store&name=Open` returns the symbols of a file or
+ *   directory, optionally only those named `name` (or `Type.name`).
+ *
+ * Every response, errors included, carries `schema_version` and
+ * `analyzer_version`. Paths in responses are relative to the server root,
+ * and requests cannot reach outside it. Source that does not parse is a 422
+ * naming the file and position; malformed requests are a 400, paths
+ * outside the root a 403, missing paths a 404 and anything else a 500.
+ *
+ * Analyses run a few at a time; requests beyond that wait their turn, and
+ * once the queue is full they are turned away with a 503. A client that
+ * disconnects cancels its analysis, which stops between files.
+ */
+
+export const DEFAULT_SERVER_CONCURRENCY = 2;
+
+export const DEFAULT_SERVER_QUEUE = 16;
+
+export const DEFAULT_SERVER_MAX_BODY_BYTES = 10 * 1024 * 1024;
+
+export interface GoServerOptions {
+  /** Directory requests are resolved in and confined to (default: the cwd) */
+  root?: string;
+  /** Analyses run at the same time (default: 2) */
+  concurrency?: number;
+  /** Requests waiting for a slot before new ones get a 503 (default: 16) */
+  maxQueued?: number;
+  /** Largest request body accepted (default: 10 MiB) */
+  maxBodyBytes?: number;
+  /** Rules run by `POST /analyze` (default: the built-in rules) */
+  registry?: GoRuleRegistry;
+}
+
+export interface GoServerError {
+  kind:
+    | "bad-request"
+    | "forbidden"
+    | "not-found"
+    | "method-not-allowed"
+    | "too-large"
+    | "parse"
+    | "overloaded"
+    | "internal";
+  message: string;
+  /** For parse failures: the file, relative to the root, and position */
+  file?: string;
+  line?: number;
+  column?: number;
+}
+
+interface Versioned {
+  schema_version: number;
+  analyzer_version: string;
+}
+
+export interface GoAnalyzeResponse extends Versioned {
+  files: JsonFile[];
+  findings: GoFindingJson[];
+}
+
+export interface GoSymbolsResponse extends Versioned {
+  files: JsonFile[];
+}
+
+export interface GoErrorResponse extends Versioned {
+  error: GoServerError;
+}
+
+const STATUS: Record<GoServerError["kind"], number> = {
+  "bad-request": 400,
+  forbidden: 403,
+  "not-found": 404,
+  "method-not-allowed": 405,
+  "too-large": 413,
+  parse: 422,
+  overloaded: 503,
+  internal: 500,
+};
+
+// Root of overlays analyzed without a directory on disk
+const VIRTUAL_ROOT = path.resolve("/");
+
+class RequestError extends Error {
+  readonly error: GoServerError;
+  /** Response headers the error calls for, such as `Allow` */
+  readonly headers: Record<string, string>;
+
+  constructor(error: GoServerError, headers: Record<string, string> = {}) {
+    super(error.message);
+    this.name = "RequestError";
+    this.error = error;
+    this.headers = headers;
+  }
+}
+
+function fail(
+  kind: GoServerError["kind"],
+  message: string,
+  headers: Record<string, string> = {},
+): never {
+  throw new RequestError({ kind, message }, headers);
+}
+
+function versioned(): Versioned {
+  return {
+    schema_version: GO_SYMBOLS_SCHEMA_VERSION,
+    analyzer_version: GO_ANALYZER_VERSION,
+  };
+}
+
+/**
+ * Admits a bounded number of analyses at a time, queueing a bounded number
+ * more
+ */
+class Limiter {
+  private readonly concurrency: number;
+  private readonly maxQueued: number;
+  private active = 0;
+  private readonly queue: (() => void)[] = [];
+
+  constructor(concurrency: number, maxQueued: number) {
+    this.concurrency = Math.max(1, Math.floor(concurrency));
+    this.maxQueued = Math.max(0, Math.floor(maxQueued));
+  }
+
+  async acquire(signal: AbortSignal): Promise<() => void> {
+    if (this.active >= this.concurrency) {
+      if (this.queue.length >= this.maxQueued) {
+        fail("overloaded", "Too many analyses in progress; retry later", {
+          "Retry-After": "1",
+        });
+      }
+      await new Promise<void>((resolve, reject) => {
+        const admit = () => {
+          signal.removeEventListener("abort", abort);
+          resolve();
+        };
+        const abort = () => {
+          this.queue.splice(this.queue.indexOf(admit), 1);
+          reject(signal.reason);
+        };
+        this.queue.push(admit);
+        signal.addEventListener("abort", abort, { once: true });
+      });
+    } else {
+      this.active++;
+    }
+    let released = false;
+    return () => {
+      if (released) return;
+      released = true;
+      // A waiting request takes over the slot
+      const next = this.queue.shift();
+      if (next) next();
+      else this.active--;
+    };
+  }
+}
+
+async function readBody(
+  req: http.IncomingMessage,
+  maxBytes: number,
+): Promise<string> {
+  const chunks: Buffer[] = [];
+  let size = 0;
+  for await (const chunk of req) {
+    size += chunk.length;
+    if (size > maxBytes) {
+      fail("too-large", `Request body exceeds ${maxBytes} bytes`);
+    }
+    chunks.push(chunk);
+  }
+  return Buffer.concat(chunks).toString("utf-8");
+}
+
+// Hands the event loop a turn, so a disconnect is noticed between files
+async function checkpoint(signal: AbortSignal): Promise<void> {
+  await new Promise<void>((resolve) => setImmediate(resolve));
+  signal.throwIfAborted();
+}
+
+class GoAnalysisService {
+  private readonly root: string;
+  private readonly options: GoServerOptions;
+  private readonly limiter: Limiter;
+
+  constructor(options: GoServerOptions) {
+    this.options = options;
+    this.root = path.resolve(options.root ?? process.cwd());
+    this.limiter = new Limiter(
+      options.concurrency ?? DEFAULT_SERVER_CONCURRENCY,
+      options.maxQueued ?? DEFAULT_SERVER_QUEUE,
+    );
+  }
+
+  // A requested path resolved in the root, which it may not leave
+  private resolve(requested: unknown): string {
+    if (typeof requested !== "string" || requested.length === 0) {
+      fail("bad-request", "path must be a non-empty string");
+    }
+    const resolved = path.resolve(this.root, requested);
+    const relative = path.relative(this.root, resolved);
+    if (relative.startsWith("..") || path.isAbsolute(relative)) {
+      fail("forbidden", `${requested} is outside the server root`);
+    }
+    return resolved;
+  }
+
+  private async stat(target: string, requested: string): Promise<fs.Stats> {
+    try {
+      return await fs.promises.stat(target);
+    } catch {
+      fail("not-found", `${requested} does not exist`);
+    }
+  }
+
+  private async parse(
+    filePaths: string[],
+    overlay: GoOverlay,
+    root: string,
+    signal: AbortSignal,
+  ): Promise<GoFile[]> {
+    const files: GoFile[] = [];
+    for (const filePath of filePaths) {
+      const source = await overlay.readFile(filePath);
+      try {
+        files.push(parseGoFile(source, filePath));
+      } catch (error) {
+        if (!(error instanceof GoSyntaxError)) throw error;
+        throw new RequestError({
+          kind: "parse",
+          message: error.message,
+          file: path.relative(root, filePath).split(path.sep).join("/"),
+          line: error.line,
+          column: error.column,
+        });
+      }
+      await checkpoint(signal);
+    }
+    return files;
+  }
+
+  private overlay(files: unknown, directory: string): GoOverlay {
+    const overlay = new GoOverlay();
+    if (files === undefined) return overlay;
+    if (typeof files !== "object" || files === null || Array.isArray(files)) {
+      fail("bad-request", "files must map paths to Go source");
+    }
+    for (const [name, content] of Object.entries(files)) {
+      const filePath = path.resolve(directory, name);
+      const relative = path.relative(directory, filePath);
+      if (typeof content !== "string" || !name.endsWith(".go")) {
+        fail("bad-request", `files.${name} must be Go source in a .go file`);
+      }
+      if (relative.startsWith("..") || path.isAbsolute(relative)) {
+        fail("forbidden", `files.${name} is outside the analyzed directory`);
+      }
+      overlay.set(filePath, content);
+    }
+    return overlay;
+  }
+
+  async analyze(
+    body: string,
+    signal: AbortSignal,
+  ): Promise<GoAnalyzeResponse> {
+    let request: { path?: unknown; files?: unknown };
+    try {
+      request = JSON.parse(body);
+    } catch {
+      fail("bad-request", "Request body must be JSON");
+    }
+    if (typeof request !== "object" || request === null) {
+      fail("bad-request", "Request body must be a JSON object");
+    }
+    if (request.path === undefined && request.files === undefined) {
+      fail("bad-request", "Give a path to analyze, files, or both");
+    }
+
+    let root = VIRTUAL_ROOT;
+    let filePaths: string[];
+    let overlay: GoOverlay;
+    if (request.path !== undefined) {
+      const directory = this.resolve(request.path);
+      const stats = await this.stat(directory, String(request.path));
+      if (!stats.isDirectory()) {
+        fail("bad-request", `${request.path} is not a directory`);
+      }
+      root = this.root;
+      overlay = this.overlay(request.files, directory);
+      filePaths = await discoverGoFiles(directory, { overlay });
+    } else {
+      overlay = this.overlay(request.files, VIRTUAL_ROOT);
+      filePaths = Object.keys(request.files)
+        .map((name) => path.resolve(VIRTUAL_ROOT, name))
+        .sort();
+    }
+
+    const files = await this.parse(filePaths, overlay, root, signal);
+    const findings: GoFindingJson[] = [];
+    const registry = this.options.registry;
+    for await (const batch of streamGoFindings(files, { registry })) {
+      signal.throwIfAborted();
+      findings.push(
+        ...batch.findings.map((finding) => goFindingJson(finding, { root })),
+      );
+    }
+    return {
+      ...versioned(),
+      files: toGoSymbolsDocument(files.map(extractGoFileSymbols), root).files,
+      findings,
+    };
+  }
+
+  async symbols(
+    query: URLSearchParams,
+    signal: AbortSignal,
+  ): Promise<GoSymbolsResponse> {
+    const requested = query.get("path") ?? ".";
+    const target = this.resolve(requested);
+    const stats = await this.stat(target, requested);
+    const filePaths = stats.isDirectory()
+      ? await discoverGoFiles(target)
+      : [target];
+    const overlay = new GoOverlay();
+    const files = await this.parse(filePaths, overlay, this.root, signal);
+
+    const name = query.get("name");
+    const matches = (symbol: { name: string; qualifiedName?: string }) =>
+      name === null || symbol.name === name || symbol.qualifiedName === name;
+    const symbols = files
+      .map(extractGoFileSymbols)
+      .map(
+        (file): GoFileSymbols => ({
+          ...file,
+          functions: file.functions.filter(matches),
+          methods: file.methods.filter(matches),
+          types: file.types.filter(matches),
+          constants: file.constants.filter(matches),
+        }),
+      )
+      .filter(
+        (file) =>
+          name === null ||
+          file.functions.length +
+            file.methods.length +
+            file.types.length +
+            file.constants.length >
+            0,
+      );
+    return {
+      ...versioned(),
+      files: toGoSymbolsDocument(symbols, this.root).files,
+    };
+  }
+
+  private async route(
+    req: http.IncomingMessage,
+    signal: AbortSignal,
+  ): Promise<unknown> {
+    const url = new URL(req.url ?? "/", "http://localhost");
+    const routes: Record<string, string> = {
+      "/analyze": "POST",
+      "/symbols": "GET",
+    };
+    const method = routes[url.pathname];
+    if (!method) fail("not-found", `No endpoint at ${url.pathname}`);
+    if (req.method !== method) {
+      fail("method-not-allowed", `${url.pathname} only accepts ${method}`, {
+        Allow: method,
+      });
+    }
+    const maxBytes =
+      this.options.maxBodyBytes ?? DEFAULT_SERVER_MAX_BODY_BYTES;
+    const body = method === "POST" ? await readBody(req, maxBytes) : "";
+    const release = await this.limiter.acquire(signal);
+    try {
+      return url.pathname === "/analyze"
+        ? await this.analyze(body, signal)
+        : await this.symbols(url.searchParams, signal);
+    } finally {
+      release();
+    }
+  }
+
+  async handle(
+    req: http.IncomingMessage,
+    res: http.ServerResponse,
+  ): Promise<void> {
+    // The request lives as long as the client waits for the response
+    const controller = new AbortController();
+    res.on("close", () => {
+      if (!res.writableFinished) controller.abort();
+    });
+
+    let status = 200;
+    let headers: Record<string, string> = {};
+    let payload: unknown;
+    try {
+      payload = await this.route(req, controller.signal);
+    } catch (error) {
+      if (controller.signal.aborted) return;
+      const details: GoServerError =
+        error instanceof RequestError
+          ? error.error
+          : { kind: "internal", message: error?.message ?? String(error) };
+      if (error instanceof RequestError) headers = error.headers;
+      status = STATUS[details.kind];
+      const response: GoErrorResponse = { ...versioned(), error: details };
+      payload = response;
+    }
+    res.writeHead(status, { "Content-Type": "application/json", ...headers });
+    res.end(JSON.stringify(payload) + "\n");
+  }
+}
+
+/**
+ * A request listener serving the analysis endpoints, for mounting in an
+ * existing server
+ */
+export function goAnalysisHandler(
+  options: GoServerOptions = {},
+): http.RequestListener {
+  const service = new GoAnalysisService(options);
+  return (req, res) => void service.handle(req, res);
+}
+
+/**
+ * An HTTP server serving the analysis endpoints; call `listen` to start it
+ */
+export function createGoAnalysisServer(
+  options: GoServerOptions = {},
+): http.Server {
+  return http.createServer(goAnalysisHandler(options));
+}
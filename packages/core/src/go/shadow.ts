@@ -0,0 +1,151 @@
+import { AssignStmt, FuncDecl, GoFile, Ident, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import {
+  GoFunctionScopes,
+  GoScope,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+
+/**
+ * Go Variable Shadowing
+ * =====================
+ * Flags `:=` and `var` declarations in an inner scope that redeclare a
+ * variable of an enclosing scope of the same function, when the outer
+ * variable is read after the inner scope ends. That is the shape of the
+ * classic bug where `err` is assigned inside a block but the check after the
+ * block sees the outer, untouched `err`. Shadowing whose outer variable is
+ * not used afterwards is taken as intentional and not reported, as is the
+ * `x := x` copy idiom.
+ */
+
+export interface GoShadowFinding extends GoFinding {
+  rule: "shadowed-variable";
+  name: string;
+  /** Line of the outer declaration being shadowed */
+  shadowedLine: number;
+  /** Line of the first read of the outer variable after the inner scope */
+  usedLine: number;
+}
+
+// The variable a name declared at a position can shadow: the nearest one
+// in an enclosing scope declared before it, since a later declaration in an
+// enclosing block is not in scope yet
+function lookup(
+  scope: GoScope | undefined,
+  name: string,
+  pos: number,
+): GoVariable | undefined {
+  for (let current = scope; current; current = current.parent) {
+    const variable = current.variables.get(name);
+    if (variable && variable.ident.pos < pos) return variable;
+  }
+  return undefined;
+}
+
+class ShadowChecker {
+  private readonly file: GoFile;
+  private readonly findings: GoShadowFinding[] = [];
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  // The `:=` statement declaring each identifier
+  private declaringStatements(decl: FuncDecl): Map<Ident, AssignStmt> {
+    const statements = new Map<Ident, AssignStmt>();
+    inspect(decl, (node) => {
+      if (node.kind === "AssignStmt" && node.tok === ":=") {
+        node.lhs.forEach(
+          (expr) => expr.kind === "Ident" && statements.set(expr, node),
+        );
+      }
+    });
+    return statements;
+  }
+
+  // `=` in place of `:=` when every name the statement declares shadows an
+  // outer variable, so assigning updates the variables read afterwards
+  private fix(
+    stmt: AssignStmt | undefined,
+    scopes: GoFunctionScopes,
+  ): string | undefined {
+    if (!stmt) return undefined;
+    const declaresOnlyShadows = stmt.lhs.every((expr) => {
+      if (expr.kind !== "Ident" || expr.name === "_") return true;
+      const variable = scopes.resolved.get(expr);
+      if (!variable || variable.ident !== expr) return true;
+      return lookup(variable.scope.parent, expr.name, expr.pos) !== undefined;
+    });
+    if (!declaresOnlyShadows) return undefined;
+    const { source } = this.file;
+    const lhs = source.slice(stmt.lhs[0].pos, stmt.lhs.at(-1).end);
+    const rhs = source.slice(stmt.rhs[0].pos, stmt.rhs.at(-1).end);
+    return `${lhs} = ${rhs}`;
+  }
+
+  check(decl: FuncDecl): void {
+    const scopes = resolveFunctionScopes(decl);
+    const statements = this.declaringStatements(decl);
+
+    for (const inner of scopes.variables) {
+      if (inner.kind !== "local" || !inner.scope.parent) continue;
+      // Range variables and type switch bindings, which are declared ahead
+      // of the clause scopes they belong to, shadow idiomatically
+      if (inner.rangeOf || inner.ident.pos < inner.scope.node.pos) continue;
+      const init = inner.init?.expr;
+      if (init?.kind === "Ident" && init.name === inner.name) continue;
+      const outer = lookup(inner.scope.parent, inner.name, inner.ident.pos);
+      if (!outer) continue;
+
+      const scopeEnd = inner.scope.node.end;
+      const use = scopes.references.find(
+        (reference) =>
+          reference.variable === outer &&
+          reference.read &&
+          reference.ident.pos >= scopeEnd,
+      );
+      if (!use) continue;
+
+      const shadowedLine = this.line(outer.ident.pos);
+      const usedLine = this.line(use.ident.pos);
+      this.findings.push({
+        rule: "shadowed-variable",
+        severity: inner.name === "err" ? "medium" : "low",
+        filePath: this.file.filePath,
+        ...this.file.sourceMap.position(inner.ident.pos),
+        message: `${inner.name} shadows the ${inner.name} declared on line ${shadowedLine}, which is read on line ${usedLine} without seeing this value`,
+        fix: this.fix(statements.get(inner.ident), scopes),
+        name: inner.name,
+        shadowedLine,
+        usedLine,
+      });
+    }
+  }
+
+  results(): GoShadowFinding[] {
+    return this.findings;
+  }
+}
+
+/**
+ * Find shadowed variables whose outer declaration is used after the inner
+ * scope ends
+ */
+export function findShadowedVariables(files: GoFile[]): GoShadowFinding[] {
+  const findings: GoShadowFinding[] = [];
+  for (const file of files) {
+    const checker = new ShadowChecker(file);
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl" && decl.body) {
+        checker.check(decl);
+      }
+    }
+    findings.push(...checker.results());
+  }
+  return sortFindings(findings);
+}
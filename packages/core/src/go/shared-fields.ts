@@ -0,0 +1,236 @@
+import {
+  Expr,
+  Field,
+  FuncDecl,
+  GoFile,
+  Node,
+  StructType,
+  TypeSpec,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { groupGoPackages } from "./package.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Unsynchronized Shared Fields
+ * ============================
+ * Maps and slices are not safe for concurrent use, so a struct whose methods
+ * write a map or slice field while others read it races as soon as two
+ * goroutines share a value of the type. This pass finds map and slice fields
+ * written through the receiver in one method and read or written in another,
+ * where neither method takes a lock: methods calling `Lock` or `RLock` on
+ * anything are taken to be synchronized. Writes are assignments to the field
+ * or its elements, `++` and `--`, and `delete`, `clear` and `copy` into it.
+ *
+ * Whether the type is ever shared between goroutines is beyond a syntactic
+ * check, so findings are informational. Types documented as "not safe for
+ * concurrent use", as Go convention has it, and types listed in
+ * `ignoreTypes` are skipped.
+ */
+
+export interface SharedFieldOptions {
+  /** Types known not to be shared between goroutines */
+  ignoreTypes?: string[];
+}
+
+export interface GoSharedFieldFinding extends GoFinding {
+  rule: "unsynchronized-field";
+  type: string;
+  field: string;
+  kind: "map" | "slice";
+  /** Methods writing the field without a lock, in source order */
+  writers: string[];
+  /** Methods only reading it without a lock, in source order */
+  readers: string[];
+}
+
+const NOT_CONCURRENT = /not (safe|meant) for concurrent use/i;
+
+interface SharedField {
+  field: Field;
+  kind: "map" | "slice";
+}
+
+interface Struct {
+  file: GoFile;
+  spec: TypeSpec;
+  /** Map and slice fields by name */
+  fields: Map<string, SharedField>;
+  methods: FuncDecl[];
+}
+
+interface FieldAccess {
+  writers: string[];
+  readers: string[];
+}
+
+// `x[i][j]` and `(x)` reach `x`
+function target(expr: Expr): Expr {
+  if (expr.kind === "IndexExpr" || expr.kind === "ParenExpr") {
+    return target(expr.x);
+  }
+  return expr;
+}
+
+function locks(decl: FuncDecl): boolean {
+  let found = false;
+  inspect(decl.body, (node) => {
+    if (
+      node.kind === "CallExpr" &&
+      node.fun.kind === "SelectorExpr" &&
+      (node.fun.sel.name === "Lock" || node.fun.sel.name === "RLock")
+    ) {
+      found = true;
+    }
+    return !found;
+  });
+  return found;
+}
+
+class SharedFieldAnalyzer {
+  private readonly ignored: Set<string>;
+
+  constructor(options: SharedFieldOptions) {
+    this.ignored = new Set(options.ignoreTypes ?? []);
+  }
+
+  private structs(group: GoFile[]): Struct[] {
+    const structs = new Map<string, Struct>();
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+        for (const spec of decl.specs) {
+          if (spec.kind !== "TypeSpec" || spec.type.kind !== "StructType") {
+            continue;
+          }
+          const doc = (spec.doc ?? decl.doc)?.text ?? "";
+          if (this.ignored.has(spec.name.name) || NOT_CONCURRENT.test(doc)) {
+            continue;
+          }
+          const fields = new Map<string, SharedField>();
+          for (const field of (spec.type as StructType).fields.list) {
+            const kind: SharedField["kind"] | undefined =
+              field.type.kind === "MapType"
+                ? "map"
+                : field.type.kind === "ArrayType" && !field.type.len
+                  ? "slice"
+                  : undefined;
+            if (!kind) continue;
+            for (const name of field.names) {
+              fields.set(name.name, { field, kind });
+            }
+          }
+          if (fields.size === 0) continue;
+          structs.set(spec.name.name, { file, spec, fields, methods: [] });
+        }
+      }
+    }
+    for (const file of group) {
+      for (const decl of file.decls) {
+        const recv = decl.kind === "FuncDecl" ? decl.recv?.list[0] : undefined;
+        if (!recv || !decl.body) continue;
+        structs.get(baseTypeName(recv.type).name)?.methods.push(decl);
+      }
+    }
+    return [...structs.values()];
+  }
+
+  // How each field is accessed by unsynchronized methods
+  private accesses(struct: Struct): Map<string, FieldAccess> {
+    const accesses = new Map<string, FieldAccess>();
+    for (const decl of struct.methods) {
+      if (locks(decl)) continue;
+      const scopes = resolveFunctionScopes(decl);
+      // The field a receiver selector names, if it is one of the struct's
+      const field = (expr: Expr): string | undefined => {
+        const node = target(expr);
+        if (node.kind !== "SelectorExpr" || node.x.kind !== "Ident") {
+          return undefined;
+        }
+        if (scopes.resolved.get(node.x)?.kind !== "receiver") return undefined;
+        return struct.fields.has(node.sel.name) ? node.sel.name : undefined;
+      };
+      const written = new Set<string>();
+      const read = new Set<string>();
+      inspect(decl.body, (node: Node) => {
+        let targets: Expr[] = [];
+        if (node.kind === "AssignStmt" && node.tok !== ":=") {
+          targets = node.lhs;
+        } else if (node.kind === "IncDecStmt") {
+          targets = [node.x];
+        } else if (
+          node.kind === "CallExpr" &&
+          node.fun.kind === "Ident" &&
+          ["delete", "clear", "copy"].includes(node.fun.name) &&
+          node.args.length > 0
+        ) {
+          targets = [node.args[0]];
+        }
+        for (const expr of targets) {
+          const name = field(expr);
+          if (name) written.add(name);
+        }
+        if (node.kind === "SelectorExpr") {
+          const name = field(node);
+          if (name) read.add(name);
+        }
+      });
+      for (const name of new Set([...written, ...read])) {
+        if (!accesses.has(name)) {
+          accesses.set(name, { writers: [], readers: [] });
+        }
+        const access = accesses.get(name);
+        (written.has(name) ? access.writers : access.readers).push(
+          decl.name.name,
+        );
+      }
+    }
+    return accesses;
+  }
+
+  analyze(files: GoFile[]): GoSharedFieldFinding[] {
+    const findings: GoSharedFieldFinding[] = [];
+    for (const group of groupGoPackages(files)) {
+      for (const struct of this.structs(group)) {
+        const type = struct.spec.name.name;
+        for (const [name, access] of this.accesses(struct)) {
+          const methods = access.writers.length + access.readers.length;
+          if (access.writers.length === 0 || methods < 2) continue;
+          const { field, kind } = struct.fields.get(name);
+          const ident = field.names.find(
+            (candidate) => candidate.name === name,
+          );
+          const advice =
+            kind === "map"
+              ? "guard it with a sync.RWMutex or use a sync.Map"
+              : "guard it with a sync.RWMutex";
+          findings.push({
+            rule: "unsynchronized-field",
+            severity: "low",
+            filePath: struct.file.filePath,
+            ...struct.file.sourceMap.position(ident.pos),
+            message: `${type}.${name} is written by ${access.writers.join(", ")} and used by ${methods} methods without a lock, which races if ${type} is shared between goroutines; ${advice}`,
+            type,
+            field: name,
+            kind,
+            writers: access.writers,
+            readers: access.readers,
+          });
+        }
+      }
+    }
+    return findings;
+  }
+}
+
+/**
+ * Find map and slice fields that methods write and read without a lock
+ */
+export function findUnsynchronizedFields(
+  files: GoFile[],
+  options: SharedFieldOptions = {},
+): GoSharedFieldFinding[] {
+  return sortFindings(new SharedFieldAnalyzer(options).analyze(files));
+}
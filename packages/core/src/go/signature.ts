@@ -0,0 +1,180 @@
+import { Expr, FieldList, FuncType, GoFile } from "./ast.js";
+
+/**
+ * Go Signatures
+ * =============
+ * Normalizes function signatures into one entry per parameter and result, so
+ * grouped (`a, b int`), unnamed, blank and variadic parameters all look the
+ * same to callers that synthesize or rewrite call sites.
+ */
+
+/**
+ * A type parameter of a generic function or type
+ */
+export interface GoTypeParam {
+  name: string;
+  /** Canonical constraint text, e.g. `any` or `~int | ~float64` */
+  constraint: string;
+}
+
+/**
+ * A single parameter or result
+ */
+export interface GoParameter {
+  /** Declared name, `_` included; undefined when the parameter is unnamed */
+  name?: string;
+  /** Canonical type text; for a variadic parameter, the element type */
+  type: string;
+  /** Set for a final `...T` parameter, which callee code sees as `[]T` */
+  variadic: boolean;
+}
+
+export interface GoSignature {
+  params: GoParameter[];
+  results: GoParameter[];
+  /** Types only, as `go/types` prints them: `([]string) ([]string, error)` */
+  text: string;
+}
+
+/**
+ * Print a type expression in canonical form, the way `go/types` spells
+ * types: no comments, no line breaks and single spaces
+ */
+export function typeString(file: GoFile, expr: Expr): string {
+  const print = (node: Expr): string => typeString(file, node);
+  switch (expr.kind) {
+    case "Ident":
+      return expr.name;
+    case "SelectorExpr":
+      return `${print(expr.x)}.${expr.sel.name}`;
+    case "StarExpr":
+      return `*${print(expr.x)}`;
+    case "ParenExpr":
+      return `(${print(expr.x)})`;
+    case "BasicLit":
+      return expr.value;
+    case "Ellipsis":
+      return expr.elt ? `...${print(expr.elt)}` : "...";
+    case "ArrayType":
+      return `[${expr.len ? print(expr.len) : ""}]${print(expr.elt)}`;
+    case "MapType":
+      return `map[${print(expr.key)}]${print(expr.value)}`;
+    case "ChanType": {
+      const prefix = { both: "chan ", send: "chan<- ", recv: "<-chan " };
+      return `${prefix[expr.dir]}${print(expr.value)}`;
+    }
+    case "IndexExpr":
+      return `${print(expr.x)}[${print(expr.index)}]`;
+    case "IndexListExpr":
+      return `${print(expr.x)}[${expr.indices.map(print).join(", ")}]`;
+    case "UnaryExpr":
+      return `${expr.op}${print(expr.x)}`;
+    case "BinaryExpr":
+      return `${print(expr.x)} ${expr.op} ${print(expr.y)}`;
+    case "FuncType":
+      return `func${signatureText(file, expr)}`;
+    case "StructType":
+      return `struct{${expr.fields.list
+        .map((field) => {
+          const type = print(field.type);
+          const tag = field.tag ? ` ${field.tag.value}` : "";
+          return field.names.length > 0
+            ? `${field.names.map((name) => name.name).join(", ")} ${type}${tag}`
+            : `${type}${tag}`;
+        })
+        .join("; ")}}`;
+    case "InterfaceType":
+      return `interface{${expr.methods.list
+        .map((field) =>
+          field.names.length > 0 && field.type.kind === "FuncType"
+            ? `${field.names[0].name}${signatureText(file, field.type)}`
+            : print(field.type),
+        )
+        .join("; ")}}`;
+    default:
+      return file.source.slice(expr.pos, expr.end).replace(/\s+/g, " ");
+  }
+}
+
+function parameters(file: GoFile, list: FieldList | undefined): GoParameter[] {
+  const result: GoParameter[] = [];
+  for (const field of list?.list ?? []) {
+    const variadic = field.type.kind === "Ellipsis";
+    const typeExpr =
+      field.type.kind === "Ellipsis" && field.type.elt
+        ? field.type.elt
+        : field.type;
+    const type = typeString(file, typeExpr);
+    if (field.names.length === 0) {
+      result.push({ type, variadic });
+    }
+    for (const name of field.names) {
+      result.push({ name: name.name, type, variadic });
+    }
+  }
+  return result;
+}
+
+function signatureText(file: GoFile, type: FuncType): string {
+  const { params, results } = goSignature(file, type);
+  return formatSignature(params, results);
+}
+
+function formatSignature(params: GoParameter[], results: GoParameter[]) {
+  const types = (list: GoParameter[]) =>
+    list
+      .map((param) => (param.variadic ? `...${param.type}` : param.type))
+      .join(", ");
+  const resultText =
+    results.length === 0
+      ? ""
+      : results.length === 1
+        ? ` ${types(results)}`
+        : ` (${types(results)})`;
+  return `(${types(params)})${resultText}`;
+}
+
+/**
+ * Normalize a type parameter list into one entry per type parameter, so
+ * `[T, U any]` and `[T any, U any]` look the same
+ */
+export function goTypeParams(
+  file: GoFile,
+  list: FieldList | undefined,
+): GoTypeParam[] {
+  return (list?.list ?? []).flatMap((field) => {
+    const constraint = typeString(file, field.type);
+    return field.names.map((name) => ({ name: name.name, constraint }));
+  });
+}
+
+/**
+ * Normalize the parameters and results of a function type
+ */
+export function goSignature(file: GoFile, type: FuncType): GoSignature {
+  const params = parameters(file, type.params);
+  const results = parameters(file, type.results);
+  return { params, results, text: formatSignature(params, results) };
+}
+
+/**
+ * Signatures of the functions and methods declared in a package's files,
+ * keyed by bare name. Methods are matched by name alone, so a name declared
+ * more than once is left out rather than guessed.
+ */
+export function functionSignatures(files: GoFile[]): Map<string, GoSignature> {
+  const signatures = new Map<string, GoSignature>();
+  const ambiguous = new Set<string>();
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl") continue;
+      const name = decl.name.name;
+      if (signatures.has(name)) {
+        ambiguous.add(name);
+      }
+      signatures.set(name, goSignature(file, decl.type));
+    }
+  }
+  ambiguous.forEach((name) => signatures.delete(name));
+  return signatures;
+}
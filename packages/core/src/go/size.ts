@@ -0,0 +1,103 @@
+import { FuncDecl, GoFile, Node, inspect } from "./ast.js";
+
+/**
+ * Go Function Size
+ * ================
+ * Line and statement counts for a function, for triage: big functions are
+ * the first to split and the last to change without tests. The declaration
+ * runs from `func` to the closing brace; its doc comment belongs to the
+ * function but is counted on its own, so documenting a function never makes
+ * it look bigger. Every line of the declaration holds code, only comments,
+ * or nothing, and is counted once as such.
+ */
+
+export interface GoFunctionSize {
+  /** Physical lines from `func` to the closing brace */
+  lines: number;
+  /** Lines holding code, i.e. without blank and comment-only lines */
+  codeLines: number;
+  /** Comment-only lines inside the declaration */
+  commentLines: number;
+  /** Lines of the doc comment above the declaration */
+  docLines: number;
+  /**
+   * Statements in the body, nested ones and those in function literals
+   * included; blocks, case clauses and labels are not counted
+   */
+  statements: number;
+}
+
+// Statement lists; the init and post statements of `for`, `if` and
+// `switch` are part of their statement rather than statements of their own
+const LISTS = new Set(["BlockStmt", "CaseClause", "CommClause", "LabeledStmt"]);
+
+function isCounted(node: Node, parent: Node | undefined): boolean {
+  return (
+    parent !== undefined &&
+    LISTS.has(parent.kind) &&
+    node.kind.endsWith("Stmt") &&
+    node.kind !== "BlockStmt" &&
+    node.kind !== "EmptyStmt" &&
+    node.kind !== "LabeledStmt"
+  );
+}
+
+/**
+ * Measure a function or method declaration
+ */
+export function goFunctionSize(file: GoFile, decl: FuncDecl): GoFunctionSize {
+  const { sourceMap, source } = file;
+  const firstLine = sourceMap.line(decl.pos);
+  const lastLine = sourceMap.line(decl.end);
+  const count = lastLine - firstLine + 1;
+  const code = new Array<boolean>(count).fill(false);
+  const comment = new Array<boolean>(count).fill(false);
+
+  // Offsets inside the declaration covered by comments
+  const covered: [number, number][] = [];
+  for (const group of file.comments) {
+    if (group.end <= decl.pos || group.pos >= decl.end) continue;
+    for (const item of group.list) {
+      covered.push([item.pos, item.end]);
+      const from = sourceMap.line(item.pos);
+      const to = sourceMap.line(item.end);
+      for (let line = from; line <= to; line++) {
+        comment[line - firstLine] = true;
+      }
+    }
+  }
+  covered.sort((a, b) => a[0] - b[0]);
+
+  let next = 0;
+  let line = firstLine;
+  for (let offset = decl.pos; offset < decl.end; offset++) {
+    while (next < covered.length && covered[next][1] <= offset) next++;
+    const ch = source[offset];
+    if (ch === "\n") {
+      line++;
+    } else if (
+      !/\s/.test(ch) &&
+      !(next < covered.length && covered[next][0] <= offset)
+    ) {
+      code[line - firstLine] = true;
+    }
+  }
+
+  let statements = 0;
+  if (decl.body) {
+    inspect(decl.body, (node, parents) => {
+      if (isCounted(node, parents.at(-1))) statements++;
+    });
+  }
+
+  const codeLines = code.filter(Boolean).length;
+  return {
+    lines: count,
+    codeLines,
+    commentLines: comment.filter((has, i) => has && !code[i]).length,
+    docLines: decl.doc
+      ? sourceMap.line(decl.doc.end) - sourceMap.line(decl.doc.pos) + 1
+      : 0,
+    statements,
+  };
+}
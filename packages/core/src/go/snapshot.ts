@@ -0,0 +1,707 @@
+import * as fs from "fs";
+import * as path from "path";
+import { TextEdit } from "../diff.js";
+import { Expr, FuncDecl, GoFile } from "./ast.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { goFunctionSymbol } from "./symbols.js";
+
+/**
+ * Behavioral Snapshots
+ * ====================
+ * Records the inputs and outputs of target functions while the existing tests
+ * run, then replays the same inputs against the refactored code and diffs the
+ * outputs. Recording wraps each target in a function that serializes its
+ * receiver, arguments and results to JSON lines; replay is a generated Go test
+ * that decodes every recorded call, makes it again and writes what came back.
+ *
+ * Values are captured with encoding/json, so only exported struct fields are
+ * recorded, errors are compared by message, and channels and functions cannot
+ * be captured at all.
+ */
+
+/** Environment variable naming the file calls are recorded to */
+export const SNAPSHOT_RECORD_ENV = "REFACTOGENT_SNAPSHOT";
+
+/** Environment variable naming the file replayed calls are written to */
+export const SNAPSHOT_REPLAY_ENV = "REFACTOGENT_SNAPSHOT_REPLAY";
+
+const HELPER_FILE = "refactogent_snapshot.go";
+const REPLAY_FILE = "refactogent_snapshot_replay_test.go";
+
+export interface SnapshotOptions {
+  /** Log each warning as it is raised */
+  verbose?: boolean;
+}
+
+/**
+ * Something left out of a snapshot, and why
+ */
+export interface SnapshotWarning {
+  function: string;
+  message: string;
+}
+
+/**
+ * A generated Go source file
+ */
+export interface SnapshotFile {
+  path: string;
+  content: string;
+}
+
+export interface SnapshotInstrumentation {
+  /** Edits to the source file renaming targets behind recording wrappers */
+  result: GoRefactorResult;
+  /** Recording helpers, added to the package next to the source file */
+  helper: SnapshotFile;
+  functions: string[];
+  warnings: SnapshotWarning[];
+}
+
+export interface SnapshotReplay {
+  test: SnapshotFile;
+  functions: string[];
+  warnings: SnapshotWarning[];
+}
+
+/**
+ * One recorded or replayed call
+ */
+export interface SnapshotEntry {
+  function: string;
+  receiver?: unknown;
+  args: unknown[];
+  /** Null when the call could not be replayed */
+  results: unknown[] | null;
+  /** Set when the replayed call panicked */
+  panic?: string;
+}
+
+/**
+ * A replayed call whose output differs from the recording
+ */
+export interface SnapshotMismatch {
+  function: string;
+  /** 1-based position of the call in the recording */
+  call: number;
+  args: unknown[];
+  /** Path to the smallest differing value, such as `results[0][2]` */
+  path: string;
+  expected: unknown;
+  actual: unknown;
+  /** Why the call could not be compared, when it could not */
+  reason?: string;
+}
+
+interface SnapshotParam {
+  type: string;
+  variadic: boolean;
+}
+
+interface SnapshotTarget {
+  decl: FuncDecl;
+  name: string;
+  receiver?: { type: string; pointer: boolean; base: string };
+  params: SnapshotParam[];
+  results: { type: string; compared: boolean }[];
+}
+
+const UNCAPTURABLE = /\bchan\b|\bfunc\b|unsafe\.Pointer/;
+
+function text(file: GoFile, expr: Expr): string {
+  return file.source.slice(expr.pos, expr.end);
+}
+
+function interfaceNames(file: GoFile): Set<string> {
+  const names = new Set(["any", "error"]);
+  for (const decl of file.decls) {
+    if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+    for (const spec of decl.specs) {
+      if (spec.kind === "TypeSpec" && spec.type.kind === "InterfaceType") {
+        names.add(spec.name.name);
+      }
+    }
+  }
+  return names;
+}
+
+function receiverWarning(file: GoFile, base: string): string | undefined {
+  for (const decl of file.decls) {
+    if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+    for (const spec of decl.specs) {
+      if (spec.kind !== "TypeSpec" || spec.name.name !== base) continue;
+      if (spec.type.kind !== "StructType") return undefined;
+      const unexported = spec.type.fields.list
+        .flatMap((field) => field.names.map((name) => name.name))
+        .filter((name) => !/^\p{Lu}/u.test(name));
+      if (unexported.length > 0) {
+        return `receiver fields ${unexported.join(", ")} are unexported and replay with zero values`;
+      }
+    }
+  }
+  return undefined;
+}
+
+/**
+ * Work out how to capture and replay a function, or why it cannot be
+ */
+function snapshotTarget(
+  file: GoFile,
+  decl: FuncDecl,
+  warn: (message: string) => void,
+): SnapshotTarget | undefined {
+  const symbol = goFunctionSymbol(file, decl);
+  if (decl.type.typeParams || !decl.body) {
+    warn(decl.body ? "generic functions are not supported" : "has no body");
+    return undefined;
+  }
+
+  const interfaces = interfaceNames(file);
+  const params: SnapshotParam[] = [];
+  for (const field of decl.type.params.list) {
+    const variadic = field.type.kind === "Ellipsis";
+    const type = variadic
+      ? `[]${text(file, (field.type as Expr & { kind: "Ellipsis" }).elt)}`
+      : text(file, field.type);
+    const label = field.names[0]?.name ?? type;
+    if (UNCAPTURABLE.test(type)) {
+      warn(`parameter ${label} has type ${type}, which cannot be captured`);
+      return undefined;
+    }
+    if (interfaces.has(type) || type.startsWith("interface")) {
+      warn(
+        `parameter ${label} has interface type ${type}, which cannot be decoded`,
+      );
+      return undefined;
+    }
+    const count = Math.max(field.names.length, 1);
+    for (let i = 0; i < count; i++) params.push({ type, variadic });
+  }
+
+  const results: SnapshotTarget["results"] = [];
+  for (const field of decl.type.results?.list ?? []) {
+    const type = text(file, field.type);
+    const count = Math.max(field.names.length, 1);
+    for (let i = 0; i < count; i++) {
+      const compared = !UNCAPTURABLE.test(type);
+      if (!compared) {
+        warn(
+          `result ${results.length} has type ${type}, which is not compared`,
+        );
+      }
+      results.push({ type, compared });
+    }
+  }
+  if (results.length === 0) {
+    warn("returns nothing to compare");
+    return undefined;
+  }
+
+  let receiver: SnapshotTarget["receiver"];
+  if (decl.recv) {
+    const recvType = decl.recv.list[0].type;
+    if (
+      recvType.kind === "IndexExpr" ||
+      recvType.kind === "IndexListExpr" ||
+      (recvType.kind === "StarExpr" && recvType.x.kind !== "Ident")
+    ) {
+      warn("generic receivers are not supported");
+      return undefined;
+    }
+    receiver = {
+      type: text(file, recvType),
+      pointer: symbol.receiver.isPointer,
+      base: symbol.receiver.typeName,
+    };
+    const fields = receiverWarning(file, receiver.base);
+    if (fields) warn(fields);
+  }
+
+  return { decl, name: symbol.qualifiedName, receiver, params, results };
+}
+
+function selectTargets(
+  file: GoFile,
+  functionNames: string[],
+  options: SnapshotOptions,
+  warnings: SnapshotWarning[],
+): SnapshotTarget[] {
+  const targets: SnapshotTarget[] = [];
+  for (const requested of functionNames) {
+    const decls = file.decls.filter(
+      (decl): decl is FuncDecl =>
+        decl.kind === "FuncDecl" &&
+        (decl.name.name === requested ||
+          goFunctionSymbol(file, decl).qualifiedName === requested),
+    );
+    if (decls.length === 0) {
+      throw new GoRefactorError(
+        `No function named ${requested} in ${file.filePath}`,
+      );
+    }
+    for (const decl of decls) {
+      const name = goFunctionSymbol(file, decl).qualifiedName;
+      const warn = (message: string) => {
+        warnings.push({ function: name, message });
+        if (options.verbose) {
+          console.log(`⚠️  ${name}: ${message}`);
+        }
+      };
+      const target = snapshotTarget(file, decl, warn);
+      if (target) targets.push(target);
+    }
+  }
+  return targets;
+}
+
+function originalName(name: string): string {
+  return `refactogentOriginal${name.charAt(0).toUpperCase()}${name.slice(1)}`;
+}
+
+function wrapper(target: SnapshotTarget): string {
+  const { decl, receiver, params, results } = target;
+  const args = params.map((_, i) => `a${i}`);
+  const outs = results.map((_, i) => `r${i}`);
+  const paramList = params
+    .map((param, i) =>
+      param.variadic
+        ? `${args[i]} ...${param.type.slice(2)}`
+        : `${args[i]} ${param.type}`,
+    )
+    .join(", ");
+  const resultList =
+    results.length === 1
+      ? ` ${results[0].type}`
+      : ` (${results.map((result) => result.type).join(", ")})`;
+  const callArgs = params
+    .map((param, i) => (param.variadic ? `${args[i]}...` : args[i]))
+    .join(", ");
+  const callee = receiver
+    ? `r.${originalName(decl.name.name)}`
+    : originalName(decl.name.name);
+  const recorded = results.map((result, i) =>
+    result.compared ? outs[i] : "nil",
+  );
+
+  const receiverText = receiver ? `(r ${receiver.type}) ` : "";
+  const captured = [receiver ? "r" : "nil", ...args].join(", ");
+  return [
+    `func ${receiverText}${decl.name.name}(${paramList})${resultList} {`,
+    `\tsnapshot := refactogentSnapshotBegin(${captured})`,
+    `\t${outs.join(", ")} := ${callee}(${callArgs})`,
+    `\trefactogentSnapshotEnd(${[
+      "snapshot",
+      JSON.stringify(target.name),
+      ...recorded,
+    ].join(", ")})`,
+    `\treturn ${outs.join(", ")}`,
+    "}",
+  ].join("\n");
+}
+
+function helperSource(packageName: string): string {
+  return `// Code generated by refactogent. DO NOT EDIT.
+
+// Recording helpers for behavioral snapshots. Calls to wrapped functions are
+// appended as JSON lines to the file named by ${SNAPSHOT_RECORD_ENV}.
+
+package ${packageName}
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+var refactogentSnapshotMu sync.Mutex
+
+type refactogentSnapshot struct {
+	Function string            \`json:"function"\`
+	Receiver json.RawMessage   \`json:"receiver,omitempty"\`
+	Args     []json.RawMessage \`json:"args"\`
+	Results  []json.RawMessage \`json:"results"\`
+}
+
+func refactogentSnapshotValue(v any) json.RawMessage {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+// refactogentSnapshotBegin captures the receiver and arguments before the
+// call, so changes the function makes to them are not recorded as inputs.
+func refactogentSnapshotBegin(receiver any, args ...any) refactogentSnapshot {
+	snapshot := refactogentSnapshot{Args: []json.RawMessage{}}
+	if os.Getenv("${SNAPSHOT_RECORD_ENV}") == "" {
+		return snapshot
+	}
+	if receiver != nil {
+		snapshot.Receiver = refactogentSnapshotValue(receiver)
+	}
+	for _, arg := range args {
+		snapshot.Args = append(snapshot.Args, refactogentSnapshotValue(arg))
+	}
+	return snapshot
+}
+
+func refactogentSnapshotEnd(snapshot refactogentSnapshot, function string, results ...any) {
+	path := os.Getenv("${SNAPSHOT_RECORD_ENV}")
+	if path == "" {
+		return
+	}
+	snapshot.Function = function
+	snapshot.Results = []json.RawMessage{}
+	for _, result := range results {
+		snapshot.Results = append(snapshot.Results, refactogentSnapshotValue(result))
+	}
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+	refactogentSnapshotMu.Lock()
+	defer refactogentSnapshotMu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\\n'))
+}
+`;
+}
+
+/**
+ * Wrap target functions so every call made while the package's tests run is
+ * recorded. Targets are named as in the symbol table (`Func` or
+ * `Type.Method`); a bare method name matches the method on any type.
+ */
+export function instrumentSnapshots(
+  file: GoFile,
+  functionNames: string[],
+  options: SnapshotOptions = {},
+): SnapshotInstrumentation {
+  const warnings: SnapshotWarning[] = [];
+  const targets = selectTargets(file, functionNames, options, warnings);
+
+  const edits: TextEdit[] = [];
+  for (const target of targets) {
+    const { decl } = target;
+    edits.push(
+      {
+        start: decl.name.pos,
+        end: decl.name.end,
+        newText: originalName(decl.name.name),
+      },
+      { start: decl.end, end: decl.end, newText: `\n\n${wrapper(target)}` },
+    );
+  }
+
+  return {
+    result: refactorResult(file, edits),
+    helper: {
+      path: path.join(path.dirname(file.filePath), HELPER_FILE),
+      content: helperSource(file.packageName.name),
+    },
+    functions: targets.map((target) => target.name),
+    warnings,
+  };
+}
+
+function replayCase(target: SnapshotTarget): string[] {
+  const { decl, receiver, params, results } = target;
+  const lines = [`\tcase ${JSON.stringify(target.name)}:`];
+  if (receiver) {
+    lines.push(
+      `\t\tvar r ${receiver.type}`,
+      "\t\trefactogentDecode(t, call.Receiver, &r)",
+    );
+    if (receiver.pointer) {
+      lines.push(
+        "\t\tif r == nil {",
+        `\t\t\tr = new(${receiver.base})`,
+        "\t\t}",
+      );
+    }
+  }
+  params.forEach((param, i) => {
+    lines.push(
+      `\t\tvar a${i} ${param.type}`,
+      `\t\trefactogentDecode(t, call.Args[${i}], &a${i})`,
+    );
+  });
+  const outs = results.map((result, i) => (result.compared ? `r${i}` : "_"));
+  const args = params
+    .map((param, i) => (param.variadic ? `a${i}...` : `a${i}`))
+    .join(", ");
+  const callee = receiver ? `r.${decl.name.name}` : decl.name.name;
+  const encoded = outs.map((out) => (out === "_" ? "nil" : out));
+  lines.push(
+    `\t\t${outs.join(", ")} := ${callee}(${args})`,
+    `\t\treturn refactogentEncode(${encoded.join(", ")})`,
+  );
+  return lines;
+}
+
+function replaySource(packageName: string, targets: SnapshotTarget[]): string {
+  const cases = targets.flatMap(replayCase).join("\n");
+  return `// Code generated by refactogent. DO NOT EDIT.
+
+// Replays calls recorded in ${SNAPSHOT_RECORD_ENV} and writes their results to
+// ${SNAPSHOT_REPLAY_ENV}, one line per recorded call.
+
+package ${packageName}
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+)
+
+type refactogentReplayCall struct {
+	Function string            \`json:"function"\`
+	Receiver json.RawMessage   \`json:"receiver,omitempty"\`
+	Args     []json.RawMessage \`json:"args"\`
+	Results  []json.RawMessage \`json:"results"\`
+	Panic    string            \`json:"panic,omitempty"\`
+}
+
+func TestRefactogentSnapshotReplay(t *testing.T) {
+	input, output := os.Getenv("${SNAPSHOT_RECORD_ENV}"), os.Getenv("${SNAPSHOT_REPLAY_ENV}")
+	if input == "" || output == "" {
+		t.Skip("${SNAPSHOT_RECORD_ENV} and ${SNAPSHOT_REPLAY_ENV} are not set")
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var call refactogentReplayCall
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			t.Fatal(err)
+		}
+		call.Results, call.Panic = refactogentReplayRecovered(t, call)
+		line, err := json.Marshal(call)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := out.Write(append(line, '\\n')); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func refactogentReplayRecovered(t *testing.T, call refactogentReplayCall) (results []json.RawMessage, panicked string) {
+	defer func() {
+		if r := recover(); r != nil {
+			results, panicked = nil, fmt.Sprint(r)
+		}
+	}()
+	return refactogentReplay(t, call), ""
+}
+
+func refactogentDecode(t *testing.T, data json.RawMessage, v any) {
+	t.Helper()
+	if len(data) == 0 {
+		return
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func refactogentEncode(values ...any) []json.RawMessage {
+	encoded := []json.RawMessage{}
+	for _, v := range values {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = json.RawMessage("null")
+		}
+		encoded = append(encoded, data)
+	}
+	return encoded
+}
+
+func refactogentReplay(t *testing.T, call refactogentReplayCall) []json.RawMessage {
+	switch call.Function {
+${cases}
+	}
+	return nil
+}
+`;
+}
+
+/**
+ * Generate the Go test that replays recorded calls against the code in its
+ * package. Run it in the refactored tree with both environment variables set.
+ */
+export function generateSnapshotReplay(
+  file: GoFile,
+  functionNames: string[],
+  options: SnapshotOptions = {},
+): SnapshotReplay {
+  const warnings: SnapshotWarning[] = [];
+  const targets = selectTargets(file, functionNames, options, warnings);
+  return {
+    test: {
+      path: path.join(path.dirname(file.filePath), REPLAY_FILE),
+      content: replaySource(file.packageName.name, targets),
+    },
+    functions: targets.map((target) => target.name),
+    warnings,
+  };
+}
+
+/**
+ * Parse a JSON lines snapshot
+ */
+export function parseSnapshots(content: string): SnapshotEntry[] {
+  return content
+    .split("\n")
+    .filter((line) => line.trim() !== "")
+    .map((line) => JSON.parse(line));
+}
+
+function isRecord(value: unknown): value is Record<string, unknown> {
+  return typeof value === "object" && value !== null && !Array.isArray(value);
+}
+
+/**
+ * The first point where two JSON values differ, descending to the smallest
+ * differing element
+ */
+function firstDifference(
+  expected: unknown,
+  actual: unknown,
+  at: string,
+): { path: string; expected: unknown; actual: unknown } | undefined {
+  if (Array.isArray(expected) && Array.isArray(actual)) {
+    const length = Math.max(expected.length, actual.length);
+    for (let i = 0; i < length; i++) {
+      if (i >= expected.length || i >= actual.length) {
+        const element = `${at}[${i}]`;
+        return { path: element, expected: expected[i], actual: actual[i] };
+      }
+      const difference = firstDifference(expected[i], actual[i], `${at}[${i}]`);
+      if (difference) return difference;
+    }
+    return undefined;
+  }
+  if (isRecord(expected) && isRecord(actual)) {
+    const keys = [
+      ...new Set([...Object.keys(expected), ...Object.keys(actual)]),
+    ].sort();
+    for (const key of keys) {
+      const difference = firstDifference(
+        expected[key],
+        actual[key],
+        `${at}.${key}`,
+      );
+      if (difference) return difference;
+    }
+    return undefined;
+  }
+  return expected === actual ? undefined : { path: at, expected, actual };
+}
+
+/**
+ * Compare a recording with its replay, call by call
+ */
+export function diffSnapshots(
+  recorded: SnapshotEntry[],
+  replayed: SnapshotEntry[],
+): SnapshotMismatch[] {
+  const mismatches: SnapshotMismatch[] = [];
+  recorded.forEach((entry, index) => {
+    const replay = replayed[index];
+    const base = {
+      function: entry.function,
+      call: index + 1,
+      args: entry.args,
+    };
+    if (!replay || replay.function !== entry.function) {
+      mismatches.push({
+        ...base,
+        path: "results",
+        expected: entry.results,
+        actual: undefined,
+        reason: "the call was not replayed",
+      });
+      return;
+    }
+    if (replay.panic !== undefined || replay.results === null) {
+      mismatches.push({
+        ...base,
+        path: "results",
+        expected: entry.results,
+        actual: undefined,
+        reason: replay.panic
+          ? `panicked: ${replay.panic}`
+          : "the function is not part of the replay test",
+      });
+      return;
+    }
+    const difference = firstDifference(
+      entry.results,
+      replay.results,
+      "results",
+    );
+    if (difference) mismatches.push({ ...base, ...difference });
+  });
+  return mismatches;
+}
+
+/**
+ * Describe a mismatch on one line
+ */
+export function formatSnapshotMismatch(mismatch: SnapshotMismatch): string {
+  const call = `${mismatch.function}(${mismatch.args
+    .map((arg) => JSON.stringify(arg))
+    .join(", ")})`;
+  const where = `call ${mismatch.call}: ${call}`;
+  if (mismatch.reason) {
+    return `${where}: ${mismatch.reason}`;
+  }
+  const show = (value: unknown) =>
+    value === undefined ? "<missing>" : JSON.stringify(value);
+  return `${where}: ${mismatch.path} = ${show(mismatch.actual)}, want ${show(mismatch.expected)}`;
+}
+
+/**
+ * Read a recording and its replay from disk and compare them
+ */
+export async function diffSnapshotFiles(
+  recordedPath: string,
+  replayedPath: string,
+): Promise<SnapshotMismatch[]> {
+  const [recorded, replayed] = await Promise.all([
+    fs.promises.readFile(recordedPath, "utf-8"),
+    fs.promises.readFile(replayedPath, "utf-8"),
+  ]);
+  return diffSnapshots(parseSnapshots(recorded), parseSnapshots(replayed));
+}
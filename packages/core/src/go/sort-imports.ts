@@ -0,0 +1,260 @@
+import { TextEdit } from "../diff.js";
+import { CommentGroup, GenDecl, GoFile, ImportSpec } from "./ast.js";
+import {
+  defaultImportName,
+  firstImportEdit,
+  GoImportEntry,
+  importPath,
+} from "./imports.js";
+import { GoRefactorResult, refactorResult } from "./refactor.js";
+
+/**
+ * Sort Imports
+ * ============
+ * Normalizes the imports of a file the way goimports lays them out: a single
+ * parenthesized declaration with the standard library first and everything
+ * else after a blank line, each group sorted by path. Repeated imports of a
+ * path under the same name are dropped. Aliased, dot and blank imports keep
+ * their names and sort by path like the rest; comments travel with the
+ * import they annotate, and a comment standing on its own moves with the
+ * import below it. The cgo `import "C"` declaration is left where it is,
+ * since its preamble comment has to stay attached to it.
+ *
+ * Transforms that add imports can pass their entries to
+ * {@link sortedImportEdits} instead of `importEdits`, so the new imports land
+ * in a normalized block.
+ */
+
+export interface SortImportsResult extends GoRefactorResult {
+  /** Standard library import paths, in their new order */
+  standard: string[];
+  /** Other import paths, in their new order */
+  external: string[];
+  /** Paths of the repeated imports that were dropped */
+  duplicates: string[];
+}
+
+interface ImportLine extends GoImportEntry {
+  /** Comment lines above the import, as written */
+  comments: string[];
+  /** Comment after the import on the same line, as written */
+  trailing?: string;
+}
+
+/**
+ * Whether an import path belongs to the standard library, which goimports
+ * decides by the first path element having no dot
+ */
+export function isStandardImportPath(path: string): boolean {
+  return !path.split("/")[0].includes(".");
+}
+
+function compareLines(a: ImportLine, b: ImportLine): number {
+  if (a.path !== b.path) return a.path < b.path ? -1 : 1;
+  const x = a.name ?? "";
+  const y = b.name ?? "";
+  return x < y ? -1 : x > y ? 1 : 0;
+}
+
+function renderImports(
+  standard: ImportLine[],
+  external: ImportLine[],
+  grouped: boolean,
+): string {
+  const spec = (line: ImportLine) => {
+    const quoted = JSON.stringify(line.path);
+    const text = line.name ? `${line.name} ${quoted}` : quoted;
+    return line.trailing ? `${text} ${line.trailing}` : text;
+  };
+  const all = [...standard, ...external];
+  if (all.length === 1 && all[0].comments.length === 0 && !grouped) {
+    return `import ${spec(all[0])}`;
+  }
+  const group = (lines: ImportLine[]) =>
+    lines.flatMap((line) => [
+      ...line.comments.map((comment) => `\t${comment}`),
+      `\t${spec(line)}`,
+    ]);
+  const body = [group(standard), group(external)]
+    .filter((lines) => lines.length > 0)
+    .map((lines) => lines.join("\n"))
+    .join("\n\n");
+  return `import (\n${body}\n)`;
+}
+
+class ImportSorter {
+  private readonly file: GoFile;
+  private readonly decls: GenDecl[];
+  readonly duplicates: string[] = [];
+  standard: ImportLine[] = [];
+  external: ImportLine[] = [];
+
+  constructor(file: GoFile, entries: GoImportEntry[]) {
+    this.file = file;
+    this.decls = file.decls.filter(
+      (decl): decl is GenDecl =>
+        decl.kind === "GenDecl" &&
+        decl.tok === "import" &&
+        !decl.specs.some(
+          (spec) => spec.kind === "ImportSpec" && importPath(spec) === "C",
+        ),
+    );
+
+    const lines: ImportLine[] = [
+      ...this.decls.flatMap((decl) => this.declLines(decl)),
+      ...entries.map((entry) => ({ ...entry, comments: [] })),
+    ];
+    const seen = new Map<string, ImportLine>();
+    for (const line of lines) {
+      // `"fmt"` and `fmt "fmt"` bind the same name
+      const name =
+        line.name === defaultImportName(line.path) ? undefined : line.name;
+      const key = `${name ?? ""} ${line.path}`;
+      const first = seen.get(key);
+      if (first) {
+        this.duplicates.push(line.path);
+        first.comments.push(...line.comments);
+        first.trailing ??= line.trailing;
+        continue;
+      }
+      seen.set(key, line);
+      (isStandardImportPath(line.path) ? this.standard : this.external).push(
+        line,
+      );
+    }
+    this.standard.sort(compareLines);
+    this.external.sort(compareLines);
+  }
+
+  private text(group: CommentGroup): string[] {
+    return this.file.source
+      .slice(group.pos, group.end)
+      .split("\n")
+      .map((line) => line.trim());
+  }
+
+  // The specs of a declaration with their comments; comments standing on
+  // their own go with the spec below them
+  private declLines(decl: GenDecl): ImportLine[] {
+    const specs = decl.specs as ImportSpec[];
+    const attached = new Set(
+      specs.flatMap((spec) => [spec.doc, spec.comment]).filter(Boolean),
+    );
+    const floating =
+      decl.lparen < 0
+        ? []
+        : this.file.comments.filter(
+            (group) =>
+              group.pos > decl.lparen &&
+              group.end < decl.rparen &&
+              !attached.has(group),
+          );
+    const lines: ImportLine[] = specs.map((spec, index) => {
+      const comments = [
+        ...(index === 0 && this.decls[0] !== decl && decl.doc
+          ? this.text(decl.doc)
+          : []),
+        ...floating
+          .filter(
+            (group) =>
+              group.end <= (spec.doc?.pos ?? spec.pos) &&
+              (index === 0 || group.pos >= specs[index - 1].end),
+          )
+          .flatMap((group) => this.text(group)),
+        ...(spec.doc ? this.text(spec.doc) : []),
+      ];
+      return {
+        path: importPath(spec),
+        name: spec.name?.name,
+        comments,
+        trailing: spec.comment && this.text(spec.comment).join(" "),
+      };
+    });
+    // Comments after the last spec stay at the end of the block
+    const last = specs.at(-1);
+    const rest = floating.filter((group) => !last || group.pos >= last.end);
+    if (rest.length > 0 && lines.length > 0) {
+      lines.at(-1).comments.push(...rest.flatMap((group) => this.text(group)));
+    }
+    return lines;
+  }
+
+  edits(): TextEdit[] {
+    const { file } = this;
+    const all = [...this.standard, ...this.external];
+    if (all.length === 0) return [];
+    const [first, ...others] = this.decls;
+    const text = renderImports(
+      this.standard,
+      this.external,
+      this.decls[0]?.lparen >= 0 || this.decls.length > 1,
+    );
+    if (!first) return [firstImportEdit(file, text)];
+
+    const { source, sourceMap } = file;
+    const edits: TextEdit[] = [];
+    // A trailing comment on a lone import ends after the declaration
+    const end = Math.max(
+      first.end,
+      ...first.specs.map((spec) => (spec as ImportSpec).comment?.end ?? 0),
+    );
+    if (source.slice(first.pos, end) !== text) {
+      edits.push({ start: first.pos, end, newText: text });
+    }
+    for (const decl of others) {
+      const start = sourceMap.lineStart(
+        sourceMap.line(decl.doc?.pos ?? decl.pos),
+      );
+      let end = sourceMap.lineStart(sourceMap.line(decl.end) + 1);
+      if (source[end] === "\n") end++;
+      edits.push({ start, end, newText: "" });
+    }
+    return edits;
+  }
+}
+
+/**
+ * Edits rewriting the imports of a file as one grouped, sorted block, with
+ * the entries added. Nothing is returned when the block is already in order.
+ */
+export function sortedImportEdits(
+  file: GoFile,
+  entries: GoImportEntry[] = [],
+): TextEdit[] {
+  return new ImportSorter(file, entries).edits();
+}
+
+/**
+ * An import declaration for the entries, grouped and sorted, for a file that
+ * has no imports yet
+ */
+export function sortedImportDecl(entries: GoImportEntry[]): string {
+  const lines = entries
+    .filter(
+      (entry, index) =>
+        entries.findIndex(
+          (other) => other.path === entry.path && other.name === entry.name,
+        ) === index,
+    )
+    .map((entry) => ({ ...entry, comments: [] }))
+    .sort(compareLines);
+  return renderImports(
+    lines.filter((line) => isStandardImportPath(line.path)),
+    lines.filter((line) => !isStandardImportPath(line.path)),
+    false,
+  );
+}
+
+/**
+ * Group the imports of a file into standard library and other imports, sort
+ * each group and drop repeated imports
+ */
+export function sortGoImports(file: GoFile): SortImportsResult {
+  const sorter = new ImportSorter(file, []);
+  return {
+    ...refactorResult(file, sorter.edits()),
+    standard: sorter.standard.map((line) => line.path),
+    external: sorter.external.map((line) => line.path),
+    duplicates: sorter.duplicates,
+  };
+}
@@ -0,0 +1,407 @@
+import { TextEdit } from "../diff.js";
+import { FuncDecl, GoFile, IfStmt, Stmt, inspect } from "./ast.js";
+import { extractFunction } from "./extract-function.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { parseGoFile } from "./parser.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+import { goFunctionSize } from "./size.js";
+
+/**
+ * Function Splits
+ * ===============
+ * Suggests splitting functions that do several things in a row, like
+ * `ProcessComplexData`, which validates its input before dispatching each
+ * item. The top-level statements of a function are grouped into segments:
+ *
+ * - validation: the guard clauses it starts with, `if` statements that only
+ *   look at parameters and return or panic
+ * - loop: a loop at the top level, with the declarations right before it of
+ *   variables only the loop goes on to use
+ * - step: three or more statements between the others
+ *
+ * A trailing `return` stays in the function. Liveness decides whether a
+ * segment is worth extracting: it may read at most four variables declared
+ * before it and leave at most two for the statements after it, or the split
+ * would only move the coupling into a long parameter list. A function of a
+ * dozen lines of code or more with two such segments is reported.
+ *
+ * The suggestion is a preview, never applied automatically: each segment is
+ * extracted with the extract function refactor, from the last to the first
+ * so the lines of the others stay put, and the diff shows the result.
+ */
+
+export type GoSplitSegmentRole = "validation" | "loop" | "step";
+
+export interface GoSplitSegment {
+  role: GoSplitSegmentRole;
+  startLine: number;
+  endLine: number;
+  /** Name suggested for the extracted function */
+  name: string;
+  /** Variables declared before the segment that it reads */
+  inputs: string[];
+  /** Variables the segment sets that are read after it */
+  outputs: string[];
+}
+
+export interface GoFunctionSplitFinding extends GoFinding {
+  rule: "split-function";
+  /** `Func` or `Type.Method` */
+  function: string;
+  segments: GoSplitSegment[];
+}
+
+export interface PreviewFunctionSplitOptions {
+  /** Line of the function's declaration, as reported */
+  line: number;
+}
+
+export interface PreviewFunctionSplitResult extends GoRefactorResult {
+  /** Names of the functions the segments were extracted into */
+  functions: string[];
+}
+
+// Functions shorter than this do too little to split
+const MIN_CODE_LINES = 12;
+const MIN_STEP_STATEMENTS = 3;
+const MAX_INPUTS = 4;
+const MAX_OUTPUTS = 2;
+
+interface Candidate {
+  role: GoSplitSegmentRole;
+  stmts: Stmt[];
+}
+
+function upperFirst(name: string): string {
+  return name.charAt(0).toUpperCase() + name.slice(1);
+}
+
+function lowerFirst(name: string): string {
+  return name.charAt(0).toLowerCase() + name.slice(1);
+}
+
+function isTerminating(stmt: Stmt | undefined): boolean {
+  if (stmt?.kind === "ReturnStmt") return true;
+  return (
+    stmt?.kind === "ExprStmt" &&
+    stmt.x.kind === "CallExpr" &&
+    stmt.x.fun.kind === "Ident" &&
+    stmt.x.fun.name === "panic"
+  );
+}
+
+class FunctionSplitAnalyzer {
+  private readonly file: GoFile;
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  // A guard clause looking at nothing but parameters and package names
+  private isValidation(stmt: Stmt, scopes: GoFunctionScopes): boolean {
+    if (stmt.kind !== "IfStmt") return false;
+    const guard: IfStmt = stmt;
+    if (guard.init || guard.else || !isTerminating(guard.body.list.at(-1))) {
+      return false;
+    }
+    return !scopes.references.some(
+      (reference) =>
+        reference.ident.pos >= guard.pos &&
+        reference.ident.end <= guard.end &&
+        reference.variable.kind === "local",
+    );
+  }
+
+  private variablesIn(scopes: GoFunctionScopes, stmts: Stmt[]) {
+    const start = stmts[0].pos;
+    const end = stmts.at(-1).end;
+    const inputs = new Set<GoVariable>();
+    const outputs = new Set<GoVariable>();
+    const set = new Set<GoVariable>();
+    for (const variable of scopes.variables) {
+      const at = variable.ident.pos;
+      if (variable.kind === "local" && at >= start && at < end) {
+        set.add(variable);
+      }
+    }
+    for (const { ident, variable, write } of scopes.references) {
+      if (ident.pos >= start && ident.end <= end) {
+        if (variable.ident.pos < start) inputs.add(variable);
+        if (write) set.add(variable);
+      }
+    }
+    for (const { ident, variable } of scopes.references) {
+      if (ident.pos >= end && set.has(variable)) outputs.add(variable);
+    }
+    const names = (variables: Set<GoVariable>) =>
+      [...variables].map((variable) => variable.name);
+    return { inputs: names(inputs), outputs: names(outputs) };
+  }
+
+  // Declarations right before a loop of variables only the loop goes on to use
+  private loopSetup(
+    list: Stmt[],
+    loop: number,
+    from: number,
+    scopes: GoFunctionScopes,
+  ): number {
+    let start = loop;
+    while (start > from) {
+      const stmt = list[start - 1];
+      const declares =
+        (stmt.kind === "AssignStmt" && stmt.tok === ":=") ||
+        (stmt.kind === "DeclStmt" && stmt.decl.tok === "var");
+      if (!declares) break;
+      const declared = scopes.variables.filter(
+        (variable) =>
+          variable.ident.pos >= stmt.pos && variable.ident.end <= stmt.end,
+      );
+      const usedByLoop = declared.every((variable) =>
+        scopes.references.some(
+          (reference) =>
+            reference.variable === variable &&
+            reference.ident.pos >= list[loop].pos &&
+            reference.ident.end <= list[loop].end,
+        ),
+      );
+      const usedBetween = declared.some((variable) =>
+        scopes.references.some(
+          (reference) =>
+            reference.variable === variable &&
+            reference.ident.pos >= stmt.end &&
+            reference.ident.pos < list[loop].pos,
+        ),
+      );
+      if (declared.length === 0 || !usedByLoop || usedBetween) break;
+      start--;
+    }
+    return start;
+  }
+
+  private candidates(decl: FuncDecl, scopes: GoFunctionScopes): Candidate[] {
+    const list = decl.body.list;
+    const end =
+      list.at(-1)?.kind === "ReturnStmt" ? list.length - 1 : list.length;
+    const candidates: Candidate[] = [];
+    let index = 0;
+    while (index < end && this.isValidation(list[index], scopes)) index++;
+    if (index > 0) {
+      candidates.push({ role: "validation", stmts: list.slice(0, index) });
+    }
+
+    let gap = index;
+    const step = (until: number) => {
+      if (until - gap >= MIN_STEP_STATEMENTS) {
+        candidates.push({ role: "step", stmts: list.slice(gap, until) });
+      }
+    };
+    for (let i = index; i < end; i++) {
+      const stmt = list[i];
+      if (stmt.kind !== "ForStmt" && stmt.kind !== "RangeStmt") continue;
+      const start = this.loopSetup(list, i, gap, scopes);
+      step(start);
+      candidates.push({ role: "loop", stmts: list.slice(start, i + 1) });
+      gap = i + 1;
+    }
+    step(end);
+    return candidates;
+  }
+
+  private segmentName(
+    decl: FuncDecl,
+    candidate: Candidate,
+    step: number,
+    taken: Set<string>,
+  ): string {
+    const name = decl.name.name;
+    let base: string;
+    if (candidate.role === "validation") {
+      base = `validate${upperFirst(name)}`;
+    } else if (candidate.role === "loop") {
+      const loop = candidate.stmts.at(-1);
+      base =
+        loop.kind === "RangeStmt" && loop.x.kind === "Ident"
+          ? `process${upperFirst(loop.x.name)}`
+          : `${lowerFirst(name)}Loop`;
+    } else {
+      base = `${lowerFirst(name)}Step${step}`;
+    }
+    let unique = base;
+    for (let i = 2; taken.has(unique); i++) unique = `${base}${i}`;
+    taken.add(unique);
+    return unique;
+  }
+
+  private declaredNames(): Set<string> {
+    const names = new Set<string>();
+    for (const decl of this.file.decls) {
+      if (decl.kind === "FuncDecl") {
+        if (!decl.recv) names.add(decl.name.name);
+        continue;
+      }
+      for (const spec of decl.specs) {
+        if (spec.kind === "TypeSpec") names.add(spec.name.name);
+        if (spec.kind === "ValueSpec") {
+          spec.names.forEach((ident) => names.add(ident.name));
+        }
+      }
+    }
+    return names;
+  }
+
+  private qualifiedName(decl: FuncDecl): string {
+    const recv = decl.recv?.list[0]?.type;
+    if (!recv) return decl.name.name;
+    let base = recv.kind === "StarExpr" ? recv.x : recv;
+    if (base.kind === "IndexExpr" || base.kind === "IndexListExpr") {
+      base = base.x;
+    }
+    return base.kind === "Ident"
+      ? `${base.name}.${decl.name.name}`
+      : decl.name.name;
+  }
+
+  // The segments of a function worth extracting, when there are two or more
+  private segments(decl: FuncDecl): GoSplitSegment[] {
+    if (!decl.body || decl.type.typeParams) return [];
+    if (goFunctionSize(this.file, decl).codeLines < MIN_CODE_LINES) return [];
+    let goto = false;
+    inspect(decl.body, (node) => {
+      goto ||= node.kind === "BranchStmt" && node.tok === "goto";
+    });
+    if (goto) return [];
+    const scopes = resolveFunctionScopes(decl);
+    const taken = this.declaredNames();
+    const segments: GoSplitSegment[] = [];
+    let steps = 0;
+    for (const candidate of this.candidates(decl, scopes)) {
+      const { inputs, outputs } = this.variablesIn(scopes, candidate.stmts);
+      if (inputs.length > MAX_INPUTS || outputs.length > MAX_OUTPUTS) continue;
+      const startLine = this.line(candidate.stmts[0].pos);
+      const endLine = this.line(candidate.stmts.at(-1).end);
+      if (candidate.role === "step") steps++;
+      const name = this.segmentName(decl, candidate, steps, taken);
+      try {
+        extractFunction(this.file, { startLine, endLine, name });
+      } catch (error) {
+        if (error instanceof GoRefactorError) continue;
+        throw error;
+      }
+      segments.push({
+        role: candidate.role,
+        startLine,
+        endLine,
+        name,
+        inputs,
+        outputs,
+      });
+    }
+    return segments.length >= 2 ? segments : [];
+  }
+
+  private splits(): { decl: FuncDecl; segments: GoSplitSegment[] }[] {
+    return this.file.decls
+      .filter((decl): decl is FuncDecl => decl.kind === "FuncDecl")
+      .map((decl) => ({ decl, segments: this.segments(decl) }))
+      .filter(({ segments }) => segments.length > 0);
+  }
+
+  findings(): GoFunctionSplitFinding[] {
+    return this.splits().map(({ decl, segments }) => {
+      const name = this.qualifiedName(decl);
+      const parts = segments
+        .map(
+          (segment) =>
+            `${segment.role} (lines ${segment.startLine}-${segment.endLine}) into ${segment.name}`,
+        )
+        .join(", ");
+      return {
+        rule: "split-function",
+        severity: "low",
+        filePath: this.file.filePath,
+        ...this.file.sourceMap.position(decl.name.pos),
+        message: `${name} does ${segments.length} things in a row; consider extracting ${parts}`,
+        function: name,
+        segments,
+      };
+    });
+  }
+
+  preview(options: PreviewFunctionSplitOptions): PreviewFunctionSplitResult {
+    const split = this.splits().find(
+      ({ decl }) => this.line(decl.name.pos) === options.line,
+    );
+    if (!split) {
+      throw new GoRefactorError(
+        `No function declared on line ${options.line} has a split to suggest`,
+      );
+    }
+    let current = this.file;
+    for (const segment of [...split.segments].reverse()) {
+      const { source } = extractFunction(current, segment);
+      current = parseGoFile(source, this.file.filePath);
+    }
+
+    // One edit spanning what changed between the first and last difference
+    const before = this.file.source;
+    const after = current.source;
+    let prefix = 0;
+    while (prefix < before.length && before[prefix] === after[prefix]) {
+      prefix++;
+    }
+    let suffix = 0;
+    while (
+      suffix < before.length - prefix &&
+      suffix < after.length - prefix &&
+      before[before.length - 1 - suffix] === after[after.length - 1 - suffix]
+    ) {
+      suffix++;
+    }
+    const edits: TextEdit[] = [
+      {
+        start: prefix,
+        end: before.length - suffix,
+        newText: after.slice(prefix, after.length - suffix),
+      },
+    ];
+    return {
+      ...refactorResult(this.file, edits),
+      functions: split.segments.map((segment) => segment.name),
+    };
+  }
+}
+
+/**
+ * Find functions doing several things in a row that could be split
+ */
+export function findFunctionSplits(files: GoFile[]): GoFunctionSplitFinding[] {
+  const findings: GoFunctionSplitFinding[] = [];
+  for (const file of files) {
+    findings.push(...new FunctionSplitAnalyzer(file).findings());
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Preview the split suggested for the function declared on a line: each
+ * segment extracted into its own function, as a diff that is not applied
+ */
+export function previewFunctionSplit(
+  file: GoFile,
+  options: PreviewFunctionSplitOptions,
+): PreviewFunctionSplitResult {
+  return new FunctionSplitAnalyzer(file).preview(options);
+}
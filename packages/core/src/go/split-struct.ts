@@ -0,0 +1,215 @@
+import { FuncDecl, GoFile, StructType, TypeSpec, inspect } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { groupGoPackages } from "./package.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Split Large Structs
+ * ===================
+ * A struct whose methods fall into groups that never touch each other's
+ * fields is several types sharing one declaration. This pass clusters the
+ * fields of each struct by the methods that access them through the
+ * receiver: two fields are in one cluster when a method uses both, and a
+ * method calling another method of the type joins its fields too, since it
+ * depends on them. When two or more clusters are big enough, the finding
+ * proposes the partition and which methods move with each part.
+ *
+ * Fields no method reads and methods that use no fields belong to no cluster
+ * and are listed apart; they can go with whichever part suits. Accesses
+ * through anything besides the receiver, such as a second value of the type
+ * or a field promoted from an embedded struct, are not seen.
+ */
+
+/** Structs with fewer fields are not considered */
+export const DEFAULT_SPLIT_STRUCT_MIN_FIELDS = 6;
+
+/** Fields a cluster needs to count towards a split */
+export const DEFAULT_SPLIT_STRUCT_MIN_CLUSTER_FIELDS = 2;
+
+export interface SplitStructOptions {
+  /** Fewest fields a struct needs (default: 6) */
+  minFields?: number;
+  /** Fewest fields of each of at least two clusters (default: 2) */
+  minClusterFields?: number;
+}
+
+export interface GoFieldCluster {
+  /** Field names, in declaration order */
+  fields: string[];
+  /** Methods using the fields, in source order */
+  methods: string[];
+}
+
+export interface GoStructSplitFinding extends GoFinding {
+  rule: "split-struct";
+  type: string;
+  /** The proposed parts, largest first */
+  clusters: GoFieldCluster[];
+  /** Fields no method uses */
+  unusedFields: string[];
+  /** Methods using no fields, directly or through other methods */
+  fieldlessMethods: string[];
+}
+
+interface Struct {
+  file: GoFile;
+  spec: TypeSpec;
+  /** Field names; embedded fields are named by their type */
+  fields: string[];
+  methods: FuncDecl[];
+}
+
+// Union-find over field and method names, methods prefixed with "()"
+class Clusters {
+  private readonly parent = new Map<string, string>();
+
+  find(key: string): string {
+    if (!this.parent.has(key)) this.parent.set(key, key);
+    const parent = this.parent.get(key);
+    if (parent === key) return key;
+    const root = this.find(parent);
+    this.parent.set(key, root);
+    return root;
+  }
+
+  union(a: string, b: string): void {
+    this.parent.set(this.find(a), this.find(b));
+  }
+}
+
+class StructSplitter {
+  private readonly options: Required<SplitStructOptions>;
+
+  constructor(options: SplitStructOptions) {
+    this.options = {
+      minFields: options.minFields ?? DEFAULT_SPLIT_STRUCT_MIN_FIELDS,
+      minClusterFields:
+        options.minClusterFields ?? DEFAULT_SPLIT_STRUCT_MIN_CLUSTER_FIELDS,
+    };
+  }
+
+  private structs(group: GoFile[]): Struct[] {
+    const structs = new Map<string, Struct>();
+    for (const file of group) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "GenDecl" || decl.tok !== "type") continue;
+        for (const spec of decl.specs) {
+          if (spec.kind !== "TypeSpec" || spec.type.kind !== "StructType") {
+            continue;
+          }
+          const fields = (spec.type as StructType).fields.list.flatMap(
+            (field) =>
+              field.names.length > 0
+                ? field.names.map((name) => name.name)
+                : [baseTypeName(field.type).name],
+          );
+          structs.set(spec.name.name, { file, spec, fields, methods: [] });
+        }
+      }
+    }
+    for (const file of group) {
+      for (const decl of file.decls) {
+        const field = decl.kind === "FuncDecl" ? decl.recv?.list[0] : undefined;
+        if (!field) continue;
+        structs.get(baseTypeName(field.type).name)?.methods.push(decl);
+      }
+    }
+    return [...structs.values()];
+  }
+
+  // Fields and methods of the type a method uses through its receiver
+  private uses(
+    decl: FuncDecl,
+    fields: Set<string>,
+    methods: Set<string>,
+  ): string[] {
+    const receiver = decl.recv.list[0].names[0];
+    if (!receiver || receiver.name === "_" || !decl.body) return [];
+    const scopes = resolveFunctionScopes(decl);
+    const used = new Set<string>();
+    inspect(decl.body, (node) => {
+      if (node.kind !== "SelectorExpr" || node.x.kind !== "Ident") return;
+      if (scopes.resolved.get(node.x)?.kind !== "receiver") return;
+      const name = node.sel.name;
+      if (methods.has(name)) used.add(`()${name}`);
+      else if (fields.has(name)) used.add(name);
+    });
+    return [...used];
+  }
+
+  private split(struct: Struct): GoStructSplitFinding | undefined {
+    if (struct.fields.length < this.options.minFields) return undefined;
+    const fields = new Set(struct.fields);
+    const methods = new Set(struct.methods.map((decl) => decl.name.name));
+    const clusters = new Clusters();
+    for (const decl of struct.methods) {
+      for (const used of this.uses(decl, fields, methods)) {
+        clusters.union(`()${decl.name.name}`, used);
+      }
+    }
+
+    const parts = new Map<string, GoFieldCluster>();
+    const part = (key: string) => {
+      const root = clusters.find(key);
+      if (!parts.has(root)) parts.set(root, { fields: [], methods: [] });
+      return parts.get(root);
+    };
+    for (const field of struct.fields) part(field).fields.push(field);
+    for (const decl of struct.methods) {
+      part(`()${decl.name.name}`).methods.push(decl.name.name);
+    }
+    const all = [...parts.values()];
+    const proposed = all
+      .filter(
+        (cluster) => cluster.fields.length > 0 && cluster.methods.length > 0,
+      )
+      .sort((a, b) => b.fields.length - a.fields.length);
+    const large = proposed.filter(
+      (cluster) => cluster.fields.length >= this.options.minClusterFields,
+    );
+    if (large.length < 2) return undefined;
+
+    const type = struct.spec.name.name;
+    const unusedFields = all
+      .filter((cluster) => cluster.methods.length === 0)
+      .flatMap((cluster) => cluster.fields);
+    const fieldlessMethods = all
+      .filter((cluster) => cluster.fields.length === 0)
+      .flatMap((cluster) => cluster.methods);
+    const described = proposed
+      .map(
+        (cluster) =>
+          `{${cluster.fields.join(", ")}} used by ${cluster.methods.join(", ")}`,
+      )
+      .join("; ");
+    return {
+      rule: "split-struct",
+      severity: "low",
+      filePath: struct.file.filePath,
+      ...struct.file.sourceMap.position(struct.spec.name.pos),
+      message: `${type} has ${proposed.length} disjoint field clusters; consider splitting it: ${described}`,
+      type,
+      clusters: proposed,
+      unusedFields,
+      fieldlessMethods,
+    };
+  }
+
+  analyze(files: GoFile[]): GoStructSplitFinding[] {
+    return groupGoPackages(files).flatMap((group) =>
+      this.structs(group).flatMap((struct) => this.split(struct) ?? []),
+    );
+  }
+}
+
+/**
+ * Find structs whose fields fall into disjoint clusters by the methods using
+ * them, with the proposed partition
+ */
+export function findStructSplits(
+  files: GoFile[],
+  options: SplitStructOptions = {},
+): GoStructSplitFinding[] {
+  return sortFindings(new StructSplitter(options).analyze(files));
+}
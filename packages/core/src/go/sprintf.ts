@@ -0,0 +1,368 @@
+import { TextEdit } from "../diff.js";
+import {
+  BasicLit,
+  CallExpr,
+  GenDecl,
+  GoFile,
+  ImportSpec,
+  Node,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importEdits, importName, importPath } from "./imports.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+
+/**
+ * Sprintf Conversions
+ * ===================
+ * `fmt.Sprintf` parses its format string and boxes its arguments on every
+ * call, which is wasted work when the format holds a single verb applied to
+ * a single value of a basic type. This pass flags such calls and rewrites
+ * them to the equivalent `strconv` call, or to the string itself for a
+ * string argument, with any literal text around the verb concatenated:
+ *
+ *     fmt.Sprintf("id-%d", n)   ->  "id-" + strconv.Itoa(n)
+ *     fmt.Sprintf("%s", name)   ->  name
+ *     fmt.Sprintf("%v", ok)     ->  strconv.FormatBool(ok)
+ *
+ * Only `%d`, `%s`, `%v` and `%t` without flags, width or precision are
+ * considered, and only for arguments whose type is known to be `string`,
+ * `bool` or a built-in integer or float type: a named type might have a
+ * `String` method `fmt` would call. The rewrite adds the `strconv` import
+ * when needed and drops `fmt` when no other use of it is left.
+ */
+
+export interface GoSprintfFinding extends GoFinding {
+  rule: "unnecessary-sprintf";
+}
+
+export interface UseStrconvOptions {
+  /**
+   * Line of a call reported by {@link findUnnecessarySprintf} (default:
+   * every one in the file)
+   */
+  line?: number;
+}
+
+export interface UseStrconvResult extends GoRefactorResult {
+  /** Positions of the rewritten calls */
+  rewritten: { line: number; column: number }[];
+  /** Import paths added to and removed from the file */
+  importsAdded: string[];
+  importsRemoved: string[];
+}
+
+const SIGNED = new Set(["int8", "int16", "int32", "rune"]);
+const UNSIGNED = new Set(["uint", "uint8", "uint16", "uint32", "byte"]);
+const FLOATS = new Set(["float32", "float64"]);
+
+// The verbs each kind of argument may be formatted with
+const VERBS: Record<string, string> = {
+  string: "sv",
+  bool: "tv",
+  integer: "dv",
+  float: "v",
+};
+
+interface Conversion {
+  call: CallExpr;
+  /** Text replacing the call */
+  replacement?: string;
+  /** Whether the replacement calls into strconv */
+  strconv: boolean;
+  /** Why it cannot be rewritten */
+  unfixable?: string;
+}
+
+/**
+ * The single verb of a format string and the literal text around it, with
+ * `%%` unescaped, or undefined when the format is not that simple
+ */
+function singleVerb(
+  literal: BasicLit,
+): { verb: string; prefix: string; suffix: string } | undefined {
+  if (literal.litKind !== "string") return undefined;
+  const text = literal.value.slice(1, -1);
+  let verb: string | undefined;
+  let at = -1;
+  for (let i = 0; i < text.length; i++) {
+    if (text[i] !== "%") continue;
+    if (text[i + 1] === "%") {
+      i++;
+      continue;
+    }
+    if (verb !== undefined || !/[dstv]/.test(text[i + 1] ?? "")) {
+      return undefined;
+    }
+    [verb, at] = [text[i + 1], i];
+    i++;
+  }
+  if (verb === undefined) return undefined;
+  const unescape = (part: string) => part.replace(/%%/g, "%");
+  return {
+    verb,
+    prefix: unescape(text.slice(0, at)),
+    suffix: unescape(text.slice(at + 2)),
+  };
+}
+
+/**
+ * The strconv call formatting a bool or number of a basic type, with `pkg`
+ * naming strconv; `x` is the value as written
+ */
+function strconvCall(type: string, x: string, pkg: string): string {
+  if (type === "bool") return `${pkg}.FormatBool(${x})`;
+  if (type === "int") return `${pkg}.Itoa(${x})`;
+  if (type === "int64") return `${pkg}.FormatInt(${x}, 10)`;
+  if (SIGNED.has(type)) return `${pkg}.FormatInt(int64(${x}), 10)`;
+  if (type === "uint64") return `${pkg}.FormatUint(${x}, 10)`;
+  if (UNSIGNED.has(type) || type === "uintptr") {
+    return `${pkg}.FormatUint(uint64(${x}), 10)`;
+  }
+  if (type === "float64") return `${pkg}.FormatFloat(${x}, 'g', -1, 64)`;
+  return `${pkg}.FormatFloat(float64(${x}), 'g', -1, 32)`;
+}
+
+const INTEGERS = new Set([...SIGNED, ...UNSIGNED, "int", "int64", "uint64"]);
+
+function typeClass(type: string | undefined): string | undefined {
+  if (type === "string" || type === "bool") return type;
+  if (INTEGERS.has(type) || type === "uintptr") return "integer";
+  return FLOATS.has(type) ? "float" : undefined;
+}
+
+class SprintfAnalyzer {
+  private readonly file: GoFile;
+  private readonly fmt?: ImportSpec;
+  private readonly strconv?: ImportSpec;
+  private readonly parents = new Map<Node, Node>();
+  private scopes: GoFunctionScopes;
+  private types: GoTypeInference;
+
+  constructor(file: GoFile) {
+    this.file = file;
+    this.fmt = file.imports.find((spec) => importPath(spec) === "fmt");
+    this.strconv = file.imports.find((spec) => importPath(spec) === "strconv");
+    inspect(file, (node, parents) => {
+      if (parents.length > 0) this.parents.set(node, parents.at(-1));
+    });
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  // Whether a call is fmt.Sprintf through the file's fmt import
+  private isSprintf(call: CallExpr): boolean {
+    const { fun } = call;
+    return (
+      this.fmt !== undefined &&
+      fun.kind === "SelectorExpr" &&
+      fun.sel.name === "Sprintf" &&
+      fun.x.kind === "Ident" &&
+      fun.x.name === importName(this.fmt) &&
+      !this.scopes.resolved.has(fun.x)
+    );
+  }
+
+  // How strconv is named here, or why it cannot be
+  private strconvPackage(): { name?: string; unfixable?: string } {
+    const name = this.strconv ? importName(this.strconv) : "strconv";
+    if (name === "_" || name === ".") {
+      return { unfixable: `strconv is imported as ${name}` };
+    }
+    if (this.scopes.variables.some((variable) => variable.name === name)) {
+      return { unfixable: `a local variable hides the ${name} package` };
+    }
+    return { name };
+  }
+
+  private conversion(call: CallExpr): Conversion | undefined {
+    if (!this.isSprintf(call) || call.args.length !== 2) return undefined;
+    if (call.ellipsis >= 0) return undefined;
+    const [format, arg] = call.args;
+    if (format.kind !== "BasicLit") return undefined;
+    const parsed = singleVerb(format);
+    if (!parsed) return undefined;
+    const type = this.types.typeOf(arg);
+    const kind = typeClass(type);
+    if (!kind || !VERBS[kind].includes(parsed.verb)) return undefined;
+
+    let value = this.text(arg);
+    if (kind !== "string") {
+      const { name, unfixable } = this.strconvPackage();
+      if (unfixable) return { call, strconv: true, unfixable };
+      value = strconvCall(type, value, name);
+    }
+    const quote = format.value[0];
+    const parts = [
+      ...(parsed.prefix ? [`${quote}${parsed.prefix}${quote}`] : []),
+      value,
+      ...(parsed.suffix ? [`${quote}${parsed.suffix}${quote}`] : []),
+    ];
+    let replacement = parts.join(" + ");
+    // Indexing or slicing the result binds tighter than the concatenation
+    const parent = this.parents.get(call);
+    if (
+      (parts.length > 1 || arg.kind === "BinaryExpr") &&
+      (parent?.kind === "IndexExpr" || parent?.kind === "SliceExpr") &&
+      parent.x === call
+    ) {
+      replacement = `(${replacement})`;
+    }
+    return { call, replacement, strconv: kind !== "string" };
+  }
+
+  analyze(): Conversion[] {
+    const conversions: Conversion[] = [];
+    if (!this.fmt) return conversions;
+    for (const decl of this.file.decls) {
+      if (decl.kind !== "FuncDecl" || !decl.body) continue;
+      this.scopes = resolveFunctionScopes(decl);
+      this.types = new GoTypeInference(this.file, this.scopes);
+      inspect(decl.body, (node) => {
+        if (node.kind !== "CallExpr") return;
+        const conversion = this.conversion(node);
+        if (conversion) conversions.push(conversion);
+      });
+    }
+    return conversions;
+  }
+
+  findings(): GoSprintfFinding[] {
+    return this.analyze().map((conversion) => ({
+      rule: "unnecessary-sprintf",
+      severity: "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(conversion.call.pos),
+      message: conversion.replacement
+        ? `${this.text(conversion.call)} formats one value; use ${conversion.replacement}`
+        : `${this.text(conversion.call)} formats one value, but strconv cannot be used: ${conversion.unfixable}`,
+      ...(conversion.replacement && { fix: conversion.replacement }),
+    }));
+  }
+
+  // Uses of the fmt import outside the rewritten calls
+  private otherFmtUses(rewritten: Conversion[]): number {
+    const name = importName(this.fmt);
+    const calls = new Set<Node>(rewritten.map((match) => match.call.fun));
+    let uses = 0;
+    for (const decl of this.file.decls) {
+      if (decl.kind === "GenDecl" && decl.tok === "import") continue;
+      const resolved =
+        decl.kind === "FuncDecl"
+          ? resolveFunctionScopes(decl).resolved
+          : undefined;
+      inspect(decl, (node) => {
+        if (
+          node.kind === "SelectorExpr" &&
+          node.x.kind === "Ident" &&
+          node.x.name === name &&
+          !resolved?.has(node.x) &&
+          !calls.has(node)
+        ) {
+          uses++;
+        }
+      });
+    }
+    return uses;
+  }
+
+  // Edits adding strconv and dropping fmt, as the rewrite needs
+  private importChanges(addStrconv: boolean, removeFmt: boolean): TextEdit[] {
+    const { source, sourceMap } = this.file;
+    if (!removeFmt) {
+      return addStrconv ? importEdits(this.file, [{ path: "strconv" }]) : [];
+    }
+    const spec = this.fmt;
+    const decl = this.file.decls.find(
+      (candidate): candidate is GenDecl =>
+        candidate.kind === "GenDecl" && candidate.specs.includes(spec),
+    );
+    const specs = decl.specs as ImportSpec[];
+    const index = specs.indexOf(spec);
+    // Where the order allows, strconv takes the place of fmt
+    const sorted =
+      (index === 0 || importPath(specs[index - 1]) < "strconv") &&
+      (index === specs.length - 1 || importPath(specs[index + 1]) > "strconv");
+    if (addStrconv && sorted) {
+      return [{ start: spec.pos, end: spec.end, newText: '"strconv"' }];
+    }
+    const lone = specs.length === 1;
+    const [from, to] = lone
+      ? [decl.doc?.pos ?? decl.pos, decl.end]
+      : [spec.doc?.pos ?? spec.pos, spec.comment?.end ?? spec.end];
+    const start = sourceMap.lineStart(sourceMap.line(from));
+    let end = sourceMap.lineStart(sourceMap.line(to) + 1);
+    // A lone declaration takes the blank line after it along
+    if (lone && source[end] === "\n") end++;
+    return [
+      { start, end, newText: "" },
+      ...(addStrconv ? importEdits(this.file, [{ path: "strconv" }]) : []),
+    ];
+  }
+
+  rewrite(options: UseStrconvOptions): UseStrconvResult {
+    const { sourceMap } = this.file;
+    const matches = this.analyze().filter(
+      (match) =>
+        options.line === undefined ||
+        sourceMap.line(match.call.pos) === options.line,
+    );
+    if (options.line !== undefined) {
+      if (matches.length === 0) {
+        throw new GoRefactorError(
+          `Line ${options.line} has no fmt.Sprintf call formatting one value`,
+        );
+      }
+      const [unfixable] = matches.filter((match) => !match.replacement);
+      if (unfixable) {
+        throw new GoRefactorError(`Cannot use strconv: ${unfixable.unfixable}`);
+      }
+    }
+    const rewritten = matches.filter((match) => match.replacement);
+    const edits: TextEdit[] = rewritten.map((match) => ({
+      start: match.call.pos,
+      end: match.call.end,
+      newText: match.replacement,
+    }));
+    const addStrconv =
+      !this.strconv && rewritten.some((match) => match.strconv);
+    const removeFmt =
+      rewritten.length > 0 && this.otherFmtUses(rewritten) === 0;
+    edits.push(...this.importChanges(addStrconv, removeFmt));
+    return {
+      ...refactorResult(this.file, edits),
+      rewritten: rewritten.map((match) => sourceMap.position(match.call.pos)),
+      importsAdded: addStrconv ? ["strconv"] : [],
+      importsRemoved: removeFmt ? ["fmt"] : [],
+    };
+  }
+}
+
+/**
+ * Find `fmt.Sprintf` calls formatting a single value that `strconv`, or
+ * plain concatenation, could format instead
+ */
+export function findUnnecessarySprintf(files: GoFile[]): GoSprintfFinding[] {
+  return sortFindings(
+    files.flatMap((file) => new SprintfAnalyzer(file).findings()),
+  );
+}
+
+/**
+ * Rewrite `fmt.Sprintf` calls formatting a single value to `strconv` calls
+ * or concatenation, managing the `strconv` and `fmt` imports
+ */
+export function useStrconv(
+  file: GoFile,
+  options: UseStrconvOptions = {},
+): UseStrconvResult {
+  return new SprintfAnalyzer(file).rewrite(options);
+}
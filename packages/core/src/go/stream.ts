@@ -0,0 +1,138 @@
+import { IncrementalGoAnalyzer } from "./cache.js";
+import { GoProfiler } from "./profile.js";
+import { GoFileSymbols } from "./symbols.js";
+
+/**
+ * Streaming Go Analysis
+ * =====================
+ * Analyzes files with a bounded pool of workers and yields each file's
+ * symbols as soon as they are ready, so a consumer never needs the symbol
+ * table of a whole repository in memory. Workers pause while results the
+ * consumer has not taken yet fill the pool. Results arrive in completion order
+ * and carry the file's position in the input for consumers that need to
+ * restore it.
+ *
+ * A file that cannot be read or parsed yields an error result and the stream
+ * carries on. Aborting the signal stops the workers from taking new files and
+ * makes the stream throw the abort reason; breaking out of the loop stops
+ * them as well.
+ */
+
+export const DEFAULT_STREAM_CONCURRENCY = 8;
+
+export interface GoStreamOptions {
+  /** Files analyzed at the same time (default: 8) */
+  concurrency?: number;
+  signal?: AbortSignal;
+  /** Analyzer to use, for example one backed by a disk cache */
+  analyzer?: IncrementalGoAnalyzer;
+  /** Records the time of each file's analysis and the cache hits */
+  profiler?: GoProfiler;
+}
+
+export type GoStreamResult =
+  | {
+      ok: true;
+      filePath: string;
+      /** Position of the file in the input */
+      index: number;
+      symbols: GoFileSymbols;
+      hash: string;
+      cached: boolean;
+    }
+  | {
+      ok: false;
+      filePath: string;
+      index: number;
+      error: string;
+    };
+
+/**
+ * Analyze files concurrently, yielding one result per file as it completes
+ */
+export async function* analyzeGoStream(
+  filePaths: Iterable<string> | AsyncIterable<string>,
+  options: GoStreamOptions = {},
+): AsyncGenerator<GoStreamResult, void, undefined> {
+  const { signal, profiler } = options;
+  const analyzer = options.analyzer ?? new IncrementalGoAnalyzer();
+  const concurrency = Math.max(
+    1,
+    Math.floor(options.concurrency ?? DEFAULT_STREAM_CONCURRENCY),
+  );
+  const iterator =
+    Symbol.asyncIterator in filePaths
+      ? filePaths[Symbol.asyncIterator]()
+      : filePaths[Symbol.iterator]();
+
+  const ready: GoStreamResult[] = [];
+  let next = 0;
+  let active = concurrency;
+  let stopped = false;
+  let failure: unknown;
+  let wake: (() => void) | undefined;
+  const notify = () => {
+    wake?.();
+    wake = undefined;
+  };
+  // Workers waiting for the consumer to take results
+  const blocked: (() => void)[] = [];
+  const release = () => blocked.splice(0).forEach((resume) => resume());
+
+  const worker = async () => {
+    try {
+      while (!stopped && !signal?.aborted) {
+        if (ready.length >= concurrency) {
+          await new Promise<void>((resume) => blocked.push(resume));
+          continue;
+        }
+        const item = await iterator.next();
+        if (item.done) break;
+        const filePath = item.value;
+        const index = next++;
+        try {
+          const analyze = () => analyzer.analyzeFile(filePath);
+          const result = profiler
+            ? await profiler.measureAsync("analyze", analyze, filePath)
+            : await analyze();
+          profiler?.count(result.cached ? "cache hits" : "cache misses");
+          ready.push({ ok: true, filePath, index, ...result });
+        } catch (error) {
+          ready.push({ ok: false, filePath, index, error: error.message });
+        }
+        notify();
+      }
+    } catch (error) {
+      // The input itself failed; no more files can be read from it
+      failure ??= error;
+    } finally {
+      active--;
+      notify();
+    }
+  };
+
+  const abort = () => {
+    notify();
+    release();
+  };
+  signal?.addEventListener("abort", abort);
+  try {
+    for (let i = 0; i < concurrency; i++) void worker();
+    for (;;) {
+      signal?.throwIfAborted();
+      if (ready.length > 0) {
+        const result = ready.shift();
+        blocked.shift()?.();
+        yield result;
+        continue;
+      }
+      if (failure !== undefined) throw failure;
+      if (active === 0) return;
+      await new Promise<void>((resolve) => (wake = resolve));
+    }
+  } finally {
+    stopped = true;
+    release();
+    signal?.removeEventListener("abort", abort);
+  }
+}
@@ -0,0 +1,368 @@
+import { TextEdit } from "../diff.js";
+import {
+  AssignStmt,
+  Expr,
+  FuncDecl,
+  GoFile,
+  Ident,
+  Node,
+  Stmt,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importEdits, importName, importPath } from "./imports.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoVariable, resolveFunctionScopes } from "./scope.js";
+import { sortedImportEdits } from "./sort-imports.js";
+
+/**
+ * String Builders
+ * ===============
+ * Flags local strings built up with `s += x` (or `s = s + x`) inside a loop,
+ * which copies the whole string on every iteration, and rewrites them to use
+ * a `strings.Builder`: the declaration becomes `var b strings.Builder`, each
+ * concatenation a `WriteString` call and each read of the string
+ * `b.String()`, which does not copy. Only accumulators the rewrite keeps
+ * equivalent are reported: strings declared on their own, outside the loop,
+ * that are never reassigned or prepended to, never have their address taken
+ * and are not used inside function literals. Concatenation outside loops is
+ * left alone.
+ */
+
+export interface GoStringConcatFinding extends GoFinding {
+  rule: "string-concat-in-loop";
+  variable: string;
+  /** Line of the outermost loop repeating the concatenation */
+  loopLine: number;
+}
+
+export interface StringBuilderOptions {
+  /** Line of a concatenation reported by {@link findStringConcatInLoops} */
+  line: number;
+  /** Also group and sort the file's imports */
+  sortImports?: boolean;
+}
+
+export interface StringBuilderResult extends GoRefactorResult {
+  variable: string;
+  builder: string;
+  /** Concatenations turned into `WriteString` calls */
+  writes: number;
+  /** Reads turned into `String` calls */
+  reads: number;
+}
+
+interface Append {
+  stmt: AssignStmt;
+  /** Source text of what the statement appends */
+  text: string;
+}
+
+interface Accumulator {
+  variable: GoVariable;
+  fn: FuncDecl;
+  /** The statement declaring the accumulator and nothing else */
+  declaration?: Stmt;
+  /** The accumulator's initial value */
+  init?: Expr;
+  appends: Append[];
+  reads: Ident[];
+  /** The first concatenation inside a loop, and that loop */
+  first: { stmt: AssignStmt; loop: Stmt };
+  /** Why the rewrite would change behavior, when it would */
+  unsupported?: string;
+}
+
+const BUILDER_NAMES = ["b", "sb", "builder"];
+
+function isLoop(node: Node): node is Stmt {
+  return node.kind === "ForStmt" || node.kind === "RangeStmt";
+}
+
+class StringConcatAnalyzer {
+  private readonly file: GoFile;
+
+  constructor(file: GoFile) {
+    this.file = file;
+  }
+
+  private line(offset: number): number {
+    return this.file.sourceMap.line(offset);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  // What `s += x` or `s = s + x + y` appends to `s`
+  private appended(stmt: AssignStmt, ident: Ident): string | undefined {
+    if (stmt.lhs.length !== 1 || stmt.rhs.length !== 1) return undefined;
+    const [rhs] = stmt.rhs;
+    if (stmt.tok === "+=") return this.text(rhs);
+    if (stmt.tok !== "=") return undefined;
+    let inner: Expr = rhs;
+    while (inner.kind === "BinaryExpr" && inner.op === "+") {
+      if (inner.x.kind === "Ident" && inner.x.name === ident.name) {
+        return this.file.source.slice(inner.y.pos, rhs.end);
+      }
+      inner = inner.x;
+    }
+    return undefined;
+  }
+
+  private analyzeFunction(fn: FuncDecl): Accumulator[] {
+    const scopes = resolveFunctionScopes(fn);
+    const types = new GoTypeInference(this.file, scopes);
+    const parents = new Map<Node, Node[]>();
+    const declarations = new Map<Ident, { stmt: Stmt; init?: Expr }>();
+    inspect(fn, (node, ancestors) => {
+      parents.set(node, [...ancestors]);
+      if (node.kind === "AssignStmt" && node.tok === ":=") {
+        if (node.lhs.length === 1 && node.lhs[0].kind === "Ident") {
+          declarations.set(node.lhs[0], { stmt: node, init: node.rhs[0] });
+        }
+      } else if (
+        node.kind === "DeclStmt" &&
+        node.decl.kind === "GenDecl" &&
+        node.decl.specs.length === 1
+      ) {
+        const [spec] = node.decl.specs;
+        if (spec.kind === "ValueSpec" && spec.names.length === 1) {
+          declarations.set(spec.names[0], { stmt: node, init: spec.values[0] });
+        }
+      }
+    });
+
+    const accumulators = new Map<GoVariable, Accumulator>();
+    for (const reference of scopes.references) {
+      const { ident, variable } = reference;
+      const stmt = parents.get(ident).at(-1);
+      if (stmt?.kind !== "AssignStmt" || stmt.lhs[0] !== ident) continue;
+      if (this.appended(stmt, ident) === undefined) continue;
+      const ancestors = parents.get(stmt);
+      const outer = ancestors.findIndex(
+        (node) => isLoop(node) && node.pos > variable.ident.pos,
+      );
+      if (outer < 0 || accumulators.has(variable)) continue;
+      // Concatenation repeated by a loop inside a closure is out of reach
+      if (ancestors.slice(outer).some((node) => node.kind === "FuncLit")) {
+        continue;
+      }
+      if (types.typeOfVariable(variable) !== "string") continue;
+      accumulators.set(variable, {
+        variable,
+        fn,
+        declaration: declarations.get(variable.ident)?.stmt,
+        init: declarations.get(variable.ident)?.init,
+        appends: [],
+        reads: [],
+        first: { stmt, loop: ancestors[outer] as Stmt },
+      });
+    }
+
+    for (const accumulator of accumulators.values()) {
+      const { variable } = accumulator;
+      const name = variable.name;
+      if (variable.kind !== "local") {
+        accumulator.unsupported = `${name} is a ${variable.kind === "param" ? "parameter" : "named result"}`;
+        continue;
+      }
+      if (!accumulator.declaration) {
+        accumulator.unsupported = `${name} is declared together with other variables`;
+        continue;
+      }
+      for (const reference of scopes.references) {
+        if (reference.variable !== variable) continue;
+        const { ident } = reference;
+        const where = `line ${this.line(ident.pos)}`;
+        const ancestors = parents.get(ident);
+        const parent = ancestors.at(-1);
+        if (
+          ancestors.some(
+            (node) => node.kind === "FuncLit" && node.pos > variable.ident.pos,
+          )
+        ) {
+          accumulator.unsupported = `${name} is used in a function literal on ${where}`;
+          break;
+        }
+        if (parent?.kind === "UnaryExpr" && parent.op === "&") {
+          accumulator.unsupported = `${name} has its address taken on ${where}`;
+          break;
+        }
+        if (parent?.kind === "AssignStmt" && parent.lhs[0] === ident) {
+          const text = this.appended(parent, ident);
+          if (text === undefined) {
+            accumulator.unsupported = `${name} is reassigned on ${where}`;
+            break;
+          }
+          accumulator.appends.push({ stmt: parent, text });
+          continue;
+        }
+        // The operand `s` of `s = s + x` goes with its statement
+        const assignment = ancestors.findLast(
+          (node) => node.kind === "AssignStmt",
+        ) as AssignStmt | undefined;
+        if (
+          assignment?.lhs[0].kind === "Ident" &&
+          scopes.resolved.get(assignment.lhs[0]) === variable &&
+          this.appended(assignment, ident) !== undefined &&
+          assignment.rhs[0].pos <= ident.pos &&
+          this.leftmost(assignment.rhs[0]) === ident
+        ) {
+          continue;
+        }
+        if (reference.write) {
+          accumulator.unsupported = `${name} is reassigned on ${where}`;
+          break;
+        }
+        accumulator.reads.push(ident);
+      }
+    }
+    return [...accumulators.values()];
+  }
+
+  private leftmost(expr: Expr): Expr {
+    let current = expr;
+    while (current.kind === "BinaryExpr") current = current.x;
+    return current;
+  }
+
+  analyze(): Accumulator[] {
+    return this.file.decls.flatMap((decl) =>
+      decl.kind === "FuncDecl" && decl.body ? this.analyzeFunction(decl) : [],
+    );
+  }
+
+  finding(accumulator: Accumulator): GoStringConcatFinding {
+    const { variable, first } = accumulator;
+    const loopLine = this.line(first.loop.pos);
+    return {
+      rule: "string-concat-in-loop",
+      severity: "low",
+      filePath: this.file.filePath,
+      ...this.file.sourceMap.position(first.stmt.pos),
+      message: `${variable.name} is concatenated in the loop on line ${loopLine}, copying it on every iteration; build it with a strings.Builder`,
+      variable: variable.name,
+      loopLine,
+    };
+  }
+
+  // Identifiers a builder would collide with
+  private builderName(accumulator: Accumulator): string {
+    const used = new Set<string>();
+    inspect(accumulator.fn, (node) => {
+      if (node.kind === "Ident") used.add(node.name);
+    });
+    return (
+      [...BUILDER_NAMES, `${accumulator.variable.name}Builder`].find(
+        (name) => !used.has(name),
+      ) ?? `${accumulator.variable.name}Builder${used.size}`
+    );
+  }
+
+  rewrite(options: StringBuilderOptions): StringBuilderResult {
+    const accumulator = this.analyze().find((candidate) =>
+      [candidate.first, ...candidate.appends].some(
+        ({ stmt }) => this.line(stmt.pos) === options.line,
+      ),
+    );
+    if (!accumulator) {
+      throw new GoRefactorError(
+        `No string is concatenated in a loop on line ${options.line}`,
+      );
+    }
+    if (accumulator.unsupported) {
+      throw new GoRefactorError(
+        `Cannot use a strings.Builder: ${accumulator.unsupported}`,
+      );
+    }
+
+    const { file } = this;
+    const existing = file.imports.find(
+      (spec) => importPath(spec) === "strings" && !spec.name,
+    ) ?? file.imports.find((spec) => importPath(spec) === "strings");
+    const pkg = existing ? importName(existing) : "strings";
+    if (pkg === "_" || pkg === ".") {
+      throw new GoRefactorError(
+        `strings is imported as ${pkg}, so strings.Builder cannot be named`,
+      );
+    }
+    const builder = this.builderName(accumulator);
+
+    const edits: TextEdit[] = [];
+    const { declaration: stmt, init } = accumulator;
+    const lineStart = file.sourceMap.lineStart(this.line(stmt.pos));
+    const indent = /^[ \t]*/.exec(file.source.slice(lineStart))[0];
+    const isEmpty =
+      !init || (init.kind === "BasicLit" && /^(""|``)$/.test(init.value));
+    edits.push({
+      start: stmt.pos,
+      end: stmt.end,
+      newText:
+        `var ${builder} ${pkg}.Builder` +
+        (isEmpty ? "" : `\n${indent}${builder}.WriteString(${this.text(init)})`),
+    });
+    for (const { stmt: append, text } of accumulator.appends) {
+      edits.push({
+        start: append.pos,
+        end: append.end,
+        newText: `${builder}.WriteString(${text})`,
+      });
+    }
+    for (const read of accumulator.reads) {
+      edits.push({
+        start: read.pos,
+        end: read.end,
+        newText: `${builder}.String()`,
+      });
+    }
+    const missing = existing ? [] : [{ path: "strings" }];
+    edits.push(
+      ...(options.sortImports
+        ? sortedImportEdits(file, missing)
+        : importEdits(file, missing)),
+    );
+
+    return {
+      ...refactorResult(file, edits),
+      variable: accumulator.variable.name,
+      builder,
+      writes: accumulator.appends.length,
+      reads: accumulator.reads.length,
+    };
+  }
+}
+
+/**
+ * Find local strings accumulated by concatenation inside loops that can be
+ * rewritten to use a strings.Builder
+ */
+export function findStringConcatInLoops(
+  files: GoFile[],
+): GoStringConcatFinding[] {
+  const findings: GoStringConcatFinding[] = [];
+  for (const file of files) {
+    const analyzer = new StringConcatAnalyzer(file);
+    for (const accumulator of analyzer.analyze()) {
+      if (!accumulator.unsupported) {
+        findings.push(analyzer.finding(accumulator));
+      }
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Rewrite a string accumulated in a loop to use a strings.Builder
+ */
+export function useStringsBuilder(
+  file: GoFile,
+  options: StringBuilderOptions,
+): StringBuilderResult {
+  return new StringConcatAnalyzer(file).rewrite(options);
+}
@@ -0,0 +1,180 @@
+import { GoFile, Node, inspect } from "./ast.js";
+import { GoFinding } from "./findings.js";
+
+/**
+ * Inline Suppressions
+ * ===================
+ * A reviewed exception to a rule is written where it applies:
+ *
+ *     //refactogent:ignore naked-return kept short for the benchmark
+ *     func parse(s string) (n int, err error) {
+ *
+ * The directive names one rule, or several separated by commas, and the
+ * reason for the exception. Like Go's own `//go:` directives it has no space
+ * after the slashes, so `go doc` leaves it out of the documentation. At the
+ * end of a line of code it covers that line. On a line of its own it covers
+ * the next line of code and, when a declaration, spec or struct field starts
+ * there, all of it, so a directive above a function silences the rule
+ * anywhere in the function.
+ *
+ * A directive no finding matches any more is reported as an
+ * `unused-suppression` finding, so exceptions are removed once the code they
+ * excuse is fixed. Unlike a baseline, which records findings wholesale, each
+ * suppression is in the diff and carries its justification.
+ */
+
+/** The comment prefix introducing a suppression */
+export const GO_SUPPRESSION_DIRECTIVE = "//refactogent:ignore";
+
+/** Rule ID of findings about suppressions that silence nothing */
+export const UNUSED_SUPPRESSION_RULE = "unused-suppression";
+
+/**
+ * One rule silenced by a directive; a directive naming several rules yields
+ * one suppression per rule
+ */
+export interface GoSuppression {
+  rule: string;
+  /** Why the finding is accepted; empty when the directive gives none */
+  reason: string;
+  filePath: string;
+  /** Position of the directive */
+  line: number;
+  column: number;
+  /** Lines the directive covers, inclusive */
+  startLine: number;
+  endLine: number;
+}
+
+export interface GoSuppressedFinding<T extends GoFinding = GoFinding> {
+  finding: T;
+  suppression: GoSuppression;
+}
+
+export interface GoSuppressionResult<T extends GoFinding = GoFinding> {
+  /** Findings no directive covers */
+  findings: T[];
+  suppressed: GoSuppressedFinding<T>[];
+  /** Suppressions no finding matched */
+  unused: GoSuppression[];
+}
+
+const DIRECTIVE = /^\/\/refactogent:ignore(?:\s+(\S+)(?:\s+(.*))?)?$/;
+
+// Kinds of nodes a directive on the line above covers entirely
+const COVERED = new Set([
+  "FuncDecl",
+  "GenDecl",
+  "TypeSpec",
+  "ValueSpec",
+  "Field",
+]);
+
+/**
+ * The suppression directives of a file, in source order
+ */
+export function parseGoSuppressions(file: GoFile): GoSuppression[] {
+  const { source, sourceMap } = file;
+  const suppressions: GoSuppression[] = [];
+  for (const group of file.comments) {
+    for (const comment of group.list) {
+      const match = DIRECTIVE.exec(comment.text.trim());
+      if (!match) continue;
+      const { line, column } = sourceMap.position(comment.pos);
+      const before = source.slice(sourceMap.lineStart(line), comment.pos);
+      let [startLine, endLine] = [line, line];
+      if (before.trim() === "") {
+        // The next line of code, past the rest of the comment group
+        startLine = endLine = sourceMap.line(group.end) + 1;
+        const [lineStart, nextLine] = [
+          sourceMap.lineStart(startLine),
+          sourceMap.lineStart(startLine + 1),
+        ];
+        inspect(file, (node: Node) => {
+          if (node.end < lineStart || node.pos >= nextLine) return false;
+          if (!COVERED.has(node.kind) || node.pos < lineStart) return;
+          endLine = Math.max(endLine, sourceMap.line(node.end));
+          return false;
+        });
+      }
+      const rules = (match[1] ?? "").split(",").filter((rule) => rule !== "");
+      for (const rule of rules.length > 0 ? rules : [""]) {
+        suppressions.push({
+          rule,
+          reason: (match[2] ?? "").trim(),
+          filePath: file.filePath,
+          line,
+          column,
+          startLine,
+          endLine,
+        });
+      }
+    }
+  }
+  return suppressions;
+}
+
+/**
+ * Split findings into those a directive in their file covers and the rest,
+ * and collect the directives that matched nothing
+ */
+export function applyGoSuppressions<T extends GoFinding>(
+  findings: T[],
+  files: GoFile[],
+): GoSuppressionResult<T> {
+  const byPath = new Map<string, GoSuppression[]>();
+  for (const file of files) {
+    byPath.set(file.filePath, parseGoSuppressions(file));
+  }
+  const used = new Set<GoSuppression>();
+  const result: GoSuppressionResult<T> = {
+    findings: [],
+    suppressed: [],
+    unused: [],
+  };
+  for (const finding of findings) {
+    const suppression = byPath
+      .get(finding.filePath)
+      ?.find(
+        (candidate) =>
+          candidate.rule === finding.rule &&
+          finding.line >= candidate.startLine &&
+          finding.line <= candidate.endLine,
+      );
+    if (suppression) {
+      used.add(suppression);
+      result.suppressed.push({ finding, suppression });
+    } else {
+      result.findings.push(finding);
+    }
+  }
+  result.unused = [...byPath.values()]
+    .flat()
+    .filter((suppression) => !used.has(suppression));
+  return result;
+}
+
+/**
+ * A finding asking for a suppression that silences nothing to be removed.
+ * `known` tells whether the suppressed rule is registered at all.
+ */
+export function unusedSuppressionFinding(
+  suppression: GoSuppression,
+  known: boolean,
+): GoFinding {
+  const { rule } = suppression;
+  const message =
+    rule === ""
+      ? `${GO_SUPPRESSION_DIRECTIVE} names no rule`
+      : known
+        ? `${GO_SUPPRESSION_DIRECTIVE} ${rule} matches no finding; remove it`
+        : `${GO_SUPPRESSION_DIRECTIVE} names unknown rule ${rule}`;
+  return {
+    rule: UNUSED_SUPPRESSION_RULE,
+    severity: "low",
+    filePath: suppression.filePath,
+    line: suppression.line,
+    column: suppression.column,
+    message,
+  };
+}
@@ -0,0 +1,217 @@
+import * as path from "path";
+import { GoFile, Ident, ImportSpec, Node, inspect } from "./ast.js";
+import {
+  GoNameDeclaration,
+  GoNamedKind,
+  indexGoPackageNames,
+} from "./naming.js";
+import { goPackageSymbols, groupGoPackages } from "./package.js";
+import { GoFileSymbols, GoFunctionSymbol, GoTypeSymbol } from "./symbols.js";
+
+/**
+ * Go Symbols by Position
+ * ======================
+ * Answers "what is at line L, column C?" for editor hover and similar
+ * queries. Building the index walks each file once, recording the ranges of
+ * its top-level functions, methods and types and of every identifier, both
+ * sorted by offset; a query is then two binary searches in the file's
+ * arrays, so it stays fast however large the package grows. Identifiers are
+ * resolved through the package's naming index: locals to their variable,
+ * package-level names to their declaration in any file of the package, and
+ * fields and methods by name, which can leave several candidates.
+ */
+
+/**
+ * Where a name is declared
+ */
+export interface GoDeclarationSite {
+  name: string;
+  /** `import` for package names introduced by an import spec */
+  kind: GoNamedKind | "import";
+  filePath: string;
+  line: number;
+  column: number;
+}
+
+/**
+ * What a position falls on
+ */
+export interface GoSymbolAt {
+  /** The top-level function, method or type declaration around it */
+  enclosing?: GoFunctionSymbol | GoTypeSymbol;
+  /** The identifier under it */
+  identifier?: string;
+  /**
+   * Declarations the identifier may refer to: one when resolved, several for
+   * a field or method name declared on more than one type, and none for
+   * other packages' members and predeclared names
+   */
+  declarations: GoDeclarationSite[];
+}
+
+interface Range<T> {
+  pos: number;
+  end: number;
+  value: T;
+}
+
+interface FileIndex {
+  file: GoFile;
+  /** Top-level declarations, sorted and disjoint */
+  enclosing: Range<GoFunctionSymbol | GoTypeSymbol>[];
+  /** Identifiers, sorted and disjoint */
+  idents: Range<GoDeclarationSite[]>[];
+}
+
+// The range containing an offset, by binary search on the start offsets
+function find<T>(ranges: Range<T>[], offset: number): Range<T> | undefined {
+  let lo = 0;
+  let hi = ranges.length - 1;
+  while (lo <= hi) {
+    const mid = (lo + hi) >> 1;
+    if (ranges[mid].pos > offset) {
+      hi = mid - 1;
+    } else if (ranges[mid].end <= offset) {
+      lo = mid + 1;
+    } else {
+      return ranges[mid];
+    }
+  }
+  return undefined;
+}
+
+function site(declaration: GoNameDeclaration): GoDeclarationSite {
+  const { file, node } = declaration;
+  return {
+    name: declaration.name,
+    kind: declaration.kind,
+    filePath: file.filePath,
+    ...file.sourceMap.position(node.pos),
+  };
+}
+
+function importSite(file: GoFile, spec: ImportSpec): GoDeclarationSite {
+  return {
+    name: spec.name?.name ?? spec.path.value.slice(1, -1).split("/").pop(),
+    kind: "import",
+    filePath: file.filePath,
+    ...file.sourceMap.position((spec.name ?? spec.path).pos),
+  };
+}
+
+/**
+ * Position index over the functions, types and identifiers of Go files
+ */
+export class GoPositionIndex {
+  private readonly files = new Map<string, FileIndex>();
+
+  constructor(files: GoFile[]) {
+    for (const group of groupGoPackages(files)) {
+      this.indexPackage(group);
+    }
+  }
+
+  /**
+   * The symbols at a 1-based line and byte column of a file, or undefined
+   * when the file is not indexed
+   */
+  symbolAt(
+    filePath: string,
+    line: number,
+    column: number,
+  ): GoSymbolAt | undefined {
+    const index = this.files.get(filePath);
+    if (!index) return undefined;
+    const offset = index.file.sourceMap.offset(line, column);
+    const ident = find(index.idents, offset);
+    return {
+      enclosing: find(index.enclosing, offset)?.value,
+      identifier: ident && index.file.source.slice(ident.pos, ident.end),
+      declarations: ident?.value ?? [],
+    };
+  }
+
+  private indexPackage(group: GoFile[]): void {
+    const names = indexGoPackageNames(group);
+    const resolved = new Map<Node, GoNameDeclaration[]>();
+    for (const declaration of names.declarations) {
+      for (const { node } of names.sites(declaration)) {
+        if (!resolved.has(node)) resolved.set(node, []);
+        resolved.get(node).push(declaration);
+      }
+    }
+    const declared = new Map(
+      names.declarations.map((declaration) => [declaration.node, declaration]),
+    );
+
+    const symbols = goPackageSymbols(group);
+    group.forEach((file, i) => {
+      const imports = new Map(
+        file.imports.map((spec) => [importSite(file, spec).name, spec]),
+      );
+      this.files.set(file.filePath, {
+        file,
+        enclosing: enclosingRanges(file, symbols.files[i]),
+        idents: identRanges(file, (ident, qualifier) => {
+          // A declaring identifier refers to itself alone
+          const own = declared.get(ident);
+          if (own) return [site(own)];
+          const found = resolved.get(ident);
+          if (found) return found.map(site);
+          const spec = qualifier && imports.get(ident.name);
+          return spec ? [importSite(file, spec)] : [];
+        }),
+      });
+    });
+  }
+}
+
+// Symbols are extracted in declaration order, so walking the declarations
+// pairs each with its symbol
+function enclosingRanges(
+  file: GoFile,
+  { functions, methods, types }: GoFileSymbols,
+): Range<GoFunctionSymbol | GoTypeSymbol>[] {
+  const ranges: Range<GoFunctionSymbol | GoTypeSymbol>[] = [];
+  let [nextFunction, nextMethod, nextType] = [0, 0, 0];
+  for (const decl of file.decls) {
+    if (decl.kind === "FuncDecl") {
+      const value = decl.recv
+        ? methods[nextMethod++]
+        : functions[nextFunction++];
+      ranges.push({ pos: decl.pos, end: decl.end, value });
+    } else if (decl.kind === "GenDecl" && decl.tok === "type") {
+      for (const spec of decl.specs) {
+        if (spec.kind !== "TypeSpec") continue;
+        ranges.push({ pos: spec.pos, end: spec.end, value: types[nextType++] });
+      }
+    }
+  }
+  return ranges;
+}
+
+function identRanges(
+  file: GoFile,
+  resolve: (ident: Ident, qualifier: boolean) => GoDeclarationSite[],
+): Range<GoDeclarationSite[]>[] {
+  const ranges: Range<GoDeclarationSite[]>[] = [];
+  inspect(file, (node, parents) => {
+    if (node.kind !== "Ident") return;
+    const parent: Node | undefined = parents.at(-1);
+    // Only the operand of a selector can name an imported package
+    const qualifier = parent?.kind === "SelectorExpr" && parent.x === node;
+    ranges.push({
+      pos: node.pos,
+      end: node.end,
+      value: resolve(node, qualifier),
+    });
+  });
+  return ranges.sort((a, b) => a.pos - b.pos);
+}
+
+/**
+ * Index Go files for position queries
+ */
+export function indexGoPositions(files: GoFile[]): GoPositionIndex {
+  return new GoPositionIndex(files);
+}
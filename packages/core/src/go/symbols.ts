@@ -0,0 +1,631 @@
+import { SymbolInfo } from "../indexing.js";
+import {
+  Expr,
+  Field,
+  FieldList,
+  FuncDecl,
+  GoFile,
+  Ident,
+  Node,
+  StructType,
+  TypeSpec,
+} from "./ast.js";
+import { extractGoConstants, GoConstantSymbol } from "./constants.js";
+import { declarationImports, fileImports, GoImport } from "./imports.js";
+import {
+  goSignature,
+  GoSignature,
+  goTypeParams,
+  GoTypeParam,
+  typeString,
+} from "./signature.js";
+import { goFunctionSize, GoFunctionSize } from "./size.js";
+import {
+  closureComplexities,
+  cognitiveComplexity,
+  cyclomaticComplexity,
+  GoClosureComplexity,
+} from "./complexity.js";
+import { compareCodePoints } from "./findings.js";
+
+/**
+ * Go visibility, decided by the first rune of an identifier
+ */
+export enum Visibility {
+  Exported = "exported",
+  Unexported = "unexported",
+}
+
+/**
+ * The receiver of a Go method
+ */
+export interface GoReceiver {
+  /** Receiver variable name; undefined when omitted or blank */
+  name?: string;
+  /** Base type name with pointer and type arguments stripped */
+  typeName: string;
+  isPointer: boolean;
+  /** Type parameter names of a generic receiver, as in `Pair[K, V]` */
+  typeParams?: string[];
+}
+
+/**
+ * A function or method declared in a Go file
+ */
+export interface GoFunctionSymbol extends SymbolInfo {
+  type: "function";
+  /** `Type.Method` for methods, the bare name for functions */
+  qualifiedName: string;
+  receiver?: GoReceiver;
+  /** Type parameters of a generic function, with their constraints */
+  typeParams?: GoTypeParam[];
+  signature: GoSignature;
+  visibility: Visibility;
+  complexity: number;
+  /** Nesting-weighted complexity, function literals included */
+  cognitiveComplexity: number;
+  /** Function literals in the body, each measured on its own */
+  closures: GoClosureComplexity[];
+  /** Line and statement counts, the doc comment counted separately */
+  size: GoFunctionSize;
+  /** Paths of the named imports the function selects from, sorted */
+  imports: string[];
+  /** Distinct callers, once annotated from a call graph */
+  fanIn?: number;
+  /** Distinct callees, once annotated from a call graph */
+  fanOut?: number;
+  /** Share of lines covered by tests, once annotated from a profile */
+  coveragePct?: number;
+  /** Score from 0 to 100 of complexity, size and comments, once annotated */
+  maintainabilityIndex?: number;
+  /** Set when parsing recovered from a syntax error in the declaration */
+  partial?: boolean;
+}
+
+/**
+ * A type embedded in a struct or interface
+ */
+export interface GoEmbeddedType {
+  typeName: string;
+  isPointer: boolean;
+}
+
+/**
+ * A field of a struct type
+ */
+export interface GoFieldSymbol {
+  /** Declared name, or the type name for an embedded field */
+  name: string;
+  /** Canonical type text, e.g. `map[string]interface{}` */
+  type: string;
+  visibility: Visibility;
+  embedded: boolean;
+  /** Tag contents without the quotes, when the field has a tag */
+  tag?: string;
+  /** Tag keys and values, parsed like `reflect.StructTag.Get` */
+  tags: Record<string, string>;
+  /**
+   * Fields of an anonymous struct type, reached through pointers, slices,
+   * arrays and map values
+   */
+  fields?: GoFieldSymbol[];
+  line: number;
+  column: number;
+  documentation?: string;
+}
+
+/**
+ * A field selectable on a struct type, declared or promoted
+ */
+export interface GoFieldSetEntry {
+  name: string;
+  type: string;
+  /** 0 for fields declared on the type, otherwise the embedding depth */
+  depth: number;
+  /** Embedded field names leading to the field, outermost first */
+  via: string[];
+}
+
+/**
+ * A type declared in a Go file, with the methods declared on it
+ */
+export interface GoTypeSymbol extends SymbolInfo {
+  type: "type" | "interface";
+  typeKind: "struct" | "interface" | "alias" | "defined";
+  /** Type parameters of a generic type, with their constraints */
+  typeParams?: GoTypeParam[];
+  visibility: Visibility;
+  embedded: GoEmbeddedType[];
+  /** Struct fields in declaration order; empty for other kinds */
+  fields: GoFieldSymbol[];
+  /** Methods declared with this type as receiver, in source order */
+  methods: GoFunctionSymbol[];
+  /** Set when parsing recovered from a syntax error in the declaration */
+  partial?: boolean;
+}
+
+/**
+ * An entry in a type's method set
+ */
+export interface GoMethodSetEntry {
+  name: string;
+  receiver: GoReceiver;
+  /** Embedded type the method is promoted from, when not declared directly */
+  promotedFrom?: string;
+}
+
+/**
+ * Symbols extracted from a single Go file
+ */
+export interface GoFileSymbols {
+  filePath: string;
+  packageName: string;
+  imports: GoImport[];
+  functions: GoFunctionSymbol[];
+  methods: GoFunctionSymbol[];
+  types: GoTypeSymbol[];
+  constants: GoConstantSymbol[];
+  /**
+   * Mean maintainability index of the functions and methods, weighted by
+   * their code lines, once annotated
+   */
+  maintainabilityIndex?: number;
+  /**
+   * Errors parsing recovered from, with messages that leave the position to
+   * `line` and `column`; symbols in declarations that did not parse at all
+   * are missing
+   */
+  syntaxErrors?: { message: string; line: number; column: number }[];
+}
+
+/**
+ * Strip pointers, parentheses and type arguments to find a type's base name
+ */
+export function baseTypeName(expr: Expr): { name: string; isPointer: boolean } {
+  let isPointer = false;
+  let current: Expr = expr;
+  for (;;) {
+    switch (current.kind) {
+      case "StarExpr":
+        isPointer = true;
+        current = current.x;
+        continue;
+      case "ParenExpr":
+        current = current.x;
+        continue;
+      case "IndexExpr":
+      case "IndexListExpr":
+        current = current.x;
+        continue;
+      case "SelectorExpr":
+        return { name: current.sel.name, isPointer };
+      case "Ident":
+        return { name: current.name, isPointer };
+      default:
+        return { name: "", isPointer };
+    }
+  }
+}
+
+/**
+ * The type parameters a generic receiver type declares, as `K` and `V` in
+ * `(p *Pair[K, V])`; empty for other receivers
+ */
+export function receiverTypeParams(expr: Expr): Ident[] {
+  let current = expr;
+  while (current.kind === "StarExpr" || current.kind === "ParenExpr") {
+    current = current.x;
+  }
+  const indices =
+    current.kind === "IndexExpr"
+      ? [current.index]
+      : current.kind === "IndexListExpr"
+        ? current.indices
+        : [];
+  return indices.filter((index): index is Ident => index.kind === "Ident");
+}
+
+function receiverOf(recv: FieldList | undefined): GoReceiver | undefined {
+  const field = recv?.list[0];
+  if (!field) {
+    return undefined;
+  }
+  const { name, isPointer } = baseTypeName(field.type);
+  const receiverName = field.names[0]?.name;
+  const typeParams = receiverTypeParams(field.type).map((ident) => ident.name);
+  return {
+    name: receiverName && receiverName !== "_" ? receiverName : undefined,
+    typeName: name,
+    isPointer,
+    typeParams: typeParams.length > 0 ? typeParams : undefined,
+  };
+}
+
+// Undefined rather than empty for declarations that are not generic
+function typeParamsOf(
+  file: GoFile,
+  list: FieldList | undefined,
+): GoTypeParam[] | undefined {
+  return list ? goTypeParams(file, list) : undefined;
+}
+
+/**
+ * Classify an identifier the way the Go spec does: exported when its first
+ * rune is an uppercase letter (`unicode.IsUpper`, Unicode class Lu). Names
+ * starting with `_`, a digit or a letter without case are unexported.
+ */
+export function goVisibility(name: string): Visibility {
+  // Match on the first code point so astral-plane letters are classified whole
+  return /^\p{Lu}/u.test(name) ? Visibility.Exported : Visibility.Unexported;
+}
+
+export function isExportedName(name: string): boolean {
+  return goVisibility(name) === Visibility.Exported;
+}
+
+/**
+ * Whether a symbol is invisible outside its package, so renaming it cannot
+ * break callers in other packages
+ */
+export function isPackageLocal(symbol: { visibility: Visibility }): boolean {
+  return symbol.visibility === Visibility.Unexported;
+}
+
+function span(file: GoFile, node: Node) {
+  const start = file.sourceMap.position(node.pos);
+  const end = file.sourceMap.position(node.end);
+  return {
+    startLine: start.line,
+    endLine: end.line,
+    startColumn: start.column,
+    endColumn: end.column,
+  };
+}
+
+// Whether parsing recovered from an error inside a node
+function partial(file: GoFile, node: Node): boolean {
+  return (
+    file.syntaxErrors?.some(
+      (error) => error.pos >= node.pos && error.pos <= node.end,
+    ) ?? false
+  );
+}
+
+/**
+ * Build the symbol for a single function or method declaration
+ */
+export function goFunctionSymbol(
+  file: GoFile,
+  decl: FuncDecl,
+): GoFunctionSymbol {
+  const name = decl.name.name;
+  const receiver = receiverOf(decl.recv);
+  const isExported = isExportedName(name);
+  return {
+    name,
+    type: "function",
+    qualifiedName: receiver ? `${receiver.typeName}.${name}` : name,
+    receiver,
+    typeParams: typeParamsOf(file, decl.type.typeParams),
+    signature: goSignature(file, decl.type),
+    ...span(file, decl),
+    visibility: goVisibility(name),
+    isExported,
+    isPrivate: !isExported,
+    documentation: decl.doc?.text.trim() || undefined,
+    complexity: cyclomaticComplexity(decl.body),
+    cognitiveComplexity: cognitiveComplexity(decl.body),
+    closures: closureComplexities(file, decl.body),
+    size: goFunctionSize(file, decl),
+    imports: declarationImports(file, decl),
+    ...(partial(file, decl) && { partial: true }),
+  };
+}
+
+/**
+ * Parse a struct tag the way `reflect.StructTag` does: space-separated
+ * `key:"value"` pairs, stopping at the first malformed pair
+ */
+export function parseStructTag(tag: string): Record<string, string> {
+  const tags: Record<string, string> = {};
+  const pair = /^\s*([^\s:"\x00-\x1f\x7f]+):("(?:[^"\\]|\\.)*")/;
+  let rest = tag;
+  for (;;) {
+    const match = pair.exec(rest);
+    if (!match) break;
+    try {
+      const value = JSON.parse(match[2]);
+      if (!(match[1] in tags)) tags[match[1]] = value;
+    } catch {
+      break;
+    }
+    rest = rest.slice(match[0].length);
+  }
+  return tags;
+}
+
+function anonymousStruct(expr: Expr): StructType | undefined {
+  let current = expr;
+  for (;;) {
+    switch (current.kind) {
+      case "StructType":
+        return current;
+      case "StarExpr":
+      case "ParenExpr":
+        current = current.x;
+        continue;
+      case "ArrayType":
+        current = current.elt;
+        continue;
+      case "MapType":
+        current = current.value;
+        continue;
+      default:
+        return undefined;
+    }
+  }
+}
+
+function fieldSymbols(file: GoFile, fields: Field[]): GoFieldSymbol[] {
+  const symbols: GoFieldSymbol[] = [];
+  for (const field of fields) {
+    const type = typeString(file, field.type);
+    const tag = field.tag ? field.tag.value.slice(1, -1) : undefined;
+    const nested = anonymousStruct(field.type);
+    const documentation = (field.doc ?? field.comment)?.text.trim();
+    const common = {
+      type,
+      tag,
+      tags: tag === undefined ? {} : parseStructTag(tag),
+      fields: nested ? fieldSymbols(file, nested.fields.list) : undefined,
+      documentation: documentation || undefined,
+    };
+    if (field.names.length === 0) {
+      const { name } = baseTypeName(field.type);
+      symbols.push({
+        name,
+        ...common,
+        visibility: goVisibility(name),
+        embedded: true,
+        ...file.sourceMap.position(field.type.pos),
+      });
+    }
+    for (const ident of field.names) {
+      symbols.push({
+        name: ident.name,
+        ...common,
+        visibility: goVisibility(ident.name),
+        embedded: false,
+        ...file.sourceMap.position(ident.pos),
+      });
+    }
+  }
+  return symbols;
+}
+
+function typeSymbol(
+  file: GoFile,
+  spec: TypeSpec,
+  doc: string | undefined,
+): GoTypeSymbol {
+  const name = spec.name.name;
+  const isExported = isExportedName(name);
+  const embedded: GoEmbeddedType[] = [];
+  let fields: GoFieldSymbol[] = [];
+  let typeKind: GoTypeSymbol["typeKind"] = spec.isAlias ? "alias" : "defined";
+
+  if (!spec.isAlias && spec.type.kind === "StructType") {
+    typeKind = "struct";
+    fields = fieldSymbols(file, spec.type.fields.list);
+    for (const field of spec.type.fields.list) {
+      if (field.names.length === 0) {
+        const { name: typeName, isPointer } = baseTypeName(field.type);
+        embedded.push({ typeName, isPointer });
+      }
+    }
+  } else if (!spec.isAlias && spec.type.kind === "InterfaceType") {
+    typeKind = "interface";
+    for (const element of spec.type.methods.list) {
+      if (element.names.length === 0 && element.type.kind !== "BinaryExpr") {
+        const { name: typeName } = baseTypeName(element.type);
+        if (typeName) {
+          embedded.push({ typeName, isPointer: false });
+        }
+      }
+    }
+  }
+
+  return {
+    name,
+    type: typeKind === "interface" ? "interface" : "type",
+    typeKind,
+    typeParams: typeParamsOf(file, spec.typeParams),
+    visibility: goVisibility(name),
+    embedded,
+    fields,
+    methods: [],
+    ...span(file, spec),
+    isExported,
+    isPrivate: !isExported,
+    documentation: doc,
+    ...(partial(file, spec) && { partial: true }),
+  };
+}
+
+/**
+ * Extract functions, methods and types from a parsed Go file. Methods are
+ * attached to the type they are declared on when that type is in the file.
+ */
+export function extractGoFileSymbols(file: GoFile): GoFileSymbols {
+  const functions: GoFunctionSymbol[] = [];
+  const methods: GoFunctionSymbol[] = [];
+  const types: GoTypeSymbol[] = [];
+
+  for (const decl of file.decls) {
+    if (decl.kind === "FuncDecl") {
+      const symbol = goFunctionSymbol(file, decl);
+      (symbol.receiver ? methods : functions).push(symbol);
+    } else if (decl.kind === "GenDecl" && decl.tok === "type") {
+      for (const spec of decl.specs) {
+        if (spec.kind !== "TypeSpec") continue;
+        // A lone spec in an ungrouped declaration takes the decl's doc
+        const doc = (spec.doc ?? (decl.lparen < 0 ? decl.doc : undefined))
+          ?.text.trim();
+        types.push(typeSymbol(file, spec, doc || undefined));
+      }
+    }
+  }
+
+  groupMethods(types, methods);
+
+  return {
+    filePath: file.filePath,
+    packageName: file.packageName.name,
+    imports: fileImports(file),
+    functions,
+    methods,
+    types,
+    constants: extractGoConstants(file),
+    ...(file.syntaxErrors?.length > 0 && {
+      syntaxErrors: file.syntaxErrors.map(({ reason, line, column }) => ({
+        message: reason,
+        line,
+        column,
+      })),
+    }),
+  };
+}
+
+/**
+ * Attach each method to the type symbol named by its receiver
+ */
+export function groupMethods(
+  types: GoTypeSymbol[],
+  methods: GoFunctionSymbol[],
+): void {
+  const byName = new Map(types.map((type) => [type.name, type]));
+  for (const method of methods) {
+    const owner = byName.get(method.receiver?.typeName ?? "");
+    if (owner && !owner.methods.includes(method)) {
+      owner.methods.push(method);
+    }
+  }
+}
+
+/**
+ * Compute the method set of a named type, following the Go spec: the set for
+ * `T` holds value-receiver methods, the set for `*T` holds all methods, and
+ * methods of embedded fields are promoted unless a method or field of the
+ * same name is found at a shallower depth. Names found twice at the same
+ * depth are ambiguous and not promoted at all.
+ */
+export function goMethodSet(
+  types: GoTypeSymbol[],
+  typeName: string,
+  pointer: boolean,
+): GoMethodSetEntry[] {
+  const byName = new Map(types.map((type) => [type.name, type]));
+  const result = new Map<string, GoMethodSetEntry>();
+  // Names blocked by a shallower selector or an ambiguity
+  const blocked = new Set<string>();
+  const visited = new Set<string>();
+
+  // Breadth-first so shallower declarations shadow deeper promoted ones
+  let level: { name: string; addressable: boolean; via?: string }[] = [
+    { name: typeName, addressable: pointer },
+  ];
+  while (level.length > 0) {
+    const nextLevel: typeof level = [];
+    // Selectors by name at this depth; null for fields, and for pointer
+    // methods outside the set, which still hide deeper methods
+    const found = new Map<string, (GoMethodSetEntry | null)[]>();
+    const add = (name: string, entry: GoMethodSetEntry | null) =>
+      found.set(name, [...(found.get(name) ?? []), entry]);
+    for (const entry of level) {
+      const type = byName.get(entry.name);
+      if (!type || visited.has(entry.name)) continue;
+      visited.add(entry.name);
+      for (const method of type.methods) {
+        const receiver = method.receiver;
+        if (!receiver) continue;
+        add(
+          method.name,
+          receiver.isPointer && !entry.addressable
+            ? null
+            : { name: method.name, receiver, promotedFrom: entry.via },
+        );
+      }
+      for (const field of type.fields) add(field.name, null);
+      for (const embedded of type.embedded) {
+        nextLevel.push({
+          name: embedded.typeName,
+          // Embedding *E promotes E's pointer methods even into T's value set
+          addressable: entry.addressable || embedded.isPointer,
+          via: entry.via ?? embedded.typeName,
+        });
+      }
+    }
+    found.forEach((candidates, name) => {
+      if (blocked.has(name)) return;
+      blocked.add(name);
+      if (candidates.length === 1 && candidates[0]) {
+        result.set(name, candidates[0]);
+      }
+    });
+    level = nextLevel;
+  }
+
+  return [...result.values()].sort((a, b) => compareCodePoints(a.name, b.name));
+}
+
+/**
+ * Compute the fields selectable on a struct type, following the Go spec:
+ * fields of embedded structs are promoted unless a field of the same name is
+ * found at a shallower depth, and names found twice at the same depth are
+ * ambiguous and not promoted at all.
+ */
+export function goFieldSet(
+  types: GoTypeSymbol[],
+  typeName: string,
+): GoFieldSetEntry[] {
+  const byName = new Map(types.map((type) => [type.name, type]));
+  const result = new Map<string, GoFieldSetEntry>();
+  // Names blocked by a shallower field or an ambiguity
+  const blocked = new Set<string>();
+  const visited = new Set<string>();
+
+  let level: { name: string; via: string[] }[] = [{ name: typeName, via: [] }];
+  for (let depth = 0; level.length > 0; depth++) {
+    const nextLevel: typeof level = [];
+    const found = new Map<string, GoFieldSetEntry[]>();
+    for (const entry of level) {
+      const type = byName.get(entry.name);
+      if (!type || visited.has(entry.name)) continue;
+      visited.add(entry.name);
+      for (const field of type.fields) {
+        const candidates = found.get(field.name) ?? [];
+        candidates.push({
+          name: field.name,
+          type: field.type,
+          depth,
+          via: entry.via,
+        });
+        found.set(field.name, candidates);
+        if (field.embedded) {
+          // An embedded field is named after its type
+          nextLevel.push({ name: field.name, via: [...entry.via, field.name] });
+        }
+      }
+    }
+    found.forEach((candidates, name) => {
+      if (blocked.has(name)) return;
+      blocked.add(name);
+      if (candidates.length === 1) result.set(name, candidates[0]);
+    });
+    level = nextLevel;
+  }
+
+  return [...result.values()].sort(
+    (a, b) => a.depth - b.depth || compareCodePoints(a.name, b.name),
+  );
+}
@@ -0,0 +1,758 @@
+import { applyEdits, TextEdit } from "../diff.js";
+import {
+  BasicLit,
+  CallExpr,
+  Expr,
+  FuncDecl,
+  GoFile,
+  Node,
+  Stmt,
+  UnaryExpr,
+  forEachChild,
+  inspect,
+} from "./ast.js";
+import { GoTypeInference } from "./infer.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoFunctionScopes, resolveFunctionScopes } from "./scope.js";
+import {
+  functionSignatures,
+  GoSignature,
+  typeString,
+} from "./signature.js";
+
+/**
+ * Table-Driven Tests
+ * ==================
+ * Rewrites a run of near-identical call-and-assert statements in a Go test
+ * into a `[]struct{...}` table ranged over with `t.Run`. Statements match
+ * when their syntax trees are equal apart from basic literals; literals that
+ * differ between repetitions become table columns, and the first repetition,
+ * with those literals replaced by column reads, becomes the loop body.
+ *
+ * Each case runs as a subtest, so a `t.Fatal` now stops only its own case.
+ */
+
+export interface TableTestOptions {
+  /** Test functions to convert; defaults to every `Test` function */
+  tests?: string[];
+  /**
+   * Other files of the package under test. Their function signatures give
+   * columns the parameter and result types of the function being tested.
+   */
+  packageFiles?: GoFile[];
+}
+
+export interface TableTestColumn {
+  name: string;
+  type: string;
+}
+
+export interface TableTestConversion {
+  test: string;
+  line: number;
+  cases: number;
+  /** Columns after `name`, in table order */
+  columns: TableTestColumn[];
+}
+
+export interface TableTestSkip {
+  test: string;
+  line: number;
+  reason: string;
+}
+
+export interface TableTestResult extends GoRefactorResult {
+  converted: TableTestConversion[];
+  skipped: TableTestSkip[];
+}
+
+const TEST_FUNCTION = /^Test([A-Z_\d]|$)/;
+
+const ASSERTIONS = new Set([
+  "Error",
+  "Errorf",
+  "Fail",
+  "FailNow",
+  "Fatal",
+  "Fatalf",
+]);
+
+const BUILTINS = new Set([
+  "append",
+  "cap",
+  "clear",
+  "close",
+  "complex",
+  "copy",
+  "delete",
+  "imag",
+  "len",
+  "make",
+  "max",
+  "min",
+  "new",
+  "panic",
+  "print",
+  "println",
+  "real",
+  "recover",
+]);
+
+const LITERAL_TYPES: Record<BasicLit["litKind"], string> = {
+  int: "int",
+  float: "float64",
+  imag: "complex128",
+  char: "rune",
+  string: "string",
+};
+
+/**
+ * A basic literal, possibly signed, so `-1` varies like `1` does
+ */
+type Literal = BasicLit | (UnaryExpr & { x: BasicLit });
+
+function asLiteral(node: Node): Literal | undefined {
+  if (node.kind === "BasicLit") return node;
+  if (
+    node.kind === "UnaryExpr" &&
+    (node.op === "-" || node.op === "+") &&
+    node.x.kind === "BasicLit"
+  ) {
+    return node as Literal;
+  }
+  return undefined;
+}
+
+function basicLit(literal: Literal): BasicLit {
+  return literal.kind === "BasicLit" ? literal : literal.x;
+}
+
+function literalValue(literal: Literal): string {
+  return literal.kind === "BasicLit"
+    ? literal.value
+    : `${literal.op}${literal.x.value}`;
+}
+
+/**
+ * A statement run reduced to its structure, with literals pulled out
+ */
+interface Shape {
+  key: string;
+  literals: Literal[];
+}
+
+function shapeOf(statements: Stmt[]): Shape {
+  const parts: string[] = [];
+  const literals: Literal[] = [];
+  const walk = (node: Node) => {
+    const literal = asLiteral(node);
+    if (literal) {
+      // Numeric kinds may mix, as in `3` and `6.0`
+      const { litKind } = basicLit(literal);
+      parts.push(`lit:${litKind === "string" ? "string" : "number"}`);
+      literals.push(literal);
+      return;
+    }
+    parts.push(node.kind);
+    for (const [key, value] of Object.entries(node)) {
+      if (key === "kind") continue;
+      if (typeof value === "string" || typeof value === "boolean") {
+        // A later repetition may assign what the first one declared
+        const normalized =
+          node.kind === "AssignStmt" && value === ":=" ? "=" : value;
+        parts.push(`${key}=${normalized}`);
+      }
+    }
+    if (node.kind === "CallExpr") {
+      parts.push(`spread=${node.ellipsis >= 0}`);
+    }
+    forEachChild(node, walk);
+    parts.push(")");
+  };
+  statements.forEach(walk);
+  return { key: parts.join(" "), literals };
+}
+
+interface Repetition {
+  start: number;
+  length: number;
+  count: number;
+}
+
+function rootIdent(expr: Expr): string | undefined {
+  let current = expr;
+  while (current.kind === "SelectorExpr" || current.kind === "ParenExpr") {
+    current = current.x;
+  }
+  return current.kind === "Ident" ? current.name : undefined;
+}
+
+class TableTestConverter {
+  private readonly file: GoFile;
+  private readonly decl: FuncDecl;
+  private readonly testingT: string;
+  private readonly signatures: Map<string, GoSignature>;
+  private readonly stdlibImports = new Set<string>();
+  private readonly scopes: GoFunctionScopes;
+  private readonly types: GoTypeInference;
+
+  constructor(
+    file: GoFile,
+    decl: FuncDecl,
+    testingT: string,
+    signatures: Map<string, GoSignature>,
+  ) {
+    this.file = file;
+    this.decl = decl;
+    this.testingT = testingT;
+    this.signatures = signatures;
+    for (const spec of file.imports) {
+      const importPath = spec.path.value.slice(1, -1);
+      // Standard library paths have no dot in their first element
+      if (!importPath.split("/")[0].includes(".")) {
+        this.stdlibImports.add(spec.name?.name ?? importPath.split("/").pop());
+      }
+    }
+    this.scopes = resolveFunctionScopes(decl);
+    this.types = new GoTypeInference(file, this.scopes);
+  }
+
+  private text(node: Node): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  private calls(statements: Stmt[]): CallExpr[] {
+    const calls: CallExpr[] = [];
+    for (const statement of statements) {
+      inspect(statement, (node) => {
+        if (node.kind === "CallExpr") calls.push(node);
+        return true;
+      });
+    }
+    return calls;
+  }
+
+  private isAssertion(call: CallExpr): boolean {
+    const { fun } = call;
+    if (
+      fun.kind === "SelectorExpr" &&
+      fun.x.kind === "Ident" &&
+      fun.x.name === this.testingT
+    ) {
+      return ASSERTIONS.has(fun.sel.name);
+    }
+    // Helpers such as assert.Equal(t, ...) take the test as first argument
+    const [first] = call.args;
+    return first?.kind === "Ident" && first.name === this.testingT;
+  }
+
+  /**
+   * The call a repetition exercises: the first call that is not on the
+   * test, a builtin or the standard library, else the first other call
+   */
+  private callUnderTest(statements: Stmt[]): CallExpr | undefined {
+    const candidates = this.calls(statements).filter((call) => {
+      const root = rootIdent(call.fun);
+      if (root === this.testingT || this.isAssertion(call)) return false;
+      return !(call.fun.kind === "Ident" && BUILTINS.has(call.fun.name));
+    });
+    return (
+      candidates.find((call) => !this.stdlibImports.has(rootIdent(call.fun))) ??
+      candidates[0]
+    );
+  }
+
+  private signatureOf(call: CallExpr): GoSignature | undefined {
+    const fun = call.fun;
+    const name =
+      fun.kind === "Ident"
+        ? fun.name
+        : fun.kind === "SelectorExpr"
+          ? fun.sel.name
+          : undefined;
+    return name === undefined ? undefined : this.signatures.get(name);
+  }
+
+  private findRepetition(statements: Stmt[]): Repetition | string {
+    let best: Repetition | undefined;
+    let reason = "no repeated call-and-assert statements";
+    const n = statements.length;
+
+    for (let length = 1; length <= n / 2; length++) {
+      const shapes = statements.map((_, start) =>
+        start + length <= n
+          ? shapeOf(statements.slice(start, start + length)).key
+          : undefined,
+      );
+      for (let start = 0; start + 2 * length <= n; start++) {
+        let count = 1;
+        while (
+          start + (count + 1) * length <= n &&
+          shapes[start + count * length] === shapes[start]
+        ) {
+          count++;
+        }
+        if (count < 2) continue;
+        const covered = count * length;
+        if (best && covered <= best.count * best.length) continue;
+
+        const first = statements.slice(start, start + length);
+        if (!this.callUnderTest(first)) {
+          reason = "repeated statements do not call a function under test";
+          continue;
+        }
+        if (!this.calls(first).some((call) => this.isAssertion(call))) {
+          reason = "repeated statements do not assert on the result";
+          continue;
+        }
+        best = { start, length, count };
+      }
+    }
+    return best ?? reason;
+  }
+
+  private lineStart(offset: number): number {
+    return this.file.sourceMap.lineStart(this.file.sourceMap.line(offset));
+  }
+
+  convert(): { edit: TextEdit; conversion: TableTestConversion } | string {
+    const statements = this.decl.body.list;
+    const repetition = this.findRepetition(statements);
+    if (typeof repetition === "string") return repetition;
+
+    const { start, length, count } = repetition;
+    const segments = Array.from({ length: count }, (_, index) =>
+      statements.slice(start + index * length, start + (index + 1) * length),
+    );
+    const shapes = segments.map(shapeOf);
+    const [first] = segments;
+    const groupStart = first[0].pos;
+    const groupEnd = segments[count - 1][length - 1].end;
+
+    for (const statement of first) {
+      let problem: string | undefined;
+      inspect(statement, (node) => {
+        if (problem || node.kind === "FuncLit") return false;
+        if (node.kind === "ReturnStmt") {
+          problem = `return at line ${this.file.sourceMap.line(node.pos)} would only end one case`;
+        } else if (node.kind === "CallExpr" && this.isRun(node)) {
+          problem = "repeated statements already use subtests";
+        } else if (
+          node.kind === "BasicLit" &&
+          node.value.startsWith("`") &&
+          node.value.includes("\n")
+        ) {
+          problem = "repeated statements contain a multi-line raw string";
+        }
+        return true;
+      });
+      if (problem) return problem;
+    }
+
+    // Locals declared by the repetitions must not outlive them
+    for (const reference of this.scopes.references) {
+      const declared = reference.variable.ident.pos;
+      if (
+        declared >= groupStart &&
+        declared < groupEnd &&
+        reference.ident.pos >= groupEnd
+      ) {
+        return `${reference.variable.name} is declared in the repeated statements and used after them`;
+      }
+    }
+
+    // Columns are the literal slots whose values differ between cases
+    const slots = shapes[0].literals
+      .map((_, index) => index)
+      .filter((index) =>
+        shapes.some(
+          (shape) =>
+            literalValue(shape.literals[index]) !==
+            literalValue(shapes[0].literals[index]),
+        ),
+      );
+    if (slots.length === 0) {
+      return "repeated statements do not differ in any literal";
+    }
+
+    const names = this.caseNames(
+      segments,
+      start > 0 ? statements[start - 1].end : this.decl.body.lbrace,
+    );
+    const commentProblem = this.lostComments(
+      segments,
+      names.fromComments ? names.commentStart : groupStart,
+      groupEnd,
+      names.fromComments,
+    );
+    if (commentProblem) return commentProblem;
+
+    const parents = this.literalParents(first);
+    const used = this.identifiers();
+    const columns: TableTestColumn[] = [];
+    const columnNames = new Set(["name"]);
+    for (const slot of slots) {
+      const literal = shapes[0].literals[slot];
+      const parentChain = parents.get(literal);
+      if (
+        parentChain.some(
+          (parent) =>
+            (parent.kind === "ArrayType" && parent.len) ||
+            (parent.kind === "GenDecl" && parent.tok === "const"),
+        )
+      ) {
+        return `literal ${literalValue(literal)} at line ${this.file.sourceMap.line(literal.pos)} must stay a constant`;
+      }
+      const role = this.columnRole(literal, parentChain);
+      const type =
+        role.type ??
+        this.literalType(shapes.map((shape) => shape.literals[slot]));
+      if (!type) {
+        const values = shapes.map((shape) =>
+          literalValue(shape.literals[slot]),
+        );
+        return `literals ${values.join(", ")} do not share a type`;
+      }
+      let name = role.name;
+      for (let suffix = 2; columnNames.has(name); suffix++) {
+        name = `${role.name}${suffix}`;
+      }
+      columnNames.add(name);
+      columns.push({ name, type });
+    }
+
+    const tableName = ["tests", "cases", "testCases"].find(
+      (candidate) => !used.has(candidate),
+    );
+    const caseName = ["tc", "tt", "test"].find(
+      (candidate) => !used.has(candidate),
+    );
+    if (!tableName || !caseName) {
+      return "no free names for the table and loop variables";
+    }
+
+    const indent = /^[ \t]*/.exec(
+      this.file.source.slice(this.lineStart(groupStart)),
+    )[0];
+    const width = Math.max(...[...columnNames].map((name) => name.length));
+    const pad = (name: string) => name.padEnd(width + 1);
+
+    const body = this.loopBody(first, shapes[0].literals, slots, columns, {
+      caseName,
+      indent,
+    });
+    const t = this.testingT;
+    const testingType = typeString(
+      this.file,
+      this.decl.type.params.list[0].type,
+    );
+    const lines = [
+      `${tableName} := []struct {`,
+      `\t${pad("name")}string`,
+      ...columns.map((column) => `\t${pad(column.name)}${column.type}`),
+      "}{",
+      ...shapes.map((shape, index) => {
+        const cells = slots.map(
+          (slot, column) =>
+            `${columns[column].name}: ${literalValue(shape.literals[slot])}`,
+        );
+        const name = `name: ${JSON.stringify(names.values[index])}`;
+        return `\t{${[name, ...cells].join(", ")}},`;
+      }),
+      "}",
+      `for _, ${caseName} := range ${tableName} {`,
+      `\t${t}.Run(${caseName}.name, func(${t} ${testingType}) {`,
+    ].map((line) => `${indent}${line}`);
+
+    const replaceFrom = this.lineStart(
+      names.fromComments ? names.commentStart : groupStart,
+    );
+    const newText = [
+      ...lines,
+      body,
+      `${indent}\t})`,
+      `${indent}}`,
+    ].join("\n");
+
+    return {
+      edit: { start: replaceFrom, end: groupEnd, newText },
+      conversion: {
+        test: this.decl.name.name,
+        line: this.file.sourceMap.line(this.decl.pos),
+        cases: count,
+        columns,
+      },
+    };
+  }
+
+  private isRun(call: CallExpr): boolean {
+    return (
+      call.fun.kind === "SelectorExpr" &&
+      call.fun.sel.name === "Run" &&
+      rootIdent(call.fun) === this.testingT
+    );
+  }
+
+  private identifiers(): Set<string> {
+    const names = new Set<string>();
+    inspect(this.decl, (node) => {
+      if (node.kind === "Ident") names.add(node.name);
+      return true;
+    });
+    return names;
+  }
+
+  /**
+   * Case names come from a comment above every repetition when each has
+   * one, otherwise from the text of the call under test
+   */
+  private caseNames(
+    segments: Stmt[][],
+    groupAfter: number,
+  ): {
+    values: string[];
+    fromComments: boolean;
+    commentStart: number;
+  } {
+    const { sourceMap } = this.file;
+    const leading = segments.map((segment, index) => {
+      const line = sourceMap.line(segment[0].pos);
+      const previousEnd =
+        index === 0 ? groupAfter : segments[index - 1].at(-1).end;
+      return this.file.comments.find(
+        (group) =>
+          group.pos > previousEnd &&
+          sourceMap.line(group.end) === line - 1,
+      );
+    });
+
+    let values: string[];
+    const fromComments = leading.every(Boolean);
+    if (fromComments) {
+      values = leading.map((group) => group.text.trim().replace(/\s+/g, " "));
+    } else {
+      values = segments.map((segment) =>
+        this.text(this.callUnderTest(segment)).replace(/\s+/g, " "),
+      );
+    }
+
+    const seen = new Map<string, number>();
+    values = values.map((value) => {
+      const occurrences = (seen.get(value) ?? 0) + 1;
+      seen.set(value, occurrences);
+      return occurrences > 1 ? `${value} #${occurrences}` : value;
+    });
+    return {
+      values,
+      fromComments,
+      commentStart: fromComments ? leading[0].pos : -1,
+    };
+  }
+
+  private lostComments(
+    segments: Stmt[][],
+    start: number,
+    end: number,
+    namesFromComments: boolean,
+  ): string | undefined {
+    const [first] = segments;
+    const firstStart = first[0].pos;
+    const firstEnd = first.at(-1).end;
+    const { sourceMap } = this.file;
+    const lost = this.file.comments.find((group) => {
+      if (group.pos < start || group.pos >= end) return false;
+      // Comments inside the first repetition move into the loop body
+      if (group.pos >= firstStart && group.end <= firstEnd) return false;
+      return !(
+        namesFromComments &&
+        segments.some(
+          (segment) =>
+            sourceMap.line(group.end) === sourceMap.line(segment[0].pos) - 1,
+        )
+      );
+    });
+    return lost
+      ? `comment at line ${sourceMap.line(lost.pos)} would be lost`
+      : undefined;
+  }
+
+  private literalParents(statements: Stmt[]): Map<Literal, Node[]> {
+    const parents = new Map<Literal, Node[]>();
+    for (const statement of statements) {
+      inspect(statement, (node, chain) => {
+        const literal = asLiteral(node);
+        if (literal) {
+          // Innermost first
+          parents.set(literal, [...chain].reverse());
+        }
+        return !literal;
+      });
+    }
+    return parents;
+  }
+
+  private columnRole(
+    literal: Literal,
+    parents: Node[],
+  ): { name: string; type?: string } {
+    // Look through negation and parentheses to the literal's real context
+    let child: Node = literal;
+    let index = 0;
+    while (
+      parents[index] &&
+      (parents[index].kind === "ParenExpr" ||
+        (parents[index].kind === "UnaryExpr" &&
+          (parents[index] as Expr & { op: string }).op === "-"))
+    ) {
+      child = parents[index++];
+    }
+    const parent = parents[index];
+
+    if (parent?.kind === "CallExpr" && parent.fun !== child) {
+      if (this.isAssertion(parent)) return { name: "message" };
+      const position = parent.args.indexOf(child as Expr);
+      const signature = this.signatureOf(parent);
+      const params = signature?.params ?? [];
+      const last = params.at(-1);
+      const param =
+        position < params.length
+          ? params[position]
+          : last?.variadic
+            ? last
+            : undefined;
+      const name =
+        param?.name && param.name !== "_" ? param.name : `arg${position + 1}`;
+      return { name, type: param?.type };
+    }
+
+    if (
+      parent?.kind === "BinaryExpr" &&
+      ["==", "!=", "<", "<=", ">", ">="].includes(parent.op)
+    ) {
+      const other = parent.x === child ? parent.y : parent.x;
+      return { name: "want", type: this.typeOfOperand(other) };
+    }
+
+    return { name: "value" };
+  }
+
+  private typeOfOperand(expr: Expr): string | undefined {
+    const value =
+      expr.kind === "Ident" ? this.scopes.resolved.get(expr)?.init : undefined;
+    const call = value?.expr ?? expr;
+    if (call.kind === "CallExpr") {
+      const results = this.signatureOf(call)?.results;
+      const result = results?.[value?.count === 1 ? 0 : (value?.index ?? 0)];
+      if (result) return result.type;
+    }
+    return this.types.typeOf(expr);
+  }
+
+  private literalType(literals: Literal[]): string | undefined {
+    const kinds = new Set(literals.map((literal) => basicLit(literal).litKind));
+    if (kinds.size === 1) return LITERAL_TYPES[[...kinds][0]];
+    // Untyped constants of mixed numeric kinds take the widest default type
+    if ([...kinds].every((kind) => kind === "int" || kind === "float")) {
+      return "float64";
+    }
+    if ([...kinds].every((kind) => kind === "int" || kind === "char")) {
+      return "rune";
+    }
+    return undefined;
+  }
+
+  private loopBody(
+    statements: Stmt[],
+    literals: Literal[],
+    slots: number[],
+    columns: TableTestColumn[],
+    options: { caseName: string; indent: string },
+  ): string {
+    const start = statements[0].pos;
+    const end = statements.at(-1).end;
+    const edits = slots.map((slot, column) => ({
+      start: literals[slot].pos - start,
+      end: literals[slot].end - start,
+      newText: `${options.caseName}.${columns[column].name}`,
+    }));
+    const text = applyEdits(this.file.source.slice(start, end), edits);
+    const target = `${options.indent}\t\t`;
+    return text
+      .split("\n")
+      .map((line, index) => {
+        if (index === 0) return `${target}${line}`;
+        if (line.trim() === "") return "";
+        return line.startsWith(options.indent)
+          ? `${target}${line.slice(options.indent.length)}`
+          : line;
+      })
+      .join("\n");
+  }
+}
+
+/**
+ * Convert repeated call-and-assert statements in the tests of a `_test.go`
+ * file into table-driven subtests. Tests that do not match the pattern are
+ * left untouched and reported with the reason.
+ */
+export function convertToTableTests(
+  file: GoFile,
+  options: TableTestOptions = {},
+): TableTestResult {
+  if (!file.filePath.endsWith("_test.go")) {
+    throw new GoRefactorError(`${file.filePath} is not a _test.go file`);
+  }
+  const signatures = functionSignatures([
+    ...(options.packageFiles ?? []),
+    file,
+  ]);
+
+  const edits: TextEdit[] = [];
+  const converted: TableTestConversion[] = [];
+  const skipped: TableTestSkip[] = [];
+
+  for (const decl of file.decls) {
+    if (
+      decl.kind !== "FuncDecl" ||
+      decl.recv ||
+      !decl.body ||
+      !TEST_FUNCTION.test(decl.name.name)
+    ) {
+      continue;
+    }
+    if (options.tests && !options.tests.includes(decl.name.name)) continue;
+
+    const skip = (reason: string) =>
+      skipped.push({
+        test: decl.name.name,
+        line: file.sourceMap.line(decl.pos),
+        reason,
+      });
+
+    const params = decl.type.params.list;
+    const testingT = params.length === 1 ? params[0].names[0]?.name : undefined;
+    if (!testingT || testingT === "_") {
+      skip("does not name its testing parameter");
+      continue;
+    }
+
+    const outcome = new TableTestConverter(
+      file,
+      decl,
+      testingT,
+      signatures,
+    ).convert();
+    if (typeof outcome === "string") {
+      skip(outcome);
+    } else {
+      edits.push(outcome.edit);
+      converted.push(outcome.conversion);
+    }
+  }
+
+  return { ...refactorResult(file, edits), converted, skipped };
+}
@@ -0,0 +1,384 @@
+import * as path from "path";
+import { GoFile, Node } from "./ast.js";
+import { GO_ANALYZER_VERSION } from "./cache.js";
+import { annotateCallMetrics, buildGoCallGraph } from "./callgraph.js";
+import { discoverGoFiles, GoDiscoverOptions } from "./discover.js";
+import { GoFinding } from "./findings.js";
+import { goFindingSymbol } from "./fingerprint.js";
+import { goFindingJson, GoFindingJson } from "./jsonl.js";
+import {
+  annotateMaintainability,
+  GoMaintainabilityConstants,
+} from "./maintainability.js";
+import { GoOverlay, GoOverlayEntries, goOverlay } from "./overlay.js";
+import { parseGoFile } from "./parser.js";
+import { GoReferenceContext, indexGoReferences } from "./references.js";
+import { defaultGoRuleRegistry, GoRuleRegistry } from "./rules.js";
+import {
+  GO_SYMBOLS_SCHEMA_VERSION,
+  JsonFunction,
+  JsonType,
+  toGoSymbolsDocument,
+} from "./serialize.js";
+import { baseTypeName, extractGoFileSymbols } from "./symbols.js";
+
+/**
+ * Targeted Analysis
+ * =================
+ * Analyzes a list of symbols instead of a whole tree, for automation that
+ * already knows what a change touches. Targets are named like call graph
+ * nodes, `pkg.Func`, `pkg.Type` or `pkg.Type.Method`, where `pkg` is the
+ * package name; when several directories hold a package of that name, the
+ * target is prefixed with the package's directory relative to the root, as
+ * in `cmd/tool/main.Run`.
+ *
+ * Every file under the root is discovered, but a file is only parsed once
+ * its package clause places it in a target's package. Those packages are
+ * analyzed whole, since references, fan-in and most rules need every file
+ * of a package, and each target gets its symbol, its references within the
+ * package and the findings reported inside its declaration. The rest of the
+ * tree is never parsed. Targets that do not resolve reject the promise with
+ * a {@link GoTargetError} naming each of them and why, before anything is
+ * analyzed.
+ */
+
+export interface GoTargetOptions {
+  /** Which files under the root to consider */
+  discover?: Omit<GoDiscoverOptions, "overlay">;
+  /** Contents read in place of files on disk, and files added to them */
+  overlay?: GoOverlay | GoOverlayEntries;
+  /** Rules to run (default: the built-in rules) */
+  registry?: GoRuleRegistry;
+  /** Stops the analysis between packages when aborted */
+  signal?: AbortSignal;
+  /** Maintainability index constants replacing the defaults, one by one */
+  maintainability?: Partial<GoMaintainabilityConstants>;
+}
+
+export interface JsonTargetReference {
+  file: string;
+  line: number;
+  column: number;
+  role: "definition" | "use";
+  context: GoReferenceContext;
+  /** Call graph id of the function or method the reference is in */
+  enclosing: string | null;
+  ambiguous: boolean;
+}
+
+export interface GoTargetAnalysis {
+  /** The target as requested */
+  symbol: string;
+  /** `pkg.Name` or `pkg.Type.Method`, as in the call graph */
+  id: string;
+  kind: "function" | "method" | "type";
+  /** Package directory relative to the root, `.` for the root itself */
+  package: string;
+  file: string;
+  line: number;
+  /** The symbol of a function or method, with fan-in and fan-out */
+  function?: JsonFunction;
+  /** The symbol of a type */
+  type?: JsonType;
+  /** Definitions and uses within the package, in file and source order */
+  references: JsonTargetReference[];
+  /** Findings reported inside the declaration */
+  findings: GoFindingJson[];
+}
+
+export interface GoTargetsResult {
+  schema_version: number;
+  analyzer_version: string;
+  /** In the order requested */
+  targets: GoTargetAnalysis[];
+}
+
+export interface GoUnresolvedTarget {
+  symbol: string;
+  reason: string;
+}
+
+/**
+ * Error raised for targets that name no symbol
+ */
+export class GoTargetError extends Error {
+  readonly unresolved: GoUnresolvedTarget[];
+
+  constructor(unresolved: GoUnresolvedTarget[]) {
+    const count =
+      unresolved.length === 1 ? "symbol" : `${unresolved.length} symbols`;
+    const list = unresolved
+      .map(({ symbol, reason }) => `${symbol} (${reason})`)
+      .join("; ");
+    super(`Cannot resolve ${count}: ${list}`);
+    this.name = "GoTargetError";
+    this.unresolved = unresolved;
+  }
+}
+
+interface Target {
+  symbol: string;
+  directory?: string;
+  packageName: string;
+  /** `Name`, or `Type.Method` */
+  name: string;
+}
+
+interface PackageFiles {
+  directory: string;
+  packageName: string;
+  sources: { filePath: string; source: string }[];
+}
+
+interface Resolved {
+  target: Target;
+  pkg: PackageFiles;
+}
+
+function deepFreeze<T>(value: T): T {
+  if (typeof value === "object" && value !== null) {
+    Object.values(value).forEach(deepFreeze);
+    Object.freeze(value);
+  }
+  return value;
+}
+
+// A copy sharing nothing with the analyzer's own objects, frozen
+function detached<T>(value: T): T {
+  return deepFreeze(JSON.parse(JSON.stringify(value)));
+}
+
+function relative(root: string, filePath: string): string {
+  return path.relative(root, filePath).split(path.sep).join("/");
+}
+
+// The name in a file's package clause, skipping the comments before it
+function packageClause(source: string): string | undefined {
+  const comments = /^(?:\s+|\/\/[^\n]*|\/\*[^]*?\*\/)*/.exec(source)[0];
+  const clause = /^package\s+([\p{L}_][\p{L}\p{Nd}_]*)/u.exec(
+    source.slice(comments.length),
+  );
+  return clause?.[1];
+}
+
+function parseTarget(symbol: string): Target | undefined {
+  const slash = symbol.lastIndexOf("/");
+  const parts = symbol.slice(slash + 1).split(".");
+  const ident = /^[\p{L}_][\p{L}\p{Nd}_]*$/u;
+  if (parts.length < 2 || parts.length > 3) return undefined;
+  if (!parts.every((part) => ident.test(part))) return undefined;
+  return {
+    symbol,
+    ...(slash >= 0 && { directory: symbol.slice(0, slash) }),
+    packageName: parts[0],
+    name: parts.slice(1).join("."),
+  };
+}
+
+// The declaration of a target in its package's files
+function declarationOf(
+  files: GoFile[],
+  name: string,
+): { file: GoFile; node: Node; kind: GoTargetAnalysis["kind"] } | undefined {
+  const [first, member] = name.split(".");
+  for (const file of files) {
+    for (const decl of file.decls) {
+      if (decl.kind === "FuncDecl") {
+        const recv = decl.recv?.list[0];
+        const owner = recv && baseTypeName(recv.type).name;
+        if (member === undefined && !recv && decl.name.name === first) {
+          return { file, node: decl, kind: "function" };
+        }
+        if (owner === first && decl.name.name === member) {
+          return { file, node: decl, kind: "method" };
+        }
+      } else if (decl.tok === "type" && member === undefined) {
+        const spec = decl.specs.find(
+          (spec) => spec.kind === "TypeSpec" && spec.name.name === first,
+        );
+        if (spec) return { file, node: spec, kind: "type" };
+      }
+    }
+  }
+  return undefined;
+}
+
+/**
+ * Analyze chosen symbols of the Go code under a root: each one's symbol,
+ * references and findings, loading only the packages holding them
+ */
+export async function analyzeGoTargets(
+  root: string,
+  symbols: string[],
+  options: GoTargetOptions = {},
+): Promise<GoTargetsResult> {
+  const { signal } = options;
+  const overlay = goOverlay(options.overlay);
+  const targets = symbols.map((symbol) => ({
+    symbol,
+    target: parseTarget(symbol),
+  }));
+  const unresolved: GoUnresolvedTarget[] = targets
+    .filter(({ target }) => !target)
+    .map(({ symbol }) => ({
+      symbol,
+      reason: "not pkg.Name or pkg.Type.Method",
+    }));
+  const wanted = new Set(
+    targets.flatMap(({ target }) => (target ? [target.packageName] : [])),
+  );
+
+  // Only files of a wanted package name are kept, by directory and package
+  const byPackage = new Map<string, PackageFiles>();
+  const filePaths = await discoverGoFiles(root, {
+    ...options.discover,
+    overlay,
+  });
+  for (const filePath of filePaths) {
+    signal?.throwIfAborted();
+    const source = await overlay.readFile(filePath);
+    const packageName =
+      packageClause(source) ?? parseGoFile(source, filePath).packageName.name;
+    if (!wanted.has(packageName)) continue;
+    const directory = relative(root, path.dirname(filePath)) || ".";
+    const key = `${directory}\0${packageName}`;
+    if (!byPackage.has(key)) {
+      byPackage.set(key, { directory, packageName, sources: [] });
+    }
+    byPackage.get(key).sources.push({ filePath, source });
+  }
+
+  const resolved: Resolved[] = [];
+  for (const { symbol, target } of targets) {
+    if (!target) continue;
+    const candidates = [...byPackage.values()].filter(
+      (pkg) =>
+        pkg.packageName === target.packageName &&
+        (target.directory === undefined || pkg.directory === target.directory),
+    );
+    if (candidates.length === 0) {
+      const where = target.directory ?? "the root";
+      unresolved.push({
+        symbol,
+        reason: `no package ${target.packageName} under ${where}`,
+      });
+    } else if (candidates.length > 1) {
+      const directories = candidates.map((pkg) => pkg.directory).join(", ");
+      unresolved.push({
+        symbol,
+        reason: `package ${target.packageName} is in ${directories}; prefix the directory, as in ${candidates[0].directory}/${target.packageName}.${target.name}`,
+      });
+    } else {
+      resolved.push({ target, pkg: candidates[0] });
+    }
+  }
+
+  // Each package holding a target is parsed once
+  const parsed = new Map<PackageFiles, GoFile[]>();
+  for (const { pkg } of resolved) {
+    if (parsed.has(pkg)) continue;
+    parsed.set(
+      pkg,
+      pkg.sources.map(({ filePath, source }) => parseGoFile(source, filePath)),
+    );
+  }
+  const declarations = resolved.map(({ target, pkg }) => {
+    const found = declarationOf(parsed.get(pkg), target.name);
+    if (!found) {
+      const [first, member] = target.name.split(".");
+      unresolved.push({
+        symbol: target.symbol,
+        reason:
+          member === undefined
+            ? `${target.packageName} has no function or type ${first}`
+            : `${target.packageName}.${first} has no method ${member}`,
+      });
+    }
+    return found;
+  });
+  if (unresolved.length > 0) {
+    const order = new Map(symbols.map((symbol, index) => [symbol, index]));
+    throw new GoTargetError(
+      unresolved.sort((a, b) => order.get(a.symbol) - order.get(b.symbol)),
+    );
+  }
+
+  const registry = options.registry ?? defaultGoRuleRegistry();
+  const analyses = new Map<GoFile[], ReturnType<typeof analyzePackage>>();
+  const results = resolved.map(({ target, pkg }, index) => {
+    signal?.throwIfAborted();
+    const files = parsed.get(pkg);
+    if (!analyses.has(files)) {
+      analyses.set(
+        files,
+        analyzePackage(files, registry, root, options.maintainability),
+      );
+    }
+    const analysis = analyses.get(files);
+    const { file, node, kind } = declarations[index];
+    const id = `${target.packageName}.${target.name}`;
+    const json = analysis.symbols.find(
+      (entry) => entry.path === relative(root, file.filePath),
+    );
+    const [start, end] = [
+      file.sourceMap.line(node.pos),
+      file.sourceMap.line(node.end),
+    ];
+    const inside = (finding: GoFinding) =>
+      finding.filePath === file.filePath &&
+      (kind === "type"
+        ? finding.line >= start && finding.line <= end
+        : goFindingSymbol(file, finding.line) === target.name);
+    return {
+      symbol: target.symbol,
+      id,
+      kind,
+      package: pkg.directory,
+      file: relative(root, file.filePath),
+      line: start,
+      ...(kind === "type"
+        ? { type: json.types.find((type) => type.name === target.name) }
+        : {
+            function: [...json.functions, ...json.methods].find(
+              (fn) => fn.qualified_name === target.name,
+            ),
+          }),
+      references: analysis.references(id),
+      findings: analysis.findings
+        .filter(inside)
+        .map((finding) => goFindingJson(finding, { root })),
+    };
+  });
+  return detached({
+    schema_version: GO_SYMBOLS_SCHEMA_VERSION,
+    analyzer_version: GO_ANALYZER_VERSION,
+    targets: results,
+  });
+}
+
+// Symbols, references and findings of one package
+function analyzePackage(
+  files: GoFile[],
+  registry: GoRuleRegistry,
+  root: string,
+  maintainability: Partial<GoMaintainabilityConstants> | undefined,
+) {
+  const symbols = files.map(extractGoFileSymbols);
+  annotateCallMetrics(symbols, buildGoCallGraph(files));
+  annotateMaintainability(symbols, maintainability);
+  const index = indexGoReferences(files);
+  return {
+    symbols: toGoSymbolsDocument(symbols, root).files,
+    findings: registry.run(files),
+    references: (id: string): JsonTargetReference[] =>
+      index.references(id).map((reference) => ({
+        file: relative(root, reference.filePath),
+        line: reference.line,
+        column: reference.column,
+        role: reference.role,
+        context: reference.context,
+        enclosing: reference.enclosing ?? null,
+        ambiguous: reference.ambiguous ?? false,
+      })),
+  };
+}
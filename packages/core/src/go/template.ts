@@ -0,0 +1,1283 @@
+import * as fs from "fs";
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import {
+  compareCodePoints,
+  GoFinding,
+  GoSeverity,
+  sortFindings,
+} from "./findings.js";
+import { GoRule } from "./rules.js";
+
+/**
+ * Report Templates
+ * ================
+ * Renders findings with a user-provided template, so a team can produce an
+ * HTML dashboard, a Slack message or any other format without a plugin or a
+ * change to the core. Templates are written in the syntax of Go's
+ * `text/template`, evaluated here in a sandbox that can only read the report
+ * and call the functions below: `{{.Field}}` and `{{$var.Field}}` chains,
+ * pipelines with `|`, parenthesized pipelines, `{{$x := ...}}`, `{{if}}`,
+ * `{{else if}}`, `{{range}}` with `$i, $v :=`, `{{with}}`, `{{- -}}` trim
+ * markers and `{{/* comments *\/}}`. Templates cannot include or define
+ * others.
+ *
+ * The data a template receives at `.` is, with `*` marking lists:
+ *
+ * - `Root`, `Version`
+ * - `Summary`: `Total`, `High`, `Medium`, `Low` (finding counts) and `Files`
+ * - `Findings*`: `Rule`, `Severity`, `Path`, `Line`, `Column`, `Message` and
+ *   `Fix`, which is empty when the finding has none
+ * - `Files*`: `Path`, `Package` and the file's `Findings*`
+ * - `Rules*`, one per rule with findings: `ID`, `Description`, `Severity`
+ *   (the highest of its findings) and `Count`
+ *
+ * Paths are relative to the root with `/` separators. Besides `and`, `or`,
+ * `not`, `eq`, `ne`, `lt`, `le`, `gt`, `ge`, `len`, `index`, `print`,
+ * `printf` and `println` as in Go, templates can call `html` and `json` to
+ * escape a value, `upper` and `lower`, `severity LEVEL... LIST` and
+ * `minSeverity LEVEL LIST` to filter by severity, `rule ID... LIST` to filter
+ * findings by rule, `sortBy FIELD... LIST` to sort, descending for a field
+ * written `-Field` and from high to low for `Severity`, and `first N LIST`.
+ *
+ * Templates are checked when they are loaded: syntax, functions and their
+ * argument counts, variables, and every field a template reads against the
+ * data above, so a misspelled field fails with the fields it could have
+ * meant before any analysis runs.
+ */
+
+export class GoTemplateError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "GoTemplateError";
+  }
+}
+
+export interface GoTemplateFinding {
+  Rule: string;
+  Severity: GoSeverity;
+  Path: string;
+  Line: number;
+  Column: number;
+  Message: string;
+  Fix: string;
+}
+
+export interface GoTemplateFile {
+  Path: string;
+  Package: string;
+  Findings: GoTemplateFinding[];
+}
+
+export interface GoTemplateRule {
+  ID: string;
+  Description: string;
+  Severity: GoSeverity;
+  Count: number;
+}
+
+export interface GoTemplateSummary {
+  Total: number;
+  High: number;
+  Medium: number;
+  Low: number;
+  Files: number;
+}
+
+/**
+ * The data a report template renders
+ */
+export interface GoTemplateData {
+  Root: string;
+  Version: string;
+  Summary: GoTemplateSummary;
+  Findings: GoTemplateFinding[];
+  Files: GoTemplateFile[];
+  Rules: GoTemplateRule[];
+}
+
+export interface GoTemplateDataOptions {
+  /** Directory paths are relative to (default: the cwd) */
+  root?: string;
+  /** Version of the tool, as `Version` */
+  version?: string;
+  /** Rules whose descriptions the report shows */
+  rules?: Pick<GoRule, "id" | "description">[];
+}
+
+export interface GoTemplateOptions {
+  /** Name errors are reported under (default: `template`) */
+  name?: string;
+}
+
+const RANK: Record<GoSeverity, number> = { high: 3, medium: 2, low: 1 };
+
+function list(names: string[]): string {
+  return names.length === 1
+    ? names[0]
+    : `${names.slice(0, -1).join(", ")} and ${names.at(-1)}`;
+}
+
+/**
+ * The template data of findings on the given files
+ */
+export function goTemplateData(
+  findings: GoFinding[],
+  files: GoFile[],
+  options: GoTemplateDataOptions = {},
+): GoTemplateData {
+  const root = options.root ?? process.cwd();
+  const name = (filePath: string) =>
+    path.relative(root, filePath).split(path.sep).join("/");
+  const sorted = sortFindings(findings);
+  const converted = sorted.map(
+    (finding): GoTemplateFinding => ({
+      Rule: finding.rule,
+      Severity: finding.severity,
+      Path: name(finding.filePath),
+      Line: finding.line,
+      Column: finding.column,
+      Message: finding.message,
+      Fix: finding.fix ?? "",
+    }),
+  );
+
+  const byPath = new Map<string, GoTemplateFile>();
+  for (const file of files) {
+    byPath.set(file.filePath, {
+      Path: name(file.filePath),
+      Package: file.packageName.name,
+      Findings: [],
+    });
+  }
+  const rules = new Map<string, GoTemplateRule>();
+  const descriptions = new Map(
+    (options.rules ?? []).map((rule) => [rule.id, rule.description]),
+  );
+  sorted.forEach((finding, index) => {
+    if (!byPath.has(finding.filePath)) {
+      byPath.set(finding.filePath, {
+        Path: name(finding.filePath),
+        Package: "",
+        Findings: [],
+      });
+    }
+    byPath.get(finding.filePath).Findings.push(converted[index]);
+    const rule = rules.get(finding.rule) ?? {
+      ID: finding.rule,
+      Description: descriptions.get(finding.rule) ?? "",
+      Severity: finding.severity,
+      Count: 0,
+    };
+    if (RANK[finding.severity] > RANK[rule.Severity]) {
+      rule.Severity = finding.severity;
+    }
+    rule.Count++;
+    rules.set(finding.rule, rule);
+  });
+
+  const count = (severity: GoSeverity) =>
+    findings.filter((finding) => finding.severity === severity).length;
+  return {
+    Root: root,
+    Version: options.version ?? "",
+    Summary: {
+      Total: findings.length,
+      High: count("high"),
+      Medium: count("medium"),
+      Low: count("low"),
+      Files: byPath.size,
+    },
+    Findings: converted,
+    Files: [...byPath.values()].sort((a, b) =>
+      compareCodePoints(a.Path, b.Path),
+    ),
+    Rules: [...rules.values()].sort((a, b) => compareCodePoints(a.ID, b.ID)),
+  };
+}
+
+// Static types of template values, which fields are checked against
+type TemplateType =
+  | { kind: "struct"; name: string; fields: Record<string, TemplateType> }
+  | { kind: "list"; elem: TemplateType }
+  | { kind: "string" | "number" | "bool" | "any" };
+
+const STRING: TemplateType = { kind: "string" };
+const NUMBER: TemplateType = { kind: "number" };
+const BOOL: TemplateType = { kind: "bool" };
+const ANY: TemplateType = { kind: "any" };
+
+const FINDING: TemplateType = {
+  kind: "struct",
+  name: "Finding",
+  fields: {
+    Rule: STRING,
+    Severity: STRING,
+    Path: STRING,
+    Line: NUMBER,
+    Column: NUMBER,
+    Message: STRING,
+    Fix: STRING,
+  },
+};
+
+const REPORT: TemplateType = {
+  kind: "struct",
+  name: "Report",
+  fields: {
+    Root: STRING,
+    Version: STRING,
+    Summary: {
+      kind: "struct",
+      name: "Summary",
+      fields: {
+        Total: NUMBER,
+        High: NUMBER,
+        Medium: NUMBER,
+        Low: NUMBER,
+        Files: NUMBER,
+      },
+    },
+    Findings: { kind: "list", elem: FINDING },
+    Files: {
+      kind: "list",
+      elem: {
+        kind: "struct",
+        name: "File",
+        fields: {
+          Path: STRING,
+          Package: STRING,
+          Findings: { kind: "list", elem: FINDING },
+        },
+      },
+    },
+    Rules: {
+      kind: "list",
+      elem: {
+        kind: "struct",
+        name: "Rule",
+        fields: {
+          ID: STRING,
+          Description: STRING,
+          Severity: STRING,
+          Count: NUMBER,
+        },
+      },
+    },
+  },
+};
+
+function typeName(type: TemplateType): string {
+  if (type.kind === "struct") return type.name;
+  if (type.kind === "list") return `list of ${typeName(type.elem)}`;
+  return type.kind;
+}
+
+type Token =
+  | { kind: "field"; names: string[]; pos: number }
+  | { kind: "variable"; name: string; names: string[]; pos: number }
+  | { kind: "identifier"; name: string; pos: number }
+  | { kind: "literal"; value: string | number; pos: number }
+  | { kind: "punct"; value: string; pos: number };
+
+type Item =
+  | { kind: "text"; text: string }
+  | { kind: "action"; tokens: Token[]; pos: number };
+
+type Arg =
+  | { kind: "field"; names: string[]; pos: number }
+  | { kind: "variable"; name: string; names: string[]; pos: number }
+  | { kind: "function"; name: string; pos: number }
+  | { kind: "literal"; value: string | number | boolean | null; pos: number }
+  | { kind: "pipe"; pipe: Pipe; names: string[]; pos: number };
+
+interface Command {
+  args: Arg[];
+  pos: number;
+}
+
+interface Pipe {
+  /** Variables the pipeline declares, or assigns with `=` */
+  vars: string[];
+  assign: boolean;
+  commands: Command[];
+  pos: number;
+}
+
+type TemplateNode =
+  | { kind: "text"; text: string }
+  | { kind: "action"; pipe: Pipe }
+  | {
+      kind: "if" | "range" | "with";
+      pipe: Pipe;
+      body: TemplateNode[];
+      otherwise: TemplateNode[];
+      pos: number;
+    };
+
+// Whitespace, strings, raw strings, numbers, fields, variables, identifiers
+// and punctuation
+const TOKEN = new RegExp(
+  [
+    "\\s+",
+    '"(?:[^"\\\\\\n]|\\\\.)*"',
+    "`[^`]*`",
+    "-?\\d+(?:\\.\\d+)?(?![\\w.])",
+    "(?:\\.[A-Za-z_]\\w*)+|\\.",
+    "\\$(?:[A-Za-z_]\\w*)?(?:\\.[A-Za-z_]\\w*)*",
+    "[A-Za-z_]\\w*",
+    ":=|[=(),|]",
+  ].join("|"),
+  "y",
+);
+
+const KEYWORDS = new Set(["if", "else", "end", "range", "with"]);
+
+const UNSUPPORTED = new Set([
+  "block",
+  "break",
+  "continue",
+  "define",
+  "template",
+]);
+
+// Template values are truthy as in Go: not the zero value, nor empty
+function truth(value: unknown): boolean {
+  if (Array.isArray(value)) return value.length > 0;
+  return Boolean(value);
+}
+
+function formatValue(value: unknown): string {
+  if (value === null || value === undefined) return "<no value>";
+  if (Array.isArray(value)) return `[${value.map(formatValue).join(" ")}]`;
+  if (typeof value === "object") {
+    return `{${Object.values(value).map(formatValue).join(" ")}}`;
+  }
+  return String(value);
+}
+
+const VERB = /%([-+ 0]*)(\d*)(?:\.(\d+))?([a-zA-Z%])/g;
+
+// fmt.Sprintf for the verbs templates need: %v, %s, %d, %f, %q and %t
+function sprintf(format: string, args: unknown[]): string {
+  let next = 0;
+  return format.replace(VERB, (_, flags, width, precision, verb) => {
+    if (verb === "%") return "%";
+    if (next >= args.length) return `%!${verb}(MISSING)`;
+    const arg = args[next++];
+    let text: string;
+    if (verb === "d" && typeof arg === "number") {
+      text = String(Math.trunc(arg));
+    } else if (verb === "f" && typeof arg === "number") {
+      text = arg.toFixed(precision === undefined ? 6 : Number(precision));
+    } else if (verb === "q") {
+      text = JSON.stringify(formatValue(arg));
+    } else if (["v", "s", "t"].includes(verb)) {
+      text = formatValue(arg);
+    } else {
+      return `%!${verb}(${formatValue(arg)})`;
+    }
+    const size = Number(width || 0);
+    if (flags.includes("-")) return text.padEnd(size);
+    const zero = flags.includes("0") && typeof arg === "number";
+    return text.padStart(size, zero ? "0" : " ");
+  });
+}
+
+const HTML_ESCAPES: Record<string, string> = {
+  "&": "&amp;",
+  "<": "&lt;",
+  ">": "&gt;",
+  '"': "&#34;",
+  "'": "&#39;",
+};
+
+interface Argument {
+  type: TemplateType;
+  /** Set when the argument is written as a literal */
+  literal?: unknown;
+}
+
+interface TemplateFunction {
+  /** Fewest and most arguments, a piped value included */
+  arity: [number, number];
+  /** Result type, from the argument types; throws on misuse */
+  type(args: Argument[], fail: (message: string) => never): TemplateType;
+  call(args: unknown[]): unknown;
+}
+
+// The list argument of a filter, whose elements need `field` if given
+function listOf(
+  arg: Argument,
+  fail: (message: string) => never,
+  field?: string,
+): TemplateType {
+  const { type } = arg;
+  if (type.kind === "any") return ANY;
+  if (type.kind !== "list") {
+    fail(`expected a list, got ${typeName(type)}`);
+  }
+  const { elem } = type;
+  if (field && elem.kind === "struct" && !(field in elem.fields)) {
+    fail(`${elem.name} has no field ${field}`);
+  }
+  return type;
+}
+
+function severities(args: Argument[], fail: (message: string) => never) {
+  for (const { literal } of args) {
+    if (typeof literal === "string" && !(literal in RANK)) {
+      const quoted = JSON.stringify(literal);
+      fail(`unknown severity ${quoted}; expected high, medium or low`);
+    }
+  }
+}
+
+function scalar(type: TemplateType): boolean {
+  return ["string", "number", "bool"].includes(type.kind);
+}
+
+function comparison(
+  args: Argument[],
+  fail: (message: string) => never,
+): TemplateType {
+  const known = args.filter(({ type }) => type.kind !== "any");
+  if (known.some(({ type }) => !scalar(type))) {
+    fail("only strings, numbers and booleans can be compared");
+  }
+  if (new Set(known.map(({ type }) => type.kind)).size > 1) {
+    fail("incompatible types for comparison");
+  }
+  return BOOL;
+}
+
+function ordered(op: (a: number | string, b: number | string) => boolean) {
+  return ([a, b]: unknown[]) => {
+    if (typeof a !== typeof b || !["number", "string"].includes(typeof a)) {
+      throw new GoTemplateError("incompatible types for comparison");
+    }
+    return op(a as number | string, b as number | string);
+  };
+}
+
+function fieldValue(value: unknown, field: string): unknown {
+  return (value as Record<string, unknown>)[field];
+}
+
+function sortKeys(keys: unknown[]) {
+  return keys.map((key) => {
+    const text = String(key);
+    return text.startsWith("-")
+      ? { field: text.slice(1), descending: true }
+      : { field: text, descending: false };
+  });
+}
+
+const FUNCTIONS: Record<string, TemplateFunction> = {
+  and: {
+    arity: [1, Infinity],
+    type: (args) =>
+      args.every(({ type }) => type.kind === args[0].type.kind)
+        ? args[0].type
+        : ANY,
+    call: (args) => args.find((arg) => !truth(arg)) ?? args.at(-1),
+  },
+  or: {
+    arity: [1, Infinity],
+    type: (args) =>
+      args.every(({ type }) => type.kind === args[0].type.kind)
+        ? args[0].type
+        : ANY,
+    call: (args) => args.find(truth) ?? args.at(-1),
+  },
+  not: {
+    arity: [1, 1],
+    type: () => BOOL,
+    call: ([arg]) => !truth(arg),
+  },
+  eq: {
+    arity: [2, Infinity],
+    type: comparison,
+    call: ([first, ...rest]) => rest.some((arg) => arg === first),
+  },
+  ne: {
+    arity: [2, 2],
+    type: comparison,
+    call: ([a, b]) => a !== b,
+  },
+  lt: { arity: [2, 2], type: comparison, call: ordered((a, b) => a < b) },
+  le: { arity: [2, 2], type: comparison, call: ordered((a, b) => a <= b) },
+  gt: { arity: [2, 2], type: comparison, call: ordered((a, b) => a > b) },
+  ge: { arity: [2, 2], type: comparison, call: ordered((a, b) => a >= b) },
+  len: {
+    arity: [1, 1],
+    type: ([arg], fail) => {
+      if (!["list", "string", "any"].includes(arg.type.kind)) {
+        fail(`len of ${typeName(arg.type)}`);
+      }
+      return NUMBER;
+    },
+    call: ([arg]) => (arg as unknown[] | string).length,
+  },
+  index: {
+    arity: [2, 2],
+    type: ([arg], fail) => {
+      if (arg.type.kind === "any") return ANY;
+      if (arg.type.kind !== "list") fail(`can't index ${typeName(arg.type)}`);
+      return arg.type.elem;
+    },
+    call: ([items, index]) => {
+      const at = Number(index);
+      if (!(at >= 0 && at < (items as unknown[]).length)) {
+        throw new GoTemplateError(`index out of range: ${index}`);
+      }
+      return (items as unknown[])[at];
+    },
+  },
+  print: {
+    arity: [0, Infinity],
+    type: () => STRING,
+    call: (args) => args.map(formatValue).join(""),
+  },
+  printf: {
+    arity: [1, Infinity],
+    type: ([format], fail) => {
+      if (!["string", "any"].includes(format.type.kind)) {
+        fail("the format must be a string");
+      }
+      return STRING;
+    },
+    call: ([format, ...args]) => sprintf(String(format), args),
+  },
+  println: {
+    arity: [0, Infinity],
+    type: () => STRING,
+    call: (args) => `${args.map(formatValue).join(" ")}\n`,
+  },
+  html: {
+    arity: [1, 1],
+    type: () => STRING,
+    call: ([arg]) =>
+      formatValue(arg).replace(/[&<>"']/g, (char) => HTML_ESCAPES[char]),
+  },
+  json: {
+    arity: [1, 1],
+    type: () => STRING,
+    call: ([arg]) => JSON.stringify(arg ?? null),
+  },
+  upper: {
+    arity: [1, 1],
+    type: () => STRING,
+    call: ([arg]) => formatValue(arg).toUpperCase(),
+  },
+  lower: {
+    arity: [1, 1],
+    type: () => STRING,
+    call: ([arg]) => formatValue(arg).toLowerCase(),
+  },
+  severity: {
+    arity: [2, Infinity],
+    type: (args, fail) => {
+      severities(args.slice(0, -1), fail);
+      return listOf(args.at(-1), fail, "Severity");
+    },
+    call: (args) => {
+      const levels = args.slice(0, -1);
+      return (args.at(-1) as unknown[]).filter((item) =>
+        levels.includes(fieldValue(item, "Severity")),
+      );
+    },
+  },
+  minSeverity: {
+    arity: [2, 2],
+    type: (args, fail) => {
+      severities(args.slice(0, 1), fail);
+      return listOf(args[1], fail, "Severity");
+    },
+    call: ([level, items]) =>
+      (items as unknown[]).filter(
+        (item) =>
+          RANK[fieldValue(item, "Severity") as GoSeverity] >=
+          RANK[level as GoSeverity],
+      ),
+  },
+  rule: {
+    arity: [2, Infinity],
+    type: (args, fail) => listOf(args.at(-1), fail, "Rule"),
+    call: (args) => {
+      const ids = args.slice(0, -1);
+      return (args.at(-1) as unknown[]).filter((item) =>
+        ids.includes(fieldValue(item, "Rule")),
+      );
+    },
+  },
+  sortBy: {
+    arity: [2, Infinity],
+    type: (args, fail) => {
+      const items = args.at(-1);
+      for (const { literal } of args.slice(0, -1)) {
+        if (typeof literal === "string") {
+          listOf(items, fail, sortKeys([literal])[0].field);
+        }
+      }
+      return listOf(items, fail);
+    },
+    call: (args) => {
+      const keys = sortKeys(args.slice(0, -1));
+      const value = (item: unknown, field: string) => {
+        const found = fieldValue(item, field);
+        // Severities sort from high to low, like findings
+        return field === "Severity" ? -RANK[found as GoSeverity] : found;
+      };
+      return [...(args.at(-1) as unknown[])].sort((a, b) => {
+        for (const { field, descending } of keys) {
+          const [x, y] = [value(a, field), value(b, field)];
+          const order =
+            typeof x === "number" && typeof y === "number"
+              ? x - y
+              : compareCodePoints(String(x), String(y));
+          if (order !== 0) return descending ? -order : order;
+        }
+        return 0;
+      });
+    },
+  },
+  first: {
+    arity: [2, 2],
+    type: ([count, items], fail) => {
+      if (!["number", "any"].includes(count.type.kind)) {
+        fail("the count must be a number");
+      }
+      return listOf(items, fail);
+    },
+    call: ([count, items]) => (items as unknown[]).slice(0, Number(count)),
+  },
+};
+
+/**
+ * A report template, checked against the template data when it is created
+ */
+export class GoReportTemplate {
+  readonly name: string;
+  private readonly source: string;
+  private readonly nodes: TemplateNode[];
+  private items: Item[] = [];
+  private next = 0;
+
+  constructor(source: string, options: GoTemplateOptions = {}) {
+    this.name = options.name ?? "template";
+    this.source = source;
+    this.items = this.scan();
+    this.nodes = this.parseList("top");
+    new TemplateChecker(this.fail.bind(this)).check(this.nodes);
+  }
+
+  /**
+   * The template rendered with the given data
+   */
+  render(data: GoTemplateData): string {
+    const renderer = new TemplateRenderer(data, this.fail.bind(this));
+    return renderer.render(this.nodes, data);
+  }
+
+  private fail(pos: number, message: string): never {
+    const before = this.source.slice(0, pos).split("\n");
+    const line = before.length;
+    const column = before.at(-1).length + 1;
+    throw new GoTemplateError(`${this.name}:${line}:${column}: ${message}`);
+  }
+
+  // Text and actions, with trim markers applied and comments dropped
+  private scan(): Item[] {
+    const { source } = this;
+    const items: Item[] = [];
+    let offset = 0;
+    let trim = false;
+    for (;;) {
+      const open = source.indexOf("{{", offset);
+      let text = source.slice(offset, open < 0 ? undefined : open);
+      if (trim) text = text.replace(/^\s+/, "");
+      if (open < 0) {
+        if (text) items.push({ kind: "text", text });
+        return items;
+      }
+      let start = open + 2;
+      if (/^-\s/.test(source.slice(start, start + 2))) {
+        text = text.replace(/\s+$/, "");
+        start++;
+      }
+      if (text) items.push({ kind: "text", text });
+
+      // The closing braces, skipping those in strings and comments
+      let close = start;
+      let quote = "";
+      for (; close < source.length; close++) {
+        const char = source[close];
+        if (quote) {
+          if (char === "\\" && quote === '"') close++;
+          else if (char === quote) quote = "";
+        } else if (char === '"' || char === "`") {
+          quote = char;
+        } else if (source.startsWith("/*", close)) {
+          const end = source.indexOf("*/", close + 2);
+          if (end < 0) this.fail(close, "unclosed comment");
+          close = end + 1;
+        } else if (source.startsWith("}}", close)) {
+          break;
+        }
+      }
+      if (close >= source.length) this.fail(open, "unclosed action");
+      let end = close;
+      trim = /\s-$/.test(source.slice(start, close));
+      if (trim) end--;
+      const body = source.slice(start, end).trim();
+      if (!(body.startsWith("/*") && body.endsWith("*/"))) {
+        const tokens = this.tokenize(start, end);
+        items.push({ kind: "action", tokens, pos: open });
+      }
+      offset = close + 2;
+    }
+  }
+
+  private tokenize(start: number, end: number): Token[] {
+    const tokens: Token[] = [];
+    const text = this.source.slice(0, end);
+    TOKEN.lastIndex = start;
+    while (TOKEN.lastIndex < end) {
+      const pos = TOKEN.lastIndex;
+      const match = TOKEN.exec(text);
+      if (!match) {
+        this.fail(pos, `unexpected ${JSON.stringify(text[pos])} in action`);
+      }
+      const [value] = match;
+      const first = value[0];
+      if (/\s/.test(first)) continue;
+      if (first === '"') {
+        try {
+          tokens.push({ kind: "literal", value: JSON.parse(value), pos });
+        } catch {
+          this.fail(pos, `invalid string ${value}`);
+        }
+      } else if (first === "`") {
+        tokens.push({ kind: "literal", value: value.slice(1, -1), pos });
+      } else if (/[-\d]/.test(first)) {
+        tokens.push({ kind: "literal", value: Number(value), pos });
+      } else if (first === ".") {
+        const names = value === "." ? [] : value.slice(1).split(".");
+        tokens.push({ kind: "field", names, pos });
+      } else if (first === "$") {
+        const [name, ...names] = value.split(".");
+        tokens.push({ kind: "variable", name, names, pos });
+      } else if (/\w/.test(first)) {
+        tokens.push({ kind: "identifier", name: value, pos });
+      } else {
+        tokens.push({ kind: "punct", value, pos });
+      }
+    }
+    return tokens;
+  }
+
+  private keyword(item: Item): string | undefined {
+    if (item.kind !== "action") return undefined;
+    const [first] = item.tokens;
+    return first?.kind === "identifier" && KEYWORDS.has(first.name)
+      ? first.name
+      : undefined;
+  }
+
+  // Nodes up to the {{end}} or {{else}} closing `block`, opened at `pos`,
+  // which is not consumed, or the end of the template at the top level
+  private parseList(block: string, pos = 0): TemplateNode[] {
+    const nodes: TemplateNode[] = [];
+    for (; this.next < this.items.length; this.next++) {
+      const item = this.items[this.next];
+      if (item.kind === "text") {
+        nodes.push(item);
+        continue;
+      }
+      const [first] = item.tokens;
+      if (!first) this.fail(item.pos, "missing value for command");
+      const keyword = this.keyword(item);
+      if (keyword === "end" || keyword === "else") {
+        if (block === "top") this.fail(item.pos, `unexpected {{${keyword}}}`);
+        return nodes;
+      }
+      if (keyword) {
+        nodes.push(this.parseBlock(keyword, item.tokens.slice(1), item.pos));
+        continue;
+      }
+      if (first.kind === "identifier" && UNSUPPORTED.has(first.name)) {
+        this.fail(item.pos, `{{${first.name}}} is not supported`);
+      }
+      nodes.push({
+        kind: "action",
+        pipe: this.parsePipe(item.tokens, item.pos, 1),
+      });
+    }
+    if (block !== "top") this.fail(pos, `missing {{end}} for {{${block}}}`);
+    return nodes;
+  }
+
+  private parseBlock(
+    kind: string,
+    tokens: Token[],
+    pos: number,
+  ): TemplateNode {
+    if (kind === "end" || kind === "else") {
+      this.fail(pos, `unexpected {{${kind}}}`);
+    }
+    const block = kind as "if" | "range" | "with";
+    const pipe = this.parsePipe(tokens, pos, block === "range" ? 2 : 1);
+    this.next++;
+    const body = this.parseList(block, pos);
+    let otherwise: TemplateNode[] = [];
+    const stop = this.items[this.next] as Extract<Item, { kind: "action" }>;
+    if (this.keyword(stop) === "else") {
+      const rest = stop.tokens.slice(1);
+      if (rest.length > 0) {
+        // {{else if ...}} and {{else with ...}} chain a block ending together
+        const chained = rest[0];
+        if (chained.kind !== "identifier" || chained.name !== block) {
+          const unexpected = this.describe(chained);
+          this.fail(stop.pos, `unexpected ${unexpected} after {{else}}`);
+        }
+        otherwise = [this.parseBlock(block, rest.slice(1), stop.pos)];
+        return { kind: block, pipe, body, otherwise, pos };
+      }
+      this.next++;
+      otherwise = this.parseList(block, pos);
+      const end = this.items[this.next] as Extract<Item, { kind: "action" }>;
+      if (this.keyword(end) === "else") {
+        this.fail(end.pos, "unexpected {{else}}");
+      }
+    }
+    const end = this.items[this.next] as Extract<Item, { kind: "action" }>;
+    if (end.tokens.length > 1) {
+      const unexpected = this.describe(end.tokens[1]);
+      this.fail(end.pos, `unexpected ${unexpected} in {{end}}`);
+    }
+    return { kind: block, pipe, body, otherwise, pos };
+  }
+
+  private describe(token: Token): string {
+    switch (token.kind) {
+      case "identifier":
+        return token.name;
+      case "punct":
+        return JSON.stringify(token.value);
+      case "literal":
+        return JSON.stringify(token.value);
+      case "variable":
+        return [token.name, ...token.names].join(".");
+      case "field":
+        return `.${token.names.join(".")}`;
+    }
+  }
+
+  private parsePipe(tokens: Token[], pos: number, maxVars: number): Pipe {
+    let index = 0;
+    const vars: string[] = [];
+    let assign = false;
+    // `$x :=`, `$i, $v :=` or `$x =`
+    const declared = tokens.findIndex(
+      (token) =>
+        token.kind === "punct" && (token.value === ":=" || token.value === "="),
+    );
+    if (declared > 0) {
+      const targets = tokens.slice(0, declared);
+      const names = targets.filter((_, at) => at % 2 === 0);
+      const valid =
+        names.every(
+          (token) => token.kind === "variable" && token.names.length === 0,
+        ) &&
+        targets.every(
+          (token, at) =>
+            at % 2 === 0 || (token.kind === "punct" && token.value === ","),
+        ) &&
+        targets.length % 2 === 1;
+      if (valid) {
+        if (names.length > maxVars) {
+          this.fail(targets[0].pos, "too many declarations");
+        }
+        vars.push(...names.map((token) => (token as { name: string }).name));
+        assign = (tokens[declared] as { value: string }).value === "=";
+        index = declared + 1;
+      }
+    }
+    const { pipe, next } = this.parseCommands(tokens, index, pos);
+    if (next < tokens.length) {
+      const unexpected = this.describe(tokens[next]);
+      this.fail(tokens[next].pos, `unexpected ${unexpected} in pipeline`);
+    }
+    return { ...pipe, vars, assign };
+  }
+
+  // Commands separated by `|`, up to the end of the tokens or a `)`
+  private parseCommands(
+    tokens: Token[],
+    start: number,
+    pos: number,
+  ): { pipe: Pipe; next: number } {
+    const commands: Command[] = [];
+    let index = start;
+    let args: Arg[] = [];
+    const finish = (at: number) => {
+      if (args.length === 0) this.fail(at, "missing value for command");
+      commands.push({ args, pos: args[0].pos });
+      args = [];
+    };
+    for (; index < tokens.length; index++) {
+      const token = tokens[index];
+      if (token.kind === "punct") {
+        if (token.value === ")") break;
+        if (token.value === "|") {
+          finish(token.pos);
+          continue;
+        }
+        if (token.value === "(") {
+          const inner = this.parseCommands(tokens, index + 1, token.pos);
+          const closing = tokens[inner.next];
+          if (closing?.kind !== "punct" || closing.value !== ")") {
+            this.fail(token.pos, "unclosed left paren");
+          }
+          // Fields of the result follow the paren directly: `(...).Field`
+          const field = tokens[inner.next + 1];
+          const chained =
+            field?.kind === "field" && field.pos === closing.pos + 1;
+          const names = chained ? field.names : [];
+          args.push({ kind: "pipe", pipe: inner.pipe, names, pos: token.pos });
+          index = inner.next + (chained ? 1 : 0);
+          continue;
+        }
+        this.fail(token.pos, `unexpected ${this.describe(token)} in command`);
+      }
+      if (token.kind === "identifier") {
+        if (token.name === "true" || token.name === "false") {
+          const value = token.name === "true";
+          args.push({ kind: "literal", value, pos: token.pos });
+        } else if (token.name === "nil") {
+          args.push({ kind: "literal", value: null, pos: token.pos });
+        } else if (FUNCTIONS[token.name]) {
+          args.push({ kind: "function", name: token.name, pos: token.pos });
+        } else {
+          const quoted = JSON.stringify(token.name);
+          this.fail(token.pos, `function ${quoted} not defined`);
+        }
+        continue;
+      }
+      args.push(token);
+    }
+    finish(tokens[index]?.pos ?? pos);
+    return {
+      pipe: { vars: [], assign: false, commands, pos },
+      next: index,
+    };
+  }
+}
+
+// Walks a template with the static types of its values
+class TemplateChecker {
+  private readonly scopes: Map<string, TemplateType>[] = [
+    new Map([["$", REPORT]]),
+  ];
+
+  constructor(private readonly fail: (pos: number, message: string) => never) {}
+
+  check(nodes: TemplateNode[], dot: TemplateType = REPORT): void {
+    for (const node of nodes) {
+      if (node.kind === "text") continue;
+      if (node.kind === "action") {
+        this.pipe(node.pipe, dot);
+        continue;
+      }
+      // Variables declared by a block's pipeline last until its {{end}}
+      this.scopes.push(new Map());
+      const type = this.pipe(node.pipe, dot, node.kind === "range");
+      let inner = dot;
+      if (node.kind === "with") inner = type;
+      if (node.kind === "range") {
+        if (type.kind !== "list" && type.kind !== "any") {
+          const name = typeName(type);
+          this.fail(
+            node.pipe.commands[0].pos,
+            `range can't iterate over ${name}`,
+          );
+        }
+        inner = type.kind === "list" ? type.elem : ANY;
+      }
+      this.branch(node.body, inner);
+      this.branch(node.otherwise, dot);
+      this.scopes.pop();
+    }
+  }
+
+  private branch(nodes: TemplateNode[], dot: TemplateType): void {
+    this.scopes.push(new Map());
+    this.check(nodes, dot);
+    this.scopes.pop();
+  }
+
+  private lookup(name: string, pos: number): TemplateType {
+    for (const scope of [...this.scopes].reverse()) {
+      if (scope.has(name)) return scope.get(name);
+    }
+    this.fail(pos, `undefined variable ${JSON.stringify(name)}`);
+  }
+
+  private pipe(pipe: Pipe, dot: TemplateType, range = false): TemplateType {
+    let type: TemplateType | undefined;
+    for (const command of pipe.commands) {
+      type = this.command(command, dot, type);
+    }
+    if (pipe.assign) {
+      for (const name of pipe.vars) this.lookup(name, pipe.pos);
+      return type;
+    }
+    const scope = this.scopes.at(-1);
+    if (range && pipe.vars.length === 2) {
+      scope.set(pipe.vars[0], NUMBER);
+      scope.set(pipe.vars[1], type.kind === "list" ? type.elem : ANY);
+    } else if (pipe.vars.length === 1) {
+      scope.set(pipe.vars[0], range && type.kind === "list" ? type.elem : type);
+    }
+    return type;
+  }
+
+  private command(
+    command: Command,
+    dot: TemplateType,
+    piped?: TemplateType,
+  ): TemplateType {
+    const [head, ...rest] = command.args;
+    if (head.kind !== "function") {
+      if (rest.length > 0 || piped) {
+        const name = this.text(head);
+        this.fail(command.pos, `can't give argument to non-function ${name}`);
+      }
+      if (head.kind === "literal" && head.value === null) {
+        this.fail(head.pos, "nil is not a command");
+      }
+      return this.arg(head, dot);
+    }
+    const fn = FUNCTIONS[head.name];
+    const args: Argument[] = rest.map((arg) => ({
+      type: this.arg(arg, dot),
+      ...(arg.kind === "literal" && { literal: arg.value }),
+    }));
+    if (piped) args.push({ type: piped });
+    const [min, max] = fn.arity;
+    if (args.length < min || args.length > max) {
+      const expected =
+        min === max
+          ? `${min}`
+          : max === Infinity
+            ? `at least ${min}`
+            : `${min} to ${max}`;
+      const got = args.length;
+      this.fail(
+        head.pos,
+        `wrong number of args for ${head.name}: want ${expected} got ${got}`,
+      );
+    }
+    return fn.type(args, (message) =>
+      this.fail(head.pos, `${head.name}: ${message}`),
+    );
+  }
+
+  private text(arg: Arg): string {
+    switch (arg.kind) {
+      case "field":
+        return `.${arg.names.join(".")}`;
+      case "variable":
+        return [arg.name, ...arg.names].join(".");
+      case "literal":
+        return JSON.stringify(arg.value);
+      default:
+        return "(...)";
+    }
+  }
+
+  private arg(arg: Arg, dot: TemplateType): TemplateType {
+    switch (arg.kind) {
+      case "field":
+        return this.fields(dot, arg.names, arg.pos);
+      case "variable":
+        return this.fields(this.lookup(arg.name, arg.pos), arg.names, arg.pos);
+      case "literal":
+        if (typeof arg.value === "string") return STRING;
+        if (typeof arg.value === "number") return NUMBER;
+        if (typeof arg.value === "boolean") return BOOL;
+        return ANY;
+      case "pipe":
+        return this.fields(this.pipe(arg.pipe, dot), arg.names, arg.pos);
+      case "function":
+        return this.command({ args: [arg], pos: arg.pos }, dot);
+    }
+  }
+
+  private fields(
+    type: TemplateType,
+    names: string[],
+    pos: number,
+  ): TemplateType {
+    let current = type;
+    for (const name of names) {
+      if (current.kind === "any") return ANY;
+      if (current.kind !== "struct") {
+        const owner = typeName(current);
+        this.fail(pos, `can't evaluate field ${name} in type ${owner}`);
+      }
+      if (!current.fields[name]) {
+        const known = Object.keys(current.fields).sort(compareCodePoints);
+        this.fail(
+          pos,
+          `${current.name} has no field ${name}; its fields are ${list(known)}`,
+        );
+      }
+      current = current.fields[name];
+    }
+    return current;
+  }
+}
+
+// Evaluates a checked template against its data
+class TemplateRenderer {
+  private readonly scopes: Map<string, unknown>[];
+  private readonly output: string[] = [];
+
+  constructor(
+    data: GoTemplateData,
+    private readonly fail: (pos: number, message: string) => never,
+  ) {
+    this.scopes = [new Map([["$", data]])];
+  }
+
+  render(nodes: TemplateNode[], dot: unknown): string {
+    this.walk(nodes, dot);
+    return this.output.join("");
+  }
+
+  private walk(nodes: TemplateNode[], dot: unknown): void {
+    for (const node of nodes) {
+      if (node.kind === "text") {
+        this.output.push(node.text);
+        continue;
+      }
+      if (node.kind === "action") {
+        const value = this.pipe(node.pipe, dot);
+        if (node.pipe.vars.length === 0) this.output.push(formatValue(value));
+        continue;
+      }
+      this.scopes.push(new Map());
+      if (node.kind === "range") {
+        const items = this.pipe(node.pipe, dot, true);
+        if (!Array.isArray(items)) {
+          const value = formatValue(items);
+          this.fail(
+            node.pipe.commands[0].pos,
+            `range can't iterate over ${value}`,
+          );
+        }
+        const scope = this.scopes.at(-1);
+        const [first, second] = node.pipe.vars;
+        items.forEach((item, index) => {
+          if (second) {
+            scope.set(first, index);
+            scope.set(second, item);
+          } else if (first) {
+            scope.set(first, item);
+          }
+          this.branch(node.body, item);
+        });
+        if (items.length === 0) this.branch(node.otherwise, dot);
+      } else {
+        const value = this.pipe(node.pipe, dot);
+        if (truth(value)) {
+          this.branch(node.body, node.kind === "with" ? value : dot);
+        } else {
+          this.branch(node.otherwise, dot);
+        }
+      }
+      this.scopes.pop();
+    }
+  }
+
+  private branch(nodes: TemplateNode[], dot: unknown): void {
+    this.scopes.push(new Map());
+    this.walk(nodes, dot);
+    this.scopes.pop();
+  }
+
+  private lookup(name: string): unknown {
+    for (const scope of [...this.scopes].reverse()) {
+      if (scope.has(name)) return scope.get(name);
+    }
+    return undefined;
+  }
+
+  private pipe(pipe: Pipe, dot: unknown, range = false): unknown {
+    let value: unknown;
+    let piped = false;
+    for (const command of pipe.commands) {
+      value = this.command(command, dot, piped ? [value] : []);
+      piped = true;
+    }
+    if (pipe.assign) {
+      const scope = [...this.scopes]
+        .reverse()
+        .find((candidate) => candidate.has(pipe.vars[0]));
+      scope.set(pipe.vars[0], value);
+    } else if (!range && pipe.vars.length === 1) {
+      this.scopes.at(-1).set(pipe.vars[0], value);
+    }
+    return value;
+  }
+
+  private command(command: Command, dot: unknown, piped: unknown[]): unknown {
+    const [head, ...rest] = command.args;
+    if (head.kind !== "function") return this.arg(head, dot);
+    const args = [...rest.map((arg) => this.arg(arg, dot)), ...piped];
+    try {
+      return FUNCTIONS[head.name].call(args);
+    } catch (error) {
+      if (!(error instanceof GoTemplateError)) throw error;
+      this.fail(head.pos, `${head.name}: ${error.message}`);
+    }
+  }
+
+  private arg(arg: Arg, dot: unknown): unknown {
+    switch (arg.kind) {
+      case "field":
+        return arg.names.reduce(fieldValue, dot);
+      case "variable":
+        return arg.names.reduce(fieldValue, this.lookup(arg.name));
+      case "literal":
+        return arg.value;
+      case "pipe":
+        return arg.names.reduce(fieldValue, this.pipe(arg.pipe, dot));
+      case "function":
+        return this.command({ args: [arg], pos: arg.pos }, dot, []);
+    }
+  }
+}
+
+/**
+ * Parse and check a report template
+ */
+export function parseGoReportTemplate(
+  source: string,
+  options: GoTemplateOptions = {},
+): GoReportTemplate {
+  return new GoReportTemplate(source, options);
+}
+
+/**
+ * Read, parse and check a report template file, reporting errors under its
+ * path
+ */
+export async function readGoReportTemplate(
+  filePath: string,
+): Promise<GoReportTemplate> {
+  const source = await fs.promises.readFile(filePath, "utf-8");
+  return parseGoReportTemplate(source, { name: filePath });
+}
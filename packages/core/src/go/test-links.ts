@@ -0,0 +1,225 @@
+import { CallExpr, FuncDecl, FuncLit, GoFile, Node, inspect } from "./ast.js";
+import {
+  buildGoCallGraph,
+  callGraphId,
+  GoCallGraph,
+  GoCallGraphNode,
+} from "./callgraph.js";
+import { isTestFunction } from "./deadcode.js";
+import { compareCodePoints } from "./findings.js";
+import { typeString } from "./signature.js";
+import { goFunctionSymbol } from "./symbols.js";
+
+/**
+ * Go Test Links
+ * =============
+ * Links each test, benchmark, example and fuzz target in `_test.go` files to
+ * the production functions it exercises, following the call graph from the
+ * test, so "is this function tested?" has an answer without a coverage
+ * profile. Helpers declared in test files are followed without counting as a
+ * step, so a table-driven test calling its subject through a `check` helper,
+ * or a `t.Run` closure calling it, links to it directly; the call graph
+ * already attributes calls in closures to the enclosing function and counts
+ * functions stored in table rows as called. Each test also lists its
+ * subtests, named by their literal name or, in table-driven tests, by the
+ * expression that names the case.
+ */
+
+export type GoTestKind = "test" | "benchmark" | "example" | "fuzz" | "main";
+
+export interface GoTestTarget {
+  /** Call graph id of a production function */
+  id: string;
+  /** Production calls from the test to it, 1 when the test calls it */
+  depth: number;
+}
+
+export interface GoSubtest {
+  /** `parent/child` for nested subtests, like `go test -run` takes */
+  name: string;
+  line: number;
+  /** Whether the subtest runs once per row of a table */
+  table: boolean;
+}
+
+export interface GoTestFunction {
+  /** Call graph id */
+  id: string;
+  name: string;
+  kind: GoTestKind;
+  filePath: string;
+  line: number;
+  subtests: GoSubtest[];
+  /** Production functions run by the test, nearest first */
+  targets: GoTestTarget[];
+}
+
+/** A test reaching a production function */
+export interface GoTestReach {
+  /** Name of the test, e.g. `TestParse` */
+  test: string;
+  depth: number;
+}
+
+const TEST_KINDS: [string, GoTestKind][] = [
+  ["Benchmark", "benchmark"],
+  ["Example", "example"],
+  ["Fuzz", "fuzz"],
+  ["Test", "test"],
+];
+
+/**
+ * Whether a file is compiled only by `go test`
+ */
+export function isGoTestFile(filePath: string): boolean {
+  return filePath.endsWith("_test.go");
+}
+
+function testKind(name: string): GoTestKind {
+  if (name === "TestMain") return "main";
+  return TEST_KINDS.find(([prefix]) => name.startsWith(prefix))[1];
+}
+
+/**
+ * Which tests reach which production functions, over a call graph
+ */
+export class GoTestIndex {
+  private readonly graph: GoCallGraph;
+  private readonly targetsByTest = new Map<string, GoTestTarget[]>();
+  private readonly reachesByTarget = new Map<string, GoTestReach[]>();
+
+  constructor(graph: GoCallGraph) {
+    this.graph = graph;
+    const tests = [...graph.nodes.values()]
+      .filter(isTestFunction)
+      .sort((a, b) => compareCodePoints(a.id, b.id));
+    for (const test of tests) {
+      const targets = this.walk(test);
+      this.targetsByTest.set(test.id, targets);
+      for (const target of targets) {
+        if (!this.reachesByTarget.has(target.id)) {
+          this.reachesByTarget.set(target.id, []);
+        }
+        this.reachesByTarget
+          .get(target.id)
+          .push({ test: test.symbol.qualifiedName, depth: target.depth });
+      }
+    }
+    for (const reaches of this.reachesByTarget.values()) {
+      reaches.sort(
+        (a, b) => a.depth - b.depth || compareCodePoints(a.test, b.test),
+      );
+    }
+  }
+
+  // Breadth-first over callees, where calls into test files cost nothing
+  private walk(test: GoCallGraphNode): GoTestTarget[] {
+    const depths = new Map<string, number>([[test.id, 0]]);
+    const queue: string[] = [test.id];
+    while (queue.length > 0) {
+      const current = queue.shift();
+      const depth = depths.get(current);
+      const callees = [...(this.graph.callees.get(current) ?? [])].sort();
+      for (const callee of callees) {
+        const node = this.graph.nodes.get(callee);
+        const next = isGoTestFile(node.filePath) ? depth : depth + 1;
+        if ((depths.get(callee) ?? Infinity) <= next) continue;
+        depths.set(callee, next);
+        // Free steps go first so depths stay shortest
+        if (next === depth) queue.unshift(callee);
+        else queue.push(callee);
+      }
+    }
+    return [...depths]
+      .filter(([id]) => !isGoTestFile(this.graph.nodes.get(id).filePath))
+      .map(([id, depth]) => ({ id, depth }))
+      .sort((a, b) => a.depth - b.depth || compareCodePoints(a.id, b.id));
+  }
+
+  /** Production functions a test reaches, nearest first */
+  targets(testID: string): GoTestTarget[] {
+    return this.targetsByTest.get(testID) ?? [];
+  }
+
+  /** Tests reaching a production function, nearest first */
+  testsOf(id: string): GoTestReach[] {
+    return this.reachesByTarget.get(id) ?? [];
+  }
+
+  /** The test nearest to a production function, if any reaches it */
+  nearest(id: string): GoTestReach | undefined {
+    return this.testsOf(id)[0];
+  }
+}
+
+// A call running a subtest, `t.Run(name, func(t *testing.T) { ... })`
+function subtestCallback(node: Node): FuncLit | undefined {
+  if (node.kind !== "CallExpr") return undefined;
+  const { fun, args } = node as CallExpr;
+  if (fun.kind !== "SelectorExpr" || fun.sel.name !== "Run") return undefined;
+  const callback = args[1];
+  if (args.length !== 2 || callback.kind !== "FuncLit") return undefined;
+  return callback;
+}
+
+function subtests(file: GoFile, decl: FuncDecl): GoSubtest[] {
+  const found: GoSubtest[] = [];
+  const names = new Map<Node, string>();
+  inspect(decl, (node, parents) => {
+    const callback = subtestCallback(node);
+    const params = callback?.type.params.list ?? [];
+    const testing =
+      params.length === 1 &&
+      /^\*(\w+\.)?[TB]$/.test(typeString(file, params[0].type));
+    if (!testing) return;
+    const [nameArg] = (node as CallExpr).args;
+    let own = file.source.slice(nameArg.pos, nameArg.end);
+    if (nameArg.kind === "BasicLit" && nameArg.litKind === "string") {
+      own = own.startsWith("`") ? own.slice(1, -1) : JSON.parse(own);
+    }
+    // The enclosing subtest, whose callback is among the parents
+    const outer = [...parents].reverse().find((parent) => names.has(parent));
+    const name = outer ? `${names.get(outer)}/${own}` : own;
+    names.set(callback, name);
+    const start = outer ? parents.indexOf(outer) : 0;
+    found.push({
+      name,
+      line: file.sourceMap.line(node.pos),
+      table: parents.slice(start).some((parent) => parent.kind === "RangeStmt"),
+    });
+  });
+  return found;
+}
+
+/**
+ * Tests, benchmarks, examples and fuzz targets in the analyzed `_test.go`
+ * files, with their subtests and the production functions they reach
+ */
+export function linkGoTests(
+  files: GoFile[],
+  graph: GoCallGraph = buildGoCallGraph(files),
+): GoTestFunction[] {
+  const index = new GoTestIndex(graph);
+  const tests: GoTestFunction[] = [];
+  for (const file of files) {
+    if (!isGoTestFile(file.filePath)) continue;
+    for (const decl of file.decls) {
+      if (decl.kind !== "FuncDecl") continue;
+      const symbol = goFunctionSymbol(file, decl);
+      const node = graph.nodes.get(callGraphId(file.packageName.name, symbol));
+      if (!node || !isTestFunction(node)) continue;
+      tests.push({
+        id: node.id,
+        name: symbol.name,
+        kind: testKind(symbol.name),
+        filePath: file.filePath,
+        line: symbol.startLine,
+        subtests: decl.body ? subtests(file, decl) : [],
+        targets: index.targets(node.id),
+      });
+    }
+  }
+  return tests.sort(
+    (a, b) => compareCodePoints(a.filePath, b.filePath) || a.line - b.line,
+  );
+}
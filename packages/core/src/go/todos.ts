@@ -0,0 +1,162 @@
+import { Decl, GoFile } from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoComment } from "./lexer.js";
+import { baseTypeName } from "./symbols.js";
+
+/**
+ * Go Tech Debt Markers
+ * ====================
+ * Collects TODO, FIXME, HACK and XXX comments and attributes each to the
+ * declaration around it: the function, method or type it is written in, or
+ * the declaration it documents. A marker counts when its tag starts a line
+ * of a comment, in upper case and as a whole word, so "TODOs" and prose that
+ * merely mentions a todo are not reported. The `TODO(name):` form records an
+ * author, and a `YYYY-MM-DD` date inside the parentheses, as in
+ * `TODO(ana, 2024-05-01):`, is reported separately. Comments come from the
+ * lexer, so tags inside string literals are never matched.
+ */
+
+export const DEFAULT_TODO_TAGS = ["TODO", "FIXME", "HACK", "XXX"];
+
+export interface GoTodoFinding extends GoFinding {
+  rule: "todo-comment";
+  /** The tag, e.g. `FIXME` */
+  tag: string;
+  /** Text after the tag */
+  text: string;
+  author?: string;
+  /** Date given with the author, as written */
+  date?: string;
+  /**
+   * Qualified name of the enclosing or documented function, method or type,
+   * or the first name of a var or const declaration
+   */
+  symbol?: string;
+}
+
+export interface FindTodoOptions {
+  /** Tags to look for (default: {@link DEFAULT_TODO_TAGS}) */
+  tags?: string[];
+}
+
+interface Attributed {
+  pos: number;
+  end: number;
+  name: string;
+}
+
+const DATE = /^\d{4}-\d{2}-\d{2}$/;
+
+function escape(tag: string): string {
+  return tag.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+}
+
+// The range of each declaration, doc comment included, with its name
+function declarationRanges(decls: Decl[]): Attributed[] {
+  const ranges: Attributed[] = [];
+  for (const decl of decls) {
+    if (decl.kind === "FuncDecl") {
+      const recv = decl.recv?.list[0];
+      const name = recv
+        ? `${baseTypeName(recv.type).name}.${decl.name.name}`
+        : decl.name.name;
+      ranges.push({ pos: decl.doc?.pos ?? decl.pos, end: decl.end, name });
+    } else if (decl.kind === "GenDecl" && decl.tok !== "import") {
+      decl.specs.forEach((spec, i) => {
+        if (spec.kind === "ImportSpec") return;
+        const name = spec.kind === "TypeSpec" ? spec.name : spec.names[0];
+        // The declaration's own doc comment belongs to its first spec
+        const start = i === 0 && decl.doc ? decl.doc.pos : spec.doc?.pos;
+        ranges.push({ pos: start ?? spec.pos, end: spec.end, name: name.name });
+      });
+    }
+  }
+  return ranges;
+}
+
+// Lines of a comment with the comment markers stripped, with their offsets
+function commentLines(comment: GoComment): { text: string; pos: number }[] {
+  if (!comment.isBlock) {
+    return [{ text: comment.text.slice(2), pos: comment.pos + 2 }];
+  }
+  const lines: { text: string; pos: number }[] = [];
+  let pos = comment.pos + 2;
+  for (const line of comment.text.slice(2, -2).split("\n")) {
+    // Block comments often start each line with an aligned "*"
+    const star = /^[ \t]*\*(?!\/)/.exec(line)?.[0].length ?? 0;
+    lines.push({ text: line.slice(star), pos: pos + star });
+    pos += line.length + 1;
+  }
+  return lines;
+}
+
+class TodoScanner {
+  private readonly marker: RegExp;
+
+  constructor(tags: string[]) {
+    // The tag, then an optional "(author)", then the text after any colon
+    const alternatives = tags.map(escape).join("|");
+    this.marker = new RegExp(
+      `^(\\s*)(${alternatives})(?:\\(([^)]*)\\))?(?![\\w(])\\s*:?\\s*(.*)$`,
+    );
+  }
+
+  scan(file: GoFile): GoTodoFinding[] {
+    const ranges = declarationRanges(file.decls);
+    const findings: GoTodoFinding[] = [];
+    for (const group of file.comments) {
+      const symbol = ranges.find(
+        (range) => range.pos <= group.pos && group.end <= range.end,
+      )?.name;
+      for (const comment of group.list) {
+        for (const line of commentLines(comment)) {
+          const match = this.marker.exec(line.text);
+          if (!match) continue;
+          const [, indent, tag, inner, rest] = match;
+          const pos = line.pos + indent.length;
+          findings.push(this.finding(file, pos, tag, inner, rest, symbol));
+        }
+      }
+    }
+    return findings;
+  }
+
+  private finding(
+    file: GoFile,
+    pos: number,
+    tag: string,
+    inner: string | undefined,
+    rest: string,
+    symbol: string | undefined,
+  ): GoTodoFinding {
+    const parts = (inner ?? "").split(/[\s,]+/).filter(Boolean);
+    const date = parts.find((part) => DATE.test(part));
+    const author = parts.filter((part) => part !== date).join(" ") || undefined;
+    const text = rest.trim();
+    const where = symbol ? ` in ${symbol}` : "";
+    return {
+      rule: "todo-comment",
+      severity: "low",
+      filePath: file.filePath,
+      ...file.sourceMap.position(pos),
+      message: `${tag}${where}${text ? `: ${text}` : ""}`,
+      tag,
+      text,
+      ...(author !== undefined && { author }),
+      ...(date !== undefined && { date }),
+      ...(symbol !== undefined && { symbol }),
+    };
+  }
+}
+
+/**
+ * Find TODO, FIXME, HACK and XXX comments, or the given tags, with the
+ * declaration each is written in
+ */
+export function findTodoComments(
+  files: GoFile[],
+  options: FindTodoOptions = {},
+): GoTodoFinding[] {
+  const scanner = new TodoScanner(options.tags ?? DEFAULT_TODO_TAGS);
+  return sortFindings(files.flatMap((file) => scanner.scan(file)));
+}
@@ -0,0 +1,595 @@
+import * as path from "path";
+import { TextEdit } from "../diff.js";
+import {
+  Expr,
+  FuncDecl,
+  GenDecl,
+  GoFile,
+  ImportSpec,
+  Node,
+  ValueSpec,
+  inspect,
+} from "./ast.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { importEdits, importName, importPath } from "./imports.js";
+import { indexGoPackageNames } from "./naming.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { resolveFunctionScopes } from "./scope.js";
+import { isExportedName } from "./symbols.js";
+
+/**
+ * Typed Errors
+ * ============
+ * A sentinel such as `var ErrNotFound = errors.New("not found")` can only
+ * be matched by identity and carries nothing but its message, so a library
+ * cannot later say which item was missing without breaking callers. An
+ * error type, `type NotFoundError struct{}` with an `Error` method, can
+ * grow fields and is matched with `errors.As`.
+ *
+ * {@link convertSentinelError} replaces a sentinel with such a type and
+ * rewrites every reference in the analyzed files: comparisons with `==`,
+ * `!=` or `errors.Is` become `errors.As(err, new(*NotFoundError))`, and
+ * any other use of the value becomes `&NotFoundError{}`. With `is`, the
+ * type also gets an `Is` method matching any value of the type, and
+ * `errors.Is` comparisons keep `errors.Is` with a `&NotFoundError{}`
+ * target. References from other packages are found through imports whose
+ * path ends in the sentinel's package name, as the call graph resolves
+ * them.
+ *
+ * The conversion is all or nothing: it is refused when a reference cannot
+ * be rewritten, such as a switch case, an assignment to the sentinel, its
+ * address or its use as a map key, or when the sentinel is not initialized
+ * with `errors.New` and a string literal. Code outside the analyzed files
+ * that uses an exported sentinel has to be updated by hand.
+ *
+ * {@link findSentinelErrors} suggests the conversion for exported sentinels
+ * of library packages whose references can all be rewritten.
+ */
+
+export interface GoSentinelErrorFinding extends GoFinding {
+  rule: "sentinel-error";
+  sentinel: string;
+  /** The error type {@link convertSentinelError} would declare */
+  typeName: string;
+  /** References the conversion would rewrite */
+  references: number;
+}
+
+export interface ConvertSentinelErrorOptions {
+  /** Name of the package-level sentinel variable */
+  sentinel: string;
+  /** Name of the error type (default: `ErrNotFound` becomes `NotFoundError`) */
+  typeName?: string;
+  /** Declare an `Is` method matching any value of the type (default: false) */
+  is?: boolean;
+}
+
+export interface GoSentinelReference {
+  filePath: string;
+  line: number;
+  column: number;
+  /** A comparison now matching the type, or a value now constructing it */
+  kind: "comparison" | "construction";
+}
+
+export interface ConvertSentinelErrorResult {
+  sentinel: string;
+  typeName: string;
+  /** Changed files */
+  files: GoRefactorResult[];
+  /** Rewritten references, excluding the declaration */
+  references: GoSentinelReference[];
+}
+
+interface Sentinel {
+  file: GoFile;
+  decl: GenDecl;
+  spec: ValueSpec;
+  /** The message literal, quotes included */
+  message: string;
+}
+
+interface Conversion {
+  sentinel: Sentinel;
+  typeName: string;
+  edits: Map<GoFile, TextEdit[]>;
+  references: GoSentinelReference[];
+}
+
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+function errorsImport(file: GoFile): ImportSpec | undefined {
+  return file.imports.find((spec) => importPath(spec) === "errors");
+}
+
+/**
+ * The error type named after a sentinel: `ErrNotFound` becomes
+ * `NotFoundError` and `errClosed` becomes `closedError`
+ */
+export function sentinelTypeName(sentinel: string): string {
+  const rest = sentinel.replace(/^[Ee]rr(?=\p{Lu}|\p{Nd}|_)/u, "");
+  const base = rest === sentinel || rest === "" ? sentinel : rest;
+  const first = isExportedName(sentinel)
+    ? base[0].toUpperCase()
+    : base[0].toLowerCase();
+  return `${first}${base.slice(1)}Error`;
+}
+
+// Package-level `var X = errors.New("...")` declarations, by name
+function sentinels(group: GoFile[]): Map<string, Sentinel | string> {
+  const found = new Map<string, Sentinel | string>();
+  for (const file of group) {
+    const errors = errorsImport(file);
+    for (const decl of file.decls) {
+      if (decl.kind !== "GenDecl" || decl.tok !== "var") continue;
+      for (const spec of decl.specs) {
+        if (spec.kind !== "ValueSpec") continue;
+        spec.names.forEach((name, index) => {
+          const value = spec.values[index];
+          const call = value && unparen(value);
+          const constructed =
+            errors !== undefined &&
+            call?.kind === "CallExpr" &&
+            call.fun.kind === "SelectorExpr" &&
+            call.fun.x.kind === "Ident" &&
+            call.fun.x.name === importName(errors) &&
+            call.fun.sel.name === "New";
+          if (!constructed) return;
+          const [arg] = call.args;
+          if (spec.names.length > 1) {
+            found.set(
+              name.name,
+              "it is declared together with other variables",
+            );
+          } else if (
+            call.args.length !== 1 ||
+            arg.kind !== "BasicLit" ||
+            arg.litKind !== "string"
+          ) {
+            found.set(name.name, "its message is not a string literal");
+          } else {
+            found.set(name.name, { file, decl, spec, message: arg.value });
+          }
+        });
+      }
+    }
+  }
+  return found;
+}
+
+class SentinelConverter {
+  private readonly files: GoFile[];
+  private readonly group: GoFile[];
+  private readonly parents = new Map<Node, Node>();
+
+  constructor(files: GoFile[], group: GoFile[]) {
+    this.files = files;
+    this.group = group;
+    for (const file of files) {
+      inspect(file, (node, stack) => {
+        if (stack.length > 0) this.parents.set(node, stack.at(-1));
+      });
+    }
+  }
+
+  private where(file: GoFile, node: Node): string {
+    return `${path.basename(file.filePath)}:${file.sourceMap.line(node.pos)}`;
+  }
+
+  private text(file: GoFile, node: Node): string {
+    return file.source.slice(node.pos, node.end);
+  }
+
+  private enclosingFunction(node: Node): FuncDecl | undefined {
+    let current = this.parents.get(node);
+    while (current && current.kind !== "FuncDecl") {
+      current = this.parents.get(current);
+    }
+    return current;
+  }
+
+  // How a file names the errors package at a node, or why it cannot
+  private errorsName(file: GoFile, node: Node): string {
+    const spec = errorsImport(file);
+    const name = spec ? importName(spec) : "errors";
+    if (name === "_" || name === ".") {
+      throw new GoRefactorError(
+        `errors is imported as ${name} in ${path.basename(file.filePath)}`,
+      );
+    }
+    const fn = this.enclosingFunction(node);
+    if (
+      fn &&
+      resolveFunctionScopes(fn).variables.some((v) => v.name === name)
+    ) {
+      throw new GoRefactorError(
+        `a local variable hides the ${name} package on ${this.where(file, node)}`,
+      );
+    }
+    return name;
+  }
+
+  // The edit rewriting one reference, `site` being `ErrX` or `pkg.ErrX`
+  private rewrite(
+    file: GoFile,
+    site: Expr,
+    type: string,
+    is: boolean,
+  ): { edit: TextEdit; kind: GoSentinelReference["kind"] } {
+    let node: Node = site;
+    while (this.parents.get(node)?.kind === "ParenExpr") {
+      node = this.parents.get(node);
+    }
+    const parent = this.parents.get(node);
+    const where = this.where(file, site);
+    const refuse = (reason: string): never => {
+      throw new GoRefactorError(`${reason} on ${where}`);
+    };
+    const matching = (err: Expr, negated: boolean) => {
+      const errors = this.errorsName(file, site);
+      const call = is
+        ? `${errors}.Is(${this.text(file, unparen(err))}, &${type}{})`
+        : `${errors}.As(${this.text(file, unparen(err))}, new(*${type}))`;
+      return negated ? `!${call}` : call;
+    };
+
+    if (
+      parent?.kind === "BinaryExpr" &&
+      (parent.op === "==" || parent.op === "!=")
+    ) {
+      const other = parent.x === node ? parent.y : parent.x;
+      return {
+        edit: {
+          start: parent.pos,
+          end: parent.end,
+          newText: matching(other, parent.op === "!="),
+        },
+        kind: "comparison",
+      };
+    }
+    if (
+      parent?.kind === "CallExpr" &&
+      parent.args.length === 2 &&
+      parent.fun.kind === "SelectorExpr" &&
+      parent.fun.x.kind === "Ident" &&
+      parent.fun.sel.name === "Is" &&
+      errorsImport(file) !== undefined &&
+      parent.fun.x.name === importName(errorsImport(file))
+    ) {
+      const other = parent.args[0] === node ? parent.args[1] : parent.args[0];
+      return {
+        edit: {
+          start: parent.pos,
+          end: parent.end,
+          newText: matching(other, false),
+        },
+        kind: "comparison",
+      };
+    }
+    if (parent?.kind === "CaseClause") {
+      refuse("it is a switch case, which compares by identity,");
+    }
+    if (parent?.kind === "AssignStmt" && parent.lhs.includes(node as Expr)) {
+      refuse("it is assigned to");
+    }
+    if (parent?.kind === "UnaryExpr" && parent.op === "&") {
+      refuse("its address is taken");
+    }
+    if (
+      (parent?.kind === "IndexExpr" && parent.index === node) ||
+      (parent?.kind === "KeyValueExpr" && parent.key === node)
+    ) {
+      refuse("it is a map key");
+    }
+    const value =
+      parent?.kind === "SelectorExpr" ? `(&${type}{})` : `&${type}{}`;
+    return {
+      edit: { start: site.pos, end: site.end, newText: value },
+      kind: "construction",
+    };
+  }
+
+  // The type replacing the sentinel, with its doc comment and methods
+  private typeDecl(sentinel: Sentinel, type: string, is: boolean): string {
+    const { file, decl, spec } = sentinel;
+    const doc = spec.doc ?? (decl.lparen < 0 ? decl.doc : undefined);
+    const name = spec.names[0].name;
+    const comment = doc
+      ? this.text(file, doc)
+          .split("\n")
+          .map((line) => line.trimStart())
+          .join("\n")
+          .replace(new RegExp(`\\b${name}\\b`, "g"), type)
+      : `// ${type} is the error ${sentinel.message}.`;
+    const lines = [
+      comment,
+      `type ${type} struct{}`,
+      "",
+      `func (*${type}) Error() string {`,
+      `\treturn ${sentinel.message}`,
+      "}",
+    ];
+    if (is) {
+      lines.push(
+        "",
+        `// Is reports whether target is a *${type}, so that errors.Is matches`,
+        `// every ${type} rather than only the same one.`,
+        `func (*${type}) Is(target error) bool {`,
+        `\t_, ok := target.(*${type})`,
+        "\treturn ok",
+        "}",
+      );
+    }
+    return `${lines.join("\n")}\n`;
+  }
+
+  // Edits removing the sentinel and declaring the type in its place
+  private declarationEdits(
+    sentinel: Sentinel,
+    type: string,
+    is: boolean,
+  ): TextEdit[] {
+    const { file, decl, spec } = sentinel;
+    const { sourceMap } = file;
+    const lineAfter = (offset: number) =>
+      sourceMap.lineStart(sourceMap.line(offset) + 1);
+    const text = this.typeDecl(sentinel, type, is);
+    if (decl.specs.length === 1) {
+      const start = sourceMap.lineStart(
+        sourceMap.line(decl.doc?.pos ?? spec.doc?.pos ?? decl.pos),
+      );
+      return [{ start, end: lineAfter(decl.end), newText: text }];
+    }
+    const start = sourceMap.lineStart(
+      sourceMap.line(spec.doc?.pos ?? spec.pos),
+    );
+    const after = lineAfter(decl.end);
+    return [
+      {
+        start,
+        end: lineAfter(spec.comment?.end ?? spec.end),
+        newText: "",
+      },
+      { start: after, end: after, newText: `\n${text}` },
+    ];
+  }
+
+  // Edits removing the errors import, when nothing else in the file uses it
+  private unusedErrorsImport(
+    file: GoFile,
+    initializer: Expr,
+    added: boolean,
+  ): TextEdit[] {
+    const spec = errorsImport(file);
+    if (added || !spec) return [];
+    const name = importName(spec);
+    let uses = 0;
+    for (const decl of file.decls) {
+      if (decl.kind === "GenDecl" && decl.tok === "import") continue;
+      const resolved =
+        decl.kind === "FuncDecl"
+          ? resolveFunctionScopes(decl).resolved
+          : undefined;
+      inspect(decl, (node) => {
+        if (node === initializer) return false;
+        if (
+          node.kind === "SelectorExpr" &&
+          node.x.kind === "Ident" &&
+          node.x.name === name &&
+          !resolved?.has(node.x)
+        ) {
+          uses++;
+        }
+      });
+    }
+    if (uses > 0) return [];
+    const { source, sourceMap } = file;
+    const decl = file.decls.find(
+      (candidate): candidate is GenDecl =>
+        candidate.kind === "GenDecl" && candidate.specs.includes(spec),
+    );
+    const lone = decl.specs.length === 1;
+    const [from, to] = lone
+      ? [decl.doc?.pos ?? decl.pos, decl.end]
+      : [spec.doc?.pos ?? spec.pos, spec.comment?.end ?? spec.end];
+    const start = sourceMap.lineStart(sourceMap.line(from));
+    let end = sourceMap.lineStart(sourceMap.line(to) + 1);
+    // A lone declaration takes the blank line after it along
+    if (lone && source[end] === "\n") end++;
+    return [{ start, end, newText: "" }];
+  }
+
+  // Uses of the sentinel from other packages, as `pkg.ErrX`
+  private importedUses(name: string): { file: GoFile; site: Expr }[] {
+    const packageName = this.group[0].packageName.name;
+    const uses: { file: GoFile; site: Expr }[] = [];
+    for (const file of this.files) {
+      if (this.group.includes(file)) continue;
+      const specs = file.imports.filter(
+        (spec) => importPath(spec).split("/").pop() === packageName,
+      );
+      const dotted = specs.find((spec) => spec.name?.name === ".");
+      if (dotted) {
+        throw new GoRefactorError(
+          `${path.basename(file.filePath)} imports ${importPath(dotted)} with a dot, so its uses of ${name} cannot be found`,
+        );
+      }
+      const qualifiers = new Set(specs.map(importName));
+      for (const decl of file.decls) {
+        const resolved =
+          decl.kind === "FuncDecl"
+            ? resolveFunctionScopes(decl).resolved
+            : undefined;
+        inspect(decl, (node) => {
+          if (
+            node.kind === "SelectorExpr" &&
+            node.x.kind === "Ident" &&
+            qualifiers.has(node.x.name) &&
+            !resolved?.has(node.x) &&
+            node.sel.name === name
+          ) {
+            uses.push({ file, site: node });
+          }
+        });
+      }
+    }
+    return uses;
+  }
+
+  convert(options: ConvertSentinelErrorOptions): Conversion {
+    const { sentinel: name, is = false } = options;
+    const found = sentinels(this.group).get(name);
+    if (typeof found === "string") {
+      throw new GoRefactorError(`Cannot convert ${name}: ${found}`);
+    }
+    const type = options.typeName ?? sentinelTypeName(name);
+    const fail = (reason: string): never => {
+      throw new GoRefactorError(`Cannot convert ${name}: ${reason}`);
+    };
+    if (!/^[\p{L}_][\p{L}\p{Nd}_]*$/u.test(type)) {
+      fail(`${type} is not a Go identifier`);
+    }
+    if (isExportedName(type) !== isExportedName(name)) {
+      fail(
+        `${type} must be ${isExportedName(name) ? "exported" : "unexported"} like ${name}`,
+      );
+    }
+    const names = indexGoPackageNames(this.group);
+    const declarations = names.declarations.filter(
+      (declaration) => declaration.namespace === "package",
+    );
+    if (declarations.some((declaration) => declaration.name === type)) {
+      fail(`${type} is already declared in the package`);
+    }
+    const declared = declarations.filter(
+      (declaration) => declaration.name === name,
+    );
+    if (declared.length > 1) {
+      fail(`${name} is declared more than once in the package`);
+    }
+
+    const edits = new Map<GoFile, TextEdit[]>();
+    const editsFor = (file: GoFile) => {
+      if (!edits.has(file)) edits.set(file, []);
+      return edits.get(file);
+    };
+    const references: GoSentinelReference[] = [];
+    const uses = [
+      ...names
+        .sites(declared[0])
+        .filter((site) => site.node !== found.spec.names[0])
+        .map((site) => ({ file: site.file, site: site.node as Expr })),
+      ...this.importedUses(name),
+    ];
+    for (const { file, site } of uses) {
+      const qualified =
+        site.kind === "SelectorExpr"
+          ? `${this.text(file, site.x)}.${type}`
+          : type;
+      try {
+        const { edit, kind } = this.rewrite(file, site, qualified, is);
+        editsFor(file).push(edit);
+        references.push({
+          filePath: file.filePath,
+          ...file.sourceMap.position(site.pos),
+          kind,
+        });
+      } catch (error) {
+        if (!(error instanceof GoRefactorError)) throw error;
+        fail(error.message);
+      }
+    }
+
+    const matching = new Set(
+      references
+        .filter((reference) => reference.kind === "comparison")
+        .map((reference) => reference.filePath),
+    );
+    for (const file of matching) {
+      const target = this.files.find((other) => other.filePath === file);
+      if (!errorsImport(target)) {
+        editsFor(target).push(...importEdits(target, [{ path: "errors" }]));
+      }
+    }
+    editsFor(found.file).push(
+      ...this.declarationEdits(found, type, is),
+      ...this.unusedErrorsImport(
+        found.file,
+        found.spec.values[0],
+        matching.has(found.file.filePath),
+      ),
+    );
+    return { sentinel: found, typeName: type, edits, references };
+  }
+}
+
+/**
+ * Suggest error types for the exported sentinels of library packages whose
+ * references can all be rewritten
+ */
+export function findSentinelErrors(files: GoFile[]): GoSentinelErrorFinding[] {
+  const findings: GoSentinelErrorFinding[] = [];
+  for (const group of groupGoPackages(files)) {
+    if (group[0].packageName.name === "main") continue;
+    const converter = new SentinelConverter(files, group);
+    for (const [name, found] of sentinels(group)) {
+      if (typeof found === "string" || !isExportedName(name)) continue;
+      if (found.file.filePath.endsWith("_test.go")) continue;
+      let conversion: Conversion;
+      try {
+        conversion = converter.convert({ sentinel: name });
+      } catch (error) {
+        if (!(error instanceof GoRefactorError)) throw error;
+        continue;
+      }
+      const { typeName, references } = conversion;
+      findings.push({
+        rule: "sentinel-error",
+        severity: "low",
+        filePath: found.file.filePath,
+        ...found.file.sourceMap.position(found.spec.names[0].pos),
+        message: `${name} is a sentinel error, which callers can only match by identity; a ${typeName} type could carry details and be matched with errors.As (${references.length} references to rewrite)`,
+        fix: `type ${typeName} struct{}`,
+        sentinel: name,
+        typeName,
+        references: references.length,
+      });
+    }
+  }
+  return sortFindings(findings);
+}
+
+/**
+ * Replace a sentinel error with an error type, rewriting its comparisons
+ * and values in the analyzed files
+ */
+export function convertSentinelError(
+  files: GoFile[],
+  options: ConvertSentinelErrorOptions,
+): ConvertSentinelErrorResult {
+  for (const group of groupGoPackages(files)) {
+    if (!sentinels(group).has(options.sentinel)) continue;
+    const { typeName, edits, references } = new SentinelConverter(
+      files,
+      group,
+    ).convert(options);
+    return {
+      sentinel: options.sentinel,
+      typeName,
+      files: files
+        .filter((file) => edits.has(file))
+        .map((file) => refactorResult(file, edits.get(file))),
+      references,
+    };
+  }
+  throw new GoRefactorError(
+    `${options.sentinel} is not a sentinel declared with errors.New in the analyzed files`,
+  );
+}
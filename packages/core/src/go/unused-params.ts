@@ -0,0 +1,331 @@
+import * as path from "path";
+import { TextEdit } from "../diff.js";
+import { Expr, FuncDecl, GoFile, Node, inspect } from "./ast.js";
+import { STDLIB_INTERFACE_METHODS } from "./deadcode.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { GoCallSite, GoPackageNames, indexGoPackageNames } from "./naming.js";
+import { groupGoPackages } from "./package.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import { GoVariable, resolveFunctionScopes } from "./scope.js";
+import { baseTypeName, isExportedName } from "./symbols.js";
+
+/**
+ * Unused Parameters
+ * =================
+ * Flags named parameters a function body never mentions, and removes them
+ * together with the matching argument of every call in the package. Removal
+ * is only offered when every caller is known: the function is unexported,
+ * is only ever called (never passed around as a value, which would fix its
+ * type), and for methods, no interface declares the method's name, since the
+ * signature may be what satisfies it. Parameters that have to stay are still
+ * reported, with the reason, so they can be renamed to `_`.
+ */
+
+export interface GoUnusedParameterFinding extends GoFinding {
+  rule: "unused-parameter";
+  /** `Type.Method` for methods, the bare name for functions */
+  function: string;
+  parameter: string;
+  /** Position of the parameter in the signature, from 0 */
+  index: number;
+  /** Whether {@link removeUnusedParameter} can drop the parameter */
+  removable: boolean;
+  /** Why the signature cannot change, when it cannot */
+  constraint?: string;
+}
+
+export interface RemoveParameterOptions {
+  /** The function: its name, or `Type.Method` for a method */
+  function: string;
+  parameter: string;
+}
+
+export interface RemovedArgument {
+  filePath: string;
+  line: number;
+  column: number;
+}
+
+export interface RemoveParameterResult {
+  function: string;
+  parameter: string;
+  /** Changed files; files without calls are left out */
+  files: GoRefactorResult[];
+  /** The calls that no longer pass the argument */
+  callSites: RemovedArgument[];
+}
+
+interface Parameter {
+  variable: GoVariable;
+  index: number;
+  variadic: boolean;
+}
+
+function qualifiedName(decl: FuncDecl): string {
+  const field = decl.recv?.list[0];
+  return field
+    ? `${baseTypeName(field.type).name}.${decl.name.name}`
+    : decl.name.name;
+}
+
+// Whether dropping an argument could drop a side effect
+function hasSideEffects(expr: Expr): boolean {
+  let effects = false;
+  inspect(expr, (node) => {
+    if (node.kind === "FuncLit") return false;
+    if (
+      node.kind === "CallExpr" ||
+      (node.kind === "UnaryExpr" && node.op === "<-")
+    ) {
+      effects = true;
+    }
+    return !effects;
+  });
+  return effects;
+}
+
+class PackageParameters {
+  private readonly files: GoFile[];
+  private readonly parents = new Map<Node, Node>();
+  private readonly interfaceMethods = new Set<string>();
+  private readonly names: GoPackageNames;
+
+  constructor(files: GoFile[]) {
+    this.files = files;
+    for (const file of files) {
+      inspect(file, (node, parents) => {
+        if (parents.length > 0) this.parents.set(node, parents.at(-1));
+        if (node.kind === "InterfaceType") {
+          for (const field of node.methods.list) {
+            field.names.forEach((ident) =>
+              this.interfaceMethods.add(ident.name),
+            );
+          }
+        }
+      });
+    }
+    this.names = indexGoPackageNames(files);
+  }
+
+  unusedParameters(decl: FuncDecl): Parameter[] {
+    if (!decl.body) return [];
+    const scopes = resolveFunctionScopes(decl);
+    const used = new Set(
+      scopes.references.map((reference) => reference.variable),
+    );
+    const unused: Parameter[] = [];
+    let index = 0;
+    for (const field of decl.type.params.list) {
+      const variadic = field.type.kind === "Ellipsis";
+      for (const ident of field.names.length > 0 ? field.names : [undefined]) {
+        const variable = ident && scopes.resolved.get(ident);
+        if (variable && ident.name !== "_" && !used.has(variable)) {
+          unused.push({ variable, index, variadic });
+        }
+        index++;
+      }
+    }
+    return unused;
+  }
+
+  /**
+   * The calls of a function in the package, or why its signature is fixed
+   */
+  calls(decl: FuncDecl): GoCallSite[] | string {
+    const name = decl.name.name;
+    const qualified = qualifiedName(decl);
+    if (
+      decl.recv &&
+      (this.interfaceMethods.has(name) || STDLIB_INTERFACE_METHODS.has(name))
+    ) {
+      return `${qualified} may implement an interface, which fixes its signature`;
+    }
+    if (isExportedName(name)) {
+      return `${qualified} is exported, so code outside the package may call it`;
+    }
+    if (decl.doc?.list.some((comment) => comment.text.startsWith("//export"))) {
+      return `${qualified} is exported to C`;
+    }
+    if (!decl.recv && (name === "init" || name === "main")) {
+      return `${name} is called by the runtime`;
+    }
+
+    return this.names.calls(decl, this.parents);
+  }
+
+  findings(): GoUnusedParameterFinding[] {
+    const findings: GoUnusedParameterFinding[] = [];
+    for (const file of this.files) {
+      for (const decl of file.decls) {
+        if (decl.kind !== "FuncDecl") continue;
+        const unused = this.unusedParameters(decl);
+        if (unused.length === 0) continue;
+        const calls = this.calls(decl);
+        const constraint = typeof calls === "string" ? calls : undefined;
+        const qualified = qualifiedName(decl);
+        for (const { variable, index } of unused) {
+          findings.push({
+            rule: "unused-parameter",
+            severity: "low",
+            filePath: file.filePath,
+            ...file.sourceMap.position(variable.ident.pos),
+            message: constraint
+              ? `${variable.name} is never used in ${qualified}; rename it to _ (${constraint})`
+              : `${variable.name} is never used in ${qualified}; remove it`,
+            ...(constraint && { fix: "_" }),
+            function: qualified,
+            parameter: variable.name,
+            index,
+            removable: !constraint,
+            ...(constraint && { constraint }),
+          });
+        }
+      }
+    }
+    return findings;
+  }
+}
+
+// Remove the list elements from `from` to the end, with their separators
+function removeElements(
+  elements: { pos: number; end: number }[],
+  from: number,
+  to: number,
+): TextEdit {
+  if (to < elements.length - 1) {
+    return {
+      start: elements[from].pos,
+      end: elements[to + 1].pos,
+      newText: "",
+    };
+  }
+  if (from > 0) {
+    return {
+      start: elements[from - 1].end,
+      end: elements[to].end,
+      newText: "",
+    };
+  }
+  return { start: elements[from].pos, end: elements[to].end, newText: "" };
+}
+
+/**
+ * Find named parameters that function bodies never use
+ */
+export function findUnusedParameters(
+  files: GoFile[],
+): GoUnusedParameterFinding[] {
+  return sortFindings(
+    groupGoPackages(files).flatMap((group) =>
+      new PackageParameters(group).findings(),
+    ),
+  );
+}
+
+/**
+ * Remove an unused parameter from an unexported function and the matching
+ * argument from its calls
+ */
+export function removeUnusedParameter(
+  files: GoFile[],
+  options: RemoveParameterOptions,
+): RemoveParameterResult {
+  let found: { file: GoFile; decl: FuncDecl; group: GoFile[] } | undefined;
+  for (const group of groupGoPackages(files)) {
+    for (const file of group) {
+      const decl = file.decls.find(
+        (candidate): candidate is FuncDecl =>
+          candidate.kind === "FuncDecl" &&
+          qualifiedName(candidate) === options.function,
+      );
+      if (decl) found = { file, decl, group };
+    }
+  }
+  if (!found) {
+    throw new GoRefactorError(
+      `${options.function} is not declared in the analyzed files`,
+    );
+  }
+  const { file, decl, group } = found;
+  const analyzer = new PackageParameters(group);
+  const parameter = analyzer
+    .unusedParameters(decl)
+    .find(({ variable }) => variable.name === options.parameter);
+  if (!parameter) {
+    const declared = decl.type.params.list.some((field) =>
+      field.names.some((ident) => ident.name === options.parameter),
+    );
+    throw new GoRefactorError(
+      declared
+        ? `${options.parameter} is used in ${options.function}`
+        : `${options.function} has no parameter ${options.parameter}`,
+    );
+  }
+  const calls = analyzer.calls(decl);
+  if (typeof calls === "string") {
+    throw new GoRefactorError(`Cannot remove ${options.parameter}: ${calls}`);
+  }
+
+  const edits = new Map<GoFile, TextEdit[]>([[file, []]]);
+  const editsFor = (target: GoFile) => {
+    if (!edits.has(target)) edits.set(target, []);
+    return edits.get(target);
+  };
+
+  // The parameter, or its name when it shares a type with others
+  const field = decl.type.params.list.find((candidate) =>
+    candidate.names.includes(parameter.variable.ident),
+  );
+  const fields = decl.type.params.list;
+  const fieldIndex = fields.indexOf(field);
+  editsFor(file).push(
+    field.names.length > 1
+      ? removeElements(
+          field.names,
+          field.names.indexOf(parameter.variable.ident),
+          field.names.indexOf(parameter.variable.ident),
+        )
+      : removeElements(fields, fieldIndex, fieldIndex),
+  );
+
+  const callSites: RemovedArgument[] = [];
+  const arity = fields.reduce(
+    (count, candidate) => count + Math.max(candidate.names.length, 1),
+    0,
+  );
+  for (const { file: callFile, call } of calls) {
+    const where = `${path.basename(callFile.filePath)}:${callFile.sourceMap.line(call.pos)}`;
+    if (call.args.length === 1 && arity > 1) {
+      throw new GoRefactorError(
+        `The call on ${where} passes the results of another call, which cannot be split`,
+      );
+    }
+    const { index } = parameter;
+    const last = parameter.variadic ? call.args.length - 1 : index;
+    if (index >= call.args.length) continue;
+    const removed = call.args.slice(index, last + 1);
+    if (removed.some(hasSideEffects)) {
+      throw new GoRefactorError(
+        `The argument for ${options.parameter} on ${where} may have side effects`,
+      );
+    }
+    editsFor(callFile).push(removeElements(call.args, index, last));
+    callSites.push({
+      filePath: callFile.filePath,
+      ...callFile.sourceMap.position(call.pos),
+    });
+  }
+
+  return {
+    function: options.function,
+    parameter: options.parameter,
+    files: group
+      .filter((candidate) => edits.has(candidate))
+      .map((candidate) => refactorResult(candidate, edits.get(candidate))),
+    callSites,
+  };
+}
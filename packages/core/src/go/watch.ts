@@ -0,0 +1,286 @@
+import { EventEmitter } from "events";
+import * as fs from "fs";
+import * as path from "path";
+import { GoFile } from "./ast.js";
+import { contentHash } from "./cache.js";
+import { discoverGoFiles, GoDiscoverOptions } from "./discover.js";
+import { findErrorHandlingIssues } from "./errors.js";
+import { GoFinding, sortFindings } from "./findings.js";
+import { findPanicsInsteadOfErrors } from "./panics.js";
+import { parseGoFile } from "./parser.js";
+import { findShadowedVariables } from "./shadow.js";
+
+/**
+ * Go Watch Mode
+ * =============
+ * Watches a directory and re-runs the Go checks whenever a `.go` file is
+ * saved, reporting only the findings that appeared or disappeared since the
+ * previous run. Changes are debounced so a burst of saves (autosave, a
+ * branch checkout) triggers one run, and only files whose content changed
+ * are re-parsed.
+ *
+ * Every event is a plain object with a `type`, emitted on the `event`
+ * channel, so editor integrations can forward them as JSON.
+ */
+
+export const DEFAULT_WATCH_DEBOUNCE_MS = 200;
+
+export interface GoWatchOptions extends GoDiscoverOptions {
+  /** Quiet period after the last change before re-analyzing */
+  debounceMs?: number;
+  /**
+   * Checks run over all discovered files on every analysis (default: error
+   * handling, shadowed variables and panics)
+   */
+  check?: (files: GoFile[]) => GoFinding[];
+}
+
+export type GoWatchEvent =
+  | {
+      type: "ready";
+      files: number;
+      findings: GoFinding[];
+    }
+  | {
+      type: "analysis";
+      /** Files added, changed or removed since the previous run */
+      changed: string[];
+      added: GoFinding[];
+      removed: GoFinding[];
+      /** Findings after this run */
+      total: number;
+    }
+  | {
+      type: "error";
+      filePath?: string;
+      message: string;
+    };
+
+function defaultCheck(files: GoFile[]): GoFinding[] {
+  return sortFindings([
+    ...findErrorHandlingIssues(files),
+    ...findShadowedVariables(files),
+    ...findPanicsInsteadOfErrors(files),
+  ]);
+}
+
+// Findings are matched without their position, so editing one part of a file
+// does not report every finding below it as removed and re-added
+function findingKey(finding: GoFinding): string {
+  const { rule, filePath, message, fix } = finding;
+  return JSON.stringify([rule, filePath, message, fix ?? null]);
+}
+
+// Findings of `next` with no counterpart in `previous`
+function difference(next: GoFinding[], previous: GoFinding[]): GoFinding[] {
+  const remaining = new Map<string, number>();
+  for (const finding of previous) {
+    const key = findingKey(finding);
+    remaining.set(key, (remaining.get(key) ?? 0) + 1);
+  }
+  return next.filter((finding) => {
+    const key = findingKey(finding);
+    const count = remaining.get(key) ?? 0;
+    if (count === 0) return true;
+    remaining.set(key, count - 1);
+    return false;
+  });
+}
+
+/**
+ * Render an event as text for a terminal: `+` for new findings, `-` for
+ * resolved ones
+ */
+export function formatWatchEvent(event: GoWatchEvent): string {
+  const line = (sign: string, finding: GoFinding) =>
+    `${sign} ${finding.filePath}:${finding.line}:${finding.column} ${finding.rule}: ${finding.message}`;
+  switch (event.type) {
+    case "ready":
+      return `Watching ${event.files} file(s), ${event.findings.length} finding(s)`;
+    case "analysis":
+      return [
+        `${event.changed.length} file(s) changed, ${event.total} finding(s)`,
+        ...event.added.map((finding) => line("+", finding)),
+        ...event.removed.map((finding) => line("-", finding)),
+      ].join("\n");
+    case "error":
+      return event.filePath
+        ? `error: ${event.filePath}: ${event.message}`
+        : `error: ${event.message}`;
+  }
+}
+
+/**
+ * Watches a directory and emits `event` with a {@link GoWatchEvent} after
+ * every analysis
+ */
+export class GoWatcher extends EventEmitter {
+  private readonly root: string;
+  private readonly options: GoWatchOptions;
+  private readonly parsed = new Map<string, { hash: string; file: GoFile }>();
+  // Hash of the content that last failed to parse, reported once per edit
+  private readonly broken = new Map<string, string>();
+  private findings: GoFinding[] = [];
+  private watcher: fs.FSWatcher | undefined;
+  private timer: NodeJS.Timeout | undefined;
+  private running: Promise<void> | undefined;
+  private rerun = false;
+  private stopped = false;
+
+  constructor(root: string, options: GoWatchOptions = {}) {
+    super();
+    this.root = path.resolve(root);
+    this.options = options;
+  }
+
+  private send(event: GoWatchEvent): void {
+    this.emit("event", event);
+  }
+
+  /**
+   * Run the first analysis and start watching. Resolves with the `ready`
+   * event once the initial findings are known.
+   */
+  async start(): Promise<GoWatchEvent> {
+    if (this.watcher) {
+      throw new Error("The watcher is already running");
+    }
+    this.stopped = false;
+    await this.refresh();
+    this.watcher = fs.watch(this.root, { recursive: true }, (_, fileName) => {
+      if (fileName && String(fileName).endsWith(".go")) {
+        this.changed();
+      }
+    });
+    this.watcher.on("error", (error) =>
+      this.send({ type: "error", message: error.message }),
+    );
+    const event: GoWatchEvent = {
+      type: "ready",
+      files: this.parsed.size,
+      findings: this.findings,
+    };
+    this.send(event);
+    return event;
+  }
+
+  /**
+   * Schedule an analysis after the debounce interval. Called for file system
+   * events, and usable by editors that already know when a file was saved.
+   */
+  changed(): void {
+    if (this.stopped) return;
+    clearTimeout(this.timer);
+    this.timer = setTimeout(() => {
+      this.timer = undefined;
+      this.schedule();
+    }, this.options.debounceMs ?? DEFAULT_WATCH_DEBOUNCE_MS);
+  }
+
+  private schedule(): void {
+    if (this.stopped) return;
+    if (this.running) {
+      // Saves during a run are picked up by one more run afterwards
+      this.rerun = true;
+      return;
+    }
+    this.running = this.analyze().finally(() => {
+      this.running = undefined;
+      if (this.rerun) {
+        this.rerun = false;
+        this.schedule();
+      }
+    });
+  }
+
+  // Re-read the discovered files, re-parsing those whose content changed.
+  // Returns the paths added, changed or removed.
+  private async refresh(): Promise<string[]> {
+    const paths = await discoverGoFiles(this.root, this.options);
+    const present = new Set(paths);
+    const changed: string[] = [];
+
+    for (const filePath of [...this.parsed.keys()]) {
+      if (!present.has(filePath)) {
+        this.parsed.delete(filePath);
+        this.broken.delete(filePath);
+        changed.push(filePath);
+      }
+    }
+    for (const filePath of paths) {
+      let source: string;
+      try {
+        source = await fs.promises.readFile(filePath, "utf-8");
+      } catch {
+        // Deleted between discovery and reading; the next run drops it
+        continue;
+      }
+      const hash = contentHash(source);
+      if (this.parsed.get(filePath)?.hash === hash) continue;
+      if (this.broken.get(filePath) === hash) continue;
+      try {
+        const file = parseGoFile(source, filePath);
+        this.parsed.set(filePath, { hash, file });
+        this.broken.delete(filePath);
+        changed.push(filePath);
+      } catch (error) {
+        this.broken.set(filePath, hash);
+        // Keep the last version that parsed, so a half-typed edit does not
+        // make every finding in the file disappear
+        this.send({ type: "error", filePath, message: error.message });
+      }
+    }
+
+    const check = this.options.check ?? defaultCheck;
+    if (changed.length > 0) {
+      this.findings = check([...this.parsed.values()].map(({ file }) => file));
+    }
+    return changed.sort();
+  }
+
+  private async analyze(): Promise<void> {
+    const previous = this.findings;
+    let changed: string[];
+    try {
+      changed = await this.refresh();
+    } catch (error) {
+      this.send({ type: "error", message: error.message });
+      return;
+    }
+    if (changed.length === 0 || this.stopped) return;
+    this.send({
+      type: "analysis",
+      changed,
+      added: difference(this.findings, previous),
+      removed: difference(previous, this.findings),
+      total: this.findings.length,
+    });
+  }
+
+  /**
+   * Stop watching. Pending debounced runs are dropped and a run in progress
+   * is awaited, so no event is emitted after this resolves.
+   */
+  async stop(): Promise<void> {
+    this.stopped = true;
+    clearTimeout(this.timer);
+    this.timer = undefined;
+    this.watcher?.close();
+    this.watcher = undefined;
+    await this.running;
+  }
+}
+
+/**
+ * Start watching a directory; stop the returned watcher to release it
+ */
+export async function watchGoFiles(
+  root: string,
+  options: GoWatchOptions = {},
+  listener?: (event: GoWatchEvent) => void,
+): Promise<GoWatcher> {
+  const watcher = new GoWatcher(root, options);
+  if (listener) watcher.on("event", listener);
+  await watcher.start();
+  return watcher;
+}
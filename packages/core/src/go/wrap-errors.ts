@@ -0,0 +1,300 @@
+import { TextEdit } from "../diff.js";
+import {
+  CallExpr,
+  Expr,
+  FuncDecl,
+  FuncLit,
+  FuncType,
+  GoFile,
+  Ident,
+  ImportSpec,
+  ReturnStmt,
+  inspect,
+} from "./ast.js";
+import { importEdits, importName, importPath } from "./imports.js";
+import {
+  GoRefactorError,
+  GoRefactorResult,
+  refactorResult,
+} from "./refactor.js";
+import {
+  GoFunctionScopes,
+  GoVariable,
+  resolveFunctionScopes,
+} from "./scope.js";
+
+/**
+ * Error Wrapping
+ * ==============
+ * A function handing a callee's error straight back to its caller leaves
+ * whoever reads the final message guessing which of the calls on the way
+ * failed. This transform rewrites such returns to wrap the error with a
+ * context message and `%w`, which keeps it visible to `errors.Is` and
+ * `errors.As`:
+ *
+ *     f, err := os.Open(path)
+ *     if err != nil {
+ *         return nil, err   =>   return nil, fmt.Errorf("open: %w", err)
+ *     }
+ *
+ * A return qualifies when the last result of its function is an `error` and
+ * it returns a local variable last assigned from a call. The generated
+ * message is the name of that call in lower-case words (`readConfig` gives
+ * `read config`); review it in the diff and pass better ones by line. Errors
+ * built by `errors.New`, `fmt.Errorf` or anything else that wraps them are
+ * already described and left alone, and so is everything the `Read` and
+ * `ReadAt` methods return, whose callers compare `io.EOF` with `==`. The
+ * `fmt` import is added when the file lacks it.
+ */
+
+export interface WrapErrorsOptions {
+  /** Line of a `return` to wrap (default: every qualifying one in the file) */
+  line?: number;
+  /** Context messages replacing the generated ones, by line of the return */
+  messages?: Record<number, string>;
+}
+
+export interface GoWrappedReturn {
+  line: number;
+  column: number;
+  /** The context message the error is wrapped with */
+  message: string;
+  /** The call the error came from, as written */
+  callee: string;
+}
+
+export interface WrapErrorsResult extends GoRefactorResult {
+  wrapped: GoWrappedReturn[];
+  /** Import paths added to the file */
+  importsAdded: string[];
+}
+
+interface ErrorReturn {
+  stmt: ReturnStmt;
+  /** The returned error variable */
+  ident: Ident;
+  call: CallExpr;
+  message: string;
+}
+
+// Calls building errors that already carry a message of their own, from the
+// standard library and github.com/pkg/errors
+const DESCRIBED = new Set([
+  "errors.New",
+  "errors.Join",
+  "fmt.Errorf",
+  "errors.Errorf",
+  "errors.Wrap",
+  "errors.Wrapf",
+  "errors.WithMessage",
+  "errors.WithMessagef",
+  "errors.WithStack",
+]);
+
+// Methods whose callers compare the error with `==`, as io.Reader's
+// contract for io.EOF allows
+const SENTINEL_METHODS = new Set(["Read", "ReadAt"]);
+
+// An upper-case run before a capitalized word, an upper-case run, a
+// capitalized or lower-case word, or digits
+const WORD = /[A-Z]+(?=[A-Z][a-z])|[A-Z]+\d*(?![a-z])|[A-Z]?[a-z]+\d*|\d+/g;
+
+// `(x)` reaches `x`
+function unparen(expr: Expr): Expr {
+  return expr.kind === "ParenExpr" ? unparen(expr.x) : expr;
+}
+
+function returnsError(type: FuncType): boolean {
+  const last = type.results?.list.at(-1)?.type;
+  return last?.kind === "Ident" && last.name === "error";
+}
+
+// The name a call is made by: `f` in `f()`, `Open` in `os.Open()`, and the
+// enclosing function's for a function literal called in place
+function calleeName(call: CallExpr, context: string): string | undefined {
+  const fun = unparen(call.fun);
+  if (fun.kind === "Ident") return fun.name;
+  if (fun.kind === "SelectorExpr") return fun.sel.name;
+  return fun.kind === "FuncLit" ? context : undefined;
+}
+
+function isDescribed(call: CallExpr): boolean {
+  const fun = unparen(call.fun);
+  return (
+    fun.kind === "SelectorExpr" &&
+    fun.x.kind === "Ident" &&
+    DESCRIBED.has(`${fun.x.name}.${fun.sel.name}`)
+  );
+}
+
+/**
+ * A context message from a name: its words with all but initialisms in
+ * lower case
+ */
+function contextMessage(name: string): string {
+  return (name.match(WORD) ?? [name])
+    .map((word) =>
+      word.length > 1 && word === word.toUpperCase()
+        ? word
+        : word.toLowerCase(),
+    )
+    .join(" ");
+}
+
+// A Go string literal holding a message as the text before `: %w`
+function formatLiteral(message: string): string {
+  const escaped = message
+    .replace(/\\/g, "\\\\")
+    .replace(/"/g, '\\"')
+    .replace(/\n/g, "\\n")
+    .replace(/%/g, "%%");
+  return `"${escaped}: %w"`;
+}
+
+class ErrorWrapper {
+  private readonly file: GoFile;
+  private readonly fmt?: ImportSpec;
+  /** The name the file refers to fmt by */
+  private readonly fmtName: string;
+
+  constructor(file: GoFile) {
+    this.file = file;
+    this.fmt = file.imports.find((spec) => importPath(spec) === "fmt");
+    this.fmtName = this.fmt ? importName(this.fmt) : "fmt";
+  }
+
+  private text(node: Expr): string {
+    return this.file.source.slice(node.pos, node.end);
+  }
+
+  // The call a variable was last assigned from before an offset, in the
+  // function declaring it or in a function literal within
+  private assignedCall(
+    decl: FuncDecl,
+    scopes: GoFunctionScopes,
+    variable: GoVariable,
+    before: number,
+  ): CallExpr | undefined {
+    let found: Expr | undefined;
+    inspect(decl.body, (node) => {
+      if (node.pos >= before) return false;
+      let lhs: Expr[];
+      let rhs: Expr[];
+      if (node.kind === "AssignStmt") {
+        [lhs, rhs] = [node.lhs, node.rhs];
+      } else if (node.kind === "ValueSpec") {
+        [lhs, rhs] = [node.names, node.values];
+      } else {
+        return;
+      }
+      lhs.forEach((target, index) => {
+        if (target.kind !== "Ident") return;
+        if (scopes.resolved.get(target) !== variable) return;
+        // A declaration without a value assigns the zero value
+        const value = rhs.length === lhs.length ? rhs[index] : rhs[0];
+        found = value && unparen(value);
+      });
+    });
+    return found?.kind === "CallExpr" ? found : undefined;
+  }
+
+  private returnsIn(
+    fn: FuncDecl | FuncLit,
+    decl: FuncDecl,
+    scopes: GoFunctionScopes,
+    found: ErrorReturn[],
+  ): void {
+    if (!fn.body || !returnsError(fn.type)) return;
+    inspect(fn.body, (node) => {
+      // Returns of function literals belong to them
+      if (node.kind === "FuncLit") {
+        this.returnsIn(node, decl, scopes, found);
+        return false;
+      }
+      if (node.kind !== "ReturnStmt") return;
+      const last = node.results.at(-1);
+      if (!last || last.kind !== "Ident") return;
+      const variable = scopes.resolved.get(last);
+      if (variable?.kind !== "local") return;
+      const call = this.assignedCall(decl, scopes, variable, node.pos);
+      if (!call || isDescribed(call)) return;
+      const name = calleeName(call, decl.name.name);
+      if (!name) return;
+      found.push({
+        stmt: node,
+        ident: last,
+        call,
+        message: contextMessage(name),
+      });
+    });
+  }
+
+  analyze(): ErrorReturn[] {
+    const found: ErrorReturn[] = [];
+    for (const decl of this.file.decls) {
+      if (decl.kind !== "FuncDecl" || !decl.body) continue;
+      if (decl.recv && SENTINEL_METHODS.has(decl.name.name)) continue;
+      // Wrapping needs fmt by its name
+      const scopes = resolveFunctionScopes(decl);
+      if (scopes.variables.some(({ name }) => name === this.fmtName)) {
+        continue;
+      }
+      this.returnsIn(decl, decl, scopes, found);
+    }
+    return found.sort((a, b) => a.stmt.pos - b.stmt.pos);
+  }
+
+  wrap(options: WrapErrorsOptions): WrapErrorsResult {
+    const { sourceMap } = this.file;
+    const returns = this.analyze().filter(
+      (match) =>
+        options.line === undefined ||
+        sourceMap.line(match.stmt.pos) === options.line,
+    );
+    if (options.line !== undefined && returns.length === 0) {
+      throw new GoRefactorError(
+        `Line ${options.line} has no return of an unwrapped error from a call`,
+      );
+    }
+    if (returns.length === 0) {
+      return {
+        ...refactorResult(this.file, []),
+        wrapped: [],
+        importsAdded: [],
+      };
+    }
+    const fmt = this.fmtName;
+    if (fmt === "_" || fmt === ".") {
+      throw new GoRefactorError(`fmt is imported as ${fmt}`);
+    }
+
+    const wrapped: GoWrappedReturn[] = [];
+    const edits: TextEdit[] = returns.map((match) => {
+      const position = sourceMap.position(match.stmt.pos);
+      const message = options.messages?.[position.line] ?? match.message;
+      wrapped.push({ ...position, message, callee: this.text(match.call) });
+      return {
+        start: match.ident.pos,
+        end: match.ident.end,
+        newText: `${fmt}.Errorf(${formatLiteral(message)}, ${match.ident.name})`,
+      };
+    });
+    if (!this.fmt) edits.push(...importEdits(this.file, [{ path: "fmt" }]));
+    return {
+      ...refactorResult(this.file, edits),
+      wrapped,
+      importsAdded: this.fmt ? [] : ["fmt"],
+    };
+  }
+}
+
+/**
+ * Wrap errors returned straight from a call with `fmt.Errorf` and `%w`,
+ * adding the `fmt` import when needed
+ */
+export function wrapErrors(
+  file: GoFile,
+  options: WrapErrorsOptions = {},
+): WrapErrorsResult {
+  return new ErrorWrapper(file).wrap(options);
+}
@@ -1,2 +1,7 @@
+export * from "./diff.js";
 export * from "./indexing.js";
+export * from "./journal.js";
+export * from "./plan.js";
 export * from "./type-abstraction.js";
+export * from "./go/index.js";
+export * from "./python/index.js";
@@ -3,6 +3,17 @@ import * as path from "path";
 import { glob } from "glob";
 // import * as ts from 'typescript';
 import { Project } from "ts-morph";
+import {
+  extractGoFileSymbols,
+  GoSyntaxError,
+  isExportedName,
+  parseGoFile,
+} from "./go/index.js";
+import {
+  extractPythonFileSymbols,
+  parsePython,
+  PythonSyntaxError,
+} from "./python/index.js";
 
 /**
  * Represents a file that can be refactored
@@ -29,6 +40,7 @@ export interface SymbolInfo {
     | "class"
     | "interface"
     | "type"
+    | "constant"
     | "variable"
     | "enum"
     | "namespace";
@@ -41,6 +53,25 @@ export interface SymbolInfo {
   parameters?: string[];
   returnType?: string;
   documentation?: string;
+  /** Cyclomatic complexity, for languages analyzed per symbol */
+  complexity?: number;
+}
+
+/**
+ * Per-language symbol counts, computed the same way for every analyzer
+ */
+export interface LanguageSymbolSummary {
+  files: number;
+  functions: number;
+  /** Classes, interfaces, type aliases and enums */
+  types: number;
+  /** Constants and variables */
+  values: number;
+  exported: number;
+  private: number;
+  /** Mean complexity of the functions that report one */
+  averageComplexity: number;
+  maxComplexity: number;
 }
 
 /**
@@ -351,7 +382,7 @@ export class CodebaseIndexer {
       case "javascript":
         return this.extractJSSymbols(filePath, content);
       case "python":
-        return this.extractPythonSymbols(content);
+        return this.extractPythonSymbols(content, filePath);
       case "go":
         return this.extractGoSymbols(content);
       default:
@@ -477,9 +508,34 @@ export class CodebaseIndexer {
   }
 
   /**
-   * Extract symbols from Python files (basic implementation)
+   * Extract symbols from Python files using the Python analyzer, falling back
+   * to a line-based scan when the file does not parse
+   */
+  private extractPythonSymbols(
+    content: string,
+    filePath?: string,
+  ): SymbolInfo[] {
+    try {
+      const file = extractPythonFileSymbols(parsePython(content, filePath));
+      const symbols: SymbolInfo[] = [
+        ...file.variables,
+        ...file.classes,
+        ...file.functions,
+        ...file.methods,
+      ];
+      return symbols.sort((a, b) => a.startLine - b.startLine);
+    } catch (error) {
+      if (!(error instanceof PythonSyntaxError)) {
+        throw error;
+      }
+      return this.scanPythonSymbols(content);
+    }
+  }
+
+  /**
+   * Extract symbols from Python files (basic line-based implementation)
    */
-  private extractPythonSymbols(content: string): SymbolInfo[] {
+  private scanPythonSymbols(content: string): SymbolInfo[] {
     const symbols: SymbolInfo[] = [];
     const lines = content.split("\n");
 
@@ -523,9 +579,31 @@ export class CodebaseIndexer {
   }
 
   /**
-   * Extract symbols from Go files (basic implementation)
+   * Extract symbols from Go files using the Go parser, falling back to a
+   * line-based scan when the file does not parse
    */
   private extractGoSymbols(content: string): SymbolInfo[] {
+    try {
+      const file = extractGoFileSymbols(parseGoFile(content));
+      const symbols: SymbolInfo[] = [
+        ...file.constants,
+        ...file.types,
+        ...file.functions,
+        ...file.methods,
+      ];
+      return symbols.sort((a, b) => a.startLine - b.startLine);
+    } catch (error) {
+      if (!(error instanceof GoSyntaxError)) {
+        throw error;
+      }
+      return this.scanGoSymbols(content);
+    }
+  }
+
+  /**
+   * Extract symbols from Go files (basic line-based implementation)
+   */
+  private scanGoSymbols(content: string): SymbolInfo[] {
     const symbols: SymbolInfo[] = [];
     const lines = content.split("\n");
 
@@ -542,8 +620,8 @@ export class CodebaseIndexer {
           endLine: i + 1,
           startColumn: 0,
           endColumn: line.length,
-          isExported: funcMatch[1][0] >= "A" && funcMatch[1][0] <= "Z",
-          isPrivate: funcMatch[1][0] >= "a" && funcMatch[1][0] <= "z",
+          isExported: isExportedName(funcMatch[1]),
+          isPrivate: !isExportedName(funcMatch[1]),
         });
       }
 
@@ -557,8 +635,8 @@ export class CodebaseIndexer {
           endLine: i + 1,
           startColumn: 0,
           endColumn: line.length,
-          isExported: typeMatch[1][0] >= "A" && typeMatch[1][0] <= "Z",
-          isPrivate: typeMatch[1][0] >= "a" && typeMatch[1][0] <= "z",
+          isExported: isExportedName(typeMatch[1]),
+          isPrivate: !isExportedName(typeMatch[1]),
         });
       }
     }
@@ -716,4 +794,54 @@ export class CodebaseIndexer {
       totalSize,
     };
   }
+
+  /**
+   * Summarize symbols per language, so mixed-language codebases can be
+   * reported uniformly
+   */
+  getSymbolSummary(
+    files: RefactorableFile[],
+  ): Record<string, LanguageSymbolSummary> {
+    const summary: Record<string, LanguageSymbolSummary> = {};
+    const complexities: Record<string, number[]> = {};
+
+    for (const file of files) {
+      const entry = (summary[file.language] ??= {
+        files: 0,
+        functions: 0,
+        types: 0,
+        values: 0,
+        exported: 0,
+        private: 0,
+        averageComplexity: 0,
+        maxComplexity: 0,
+      });
+      const scores = (complexities[file.language] ??= []);
+      entry.files++;
+
+      for (const symbol of file.symbols) {
+        if (symbol.type === "function") {
+          entry.functions++;
+          if (symbol.complexity !== undefined) scores.push(symbol.complexity);
+        } else if (symbol.type === "constant" || symbol.type === "variable") {
+          entry.values++;
+        } else if (symbol.type !== "namespace") {
+          entry.types++;
+        }
+        if (symbol.isPrivate) {
+          entry.private++;
+        } else if (symbol.isExported) {
+          entry.exported++;
+        }
+      }
+    }
+
+    for (const [language, scores] of Object.entries(complexities)) {
+      if (scores.length === 0) continue;
+      summary[language].averageComplexity =
+        scores.reduce((total, score) => total + score, 0) / scores.length;
+      summary[language].maxComplexity = Math.max(...scores);
+    }
+    return summary;
+  }
 }
@@ -0,0 +1,253 @@
+import { createHash, randomBytes } from "crypto";
+import * as fs from "fs";
+import * as os from "os";
+import * as path from "path";
+import { applyPlan, ApplyPlanOptions, RefactorPlan } from "./plan.js";
+
+/**
+ * Refactor Journals
+ * =================
+ * A journal records the content of every file a transform is about to
+ * write, so the transform can be rolled back if it breaks the build, without
+ * relying on version control. Journals are JSON files in a directory under
+ * the system temp directory and survive the process that wrote them.
+ *
+ * Each entry also records a hash of the content the transform writes.
+ * Rolling back first checks every file still has that content and refuses
+ * to touch anything if one was edited since, so changes made afterwards are
+ * never overwritten. The restored contents are then staged next to their
+ * files and moved into place together, and files the transform created are
+ * removed; a restore that fails while staging changes nothing.
+ */
+
+export const REFACTOR_JOURNAL_VERSION = 1;
+
+export interface RefactorJournalOptions {
+  /** Where journals are kept (default: `refactogent/journals` in the tmpdir) */
+  directory?: string;
+}
+
+export interface RefactorJournalEntry {
+  /** Path relative to the journal root, with `/` separators */
+  path: string;
+  /** Content before the transform; null when it created the file */
+  before: string | null;
+  /** Hash of the content the transform wrote; null when it removed the file */
+  afterHash: string | null;
+}
+
+export interface RefactorJournal {
+  version: number;
+  id: string;
+  /** Absolute directory the paths are relative to */
+  rootPath: string;
+  createdAt: string;
+  entries: RefactorJournalEntry[];
+}
+
+/**
+ * A file a transform is about to write
+ */
+export interface JournaledChange {
+  /** Path relative to the root */
+  path: string;
+  /** Content it will have; null when the transform removes it */
+  content: string | null;
+}
+
+export class RefactorJournalError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "RefactorJournalError";
+  }
+}
+
+const STAGED_SUFFIX = ".refactogent-rollback";
+
+function contentHash(content: string): string {
+  return createHash("sha256").update(content).digest("hex");
+}
+
+function journalDirectory(options: RefactorJournalOptions): string {
+  return (
+    options.directory ?? path.join(os.tmpdir(), "refactogent", "journals")
+  );
+}
+
+function journalPath(id: string, options: RefactorJournalOptions): string {
+  if (!/^[\w-]+$/.test(id)) {
+    throw new RefactorJournalError(`Invalid journal ID ${id}`);
+  }
+  return path.join(journalDirectory(options), `${id}.json`);
+}
+
+async function readIfExists(filePath: string): Promise<string | null> {
+  try {
+    return await fs.promises.readFile(filePath, "utf-8");
+  } catch (error) {
+    if ((error as NodeJS.ErrnoException).code === "ENOENT") return null;
+    throw error;
+  }
+}
+
+/**
+ * Record the current content of the files a transform is about to write.
+ * The journal is on disk before this returns.
+ */
+export async function recordJournal(
+  rootPath: string,
+  changes: JournaledChange[],
+  options: RefactorJournalOptions = {},
+): Promise<RefactorJournal> {
+  const root = path.resolve(rootPath);
+  const entries: RefactorJournalEntry[] = [];
+  for (const change of changes) {
+    entries.push({
+      path: change.path.split(path.sep).join("/"),
+      before: await readIfExists(path.join(root, change.path)),
+      afterHash: change.content === null ? null : contentHash(change.content),
+    });
+  }
+  // Sortable by creation time, unique across processes
+  const id = `${Date.now().toString(36)}-${randomBytes(4).toString("hex")}`;
+  const journal: RefactorJournal = {
+    version: REFACTOR_JOURNAL_VERSION,
+    id,
+    rootPath: root,
+    createdAt: new Date().toISOString(),
+    entries,
+  };
+  const target = journalPath(id, options);
+  await fs.promises.mkdir(path.dirname(target), { recursive: true });
+  // Written under another name first, so a journal is never half there
+  await fs.promises.writeFile(
+    `${target}.tmp`,
+    JSON.stringify(journal, null, 2) + "\n",
+    "utf-8",
+  );
+  await fs.promises.rename(`${target}.tmp`, target);
+  return journal;
+}
+
+/**
+ * Read a recorded journal
+ */
+export async function readJournal(
+  id: string,
+  options: RefactorJournalOptions = {},
+): Promise<RefactorJournal> {
+  const text = await readIfExists(journalPath(id, options));
+  if (text === null) {
+    throw new RefactorJournalError(`No journal ${id}`);
+  }
+  const journal: RefactorJournal = JSON.parse(text);
+  if (journal?.version !== REFACTOR_JOURNAL_VERSION) {
+    throw new RefactorJournalError(
+      `Unsupported journal version ${journal?.version}; expected ${REFACTOR_JOURNAL_VERSION}`,
+    );
+  }
+  return journal;
+}
+
+/**
+ * The recorded journals, oldest first
+ */
+export async function listJournals(
+  options: RefactorJournalOptions = {},
+): Promise<RefactorJournal[]> {
+  let names: string[];
+  try {
+    names = await fs.promises.readdir(journalDirectory(options));
+  } catch {
+    return [];
+  }
+  const ids = names
+    .filter((name) => name.endsWith(".json"))
+    .map((name) => name.slice(0, -".json".length))
+    .sort();
+  return Promise.all(ids.map((id) => readJournal(id, options)));
+}
+
+/**
+ * Delete a journal without restoring anything
+ */
+export async function discardJournal(
+  id: string,
+  options: RefactorJournalOptions = {},
+): Promise<void> {
+  await fs.promises.rm(journalPath(id, options), { force: true });
+}
+
+/**
+ * Restore the files a journal recorded and delete the journal. Nothing is
+ * restored when any file no longer has the content the transform wrote.
+ * Returns the restored paths.
+ */
+export async function rollback(
+  journalID: string,
+  options: RefactorJournalOptions = {},
+): Promise<string[]> {
+  const journal = await readJournal(journalID, options);
+  const target = (entry: RefactorJournalEntry) =>
+    path.join(journal.rootPath, entry.path);
+
+  const edited: string[] = [];
+  for (const entry of journal.entries) {
+    const current = await readIfExists(target(entry));
+    const hash = current === null ? null : contentHash(current);
+    if (hash !== entry.afterHash) edited.push(entry.path);
+  }
+  if (edited.length > 0) {
+    throw new RefactorJournalError(
+      `Files changed since journal ${journalID} was recorded: ${edited.join(", ")}`,
+    );
+  }
+
+  const staged: string[] = [];
+  try {
+    for (const entry of journal.entries) {
+      if (entry.before === null) continue;
+      const file = `${target(entry)}${STAGED_SUFFIX}`;
+      await fs.promises.mkdir(path.dirname(file), { recursive: true });
+      await fs.promises.writeFile(file, entry.before, "utf-8");
+      staged.push(file);
+    }
+  } catch (error) {
+    await Promise.all(
+      staged.map((file) => fs.promises.rm(file, { force: true })),
+    );
+    throw error;
+  }
+  for (const entry of journal.entries) {
+    if (entry.before === null) {
+      await fs.promises.rm(target(entry), { force: true });
+    } else {
+      const file = `${target(entry)}${STAGED_SUFFIX}`;
+      await fs.promises.rename(file, target(entry));
+    }
+  }
+  await discardJournal(journalID, options);
+  return journal.entries.map(target);
+}
+
+/**
+ * Apply a plan like {@link applyPlan}, recording a journal first so it can
+ * be rolled back. The journal is discarded when the plan is refused.
+ */
+export async function applyPlanWithJournal(
+  plan: RefactorPlan,
+  rootPath: string,
+  options: ApplyPlanOptions & RefactorJournalOptions = {},
+): Promise<{ written: string[]; journal: RefactorJournal }> {
+  const journal = await recordJournal(
+    rootPath,
+    plan.files.map((file) => ({ path: file.path, content: file.content })),
+    options,
+  );
+  try {
+    return { written: await applyPlan(plan, rootPath, options), journal };
+  } catch (error) {
+    await discardJournal(journal.id, options);
+    throw error;
+  }
+}
@@ -0,0 +1,331 @@
+import { createHash } from "crypto";
+import * as fs from "fs";
+import * as path from "path";
+import { unifiedDiff } from "./diff.js";
+
+/**
+ * Refactor Plans
+ * ==============
+ * A plan is every file write a refactor would make, computed up front. It can
+ * be rendered as unified diffs for review, serialized for a later apply step,
+ * and applied exactly as reviewed: apply refuses to run if any file changed
+ * since the plan was made.
+ *
+ * Plans contain no timestamps or absolute paths, so the same inputs always
+ * produce the same plan.
+ *
+ * Each file, and the plan as a whole, carries an impact estimate: how many
+ * files and reference sites change, and which exported symbols are removed,
+ * renamed or given a different signature. A plan changing exported API is
+ * breaking, since code outside the repository may depend on it, and is only
+ * applied when that is explicitly allowed.
+ */
+
+export const REFACTOR_PLAN_VERSION = 2;
+
+/**
+ * How far a change reaches
+ */
+export interface RefactorImpact {
+  files: number;
+  /** Reference sites updated */
+  references: number;
+  /** Exported symbols removed, renamed or given a different signature */
+  exportedChanges: string[];
+  /** True when exported API changes, which may break downstream code */
+  breaking: boolean;
+}
+
+/**
+ * A single file the plan writes
+ */
+export interface PlannedFile {
+  /** Path relative to the plan root, with forward slashes */
+  path: string;
+  action: "create" | "modify";
+  /** SHA-256 of the file's content when planned; null for new files */
+  originalHash: string | null;
+  /** Full content after the refactor */
+  content: string;
+  diff: string;
+  /** Symbols moved, added or rewritten in this file */
+  symbols: string[];
+  impact: RefactorImpact;
+}
+
+export interface RefactorPlanSummary {
+  filesTouched: number;
+  created: string[];
+  modified: string[];
+  /** Every affected symbol, sorted and deduplicated */
+  symbols: string[];
+  /** The impact of all files together */
+  impact: RefactorImpact;
+}
+
+export interface RefactorPlan {
+  version: number;
+  files: PlannedFile[];
+  summary: RefactorPlanSummary;
+}
+
+/**
+ * A computed change to one file, as produced by a refactor
+ */
+export interface PlannedChange {
+  filePath: string;
+  /** Content before the change; null when the file does not exist yet */
+  original: string | null;
+  content: string;
+  symbols?: string[];
+  /** Reference sites the change updates (default: 0) */
+  references?: number;
+  /** Exported symbols the change removes, renames or changes */
+  exportedChanges?: string[];
+}
+
+export interface ApplyPlanOptions {
+  /** Apply plans that change exported API (default: false) */
+  allowBreaking?: boolean;
+}
+
+/**
+ * Error raised when a plan cannot be read or applied
+ */
+export class RefactorPlanError extends Error {
+  constructor(message: string) {
+    super(message);
+    this.name = "RefactorPlanError";
+  }
+}
+
+function contentHash(content: string): string {
+  return createHash("sha256").update(content).digest("hex");
+}
+
+function planPath(rootPath: string, filePath: string): string {
+  return path.relative(rootPath, filePath).split(path.sep).join("/");
+}
+
+function impactOf(
+  files: number,
+  references: number,
+  exportedChanges: string[],
+): RefactorImpact {
+  const changes = [...new Set(exportedChanges)].sort();
+  return {
+    files,
+    references,
+    exportedChanges: changes,
+    breaking: changes.length > 0,
+  };
+}
+
+/**
+ * Build a plan from computed changes. Changes to the same file are merged:
+ * the first change's original and the last change's content are kept. Files
+ * whose content does not change are left out.
+ */
+export function createPlan(
+  rootPath: string,
+  changes: PlannedChange[],
+): RefactorPlan {
+  type Merged = PlannedChange & {
+    symbols: string[];
+    references: number;
+    exportedChanges: string[];
+  };
+  const byPath = new Map<string, Merged>();
+  for (const change of changes) {
+    const key = planPath(rootPath, change.filePath);
+    const existing = byPath.get(key);
+    byPath.set(key, {
+      filePath: change.filePath,
+      original: existing ? existing.original : change.original,
+      content: change.content,
+      symbols: [...(existing?.symbols ?? []), ...(change.symbols ?? [])],
+      references: (existing?.references ?? 0) + (change.references ?? 0),
+      exportedChanges: [
+        ...(existing?.exportedChanges ?? []),
+        ...(change.exportedChanges ?? []),
+      ],
+    });
+  }
+
+  const files: PlannedFile[] = [];
+  for (const [filePath, change] of byPath) {
+    if (change.original === change.content) continue;
+    const action = change.original === null ? "create" : "modify";
+    files.push({
+      path: filePath,
+      action,
+      originalHash:
+        change.original === null ? null : contentHash(change.original),
+      content: change.content,
+      diff: unifiedDiff(change.original ?? "", change.content, {
+        oldPath: action === "create" ? "/dev/null" : `a/${filePath}`,
+        newPath: `b/${filePath}`,
+      }),
+      symbols: [...new Set(change.symbols)].sort(),
+      impact: impactOf(1, change.references, change.exportedChanges),
+    });
+  }
+  // Code-point order, so the plan does not depend on the machine's locale
+  files.sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : 0));
+
+  const pathsFor = (action: PlannedFile["action"]) =>
+    files.filter((file) => file.action === action).map((file) => file.path);
+  return {
+    version: REFACTOR_PLAN_VERSION,
+    files,
+    summary: {
+      filesTouched: files.length,
+      created: pathsFor("create"),
+      modified: pathsFor("modify"),
+      symbols: [...new Set(files.flatMap((file) => file.symbols))].sort(),
+      impact: impactOf(
+        files.length,
+        files.reduce((sum, file) => sum + file.impact.references, 0),
+        files.flatMap((file) => file.impact.exportedChanges),
+      ),
+    },
+  };
+}
+
+/**
+ * Render a plan for review: a summary followed by every file's diff
+ */
+export function renderPlan(plan: RefactorPlan): string {
+  const { summary } = plan;
+  const lines = [
+    `${summary.filesTouched} file(s) touched: ${summary.created.length} created, ${summary.modified.length} modified`,
+  ];
+  if (summary.symbols.length > 0) {
+    lines.push(`Symbols affected: ${summary.symbols.join(", ")}`);
+  }
+  const { impact } = summary;
+  if (impact.references > 0) {
+    lines.push(`References updated: ${impact.references}`);
+  }
+  if (impact.breaking) {
+    lines.push(
+      `Breaking: changes exported API (${impact.exportedChanges.join(", ")})`,
+    );
+  }
+  const diffs = plan.files.map((file) => file.diff);
+  return `${lines.join("\n")}\n\n${diffs.join("")}`;
+}
+
+/**
+ * Serialize a plan as stable, pretty-printed JSON
+ */
+export function serializePlan(plan: RefactorPlan): string {
+  return JSON.stringify(plan, null, 2) + "\n";
+}
+
+/**
+ * Read a plan written by `serializePlan`
+ */
+export function parsePlan(text: string): RefactorPlan {
+  let plan: RefactorPlan;
+  try {
+    plan = JSON.parse(text);
+  } catch (error) {
+    throw new RefactorPlanError(
+      `Invalid refactor plan: ${error instanceof Error ? error.message : String(error)}`,
+    );
+  }
+  if (plan?.version !== REFACTOR_PLAN_VERSION || !Array.isArray(plan.files)) {
+    throw new RefactorPlanError(
+      `Unsupported refactor plan version ${plan?.version}; expected ${REFACTOR_PLAN_VERSION}`,
+    );
+  }
+  for (const file of plan.files) {
+    if (typeof file?.path !== "string" || escapesRoot(file.path)) {
+      throw new RefactorPlanError(
+        `Invalid refactor plan: ${JSON.stringify(file?.path)} is not a path under the plan root`,
+      );
+    }
+  }
+  return plan;
+}
+
+/**
+ * True when a planned path is absolute or climbs out of the plan root
+ */
+function escapesRoot(filePath: string): boolean {
+  if (path.isAbsolute(filePath) || path.win32.isAbsolute(filePath)) {
+    return true;
+  }
+  const normalized = path.posix.normalize(filePath.replace(/\\/g, "/"));
+  return normalized === ".." || normalized.startsWith("../");
+}
+
+/**
+ * Where a planned file is written under `rootPath`, refusing paths that
+ * leave it
+ */
+function planTarget(rootPath: string, filePath: string): string {
+  const root = path.resolve(rootPath);
+  const target = path.resolve(root, filePath);
+  const relative = path.relative(root, target);
+  if (
+    escapesRoot(filePath) ||
+    relative === "" ||
+    relative === ".." ||
+    relative.startsWith(`..${path.sep}`) ||
+    path.isAbsolute(relative)
+  ) {
+    throw new RefactorPlanError(
+      `The plan writes ${JSON.stringify(filePath)}, which is not under ${rootPath}`,
+    );
+  }
+  return target;
+}
+
+/**
+ * Write a plan's files under `rootPath`. Every file is checked against the
+ * plan before anything is written, so a stale plan changes nothing, and a
+ * breaking plan is refused unless `allowBreaking` is set.
+ */
+export async function applyPlan(
+  plan: RefactorPlan,
+  rootPath: string,
+  options: ApplyPlanOptions = {},
+): Promise<string[]> {
+  const { impact } = plan.summary;
+  if (impact.breaking && !options.allowBreaking) {
+    throw new RefactorPlanError(
+      `The plan changes exported API (${impact.exportedChanges.join(", ")}); allow breaking changes to apply it`,
+    );
+  }
+  const targets = plan.files.map((file) => planTarget(rootPath, file.path));
+  const conflicts: string[] = [];
+  for (const [i, file] of plan.files.entries()) {
+    const target = targets[i];
+    let current: string | null = null;
+    try {
+      current = await fs.promises.readFile(target, "utf-8");
+    } catch {
+      // Missing files only match plans that create them
+    }
+    const hash = current === null ? null : contentHash(current);
+    if (hash !== file.originalHash) {
+      conflicts.push(file.path);
+    }
+  }
+  if (conflicts.length > 0) {
+    throw new RefactorPlanError(
+      `Files changed since the plan was made: ${conflicts.join(", ")}`,
+    );
+  }
+
+  const written: string[] = [];
+  for (const [i, file] of plan.files.entries()) {
+    const target = targets[i];
+    await fs.promises.mkdir(path.dirname(target), { recursive: true });
+    await fs.promises.writeFile(target, file.content, "utf-8");
+    written.push(target);
+  }
+  return written;
+}
@@ -0,0 +1,54 @@
+import { PythonStatement } from "./parser.js";
+
+const BRANCH_KEYWORDS = new Set(["if", "elif", "else", "except", "case"]);
+
+/**
+ * Count decision points in a function body, mirroring the Go analyzer so
+ * scores are comparable across languages. The count starts at 1 and adds one
+ * for each `if`/`elif`, `for`/`while`, `except`, non-wildcard `case`, `and`,
+ * `or`, conditional expression and comprehension clause, and each `return`
+ * nested inside a branch (an early exit). Nested `def` and `class` bodies are
+ * not descended into; lambda bodies count toward the enclosing function.
+ */
+export function pythonCyclomaticComplexity(body: PythonStatement[]): number {
+  let complexity = 1;
+
+  const visit = (statement: PythonStatement, inBranch: boolean) => {
+    const [first, second] = statement.tokens;
+    let keyword = first.kind === "name" ? first.value : undefined;
+    if (keyword === "async" && second) keyword = second.value;
+    if (keyword === "def" || keyword === "class") return;
+    if (first.value === "@") return;
+
+    switch (keyword) {
+      case "if":
+      case "elif":
+      case "for":
+      case "while":
+      case "except":
+        complexity++;
+        break;
+      case "case":
+        if (second?.value !== "_") complexity++;
+        break;
+      case "return":
+        if (inBranch) complexity++;
+        break;
+    }
+    // Operators and clauses inside the statement's expressions
+    statement.tokens.forEach((token, index) => {
+      if (token.kind !== "name") return;
+      if (token.value === "and" || token.value === "or") complexity++;
+      if (index > 0 && (token.value === "if" || token.value === "for")) {
+        // Skip the `for` of `async for` heading a statement
+        if (!(index === 1 && first.value === "async")) complexity++;
+      }
+    });
+
+    const branch = inBranch || BRANCH_KEYWORDS.has(keyword ?? "");
+    statement.body.forEach((child) => visit(child, branch));
+  };
+
+  body.forEach((statement) => visit(statement, false));
+  return complexity;
+}
@@ -0,0 +1,4 @@
+export * from "./complexity.js";
+export * from "./lexer.js";
+export * from "./parser.js";
+export * from "./symbols.js";
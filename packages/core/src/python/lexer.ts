@@ -0,0 +1,255 @@
+import { GoSourceMap } from "../go/lexer.js";
+
+/**
+ * Python Lexer
+ * ============
+ * Tokenizes Python source following the lexical rules of the language
+ * reference: logical lines, implicit joining inside brackets, backslash
+ * continuations and INDENT/DEDENT tokens. Offsets are UTF-16 indices into the
+ * source string; line and column conversion reuses {@link GoSourceMap}, whose
+ * 1-based lines and UTF-8 byte columns match what Python's `ast` reports.
+ */
+
+export type PythonTokenKind =
+  | "name"
+  | "number"
+  | "string"
+  | "op"
+  | "newline"
+  | "indent"
+  | "dedent"
+  | "eof";
+
+export interface PythonToken {
+  kind: PythonTokenKind;
+  value: string;
+  pos: number;
+  end: number;
+}
+
+// Operators sorted longest first so the scanner can match greedily
+const PYTHON_OPERATORS = [
+  "**=",
+  "//=",
+  ">>=",
+  "<<=",
+  "...",
+  "->",
+  ":=",
+  "**",
+  "//",
+  "<<",
+  ">>",
+  "<=",
+  ">=",
+  "==",
+  "!=",
+  "+=",
+  "-=",
+  "*=",
+  "/=",
+  "%=",
+  "&=",
+  "|=",
+  "^=",
+  "@=",
+  "+",
+  "-",
+  "*",
+  "/",
+  "%",
+  "&",
+  "|",
+  "^",
+  "~",
+  "<",
+  ">",
+  "=",
+  "(",
+  ")",
+  "[",
+  "]",
+  "{",
+  "}",
+  ",",
+  ":",
+  ";",
+  ".",
+  "@",
+];
+
+/**
+ * Error raised for malformed Python source
+ */
+export class PythonSyntaxError extends Error {
+  readonly pos: number;
+  readonly line: number;
+  readonly column: number;
+
+  constructor(message: string, pos: number, line: number, column: number) {
+    super(`${line}:${column}: ${message}`);
+    this.name = "PythonSyntaxError";
+    this.pos = pos;
+    this.line = line;
+    this.column = column;
+  }
+}
+
+const NAME_START = /[\p{L}\p{Nl}_]/u;
+const NAME = /[\p{L}\p{Nl}\p{Mn}\p{Mc}\p{Nd}\p{Pc}]+/uy;
+// Hex, octal and binary integers, then decimal integers, floats and imaginary
+const NUMBER =
+  /0[xX][0-9a-fA-F_]+|0[oO][0-7_]+|0[bB][01_]+|(?:\d[\d_]*(?:\.[\d_]*)?|\.\d[\d_]*)(?:[eE][+-]?\d[\d_]*)?[jJ]?/y;
+const STRING_PREFIX = /(?:[rR][bBfF]?|[bBfF][rR]?|[uU])?(?='|")/y;
+
+/**
+ * Tokenize Python source. Comments and blank lines produce no tokens.
+ */
+export function tokenizePython(
+  source: string,
+  map: GoSourceMap = new GoSourceMap(source),
+): PythonToken[] {
+  const tokens: PythonToken[] = [];
+  const indents = [0];
+  let depth = 0;
+  let pos = 0;
+  let atLineStart = true;
+
+  const fail = (message: string, at: number): never => {
+    const { line, column } = map.position(at);
+    throw new PythonSyntaxError(message, at, line, column);
+  };
+  const push = (kind: PythonTokenKind, start: number, end: number) => {
+    tokens.push({ kind, value: source.slice(start, end), pos: start, end });
+  };
+
+  while (pos < source.length) {
+    if (atLineStart && depth === 0) {
+      // Measure indentation; tabs advance to the next multiple of 8
+      let column = 0;
+      let cursor = pos;
+      for (; cursor < source.length; cursor++) {
+        const ch = source[cursor];
+        if (ch === " ") column++;
+        else if (ch === "\t") column = (Math.floor(column / 8) + 1) * 8;
+        else if (ch === "\f") column = 0;
+        else break;
+      }
+      const next = source[cursor];
+      if (next === undefined || "\n\r#".includes(next)) {
+        // Blank and comment-only lines do not affect indentation
+        const lineEnd = source.indexOf("\n", cursor);
+        pos = lineEnd < 0 ? source.length : lineEnd + 1;
+        continue;
+      }
+      if (column > indents.at(-1)) {
+        indents.push(column);
+        push("indent", pos, cursor);
+      } else {
+        while (column < indents.at(-1)) {
+          indents.pop();
+          push("dedent", cursor, cursor);
+        }
+        if (column !== indents.at(-1)) {
+          fail("unindent does not match any outer indentation level", cursor);
+        }
+      }
+      pos = cursor;
+      atLineStart = false;
+    }
+
+    const ch = source[pos];
+    if (ch === " " || ch === "\t" || ch === "\f" || ch === "\r") {
+      pos++;
+      continue;
+    }
+    if (ch === "#") {
+      const lineEnd = source.indexOf("\n", pos);
+      pos = lineEnd < 0 ? source.length : lineEnd;
+      continue;
+    }
+    if (ch === "\\" && /^\\\r?\n/.test(source.slice(pos, pos + 3))) {
+      pos = source.indexOf("\n", pos) + 1;
+      continue;
+    }
+    if (ch === "\n") {
+      if (depth === 0) {
+        push("newline", pos, pos + 1);
+        atLineStart = true;
+      }
+      pos++;
+      continue;
+    }
+
+    STRING_PREFIX.lastIndex = pos;
+    const prefix = STRING_PREFIX.exec(source);
+    if (prefix) {
+      const start = pos;
+      const quoteStart = pos + prefix[0].length;
+      const quote = source[quoteStart];
+      const triple = source.startsWith(quote.repeat(3), quoteStart);
+      const delimiter = triple ? quote.repeat(3) : quote;
+      let cursor = quoteStart + delimiter.length;
+      for (;;) {
+        if (cursor >= source.length || (!triple && source[cursor] === "\n")) {
+          fail("unterminated string literal", start);
+        }
+        if (source[cursor] === "\\") {
+          cursor += 2;
+          continue;
+        }
+        if (source.startsWith(delimiter, cursor)) {
+          cursor += delimiter.length;
+          break;
+        }
+        cursor++;
+      }
+      push("string", start, cursor);
+      pos = cursor;
+      continue;
+    }
+
+    if (NAME_START.test(ch)) {
+      NAME.lastIndex = pos;
+      const match = NAME.exec(source);
+      push("name", pos, pos + match[0].length);
+      pos += match[0].length;
+      continue;
+    }
+
+    if (/[0-9]/.test(ch) || (ch === "." && /[0-9]/.test(source[pos + 1]))) {
+      NUMBER.lastIndex = pos;
+      const match = NUMBER.exec(source);
+      push("number", pos, pos + match[0].length);
+      pos += match[0].length;
+      continue;
+    }
+
+    const op = PYTHON_OPERATORS.find((candidate) =>
+      source.startsWith(candidate, pos),
+    );
+    if (!op) {
+      fail(`invalid character '${ch}'`, pos);
+    }
+    if (op === "(" || op === "[" || op === "{") depth++;
+    if (op === ")" || op === "]" || op === "}") {
+      if (depth === 0) fail(`unmatched '${op}'`, pos);
+      depth--;
+    }
+    push("op", pos, pos + op.length);
+    pos += op.length;
+  }
+
+  if (depth > 0) {
+    fail("unexpected EOF in multi-line statement", source.length);
+  }
+  if (tokens.length > 0 && tokens.at(-1).kind !== "newline") {
+    push("newline", source.length, source.length);
+  }
+  while (indents.length > 1) {
+    indents.pop();
+    push("dedent", source.length, source.length);
+  }
+  push("eof", source.length, source.length);
+  return tokens;
+}
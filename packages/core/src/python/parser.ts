@@ -0,0 +1,161 @@
+import { GoSourceMap } from "../go/lexer.js";
+import { PythonSyntaxError, PythonToken, tokenizePython } from "./lexer.js";
+
+/**
+ * Python Statement Parser
+ * =======================
+ * Groups tokens into a tree of logical lines: each statement keeps its own
+ * tokens (for a compound statement, the header up to its `:`) and the
+ * statements of its suite. Expressions are left as token runs; the analyses
+ * built on top only need statement structure plus keyword and operator
+ * tokens, which keeps the parser small and tolerant of newer syntax.
+ */
+
+export interface PythonStatement {
+  /** Tokens of the statement; for compound statements, the header only */
+  tokens: PythonToken[];
+  /** Statements of the suite, empty for simple statements */
+  body: PythonStatement[];
+  pos: number;
+  end: number;
+}
+
+export interface PythonModule {
+  filePath: string;
+  source: string;
+  sourceMap: GoSourceMap;
+  statements: PythonStatement[];
+}
+
+const COMPOUND_KEYWORDS = new Set([
+  "async",
+  "class",
+  "def",
+  "elif",
+  "else",
+  "except",
+  "finally",
+  "for",
+  "if",
+  "try",
+  "while",
+  "with",
+]);
+
+// Soft keywords only start a compound statement when a suite follows
+const SOFT_KEYWORDS = new Set(["match", "case"]);
+
+/**
+ * Index of the `:` ending a compound statement header, skipping colons of
+ * lambdas, slices, dict displays and annotations inside brackets
+ */
+export function headerColon(tokens: PythonToken[]): number {
+  let depth = 0;
+  let lambdas = 0;
+  for (let i = 0; i < tokens.length; i++) {
+    const { kind, value } = tokens[i];
+    if (kind === "op" && "([{".includes(value)) depth++;
+    else if (kind === "op" && ")]}".includes(value)) depth--;
+    else if (depth === 0 && kind === "name" && value === "lambda") lambdas++;
+    else if (depth === 0 && kind === "op" && value === ":") {
+      if (lambdas === 0) return i;
+      lambdas--;
+    }
+  }
+  return -1;
+}
+
+class PythonParser {
+  private index = 0;
+
+  constructor(
+    private readonly tokens: PythonToken[],
+    private readonly map: GoSourceMap,
+  ) {}
+
+  private get tok(): PythonToken {
+    return this.tokens[this.index];
+  }
+
+  private error(message: string, pos: number = this.tok.pos): never {
+    const { line, column } = this.map.position(pos);
+    throw new PythonSyntaxError(message, pos, line, column);
+  }
+
+  private statement(tokens: PythonToken[], body: PythonStatement[] = []) {
+    const last = body.at(-1) ?? tokens.at(-1);
+    return { tokens, body, pos: tokens[0].pos, end: last.end };
+  }
+
+  private simple(tokens: PythonToken[]): PythonStatement[] {
+    const statements: PythonStatement[] = [];
+    let start = 0;
+    for (let i = 0; i <= tokens.length; i++) {
+      if (i === tokens.length || tokens[i].value === ";") {
+        if (i > start) statements.push(this.statement(tokens.slice(start, i)));
+        start = i + 1;
+      }
+    }
+    return statements;
+  }
+
+  suite(): PythonStatement[] {
+    const statements: PythonStatement[] = [];
+    while (this.tok.kind !== "dedent" && this.tok.kind !== "eof") {
+      if (this.tok.kind === "indent") {
+        this.error("unexpected indent");
+      }
+      const line: PythonToken[] = [];
+      while (this.tok.kind !== "newline") {
+        line.push(this.tok);
+        this.index++;
+      }
+      this.index++;
+
+      const [first] = line;
+      const hasSuite = this.tok.kind === "indent";
+      const compound =
+        first.kind === "name" &&
+        (COMPOUND_KEYWORDS.has(first.value) ||
+          (SOFT_KEYWORDS.has(first.value) && hasSuite));
+      if (!compound) {
+        if (hasSuite) this.error("unexpected indent");
+        statements.push(...this.simple(line));
+        continue;
+      }
+
+      const colon = headerColon(line);
+      if (colon < 0) {
+        this.error("expected ':'", line.at(-1).end);
+      }
+      const header = line.slice(0, colon + 1);
+      if (colon < line.length - 1) {
+        // A suite on the same line, as in `if done: return`
+        const inline = this.simple(line.slice(colon + 1));
+        statements.push(this.statement(header, inline));
+        continue;
+      }
+      if (!hasSuite) {
+        this.error("expected an indented block");
+      }
+      this.index++;
+      const body = this.suite();
+      this.index++;
+      statements.push(this.statement(header, body));
+    }
+    return statements;
+  }
+}
+
+/**
+ * Parse Python source into a statement tree
+ */
+export function parsePython(
+  source: string,
+  filePath: string = "<input>",
+): PythonModule {
+  const sourceMap = new GoSourceMap(source);
+  const parser = new PythonParser(tokenizePython(source, sourceMap), sourceMap);
+  const statements = parser.suite();
+  return { filePath, source, sourceMap, statements };
+}
@@ -0,0 +1,456 @@
+import { Visibility } from "../go/symbols.js";
+import { SymbolInfo } from "../indexing.js";
+import { pythonCyclomaticComplexity } from "./complexity.js";
+import { PythonToken } from "./lexer.js";
+import { PythonModule, PythonStatement } from "./parser.js";
+
+/**
+ * Python Symbols
+ * ==============
+ * Extracts functions, classes, methods and module-level variables into the
+ * same symbol model the Go analyzer produces, so reports can treat both
+ * languages alike. Visibility follows Python's naming conventions: a leading
+ * underscore makes a name private, while dunder names such as `__init__` are
+ * part of the public protocol. When a module declares `__all__`, module-level
+ * names it does not list are private too.
+ */
+
+/**
+ * A decorator applied to a function or class
+ */
+export interface PythonDecorator {
+  /** Dotted name, e.g. `functools.lru_cache` */
+  name: string;
+  /** Source of the call arguments without the parentheses, if called */
+  arguments?: string;
+  line: number;
+}
+
+export interface PythonParameter {
+  name: string;
+  annotation?: string;
+  default?: string;
+  /** `*args` and `**kwargs` are "variadic" and "keywords" */
+  kind: "positional" | "variadic" | "keyword-only" | "keywords";
+}
+
+export interface PythonFunctionSymbol extends SymbolInfo {
+  type: "function";
+  /** `Class.method` for methods, the bare name otherwise */
+  qualifiedName: string;
+  visibility: Visibility;
+  decorators: PythonDecorator[];
+  isAsync: boolean;
+  /** Enclosing class for methods */
+  className?: string;
+  params: PythonParameter[];
+  complexity: number;
+}
+
+export interface PythonClassSymbol extends SymbolInfo {
+  type: "class";
+  qualifiedName: string;
+  visibility: Visibility;
+  decorators: PythonDecorator[];
+  bases: string[];
+  /** Methods declared in the class body, in source order */
+  methods: PythonFunctionSymbol[];
+}
+
+export interface PythonVariableSymbol extends SymbolInfo {
+  /** "constant" for UPPER_CASE names, following PEP 8 */
+  type: "constant" | "variable";
+  visibility: Visibility;
+  annotation?: string;
+}
+
+export interface PythonFileSymbols {
+  filePath: string;
+  /** Names listed in `__all__`, when the module declares it */
+  exports?: string[];
+  functions: PythonFunctionSymbol[];
+  methods: PythonFunctionSymbol[];
+  classes: PythonClassSymbol[];
+  variables: PythonVariableSymbol[];
+}
+
+/**
+ * Classify a name by Python convention: dunder names are public, other names
+ * with a leading underscore are private
+ */
+export function pythonVisibility(name: string): Visibility {
+  if (/^__.+__$/.test(name)) return Visibility.Exported;
+  return name.startsWith("_") ? Visibility.Unexported : Visibility.Exported;
+}
+
+function isName(token: PythonToken | undefined, value?: string): boolean {
+  return (
+    token?.kind === "name" && (value === undefined || token.value === value)
+  );
+}
+
+function isOp(token: PythonToken | undefined, value: string): boolean {
+  return token?.kind === "op" && token.value === value;
+}
+
+/**
+ * Split tokens at top-level commas
+ */
+function splitTopLevel(tokens: PythonToken[]): PythonToken[][] {
+  const parts: PythonToken[][] = [[]];
+  let depth = 0;
+  for (const token of tokens) {
+    if (token.kind === "op" && "([{".includes(token.value)) depth++;
+    if (token.kind === "op" && ")]}".includes(token.value)) depth--;
+    if (depth === 0 && isOp(token, ",")) {
+      parts.push([]);
+    } else {
+      parts.at(-1).push(token);
+    }
+  }
+  return parts.filter((part) => part.length > 0);
+}
+
+/**
+ * Index of the bracket closing the one at `open`
+ */
+function closing(tokens: PythonToken[], open: number): number {
+  let depth = 0;
+  for (let i = open; i < tokens.length; i++) {
+    if (tokens[i].kind !== "op") continue;
+    if ("([{".includes(tokens[i].value)) depth++;
+    if (")]}".includes(tokens[i].value) && --depth === 0) return i;
+  }
+  return tokens.length - 1;
+}
+
+/**
+ * Strip the quotes and prefix of a string literal and clean up indentation
+ * the way `inspect.cleandoc` does
+ */
+function docstringText(tokens: PythonToken[]): string | undefined {
+  if (tokens.length === 0 || !tokens.every((t) => t.kind === "string")) {
+    return undefined;
+  }
+  const text = tokens
+    .map((token) => {
+      const quoteStart = token.value.search(/['"]/);
+      const quote = token.value.slice(quoteStart, quoteStart + 3);
+      const width = quote === '"""' || quote === "'''" ? 3 : 1;
+      return token.value.slice(quoteStart + width, -width);
+    })
+    .join("");
+  const lines = text.replace(/\t/g, "        ").split("\n");
+  const indent = Math.min(
+    ...lines
+      .slice(1)
+      .filter((line) => line.trim().length > 0)
+      .map((line) => line.length - line.trimStart().length),
+  );
+  const cleaned = [
+    lines[0].trim(),
+    ...lines
+      .slice(1)
+      .map((line) =>
+        (Number.isFinite(indent) ? line.slice(indent) : line).trimEnd(),
+      ),
+  ];
+  return cleaned.join("\n").trim() || undefined;
+}
+
+class SymbolExtractor {
+  readonly symbols: PythonFileSymbols;
+
+  constructor(private readonly module: PythonModule) {
+    this.symbols = {
+      filePath: module.filePath,
+      functions: [],
+      methods: [],
+      classes: [],
+      variables: [],
+    };
+  }
+
+  private text(tokens: PythonToken[]): string {
+    if (tokens.length === 0) return "";
+    return this.module.source
+      .slice(tokens[0].pos, tokens.at(-1).end)
+      .replace(/\s+/g, " ");
+  }
+
+  private span(pos: number, end: number) {
+    const start = this.module.sourceMap.position(pos);
+    const stop = this.module.sourceMap.position(end);
+    return {
+      startLine: start.line,
+      endLine: stop.line,
+      startColumn: start.column,
+      endColumn: stop.column,
+    };
+  }
+
+  private decorator(statement: PythonStatement): PythonDecorator {
+    const tokens = statement.tokens.slice(1);
+    const open = tokens.findIndex((token) => isOp(token, "("));
+    const nameTokens = open < 0 ? tokens : tokens.slice(0, open);
+    return {
+      name: this.text(nameTokens).replace(/\s/g, ""),
+      arguments:
+        open < 0
+          ? undefined
+          : this.text(tokens.slice(open + 1, closing(tokens, open))),
+      line: this.module.sourceMap.line(statement.pos),
+    };
+  }
+
+  private parameters(tokens: PythonToken[]): PythonParameter[] {
+    const params: PythonParameter[] = [];
+    let keywordOnly = false;
+    for (const part of splitTopLevel(tokens)) {
+      if (isOp(part[0], "/")) continue;
+      if (isOp(part[0], "*") && part.length === 1) {
+        keywordOnly = true;
+        continue;
+      }
+      let kind: PythonParameter["kind"] = keywordOnly
+        ? "keyword-only"
+        : "positional";
+      let rest = part;
+      if (isOp(part[0], "*")) {
+        kind = "variadic";
+        keywordOnly = true;
+        rest = part.slice(1);
+      } else if (isOp(part[0], "**")) {
+        kind = "keywords";
+        rest = part.slice(1);
+      }
+      const equals = rest.findIndex((token) => isOp(token, "="));
+      const annotationEnd = equals < 0 ? rest.length : equals;
+      // A colon after the `=` belongs to a lambda in the default
+      const colon = rest
+        .slice(0, annotationEnd)
+        .findIndex((token) => isOp(token, ":"));
+      params.push({
+        name: rest[0].value,
+        annotation:
+          colon < 0
+            ? undefined
+            : this.text(rest.slice(colon + 1, annotationEnd)),
+        default: equals < 0 ? undefined : this.text(rest.slice(equals + 1)),
+        kind,
+      });
+    }
+    return params;
+  }
+
+  private docstring(body: PythonStatement[]): string | undefined {
+    return body.length > 0 ? docstringText(body[0].tokens) : undefined;
+  }
+
+  private functionSymbol(
+    statement: PythonStatement,
+    decorators: PythonDecorator[],
+    className?: string,
+  ): PythonFunctionSymbol {
+    const { tokens } = statement;
+    const isAsync = isName(tokens[0], "async");
+    const nameIndex = isAsync ? 2 : 1;
+    const name = tokens[nameIndex].value;
+    const open = nameIndex + 1;
+    const close = closing(tokens, open);
+    const arrow = tokens.findIndex(
+      (token, index) => index > close && isOp(token, "->"),
+    );
+    const params = this.parameters(tokens.slice(open + 1, close));
+    const visibility = pythonVisibility(name);
+    return {
+      name,
+      type: "function",
+      qualifiedName: className ? `${className}.${name}` : name,
+      ...this.span(statement.pos, statement.end),
+      visibility,
+      isExported: visibility === Visibility.Exported,
+      isPrivate: visibility !== Visibility.Exported,
+      parameters: params.map((param) => param.name),
+      returnType:
+        arrow < 0 ? undefined : this.text(tokens.slice(arrow + 1, -1)),
+      documentation: this.docstring(statement.body),
+      decorators,
+      isAsync,
+      className,
+      params,
+      complexity: pythonCyclomaticComplexity(statement.body),
+    };
+  }
+
+  private classSymbol(
+    statement: PythonStatement,
+    decorators: PythonDecorator[],
+    outer?: string,
+  ): void {
+    const { tokens } = statement;
+    const name = tokens[1].value;
+    const qualifiedName = outer ? `${outer}.${name}` : name;
+    const bases = isOp(tokens[2], "(")
+      ? splitTopLevel(tokens.slice(3, closing(tokens, 2))).map((base) =>
+          this.text(base),
+        )
+      : [];
+    const visibility = pythonVisibility(name);
+    const symbol: PythonClassSymbol = {
+      name,
+      type: "class",
+      qualifiedName,
+      ...this.span(statement.pos, statement.end),
+      visibility,
+      isExported: visibility === Visibility.Exported,
+      isPrivate: visibility !== Visibility.Exported,
+      documentation: this.docstring(statement.body),
+      decorators,
+      bases,
+      methods: [],
+    };
+    this.symbols.classes.push(symbol);
+
+    this.definitions(statement.body, (definition, defDecorators) => {
+      if (isName(definition.tokens[0], "class")) {
+        this.classSymbol(definition, defDecorators, qualifiedName);
+      } else {
+        const method = this.functionSymbol(
+          definition,
+          defDecorators,
+          qualifiedName,
+        );
+        symbol.methods.push(method);
+        this.symbols.methods.push(method);
+      }
+    });
+  }
+
+  /**
+   * Visit the `def` and `class` statements of a suite with their decorators
+   */
+  private definitions(
+    body: PythonStatement[],
+    visit: (statement: PythonStatement, decorators: PythonDecorator[]) => void,
+    onOther?: (statement: PythonStatement) => void,
+  ): void {
+    let decorators: PythonDecorator[] = [];
+    for (const statement of body) {
+      const [first, second] = statement.tokens;
+      if (isOp(first, "@")) {
+        decorators.push(this.decorator(statement));
+        continue;
+      }
+      if (
+        isName(first, "def") ||
+        isName(first, "class") ||
+        (isName(first, "async") && isName(second, "def"))
+      ) {
+        visit(statement, decorators);
+      } else {
+        onOther?.(statement);
+      }
+      decorators = [];
+    }
+  }
+
+  private variables(statement: PythonStatement): void {
+    const { tokens } = statement;
+    const equals = tokens.findIndex((token) => isOp(token, "="));
+    const colon = tokens.findIndex((token) => isOp(token, ":"));
+    let targets: PythonToken[][];
+    let annotation: string | undefined;
+    if (colon === 1 && isName(tokens[0])) {
+      // `NAME: annotation [= value]`
+      targets = [[tokens[0]]];
+      annotation = this.text(
+        tokens.slice(2, equals < 0 ? tokens.length : equals),
+      );
+    } else if (equals > 0) {
+      targets = splitTopLevel(tokens.slice(0, equals));
+    } else {
+      return;
+    }
+
+    for (const target of targets) {
+      if (target.length !== 1 || !isName(target[0])) continue;
+      const name = target[0].value;
+      if (name === "__all__") {
+        this.symbols.exports = tokens
+          .slice(equals + 1)
+          .filter((token) => token.kind === "string")
+          .map((token) => token.value.replace(/^[a-zA-Z]*(['"])(.*)\1$/, "$2"));
+        continue;
+      }
+      const visibility = pythonVisibility(name);
+      this.symbols.variables.push({
+        name,
+        type: /^_*[A-Z][A-Z0-9_]*$/.test(name) ? "constant" : "variable",
+        ...this.span(target[0].pos, statement.end),
+        visibility,
+        isExported: visibility === Visibility.Exported,
+        isPrivate: visibility !== Visibility.Exported,
+        annotation,
+      });
+    }
+  }
+
+  /**
+   * Module-level statements, descending into `if`, `try` and `with` blocks
+   * that conditionally define symbols
+   */
+  visitModule(body: PythonStatement[]): void {
+    this.definitions(
+      body,
+      (statement, decorators) => {
+        if (isName(statement.tokens[0], "class")) {
+          this.classSymbol(statement, decorators);
+        } else {
+          const symbol = this.functionSymbol(statement, decorators);
+          this.symbols.functions.push(symbol);
+        }
+      },
+      (statement) => {
+        if (statement.body.length > 0) {
+          this.visitModule(statement.body);
+        } else {
+          this.variables(statement);
+        }
+      },
+    );
+  }
+
+  /**
+   * Narrow visibility of module-level names to those listed in `__all__`
+   */
+  applyExports(): void {
+    const { exports } = this.symbols;
+    if (!exports) return;
+    const listed = new Set(exports);
+    const topLevel = [
+      ...this.symbols.functions,
+      ...this.symbols.variables,
+      ...this.symbols.classes.filter((cls) => cls.qualifiedName === cls.name),
+    ];
+    for (const symbol of topLevel) {
+      if (!listed.has(symbol.name)) {
+        symbol.visibility = Visibility.Unexported;
+        symbol.isExported = false;
+        symbol.isPrivate = true;
+      }
+    }
+  }
+}
+
+/**
+ * Extract the symbols of a parsed Python module
+ */
+export function extractPythonFileSymbols(
+  module: PythonModule,
+): PythonFileSymbols {
+  const extractor = new SymbolExtractor(module);
+  extractor.visitModule(module.statements);
+  extractor.applyExports();
+  return extractor.symbols;
+}
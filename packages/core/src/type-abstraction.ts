@@ -1,6 +1,7 @@
 import * as fs from "fs";
 import * as path from "path";
 import { RefactorableFile } from "./indexing.js";
+import { applyPlan, createPlan, PlannedChange, RefactorPlan } from "./plan.js";
 
 /**
  * Configuration for type abstraction
@@ -518,11 +519,13 @@ export class TypeAbstraction {
 
   /**
    * Resolve type dependencies across extracted .types.ts files
-   * Finds references to other extracted types and adds imports
+   * Finds references to other extracted types and adds imports to the planned
+   * contents of each type file
    */
-  private async resolveTypeDependencies(
+  private resolveTypeDependencies(
     abstractions: TypeAbstractionResult[],
-  ): Promise<void> {
+    contents: Map<string, string>,
+  ): void {
     if (this.config.verbose) {
       console.log("[TypeAbstraction] Resolving type dependencies...");
     }
@@ -539,10 +542,7 @@ export class TypeAbstraction {
     // Process each extracted type file
     for (const abstraction of abstractions) {
       try {
-        const content = await fs.promises.readFile(
-          abstraction.targetFile,
-          "utf-8",
-        );
+        const content = contents.get(abstraction.targetFile) ?? "";
         const imports: string[] = [];
 
         // Find all type references in the content
@@ -605,11 +605,7 @@ export class TypeAbstraction {
         // If we found imports, prepend them to the file
         if (imports.length > 0) {
           const updatedContent = imports.join("\n") + "\n\n" + content;
-          await fs.promises.writeFile(
-            abstraction.targetFile,
-            updatedContent,
-            "utf-8",
-          );
+          contents.set(abstraction.targetFile, updatedContent);
 
           if (this.config.verbose) {
             console.log(
@@ -629,63 +625,96 @@ export class TypeAbstraction {
   }
 
   /**
-   * Apply type abstractions
+   * Compute every file write the abstractions need without touching disk
    */
-  async applyAbstractions(results: TypeAbstractionResults): Promise<void> {
-    try {
+  async planAbstractions(
+    results: TypeAbstractionResults,
+  ): Promise<RefactorPlan> {
+    // Combine all abstractions
+    const allAbstractions = [...results.centralized, ...results.local];
+
+    // Group abstractions by source file
+    const bySourceFile = new Map<string, TypeAbstractionResult[]>();
+    for (const abstraction of allAbstractions) {
+      const existing = bySourceFile.get(abstraction.sourceFile) || [];
+      existing.push(abstraction);
+      bySourceFile.set(abstraction.sourceFile, existing);
+    }
+
+    const changes: PlannedChange[] = [];
+    const typeFiles = new Map<string, string>();
+    const typeSymbols = new Map<string, string[]>();
+    for (const [sourceFile, abstractions] of bySourceFile.entries()) {
+      // Sort by startLine descending (process from bottom to top)
+      const sorted = abstractions.sort((a, b) => b.startLine - a.startLine);
+
       if (this.config.verbose) {
         console.log(
-          `[TypeAbstraction] Applying ${results.centralized.length + results.local.length} type abstractions`,
+          `[TypeAbstraction] Planning ${sorted.length} abstractions in ${sourceFile}`,
         );
       }
 
-      // Combine all abstractions
-      const allAbstractions = [...results.centralized, ...results.local];
-
-      // Group abstractions by source file
-      const bySourceFile = new Map<string, TypeAbstractionResult[]>();
-      for (const abstraction of allAbstractions) {
-        const existing = bySourceFile.get(abstraction.sourceFile) || [];
-        existing.push(abstraction);
-        bySourceFile.set(abstraction.sourceFile, existing);
+      for (const abstraction of sorted) {
+        typeFiles.set(abstraction.targetFile, abstraction.typeContent);
+        typeSymbols.set(abstraction.targetFile, [
+          ...(typeSymbols.get(abstraction.targetFile) ?? []),
+          abstraction.typeName,
+        ]);
       }
 
-      // Process each file: sort by line number descending to avoid index shifts
-      for (const [sourceFile, abstractions] of bySourceFile.entries()) {
-        // Sort by startLine descending (process from bottom to top)
-        const sorted = abstractions.sort((a, b) => b.startLine - a.startLine);
+      // Update the source file once with all changes
+      const original = await fs.promises.readFile(sourceFile, "utf-8");
+      changes.push({
+        filePath: sourceFile,
+        original,
+        content: this.removeAbstractedTypes(original, sorted),
+        symbols: sorted.map((abstraction) => abstraction.typeName),
+      });
+    }
 
-        if (this.config.verbose) {
-          console.log(
-            `[TypeAbstraction] Processing ${sorted.length} abstractions in ${sourceFile}`,
-          );
-        }
+    // Once all type files are known, resolve type dependencies
+    this.resolveTypeDependencies(allAbstractions, typeFiles);
 
-        // Create all type files first
-        for (const abstraction of sorted) {
-          const targetDir = path.dirname(abstraction.targetFile);
-          await fs.promises.mkdir(targetDir, { recursive: true });
-          await fs.promises.writeFile(
-            abstraction.targetFile,
-            abstraction.typeContent,
-            "utf-8",
-          );
+    for (const [targetFile, content] of typeFiles) {
+      let original: string | null = null;
+      try {
+        original = await fs.promises.readFile(targetFile, "utf-8");
+      } catch {
+        // New type file
+      }
+      changes.push({
+        filePath: targetFile,
+        original,
+        content,
+        symbols: typeSymbols.get(targetFile),
+      });
+    }
 
-          if (this.config.verbose) {
-            console.log(
-              `[TypeAbstraction] Created type file: ${abstraction.targetFile}`,
-            );
-          }
-        }
+    return createPlan(this.config.rootPath, changes);
+  }
 
-        // Then update the source file once with all changes
-        await this.updateSourceFileWithMultipleAbstractions(sourceFile, sorted);
+  /**
+   * Apply type abstractions, or a plan made earlier by `planAbstractions`
+   */
+  async applyAbstractions(
+    results: TypeAbstractionResults | RefactorPlan,
+  ): Promise<void> {
+    try {
+      const plan =
+        "files" in results ? results : await this.planAbstractions(results);
+
+      if (this.config.verbose) {
+        console.log(
+          `[TypeAbstraction] Applying ${plan.summary.symbols.length} type abstractions`,
+        );
       }
 
-      // After all files are created, resolve type dependencies
-      await this.resolveTypeDependencies(allAbstractions);
+      const written = await applyPlan(plan, this.config.rootPath);
 
       if (this.config.verbose) {
+        for (const filePath of written) {
+          console.log(`[TypeAbstraction] Wrote ${filePath}`);
+        }
         console.log(
           "[TypeAbstraction] Successfully applied all type abstractions",
         );
@@ -769,130 +798,115 @@ export class TypeAbstraction {
   }
 
   /**
-   * Update the source file to remove multiple types and add their import statements
+   * Remove multiple types from a source file's content and add their import statements
    * Processing all abstractions from a file at once avoids line number shift issues
    */
-  private async updateSourceFileWithMultipleAbstractions(
-    sourceFile: string,
+  private removeAbstractedTypes(
+    content: string,
     abstractions: TypeAbstractionResult[],
-  ): Promise<void> {
-    try {
-      // Read the source file
-      const content = await fs.promises.readFile(sourceFile, "utf-8");
-      let lines = content.split("\n");
-
-      // Find where to insert imports (after last import)
-      let importInsertIndex = 0;
-      let lastImportIndex = -1;
-      let inMultiLineImport = false;
-
-      for (let i = 0; i < lines.length; i++) {
-        const line = lines[i].trim();
-
-        if (line.startsWith("import ") || line.startsWith("import{")) {
-          lastImportIndex = i;
-          if (line.includes("{") && !line.includes("}")) {
-            inMultiLineImport = true;
-          } else if (line.includes("{") && line.includes("}")) {
-            inMultiLineImport = false;
-          }
-        } else if (inMultiLineImport) {
-          lastImportIndex = i;
-          if (line.includes("}")) {
-            inMultiLineImport = false;
-          }
-        } else {
-          if (
-            lastImportIndex >= 0 &&
-            line.length > 0 &&
-            !line.startsWith("//") &&
-            !line.startsWith("/*")
-          ) {
-            break;
-          }
+  ): string {
+    let lines = content.split("\n");
+
+    // Find where to insert imports (after last import)
+    let importInsertIndex = 0;
+    let lastImportIndex = -1;
+    let inMultiLineImport = false;
+
+    for (let i = 0; i < lines.length; i++) {
+      const line = lines[i].trim();
+
+      if (line.startsWith("import ") || line.startsWith("import{")) {
+        lastImportIndex = i;
+        if (line.includes("{") && !line.includes("}")) {
+          inMultiLineImport = true;
+        } else if (line.includes("{") && line.includes("}")) {
+          inMultiLineImport = false;
+        }
+      } else if (inMultiLineImport) {
+        lastImportIndex = i;
+        if (line.includes("}")) {
+          inMultiLineImport = false;
+        }
+      } else {
+        if (
+          lastImportIndex >= 0 &&
+          line.length > 0 &&
+          !line.startsWith("//") &&
+          !line.startsWith("/*")
+        ) {
+          break;
         }
       }
+    }
 
-      if (lastImportIndex >= 0) {
-        importInsertIndex = lastImportIndex + 1;
-      }
+    if (lastImportIndex >= 0) {
+      importInsertIndex = lastImportIndex + 1;
+    }
 
-      // Collect all imports and re-exports to add
-      const importsToAdd: string[] = [];
-      const typeImportsToAdd: string[] = [];
+    // Collect all imports and re-exports to add
+    const importsToAdd: string[] = [];
+    const typeImportsToAdd: string[] = [];
 
-      for (const abstraction of abstractions) {
-        const sourceDir = path.dirname(abstraction.sourceFile);
-        const relativePath = path.relative(sourceDir, abstraction.targetFile);
-        const importPath = relativePath
-          .replace(/\.ts$/, "")
-          .replace(/\\/g, "/");
-        const finalImportPath = importPath.startsWith(".")
-          ? importPath
-          : `./${importPath}`;
-
-        // Check if the type was originally exported in the source file
-        const originalLine = content.split("\n")[abstraction.startLine];
-        const wasExported = originalLine.trim().startsWith("export ");
-
-        // Always import the type for use within the file
-        const importStatement = `import type { ${abstraction.typeName} } from '${finalImportPath}';`;
-        typeImportsToAdd.push(importStatement);
-
-        if (wasExported) {
-          // Also re-export it using export type {} from syntax (required for isolatedModules)
-          const exportStatement = `export type { ${abstraction.typeName} } from '${finalImportPath}';`;
-          importsToAdd.push(exportStatement);
-        }
-      }
+    for (const abstraction of abstractions) {
+      const sourceDir = path.dirname(abstraction.sourceFile);
+      const relativePath = path.relative(sourceDir, abstraction.targetFile);
+      const importPath = relativePath
+        .replace(/\.ts$/, "")
+        .replace(/\\/g, "/");
+      const finalImportPath = importPath.startsWith(".")
+        ? importPath
+        : `./${importPath}`;
 
-      // Combine type imports and re-exports
-      importsToAdd.unshift(...typeImportsToAdd);
+      // Check if the type was originally exported in the source file
+      const originalLine = content.split("\n")[abstraction.startLine];
+      const wasExported = originalLine.trim().startsWith("export ");
 
-      // Remove type definitions (bottom to top, already sorted)
-      for (const abstraction of abstractions) {
-        lines = [
-          ...lines.slice(0, abstraction.startLine),
-          ...lines.slice(abstraction.endLine + 1),
-        ];
+      // Always import the type for use within the file
+      const importStatement = `import type { ${abstraction.typeName} } from '${finalImportPath}';`;
+      typeImportsToAdd.push(importStatement);
 
-        // Adjust import insert index if we removed lines before it
-        if (abstraction.endLine < importInsertIndex) {
-          const linesRemoved = abstraction.endLine - abstraction.startLine + 1;
-          importInsertIndex -= linesRemoved;
-        }
+      if (wasExported) {
+        // Also re-export it using export type {} from syntax (required for isolatedModules)
+        const exportStatement = `export type { ${abstraction.typeName} } from '${finalImportPath}';`;
+        importsToAdd.push(exportStatement);
       }
+    }
 
-      // Insert all imports
-      lines.splice(importInsertIndex, 0, ...importsToAdd);
-
-      // Write back
-      const updatedContent = lines.join("\n");
-      await fs.promises.writeFile(sourceFile, updatedContent, "utf-8");
+    // Combine type imports and re-exports
+    importsToAdd.unshift(...typeImportsToAdd);
 
-      if (this.config.verbose) {
-        console.log(`[TypeAbstraction] Updated source file: ${sourceFile}`);
-        for (const abstraction of abstractions) {
-          console.log(
-            `[TypeAbstraction] - Removed type "${abstraction.typeName}" (lines ${abstraction.startLine}-${abstraction.endLine})`,
-          );
-        }
-        console.log(`[TypeAbstraction] - Added ${importsToAdd.length} imports`);
+    // Remove type definitions (bottom to top, already sorted)
+    for (const abstraction of abstractions) {
+      lines = [
+        ...lines.slice(0, abstraction.startLine),
+        ...lines.slice(abstraction.endLine + 1),
+      ];
+
+      // Adjust import insert index if we removed lines before it
+      if (abstraction.endLine < importInsertIndex) {
+        const linesRemoved = abstraction.endLine - abstraction.startLine + 1;
+        importInsertIndex -= linesRemoved;
       }
-    } catch (error) {
-      if (this.config.verbose) {
-        console.warn(
-          `[TypeAbstraction] Error updating source file ${sourceFile}:`,
-          error,
+    }
+
+    // Insert all imports
+    lines.splice(importInsertIndex, 0, ...importsToAdd);
+
+    if (this.config.verbose) {
+      for (const abstraction of abstractions) {
+        console.log(
+          `[TypeAbstraction] - Removed type "${abstraction.typeName}" (lines ${abstraction.startLine}-${abstraction.endLine})`,
         );
       }
-      throw error;
+      console.log(`[TypeAbstraction] - Added ${importsToAdd.length} imports`);
     }
+
+    return lines.join("\n");
   }
 
   /**
    * Update the source file to remove the type and add the import statement
-   * @deprecated Use updateSourceFileWithMultipleAbstractions instead
+   * @deprecated Use planAbstractions instead
    */
   private async updateSourceFile(result: TypeAbstractionResult): Promise<void> {
     try {
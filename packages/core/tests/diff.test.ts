@@ -0,0 +1,54 @@
+import { describe, it, expect } from '@jest/globals';
+import { applyEdits, unifiedDiff } from '../src/diff';
+
+describe('Text edits', () => {
+  it('should apply edits in offset order', () => {
+    const result = applyEdits('hello world', [
+      { start: 6, end: 11, newText: 'there' },
+      { start: 0, end: 0, newText: '> ' },
+    ]);
+
+    expect(result).toBe('> hello there');
+  });
+
+  it('should reject overlapping edits', () => {
+    expect(() =>
+      applyEdits('abcdef', [
+        { start: 0, end: 3, newText: 'x' },
+        { start: 2, end: 4, newText: 'y' },
+      ])
+    ).toThrow('Overlapping edits');
+  });
+});
+
+describe('Unified diff', () => {
+  it('should render hunks with context', () => {
+    const before = ['a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i'].join('\n') + '\n';
+    const after = before.replace('e\n', 'E\n');
+
+    expect(unifiedDiff(before, after, { oldPath: 'a.txt' })).toBe(
+      '--- a.txt\n+++ a.txt\n@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n'
+    );
+  });
+
+  it('should split distant changes into separate hunks', () => {
+    const lines = Array.from({ length: 20 }, (_, i) => `line ${i + 1}`);
+    const before = lines.join('\n') + '\n';
+    const after = before.replace('line 2\n', 'line two\n').replace('line 19\n', '');
+
+    const diff = unifiedDiff(before, after, { context: 1 });
+    expect(diff.match(/^@@/gm)).toHaveLength(2);
+    expect(diff).toContain('@@ -1,3 +1,3 @@');
+    expect(diff).toContain('@@ -18,3 +18,2 @@');
+  });
+
+  it('should mark a missing newline at end of file', () => {
+    expect(unifiedDiff('a\nb', 'a\nc\n')).toBe(
+      '--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n'
+    );
+  });
+
+  it('should return an empty string for identical texts', () => {
+    expect(unifiedDiff('same\n', 'same\n')).toBe('');
+  });
+});
@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"errors"
+	"sync"
+)
+
+// Run processes jobs concurrently and recovers from panics
+func Run(jobs []string, limit int) error {
+	if limit <= 0 || len(jobs) == 0 {
+		return errors.New("nothing to do")
+	}
+
+	var wg sync.WaitGroup
+	defer func() {
+		if r := recover(); r != nil {
+			wg.Wait()
+		}
+	}()
+
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if name == "" {
+				return
+			}
+		}(job)
+	}
+
+	filter := func(s string) bool {
+		return s != "" && s != "skip"
+	}
+	_ = filter
+
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+// Weekday is a day of the week
+type Weekday int
+
+// Days of the week
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+const (
+	_  = iota
+	KB = 1 << (10 * iota)
+	MB
+	GB
+)
+
+// Timeout is the default timeout in seconds
+const Timeout = 30
+
+const (
+	Pi       = 3.14159
+	TwoPi    = 2 * Pi
+	Greeting = "hello, " + "world"
+	Initial  = 'G'
+	Debug    = false
+	Mask     = 0755
+	Width    = len(Greeting)
+	Ratio    float32 = 1 / 4.0
+)
+
+const (
+	a, b = iota, iota * 10
+	c, d
+)
+
+const unknown = time.Second
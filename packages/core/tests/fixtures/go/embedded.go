@@ -0,0 +1,50 @@
+package shapes
+
+import "fmt"
+
+// Base holds identity shared by all shapes
+type Base struct {
+	ID string
+}
+
+// Describe returns a human readable identifier
+func (b Base) Describe() string {
+	return fmt.Sprintf("shape %s", b.ID)
+}
+
+// Rename changes the identifier in place
+func (b *Base) Rename(id string) {
+	b.ID = id
+}
+
+// Logger records messages
+type Logger struct {
+	lines []string
+}
+
+// Log appends a message
+func (l *Logger) Log(msg string) {
+	l.lines = append(l.lines, msg)
+}
+
+// Circle embeds Base by value and Logger by pointer
+type Circle struct {
+	Base
+	*Logger
+	Radius float64
+}
+
+// Area computes the circle's area
+func (c Circle) Area() float64 {
+	return 3.14159 * c.Radius * c.Radius
+}
+
+// Describe shadows the promoted Base.Describe
+func (c Circle) Describe() string {
+	return "circle " + c.ID
+}
+
+// Scale grows the radius
+func (c *Circle) Scale(factor float64) {
+	c.Radius *= factor
+}
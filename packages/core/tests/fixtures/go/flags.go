@@ -0,0 +1,77 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+)
+
+type Exporter struct {
+	verbose bool
+	out     *os.File
+}
+
+// Export writes the records, or only prints what it would write
+func (e *Exporter) Export(records []string, dryRun bool) error {
+	if dryRun {
+		for _, record := range records {
+			fmt.Println("would write", record)
+		}
+		return nil
+	}
+	for _, record := range records {
+		if _, err := e.out.WriteString(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Format renders a name for a heading or for running text
+func Format(name string, heading bool) string {
+	if !heading {
+		return name
+	} else {
+		return "# " + name
+	}
+}
+
+func run(e *Exporter, records []string) {
+	_ = e.Export(records, true)
+	_ = e.Export(records, false)
+	fmt.Println(Format("intro", true), Format("body", os.Getenv("H") != ""))
+}
+
+// NewExporter stores verbose; the flag is data
+func NewExporter(out *os.File, verbose bool) *Exporter {
+	return &Exporter{verbose: verbose, out: out}
+}
+
+// Enabled returns its flag
+func Enabled(name string, on bool) bool {
+	fmt.Println(name)
+	return on
+}
+
+// Log only adds a step when verbose is set
+func Log(message string, verbose bool) {
+	if verbose {
+		fmt.Println("log:", message)
+	}
+	os.Stderr.WriteString(message)
+}
+
+// Save passes its flag on besides branching on it
+func (e *Exporter) Save(records []string, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+	return e.Export(records, dryRun)
+}
+
+// Pick branches on a condition combining its flag
+func Pick(a, b string, first bool) string {
+	if first && a != "" {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,77 @@
+package generics
+
+import "fmt"
+
+// Number is satisfied by the built-in numeric types
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+// Stringer is a constraint with a method
+type Stringer interface {
+	comparable
+	String() string
+}
+
+// T is a package-level type sharing its name with type parameters below
+type T struct{ ID int }
+
+// Map applies f to every element of items
+func Map[T, U any](items []T, f func(T) U) []U {
+	out := make([]U, 0, len(items))
+	for _, item := range items {
+		out = append(out, f(item))
+	}
+	return out
+}
+
+// Sum adds up numbers of any numeric type
+func Sum[N Number](values ...N) N {
+	var total N
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// Max returns the larger of two numbers
+func Max[V ~int | ~float64](a, b V) V {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Pair holds two values of possibly different types
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Swap returns the pair with key and value exchanged
+func (p Pair[K, V]) Swap() Pair[V, K] {
+	return Pair[V, K]{Key: p.Value, Value: p.Key}
+}
+
+// Set is a set of comparable elements
+type Set[E comparable] map[E]struct{}
+
+// Add inserts an element
+func (s Set[E]) Add(e E) {
+	s[e] = struct{}{}
+}
+
+// describe formats values using their String method
+func describe[S Stringer, P interface{ *S }](values []S, label string, unused int) string {
+	return fmt.Sprint(label, len(values))
+}
+
+// Lookup finds a T by ID
+func Lookup(items []T, id int) *T {
+	for i := range items {
+		if items[i].ID == id {
+			return &items[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import "fmt"
+
+// SumSquares adds up the squares of the values, which can overflow an int
+func SumSquares(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v * v
+	}
+	return total
+}
+
+// Power raises base to exp by repeated multiplication
+func Power(base int32, exp int) int32 {
+	result := int32(1)
+	for i := 0; i < exp; i++ {
+		result *= base
+	}
+	return result
+}
+
+// Average divides before converting, so the fraction is lost
+func Average(values []int) float64 {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum / len(values))
+}
+
+// Scale halves x, but 1 / 2 is zero
+func Scale(x float64) float64 {
+	var half float64 = 1 / 2
+	return x * half
+}
+
+// Count counts the values, which stays within the length of the input
+func Count(values []int) int {
+	count := 0
+	for range values {
+		count++
+	}
+	for range values {
+		count += 1
+	}
+	return count
+}
+
+// Midpoint keeps the result of its division an integer
+func Midpoint(lo, hi int) int {
+	return lo + (hi-lo)/2
+}
+
+// Ratio converts its operands before dividing
+func Ratio(part, whole int) float64 {
+	return float64(part) / float64(whole)
+}
+
+// TableTotal sums a loop of constant length
+func TableTotal() int {
+	total := 0
+	for i := 0; i < 10; i++ {
+		total += i
+	}
+	return total
+}
+
+// PrintSum accumulates a sum it does not return
+func PrintSum(values []int) {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	fmt.Println(sum)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store guards its entries with a mutex
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+// Conn is a connection of the package that must be closed
+type Conn struct{}
+
+// Close releases the connection
+func (c *Conn) Close() error { return nil }
+
+// Dial opens a connection
+func Dial(addr string) (*Conn, error) { return &Conn{}, nil }
+
+// ReadConfig closes the file on every path
+func ReadConfig(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	return scanner.Text(), nil
+}
+
+// FirstLine leaks the file when the scan fails
+func FirstLine(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return "", scanner.Err()
+	}
+	f.Close()
+	return scanner.Text(), nil
+}
+
+// Fetch never closes the response body
+func Fetch(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// OpenLog hands the file to its caller
+func OpenLog(path string) (*os.File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// CountLines defers every close until all files are read
+func CountLines(paths []string) int {
+	total := 0
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			total++
+		}
+	}
+	return total
+}
+
+// Sizes skips the close when a stat fails
+func Sizes(paths []string) []int64 {
+	sizes := make([]int64, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil {
+			continue
+		}
+		sizes = append(sizes, info.Size())
+		f.Close()
+	}
+	return sizes
+}
+
+// Get returns without unlocking when the key is missing
+func (s *Store) Get(key string) (string, bool) {
+	s.mu.Lock()
+	value, ok := s.entries[key]
+	if !ok {
+		return "", false
+	}
+	s.mu.Unlock()
+	return value, true
+}
+
+// Set unlocks with defer
+func (s *Store) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = value
+}
+
+// Ping dials without closing and never stops its ticker
+func Ping(addr string) {
+	conn, err := Dial(addr)
+	if err != nil {
+		return
+	}
+	_ = conn
+	time.NewTicker(time.Second)
+}
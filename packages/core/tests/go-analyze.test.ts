@@ -0,0 +1,103 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { analyzeGo, loadGoFiles } from '../src/go/analyze';
+import { GoSyntaxError } from '../src/go/lexer';
+import { GoRuleRegistry } from '../src/go/rules';
+import { GO_SYMBOLS_SCHEMA_VERSION } from '../src/go/serialize';
+
+const store = `package store
+
+import "os"
+
+// Open opens the store
+func Open(name string) {
+	os.Remove(name)
+	load(name)
+}
+
+func load(name string) {
+	if name != "" {
+		load(name[1:])
+	}
+}
+`;
+
+describe('Go library entry point', () => {
+  let tempDir: string;
+
+  const write = (name: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(tempDir, name)), { recursive: true });
+    fs.writeFileSync(path.join(tempDir, name), content);
+  };
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-analyze-'));
+    write('store/store.go', store);
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('returns symbols, findings and the call graph with relative paths', async () => {
+    const result = await analyzeGo(tempDir);
+
+    expect(result.schema_version).toBe(GO_SYMBOLS_SCHEMA_VERSION);
+    expect(result.files.map((file) => file.path)).toEqual(['store/store.go']);
+    expect(result.files[0].functions.map((fn) => fn.name)).toEqual(['Open', 'load']);
+    expect(result.findings).toContainEqual(
+      expect.objectContaining({ rule: 'ignored-error', file: 'store/store.go', line: 7 })
+    );
+    expect(result.call_graph.nodes).toEqual([
+      { id: 'store.Open', package: 'store', file: 'store/store.go', line: 6 },
+      { id: 'store.load', package: 'store', file: 'store/store.go', line: 11 },
+    ]);
+    expect(result.call_graph.edges).toEqual([
+      { caller: 'store.Open', callee: 'store.load' },
+      { caller: 'store.load', callee: 'store.load' },
+    ]);
+    expect(result.call_graph.external_calls).toEqual([{ caller: 'store.Open', callee: 'os.Remove' }]);
+    expect(result.call_graph.cycles).toEqual([{ ids: ['store.load'], direct: true }]);
+  });
+
+  it('returns a frozen plain copy that survives a JSON round trip', async () => {
+    const result = await analyzeGo(tempDir);
+
+    expect(JSON.parse(JSON.stringify(result))).toEqual(result);
+    expect(Object.isFrozen(result)).toBe(true);
+    expect(Object.isFrozen(result.files[0].functions[0])).toBe(true);
+    expect(() => {
+      'use strict';
+      (result.findings as unknown[]).push({});
+    }).toThrow(TypeError);
+  });
+
+  it('takes overlays, rule registries and discovery options', async () => {
+    write('store/store_test.go', 'package store\n');
+    const overlay = { [path.join(tempDir, 'store', 'draft.go')]: 'package store\n\nfunc Draft() {}\n' };
+    const result = await analyzeGo(tempDir, {
+      overlay,
+      registry: new GoRuleRegistry(),
+      discover: { includeTests: false },
+    });
+
+    expect(result.files.map((file) => file.path)).toEqual(['store/draft.go', 'store/store.go']);
+    expect(result.findings).toEqual([]);
+    expect((await loadGoFiles(tempDir, { overlay })).map((file) => path.basename(file.filePath))).toEqual([
+      'draft.go',
+      'store.go',
+      'store_test.go',
+    ]);
+  });
+
+  it('rejects on syntax errors and aborted signals', async () => {
+    const controller = new AbortController();
+    controller.abort(new Error('cancelled'));
+    await expect(analyzeGo(tempDir, { signal: controller.signal })).rejects.toThrow('cancelled');
+
+    write('store/broken.go', 'package store\n\nfunc {\n');
+    await expect(analyzeGo(tempDir)).rejects.toThrow(GoSyntaxError);
+  });
+});
@@ -0,0 +1,137 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findUnnecessaryAnyReturns } from '../src/go/any-returns';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package conf
+
+type Config struct{ Name string }
+
+type Loader interface {
+	Load() interface{}
+}
+
+func Default(name string) any {
+	if name == "" {
+		return nil
+	}
+	return &Config{Name: name}
+}
+
+func Count(items []string) (interface{}, error) {
+	if len(items) == 0 {
+		return 0, errEmpty
+	}
+	return len(items), nil
+}
+
+func Mixed(ok bool) any {
+	if ok {
+		return 1
+	}
+	return "one"
+}
+
+func Zero(ok bool) any {
+	if ok {
+		return Config{}
+	}
+	return nil
+}
+
+type file struct{}
+
+func (f file) Load() interface{} { return &Config{} }
+
+func Asserted() any { return "x" }
+
+func Stored() any { return 2 }
+
+var hooks = []func() any{Stored}
+
+func use() string { return Asserted().(string) }
+`;
+
+describe('Go interface returns', () => {
+  const file = parseGoFile(source, 'conf.go');
+
+  it('suggests the concrete type every return yields', () => {
+    const findings = findUnnecessaryAnyReturns([file]);
+
+    expect(findings.map(finding => [finding.function, finding.result, finding.type])).toEqual([
+      ['Default', 0, '*Config'],
+      ['Count', 0, 'int'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'unnecessary-any-return',
+      severity: 'low',
+      line: 9,
+      column: 27,
+      fix: 'func Default(name string) *Config',
+    });
+    expect(findings[1].fix).toBe('func Count(items []string) (int, error)');
+    expect(findings[1].message).toBe('Count always returns int as interface{}; declare the result as int');
+  });
+
+  it('skips heterogeneous returns and nil for non-nilable types', () => {
+    const names = findUnnecessaryAnyReturns([file]).map(finding => finding.function);
+
+    expect(names).not.toContain('Mixed');
+    expect(names).not.toContain('Zero');
+  });
+
+  it('skips interface implementations, function values and asserted results', () => {
+    const names = findUnnecessaryAnyReturns([file]).map(finding => finding.function);
+
+    expect(names).not.toContain('file.Load');
+    expect(names).not.toContain('Stored');
+    expect(names).not.toContain('Asserted');
+    expect(defaultGoRuleRegistry().run([file]).filter(f => f.rule === 'unnecessary-any-return')).toHaveLength(2);
+  });
+
+  it('skips methods of standard library interfaces and of types used as interface values', () => {
+    const tar = parseGoFile(
+      `package tar
+
+import "io/fs"
+
+type Header struct{ Name string }
+
+type headerFileInfo struct{ h *Header }
+
+func (fi headerFileInfo) Sys() any { return fi.h }
+
+func (h *Header) FileInfo() fs.FileInfo { return headerFileInfo{h} }
+
+type entry struct{ name string }
+
+func (e entry) Title() any { return e.name }
+
+func (e entry) key() any { return e.name }
+
+type plain struct{ name string }
+
+func (p plain) Label() any { return p.name }
+
+func newPlain() plain { return plain{} }
+
+func register(v any) {}
+
+func init() { register(&entry{}) }
+`,
+      'tar.go'
+    );
+
+    expect(findUnnecessaryAnyReturns([tar]).map(finding => finding.function)).toEqual(['entry.key', 'plain.Label']);
+  });
+
+  it('passes the sample fixture', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+    expect(findUnnecessaryAnyReturns([sample])).toEqual([]);
+  });
+});
@@ -0,0 +1,116 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { diffGoApi, formatGoApi, goApiSurface } from '../src/go/api';
+import { parseGoFile } from '../src/go/parser';
+
+const fixtures = path.join(__dirname, 'fixtures');
+const samplePath = path.join(fixtures, 'go', 'sample.go');
+
+const source = `package store
+
+import "io"
+
+// ErrClosed is returned after Close
+var ErrClosed error
+
+var Default = NewStore(io.Discard)
+
+// Store keeps values
+type Store[K comparable, V any] struct {
+	io.Writer
+	// Name labels the store
+	Name  string
+	items map[K]V
+}
+
+type Codec interface {
+	io.Closer
+	Encode(any) ([]byte, error)
+	decode([]byte) any
+}
+
+type (
+	// Mode selects how values are kept
+	Mode int
+	logger struct{}
+)
+
+func NewStore(w io.Writer) *Store[string, int] { return nil }
+
+func (s *Store[K, V]) Get(key K) (V, bool) { var v V; return v, false }
+
+func (l logger) Print(string) {}
+`;
+
+describe('Go exported API surface', () => {
+  it('lists the exported symbols of the sample with their documentation', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+    expect(formatGoApi(goApiSurface([sample], { root: fixtures }))).toBe(
+      [
+        'pkg go, const API_VERSION = "1.0.0"',
+        'pkg go, const MAX_RETRIES = 3',
+        'pkg go, func CalculateFibonacci(int) int',
+        'pkg go, func NewDataProcessor(map[string]string) *DataProcessor',
+        'pkg go, func ProcessComplexData([]string) ([]string, error)',
+        'pkg go, method (*DataProcessor) GetCacheSize() int',
+        'pkg go, method (*DataProcessor) ProcessData([]string) []string',
+        'pkg go, type DataProcessor struct',
+        '',
+      ].join('\n')
+    );
+  });
+
+  it('lists fields, interface methods, generics and undocumented symbols', () => {
+    const file = parseGoFile(source, '/repo/store/store.go');
+    const listing = formatGoApi(goApiSurface([file], { root: '/repo' }));
+
+    expect(listing.split('\n')).toEqual([
+      'pkg store, func NewStore(io.Writer) *Store[string, int] // undocumented',
+      'pkg store, method (*Store[K, V]) Get(K) (V, bool) // undocumented',
+      'pkg store, type Codec interface // undocumented',
+      'pkg store, type Codec interface, Encode(any) ([]byte, error) // undocumented',
+      'pkg store, type Codec interface, embedded io.Closer // undocumented',
+      'pkg store, type Mode int',
+      'pkg store, type Store[K comparable, V any] struct',
+      'pkg store, type Store[K comparable, V any] struct, Name string',
+      'pkg store, type Store[K comparable, V any] struct, embedded io.Writer // undocumented',
+      'pkg store, var Default *Store[string, int] // undocumented',
+      'pkg store, var ErrClosed error',
+      '',
+    ]);
+  });
+
+  it('describes entries with their kind and position', () => {
+    const file = parseGoFile(source, '/repo/store/store.go');
+    const get = goApiSurface([file], { root: '/repo' }).find(entry => entry.name === 'Store.Get');
+
+    expect(get).toEqual({
+      package: 'store',
+      kind: 'method',
+      name: 'Store.Get',
+      declaration: 'method (*Store[K, V]) Get(K) (V, bool)',
+      documented: false,
+      filePath: '/repo/store/store.go',
+      line: 32,
+    });
+    expect(goApiSurface([parseGoFile(source, '/repo/store/store_test.go')], { root: '/repo' })).toEqual([]);
+  });
+
+  it('diffs listings by declaration, ignoring documentation', () => {
+    const before = parseGoFile(source, '/repo/store/store.go');
+    const after = parseGoFile(
+      source.replace('func NewStore(w io.Writer)', '// NewStore makes a store\nfunc NewStore(w io.Writer, size int)')
+        .replace('\tMode int', '\tMode uint8'),
+      '/repo/store/store.go'
+    );
+    const old = formatGoApi(goApiSurface([before], { root: '/repo' }));
+
+    expect(diffGoApi(old, formatGoApi(goApiSurface([after], { root: '/repo' })))).toEqual({
+      added: ['pkg store, func NewStore(io.Writer, int) *Store[string, int]', 'pkg store, type Mode uint8'],
+      removed: ['pkg store, func NewStore(io.Writer) *Store[string, int]', 'pkg store, type Mode int'],
+    });
+    expect(diffGoApi(old, old)).toEqual({ added: [], removed: [] });
+  });
+});
@@ -0,0 +1,111 @@
+import { describe, it, expect } from '@jest/globals';
+import { findUncheckedTypeAssertions, guardTypeAssertions } from '../src/go/assertions';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+
+const source = `package cache
+
+var cache map[string]interface{}
+
+func Name(key string) {
+	name := cache[key].(string)
+	println(name)
+	println(len(name))
+	println("done")
+}
+
+func Size(key string) int {
+	return cache[key].(int) * 2
+}
+
+func Checked(key string) {
+	if n, ok := cache[key].(int); ok {
+		println(n)
+	}
+}
+
+func Describe(v interface{}) {
+	switch v.(type) {
+	case string:
+		println(v.(string))
+	case error:
+		println(cache["other"].(error))
+	}
+}
+
+func Safe(key string) (n int) {
+	defer func() {
+		recover()
+	}()
+	return cache[key].(int)
+}
+
+func Count(key string) int {
+	count := cache[key].(int)
+	return count
+}
+`;
+
+describe('Go unchecked type assertions', () => {
+  const file = parseGoFile(source, 'cache.go');
+
+  it('flags single-result assertions outside recover and type switches', () => {
+    const findings = findUncheckedTypeAssertions([file]);
+
+    expect(findings.map((finding) => [finding.line, finding.expression, finding.type])).toEqual([
+      [6, 'cache[key]', 'string'],
+      [13, 'cache[key]', 'int'],
+      [27, 'cache["other"]', 'error'],
+      [39, 'cache[key]', 'int'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'unchecked-type-assertion',
+      severity: 'medium',
+      message:
+        'cache[key].(string) panics when cache[key] does not hold a string; use name, ok := cache[key].(string) and check ok',
+      fix: 'name, ok := cache[key].(string)',
+    });
+    expect(findings[3].fix).toBe('count, ok := cache[key].(int)');
+  });
+
+  it('wraps the uses of the asserted value in an if ok block', () => {
+    const result = guardTypeAssertions(file);
+
+    expect(result.guarded).toEqual([{ line: 6, column: 10 }]);
+    expect(result.source).toContain(
+      '\tif name, ok := cache[key].(string); ok {\n\t\tprintln(name)\n\t\tprintln(len(name))\n\t}\n\tprintln("done")\n'
+    );
+    expect(findUncheckedTypeAssertions([parseGoFile(result.source, 'cache.go')]).map((f) => f.line)).toEqual([
+      14, 28, 40,
+    ]);
+  });
+
+  it('picks another flag name when ok is taken', () => {
+    const taken = parseGoFile(
+      'package cache\n\nfunc F(x any, ok bool) {\n\ts := x.(string)\n\tif ok {\n\t\tprintln(s)\n\t}\n}\n',
+      'f.go'
+    );
+
+    expect(guardTypeAssertions(taken).source).toBe(
+      'package cache\n\nfunc F(x any, ok bool) {\n\tif s, ok2 := x.(string); ok2 {\n\t\tif ok {\n\t\t\tprintln(s)\n\t\t}\n\t}\n}\n'
+    );
+  });
+
+  it('refuses assertions it cannot guard', () => {
+    expect(() => guardTypeAssertions(file, { line: 13 })).toThrow(
+      'Cannot guard cache[key].(int): the assertion does not declare a variable of its own'
+    );
+    expect(() => guardTypeAssertions(file, { line: 39 })).toThrow(
+      'Cannot guard cache[key].(int): the function would end without a return when the assertion fails'
+    );
+    expect(() => guardTypeAssertions(file, { line: 17 })).toThrow(GoRefactorError);
+
+    const declares = parseGoFile(
+      'package p\n\nfunc f(x any) int {\n\ts := x.(string)\n\tn := len(s)\n\tprintln(s)\n\treturn n\n}\n',
+      '/src/p/p.go'
+    );
+    expect(() => guardTypeAssertions(declares, { line: 4 })).toThrow(
+      'Cannot guard x.(string): n would be declared inside the if block but is used after it'
+    );
+  });
+});
@@ -0,0 +1,122 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  compareGoBaseline,
+  createGoBaseline,
+  fingerprintGoFindings,
+  GoBaselineError,
+  parseGoBaseline,
+  pruneGoBaseline,
+  readGoBaseline,
+  serializeGoBaseline,
+  writeGoBaseline,
+} from '../src/go/baseline';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const root = path.join(os.tmpdir(), 'refactogent-baseline');
+
+const source = `package legacy
+
+import "os"
+
+func Save(path string, data []byte) {
+	os.WriteFile(path, data, 0o644)
+}
+
+func Remove(path string) {
+	os.Remove(path)
+	os.Remove(path + ".bak")
+}
+`;
+
+const analyze = (text: string) => {
+  const files = [parseGoFile(text, path.join(root, 'legacy', 'legacy.go'))];
+  return { files, findings: defaultGoRuleRegistry().run(files) };
+};
+
+describe('Go findings baseline', () => {
+  it('should record every finding with a stable fingerprint', () => {
+    const { files, findings } = analyze(source);
+    const baseline = createGoBaseline(findings, files, { root });
+
+    expect(baseline.version).toBe(2);
+    expect(baseline.entries.map(entry => [entry.rule, entry.filePath, entry.symbol])).toEqual([
+      ['ignored-error', 'legacy/legacy.go', 'Save'],
+      ['ignored-error', 'legacy/legacy.go', 'Remove'],
+      ['ignored-error', 'legacy/legacy.go', 'Remove'],
+    ]);
+    expect(baseline.entries[0].fingerprint).toMatch(/^[0-9a-f]{16}$/);
+    expect(new Set(baseline.entries.map(entry => entry.fingerprint)).size).toBe(3);
+    expect(createGoBaseline(findings, files, { root })).toEqual(baseline);
+  });
+
+  it('should keep suppressing findings after unrelated edits move them', () => {
+    const { files, findings } = analyze(source);
+    const baseline = createGoBaseline(findings, files, { root });
+
+    const edited = source
+      .replace(
+        'import "os"',
+        'import (\n\t"fmt"\n\t"os"\n)\n\n// Greet says hello\nfunc Greet() { fmt.Println("hi") }'
+      )
+      .replace('\tos.WriteFile', '\t\tos.WriteFile')
+      .concat('\nfunc Clean(path string) {\n\tos.Remove(path + ".tmp")\n}\n');
+    const after = analyze(edited);
+    const comparison = compareGoBaseline(after.findings, after.files, baseline, { root });
+
+    expect(comparison.suppressed).toHaveLength(3);
+    expect(comparison.findings.map(finding => finding.line)).toEqual([21]);
+    expect(comparison.stale).toEqual([]);
+  });
+
+  it('should report fixed entries as stale and prune them on update', () => {
+    const { files, findings } = analyze(source);
+    const baseline = createGoBaseline(findings, files, { root });
+
+    const fixed = analyze(
+      source.replace(
+        '\tos.Remove(path)\n',
+        '\tif err := os.Remove(path); err != nil {\n\t\treturn\n\t}\n'
+      )
+    );
+    const comparison = compareGoBaseline(fixed.findings, fixed.files, baseline, { root });
+    expect(comparison.findings).toEqual([]);
+    expect(comparison.stale).toHaveLength(1);
+
+    const pruned = pruneGoBaseline(baseline, fixed.findings, fixed.files, { root });
+    expect(pruned.entries).toHaveLength(2);
+    expect(pruned.entries).not.toContainEqual(comparison.stale[0]);
+
+    // A new finding is not added by pruning
+    const regressed = analyze(
+      source.concat('\nfunc Touch(path string) { os.Remove(path + ".tmp") }\n')
+    );
+    const kept = pruneGoBaseline(baseline, regressed.findings, regressed.files, { root });
+    expect(kept.entries).toEqual(baseline.entries);
+    expect(fingerprintGoFindings(regressed.findings, regressed.files, { root })).toHaveLength(4);
+  });
+
+  it('should round-trip through a file and reject unreadable baselines', async () => {
+    const { files, findings } = analyze(source);
+    const baseline = createGoBaseline(findings, files, { root });
+    const directory = fs.mkdtempSync(path.join(os.tmpdir(), 'baseline-'));
+    const filePath = path.join(directory, 'ci', 'baseline.json');
+
+    await writeGoBaseline(filePath, baseline);
+    const text = fs.readFileSync(filePath, 'utf-8');
+    expect(text).toBe(serializeGoBaseline(baseline));
+    expect(text.endsWith('}\n')).toBe(true);
+    expect((await readGoBaseline(filePath)).entries).toHaveLength(3);
+
+    expect(() => parseGoBaseline('{')).toThrow(GoBaselineError);
+    expect(() => parseGoBaseline('{"version": 1, "entries": []}')).toThrow(
+      'Unsupported baseline version 1; expected 2'
+    );
+    expect(() => parseGoBaseline('{"version": 2, "entries": [{}]}')).toThrow(
+      'Baseline entries must each have a fingerprint'
+    );
+  });
+});
@@ -0,0 +1,99 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { benchmarkTestPath, generateBenchmarks, writeBenchmarks } from '../src/go/benchmark';
+import { parseGoFile } from '../src/go/parser';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+describe('Go benchmark generation', () => {
+  it('should scaffold a b.N loop with allocations reported', () => {
+    const result = generateBenchmarks(sample(), { functions: ['CalculateFibonacci'] });
+
+    expect(result.functions).toEqual(['CalculateFibonacci']);
+    expect(result.testPath).toBe(samplePath.replace(/\.go$/, '_bench_test.go'));
+    expect(result.content).toContain('package main\n\nimport "testing"\n');
+    expect(result.content).toContain(
+      [
+        'var benchmarkCalculateFibonacciResult int',
+        '',
+        'func BenchmarkCalculateFibonacci(b *testing.B) {',
+        '\tn := 20',
+        '\tb.ReportAllocs()',
+        '\tfor i := 0; i < b.N; i++ {',
+        '\t\tbenchmarkCalculateFibonacciResult = CalculateFibonacci(n)',
+        '\t}',
+        '}',
+      ].join('\n')
+    );
+  });
+
+  it('should build slice inputs before resetting the timer', () => {
+    const result = generateBenchmarks(
+      parseGoFile(
+        'package text\n\nfunc join(_ string, parts []string, i int, flags ...bool) (string, error) { return "", nil }\n',
+        'text.go'
+      )
+    );
+
+    expect(result.content).toContain('import (\n\t"strconv"\n\t"testing"\n)');
+    expect(result.content).toContain(
+      [
+        'var (',
+        '\tbenchmarkJoinResult0 string',
+        '\tbenchmarkJoinResult1 error',
+        ')',
+        '',
+        'func Benchmark_join(b *testing.B) {',
+        '\targ0 := "The quick brown fox jumps over the lazy dog"',
+        '\tparts := make([]string, 100)',
+        '\tfor i := range parts {',
+        '\t\tparts[i] = "item" + strconv.Itoa(i)',
+        '\t}',
+        '\targ2 := 20',
+        '\tflags := make([]bool, 100)',
+        '\tfor i := range flags {',
+        '\t\tflags[i] = i%2 == 0',
+        '\t}',
+        '\tb.ReportAllocs()',
+        '\tb.ResetTimer()',
+        '\tfor i := 0; i < b.N; i++ {',
+        '\t\tbenchmarkJoinResult0, benchmarkJoinResult1 = join(arg0, parts, arg2, flags...)',
+      ].join('\n')
+    );
+  });
+
+  it('should skip functions whose inputs cannot be synthesized, with a reason', () => {
+    const result = generateBenchmarks(sample(), {
+      functions: ['NewDataProcessor', 'GetCacheSize', 'Missing', 'ProcessComplexData'],
+    });
+    const reasons = Object.fromEntries(result.skipped.map(skip => [skip.name, skip.reason]));
+
+    expect(result.functions).toEqual(['ProcessComplexData']);
+    expect(reasons).toEqual({
+      NewDataProcessor: 'takes a map[string]string parameter, which cannot be synthesized cheaply',
+      '(dp *DataProcessor) GetCacheSize': 'method; needs a receiver value',
+      Missing: 'not declared in this file',
+    });
+  });
+
+  it('should write nothing when no function can be benchmarked', async () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'benchmark-'));
+    const filePath = path.join(dir, 'main.go');
+    fs.writeFileSync(filePath, 'package main\n\nfunc main() {}\n\nfunc Run(p *int) {}\n');
+
+    try {
+      const result = await writeBenchmarks(filePath);
+      expect(result.content).toBe('');
+      expect(result.skipped.map(skip => skip.reason)).toEqual([
+        'main cannot be called',
+        'takes a *int parameter, which cannot be synthesized cheaply',
+      ]);
+      expect(fs.existsSync(benchmarkTestPath(filePath))).toBe(false);
+    } finally {
+      fs.rmSync(dir, { recursive: true, force: true });
+    }
+  });
+});
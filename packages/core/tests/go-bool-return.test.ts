@@ -0,0 +1,126 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { findRedundantBoolReturns, simplifyBoolReturns } from '../src/go/bool-return';
+import { GoRefactorError } from '../src/go/refactor';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const source = `package check
+
+type Flag bool
+
+func IsAdult(age int) bool {
+	if age >= 18 {
+		return true
+	} else {
+		return false
+	}
+}
+
+func Empty(s string, ready func() bool) bool {
+	if s != "" && ready() {
+		return false
+	}
+	return true
+}
+
+func NotEqual(a, b int) bool {
+	if a == b {
+		return false
+	}
+	return true
+}
+
+func Logs(ok bool) bool {
+	if ok {
+		println("ok")
+		return true
+	}
+	return false
+}
+
+func Named(f Flag) bool {
+	if f {
+		return true
+	}
+	return false
+}
+
+func Init(m map[string]int) bool {
+	if _, ok := m["k"]; ok {
+		return true
+	}
+	return false
+}
+
+func Count(n int) int {
+	if n > 0 {
+		return 1
+	}
+	return 0
+}
+
+func Closure() func(int) bool {
+	return func(n int) bool {
+		if n%2 == 0 {
+			return true
+		}
+		// odd numbers
+		return false
+	}
+}
+`;
+
+describe('Go boolean returns', () => {
+  const file = parseGoFile(source, 'check.go');
+
+  it('reports ifs that return true and false', () => {
+    const findings = findRedundantBoolReturns([file]);
+
+    expect(findings.map(finding => [finding.line, finding.fix])).toEqual([
+      [6, 'return age >= 18'],
+      [14, 'return !(s != "" && ready())'],
+      [21, 'return a != b'],
+    ]);
+    expect(findings[1]).toMatchObject({
+      rule: 'redundant-bool-return',
+      severity: 'low',
+      column: 2,
+      condition: 's != "" && ready()',
+      negated: true,
+    });
+    expect(
+      defaultGoRuleRegistry()
+        .run([file])
+        .filter(finding => finding.rule === 'redundant-bool-return')
+    ).toHaveLength(3);
+  });
+
+  it('skips branches with side effects, init statements and named bools', () => {
+    const lines = findRedundantBoolReturns([file]).map(finding => finding.line);
+
+    // Logs, Named, Init, Count and the commented closure
+    for (const line of [28, 36, 43, 50, 58]) {
+      expect(lines).not.toContain(line);
+    }
+  });
+
+  it('rewrites one if or every one in the file', () => {
+    const one = simplifyBoolReturns(file, { line: 6 });
+    expect(one.source).toContain('func IsAdult(age int) bool {\n\treturn age >= 18\n}');
+    expect(one.simplified).toEqual([{ line: 6, column: 2 }]);
+
+    const all = simplifyBoolReturns(file);
+    expect(all.simplified.map(position => position.line)).toEqual([6, 14, 21]);
+    // The condition keeps its short-circuit evaluation, negated as a whole
+    expect(all.source).toContain('func Empty(s string, ready func() bool) bool {\n\treturn !(s != "" && ready())\n}');
+    expect(all.source).toContain('func NotEqual(a, b int) bool {\n\treturn a != b\n}');
+    expect(findRedundantBoolReturns([parseGoFile(all.source, 'check.go')])).toEqual([]);
+  });
+
+  it('refuses lines without the pattern', () => {
+    expect(() => simplifyBoolReturns(file, { line: 28 })).toThrow(GoRefactorError);
+    expect(() => simplifyBoolReturns(file, { line: 28 })).toThrow(
+      'Line 28 has no if statement returning true and false'
+    );
+  });
+});
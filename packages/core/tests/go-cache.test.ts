@@ -0,0 +1,143 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  contentHash,
+  DiskCache,
+  GO_ANALYZER_VERSION,
+  IncrementalGoAnalyzer,
+  MemoryCache,
+} from '../src/go/cache';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const embeddedPath = path.join(__dirname, 'fixtures', 'go', 'embedded.go');
+
+describe('Incremental Go analysis', () => {
+  let tempDir: string;
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-cache-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should key entries on a SHA-256 of the content and analyzer version', () => {
+    const hash = contentHash('package main\n');
+
+    expect(hash).toMatch(/^[0-9a-f]{64}$/);
+    expect(contentHash('package main\n')).toBe(hash);
+    expect(contentHash('package other\n')).not.toBe(hash);
+    expect(contentHash('package main\n', `${GO_ANALYZER_VERSION}-next`)).not.toBe(hash);
+  });
+
+  it('should only re-parse files whose content changed', async () => {
+    const analyzer = new IncrementalGoAnalyzer();
+    const source = 'package main\n\nfunc A() {}\n';
+
+    const first = await analyzer.analyzeFile('main.go', source);
+    const second = await analyzer.analyzeFile('main.go', source);
+    const changed = await analyzer.analyzeFile('main.go', source + '\nfunc B() {}\n');
+
+    expect(first.cached).toBe(false);
+    expect(second.cached).toBe(true);
+    expect(second.symbols).toBe(first.symbols);
+    expect(changed.cached).toBe(false);
+    expect(changed.symbols.functions.map(f => f.name)).toEqual(['A', 'B']);
+    expect(analyzer.getStats()).toEqual({ hits: 1, misses: 2 });
+  });
+
+  it('should ignore entries written by another analyzer version', async () => {
+    const cache = new MemoryCache();
+    const source = 'package main\n\nfunc A() {}\n';
+    const stale = await new IncrementalGoAnalyzer(cache).analyzeFile('main.go', source);
+
+    // Simulate an entry from an older analyzer for the same content
+    cache.put('main.go', contentHash(source, '0'), stale.symbols);
+    const result = await new IncrementalGoAnalyzer(cache).analyzeFile('main.go', source);
+
+    expect(cache.get('main.go', contentHash(source, '0'))).toBeUndefined();
+    expect(result.cached).toBe(false);
+  });
+
+  it('should persist symbol tables to disk across analyzer instances', async () => {
+    const cacheDir = path.join(tempDir, 'cache');
+    await new IncrementalGoAnalyzer(new DiskCache(cacheDir)).analyzeFiles([
+      samplePath,
+      embeddedPath,
+    ]);
+
+    const analyzer = new IncrementalGoAnalyzer(new DiskCache(cacheDir));
+    const [sample, embedded] = await analyzer.analyzeFiles([samplePath, embeddedPath]);
+
+    expect(analyzer.getStats()).toEqual({ hits: 2, misses: 0 });
+    expect(sample.symbols.functions.map(f => f.name)).toContain('CalculateFibonacci');
+    // Methods attached to types are the same objects as the method list
+    const circle = embedded.symbols.types.find(t => t.name === 'Circle');
+    expect(circle?.methods.map(m => m.name)).toEqual(['Area', 'Describe', 'Scale']);
+    expect(embedded.symbols.methods).toContain(circle?.methods[0]);
+  });
+
+  it('should treat corrupt disk entries as misses', async () => {
+    const cacheDir = path.join(tempDir, 'cache');
+    const cache = new DiskCache(cacheDir);
+    await new IncrementalGoAnalyzer(cache).analyzeFile(samplePath);
+    for (const entry of fs.readdirSync(cacheDir)) {
+      fs.writeFileSync(path.join(cacheDir, entry), '{not json');
+    }
+
+    const result = await new IncrementalGoAnalyzer(cache).analyzeFile(samplePath);
+
+    expect(result.cached).toBe(false);
+  });
+
+  it('should parse a file requested concurrently only once', async () => {
+    const analyzer = new IncrementalGoAnalyzer(new DiskCache(path.join(tempDir, 'cache')));
+
+    const results = await Promise.all(
+      Array.from({ length: 5 }, () => analyzer.analyzeFile(samplePath))
+    );
+
+    expect(analyzer.getStats()).toEqual({ hits: 4, misses: 1 });
+    expect(results.filter(result => !result.cached)).toHaveLength(1);
+    expect(new Set(results.map(result => result.symbols)).size).toBe(1);
+  });
+
+  it('should produce identical output across 100 concurrent runs', async () => {
+    const filePaths = [samplePath, embeddedPath];
+    for (let i = 0; i < 6; i++) {
+      const filePath = path.join(tempDir, `gen${i}.go`);
+      const funcs = Array.from(
+        { length: 6 - i },
+        (_, j) => `func F${j}(n int) int {\n\tif n > ${j} {\n\t\treturn n\n\t}\n\treturn ${j}\n}\n`
+      );
+      fs.writeFileSync(filePath, `package gen\n\n${funcs.join('\n')}`);
+      filePaths.push(filePath);
+    }
+    const cacheDir = path.join(tempDir, 'cache');
+    const cache = new DiskCache(cacheDir);
+    const shared = new IncrementalGoAnalyzer(cache);
+
+    const runs = await Promise.all(
+      Array.from({ length: 100 }, (_, run) =>
+        // Alternate between a shared analyzer and fresh ones on the same cache
+        (run % 2 === 0 ? new IncrementalGoAnalyzer(cache) : shared).analyzeFiles(filePaths, {
+          concurrency: 1 + (run % 5),
+        })
+      )
+    );
+
+    const outputs = new Set(
+      runs.map(results => JSON.stringify(results.map(({ symbols, hash }) => ({ hash, symbols }))))
+    );
+    expect(outputs.size).toBe(1);
+    expect(runs[0].map(result => path.basename(result.symbols.filePath))).toEqual(
+      filePaths.map(filePath => path.basename(filePath))
+    );
+    // Only whole entries are left behind, one per file
+    expect(fs.readdirSync(cacheDir)).toHaveLength(filePaths.length);
+    expect(fs.readdirSync(cacheDir).filter(entry => entry.endsWith('.tmp'))).toEqual([]);
+  });
+});
@@ -0,0 +1,230 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import {
+  annotateCallMetrics,
+  buildGoCallGraph,
+  callGraphMetrics,
+  callGraphToDot,
+  findRecursionCycles,
+  GoCallGraph,
+  GoCallGraphBuilder,
+} from '../src/go/callgraph';
+import { complexityCandidates } from '../src/go/complexity';
+import { inlineFunction } from '../src/go/inline-function';
+import { extractGoFileSymbols } from '../src/go/symbols';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const storeSource = `package store
+
+import "strings"
+
+type Store struct{}
+
+func (s *Store) Put(key string) { s.normalize(key) }
+func (s *Store) normalize(key string) string { return strings.TrimSpace(key) }
+`;
+
+const appSource = `package app
+
+import (
+	"example.com/project/store"
+	"os"
+)
+
+func Run() {
+	s := &store.Store{}
+	s.Put(os.Getenv("KEY"))
+}
+`;
+
+const parserSource = `package parser
+
+func parseExpr(s string) int {
+	if s == "(" {
+		return parseGroup(s[1:])
+	}
+	return parseTerm(s)
+}
+
+func parseGroup(s string) int { return parseExpr(s) }
+
+func parseTerm(s string) int { return len(s) }
+
+func walk(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return walk(n - 1)
+}
+`;
+
+describe('Go call graph', () => {
+  it('should link method calls through receivers', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath),
+    ]);
+
+    expect([...graph.callees.get('main.DataProcessor.ProcessData')!]).toEqual([
+      'main.DataProcessor.processItem',
+    ]);
+    expect([...graph.callees.get('main.ProcessComplexData')!].sort()).toEqual([
+      'main.processTypeA',
+      'main.processTypeB',
+    ]);
+  });
+
+  it('should record unresolved calls as external', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath),
+    ]);
+
+    expect([...graph.externalCalls.get('main.ProcessComplexData')!].sort()).toEqual([
+      'fmt.Errorf',
+      'fmt.Sprintf',
+      'strings.HasPrefix',
+    ]);
+    expect(graph.externalCalls.has('main.CalculateFibonacci')).toBe(false);
+  });
+
+  it('should resolve package-qualified calls across packages', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(storeSource, 'store/store.go'),
+      parseGoFile(appSource, 'app/app.go'),
+    ]);
+
+    expect([...graph.callees.get('app.Run')!]).toEqual(['store.Store.Put']);
+    expect([...graph.externalCalls.get('app.Run')!]).toEqual(['os.Getenv']);
+    expect([...graph.callees.get('store.Store.Put')!]).toEqual(['store.Store.normalize']);
+  });
+
+  it('should export DOT with shapes, colors and one external node', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath),
+    ]);
+    const dot = callGraphToDot(graph, { name: 'sample' });
+
+    expect(dot.startsWith('digraph "sample" {\n')).toBe(true);
+    expect(dot).toContain(
+      '"main.DataProcessor.processItem" [label="DataProcessor.processItem", shape=box, fillcolor=lightgrey];'
+    );
+    expect(dot).toContain(
+      '"main.NewDataProcessor" [label="NewDataProcessor", shape=ellipse, fillcolor=lightblue];'
+    );
+    expect(dot).toContain('"main.DataProcessor.ProcessData" -> "main.DataProcessor.processItem";');
+    expect(dot.match(/^ {2}"external" \[/gm)).toHaveLength(1);
+    expect(dot).toContain(
+      '"main.processTypeB" -> "external" [style=dashed, tooltip="fmt.Sprintf, strings.ToUpper"];'
+    );
+    expect(dot.trimEnd().endsWith('}')).toBe(true);
+  });
+
+  it('should label nodes with packages when several are present', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(storeSource, 'store/store.go'),
+      parseGoFile(appSource, 'app/app.go'),
+    ]);
+    const dot = callGraphToDot(graph, { hideExternal: true });
+
+    expect(dot).toContain('"app.Run" [label="app.Run", shape=ellipse, fillcolor=lightblue];');
+    expect(dot).not.toContain('"external"');
+  });
+
+  it('should compute fan-in and fan-out per function', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath),
+    ]);
+    const metrics = callGraphMetrics(graph);
+
+    expect(metrics.get('main.processTypeA')).toEqual({ fanIn: 1, fanOut: 1 });
+    expect(metrics.get('main.processTypeB')?.fanIn).toBe(1);
+    // Two declared callees plus three external calls
+    expect(metrics.get('main.ProcessComplexData')).toEqual({ fanIn: 0, fanOut: 5 });
+  });
+
+  it('should annotate symbols and rank candidates by coupling', () => {
+    const file = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const symbols = extractGoFileSymbols(file);
+    annotateCallMetrics([symbols], buildGoCallGraph([file]));
+    const all = [...symbols.functions, ...symbols.methods];
+
+    expect(symbols.functions.find(f => f.name === 'processTypeA')).toMatchObject({
+      fanIn: 1,
+      fanOut: 1,
+    });
+    expect(
+      complexityCandidates(all, 0, { sortBy: 'fanOut' })
+        .slice(0, 2)
+        .map(c => [c.qualifiedName, c.fanOut]),
+    ).toEqual([
+      ['ProcessComplexData', 5],
+      ['processTypeB', 2],
+    ]);
+    expect(
+      complexityCandidates(all, 0, { minFanIn: 1 }).map(c => c.qualifiedName),
+    ).not.toContain('ProcessComplexData');
+  });
+
+  it('should report direct and mutual recursion as cycles', () => {
+    const graph = buildGoCallGraph([parseGoFile(parserSource, '/repo/parser/parser.go')]);
+
+    expect(findRecursionCycles(graph)).toEqual([
+      { ids: ['parser.parseExpr', 'parser.parseGroup'], direct: false },
+      { ids: ['parser.walk'], direct: true },
+    ]);
+  });
+
+  it('should find no recursion in the iterative sample', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath),
+    ]);
+
+    expect(findRecursionCycles(graph)).toEqual([]);
+  });
+
+  it('should refuse to inline a function of a recursive cycle', () => {
+    const file = parseGoFile(parserSource, '/repo/parser/parser.go');
+
+    expect(() => inlineFunction(file, { name: 'parseGroup' })).toThrow(
+      'parseGroup is mutually recursive with parseExpr',
+    );
+    expect(inlineFunction(file, { name: 'parseTerm' }).inlined).toEqual([7]);
+  });
+
+  it('should patch changed files to the graph a full rebuild gives', () => {
+    const store = parseGoFile(storeSource, 'store/store.go');
+    const app = parseGoFile(appSource, 'app/app.go');
+    const parser = parseGoFile(parserSource, '/repo/parser/parser.go');
+    const builder = new GoCallGraphBuilder([store, app, parser]);
+    const plain = (graph: GoCallGraph) => ({
+      nodes: [...graph.nodes.keys()].sort(),
+      callees: [...graph.callees].map(([id, ids]) => [id, [...ids].sort()]).sort(),
+      external: [...graph.externalCalls].map(([id, ids]) => [id, [...ids].sort()]).sort(),
+    });
+
+    // Put is renamed, so app's call of it becomes external
+    const renamed = parseGoFile(storeSource.replace(/\bPut\b/, 'Store'), 'store/store.go');
+    const patched = builder.update([renamed]);
+    expect(builder.lastUpdate).toEqual({ rebuilt: ['store/store.go'], revalidated: ['app/app.go'] });
+    expect(plain(patched)).toEqual(plain(buildGoCallGraph([renamed, app, parser])));
+    expect([...patched.externalCalls.get('app.Run')!].sort()).toEqual(['.Put', 'os.Getenv']);
+  });
+
+  it('should drop deleted files and revalidate their callers', () => {
+    const store = parseGoFile(storeSource, 'store/store.go');
+    const app = parseGoFile(appSource, 'app/app.go');
+    const parser = parseGoFile(parserSource, '/repo/parser/parser.go');
+    const builder = new GoCallGraphBuilder([store, app, parser]);
+
+    const graph = builder.update([], ['store/store.go']);
+    expect(builder.lastUpdate).toEqual({ rebuilt: [], revalidated: ['app/app.go'] });
+    expect(graph.nodes.has('store.Store.Put')).toBe(false);
+    expect(graph.callees.has('app.Run')).toBe(false);
+    expect(graph.callees.get('parser.walk')).toEqual(new Set(['parser.walk']));
+
+    builder.update([store]);
+    expect([...builder.graph.callees.get('app.Run')!]).toEqual(['store.Store.Put']);
+  });
+});
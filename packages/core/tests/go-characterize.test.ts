@@ -0,0 +1,105 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import {
+  characterizationTestPath,
+  generateCharacterizationTests,
+  writeCharacterizationTests,
+} from '../src/go/characterize';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+function generate(source: string) {
+  return generateCharacterizationTests(parseGoFile(source, 'pure.go'));
+}
+
+describe('Go characterization tests', () => {
+  it('should characterize deterministic functions with simple signatures', () => {
+    const result = generateCharacterizationTests(
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)
+    );
+
+    expect(result.functions).toEqual([
+      'CalculateFibonacci',
+      'ProcessComplexData',
+      'processTypeA',
+      'processTypeB',
+    ]);
+    expect(result.testPath).toBe(samplePath.replace(/\.go$/, '_characterization_test.go'));
+    expect(result.content).toContain('package main');
+    expect(result.content).toContain('func TestCharacterizeCalculateFibonacci(t *testing.T) {');
+    expect(result.content).toContain('func TestCharacterize_processTypeA(t *testing.T) {');
+    expect(result.content).toContain('for _, arg0 := range []int{0, 1, -1, 2, 3, 5, 10} {');
+    expect(result.content).toContain('r0, r1 := ProcessComplexData(arg0)');
+  });
+
+  it('should skip unsafe functions with a reason', () => {
+    const result = generateCharacterizationTests(
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)
+    );
+    const reasons = new Map(result.skipped.map(s => [s.name, s.reason]));
+
+    expect(reasons.get('NewDataProcessor')).toBe('takes an unsupported parameter');
+    expect(reasons.get('privateHelper')).toBe('returns nothing to record');
+    expect(reasons.get('(dp *DataProcessor) GetCacheSize')).toBe('method; needs a receiver value');
+
+    const variadic = generate('package p\n\nfunc Sum(xs ...int) int { return len(xs) }\n');
+    expect(variadic.skipped.map(s => s.reason)).toEqual(['takes a variadic parameter']);
+  });
+
+  it('should detect I/O, package state, pointers and concurrency', () => {
+    const result = generate(`package pure
+
+import (
+	"fmt"
+	"os"
+)
+
+var counter int
+
+func Env(key string) string { return os.Getenv(key) }
+func Count(n int) int { return counter + n }
+func Deref(p *int) int { return *p }
+func Spawn(n int) int { go func() {}(); return n }
+func Log(n int) int { fmt.Println(n); return n }
+func Wrapped(n int) int { return Log(n) }
+func Shadow(n int) int { counter := n; return counter }
+`);
+    const reasons = Object.fromEntries(result.skipped.map(s => [s.name, s.reason]));
+
+    expect(reasons).toEqual({
+      Env: 'uses os.Getenv',
+      Count: 'reads package state (counter)',
+      Deref: 'takes a pointer argument',
+      Spawn: 'starts a goroutine',
+      Log: 'uses fmt.Println',
+      Wrapped: 'calls Log, which uses fmt.Println',
+    });
+    expect(result.functions).toEqual(['Shadow']);
+  });
+
+  it('should bound the number of generated cases', () => {
+    const result = generateCharacterizationTests(
+      parseGoFile('package pure\n\nfunc Add(a, b, c int) int { return a + b + c }\n', 'pure.go'),
+      { maxCases: 8 }
+    );
+
+    expect(result.content.match(/range \[\]int\{0, 1\}/g)).toHaveLength(3);
+  });
+
+  it('should write nothing when no function is safe to call', async () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'characterize-'));
+    const filePath = path.join(dir, 'io.go');
+    fs.writeFileSync(filePath, 'package io\n\nfunc main() {}\n');
+
+    try {
+      const result = await writeCharacterizationTests(filePath);
+      expect(result.content).toBe('');
+      expect(fs.existsSync(characterizationTestPath(filePath))).toBe(false);
+    } finally {
+      fs.rmSync(dir, { recursive: true, force: true });
+    }
+  });
+});
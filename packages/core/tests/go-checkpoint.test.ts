@@ -0,0 +1,129 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { GoCheckpoint } from '../src/go/checkpoint';
+import { GoPackageFindings, streamGoFindings } from '../src/go/jsonl';
+import { parseGoFile } from '../src/go/parser';
+import { GoRuleRegistry } from '../src/go/rules';
+
+const source = (pkg: string, body = 'os.Remove("tmp")') => `package ${pkg}
+
+import "os"
+
+func Clean() {
+	${body}
+}
+`;
+
+describe('Go check checkpoints', () => {
+  const root = path.join(path.sep, 'repo');
+  let tempDir: string;
+  let checked: string[];
+  let registry: GoRuleRegistry;
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-checkpoint-'));
+    checked = [];
+    // Records which packages the rules actually ran on
+    registry = new GoRuleRegistry().register({
+      id: 'package-files',
+      description: 'One finding per file',
+      severity: 'low',
+      checkFiles: group => {
+        checked.push(path.basename(path.dirname(group[0].filePath)));
+        return group.map(file => ({
+          rule: 'package-files',
+          severity: 'low' as const,
+          filePath: file.filePath,
+          line: 1,
+          column: 1,
+          message: `${file.packageName.name} file`,
+        }));
+      },
+    });
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  const parse = (pkg: string, body?: string, name = `${pkg}.go`) =>
+    parseGoFile(source(pkg, body), path.join(root, pkg, name));
+  const run = async (files: ReturnType<typeof parse>[], checkpoint: GoCheckpoint) => {
+    const batches: GoPackageFindings[] = [];
+    for await (const batch of streamGoFindings(files, { registry, checkpoint })) {
+      batches.push(batch);
+    }
+    return batches;
+  };
+
+  it('keys a package on its files, in any order, the run and the version', () => {
+    const checkpoint = new GoCheckpoint(tempDir, { version: '1.0.0' });
+    const files = [parse('a'), parse('a', undefined, 'other.go')];
+    const key = checkpoint.key(files, 'rules');
+
+    expect(key).toMatch(/^[0-9a-f]{64}$/);
+    expect(checkpoint.key([...files].reverse(), 'rules')).toBe(key);
+    expect(checkpoint.key([files[0], parse('a', 'os.Exit(1)', 'other.go')], 'rules')).not.toBe(key);
+    expect(checkpoint.key(files, 'other rules')).not.toBe(key);
+    expect(new GoCheckpoint(tempDir, { version: '1.0.1' }).key(files, 'rules')).not.toBe(key);
+  });
+
+  it('resumes unchanged packages and checks changed ones again', async () => {
+    const first = await run([parse('a'), parse('b')], new GoCheckpoint(tempDir));
+    expect(checked).toEqual(['a', 'b']);
+    expect(first.map(batch => batch.resumed)).toEqual([undefined, undefined]);
+
+    checked = [];
+    const checkpoint = new GoCheckpoint(tempDir);
+    const second = await run([parse('a'), parse('b', 'os.Exit(1)')], checkpoint);
+
+    expect(checked).toEqual(['b']);
+    expect(second.map(batch => batch.resumed)).toEqual([true, undefined]);
+    expect(second[0].findings).toEqual(first[0].findings);
+    expect(second[0].files.map(file => file.filePath)).toEqual([path.join(root, 'a', 'a.go')]);
+    expect(checkpoint.getStats()).toEqual({ resumed: 1, saved: 1 });
+  });
+
+  it('keeps the packages an interrupted run completed', async () => {
+    const files = [parse('a'), parse('b'), parse('c')];
+    for await (const batch of streamGoFindings(files, { registry, checkpoint: new GoCheckpoint(tempDir) })) {
+      if (batch.files[0].packageName.name === 'b') break;
+    }
+    expect(checked).toEqual(['a', 'b']);
+
+    checked = [];
+    const resumed = await run(files, new GoCheckpoint(tempDir));
+    expect(checked).toEqual(['c']);
+    expect(resumed.map(batch => batch.findings.map(finding => finding.message))).toEqual([
+      ['a file'],
+      ['b file'],
+      ['c file'],
+    ]);
+  });
+
+  it('checks a package again when its rules or its entry changed', async () => {
+    await run([parse('a')], new GoCheckpoint(tempDir));
+    registry.register({ id: 'quiet', description: 'Finds nothing', severity: 'low', checkFiles: () => [] });
+    await run([parse('a')], new GoCheckpoint(tempDir));
+    expect(checked).toEqual(['a', 'a']);
+
+    for (const name of fs.readdirSync(tempDir)) fs.writeFileSync(path.join(tempDir, name), '{"key": ');
+    await run([parse('a')], new GoCheckpoint(tempDir));
+    expect(checked).toEqual(['a', 'a', 'a']);
+  });
+
+  it('prunes entries the run did not use and clears on request', async () => {
+    await run([parse('a'), parse('b')], new GoCheckpoint(tempDir));
+    const checkpoint = new GoCheckpoint(tempDir);
+    await run([parse('a'), parse('b', 'os.Exit(1)')], checkpoint);
+    expect(fs.readdirSync(tempDir)).toHaveLength(3);
+
+    expect(await checkpoint.prune()).toBe(1);
+    expect(fs.readdirSync(tempDir)).toHaveLength(2);
+    await checkpoint.clear();
+    expect(fs.existsSync(tempDir)).toBe(false);
+    expect(await new GoCheckpoint(tempDir).prune()).toBe(0);
+  });
+});
@@ -0,0 +1,167 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { escapeXmlAttribute, goCheckstyleReport } from '../src/go/checkstyle';
+import { GoFinding } from '../src/go/findings';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const read = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+
+interface XmlElement {
+  name: string;
+  attributes: Record<string, string>;
+  children: XmlElement[];
+}
+
+const ENTITIES: Record<string, string> = { amp: '&', lt: '<', gt: '>', quot: '"', apos: "'" };
+
+// A strict parser for the XML subset reports use: a declaration, elements,
+// double-quoted attributes, entity and character references and whitespace
+// between elements. Throws on anything not well-formed.
+function parseXml(text: string): XmlElement {
+  let at = 0;
+  const fail = (message: string): never => {
+    throw new Error(`${message} at offset ${at}`);
+  };
+  const skipSpace = () => {
+    while (/\s/.test(text[at] ?? '')) at++;
+  };
+  const reference = /&(#\d+|#x[0-9a-f]+|[a-z]+);/gi;
+  const decode = (raw: string) => {
+    if (/[<&]/.test(raw.replace(reference, ''))) fail('raw < or & in text');
+    if (/[\u0000-\u0008\u000B\u000C\u000E-\u001F]/.test(raw)) fail('invalid character');
+    return raw.replace(reference, (_, ref: string) => {
+      if (ref.startsWith('#x')) return String.fromCodePoint(parseInt(ref.slice(2), 16));
+      if (ref.startsWith('#')) return String.fromCodePoint(Number(ref.slice(1)));
+      return ENTITIES[ref] ?? fail(`unknown entity ${ref}`);
+    });
+  };
+  const element = (): XmlElement => {
+    if (text[at] !== '<') fail('expected <');
+    const name = /^<([A-Za-z_][\w.-]*)/.exec(text.slice(at))?.[1] ?? fail('expected a name');
+    at += name.length + 1;
+    const attributes: Record<string, string> = {};
+    for (;;) {
+      skipSpace();
+      if (text.startsWith('/>', at)) {
+        at += 2;
+        return { name, attributes, children: [] };
+      }
+      if (text[at] === '>') break;
+      const match = /^([A-Za-z_][\w.-]*)="([^"]*)"/.exec(text.slice(at)) ?? fail('bad attribute');
+      if (match[1] in attributes) fail(`duplicate attribute ${match[1]}`);
+      attributes[match[1]] = decode(match[2]);
+      at += match[0].length;
+    }
+    at++;
+    const children: XmlElement[] = [];
+    for (;;) {
+      skipSpace();
+      if (text.startsWith(`</${name}>`, at)) {
+        at += name.length + 3;
+        return { name, attributes, children };
+      }
+      if (text.startsWith('</', at)) fail(`mismatched end tag for ${name}`);
+      children.push(element());
+    }
+  };
+  const declaration = /^<\?xml version="1\.0" encoding="UTF-8"\?>/.exec(text) ?? fail('no XML declaration');
+  at = declaration[0].length;
+  skipSpace();
+  const root = element();
+  skipSpace();
+  if (at !== text.length) fail('content after the root element');
+  return root;
+}
+
+const finding = (overrides: Partial<GoFinding>): GoFinding => ({
+  rule: 'todo-comment',
+  severity: 'low',
+  filePath: path.join(fixtures, 'sample.go'),
+  line: 1,
+  column: 1,
+  message: 'message',
+  ...overrides,
+});
+
+describe('Go CheckStyle reports', () => {
+  it('groups the findings of the fixtures into file elements that parse', () => {
+    const files = [read('sample.go'), read('constants.go')];
+    const findings = defaultGoRuleRegistry().run(files);
+    const root = parseXml(goCheckstyleReport(findings, files, { root: fixtures }));
+
+    expect(root.name).toBe('checkstyle');
+    expect(root.attributes.version).toBe('8.0');
+    expect(root.children.map((file) => file.attributes.name)).toEqual(['constants.go', 'sample.go']);
+    const errors = root.children.flatMap((file) => file.children);
+    expect(errors).toHaveLength(findings.length);
+    expect(errors.every((error) => error.name === 'error')).toBe(true);
+    for (const error of errors) {
+      expect(Object.keys(error.attributes)).toEqual(['line', 'column', 'severity', 'message', 'source']);
+    }
+  });
+
+  it('maps severities to error, warning and info with the rule as source', () => {
+    const files = [read('sample.go')];
+    const findings = [
+      finding({ severity: 'high', rule: 'ignored-error', line: 30 }),
+      finding({ severity: 'medium', rule: 'unwrapped-error', line: 10 }),
+      finding({ severity: 'low', rule: 'todo-comment', line: 20 }),
+    ];
+    const [file] = parseXml(goCheckstyleReport(findings, files, { root: fixtures })).children;
+
+    expect(file.children.map(({ attributes }) => [attributes.line, attributes.severity, attributes.source])).toEqual([
+      ['10', 'warning', 'unwrapped-error'],
+      ['20', 'info', 'todo-comment'],
+      ['30', 'error', 'ignored-error'],
+    ]);
+  });
+
+  it('keeps messages with special characters well-formed and intact', () => {
+    const message = `use "a" & 'b' <not> c\nsecond line\twith tab`;
+    const xml = goCheckstyleReport([finding({ message })], [read('sample.go')], { root: fixtures });
+    const [file] = parseXml(xml).children;
+
+    expect(file.children[0].attributes.message).toBe(message);
+    expect(xml).toContain('&quot;a&quot; &amp; &apos;b&apos; &lt;not&gt; c&#10;second line&#9;with tab');
+  });
+
+  it('replaces characters XML cannot hold and converts columns from bytes', () => {
+    expect(escapeXmlAttribute('nul\u0000 bell\u0007 \uD800 ok 😀')).toBe(
+      'nul� bell� � ok 😀',
+    );
+    const source = 'package p\n\nvar s = "héllo" // TODO: x\n';
+    const file = parseGoFile(source, '/src/p/p.go');
+    const byte = Buffer.from(source.split('\n')[2]).indexOf('//') + 1;
+    const xml = goCheckstyleReport([finding({ filePath: file.filePath, line: 3, column: byte })], [file], {
+      root: '/src',
+    });
+    const [element] = parseXml(xml).children;
+
+    expect(element.attributes.name).toBe('p/p.go');
+    expect(element.children[0].attributes.column).toBe(String(source.split('\n')[2].indexOf('//') + 1));
+  });
+
+  it('lists checked files without findings as empty elements', () => {
+    const files = [read('sample.go'), read('constants.go')];
+    const xml = goCheckstyleReport([], files, { root: fixtures });
+
+    expect(xml).toBe(
+      [
+        '<?xml version="1.0" encoding="UTF-8"?>',
+        '<checkstyle version="8.0">',
+        '  <file name="constants.go"/>',
+        '  <file name="sample.go"/>',
+        '</checkstyle>',
+        '',
+      ].join('\n'),
+    );
+    expect(() => parseXml(xml)).not.toThrow();
+    expect(() => parseXml(xml.replace('constants.go', 'a & b'))).toThrow();
+  });
+});
@@ -0,0 +1,85 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findUnreleasedResources } from '../src/go/cleanup';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const parse = (name: string) =>
+  parseGoFile(fs.readFileSync(path.join(fixtures, name), 'utf-8'), path.join(fixtures, name));
+
+describe('Go unreleased resources', () => {
+  it('reports each leak with its reason and skips released or handed-over resources', () => {
+    const findings = findUnreleasedResources([parse('resources.go')]);
+
+    expect(findings.map(f => [f.line, f.resource, f.reason, f.exitLine])).toEqual([
+      [40, 'f', 'early-exit', 46],
+      [54, 'resp.Body', 'never-released', undefined],
+      [74, 'f', 'deferred-in-loop', undefined],
+      [91, 'f', 'early-exit', 97],
+      [107, 's.mu', 'early-exit', 110],
+      [125, 'conn', 'never-released', undefined],
+      [130, '', 'discarded', undefined],
+    ]);
+  });
+
+  it('suggests a defer outside loops only', () => {
+    const findings = findUnreleasedResources([parse('resources.go')]);
+    const byLine = new Map(findings.map(f => [f.line, f]));
+
+    expect(byLine.get(40)).toMatchObject({
+      severity: 'medium',
+      fix: 'defer f.Close()',
+      message: 'The return on line 46 skips f.Close() on line 48, leaking f from os.Open; defer f.Close() instead',
+    });
+    expect(byLine.get(54)?.message).toBe('resp.Body from http.Get is never released; defer resp.Body.Close() after acquiring it');
+    expect(byLine.get(74)?.fix).toBeUndefined();
+    expect(byLine.get(74)?.message).toContain('runs when the function returns, not at the end of the loop iteration');
+    expect(byLine.get(91)?.message).toBe(
+      'The continue on line 97 skips f.Close() on line 100, leaking f from os.Open; release it before leaving the iteration'
+    );
+    expect(byLine.get(107)?.fix).toBe('defer s.mu.Unlock()');
+    expect(byLine.get(130)?.message).toBe('The result of time.NewTicker is discarded, so it can never be released with Stop()');
+  });
+
+  it('follows package types with a Close method across files', () => {
+    const conn = `package main
+
+type Conn struct{}
+
+func (c *Conn) Close() error { return nil }
+
+func Dial(addr string) (*Conn, error) { return &Conn{}, nil }
+`;
+    const use = `package main
+
+func Check(addr string) error {
+	c, err := Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return nil
+}
+
+func Later(addr string) {
+	c, _ := Dial(addr)
+	go func() { c.Close() }()
+}
+
+func Leak(addr string) {
+	c, _ := Dial(addr)
+	_ = c
+}
+`;
+    const findings = findUnreleasedResources([parseGoFile(conn, '/p/conn.go'), parseGoFile(use, '/p/use.go')]);
+
+    expect(findings.map(f => [f.filePath, f.line, f.reason])).toEqual([['/p/use.go', 18, 'never-released']]);
+  });
+
+  it('runs as a registered rule and leaves the sample alone', () => {
+    expect(findUnreleasedResources([parse('sample.go')])).toEqual([]);
+    expect(defaultGoRuleRegistry().run([parse('resources.go')]).filter(f => f.rule === 'unreleased-resource')).toHaveLength(7);
+  });
+});
@@ -0,0 +1,218 @@
+import { describe, it, expect } from '@jest/globals';
+import { findDirectClockCalls, injectClock } from '../src/go/clock';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+
+const parse = (source: string, name = 'p.go') => parseGoFile(source, `/src/p/${name}`);
+
+const cache = `package p
+
+import (
+	"sync"
+	"time"
+)
+
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]time.Time
+}
+
+func (c *Cache) Put(key string) {
+	c.entries[key] = time.Now()
+}
+
+func (c *Cache) Fresh(key string) bool {
+	return time.Since(c.entries[key]) < c.ttl
+}
+
+func (Cache) Left(d time.Time) time.Duration {
+	return time.Until(d.Add(time.Second))
+}
+`;
+
+const expire = `package p
+
+import "time"
+
+func (c *Cache) Expire() {
+	go func() {
+		for key, at := range c.entries {
+			if time.Now().After(at) {
+				delete(c.entries, key)
+			}
+		}
+	}()
+}
+`;
+
+describe('Go direct clock reads', () => {
+  it('flags each function once, at its first call, counting calls in function literals', () => {
+    const source = `package p
+
+import "time"
+
+type Job struct{ started time.Time }
+
+func (j *Job) Elapsed() time.Duration {
+	tick := func() time.Time { return time.Now() }
+	_ = tick
+	return time.Since(j.started)
+}
+
+func Deadline(d time.Duration) time.Time {
+	return time.Now().Add(d)
+}
+`;
+    const findings = findDirectClockCalls([parse(source)]);
+
+    expect(findings.map(({ line, column, function: fn, type, calls }) => [line, column, fn, type, calls])).toEqual([
+      [8, 36, 'Job.Elapsed', 'Job', 2],
+      [14, 9, 'Deadline', undefined, 1],
+    ]);
+    expect(findings[0]).toMatchObject({ rule: 'direct-clock', severity: 'low' });
+    expect(findings[0].message).toBe(
+      'Job.Elapsed reads the clock with time.Now and time.Since (2 calls), which tests cannot control; give Job a now func() time.Time field to call instead',
+    );
+    expect(findings[1].message).toBe(
+      'Deadline reads the clock with time.Now, which tests cannot control; take a now func() time.Time parameter to call instead',
+    );
+  });
+
+  it('leaves test files, clock adapters, shadowed names and package initializers alone', () => {
+    const source = `package p
+
+import clock "time"
+
+var started = clock.Now()
+
+func now() clock.Time { return clock.Now() }
+
+func local(clock fakeClock) { clock.Now() }
+
+func Stamp() string { return clock.Now().Format(clock.RFC3339) }
+`;
+    const test = parse('package p\n\nimport "time"\n\nfunc TestX(t *testing.T) { _ = time.Now() }\n', 'p_test.go');
+    const findings = findDirectClockCalls([parse(source), test]);
+
+    expect(findings.map(({ line, function: fn }) => [line, fn])).toEqual([[11, 'Stamp']]);
+    expect(findings[0].message).toContain('take a now func() clock.Time parameter');
+  });
+});
+
+describe('Go clock injection', () => {
+  it('adds an aligned clock field and method, and routes every method through it', () => {
+    const result = injectClock([parse(cache), parse(expire, 'expire.go')], { type: 'Cache' });
+
+    expect(result).toMatchObject({ type: 'Cache', field: 'now', method: 'clock' });
+    expect(result.methods).toEqual(['Put', 'Fresh', 'Left', 'Expire']);
+    expect(result.files.map((file) => file.filePath)).toEqual(['/src/p/p.go', '/src/p/expire.go']);
+    expect(result.files[0].source).toBe(`package p
+
+import (
+	"sync"
+	"time"
+)
+
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]time.Time
+	now     func() time.Time
+}
+
+func (c *Cache) Put(key string) {
+	c.entries[key] = c.clock()
+}
+
+func (c *Cache) Fresh(key string) bool {
+	return c.clock().Sub(c.entries[key]) < c.ttl
+}
+
+func (c Cache) Left(d time.Time) time.Duration {
+	return d.Add(time.Second).Sub(c.clock())
+}
+
+// clock calls now when it is set, and time.Now otherwise
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+`);
+    // The other file no longer needs the time import
+    expect(result.files[1].source).toBe(`package p
+
+func (c *Cache) Expire() {
+	go func() {
+		for key, at := range c.entries {
+			if c.clock().After(at) {
+				delete(c.entries, key)
+			}
+		}
+	}()
+}
+`);
+  });
+
+  it('opens an empty struct, re-pads shorter fields and imports time where the struct lives', () => {
+    const types = parse('package p\n\ntype Empty struct{}\n\ntype Timer struct {\n\tid int\n\tts  int64\n}\n', 'types.go');
+    const uses = parse(
+      'package p\n\nimport "time"\n\nfunc (e Empty) Stamp() int64 { return time.Now().Unix() }\n\nfunc (t *Timer) Left(at *time.Time) time.Duration { return time.Until(*at) }\n',
+      'uses.go',
+    );
+
+    expect(injectClock([types, uses], { type: 'Empty', field: 'clockFn', method: 'now' }).files[0].source).toBe(
+      `package p
+
+import "time"
+
+type Empty struct {
+	clockFn func() time.Time
+}
+
+// now calls clockFn when it is set, and time.Now otherwise
+func (e Empty) now() time.Time {
+	if e.clockFn != nil {
+		return e.clockFn()
+	}
+	return time.Now()
+}
+
+type Timer struct {
+\tid int
+\tts  int64
+}
+`,
+    );
+    const timer = injectClock([types, uses], { type: 'Timer' });
+    expect(timer.files[0].source).toContain('type Timer struct {\n\tid  int\n\tts  int64\n\tnow func() time.Time\n}\n');
+    expect(timer.files[1].source).toContain('return (*at).Sub(t.clock()) }');
+  });
+
+  it('refuses missing structs, taken names, methods without clock calls and hidden receivers', () => {
+    const files = [parse(cache)];
+    const reason = (options: Parameters<typeof injectClock>[1], given = files) => {
+      try {
+        injectClock(given, options);
+      } catch (error) {
+        expect(error).toBeInstanceOf(GoRefactorError);
+        return (error as Error).message;
+      }
+      return undefined;
+    };
+
+    expect(reason({ type: 'Store' })).toBe('Store is not a struct type in the analyzed files');
+    expect(reason({ type: 'Cache', field: 'ttl' })).toBe('Cache already has a member named ttl');
+    expect(reason({ type: 'Cache', method: 'Put' })).toBe('Cache already has a member named Put');
+    expect(reason({ type: 'Idle' }, [parse('package p\n\ntype Idle struct {\n\tn int\n}\n\nfunc (i Idle) N() int { return i.n }\n')])).toBe(
+      'No method of Idle calls time.Now, time.Since or time.Until',
+    );
+    expect(
+      reason({ type: 'Job' }, [
+        parse('package p\n\nimport "time"\n\ntype Job struct {\n\tn int\n}\n\nfunc (j Job) Run(js []Job) {\n\tfor _, j := range js {\n\t\t_ = time.Now()\n\t}\n}\n'),
+      ]),
+    ).toBe('Cannot inject the clock: Job.Run declares j, hiding the receiver where it reads the clock');
+  });
+});
@@ -0,0 +1,106 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findGoClones } from '../src/go/clones';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const reportSource = `package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+func Users(names []string) string {
+	var b strings.Builder
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%d: %s\\n", i, name)
+	}
+	return b.String()
+}
+
+func Groups(names []string) string {
+	var b strings.Builder
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%d: %s\\n", i, name)
+	}
+	return b.String()
+}
+
+func Orders(ids []string) string {
+	var out strings.Builder
+	for n, id := range ids {
+		if id == "-" {
+			continue
+		}
+		fmt.Fprintf(&out, "#%d %s\\n", n, id)
+	}
+	return out.String()
+}
+`;
+
+const report = parseGoFile(reportSource, 'report.go');
+
+describe('Go clone detection', () => {
+  it('should report renamed copies as one clone with a helper suggestion', () => {
+    const [clone, ...rest] = findGoClones([report]);
+
+    expect(rest).toEqual([]);
+    expect(clone.kind).toBe('renamed');
+    expect(clone.locations.map(l => [l.function, l.startLine, l.endLine])).toEqual([
+      ['Users', 9, 16],
+      ['Groups', 20, 27],
+      ['Orders', 31, 38],
+    ]);
+    expect(clone.differences).toBe(11);
+    expect(clone.suggestion).toBe(
+      'Extract a shared helper for the 3 copies in Users, Groups and Orders, passing the 11 differing names and values as parameters'
+    );
+  });
+
+  it('should only report exact copies at exact sensitivity', () => {
+    const clones = findGoClones([report], { sensitivity: 'exact' });
+
+    expect(clones).toHaveLength(1);
+    expect(clones[0].kind).toBe('exact');
+    expect(clones[0].differences).toBe(0);
+    expect(clones[0].locations.map(l => l.function)).toEqual(['Users', 'Groups']);
+  });
+
+  it('should find clones across files', () => {
+    const other = parseGoFile(
+      reportSource.replace(/func (\w+)\(/g, 'func copy$1('),
+      'copy.go'
+    );
+    const clones = findGoClones([report, other], { sensitivity: 'exact' });
+
+    expect(clones[0].locations.map(l => `${l.filePath}:${l.function}`)).toEqual([
+      'report.go:Users',
+      'report.go:Groups',
+      'copy.go:copyUsers',
+      'copy.go:copyGroups',
+    ]);
+  });
+
+  it('should honor the minimum size', () => {
+    const content = fs.readFileSync(samplePath, 'utf-8');
+    const file = parseGoFile(content, samplePath);
+
+    expect(findGoClones([file])).toEqual([]);
+    const small = findGoClones([file], { minSize: 5 });
+    // processTypeA's two Sprintf returns differ only in the format string
+    expect(small.map(c => c.locations.map(l => `${l.function}:${l.startLine}`))).toContainEqual([
+      'processTypeA:86',
+      'processTypeA:88',
+    ]);
+    expect(small.find(c => c.locations[0].function === 'processTypeA')?.differences).toBe(1);
+  });
+});
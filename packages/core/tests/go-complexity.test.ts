@@ -0,0 +1,114 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { extractGoFileSymbols } from '../src/go/symbols';
+import { cognitiveComplexity, complexityCandidates, cyclomaticComplexity } from '../src/go/complexity';
+
+const fixturesPath = path.join(__dirname, 'fixtures', 'go');
+
+function loadSymbols(fileName: string) {
+  const filePath = path.join(fixturesPath, fileName);
+  return extractGoFileSymbols(parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath));
+}
+
+function complexityOf(body: string, measure = cyclomaticComplexity): number {
+  const file = parseGoFile(`package p\nfunc f(a, b bool, xs []int) int {\n${body}\n}\n`);
+  const decl = file.decls[0];
+  return decl.kind === 'FuncDecl' ? measure(decl.body) : -1;
+}
+
+const cognitiveOf = (body: string) => complexityOf(body, cognitiveComplexity);
+
+describe('Go cyclomatic complexity', () => {
+  it('should start at 1 for straight-line code', () => {
+    expect(complexityOf('return 0')).toBe(1);
+  });
+
+  it('should count if, for and boolean operators', () => {
+    expect(complexityOf('if a && b { }\nfor range xs { }\nreturn 0')).toBe(4);
+    expect(complexityOf('if a || b || !a { }\nreturn 0')).toBe(4);
+  });
+
+  it('should count non-default case clauses', () => {
+    expect(complexityOf('switch { case a: case b: default: }\nreturn 0')).toBe(3);
+  });
+
+  it('should count returns nested inside branches', () => {
+    expect(complexityOf('if a { return 1 }\nreturn 0')).toBe(3);
+  });
+
+  it('should score the fixture functions', () => {
+    const symbols = loadSymbols('sample.go');
+    const byName = new Map(
+      [...symbols.functions, ...symbols.methods].map(s => [s.qualifiedName, s.complexity])
+    );
+
+    // guard + early return + range + two non-default cases
+    expect(byName.get('ProcessComplexData')).toBe(6);
+    expect(byName.get('CalculateFibonacci')).toBe(4);
+    expect(byName.get('DataProcessor.GetCacheSize')).toBe(1);
+  });
+
+  it('should measure function literals separately from the enclosing function', () => {
+    const symbols = loadSymbols('closures.go');
+    const run = symbols.functions.find(f => f.name === 'Run');
+
+    // if + || + early return + range; closure bodies are excluded
+    expect(run?.complexity).toBe(5);
+    expect(run?.closures.map(c => [c.usage, c.complexity])).toEqual([
+      ['defer', 2],
+      ['go', 3],
+      ['value', 2],
+    ]);
+  });
+
+  it('should flag functions above a configurable threshold', () => {
+    const symbols = loadSymbols('sample.go');
+    const all = [...symbols.functions, ...symbols.methods];
+
+    expect(complexityCandidates(all)).toEqual([]);
+    expect(complexityCandidates(all, 3).map(c => c.qualifiedName)).toEqual([
+      'ProcessComplexData',
+      'CalculateFibonacci',
+    ]);
+  });
+});
+
+describe('Go cognitive complexity', () => {
+  it('should weigh structures by how deeply they are nested', () => {
+    expect(cognitiveOf('return 0')).toBe(0);
+    expect(cognitiveOf('if a { }\nif b { }\nfor range xs { }\nreturn 0')).toBe(3);
+    // switch 1, for 1 + 1 nesting, if 1 + 2 nesting
+    expect(cognitiveOf('switch { case a: for range xs { if b { } } }\nreturn 0')).toBe(6);
+  });
+
+  it('should count else branches and runs of boolean operators once each', () => {
+    expect(cognitiveOf('if a { } else if b { } else { }\nreturn 0')).toBe(3);
+    expect(cognitiveOf('switch { case a: case b: case !a: default: }\nreturn 0')).toBe(1);
+    expect(cognitiveOf('if a && b && a || b { }\nreturn 0')).toBe(3);
+  });
+
+  it('should nest function literals and count labeled jumps', () => {
+    expect(cognitiveOf('f := func() { if a { } }\nf()\nreturn 0')).toBe(2);
+    expect(cognitiveOf('outer:\nfor range xs { for range xs { break outer } }\nreturn 0')).toBe(4);
+  });
+
+  it('should score the single-level switch of the fixture low and flag by either metric', () => {
+    const symbols = loadSymbols('sample.go');
+    const all = [...symbols.functions, ...symbols.methods];
+    const complex = all.find(s => s.qualifiedName === 'ProcessComplexData');
+
+    // guard, range, and the switch nested in it
+    expect([complex.complexity, complex.cognitiveComplexity]).toEqual([6, 4]);
+    expect(complexityCandidates(all, 100, { cognitiveThreshold: 2 }).map(c => c.qualifiedName)).toEqual([
+      'ProcessComplexData',
+      'DataProcessor.ProcessData',
+    ]);
+    expect(complexityCandidates(all, 3, { sortBy: 'cognitiveComplexity' })[0]).toMatchObject({
+      qualifiedName: 'ProcessComplexData',
+      cognitiveComplexity: 4,
+      cognitiveThreshold: 15,
+    });
+  });
+});
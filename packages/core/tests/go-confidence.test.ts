@@ -0,0 +1,141 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { buildGoCallGraph } from '../src/go/callgraph';
+import {
+  autoApplicable,
+  refactorConfidence,
+  scoreSuggestions,
+} from '../src/go/confidence';
+
+const configSource = `package config
+
+type loader interface {
+	load(path string) error
+}
+
+type fileLoader struct{}
+
+func (f *fileLoader) load(path string) error { return nil }
+
+func Parse(input string) (string, error) {
+	return normalize(input), nil
+}
+
+func normalize(input string) string {
+	return trim(input)
+}
+
+func trim(s string) string { return s }
+
+func describe(v interface{ Name() string }) string {
+	return v.Name()
+}
+
+func classify(n int) string {
+	switch {
+	case n < 0:
+		return "negative"
+	case n == 0:
+		return "zero"
+	case n < 10:
+		return "small"
+	case n < 100:
+		return "medium"
+	case n < 1000:
+		return "large"
+	case n < 10000:
+		return "huge"
+	}
+	return "enormous"
+}
+`;
+
+const configTestSource = `package config
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	if normalize(" a ") != "a" {
+		t.Fail()
+	}
+}
+`;
+
+const files = [
+  parseGoFile(configSource, 'config.go'),
+  parseGoFile(configTestSource, 'config_test.go'),
+];
+
+describe('Go refactor confidence', () => {
+  it('should score a rename of an unexported, tested function near 1', () => {
+    const confidence = refactorConfidence(buildGoCallGraph(files), {
+      kind: 'rename',
+      target: 'config.normalize',
+    });
+
+    expect(confidence.score).toBe(1);
+    expect(confidence.factors).toEqual([
+      { name: 'visibility', score: 1, reason: 'unexported' },
+      { name: 'tests', score: 1, reason: 'called by TestNormalize' },
+      { name: 'complexity', score: 1, reason: 'the body is not edited' },
+      { name: 'references', score: 1, reason: 'all 2 caller(s) are in the analyzed files' },
+    ]);
+  });
+
+  it('should score a signature change to an exported, untested function low', () => {
+    const confidence = refactorConfidence(buildGoCallGraph(files), {
+      kind: 'signature',
+      target: 'config.Parse',
+    });
+
+    expect(confidence.score).toBe(0.18);
+    expect(confidence.factors.map(f => [f.name, f.score])).toEqual([
+      ['visibility', 0.6],
+      ['tests', 0.6],
+      ['complexity', 1],
+      ['references', 0.5],
+    ]);
+    expect(confidence.factors[3].reason).toBe(
+      '0 caller(s) found; callers in other packages may exist'
+    );
+  });
+
+  it('should account for indirect tests, complexity and unresolved calls', () => {
+    const graph = buildGoCallGraph(files);
+
+    const trim = refactorConfidence(graph, { kind: 'inline', target: 'config.trim' });
+    expect(trim.score).toBe(0.9);
+    expect(trim.factors[1].reason).toBe('reached from TestNormalize through 1 call(s)');
+
+    const classify = refactorConfidence(graph, { kind: 'extract', target: 'config.classify' });
+    expect(classify.factors[2]).toEqual({
+      name: 'complexity',
+      score: 0.73,
+      reason: 'cyclomatic complexity 13',
+    });
+
+    const describe = refactorConfidence(graph, { kind: 'rewrite', target: 'config.describe' });
+    expect(describe.factors[3]).toEqual({
+      name: 'references',
+      score: 0.9,
+      reason: 'calls undeclared method(s) .Name',
+    });
+
+    const load = refactorConfidence(graph, { kind: 'rename', target: 'config.fileLoader.load' });
+    expect(load.factors[3].score).toBe(0.5);
+  });
+
+  it('should gate auto-apply on a threshold', () => {
+    const scored = scoreSuggestions(files, [
+      { kind: 'rename' as const, target: 'config.normalize' },
+      { kind: 'signature' as const, target: 'config.Parse' },
+      { kind: 'inline' as const, target: 'config.trim' },
+    ]);
+
+    expect(autoApplicable(scored).map(s => s.target)).toEqual(['config.normalize', 'config.trim']);
+    expect(autoApplicable(scored, 0.95).map(s => s.target)).toEqual(['config.normalize']);
+    expect(() => refactorConfidence(buildGoCallGraph(files), { kind: 'delete', target: 'config.missing' })).toThrow(
+      'No function config.missing in the analyzed files'
+    );
+  });
+});
@@ -0,0 +1,145 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import {
+  GoConfigError,
+  GoConfigResolver,
+  goConfigRegistry,
+  mergeGoConfigs,
+  parseGoConfig,
+} from '../src/go/config';
+import { streamGoFindings } from '../src/go/jsonl';
+
+const source = (pkg: string) => `package ${pkg}
+
+// TODO: split this up
+func Build(a, b, c, d int) int {
+	return a + b + c + d
+}
+`;
+
+describe('Go configuration files', () => {
+  let dir: string;
+
+  beforeEach(() => {
+    dir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-config-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(dir, { recursive: true, force: true });
+  });
+
+  const write = (file: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(dir, file)), { recursive: true });
+    fs.writeFileSync(path.join(dir, file), content);
+  };
+
+  it('parses severities, switched off rules and check options', () => {
+    const config = parseGoConfig(
+      [
+        'failOn: warning',
+        'maxWarnings: 3',
+        'rules:',
+        '  todo-comment: off',
+        '  ignored-error: medium',
+        '  too-many-parameters:',
+        '    severity: high',
+        '    maxParameters: 3',
+      ].join('\n')
+    );
+
+    expect(config).toEqual({
+      failOn: 'medium',
+      maxWarnings: 3,
+      rules: {
+        'todo-comment': { severity: 'off' },
+        'ignored-error': { severity: 'medium' },
+        'too-many-parameters': { severity: 'high', options: { maxParameters: 3 } },
+      },
+    });
+    expect(parseGoConfig('')).toEqual({});
+    expect(goConfigRegistry(config).isEnabled('todo-comment')).toBe(false);
+  });
+
+  it('rejects unknown keys, unknown rules and malformed values naming the file', () => {
+    const parse = (content: string) => () =>
+      parseGoConfig(content, { source: 'internal/.refactogent.yaml', root: false });
+
+    expect(parse('rule:\n  todo-comment: off')).toThrow(GoConfigError);
+    expect(parse('rule:\n  todo-comment: off')).toThrow(
+      'internal/.refactogent.yaml: unknown key rule; expected failOn, maxWarnings or rules'
+    );
+    expect(parse('rules:\n  todo-coment: off')).toThrow('unknown rule todo-coment');
+    expect(parse('rules:\n  naked-return:\n    maxLine: 5')).toThrow(
+      'unknown key rules.naked-return.maxLine; expected severity or maxLines'
+    );
+    expect(parse('rules:\n  naked-return:\n    maxLines: five')).toThrow(
+      'rules.naked-return.maxLines must be a non-negative integer'
+    );
+    expect(parse('rules:\n  todo-comment: loud')).toThrow('rules.todo-comment: Unknown severity "loud"');
+    expect(parse('failOn: low')).toThrow('failOn applies to the whole run; set it in the root config');
+    expect(parse('rules: [')).toThrow(/^internal\/\.refactogent\.yaml: /);
+    expect(() => parseGoConfig('rules:\n  project-rule: high', { rules: ['project-rule'] })).not.toThrow();
+  });
+
+  it('merges the root config with the nearest one below it', () => {
+    write('.refactogent.yaml', 'rules:\n  todo-comment: low\n  too-many-parameters:\n    maxParameters: 6\n    severity: low\n');
+    write('internal/.refactogent.yaml', 'rules:\n  too-many-parameters:\n    maxParameters: 3\n');
+    write('testdata/.refactogent.yml', 'rules:\n  todo-comment: off\n');
+    const resolver = new GoConfigResolver(dir);
+
+    const deep = resolver.resolve(path.join(dir, 'internal', 'store', 'sql', 'db.go'));
+    expect(deep.sources).toEqual([path.join(dir, '.refactogent.yaml'), path.join(dir, 'internal', '.refactogent.yaml')]);
+    expect(deep.config.rules).toEqual({
+      'todo-comment': { severity: 'low' },
+      'too-many-parameters': { severity: 'low', options: { maxParameters: 3 } },
+    });
+    expect(resolver.resolve(path.join(dir, 'testdata', 'x.go')).config.rules['todo-comment']).toEqual({
+      severity: 'off',
+    });
+    expect(resolver.resolve(path.join(dir, 'main.go')).sources).toEqual([path.join(dir, '.refactogent.yaml')]);
+    expect(mergeGoConfigs({ rules: { a: { options: { tags: ['TODO'] } } } }, { rules: { a: { options: { tags: ['XXX'] } } } })).toEqual({
+      rules: { a: { options: { tags: ['XXX'] } } },
+    });
+  });
+
+  it('checks each package with the rules and options of its directory', async () => {
+    write('.refactogent.yaml', 'rules:\n  todo-comment: medium\n');
+    write('internal/.refactogent.yaml', 'rules:\n  too-many-parameters:\n    maxParameters: 3\n');
+    write('testdata/.refactogent.yaml', 'rules:\n  todo-comment: off\n');
+    const files = [
+      ['main', 'main.go'],
+      ['internal', 'internal/build.go'],
+      ['testdata', 'testdata/build.go'],
+    ].map(([pkg, file]) => parseGoFile(source(pkg), path.join(dir, file)));
+
+    const found: string[] = [];
+    const sources: number[] = [];
+    for await (const batch of streamGoFindings(files, { config: new GoConfigResolver(dir) })) {
+      sources.push(batch.config.sources.length);
+      for (const finding of batch.findings) {
+        found.push(`${path.relative(dir, finding.filePath)} ${finding.rule} ${finding.severity}`);
+      }
+    }
+
+    expect(found.sort()).toEqual([
+      'internal/build.go todo-comment medium',
+      'internal/build.go too-many-parameters low',
+      'main.go todo-comment medium',
+    ]);
+    expect(sources).toEqual([1, 2, 2]);
+  });
+
+  it('refuses a directory with both a .yaml and a .yml config', () => {
+    write('.refactogent.yaml', 'rules: {}\n');
+    write('pkg/.refactogent.yaml', '');
+    write('pkg/.refactogent.yml', '');
+    const resolver = new GoConfigResolver(dir);
+
+    expect(() => resolver.resolve(path.join(dir, 'pkg', 'a.go'))).toThrow(
+      'pkg has both .refactogent.yaml and .refactogent.yml; keep one'
+    );
+  });
+});
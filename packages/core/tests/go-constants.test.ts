@@ -0,0 +1,88 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { extractGoConstants } from '../src/go/constants';
+
+const fixturesPath = path.join(__dirname, 'fixtures', 'go');
+
+function loadConstants(fileName: string) {
+  const filePath = path.join(fixturesPath, fileName);
+  const constants = extractGoConstants(parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath));
+  return new Map(constants.map(c => [c.name, c]));
+}
+
+describe('Go constant extraction', () => {
+  it('should extract grouped constants from the sample', () => {
+    const constants = loadConstants('sample.go');
+
+    expect(constants.get('API_VERSION')).toMatchObject({
+      type: 'constant',
+      declaredType: 'string',
+      isTyped: false,
+      expression: '"1.0.0"',
+      value: '"1.0.0"',
+      grouped: true,
+      isExported: true,
+    });
+    expect(constants.get('MAX_RETRIES')).toMatchObject({
+      declaredType: 'int',
+      value: '3',
+      iota: 1,
+    });
+  });
+
+  it('should resolve iota through implicit repetition in typed groups', () => {
+    const constants = loadConstants('constants.go');
+
+    expect(['Sunday', 'Monday', 'Tuesday'].map(n => constants.get(n)?.value)).toEqual([
+      '0',
+      '1',
+      '2',
+    ]);
+    expect(constants.get('Tuesday')).toMatchObject({
+      declaredType: 'Weekday',
+      isTyped: true,
+      expression: 'iota',
+    });
+  });
+
+  it('should evaluate expressions over iota', () => {
+    const constants = loadConstants('constants.go');
+
+    expect(constants.has('_')).toBe(true);
+    expect(constants.get('KB')?.value).toBe('1024');
+    expect(constants.get('MB')?.value).toBe('1048576');
+    expect(constants.get('GB')?.value).toBe('1073741824');
+    expect(constants.get('GB')?.expression).toBe('1 << (10 * iota)');
+    expect(['a', 'b', 'c', 'd'].map(n => constants.get(n)?.value)).toEqual(['0', '0', '1', '10']);
+  });
+
+  it('should infer default types for untyped constants', () => {
+    const constants = loadConstants('constants.go');
+    const summary = (name: string) => {
+      const c = constants.get(name);
+      return [c?.declaredType, c?.value];
+    };
+
+    expect(summary('Timeout')).toEqual(['int', '30']);
+    expect(constants.get('Timeout')?.grouped).toBe(false);
+    expect(constants.get('Timeout')?.documentation).toBe('Timeout is the default timeout in seconds');
+    expect(summary('Pi')).toEqual(['float64', '3.14159']);
+    expect(summary('TwoPi')).toEqual(['float64', '6.28318']);
+    expect(summary('Greeting')).toEqual(['string', '"hello, world"']);
+    expect(summary('Initial')).toEqual(['rune', '71']);
+    expect(summary('Debug')).toEqual(['bool', 'false']);
+    expect(summary('Mask')).toEqual(['int', '493']);
+    expect(summary('Width')).toEqual(['int', '12']);
+    expect(summary('Ratio')).toEqual(['float32', '0.25']);
+  });
+
+  it('should leave values it cannot resolve undefined', () => {
+    const unknown = loadConstants('constants.go').get('unknown');
+
+    expect(unknown?.expression).toBe('time.Second');
+    expect(unknown?.value).toBeUndefined();
+    expect(unknown?.isExported).toBe(false);
+  });
+});
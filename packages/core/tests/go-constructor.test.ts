@@ -0,0 +1,104 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { generateConstructor } from '../src/go/constructor';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+
+const source = `package server
+
+import (
+	"sync"
+	"time"
+)
+
+type Headers map[string]string
+
+// Server serves requests
+type Server struct {
+	sync.Mutex
+	Addr    string
+	Port    int
+	Timeout time.Duration
+	routes  map[string]func()
+	headers Headers
+	queue   chan string
+	tags    []string
+	Type    string
+}
+`;
+
+describe('Go generate constructor', () => {
+  const file = parseGoFile(source, 'server.go');
+
+  it('takes every named field as a parameter by default', () => {
+    const result = generateConstructor(file, { type: 'Server' });
+
+    expect(result.constructorName).toBe('NewServer');
+    expect(result.params).toEqual(['Addr', 'Port', 'Timeout', 'routes', 'headers', 'queue', 'tags', 'Type']);
+    expect(result.initialized).toEqual([]);
+    expect(result.updated).toBe(false);
+    expect(result.source).toContain(
+      '}\n\n// NewServer creates a new Server\n' +
+        'func NewServer(addr string, port int, timeout time.Duration, routes map[string]func(), headers Headers, queue chan string, tags []string, typeValue string) *Server {\n' +
+        '\treturn &Server{\n' +
+        '\t\tAddr:    addr,\n' +
+        '\t\tPort:    port,\n' +
+        '\t\tTimeout: timeout,\n' +
+        '\t\troutes:  routes,\n' +
+        '\t\theaders: headers,\n' +
+        '\t\tqueue:   queue,\n' +
+        '\t\ttags:    tags,\n' +
+        '\t\tType:    typeValue,\n' +
+        '\t}\n}\n'
+    );
+  });
+
+  it('initializes maps, slices and channels that are not parameters', () => {
+    const result = generateConstructor(file, { type: 'Server', params: ['Addr', 'Port'] });
+
+    expect(result.initialized).toEqual(['routes', 'headers', 'queue', 'tags']);
+    expect(result.source).toContain(
+      'func NewServer(addr string, port int) *Server {\n' +
+        '\treturn &Server{\n' +
+        '\t\tAddr:    addr,\n' +
+        '\t\tPort:    port,\n' +
+        '\t\troutes:  make(map[string]func()),\n' +
+        '\t\theaders: make(Headers),\n' +
+        '\t\tqueue:   make(chan string),\n' +
+        '\t\ttags:    []string{},\n' +
+        '\t}\n}\n'
+    );
+  });
+
+  it('refuses a struct with a constructor unless asked to update it', () => {
+    const fixture = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+    const sample = parseGoFile(fs.readFileSync(fixture, 'utf-8'), fixture);
+
+    expect(() => generateConstructor(sample, { type: 'DataProcessor' })).toThrow(
+      'DataProcessor already has a constructor, NewDataProcessor; update it instead'
+    );
+    const result = generateConstructor(sample, { type: 'DataProcessor', params: ['config'], update: true });
+    expect(result.updated).toBe(true);
+    expect(result.source).toContain(
+      '// NewDataProcessor creates a new DataProcessor instance\n' +
+        'func NewDataProcessor(config map[string]string) *DataProcessor {\n' +
+        '\treturn &DataProcessor{\n' +
+        '\t\tconfig: config,\n' +
+        '\t\tcache:  make(map[string]interface{}),\n' +
+        '\t}\n}\n'
+    );
+    expect(result.source.match(/func NewDataProcessor/g)).toHaveLength(1);
+  });
+
+  it('names constructors of unexported types in lowercase and validates fields', () => {
+    const point = parseGoFile('package geo\n\ntype point struct {\n\tx, y int\n}\n', 'geo.go');
+
+    expect(generateConstructor(point, { type: 'point' }).source).toBe(
+      'package geo\n\ntype point struct {\n\tx, y int\n}\n\n' +
+        '// newPoint creates a new point\nfunc newPoint(x, y int) *point {\n\treturn &point{\n\t\tx: x,\n\t\ty: y,\n\t}\n}\n'
+    );
+    expect(() => generateConstructor(point, { type: 'point', params: ['z'] })).toThrow('point has no field z');
+    expect(() => generateConstructor(file, { type: 'Headers' })).toThrow(GoRefactorError);
+  });
+});
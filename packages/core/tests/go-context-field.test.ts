@@ -0,0 +1,94 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findStoredContexts } from '../src/go/context-field';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package fetch
+
+import (
+	stdctx "context"
+	"net/http"
+)
+
+type Client struct {
+	http *http.Client
+	ctx  stdctx.Context
+}
+
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.http.Do(req)
+}
+
+func (c *Client) Refresh() error {
+	_, err := c.Get("/refresh")
+	return err
+}
+
+func (c *Client) Close() {}
+
+type Worker struct {
+	stdctx.Context
+	jobs chan int
+}
+
+func (w Worker) Run() {
+	for {
+		select {
+		case <-w.Done():
+			return
+		case <-w.jobs:
+		}
+	}
+}
+
+type Idle struct{ ctx stdctx.Context }
+`;
+
+describe('Go stored contexts', () => {
+  const file = parseGoFile(source, '/src/fetch/client.go');
+  const findings = findStoredContexts([file]);
+
+  it('flags context fields with the methods that read them, transitively', () => {
+    expect(findings[0]).toMatchObject({
+      rule: 'context-in-struct',
+      severity: 'low',
+      line: 10,
+      column: 2,
+      type: 'Client',
+      field: 'ctx',
+      methods: ['Get', 'Refresh'],
+    });
+    expect(findings[0].message).toBe(
+      'Client stores a context.Context in field ctx, which outlives the call it belongs to; pass a context to the methods using it instead: Client.Get, Client.Refresh'
+    );
+    expect(findings[0].fix).toBeUndefined();
+  });
+
+  it('flags embedded contexts used through their methods', () => {
+    expect(findings[1]).toMatchObject({ line: 29, column: 2, type: 'Worker', field: 'Context', methods: ['Run'] });
+  });
+
+  it('reports unread fields and leaves other structs alone', () => {
+    expect(findings.map(finding => finding.type)).toEqual(['Client', 'Worker', 'Idle']);
+    expect(findings[2].message).toContain('no method of Idle reads it');
+    expect(findStoredContexts([parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)])).toEqual([]);
+    expect(findStoredContexts([parseGoFile('package p\n\ntype T struct{ ctx Context }\n', '/p.go')])).toEqual([]);
+  });
+
+  it('runs as a low-severity built-in rule', () => {
+    const rule = defaultGoRuleRegistry()
+      .list()
+      .find(candidate => candidate.id === 'context-in-struct');
+
+    expect(rule.severity).toBe('low');
+    expect(defaultGoRuleRegistry().run([file]).filter(f => f.rule === 'context-in-struct')).toHaveLength(3);
+  });
+});
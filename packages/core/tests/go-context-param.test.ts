@@ -0,0 +1,150 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { addContextParameter, findMissingContextParams } from '../src/go/context-param';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package store
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+)
+
+type Store struct{ db *sql.DB }
+
+func (s *Store) lookup(id string) (*sql.Rows, error) {
+	return s.db.Query("SELECT name FROM users WHERE id = ?", id)
+}
+
+func (s *Store) Names(ids []string) []string {
+	var names []string
+	for _, id := range ids {
+		if rows, err := s.lookup(id); err == nil {
+			rows.Close()
+			names = append(names, strings.ToUpper(id))
+		}
+	}
+	return names
+}
+
+func ping(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	_ = req
+	return nil
+}
+
+func check(url string) error {
+	return ping(context.Background(), url)
+}
+
+func Handle(w http.ResponseWriter, r *http.Request) {
+	_ = check(r.URL.String())
+}
+
+func normalize(name string) string {
+	return strings.TrimSpace(name)
+}
+`;
+
+describe('Go missing context parameters', () => {
+  const file = parseGoFile(source, '/src/store/store.go');
+
+  it('flags blocking calls and the callers they propagate to', () => {
+    const findings = findMissingContextParams([file]);
+
+    expect(findings.map(finding => [finding.function, finding.reason])).toEqual([
+      ['Store.lookup', 'calls s.db.Query'],
+      ['Store.Names', 'calls s.lookup, which needs a context'],
+      ['check', 'makes its own context with context.Background()'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'missing-context',
+      severity: 'medium',
+      line: 12,
+      column: 17,
+      fix: 'ctx context.Context',
+    });
+  });
+
+  it('does not flag pure functions', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const findings = findMissingContextParams([sample]);
+
+    expect(findings.map(finding => finding.function)).not.toContain('CalculateFibonacci');
+    expect(findings).toEqual([]);
+  });
+
+  it('takes a custom predicate and name pattern', () => {
+    const byName = findMissingContextParams([file], {
+      blockingCalls: [],
+      namePattern: /^normal/,
+    });
+    expect(byName.map(finding => finding.function)).toEqual(['check', 'normalize']);
+
+    const custom = findMissingContextParams([file], {
+      needsContext: candidate =>
+        candidate.calls.some(call => call.callee === 'strings.TrimSpace')
+          ? 'trims names'
+          : undefined,
+    });
+    expect(custom.map(finding => [finding.function, finding.reason])).toEqual([
+      ['check', 'calls ping, which takes a context'],
+      ['normalize', 'trims names'],
+    ]);
+  });
+
+  it('adds the parameter and threads it to callees and callers', () => {
+    const result = addContextParameter([file], { function: 'Store.lookup' });
+
+    expect(result.files).toHaveLength(1);
+    expect(result.files[0].source).toContain(
+      'func (s *Store) lookup(ctx context.Context, id string) (*sql.Rows, error) {\n' +
+        '\treturn s.db.QueryContext(ctx, "SELECT name FROM users WHERE id = ?", id)'
+    );
+    expect(result.files[0].source).toContain('s.lookup(context.TODO(), id)');
+    expect(result.callSites).toEqual([
+      { filePath: '/src/store/store.go', line: 19, column: 19, argument: 'context.TODO()' },
+    ]);
+    expect(result.threaded.map(site => site.line)).toEqual([13]);
+
+    const check = addContextParameter([file], { function: 'check' });
+    expect(check.files[0].source).toContain(
+      'func check(ctx context.Context, url string) error {\n\treturn ping(ctx, url)'
+    );
+    expect(check.files[0].source).toContain('_ = check(r.Context(), r.URL.String())');
+  });
+
+  it('adds the context import and refuses fixed signatures', () => {
+    const plain = parseGoFile(
+      `package jobs
+
+import "time"
+
+func wait() { time.Sleep(time.Second) }
+
+func run() { wait() }
+
+var hook = wait
+`,
+      '/src/jobs/jobs.go'
+    );
+    expect(() => addContextParameter([plain], { function: 'wait' })).toThrow(
+      'wait is used as a value on jobs.go:9'
+    );
+
+    const result = addContextParameter([plain], { function: 'run' });
+    expect(result.files[0].source).toContain('import (\n\t"context"\n\t"time"\n)');
+    expect(result.files[0].source).toContain('func run(ctx context.Context) { wait() }');
+    expect(() => addContextParameter([file], { function: 'ping' })).toThrow(
+      'ping already takes a context'
+    );
+  });
+});
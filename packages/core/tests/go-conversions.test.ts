@@ -0,0 +1,129 @@
+import { describe, it, expect } from '@jest/globals';
+import { findRedundantConversions, removeRedundantConversions } from '../src/go/conversions';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+
+const source = `package temp
+
+import "time"
+
+type Celsius float64
+
+type Fahrenheit float64
+
+const limit = 100
+
+func Convert(s string, c Celsius, f Fahrenheit, n int, d time.Duration, b []byte) {
+	use(string(s))
+	use(Celsius(c))
+	use(Celsius(f))
+	use(float64(c))
+	use(int64(n))
+	use(int(limit))
+	use(int(n) * 2)
+	use(time.Duration(d))
+	use([]byte(b))
+	use(string(string(s)))
+	use(-int(n + 1))
+	const local = 3
+	use(int(local))
+	use(int(len(s)))
+}
+
+func Typed() {
+	var x int = 1
+	use(int(x))
+	string := func(v int) int { return v }
+	use(string(x))
+}
+
+func use(v any) {}
+`;
+
+describe('Go redundant conversions', () => {
+  const file = parseGoFile(source, 'temp.go');
+
+  it('flags conversions to the type the operand already has', () => {
+    const findings = findRedundantConversions([file]);
+
+    expect(findings.map((finding) => [finding.line, finding.type, finding.fix])).toEqual([
+      [12, 'string', 's'],
+      [13, 'Celsius', 'c'],
+      [18, 'int', 'n'],
+      [19, 'time.Duration', 'd'],
+      [20, '[]byte', 'b'],
+      [21, 'string', 's'],
+      [21, 'string', 's'],
+      [22, 'int', '(n + 1)'],
+      [25, 'int', 'len(s)'],
+      [30, 'int', 'x'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'redundant-conversion',
+      severity: 'low',
+      message: 'string(s) converts a value that is already of type string',
+    });
+  });
+
+  it('leaves conversions between distinct named types and of untyped constants', () => {
+    const lines = findRedundantConversions([file]).map((finding) => finding.line);
+
+    // Celsius(f), float64(c), int64(n), int(limit) and int(local) change types
+    for (const line of [14, 15, 16, 17, 24]) expect(lines).not.toContain(line);
+    // A local function named string is no conversion
+    expect(lines).not.toContain(32);
+  });
+
+  it('removes every redundant conversion, nested ones included', () => {
+    const result = removeRedundantConversions(file);
+
+    expect(result.removed).toHaveLength(9);
+    expect(result.source).toContain('\tuse(s)\n\tuse(c)\n\tuse(Celsius(f))\n');
+    expect(result.source).toContain('\tuse(n * 2)\n\tuse(d)\n\tuse(b)\n\tuse(s)\n\tuse(-(n + 1))\n');
+    expect(findRedundantConversions([parseGoFile(result.source, 'temp.go')])).toEqual([]);
+  });
+
+  it('removes one line on request and sees types declared in other files', () => {
+    expect(removeRedundantConversions(file, { line: 13 }).source).toContain(
+      '\tuse(string(s))\n\tuse(c)\n'
+    );
+    expect(() => removeRedundantConversions(file, { line: 14 })).toThrow(GoRefactorError);
+
+    const types = parseGoFile('package temp\n\ntype ID string\n', 'types.go');
+    const user = parseGoFile('package temp\n\nfunc Key(id ID) ID {\n\treturn ID(id)\n}\n', 'key.go');
+    expect(findRedundantConversions([types, user]).map((finding) => finding.fix)).toEqual(['id']);
+    expect(removeRedundantConversions(user, {}, [types, user]).source).toContain('\treturn id\n');
+  });
+
+  it('leaves conversions whose operand type is only a guess', () => {
+    const arith = parseGoFile(
+      `package big
+
+const _W = 32
+
+type Word uint32
+
+func shift(x Word, s uint32, n int, c complex64, d complex128) {
+	use(int(_W * s))
+	use(uint(s * _W))
+	use(uint32(s * _W))
+	use(int(1 << s))
+	use(int(Word(n) + x))
+	use(float64(real(c)))
+	use(float32(imag(c)))
+	use(float64(real(d)))
+	use(float64(real(2i)))
+}
+
+func use(v any) {}
+`,
+      'arith.go'
+    );
+
+    expect(findRedundantConversions([arith]).map((finding) => [finding.line, finding.fix])).toEqual([
+      [10, 's * _W'],
+      [14, 'imag(c)'],
+      [15, 'real(d)'],
+    ]);
+  });
+});
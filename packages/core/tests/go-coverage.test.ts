@@ -0,0 +1,111 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { extractGoFileSymbols } from '../src/go/symbols';
+import { buildGoCallGraph } from '../src/go/callgraph';
+import { refactorConfidence } from '../src/go/confidence';
+import {
+  annotateCoverage,
+  coverageReport,
+  parseCoverProfile,
+  readCoverProfile,
+  symbolCoverage,
+} from '../src/go/coverage';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const profilePath = path.join(__dirname, 'fixtures', 'go', 'sample.coverprofile');
+
+describe('Go coverage mapping', () => {
+  it('should parse profiles and merge repeated blocks', () => {
+    const profile = parseCoverProfile(
+      [
+        'mode: count',
+        'example.com/m/a.go:3.14,5.2 2 1',
+        'example.com/m/a.go:7.10,7.20 1 0',
+        'mode: count',
+        'example.com/m/a.go:3.14,5.2 2 4',
+        '',
+      ].join('\n')
+    );
+
+    expect(profile.mode).toBe('count');
+    expect(profile.files.get('example.com/m/a.go')).toEqual([
+      {
+        fileName: 'example.com/m/a.go',
+        startLine: 3,
+        startColumn: 14,
+        endLine: 5,
+        endColumn: 2,
+        statements: 2,
+        count: 5,
+      },
+      {
+        fileName: 'example.com/m/a.go',
+        startLine: 7,
+        startColumn: 10,
+        endLine: 7,
+        endColumn: 20,
+        statements: 1,
+        count: 0,
+      },
+    ]);
+    expect(() => parseCoverProfile('a.go:1.1,2.1 1 1\n')).toThrow('line 1: expected a mode line first');
+    expect(() => parseCoverProfile('mode: set\nmode: count\n')).toThrow(
+      'line 2: mode count does not match mode set'
+    );
+    expect(() => parseCoverProfile('mode: set\na.go:1,2 1 1\n')).toThrow('line 2: malformed block');
+  });
+
+  it('should map profile blocks onto function symbols', async () => {
+    const profile = await readCoverProfile(profilePath);
+    const symbols = extractGoFileSymbols(parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath));
+    const report = coverageReport([symbols], profile);
+    const byName = new Map(report.map(entry => [entry.name, entry]));
+
+    expect(byName.get('CalculateFibonacci')).toMatchObject({
+      coveragePct: 100,
+      risk: 'safe',
+    });
+    expect(byName.get('DataProcessor.processItem')).toMatchObject({
+      coveredLines: 0,
+      risk: 'high-risk',
+    });
+    // The loop body runs, but the branch appending results does not
+    expect(byName.get('DataProcessor.ProcessData')).toMatchObject({
+      coveredLines: 4,
+      totalLines: 6,
+      coveragePct: 66.7,
+      risk: 'partial',
+    });
+    expect(report[0].coveragePct).toBe(0);
+    expect(report.at(-1)?.coveragePct).toBe(100);
+
+    annotateCoverage([symbols], profile);
+    const fibonacci = symbols.functions.find(f => f.name === 'CalculateFibonacci');
+    expect(fibonacci?.coveragePct).toBe(100);
+  });
+
+  it('should only match files sharing trailing path segments', async () => {
+    const profile = await readCoverProfile(profilePath);
+    const span = { startLine: 1, endLine: 100 };
+
+    expect(symbolCoverage(profile, '/work/sample/sample.go', span)).toBeDefined();
+    expect(symbolCoverage(profile, '/work/sample/other.go', span)).toBeUndefined();
+  });
+
+  it('should feed coverage into refactor confidence', async () => {
+    const coverage = await readCoverProfile(profilePath);
+    const graph = buildGoCallGraph([parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)]);
+
+    const fibonacci = refactorConfidence(graph, { kind: 'extract', target: 'main.CalculateFibonacci' }, { coverage });
+    const processItem = refactorConfidence(
+      graph,
+      { kind: 'extract', target: 'main.DataProcessor.processItem' },
+      { coverage }
+    );
+
+    expect(fibonacci.factors[1]).toEqual({ name: 'tests', score: 1, reason: '100% of lines covered' });
+    expect(processItem.factors[1]).toEqual({ name: 'tests', score: 0.6, reason: '0% of lines covered' });
+  });
+});
@@ -0,0 +1,124 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { buildGoCallGraph } from '../src/go/callgraph';
+import { findDeadFunctions } from '../src/go/deadcode';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const storeSource = `package store
+
+type saver interface {
+	save(key string) error
+}
+
+type diskStore struct{}
+
+func (d *diskStore) save(key string) error { return nil }
+func (d *diskStore) flush() {}
+
+func newStore() saver { return &diskStore{} }
+
+func walk(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return walk(n - 1)
+}
+
+func sortKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+}
+
+func less(a, b string) bool { return a < b }
+
+var defaultStore = newStore()
+
+func _() {}
+
+func init() {}
+`;
+
+const mainSource = `package main
+
+import "example.com/store"
+
+func main() {
+	store.Open()
+}
+`;
+
+describe('Go dead code detection', () => {
+  it('should flag unexported functions with no callers', () => {
+    const file = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const dead = findDeadFunctions([file]);
+
+    expect(dead).toEqual([
+      {
+        filePath: samplePath,
+        line: 97,
+        packageName: 'main',
+        name: 'privateHelper',
+        qualifiedName: 'privateHelper',
+      },
+    ]);
+  });
+
+  it('should keep interface methods, function values and initializers alive', () => {
+    const file = parseGoFile(storeSource, 'store.go');
+    const dead = findDeadFunctions([file]).map(d => d.qualifiedName);
+
+    // save satisfies saver; less is passed through a closure; newStore
+    // initializes a package variable; walk only calls itself
+    expect(dead).toEqual(['diskStore.flush', 'walk', 'sortKeys']);
+  });
+
+  it('should report exported symbols only in whole-program mode', () => {
+    const files = [
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath),
+      parseGoFile('package store\n\nfunc Open() {}\n\nfunc Close() {}\n', 'store/open.go'),
+      parseGoFile(
+        'package cmd\n\nimport "example.com/store"\n\nfunc Run() { store.Open() }\n',
+        'cmd/cmd.go'
+      ),
+    ];
+
+    expect(findDeadFunctions(files).map(d => d.name)).toEqual(['privateHelper']);
+    expect(
+      findDeadFunctions(files, { wholeProgram: true }).map(d => d.qualifiedName)
+    ).toEqual([
+      'NewDataProcessor',
+      'DataProcessor.ProcessData',
+      'DataProcessor.GetCacheSize',
+      'CalculateFibonacci',
+      'ProcessComplexData',
+      'privateHelper',
+      'Run',
+      'Close',
+    ]);
+  });
+
+  it('should treat main, init and test entry points as roots', () => {
+    const files = [
+      parseGoFile(mainSource, 'main.go'),
+      parseGoFile('package store\n\nfunc Open() {}\n', 'store/open.go'),
+      parseGoFile(
+        'package store\n\nimport "testing"\n\nfunc TestOpen(t *testing.T) { Open() }\n',
+        'store/open_test.go'
+      ),
+    ];
+
+    expect(findDeadFunctions(files, { wholeProgram: true })).toEqual([]);
+  });
+
+  it('should resolve calls across packages through imports', () => {
+    const graph = buildGoCallGraph([
+      parseGoFile(mainSource, 'main.go'),
+      parseGoFile('package store\n\nfunc Open() {}\n', 'store/open.go'),
+    ]);
+
+    expect([...graph.callees.get('main.main')]).toEqual(['store.Open']);
+    expect([...graph.callers.get('store.Open')]).toEqual(['main.main']);
+  });
+});
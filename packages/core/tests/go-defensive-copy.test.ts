@@ -0,0 +1,134 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findExposedMutableFields } from '../src/go/defensive-copy';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const sample = fs.readFileSync(path.join(fixtures, 'sample.go'), 'utf-8');
+const parse = (source: string) => parseGoFile(source, '/src/p/p.go');
+
+const store = `package p
+
+import "sync"
+
+type Names []string
+
+type Store struct {
+	mu    sync.Mutex
+	items []string
+	names Names
+	byID  map[int]string
+	size  int
+}
+
+func (s *Store) Items() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items
+}
+
+func (s *Store) Tail() []string {
+	return s.items[1:]
+}
+
+func (s Store) Names() (Names, error) {
+	return (s.names), nil
+}
+
+func (s *Store) Size() int {
+	return s.size
+}
+`;
+
+describe('Go defensive copies', () => {
+  it('leaves the sample alone, whose getters return derived values', () => {
+    expect(findExposedMutableFields([parse(sample)])).toEqual([]);
+  });
+
+  it('flags a map field returned as it is with a copy loop fix', () => {
+    const source = `${sample}
+// Cache returns the processor's cache
+func (dp *DataProcessor) Cache() map[string]interface{} {
+	return dp.cache
+}
+`;
+    const findings = findExposedMutableFields([parse(source)]);
+
+    expect(findings).toHaveLength(1);
+    expect(findings[0]).toMatchObject({
+      rule: 'exposed-mutable-field',
+      severity: 'low',
+      column: 9,
+      type: 'DataProcessor',
+      method: 'Cache',
+      field: 'cache',
+      kind: 'map',
+      message:
+        'DataProcessor.Cache returns dp.cache without copying it, so callers can change the map DataProcessor holds; return a copy',
+    });
+    expect(findings[0].fix).toBe(
+      [
+        'cache := make(map[string]interface{}, len(dp.cache))',
+        '\tfor k, v := range dp.cache {',
+        '\t\tcache[k] = v',
+        '\t}',
+        '\treturn cache',
+      ].join('\n'),
+    );
+  });
+
+  it('flags slice fields, re-slices and named slice types with an append fix', () => {
+    const findings = findExposedMutableFields([parse(store)]);
+
+    expect(findings.map((finding) => [finding.method, finding.line, finding.fix])).toEqual([
+      ['Items', 18, 'return append([]string(nil), s.items...)'],
+      ['Tail', 22, 'return append([]string(nil), s.items[1:]...)'],
+      ['Names', 26, 'return append(Names(nil), (s.names)...), nil'],
+    ]);
+    expect(findings.map((finding) => finding.kind)).toEqual(['slice', 'slice', 'slice']);
+  });
+
+  it('skips read-only methods, unexported methods and function literals', () => {
+    const source = `package p
+
+type Store struct {
+	items []string
+}
+
+// Items returns the items, which callers must not modify.
+func (s *Store) Items() []string {
+	return s.items
+}
+
+func (s *Store) items2() []string {
+	return s.items
+}
+
+func (s *Store) Each() func() []string {
+	return func() []string { return s.items }
+}
+`;
+    expect(findExposedMutableFields([parse(source)])).toEqual([]);
+  });
+
+  it('names the copy so it does not clash with names in the function', () => {
+    const source = `package p
+
+type Index struct {
+	byID map[int]string
+}
+
+func (byID *Index) ByID(k int) map[int]string {
+	if k < 0 {
+		byIDCopy := k
+		_ = byIDCopy
+	}
+	return byID.byID
+}
+`;
+    const [finding] = findExposedMutableFields([parse(source)]);
+
+    expect(finding.fix.split('\n')[0]).toBe('byIDCopy2 := make(map[int]string, len(byID.byID))');
+  });
+});
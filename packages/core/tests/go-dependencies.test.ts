@@ -0,0 +1,162 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { findDeadFunctions } from '../src/go/deadcode';
+import {
+  escapeModulePath,
+  GoModError,
+  loadGoDependencies,
+  parseGoMod,
+  resolveGoDependencies,
+} from '../src/go/dependencies';
+import { parseGoFile } from '../src/go/parser';
+
+const goMod = `module example.com/app
+
+go 1.21
+
+require (
+	example.com/lib v1.2.0 // indirect
+	github.com/Acme/util v0.3.1
+	example.com/local v0.0.0
+)
+
+replace example.com/local => ../local
+`;
+
+const mainSource = `package main
+
+import (
+	"fmt"
+
+	"example.com/lib"
+	"example.com/local/sub"
+	"github.com/Acme/util"
+	"example.com/app/internal"
+)
+
+type handler struct{}
+
+func (h handler) Serve() {
+	fmt.Println("served")
+}
+
+func (h handler) Unused() {}
+
+func main() {
+	lib.Run(handler{})
+	sub.Do()
+	util.Help()
+	internal.Setup()
+}
+`;
+
+describe('Go dependencies', () => {
+  let tempDir: string;
+  let root: string;
+  let moduleCache: string;
+
+  const write = (file: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(tempDir, file)), { recursive: true });
+    fs.writeFileSync(path.join(tempDir, file), content);
+  };
+  const main = () => parseGoFile(mainSource, path.join(root, 'main.go'));
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-dependencies-'));
+    root = path.join(tempDir, 'app');
+    moduleCache = path.join(tempDir, 'modcache');
+    write('app/go.mod', goMod);
+    write(
+      'modcache/example.com/lib@v1.2.0/lib.go',
+      'package lib\n\ntype Server interface {\n\tServe()\n}\n\nfunc Run(s Server) {\n\ts.Serve()\n}\n'
+    );
+    write('modcache/github.com/!acme/util@v0.3.1/util.go', 'package util\n\nfunc Help() {}\n');
+    write('local/sub/sub.go', 'package sub\n\nfunc Do() {}\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('parses require and replace directives, single and in blocks', () => {
+    expect(parseGoMod(goMod)).toEqual({
+      module: 'example.com/app',
+      require: [
+        { path: 'example.com/lib', version: 'v1.2.0' },
+        { path: 'github.com/Acme/util', version: 'v0.3.1' },
+        { path: 'example.com/local', version: 'v0.0.0' },
+      ],
+      replace: [{ path: 'example.com/local', newPath: '../local' }],
+    });
+    expect(() => parseGoMod('go 1.21\n')).toThrow(GoModError);
+    expect(() => parseGoMod('module a\nreplace b\n')).toThrow('go.mod:2: malformed replace directive');
+    expect(escapeModulePath('github.com/Acme/util')).toBe('github.com/!acme/util');
+  });
+
+  it('finds packages in the module cache and replacement directories', async () => {
+    const { packages, missing } = await resolveGoDependencies(root, [main()], { moduleCache });
+
+    expect(packages.map(({ importPath, source }) => [importPath, source])).toEqual([
+      ['example.com/lib', 'module-cache'],
+      ['example.com/local/sub', 'replace'],
+      ['github.com/Acme/util', 'module-cache'],
+    ]);
+    expect(packages[1].directory).toBe(path.join(tempDir, 'local', 'sub'));
+    expect(missing).toEqual([]);
+
+    fs.rmSync(path.join(moduleCache, 'example.com'), { recursive: true });
+    expect((await resolveGoDependencies(root, [main()], { moduleCache })).missing).toEqual([
+      'example.com/lib',
+    ]);
+  });
+
+  it('reads vendored packages when vendor/modules.txt exists', async () => {
+    write('app/vendor/modules.txt', '# example.com/lib v1.2.0\nexample.com/lib\n');
+    write('app/vendor/example.com/lib/lib.go', 'package lib\n\nfunc Run(s any) {}\n');
+
+    const { packages, missing } = await resolveGoDependencies(root, [main()], { moduleCache });
+
+    expect(packages).toEqual([
+      {
+        importPath: 'example.com/lib',
+        module: 'example.com/lib',
+        version: 'v1.2.0',
+        directory: path.join(root, 'vendor', 'example.com', 'lib'),
+        source: 'vendor',
+      },
+    ]);
+    expect(missing).toEqual(['example.com/local/sub', 'github.com/Acme/util']);
+    await expect(resolveGoDependencies(tempDir, [main()])).rejects.toThrow(GoModError);
+  });
+
+  it('caches what each package contributes between runs', async () => {
+    const cacheDirectory = path.join(tempDir, 'cache');
+    const first = await loadGoDependencies(root, [main()], { moduleCache, cacheDirectory });
+    const second = await loadGoDependencies(root, [main()], { moduleCache, cacheDirectory });
+
+    expect(first.cached).toEqual([]);
+    expect(second.cached).toEqual(['example.com/lib', 'example.com/local/sub', 'github.com/Acme/util']);
+    expect(second.files).toEqual(first.files);
+    expect(first.files.flatMap((file) => file.nodes.map((node) => node.id))).toEqual([
+      'lib.Run',
+      'sub.Do',
+      'util.Help',
+    ]);
+
+    write('local/sub/sub.go', 'package sub\n\nfunc Do() {}\n\nfunc Undo() {}\n');
+    const third = await loadGoDependencies(root, [main()], { moduleCache, cacheDirectory });
+    expect(third.cached).toEqual(['example.com/lib', 'github.com/Acme/util']);
+  });
+
+  it('keeps methods a dependency calls through an interface alive', async () => {
+    const file = main();
+    const { files } = await loadGoDependencies(root, [file], { moduleCache });
+    const dead = (options = {}) =>
+      findDeadFunctions([file], { wholeProgram: true, ...options }).map((d) => d.qualifiedName);
+
+    expect(dead()).toEqual(['handler.Serve', 'handler.Unused']);
+    expect(dead({ dependencies: files })).toEqual(['handler.Unused']);
+  });
+});
@@ -0,0 +1,104 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { discoverGoFiles } from '../src/go/discover';
+
+describe('Go file discovery', () => {
+  let tempDir: string;
+
+  const write = (rel: string, content = 'package main\n') => {
+    const filePath = path.join(tempDir, ...rel.split('/'));
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, content);
+  };
+
+  const discover = async (options = {}) =>
+    (await discoverGoFiles(tempDir, options)).map(file =>
+      path.relative(tempDir, file).split(path.sep).join('/')
+    );
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-discover-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should honor nested .gitignore files and negations', async () => {
+    write('main.go');
+    write('gen/api.pb.go');
+    write('gen/keep.pb.go');
+    write('internal/build/out.go');
+    write('internal/util.go');
+    write('.gitignore', '*.pb.go\n!keep.pb.go\n');
+    write('internal/.gitignore', '# generated\n/build/\n');
+    write('.git/info/exclude', 'main.go\n');
+
+    expect(await discover()).toEqual(['gen/keep.pb.go', 'internal/util.go']);
+    expect(await discover({ respectGitignore: false })).toEqual([
+      'gen/api.pb.go',
+      'gen/keep.pb.go',
+      'internal/build/out.go',
+      'internal/util.go',
+      'main.go',
+    ]);
+  });
+
+  it('should skip vendor, testdata and hidden directories by default', async () => {
+    write('main.go');
+    write('vendor/dep/dep.go');
+    write('pkg/testdata/input.go');
+    write('.cache/x.go');
+    write('_tools/tool.go');
+
+    expect(await discover()).toEqual(['main.go']);
+    expect(await discover({ includeVendor: true, includeTestdata: true })).toEqual([
+      'main.go',
+      'pkg/testdata/input.go',
+      'vendor/dep/dep.go',
+    ]);
+  });
+
+  it('should filter by include and exclude globs', async () => {
+    write('cmd/app/main.go');
+    write('pkg/a/a.go');
+    write('pkg/a/a_test.go');
+    write('pkg/b/b.go');
+    write('pkg/b/mock_b.go');
+    write('README.md', '# readme\n');
+
+    expect(await discover({ include: ['pkg/**'], exclude: ['**/mock_*.go'] })).toEqual([
+      'pkg/a/a.go',
+      'pkg/a/a_test.go',
+      'pkg/b/b.go',
+    ]);
+    expect(await discover({ include: ['{cmd,pkg/b}/**/*.go'], exclude: ['pkg/b/mock_?.go'] })).toEqual([
+      'cmd/app/main.go',
+      'pkg/b/b.go',
+    ]);
+    expect(await discover({ exclude: ['cmd'], includeTests: false })).toEqual([
+      'pkg/a/a.go',
+      'pkg/b/b.go',
+      'pkg/b/mock_b.go',
+    ]);
+  });
+
+  it('should stop at symlink loops and links leaving the root', async () => {
+    write('pkg/a.go');
+    const outside = fs.mkdtempSync(path.join(os.tmpdir(), 'go-discover-outside-'));
+    try {
+      fs.writeFileSync(path.join(outside, 'secret.go'), 'package secret\n');
+      fs.symlinkSync(path.join(tempDir, 'pkg'), path.join(tempDir, 'pkg', 'loop'));
+      fs.symlinkSync(outside, path.join(tempDir, 'external'));
+      fs.symlinkSync(path.join(outside, 'secret.go'), path.join(tempDir, 'secret.go'));
+      fs.symlinkSync(path.join(tempDir, 'pkg', 'a.go'), path.join(tempDir, 'alias.go'));
+      fs.symlinkSync(path.join(tempDir, 'missing.go'), path.join(tempDir, 'dangling.go'));
+
+      expect(await discover()).toEqual(['pkg/a.go']);
+    } finally {
+      fs.rmSync(outside, { recursive: true, force: true });
+    }
+  });
+});
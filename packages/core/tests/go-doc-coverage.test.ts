@@ -0,0 +1,97 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { formatGoDocCoverage, goDocCoverage } from '../src/go/doc-coverage';
+import { parseGoFile } from '../src/go/parser';
+
+const fixtures = path.join(__dirname, 'fixtures');
+const samplePath = path.join(fixtures, 'go', 'sample.go');
+
+const source = `package store
+
+// A Store keeps values
+type Store struct{}
+
+// Open opens a store
+func Open() *Store { return nil }
+
+// closes the store
+func (s *Store) Close() error { return nil }
+
+func (s *Store) Len() int { return 0 }
+
+type (
+	// Mode selects how values are kept
+	Mode int
+	Level int
+)
+
+// Limits of a store
+const (
+	// MaxKeys caps the number of keys
+	MaxKeys = 100
+	MaxSize = 1 << 20
+	// the default, in seconds
+	Timeout = 30
+)
+
+func (m Mode) String() string { return "" }
+
+type logger struct{}
+
+// Print is on an unexported type
+func (l logger) Print() {}
+`;
+
+describe('Go documentation coverage', () => {
+  it('scores the sample fully, its constants documented by their group', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const coverage = goDocCoverage([sample], { root: fixtures });
+
+    expect(coverage).toMatchObject({ total: 8, documented: 8, percent: 100, undocumented: [] });
+    expect(coverage.symbols.filter(symbol => symbol.kind === 'const').map(symbol => [symbol.name, symbol.status])).toEqual([
+      ['API_VERSION', 'group'],
+      ['MAX_RETRIES', 'group'],
+    ]);
+  });
+
+  it('requires own comments to begin with the name', () => {
+    const coverage = goDocCoverage([parseGoFile(source, '/repo/store/store.go')], { root: '/repo' });
+
+    expect(coverage.symbols.map(symbol => [symbol.kind, symbol.name, symbol.status])).toEqual([
+      ['type', 'Store', 'documented'],
+      ['func', 'Open', 'documented'],
+      ['method', 'Store.Close', 'misnamed'],
+      ['method', 'Store.Len', 'missing'],
+      ['type', 'Mode', 'documented'],
+      ['type', 'Level', 'missing'],
+      ['const', 'MaxKeys', 'documented'],
+      ['const', 'MaxSize', 'group'],
+      ['const', 'Timeout', 'misnamed'],
+      ['method', 'Mode.String', 'missing'],
+    ]);
+    expect(coverage).toMatchObject({ total: 10, documented: 5, percent: 50 });
+  });
+
+  it('formats the percentage and the undocumented symbols', () => {
+    const coverage = goDocCoverage([parseGoFile(source, '/repo/store/store.go')], { root: '/repo' });
+
+    expect(formatGoDocCoverage(coverage, { root: '/repo' })).toBe(
+      [
+        'Documentation coverage: 50.0% (5 of 10 exported symbols)',
+        'store/store.go:10: method Store.Close: doc comment does not begin with Close',
+        'store/store.go:12: method Store.Len: no doc comment',
+        'store/store.go:17: type Level: no doc comment',
+        'store/store.go:26: const Timeout: doc comment does not begin with Timeout',
+        'store/store.go:29: method Mode.String: no doc comment',
+        '',
+      ].join('\n')
+    );
+  });
+
+  it('leaves out test files and counts an empty API as covered', () => {
+    const coverage = goDocCoverage([parseGoFile(source, '/repo/store/store_test.go')], { root: '/repo' });
+
+    expect(coverage).toEqual({ total: 0, documented: 0, percent: 100, undocumented: [], symbols: [] });
+  });
+});
@@ -0,0 +1,111 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findDocDrift, fixDocNames } from '../src/go/doc-sync';
+import { parseGoFile } from '../src/go/parser';
+import { applyGoQuickFixes } from '../src/go/quick-fix';
+import { GoRefactorError } from '../src/go/refactor';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package fetch
+
+import "time"
+
+type Client struct{ timeout time.Duration }
+
+// Fetch fetches a page, giving up after \`maxRetries\` attempts.
+func Get(url string) string { return url }
+
+// ProcessData runs the client.
+func (c *Client) Run() {}
+
+// Returns the timeout.
+func (c *Client) Timeout() time.Duration { return c.timeout }
+
+// Client.Open opens the client.
+func (c *Client) Open() {}
+
+// Close closes the client, waiting up to retryDelay for the
+// connection to drain. See https://example.com/closeNow for why gRPC and
+// macOS need it; the client's timeout is kept.
+//
+//	stopAll := true
+func (c *Client) Close(maxWait time.Duration) {}
+
+// Dial dials \`addr\`, respecting maxWait.
+func Dial(addr string) {
+	maxWait := time.Second
+	_ = maxWait
+}
+
+// renamed is unexported.
+func helper() {}
+`;
+
+describe('Go doc comment drift', () => {
+  const file = parseGoFile(source, '/src/fetch/client.go');
+  const findings = findDocDrift([file]);
+
+  it('flags doc comments that do not begin with the function name', () => {
+    const names = findings.filter(finding => finding.rule === 'stale-doc-name');
+
+    expect(names.map(finding => [finding.line, finding.function, finding.mention, finding.fix])).toEqual([
+      [7, 'Get', 'Fetch', '// Get fetches a page, giving up after `maxRetries` attempts.'],
+      [10, 'Client.Run', 'ProcessData', '// Run runs the client.'],
+      [13, 'Client.Timeout', 'Returns', undefined],
+    ]);
+    expect(names[0]).toMatchObject({
+      severity: 'low',
+      column: 4,
+      message: 'Doc comment of Get begins with Fetch instead of its name Get',
+    });
+  });
+
+  it('flags code-like mentions that nothing declares', () => {
+    const mentions = findings.filter(finding => finding.rule === 'stale-doc-parameter');
+
+    expect(mentions.map(finding => [finding.line, finding.column, finding.mention])).toEqual([
+      [7, 43, 'maxRetries'],
+      [19, 43, 'retryDelay'],
+    ]);
+    expect(mentions[1].message).toBe(
+      'Doc comment of Client.Close mentions retryDelay, which nothing in the function or its package declares; its parameters are maxWait'
+    );
+  });
+
+  it('leaves accurate comments alone, the sample included', () => {
+    expect(findings.map(finding => finding.function)).not.toContain('Client.Open');
+    expect(findings.map(finding => finding.function)).not.toContain('Dial');
+    expect(findings.map(finding => finding.function)).not.toContain('helper');
+    expect(findDocDrift([parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)])).toEqual([]);
+    expect(findDocDrift([parseGoFile('package p\n\n// Old does it.\nfunc New() {}\n', '/p/p_test.go')])).toEqual([]);
+  });
+
+  it('corrects the leading name of one comment, or all', () => {
+    const one = fixDocNames(file, { line: 10 });
+
+    expect(one.renamed).toEqual([{ line: 10, column: 4, from: 'ProcessData', to: 'Run' }]);
+    expect(one.source).toContain('// Run runs the client.\nfunc (c *Client) Run() {}');
+    expect(fixDocNames(file).renamed.map(rename => rename.to)).toEqual(['Get', 'Run']);
+    expect(() => fixDocNames(file, { line: 13 })).toThrow(GoRefactorError);
+    expect(() => fixDocNames(file, { line: 13 })).toThrow(
+      'Line 13 has no doc comment beginning with a former name'
+    );
+  });
+
+  it('runs as built-in rules whose name fix is a quick fix', () => {
+    const registry = defaultGoRuleRegistry();
+    const rules = registry.list().filter(rule => rule.id.startsWith('stale-doc-'));
+
+    expect(rules.map(rule => [rule.id, rule.severity])).toEqual([
+      ['stale-doc-name', 'low'],
+      ['stale-doc-parameter', 'low'],
+    ]);
+    const result = applyGoQuickFixes([file], registry.run([file]), { rules: ['stale-doc-name'] });
+    expect(result.applied.map(finding => finding.line)).toEqual([7, 10]);
+    expect(result.skipped.map(({ finding }) => finding.line)).toEqual([13]);
+    expect(findDocDrift([parseGoFile(result.changes[0].content, file.filePath)]).filter(f => f.rule === 'stale-doc-name')).toHaveLength(1);
+  });
+});
@@ -0,0 +1,113 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findErrorComparisons, useErrorsIs } from '../src/go/error-compare';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package store
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+var ErrNotFound = fmt.Errorf("not found")
+
+type NotFoundError struct{ Key string }
+
+func (e *NotFoundError) Error() string { return e.Key }
+
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+func Read(f *os.File) error {
+	_, err := os.ReadFile(f.Name())
+	if err == io.EOF {
+		return nil
+	}
+	if ErrNotFound != err {
+		return err
+	}
+	return nil
+}
+
+func Missing(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+`;
+
+describe('Go error comparisons', () => {
+  const file = parseGoFile(source, 'store.go');
+
+  it('flags comparisons with sentinels and assertions on errors', () => {
+    const findings = findErrorComparisons([file]);
+
+    expect(findings.map(finding => [finding.rule, finding.line, finding.fix])).toEqual([
+      ['error-comparison', 19, 'errors.Is(err, io.EOF)'],
+      ['error-comparison', 22, '!errors.Is(err, ErrNotFound)'],
+      ['error-type-assertion', 32, undefined],
+    ]);
+    expect(findings[0].message).toBe('err == io.EOF does not match wrapped errors; use errors.Is(err, io.EOF)');
+    expect(findings[2].message).toContain('use errors.As(err, &target) with a *NotFoundError target');
+    expect(defaultGoRuleRegistry().run([file]).filter(f => f.rule === 'error-comparison')).toHaveLength(2);
+  });
+
+  it('leaves nil comparisons and Is methods alone', () => {
+    const lines = findErrorComparisons([file]).map(finding => finding.line);
+
+    expect(lines).not.toContain(15);
+    expect(lines).not.toContain(29);
+  });
+
+  it('leaves comparisons of non-errors and the errors package alone', () => {
+    const recovered = parseGoFile(
+      'package store\n\nfunc Safe(run func()) (failed bool) {\n\tdefer func() {\n\t\tif r := recover(); r == ErrNotFound {\n\t\t\tfailed = true\n\t\t}\n\t}()\n\trun()\n\treturn false\n}\n',
+      'safe.go'
+    );
+    expect(findErrorComparisons([file, recovered]).map(finding => finding.filePath)).not.toContain('safe.go');
+
+    const errs = parseGoFile(
+      'package errors\n\nvar ErrUnsupported = New("unsupported")\n\nfunc is(err, target error) bool {\n\treturn err == target || err == ErrUnsupported\n}\n',
+      '/go/src/errors/wrap.go'
+    );
+    expect(findErrorComparisons([errs])).toEqual([]);
+  });
+
+  it('rewrites comparisons and adds the errors import', () => {
+    const result = useErrorsIs(file);
+
+    expect(result.rewritten).toEqual([
+      { line: 19, column: 5 },
+      { line: 22, column: 5 },
+    ]);
+    expect(result.source).toContain('import (\n\t"errors"\n\t"fmt"\n\t"io"\n\t"os"\n)');
+    expect(result.source).toContain('if errors.Is(err, io.EOF) {');
+    expect(result.source).toContain('if !errors.Is(err, ErrNotFound) {');
+    expect(result.source).toContain('return target == ErrNotFound');
+    expect(() => useErrorsIs(file, { line: 29 })).toThrow('Line 29 has no comparison of errors');
+  });
+
+  it('finds sentinels declared elsewhere in the package and respects a hidden package', () => {
+    const errs = parseGoFile('package store\n\nimport "errors"\n\nvar ErrClosed = errors.New("closed")\n', 'errs.go');
+    const other = parseGoFile(
+      'package store\n\nfunc Closed(err error) bool {\n\terrors := 0\n\t_ = errors\n\treturn err == ErrClosed\n}\n',
+      'closed.go'
+    );
+
+    expect(findErrorComparisons([errs, other])).toMatchObject([{ rule: 'error-comparison', line: 6 }]);
+    expect(findErrorComparisons([errs, other])[0].fix).toBeUndefined();
+    expect(() => useErrorsIs(other, { line: 6 }, [errs, other])).toThrow(
+      'Cannot use errors.Is: a local variable hides the errors package'
+    );
+
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    expect(findErrorComparisons([sample])).toEqual([]);
+  });
+});
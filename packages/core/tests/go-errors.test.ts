@@ -0,0 +1,200 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findErrorHandlingIssues, restyleErrorStrings } from '../src/go/errors';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const storeSource = `package store
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Store struct{}
+
+func (s *Store) Save(key string) error { return nil }
+
+func Cleanup(s *Store, path string) (int, error) {
+	os.Remove(path)
+	_ = s.Save("key")
+	n, _ := strconv.Atoi("12")
+	data, err := load(path)
+	if err != nil {
+		return 0, fmt.Errorf("load %s: %v", path, err)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	go func() {
+		_, _ = load(path)
+	}()
+	return n, nil
+}
+`;
+
+const loadSource = `package store
+
+import "os"
+
+func load(path string) ([]byte, error) { return os.ReadFile(path) }
+`;
+
+function check(options = {}) {
+  return findErrorHandlingIssues(
+    [parseGoFile(storeSource, 'store.go'), parseGoFile(loadSource, 'load.go')],
+    options
+  );
+}
+
+describe('Go error-handling checks', () => {
+  it('should not flag the fixture, whose empty-input branch returns an error', () => {
+    const content = fs.readFileSync(samplePath, 'utf-8');
+    expect(findErrorHandlingIssues([parseGoFile(content, samplePath)])).toEqual([]);
+  });
+
+  it('should report each anti-pattern with a severity', () => {
+    expect(check().map((f) => [f.line, f.rule, f.severity, f.message])).toEqual([
+      [14, 'ignored-error', 'high', 'error returned by os.Remove is not checked'],
+      [15, 'ignored-error', 'medium', 'error returned by s.Save is discarded with _'],
+      [16, 'ignored-error', 'medium', 'error returned by strconv.Atoi is discarded with _'],
+      [
+        19,
+        'unwrapped-error',
+        'medium',
+        'fmt.Errorf formats err with %v; use %w so callers can match it with errors.Is and errors.As'
+      ],
+      [
+        22,
+        'nil-error-zero-value',
+        'low',
+        'early return of 0, nil reports success without a value; return an error if this branch is a failure'
+      ],
+      [25, 'ignored-error', 'medium', 'error returned by load is discarded with _']
+    ]);
+  });
+
+  it('should suggest fixes that propagate the error', () => {
+    const fixes = check().map((f) => f.fix);
+
+    expect(fixes[0]).toBe('if err := os.Remove(path); err != nil {\n\treturn 0, err\n}');
+    expect(fixes[2]).toBe('n, err := strconv.Atoi("12")\nif err != nil {\n\treturn 0, err\n}');
+    expect(fixes[3]).toBe('fmt.Errorf("load %s: %w", path, err)');
+    expect(fixes[4]).toBe('return 0, errors.New("<describe the failure>")');
+    // The goroutine has no error result to return to
+    expect(fixes[5]).toBe('if _, err := load(path); err != nil {\n\t// handle err\n}');
+  });
+
+  it('should keep explicit argument indexes when suggesting %w', () => {
+    const source = `package p
+
+import "fmt"
+
+func wrap(path string, err error) error {
+	return fmt.Errorf("%[2]s: %[1]v", err, path)
+}
+`;
+    const [finding] = findErrorHandlingIssues([parseGoFile(source, 'p.go')]);
+    expect(finding.fix).toBe('fmt.Errorf("%[2]s: %[1]w", err, path)');
+  });
+
+  it('should make the wrapping check optional', () => {
+    const rules = check({ requireWrapping: false }).map((f) => f.rule);
+    expect(rules).not.toContain('unwrapped-error');
+    expect(rules).toContain('ignored-error');
+  });
+
+  it('should flag capitalized error strings and trailing punctuation', () => {
+    const source = `package p
+
+import (
+	"errors"
+	fmt2 "fmt"
+)
+
+var (
+	errA = errors.New("Invalid input.")
+	errB = fmt2.Errorf("Key %q not found: %w", "k", errA)
+	errC = errors.New("JSON decoding failed")
+	errD = errors.New("GetUser returned nothing\\n")
+	errE = errors.New("waiting...")
+	errF = errors.New(\`Stripe rejected the card!\`)
+)
+
+func f() error {
+	return errors.New("Something went wrong!")
+}
+
+func g() error { return errors.New("ok") }
+`;
+    const findings = findErrorHandlingIssues([parseGoFile(source, 'p.go')]);
+
+    expect(findings.map((f) => [f.line, f.message])).toEqual([
+      [9, 'error strings should not be capitalized or end with punctuation: "Invalid input."'],
+      [10, 'error strings should not be capitalized: "Key %q not found: %w"'],
+      [12, 'error strings should not end with punctuation: "GetUser returned nothing\\n"'],
+      [14, 'error strings should not be capitalized or end with punctuation: `Stripe rejected the card!`'],
+      [18, 'error strings should not be capitalized or end with punctuation: "Something went wrong!"'],
+    ]);
+    expect(findings[4]).toMatchObject({
+      rule: 'error-string-style',
+      severity: 'low',
+      column: 20,
+      fix: 'errors.New("something went wrong")',
+    });
+    expect(findings[1].fix).toBe('fmt2.Errorf("key %q not found: %w", "k", errA)');
+    expect(findings[3].fix).toBe('errors.New(`stripe rejected the card`)');
+  });
+
+  it('should keep configured words capitalized', () => {
+    const source = `package p
+
+import "errors"
+
+func f() error {
+	if true {
+		return errors.New("Stripe rejected the card.")
+	}
+	return errors.New("GetUser failed: \\n")
+}
+`;
+    const file = parseGoFile(source, 'p.go');
+    expect(findErrorHandlingIssues([file]).map((f) => f.fix)).toEqual([
+      'errors.New("stripe rejected the card")',
+      'errors.New("GetUser failed: ")',
+    ]);
+    expect(
+      findErrorHandlingIssues([file], { capitalizedWords: ['Stripe'] }).map((f) => f.fix),
+    ).toEqual(['errors.New("Stripe rejected the card")', 'errors.New("GetUser failed: ")']);
+  });
+
+  it('should restyle error strings in place, by line or all at once', () => {
+    const source = `package p
+
+import "errors"
+
+var ErrClosed = errors.New("Connection closed.")
+
+func f() error {
+	return errors.New("Stripe rejected the card!")
+}
+`;
+    const file = parseGoFile(source, 'p.go');
+    const all = restyleErrorStrings(file, { capitalizedWords: ['Stripe'] });
+
+    expect(all.restyled).toEqual([
+      { line: 5, column: 28 },
+      { line: 8, column: 20 },
+    ]);
+    expect(all.source).toContain('errors.New("connection closed")');
+    expect(all.source).toContain('errors.New("Stripe rejected the card")');
+    expect(restyleErrorStrings(file, { line: 8 }).source).toContain(
+      'errors.New("Connection closed.")',
+    );
+    expect(() => restyleErrorStrings(file, { line: 3 })).toThrow(GoRefactorError);
+  });
+});
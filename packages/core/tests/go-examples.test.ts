@@ -0,0 +1,181 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import {
+  findExampleProblems,
+  formatGoExampleCoverage,
+  goExampleCoverage,
+  goExamples,
+} from '../src/go/examples';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const sample = parseGoFile(
+  fs.readFileSync(path.join(fixtures, 'sample.go'), 'utf-8'),
+  '/src/sample/sample.go',
+);
+const parse = (source: string, name = 'example_test.go') =>
+  parseGoFile(source, `/src/sample/${name}`);
+
+const examples = parse(`package main_test
+
+import "fmt"
+
+func Example() {
+	fmt.Println("hello")
+	// Output: hello
+}
+
+func ExampleNewDataProcessor() {
+	dp := NewDataProcessor(nil)
+	_ = dp
+}
+
+// ExampleDataProcessor_ProcessData_empty processes nothing
+func ExampleDataProcessor_ProcessData_empty() {
+	fmt.Println(len(NewDataProcessor(nil).ProcessData(nil)))
+	// Unordered output:
+	// 0
+}
+
+func ExampleDataProcessor_cache() {}
+
+func ExampleCalculateFibonacci() {
+	// a note
+	fmt.Println(CalculateFibonacci(10))
+	// output:
+	// 55
+}
+
+func Examplehelper() {}
+`);
+
+describe('Go examples', () => {
+  it('lists examples with their subjects, suffixes and output', () => {
+    const found = goExamples([sample, examples], { root: '/src' });
+
+    expect(
+      found.map(({ name, subject, suffix, runnable, unordered, output }) => ({
+        name,
+        subject,
+        suffix,
+        runnable,
+        unordered,
+        output,
+      })),
+    ).toEqual([
+      { name: 'Example', runnable: true, unordered: false, output: 'hello' },
+      { name: 'ExampleNewDataProcessor', subject: 'NewDataProcessor', runnable: false, unordered: false },
+      {
+        name: 'ExampleDataProcessor_ProcessData_empty',
+        subject: 'DataProcessor.ProcessData',
+        suffix: 'empty',
+        runnable: true,
+        unordered: true,
+        output: '0',
+      },
+      {
+        name: 'ExampleDataProcessor_cache',
+        subject: 'DataProcessor',
+        suffix: 'cache',
+        runnable: false,
+        unordered: false,
+      },
+      {
+        name: 'ExampleCalculateFibonacci',
+        subject: 'CalculateFibonacci',
+        runnable: true,
+        unordered: false,
+        output: '55',
+      },
+    ]);
+    expect(found.every((example) => example.package === 'sample')).toBe(true);
+    expect(found.every((example) => example.problems.length === 0)).toBe(true);
+  });
+
+  it('reports exported symbols without examples', () => {
+    const coverage = goExampleCoverage([sample, examples], { root: '/src' });
+
+    expect(coverage.missing.map((symbol) => `${symbol.kind} ${symbol.name}`)).toEqual([
+      'method DataProcessor.GetCacheSize',
+      'func ProcessComplexData',
+    ]);
+    expect(coverage.symbols.find((symbol) => symbol.name === 'DataProcessor')?.examples).toEqual([
+      'ExampleDataProcessor_cache',
+    ]);
+    expect([coverage.covered, coverage.total]).toEqual([4, 6]);
+  });
+
+  it('flags examples naming symbols that no longer exist', () => {
+    const stale = parse(
+      `package main
+
+func ExampleNewProcessor() {}
+
+func ExampleDataProcessor_Process() {}
+
+func ExampleDataProcessor_config() {}
+`,
+      'stale_test.go',
+    );
+    const findings = findExampleProblems([sample, stale]);
+
+    expect(findings.map((finding) => [finding.rule, finding.line, finding.message])).toEqual([
+      [
+        'stale-example',
+        3,
+        'ExampleNewProcessor refers to unknown identifier: NewProcessor; rename the example after the symbol it documents',
+      ],
+      [
+        'stale-example',
+        5,
+        'ExampleDataProcessor_Process refers to unknown field or method: DataProcessor.Process; rename the example after the symbol it documents',
+      ],
+    ]);
+  });
+
+  it('flags signatures and suffixes go vet rejects', () => {
+    const malformed = parse(
+      `package main
+
+func Example_Bad() {}
+
+func ExampleCalculateFibonacci_Bad_Suffix() {}
+
+func ExampleNewDataProcessor_second(n int) int { return n }
+
+func ExampleDataProcessor_GetCacheSize_result[T any]() {}
+`,
+      'malformed_test.go',
+    );
+    const findings = findExampleProblems([sample, malformed]);
+
+    expect(findings.map((finding) => [finding.rule, finding.message])).toEqual([
+      ['malformed-example', 'Example_Bad has malformed example suffix: Bad'],
+      ['malformed-example', 'ExampleCalculateFibonacci_Bad_Suffix has malformed example suffix: Suffix'],
+      [
+        'stale-example',
+        'ExampleCalculateFibonacci_Bad_Suffix refers to unknown field or method: CalculateFibonacci.Bad; rename the example after the symbol it documents',
+      ],
+      ['malformed-example', 'ExampleNewDataProcessor_second should be niladic'],
+      ['malformed-example', 'ExampleNewDataProcessor_second should return nothing'],
+      ['malformed-example', 'ExampleDataProcessor_GetCacheSize_result should not have type params'],
+    ]);
+  });
+
+  it('formats coverage with runnable counts, missing symbols and problems', () => {
+    const stale = parse('package main\n\nfunc ExampleGone() {}\n', 'stale_test.go');
+    const text = formatGoExampleCoverage(goExampleCoverage([sample, examples, stale], { root: '/src' }), {
+      root: '/src',
+    });
+
+    expect(text.split('\n')).toEqual([
+      'Example coverage: 66.7% (4 of 6 exported symbols)',
+      'Examples: 6, 3 runnable',
+      'sample/sample.go:43: method DataProcessor.GetCacheSize: no example',
+      'sample/sample.go:62: func ProcessComplexData: no example',
+      'sample/stale_test.go:3: ExampleGone refers to unknown identifier: Gone',
+      '',
+    ]);
+  });
+});
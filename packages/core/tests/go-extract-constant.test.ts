@@ -0,0 +1,87 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { extractConstant } from '../src/go/extract-constant';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package main
+
+import "fmt"
+
+const itemLengthLimit = 10
+
+// retry sends until it succeeds
+func retry(send func() error) {
+	buf := make([]byte, 0, 512)
+	for attempt := 0; attempt < 3; attempt++ {
+		if send() == nil || attempt*2 > -3 {
+			return
+		}
+		if attempt >= 3 {
+			fmt.Println("giving up", len(buf), 3)
+		}
+	}
+}
+`;
+
+describe('Go extract constant', () => {
+  const file = parseGoFile(source, 'main.go');
+
+  it('extracts the threshold in processTypeA above the function', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const result = extractConstant(sample, { line: 85 });
+
+    expect(result.name).toBe('itemLengthLimit');
+    expect(result.value).toBe('5');
+    expect(result.source).toContain(
+      'const itemLengthLimit = 5\n\n// processTypeA handles type A items\nfunc processTypeA(item string) string {\n\tif len(item) > itemLengthLimit {'
+    );
+    expect(result.replaced).toEqual([{ line: 85, column: 17 }]);
+  });
+
+  it('replaces identical thresholds in the declaration when asked', () => {
+    const one = extractConstant(file, { line: 10, name: 'maxAttempts' });
+    expect(one.replaced).toEqual([{ line: 10, column: 30 }]);
+
+    const all = extractConstant(file, { line: 10, name: 'maxAttempts', all: true });
+    expect(all.replaced.map(position => position.line)).toEqual([10, 14]);
+    expect(all.source).toContain('const maxAttempts = 3\n\n// retry sends');
+    expect(all.source).toContain('attempt < maxAttempts; attempt++');
+    expect(all.source).toContain('if attempt >= maxAttempts {');
+    // Arithmetic and arguments are not thresholds
+    expect(all.source).toContain('attempt*2 > -3');
+    expect(all.source).toContain('len(buf), 3)');
+  });
+
+  it('infers names that do not collide and keeps signs', () => {
+    const capacity = extractConstant(file, { line: 9 });
+    expect(capacity.name).toBe('bufCapacity');
+    expect(capacity.source).toContain('make([]byte, 0, bufCapacity)');
+
+    const negative = extractConstant(file, { line: 11 });
+    expect(negative.value).toBe('-3');
+    expect(negative.name).toBe('magicNumber');
+
+    const taken = parseGoFile(source.replace('attempt < 3', 'len(item) < 3'), 'main.go');
+    expect(extractConstant(taken, { line: 10 }).name).toBe('itemLengthLimit2');
+    expect(() => extractConstant(file, { line: 10, name: 'send' })).toThrow(
+      'send is already declared in the package or the function'
+    );
+  });
+
+  it('only targets other positions on request', () => {
+    expect(() => extractConstant(file, { line: 11, column: 31 })).toThrow(GoRefactorError);
+    expect(() => extractConstant(file, { line: 11, anyPosition: true })).toThrow(
+      'Line 11 has 2 numbers'
+    );
+    expect(() => extractConstant(file, { line: 5 })).toThrow(
+      '10 is already part of a constant declaration'
+    );
+
+    const arithmetic = extractConstant(file, { line: 11, column: 31, anyPosition: true });
+    expect(arithmetic.source).toContain('attempt*magicNumber > -3');
+  });
+});
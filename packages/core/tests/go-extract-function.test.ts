@@ -0,0 +1,162 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { extractFunction } from '../src/go/extract-function';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), 'sample.go');
+
+const loopSource = `package sums
+
+import "errors"
+
+func Sum(xs []int) (int, error) {
+	total := 0
+	prev := 0
+	for _, x := range xs {
+		if x < prev {
+			return 0, errors.New("unsorted")
+		}
+		total += x
+		prev = x
+	}
+	return total, nil
+}
+
+func Walk(xs []int) int {
+	n := 0
+	for _, x := range xs {
+		if x < 0 {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func Close(f func()) {
+	defer f()
+	f()
+}
+`;
+
+describe('Go extract function', () => {
+  it('should pass inputs as parameters and return live assignments', () => {
+    const result = extractFunction(sample(), { startLine: 69, endLine: 78 });
+
+    expect(result.name).toBe('processComplexDataExtracted');
+    expect(result.parameters).toEqual([
+      { name: 'input', type: '[]string' },
+      { name: 'results', type: '[]string' },
+    ]);
+    expect(result.results).toEqual([{ name: 'results', type: '[]string' }]);
+    expect(result.returnsError).toBe(false);
+    expect(result.source).toContain('\tresults = processComplexDataExtracted(input, results)\n');
+    expect(result.source).toContain(
+      '}\n\nfunc processComplexDataExtracted(input []string, results []string) []string {\n' +
+        '\tfor i, item := range input {\n'
+    );
+    expect(result.source).toContain('\t\t\tresults = append(results, processTypeB(item))\n');
+    expect(result.diff).toMatch(/^--- sample.go\n\+\+\+ sample.go\n@@ -66,6 \+66,12 @@/);
+  });
+
+  it('should not capture variables local to the range', () => {
+    const result = extractFunction(sample(), { startLine: 69, endLine: 78 });
+    const names = result.parameters.map(p => p.name);
+
+    expect(names).not.toContain('i');
+    expect(names).not.toContain('item');
+  });
+
+  it('should thread early error returns through an error result', () => {
+    const result = extractFunction(sample(), { startLine: 63, endLine: 67 });
+
+    expect(result.returnsError).toBe(true);
+    expect(result.results).toEqual([{ name: 'results', type: '[]string' }]);
+    expect(result.source).toContain(
+      '\tresults, err := processComplexDataExtracted(input)\n' +
+        '\tif err != nil {\n' +
+        '\t\treturn nil, err\n' +
+        '\t}\n'
+    );
+    expect(result.source).toContain(
+      'func processComplexDataExtracted(input []string) ([]string, error) {\n' +
+        '\tif len(input) == 0 {\n' +
+        '\t\treturn nil, fmt.Errorf("input cannot be empty")\n' +
+        '\t}\n' +
+        '\n' +
+        '\tresults := make([]string, 0, len(input))\n' +
+        '\treturn results, nil\n' +
+        '}'
+    );
+  });
+
+  it('should check the error inline when nothing else is returned', () => {
+    const result = extractFunction(sample(), { startLine: 63, endLine: 65 });
+
+    expect(result.source).toContain(
+      '\tif err := processComplexDataExtracted(input); err != nil {\n\t\treturn nil, err\n\t}\n'
+    );
+    expect(result.source).toContain(
+      '\t\treturn fmt.Errorf("input cannot be empty")\n\t}\n\treturn nil\n}'
+    );
+  });
+
+  it('should return the call directly when the range ends the function', () => {
+    const result = extractFunction(sample(), { startLine: 69, endLine: 80, name: 'collect' });
+
+    expect(result.returnsError).toBe(false);
+    expect(result.source).toContain('\treturn collect(input, results)\n}');
+    expect(result.source).toContain(
+      'func collect(input []string, results []string) ([]string, error) {'
+    );
+    expect(result.source).toContain('\n\treturn results, nil\n}');
+  });
+
+  it('should extract methods that use the receiver', () => {
+    const result = extractFunction(sample(), { startLine: 29, endLine: 30 });
+
+    expect(result.receiver).toBe('dp *DataProcessor');
+    expect(result.parameters.map(p => p.name)).toEqual(['results', 'item']);
+    expect(result.source).toContain('\t\t\tresults = dp.processDataExtracted(results, item)\n');
+    expect(result.source).toContain(
+      'func (dp *DataProcessor) processDataExtracted(results []string, item string) []string {'
+    );
+  });
+
+  it('should return variables read again by an enclosing loop', () => {
+    const file = parseGoFile(loopSource, 'sums.go');
+    const result = extractFunction(file, { startLine: 9, endLine: 13 });
+
+    expect(result.parameters.map(p => p.name)).toEqual(['total', 'prev', 'x']);
+    expect(result.results.map(r => r.name)).toEqual(['total', 'prev']);
+    // Outer variables are assigned rather than shadowed
+    expect(result.source).toContain(
+      '\t\tvar err error\n' +
+        '\t\ttotal, prev, err = sumExtracted(total, prev, x)\n' +
+        '\t\tif err != nil {\n' +
+        '\t\t\treturn 0, err\n' +
+        '\t\t}\n'
+    );
+    expect(result.source).toContain('\t\treturn 0, 0, errors.New("unsorted")\n');
+  });
+
+  it('should reject ranges that cannot be extracted safely', () => {
+    const file = parseGoFile(loopSource, 'sums.go');
+
+    expect(() => extractFunction(file, { startLine: 7, endLine: 9 })).toThrow(
+      'do not cover complete statements'
+    );
+    expect(() => extractFunction(file, { startLine: 21, endLine: 23 })).toThrow(
+      'break at line 22 targets a statement outside the range'
+    );
+    expect(() => extractFunction(file, { startLine: 30, endLine: 31 })).toThrow(
+      GoRefactorError
+    );
+    expect(() => extractFunction(file, { startLine: 9, endLine: 11, name: 'Walk' })).toThrow(
+      'Walk is already declared in the file'
+    );
+  });
+});
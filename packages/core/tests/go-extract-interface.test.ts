@@ -0,0 +1,100 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { extractInterface } from '../src/go/extract-interface';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), 'sample.go');
+
+const source = `package shop
+
+type Cart struct {
+	items []string
+}
+
+func (c Cart) Len() int { return len(c.items) }
+
+// Has reports whether the cart holds item
+func (c Cart) Has(item string) bool {
+	for _, i := range c.items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func Total(c Cart, extra int) int {
+	return c.Len() + extra
+}
+
+func Peek(c Cart) []string {
+	return c.items
+}
+`;
+
+describe('Go extract interface', () => {
+  it('declares the exported method set of the sample struct after it', () => {
+    const result = extractInterface(sample(), { type: 'DataProcessor' });
+
+    expect(result.interfaceName).toBe('DataProcessorer');
+    expect(result.methods).toEqual(['ProcessData', 'GetCacheSize']);
+    expect(result.implementation).toBe('*DataProcessor');
+    expect(result.source).toContain(`	cache  map[string]interface{}
+}
+
+// DataProcessorer holds the exported methods of DataProcessor
+type DataProcessorer interface {
+	// ProcessData processes a slice of strings
+	ProcessData([]string) []string
+	// GetCacheSize returns the current cache size
+	GetCacheSize() int
+}
+
+var _ DataProcessorer = (*DataProcessor)(nil)
+
+// NewDataProcessor creates`);
+    expect(result.source).not.toContain('processItem(string)');
+  });
+
+  it('uses value receivers, a chosen name, and rewrites consumers', () => {
+    const result = extractInterface(parseGoFile(source, 'cart.go'), {
+      type: 'Cart',
+      interfaceName: 'Items',
+      consumers: ['Total'],
+    });
+
+    expect(result.implementation).toBe('Cart');
+    expect(result.rewritten).toEqual(['Total.c']);
+    expect(result.source).toContain('\tLen() int\n\t// Has reports whether the cart holds item\n\tHas(string) bool\n}');
+    expect(result.source).toContain('var _ Items = Cart{}');
+    expect(result.source).toContain('func Total(c Items, extra int) int {');
+    expect(result.source).toContain('func Peek(c Cart) []string {');
+  });
+
+  it('refuses consumers that use more than the interface', () => {
+    const file = parseGoFile(source, 'cart.go');
+
+    expect(() => extractInterface(file, { type: 'Cart', consumers: ['Peek'] })).toThrow(
+      'Peek uses c.items on line 24, which the interface cannot stand in for'
+    );
+    expect(() => extractInterface(file, { type: 'Cart', consumers: ['Missing'] })).toThrow(
+      'Missing is not declared in the file'
+    );
+    expect(() => extractInterface(sample(), { type: 'DataProcessor', consumers: ['CalculateFibonacci'] })).toThrow(
+      'CalculateFibonacci has no parameter of type *DataProcessor'
+    );
+  });
+
+  it('refuses names that are taken and types without exported methods', () => {
+    expect(() => extractInterface(sample(), { type: 'DataProcessor', interfaceName: 'NewDataProcessor' })).toThrow(
+      GoRefactorError
+    );
+    expect(() =>
+      extractInterface(parseGoFile('package p\n\ntype T struct{}\n\nfunc (T) hidden() {}\n', 'p.go'), { type: 'T' })
+    ).toThrow('T has no exported methods');
+    expect(() => extractInterface(sample(), { type: 'Missing' })).toThrow('Missing is not declared in the file');
+  });
+});
@@ -0,0 +1,77 @@
+import { describe, it, expect } from '@jest/globals';
+import { GoFinding } from '../src/go/findings';
+import { goFindingFingerprint, GoFingerprinter, goFindingSymbol } from '../src/go/fingerprint';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const source = `package store
+
+import "os"
+
+type Store struct{ dir string }
+
+func (s *Store) Clear() {
+	os.Remove(s.dir)
+	os.Remove(s.dir)
+}
+
+var defaultDir = "tmp"
+`;
+
+const run = (text: string, filePath = 'store/store.go') => {
+  const file = parseGoFile(text, filePath);
+  const findings = defaultGoRuleRegistry()
+    .run([file])
+    .filter(finding => finding.rule === 'ignored-error');
+  return { file, findings };
+};
+
+describe('Go finding fingerprints', () => {
+  it('names the declaration a finding sits in', () => {
+    const file = parseGoFile(source, 'store/store.go');
+
+    expect(goFindingSymbol(file, 8)).toBe('Store.Clear');
+    expect(goFindingSymbol(file, 12)).toBe('var defaultDir');
+    expect(goFindingSymbol(file, 2)).toBe('');
+  });
+
+  it('stays the same when unrelated lines move or the line is reindented', () => {
+    const before = run(source);
+    const moved = run(
+      source
+        .replace('import "os"', 'import (\n\t"fmt"\n\t"os"\n)\n\nfunc Hello() { fmt.Println("hi") }')
+        .replace('\tos.Remove(s.dir)\n\tos.Remove', '\t\tos.Remove(s.dir)\n\tos.Remove'),
+      'store/moved.go'
+    );
+
+    expect(moved.findings[0].line).not.toBe(before.findings[0].line);
+    expect(goFindingFingerprint(moved.findings[0], moved.file)).toBe(
+      goFindingFingerprint(before.findings[0], before.file)
+    );
+    expect(goFindingFingerprint(before.findings[0], before.file)).toMatch(/^[0-9a-f]{16}$/);
+  });
+
+  it('changes with the rule, the declaration or the offending code', () => {
+    const { file, findings } = run(source);
+    const [finding] = findings;
+    const fingerprint = goFindingFingerprint(finding, file);
+
+    const renamed = run(source.replace('Clear', 'Reset'));
+    expect(goFindingFingerprint(renamed.findings[0], renamed.file)).not.toBe(fingerprint);
+    const edited = run(source.replace('os.Remove(s.dir)\n\tos', 'os.RemoveAll(s.dir)\n\tos'));
+    expect(goFindingFingerprint(edited.findings[0], edited.file)).not.toBe(fingerprint);
+    const other: GoFinding = { ...finding, rule: 'other-rule' };
+    expect(goFindingFingerprint(other, file)).not.toBe(fingerprint);
+  });
+
+  it('numbers findings that would share a fingerprint', () => {
+    const { file, findings } = run(source);
+    const fingerprinter = new GoFingerprinter([file]);
+    const [first, second] = findings.map(finding => fingerprinter.fingerprint(finding));
+
+    expect(goFindingFingerprint(findings[1], file)).toBe(goFindingFingerprint(findings[0], file));
+    expect(first).toBe(goFindingFingerprint(findings[0], file));
+    expect(second).not.toBe(first);
+    expect(new GoFingerprinter([file]).fingerprint(findings[1])).toBe(first);
+  });
+});
@@ -0,0 +1,105 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findFlagArguments } from '../src/go/flag-args';
+import { parseGoFile } from '../src/go/parser';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const read = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+const parse = (source: string, name = 'p.go') => parseGoFile(source, `/src/p/${name}`);
+
+describe('Go flag arguments', () => {
+  it('flags the bool parameters of flags.go that switch between two behaviors', () => {
+    const findings = findFlagArguments([read('flags.go')]);
+
+    expect(findings.filter(finding => finding.site === 'declaration').map(({ line, column, function: fn, parameter }) => [line, column, fn, parameter])).toEqual([
+      [14, 45, 'Exporter.Export', 'dryRun'],
+      [30, 26, 'Format', 'heading'],
+    ]);
+    expect(findings[0]).toMatchObject({ rule: 'flag-argument', severity: 'low' });
+    expect(findings[0].message).toBe(
+      'Exporter.Export does one of two things depending on its bool parameter dryRun; split it into two functions named for what each does',
+    );
+  });
+
+  it('reports the calls passing the flags a literal', () => {
+    const calls = findFlagArguments([read('flags.go')]).filter(finding => finding.site === 'call');
+
+    expect(calls.map(({ line, column, function: fn, value }) => [line, column, fn, value])).toEqual([
+      [39, 24, 'Exporter.Export', true],
+      [40, 24, 'Exporter.Export', false],
+      [41, 30, 'Format', true],
+    ]);
+    expect(calls[0].message).toBe(
+      'Literal true passed as dryRun to Exporter.Export does not say what it chooses; call a function named for that behavior instead',
+    );
+  });
+
+  it('leaves bools that are stored, returned, passed on, combined or only guard a step', () => {
+    const flagged = findFlagArguments([read('flags.go')]).map(finding => finding.function);
+
+    for (const fn of ['NewExporter', 'Enabled', 'Log', 'Exporter.Save', 'Pick']) {
+      expect(flagged).not.toContain(fn);
+    }
+  });
+
+  it('finds calls in other files of the package but not through other packages', () => {
+    const decl = parse(`package p
+
+func Render(s string, compact bool) string {
+	if compact {
+		return s
+	}
+	return "\\n" + s + "\\n"
+}
+`);
+    const use = parse(
+      `package p
+
+import "other"
+
+func Page() {
+	_ = Render("a", true)
+	_ = Render("b", (false))
+	_ = other.Render("c", true)
+	true := false
+	_ = Render("d", true)
+}
+`,
+      'use.go',
+    );
+    const calls = findFlagArguments([decl, use]).filter(finding => finding.site === 'call');
+
+    expect(calls.map(({ filePath, line, value }) => [path.basename(filePath), line, value])).toEqual([
+      ['use.go', 6, true],
+      ['use.go', 7, false],
+    ]);
+  });
+
+  it('skips methods sharing a name with another method when matching calls', () => {
+    const source = `package p
+
+type A struct{}
+type B struct{}
+
+func (A) Run(fast bool) {
+	if fast {
+		return
+	}
+	println("slow")
+}
+
+func (B) Run(fast bool) {}
+
+func main() {
+	A{}.Run(true)
+}
+`;
+    const findings = findFlagArguments([parse(source)]);
+
+    expect(findings.map(({ line, site, function: fn }) => [line, site, fn])).toEqual([[6, 'declaration', 'A.Run']]);
+  });
+});
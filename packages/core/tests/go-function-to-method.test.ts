@@ -0,0 +1,106 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { convertToMethod, findMethodCandidates } from '../src/go/function-to-method';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package cart
+
+type Cart struct {
+	items []int
+	Total int
+}
+
+type Shape interface{ Area() float64 }
+
+func Add(c *Cart, item int) {
+	c.items = append(c.items, item)
+}
+
+func Count(c *Cart) int { return len(c.items) }
+
+func Clear(c, other *Cart) {
+	c.items = other.items[:0]
+}
+
+func Total(c *Cart) int { return c.Total }
+
+func Describe(s Shape) float64 { return s.Area() }
+
+func Fill(c Cart) Cart {
+	Add(&c, 1)
+	return c
+}
+`;
+
+const callers = `package cart
+
+func Use() int {
+	var c Cart
+	Add(&c, 2)
+	Clear(&c, new(Cart))
+	return Count(&c) + Count(func() *Cart { return &c }())
+}
+`;
+
+describe('Go functions that could be methods', () => {
+  const file = parseGoFile(source, '/src/cart/cart.go');
+  const use = parseGoFile(callers, '/src/cart/use.go');
+
+  it('groups functions by the local type they take first', () => {
+    const findings = findMethodCandidates([file, use]);
+
+    expect(findings.map(finding => finding.function)).toEqual(['Add', 'Count', 'Clear', 'Fill']);
+    expect(findings[0]).toMatchObject({
+      rule: 'function-could-be-method',
+      severity: 'low',
+      type: 'Cart',
+      line: 10,
+      column: 6,
+      cluster: ['Add', 'Count', 'Clear', 'Fill'],
+      message: 'Add takes *Cart first like 3 other function(s); make it a method of Cart',
+      fix: 'func (c *Cart) Add',
+    });
+  });
+
+  it('requires a minimum cluster size', () => {
+    expect(findMethodCandidates([file, use], { minClusterSize: 5 })).toEqual([]);
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    expect(findMethodCandidates([sample])).toEqual([]);
+  });
+
+  it('moves the first parameter to the receiver and rewrites calls', () => {
+    const result = convertToMethod([file, use], { function: 'Add' });
+
+    expect(result.type).toBe('Cart');
+    expect(result.callSites.map(site => `${path.basename(site.filePath)}:${site.line}`)).toEqual([
+      'cart.go:25',
+      'use.go:5',
+    ]);
+    const [cart, used] = result.files;
+    expect(cart.source).toContain('func (c *Cart) Add(item int) {');
+    expect(cart.source).toContain('\tc.Add(1)\n');
+    expect(used.source).toContain('\tc.Add(2)\n');
+
+    const count = convertToMethod([file, use], { function: 'Count' });
+    expect(count.files[0].source).toContain('func (c *Cart) Count() int {');
+    expect(count.files[1].source).toContain('return c.Count() + func() *Cart { return &c }().Count()');
+
+    const clear = convertToMethod([file, use], { function: 'Clear' });
+    expect(clear.files[0].source).toContain('func (c *Cart) Clear(other *Cart) {');
+    expect(clear.files[1].source).toContain('\tc.Clear(new(Cart))\n');
+  });
+
+  it('refuses functions that cannot become methods', () => {
+    expect(() => convertToMethod([file], { function: 'Total' })).toThrow(
+      'Cannot make Total a method: Cart already has a field Total'
+    );
+    expect(() => convertToMethod([file], { function: 'Describe' })).toThrow(
+      'Cannot make Describe a method: Shape is an interface'
+    );
+    expect(() => convertToMethod([file], { function: 'Missing' })).toThrow(GoRefactorError);
+  });
+});
@@ -0,0 +1,122 @@
+import { describe, it, expect } from '@jest/globals';
+import { GoFinding, GoSeverity } from '../src/go/findings';
+import {
+  applyGoSeverityOverrides,
+  evaluateGoGate,
+  formatGoGateSummary,
+  GoGateError,
+  parseGoSeverity,
+  parseGoSeverityOverrides,
+} from '../src/go/gate';
+
+const finding = (rule: string, severity: GoSeverity, line: number): GoFinding => ({
+  rule,
+  severity,
+  filePath: 'main.go',
+  line,
+  column: 2,
+  message: `${rule} on line ${line}`,
+});
+
+const findings = [
+  finding('ignored-error', 'high', 4),
+  finding('shadowed-variable', 'medium', 9),
+  finding('too-many-parameters', 'low', 12),
+  finding('string-concat-in-loop', 'low', 20),
+];
+
+describe('Go CI gate', () => {
+  it('should fail on findings at or above the threshold', () => {
+    const result = evaluateGoGate(findings);
+    expect(result.passed).toBe(false);
+    expect(result.exitCode).toBe(1);
+    expect(result.failures.map(failure => failure.rule)).toEqual(['ignored-error']);
+    expect(result.warnings).toBe(3);
+    expect(result.counts).toEqual({ high: 1, medium: 1, low: 2 });
+    expect(result.reasons).toEqual(['1 finding at or above high severity']);
+
+    const medium = evaluateGoGate(findings, { failOn: 'medium' });
+    expect(medium.failures.map(failure => failure.rule)).toEqual([
+      'ignored-error',
+      'shadowed-variable',
+    ]);
+    expect(evaluateGoGate(findings.slice(1)).passed).toBe(true);
+    expect(evaluateGoGate([]).exitCode).toBe(0);
+  });
+
+  it('should apply severity overrides and switch rules off', () => {
+    const result = evaluateGoGate(findings, {
+      severities: { 'ignored-error': 'off', 'shadowed-variable': 'high' },
+    });
+    expect(result.failures).toEqual([{ ...findings[1], severity: 'high' }]);
+    expect(result.counts).toEqual({ high: 1, medium: 0, low: 2 });
+    expect(result.rules).toEqual({
+      'shadowed-variable': 1,
+      'string-concat-in-loop': 1,
+      'too-many-parameters': 1,
+    });
+
+    const relaxed = evaluateGoGate(findings, { severities: { 'ignored-error': 'low' } });
+    expect(relaxed.passed).toBe(true);
+    expect(relaxed.warnings).toBe(4);
+  });
+
+  it('should fail once warnings exceed the budget', () => {
+    const passing = findings.slice(1);
+    expect(evaluateGoGate(passing, { maxWarnings: 3 }).passed).toBe(true);
+    const over = evaluateGoGate(passing, { maxWarnings: 2 });
+    expect(over.passed).toBe(false);
+    expect(over.failures).toEqual([]);
+    expect(over.reasons).toEqual(['3 warnings exceed the budget of 2']);
+
+    expect(JSON.parse(formatGoGateSummary(over))).toEqual({
+      passed: false,
+      failOn: 'high',
+      maxWarnings: 2,
+      failures: 0,
+      warnings: 3,
+      counts: { high: 0, medium: 1, low: 2 },
+      rules: { 'shadowed-variable': 1, 'string-concat-in-loop': 1, 'too-many-parameters': 1 },
+      reasons: ['3 warnings exceed the budget of 2'],
+    });
+    expect(() => evaluateGoGate(passing, { maxWarnings: -1 })).toThrow(GoGateError);
+    expect(() => evaluateGoGate(passing, { maxWarnings: NaN })).toThrow(
+      'maxWarnings must be a non-negative integer, not NaN'
+    );
+  });
+
+  it('should parse severities and their CI aliases', () => {
+    expect(parseGoSeverity('error')).toBe('high');
+    expect(parseGoSeverity('Warning')).toBe('medium');
+    expect(parseGoSeverity('info')).toBe('low');
+    expect(parseGoSeverity('medium')).toBe('medium');
+    expect(parseGoSeverity('off')).toBe('off');
+    expect(() => parseGoSeverity('fatal')).toThrow(
+      'Unknown severity "fatal"; expected high, medium, low or off'
+    );
+
+    expect(parseGoSeverityOverrides(['ignored-error=error', 'too-many-parameters = off'])).toEqual({
+      'ignored-error': 'high',
+      'too-many-parameters': 'off',
+    });
+    expect(() => parseGoSeverityOverrides(['ignored-error'])).toThrow(
+      'Severity override "ignored-error" must look like rule=severity'
+    );
+  });
+
+  it('should rewrite severities for reports and drop rules switched off', () => {
+    const overridden = applyGoSeverityOverrides(
+      findings,
+      parseGoSeverityOverrides(['shadowed-variable=error', 'too-many-parameters=off'])
+    );
+
+    expect(overridden.map(({ rule, severity }) => [rule, severity])).toEqual([
+      ['ignored-error', 'high'],
+      ['shadowed-variable', 'high'],
+      ['string-concat-in-loop', 'low'],
+    ]);
+    expect(findings[1].severity).toBe('medium');
+    expect(evaluateGoGate(overridden, { failOn: 'high' }).failures).toHaveLength(2);
+    expect(applyGoSeverityOverrides(findings, {})).toEqual(findings);
+  });
+});
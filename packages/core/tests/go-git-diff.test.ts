@@ -0,0 +1,120 @@
+import { describe, it, expect } from '@jest/globals';
+import { execFileSync } from 'child_process';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { GoFinding } from '../src/go/findings';
+import { goDiffScope, GoGitDiffError, parseGitDiff, readGitDiff } from '../src/go/git-diff';
+import { parseGoFile } from '../src/go/parser';
+
+const diff = `diff --git a/store/store.go b/store/store.go
+index 1111111..2222222 100644
+--- a/store/store.go
++++ b/store/store.go
+@@ -8 +8,2 @@ func Get(key string) int {
+-	return 0
++	v := lookup(key)
++	return v
+@@ -20,3 +21,0 @@ func Put() {
+diff --git a/old.go b/cache/new.go
+similarity index 90%
+rename from old.go
+rename to cache/new.go
+index 3333333..4444444 100644
+--- a/old.go
++++ b/cache/new.go
+@@ -3,0 +4 @@ package cache
++// Added
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+index 5555555..0000000
+--- a/gone.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package gone
+diff --git a/fresh.go b/fresh.go
+new file mode 100644
+index 0000000..6666666
+--- /dev/null
++++ b/fresh.go
+@@ -0,0 +1,3 @@
++package fresh
+`;
+
+const source = `package store
+
+func lookup(key string) int { return len(key) }
+
+// Get returns the value of a key
+func Get(key string) int {
+	_ = key
+	v := lookup(key)
+	return v
+}
+
+func Other() {
+	_ = 1
+}
+`;
+
+function finding(filePath: string, line: number): GoFinding {
+  return { rule: 'test', severity: 'low', filePath, line, column: 1, message: `line ${line}` };
+}
+
+describe('Go diff scope', () => {
+  it('parses changed ranges, renames and new files and drops deletions', () => {
+    expect(parseGitDiff(diff)).toEqual([
+      {
+        filePath: 'store/store.go',
+        status: 'modified',
+        ranges: [
+          { start: 8, end: 9 },
+          { start: 21, end: 22 },
+        ],
+      },
+      { filePath: 'cache/new.go', previousPath: 'old.go', status: 'renamed', ranges: [{ start: 4, end: 4 }] },
+      { filePath: 'fresh.go', status: 'added', ranges: [{ start: 1, end: 3 }] },
+    ]);
+  });
+
+  it('keeps findings anywhere in a touched declaration and in new files', () => {
+    const file = parseGoFile(source, '/repo/store/store.go');
+    const fresh = parseGoFile('package fresh\n', '/repo/fresh.go');
+    const untouched = parseGoFile('package other\n', '/repo/other/other.go');
+    const scope = goDiffScope([file, fresh, untouched], parseGitDiff(diff), { root: '/repo' });
+
+    const findings = [5, 7, 9, 3, 13].map(line => finding(file.filePath, line));
+    expect(scope.filter(findings).map(kept => kept.line)).toEqual([5, 7, 9]);
+    expect(scope.includes(finding(fresh.filePath, 1))).toBe(true);
+    expect(scope.includes(finding(untouched.filePath, 1))).toBe(false);
+    expect(scope.packageFiles([file, fresh, untouched])).toEqual([file, fresh]);
+  });
+
+  it('reads the diff between two revisions with git', async () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'git-diff-'));
+    const git = (...args: string[]) =>
+      execFileSync('git', ['-c', 'user.name=test', '-c', 'user.email=test@example.com', ...args], {
+        cwd: dir,
+      });
+
+    try {
+      git('init', '-q');
+      fs.mkdirSync(path.join(dir, 'pkg'));
+      fs.writeFileSync(path.join(dir, 'pkg', 'a.go'), 'package pkg\n\nfunc A() {}\n');
+      git('add', '-A');
+      git('commit', '-qm', 'base');
+      fs.writeFileSync(path.join(dir, 'pkg', 'a.go'), 'package pkg\n\nfunc A() {\n\t_ = 1\n}\n');
+      fs.writeFileSync(path.join(dir, 'pkg', 'b.go'), 'package pkg\n');
+      git('add', '-A');
+      git('commit', '-qm', 'head');
+
+      expect(await readGitDiff(path.join(dir, 'pkg'), 'HEAD~1', 'HEAD')).toEqual([
+        { filePath: 'a.go', status: 'modified', ranges: [{ start: 3, end: 5 }] },
+        { filePath: 'b.go', status: 'added', ranges: [{ start: 1, end: 1 }] },
+      ]);
+      await expect(readGitDiff(dir, 'missing-ref')).rejects.toThrow(GoGitDiffError);
+    } finally {
+      fs.rmSync(dir, { recursive: true, force: true });
+    }
+  });
+});
@@ -0,0 +1,146 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findHiddenGlobalState } from '../src/go/globals';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const read = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+const parse = (source: string, name = 'p.go') => parseGoFile(source, `/src/p/${name}`);
+
+const state = `package p
+
+import (
+	"errors"
+	"regexp"
+)
+
+const limit = 10
+
+var (
+	ErrFull  = errors.New("full")
+	pattern  = regexp.MustCompile("^[a-z]+$")
+	counter  int
+	settings = map[string]string{}
+	verbose  bool
+)
+
+type Config struct{ counter int }
+
+func init() {
+	verbose = true
+}
+
+func next() (int, error) {
+	if counter >= limit {
+		return 0, ErrFull
+	}
+	counter++
+	return counter, nil
+}
+
+func valid() bool {
+	return pattern.MatchString("abc")
+}
+
+func reset() {
+	counter = 0
+	settings["mode"] = "default"
+}
+
+func describe() Config {
+	return Config{counter: len(settings)}
+}
+
+func set(key, value string) {
+	settings[key] = value
+}
+`;
+
+describe('Go hidden global state', () => {
+  it('leaves the fixtures alone, whose parameter-less functions use no mutable globals', () => {
+    expect(findHiddenGlobalState([read('sample.go'), read('constants.go')])).toEqual([]);
+  });
+
+  it('reports parameter-less functions using mutable globals, with how each is used', () => {
+    const findings = findHiddenGlobalState([parse(state)]);
+
+    expect(findings.map((finding) => [finding.function, finding.line, finding.globals])).toEqual([
+      ['next', 24, [{ name: 'counter', read: true, write: true }]],
+      [
+        'reset',
+        36,
+        [
+          { name: 'counter', read: false, write: true },
+          { name: 'settings', read: true, write: true },
+        ],
+      ],
+      ['describe', 41, [{ name: 'settings', read: true, write: false }]],
+    ]);
+    expect(findings[1]).toMatchObject({
+      rule: 'hidden-global-state',
+      severity: 'low',
+      message:
+        'reset takes no parameters but depends on the package-level state counter (written) and settings (read and written); pass them in as parameters, or fields of a receiver, so tests can supply their own',
+    });
+  });
+
+  it('does not count globals hidden by locals, fields or struct literal keys', () => {
+    const source = `package p
+
+var count int
+
+type T struct{ count int }
+
+func bump(n int) { count += n }
+
+func (t *T) Count() int {
+	return t.count
+}
+
+func local() int {
+	count := 3
+	return count
+}
+
+func literal() T {
+	return T{count: 1}
+}
+`;
+    expect(findHiddenGlobalState([parse(source)])).toEqual([]);
+  });
+
+  it('sees writes in other files and closures, and skips init, main and tests', () => {
+    const main = parse(
+      `package main
+
+var level int
+
+func main() {
+	level = 2
+	run()
+}
+
+func run() {
+	go func() { println(level) }()
+}
+`,
+      'main.go',
+    );
+    const test = parse('package main\n\nfunc TestRun() { level = 1 }\n', 'main_test.go');
+    const findings = findHiddenGlobalState([main, test]);
+
+    expect(findings.map((finding) => [finding.function, finding.globals])).toEqual([
+      ['run', [{ name: 'level', read: true, write: false }]],
+    ]);
+  });
+
+  it('takes the number of globals a function must use', () => {
+    const files = [parse(state)];
+
+    expect(findHiddenGlobalState(files, { minGlobals: 2 }).map((finding) => finding.function)).toEqual(['reset']);
+  });
+});
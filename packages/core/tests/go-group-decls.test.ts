@@ -0,0 +1,131 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findUngroupedDeclarations, normalizeDeclGroups } from '../src/go/group-decls';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const read = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+
+const vars = `package p
+
+import "errors"
+
+// ErrA is bad
+var ErrA = errors.New("a") // the first
+
+var errLong = errors.New("bbbbbbbb")
+var n int
+var m = map[string]int{
+	"a": 1,
+}
+var x, y int = 1, 2 // pair
+`;
+
+describe('Go declaration grouping', () => {
+  it('reports adjacent declarations but leaves iota blocks and neighbouring blocks alone', () => {
+    const findings = findUngroupedDeclarations([read('constants.go'), read('sample.go')]);
+
+    expect(findings).toHaveLength(1);
+    expect(findings[0]).toMatchObject({
+      rule: 'ungrouped-declarations',
+      severity: 'low',
+      line: 21,
+      tok: 'const',
+      count: 2,
+      message: '2 adjacent const declarations could share one const (...) block',
+    });
+  });
+
+  it('merges a lone constant into the block after it, keeping its doc comment', () => {
+    const result = normalizeDeclGroups(read('constants.go'));
+
+    expect(result.merged).toEqual([{ tok: 'const', line: 20, count: 2 }]);
+    expect(result.source).toContain(
+      [
+        '\n\nconst (',
+        '\t// Timeout is the default timeout in seconds',
+        '\tTimeout = 30',
+        '',
+        '\tPi               = 3.14159',
+        '\tTwoPi            = 2 * Pi',
+        '\tGreeting         = "hello, " + "world"',
+        '\tInitial          = \'G\'',
+        '\tDebug            = false',
+        '\tMask             = 0755',
+        '\tWidth            = len(Greeting)',
+        '\tRatio    float32 = 1 / 4.0',
+        ')\n',
+      ].join('\n')
+    );
+    expect(result.source).toContain('const (\n\t_  = iota\n');
+    expect(result.source).toContain('\nconst unknown = time.Second\n');
+  });
+
+  it('aligns names, types, values and comments the way gofmt does', () => {
+    const result = normalizeDeclGroups(parseGoFile(vars, 'p.go'));
+
+    expect(result.merged).toEqual([{ tok: 'var', line: 5, count: 5 }]);
+    expect(result.source).toBe(`package p
+
+import "errors"
+
+var (
+	// ErrA is bad
+	ErrA = errors.New("a") // the first
+
+	errLong = errors.New("bbbbbbbb")
+	n       int
+	m       = map[string]int{
+		"a": 1,
+	}
+	x, y int = 1, 2 // pair
+)
+`);
+  });
+
+  it('leaves declarations apart under leave-as-is and can unwrap single-entry blocks', () => {
+    const file = parseGoFile(
+      [
+        'package p',
+        '',
+        'var (',
+        '\t// limit caps the batch',
+        '\tlimit = 3 // per request',
+        ')',
+        '',
+        'const (',
+        '\tname = "p"',
+        '\t// version of the format',
+        ')',
+        '',
+        'const a = 1',
+        'const b = 2',
+        '',
+      ].join('\n'),
+      'p.go'
+    );
+
+    const result = normalizeDeclGroups(file, { policy: 'leave-as-is', ungroupSingle: true });
+
+    expect(result.merged).toEqual([]);
+    expect(result.ungrouped).toEqual([{ tok: 'var', line: 3 }]);
+    expect(result.source).toContain('\n// limit caps the batch\nvar limit = 3 // per request\n\nconst (\n\tname = "p"\n');
+    expect(result.source).toContain('const a = 1\nconst b = 2\n');
+    expect(() => normalizeDeclGroups(file, { policy: 'sometimes' as 'leave-as-is' })).toThrow(
+      'Unknown grouping policy "sometimes"; expected always-group or leave-as-is'
+    );
+  });
+
+  it('keeps declarations holding multi-line raw strings out of blocks', () => {
+    const file = parseGoFile('package p\n\nvar usage = `line one\nline two`\nvar verbose bool\nvar quiet bool\n', 'p.go');
+
+    const result = normalizeDeclGroups(file);
+
+    expect(result.merged).toEqual([{ tok: 'var', line: 5, count: 2 }]);
+    expect(result.source).toBe('package p\n\nvar usage = `line one\nline two`\nvar (\n\tverbose bool\n\tquiet   bool\n)\n');
+  });
+});
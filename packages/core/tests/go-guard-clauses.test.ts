@@ -0,0 +1,152 @@
+import { describe, it, expect } from '@jest/globals';
+import { findNestedConditionals, flattenNestedConditionals } from '../src/go/guard-clauses';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+
+const source = `package users
+
+func Notify(u *User, m *Mailer) {
+	if u != nil {
+		if m != nil && m.Ready() {
+			m.Send(u.Email)
+		}
+	}
+}
+
+func Lookup(db *DB, id string) (*User, error) {
+	if db != nil {
+		if id != "" {
+			user, err := db.Load(id)
+			if err == nil {
+				return user, nil
+			}
+			return nil, err
+		}
+		return nil, errEmpty
+	}
+	return nil, errNoDB
+}
+
+func Each(items []Item) {
+	for _, item := range items {
+		if item.Valid {
+			if item.Count > 0 {
+				process(item)
+			} else {
+				log("empty")
+			}
+		}
+	}
+}
+
+func Check(x *T) error {
+	if x != nil {
+		x.Run()
+	} else {
+		return errNil
+	}
+	return x.Done()
+}
+`;
+
+describe('Go nested conditionals', () => {
+  const file = parseGoFile(source, 'users.go');
+
+  it('flags chains of two levels or more', () => {
+    const findings = findNestedConditionals([file]);
+
+    expect(findings.map((finding) => [finding.line, finding.depth, finding.fix])).toEqual([
+      [4, 2, 'if u == nil { return }'],
+      [12, 3, 'if db == nil { return nil, errNoDB }'],
+      [27, 2, 'if !item.Valid { continue }'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'nested-conditional',
+      severity: 'low',
+      message: '2 nested if statements can be flattened into guard clauses that exit early',
+    });
+  });
+
+  it('inverts the conditions into guards that exit early', () => {
+    const result = flattenNestedConditionals(file);
+
+    expect(result.flattened).toEqual([
+      { line: 4, column: 2, depth: 2 },
+      { line: 12, column: 2, depth: 3 },
+      { line: 27, column: 3, depth: 2 },
+    ]);
+    expect(result.source).toContain(
+      '\tif u == nil {\n\t\treturn\n\t}\n\tif m == nil || !m.Ready() {\n\t\treturn\n\t}\n\tm.Send(u.Email)\n}'
+    );
+    expect(result.source).toContain(
+      [
+        '\tif db == nil {',
+        '\t\treturn nil, errNoDB',
+        '\t}',
+        '\tif id == "" {',
+        '\t\treturn nil, errEmpty',
+        '\t}',
+        '\tuser, err := db.Load(id)',
+        '\tif err != nil {',
+        '\t\treturn nil, err',
+        '\t}',
+        '\treturn user, nil',
+        '}',
+      ].join('\n')
+    );
+    // The else branch runs before the iteration ends, and an ordered
+    // comparison is negated as a whole
+    expect(result.source).toContain(
+      '\t\tif !item.Valid {\n\t\t\tcontinue\n\t\t}\n\t\tif !(item.Count > 0) {\n\t\t\tlog("empty")\n\t\t\tcontinue\n\t\t}\n\t\tprocess(item)\n\t}'
+    );
+    expect(findNestedConditionals([parseGoFile(result.source, 'users.go')])).toEqual([]);
+  });
+
+  it('moves a comment after the opening brace to a line of its own', () => {
+    const file = parseGoFile('package p\n\nfunc f(a *A) {\n\tif a != nil { // ready\n\t\ta.Run()\n\t}\n}\n', 'p.go');
+
+    expect(flattenNestedConditionals(file, { line: 4 }).source).toBe(
+      'package p\n\nfunc f(a *A) {\n\tif a == nil {\n\t\treturn\n\t}\n\t// ready\n\ta.Run()\n}\n'
+    );
+  });
+
+  it('flattens a single level at a line when the else branch returns', () => {
+    const result = flattenNestedConditionals(file, { line: 38 });
+
+    expect(result.flattened).toEqual([{ line: 38, column: 2, depth: 1 }]);
+    expect(result.source).toContain(
+      '\tif x == nil {\n\t\treturn errNil\n\t}\n\tx.Run()\n\treturn x.Done()\n}'
+    );
+  });
+
+  it('refuses levels whose branch would change scope or behavior', () => {
+    const refuse = (body: string, line = 4) =>
+      () => flattenNestedConditionals(parseGoFile(`package p\n\nfunc f(a *A) {\n${body}}\n`, 'p.go'), { line });
+
+    expect(refuse('\tif b := a.B(); b != nil {\n\t\tb.Run()\n\t}\n')).toThrow(
+      'Cannot flatten the if statement on line 4: its init statement declares variables for both branches'
+    );
+    expect(refuse('\tn := 1\n\tif a != nil {\n\t\tn := 2\n\t\tuse(n)\n\t}\n', 5)).toThrow(
+      'it declares n, which the enclosing block already declares or uses'
+    );
+    expect(refuse('\tif a != nil {\n\t\ta.Run()\n\t}\n\ta.Stop()\n')).toThrow(
+      'the code after it would run when its condition fails'
+    );
+    expect(refuse('\tif a != nil {\n\t\tgoto done\n\t}\ndone:\n')).toThrow('the function uses goto');
+    expect(refuse('\tif a != nil {\n\t\ta.Run()\n\t} /* done */ else {\n\t\tpanic(a)\n\t}\n')).toThrow(
+      GoRefactorError
+    );
+    expect(refuse('\ta.Run()\n')).toThrow('No if statement starts on line 4');
+  });
+
+  it('keeps side effects of the conditions in their order', () => {
+    const file = parseGoFile(
+      'package p\n\nfunc f() {\n\tif open() && lock() {\n\t\tif !busy() {\n\t\t\twork()\n\t\t}\n\t}\n}\n',
+      'p.go'
+    );
+
+    expect(flattenNestedConditionals(file).source).toBe(
+      'package p\n\nfunc f() {\n\tif !open() || !lock() {\n\t\treturn\n\t}\n\tif busy() {\n\t\treturn\n\t}\n\twork()\n}\n'
+    );
+  });
+});
@@ -0,0 +1,148 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { parseCoverProfile } from '../src/go/coverage';
+import {
+  compareGoHealthSnapshots,
+  formatGoRegressionReport,
+  goHealthSnapshot,
+  GoHealthSnapshotError,
+  parseGoHealthSnapshot,
+  readGoHealthSnapshot,
+  writeGoHealthSnapshot,
+} from '../src/go/health';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const root = path.join(os.tmpdir(), 'refactogent-health');
+
+const release = `package store
+
+import "os"
+
+// Save writes data to path
+func Save(path string, data []byte) error {
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Load reads path
+func Load(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func cleanup(path string) {
+	os.Remove(path)
+}
+`;
+
+const snapshot = (text: string, label: string, profile?: string) => {
+  const files = [parseGoFile(text, path.join(root, 'store', 'store.go'))];
+  const findings = defaultGoRuleRegistry().run(files);
+  return goHealthSnapshot(files, findings, {
+    root,
+    label,
+    ...(profile && { coverage: parseCoverProfile(profile) }),
+  });
+};
+
+describe('Go health snapshots', () => {
+  it('records functions, findings, coverage and API with relative paths', () => {
+    const taken = snapshot(
+      release,
+      'v1.0.0',
+      'mode: set\nexample.com/store/store.go:6.43,8.2 1 1\nexample.com/store/store.go:11.40,13.2 1 0\n',
+    );
+    expect(taken.label).toBe('v1.0.0');
+    expect(taken.coveragePct).toBe(50);
+    expect(taken.functions.map((fn) => [fn.package, fn.id, fn.filePath, fn.line])).toEqual([
+      ['store', 'store.Load', 'store/store.go', 11],
+      ['store', 'store.Save', 'store/store.go', 6],
+      ['store', 'store.cleanup', 'store/store.go', 15],
+    ]);
+    expect(taken.functions.map((fn) => fn.coveragePct)).toEqual([0, 100, undefined]);
+    expect(taken.findings.some((f) => f.symbol === 'cleanup' && f.filePath === 'store/store.go')).toBe(true);
+    expect(taken.api).toEqual(
+      expect.arrayContaining([
+        expect.stringContaining('func Load(string) ([]byte, error)'),
+        expect.stringContaining('func Save(string, []byte) error'),
+      ]),
+    );
+  });
+
+  it('reports new findings, more complex functions and API removals as regressions', () => {
+    const before = snapshot(release, 'v1.0.0');
+    const after = snapshot(
+      release
+        .replace(
+          '\treturn os.WriteFile(path, data, 0o644)',
+          '\tif len(data) == 0 {\n\t\treturn nil\n\t}\n\treturn os.WriteFile(path, data, 0o644)',
+        )
+        .replace(/\/\/ Load reads path\nfunc Load[^]*?\n}\n\n/, '')
+        .concat('\nfunc purge(path string) {\n\tos.RemoveAll(path)\n}\n'),
+      'v1.1.0',
+    );
+    const comparison = compareGoHealthSnapshots(before, after);
+    expect(comparison.regressed).toBe(true);
+    expect(comparison.worse.map(({ before: was, after: now }) => [now.id, was.complexity, now.complexity])).toEqual([
+      ['store.Save', 1, 3],
+    ]);
+    expect(comparison.removed.map((fn) => fn.id)).toEqual(['store.Load']);
+    expect(comparison.added.map((fn) => fn.id)).toEqual(['store.purge']);
+    expect(comparison.newFindings.map((f) => f.symbol)).toContain('purge');
+    expect(comparison.resolvedFindings).toEqual([]);
+    expect(comparison.api.removed).toEqual([expect.stringContaining('func Load(string)')]);
+  });
+
+  it('matches renamed functions and their findings by fingerprint', () => {
+    const before = snapshot(release, 'v1.0.0');
+    const after = snapshot(release.replace('func cleanup(', 'func removeFile('), 'v1.1.0');
+    const comparison = compareGoHealthSnapshots(before, after);
+    expect(comparison.renamed.map(({ before: was, after: now }) => [was.id, now.id])).toEqual([
+      ['store.cleanup', 'store.removeFile'],
+    ]);
+    expect(comparison.added).toEqual([]);
+    expect(comparison.removed).toEqual([]);
+    expect(comparison.newFindings).toEqual([]);
+    expect(comparison.resolvedFindings).toEqual([]);
+    expect(comparison.regressed).toBe(false);
+  });
+
+  it('renders a regression report with a checklist of regressions', () => {
+    const before = snapshot(
+      release,
+      'v1.0.0',
+      'mode: set\nexample.com/store/store.go:6.43,8.2 1 1\nexample.com/store/store.go:11.40,13.2 1 1\n',
+    );
+    const after = snapshot(
+      release,
+      'v1.1.0',
+      'mode: set\nexample.com/store/store.go:6.43,8.2 1 1\nexample.com/store/store.go:11.40,13.2 1 0\n',
+    );
+    const report = formatGoRegressionReport(compareGoHealthSnapshots(before, after));
+    expect(report).toContain('# Regression report: v1.0.0 → v1.1.0');
+    expect(report).toContain('Regressed: review the items below before releasing.');
+    expect(report).toContain('| Coverage | 100% | 50% | -50 |');
+    expect(report).toContain('- [ ] Worse: `store.Load` (store/store.go): complexity 1 → 1, cognitive 0 → 0, coverage 100% → 0%');
+    expect(report).toContain('- [ ] Coverage fell from 100% to 50%');
+    expect(report).not.toContain('## Improvements');
+
+    const clean = formatGoRegressionReport(compareGoHealthSnapshots(after, after));
+    expect(clean).toContain('No regressions.');
+    expect(clean).not.toContain('## Regressions');
+  });
+
+  it('round-trips snapshot files and rejects malformed ones', async () => {
+    const filePath = path.join(root, 'snapshots', 'v1.json');
+    const taken = snapshot(release, 'v1.0.0');
+    await writeGoHealthSnapshot(filePath, taken);
+    expect(await readGoHealthSnapshot(filePath)).toEqual(taken);
+    fs.rmSync(root, { recursive: true, force: true });
+
+    expect(() => parseGoHealthSnapshot('{')).toThrow(GoHealthSnapshotError);
+    expect(() => parseGoHealthSnapshot('{"version":99}')).toThrow('Unsupported snapshot version 99; expected 1');
+    expect(() => parseGoHealthSnapshot('{"version":1,"functions":[]}')).toThrow(
+      'Snapshot must list functions, findings and api',
+    );
+  });
+});
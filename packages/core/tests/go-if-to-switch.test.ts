@@ -0,0 +1,115 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { convertIfElseToSwitch } from '../src/go/if-to-switch';
+import { GoRefactorError } from '../src/go/refactor';
+
+const source = `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func route(path string, verbose bool) string {
+	if v := len(path); strings.HasPrefix(path, "/api") {
+		return fmt.Sprint("api", v)
+	} else if strings.HasPrefix(path, "/static") {
+		return "static"
+	} else { return "page" }
+}
+
+func level(code int) string {
+	if code == 1 || code == 2 {
+		return "low"
+	} else if 3 == code {
+		// borderline
+		return "mid"
+	} else if code == 4 {
+	}
+	return "high"
+}
+
+func limits(n int) {
+	if n < 0 {
+		return
+	} else if m := n * 2; m > 10 {
+		fmt.Println(m)
+	}
+	if n == 1 {
+		return
+	} else if n == 1 {
+		fmt.Println(n)
+	}
+	for i := 0; i < n; i++ {
+		if i == 3 {
+			break
+		} else if i == 5 {
+			continue
+		}
+	}
+}
+`;
+
+const file = () => parseGoFile(source, 'main.go');
+
+describe('Go if-else chain to switch', () => {
+  it('should convert boolean conditions to a tagless switch keeping init and else', () => {
+    const result = convertIfElseToSwitch(file(), { line: 9 });
+
+    expect(result.tag).toBeUndefined();
+    expect(result.cases).toBe(2);
+    expect(result.hasDefault).toBe(true);
+    expect(result.source).toContain(
+      [
+        '\tswitch v := len(path); {',
+        '\tcase strings.HasPrefix(path, "/api"):',
+        '\t\treturn fmt.Sprint("api", v)',
+        '\tcase strings.HasPrefix(path, "/static"):',
+        '\t\treturn "static"',
+        '\tdefault:',
+        '\t\treturn "page"',
+        '\t}',
+        '}',
+      ].join('\n')
+    );
+  });
+
+  it('should convert equality comparisons of one subject to a tagged switch', () => {
+    const result = convertIfElseToSwitch(file(), { line: 17 });
+
+    expect(result.tag).toBe('code');
+    expect(result.hasDefault).toBe(false);
+    expect(result.source).toContain(
+      [
+        '\tswitch code {',
+        '\tcase 1, 2:',
+        '\t\treturn "low"',
+        '\tcase 3:',
+        '\t\t// borderline',
+        '\t\treturn "mid"',
+        '\tcase 4:',
+        '\t}',
+        '\treturn "high"',
+      ].join('\n')
+    );
+  });
+
+  it('should refuse chains whose conditions depend on each other', () => {
+    expect(() => convertIfElseToSwitch(file(), { line: 28 })).toThrow(
+      'The else if on line 30 declares variables for the conditions after it'
+    );
+    expect(() => convertIfElseToSwitch(file(), { line: 33 })).toThrow(
+      'The condition on line 35 repeats the one on line 33'
+    );
+  });
+
+  it('should refuse breaks that would leave the switch and lines without a chain', () => {
+    expect(() => convertIfElseToSwitch(file(), { line: 39 })).toThrow(
+      'The break on line 40 would leave the switch'
+    );
+    expect(() => convertIfElseToSwitch(file(), { line: 11 })).toThrow(GoRefactorError);
+    expect(() => convertIfElseToSwitch(file(), { line: 2 })).toThrow(
+      'No if statement starts on line 2'
+    );
+  });
+});
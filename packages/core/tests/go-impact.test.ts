@@ -0,0 +1,65 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { goPlannedChanges } from '../src/go/impact';
+import { inlineFunction } from '../src/go/inline-function';
+import { moveFunctions } from '../src/go/move-function';
+import { renameGoSymbol } from '../src/go/rename';
+import { createPlan } from '../src/plan';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const samplePath = path.join(fixtures, 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+describe('Go refactor impact', () => {
+  it('weighs renaming an exported type above inlining a private method', () => {
+    const file = sample();
+    const rename = renameGoSymbol([file], { name: 'DataProcessor', newName: 'Processor' });
+    const renamePlan = createPlan(fixtures, goPlannedChanges([file], rename.files));
+
+    expect(renamePlan.summary.impact).toEqual({
+      files: 1,
+      references: 7,
+      exportedChanges: [
+        'DataProcessor',
+        'DataProcessor.GetCacheSize',
+        'DataProcessor.ProcessData',
+        'NewDataProcessor',
+      ],
+      breaking: true,
+    });
+    expect(renamePlan.files[0].impact.breaking).toBe(true);
+
+    const inline = inlineFunction(file, { name: 'DataProcessor.processItem', deleteDefinition: true });
+    const inlinePlan = createPlan(fixtures, goPlannedChanges([file], [inline], { symbols: ['processItem'] }));
+
+    expect(inlinePlan.summary.impact.references).toBeLessThan(renamePlan.summary.impact.references);
+    expect(inlinePlan.summary.impact).toMatchObject({ files: 1, exportedChanges: [], breaking: false });
+    expect(inlinePlan.summary.symbols).toEqual(['processItem']);
+  });
+
+  it('does not count moves within a package as API changes', () => {
+    const file = sample();
+    const moved = moveFunctions(file, path.join(fixtures, 'process.go'), ['CalculateFibonacci']);
+    const changes = goPlannedChanges([file], [moved.source, moved.destination]);
+
+    expect(changes.map(change => [path.basename(change.filePath), change.original === null])).toEqual([
+      ['sample.go', false],
+      ['process.go', true],
+    ]);
+    expect(createPlan(fixtures, changes).summary.impact).toMatchObject({ files: 2, breaking: false });
+  });
+
+  it('reports changed signatures on the file that declared them', () => {
+    const source = 'package api\n\ntype Client struct{ Timeout int }\n\nfunc (c *Client) Get(url string) error { return nil }\n';
+    const file = parseGoFile(source, '/api/client.go');
+    const edited = source.replace('Get(url string)', 'Get(url string, retries int)').replace('Timeout int', 'timeout int');
+    const changes = goPlannedChanges([file], [
+      { filePath: file.filePath, edits: [{ start: 0, end: 0, newText: '' }], source: edited, diff: '' },
+    ]);
+
+    expect(changes[0].exportedChanges).toEqual(['Client.Get', 'Client.Timeout']);
+    expect(changes[0].references).toBe(1);
+  });
+});
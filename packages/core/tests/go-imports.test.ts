@@ -0,0 +1,99 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { applyEdits } from '../src/diff';
+import { defaultImportName, fileImports, findUnusedImports, importEdits } from '../src/go/imports';
+import { extractGoFileSymbols } from '../src/go/symbols';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+const source = `package main
+
+import (
+	"fmt"
+	str "strings"
+	. "math"
+	_ "embed"
+	"os"
+	"gopkg.in/yaml.v3"
+	"example.com/mod/v2"
+)
+
+var version = mod.Version
+
+func Print(s string) {
+	fmt.Println(str.ToUpper(s), Sqrt(2))
+}
+
+func Shadow() {
+	os := "not the package"
+	_ = os
+	_, _ = yaml.Marshal(1)
+}
+`;
+
+describe('Go import dependencies', () => {
+  it('should record the imports each function of the fixture uses', () => {
+    const symbols = extractGoFileSymbols(sample());
+    const byName = new Map(
+      [...symbols.functions, ...symbols.methods].map(fn => [fn.qualifiedName, fn.imports])
+    );
+
+    expect(symbols.imports.map(spec => spec.path)).toEqual(['fmt', 'strings', 'time']);
+    expect(byName.get('ProcessComplexData')).toEqual(['fmt', 'strings']);
+    expect(byName.get('DataProcessor.processItem')).toEqual(['strings']);
+    expect(byName.get('CalculateFibonacci')).toEqual([]);
+    // The fixture imports time without using it
+    expect(findUnusedImports([sample()]).map(finding => [finding.path, finding.line])).toEqual([
+      ['time', 6],
+    ]);
+  });
+
+  it('should tell named, aliased, dot and blank imports apart', () => {
+    const imports = fileImports(parseGoFile(source, 'main.go'));
+
+    expect(imports.map(spec => [spec.path, spec.name, spec.kind])).toEqual([
+      ['fmt', 'fmt', 'package'],
+      ['strings', 'str', 'alias'],
+      ['math', undefined, 'dot'],
+      ['embed', undefined, 'blank'],
+      ['os', 'os', 'package'],
+      ['gopkg.in/yaml.v3', 'yaml', 'package'],
+      ['example.com/mod/v2', 'mod', 'package'],
+    ]);
+    expect(imports[1]).toMatchObject({ line: 5, column: 2 });
+    expect(defaultImportName('github.com/user/repo/pkg')).toBe('pkg');
+  });
+
+  it('should attribute aliased imports and ignore shadowing locals', () => {
+    const symbols = extractGoFileSymbols(parseGoFile(source, 'main.go'));
+
+    expect(symbols.functions.map(fn => [fn.name, fn.imports])).toEqual([
+      ['Print', ['fmt', 'strings']],
+      ['Shadow', ['gopkg.in/yaml.v3']],
+    ]);
+  });
+
+  it('should report unused named imports only', () => {
+    const findings = findUnusedImports([parseGoFile(source, 'main.go')]);
+
+    expect(findings.map(finding => [finding.path, finding.line, finding.message])).toEqual([
+      ['os', 8, 'os is imported but not used'],
+    ]);
+  });
+
+  it('should add imports in byte order, like gofmt', () => {
+    const lone = 'package main\n\nimport "strings"\n\nfunc main() {}\n';
+    const grouped = 'package main\n\nimport (\n\t"Zeta/x"\n\t"strings"\n)\n\nfunc main() {}\n';
+    const entries = [{ path: 'bytes' }, { path: 'Zeta/x' }, { path: 'C' }];
+
+    expect(applyEdits(lone, importEdits(parseGoFile(lone, 'main.go'), entries))).toContain(
+      'import (\n\t"C"\n\t"Zeta/x"\n\t"bytes"\n\t"strings"\n)\n'
+    );
+    expect(applyEdits(grouped, importEdits(parseGoFile(grouped, 'main.go'), entries.slice(0, 1)))).toContain(
+      'import (\n\t"Zeta/x"\n\t"bytes"\n\t"strings"\n)\n'
+    );
+  });
+});
@@ -0,0 +1,110 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { inlineFunction } from '../src/go/inline-function';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), 'sample.go');
+
+const logSource = `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func logLine(prefix string, n int) {
+	line := fmt.Sprintf("%s=%d", prefix, n)
+	fmt.Println(line)
+}
+
+func scaled(x, factor int) int {
+	return x * factor
+}
+
+func first(a, b int) int {
+	return b - a
+}
+
+func next() int { return 1 }
+
+func shout(s string) string {
+	return strings.ToUpper(s)
+}
+
+func Run(values []int) {
+	line := "start"
+	for i, v := range values {
+		logLine(line, v+i)
+		logLine(strings.TrimSpace(line), next())
+	}
+	total := scaled(values[0]+1, 2) + 1
+	fmt.Println(total, first(next(), next()))
+	strings := []string{"a"}
+	fmt.Println(shout(strings[0]))
+	fmt.Println(-scaled(total, 3))
+}
+`;
+
+const logFile = () => parseGoFile(logSource, 'main.go');
+
+describe('Go inline function', () => {
+  it('should inline a one-line method and offer to delete it', () => {
+    const result = inlineFunction(sample(), { name: 'DataProcessor.processItem' });
+
+    expect(result.inlined).toEqual([29]);
+    expect(result.source).toContain('\t\t\tprocessed := strings.ToUpper(item)\n');
+    expect(result.remainingReferences).toBe(0);
+    expect(result.removable).toBe(true);
+    expect(result.removed).toBe(false);
+    expect(result.source).toContain('func (dp *DataProcessor) processItem(');
+  });
+
+  it('should delete the definition when asked and nothing references it', () => {
+    const result = inlineFunction(sample(), {
+      name: 'DataProcessor.processItem',
+      deleteDefinition: true,
+    });
+
+    expect(result.removed).toBe(true);
+    expect(result.source).not.toContain('processItem');
+    expect(result.source).toContain(
+      '\treturn results\n}\n\n// GetCacheSize returns the current cache size\n',
+    );
+  });
+
+  it('should bind arguments with side effects and rename clashing locals', () => {
+    const result = inlineFunction(logFile(), { name: 'logLine' });
+
+    expect(result.source).toContain(
+      '\t\tline2 := fmt.Sprintf("%s=%d", line, v+i)\n' +
+        '\t\tfmt.Println(line2)\n' +
+        '\t\tprefix := strings.TrimSpace(line)\n' +
+        '\t\tn := next()\n' +
+        '\t\tline3 := fmt.Sprintf("%s=%d", prefix, n)\n' +
+        '\t\tfmt.Println(line3)\n',
+    );
+  });
+
+  it('should parenthesize substituted expressions by precedence', () => {
+    const result = inlineFunction(logFile(), { name: 'scaled' });
+
+    expect(result.source).toContain('\ttotal := (values[0]+1) * 2 + 1\n');
+    expect(result.source).toContain('\tfmt.Println(-(total * 3))\n');
+  });
+
+  it('should refuse calls and bodies that cannot be expressed inline', () => {
+    expect(() => inlineFunction(sample(), { name: 'processTypeA' })).toThrow(
+      /does more than return a single expression/,
+    );
+    expect(() => inlineFunction(logFile(), { name: 'first' })).toThrow(
+      /line 34: argument 1 would be evaluated out of order/,
+    );
+    expect(() => inlineFunction(logFile(), { name: 'shout' })).toThrow(
+      /strings refers to a local variable at the call site/,
+    );
+    expect(() => inlineFunction(sample(), { name: 'Missing' })).toThrow(GoRefactorError);
+  });
+});
@@ -0,0 +1,102 @@
+import { describe, it, expect } from '@jest/globals';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import {
+  formatGoFindingJsonLine,
+  goFindingJson,
+  GoPackageFindings,
+  streamGoFindings,
+} from '../src/go/jsonl';
+import { GoRuleRegistry } from '../src/go/rules';
+
+const source = (pkg: string) => `package ${pkg}
+
+import "os"
+
+func Clean() {
+	os.Remove("tmp")
+}
+`;
+
+describe('Go findings as JSON Lines', () => {
+  const root = path.join(path.sep, 'repo');
+  const files = [
+    parseGoFile(source('b'), path.join(root, 'b', 'b.go')),
+    parseGoFile(source('a'), path.join(root, 'a', 'a.go')),
+    parseGoFile(source('a'), path.join(root, 'a', 'other.go')),
+  ];
+
+  it('writes each finding as one complete JSON object per line', () => {
+    const finding = {
+      rule: 'ignored-error',
+      severity: 'high' as const,
+      filePath: path.join(root, 'a', 'a.go'),
+      line: 6,
+      column: 2,
+      message: 'Error result of os.Remove is ignored\nsecond line',
+    };
+    const line = formatGoFindingJsonLine(finding, { root });
+
+    expect(line.endsWith('\n')).toBe(true);
+    expect(line.slice(0, -1)).not.toContain('\n');
+    expect(JSON.parse(line)).toEqual({
+      rule: 'ignored-error',
+      severity: 'high',
+      file: 'a/a.go',
+      line: 6,
+      column: 2,
+      message: 'Error result of os.Remove is ignored\nsecond line',
+      fix: null,
+    });
+    expect(goFindingJson({ ...finding, fix: '_ = x' }).fix).toBe('_ = x');
+  });
+
+  it('yields findings one package at a time, in input order', async () => {
+    const batches: GoPackageFindings[] = [];
+    for await (const batch of streamGoFindings(files)) {
+      batches.push(batch);
+    }
+
+    expect(batches.map(batch => batch.files.map(file => path.basename(file.filePath)))).toEqual([
+      ['b.go'],
+      ['a.go', 'other.go'],
+    ]);
+    expect(batches.map(batch => batch.findings.map(finding => finding.rule))).toEqual([
+      ['ignored-error'],
+      ['ignored-error', 'ignored-error'],
+    ]);
+  });
+
+  it('leaves complete lines behind when a run fails partway', async () => {
+    let calls = 0;
+    const registry = new GoRuleRegistry().register({
+      id: 'flaky',
+      description: 'Fails on the second package',
+      severity: 'low',
+      checkFiles: group => {
+        if (++calls > 1) throw new Error('crashed');
+        return group.map(file => ({
+          rule: 'flaky',
+          severity: 'low' as const,
+          filePath: file.filePath,
+          line: 1,
+          column: 1,
+          message: 'found',
+        }));
+      },
+    });
+
+    let output = '';
+    await expect(
+      (async () => {
+        for await (const batch of streamGoFindings(files, { registry })) {
+          batch.findings.forEach(finding => (output += formatGoFindingJsonLine(finding)));
+        }
+      })()
+    ).rejects.toThrow('crashed');
+
+    const lines = output.split('\n');
+    expect(lines.pop()).toBe('');
+    expect(lines.map(line => JSON.parse(line).file)).toEqual([files[0].filePath]);
+  });
+});
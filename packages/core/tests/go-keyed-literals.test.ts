@@ -0,0 +1,120 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findUnkeyedLiterals, keyStructLiterals } from '../src/go/keyed-literals';
+import { parseGoFile } from '../src/go/parser';
+import { applyGoQuickFixes } from '../src/go/quick-fix';
+import { GoRefactorError } from '../src/go/refactor';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package geo
+
+import "image"
+
+type Point struct{ X, Y int }
+
+type Labeled struct {
+	Point
+	Name string
+}
+
+type Points []Point
+
+type padded struct {
+	_ int
+	v int
+}
+
+var origin = Point{0, 0}
+
+func Shapes() {
+	_ = []Point{{1, 2}, {3, 4}}
+	_ = map[string]*Point{"a": {5, 6}}
+	_ = Points{{7, 8}}
+	_ = Labeled{Point{1, 1}, "one"}
+	_ = Point{X: 1, Y: 2}
+	_ = image.Point{1, 2}
+	_ = padded{1, 2}
+	_ = Point{}
+}
+
+func Local() {
+	type Point struct{ A, B int }
+	_ = Point{1, 2}
+}
+`;
+
+describe('Go keyed struct literals', () => {
+  const file = parseGoFile(source, '/src/geo/geo.go');
+
+  it('flags positional literals of structs declared in the package', () => {
+    const findings = findUnkeyedLiterals([file]);
+
+    expect(findings.map(finding => [finding.line, finding.type, finding.fix])).toEqual([
+      [19, 'Point', 'Point{X: 0, Y: 0}'],
+      [22, 'Point', '{X: 1, Y: 2}'],
+      [22, 'Point', '{X: 3, Y: 4}'],
+      [23, 'Point', '{X: 5, Y: 6}'],
+      [24, 'Point', '{X: 7, Y: 8}'],
+      [25, 'Labeled', 'Labeled{Point: Point{1, 1}, Name: "one"}'],
+      [25, 'Point', 'Point{X: 1, Y: 1}'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'unkeyed-literal',
+      severity: 'low',
+      column: 14,
+      fields: ['X', 'Y'],
+    });
+    expect(defaultGoRuleRegistry().run([file]).filter(f => f.rule === 'unkeyed-literal')).toHaveLength(7);
+  });
+
+  it('leaves external, local, blank-field and keyed literals alone', () => {
+    const lines = findUnkeyedLiterals([file]).map(finding => finding.line);
+
+    expect(lines).not.toContain(26);
+    expect(lines).not.toContain(27);
+    expect(lines).not.toContain(28);
+    expect(lines).not.toContain(34);
+  });
+
+  it('keys the fields of a positional DataProcessor literal', () => {
+    const sample = fs
+      .readFileSync(samplePath, 'utf-8')
+      .replace(
+        '\treturn &DataProcessor{\n\t\tconfig: config,\n\t\tcache:  make(map[string]interface{}),\n\t}',
+        '\treturn &DataProcessor{config, make(map[string]interface{})}'
+      );
+    const parsed = parseGoFile(sample, samplePath);
+    const result = keyStructLiterals(parsed, { line: 17 });
+
+    expect(result.rewritten).toEqual([{ line: 17, column: 10 }]);
+    expect(result.source).toContain(
+      '\treturn &DataProcessor{config: config, cache: make(map[string]interface{})}'
+    );
+    expect(findUnkeyedLiterals([parseGoFile(result.source, samplePath)])).toEqual([]);
+    expect(findUnkeyedLiterals([parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)])).toEqual([]);
+  });
+
+  it('resolves structs declared in other files of the package', () => {
+    const types = parseGoFile('package geo\n\ntype Size struct{ W, H int }\n', '/src/geo/types.go');
+    const use = parseGoFile('package geo\n\nvar unit = Size{1, 1}\n', '/src/geo/use.go');
+
+    expect(findUnkeyedLiterals([use])).toEqual([]);
+    expect(keyStructLiterals(use, {}, [types, use]).source).toBe('package geo\n\nvar unit = Size{W: 1, H: 1}\n');
+    expect(() => keyStructLiterals(use, { line: 1 }, [types, use])).toThrow(GoRefactorError);
+    expect(() => keyStructLiterals(use, { line: 1 }, [types, use])).toThrow(
+      'Line 1 has no positional literal of a struct declared in the package'
+    );
+  });
+
+  it('applies as a quick fix, nested literals included', () => {
+    const result = applyGoQuickFixes([file], findUnkeyedLiterals([file]), { rules: ['unkeyed-literal'] });
+
+    expect(result.skipped).toEqual([]);
+    expect(result.changes[0].content).toContain('_ = []Point{{X: 1, Y: 2}, {X: 3, Y: 4}}');
+    expect(result.changes[0].content).toContain('_ = Labeled{Point: Point{X: 1, Y: 1}, Name: "one"}');
+    expect(result.changes[0].content).toContain('_ = image.Point{1, 2}');
+  });
+});
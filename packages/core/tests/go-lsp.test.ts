@@ -0,0 +1,70 @@
+import { describe, it, expect } from '@jest/globals';
+import * as path from 'path';
+import { GoFinding } from '../src/go/findings';
+import { goLspDiagnostics, goLspPosition, LSP_DIAGNOSTIC_SEVERITY } from '../src/go/lsp';
+import { parseGoFile } from '../src/go/parser';
+
+const source = `package text
+
+func Greet() string {
+	s := "héllo"; t := "😀 "; u := len(s + t)
+	return s
+}
+`;
+
+describe('Go findings as LSP diagnostics', () => {
+  const root = path.join(path.sep, 'repo');
+  const file = parseGoFile(source, path.join(root, 'text.go'));
+  const finding = (column: number, severity: GoFinding['severity'] = 'low'): GoFinding => ({
+    rule: 'test-rule',
+    severity,
+    filePath: file.filePath,
+    line: 4,
+    column,
+    message: `column ${column}`,
+  });
+
+  it('converts byte columns to zero-based UTF-16 characters', () => {
+    // t starts after `s := "héllo"; ` (16 bytes, 15 code units)
+    expect(goLspPosition(file, 4, 17)).toEqual({ line: 3, character: 15 });
+    // u starts after the emoji, four bytes but two code units
+    expect(goLspPosition(file, 4, 31)).toEqual({ line: 3, character: 27 });
+    expect(source.split('\n')[3].slice(27, 28)).toBe('u');
+    expect(goLspPosition(file, 3, 6)).toEqual({ line: 2, character: 5 });
+  });
+
+  it('groups diagnostics per file with ranges over the word at the position', () => {
+    const other = { ...finding(2, 'high'), filePath: path.join(root, 'other.go'), line: 1 };
+    const diagnostics = goLspDiagnostics([finding(31, 'medium'), other, finding(2, 'high')], [file]);
+
+    expect(diagnostics.map(entry => entry.uri)).toEqual(['file:///repo/other.go', 'file:///repo/text.go']);
+    expect(diagnostics[1].diagnostics).toEqual([
+      {
+        range: { start: { line: 3, character: 1 }, end: { line: 3, character: 2 } },
+        severity: LSP_DIAGNOSTIC_SEVERITY.error,
+        source: 'refactogent',
+        code: 'test-rule',
+        message: 'column 2',
+      },
+      {
+        range: { start: { line: 3, character: 27 }, end: { line: 3, character: 28 } },
+        severity: LSP_DIAGNOSTIC_SEVERITY.warning,
+        source: 'refactogent',
+        code: 'test-rule',
+        message: 'column 31',
+      },
+    ]);
+    // Files that were not parsed keep their columns
+    expect(diagnostics[0].diagnostics[0].range.start).toEqual({ line: 0, character: 1 });
+  });
+
+  it('maps severities as configured', () => {
+    const [entry] = goLspDiagnostics([finding(2)], [file], {
+      severities: { low: LSP_DIAGNOSTIC_SEVERITY.hint },
+      source: 'go-lint',
+    });
+
+    expect(entry.diagnostics[0]).toMatchObject({ severity: 4, source: 'go-lint' });
+    expect(goLspDiagnostics([finding(2)], [file])[0].diagnostics[0].severity).toBe(3);
+  });
+});
@@ -0,0 +1,184 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { analyzeGo } from '../src/go/analyze';
+import { applyGoConfigSeverities, goConfigRegistry, parseGoConfig } from '../src/go/config';
+import { evaluateGoGate } from '../src/go/gate';
+import {
+  annotateMaintainability,
+  findLowMaintainability,
+  GoMaintainabilityError,
+  goMaintainabilityIndex,
+  parseGoMaintainabilityConstants,
+} from '../src/go/maintainability';
+import { parseGoFile } from '../src/go/parser';
+import { goMarkdownReport } from '../src/go/report';
+import { parseGoSymbolsJson, serializeGoSymbols } from '../src/go/serialize';
+import { extractGoFileSymbols } from '../src/go/symbols';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const parse = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+
+describe('Go maintainability index', () => {
+  it('scores simple methods high and the complex function of the fixture lower', () => {
+    const symbols = [extractGoFileSymbols(parse('sample.go')), extractGoFileSymbols(parse('embedded.go'))];
+    annotateMaintainability(symbols);
+
+    const [sample, embedded] = symbols;
+    const scores = Object.fromEntries(
+      [...sample.functions, ...sample.methods].map((fn) => [fn.qualifiedName, fn.maintainabilityIndex])
+    );
+    expect(scores).toEqual({
+      NewDataProcessor: 99.1,
+      CalculateFibonacci: 90.8,
+      ProcessComplexData: 82.8,
+      processTypeA: 98.8,
+      processTypeB: 100,
+      privateHelper: 100,
+      'DataProcessor.ProcessData': 90.9,
+      'DataProcessor.processItem': 100,
+      'DataProcessor.GetCacheSize': 100,
+    });
+    // Weighted by code lines, so ProcessComplexData counts most
+    expect(sample.maintainabilityIndex).toBe(92);
+    expect(embedded.maintainabilityIndex).toBe(100);
+    expect(sample.types[0].methods[0].maintainabilityIndex).toBe(90.9);
+  });
+
+  it('follows the classic formula with configurable constants', () => {
+    const size = { codeLines: 40, commentLines: 0, docLines: 0 };
+
+    // 171 - 0.23 * 12 - 16.2 * ln(40) = 108.5, scaled by 100 / 171
+    expect(goMaintainabilityIndex(12, size)).toBe(63.4);
+    expect(goMaintainabilityIndex(12, { ...size, commentLines: 10 })).toBeGreaterThan(63.4);
+    expect(goMaintainabilityIndex(12, size, { base: 139 })).toBe(44.7);
+    expect(goMaintainabilityIndex(12, size, { lines: 0, complexity: 0 })).toBe(100);
+    expect(goMaintainabilityIndex(200, { codeLines: 5000, commentLines: 0, docLines: 0 })).toBe(0);
+    expect(goMaintainabilityIndex(1, { codeLines: 0, commentLines: 0, docLines: 0 }, { base: 85.5 })).toBe(49.9);
+  });
+
+  it('parses constants given on the command line', () => {
+    expect(parseGoMaintainabilityConstants('base=140, comments=0,complexity=0.5')).toEqual({
+      base: 140,
+      comments: 0,
+      complexity: 0.5,
+    });
+    expect(() => parseGoMaintainabilityConstants('volume=5.2')).toThrow(
+      'Constant "volume=5.2" must look like name=value, with name one of base, complexity, lines, comments, scale'
+    );
+    expect(() => parseGoMaintainabilityConstants('lines=many')).toThrow('Constant lines must be a number, not "many"');
+    expect(() => parseGoMaintainabilityConstants('scale=0')).toThrow(GoMaintainabilityError);
+  });
+
+  it('lists files by their index in the Markdown report', () => {
+    const report = goMarkdownReport([parse('sample.go'), parse('embedded.go')], {
+      root: fixtures,
+      maintainability: { base: 150 },
+    });
+
+    expect(report).toContain(`## Maintainability
+
+Files by maintainability index, lowest first. The index scores a function from 0 to 100 on its cyclomatic complexity, code lines and share of comments; a file scores the mean of its functions weighted by their code lines.
+
+| File | Index | Functions | Lowest |
+| --- | ---: | ---: | --- |
+| sample.go | 81.7 | 9 | \`ProcessComplexData\` (70.5) |
+| embedded.go | 97.6 | 6 | \`Base.Describe\` (97.6) |
+`);
+    expect(goMarkdownReport([])).toContain('## Maintainability\n\nFiles by maintainability index');
+  });
+
+  it('reports functions below the minimum index', () => {
+    const long = `package main\n\nfunc Long() {\n\tx := 0\n${'\tx++\n'.repeat(5000)}\t_ = x\n}\n`;
+    const [finding] = findLowMaintainability([parseGoFile(long, '/repo/long.go')]);
+
+    expect(finding).toMatchObject({
+      rule: 'low-maintainability',
+      severity: 'low',
+      line: 3,
+      column: 6,
+      name: 'Long',
+      maintainabilityIndex: 19.2,
+      message: 'Long has a maintainability index of 19.2, below 20; split it or simplify its control flow',
+    });
+    expect(findLowMaintainability([parse('sample.go')])).toEqual([]);
+    expect(findLowMaintainability([parse('sample.go')], { minIndex: 91 }).map((f) => f.name)).toEqual([
+      'DataProcessor.ProcessData',
+      'CalculateFibonacci',
+      'ProcessComplexData',
+    ]);
+  });
+
+  it('fails the gate on functions below the minimum a config sets', () => {
+    const config = parseGoConfig(
+      ['failOn: high', 'rules:', '  low-maintainability:', '    severity: high', '    minIndex: 85'].join('\n')
+    );
+    const findings = applyGoConfigSeverities(
+      goConfigRegistry(config)
+        .run([parse('sample.go')])
+        .filter((f) => f.rule === 'low-maintainability'),
+      config
+    );
+
+    expect(findings).toHaveLength(1);
+    expect(findings[0]).toMatchObject({ name: 'ProcessComplexData', severity: 'high' });
+    expect(evaluateGoGate(findings, { failOn: config.failOn }).passed).toBe(false);
+    expect(evaluateGoGate(findings.slice(1), { failOn: config.failOn }).passed).toBe(true);
+  });
+
+  it('gates on the scores the report shows when given the same constants', () => {
+    const rule = (options: string[]) =>
+      parseGoConfig(['rules:', '  low-maintainability:', ...options.map((option) => `    ${option}`)].join('\n'));
+    const low = (options: string[]) =>
+      goConfigRegistry(rule(options))
+        .run([parse('sample.go')])
+        .filter((f) => f.rule === 'low-maintainability')
+        .map((f) => f.message);
+
+    expect(low(['minIndex: 80.5'])).toEqual([]);
+    expect(low(['minIndex: 80.5', 'constants:', '  base: 150'])).toEqual([
+      'DataProcessor.ProcessData has a maintainability index of 78.7, below 80.5; split it or simplify its control flow',
+      'CalculateFibonacci has a maintainability index of 78.5, below 80.5; split it or simplify its control flow',
+      'ProcessComplexData has a maintainability index of 70.5, below 80.5; split it or simplify its control flow',
+    ]);
+    expect(() => rule(['minIndex: 120'])).toThrow(
+      '.refactogent.yaml: rules.low-maintainability.minIndex must be a number from 0 to 100'
+    );
+    expect(() => rule(['constants:', '  volume: 5.2'])).toThrow(
+      'unknown key rules.low-maintainability.constants.volume; expected base, complexity, lines, comments or scale'
+    );
+    expect(() => rule(['constants:', '  scale: 0'])).toThrow('rules.low-maintainability.constants.scale cannot be 0');
+    expect(() => rule(['constants: 140'])).toThrow('rules.low-maintainability.constants must be a mapping');
+  });
+
+  describe('analyzeGo', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-maintainability-'));
+      fs.copyFileSync(path.join(fixtures, 'sample.go'), path.join(tempDir, 'sample.go'));
+      fs.writeFileSync(path.join(tempDir, 'version.go'), 'package main\n\nconst Version = "1"\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    it('includes the scores in the JSON document', async () => {
+      const result = await analyzeGo(tempDir, { maintainability: { base: 150 } });
+      const [sample, version] = result.files;
+
+      expect(sample.maintainability_index).toBe(81.7);
+      expect(sample.functions.find((fn) => fn.name === 'ProcessComplexData').maintainability_index).toBe(70.5);
+      expect(version.maintainability_index).toBeNull();
+
+      const symbols = [extractGoFileSymbols(parse('sample.go'))];
+      annotateMaintainability(symbols);
+      expect(parseGoSymbolsJson(serializeGoSymbols(symbols, fixtures), fixtures)).toEqual(symbols);
+    });
+  });
+});
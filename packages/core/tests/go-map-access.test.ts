@@ -0,0 +1,121 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findMapReadsWithoutOk } from '../src/go/map-access';
+import { parseGoFile } from '../src/go/parser';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package cache
+
+type Entry struct{ Value string }
+
+type Store struct {
+	entries map[string]*Entry
+	meta    map[string]interface{}
+	counts  map[string]int
+}
+
+func (s *Store) Has(key string) bool {
+	e := s.entries[key]
+	return e != nil
+}
+
+func (s *Store) Value(key string) string {
+	return s.entries[key].Value
+}
+
+func (s *Store) Label(key string) string {
+	if v, ok := s.meta[key]; ok {
+		return v.(string)
+	}
+	label := s.meta[key].(string)
+	name, _ := s.meta[key].(string)
+	return label + name
+}
+
+func (s *Store) Describe(key string) string {
+	n := s.counts[key]
+	text := describe(s.entries[key])
+	var e = s.entries[key]
+	e = nil
+	if e == nil {
+		return text
+	}
+	if s.meta[key] == nil {
+		return ""
+	}
+	s.entries[key] = nil
+	_ = n
+	return ""
+}
+
+func describe(e *Entry) string { return "" }
+
+func local(m map[string]error) error {
+	err := m["x"]
+	if err != nil {
+		return err
+	}
+	lookup := map[int]any{}
+	if lookup[1] != nil {
+		return nil
+	}
+	return nil
+}
+`;
+
+const findings = () => findMapReadsWithoutOk([parseGoFile(source, 'cache.go')]);
+
+describe('Go map reads without comma-ok', () => {
+  it('should follow a read into the variable that is nil checked', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    expect(findMapReadsWithoutOk([sample])).toEqual([]);
+
+    expect(findings()[0]).toEqual({
+      rule: 'map-read-without-ok',
+      severity: 'low',
+      filePath: 'cache.go',
+      line: 12,
+      column: 7,
+      message:
+        's.entries[key] is read into e, which is compared with nil, which cannot tell a missing key from a stored nil; use e, ok := s.entries[key]',
+      fix: 'e, ok := s.entries[key]',
+      expression: 's.entries[key]',
+      valueType: '*Entry',
+      use: 'nil-check',
+    });
+  });
+
+  it('should flag dereferences and single-value type assertions', () => {
+    const dereferences = findings().filter(finding => finding.use === 'dereference');
+    expect(dereferences.map(finding => [finding.line, finding.severity, finding.fix])).toEqual([
+      [17, 'medium', 'v, ok := s.entries[key]'],
+      [24, 'medium', 'v, ok := s.meta[key]'],
+    ]);
+  });
+
+  it('should cover local maps of any and error values', () => {
+    expect(findings().map(finding => [finding.line, finding.valueType])).toEqual([
+      [12, '*Entry'],
+      [17, '*Entry'],
+      [24, 'interface{}'],
+      [37, 'interface{}'],
+      [48, 'error'],
+      [53, 'any'],
+    ]);
+  });
+
+  it('should ignore comma-ok reads, writes, reassigned results and values passed on', () => {
+    const lines = findings().map(finding => finding.line);
+    // comma-ok forms
+    expect(lines).not.toContain(21);
+    expect(lines).not.toContain(25);
+    // an int map, a read passed to a call, a variable reassigned before the check
+    expect(lines).not.toContain(30);
+    expect(lines).not.toContain(31);
+    expect(lines).not.toContain(32);
+    // a write
+    expect(lines).not.toContain(40);
+  });
+});
@@ -0,0 +1,95 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findMapsAsStructs } from '../src/go/map-struct';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package server
+
+const keyPort = "port"
+
+type Server struct {
+	settings map[string]interface{}
+	labels   map[string]string
+}
+
+func NewServer() *Server {
+	return &Server{
+		settings: map[string]interface{}{"host": "localhost", keyPort: 8080},
+		labels:   make(map[string]string),
+	}
+}
+
+func (s *Server) Configure(debug bool) {
+	s.settings["debug"] = debug
+	s.settings["api_url"] = "http://localhost"
+	delete(s.settings, "debug")
+}
+
+func (s *Server) Label(name, value string) {
+	s.labels[name] = value
+	s.labels["owner"] = value
+}
+`;
+
+const limits = `package server
+
+var limits = map[string]int{"max-conns": 10}
+
+func Limits() int {
+	for range limits {
+	}
+	return limits["max-conns"] + len(limits)
+}
+`;
+
+describe('Go maps as structs', () => {
+  const file = parseGoFile(source, '/repo/server/server.go');
+
+  it('suggests a struct for maps only indexed with literal keys', () => {
+    const [settings] = findMapsAsStructs([file]).filter(finding => finding.map === 'Server.settings');
+
+    expect(settings).toMatchObject({
+      rule: 'map-as-struct',
+      severity: 'low',
+      line: 6,
+      keys: ['host', 'port', 'debug', 'api_url'],
+      struct: 'Settings',
+      unresolved: [],
+    });
+    expect(settings.fix).toBe(
+      'type Settings struct {\n\tHost   string\n\tPort   int\n\tDebug  bool\n\tAPIURL string\n}'
+    );
+    expect(settings.message).toBe(
+      'Server.settings is only used with the keys "host", "port", "debug", "api_url"; a Settings struct would name and type them'
+    );
+  });
+
+  it('lists the keys and uses it cannot resolve', () => {
+    const [labels] = findMapsAsStructs([file]).filter(finding => finding.map === 'Server.labels');
+
+    expect(labels.keys).toEqual(['owner']);
+    expect(labels.unresolved).toEqual([{ text: 'name', line: 24, column: 11 }]);
+    expect(labels.fix).toBeUndefined();
+    expect(labels.message).toContain('1 key(s) or use(s) could not be resolved: name (line 24)');
+  });
+
+  it('follows package-level maps across the files of the package', () => {
+    const other = parseGoFile(limits, '/repo/server/limits.go');
+    const [finding] = findMapsAsStructs([file, other]).filter(candidate => candidate.map === 'limits');
+
+    expect(finding).toMatchObject({ filePath: '/repo/server/limits.go', line: 3, keys: ['max-conns'] });
+    expect(finding.unresolved).toEqual([{ text: 'range limits', line: 6, column: 12 }]);
+    expect(findMapsAsStructs([parseGoFile(limits, '/repo/other/limits.go')])[0].struct).toBe('LimitsFields');
+  });
+
+  it('runs as a registered rule and leaves the sample alone', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+    expect(defaultGoRuleRegistry().run([file]).map(f => f.rule)).toContain('map-as-struct');
+    expect(findMapsAsStructs([sample])).toEqual([]);
+  });
+});
@@ -0,0 +1,156 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { moveFunctions } from '../src/go/move-function';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), 'sample.go');
+
+const serverSource = `//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Start runs the server
+func Start(name string) {
+	fmt.Println(banner(name))
+}
+
+// banner formats the greeting
+func banner(name string) string {
+	return strings.ToUpper(name)
+}
+`;
+
+describe('Go move function', () => {
+  it('should create the destination with a package clause and imports', () => {
+    const result = moveFunctions(sample(), 'types.go', ['processTypeA', 'processTypeB']);
+
+    expect(result.created).toBe(true);
+    expect(result.moved).toEqual(['processTypeA', 'processTypeB']);
+    expect(result.addedImports).toEqual(['fmt', 'strings']);
+    expect(result.removedImports).toEqual([]);
+    expect(result.destination.source).toBe(`package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// processTypeA handles type A items
+func processTypeA(item string) string {
+	if len(item) > 5 {
+		return fmt.Sprintf("A_LONG_%s", item)
+	}
+	return fmt.Sprintf("A_SHORT_%s", item)
+}
+
+// processTypeB handles type B items
+func processTypeB(item string) string {
+	return fmt.Sprintf("B_%s", strings.ToUpper(item))
+}
+`);
+    expect(result.source.source).not.toContain('processTypeA handles');
+    expect(result.source.source).toContain('return results, nil\n}\n\n// privateHelper');
+    expect(result.destination.diff).toContain('--- /dev/null\n+++ types.go');
+  });
+
+  it('should write moved imports in byte order, like gofmt', () => {
+    const source = 'package main\n\nimport (\n\t"Zeta/log"\n\t"bytes"\n)\n\nfunc Write() {\n\tlog.Print(bytes.MinRead)\n}\n';
+    const result = moveFunctions(parseGoFile(source, 'main.go'), 'write.go', ['Write']);
+
+    expect(result.addedImports).toEqual(['Zeta/log', 'bytes']);
+    expect(result.destination.source).toContain('import (\n\t"Zeta/log"\n\t"bytes"\n)\n');
+  });
+
+  it('should move imports between existing files', () => {
+    const source = parseGoFile(serverSource, 'server.go');
+    const destination = parseGoFile(
+      '//go:build linux\n\npackage server\n\nimport "fmt"\n\nfunc log(s string) { fmt.Println(s) }\n',
+      'format.go'
+    );
+
+    const result = moveFunctions(source, destination, ['banner']);
+
+    expect(result.created).toBe(false);
+    expect(result.addedImports).toEqual(['strings']);
+    expect(result.removedImports).toEqual(['strings']);
+    expect(result.source.source).toBe(`//go:build linux
+
+package server
+
+import (
+	"fmt"
+)
+
+// Start runs the server
+func Start(name string) {
+	fmt.Println(banner(name))
+}
+`);
+    expect(result.destination.source).toBe(`//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+func log(s string) { fmt.Println(s) }
+
+// banner formats the greeting
+func banner(name string) string {
+	return strings.ToUpper(name)
+}
+`);
+  });
+
+  it('should copy the build constraint into a new file', () => {
+    const result = moveFunctions(parseGoFile(serverSource, 'server.go'), 'start.go', ['Start']);
+
+    expect(result.destination.source).toBe(`//go:build linux
+
+package server
+
+import "fmt"
+
+// Start runs the server
+func Start(name string) {
+	fmt.Println(banner(name))
+}
+`);
+    expect(result.removedImports).toEqual(['fmt']);
+    expect(result.source.source).toContain('import (\n\t"strings"\n)\n\n// banner');
+  });
+
+  it('should refuse moves that change the package or build', () => {
+    const source = parseGoFile(serverSource, 'server.go');
+    const other = (text: string, name = 'other.go') => parseGoFile(text, name);
+
+    expect(() => moveFunctions(source, other('package client\n'), ['banner'])).toThrow(
+      'other.go is in package client, not server'
+    );
+    expect(() => moveFunctions(source, other('package server\n'), ['banner'])).toThrow(
+      'server.go and other.go have different build constraints'
+    );
+    expect(() => moveFunctions(source, 'sub/banner.go', ['banner'])).toThrow(
+      'banner.go is not in the same directory as server.go'
+    );
+    expect(() => moveFunctions(source, 'server_windows.go', ['banner'])).toThrow(
+      'server.go and server_windows.go have different file name build tags'
+    );
+    expect(() => moveFunctions(source, 'server_test.go', ['banner'])).toThrow(
+      'Cannot move code between test and non-test files'
+    );
+    expect(() =>
+      moveFunctions(source, other('//go:build linux\n\npackage server\n\nfunc banner() {}\n'), ['banner'])
+    ).toThrow('other.go already declares banner');
+    expect(() => moveFunctions(source, 'x.go', ['missing'])).toThrow('missing is not declared in the file');
+  });
+});
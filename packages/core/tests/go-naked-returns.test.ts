@@ -0,0 +1,100 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findNakedReturns, makeReturnsExplicit } from '../src/go/naked-returns';
+import { GoRefactorError } from '../src/go/refactor';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package parse
+
+type Point struct{ X, Y int }
+
+func Split(s string) (head, tail string) {
+	head = s
+	return
+}
+
+func Parse(s string) (p Point, _ int, err error) {
+	if s == "" {
+		err = errEmpty
+		return
+	}
+	p.X = len(s)
+	p.Y = p.X * 2
+	return
+}
+
+func Lookup(m map[string]int, k string) (n int, err error) {
+	n = m[k]
+	if n == 0 {
+		err := errMissing
+		_ = err
+		return
+	}
+	return
+}
+
+func Deferred() func() (ok bool) {
+	return func() (ok bool) {
+		ok = true
+		defer func() {}()
+		if ok {
+			return
+		}
+		return
+	}
+}
+`;
+
+describe('Go naked returns', () => {
+  const file = parseGoFile(source, 'parse.go');
+
+  it('reports bare returns in long functions with named results', () => {
+    const findings = findNakedReturns([file]);
+
+    expect(findings.map(finding => [finding.line, finding.function, finding.fix])).toEqual([
+      [13, 'Parse', 'return p, 0, err'],
+      [17, 'Parse', 'return p, 0, err'],
+      [25, 'Lookup', undefined],
+      [27, 'Lookup', 'return n, err'],
+      [35, 'function literal in Deferred', 'return ok'],
+      [37, 'function literal in Deferred', 'return ok'],
+    ]);
+    expect(findings[0]).toMatchObject({ rule: 'naked-return', severity: 'low', column: 3, lines: 9 });
+    expect(findings[2].message).toContain('a local hides the result err');
+    // Split is short enough, and so is the literal with a higher limit
+    const longer = findNakedReturns([file], { maxLines: 8 });
+    expect(longer.map(finding => finding.function)).not.toContain('function literal in Deferred');
+    expect(longer).toHaveLength(4);
+  });
+
+  it('returns the values the named results hold', () => {
+    const result = makeReturnsExplicit(file);
+
+    expect(result.rewritten.map(position => position.line)).toEqual([13, 17, 27, 35, 37]);
+    expect(result.source).toContain('\t\terr = errEmpty\n\t\treturn p, 0, err\n');
+    expect(result.source).toContain('\tp.Y = p.X * 2\n\treturn p, 0, err\n}');
+    // The shadowed return would return the local err, so it is kept
+    expect(result.source).toContain('\t\t_ = err\n\t\treturn\n\t}\n\treturn n, err\n}');
+    expect(result.source).toContain('\thead = s\n\treturn\n}');
+    expect(findNakedReturns([parseGoFile(result.source, 'parse.go')]).map(finding => finding.line)).toEqual([25]);
+  });
+
+  it('rewrites one return by line and refuses shadowed results', () => {
+    const one = makeReturnsExplicit(file, { line: 27 });
+    expect(one.rewritten).toEqual([{ line: 27, column: 2 }]);
+
+    expect(() => makeReturnsExplicit(file, { line: 25 })).toThrow(GoRefactorError);
+    expect(() => makeReturnsExplicit(file, { line: 6 })).toThrow('Line 6 has no naked return');
+  });
+
+  it('passes functions with explicit returns', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+    expect(findNakedReturns([sample])).toEqual([]);
+    expect(defaultGoRuleRegistry().list().map(rule => rule.id)).toContain('naked-return');
+  });
+});
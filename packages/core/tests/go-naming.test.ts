@@ -0,0 +1,111 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findNamingViolations, suggestGoName } from '../src/go/naming';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const clientSource = `package client
+
+import "net/http"
+
+type ApiClient struct {
+	base_url string
+	http     *http.Client
+}
+
+func new_client(url string) *ApiClient {
+	return &ApiClient{base_url: url, http: http.DefaultClient}
+}
+
+func (c *ApiClient) GetUserId(user_name string) string {
+	user_id := c.base_url + "/" + user_name
+	return user_id
+}
+`;
+
+const usageSource = `package client
+
+func lookup() string {
+	c := new_client("https://example.com")
+	return c.GetUserId("gopher")
+}
+`;
+
+const testSource = `package client
+
+import "testing"
+
+func TestLookup_empty(t *testing.T) {}
+`;
+
+describe('Go naming conventions', () => {
+  it('should suggest MixedCaps names with initialisms', () => {
+    expect(suggestGoName('API_VERSION')).toBe('APIVersion');
+    expect(suggestGoName('MAX_RETRIES')).toBe('MaxRetries');
+    expect(suggestGoName('get_user_id')).toBe('getUserID');
+    expect(suggestGoName('ApiClient')).toBe('APIClient');
+    expect(suggestGoName('HttpsProxy')).toBe('HTTPSProxy');
+    expect(suggestGoName('urlPath')).toBe('urlPath');
+    expect(suggestGoName('ServeHTTP')).toBe('ServeHTTP');
+    expect(suggestGoName('IDs')).toBe('IDs');
+  });
+
+  it('should use a configured initialism list', () => {
+    expect(suggestGoName('GrpcServer', { initialisms: ['GRPC'] })).toBe('GRPCServer');
+    expect(suggestGoName('ApiClient', { initialisms: ['GRPC'] })).toBe('ApiClient');
+  });
+
+  it('should flag ALL_CAPS constants in the fixture', () => {
+    const file = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const violations = findNamingViolations([file]);
+
+    expect(violations.map(v => [v.name, v.kind, v.rule, v.suggestion])).toEqual([
+      ['API_VERSION', 'constant', 'all-caps', 'APIVersion'],
+      ['MAX_RETRIES', 'constant', 'all-caps', 'MaxRetries'],
+    ]);
+    expect(violations[0]).toMatchObject({ line: 103, column: 2 });
+    expect(violations[0].message).toBe(
+      'constant API_VERSION is written in ALL_CAPS; rename it to APIVersion'
+    );
+  });
+
+  it('should list every reference in the package', () => {
+    const files = [
+      parseGoFile(clientSource, 'client.go'),
+      parseGoFile(usageSource, 'usage.go'),
+    ];
+    const violations = findNamingViolations(files);
+    const byName = new Map(violations.map(v => [v.name, v]));
+
+    expect([...byName.keys()]).toEqual([
+      'ApiClient',
+      'base_url',
+      'new_client',
+      'GetUserId',
+      'user_name',
+      'user_id',
+    ]);
+    expect(byName.get('new_client')?.references).toEqual([
+      { filePath: 'client.go', line: 10, column: 6 },
+      { filePath: 'usage.go', line: 4, column: 7 },
+    ]);
+    expect(byName.get('ApiClient')?.references.map(r => r.line)).toEqual([5, 10, 11, 14]);
+    expect(byName.get('base_url')?.references.map(r => r.line)).toEqual([6, 11, 15]);
+    expect(byName.get('GetUserId')).toMatchObject({ kind: 'method', suggestion: 'GetUserID' });
+    expect(byName.get('GetUserId')?.references.map(r => r.filePath)).toEqual([
+      'client.go',
+      'usage.go',
+    ]);
+    expect(byName.get('user_id')).toMatchObject({ kind: 'variable', suggestion: 'userID' });
+    expect(byName.get('user_id')?.references.map(r => r.line)).toEqual([15, 16]);
+    expect(byName.get('user_name')?.kind).toBe('parameter');
+  });
+
+  it('should allow underscores in test function names', () => {
+    const violations = findNamingViolations([parseGoFile(testSource, 'lookup_test.go')]);
+
+    expect(violations).toEqual([]);
+  });
+});
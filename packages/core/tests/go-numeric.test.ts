@@ -0,0 +1,146 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { findNumericRisks } from '../src/go/numeric';
+import { parseGoFile } from '../src/go/parser';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const read = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+const parse = (source: string) => parseGoFile(source, '/src/p/p.go');
+
+describe('Go numeric risks', () => {
+  it('flags the Fibonacci recurrence of CalculateFibonacci', () => {
+    const findings = findNumericRisks([read('sample.go'), read('constants.go')]);
+
+    expect(findings).toHaveLength(1);
+    expect(findings[0]).toMatchObject({
+      rule: 'integer-overflow',
+      severity: 'low',
+      filePath: path.join(fixtures, 'sample.go'),
+      line: 55,
+      column: 3,
+      function: 'CalculateFibonacci',
+      variable: 'b',
+      type: 'int',
+      message:
+        'CalculateFibonacci returns b, an int that grows on every iteration of a loop bounded by n, and can overflow for large inputs; use math/big, or check for overflow before the arithmetic',
+    });
+  });
+
+  it('separates true from false positives in the numeric fixture', () => {
+    const findings = findNumericRisks([read('numeric.go')]);
+
+    expect(findings.map(({ rule, line, function: name }) => [rule, line, name])).toEqual([
+      ['integer-overflow', 9, 'SumSquares'],
+      ['integer-overflow', 18, 'Power'],
+      ['integer-division', 29, 'Average'],
+      ['integer-division', 34, 'Scale'],
+    ]);
+    expect(findings[1].message).toBe(
+      'Power returns result, an int32 that grows on every iteration of a loop bounded by exp, and can overflow for large inputs; use int64, or check for overflow before the arithmetic',
+    );
+    expect(findings.slice(2).map(({ message, fix }) => [message, fix])).toEqual([
+      [
+        'sum / len(values) is integer division, so the remainder is lost before the conversion to float64; convert the operands instead',
+        'float64(sum) / float64(len(values))',
+      ],
+      [
+        '1 / 2 divides integer constants, which truncates to a whole number where a float64 is expected; write 1.0 / 2',
+        '1.0 / 2',
+      ],
+    ]);
+  });
+
+  it('follows growth through named results, tuple assignments and shifts', () => {
+    const source = `package p
+
+type Counter uint16
+
+func lucas(n int) (a int64) {
+	b := int64(1)
+	for i := 0; i < n; i++ {
+		a, b = b, a+b
+	}
+	return
+}
+
+func flags(bits []bool) Counter {
+	var mask Counter = 1
+	for range bits {
+		mask <<= 1
+	}
+	return mask
+}
+
+func local(n int) int {
+	for i := 0; i < n; i++ {
+		x := 1
+		x *= 2
+		_ = x
+	}
+	return 0
+}
+`;
+    const findings = findNumericRisks([parse(source)]);
+
+    expect(findings.map(({ line, variable, type }) => [line, variable, type])).toEqual([
+      [8, 'a', 'int64'],
+      [16, 'mask', 'Counter'],
+    ]);
+    expect(findings[1].message).toBe(
+      'flags returns mask, a Counter that grows on every iteration of a loop over bits, and can overflow for large inputs; use uint64, or check for overflow before the arithmetic',
+    );
+  });
+
+  it('flags constant division in every float context, at package level too', () => {
+    const source = `package p
+
+var third float64 = 1 / 3
+
+func scale(x float64) (float64, float32) {
+	var share float32
+	share = 2 / 3
+	y := x * (1 / 4)
+	return y, float32(0x10 / 3) + share
+}
+
+func whole(x float64) int {
+	ratio := 1 / 2
+	return ratio
+}
+`;
+    const findings = findNumericRisks([parse(source)]);
+
+    expect(findings.map(({ line, column, fix, function: name }) => [line, column, fix, name])).toEqual([
+      [3, 21, '1.0 / 3', undefined],
+      [7, 10, '2.0 / 3', 'scale'],
+      [8, 12, '1.0 / 4', 'scale'],
+      [9, 12, 'float32(0x10) / float32(3)', 'scale'],
+    ]);
+  });
+
+  it('leaves divisions and sums of unknown or non-integer types alone', () => {
+    const source = `package p
+
+import "time"
+
+func rate(d time.Duration, n int, f float64) float64 {
+	a := float64(d / time.Millisecond)
+	b := float64(f / 2)
+	return a + b + float64(n) / 2
+}
+
+func total(xs []float64) float64 {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum
+}
+`;
+    expect(findNumericRisks([parse(source)])).toEqual([]);
+  });
+});
@@ -0,0 +1,103 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { IncrementalGoAnalyzer } from '../src/go/cache';
+import { discoverGoFiles } from '../src/go/discover';
+import { GoOverlay } from '../src/go/overlay';
+import { loadGoPackage } from '../src/go/package';
+
+describe('Go source overlays', () => {
+  let tempDir: string;
+
+  const write = (name: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(tempDir, name)), { recursive: true });
+    fs.writeFileSync(path.join(tempDir, name), content);
+  };
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-overlay-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should analyze overlaid contents in place of the file on disk', async () => {
+    write('main.go', 'package main\n\nfunc Saved() {}\n');
+    const filePath = path.join(tempDir, 'main.go');
+    const overlay = new GoOverlay({ [filePath]: 'package main\n\nfunc Unsaved() {}\n' });
+    const analyzer = new IncrementalGoAnalyzer(undefined, { overlay });
+
+    const names = async () =>
+      (await analyzer.analyzeFile(filePath)).symbols.functions.map(fn => fn.name);
+    expect(await names()).toEqual(['Unsaved']);
+
+    overlay.set(filePath, 'package main\n\nfunc Typing() {}\n');
+    expect(await names()).toEqual(['Typing']);
+
+    overlay.delete(filePath);
+    expect(await names()).toEqual(['Saved']);
+    expect(analyzer.getStats()).toEqual({ hits: 0, misses: 3 });
+  });
+
+  it('should match relative and absolute paths and accept maps', async () => {
+    const relative = path.relative(process.cwd(), path.join(tempDir, 'buffer.go'));
+    const overlay = new GoOverlay(new Map([[relative, 'package main\n\ntype Buffer struct{}\n']]));
+
+    expect(overlay.has(path.join(tempDir, 'buffer.go'))).toBe(true);
+    expect(overlay.fileNames(tempDir)).toEqual(['buffer.go']);
+    expect(await overlay.readFile(path.join(tempDir, 'buffer.go'))).toContain('Buffer');
+    await expect(overlay.readFile(path.join(tempDir, 'missing.go'))).rejects.toThrow();
+  });
+
+  it('should load packages with overlaid and overlay-only files', async () => {
+    write('store.go', 'package store\n\ntype Store struct{}\n');
+    write('extra.go', 'package store\n\nfunc Extra() {}\n');
+
+    const pkg = await loadGoPackage(tempDir, {
+      overlay: {
+        [path.join(tempDir, 'extra.go')]: 'package store\n\nfunc Edited() {}\n',
+        [path.join(tempDir, 'new.go')]: 'package store\n\nfunc (s *Store) Added() {}\n',
+        [path.join(tempDir, 'linux_only_windows.go')]: 'package store\n\nfunc Windows() {}\n',
+      },
+      context: { goos: 'linux', goarch: 'amd64', tags: [], cgo: true },
+    });
+
+    expect(pkg.files.map(file => path.basename(file.filePath))).toEqual([
+      'extra.go',
+      'new.go',
+      'store.go',
+    ]);
+    expect(pkg.symbols.functions.map(fn => fn.name)).toEqual(['Edited']);
+    expect(pkg.symbols.types[0].methods.map(method => method.name)).toEqual(['Added']);
+
+    const unsaved = path.join(tempDir, 'unsaved');
+    const only = await loadGoPackage(unsaved, {
+      overlay: { [path.join(unsaved, 'a.go')]: 'package unsaved\n' },
+    });
+    expect(only.name).toBe('unsaved');
+    await expect(loadGoPackage(path.join(tempDir, 'missing'))).rejects.toThrow('ENOENT');
+  });
+
+  it('should discover overlay-only files under the same rules as files on disk', async () => {
+    write('.gitignore', 'generated.go\n');
+    write('pkg/a.go', 'package pkg\n');
+
+    const files = await discoverGoFiles(tempDir, {
+      overlay: {
+        [path.join(tempDir, 'pkg', 'b.go')]: 'package pkg\n',
+        [path.join(tempDir, 'pkg', 'a.go')]: 'package pkg\n\nfunc A() {}\n',
+        [path.join(tempDir, 'pkg', 'b_test.go')]: 'package pkg\n',
+        [path.join(tempDir, 'generated.go')]: 'package main\n',
+        [path.join(tempDir, 'vendor', 'dep.go')]: 'package dep\n',
+      },
+      includeTests: false,
+    });
+
+    expect(files.map(file => path.relative(tempDir, file))).toEqual([
+      path.join('pkg', 'a.go'),
+      path.join('pkg', 'b.go'),
+    ]);
+  });
+});
@@ -0,0 +1,161 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { goMethodSet } from '../src/go/symbols';
+import {
+  evaluateBuildExpr,
+  GoBuildContext,
+  GoPackageError,
+  groupGoPackages,
+  loadGoPackage,
+  matchFileName,
+  parseBuildConstraint,
+} from '../src/go/package';
+
+const linux: GoBuildContext = { goos: 'linux', goarch: 'amd64', tags: [], cgo: true };
+
+describe('Go package loading', () => {
+  let tempDir: string;
+
+  const write = (name: string, content: string) =>
+    fs.writeFileSync(path.join(tempDir, name), content);
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-package-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should attach methods to types declared in another file', async () => {
+    write('a.go', 'package store\n\ntype Store struct{ Cache }\n\ntype Cache struct{}\n');
+    write('b.go', 'package store\n\nfunc (s *Store) Put(key string) {}\n');
+    write('c.go', 'package store\n\nfunc (c Cache) Len() int { return 0 }\n');
+
+    const pkg = await loadGoPackage(tempDir, { context: linux });
+
+    expect(pkg.name).toBe('store');
+    expect(pkg.files.map((file) => path.basename(file.filePath))).toEqual([
+      'a.go',
+      'b.go',
+      'c.go'
+    ]);
+    const store = pkg.symbols.types.find((type) => type.name === 'Store');
+    expect(store?.methods.map((method) => method.name)).toEqual(['Put']);
+    expect(goMethodSet(pkg.symbols.types, 'Store', true).map((entry) => entry.name)).toEqual([
+      'Len',
+      'Put'
+    ]);
+    // The per-file table of a.go sees the methods too
+    const aTypes = pkg.symbols.files[0].types;
+    expect(aTypes.find((type) => type.name === 'Store')?.methods).toHaveLength(1);
+  });
+
+  it('should skip files excluded by build constraints and file name suffixes', async () => {
+    write('conn.go', 'package net\n\nfunc Dial() {}\n');
+    write('conn_linux.go', 'package net\n\nfunc platform() string { return "linux" }\n');
+    write('conn_windows.go', 'package net\n\nfunc platform() string { return "windows" }\n');
+    write('conn_darwin_arm64.go', 'package net\n\nfunc platform() string { return "darwin" }\n');
+    write('debug.go', '//go:build debug && !windows\n\npackage net\n\nfunc trace() {}\n');
+    write('legacy.go', '// +build ignore\n\npackage main\n\nfunc main() {}\n');
+    write('_scratch.go', 'not go at all');
+
+    const pkg = await loadGoPackage(tempDir, { context: linux });
+
+    expect(pkg.symbols.functions.map((fn) => fn.name).sort()).toEqual(['Dial', 'platform']);
+    expect(pkg.ignored.map((file) => path.basename(file.filePath))).toEqual([
+      'conn_darwin_arm64.go',
+      'conn_windows.go',
+      'debug.go',
+      'legacy.go'
+    ]);
+
+    const tagged = await loadGoPackage(tempDir, { context: { ...linux, tags: ['debug'] } });
+    expect(tagged.symbols.functions.map((fn) => fn.name)).toContain('trace');
+  });
+
+  it('should keep internal and external test files apart', async () => {
+    write('parse.go', 'package parse\n\nfunc Parse() {}\n');
+    write('parse_test.go', 'package parse\n\nimport "testing"\n\nfunc TestParse(t *testing.T) {}\n');
+    write('example_test.go', 'package parse_test\n\nfunc ExampleParse() {}\n');
+
+    const pkg = await loadGoPackage(tempDir, { context: linux });
+    expect(pkg.files).toHaveLength(1);
+    expect(pkg.testFiles.map((file) => path.basename(file.filePath))).toEqual(['parse_test.go']);
+    expect(pkg.externalTestFiles.map((file) => path.basename(file.filePath))).toEqual([
+      'example_test.go'
+    ]);
+    expect(pkg.symbols.functions.map((fn) => fn.name)).toEqual(['Parse']);
+
+    const withoutTests = await loadGoPackage(tempDir, { context: linux, includeTests: false });
+    expect(withoutTests.testFiles).toHaveLength(0);
+    expect(withoutTests.externalTestFiles).toHaveLength(0);
+  });
+
+  it('should reject directories mixing packages', async () => {
+    write('a.go', 'package a\n');
+    write('b.go', 'package b\n');
+
+    await expect(loadGoPackage(tempDir, { context: linux })).rejects.toThrow(GoPackageError);
+    await expect(loadGoPackage(tempDir, { context: linux })).rejects.toThrow(
+      'found packages a (a.go) and b (b.go)'
+    );
+  });
+
+  it('should group parsed files by directory and package clause', () => {
+    const files = [
+      parseGoFile('package a\n', '/repo/a/a.go'),
+      parseGoFile('package a_test\n', '/repo/a/a_test.go'),
+      parseGoFile('package a\n', '/repo/b/a.go'),
+      parseGoFile('package a\n', '/repo/a/b.go'),
+    ];
+    const paths = (groups: ReturnType<typeof groupGoPackages>) =>
+      groups.map(group => group.map(file => file.filePath));
+
+    expect(paths(groupGoPackages(files))).toEqual([
+      ['/repo/a/a.go', '/repo/a/b.go'],
+      ['/repo/a/a_test.go'],
+      ['/repo/b/a.go'],
+    ]);
+    expect(paths(groupGoPackages(files, { externalTests: true }))).toEqual([
+      ['/repo/a/a.go', '/repo/a/a_test.go', '/repo/a/b.go'],
+      ['/repo/b/a.go'],
+    ]);
+  });
+});
+
+describe('Go build constraints', () => {
+  it('should evaluate //go:build expressions', () => {
+    const matches = (line: string, context: GoBuildContext = linux) =>
+      evaluateBuildExpr(parseBuildConstraint(`${line}\n\npackage p\n`)!, context);
+
+    expect(matches('//go:build linux && (amd64 || arm64)')).toBe(true);
+    expect(matches('//go:build !linux')).toBe(false);
+    expect(matches('//go:build unix && cgo')).toBe(true);
+    expect(matches('//go:build linux', { ...linux, goos: 'android' })).toBe(true);
+    expect(matches('//go:build go1.21', { ...linux, goMinorVersion: 20 })).toBe(false);
+  });
+
+  it('should prefer //go:build over legacy +build lines', () => {
+    const source = '// +build windows\n//go:build linux\n\npackage p\n';
+    expect(evaluateBuildExpr(parseBuildConstraint(source)!, linux)).toBe(true);
+
+    const legacy = '// +build linux,386 darwin\n// +build !cgo\n\npackage p\n';
+    expect(evaluateBuildExpr(parseBuildConstraint(legacy)!, { ...linux, goarch: '386' })).toBe(
+      false
+    );
+    expect(
+      evaluateBuildExpr(parseBuildConstraint(legacy)!, { ...linux, goarch: '386', cgo: false })
+    ).toBe(true);
+  });
+
+  it('should ignore constraints after the package clause', () => {
+    expect(parseBuildConstraint('package p\n\n//go:build ignore\n')).toBeUndefined();
+    expect(matchFileName('conn_linux_test.go', linux)).toBe(true);
+    expect(matchFileName('conn_plan9.go', linux)).toBe(false);
+    expect(matchFileName('user_profile.go', linux)).toBe(true);
+  });
+});
@@ -0,0 +1,137 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { classifyPanics, findPanicsInsteadOfErrors } from '../src/go/panics';
+
+const configSource = `package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var pattern = regexp.MustCompile(\`^[a-z]+$\`)
+
+type Config struct{ Name string }
+
+func init() {
+	if pattern == nil {
+		panic("pattern failed to compile")
+	}
+}
+
+// Parse reads a config name
+func Parse(name string) *Config {
+	validate(name)
+	c := &Config{Name: name}
+	return c
+}
+
+func validate(name string) {
+	if name == "" {
+		panic("name must not be empty")
+	}
+	if !pattern.MatchString(name) {
+		panic(fmt.Sprintf("invalid name %q", name))
+	}
+}
+
+func (c *Config) Level(n int) (int, error) {
+	if n < 0 {
+		panic(errors.New("negative level"))
+	}
+	switch n {
+	case 0, 1:
+		return n, nil
+	}
+	panic("unreachable")
+}
+
+func MustLoad(name string) *Config {
+	c := Parse(name)
+	if c == nil {
+		panic("no config")
+	}
+	return c
+}
+
+func orphan() {
+	panic("never called")
+}
+
+func Recover() {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(r)
+		}
+	}()
+	var c *Config
+	total := c.Len() + 1
+	_ = total
+}
+
+func (c *Config) Len() int {
+	if c == nil {
+		panic(fmt.Errorf("nil config"))
+	}
+	return len(c.Name)
+}
+`;
+
+const files = () => [parseGoFile(configSource, 'config.go')];
+
+describe('Go panic checks', () => {
+  it('should classify recoverable and unrecoverable panics', () => {
+    expect(classifyPanics(files()).map(p => [p.line, p.function, p.recoverable, p.reason])).toEqual([
+      [15, 'init', false, 'panics during package initialization'],
+      [28, 'validate', true, 'panics with a message a caller could handle'],
+      [31, 'validate', true, 'panics with a message a caller could handle'],
+      [37, 'Config.Level', true, 'panics with an error a caller could handle'],
+      [43, 'Config.Level', false, 'the message describes a programming error'],
+      [49, 'MustLoad', false, 'Must functions panic by convention'],
+      [55, 'orphan', false, 'not reachable from an exported function'],
+      [71, 'Config.Len', true, 'panics with an error a caller could handle'],
+    ]);
+  });
+
+  it('should propose an error result and the replacement return', () => {
+    const [empty, invalid, level] = findPanicsInsteadOfErrors(files());
+
+    expect(empty).toMatchObject({
+      rule: 'panic-instead-of-error',
+      severity: 'low',
+      line: 28,
+      message:
+        'validate panics on a condition its callers could handle and is reachable from exported Parse; return an error instead',
+      fix: 'return errors.New("name must not be empty")',
+      signature: 'func validate(name string) error',
+    });
+    expect(invalid.fix).toBe('return fmt.Errorf("invalid name %q", name)');
+    // Functions that already return an error keep their signature
+    expect(level).toMatchObject({
+      severity: 'medium',
+      fix: 'return 0, errors.New("negative level")',
+      signature: 'func (c *Config) Level(n int) (int, error)',
+      callers: [],
+    });
+  });
+
+  it('should show the error check each caller needs', () => {
+    const findings = findPanicsInsteadOfErrors(files());
+    const len = findings.find(f => f.function === 'Config.Len')!;
+
+    expect(findings[0].callers).toEqual([
+      {
+        filePath: 'config.go',
+        line: 21,
+        column: 2,
+        function: 'Parse',
+        fix: 'if err := validate(name); err != nil {\n\t// handle err\n}',
+      },
+    ]);
+    expect(len.signature).toBe('func (c *Config) Len() (int, error)');
+    expect(len.callers.map(c => c.fix)).toEqual([
+      'v, err := c.Len()\nif err != nil {\n\t// handle err\n}\n// use v in place of the call',
+    ]);
+  });
+});
@@ -0,0 +1,109 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findWideSignatures, introduceParameterObject } from '../src/go/parameter-object';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package main
+
+import "fmt"
+
+// Connect dials a server
+func Connect(host string, port int, user, password string, timeout int, url string, tags ...string) string {
+	if port == 0 {
+		port = 80
+	}
+	return fmt.Sprint(host, port, user, password, timeout, url, tags)
+}
+
+func record(name string, size, count int, params []string, ok bool, note string) {
+	fmt.Println(name, size, count, params, ok, note)
+}
+
+func main() {
+	s := Connect("h", 1, "u", "p", 3, "x")
+	fmt.Println(s, Connect(Connect("a", 2, "b", "c", 4, "y", "t"), 1, "u", "p", 3, "x", "z"))
+	dial := Connect
+	_ = dial
+	record("n", 1, 2, nil, true, "")
+}
+`;
+
+const file = () => parseGoFile(source, 'main.go');
+
+describe('Go introduce parameter object', () => {
+  it('should flag functions with more parameters than the limit', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    expect(findWideSignatures([sample])).toEqual([]);
+
+    const findings = findWideSignatures([file()]);
+    expect(findings.map(finding => [finding.name, finding.line, finding.parameters])).toEqual([
+      ['Connect', 6, 7],
+      ['record', 13, 6],
+    ]);
+    expect(findings[0].message).toBe(
+      'Connect takes 7 parameters, more than 5; group them in a ConnectParams struct'
+    );
+    expect(findWideSignatures([file()], { maxParameters: 6 })).toHaveLength(1);
+  });
+
+  it('should group the parameters in order and keep the variadic one positional', () => {
+    const result = introduceParameterObject(file(), { name: 'Connect' });
+
+    expect(result.fields.map(field => field.field)).toEqual([
+      'Host',
+      'Port',
+      'User',
+      'Password',
+      'Timeout',
+      'URL',
+    ]);
+    expect(result.source).toContain(
+      [
+        '// ConnectParams holds the parameters of Connect',
+        'type ConnectParams struct {',
+        '\tHost     string',
+        '\tPort     int',
+        '\tUser     string',
+        '\tPassword string',
+        '\tTimeout  int',
+        '\tURL      string',
+        '}',
+        '',
+        '// Connect dials a server',
+        'func Connect(params ConnectParams, tags ...string) string {',
+        '\tif params.Port == 0 {',
+        '\t\tparams.Port = 80',
+      ].join('\n')
+    );
+  });
+
+  it('should rewrite nested calls and report references it cannot update', () => {
+    const result = introduceParameterObject(file(), { name: 'Connect' });
+
+    expect(result.source).toContain(
+      '\ts := Connect(ConnectParams{Host: "h", Port: 1, User: "u", Password: "p", Timeout: 3, URL: "x"})\n'
+    );
+    expect(result.source).toContain(
+      'Connect(ConnectParams{Host: Connect(ConnectParams{Host: "a", Port: 2, User: "b", Password: "c", Timeout: 4, URL: "y"}, "t"), Port: 1,'
+    );
+    expect(result.updated).toEqual([18, 19, 19]);
+    expect(result.skipped).toEqual([{ line: 20, reason: 'the function is used as a value' }]);
+  });
+
+  it('should keep unexported names and avoid clashing parameter names', () => {
+    const result = introduceParameterObject(file(), { name: 'record' });
+
+    expect(result.structName).toBe('recordParams');
+    expect(result.paramName).toBe('params2');
+    expect(result.source).toContain('func record(params2 recordParams) {');
+    expect(result.source).toContain('\trecord(recordParams{name: "n", size: 1, count: 2, params: nil');
+    expect(() =>
+      introduceParameterObject(file(), { name: 'record', structName: 'Connect' })
+    ).toThrow('Connect is already declared in the file');
+    expect(() => introduceParameterObject(file(), { name: 'main' })).toThrow(GoRefactorError);
+  });
+});
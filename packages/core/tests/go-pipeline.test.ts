@@ -0,0 +1,99 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { GoPipeline, GoPipelineError, goPipelineFromConfig, planGoPipeline } from '../src/go/pipeline';
+
+const source = `package shop
+
+import (
+	"strings"
+	"fmt"
+)
+
+func Total(prices []int) int {
+	sum := 0
+	for _, p := range prices {
+		sum += p
+	}
+	return sum
+}
+
+func Label(name string) string { return fmt.Sprint(strings.ToUpper(name)) }
+`;
+
+const files = () => [parseGoFile(source, '/repo/shop/shop.go')];
+
+describe('Go transform pipelines', () => {
+  it('feeds each stage the output of the one before', () => {
+    const pipeline = goPipelineFromConfig({
+      stages: [
+        { transform: 'rename', options: { name: 'Total', newName: 'Sum' } },
+        { transform: 'sort-imports' },
+        { transform: 'characterization-tests' },
+      ],
+    });
+    const result = pipeline.run(files());
+
+    expect(result.stages).toEqual([
+      { name: 'rename', files: ['/repo/shop/shop.go'] },
+      { name: 'sort-imports', files: ['/repo/shop/shop.go'] },
+      { name: 'characterization-tests', files: ['/repo/shop/shop_characterization_test.go'] },
+    ]);
+    const [shop, tests] = result.changes;
+    expect(shop).toMatchObject({ original: source, symbols: ['rename', 'sort-imports'] });
+    expect(shop.content).toContain('import (\n\t"fmt"\n\t"strings"\n)');
+    expect(shop.content).toContain('func Sum(prices []int) int');
+    expect(tests.original).toBeNull();
+    expect(tests.content).toContain('Sum(');
+  });
+
+  it('rejects orderings that break requires, after and conflicts', () => {
+    const stage = (name: string, extra = {}) => ({ name, run: () => [], ...extra });
+
+    expect(() => new GoPipeline([stage('b', { requires: ['a'] }), stage('a')])).toThrow(
+      'Stage b requires a to run before it'
+    );
+    expect(() => new GoPipeline([stage('b', { requires: ['a'] })])).toThrow('Stage b requires a');
+    expect(() => new GoPipeline([stage('b', { after: ['a'] })])).not.toThrow();
+    expect(() => new GoPipeline([stage('a', { conflicts: ['b'] }), stage('b')])).toThrow(
+      'Stage a conflicts with b'
+    );
+    expect(() => new GoPipeline([stage('a'), stage('a')])).toThrow('Stage a appears twice');
+    expect(() =>
+      goPipelineFromConfig({
+        stages: [
+          { transform: 'characterization-tests' },
+          { transform: 'rename', name: 'rename-total', options: { name: 'Total', newName: 'Sum' } },
+        ],
+      })
+    ).toThrow('Stage characterization-tests must run after rename');
+    expect(() => goPipelineFromConfig({ stages: [{ transform: 'reformat' }] })).toThrow(
+      GoPipelineError
+    );
+  });
+
+  it('fails the whole run, naming the stage, when a stage fails', () => {
+    const pipeline = goPipelineFromConfig({
+      stages: [
+        { transform: 'sort-imports' },
+        { transform: 'rename', options: { name: 'Missing', newName: 'Found' } },
+      ],
+    });
+
+    expect(() => pipeline.run(files())).toThrow(/^Stage rename failed: /);
+    expect(() =>
+      new GoPipeline([
+        { name: 'break', run: inputs => [{ filePath: inputs[0].filePath, source: 'package' }] },
+      ]).run(files())
+    ).toThrow('Stage break produced invalid Go in /repo/shop/shop.go');
+  });
+
+  it('plans every change to be written once', () => {
+    const pipeline = goPipelineFromConfig({ stages: [{ transform: 'sort-imports' }] });
+    const plan = planGoPipeline('/repo', pipeline, files());
+
+    expect(plan.summary.modified).toEqual(['shop/shop.go']);
+    expect(plan.files[0].symbols).toEqual(['sort-imports']);
+    const sorted = parseGoFile(plan.files[0].content, '/repo/shop/shop.go');
+    expect(planGoPipeline('/repo', pipeline, [sorted]).files).toEqual([]);
+  });
+});
@@ -0,0 +1,131 @@
+import { describe, it, expect } from '@jest/globals';
+import * as path from 'path';
+import { goFindingJson, streamGoFindings } from '../src/go/jsonl';
+import { defaultGoBuildContext } from '../src/go/package';
+import { parseGoFile } from '../src/go/parser';
+import {
+  analyzeGoPlatforms,
+  goPlatformContext,
+  goPlatformFiles,
+  GoPlatformError,
+  parseGoPlatforms,
+} from '../src/go/platforms';
+
+const root = path.join(path.sep, 'repo', 'conn');
+
+const common = `package conn
+
+import "os"
+
+func Reset() {
+	os.Remove("state")
+}
+`;
+
+const linux = `//go:build linux
+
+package conn
+
+func platform() string { return "linux" }
+`;
+
+const windows = `package conn
+
+import "os"
+
+func platform() string {
+	os.Remove("C:\\\\state")
+	return "windows"
+}
+`;
+
+const unix = `//go:build unix && !cgo
+
+package conn
+
+func pipe() {}
+`;
+
+const files = [
+  parseGoFile(common, path.join(root, 'conn.go')),
+  parseGoFile(linux, path.join(root, 'platform.go')),
+  parseGoFile(windows, path.join(root, 'platform_windows.go')),
+  parseGoFile(unix, path.join(root, 'pipe.go')),
+];
+
+const names = (selected: { filePath: string }[]) =>
+  selected.map((file) => path.basename(file.filePath));
+
+describe('Go platform matrix', () => {
+  it('parses a comma-separated matrix and rejects unknown targets', () => {
+    expect(parseGoPlatforms('linux/amd64, windows/amd64,linux/amd64')).toEqual([
+      { goos: 'linux', goarch: 'amd64' },
+      { goos: 'windows', goarch: 'amd64' },
+    ]);
+    expect(() => parseGoPlatforms('linux')).toThrow(GoPlatformError);
+    expect(() => parseGoPlatforms('beos/amd64')).toThrow('unknown operating system beos');
+    expect(() => parseGoPlatforms('linux/vax')).toThrow('unknown architecture vax');
+    expect(() => parseGoPlatforms(' , ')).toThrow('no target platform given');
+  });
+
+  it('selects the files each target builds', () => {
+    const linuxContext = goPlatformContext({ goos: 'linux', goarch: 'arm64' });
+    const windowsContext = goPlatformContext({ goos: 'windows', goarch: 'amd64' });
+
+    expect(names(goPlatformFiles(files, { ...linuxContext, cgo: false }))).toEqual([
+      'conn.go',
+      'platform.go',
+      'pipe.go',
+    ]);
+    expect(names(goPlatformFiles(files, { ...linuxContext, cgo: true }))).toEqual([
+      'conn.go',
+      'platform.go',
+    ]);
+    expect(names(goPlatformFiles(files, windowsContext))).toEqual([
+      'conn.go',
+      'platform_windows.go',
+    ]);
+  });
+
+  it('enables cgo only for the host platform unless told otherwise', () => {
+    const host = defaultGoBuildContext();
+    expect(goPlatformContext({ goos: host.goos, goarch: host.goarch }).cgo).toBe(true);
+    const other = host.goos === 'plan9' ? 'linux' : 'plan9';
+    expect(goPlatformContext({ goos: other, goarch: 'amd64' }).cgo).toBe(false);
+    expect(
+      goPlatformContext({ goos: other, goarch: 'amd64' }, { context: { cgo: true, tags: ['e2e'] } })
+    ).toMatchObject({ goos: other, cgo: true, tags: ['e2e'] });
+  });
+
+  it('checks each target and reports shared findings once', () => {
+    const analysis = analyzeGoPlatforms(files, parseGoPlatforms('linux/amd64,windows/amd64'));
+
+    expect(analysis.platforms.map((result) => result.platform)).toEqual([
+      'linux/amd64',
+      'windows/amd64',
+    ]);
+    expect(names(analysis.platforms[1].files)).toEqual(['conn.go', 'platform_windows.go']);
+    const ignored = analysis.findings.filter((finding) => finding.rule === 'ignored-error');
+    expect(ignored.map((finding) => [path.basename(finding.filePath), finding.platforms])).toEqual(
+      [
+        ['conn.go', ['linux/amd64', 'windows/amd64']],
+        ['platform_windows.go', ['windows/amd64']],
+      ]
+    );
+  });
+
+  it('streams merged findings with their targets', async () => {
+    const platforms = parseGoPlatforms('linux/amd64,windows/amd64');
+    const batches = [];
+    for await (const batch of streamGoFindings(files, { platforms })) batches.push(batch);
+
+    const windowsOnly = batches
+      .flatMap((batch) => batch.findings)
+      .find((finding) => finding.filePath.endsWith('platform_windows.go'));
+    expect(goFindingJson(windowsOnly, { root })).toMatchObject({
+      file: 'platform_windows.go',
+      rule: 'ignored-error',
+      platforms: ['windows/amd64'],
+    });
+  });
+});
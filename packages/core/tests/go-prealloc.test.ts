@@ -0,0 +1,111 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findMissingPreallocations, preallocateSlices } from '../src/go/prealloc';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package store
+
+type Item struct{ Name string }
+
+type Store struct{ items map[string]Item }
+
+func Names(items []Item) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func (s *Store) Keys() []string {
+	keys := make([]string, 0)
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func Squares(n int) []int {
+	squares := []int{}
+	for i := range n {
+		squares = append(squares, i*i)
+	}
+	return squares
+}
+
+func Long(items []Item) []string {
+	var names []string
+	for _, item := range items {
+		if len(item.Name) > 3 {
+			names = append(names, item.Name)
+		}
+	}
+	return names
+}
+
+func Early(items []Item) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+		if item.Name == "" {
+			break
+		}
+	}
+	return names
+}
+`;
+
+describe('Go slice preallocation', () => {
+  const file = parseGoFile(source, 'store.go');
+
+  it('flags slices appended to once per iteration with the make call', () => {
+    const findings = findMissingPreallocations([file]);
+
+    expect(findings.map(finding => [finding.line, finding.variable, finding.fix])).toEqual([
+      [8, 'names', 'make([]string, 0, len(items))'],
+      [16, 'keys', 'make([]string, 0, len(s.items))'],
+      [24, 'squares', 'make([]int, 0, n)'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'slice-prealloc',
+      severity: 'low',
+      loopLine: 9,
+      message:
+        'names grows by one element per iteration of the loop on line 9; preallocate it with make([]string, 0, len(items))',
+    });
+  });
+
+  it('leaves conditional appends, early exits and the sample alone', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const lines = findMissingPreallocations([file]).map(finding => finding.line);
+
+    expect(lines).not.toContain(32);
+    expect(lines).not.toContain(42);
+    expect(findMissingPreallocations([sample])).toEqual([]);
+    expect(defaultGoRuleRegistry().run([file]).filter(f => f.rule === 'slice-prealloc')).toHaveLength(3);
+  });
+
+  it('needs the ranged value in scope at the declaration', () => {
+    const late = parseGoFile(
+      'package p\n\nfunc F(load func() []int) []int {\n\tvar out []int\n\tvalues := load()\n\tfor _, v := range values {\n\t\tout = append(out, v)\n\t}\n\treturn out\n}\n',
+      'late.go'
+    );
+
+    expect(findMissingPreallocations([late])).toEqual([]);
+  });
+
+  it('rewrites declarations to preallocate', () => {
+    const result = preallocateSlices(file, { line: 8 });
+
+    expect(result.variables).toEqual(['names']);
+    expect(result.source).toContain('\tnames := make([]string, 0, len(items))\n\tfor _, item := range items {');
+    expect(preallocateSlices(file).source).toContain('\tsquares := make([]int, 0, n)\n');
+    expect(() => preallocateSlices(file, { line: 32 })).toThrow(
+      'Line 32 declares no slice appended to once per loop iteration'
+    );
+  });
+});
@@ -0,0 +1,110 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseCoverProfile } from '../src/go/coverage';
+import { parseGoFile } from '../src/go/parser';
+import {
+  DEFAULT_GO_PRIORITY_WEIGHTS,
+  formatGoRefactorPriorities,
+  goRefactorPriorities,
+  GoPriorityError,
+  parseGoPriorityWeights,
+} from '../src/go/priority';
+
+const fixture = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+describe('Go refactor priorities', () => {
+  const sample = parseGoFile(fs.readFileSync(fixture, 'utf-8'), fixture);
+
+  it('ranks ProcessComplexData above the trivial methods of the fixture', () => {
+    const items = goRefactorPriorities([sample]);
+    const names = items.map((item) => item.name);
+
+    expect(names[0]).toBe('main.ProcessComplexData');
+    expect(names.indexOf('main.DataProcessor.GetCacheSize')).toBeGreaterThan(0);
+    expect(items[0]).toMatchObject({ filePath: fixture, line: 62, score: 0.441 });
+    expect(items[0].factors).toEqual([
+      {
+        name: 'complexity',
+        value: 0.36,
+        weight: 3,
+        reason: 'cyclomatic complexity 6, cognitive complexity 4',
+      },
+      { name: 'coverage', value: 1, weight: 2, reason: 'no test reaches it' },
+      { name: 'fanIn', value: 0, weight: 1, reason: '0 caller(s)' },
+      { name: 'effort', value: 0.28, weight: 0.5, reason: '17 line(s) of code' },
+    ]);
+  });
+
+  it('breaks ties by symbol name', () => {
+    const items = goRefactorPriorities([sample]);
+    const tied = items.filter((item) => item.score === items.at(-2).score);
+
+    expect(tied.map((item) => item.name)).toEqual([
+      'main.DataProcessor.GetCacheSize',
+      'main.privateHelper',
+    ]);
+  });
+
+  it('takes weights, a coverage profile and tests that reach functions', () => {
+    const source = `package calc
+
+func Sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+
+func Zero() int {
+	return 0
+}
+`;
+    const calc = parseGoFile(source, 'calc/calc.go');
+    const test = parseGoFile(
+      'package calc\n\nimport "testing"\n\nfunc TestZero(t *testing.T) {\n\tZero()\n}\n',
+      'calc/calc_test.go'
+    );
+    const names = (items: { name: string }[]) => items.map((item) => item.name);
+
+    const tested = goRefactorPriorities([calc, test]);
+    expect(names(tested)).toEqual(['calc.Sum', 'calc.Zero']);
+    expect(tested[1].factors[1]).toMatchObject({ value: 0.25, reason: 'called by TestZero' });
+
+    const byCoverage = goRefactorPriorities([calc, test], {
+      weights: { complexity: 0 },
+      coverage: parseCoverProfile('mode: set\ncalc/calc.go:3.27,10.14 4 1\ncalc/calc.go:13.18,15.2 1 0\n'),
+    });
+    expect(names(byCoverage)).toEqual(['calc.Zero', 'calc.Sum']);
+    expect(byCoverage[1].factors[1]).toMatchObject({ value: 0, reason: '100% of lines covered' });
+    expect(goRefactorPriorities([calc], { limit: 1 })).toHaveLength(1);
+  });
+
+  it('parses weights and rejects invalid ones', () => {
+    expect(parseGoPriorityWeights('complexity=2, effort=0')).toEqual({ complexity: 2, effort: 0 });
+    expect(() => parseGoPriorityWeights('size=1')).toThrow(GoPriorityError);
+    expect(() => parseGoPriorityWeights('fanIn=many')).toThrow('Weight of fanIn must be a number');
+    expect(() => goRefactorPriorities([sample], { weights: { effort: 2 } })).toThrow(
+      'Weight of effort must be at most 1, not 2'
+    );
+    expect(DEFAULT_GO_PRIORITY_WEIGHTS).toEqual({ complexity: 3, coverage: 2, fanIn: 1, effort: 0.5 });
+  });
+
+  it('formats each item with its factor breakdown', () => {
+    const [first] = goRefactorPriorities([sample], { limit: 1 });
+
+    expect(formatGoRefactorPriorities([first], { root: path.dirname(fixture) })).toBe(
+      [
+        '1. main.ProcessComplexData (sample.go:62): priority 0.441',
+        '   complexity 0.36 x3: cyclomatic complexity 6, cognitive complexity 4',
+        '   coverage 1.00 x2: no test reaches it',
+        '   fanIn 0.00 x1: 0 caller(s)',
+        '   effort 0.28 x0.5: 17 line(s) of code',
+        '',
+      ].join('\n')
+    );
+  });
+});
@@ -0,0 +1,121 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { gunzipSync } from 'zlib';
+import { analyzeGo } from '../src/go/analyze';
+import { formatGoProfileSummary, goPprofProfile, GoProfiler } from '../src/go/profile';
+
+// A clock advancing by the given steps, one per reading
+function steps(...readings: number[]): () => number {
+  let now = 0;
+  return () => (now += readings.shift() ?? 0);
+}
+
+describe('Go profiling', () => {
+  let tempDir: string;
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-profile-'));
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('sums phases over their runs and per file, slowest first', () => {
+    const profiler = new GoProfiler({ clock: steps(0, 0, 5, 0, 20, 0, 1, 4) });
+    profiler.measure('parse', () => 1, 'a.go');
+    profiler.measure('parse', () => 2, 'b.go');
+    profiler.measure('symbols', () => 3, 'a.go');
+    profiler.count('cache hits');
+    profiler.count('cache hits', 2);
+
+    expect(profiler.summary()).toEqual({
+      wallMs: 30,
+      phases: [
+        { name: 'parse', totalMs: 25, count: 2 },
+        { name: 'symbols', totalMs: 1, count: 1 },
+      ],
+      files: [
+        { filePath: 'b.go', totalMs: 20, phases: [{ name: 'parse', totalMs: 20 }] },
+        {
+          filePath: 'a.go',
+          totalMs: 6,
+          phases: [
+            { name: 'parse', totalMs: 5 },
+            { name: 'symbols', totalMs: 1 },
+          ],
+        },
+      ],
+      counters: { 'cache hits': 3 },
+    });
+  });
+
+  it('records the time of a phase that throws', async () => {
+    const profiler = new GoProfiler({ clock: steps(0, 0, 2, 0, 3) });
+
+    expect(() =>
+      profiler.measure('parse', () => {
+        throw new Error('bad');
+      })
+    ).toThrow('bad');
+    await expect(profiler.measureAsync('discover', async () => Promise.reject(new Error('gone')))).rejects.toThrow(
+      'gone'
+    );
+    expect(profiler.summary().phases).toEqual([
+      { name: 'discover', totalMs: 3, count: 1 },
+      { name: 'parse', totalMs: 2, count: 1 },
+    ]);
+  });
+
+  it('formats the phases, slowest files and counters', () => {
+    const profiler = new GoProfiler({ clock: steps(0, 0, 30, 0, 10, 60) });
+    profiler.measure('parse', () => undefined, path.join(tempDir, 'pkg', 'slow.go'));
+    profiler.measure('rule ignored-error', () => undefined);
+    profiler.count('cache misses', 4);
+
+    expect(formatGoProfileSummary(profiler.summary(), { root: tempDir })).toBe(
+      [
+        'Wall time: 100.0 ms',
+        'Phases:',
+        '  parse                  30.0 ms   30.0%  1 run(s)',
+        '  rule ignored-error     10.0 ms   10.0%  1 run(s)',
+        'Slowest files:',
+        '  pkg/slow.go  30.0 ms (parse 30.0)',
+        'Counters:',
+        '  cache misses: 4',
+        '',
+      ].join('\n')
+    );
+  });
+
+  it('encodes a gzipped pprof profile with the phases and files as frames', () => {
+    const profiler = new GoProfiler({ clock: steps(0, 0, 2, 0, 3) });
+    profiler.measure('parse', () => undefined, 'main.go');
+    profiler.measure('call graph', () => undefined);
+
+    const profile = gunzipSync(goPprofProfile(profiler.summary()));
+    const text = profile.toString('latin1');
+
+    for (const name of ['refactogent', 'parse', 'call graph', 'main.go', 'wall', 'nanoseconds']) {
+      expect(text).toContain(name);
+    }
+    // The string table starts with the empty string
+    expect(text.indexOf('\x32\x00')).toBeGreaterThan(0);
+  });
+
+  it('profiles every phase of analyzeGo', async () => {
+    fs.writeFileSync(path.join(tempDir, 'main.go'), 'package main\n\nfunc main() {}\n');
+    const profiler = new GoProfiler();
+
+    await analyzeGo(tempDir, { profiler });
+    const summary = profiler.summary();
+    const phases = summary.phases.map((phase) => phase.name);
+
+    for (const phase of ['discover', 'parse', 'symbols', 'call graph', 'suppressions', 'rule ignored-error']) {
+      expect(phases).toContain(phase);
+    }
+    expect(summary.files.map((file) => file.filePath)).toEqual([path.join(tempDir, 'main.go')]);
+  });
+});
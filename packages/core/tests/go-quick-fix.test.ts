@@ -0,0 +1,133 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { applyGoQuickFixes, goQuickFixRules } from '../src/go/quick-fix';
+import { GoRefactorError } from '../src/go/refactor';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const parse = (source: string, name = 'p.go') => parseGoFile(source, `/src/p/${name}`);
+
+const source = `package p
+
+import "errors"
+
+var ErrMissing = errors.New("Missing value.")
+
+func valid(a, b *int) bool {
+	if a != nil {
+		if b != nil {
+			if *a > *b {
+				return true
+			}
+			return false
+		}
+		return false
+	}
+	return false
+}
+
+func check(err error) bool {
+	if err == ErrMissing {
+		return errors.New("Not found.") != nil
+	}
+	return err == ErrMissing
+}
+`;
+
+describe('Go quick fixes by rule', () => {
+  it('applies only the fixes of the chosen rule', () => {
+    const file = parse(source);
+    const findings = defaultGoRuleRegistry().run([file]);
+    const result = applyGoQuickFixes([file], findings, { rules: ['error-string-style'] });
+
+    expect(result.applied.map(({ rule, line }) => [rule, line])).toEqual([
+      ['error-string-style', 5],
+      ['error-string-style', 22],
+    ]);
+    expect(result.skipped).toEqual([]);
+    expect(result.changes).toHaveLength(1);
+    expect(result.changes[0]).toMatchObject({
+      filePath: '/src/p/p.go',
+      original: source,
+      symbols: ['error-string-style'],
+    });
+    expect(result.changes[0].content).toBe(
+      source.replace('"Missing value."', '"missing value"').replace('"Not found."', '"not found"'),
+    );
+  });
+
+  it('combines the fixes of several rules bottom-up and skips the ones that overlap', () => {
+    const file = parse(source);
+    const findings = defaultGoRuleRegistry().run([file]);
+    const result = applyGoQuickFixes([file], findings, {
+      rules: ['error-comparison', 'error-string-style', 'nested-conditional', 'redundant-bool-return'],
+    });
+
+    expect(result.applied.map(({ rule, line }) => [rule, line])).toEqual([
+      ['error-string-style', 5],
+      ['redundant-bool-return', 10],
+      ['error-comparison', 21],
+      ['error-string-style', 22],
+      ['error-comparison', 24],
+    ]);
+    expect(result.skipped.map(({ finding, reason }) => [finding.rule, finding.line, reason])).toEqual([
+      ['nested-conditional', 8, 'conflicts with the fix for redundant-bool-return on line 10'],
+    ]);
+    const content = result.changes[0].content;
+    expect(content).toContain('\t\tif b != nil {\n\t\t\treturn *a > *b\n\t\t}\n');
+    expect(content).toContain('\tif errors.Is(err, ErrMissing) {\n\t\treturn errors.New("not found") != nil\n');
+    expect(content).toContain('\treturn errors.Is(err, ErrMissing)\n');
+    expect(() => parse(content)).not.toThrow();
+  });
+
+  it('makes identical edits of different fixes once', () => {
+    const file = parse(`package p
+
+var errClosed error
+
+func closed(err error) bool { return err == errClosed }
+
+func open(err error) bool { return err != errClosed }
+`);
+    const findings = defaultGoRuleRegistry().run([file]);
+    const result = applyGoQuickFixes([file], findings, { rules: ['error-comparison'] });
+
+    expect(result.applied.map(({ line }) => line)).toEqual([5, 7]);
+    const content = result.changes[0].content;
+    expect(content.match(/import "errors"/g)).toEqual(['import "errors"']);
+    expect(content).toContain('return errors.Is(err, errClosed)');
+    expect(content).toContain('return !errors.Is(err, errClosed)');
+  });
+
+  it('skips findings the code no longer has, and those of files not given', () => {
+    const file = parse(source);
+    const [stale] = defaultGoRuleRegistry()
+      .run([file])
+      .filter(({ rule }) => rule === 'error-string-style');
+    const elsewhere = { ...stale, filePath: '/src/q/q.go' };
+    const fixed = parse(source.replace('"Missing value."', '"missing value"'));
+    const result = applyGoQuickFixes([fixed], [stale, elsewhere], { rules: ['error-string-style'] });
+
+    expect(result.changes).toEqual([]);
+    expect(result.skipped.map(({ finding, reason }) => [finding.filePath, reason])).toEqual([
+      ['/src/p/p.go', 'Line 5 has no error string to restyle'],
+      ['/src/q/q.go', 'the file was not analyzed'],
+    ]);
+  });
+
+  it('follows the rule options and refuses rules without a quick fix', () => {
+    const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const file = parse('package p\n\nimport "errors"\n\nvar ErrCard = errors.New("Stripe declined.")\n');
+    const findings = defaultGoRuleRegistry().run([file]);
+    const result = applyGoQuickFixes([file], findings, {
+      rules: ['error-string-style'],
+      ruleOptions: { 'error-string-style': { capitalizedWords: ['Stripe'] } },
+    });
+
+    expect(result.changes[0].content).toContain('errors.New("Stripe declined")');
+    expect(goQuickFixRules()).toContain('slice-prealloc');
+    expect(() => applyGoQuickFixes([sample], [], { rules: ['todo-comment'] })).toThrow(GoRefactorError);
+  });
+});
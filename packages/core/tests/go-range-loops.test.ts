@@ -0,0 +1,127 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findIndexLoops } from '../src/go/range-loops';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const read = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+const parse = (source: string) => parseGoFile(source, '/src/p/p.go');
+
+describe('Go index loops', () => {
+  it('leaves the fixtures alone, whose loops do not index a collection', () => {
+    expect(findIndexLoops([read('sample.go'), read('constants.go')])).toEqual([]);
+  });
+
+  it('suggests ranging over values when the elements are only read', () => {
+    const source = `package p
+
+type Order struct{ items []string }
+
+func (o *Order) Print() {
+	for i := 0; i < len(o.items); i++ {
+		println(o.items[i], len(o.items[i]))
+	}
+}
+`;
+    const findings = findIndexLoops([parse(source)]);
+
+    expect(findings).toHaveLength(1);
+    expect(findings[0]).toMatchObject({
+      rule: 'index-loop',
+      severity: 'low',
+      line: 6,
+      column: 2,
+      index: 'i',
+      collection: 'o.items',
+      value: 'item',
+      message: 'Loop uses i only to read o.items[i]; write it as for _, item := range o.items',
+      fix: 'for _, item := range o.items {\n\t\tprintln(item, len(item))\n\t}',
+    });
+  });
+
+  it('keeps the index when elements are written, and avoids names in use', () => {
+    const source = `package p
+
+func scale(values []float64, factor float64) {
+	for i := 0; i < len(values); i++ {
+		values[i] *= factor
+	}
+}
+
+func sum(vs []int) (v int) {
+	for i := 0; i < len(vs); i++ {
+		v += vs[i]
+	}
+	return v
+}
+`;
+    const findings = findIndexLoops([parse(source)]);
+
+    expect(findings.map(({ line, fix, value }) => [line, fix, value])).toEqual([
+      [4, 'for i := range values {\n\t\tvalues[i] *= factor\n\t}', undefined],
+      [10, 'for _, v2 := range vs {\n\t\tv += v2\n\t}', 'v2'],
+    ]);
+  });
+
+  it('does not flag subranges, other steps or loops using the index itself', () => {
+    const source = `package p
+
+func loops(s []int, t []int) {
+	for i := 1; i < len(s); i++ {
+		println(s[i])
+	}
+	for i := 0; i < len(s)-1; i++ {
+		println(s[i])
+	}
+	for i := 0; i <= len(s); i++ {
+		println(s[i])
+	}
+	for i := 0; i < len(s); i += 2 {
+		println(s[i])
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0 {
+			i++
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		println(i, s[i])
+	}
+	for i := 0; i < len(s); i++ {
+		println(t[i])
+	}
+	for i := 0; i < len(s); i++ {
+		s = append(s, s[i])
+	}
+}
+`;
+    expect(findIndexLoops([parse(source)])).toEqual([]);
+  });
+
+  it('skips maps, strings and channels, whose range differs from indexing', () => {
+    const source = `package p
+
+type Names map[int]string
+
+func loops(m map[int]string, n Names, s string, c chan int) {
+	for i := 0; i < len(m); i++ {
+		println(m[i])
+	}
+	for i := 0; i < len(n); i++ {
+		println(n[i])
+	}
+	for i := 0; i < len(s); i++ {
+		println(s[i])
+	}
+	for i := 0; i < len(c); i++ {
+		println(i)
+	}
+}
+`;
+    expect(findIndexLoops([parse(source)])).toEqual([]);
+  });
+});
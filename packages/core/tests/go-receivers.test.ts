@@ -0,0 +1,165 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findInconsistentReceivers, findMixedReceivers, renameReceivers } from '../src/go/receivers';
+import { GoRefactorError } from '../src/go/refactor';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package store
+
+import "fmt"
+
+type Store struct{ items map[string]int }
+
+func (s *Store) Get(key string) int { return s.items[key] }
+
+func (st *Store) Put(key string, value int) {
+	st.items[key] = value
+}
+
+func (s *Store) Len() int { return len(s.items) }
+
+func (x Store) String() string {
+	return fmt.Sprint(len(x.items), Store{items: x.items}.items)
+}
+
+func (*Store) Reset() {}
+
+type Single struct{}
+
+func (one Single) Only() {}
+`;
+
+describe('Go receiver names', () => {
+  const file = parseGoFile(source, '/src/store/store.go');
+
+  it('flags receivers that differ from the most common name', () => {
+    const findings = findInconsistentReceivers([file]);
+
+    expect(findings.map(finding => [finding.method, finding.receiver, finding.suggested])).toEqual([
+      ['Put', 'st', 's'],
+      ['String', 'x', 's'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'inconsistent-receiver',
+      severity: 'low',
+      type: 'Store',
+      line: 9,
+      column: 7,
+      fix: 's',
+    });
+  });
+
+  it('passes consistent and single-method types', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+    expect(findInconsistentReceivers([sample])).toEqual([]);
+    expect(findInconsistentReceivers([file]).map(finding => finding.type)).not.toContain('Single');
+  });
+
+  it('renames receivers and their uses across the methods of a type', () => {
+    const result = renameReceivers([file], { type: 'Store' });
+
+    expect(result.name).toBe('s');
+    expect(result.methods).toEqual(['Put', 'String']);
+    expect(result.files[0].source).toContain('func (s *Store) Put(key string, value int) {\n\ts.items[key] = value');
+    expect(result.files[0].source).toContain(
+      'func (s Store) String() string {\n\treturn fmt.Sprint(len(s.items), Store{items: s.items}.items)'
+    );
+    expect(result.files[0].source).toContain('func (*Store) Reset() {}');
+    expect(findInconsistentReceivers([parseGoFile(result.files[0].source, file.filePath)])).toEqual([]);
+  });
+
+  it('refuses names that would clash inside a method', () => {
+    expect(() => renameReceivers([file], { type: 'Store', name: 'key' })).toThrow(
+      'Cannot rename the receiver: Get already declares key'
+    );
+    expect(() => renameReceivers([file], { type: 'Store', name: 'fmt' })).toThrow(
+      'String uses fmt from outside the method'
+    );
+    expect(() => renameReceivers([file], { type: 'Missing' })).toThrow(GoRefactorError);
+  });
+});
+
+const mixed = `package geo
+
+import "sync"
+
+type Shape interface {
+	Area() float64
+}
+
+type Point struct{ X, Y float64 }
+
+func (p *Point) Move(dx, dy float64) {
+	p.X += dx
+	p.Y += dy
+}
+
+func (p Point) Dist() float64 { return p.X*p.X + p.Y*p.Y }
+
+func (p Point) String() string { return "point" }
+
+type Square struct{ side float64 }
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+func (s *Square) Side() float64 { return s.side }
+
+func (s Square) Grow() { s.side *= 2 }
+
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *Counter) Value() int { return c.n }
+
+func (c Counter) Inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+}
+
+type Span struct{ a, b int }
+
+func (s Span) Len() int { return s.b - s.a }
+
+func (s Span) Empty() bool { return s.a == s.b }
+
+func (s *Span) Mid() int { return (s.a + s.b) / 2 }
+`;
+
+describe('Go receiver kinds', () => {
+  const findings = findMixedReceivers([parseGoFile(mixed, '/src/geo/geo.go')]);
+
+  it('suggests pointer receivers when a method modifies the receiver', () => {
+    const point = findings.filter(finding => finding.type === 'Point');
+
+    expect(point.map(finding => [finding.method, finding.receiver, finding.suggested])).toEqual([
+      ['Dist', 'value', 'pointer'],
+    ]);
+    expect(point[0]).toMatchObject({ rule: 'mixed-receiver-kind', line: 16, column: 9, kept: ['String'] });
+    expect(point[0].message).toBe(
+      'Point.Dist has a value receiver unlike the other methods of Point; give every method a pointer receiver, since Move modifies the receiver; String keeps its value receiver so values of Point still satisfy fmt.Stringer'
+    );
+  });
+
+  it('explains value methods that only modify a copy, and locks', () => {
+    const byMethod = new Map(findings.map(finding => [`${finding.type}.${finding.method}`, finding]));
+
+    expect(byMethod.get('Square.Grow').message).toContain('since Grow modifies the receiver, which a value receiver only does to a copy');
+    expect(byMethod.get('Square.Grow').kept).toEqual(['Area']);
+    expect(byMethod.has('Square.Area')).toBe(false);
+    expect(byMethod.get('Counter.Inc').message).toContain('since Inc modifies the receiver');
+  });
+
+  it('suggests value receivers when most methods take values and none modifies', () => {
+    const span = findings.filter(finding => finding.type === 'Span');
+
+    expect(span.map(finding => [finding.method, finding.receiver, finding.suggested])).toEqual([['Mid', 'pointer', 'value']]);
+    expect(span[0].message).toContain('since no method modifies the receiver, and 2 of Span\'s methods take values');
+    expect(findMixedReceivers([parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)])).toEqual([]);
+  });
+});
@@ -0,0 +1,163 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { analyzeGo } from '../src/go/analyze';
+import { GoSyntaxError } from '../src/go/lexer';
+import { parseGoFile } from '../src/go/parser';
+import { parseGoSymbolsJson, serializeGoSymbols } from '../src/go/serialize';
+import { extractGoFileSymbols } from '../src/go/symbols';
+
+const midEdit = `package shop
+
+func Total(prices []int) int {
+	sum := 0
+	for _, p := range prices {
+		sum += p *
+	}
+	return sum
+}
+
+func Count(prices []int) int { return len(prices) }
+`;
+
+const unclosed = `package shop
+
+type Cart struct{ items []string }
+
+func (c *Cart) Add(item string) {
+	if item != "" {
+		c.items = append(c.items, item)
+
+
+func (c *Cart) Len() int { return len(c.items) }
+`;
+
+const decls = (source: string) =>
+  parseGoFile(source, '/shop/cart.go', { recover: true }).decls.map((decl) =>
+    decl.kind === 'FuncDecl' ? decl.name.name : decl.kind
+  );
+
+describe('Go syntax error recovery', () => {
+  it('skips a broken statement and keeps the rest of the function', () => {
+    const file = parseGoFile(midEdit, '/shop/total.go', { recover: true });
+    const total = file.decls[0];
+
+    expect(file.syntaxErrors.map((error) => error.reason)).toEqual(["expected operand, found '}'"]);
+    expect(total.kind === 'FuncDecl' && total.body.list.map((stmt) => stmt.kind)).toEqual([
+      'AssignStmt',
+      'RangeStmt',
+      'ReturnStmt',
+    ]);
+    const symbols = extractGoFileSymbols(file);
+    expect(symbols.functions.map(({ name, partial }) => ({ name, partial }))).toEqual([
+      { name: 'Total', partial: true },
+      { name: 'Count', partial: undefined },
+    ]);
+    expect(symbols.syntaxErrors).toEqual([{ message: "expected operand, found '}'", line: 7, column: 2 }]);
+    expect(() => parseGoFile(midEdit, '/shop/total.go')).toThrow(GoSyntaxError);
+  });
+
+  it('closes an unclosed body before the next declaration', () => {
+    const file = parseGoFile(unclosed, '/shop/cart.go', { recover: true });
+
+    expect(decls(unclosed)).toEqual(['GenDecl', 'Add', 'Len']);
+    expect(file.syntaxErrors).toHaveLength(1);
+    expect(file.syntaxErrors[0]).toMatchObject({ line: 7, column: 34 });
+    expect(file.syntaxErrors[0].reason).toBe("missing '}' for the '{' on line 6");
+    expect(file.syntaxErrors[0].message).toBe("7:34: missing '}' for the '{' on line 6");
+    const { methods, types } = extractGoFileSymbols(file);
+    expect(methods.map(({ name, partial, endLine }) => ({ name, partial, endLine }))).toEqual([
+      { name: 'Add', partial: true, endLine: 7 },
+      { name: 'Len', partial: undefined, endLine: 10 },
+    ]);
+    expect(types[0].partial).toBeUndefined();
+  });
+
+  it('lists declarations that do not parse apart from the ones that do', () => {
+    const source = 'package shop\n\nx := 1\n\nfunc (c *Cart) Empty( {\n}\n\nvar Limit = 10\n';
+    const file = parseGoFile(source, '/shop/cart.go', { recover: true });
+
+    expect(decls(source)).toEqual(['GenDecl']);
+    expect(file.badDecls.map((decl) => source.slice(decl.pos, decl.end))).toEqual([
+      'x := 1',
+      'func (c *Cart) Empty( {\n}',
+    ]);
+    expect(file.syntaxErrors.map((error) => error.reason)).toEqual([
+      "non-declaration statement outside function body: 'x'",
+      "expected type, found '{'",
+    ]);
+    expect(() => parseGoFile('func F() {}\n', '/shop/cart.go', { recover: true })).toThrow(
+      "1:1: expected 'package', found 'func'"
+    );
+  });
+
+  it('reports malformed literals and characters once per line, at their offsets', () => {
+    const source = 'package shop\n\nvar name = "cart\nvar sign = 1 € 2 @ 3\n\nfunc Name() string { return name }\n/* unfinished\n';
+    const file = parseGoFile(source, '/shop/cart.go', { recover: true });
+
+    expect(file.syntaxErrors.map(({ line, column, reason }) => [line, column, reason])).toEqual([
+      [3, 12, 'string literal not terminated'],
+      [4, 14, 'invalid character "€"'],
+      [7, 1, 'comment not terminated'],
+    ]);
+    expect(file.source).toBe(source);
+    expect(decls(source)).toEqual(['GenDecl', 'Name']);
+    expect(file.sourceMap.position(file.decls[1].pos)).toEqual({ line: 6, column: 1 });
+  });
+
+  it('reports nesting deeper than the parser goes as a syntax error', () => {
+    const deep = (n: number) =>
+      `package shop\n\nvar total = ${'('.repeat(n)}1${')'.repeat(n)}\n\nfunc Name() string {\n\t${'{'.repeat(n)}${'}'.repeat(n)}\n\treturn name\n}\n\nvar name = "cart"\n`;
+
+    expect(parseGoFile(deep(500), '/shop/cart.go', { recover: true }).syntaxErrors).toEqual([]);
+    const file = parseGoFile(deep(100000), '/shop/cart.go', { recover: true });
+    expect(file.syntaxErrors.map((error) => [error.line, error.reason])).toEqual([
+      [3, 'exceeded max nesting depth'],
+      [6, 'exceeded max nesting depth'],
+    ]);
+    expect(file.badDecls).toHaveLength(2);
+    expect(decls(deep(100000))).toEqual(['GenDecl']);
+    expect(() => parseGoFile(deep(100000), '/shop/cart.go')).toThrow(GoSyntaxError);
+  });
+
+  describe('analyzeGo', () => {
+    let tempDir: string;
+
+    beforeEach(() => {
+      tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-recovery-'));
+      fs.writeFileSync(path.join(tempDir, 'store.go'), 'package store\n\nimport "os"\n\nfunc Wipe() {\n\tos.Remove("a")\n}\n');
+    });
+
+    afterEach(() => {
+      fs.rmSync(tempDir, { recursive: true, force: true });
+    });
+
+    it('analyzes broken files as far as they parse', async () => {
+      const overlay = {
+        [path.join(tempDir, 'draft.go')]:
+          'package store\n\nimport "os"\n\nfunc Save() {\n\tos.Remove("b")\n}\n\nfunc Draft() {\n\tos.Remove("c")\n\tx :=\n}\n',
+      };
+      await expect(analyzeGo(tempDir, { overlay })).rejects.toThrow(GoSyntaxError);
+
+      const result = await analyzeGo(tempDir, { overlay, recover: true });
+      const [draft, store] = result.files;
+      expect(draft.syntax_errors).toEqual([{ message: "expected operand, found '}'", line: 12, column: 1 }]);
+      expect(draft.functions.map((fn) => [fn.name, fn.partial])).toEqual([
+        ['Save', undefined],
+        ['Draft', true],
+      ]);
+      expect(store.syntax_errors).toBeUndefined();
+      expect(result.findings.filter((finding) => finding.rule === 'ignored-error').map((f) => [f.file, f.line])).toEqual([
+        ['draft.go', 6],
+        ['store.go', 6],
+      ]);
+      expect(result.call_graph.nodes.map((node) => node.id)).toEqual(['store.Draft', 'store.Save', 'store.Wipe']);
+
+      const symbols = extractGoFileSymbols(
+        parseGoFile(overlay[path.join(tempDir, 'draft.go')], path.join(tempDir, 'draft.go'), { recover: true })
+      );
+      expect(parseGoSymbolsJson(serializeGoSymbols([symbols], tempDir), tempDir)).toEqual([symbols]);
+    });
+  });
+});
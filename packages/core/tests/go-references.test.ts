@@ -0,0 +1,129 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { indexGoReferences } from '../src/go/references';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const read = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+const parse = (source: string, name = 'p.go') => parseGoFile(source, `/src/p/${name}`);
+
+describe('Go reference index', () => {
+  it('finds the definition, constructor result, literal and receivers of DataProcessor', () => {
+    const index = indexGoReferences([read('sample.go')]);
+
+    expect(
+      index
+        .references('main.DataProcessor')
+        .map(({ line, column, role, context, enclosing }) => [line, column, role, context, enclosing]),
+    ).toEqual([
+      [10, 6, 'definition', 'declaration', undefined],
+      [16, 50, 'use', 'result', 'main.NewDataProcessor'],
+      [17, 10, 'use', 'literal', 'main.NewDataProcessor'],
+      [24, 11, 'use', 'receiver', 'main.DataProcessor.ProcessData'],
+      [38, 11, 'use', 'receiver', 'main.DataProcessor.processItem'],
+      [43, 11, 'use', 'receiver', 'main.DataProcessor.GetCacheSize'],
+    ]);
+    expect(index.references('main.DataProcessor')[0].filePath).toBe(path.join(fixtures, 'sample.go'));
+  });
+
+  it('indexes members by type with call graph ids', () => {
+    const index = indexGoReferences([read('sample.go')]);
+
+    expect(index.symbols()).toContain('main.DataProcessor.cache');
+    expect(index.symbols()).toContain('main.NewDataProcessor');
+    expect(
+      index.references('main.DataProcessor.processItem').map(({ line, role, context }) => [line, role, context]),
+    ).toEqual([
+      [29, 'use', 'call'],
+      [38, 'definition', 'declaration'],
+    ]);
+    expect(index.references('main.DataProcessor.cache').map(({ line, role }) => [line, role])).toEqual([
+      [12, 'definition'],
+      [19, 'use'],
+      [44, 'use'],
+    ]);
+  });
+
+  it('tells variable, parameter, field and conversion uses apart across files', () => {
+    const store = parse(
+      `package p
+
+type ID int
+
+type Store struct {
+	owner ID
+	ids   []ID
+}
+
+var Default ID = ID(1)
+`,
+      'store.go',
+    );
+    const use = parse(
+      `package p
+
+func Lookup(id ID) (found *ID) {
+	var ids []ID
+	_ = ids
+	return nil
+}
+`,
+      'use.go',
+    );
+    const index = indexGoReferences([use, store]);
+
+    expect(index.references('p.ID').map(({ filePath, line, context }) => [path.basename(filePath), line, context])).toEqual([
+      ['store.go', 3, 'declaration'],
+      ['store.go', 6, 'field'],
+      ['store.go', 7, 'field'],
+      ['store.go', 10, 'variable'],
+      ['store.go', 10, 'call'],
+      ['use.go', 3, 'parameter'],
+      ['use.go', 3, 'result'],
+      ['use.go', 4, 'variable'],
+    ]);
+  });
+
+  it('marks uses of members shared by several types as ambiguous', () => {
+    const source = `package p
+
+type A struct{ name string }
+
+type B struct{ name string }
+
+func (a A) Name() string { return a.name }
+`;
+    const index = indexGoReferences([parse(source)]);
+
+    for (const id of ['p.A.name', 'p.B.name']) {
+      expect(index.references(id).map(({ line, role, ambiguous }) => [line, role, ambiguous])).toEqual([
+        [id === 'p.A.name' ? 3 : 5, 'definition', undefined],
+        [7, 'use', true],
+      ]);
+    }
+    expect(index.references('p.A.Name')[0].ambiguous).toBeUndefined();
+  });
+
+  it('keeps locals, other packages and unknown ids out of the index', () => {
+    const a = parse('package p\n\nfunc Run() { x := 1; _ = x }\n');
+    const b = parseGoFile('package q\n\nfunc Run() {}\n', '/src/q/q.go');
+    const index = indexGoReferences([a, b]);
+
+    expect(index.symbols()).toEqual(['p.Run', 'q.Run']);
+    expect(index.references('p.Run')).toEqual([
+      {
+        filePath: '/src/p/p.go',
+        line: 3,
+        column: 6,
+        role: 'definition',
+        context: 'declaration',
+        enclosing: 'p.Run',
+      },
+    ]);
+    expect(index.references('p.x')).toEqual([]);
+  });
+});
@@ -0,0 +1,134 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { applyEdits } from '../src/diff';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+import { renameGoSymbol } from '../src/go/rename';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const genericsPath = path.join(__dirname, 'fixtures', 'go', 'generics.go');
+
+const store = `package store
+
+import "strings"
+
+// Store keeps items by key
+type Store struct {
+	items map[string]string
+}
+
+func (s *Store) Get(key string) string { return s.items[strings.ToLower(key)] }
+
+func (s *Store) Put(key, value string) { s.items[key] = value }
+`;
+
+const uses = `package store
+
+func Fill(s *Store, entries map[string]string) {
+	for key, value := range entries {
+		s.Put(key, value)
+	}
+}
+
+func Lookup(keys []string) []string {
+	s := &Store{items: map[string]string{}}
+	var found []string
+	for _, key := range keys {
+		found = append(found, s.Get(key))
+	}
+	return found
+}
+`;
+
+const files = () => [parseGoFile(store, 'store/store.go'), parseGoFile(uses, 'store/uses.go')];
+
+describe('Go rename symbol', () => {
+  it('should rename a type with its receivers, uses and doc comment', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const result = renameGoSymbol([sample], { name: 'DataProcessor', newName: 'Processor' });
+
+    expect(result.count).toBe(6);
+    expect(result.docCommentUpdated).toBe(true);
+    expect(result.references.map(reference => reference.line)).toEqual([10, 16, 17, 24, 38, 43]);
+    expect(result.references[0]).toEqual({ filePath: samplePath, line: 10, column: 6 });
+
+    const source = applyEdits(sample.source, result.files[0].edits);
+    expect(source).toContain('// Processor handles data processing operations\ntype Processor struct');
+    expect(source).toContain('func NewDataProcessor(config map[string]string) *Processor {');
+    expect(source).toContain('return &Processor{');
+    expect(source).toContain('func (dp *Processor) GetCacheSize() int {');
+    // Only the doc comment's leading name is updated
+    expect(source).toContain('// NewDataProcessor creates a new DataProcessor instance');
+  });
+
+  it('should update references in every file of the package', () => {
+    const result = renameGoSymbol(files(), { name: 'Store.Put', newName: 'Set' });
+
+    expect(result.files.map(file => file.filePath)).toEqual(['store/store.go', 'store/uses.go']);
+    expect(result.references.map(reference => `${reference.filePath}:${reference.line}`)).toEqual([
+      'store/store.go:12',
+      'store/uses.go:5',
+    ]);
+    expect(result.docCommentUpdated).toBe(false);
+
+    const field = renameGoSymbol(files(), { name: 'Store.items', newName: 'entries' });
+    expect(field.count).toBe(4);
+    expect(applyEdits(uses, field.files[1].edits)).toContain('&Store{entries: map[string]string{}}');
+  });
+
+  it('should refuse names that collide or would be captured', () => {
+    expect(() => renameGoSymbol(files(), { name: 'Fill', newName: 'Lookup' })).toThrow(
+      'Lookup already names something in the package (uses.go:9)'
+    );
+    expect(() => renameGoSymbol(files(), { name: 'Store.Get', newName: 'Put' })).toThrow(
+      'Put is already used as a field or method name in the package'
+    );
+    expect(() => renameGoSymbol(files(), { name: 'Store', newName: 'found' })).toThrow(
+      'The reference at uses.go:10 would refer to the local found declared at uses.go:11'
+    );
+    expect(() => renameGoSymbol(files(), { name: 'Lookup', newName: 'strings' })).toThrow(
+      'strings already names something in the package (store.go:10)'
+    );
+  });
+
+  it('should refuse invalid names and symbols it cannot resolve', () => {
+    expect(() => renameGoSymbol(files(), { name: 'Store', newName: 'type' })).toThrow(
+      'type is not a valid Go identifier'
+    );
+    expect(() => renameGoSymbol(files(), { name: 'Store', newName: '_' })).toThrow(GoRefactorError);
+    expect(() => renameGoSymbol(files(), { name: 'Missing', newName: 'Found' })).toThrow(
+      'Missing is not declared in the analyzed files'
+    );
+    expect(() => renameGoSymbol(files(), { name: 'Store.Delete', newName: 'Remove' })).toThrow(
+      'Store.Delete is not declared in the analyzed files'
+    );
+
+    const duplicated = parseGoFile('package store\n\nvar Store = 1\n', 'store/dup.go');
+    expect(() =>
+      renameGoSymbol([...files(), duplicated], { name: 'Store', newName: 'Cache' })
+    ).toThrow('Store is declared more than once in the package');
+  });
+
+  it('should keep type parameters and their brackets intact', () => {
+    const generics = parseGoFile(fs.readFileSync(genericsPath, 'utf-8'), genericsPath);
+
+    // Type parameters named T are not the package-level type T
+    const type = renameGoSymbol([generics], { name: 'T', newName: 'Item' });
+    expect(type.references.map(reference => reference.line)).toEqual([17, 70, 70]);
+    expect(type.files[0].source).toContain('func Map[T, U any](items []T, f func(T) U) []U {');
+    expect(type.files[0].source).toContain('func Lookup(items []Item, id int) *Item {');
+
+    const pair = renameGoSymbol([generics], { name: 'Pair', newName: 'Couple' });
+    expect(pair.files[0].source).toContain('type Couple[K comparable, V any] struct {');
+    expect(pair.files[0].source).toContain(
+      'func (p Couple[K, V]) Swap() Couple[V, K] {\n\treturn Couple[V, K]{Key: p.Value, Value: p.Key}'
+    );
+
+    const constraint = renameGoSymbol([generics], { name: 'Number', newName: 'Numeric' });
+    expect(constraint.files[0].source).toContain('func Sum[N Numeric](values ...N) N {');
+    expect(() => renameGoSymbol([generics], { name: 'Number', newName: 'N' })).toThrow(
+      'The reference at generics.go:29 would refer to the local N declared at generics.go:29'
+    );
+  });
+});
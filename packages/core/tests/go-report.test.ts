@@ -0,0 +1,74 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { readCoverProfile } from '../src/go/coverage';
+import { goMarkdownReport } from '../src/go/report';
+
+const fixtures = path.join(__dirname, 'fixtures', 'go');
+const parse = (name: string) => {
+  const filePath = path.join(fixtures, name);
+  return parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath);
+};
+
+describe('Go Markdown report', () => {
+  it('should tabulate functions and list dead code for the fixture', () => {
+    const report = goMarkdownReport([parse('sample.go')], { root: fixtures });
+
+    expect(report).toContain(`# Go analysis: main
+
+1 file(s), 9 function(s), 1 dead function(s).
+
+## Functions
+
+| Function | File | Line | Visibility | Complexity | Cognitive | Maintainability | Fan-in | Fan-out |
+| --- | --- | ---: | --- | ---: | ---: | ---: | ---: | ---: |
+| \`NewDataProcessor\` | sample.go | 16 | exported | 1 | 0 | 99.1 | 0 | 0 |
+| \`DataProcessor.ProcessData\` | sample.go | 24 | exported | 3 | 3 | 90.9 | 0 | 1 |`);
+    expect(report).toContain('| `CalculateFibonacci` | sample.go | 48 | exported | 4 | 2 | 90.8 | 0 | 0 |');
+    expect(report).toContain('| `ProcessComplexData` | sample.go | 62 | exported | 6 | 4 | 82.8 | 0 | 5 |');
+    expect(report).toContain(`## Refactor candidates
+
+Functions with cyclomatic complexity above 10 or cognitive complexity above 15, by priority.`);
+    expect(report).toContain('plus 1 per level of nesting for `if`, `switch`, `select` and loops');
+    expect(report).toMatch(/by priority\. .*\n\n_None\._/);
+    expect(report).toContain(`## Largest functions
+
+Functions by lines of code, excluding blank lines and comments.
+
+| Function | File | Code | Statements | Comments | Doc | Lines |
+| --- | --- | ---: | ---: | ---: | ---: | ---: |
+| \`ProcessComplexData\` | sample.go:62 | 17 | 9 | 0 | 1 | 20 |
+| \`DataProcessor.ProcessData\` | sample.go:24 | 10 | 6 | 0 | 1 | 12 |
+| \`CalculateFibonacci\` | sample.go:48 | 10 | 6 | 0 | 1 | 12 |`);
+    expect(report).toContain('- `privateHelper` (sample.go:97)');
+    expect(report).toContain('## Recursion\n\nFunctions that call themselves, directly or through each other; inlining across them is unsafe.\n\n_None._');
+    expect(report).not.toContain('```dot');
+  });
+
+  it('should rank candidates and show coverage when given a profile', async () => {
+    const report = goMarkdownReport([parse('sample.go')], {
+      root: fixtures,
+      title: 'Sample',
+      complexityThreshold: 3,
+      coverage: await readCoverProfile(path.join(fixtures, 'sample.coverprofile')),
+    });
+
+    expect(report.startsWith('# Sample\n')).toBe(true);
+    expect(report).toContain('| `DataProcessor.ProcessData` | sample.go | 24 | exported | 3 | 3 | 90.9 | 66.7% | 0 | 1 |');
+    expect(report).toContain(`| Priority | Function | File | Complexity | Cognitive | Coverage | Fan-in |
+| ---: | --- | --- | ---: | ---: | ---: | ---: |
+| 1 | \`ProcessComplexData\` | sample.go:62 | 6 | 4 | 0% | 0 |
+| 2 | \`CalculateFibonacci\` | sample.go:48 | 4 | 2 | 100% | 0 |
+`);
+  });
+
+  it('should render the same report regardless of file order', () => {
+    const forward = goMarkdownReport([parse('sample.go'), parse('embedded.go')], { root: fixtures, callGraph: true });
+    const backward = goMarkdownReport([parse('embedded.go'), parse('sample.go')], { root: fixtures, callGraph: true });
+
+    expect(backward).toBe(forward);
+    expect(forward).toContain('## Call graph\n\n```dot\ndigraph "callgraph" {');
+    expect(forward.endsWith('}\n```\n')).toBe(true);
+  });
+});
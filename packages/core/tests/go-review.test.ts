@@ -0,0 +1,118 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+import { GoReviewSession } from '../src/go/review';
+
+const geo = `package geo
+
+import "errors"
+
+type Point struct{ X, Y int }
+
+func Positive(v int) bool {
+	if v > 0 {
+		return true
+	}
+	return false
+}
+
+func Scale(items []int) []int {
+	var out []int
+	for _, item := range items {
+		out = append(out, item*2)
+	}
+	return out
+}
+
+func Parse(s string) (Point, error) {
+	return Point{1, 2}, errors.New("Cannot parse.")
+}
+`;
+
+const shapes = `package geo
+
+func Pair() (Point, Point) {
+	return Point{1, 2}, Point{3, 4}
+}
+`;
+
+describe('Go suggestion review', () => {
+  const files = () => [parseGoFile(shapes, '/src/geo/shapes.go'), parseGoFile(geo, '/src/geo/geo.go')];
+
+  it('groups fixable findings by file, in source order', () => {
+    const session = new GoReviewSession('/src', files());
+
+    expect(session.groups().map(group => [group.filePath, group.items.map(item => [item.id, item.finding.rule, item.finding.line])])).toEqual([
+      [
+        '/src/geo/geo.go',
+        [
+          [0, 'redundant-bool-return', 8],
+          [1, 'slice-prealloc', 15],
+          [2, 'unkeyed-literal', 23],
+          [3, 'error-string-style', 23],
+        ],
+      ],
+      [
+        '/src/geo/shapes.go',
+        [
+          [4, 'unkeyed-literal', 4],
+          [5, 'unkeyed-literal', 4],
+        ],
+      ],
+    ]);
+    expect(session.items().every(item => item.status === 'pending')).toBe(true);
+  });
+
+  it('previews a fix with its diff, confidence and impact', () => {
+    const preview = new GoReviewSession('/src', files()).preview(1);
+
+    expect(preview.file.path).toBe('geo/geo.go');
+    expect(preview.file.diff).toContain('-\tvar out []int\n+\tout := make([]int, 0, len(items))\n');
+    expect(preview.file.impact).toEqual({ files: 1, references: 1, exportedChanges: [], breaking: false });
+    expect(preview.confidence.score).toBeGreaterThan(0);
+    expect(preview.confidence.factors.map(factor => factor.name)).toContain('tests');
+  });
+
+  it('previews later fixes against the approved edits', () => {
+    const session = new GoReviewSession('/src', files());
+    session.approve(0);
+
+    expect(session.items()[0].status).toBe('approved');
+    expect(session.items()[1].finding.line).toBe(12);
+    const preview = session.preview(1);
+    expect(preview.file.diff).toContain('@@ -9,7 +9,7 @@');
+    expect(preview.file.content).toContain('\treturn v > 0\n');
+    expect(preview.file.content).not.toContain('return true');
+    expect(session.preview(0).reason).toBe('the finding is approved');
+  });
+
+  it('follows findings whose line a fix edited, and resolves those it fixed', () => {
+    const session = new GoReviewSession('/src', files());
+    session.approve(2);
+    session.approve(4);
+
+    expect(session.items()[3]).toMatchObject({ status: 'pending', finding: { rule: 'error-string-style', line: 23 } });
+    expect(session.preview(3).file.diff).toContain('+\treturn Point{X: 1, Y: 2}, errors.New("cannot parse")\n');
+    expect(session.items()[5].status).toBe('resolved');
+    expect(session.skip(3).status).toBe('skipped');
+    expect(session.approve(3).item.status).toBe('approved');
+    expect(() => session.approve(5)).toThrow(GoRefactorError);
+    expect(() => session.approve(5)).toThrow('Item 5 is already resolved');
+    expect(() => new GoReviewSession('/src', files(), { rules: ['exported-doc'] })).toThrow('exported-doc has no quick fix');
+  });
+
+  it('plans the approved fixes, one entry per file', () => {
+    const session = new GoReviewSession('/src', files());
+    session.approve(0);
+    session.approve(1);
+    session.skip(2);
+    const plan = session.plan();
+
+    expect(plan.files.map(file => file.path)).toEqual(['geo/geo.go']);
+    expect(plan.files[0].content).toBe(session.files.find(file => file.filePath === '/src/geo/geo.go').source);
+    expect(plan.files[0].diff).toContain('-\t\treturn true\n');
+    expect(plan.files[0].diff).toContain('+\tout := make([]int, 0, len(items))\n');
+    expect(plan.summary.impact.references).toBe(2);
+    expect(new GoReviewSession('/src', files()).plan().files).toEqual([]);
+  });
+});
@@ -0,0 +1,114 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry, GoRule, GoRuleRegistry } from '../src/go/rules';
+import { Visibility } from '../src/go/symbols';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), 'sample.go');
+
+// Every exported function must have a doc comment starting with its name
+const exportedDoc: GoRule = {
+  id: 'exported-doc',
+  description: 'Exported functions are documented',
+  severity: 'low',
+  check: symbol => {
+    if (symbol.type !== 'function' || symbol.visibility !== Visibility.Exported) return [];
+    if (symbol.documentation?.startsWith(`${symbol.name} `)) return [];
+    return [{ message: `${symbol.qualifiedName} needs a doc comment starting with its name` }];
+  },
+};
+
+const source = `package main
+
+import "os"
+
+// Run starts the program
+func Run() {
+	os.Remove("tmp")
+}
+
+// starts the helper
+func Helper() {}
+
+func Missing() {}
+`;
+
+describe('Go rule registry', () => {
+  it('should run custom symbol rules with the symbol position and rule severity', () => {
+    const registry = new GoRuleRegistry().register(exportedDoc);
+
+    expect(registry.run([sample()])).toEqual([]);
+    expect(registry.run([parseGoFile(source, 'main.go')])).toEqual([
+      {
+        rule: 'exported-doc',
+        severity: 'low',
+        filePath: 'main.go',
+        line: 11,
+        column: 1,
+        message: 'Helper needs a doc comment starting with its name',
+      },
+      {
+        rule: 'exported-doc',
+        severity: 'low',
+        filePath: 'main.go',
+        line: 13,
+        column: 1,
+        message: 'Missing needs a doc comment starting with its name',
+      },
+    ]);
+  });
+
+  it('should run built-in rules alongside custom ones', () => {
+    const registry = defaultGoRuleRegistry().register(exportedDoc);
+    const findings = registry.run([parseGoFile(source, 'main.go')]);
+
+    expect(findings.map(finding => [finding.rule, finding.line])).toEqual([
+      ['ignored-error', 7],
+      ['stale-doc-name', 10],
+      ['exported-doc', 11],
+      ['exported-doc', 13],
+    ]);
+    expect(registry.list().map(rule => rule.id)).toContain('unused-import');
+  });
+
+  it('should enable and disable rules by ID', () => {
+    const registry = defaultGoRuleRegistry().register(exportedDoc, { enabled: false });
+    const file = parseGoFile(source, 'main.go');
+
+    expect(registry.isEnabled('exported-doc')).toBe(false);
+    expect(registry.run([file]).map(finding => finding.rule)).toEqual(['ignored-error', 'stale-doc-name']);
+
+    registry.enable('exported-doc').disable('ignored-error');
+    expect(registry.run([file]).map(finding => finding.rule)).toEqual([
+      'stale-doc-name',
+      'exported-doc',
+      'exported-doc',
+    ]);
+    expect(() => registry.disable('no-such-rule')).toThrow('Unknown rule no-such-rule');
+  });
+
+  it('should reject invalid and duplicate rule IDs', () => {
+    const registry = new GoRuleRegistry().register(exportedDoc);
+
+    expect(() => registry.register(exportedDoc)).toThrow('Rule exported-doc is already registered');
+    expect(() => registry.register({ ...exportedDoc, id: 'Exported Doc' })).toThrow(
+      'must be kebab-case'
+    );
+    expect(() =>
+      registry.register({ id: 'empty', description: '', severity: 'low' })
+    ).toThrow('Rule empty has no check');
+  });
+
+  it('should report findings in the same order whatever order files come in', () => {
+    const files = ['b.go', 'a.go', 'c.go'].map(name => parseGoFile(source, name));
+    const registry = defaultGoRuleRegistry().register(exportedDoc);
+    const expected = JSON.stringify(registry.run(files));
+
+    for (const order of [[0, 1, 2], [2, 1, 0], [1, 2, 0], [0, 2, 1]]) {
+      expect(JSON.stringify(registry.run(order.map(index => files[index])))).toBe(expected);
+    }
+    expect(registry.run(files).map(finding => finding.filePath)[0]).toBe('a.go');
+  });
+});
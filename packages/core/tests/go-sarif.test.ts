@@ -0,0 +1,124 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { goFindingFingerprint } from '../src/go/fingerprint';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+import { GO_SARIF_FINGERPRINT, goSarifLog } from '../src/go/sarif';
+
+const schema = JSON.parse(
+  fs.readFileSync(path.join(__dirname, 'fixtures', 'sarif', 'sarif-2.1.0-subset.schema.json'), 'utf-8')
+);
+
+// The JSON Schema keywords the SARIF schema fixture uses; returns the paths that fail
+function validate(value: any, node: any = schema, at = '$'): string[] {
+  if (node.$ref) {
+    return validate(value, schema.definitions[node.$ref.replace('#/definitions/', '')], at);
+  }
+  const errors: string[] = [];
+  if (node.enum && !node.enum.includes(value)) errors.push(`${at}: not one of ${node.enum}`);
+  if (node.type) {
+    const actual = Array.isArray(value) ? 'array' : Number.isInteger(value) ? 'integer' : typeof value;
+    const ok = actual === node.type || (node.type === 'number' && actual === 'integer');
+    if (!ok) return [...errors, `${at}: expected ${node.type}`];
+  }
+  if (node.minimum !== undefined && value < node.minimum) errors.push(`${at}: below ${node.minimum}`);
+  if (node.maximum !== undefined && value > node.maximum) errors.push(`${at}: above ${node.maximum}`);
+  if (node.format === 'uri' && !/^[a-z][a-z0-9+.-]*:\S+$/i.test(value)) errors.push(`${at}: not a URI`);
+  if (node.format === 'uri-reference' && /[\s\\]/.test(value)) errors.push(`${at}: not a URI reference`);
+  if (node.type === 'array') {
+    value.forEach((item: any, i: number) => errors.push(...validate(item, node.items, `${at}[${i}]`)));
+    if (node.uniqueItems && new Set(value.map((item: any) => JSON.stringify(item))).size !== value.length) {
+      errors.push(`${at}: items not unique`);
+    }
+  }
+  if (node.type === 'object') {
+    for (const key of node.required ?? []) {
+      if (!(key in value)) errors.push(`${at}: missing ${key}`);
+    }
+    for (const [key, item] of Object.entries(value)) {
+      const property = node.properties?.[key];
+      if (property) errors.push(...validate(item, property, `${at}.${key}`));
+      else if (node.additionalProperties === false) errors.push(`${at}: unexpected ${key}`);
+      else if (node.additionalProperties) errors.push(...validate(item, node.additionalProperties, `${at}.${key}`));
+    }
+  }
+  return errors;
+}
+
+const source = `package store
+
+import "os"
+
+// Clear removes the café's directory
+func Clear(dir string) {
+	/* café */ os.Remove(dir)
+}
+`;
+
+describe('Go findings as SARIF', () => {
+  const file = parseGoFile(source, '/repo/my store/store.go');
+  const findings = defaultGoRuleRegistry().run([file]);
+
+  it('writes a log the SARIF 2.1.0 schema accepts', () => {
+    const log = goSarifLog(findings, [file], { root: '/repo', toolVersion: '1.0.1' });
+
+    expect(validate(JSON.parse(JSON.stringify(log)))).toEqual([]);
+    expect(log.version).toBe('2.1.0');
+    expect(log.runs[0].tool.driver).toMatchObject({ name: 'refactogent', version: '1.0.1' });
+    expect(validate({ version: '2.1.0', runs: [{ tool: { driver: {} }, extra: 1 }] })).toEqual([
+      '$.runs[0].tool.driver: missing name',
+      '$.runs[0]: unexpected extra',
+    ]);
+  });
+
+  it('defines every registered rule and refers to it by index', () => {
+    const { rules } = goSarifLog(findings, [file], { root: '/repo' }).runs[0].tool.driver;
+    const ignored = rules.find(rule => rule.id === 'ignored-error');
+
+    expect(rules.map(rule => rule.id)).toEqual(defaultGoRuleRegistry().list().map(rule => rule.id));
+    expect(ignored).toEqual({
+      id: 'ignored-error',
+      name: 'IgnoredError',
+      shortDescription: { text: 'Error results dropped or discarded with _' },
+      fullDescription: {
+        text: 'Error results dropped or discarded with _. Reported at high severity unless configured otherwise.',
+      },
+      defaultConfiguration: { level: 'error' },
+    });
+    const [result] = goSarifLog(findings, [file], { root: '/repo' }).runs[0].results;
+    expect(rules[result.ruleIndex].id).toBe(result.ruleId);
+  });
+
+  it('locates results relative to the root in UTF-16 columns', () => {
+    const log = goSarifLog(findings, [file], { root: '/repo' });
+    const result = log.runs[0].results.find(candidate => candidate.ruleId === 'ignored-error');
+    const finding = findings.find(candidate => candidate.rule === 'ignored-error');
+
+    expect(finding.column).toBe(14);
+    expect(result).toMatchObject({
+      level: 'error',
+      message: { text: finding.message },
+      locations: [
+        {
+          physicalLocation: {
+            artifactLocation: { uri: 'my%20store/store.go', uriBaseId: '%SRCROOT%' },
+            region: { startLine: 7, startColumn: 13 },
+          },
+        },
+      ],
+    });
+    expect(log.runs[0].columnKind).toBe('utf16CodeUnits');
+  });
+
+  it('fingerprints results as baselines do', () => {
+    const log = goSarifLog(findings, [file], { root: '/repo' });
+    const result = log.runs[0].results.find(candidate => candidate.ruleId === 'ignored-error');
+    const finding = findings.find(candidate => candidate.rule === 'ignored-error');
+
+    expect(result.partialFingerprints).toEqual({
+      [GO_SARIF_FINGERPRINT]: goFindingFingerprint(finding, file),
+    });
+    expect(goSarifLog([], [file]).runs[0].results).toEqual([]);
+  });
+});
@@ -0,0 +1,100 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { extractGoFileSymbols } from '../src/go/symbols';
+import {
+  GO_SYMBOLS_SCHEMA_VERSION,
+  parseGoSymbolsJson,
+  serializeGoSymbols,
+} from '../src/go/serialize';
+
+const rootPath = path.join(__dirname, 'fixtures');
+
+function loadSymbols(fileName: string) {
+  const filePath = path.join(rootPath, 'go', fileName);
+  return extractGoFileSymbols(parseGoFile(fs.readFileSync(filePath, 'utf-8'), filePath));
+}
+
+describe('Go symbol JSON', () => {
+  it('should emit a versioned document with repo-relative paths', () => {
+    const document = JSON.parse(serializeGoSymbols([loadSymbols('sample.go')], rootPath));
+
+    expect(document.schema_version).toBe(GO_SYMBOLS_SCHEMA_VERSION);
+    expect(document.files[0].path).toBe('go/sample.go');
+    expect(document.files[0].package).toBe('main');
+  });
+
+  it('should use snake_case fields and include positions', () => {
+    const document = JSON.parse(serializeGoSymbols([loadSymbols('sample.go')], rootPath));
+    const [file] = document.files;
+    const fibonacci = file.functions.find((f: any) => f.name === 'CalculateFibonacci');
+    const processData = file.methods.find((m: any) => m.name === 'ProcessData');
+
+    expect(fibonacci).toEqual({
+      name: 'CalculateFibonacci',
+      qualified_name: 'CalculateFibonacci',
+      visibility: 'exported',
+      receiver: null,
+      type_params: null,
+      signature: {
+        params: [{ name: 'n', type: 'int', variadic: false }],
+        results: [{ name: null, type: 'int', variadic: false }],
+        text: '(int) int',
+      },
+      complexity: 4,
+      cognitive_complexity: 2,
+      closures: [],
+      size: { lines: 12, code_lines: 10, comment_lines: 0, doc_lines: 1, statements: 6 },
+      imports: [],
+      fan_in: null,
+      fan_out: null,
+      coverage_pct: null,
+      maintainability_index: null,
+      documentation: 'CalculateFibonacci calculates the nth Fibonacci number',
+      position: { start_line: 48, start_column: 1, end_line: 59, end_column: 2 },
+    });
+    expect(processData.receiver).toEqual({
+      name: 'dp',
+      type_name: 'DataProcessor',
+      is_pointer: true,
+      type_params: null,
+    });
+    expect(file.types[0]).toMatchObject({
+      name: 'DataProcessor',
+      kind: 'struct',
+      methods: [
+        'DataProcessor.ProcessData',
+        'DataProcessor.processItem',
+        'DataProcessor.GetCacheSize',
+      ],
+    });
+    expect(file.constants.map((c: any) => [c.name, c.declared_type, c.value])).toEqual([
+      ['API_VERSION', 'string', '"1.0.0"'],
+      ['MAX_RETRIES', 'int', '3'],
+    ]);
+  });
+
+  it('should round-trip into the internal model', () => {
+    const original = [
+      loadSymbols('sample.go'),
+      loadSymbols('embedded.go'),
+      loadSymbols('closures.go'),
+      loadSymbols('constants.go'),
+      loadSymbols('generics.go'),
+    ];
+
+    const restored = parseGoSymbolsJson(serializeGoSymbols(original, rootPath), rootPath);
+
+    expect(restored).toEqual(original);
+    // Type methods are re-attached to the restored method objects
+    const circle = restored[1].types.find(t => t.name === 'Circle');
+    expect(restored[1].methods).toContain(circle?.methods[0]);
+  });
+
+  it('should reject documents with an unknown schema version', () => {
+    const json = JSON.stringify({ schema_version: 99, analyzer_version: '1', files: [] });
+
+    expect(() => parseGoSymbolsJson(json, rootPath)).toThrow(/schema version 99/);
+  });
+});
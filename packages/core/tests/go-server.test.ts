@@ -0,0 +1,97 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as http from 'http';
+import * as os from 'os';
+import * as path from 'path';
+import { createGoAnalysisServer, GoServerOptions } from '../src/go/server';
+import { GO_SYMBOLS_SCHEMA_VERSION } from '../src/go/serialize';
+
+const store = `package store
+
+import "os"
+
+// Open opens the store
+func Open(name string) *os.File {
+	f, _ := os.Open(name)
+	return f
+}
+`;
+
+describe('Go analysis server', () => {
+  let root: string;
+  let server: http.Server;
+  let base: string;
+
+  const start = async (options: GoServerOptions = {}) => {
+    server = createGoAnalysisServer({ root, ...options });
+    await new Promise<void>(resolve => server.listen(0, '127.0.0.1', resolve));
+    base = `http://127.0.0.1:${(server.address() as { port: number }).port}`;
+  };
+  const post = (body: unknown) =>
+    fetch(`${base}/analyze`, { method: 'POST', body: JSON.stringify(body) });
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'go-server-'));
+    fs.mkdirSync(path.join(root, 'store'));
+    fs.writeFileSync(path.join(root, 'store', 'store.go'), store);
+  });
+
+  afterEach(async () => {
+    await new Promise(resolve => server.close(resolve));
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  it('analyzes a directory under the root, with an overlay', async () => {
+    await start();
+    const response = await post({ path: 'store', files: { 'extra.go': 'package store\n\nfunc Extra() {}\n' } });
+    const body = await response.json();
+
+    expect(response.status).toBe(200);
+    expect(body.schema_version).toBe(GO_SYMBOLS_SCHEMA_VERSION);
+    expect(body.files.map((file: any) => file.path)).toEqual(['store/extra.go', 'store/store.go']);
+    expect(body.findings).toMatchObject([{ rule: 'ignored-error', file: 'store/store.go', line: 7 }]);
+
+    const inMemory = await (await post({ files: { 'a/a.go': 'package a\n\nconst A = 1\n' } })).json();
+    expect(inMemory.files).toMatchObject([{ path: 'a/a.go', constants: [{ name: 'A' }] }]);
+  });
+
+  it('queries symbols by path and name', async () => {
+    await start();
+    const response = await fetch(`${base}/symbols?path=store&name=Open`);
+    const body = await response.json();
+
+    expect(response.status).toBe(200);
+    expect(body.files).toHaveLength(1);
+    expect(body.files[0].functions.map((fn: any) => fn.name)).toEqual(['Open']);
+    expect((await (await fetch(`${base}/symbols?path=store&name=Close`)).json()).files).toEqual([]);
+  });
+
+  it('tells parse failures from bad requests and server errors', async () => {
+    await start();
+    const broken = await post({ files: { 'bad.go': 'package bad\n\nfunc {\n' } });
+    const error = (await broken.json()).error;
+
+    expect(broken.status).toBe(422);
+    expect(error).toMatchObject({ kind: 'parse', file: 'bad.go', line: 3 });
+    expect((await post({ path: '../..' })).status).toBe(403);
+    expect((await post({ path: 'missing' })).status).toBe(404);
+    expect((await post({})).status).toBe(400);
+    expect((await fetch(`${base}/analyze`, { method: 'POST', body: '{' })).status).toBe(400);
+    const wrongMethod = await fetch(`${base}/analyze`);
+    expect(wrongMethod.status).toBe(405);
+    expect(wrongMethod.headers.get('allow')).toBe('POST');
+    expect((await fetch(`${base}/nothing`)).status).toBe(404);
+    expect((await wrongMethod.json()).schema_version).toBe(GO_SYMBOLS_SCHEMA_VERSION);
+  });
+
+  it('limits concurrent analyses and request sizes', async () => {
+    await start({ concurrency: 1, maxQueued: 0, maxBodyBytes: 64 });
+    const file = (name: string) => ({ [name]: 'package p\n' });
+
+    expect((await post({ files: { 'big.go': `package p\n// ${'x'.repeat(100)}\n` } })).status).toBe(413);
+    const statuses = await Promise.all([post({ files: file('a.go') }), post({ files: file('b.go') })]).then(
+      responses => responses.map(response => response.status).sort()
+    );
+    expect(statuses).toEqual([200, 503]);
+  });
+});
@@ -0,0 +1,92 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findShadowedVariables } from '../src/go/shadow';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const loaderSource = `package loader
+
+import "os"
+
+func Load(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if len(data) == 0 {
+		data, err := os.ReadFile(path + ".bak")
+		_ = data
+		_ = err
+	}
+	return data, err
+}
+
+func Count(items []string) int {
+	total := 0
+	for _, item := range items {
+		if item != "" {
+			total := total + 1
+			_ = total
+		}
+	}
+	return total
+}
+
+func Intentional(v interface{}) string {
+	err := check()
+	if err != nil {
+		err := wrap(err)
+		return err.Error()
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	}
+	for _, err := range []error{nil} {
+		_ = err
+	}
+	n := 1
+	go func() {
+		n := n
+		_ = n
+	}()
+	return ""
+}
+
+func check() error { return nil }
+
+func wrap(err error) error { return err }
+`;
+
+describe('Go shadowed variables', () => {
+  it('should not flag the fixture', () => {
+    const content = fs.readFileSync(samplePath, 'utf-8');
+    expect(findShadowedVariables([parseGoFile(content, samplePath)])).toEqual([]);
+  });
+
+  it('should flag shadowing when the outer variable is read afterwards', () => {
+    const findings = findShadowedVariables([parseGoFile(loaderSource, 'loader.go')]);
+
+    expect(findings.map(f => [f.line, f.name, f.severity, f.shadowedLine, f.usedLine])).toEqual([
+      [8, 'data', 'low', 6, 12],
+      [8, 'err', 'medium', 6, 12],
+      [19, 'total', 'low', 16, 23],
+    ]);
+    expect(findings[1]).toMatchObject({
+      rule: 'shadowed-variable',
+      column: 9,
+      message: 'err shadows the err declared on line 6, which is read on line 12 without seeing this value',
+      fix: 'data, err = os.ReadFile(path + ".bak")',
+    });
+    expect(findings[2].fix).toBe('total = total + 1');
+  });
+
+  it('should not flag intentional shadowing', () => {
+    const findings = findShadowedVariables([parseGoFile(loaderSource, 'loader.go')]);
+    expect(findings.filter(f => f.line > 24)).toEqual([]);
+  });
+
+  it('should not flag a variable declared later in the enclosing block', () => {
+    const source = 'package p\n\nfunc f(ok bool) int {\n\tif ok {\n\t\tr := 1\n\t\treturn r\n\t}\n\tr := 2\n\treturn r\n}\n';
+    expect(findShadowedVariables([parseGoFile(source, 'p.go')])).toEqual([]);
+  });
+});
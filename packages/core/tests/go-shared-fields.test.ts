@@ -0,0 +1,92 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findUnsynchronizedFields } from '../src/go/shared-fields';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package cache
+
+import "sync"
+
+type Cache struct {
+	items map[string]int
+	order []string
+	limit int
+}
+
+func (c *Cache) Put(k string, v int) {
+	c.items[k] = v
+	c.order = append(c.order, k)
+}
+
+func (c *Cache) Get(k string) int { return c.items[k] }
+
+func (c *Cache) Evict() { delete(c.items, c.order[0]) }
+
+type Guarded struct {
+	mu    sync.RWMutex
+	items map[string]int
+}
+
+func (g *Guarded) Put(k string, v int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.items[k] = v
+}
+
+func (g *Guarded) Get(k string) int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.items[k]
+}
+
+// Builder collects parts. It is not safe for concurrent use.
+type Builder struct{ parts []string }
+
+func (b *Builder) Add(p string) { b.parts = append(b.parts, p) }
+
+func (b *Builder) Len() int { return len(b.parts) }
+`;
+
+describe('Go unsynchronized shared fields', () => {
+  const file = parseGoFile(source, 'cache.go');
+
+  it('flags maps and slices written and read by methods without a lock', () => {
+    const findings = findUnsynchronizedFields([file]);
+
+    expect(findings.map(finding => [finding.type, finding.field, finding.kind, finding.writers, finding.readers])).toEqual([
+      ['Cache', 'items', 'map', ['Put', 'Evict'], ['Get']],
+      ['Cache', 'order', 'slice', ['Put'], ['Evict']],
+    ]);
+    expect(findings[0]).toMatchObject({ rule: 'unsynchronized-field', severity: 'low', line: 6, column: 2 });
+    expect(findings[0].message).toContain('use a sync.Map');
+    expect(findings[1].message).toContain('guard it with a sync.RWMutex');
+  });
+
+  it('skips locked methods, types documented as unsafe and ignored types', () => {
+    const types = findUnsynchronizedFields([file]).map(finding => finding.type);
+    expect(types).not.toContain('Guarded');
+    expect(types).not.toContain('Builder');
+
+    expect(findUnsynchronizedFields([file], { ignoreTypes: ['Cache'] })).toEqual([]);
+  });
+
+  it('needs a write and a second method', () => {
+    const single = parseGoFile(
+      'package p\n\ntype T struct{ m map[string]int }\n\nfunc (t *T) Set() { t.m["a"] = len(t.m) }\n',
+      'p.go'
+    );
+
+    expect(findUnsynchronizedFields([single])).toEqual([]);
+  });
+
+  it('leaves the read-only DataProcessor cache alone', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+    expect(findUnsynchronizedFields([sample])).toEqual([]);
+    expect(defaultGoRuleRegistry().run([file]).filter(f => f.rule === 'unsynchronized-field')).toHaveLength(2);
+  });
+});
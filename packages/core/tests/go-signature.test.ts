@@ -0,0 +1,89 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { extractGoFileSymbols } from '../src/go/symbols';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+function signatureOf(source: string, name: string) {
+  const symbols = extractGoFileSymbols(parseGoFile(source, 'sig.go'));
+  return [...symbols.functions, ...symbols.methods].find((fn) => fn.name === name)?.signature;
+}
+
+describe('Go function signatures', () => {
+  it('should record parameter and result types from the fixture', () => {
+    const symbols = extractGoFileSymbols(
+      parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath)
+    );
+    const complex = symbols.functions.find((fn) => fn.name === 'ProcessComplexData');
+    const processData = symbols.methods.find((fn) => fn.name === 'ProcessData');
+
+    expect(complex?.signature).toEqual({
+      params: [{ name: 'input', type: '[]string', variadic: false }],
+      results: [
+        { type: '[]string', variadic: false },
+        { type: 'error', variadic: false }
+      ],
+      text: '([]string) ([]string, error)'
+    });
+    // The receiver is not part of the signature
+    expect(processData?.signature.text).toBe('([]string) []string');
+  });
+
+  it('should split grouped parameters and keep blank and unnamed ones', () => {
+    const source = `package p
+
+func Grouped(a, b int, _ string, c bool) (x, y int) { return }
+func Unnamed(int, string) {}
+`;
+    expect(signatureOf(source, 'Grouped')).toEqual({
+      params: [
+        { name: 'a', type: 'int', variadic: false },
+        { name: 'b', type: 'int', variadic: false },
+        { name: '_', type: 'string', variadic: false },
+        { name: 'c', type: 'bool', variadic: false }
+      ],
+      results: [
+        { name: 'x', type: 'int', variadic: false },
+        { name: 'y', type: 'int', variadic: false }
+      ],
+      text: '(int, int, string, bool) (int, int)'
+    });
+    expect(signatureOf(source, 'Unnamed')?.params).toEqual([
+      { type: 'int', variadic: false },
+      { type: 'string', variadic: false }
+    ]);
+  });
+
+  it('should mark variadic parameters with their element type', () => {
+    const source = 'package p\n\nfunc Join(sep string, parts ...[]byte) []byte { return nil }\n';
+    const signature = signatureOf(source, 'Join');
+
+    expect(signature?.params[1]).toEqual({ name: 'parts', type: '[]byte', variadic: true });
+    expect(signature?.text).toBe('(string, ...[]byte) []byte');
+  });
+
+  it('should print composite types canonically', () => {
+    const source = `package p
+
+import "context"
+
+func Run(
+	ctx context.Context, // the request context
+	jobs <-chan map[string]*Job,
+	done func(error) bool,
+	opts struct {
+		Retries int \`json:"retries"\`
+		Name    string
+	},
+) (interface{ Close() error }, [4]int) {
+	return nil, [4]int{}
+}
+`;
+    expect(signatureOf(source, 'Run')?.text).toBe(
+      '(context.Context, <-chan map[string]*Job, func(error) bool, ' +
+        'struct{Retries int `json:"retries"`; Name string}) (interface{Close() error}, [4]int)'
+    );
+  });
+});
@@ -0,0 +1,89 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { FuncDecl } from '../src/go/ast';
+import { parseGoFile } from '../src/go/parser';
+import { goFunctionSize } from '../src/go/size';
+import { extractGoFileSymbols } from '../src/go/symbols';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package main
+
+// Sum adds the values.
+//
+// Negative values are skipped.
+func Sum(values []int) (total int) {
+	// A plain loop; range would do too
+	for i := 0; i < len(values); i++ {
+		if v := values[i]; v > 0 { // positives only
+			total += v
+		}
+	}
+
+	/*
+	   block comments
+	   count per line
+	*/
+	return
+}
+
+func Each(values []int, fn func(int)) {
+outer:
+	for _, v := range values {
+		switch {
+		case v < 0:
+			break outer
+		default:
+			func() { fn(v) }()
+		}
+	}
+}
+`;
+
+const size = (name: string) => {
+  const file = parseGoFile(source, 'sum.go');
+  const decl = file.decls.find(
+    decl => decl.kind === 'FuncDecl' && decl.name.name === name
+  ) as FuncDecl;
+  return goFunctionSize(file, decl);
+};
+
+describe('Go function size', () => {
+  it('should report the size of the fixture functions on their symbols', () => {
+    const file = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const symbols = extractGoFileSymbols(file);
+    const processComplexData = symbols.functions.find(fn => fn.name === 'ProcessComplexData');
+
+    // Lines 62-81; the doc comment on line 61 is counted apart
+    expect(processComplexData.size).toEqual({
+      lines: 20,
+      codeLines: 17,
+      commentLines: 0,
+      docLines: 1,
+      statements: 9,
+    });
+    expect(symbols.methods.find(fn => fn.name === 'GetCacheSize').size.lines).toBe(3);
+  });
+
+  it('should count comment-only lines apart from code and the doc comment', () => {
+    expect(size('Sum')).toEqual({
+      lines: 14,
+      codeLines: 8,
+      commentLines: 5,
+      docLines: 3,
+      statements: 4,
+    });
+  });
+
+  it('should count statements in clauses, labels and function literals', () => {
+    // for, switch, break, the call of the literal and the call inside it
+    expect(size('Each')).toEqual({
+      lines: 11,
+      codeLines: 11,
+      commentLines: 0,
+      docLines: 0,
+      statements: 5,
+    });
+  });
+});
@@ -0,0 +1,148 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import {
+  diffSnapshots,
+  formatSnapshotMismatch,
+  generateSnapshotReplay,
+  instrumentSnapshots,
+  parseSnapshots,
+} from '../src/go/snapshot';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+const sample = () => parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+const workerSource = `package worker
+
+func Start(jobs chan int) int { return len(jobs) }
+
+func Handler(name string) (func(), error) { return func() {}, nil }
+
+func Join(sep string, parts ...string) string { return sep }
+`;
+
+describe('Go behavioral snapshots', () => {
+  it('should wrap targets in recording functions', () => {
+    const instrumentation = instrumentSnapshots(sample(), ['ProcessData']);
+    const { source } = instrumentation.result;
+
+    expect(instrumentation.functions).toEqual(['DataProcessor.ProcessData']);
+    expect(source).toContain(
+      'func (dp *DataProcessor) refactogentOriginalProcessData(data []string) []string {'
+    );
+    expect(source).toContain(
+      'func (r *DataProcessor) ProcessData(a0 []string) []string {\n' +
+        '\tsnapshot := refactogentSnapshotBegin(r, a0)\n' +
+        '\tr0 := r.refactogentOriginalProcessData(a0)\n' +
+        '\trefactogentSnapshotEnd(snapshot, "DataProcessor.ProcessData", r0)\n' +
+        '\treturn r0\n' +
+        '}'
+    );
+    expect(instrumentation.helper.path).toBe(
+      path.join(path.dirname(samplePath), 'refactogent_snapshot.go')
+    );
+    expect(instrumentation.helper.content).toContain('package main');
+    expect(instrumentation.helper.content).toContain('os.Getenv("REFACTOGENT_SNAPSHOT")');
+  });
+
+  it('should warn when receiver state cannot be captured', () => {
+    const { warnings } = instrumentSnapshots(sample(), ['DataProcessor.ProcessData']);
+
+    expect(warnings).toEqual([
+      {
+        function: 'DataProcessor.ProcessData',
+        message: 'receiver fields config, cache are unexported and replay with zero values',
+      },
+    ]);
+  });
+
+  it('should skip channels and functions with a warning', () => {
+    const file = parseGoFile(workerSource, 'worker.go');
+    const instrumentation = instrumentSnapshots(file, ['Start', 'Handler', 'Join']);
+
+    expect(instrumentation.functions).toEqual(['Handler', 'Join']);
+    expect(instrumentation.warnings.map(w => `${w.function}: ${w.message}`)).toEqual([
+      'Start: parameter jobs has type chan int, which cannot be captured',
+      'Handler: result 0 has type func(), which is not compared',
+    ]);
+    expect(instrumentation.result.source).toContain(
+      '\trefactogentSnapshotEnd(snapshot, "Handler", nil, r1)\n'
+    );
+    expect(instrumentation.result.source).toContain(
+      'func Join(a0 string, a1 ...string) string {\n' +
+        '\tsnapshot := refactogentSnapshotBegin(nil, a0, a1)\n' +
+        '\tr0 := refactogentOriginalJoin(a0, a1...)\n'
+    );
+  });
+
+  it('should generate a replay test for the refactored code', () => {
+    const replay = generateSnapshotReplay(sample(), ['ProcessData', 'ProcessComplexData']);
+    const { content } = replay.test;
+
+    expect(replay.test.path).toBe(
+      path.join(path.dirname(samplePath), 'refactogent_snapshot_replay_test.go')
+    );
+    expect(content).toContain('func TestRefactogentSnapshotReplay(t *testing.T) {');
+    expect(content).toContain(
+      '\tcase "DataProcessor.ProcessData":\n' +
+        '\t\tvar r *DataProcessor\n' +
+        '\t\trefactogentDecode(t, call.Receiver, &r)\n' +
+        '\t\tif r == nil {\n' +
+        '\t\t\tr = new(DataProcessor)\n' +
+        '\t\t}\n' +
+        '\t\tvar a0 []string\n' +
+        '\t\trefactogentDecode(t, call.Args[0], &a0)\n' +
+        '\t\tr0 := r.ProcessData(a0)\n' +
+        '\t\treturn refactogentEncode(r0)\n'
+    );
+    expect(content).toContain('\t\tr0, r1 := ProcessComplexData(a0)\n');
+  });
+
+  it('should report the smallest differing element', () => {
+    const recorded = parseSnapshots(
+      '{"function":"DataProcessor.ProcessData","args":[["a","bc"]],"results":[["A","BC"]]}\n' +
+        '{"function":"ProcessComplexData","args":[null],"results":[null,"input cannot be empty"]}\n'
+    );
+    const replayed = parseSnapshots(
+      '{"function":"DataProcessor.ProcessData","args":[["a","bc"]],"results":[["A","Bc"]]}\n' +
+        '{"function":"ProcessComplexData","args":[null],"results":[null,"input cannot be empty"]}\n'
+    );
+    const mismatches = diffSnapshots(recorded, replayed);
+
+    expect(mismatches).toHaveLength(1);
+    expect(mismatches[0]).toMatchObject({
+      call: 1,
+      path: 'results[0][1]',
+      expected: 'BC',
+      actual: 'Bc',
+    });
+    expect(formatSnapshotMismatch(mismatches[0])).toBe(
+      'call 1: DataProcessor.ProcessData(["a","bc"]): results[0][1] = "Bc", want "BC"'
+    );
+  });
+
+  it('should report missing elements, nested fields and panics', () => {
+    const recorded = parseSnapshots(
+      [
+        '{"function":"Split","args":["a,b"],"results":[["a","b"]]}',
+        '{"function":"Load","args":[],"results":[{"Name":"x","Tags":["t"]}]}',
+        '{"function":"Parse","args":["1"],"results":[1,null]}',
+      ].join('\n')
+    );
+    const replayed = parseSnapshots(
+      [
+        '{"function":"Split","args":["a,b"],"results":[["a"]]}',
+        '{"function":"Load","args":[],"results":[{"Name":"x","Tags":["u"]}]}',
+        '{"function":"Parse","args":["1"],"results":null,"panic":"boom"}',
+      ].join('\n')
+    );
+    const messages = diffSnapshots(recorded, replayed).map(formatSnapshotMismatch);
+
+    expect(messages).toEqual([
+      'call 1: Split("a,b"): results[0][1] = <missing>, want "b"',
+      'call 2: Load(): results[0].Tags[0] = "u", want "t"',
+      'call 3: Parse("1"): panicked: boom',
+    ]);
+  });
+});
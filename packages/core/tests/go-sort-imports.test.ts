@@ -0,0 +1,129 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { moveFunctions } from '../src/go/move-function';
+import { parseGoFile } from '../src/go/parser';
+import { isStandardImportPath, sortedImportDecl, sortGoImports } from '../src/go/sort-imports';
+import { useStringsBuilder } from '../src/go/string-builder';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const mixed = `package server
+
+// Imports for the server
+import (
+	"github.com/acme/log"
+	_ "net/http/pprof" // profiling endpoints
+	"fmt"
+
+	// Routing
+	mux "github.com/gorilla/mux"
+	. "github.com/acme/testing/dsl"
+	"fmt"
+	"context"
+)
+
+import "os"
+
+func main() {}
+`;
+
+describe('Go sort imports', () => {
+  it('should leave an import block that is already in order alone', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    const result = sortGoImports(sample);
+
+    expect(result.edits).toEqual([]);
+    expect(result.source).toBe(sample.source);
+    expect(result.standard).toEqual(['fmt', 'strings', 'time']);
+    expect(result.external).toEqual([]);
+  });
+
+  it('should group standard library imports first and drop duplicates', () => {
+    const result = sortGoImports(parseGoFile(mixed, 'server.go'));
+
+    expect(result.standard).toEqual(['context', 'fmt', 'net/http/pprof', 'os']);
+    expect(result.external).toEqual([
+      'github.com/acme/log',
+      'github.com/acme/testing/dsl',
+      'github.com/gorilla/mux',
+    ]);
+    expect(result.duplicates).toEqual(['fmt']);
+    expect(result.source).toBe(`package server
+
+// Imports for the server
+import (
+	"context"
+	"fmt"
+	_ "net/http/pprof" // profiling endpoints
+	"os"
+
+	"github.com/acme/log"
+	. "github.com/acme/testing/dsl"
+	// Routing
+	mux "github.com/gorilla/mux"
+)
+
+func main() {}
+`);
+    expect(sortGoImports(parseGoFile(result.source, 'server.go')).edits).toEqual([]);
+  });
+
+  it('should keep lone imports and cgo imports in place', () => {
+    const lone = parseGoFile('package main\n\nimport "fmt" // printing\n\nfunc main() {}\n');
+    expect(sortGoImports(lone).edits).toEqual([]);
+
+    const cgo = `package main
+
+// #include <stdio.h>
+import "C"
+
+import (
+	"unsafe"
+	"fmt"
+)
+`;
+    expect(sortGoImports(parseGoFile(cgo)).source).toBe(`package main
+
+// #include <stdio.h>
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+`);
+    expect(isStandardImportPath('golang.org/x/sync/errgroup')).toBe(false);
+    expect(isStandardImportPath('encoding/json')).toBe(true);
+  });
+
+  it('should run as a cleanup step for transforms that add imports', () => {
+    const source = `package main
+
+import (
+	"github.com/acme/log"
+	"fmt"
+)
+
+func Join(parts []string) string {
+	s := ""
+	for _, part := range parts {
+		s += part
+	}
+	log.Print(s)
+	return fmt.Sprint(s)
+}
+`;
+    const result = useStringsBuilder(parseGoFile(source, 'join.go'), {
+      line: 11,
+      sortImports: true,
+    });
+    expect(result.source).toContain('import (\n\t"fmt"\n\t"strings"\n\n\t"github.com/acme/log"\n)');
+
+    const moved = moveFunctions(parseGoFile(source, 'join.go'), 'join_util.go', ['Join'], {
+      sortImports: true,
+    });
+    expect(moved.destination.source).toContain('import (\n\t"fmt"\n\n\t"github.com/acme/log"\n)');
+    expect(sortedImportDecl([{ path: 'os' }, { path: 'os' }])).toBe('import "os"');
+  });
+});
@@ -0,0 +1,108 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+import { findFunctionSplits, previewFunctionSplit } from '../src/go/split-function';
+
+const fixture = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const report = `package report
+
+import "strings"
+
+func Build(rows []Row, title string) string {
+	if title == "" {
+		panic("no title")
+	}
+
+	header := strings.ToUpper(title)
+	width := len(header)
+	line := strings.Repeat("=", width)
+
+	var b strings.Builder
+	for _, r := range rows {
+		b.WriteString(r.Name)
+		b.WriteString("\\n")
+	}
+	body := b.String()
+	return header + "\\n" + line + "\\n" + body
+}
+`;
+
+describe('Go function splits', () => {
+  const sample = parseGoFile(fs.readFileSync(fixture, 'utf-8'), fixture);
+
+  it('splits the validation prologue of ProcessComplexData from its loop', () => {
+    const findings = findFunctionSplits([sample]);
+
+    expect(findings).toHaveLength(1);
+    expect(findings[0]).toMatchObject({
+      rule: 'split-function',
+      severity: 'low',
+      line: 62,
+      function: 'ProcessComplexData',
+      message:
+        'ProcessComplexData does 2 things in a row; consider extracting validation (lines 63-65) into validateProcessComplexData, loop (lines 67-78) into processInput',
+    });
+    expect(findings[0].segments).toEqual([
+      {
+        role: 'validation',
+        startLine: 63,
+        endLine: 65,
+        name: 'validateProcessComplexData',
+        inputs: ['input'],
+        outputs: [],
+      },
+      { role: 'loop', startLine: 67, endLine: 78, name: 'processInput', inputs: ['input'], outputs: ['results'] },
+    ]);
+  });
+
+  it('previews the split as a diff without applying it', () => {
+    const result = previewFunctionSplit(sample, { line: 62 });
+
+    expect(result.functions).toEqual(['validateProcessComplexData', 'processInput']);
+    expect(result.diff).toContain('+\tif err := validateProcessComplexData(input); err != nil {\n+\t\treturn nil, err\n');
+    expect(result.diff).toContain('+\tresults := processInput(input)\n');
+    expect(result.source).toContain('\nfunc processInput(input []string) []string {\n\tresults := make([]string, 0, len(input))\n');
+    expect(result.source.indexOf('func validateProcessComplexData')).toBeLessThan(
+      result.source.indexOf('func processInput')
+    );
+    expect(result.edits).toHaveLength(1);
+    expect(sample.source).not.toContain('validateProcessComplexData');
+  });
+
+  it('groups statements between the loop and the prologue into a step', () => {
+    const file = parseGoFile(report, 'report.go');
+    const [finding] = findFunctionSplits([file]);
+
+    expect(finding.segments.map(({ role, startLine, endLine, name, outputs }) => [role, startLine, endLine, name, outputs])).toEqual([
+      ['validation', 6, 8, 'validateBuild', []],
+      ['step', 10, 12, 'buildStep1', ['header', 'line']],
+      ['loop', 14, 18, 'processRows', ['b']],
+    ]);
+    const preview = previewFunctionSplit(file, { line: 5 });
+    expect(preview.source).toContain('\theader, line := buildStep1(title)\n');
+    expect(() => parseGoFile(preview.source, 'report.go')).not.toThrow();
+  });
+
+  it('leaves out segments that would pass too many variables', () => {
+    // The step leaves three variables for the statements after it
+    const file = parseGoFile(
+      report.replace('return header + "\\n" + line + "\\n" + body', 'return header + line + body + string(rune(width))'),
+      'report.go'
+    );
+    const [finding] = findFunctionSplits([file]);
+
+    expect(finding.segments.map((segment) => segment.role)).toEqual(['validation', 'loop']);
+  });
+
+  it('ignores short functions and refuses previews without a split', () => {
+    expect(() => previewFunctionSplit(sample, { line: 83 })).toThrow(GoRefactorError);
+    expect(() => previewFunctionSplit(sample, { line: 83 })).toThrow(
+      'No function declared on line 83 has a split to suggest'
+    );
+    const short = parseGoFile('package p\n\nfunc f(a int) int {\n\tif a < 0 {\n\t\treturn 0\n\t}\n\tfor a > 10 {\n\t\ta--\n\t}\n\treturn a\n}\n', 'p.go');
+    expect(findFunctionSplits([short])).toEqual([]);
+  });
+});
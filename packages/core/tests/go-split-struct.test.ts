@@ -0,0 +1,91 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findStructSplits } from '../src/go/split-struct';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package app
+
+type Server struct {
+	addr     string
+	port     int
+	dbURL    string
+	dbPool   *Pool
+	cache    map[string]string
+	cacheTTL int
+	legacy   bool
+	sync.Mutex
+}
+
+func (s *Server) Listen() string { return s.addr + ":" + itoa(s.port) }
+
+func (s *Server) Restart() { s.Listen() }
+
+func (s *Server) Query(q string) { s.dbPool.Run(s.dbURL, q) }
+
+func (s *Server) Get(k string) string {
+	s.Lock()
+	defer s.Mutex.Unlock()
+	return s.cache[k]
+}
+
+func (s *Server) Expire() { s.cacheTTL = 0; s.cache = nil }
+
+func (s *Server) Name(o *Server) string {
+	if o != nil {
+		s := o
+		return s.addr
+	}
+	return "server"
+}
+`;
+
+describe('Go struct splitting', () => {
+  const file = parseGoFile(source, 'server.go');
+
+  it('proposes parts from the fields each method set uses', () => {
+    const [finding] = findStructSplits([file]);
+
+    expect(finding).toMatchObject({
+      rule: 'split-struct',
+      severity: 'low',
+      line: 3,
+      column: 6,
+      type: 'Server',
+      unusedFields: ['legacy'],
+      fieldlessMethods: ['Name'],
+    });
+    // Restart joins Listen through the call; Get uses the embedded mutex
+    expect(finding.clusters).toEqual([
+      { fields: ['cache', 'cacheTTL', 'Mutex'], methods: ['Get', 'Expire'] },
+      { fields: ['addr', 'port'], methods: ['Listen', 'Restart'] },
+      { fields: ['dbURL', 'dbPool'], methods: ['Query'] },
+    ]);
+    expect(finding.message).toContain('Server has 3 disjoint field clusters');
+    expect(defaultGoRuleRegistry().run([file]).map(f => f.rule)).toContain('split-struct');
+  });
+
+  it('keeps structs whose methods share fields', () => {
+    const shared = source.replace(
+      'func (s *Server) Expire() { s.cacheTTL = 0; s.cache = nil }',
+      'func (s *Server) Expire() { s.cache = nil; s.dbURL = s.addr + itoa(s.cacheTTL) }'
+    );
+
+    expect(findStructSplits([parseGoFile(shared, 'server.go')])).toEqual([]);
+  });
+
+  it('honors the size limits', () => {
+    expect(findStructSplits([file], { minFields: 9 })).toEqual([]);
+    expect(findStructSplits([file], { minClusterFields: 3 })).toEqual([]);
+  });
+
+  it('leaves small structs such as DataProcessor alone', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+
+    expect(findStructSplits([sample])).toEqual([]);
+    expect(findStructSplits([sample], { minFields: 1, minClusterFields: 1 })).toEqual([]);
+  });
+});
@@ -0,0 +1,125 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+import { findUnnecessarySprintf, useStrconv } from '../src/go/sprintf';
+
+const source = `package ids
+
+import (
+	"fmt"
+	"os"
+)
+
+type Code int
+
+func Format(n int, big int64, small uint8, ratio float64, ok bool, name string, code Code) []string {
+	return []string{
+		fmt.Sprintf("%d", n),
+		fmt.Sprintf("id-%d", big),
+		fmt.Sprintf("%v%%", small),
+		fmt.Sprintf("%v", ratio),
+		fmt.Sprintf("%t", ok),
+		fmt.Sprintf("user %s!", name),
+		fmt.Sprintf("%s", name+"x")[1:],
+		fmt.Sprintf("%d", code),
+		fmt.Sprintf("%5d", n),
+		fmt.Sprintf("%d-%d", n, n),
+		fmt.Sprintf("%s", n),
+		fmt.Sprintf("%x", n),
+	}
+}
+
+func Open(name string) {
+	os.Open(fmt.Sprintf("/tmp/%s", name))
+}
+`;
+
+describe('Go Sprintf conversions', () => {
+  const file = parseGoFile(source, 'ids.go');
+
+  it('flags single-verb Sprintf calls over basic types with their replacement', () => {
+    const findings = findUnnecessarySprintf([file]);
+
+    expect(findings.map((finding) => [finding.line, finding.fix])).toEqual([
+      [12, 'strconv.Itoa(n)'],
+      [13, '"id-" + strconv.FormatInt(big, 10)'],
+      [14, 'strconv.FormatUint(uint64(small), 10) + "%"'],
+      [15, "strconv.FormatFloat(ratio, 'g', -1, 64)"],
+      [16, 'strconv.FormatBool(ok)'],
+      [17, '"user " + name + "!"'],
+      [18, '(name+"x")'],
+      [28, '"/tmp/" + name'],
+    ]);
+    expect(findings[0]).toMatchObject({
+      rule: 'unnecessary-sprintf',
+      severity: 'low',
+      message: 'fmt.Sprintf("%d", n) formats one value; use strconv.Itoa(n)',
+    });
+  });
+
+  it('rewrites every call, adding strconv and keeping fmt while it is used', () => {
+    const result = useStrconv(file);
+
+    expect(result.rewritten).toHaveLength(8);
+    expect(result.importsAdded).toEqual(['strconv']);
+    expect(result.importsRemoved).toEqual([]);
+    expect(result.source).toContain('import (\n\t"fmt"\n\t"os"\n\t"strconv"\n)');
+    expect(result.source).toContain('\t\t"id-" + strconv.FormatInt(big, 10),\n');
+    expect(result.source).toContain('\t\t(name+"x")[1:],\n');
+    expect(result.source).toContain('\t\tfmt.Sprintf("%d", code),\n');
+    expect(findUnnecessarySprintf([parseGoFile(result.source, 'ids.go')])).toEqual([]);
+  });
+
+  it('replaces fmt with strconv once nothing else uses it', () => {
+    const lone = parseGoFile(
+      'package ids\n\nimport "fmt"\n\nfunc Key(n int) string {\n\treturn fmt.Sprintf("k%d", n)\n}\n',
+      'key.go'
+    );
+    const replaced = useStrconv(lone);
+    expect(replaced.source).toBe(
+      'package ids\n\nimport "strconv"\n\nfunc Key(n int) string {\n\treturn "k" + strconv.Itoa(n)\n}\n'
+    );
+    expect(replaced.importsRemoved).toEqual(['fmt']);
+
+    const grouped = parseGoFile(
+      'package ids\n\nimport (\n\t"fmt"\n\t"os"\n)\n\nfunc Name(name string) string {\n\tos.Exit(0)\n\treturn fmt.Sprintf("%s!", name)\n}\n',
+      'name.go'
+    );
+    expect(useStrconv(grouped).source).toBe(
+      'package ids\n\nimport (\n\t"os"\n)\n\nfunc Name(name string) string {\n\tos.Exit(0)\n\treturn name + "!"\n}\n'
+    );
+  });
+
+  it('rewrites one line on request and refuses lines it cannot rewrite', () => {
+    const result = useStrconv(file, { line: 16 });
+    expect(result.rewritten).toEqual([{ line: 16, column: 3 }]);
+    expect(result.source).toContain('\t\tstrconv.FormatBool(ok),\n');
+    expect(result.source).toContain('\t\tfmt.Sprintf("%d", n),\n');
+
+    expect(() => useStrconv(file, { line: 20 })).toThrow(GoRefactorError);
+    const shadowed = parseGoFile(
+      'package ids\n\nimport "fmt"\n\nfunc Key(strconv, n int) string {\n\treturn fmt.Sprintf("%d", n)\n}\n',
+      'key.go'
+    );
+    expect(findUnnecessarySprintf([shadowed])[0].fix).toBeUndefined();
+    expect(() => useStrconv(shadowed, { line: 6 })).toThrow(
+      'Cannot use strconv: a local variable hides the strconv package'
+    );
+  });
+
+  it('turns the Sprintf calls of processTypeA and processTypeB into concatenation', () => {
+    const fixture = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+    const sample = parseGoFile(fs.readFileSync(fixture, 'utf-8'), fixture);
+    const fixes = findUnnecessarySprintf([sample]).map((finding) => finding.fix);
+
+    expect(fixes).toEqual(
+      expect.arrayContaining([
+        '"A_LONG_" + item',
+        '"A_SHORT_" + item',
+        '"B_" + strings.ToUpper(item)',
+      ])
+    );
+  });
+});
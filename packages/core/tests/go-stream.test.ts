@@ -0,0 +1,103 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { IncrementalGoAnalyzer } from '../src/go/cache';
+import { analyzeGoStream, GoStreamResult } from '../src/go/stream';
+
+// Records how many files are analyzed at once
+class TrackingAnalyzer extends IncrementalGoAnalyzer {
+  running = 0;
+  peak = 0;
+  started = 0;
+
+  async analyzeFile(filePath: string, content?: string) {
+    this.started++;
+    this.peak = Math.max(this.peak, ++this.running);
+    await new Promise(resolve => setTimeout(resolve, 5));
+    try {
+      return await super.analyzeFile(filePath, content);
+    } finally {
+      this.running--;
+    }
+  }
+}
+
+describe('Go streaming analysis', () => {
+  let tempDir: string;
+  let files: string[];
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-stream-'));
+    files = [];
+    for (let i = 0; i < 12; i++) {
+      const filePath = path.join(tempDir, `f${i}.go`);
+      fs.writeFileSync(filePath, `package main\n\nfunc F${i}() int { return ${i} }\n`);
+      files.push(filePath);
+    }
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  const collect = async (stream: AsyncIterable<GoStreamResult>) => {
+    const results: GoStreamResult[] = [];
+    for await (const result of stream) results.push(result);
+    return results;
+  };
+
+  it('should yield every file with bounded concurrency', async () => {
+    const analyzer = new TrackingAnalyzer();
+    const results = await collect(analyzeGoStream(files, { concurrency: 3, analyzer }));
+
+    expect(results.map(result => result.index).sort((a, b) => a - b)).toEqual(
+      files.map((_, index) => index)
+    );
+    expect(analyzer.peak).toBe(3);
+    const first = results.find(result => result.index === 0);
+    expect(first.ok && first.symbols.functions.map(fn => fn.name)).toEqual(['F0']);
+  });
+
+  it('should report failing files in-band and keep going', async () => {
+    fs.writeFileSync(files[4], 'package main\n\nfunc broken( {\n');
+    const missing = path.join(tempDir, 'missing.go');
+    const results = await collect(analyzeGoStream([...files, missing], { concurrency: 4 }));
+
+    const failed = results.filter(result => !result.ok).map(result => result.filePath);
+    expect(failed.sort()).toEqual([files[4], missing].sort());
+    expect(results.filter(result => result.ok)).toHaveLength(11);
+  });
+
+  it('should stop taking files when aborted', async () => {
+    const analyzer = new TrackingAnalyzer();
+    const controller = new AbortController();
+    const seen: GoStreamResult[] = [];
+
+    await expect(
+      (async () => {
+        for await (const result of analyzeGoStream(files, {
+          concurrency: 2,
+          analyzer,
+          signal: controller.signal,
+        })) {
+          seen.push(result);
+          if (seen.length === 2) controller.abort();
+        }
+      })()
+    ).rejects.toThrow();
+    expect(seen).toHaveLength(2);
+    expect(analyzer.started).toBeLessThan(files.length);
+  });
+
+  it('should pause workers when the consumer stops reading', async () => {
+    const analyzer = new TrackingAnalyzer();
+    for await (const result of analyzeGoStream(files, { concurrency: 2, analyzer })) {
+      expect(result.ok).toBe(true);
+      break;
+    }
+    await new Promise(resolve => setTimeout(resolve, 50));
+
+    expect(analyzer.started).toBeLessThanOrEqual(5);
+  });
+});
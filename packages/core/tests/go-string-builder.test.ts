@@ -0,0 +1,156 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+import { findStringConcatInLoops, useStringsBuilder } from '../src/go/string-builder';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package report
+
+import "fmt"
+
+func Render(items []string) string {
+	out := "items:"
+	for i, item := range items {
+		if len(out) > 100 {
+			break
+		}
+		out += fmt.Sprintf("%d=%s", i, item)
+		out = out + ", " + item
+	}
+	out += "."
+	return out
+}
+
+func Join(words []string) (s string) {
+	for _, w := range words {
+		s += w
+	}
+	return
+}
+
+func Reset(words []string) string {
+	var acc string
+	for _, w := range words {
+		acc += w
+		if w == "" {
+			acc = ""
+		}
+	}
+	return acc
+}
+
+func Pointer(words []string) string {
+	var acc = ""
+	for _, w := range words {
+		acc += w
+	}
+	show(&acc)
+	return acc
+}
+
+func Once(a, b string) string {
+	s := a
+	s += b
+	return s
+}
+
+func Count(n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += i
+	}
+	return total
+}
+
+func Closure(words []string) string {
+	text := ""
+	for _, w := range words {
+		text += w
+	}
+	f := func() string { return text }
+	return f()
+}
+
+func Nested(rows [][]string) string {
+	var b string
+	for _, row := range rows {
+		for _, cell := range row {
+			b += cell
+		}
+		b += "\\n"
+	}
+	return b
+}
+
+func show(*string) {}
+`;
+
+const file = () => parseGoFile(source, 'report.go');
+
+describe('Go strings.Builder transform', () => {
+  it('should flag strings concatenated inside loops', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    expect(findStringConcatInLoops([sample])).toEqual([]);
+
+    const findings = findStringConcatInLoops([file()]);
+    expect(findings.map(finding => [finding.variable, finding.line, finding.loopLine])).toEqual([
+      ['out', 11, 7],
+      ['b', 72, 70],
+    ]);
+    expect(findings[0].message).toBe(
+      'out is concatenated in the loop on line 7, copying it on every iteration; build it with a strings.Builder'
+    );
+  });
+
+  it('should introduce a builder, write to it and read it with String', () => {
+    const result = useStringsBuilder(file(), { line: 11 });
+
+    expect([result.variable, result.builder, result.writes, result.reads]).toEqual(['out', 'b', 3, 2]);
+    expect(result.source).toContain('import (\n\t"fmt"\n\t"strings"\n)');
+    expect(result.source).toContain(
+      [
+        'func Render(items []string) string {',
+        '\tvar b strings.Builder',
+        '\tb.WriteString("items:")',
+        '\tfor i, item := range items {',
+        '\t\tif len(b.String()) > 100 {',
+        '\t\t\tbreak',
+        '\t\t}',
+        '\t\tb.WriteString(fmt.Sprintf("%d=%s", i, item))',
+        '\t\tb.WriteString(", " + item)',
+        '\t}',
+        '\tb.WriteString(".")',
+        '\treturn b.String()',
+        '}',
+      ].join('\n')
+    );
+  });
+
+  it('should pick a builder name that does not collide', () => {
+    const result = useStringsBuilder(file(), { line: 74 });
+
+    expect(result.builder).toBe('sb');
+    expect(result.source).toContain('\tvar sb strings.Builder\n\tfor _, row := range rows {');
+    expect(result.source).toContain('\t\tsb.WriteString("\\n")\n\t}\n\treturn sb.String()');
+  });
+
+  it('should not flag or rewrite accumulators the builder cannot replace', () => {
+    const variables = findStringConcatInLoops([file()]).map(finding => finding.variable);
+    // named results, reassignments, address-taken and captured strings, ints and code outside loops
+    for (const name of ['s', 'acc', 'text', 'total']) expect(variables).not.toContain(name);
+
+    expect(() => useStringsBuilder(file(), { line: 20 })).toThrow(
+      'Cannot use a strings.Builder: s is a named result'
+    );
+    expect(() => useStringsBuilder(file(), { line: 28 })).toThrow(
+      'Cannot use a strings.Builder: acc is reassigned on line 30'
+    );
+    expect(() => useStringsBuilder(file(), { line: 39 })).toThrow(
+      'Cannot use a strings.Builder: acc has its address taken on line 41'
+    );
+    expect(() => useStringsBuilder(file(), { line: 47 })).toThrow(GoRefactorError);
+  });
+});
@@ -0,0 +1,86 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+import { goSarifLog } from '../src/go/sarif';
+import { applyGoSuppressions, parseGoSuppressions } from '../src/go/suppress';
+
+const source = `package calc
+
+import (
+	"os" //refactogent:ignore unused-import kept for its init side effects
+	"strings"
+)
+
+// split cuts s at the first comma
+//refactogent:ignore naked-return kept short for the benchmark
+func split(s string) (head, tail string) {
+	head, tail, _ = strings.Cut(s, ",")
+	head = strings.TrimSpace(head)
+	tail = strings.TrimSpace(tail)
+	head = strings.ToLower(head)
+	return
+}
+
+func join(a, b string) (out string) {
+	out = a + b
+	out = strings.TrimSpace(out)
+	out = strings.ToLower(out)
+	out = strings.ToUpper(out)
+	return
+}
+
+//refactogent:ignore todo-comment,naked-return
+func clean() {}
+
+//refactogent:ignore no-such-rule because
+var limit = 1
+`;
+
+const file = () => parseGoFile(source, '/repo/calc/calc.go');
+
+describe('Go inline suppressions', () => {
+  it('parses directives with the lines they cover', () => {
+    expect(parseGoSuppressions(file()).map(s => [s.rule, s.reason, s.line, s.startLine, s.endLine])).toEqual([
+      ['unused-import', 'kept for its init side effects', 4, 4, 4],
+      ['naked-return', 'kept short for the benchmark', 9, 10, 16],
+      ['todo-comment', '', 26, 27, 27],
+      ['naked-return', '', 26, 27, 27],
+      ['no-such-rule', 'because', 29, 30, 30],
+    ]);
+  });
+
+  it('silences covered findings and keeps the reason', () => {
+    const { findings, suppressed } = defaultGoRuleRegistry().runWithSuppressions([file()]);
+
+    expect(suppressed.map(({ finding, suppression }) => [finding.rule, finding.line, suppression.reason])).toEqual([
+      ['unused-import', 4, 'kept for its init side effects'],
+      ['naked-return', 15, 'kept short for the benchmark'],
+    ]);
+    expect(findings.filter(f => f.rule === 'naked-return').map(f => f.line)).toEqual([23]);
+  });
+
+  it('reports directives that silence nothing', () => {
+    const unused = defaultGoRuleRegistry()
+      .run([file()])
+      .filter(f => f.rule === 'unused-suppression');
+
+    expect(unused.map(f => [f.line, f.message])).toEqual([
+      [26, '//refactogent:ignore naked-return matches no finding; remove it'],
+      [26, '//refactogent:ignore todo-comment matches no finding; remove it'],
+      [29, '//refactogent:ignore names unknown rule no-such-rule'],
+    ]);
+    const registry = defaultGoRuleRegistry().disable('todo-comment');
+    expect(registry.run([file()]).filter(f => f.rule === 'unused-suppression')).toHaveLength(2);
+    expect(applyGoSuppressions([], [file()]).unused).toHaveLength(5);
+  });
+
+  it('writes suppressed findings to SARIF with their justification', () => {
+    const { findings, suppressed } = defaultGoRuleRegistry().runWithSuppressions([file()]);
+    const [run] = goSarifLog(findings, [file()], { root: '/repo', suppressed }).runs;
+
+    expect(run.results.filter(result => result.suppressions)).toMatchObject([
+      { ruleId: 'unused-import', suppressions: [{ kind: 'inSource', justification: 'kept for its init side effects' }] },
+      { ruleId: 'naked-return', suppressions: [{ kind: 'inSource', justification: 'kept short for the benchmark' }] },
+    ]);
+  });
+});
@@ -0,0 +1,83 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { indexGoPositions } from '../src/go/symbol-at';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const model = `package shop
+
+type Item struct{ Name string }
+
+func (i Item) Label() string { return "é" + i.Name }
+`;
+
+const usePath = path.join(path.sep, 'src', 'shop', 'use.go');
+const use = `package shop
+
+import str "strings"
+
+func Shout(item Item) string {
+	label := item.Label()
+	return str.ToUpper(label)
+}
+`;
+
+describe('Go symbols by position', () => {
+  const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+  const index = indexGoPositions([
+    sample,
+    parseGoFile(model, path.join(path.sep, 'src', 'shop', 'model.go')),
+    parseGoFile(use, usePath),
+  ]);
+
+  it('returns the function around a position inside a switch', () => {
+    const at = index.symbolAt(samplePath, 70, 3);
+
+    expect(at.enclosing).toMatchObject({ name: 'ProcessComplexData', startLine: 62 });
+    expect(at.identifier).toBeUndefined();
+    expect(at.declarations).toEqual([]);
+  });
+
+  it('resolves identifiers to their declarations', () => {
+    const call = index.symbolAt(samplePath, 72, 32);
+    expect(call.identifier).toBe('processTypeA');
+    expect(call.declarations).toEqual([
+      { name: 'processTypeA', kind: 'function', filePath: samplePath, line: 84, column: 6 },
+    ]);
+
+    const local = index.symbolAt(samplePath, 76, 56);
+    expect(local.identifier).toBe('i');
+    expect(local.declarations).toMatchObject([{ kind: 'variable', line: 69, column: 6 }]);
+
+    const field = index.symbolAt(samplePath, 44, 17);
+    expect(field.enclosing).toMatchObject({ qualifiedName: 'DataProcessor.GetCacheSize' });
+    expect(field.declarations).toMatchObject([{ name: 'cache', kind: 'field', line: 12 }]);
+
+    expect(index.symbolAt(samplePath, 39, 10).declarations).toMatchObject([
+      { name: 'strings', kind: 'import', line: 5, column: 2 },
+    ]);
+    expect(index.symbolAt(samplePath, 39, 18).declarations).toEqual([]);
+  });
+
+  it('resolves across the files of a package', () => {
+    expect(index.symbolAt(usePath, 5, 18).declarations).toMatchObject([
+      { name: 'Item', kind: 'type', filePath: path.join(path.sep, 'src', 'shop', 'model.go'), line: 3 },
+    ]);
+    expect(index.symbolAt(usePath, 6, 16).declarations).toMatchObject([{ name: 'Label', kind: 'method' }]);
+    expect(index.symbolAt(usePath, 7, 9).declarations).toMatchObject([{ name: 'str', kind: 'import', line: 3 }]);
+  });
+
+  it('counts columns in bytes and finds types and methods', () => {
+    const modelPath = path.join(path.sep, 'src', 'shop', 'model.go');
+    // "é" takes two bytes, so Name starts two columns after where it would
+    const name = index.symbolAt(modelPath, 5, 48);
+    expect(name.identifier).toBe('Name');
+    expect(name.enclosing).toMatchObject({ qualifiedName: 'Item.Label' });
+
+    expect(index.symbolAt(modelPath, 3, 20).enclosing).toMatchObject({ name: 'Item', typeKind: 'struct' });
+    expect(index.symbolAt(modelPath, 1, 1).enclosing).toBeUndefined();
+    expect(index.symbolAt('missing.go', 1, 1)).toBeUndefined();
+  });
+});
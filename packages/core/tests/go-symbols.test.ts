@@ -0,0 +1,380 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import {
+  extractGoFileSymbols,
+  goFieldSet,
+  goMethodSet,
+  goVisibility,
+  isPackageLocal,
+  parseStructTag,
+  Visibility,
+} from '../src/go/symbols';
+
+const fixturesPath = path.join(__dirname, 'fixtures', 'go');
+
+function loadSymbols(fileName: string) {
+  const filePath = path.join(fixturesPath, fileName);
+  const content = fs.readFileSync(filePath, 'utf-8');
+  return extractGoFileSymbols(parseGoFile(content, filePath));
+}
+
+describe('Go symbol extraction', () => {
+  describe('Receivers', () => {
+    it('should separate methods from free functions', () => {
+      const symbols = loadSymbols('sample.go');
+
+      expect(symbols.functions.map(f => f.name)).toEqual([
+        'NewDataProcessor',
+        'CalculateFibonacci',
+        'ProcessComplexData',
+        'processTypeA',
+        'processTypeB',
+        'privateHelper',
+      ]);
+      expect(symbols.methods.map(m => m.name)).toEqual([
+        'ProcessData',
+        'processItem',
+        'GetCacheSize',
+      ]);
+    });
+
+    it('should record the receiver type and pointer flag', () => {
+      const symbols = loadSymbols('sample.go');
+      const processData = symbols.methods.find(m => m.name === 'ProcessData');
+
+      expect(processData?.receiver).toEqual({
+        name: 'dp',
+        typeName: 'DataProcessor',
+        isPointer: true,
+      });
+      expect(processData?.qualifiedName).toBe('DataProcessor.ProcessData');
+      expect(symbols.functions.every(f => f.receiver === undefined)).toBe(true);
+    });
+
+    it('should distinguish value and pointer receivers', () => {
+      const symbols = loadSymbols('embedded.go');
+      const describeMethod = symbols.methods.find(m => m.qualifiedName === 'Base.Describe');
+      const rename = symbols.methods.find(m => m.qualifiedName === 'Base.Rename');
+
+      expect(describeMethod?.receiver?.isPointer).toBe(false);
+      expect(rename?.receiver?.isPointer).toBe(true);
+    });
+
+    it('should group methods under the struct they belong to', () => {
+      const symbols = loadSymbols('sample.go');
+      const processor = symbols.types.find(t => t.name === 'DataProcessor');
+
+      expect(processor?.typeKind).toBe('struct');
+      expect(processor?.methods.map(m => m.name)).toEqual([
+        'ProcessData',
+        'processItem',
+        'GetCacheSize',
+      ]);
+    });
+
+    it('should record accurate spans and documentation', () => {
+      const symbols = loadSymbols('sample.go');
+      const fib = symbols.functions.find(f => f.name === 'CalculateFibonacci');
+
+      expect(fib?.startLine).toBe(48);
+      expect(fib?.endLine).toBe(59);
+      expect(fib?.documentation).toBe('CalculateFibonacci calculates the nth Fibonacci number');
+    });
+  });
+
+  describe('Method sets', () => {
+    it('should include only value-receiver methods for the value type', () => {
+      const symbols = loadSymbols('sample.go');
+
+      expect(goMethodSet(symbols.types, 'DataProcessor', false)).toEqual([]);
+      expect(goMethodSet(symbols.types, 'DataProcessor', true).map(m => m.name)).toEqual([
+        'GetCacheSize',
+        'ProcessData',
+        'processItem',
+      ]);
+    });
+
+    it('should record embedded fields', () => {
+      const symbols = loadSymbols('embedded.go');
+      const circle = symbols.types.find(t => t.name === 'Circle');
+
+      expect(circle?.embedded).toEqual([
+        { typeName: 'Base', isPointer: false },
+        { typeName: 'Logger', isPointer: true },
+      ]);
+    });
+
+    it('should promote methods from embedded structs', () => {
+      const symbols = loadSymbols('embedded.go');
+      const valueSet = goMethodSet(symbols.types, 'Circle', false);
+
+      // Value embedding promotes only Base's value methods; pointer embedding
+      // promotes all of Logger's methods
+      expect(valueSet.map(m => m.name)).toEqual(['Area', 'Describe', 'Log']);
+      expect(valueSet.find(m => m.name === 'Log')?.promotedFrom).toBe('Logger');
+    });
+
+    it('should let declared methods shadow promoted ones', () => {
+      const symbols = loadSymbols('embedded.go');
+      const pointerSet = goMethodSet(symbols.types, 'Circle', true);
+      const describeEntry = pointerSet.find(m => m.name === 'Describe');
+
+      expect(pointerSet.map(m => m.name)).toEqual(['Area', 'Describe', 'Log', 'Rename', 'Scale']);
+      expect(describeEntry?.receiver.typeName).toBe('Circle');
+      expect(describeEntry?.promotedFrom).toBeUndefined();
+    });
+
+    it('should not promote methods that are ambiguous at their depth', () => {
+      const source = `package p
+
+type Reader struct{}
+
+func (Reader) Close() error { return nil }
+func (Reader) Read() {}
+
+type Writer struct{}
+
+func (*Writer) Close() error { return nil }
+func (Writer) Write() {}
+
+type File struct {
+	Reader
+	Writer
+}
+`;
+      const symbols = extractGoFileSymbols(parseGoFile(source, 'p.go'));
+
+      // Writer's Close is outside the value set but still collides
+      expect(goMethodSet(symbols.types, 'File', false).map(m => m.name)).toEqual(['Read', 'Write']);
+      expect(goMethodSet(symbols.types, 'File', true).map(m => m.name)).toEqual(['Read', 'Write']);
+    });
+
+    it('should let fields at a shallower depth hide promoted methods', () => {
+      const source = `package p
+
+type Inner struct{}
+
+func (Inner) Name() string { return "" }
+func (Inner) Size() int { return 0 }
+
+type Middle struct {
+	Inner
+	Size int
+}
+
+type Outer struct {
+	Middle
+	Name string
+}
+`;
+      const symbols = extractGoFileSymbols(parseGoFile(source, 'p.go'));
+
+      expect(goMethodSet(symbols.types, 'Middle', false).map(m => m.name)).toEqual(['Name']);
+      expect(goMethodSet(symbols.types, 'Outer', true)).toEqual([]);
+    });
+  });
+
+  describe('Struct fields', () => {
+    it('should record field names, canonical types and visibility', () => {
+      const symbols = loadSymbols('sample.go');
+      const processor = symbols.types.find(t => t.name === 'DataProcessor');
+
+      expect(processor?.fields.map(f => [f.name, f.type, f.visibility, f.line])).toEqual([
+        ['config', 'map[string]string', Visibility.Unexported, 11],
+        ['cache', 'map[string]interface{}', Visibility.Unexported, 12],
+      ]);
+    });
+
+    it('should parse tags, split grouped names and describe anonymous structs', () => {
+      const source = `package p
+
+type Config struct {
+	// Name is shown in logs
+	Name, Alias string \`json:"name,omitempty" yaml:"name"\`
+	Limits      *struct {
+		Max int \`json:"max"\`
+	}
+	hidden bool
+}
+`;
+      const symbols = extractGoFileSymbols(parseGoFile(source, 'p.go'));
+      const [name, alias, limits, hidden] = symbols.types[0].fields;
+
+      expect(name).toMatchObject({
+        name: 'Name',
+        type: 'string',
+        tag: 'json:"name,omitempty" yaml:"name"',
+        tags: { json: 'name,omitempty', yaml: 'name' },
+        documentation: 'Name is shown in logs',
+        line: 5,
+        column: 2,
+      });
+      expect(alias.name).toBe('Alias');
+      expect(alias.tags).toEqual(name.tags);
+      expect(limits.type).toBe('*struct{Max int `json:"max"`}');
+      expect(limits.fields).toMatchObject([{ name: 'Max', type: 'int', tags: { json: 'max' } }]);
+      expect(hidden).toMatchObject({ visibility: Visibility.Unexported, tags: {} });
+      expect(hidden.tag).toBeUndefined();
+    });
+
+    it('should parse struct tags like reflect.StructTag', () => {
+      expect(parseStructTag('json:"a\\"b" xml:"c"')).toEqual({ json: 'a"b', xml: 'c' });
+      // The first occurrence of a key wins, and parsing stops at a malformed pair
+      expect(parseStructTag('json:"a" json:"b" bad yaml:"c"')).toEqual({ json: 'a' });
+      expect(parseStructTag('')).toEqual({});
+    });
+
+    it('should name embedded fields after their type and promote their fields', () => {
+      const symbols = loadSymbols('embedded.go');
+      const circle = symbols.types.find(t => t.name === 'Circle');
+
+      expect(circle?.fields.map(f => [f.name, f.type, f.embedded])).toEqual([
+        ['Base', 'Base', true],
+        ['Logger', '*Logger', true],
+        ['Radius', 'float64', false],
+      ]);
+      expect(goFieldSet(symbols.types, 'Circle')).toEqual([
+        { name: 'Base', type: 'Base', depth: 0, via: [] },
+        { name: 'Logger', type: '*Logger', depth: 0, via: [] },
+        { name: 'Radius', type: 'float64', depth: 0, via: [] },
+        { name: 'ID', type: 'string', depth: 1, via: ['Base'] },
+        { name: 'lines', type: '[]string', depth: 1, via: ['Logger'] },
+      ]);
+    });
+
+    it('should not promote fields that are ambiguous or shadowed', () => {
+      const source = `package p
+
+type A struct{ ID, Name string }
+
+type B struct{ ID int }
+
+type C struct {
+	A
+	B
+	Name []byte
+}
+`;
+      const symbols = extractGoFileSymbols(parseGoFile(source, 'p.go'));
+      const names = goFieldSet(symbols.types, 'C').map(f => `${f.name} ${f.type}`);
+
+      expect(names).toEqual(['A A', 'B B', 'Name []byte']);
+    });
+  });
+
+  describe('Visibility', () => {
+    it('should classify every symbol by its first rune', () => {
+      const symbols = loadSymbols('sample.go');
+      const all = [...symbols.functions, ...symbols.methods, ...symbols.types];
+      const visibility = Object.fromEntries(all.map(s => [s.name, s.visibility]));
+
+      expect(visibility.ProcessData).toBe(Visibility.Exported);
+      expect(visibility.DataProcessor).toBe(Visibility.Exported);
+      expect(visibility.processItem).toBe(Visibility.Unexported);
+      expect(visibility.privateHelper).toBe(Visibility.Unexported);
+      expect(visibility.processTypeA).toBe(Visibility.Unexported);
+    });
+
+    it('should handle underscores, single letters and non-ASCII names', () => {
+      expect(goVisibility('_Hidden')).toBe(Visibility.Unexported);
+      expect(goVisibility('_')).toBe(Visibility.Unexported);
+      expect(goVisibility('X')).toBe(Visibility.Exported);
+      expect(goVisibility('x')).toBe(Visibility.Unexported);
+      expect(goVisibility('Ähnlich')).toBe(Visibility.Exported);
+      expect(goVisibility('école')).toBe(Visibility.Unexported);
+      // Letters without case, such as CJK, are never exported
+      expect(goVisibility('名前')).toBe(Visibility.Unexported);
+      // Titlecase letters are not uppercase to unicode.IsUpper
+      expect(goVisibility('ǅemal')).toBe(Visibility.Unexported);
+      expect(goVisibility('𝐀lpha')).toBe(Visibility.Exported);
+    });
+
+    it('should mark unexported symbols as package-local', () => {
+      const symbols = loadSymbols('sample.go');
+      const local = symbols.functions.filter(isPackageLocal).map(f => f.name);
+
+      expect(local).toEqual(['processTypeA', 'processTypeB', 'privateHelper']);
+    });
+  });
+
+  describe('Type parameters', () => {
+    it('should record type parameters and constraints of generic functions', () => {
+      const symbols = loadSymbols('generics.go');
+      const typeParams = Object.fromEntries(symbols.functions.map(f => [f.name, f.typeParams]));
+
+      expect(typeParams.Map).toEqual([
+        { name: 'T', constraint: 'any' },
+        { name: 'U', constraint: 'any' },
+      ]);
+      expect(typeParams.Sum).toEqual([{ name: 'N', constraint: 'Number' }]);
+      expect(typeParams.Max).toEqual([{ name: 'V', constraint: '~int | ~float64' }]);
+      expect(typeParams.describe).toEqual([
+        { name: 'S', constraint: 'Stringer' },
+        { name: 'P', constraint: 'interface{*S}' },
+      ]);
+      expect(typeParams.Lookup).toBeUndefined();
+      // The brackets are not part of the signature
+      expect(symbols.functions[0].signature.text).toBe('([]T, func(T) U) []U');
+    });
+
+    it('should record type parameters of generic types and their receivers', () => {
+      const symbols = loadSymbols('generics.go');
+      const types = Object.fromEntries(symbols.types.map(t => [t.name, t]));
+
+      expect(types.Pair.typeParams).toEqual([
+        { name: 'K', constraint: 'comparable' },
+        { name: 'V', constraint: 'any' },
+      ]);
+      expect(types.Pair.fields.map(field => field.type)).toEqual(['K', 'V']);
+      expect(types.Set).toMatchObject({ typeKind: 'defined', typeParams: [{ name: 'E', constraint: 'comparable' }] });
+      expect(types.Number.typeParams).toBeUndefined();
+      expect(types.Pair.methods.map(m => m.receiver)).toEqual([
+        { name: 'p', typeName: 'Pair', isPointer: false, typeParams: ['K', 'V'] },
+      ]);
+      expect(types.Set.methods[0].receiver?.typeParams).toEqual(['E']);
+    });
+
+    it('should parse embedded instantiations, unnamed generic receivers and labeled empty statements', () => {
+      const source = `package p
+
+type B struct{ A[int] }
+
+type C[K comparable, V any] struct {
+	Cache[K, V]
+	*List[V]
+	n    [4]int
+	keys []K \`json:"keys"\`
+}
+
+func (H[T]) Hash(v T) {}
+
+func (p Pair[K, V]) Swap() {}
+
+func f(Map[string, int], [2]int) {
+loop:
+	;
+	goto loop
+}
+`;
+      const file = parseGoFile(source, 'p.go');
+      const symbols = extractGoFileSymbols(file);
+      const types = Object.fromEntries(symbols.types.map(t => [t.name, t]));
+
+      expect(types.B.fields.map(field => [field.name, field.type, field.embedded])).toEqual([['A', 'A[int]', true]]);
+      expect(types.C.fields.map(field => [field.name, field.type, field.embedded])).toEqual([
+        ['Cache', 'Cache[K, V]', true],
+        ['List', '*List[V]', true],
+        ['n', '[4]int', false],
+        ['keys', '[]K', false],
+      ]);
+      expect(symbols.methods.map(m => m.receiver)).toEqual([
+        { typeName: 'H', isPointer: false, typeParams: ['T'] },
+        { name: 'p', typeName: 'Pair', isPointer: false, typeParams: ['K', 'V'] },
+      ]);
+      expect(symbols.functions[0].signature.text).toBe('(Map[string, int], [2]int)');
+      expect(file.decls.at(-1)).toMatchObject({ body: { list: [{ kind: 'LabeledStmt', stmt: { kind: 'EmptyStmt' } }, { kind: 'BranchStmt' }] } });
+    });
+  });
+});
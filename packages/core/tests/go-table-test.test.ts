@@ -0,0 +1,229 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+import { convertToTableTests } from '../src/go/table-test';
+
+const calcSource = `package calc
+
+func Add(a, b int) int { return a + b }
+
+func Clamp(v, lo, hi int64) int64 { return v }
+`;
+
+const calc = parseGoFile(calcSource, 'calc.go');
+
+function convert(source: string, tests?: string[]) {
+  return convertToTableTests(parseGoFile(source, 'calc_test.go'), {
+    packageFiles: [calc],
+    tests
+  });
+}
+
+describe('Go table-driven test conversion', () => {
+  it('should turn repeated assertions into a table with a t.Run loop', () => {
+    const result = convert(`package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if got := Add(1, 2); got != 3 {
+		t.Errorf("got %d", got)
+	}
+	if got := Add(-1, 1); got != 0 {
+		t.Errorf("got %d", got)
+	}
+}
+`);
+
+    expect(result.converted).toEqual([
+      {
+        test: 'TestAdd',
+        line: 5,
+        cases: 2,
+        columns: [
+          { name: 'a', type: 'int' },
+          { name: 'b', type: 'int' },
+          { name: 'want', type: 'int' }
+        ]
+      }
+    ]);
+    expect(result.source).toBe(`package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		a    int
+		b    int
+		want int
+	}{
+		{name: "Add(1, 2)", a: 1, b: 2, want: 3},
+		{name: "Add(-1, 1)", a: -1, b: 1, want: 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Add(tc.a, tc.b); got != tc.want {
+				t.Errorf("got %d", got)
+			}
+		})
+	}
+}
+`);
+  });
+
+  it('should keep setup and trailing statements and name cases from comments', () => {
+    const result = convert(`package calc
+
+import "testing"
+
+func TestClamp(tt *testing.T) {
+	lo := int64(0)
+	// below the range
+	got := Clamp(-5, lo, 10)
+	if got != 0 {
+		tt.Fatalf("got %d", got)
+	}
+	// above the range
+	got = Clamp(50, lo, 10)
+	if got != 10 {
+		tt.Fatalf("got %d", got)
+	}
+	tt.Log("done")
+}
+`);
+
+    expect(result.converted[0].columns).toEqual([
+      { name: 'v', type: 'int64' },
+      { name: 'want', type: 'int64' }
+    ]);
+    expect(result.source).toContain('\tlo := int64(0)\n\ttests := []struct {');
+    expect(result.source).toContain('{name: "below the range", v: -5, want: 0},');
+    expect(result.source).toContain('tt.Run(tc.name, func(tt *testing.T) {');
+    expect(result.source).toContain('\t\t\tgot := Clamp(tc.v, lo, 10)\n');
+    expect(result.source).toContain('\t}\n\ttt.Log("done")\n}');
+    expect(result.source).not.toContain('// above the range');
+  });
+
+  it('should fall back to literal types for functions outside the package files', () => {
+    const result = convert(`package calc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpper(t *testing.T) {
+	if strings.ToUpper("a") != "A" {
+		t.Error("a")
+	}
+	if strings.ToUpper("b") != "B" {
+		t.Error("b")
+	}
+}
+`);
+
+    expect(result.converted[0].columns).toEqual([
+      { name: 'arg1', type: 'string' },
+      { name: 'want', type: 'string' },
+      { name: 'message', type: 'string' }
+    ]);
+  });
+
+  it('should leave tests that do not match untouched and report why', () => {
+    const result = convert(`package calc
+
+import "testing"
+
+func TestOnce(t *testing.T) {
+	if Add(1, 1) != 2 {
+		t.Fail()
+	}
+}
+
+func TestSame(t *testing.T) {
+	if Add(1, 1) != 2 {
+		t.Fail()
+	}
+	if Add(1, 1) != 2 {
+		t.Fail()
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	got := Add(1, 1)
+	if got != 2 {
+		t.Fail()
+	}
+	got2 := Add(2, 2)
+	if got2 != 4 {
+		t.Fail()
+	}
+}
+
+func TestReturns(t *testing.T) {
+	if Add(1, 1) != 2 {
+		return
+	}
+	if Add(2, 2) != 4 {
+		return
+	}
+}
+
+func TestLeaks(t *testing.T) {
+	sum := Add(1, 1)
+	if sum != 2 {
+		t.Fail()
+	}
+	sum = Add(2, 2)
+	if sum != 4 {
+		t.Fail()
+	}
+	t.Log(sum)
+}
+
+func TestNotes(t *testing.T) {
+	if Add(1, 1) != 2 {
+		t.Fail()
+	}
+	// only one case has a comment
+	if Add(2, 2) != 4 {
+		t.Fail()
+	}
+}
+`);
+
+    expect(result.converted).toEqual([]);
+    expect(result.diff).toBe('');
+    expect(result.skipped.map((skip) => [skip.test, skip.reason])).toEqual([
+      ['TestOnce', 'no repeated call-and-assert statements'],
+      ['TestSame', 'repeated statements do not differ in any literal'],
+      ['TestEscapes', 'no repeated call-and-assert statements'],
+      ['TestReturns', 'repeated statements do not assert on the result'],
+      ['TestLeaks', 'sum is declared in the repeated statements and used after them'],
+      ['TestNotes', 'comment at line 56 would be lost']
+    ]);
+  });
+
+  it('should only convert the requested tests', () => {
+    const source = `package calc
+
+import "testing"
+
+func TestA(t *testing.T) {
+	if Add(1, 1) != 2 {
+		t.Fail()
+	}
+	if Add(2, 2) != 4 {
+		t.Fail()
+	}
+}
+`;
+    expect(convert(source, ['TestB']).converted).toEqual([]);
+    expect(convert(source, ['TestA']).converted).toHaveLength(1);
+  });
+
+  it('should reject files that are not tests', () => {
+    expect(() => convertToTableTests(calc)).toThrow(GoRefactorError);
+  });
+});
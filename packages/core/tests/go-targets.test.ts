@@ -0,0 +1,108 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { GoSyntaxError } from '../src/go/lexer';
+import { analyzeGoTargets, GoTargetError } from '../src/go/targets';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const store = `// Package store keeps things
+package store
+
+import "os"
+
+func Save(name string) {
+	os.Remove(name)
+}
+`;
+
+describe('Go targeted analysis', () => {
+  let tempDir: string;
+
+  const write = (name: string, content: string) => {
+    fs.mkdirSync(path.dirname(path.join(tempDir, name)), { recursive: true });
+    fs.writeFileSync(path.join(tempDir, name), content);
+  };
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-targets-'));
+    write('sample.go', fs.readFileSync(samplePath, 'utf-8'));
+    write('store/store.go', store);
+  });
+
+  afterEach(() => {
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('analyzes a method with its package as context', async () => {
+    const result = await analyzeGoTargets(tempDir, ['main.DataProcessor.ProcessData']);
+
+    expect(result.targets).toHaveLength(1);
+    const [target] = result.targets;
+    expect(target).toMatchObject({
+      symbol: 'main.DataProcessor.ProcessData',
+      id: 'main.DataProcessor.ProcessData',
+      kind: 'method',
+      package: '.',
+      file: 'sample.go',
+      line: 24,
+    });
+    expect(target.function.qualified_name).toBe('DataProcessor.ProcessData');
+    expect(target.function.complexity).toBeGreaterThan(1);
+    // processItem is declared in the same package, so the call is counted
+    expect(target.function.fan_out).toBeGreaterThanOrEqual(1);
+    expect(target.references[0]).toMatchObject({ file: 'sample.go', line: 24, role: 'definition' });
+    expect(Object.isFrozen(target)).toBe(true);
+  });
+
+  it('returns types and functions in the order requested, with their findings only', async () => {
+    const result = await analyzeGoTargets(tempDir, ['store.Save', 'main.DataProcessor']);
+
+    expect(result.targets.map((target) => [target.id, target.kind])).toEqual([
+      ['store.Save', 'function'],
+      ['main.DataProcessor', 'type'],
+    ]);
+    expect(result.targets[0].findings.map((finding) => finding.rule)).toContain('ignored-error');
+    expect(result.targets[0].findings.every((finding) => finding.file === 'store/store.go')).toBe(true);
+    expect(result.targets[1].type.fields.map((field) => field.name)).toEqual(['config', 'cache']);
+    expect(result.targets[1].references.some((reference) => reference.context === 'receiver')).toBe(true);
+  });
+
+  it('never parses packages holding no target', async () => {
+    write('broken/broken.go', 'package broken\n\nfunc {\n');
+
+    const result = await analyzeGoTargets(tempDir, ['store.Save']);
+    expect(result.targets.map((target) => target.id)).toEqual(['store.Save']);
+    await expect(analyzeGoTargets(tempDir, ['broken.Anything'])).rejects.toThrow(GoSyntaxError);
+  });
+
+  it('names every target that does not resolve, and why', async () => {
+    const error = await analyzeGoTargets(tempDir, [
+      'main.Missing',
+      'store.Save',
+      'main.DataProcessor.Missing',
+      'nowhere.Func',
+      'not a symbol',
+    ]).catch((caught) => caught);
+
+    expect(error).toBeInstanceOf(GoTargetError);
+    expect(error.unresolved).toEqual([
+      { symbol: 'main.Missing', reason: 'main has no function or type Missing' },
+      { symbol: 'main.DataProcessor.Missing', reason: 'main.DataProcessor has no method Missing' },
+      { symbol: 'nowhere.Func', reason: 'no package nowhere under the root' },
+      { symbol: 'not a symbol', reason: 'not pkg.Name or pkg.Type.Method' },
+    ]);
+    expect(error.message).toMatch(/^Cannot resolve 4 symbols: main\.Missing \(main has no function or type Missing\); /);
+  });
+
+  it('disambiguates same-named packages by directory', async () => {
+    write('cmd/a/main.go', 'package main\n\nfunc Run() {}\n');
+
+    await expect(analyzeGoTargets(tempDir, ['main.Run'])).rejects.toThrow(
+      'main.Run (package main is in cmd/a, .; prefix the directory, as in cmd/a/main.Run)'
+    );
+    const result = await analyzeGoTargets(tempDir, ['cmd/a/main.Run']);
+    expect(result.targets[0]).toMatchObject({ id: 'main.Run', package: 'cmd/a', file: 'cmd/a/main.go' });
+  });
+});
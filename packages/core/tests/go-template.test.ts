@@ -0,0 +1,127 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { GoFinding } from '../src/go/findings';
+import { parseGoFile } from '../src/go/parser';
+import { builtinGoRules } from '../src/go/rules';
+import {
+  GoTemplateError,
+  goTemplateData,
+  parseGoReportTemplate,
+  readGoReportTemplate,
+} from '../src/go/template';
+
+const a = parseGoFile('package p\n\nfunc A() {}\n', '/repo/p/a.go');
+const b = parseGoFile('package q\n\nfunc B() {}\n', '/repo/q/b.go');
+const finding = (overrides: Partial<GoFinding>): GoFinding => ({
+  rule: 'naked-return',
+  severity: 'low',
+  filePath: '/repo/p/a.go',
+  line: 3,
+  column: 1,
+  message: 'Naked return',
+  ...overrides,
+});
+const findings = [
+  finding({ rule: 'ignored-error', severity: 'high', line: 9, message: 'Error of os.Remove dropped' }),
+  finding({ rule: 'shadowed-variable', severity: 'medium', filePath: '/repo/q/b.go', message: 'err <shadows> err' }),
+  finding({ fix: 'return x, err' }),
+  finding({ rule: 'ignored-error', severity: 'medium', line: 12, message: 'Error of f.Close dropped' }),
+];
+const data = goTemplateData(findings, [b, a], { root: '/repo', version: '1.2.3', rules: builtinGoRules() });
+const render = (source: string) => parseGoReportTemplate(source).render(data);
+const error = (source: string) => {
+  try {
+    parseGoReportTemplate(source);
+  } catch (caught) {
+    expect(caught).toBeInstanceOf(GoTemplateError);
+    return (caught as Error).message;
+  }
+  throw new Error('the template was accepted');
+};
+
+describe('Go report templates', () => {
+  it('builds the documented data model from findings and files', () => {
+    expect(data.Summary).toEqual({ Total: 4, High: 1, Medium: 2, Low: 1, Files: 2 });
+    expect(data.Files.map(({ Path, Package, Findings }) => [Path, Package, Findings.length])).toEqual([
+      ['p/a.go', 'p', 3],
+      ['q/b.go', 'q', 1],
+    ]);
+    expect(data.Findings[0]).toEqual({
+      Rule: 'naked-return',
+      Severity: 'low',
+      Path: 'p/a.go',
+      Line: 3,
+      Column: 1,
+      Message: 'Naked return',
+      Fix: 'return x, err',
+    });
+    expect(data.Rules.map(({ ID, Severity, Count }) => [ID, Severity, Count])).toEqual([
+      ['ignored-error', 'high', 2],
+      ['naked-return', 'low', 1],
+      ['shadowed-variable', 'medium', 1],
+    ]);
+    expect(data.Rules[0].Description).toBe('Error results dropped or discarded with _');
+  });
+
+  it('renders fields, ranges, conditionals and trim markers like text/template', () => {
+    const source = `{{/* summary */ -}}
+v{{.Version}}: {{with .Summary}}{{.Total}} findings{{end}}
+{{range $i, $file := .Files -}}
+{{$i}} {{$file.Path}} ({{$file.Package}}){{if gt (len .Findings) 1}} x{{len .Findings}}{{else if .Findings}} once{{end}}
+{{end -}}
+{{range .Rules}}{{if eq .Severity "high"}}!{{end}}{{.ID}} {{end}}
+{{range minSeverity "high" (rule "naked-return" .Findings)}}{{.Message}}{{else}}nothing high{{end}}
+`;
+    expect(render(source)).toBe(`v1.2.3: 4 findings
+0 p/a.go (p) x3
+1 q/b.go (q) once
+!ignored-error naked-return shadowed-variable 
+nothing high
+`);
+  });
+
+  it('sorts, filters, limits and escapes with the helper functions', () => {
+    const source = `{{range first 3 (sortBy "Severity" "-Line" .Findings)}}{{printf "%-6s %2d %s" .Severity .Line .Rule}}
+{{end}}{{$medium := severity "medium" .Findings}}{{len $medium}} medium: {{range $medium}}<li>{{html .Message}}</li>{{end}}
+{{(index .Findings 0).Fix | printf "%q"}} {{upper "ok" | lower}} {{json .Summary}}`;
+    expect(render(source)).toBe(`high    9 ignored-error
+medium 12 ignored-error
+medium  3 shadowed-variable
+2 medium: <li>Error of f.Close dropped</li><li>err &lt;shadows&gt; err</li>
+"return x, err" ok {"Total":4,"High":1,"Medium":2,"Low":1,"Files":2}`);
+  });
+
+  it('fails at load time on missing fields, unknown functions and bad syntax', () => {
+    expect(error('{{range .Findings}}\n  {{.Sevrity}}\n{{end}}')).toBe(
+      'template:2:5: Finding has no field Sevrity; its fields are Column, Fix, Line, Message, Path, Rule and Severity',
+    );
+    expect(error('{{.Summary.Total.Count}}')).toBe('template:1:3: can\'t evaluate field Count in type number');
+    expect(error('{{.Findings.Line}}')).toBe('template:1:3: can\'t evaluate field Line in type list of Finding');
+    expect(error('{{sortBy "Lenght" .Findings}}')).toBe('template:1:3: sortBy: Finding has no field Lenght');
+    expect(error('{{severity "critical" .Findings}}')).toBe(
+      'template:1:3: severity: unknown severity "critical"; expected high, medium or low',
+    );
+    expect(error('{{count .Findings}}')).toBe('template:1:3: function "count" not defined');
+    expect(error('{{len .Findings .Files}}')).toBe('template:1:3: wrong number of args for len: want 1 got 2');
+    expect(error('{{range .Summary}}{{end}}')).toBe("template:1:9: range can't iterate over Summary");
+    expect(error('{{if .Findings}}\n{{range .Files}}{{end}}')).toBe('template:1:1: missing {{end}} for {{if}}');
+    expect(error('{{with $f := .Files}}{{end}}{{$f}}')).toBe('template:1:31: undefined variable "$f"');
+    expect(error('{{template "other"}}')).toBe('template:1:1: {{template}} is not supported');
+    expect(error('{{.Root')).toBe('template:1:1: unclosed action');
+  });
+
+  it('reads templates from files, reporting errors under their path', async () => {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'refactogent-template-'));
+    const good = path.join(dir, 'good.tmpl');
+    const bad = path.join(dir, 'bad.tmpl');
+    fs.writeFileSync(good, '{{range .Files}}{{.Path}};{{end}}');
+    fs.writeFileSync(bad, 'ok\n{{.Rules.ID}}');
+
+    expect((await readGoReportTemplate(good)).render(data)).toBe('p/a.go;q/b.go;');
+    await expect(readGoReportTemplate(bad)).rejects.toThrow(
+      `${bad}:2:3: can't evaluate field ID in type list of Rule`,
+    );
+  });
+});
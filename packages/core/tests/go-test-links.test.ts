@@ -0,0 +1,114 @@
+import { describe, it, expect } from '@jest/globals';
+import { buildGoCallGraph } from '../src/go/callgraph';
+import { findDeadFunctions } from '../src/go/deadcode';
+import { parseGoFile } from '../src/go/parser';
+import { GoTestIndex, linkGoTests } from '../src/go/test-links';
+
+const parseSource = `package parse
+
+func Parse(s string) int { return normalize(len(s)) }
+
+func normalize(n int) int { return n }
+
+func fixture() string { return "abc" }
+
+func golden() string { return fixture() }
+
+func unused() {}
+`;
+
+const parseTestSource = `package parse
+
+import "testing"
+
+func check(t *testing.T, s string, want int) {
+	if got := Parse(s); got != want {
+		t.Fatalf("Parse(%q) = %d", s, got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"empty", "", 0},
+		{"word", golden(), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check(t, tt.in, tt.want)
+			t.Run("again", func(t *testing.T) { check(t, tt.in, tt.want) })
+		})
+	}
+	t.Run(\`raw\`, func(t *testing.T) {})
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Parse("x")
+	}
+}
+
+func ExampleParse() {}
+`;
+
+const files = [parseGoFile(parseSource, 'parse.go'), parseGoFile(parseTestSource, 'parse_test.go')];
+
+describe('Go test links', () => {
+  it('finds tests with their kinds and subtests', () => {
+    const tests = linkGoTests(files);
+
+    expect(tests.map(test => [test.name, test.kind, test.line])).toEqual([
+      ['TestParse', 'test', 11],
+      ['BenchmarkParse', 'benchmark', 29],
+      ['ExampleParse', 'example', 35],
+    ]);
+    expect(tests[0].subtests).toEqual([
+      { name: 'tt.name', line: 21, table: true },
+      { name: 'tt.name/again', line: 23, table: false },
+      { name: 'raw', line: 26, table: false },
+    ]);
+  });
+
+  it('links tests to the production functions they reach, through test helpers', () => {
+    const [test, benchmark, example] = linkGoTests(files);
+
+    // Parse is called through check, golden from a table row
+    expect(test.targets).toEqual([
+      { id: 'parse.Parse', depth: 1 },
+      { id: 'parse.golden', depth: 1 },
+      { id: 'parse.fixture', depth: 2 },
+      { id: 'parse.normalize', depth: 2 },
+    ]);
+    expect(benchmark.targets.map(target => target.id)).toEqual(['parse.Parse', 'parse.normalize']);
+    expect(example.targets).toEqual([]);
+  });
+
+  it('answers which tests reach a function', () => {
+    const index = new GoTestIndex(buildGoCallGraph(files));
+
+    expect(index.testsOf('parse.normalize')).toEqual([
+      { test: 'BenchmarkParse', depth: 2 },
+      { test: 'TestParse', depth: 2 },
+    ]);
+    expect(index.nearest('parse.Parse')).toEqual({ test: 'BenchmarkParse', depth: 1 });
+    expect(index.nearest('parse.unused')).toBeUndefined();
+  });
+
+  it('flags functions only tests use apart from dead ones', () => {
+    const dead = findDeadFunctions(files).map(fn => [fn.qualifiedName, fn.testOnly ?? false]);
+
+    expect(dead).toEqual([
+      ['fixture', true],
+      ['golden', true],
+      ['unused', false],
+    ]);
+    // Without the tests they are plain dead code
+    expect(findDeadFunctions([files[0]]).map(fn => [fn.qualifiedName, fn.testOnly])).toEqual([
+      ['golden', undefined],
+      ['unused', undefined],
+    ]);
+  });
+});
@@ -0,0 +1,88 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { goMarkdownReport } from '../src/go/report';
+import { defaultGoRuleRegistry } from '../src/go/rules';
+import { findTodoComments } from '../src/go/todos';
+
+const source = `package jobs
+
+import "strings"
+
+// TODO(ana, 2024-05-01): split into a parser and a runner
+func Run(input string) string {
+	// FIXME: trims too much
+	out := strings.TrimSpace(input)
+	/*
+	 * HACK(bob) until the queue is fixed
+	 */
+	if out == "" {
+		return "TODO: not a comment"
+	}
+	return out
+}
+
+type Job struct {
+	Name string // XXX unused outside tests
+}
+
+func (j *Job) Start() {
+	// TODOs and NOTE: are not markers, nor is a todo in prose
+}
+
+// TODO
+var Default = Job{}
+`;
+
+describe('Go TODO comments', () => {
+  const file = parseGoFile(source, '/src/jobs/jobs.go');
+
+  it('finds tags in line and block comments with their declaration', () => {
+    const todos = findTodoComments([file]);
+
+    expect(todos.map(todo => [todo.line, todo.tag, todo.symbol, todo.text])).toEqual([
+      [5, 'TODO', 'Run', 'split into a parser and a runner'],
+      [7, 'FIXME', 'Run', 'trims too much'],
+      [10, 'HACK', 'Run', 'until the queue is fixed'],
+      [19, 'XXX', 'Job', 'unused outside tests'],
+      [26, 'TODO', 'Default', ''],
+    ]);
+    expect(todos[0]).toMatchObject({
+      rule: 'todo-comment',
+      severity: 'low',
+      column: 4,
+      author: 'ana',
+      date: '2024-05-01',
+      message: 'TODO in Run: split into a parser and a runner',
+    });
+    expect(todos[2]).toMatchObject({ column: 5, author: 'bob' });
+    expect(todos[2].date).toBeUndefined();
+    expect(todos[1].author).toBeUndefined();
+  });
+
+  it('looks only for the configured tags', () => {
+    const todos = findTodoComments([file], { tags: ['FIXME', 'NOTE'] });
+
+    expect(todos.map(todo => `${todo.tag}:${todo.line}`)).toEqual(['FIXME:7']);
+  });
+
+  it('runs as a built-in rule', () => {
+    const rules = defaultGoRuleRegistry().run([file]).filter(finding => finding.rule === 'todo-comment');
+
+    expect(rules).toHaveLength(5);
+  });
+
+  it('groups markers by declaration in the report', () => {
+    const report = goMarkdownReport([file], { root: '/src' });
+
+    expect(report).toContain(`## Tech debt
+
+TODO, FIXME, HACK, XXX comments by declaration, the most first.
+
+| Declaration | File | Markers | Complexity |
+| --- | --- | --- | ---: |
+| \`Run\` | jobs/jobs.go:5 | 1 TODO, 1 FIXME, 1 HACK | 3 |
+| \`Job\` | jobs/jobs.go:19 | 1 XXX | — |
+| \`Default\` | jobs/jobs.go:26 | 1 TODO | — |`);
+    expect(goMarkdownReport([])).toContain('## Tech debt\n\nTODO, FIXME, HACK, XXX comments by declaration, the most first.\n\n_None._');
+  });
+});
@@ -0,0 +1,207 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { goPipelineFromConfig } from '../src/go/pipeline';
+import { GoRefactorError } from '../src/go/refactor';
+import { convertSentinelError, findSentinelErrors, sentinelTypeName } from '../src/go/typed-errors';
+
+const store = `package store
+
+import "errors"
+
+// ErrNotFound is returned when a key is missing.
+var ErrNotFound = errors.New("not found")
+
+type Store struct{ items map[string]string }
+
+func (s *Store) Get(key string) (string, error) {
+	v, ok := s.items[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+`;
+
+const app = `package main
+
+import (
+	"fmt"
+
+	"example.com/shop/store"
+)
+
+func lookup(s *store.Store, key string) string {
+	v, err := s.Get(key)
+	if err == store.ErrNotFound {
+		return "missing"
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	return v
+}
+`;
+
+const cache = `package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMiss    = errors.New("cache miss")
+	ErrClosed  = errors.New("cache closed")
+	errExpired = errors.New(fmt.Sprint("expired"))
+)
+
+func Fetch(key string) error {
+	if key == "" {
+		return fmt.Errorf("fetch: %w", ErrMiss)
+	}
+	return ErrClosed
+}
+
+func Retry(err error) bool {
+	if errors.Is(err, ErrMiss) || (ErrMiss != err) {
+		return true
+	}
+	println(ErrMiss.Error())
+	switch err {
+	case ErrClosed:
+		return false
+	}
+	return errors.Is(err, errExpired)
+}
+`;
+
+const files = () => [
+  parseGoFile(store, '/repo/store/store.go'),
+  parseGoFile(app, '/repo/cmd/app/main.go'),
+  parseGoFile(cache, '/repo/cache/cache.go'),
+];
+
+describe('Go typed errors', () => {
+  it('names the type after the sentinel', () => {
+    expect(sentinelTypeName('ErrNotFound')).toBe('NotFoundError');
+    expect(sentinelTypeName('errClosed')).toBe('closedError');
+    expect(sentinelTypeName('Errata')).toBe('ErrataError');
+    expect(sentinelTypeName('EOF')).toBe('EOFError');
+  });
+
+  it('replaces a sentinel with a type and rewrites its uses in other packages', () => {
+    const result = convertSentinelError(files(), { sentinel: 'ErrNotFound' });
+
+    expect(result.typeName).toBe('NotFoundError');
+    expect(result.references).toEqual([
+      { filePath: '/repo/store/store.go', line: 13, column: 14, kind: 'construction' },
+      { filePath: '/repo/cmd/app/main.go', line: 11, column: 12, kind: 'comparison' },
+    ]);
+    const [declaring, caller] = result.files;
+    expect(declaring.source).toBe(`package store
+
+// NotFoundError is returned when a key is missing.
+type NotFoundError struct{}
+
+func (*NotFoundError) Error() string {
+	return "not found"
+}
+
+type Store struct{ items map[string]string }
+
+func (s *Store) Get(key string) (string, error) {
+	v, ok := s.items[key]
+	if !ok {
+		return "", &NotFoundError{}
+	}
+	return v, nil
+}
+`);
+    expect(caller.source).toContain('import (\n\t"errors"\n\t"fmt"\n');
+    expect(caller.source).toContain('\tif errors.As(err, new(*store.NotFoundError)) {\n');
+  });
+
+  it('keeps errors.Is comparisons when the type matches itself with Is', () => {
+    const source = cache.replace(/\tswitch err \{\n\tcase ErrClosed:\n\t\treturn false\n\t\}\n/, '');
+    const result = convertSentinelError([parseGoFile(source, '/repo/cache/cache.go')], {
+      sentinel: 'ErrMiss',
+      is: true,
+    });
+
+    expect(result.references.map(({ line, kind }) => [line, kind])).toEqual([
+      [16, 'construction'],
+      [22, 'comparison'],
+      [22, 'comparison'],
+      [25, 'construction'],
+    ]);
+    const [{ source: converted }] = result.files;
+    expect(converted).toContain('var (\n\tErrClosed  = errors.New("cache closed")\n\terrExpired');
+    expect(converted).toContain(`)
+
+// MissError is the error "cache miss".
+type MissError struct{}
+
+func (*MissError) Error() string {
+	return "cache miss"
+}
+
+// Is reports whether target is a *MissError, so that errors.Is matches
+// every MissError rather than only the same one.
+func (*MissError) Is(target error) bool {
+	_, ok := target.(*MissError)
+	return ok
+}
+
+func Fetch`);
+    expect(converted).toContain('return fmt.Errorf("fetch: %w", &MissError{})');
+    expect(converted).toContain('if errors.Is(err, &MissError{}) || (!errors.Is(err, &MissError{})) {');
+    expect(converted).toContain('println((&MissError{}).Error())');
+  });
+
+  it('refuses sentinels with uses it cannot rewrite', () => {
+    const convert = (sentinel: string, options = {}) => () =>
+      convertSentinelError(files(), { sentinel, ...options });
+
+    expect(convert('ErrClosed')).toThrow(
+      'Cannot convert ErrClosed: it is a switch case, which compares by identity, on cache.go:27'
+    );
+    expect(convert('errExpired')).toThrow('Cannot convert errExpired: its message is not a string literal');
+    expect(convert('ErrMiss', { typeName: 'missError' })).toThrow(
+      'Cannot convert ErrMiss: missError must be exported like ErrMiss'
+    );
+    expect(convert('ErrNotFound', { typeName: 'Store' })).toThrow(
+      'Cannot convert ErrNotFound: Store is already declared in the package'
+    );
+    const reset = parseGoFile(
+      store.replace('\treturn v, nil\n', '\tErrNotFound = nil\n\treturn v, nil\n'),
+      '/repo/store/store.go'
+    );
+    expect(() => convertSentinelError([reset], { sentinel: 'ErrNotFound' })).toThrow(
+      'Cannot convert ErrNotFound: it is assigned to on store.go:15'
+    );
+    expect(convert('ErrGone')).toThrow(GoRefactorError);
+  });
+
+  it('suggests the conversion for exported sentinels whose uses all convert', () => {
+    const findings = findSentinelErrors(files());
+
+    expect(findings.map(({ filePath, line, sentinel, typeName, references }) => ({
+      filePath,
+      line,
+      sentinel,
+      typeName,
+      references,
+    }))).toEqual([
+      { filePath: '/repo/cache/cache.go', line: 9, sentinel: 'ErrMiss', typeName: 'MissError', references: 4 },
+      { filePath: '/repo/store/store.go', line: 6, sentinel: 'ErrNotFound', typeName: 'NotFoundError', references: 2 },
+    ]);
+    expect(findings[1]).toMatchObject({ rule: 'sentinel-error', severity: 'low', fix: 'type NotFoundError struct{}' });
+
+    const result = goPipelineFromConfig({
+      stages: [{ transform: 'typed-error', options: { sentinel: 'ErrNotFound' } }],
+    }).run(files());
+    expect(result.stages).toEqual([
+      { name: 'typed-error', files: ['/repo/store/store.go', '/repo/cmd/app/main.go'] },
+    ]);
+  });
+});
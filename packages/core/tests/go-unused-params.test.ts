@@ -0,0 +1,129 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { parseGoFile } from '../src/go/parser';
+import { findUnusedParameters, removeUnusedParameter } from '../src/go/unused-params';
+
+const samplePath = path.join(__dirname, 'fixtures', 'go', 'sample.go');
+
+const source = `package shop
+
+import "fmt"
+
+type Formatter interface {
+	Format(price int, currency string) string
+}
+
+type plain struct{}
+
+// Format ignores the currency
+func (plain) Format(price int, currency string) string { return fmt.Sprint(price) }
+
+type cart struct{ items []int }
+
+func (c *cart) add(item, quantity int) { c.items = append(c.items, item) }
+
+func total(prices []int, discount float64, verbose bool) int {
+	sum := 0
+	for _, price := range prices {
+		sum += price
+	}
+	return sum
+}
+
+func Report(prices []int, w fmt.Stringer) string {
+	c := &cart{}
+	c.add(prices[0], 2)
+	return fmt.Sprint(total(prices, 0.5, false), total(
+		prices,
+		0.1,
+		true,
+	))
+}
+
+func handler(event string, attempt int) {}
+
+var handlers = map[string]func(string, int){"retry": handler}
+`;
+
+const files = () => [parseGoFile(source, 'shop/shop.go')];
+
+describe('Go unused parameters', () => {
+  it('should find nothing in the fixture, whose functions use their parameters', () => {
+    const sample = parseGoFile(fs.readFileSync(samplePath, 'utf-8'), samplePath);
+    expect(findUnusedParameters([sample])).toEqual([]);
+  });
+
+  it('should report unused parameters and whether they can be removed', () => {
+    const findings = findUnusedParameters(files());
+
+    expect(findings.map(f => [f.function, f.parameter, f.index, f.removable])).toEqual([
+      ['plain.Format', 'currency', 1, false],
+      ['cart.add', 'quantity', 1, true],
+      ['total', 'discount', 1, true],
+      ['total', 'verbose', 2, true],
+      ['Report', 'w', 1, false],
+      ['handler', 'event', 0, false],
+      ['handler', 'attempt', 1, false],
+    ]);
+    expect(findings[0].constraint).toBe(
+      'plain.Format may implement an interface, which fixes its signature'
+    );
+    expect(findings[2]).toMatchObject({
+      rule: 'unused-parameter',
+      severity: 'low',
+      line: 18,
+      column: 26,
+      message: 'discount is never used in total; remove it',
+    });
+    expect(findings[4].message).toBe(
+      'w is never used in Report; rename it to _ (Report is exported, so code outside the package may call it)'
+    );
+    expect(findings[5].constraint).toBe(
+      'handler is used as a value on shop.go:38, which fixes its signature'
+    );
+  });
+
+  it('should remove a parameter and the arguments of every call', () => {
+    const result = removeUnusedParameter(files(), { function: 'total', parameter: 'verbose' });
+    expect(result.callSites.map(site => site.line)).toEqual([29, 29]);
+    const [file] = result.files;
+    expect(file.source).toContain('func total(prices []int, discount float64) int {');
+    expect(file.source).toContain('fmt.Sprint(total(prices, 0.5), total(\n\t\tprices,\n\t\t0.1,\n\t))');
+
+    const grouped = removeUnusedParameter(files(), { function: 'cart.add', parameter: 'quantity' });
+    expect(grouped.files[0].source).toContain('func (c *cart) add(item int) {');
+    expect(grouped.files[0].source).toContain('c.add(prices[0])');
+  });
+
+  it('should update generic functions and their explicit instantiations', () => {
+    const generic = parseGoFile(
+      'package shop\n\nfunc first[T any, N ~int](items []T, n N) T { return items[0] }\n\nfunc run() { first[string, int]([]string{"a"}, 2) }\n',
+      'shop/first.go'
+    );
+    const result = removeUnusedParameter([generic], { function: 'first', parameter: 'n' });
+
+    expect(result.files[0].source).toContain('func first[T any, N ~int](items []T) T {');
+    expect(result.files[0].source).toContain('first[string, int]([]string{"a"})');
+  });
+
+  it('should refuse parameters whose signature is fixed or that are used', () => {
+    expect(() =>
+      removeUnusedParameter(files(), { function: 'plain.Format', parameter: 'currency' })
+    ).toThrow('Cannot remove currency: plain.Format may implement an interface');
+    expect(() => removeUnusedParameter(files(), { function: 'total', parameter: 'prices' })).toThrow(
+      'prices is used in total'
+    );
+    expect(() => removeUnusedParameter(files(), { function: 'total', parameter: 'tax' })).toThrow(
+      'total has no parameter tax'
+    );
+
+    const effects = parseGoFile(
+      'package shop\n\nfunc log(msg string, n int) { println(msg) }\n\nfunc run() { log("x", next()) }\n\nfunc next() int { return 1 }\n',
+      'shop/log.go'
+    );
+    expect(() => removeUnusedParameter([effects], { function: 'log', parameter: 'n' })).toThrow(
+      'The argument for n on log.go:5 may have side effects'
+    );
+  });
+});
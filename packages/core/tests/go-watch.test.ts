@@ -0,0 +1,117 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { formatWatchEvent, GoWatcher, GoWatchEvent } from '../src/go/watch';
+
+const UNCHECKED = `package main
+
+import "os"
+
+func cleanup(path string) {
+\tos.Remove(path)
+}
+`;
+
+const CHECKED = `package main
+
+import "os"
+
+func cleanup(path string) error {
+\treturn os.Remove(path)
+}
+`;
+
+describe('Go watch mode', () => {
+  let tempDir: string;
+  let watcher: GoWatcher;
+  let events: GoWatchEvent[];
+
+  const write = (rel: string, content: string) =>
+    fs.writeFileSync(path.join(tempDir, rel), content);
+
+  const next = (type: GoWatchEvent['type']) =>
+    new Promise<GoWatchEvent>((resolve, reject) => {
+      const timeout = setTimeout(() => reject(new Error(`no ${type} event`)), 2000);
+      const listener = (event: GoWatchEvent) => {
+        if (event.type !== type) return;
+        clearTimeout(timeout);
+        watcher.off('event', listener);
+        resolve(event);
+      };
+      watcher.on('event', listener);
+    });
+
+  const sleep = (ms: number) => new Promise(resolve => setTimeout(resolve, ms));
+
+  beforeEach(() => {
+    tempDir = fs.mkdtempSync(path.join(os.tmpdir(), 'go-watch-'));
+    watcher = new GoWatcher(tempDir, { debounceMs: 20 });
+    events = [];
+    watcher.on('event', event => events.push(event));
+  });
+
+  afterEach(async () => {
+    await watcher.stop();
+    fs.rmSync(tempDir, { recursive: true, force: true });
+  });
+
+  it('should report the initial findings once ready', async () => {
+    write('main.go', UNCHECKED);
+    const ready = await watcher.start();
+
+    expect(ready.type).toBe('ready');
+    if (ready.type !== 'ready') return;
+    expect(ready.files).toBe(1);
+    expect(ready.findings.map(finding => finding.line)).toEqual([6]);
+    expect(formatWatchEvent(ready)).toBe('Watching 1 file(s), 1 finding(s)');
+  });
+
+  it('should report only the findings that changed after a save', async () => {
+    write('main.go', UNCHECKED);
+    write('other.go', UNCHECKED.replace('cleanup', 'other'));
+    await watcher.start();
+
+    const analysis = next('analysis');
+    write('main.go', CHECKED);
+    watcher.changed();
+    const event = await analysis;
+
+    expect(event.type).toBe('analysis');
+    if (event.type !== 'analysis') return;
+    expect(event.changed).toEqual([path.join(tempDir, 'main.go')]);
+    expect(event.added).toEqual([]);
+    expect(event.removed.map(finding => finding.filePath)).toEqual([
+      path.join(tempDir, 'main.go'),
+    ]);
+    expect(event.total).toBe(1);
+    expect(formatWatchEvent(event).split('\n')[1]).toMatch(/^- .*main\.go:6:2 /);
+  });
+
+  it('should debounce bursts and keep the last good parse of broken files', async () => {
+    write('main.go', UNCHECKED);
+    await watcher.start();
+
+    const error = next('error');
+    write('main.go', 'package main\n\nfunc broken( {\n');
+    for (let i = 0; i < 5; i++) watcher.changed();
+    expect((await error).type).toBe('error');
+    await sleep(100);
+
+    expect(events.filter(event => event.type === 'analysis')).toEqual([]);
+    expect(events.filter(event => event.type === 'error')).toHaveLength(1);
+  });
+
+  it('should emit nothing once stopped', async () => {
+    write('main.go', UNCHECKED);
+    await watcher.start();
+
+    watcher.changed();
+    await watcher.stop();
+    write('main.go', CHECKED);
+    watcher.changed();
+    await sleep(100);
+
+    expect(events.map(event => event.type)).toEqual(['ready']);
+  });
+});
@@ -0,0 +1,125 @@
+import { describe, it, expect } from '@jest/globals';
+import { parseGoFile } from '../src/go/parser';
+import { GoRefactorError } from '../src/go/refactor';
+import { wrapErrors } from '../src/go/wrap-errors';
+
+const parse = (source: string) => parseGoFile(source, '/src/p/p.go');
+
+const loader = `package p
+
+import (
+	"errors"
+	"os"
+)
+
+func loadConfig(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := readAllBytes(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		err := errors.New("empty config")
+		return nil, err
+	}
+	return data, nil
+}
+`;
+
+describe('Go error wrapping', () => {
+  it('wraps errors returned from calls and adds the fmt import', () => {
+    const result = wrapErrors(parse(loader));
+
+    expect(result.wrapped).toEqual([
+      { line: 11, column: 3, message: 'open', callee: 'os.Open(path)' },
+      { line: 16, column: 3, message: 'read all bytes', callee: 'readAllBytes(f)' },
+    ]);
+    expect(result.importsAdded).toEqual(['fmt']);
+    expect(result.source).toContain('\t"errors"\n\t"fmt"\n\t"os"\n');
+    expect(result.source).toContain('return nil, fmt.Errorf("open: %w", err)');
+    expect(result.source).toContain('return nil, fmt.Errorf("read all bytes: %w", err)');
+    expect(result.source).toContain('err := errors.New("empty config")\n\t\treturn nil, err\n');
+  });
+
+  it('does not wrap twice', () => {
+    const once = wrapErrors(parse(loader));
+    const twice = wrapErrors(parse(once.source));
+
+    expect(twice.wrapped).toEqual([]);
+    expect(twice.source).toBe(once.source);
+  });
+
+  it('takes reviewed messages by line and escapes them', () => {
+    const result = wrapErrors(parse(loader), {
+      line: 11,
+      messages: { 11: 'open "config" 100%' },
+    });
+
+    expect(result.wrapped.map((wrapped) => wrapped.line)).toEqual([11]);
+    expect(result.source).toContain('return nil, fmt.Errorf("open \\"config\\" 100%%: %w", err)');
+    expect(result.source).toContain('data, err := readAllBytes(f)\n\tif err != nil {\n\t\treturn nil, err\n');
+    expect(() => wrapErrors(parse(loader), { line: 21 })).toThrow(GoRefactorError);
+  });
+
+  it('uses the file fmt import and leaves Read methods, parameters and literals alone', () => {
+    const source = `package p
+
+import f "fmt"
+
+type R struct{ src Reader }
+
+func (r *R) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	return n, err
+}
+
+func check(err error) error {
+	return err
+}
+
+func run(tasks []func() error) error {
+	var err error
+	for _, task := range tasks {
+		err = func() error {
+			v, err := parseURL(f.Sprint(task))
+			_ = v
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`;
+    const result = wrapErrors(parse(source));
+
+    expect(result.importsAdded).toEqual([]);
+    expect(result.wrapped.map(({ line, message }) => [line, message])).toEqual([
+      [22, 'parse URL'],
+      [25, 'run'],
+    ]);
+    expect(result.source).toContain('return f.Errorf("parse URL: %w", err)');
+    expect(result.source).toContain('return f.Errorf("run: %w", err)');
+    expect(result.source).toContain('n, err := r.src.Read(p)\n\treturn n, err\n');
+  });
+
+  it('skips functions where fmt is shadowed and files without such returns', () => {
+    const source = `package p
+
+func format(fmt string) error {
+	err := validate(fmt)
+	return err
+}
+`;
+    const result = wrapErrors(parse(source));
+
+    expect(result.wrapped).toEqual([]);
+    expect(result.edits).toEqual([]);
+    expect(result.source).toBe(source);
+  });
+});
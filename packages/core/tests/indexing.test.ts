@@ -429,6 +429,22 @@ describe('CodebaseIndexer', () => {
     });
   });
 
+  describe('Symbol Summary', () => {
+    it('should summarize Go and Python symbols the same way', async () => {
+      const files = [
+        await indexer['analyzeFile'](path.join(fixturesPath, 'go', 'sample.go')),
+        await indexer['analyzeFile'](path.join(fixturesPath, 'python', 'sample.py')),
+      ] as RefactorableFile[];
+      const summary = indexer.getSymbolSummary(files);
+
+      expect(Object.keys(summary)).toEqual(['go', 'python']);
+      expect(summary.python).toMatchObject({ files: 1, functions: 7, types: 1, values: 1, private: 2 });
+      expect(summary.go).toMatchObject({ files: 1, types: 1 });
+      // calculate_fibonacci and process_data score as their Go counterparts do
+      expect(summary.python.maxComplexity).toBe(4);
+    });
+  });
+
   describe('Full Codebase Indexing', () => {
     it('should index the entire fixtures directory', async () => {
       const files = await indexer.indexCodebase();
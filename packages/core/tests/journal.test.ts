@@ -0,0 +1,85 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  applyPlanWithJournal,
+  listJournals,
+  readJournal,
+  recordJournal,
+  RefactorJournalError,
+  rollback,
+} from '../src/journal';
+import { createPlan } from '../src/plan';
+
+describe('Refactor journals', () => {
+  let root: string;
+  let directory: string;
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'journal-root-'));
+    directory = fs.mkdtempSync(path.join(os.tmpdir(), 'journal-'));
+    fs.writeFileSync(path.join(root, 'a.go'), 'package a\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+    fs.rmSync(directory, { recursive: true, force: true });
+  });
+
+  const plan = () =>
+    createPlan(root, [
+      { filePath: path.join(root, 'a.go'), original: 'package a\n', content: 'package a\n\nvar X = 1\n' },
+      { filePath: path.join(root, 'sub', 'b.go'), original: null, content: 'package sub\n' },
+    ]);
+
+  it('records a journal on disk and rolls an applied plan back', async () => {
+    const { written, journal } = await applyPlanWithJournal(plan(), root, { directory });
+
+    expect(written).toHaveLength(2);
+    expect(journal.entries.map(entry => [entry.path, entry.before])).toEqual([
+      ['a.go', 'package a\n'],
+      ['sub/b.go', null],
+    ]);
+    // A later process finds the journal by its ID
+    expect((await readJournal(journal.id, { directory })).entries).toEqual(journal.entries);
+    expect((await listJournals({ directory })).map(entry => entry.id)).toEqual([journal.id]);
+
+    const restored = await rollback(journal.id, { directory });
+
+    expect(restored).toEqual([path.join(root, 'a.go'), path.join(root, 'sub', 'b.go')]);
+    expect(fs.readFileSync(path.join(root, 'a.go'), 'utf-8')).toBe('package a\n');
+    expect(fs.existsSync(path.join(root, 'sub', 'b.go'))).toBe(false);
+    expect(fs.readdirSync(path.join(root, 'sub'))).toEqual([]);
+    await expect(rollback(journal.id, { directory })).rejects.toThrow(`No journal ${journal.id}`);
+  });
+
+  it('refuses to roll back over files edited since', async () => {
+    const { journal } = await applyPlanWithJournal(plan(), root, { directory });
+    fs.writeFileSync(path.join(root, 'sub', 'b.go'), 'package sub // edited\n');
+
+    await expect(rollback(journal.id, { directory })).rejects.toThrow(
+      `Files changed since journal ${journal.id} was recorded: sub/b.go`
+    );
+    expect(fs.readFileSync(path.join(root, 'a.go'), 'utf-8')).toBe('package a\n\nvar X = 1\n');
+    expect(await listJournals({ directory })).toHaveLength(1);
+  });
+
+  it('discards the journal of a plan that is refused', async () => {
+    const stale = plan();
+    fs.writeFileSync(path.join(root, 'a.go'), 'package a // changed\n');
+
+    await expect(applyPlanWithJournal(stale, root, { directory })).rejects.toThrow('Files changed since the plan was made');
+    expect(await listJournals({ directory })).toEqual([]);
+  });
+
+  it('journals any transform writing files', async () => {
+    const journal = await recordJournal(root, [{ path: 'a.go', content: null }], { directory });
+    fs.rmSync(path.join(root, 'a.go'));
+
+    await rollback(journal.id, { directory });
+
+    expect(fs.readFileSync(path.join(root, 'a.go'), 'utf-8')).toBe('package a\n');
+    await expect(readJournal('../escape', { directory })).rejects.toThrow(RefactorJournalError);
+  });
+});
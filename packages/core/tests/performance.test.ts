@@ -2,6 +2,8 @@ import { describe, it, expect, beforeEach } from '@jest/globals';
 import * as fs from 'fs';
 import * as path from 'path';
 import { CodebaseIndexer } from '../src/indexing';
+import { buildGoCallGraph, GoCallGraphBuilder } from '../src/go/callgraph';
+import { parseGoFile } from '../src/go/parser';
 
 describe('Performance Tests', () => {
   let indexer: CodebaseIndexer;
@@ -151,4 +153,48 @@ describe('Performance Tests', () => {
       expect(duration).toBeLessThan(5000); // Should complete within 5 seconds
     }, 10000);
   });
+
+  describe('Go Call Graph Performance', () => {
+    // 50 files of 20 functions, each calling the next function and a method
+    // declared outside the analyzed code
+    const goFiles = Array.from({ length: 50 }, (_, f) => {
+      const functions = Array.from({ length: 20 }, (_, i) => {
+        const n = f * 20 + i;
+        return `
+func F${n}(items []int) int {
+	total := 0
+	for _, item := range items {
+		if item%2 == 0 {
+			total += item
+		} else if item > ${n} {
+			total -= item
+		}
+	}
+	var s store.Store
+	s.Put(total)
+	return total + F${(n + 1) % 1000}(items[1:])
+}
+`;
+      });
+      return parseGoFile(`package bench\n\nimport "store"\n${functions.join('')}`, `bench/f${f}.go`);
+    });
+
+    it('should patch one changed file much faster than rebuilding the graph', () => {
+      const time = (run: () => void) => {
+        const start = process.hrtime.bigint();
+        for (let i = 0; i < 5; i++) run();
+        return Number(process.hrtime.bigint() - start) / 5;
+      };
+      const builder = new GoCallGraphBuilder(goFiles);
+      const changed = goFiles[25];
+
+      const full = time(() => buildGoCallGraph(goFiles));
+      const incremental = time(() => builder.update([changed]));
+
+      expect(builder.graph.nodes.size).toBe(1000);
+      // F499 in the file before calls F500, so that file is revalidated too
+      expect(builder.lastUpdate.revalidated).toEqual(['bench/f24.go']);
+      expect(incremental).toBeLessThan(full / 3);
+    }, 30000);
+  });
 });
@@ -0,0 +1,196 @@
+import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import {
+  applyPlan,
+  createPlan,
+  parsePlan,
+  RefactorPlanError,
+  renderPlan,
+  serializePlan,
+} from '../src/plan';
+import { TypeAbstraction } from '../src/type-abstraction';
+
+describe('Refactor plans', () => {
+  let root: string;
+
+  beforeEach(() => {
+    root = fs.mkdtempSync(path.join(os.tmpdir(), 'plan-'));
+    fs.writeFileSync(path.join(root, 'a.ts'), 'const a = 1;\n');
+  });
+
+  afterEach(() => {
+    fs.rmSync(root, { recursive: true, force: true });
+  });
+
+  function plan() {
+    return createPlan(root, [
+      {
+        filePath: path.join(root, 'a.ts'),
+        original: 'const a = 1;\n',
+        content: 'const a = 2;\n',
+        symbols: ['a'],
+      },
+      { filePath: path.join(root, 'types', 'B.ts'), original: null, content: 'type B = 1;\n', symbols: ['B'] },
+      { filePath: path.join(root, 'same.ts'), original: 'x\n', content: 'x\n' },
+    ]);
+  }
+
+  it('should summarize files and symbols and leave unchanged files out', () => {
+    const result = plan();
+
+    expect(result.files.map(f => [f.path, f.action])).toEqual([
+      ['a.ts', 'modify'],
+      ['types/B.ts', 'create'],
+    ]);
+    expect(result.summary).toEqual({
+      filesTouched: 2,
+      created: ['types/B.ts'],
+      modified: ['a.ts'],
+      symbols: ['B', 'a'],
+      impact: { files: 2, references: 0, exportedChanges: [], breaking: false },
+    });
+    expect(result.files[1].originalHash).toBeNull();
+  });
+
+  it('should render the summary and unified diffs', () => {
+    expect(renderPlan(plan())).toBe(
+      [
+        '2 file(s) touched: 1 created, 1 modified',
+        'Symbols affected: B, a',
+        '',
+        '--- a/a.ts',
+        '+++ b/a.ts',
+        '@@ -1 +1 @@',
+        '-const a = 1;',
+        '+const a = 2;',
+        '--- /dev/null',
+        '+++ b/types/B.ts',
+        '@@ -0,0 +1 @@',
+        '+type B = 1;',
+        '',
+      ].join('\n')
+    );
+  });
+
+  it('should serialize deterministically and round-trip', () => {
+    const text = serializePlan(plan());
+
+    expect(serializePlan(plan())).toBe(text);
+    expect(parsePlan(text)).toEqual(plan());
+    expect(() => parsePlan('{"version": 99, "files": []}')).toThrow(RefactorPlanError);
+  });
+
+  it('should apply exactly the planned content', async () => {
+    await applyPlan(parsePlan(serializePlan(plan())), root);
+
+    expect(fs.readFileSync(path.join(root, 'a.ts'), 'utf-8')).toBe('const a = 2;\n');
+    expect(fs.readFileSync(path.join(root, 'types', 'B.ts'), 'utf-8')).toBe('type B = 1;\n');
+  });
+
+  it('should refuse to parse plans writing outside the root', () => {
+    const withPath = (filePath: string) => {
+      const planned = plan();
+      planned.files[0].path = filePath;
+      return serializePlan(planned);
+    };
+
+    expect(() => parsePlan(withPath('../outside.ts'))).toThrow(
+      'Invalid refactor plan: "../outside.ts" is not a path under the plan root'
+    );
+    expect(() => parsePlan(withPath('types/../../outside.ts'))).toThrow(RefactorPlanError);
+    expect(() => parsePlan(withPath('/etc/passwd'))).toThrow(RefactorPlanError);
+    expect(() => parsePlan(withPath('C:\\Windows\\a.ts'))).toThrow(RefactorPlanError);
+    expect(parsePlan(withPath('types/../a.ts')).files[0].path).toBe('types/../a.ts');
+  });
+
+  it('should refuse to apply plans writing outside the root without writing anything', async () => {
+    const outside = path.join(path.dirname(root), `${path.basename(root)}-outside.ts`);
+    for (const filePath of [`../${path.basename(outside)}`, outside]) {
+      const escaping = plan();
+      escaping.files[1] = { ...escaping.files[1], path: filePath };
+
+      await expect(applyPlan(escaping, root)).rejects.toThrow(
+        `The plan writes ${JSON.stringify(filePath)}, which is not under ${root}`
+      );
+      expect(fs.existsSync(outside)).toBe(false);
+      expect(fs.readFileSync(path.join(root, 'a.ts'), 'utf-8')).toBe('const a = 1;\n');
+    }
+  });
+
+  it('should refuse a stale plan without writing anything', async () => {
+    const stale = plan();
+    fs.writeFileSync(path.join(root, 'a.ts'), 'const a = 3;\n');
+
+    await expect(applyPlan(stale, root)).rejects.toThrow('Files changed since the plan was made: a.ts');
+    expect(fs.existsSync(path.join(root, 'types', 'B.ts'))).toBe(false);
+  });
+
+  it('should add up impact and refuse breaking plans unless allowed', async () => {
+    const breaking = createPlan(root, [
+      {
+        filePath: path.join(root, 'a.ts'),
+        original: 'const a = 1;\n',
+        content: 'const b = 1;\n',
+        references: 2,
+        exportedChanges: ['a'],
+      },
+      { filePath: path.join(root, 'a.ts'), original: 'x', content: 'const b = 1;\n', references: 1 },
+      { filePath: path.join(root, 'c.ts'), original: null, content: 'b;\n', references: 1 },
+    ]);
+
+    expect(breaking.files[0].impact).toEqual({
+      files: 1,
+      references: 3,
+      exportedChanges: ['a'],
+      breaking: true,
+    });
+    expect(breaking.summary.impact).toEqual({
+      files: 2,
+      references: 4,
+      exportedChanges: ['a'],
+      breaking: true,
+    });
+    expect(renderPlan(breaking)).toContain(
+      'References updated: 4\nBreaking: changes exported API (a)\n'
+    );
+
+    await expect(applyPlan(breaking, root)).rejects.toThrow(
+      'The plan changes exported API (a); allow breaking changes to apply it'
+    );
+    expect(fs.readFileSync(path.join(root, 'a.ts'), 'utf-8')).toBe('const a = 1;\n');
+    await applyPlan(breaking, root, { allowBreaking: true });
+    expect(fs.readFileSync(path.join(root, 'a.ts'), 'utf-8')).toBe('const b = 1;\n');
+  });
+
+  it('should plan type abstractions without touching disk', async () => {
+    const source = 'export interface User {\n  id: string;\n}\n\nexport const user: User = { id: "1" };\n';
+    const sourceFile = path.join(root, 'user.ts');
+    fs.writeFileSync(sourceFile, source);
+    const abstraction = new TypeAbstraction({ rootPath: root });
+    const results = {
+      centralized: [],
+      local: [
+        {
+          typeName: 'User',
+          sourceFile,
+          targetFile: path.join(root, 'User.types.ts'),
+          typeContent: 'export interface User {\n  id: string;\n}\n',
+          isCentralized: false,
+          importStatements: [],
+          startLine: 0,
+          endLine: 2,
+        },
+      ],
+    };
+
+    const planned = await abstraction.planAbstractions(results);
+    expect(planned.summary).toMatchObject({ created: ['User.types.ts'], modified: ['user.ts'] });
+    expect(fs.readFileSync(sourceFile, 'utf-8')).toBe(source);
+
+    await abstraction.applyAbstractions(planned);
+    expect(fs.readFileSync(sourceFile, 'utf-8')).toBe(planned.files[1].content);
+    expect(planned.files[1].content).toContain("import type { User } from './User.types';");
+  });
+});
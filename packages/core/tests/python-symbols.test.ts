@@ -0,0 +1,128 @@
+import { describe, it, expect } from '@jest/globals';
+import * as fs from 'fs';
+import * as path from 'path';
+import { Visibility } from '../src/go/symbols';
+import { parsePython } from '../src/python/parser';
+import { PythonSyntaxError } from '../src/python/lexer';
+import { extractPythonFileSymbols, pythonVisibility } from '../src/python/symbols';
+
+const samplePath = path.join(__dirname, 'fixtures', 'python', 'sample.py');
+
+function symbolsOf(source: string) {
+  return extractPythonFileSymbols(parsePython(source, 'module.py'));
+}
+
+describe('Python symbol extraction', () => {
+  it('should extract classes, methods, functions and variables from the fixture', () => {
+    const symbols = extractPythonFileSymbols(
+      parsePython(fs.readFileSync(samplePath, 'utf-8'), samplePath)
+    );
+
+    expect(symbols.classes.map(c => [c.name, c.startLine, c.endLine, c.documentation])).toEqual([
+      ['DataProcessor', 9, 33, 'A sample data processor class'],
+    ]);
+    expect(symbols.methods.map(m => m.qualifiedName)).toEqual([
+      'DataProcessor.__init__',
+      'DataProcessor.process_data',
+      'DataProcessor._process_item',
+      'DataProcessor.get_cache_size',
+    ]);
+    expect(symbols.functions.map(f => [f.name, f.isAsync, f.startLine, f.endLine])).toEqual([
+      ['calculate_fibonacci', false, 35, 44],
+      ['async_operation', true, 46, 49],
+      ['_helper_function', false, 52, 54],
+    ]);
+    expect(symbols.variables).toMatchObject([{ name: 'API_VERSION', type: 'constant', startLine: 57 }]);
+  });
+
+  it('should record parameters, return types and complexity like the Go analyzer', () => {
+    const symbols = extractPythonFileSymbols(
+      parsePython(fs.readFileSync(samplePath, 'utf-8'), samplePath)
+    );
+    const fib = symbols.functions[0];
+    const processData = symbols.methods[1];
+
+    expect(fib.parameters).toEqual(['n']);
+    expect(fib.params).toEqual([{ name: 'n', annotation: 'int', kind: 'positional' }]);
+    expect(fib.returnType).toBe('int');
+    // if, the early return inside it, and for; the same as the Go fixture
+    expect(fib.complexity).toBe(4);
+    // for, if, and
+    expect(processData.complexity).toBe(4);
+    expect(processData.returnType).toBe('List[str]');
+  });
+
+  it('should map underscore and dunder conventions to visibility', () => {
+    expect(pythonVisibility('public')).toBe(Visibility.Exported);
+    expect(pythonVisibility('_private')).toBe(Visibility.Unexported);
+    expect(pythonVisibility('__mangled')).toBe(Visibility.Unexported);
+    expect(pythonVisibility('__init__')).toBe(Visibility.Exported);
+
+    const symbols = symbolsOf(`class _Internal:
+    def __repr__(self):
+        return "x"
+
+    def _hidden(self): pass
+`);
+    expect(symbols.classes[0]).toMatchObject({ isExported: false, isPrivate: true });
+    expect(symbols.methods.map(m => [m.name, m.visibility])).toEqual([
+      ['__repr__', Visibility.Exported],
+      ['_hidden', Visibility.Unexported],
+    ]);
+  });
+
+  it('should narrow module-level visibility to __all__', () => {
+    const symbols = symbolsOf(`__all__ = ["load"]
+
+def load(): pass
+
+def save(): pass
+`);
+
+    expect(symbols.exports).toEqual(['load']);
+    expect(symbols.functions.map(f => [f.name, f.visibility])).toEqual([
+      ['load', Visibility.Exported],
+      ['save', Visibility.Unexported],
+    ]);
+  });
+
+  it('should capture decorators and parameter kinds', () => {
+    const symbols = symbolsOf(`import functools
+
+class Cache:
+    @staticmethod
+    def key(*parts: str, sep="/", **options) -> str:
+        return sep.join(parts)
+
+    @functools.lru_cache(maxsize=128)
+    def get(self, name, /, *, default=lambda: None):
+        """Look a value up.
+
+        Falls back to the default.
+        """
+        return default() if name else None
+`);
+    const [key, get] = symbols.methods;
+
+    expect(key.decorators).toEqual([{ name: 'staticmethod', arguments: undefined, line: 4 }]);
+    expect(key.params.map(p => [p.name, p.kind, p.default])).toEqual([
+      ['parts', 'variadic', undefined],
+      ['sep', 'keyword-only', '"/"'],
+      ['options', 'keywords', undefined],
+    ]);
+    expect(get.decorators).toEqual([{ name: 'functools.lru_cache', arguments: 'maxsize=128', line: 8 }]);
+    expect(get.params.map(p => [p.name, p.kind, p.default])).toEqual([
+      ['self', 'positional', undefined],
+      ['name', 'positional', undefined],
+      ['default', 'keyword-only', 'lambda: None'],
+    ]);
+    expect(get.documentation).toBe('Look a value up.\n\nFalls back to the default.');
+    // The conditional expression
+    expect(get.complexity).toBe(2);
+  });
+
+  it('should report syntax errors with positions', () => {
+    expect(() => parsePython('def f(:\n  pass\n')).toThrow(PythonSyntaxError);
+    expect(() => parsePython('if x:\nreturn\n')).toThrow('2:1: expected an indented block');
+  });
+});